@@ -1,4 +1,6 @@
 // Package apiclient implements a client for connecting to Kopia HTTP API server.
+//
+// Typed methods for all API endpoints are provided by the serverapi package.
 package apiclient
 
 import (
@@ -13,9 +15,11 @@
 	net_url "net/url"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/internal/tlsutil"
 	"github.com/kopia/kopia/repo/logging"
@@ -28,12 +32,26 @@
 //nolint:gosec
 const CSRFTokenHeader = "X-Kopia-Csrf-Token"
 
+// InvalidCSRFTokenMessage is the error message returned by the server when the CSRF token is missing or invalid.
+//
+//nolint:gosec
+const InvalidCSRFTokenMessage = "Invalid or missing CSRF token."
+
 // KopiaAPIClient provides helper methods for communicating with Kopia API server.
+//
+// Once a session required by the UI API methods has been established using EstablishSession(), the client
+// re-establishes it whenever the server rejects the CSRF token, for example after the server has restarted.
 type KopiaAPIClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
 
-	CSRFToken string
+	// maximum number of times idempotent (GET, PUT and DELETE) requests are retried on
+	// connection errors and 429, 502, 503 and 504 responses.
+	MaxRetries int
+
+	mu sync.Mutex
+	// +checklocks:mu
+	csrfToken string
 }
 
 // Get is a helper that performs HTTP GET on a URL with the specified suffix and decodes the response
@@ -60,27 +78,59 @@ func (c *KopiaAPIClient) Delete(ctx context.Context, urlSuffix string, onNotFoun
 	return c.runRequest(ctx, http.MethodDelete, c.actualURL(urlSuffix), onNotFound, reqPayload, respPayload)
 }
 
-// FetchCSRFTokenForTesting fetches the CSRF token and session cookie for use when making subsequent calls to the API.
+// Stream is a helper that performs HTTP GET on a URL with the specified suffix and returns the response
+// body for incremental reading. The caller must close the returned reader.
+func (c *KopiaAPIClient) Stream(ctx context.Context, urlSuffix string) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.actualURL(urlSuffix), nil, "") //nolint:bodyclose
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close() //nolint:errcheck
+
+		return nil, HTTPStatusError{resp.StatusCode, respToErrorMessage(resp)}
+	}
+
+	return resp.Body, nil
+}
+
+// EstablishSession fetches the CSRF token and session cookie for use when making subsequent calls to the API.
 // This simulates the browser behavior of downloading the "/" and is required to call the UI-only methods.
-func (c *KopiaAPIClient) FetchCSRFTokenForTesting(ctx context.Context) error {
+// Once established, the session is renewed automatically when rejected by the server.
+func (c *KopiaAPIClient) EstablishSession(ctx context.Context) error {
 	var b []byte
 
 	if err := c.Get(ctx, "/", nil, &b); err != nil {
 		return err
 	}
 
-	re := regexp.MustCompile(`<meta name="kopia-csrf-token" content="(.*)" />`)
-
-	match := re.FindSubmatch(b)
+	match := csrfTokenRegexp.FindSubmatch(b)
 	if match == nil {
 		return errors.New("CSRF token not found")
 	}
 
-	c.CSRFToken = string(match[1])
+	c.mu.Lock()
+	c.csrfToken = string(match[1])
+	c.mu.Unlock()
 
 	return nil
 }
 
+// FetchCSRFTokenForTesting is the same as EstablishSession.
+func (c *KopiaAPIClient) FetchCSRFTokenForTesting(ctx context.Context) error {
+	return c.EstablishSession(ctx)
+}
+
+var csrfTokenRegexp = regexp.MustCompile(`<meta name="kopia-csrf-token" content="(.*)" />`)
+
+func (c *KopiaAPIClient) currentCSRFToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.csrfToken
+}
+
 func (c *KopiaAPIClient) actualURL(suffix string) string {
 	if strings.HasPrefix(suffix, "/") {
 		return c.BaseURL + suffix
@@ -90,18 +140,77 @@ func (c *KopiaAPIClient) actualURL(suffix string) string {
 }
 
 func (c *KopiaAPIClient) runRequest(ctx context.Context, method, url string, notFoundError error, reqPayload, respPayload interface{}) error {
-	payload, contentType, err := requestReader(reqPayload)
+	payload, contentType, err := requestBody(reqPayload)
+	if err != nil {
+		return errors.Wrap(err, "error getting request body")
+	}
+
+	resp, err := c.do(ctx, method, url, payload, contentType)
 	if err != nil {
-		return errors.Wrap(err, "error getting reader")
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode == http.StatusNotFound && notFoundError != nil {
+		return notFoundError
+	}
+
+	return decodeResponse(resp, respPayload)
+}
+
+// do sends the request, retrying idempotent requests on transient errors.
+func (c *KopiaAPIClient) do(ctx context.Context, method, url string, body []byte, contentType string) (*http.Response, error) {
+	if c.MaxRetries <= 0 || method == http.MethodPost {
+		return c.doWithSession(ctx, method, url, body, contentType)
+	}
+
+	//nolint:wrapcheck
+	return retry.WithExponentialBackoffMaxRetries(ctx, c.MaxRetries+1, method+" "+url, func() (*http.Response, error) {
+		resp, err := c.doWithSession(ctx, method, url, body, contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		if isTransientStatus(resp.StatusCode) {
+			defer resp.Body.Close() //nolint:errcheck
+
+			return nil, HTTPStatusError{resp.StatusCode, respToErrorMessage(resp)}
+		}
+
+		return resp, nil
+	}, isTransientError)
+}
+
+// doWithSession sends the request, re-establishing the session and trying again if the server rejects the CSRF token.
+func (c *KopiaAPIClient) doWithSession(ctx context.Context, method, url string, body []byte, contentType string) (*http.Response, error) {
+	resp, err := c.doOnce(ctx, method, url, body, contentType)
+	if err != nil || c.currentCSRFToken() == "" || !isInvalidCSRFTokenResponse(resp) {
+		return resp, err
+	}
+
+	resp.Body.Close() //nolint:errcheck
+
+	if err := c.EstablishSession(ctx); err != nil {
+		return nil, errors.Wrap(err, "unable to establish session")
+	}
+
+	return c.doOnce(ctx, method, url, body, contentType)
+}
+
+func (c *KopiaAPIClient) doOnce(ctx context.Context, method, url string, body []byte, contentType string) (*http.Response, error) {
+	var payload io.Reader = http.NoBody
+	if body != nil {
+		payload = bytes.NewReader(body)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, method, url, payload)
 	if err != nil {
-		return errors.Wrap(err, "error creating request")
+		return nil, errors.Wrap(err, "error creating request")
 	}
 
-	if c.CSRFToken != "" {
-		req.Header.Add(CSRFTokenHeader, c.CSRFToken)
+	if t := c.currentCSRFToken(); t != "" {
+		req.Header.Add(CSRFTokenHeader, t)
 	}
 
 	if contentType != "" {
@@ -110,25 +219,52 @@ func (c *KopiaAPIClient) runRequest(ctx context.Context, method, url string, not
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return errors.Wrap(err, "error running http request")
+		return nil, errors.Wrap(err, "error running http request")
 	}
 
-	defer resp.Body.Close() //nolint:errcheck
+	return resp, nil
+}
 
-	if resp.StatusCode == http.StatusNotFound && notFoundError != nil {
-		return notFoundError
+// isInvalidCSRFTokenResponse determines whether the server rejected the request because of the CSRF token.
+// The response body is preserved for subsequent reading.
+func isInvalidCSRFTokenResponse(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
 	}
 
-	return decodeResponse(resp, respPayload)
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close() //nolint:errcheck
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	return err == nil && strings.HasPrefix(string(b), InvalidCSRFTokenMessage)
 }
 
-func requestReader(reqPayload interface{}) (io.Reader, string, error) {
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isTransientError(err error) bool {
+	var se HTTPStatusError
+	if errors.As(err, &se) {
+		return isTransientStatus(se.HTTPStatusCode)
+	}
+
+	// connection errors.
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+func requestBody(reqPayload interface{}) ([]byte, string, error) {
 	if reqPayload == nil {
 		return nil, "", nil
 	}
 
 	if bs, ok := reqPayload.([]byte); ok {
-		return bytes.NewReader(bs), "application/octet-stream", nil
+		return bs, "application/octet-stream", nil
 	}
 
 	var b bytes.Buffer
@@ -136,7 +272,7 @@ func requestReader(reqPayload interface{}) (io.Reader, string, error) {
 		return nil, "", errors.Wrap(err, "unable to serialize JSON")
 	}
 
-	return bytes.NewReader(b.Bytes()), "application/json", nil
+	return b.Bytes(), "application/json", nil
 }
 
 // HTTPStatusError encapsulates HTTP status error.
@@ -203,6 +339,8 @@ type Options struct {
 	TrustedServerCertificateFingerprint string
 
 	LogRequests bool
+
+	MaxRetries int // maximum number of retries of idempotent requests on transient errors
 }
 
 // NewKopiaAPIClient creates a client for connecting to Kopia HTTP API.
@@ -245,12 +383,12 @@ func NewKopiaAPIClient(options Options) (*KopiaAPIClient, error) {
 	}
 
 	return &KopiaAPIClient{
-		uri,
-		&http.Client{
+		BaseURL: uri,
+		HTTPClient: &http.Client{
 			Jar:       cj,
 			Transport: transport,
 		},
-		"",
+		MaxRetries: options.MaxRetries,
 	}, nil
 }
 
@@ -0,0 +1,99 @@
+package apiclient_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestRetriesTransientErrors(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var getCount, postCount atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if getCount.Add(1) < 3 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Write([]byte(`{"value":"ok"}`)) //nolint:errcheck
+
+		default:
+			postCount.Add(1)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:    srv.URL,
+		MaxRetries: 3,
+	})
+	require.NoError(t, err)
+
+	var resp struct {
+		Value string `json:"value"`
+	}
+
+	require.NoError(t, cli.Get(ctx, "something", nil, &resp))
+	require.Equal(t, "ok", resp.Value)
+	require.EqualValues(t, 3, getCount.Load())
+
+	// non-idempotent requests are not retried.
+	require.Error(t, cli.Post(ctx, "something", &resp, &resp))
+	require.EqualValues(t, 1, postCount.Load())
+
+	// retries are exhausted.
+	getCount.Store(-10)
+	require.Error(t, cli.Get(ctx, "something", nil, &resp))
+	require.EqualValues(t, -6, getCount.Load())
+}
+
+func TestReestablishesSession(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var sessionCount atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, `<meta name="kopia-csrf-token" content="token%v" />`, sessionCount.Add(1))
+			return
+		}
+
+		// simulate server restart that invalidates the first session.
+		if r.Header.Get(apiclient.CSRFTokenHeader) != "token2" {
+			http.Error(w, apiclient.InvalidCSRFTokenMessage, http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL: srv.URL,
+	})
+	require.NoError(t, err)
+
+	// no session, no automatic renewal.
+	var hse apiclient.HTTPStatusError
+
+	require.ErrorAs(t, cli.Post(ctx, "something", &struct{}{}, &struct{}{}), &hse)
+	require.Equal(t, http.StatusUnauthorized, hse.HTTPStatusCode)
+	require.EqualValues(t, 0, sessionCount.Load())
+
+	require.NoError(t, cli.EstablishSession(ctx))
+	require.NoError(t, cli.Post(ctx, "something", &struct{}{}, &struct{}{}))
+	require.NoError(t, cli.Post(ctx, "something", &struct{}{}, &struct{}{}))
+	require.EqualValues(t, 2, sessionCount.Load())
+}
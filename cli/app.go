@@ -15,7 +15,7 @@
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/passwordpersist"
@@ -115,9 +115,12 @@ type advancedAppServices interface {
 	removeUpdateState()
 	passwordPersistenceStrategy() passwordpersist.Strategy
 	getPasswordFromFlags(ctx context.Context, isCreate, allowPersistent bool) (string, error)
+	getConnectPassword(ctx context.Context, co *connectOptions, isCreate bool) (string, error)
+	getPasswordlessKey(ctx context.Context, co *connectOptions, isCreate bool) (string, error)
 	optionsFromFlags(ctx context.Context) *repo.Options
 	runAppWithContext(command *kingpin.CmdClause, callback func(ctx context.Context) error) error
 	enableErrorNotifications() bool
+	getModuleLogLevels() *logging.ModuleLevels
 }
 
 // App contains per-invocation flags and state of Kopia CLI.
@@ -131,11 +134,13 @@ type App struct {
 	updateCheckInterval           time.Duration
 	updateAvailableNotifyInterval time.Duration
 	password                      string
+	keyFile                       string
 	configPath                    string
 	traceStorage                  bool
 	keyRingEnabled                bool
 	persistCredentials            bool
 	disableInternalLog            bool
+	cacheMemoryFallback           bool
 	dumpAllocatorStats            bool
 	AdvancedCommands              string
 	cliStorageProviders           []StorageProvider
@@ -146,29 +151,35 @@ type App struct {
 	doNotWaitForUpgrade bool
 
 	errorNotifications string
+	errorFormat        string
 
 	currentAction         string
 	onExitCallbacks       []func()
 	onFatalErrorCallbacks []func(err error)
 
 	// subcommands
+	api          commandAPI
 	blob         commandBlob
 	benchmark    commandBenchmark
 	cache        commandCache
 	content      commandContent
 	diff         commandDiff
+	verify       commandVerify
 	index        commandIndex
 	list         commandList
 	server       commandServer
 	session      commandSession
 	policy       commandPolicy
 	restore      commandRestore
+	restoreReqs  commandRestoreRequests
 	show         commandShow
 	snapshot     commandSnapshot
 	manifest     commandManifest
 	mount        commandMount
 	maintenance  commandMaintenance
+	migrate      commandMigrate
 	repository   commandRepository
+	repair       commandRepair
 	logs         commandLogs
 	notification commandNotification
 
@@ -176,12 +187,13 @@ type App struct {
 	testonlyIgnoreMissingRequiredFeatures bool
 
 	isInProcessTest bool
-	exitWithError   func(err error) // os.Exit() with 1 or 0 based on err
+	exitWithError   func(err error) // os.Exit() with exit code based on err
 	stdinReader     io.Reader
 	stdoutWriter    io.Writer
 	stderrWriter    io.Writer
 	rootctx         context.Context //nolint:containedctx
 	loggerFactory   logging.LoggerFactory
+	moduleLogLevels *logging.ModuleLevels
 	simulatedCtrlC  chan bool
 	envNamePrefix   string
 }
@@ -221,6 +233,15 @@ func (c *App) SetLoggerFactory(loggerForModule logging.LoggerFactory) {
 	c.loggerFactory = loggerForModule
 }
 
+// SetModuleLogLevels sets the per-subsystem log levels that can be adjusted at runtime.
+func (c *App) SetModuleLogLevels(l *logging.ModuleLevels) {
+	c.moduleLogLevels = l
+}
+
+func (c *App) getModuleLogLevels() *logging.ModuleLevels {
+	return c.moduleLogLevels
+}
+
 // RegisterOnExit registers the provided function to run before app exits.
 func (c *App) RegisterOnExit(f func()) {
 	c.onExitCallbacks = append(c.onExitCallbacks, f)
@@ -275,8 +296,10 @@ func (c *App) setup(app *kingpin.Application) {
 	app.Flag("trace-storage", "Enables tracing of storage operations.").Default("true").Hidden().BoolVar(&c.traceStorage)
 	app.Flag("timezone", "Format time according to specified time zone (local, utc, original or time zone name)").Hidden().StringVar(&timeZone)
 	app.Flag("password", "Repository password.").Envar(c.EnvName("KOPIA_PASSWORD")).Short('p').StringVar(&c.password)
+	app.Flag("key-file", "Read repository password or key from the provided file.").Envar(c.EnvName("KOPIA_KEY_FILE")).StringVar(&c.keyFile)
 	app.Flag("persist-credentials", "Persist credentials").Default("true").Envar(c.EnvName("KOPIA_PERSIST_CREDENTIALS_ON_CONNECT")).BoolVar(&c.persistCredentials)
 	app.Flag("disable-internal-log", "Disable internal log").Hidden().Envar(c.EnvName("KOPIA_DISABLE_INTERNAL_LOG")).BoolVar(&c.disableInternalLog)
+	app.Flag("cache-memory-fallback", "Use in-memory caches when the cache directory is missing, read-only or full.").Envar(c.EnvName("KOPIA_CACHE_MEMORY_FALLBACK")).BoolVar(&c.cacheMemoryFallback)
 	app.Flag("advanced-commands", "Enable advanced (and potentially dangerous) commands.").Hidden().Envar(c.EnvName("KOPIA_ADVANCED_COMMANDS")).StringVar(&c.AdvancedCommands)
 	app.Flag("track-releasable", "Enable tracking of releasable resources.").Hidden().Envar(c.EnvName("KOPIA_TRACK_RELEASABLE")).StringsVar(&c.trackReleasable)
 	app.Flag("dump-allocator-stats", "Dump allocator stats at the end of execution.").Hidden().Envar(c.EnvName("KOPIA_DUMP_ALLOCATOR_STATS")).BoolVar(&c.dumpAllocatorStats)
@@ -286,6 +309,10 @@ func (c *App) setup(app *kingpin.Application) {
 		Envar(c.EnvName("KOPIA_SEND_ERROR_NOTIFICATIONS")).
 		Default(errorNotificationsNonInteractive).
 		EnumVar(&c.errorNotifications, errorNotificationsAlways, errorNotificationsNever, errorNotificationsNonInteractive)
+	app.Flag("error-format", "Format of the error printed on failure, 'json' appends a machine-readable error trailer to stderr").
+		Envar(c.EnvName("KOPIA_ERROR_FORMAT")).
+		Default(errorFormatText).
+		EnumVar(&c.errorFormat, errorFormatText, errorFormatJSON)
 
 	if c.enableTestOnlyFlags() {
 		app.Flag("ignore-missing-required-features", "Open repository despite missing features (VERY DANGEROUS, ONLY FOR TESTING)").Hidden().BoolVar(&c.testonlyIgnoreMissingRequiredFeatures)
@@ -306,11 +333,13 @@ func (c *App) setup(app *kingpin.Application) {
 	c.pf.setup(app)
 	c.progress.setup(c, app)
 
+	c.api.setup(c, app)
 	c.blob.setup(c, app)
 	c.benchmark.setup(c, app)
 	c.cache.setup(c, app)
 	c.content.setup(c, app)
 	c.diff.setup(c, app)
+	c.verify.setup(c, app)
 	c.index.setup(c, app)
 	c.list.setup(c, app)
 	c.logs.setup(c, app)
@@ -318,13 +347,16 @@ func (c *App) setup(app *kingpin.Application) {
 	c.server.setup(c, app)
 	c.session.setup(c, app)
 	c.restore.setup(c, app)
+	c.restoreReqs.setup(c, app)
 	c.show.setup(c, app)
 	c.snapshot.setup(c, app)
 	c.manifest.setup(c, app)
 	c.policy.setup(c, app)
 	c.mount.setup(c, app)
 	c.maintenance.setup(c, app)
+	c.migrate.setup(c, app)
 	c.repository.setup(c, app)
+	c.repair.setup(c, app)
 }
 
 // commandParent is implemented by app and commands that can have sub-commands.
@@ -335,7 +367,8 @@ type commandParent interface {
 // NewApp creates a new instance of App.
 func NewApp() *App {
 	return &App{
-		progress: &cliProgress{},
+		progress:        &cliProgress{},
+		moduleLogLevels: logging.NewModuleLevels(),
 		cliStorageProviders: []StorageProvider{
 			{"from-config", "the provided configuration file", func() StorageFlags { return &storageFromConfigFlags{} }},
 
@@ -353,11 +386,7 @@ func NewApp() *App {
 
 		// testability hooks
 		exitWithError: func(err error) {
-			if err != nil {
-				os.Exit(1)
-			}
-
-			os.Exit(0)
+			os.Exit(int(ExitCodeForError(err)))
 		},
 		stdoutWriter: colorable.NewColorableStdout(),
 		stderrWriter: colorable.NewColorableStderr(),
@@ -535,6 +564,7 @@ func (c *App) runAppWithContext(command *kingpin.CmdClause, cb func(ctx context.
 	if err != nil {
 		// print error in red
 		log(ctx).Errorf("%v", err.Error())
+		c.printErrorTrailer(err)
 		c.exitWithError(err)
 	}
 
@@ -11,6 +11,7 @@
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/fatih/color"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
@@ -174,10 +175,16 @@ func (p *cliProgress) output(col *color.Color, msg string) {
 		return
 	}
 
-	if est, ok := p.uploadStartTime.Estimate(float64(hashedBytes+cachedBytes), float64(p.estimatedTotalBytes)); ok {
+	if est, ok := snapshotfs.EstimateUploadETA(snapshotfs.UploadCounters{
+		TotalHashedBytes:   hashedBytes,
+		TotalCachedBytes:   cachedBytes,
+		TotalUploadedBytes: uploadedBytes,
+		EstimatedBytes:     p.estimatedTotalBytes,
+	}, clock.Now(), p.uploadStartTime.Elapsed()); ok {
 		line += fmt.Sprintf(", estimated %v", units.BytesString(p.estimatedTotalBytes))
 		line += fmt.Sprintf(" (%.1f%%)", est.PercentComplete)
 		line += fmt.Sprintf(" %v left", est.Remaining)
+		line += fmt.Sprintf(", predicted upload %v", units.BytesString(est.PredictedUploadBytes))
 	} else {
 		line += ", estimating..."
 	}
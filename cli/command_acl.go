@@ -1,10 +1,12 @@
 package cli
 
 type commandServerACL struct {
-	add    commandACLAdd
-	delete commandACLDelete
-	enable commandACLEnable
-	list   commandACLList
+	add       commandACLAdd
+	delete    commandACLDelete
+	enable    commandACLEnable
+	list      commandACLList
+	grantRead commandACLGrantRead
+	grantPath commandACLGrantPath
 }
 
 func (c *commandServerACL) setup(svc appServices, parent commandParent) {
@@ -14,4 +16,6 @@ func (c *commandServerACL) setup(svc appServices, parent commandParent) {
 	c.delete.setup(svc, cmd)
 	c.enable.setup(svc, cmd)
 	c.list.setup(svc, cmd)
+	c.grantRead.setup(svc, cmd)
+	c.grantPath.setup(svc, cmd)
 }
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type commandACLGrantRead struct {
+	user      string
+	source    string
+	overwrite bool
+}
+
+func (c *commandACLGrantRead) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("grant-read", "Grant read-only access to snapshots and policies of another user")
+	cmd.Flag("user", "User receiving access (user@host, possibly including wildcards)").Required().StringVar(&c.user)
+	cmd.Flag("source", "Snapshots to grant access to (user@host or user@host:path, path may end with '/*' to include paths under it)").Required().StringVar(&c.source)
+	cmd.Flag("overwrite", "Overwrite existing rules with the same user and target").BoolVar(&c.overwrite)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandACLGrantRead) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	userAtHost, path, _ := strings.Cut(c.source, ":")
+
+	username, hostname, ok := strings.Cut(userAtHost, "@")
+	if !ok || username == "" || hostname == "" {
+		return errors.Errorf("invalid source %q, must be user@host or user@host:path", c.source)
+	}
+
+	return addSourceACLEntries(ctx, rep, c.user, username, hostname, path, acl.AccessLevelRead, c.overwrite)
+}
+
+type commandACLGrantPath struct {
+	user      string
+	path      string
+	owner     string
+	level     string
+	overwrite bool
+}
+
+func (c *commandACLGrantPath) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("grant-path", "Grant access to snapshots and policies of paths under the provided directory")
+	cmd.Flag("user", "User receiving access (user@host, possibly including wildcards)").Required().StringVar(&c.user)
+	cmd.Flag("path", "Directory, access is granted to snapshots of the directory and all paths under it").Required().StringVar(&c.path)
+	cmd.Flag("owner", "Owner of snapshots (user@host), defaults to the user receiving access").StringVar(&c.owner)
+	cmd.Flag("access", "Access the user gets to snapshots and policies").Default(acl.AccessLevelFull.String()).EnumVar(&c.level, acl.SupportedAccessLevels()...)
+	cmd.Flag("overwrite", "Overwrite existing rules with the same user and target").BoolVar(&c.overwrite)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandACLGrantPath) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	username, hostname := acl.OwnUser, acl.OwnHost
+
+	if c.owner != "" {
+		var ok bool
+
+		username, hostname, ok = strings.Cut(c.owner, "@")
+		if !ok || username == "" || hostname == "" {
+			return errors.Errorf("invalid owner %q, must be user@host", c.owner)
+		}
+	}
+
+	al, err := acl.ParseAccessLevel(c.level)
+	if err != nil {
+		return errors.Wrap(err, "invalid access level")
+	}
+
+	return addSourceACLEntries(ctx, rep, c.user, username, hostname, pathWithWildcard(c.path), al, c.overwrite)
+}
+
+// pathWithWildcard returns the path followed by separator and acl.PathWildcard, matching the path and all paths under it.
+func pathWithWildcard(p string) string {
+	sep := "/"
+	if strings.Contains(p, "\\") && !strings.Contains(p, "/") {
+		sep = "\\"
+	}
+
+	if strings.HasSuffix(p, sep+acl.PathWildcard) {
+		return p
+	}
+
+	return strings.TrimSuffix(p, sep) + sep + acl.PathWildcard
+}
+
+// addSourceACLEntries adds ACL entries granting the user access to snapshots and policies with given owner and path.
+func addSourceACLEntries(ctx context.Context, rep repo.RepositoryWriter, user, username, hostname, path string, level acl.AccessLevel, overwrite bool) error {
+	for _, typ := range []string{snapshot.ManifestType, policy.ManifestType} {
+		r := acl.TargetRule{
+			manifest.TypeLabelKey:  typ,
+			snapshot.UsernameLabel: username,
+			snapshot.HostnameLabel: hostname,
+		}
+
+		if path != "" {
+			r[snapshot.PathLabel] = path
+		}
+
+		e := &acl.Entry{
+			User:   user,
+			Target: r,
+			Access: level,
+		}
+
+		if err := acl.AddACL(ctx, rep, e, overwrite); err != nil {
+			return errors.Wrap(err, "error adding ACL entry")
+		}
+
+		log(ctx).Infof("Added ACL entry for %v: access:%v target:%v", user, level, r)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/server"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandAPI struct {
+	apiMethod  string
+	params     string
+	httpMethod string
+
+	out textOutput
+	svc advancedAppServices
+}
+
+func (c *commandAPI) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("api", "Invoke server API locally and print the JSON response, without starting a server.")
+	cmd.Arg("method", "API method path relative to /api/v1/, optionally with query parameters (e.g. 'repo/status', 'snapshots?all=1')").Required().StringVar(&c.apiMethod)
+	cmd.Arg("params", "JSON-encoded request parameters ('-' to read from stdin)").StringVar(&c.params)
+	cmd.Flag("http-method", "HTTP method to use (defaults to GET without parameters and POST with parameters)").EnumVar(&c.httpMethod, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+
+	c.out.setup(svc)
+	c.svc = svc
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandAPI) requestBody() ([]byte, error) {
+	if c.params != "-" {
+		return []byte(c.params), nil
+	}
+
+	b, err := io.ReadAll(c.svc.stdin())
+
+	return b, errors.Wrap(err, "unable to read parameters from stdin")
+}
+
+func (c *commandAPI) run(ctx context.Context, rep repo.Repository) error {
+	body, err := c.requestBody()
+	if err != nil {
+		return err
+	}
+
+	method := c.httpMethod
+	if method == "" {
+		method = http.MethodGet
+
+		if len(body) > 0 {
+			method = http.MethodPost
+		}
+	}
+
+	api, err := server.NewLocalAPI(ctx, rep, &server.Options{
+		ConfigFile:      c.svc.repositoryConfigFileName(),
+		Authorizer:      auth.DefaultAuthorizer(),
+		PasswordPersist: c.svc.passwordPersistenceStrategy(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize API")
+	}
+
+	defer api.Close(ctx)
+
+	resp, err := api.Invoke(ctx, method, c.apiMethod, body)
+
+	// error responses are JSON too, print them so that scripts can inspect the error code.
+	c.out.stdout().Write(resp) //nolint:errcheck
+
+	return errors.Wrap(err, "API error")
+}
@@ -0,0 +1,39 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestAPICommand(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--override-hostname=api-host", "--override-username=api-user")
+
+	var status serverapi.StatusResponse
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "api", "repo/status"), &status)
+	require.True(t, status.Connected)
+	require.Equal(t, "api-host", status.Hostname)
+
+	// set policy using PUT and read it back.
+	env.RunAndExpectSuccess(t, "api", "--http-method=PUT", "policy?userName=api-user&host=api-host&path=/some/path", `{"retention":{"keepLatest":7}}`)
+
+	lines := env.RunAndExpectSuccess(t, "policy", "show", "api-user@api-host:/some/path")
+	require.Contains(t, lines, "  Latest snapshots:                        7   (defined for this target)")
+
+	// errors are reported as JSON with non-zero exit code.
+	stdout, _ := env.RunAndExpectFailure(t, "api", "policy?userName=no-such-user&host=api-host&path=/other/path")
+
+	var er serverapi.ErrorResponse
+
+	testutil.MustParseJSONLines(t, stdout, &er)
+	require.Equal(t, serverapi.ErrorNotFound, er.Code)
+
+	env.RunAndExpectFailure(t, "api", "no-such-method")
+}
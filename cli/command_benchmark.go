@@ -2,7 +2,10 @@
 
 import (
 	"bytes"
+	"slices"
 	"sync"
+
+	"github.com/pkg/errors"
 )
 
 type commandBenchmark struct {
@@ -31,6 +34,21 @@ type cryptoBenchResult struct {
 	throughput float64
 }
 
+// selectAlgorithms returns the subset of supported algorithms selected by the user, all of them if none were selected.
+func selectAlgorithms(supported, selected []string) ([]string, error) {
+	if len(selected) == 0 {
+		return supported, nil
+	}
+
+	for _, s := range selected {
+		if !slices.Contains(supported, s) {
+			return nil, errors.Errorf("unsupported algorithm: %v", s)
+		}
+	}
+
+	return selected, nil
+}
+
 func runInParallelNoInputNoResult(n int, run func()) {
 	dummyArgs := make([]int, n)
 
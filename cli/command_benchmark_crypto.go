@@ -5,6 +5,7 @@
 	"sort"
 
 	atunits "github.com/alecthomas/units"
+	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/timetrack"
@@ -20,6 +21,8 @@ type commandBenchmarkCrypto struct {
 	deprecatedAlgorithms bool
 	optionPrint          bool
 	parallel             int
+	hashAlgorithms       []string
+	encryptionAlgorithms []string
 
 	out textOutput
 }
@@ -31,12 +34,31 @@ func (c *commandBenchmarkCrypto) setup(svc appServices, parent commandParent) {
 	cmd.Flag("deprecated", "Include deprecated algorithms").BoolVar(&c.deprecatedAlgorithms)
 	cmd.Flag("parallel", "Number of parallel goroutines").Default("1").IntVar(&c.parallel)
 	cmd.Flag("print-options", "Print out options usable for repository creation").BoolVar(&c.optionPrint)
+	cmd.Flag("block-hash", "Hash algorithm to benchmark (default: all supported algorithms)").StringsVar(&c.hashAlgorithms)
+	cmd.Flag("encryption", "Encryption algorithm to benchmark (default: all supported algorithms)").StringsVar(&c.encryptionAlgorithms)
 	cmd.Action(svc.noRepositoryAction(c.run))
 	c.out.setup(svc)
 }
 
 func (c *commandBenchmarkCrypto) run(ctx context.Context) error {
-	results := c.runBenchmark(ctx)
+	hashAlgorithms, err := selectAlgorithms(hashing.SupportedAlgorithms(), c.hashAlgorithms)
+	if err != nil {
+		return err
+	}
+
+	encryptionAlgorithms, err := selectAlgorithms(encryption.SupportedAlgorithms(true), c.encryptionAlgorithms)
+	if err != nil {
+		return err
+	}
+
+	if len(c.encryptionAlgorithms) == 0 && !c.deprecatedAlgorithms {
+		encryptionAlgorithms = encryption.SupportedAlgorithms(false)
+	}
+
+	results := c.runBenchmark(ctx, hashAlgorithms, encryptionAlgorithms)
+	if len(results) == 0 {
+		return errors.New("no algorithms could be benchmarked")
+	}
 
 	sort.Slice(results, func(i, j int) bool {
 		return results[i].throughput > results[j].throughput
@@ -60,13 +82,13 @@ func (c *commandBenchmarkCrypto) run(ctx context.Context) error {
 	return nil
 }
 
-func (c *commandBenchmarkCrypto) runBenchmark(ctx context.Context) []cryptoBenchResult {
+func (c *commandBenchmarkCrypto) runBenchmark(ctx context.Context, hashAlgorithms, encryptionAlgorithms []string) []cryptoBenchResult {
 	var results []cryptoBenchResult
 
 	data := make([]byte, c.blockSize)
 
-	for _, ha := range hashing.SupportedAlgorithms() {
-		for _, ea := range encryption.SupportedAlgorithms(c.deprecatedAlgorithms) {
+	for _, ha := range hashAlgorithms {
+		for _, ea := range encryptionAlgorithms {
 			fo := &format.ContentFormat{
 				Encryption: ea,
 				Hash:       ha,
@@ -76,11 +98,13 @@ func (c *commandBenchmarkCrypto) runBenchmark(ctx context.Context) []cryptoBench
 
 			hf, err := hashing.CreateHashFunc(fo)
 			if err != nil {
+				log(ctx).Warnf("unable to create hash '%v': %v", ha, err)
 				continue
 			}
 
 			enc, err := encryption.CreateEncryptor(fo)
 			if err != nil {
+				log(ctx).Warnf("unable to create encryptor '%v': %v", ea, err)
 				continue
 			}
 
@@ -5,6 +5,7 @@
 	"sort"
 
 	atunits "github.com/alecthomas/units"
+	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/timetrack"
@@ -20,6 +21,7 @@ type commandBenchmarkEncryption struct {
 	deprecatedAlgorithms bool
 	optionPrint          bool
 	parallel             int
+	algorithms           []string
 
 	out textOutput
 }
@@ -31,12 +33,25 @@ func (c *commandBenchmarkEncryption) setup(svc appServices, parent commandParent
 	cmd.Flag("deprecated", "Include deprecated algorithms").BoolVar(&c.deprecatedAlgorithms)
 	cmd.Flag("parallel", "Number of parallel goroutines").Default("1").IntVar(&c.parallel)
 	cmd.Flag("print-options", "Print out options usable for repository creation").BoolVar(&c.optionPrint)
+	cmd.Flag("algorithm", "Encryption algorithm to benchmark (default: all supported algorithms)").StringsVar(&c.algorithms)
 	cmd.Action(svc.noRepositoryAction(c.run))
 	c.out.setup(svc)
 }
 
 func (c *commandBenchmarkEncryption) run(ctx context.Context) error {
-	results := c.runBenchmark(ctx)
+	algorithms, err := selectAlgorithms(encryption.SupportedAlgorithms(true), c.algorithms)
+	if err != nil {
+		return err
+	}
+
+	if len(c.algorithms) == 0 && !c.deprecatedAlgorithms {
+		algorithms = encryption.SupportedAlgorithms(false)
+	}
+
+	results := c.runBenchmark(ctx, algorithms)
+	if len(results) == 0 {
+		return errors.New("no algorithms could be benchmarked")
+	}
 
 	sort.Slice(results, func(i, j int) bool {
 		return results[i].throughput > results[j].throughput
@@ -60,12 +75,12 @@ func (c *commandBenchmarkEncryption) run(ctx context.Context) error {
 	return nil
 }
 
-func (c *commandBenchmarkEncryption) runBenchmark(ctx context.Context) []cryptoBenchResult {
+func (c *commandBenchmarkEncryption) runBenchmark(ctx context.Context, algorithms []string) []cryptoBenchResult {
 	var results []cryptoBenchResult
 
 	data := make([]byte, c.blockSize)
 
-	for _, ea := range encryption.SupportedAlgorithms(c.deprecatedAlgorithms) {
+	for _, ea := range algorithms {
 		enc, err := encryption.CreateEncryptor(&format.ContentFormat{
 			Encryption: ea,
 			Hash:       hashing.DefaultAlgorithm,
@@ -73,6 +88,7 @@ func (c *commandBenchmarkEncryption) runBenchmark(ctx context.Context) []cryptoB
 			HMACSecret: make([]byte, 32), //nolint:mnd
 		})
 		if err != nil {
+			log(ctx).Warnf("unable to create encryptor '%v': %v", ea, err)
 			continue
 		}
 
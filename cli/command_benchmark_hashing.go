@@ -5,6 +5,7 @@
 	"sort"
 
 	atunits "github.com/alecthomas/units"
+	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/timetrack"
@@ -18,6 +19,7 @@ type commandBenchmarkHashing struct {
 	repeat      int
 	optionPrint bool
 	parallel    int
+	algorithms  []string
 
 	out textOutput
 }
@@ -28,12 +30,21 @@ func (c *commandBenchmarkHashing) setup(svc appServices, parent commandParent) {
 	cmd.Flag("repeat", "Number of repetitions").Default("10").IntVar(&c.repeat)
 	cmd.Flag("parallel", "Number of parallel goroutines").Default("1").IntVar(&c.parallel)
 	cmd.Flag("print-options", "Print out options usable for repository creation").BoolVar(&c.optionPrint)
+	cmd.Flag("algorithm", "Hash algorithm to benchmark (default: all supported algorithms)").StringsVar(&c.algorithms)
 	cmd.Action(svc.noRepositoryAction(c.run))
 	c.out.setup(svc)
 }
 
 func (c *commandBenchmarkHashing) run(ctx context.Context) error {
-	results := c.runBenchmark(ctx)
+	algorithms, err := selectAlgorithms(hashing.SupportedAlgorithms(), c.algorithms)
+	if err != nil {
+		return err
+	}
+
+	results := c.runBenchmark(ctx, algorithms)
+	if len(results) == 0 {
+		return errors.New("no algorithms could be benchmarked")
+	}
 
 	sort.Slice(results, func(i, j int) bool {
 		return results[i].throughput > results[j].throughput
@@ -57,17 +68,18 @@ func (c *commandBenchmarkHashing) run(ctx context.Context) error {
 	return nil
 }
 
-func (c *commandBenchmarkHashing) runBenchmark(ctx context.Context) []cryptoBenchResult {
+func (c *commandBenchmarkHashing) runBenchmark(ctx context.Context, algorithms []string) []cryptoBenchResult {
 	var results []cryptoBenchResult
 
 	data := make([]byte, c.blockSize)
 
-	for _, ha := range hashing.SupportedAlgorithms() {
+	for _, ha := range algorithms {
 		hf, err := hashing.CreateHashFunc(&format.ContentFormat{
 			Hash:       ha,
 			HMACSecret: make([]byte, 32), //nolint:mnd
 		})
 		if err != nil {
+			log(ctx).Warnf("unable to create hash '%v': %v", ha, err)
 			continue
 		}
 
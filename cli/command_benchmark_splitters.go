@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"math/rand"
 	"sort"
@@ -22,6 +23,9 @@ type commandBenchmarkSplitters struct {
 	blockCount  int
 	printOption bool
 	parallel    int
+	minSize     atunits.Base2Bytes
+	avgSize     atunits.Base2Bytes
+	maxSize     atunits.Base2Bytes
 
 	out textOutput
 }
@@ -34,6 +38,9 @@ func (c *commandBenchmarkSplitters) setup(svc appServices, parent commandParent)
 	cmd.Flag("block-count", "Number of data blocks to split").Default("16").IntVar(&c.blockCount)
 	cmd.Flag("print-options", "Print out the fastest dynamic splitter option").BoolVar(&c.printOption)
 	cmd.Flag("parallel", "Number of parallel goroutines").Default("1").IntVar(&c.parallel)
+	cmd.Flag("min-size", "Override minimum chunk size of dynamic splitters").BytesVar(&c.minSize)
+	cmd.Flag("avg-size", "Override average chunk size of dynamic splitters, must be a power of two").BytesVar(&c.avgSize)
+	cmd.Flag("max-size", "Override maximum chunk size of dynamic splitters").BytesVar(&c.maxSize)
 
 	cmd.Action(svc.noRepositoryAction(c.run))
 
@@ -75,14 +82,34 @@ type benchResult struct {
 		dataBlocks = append(dataBlocks, b)
 	}
 
+	params := splitter.Parameters{
+		MinSize: int(c.minSize),
+		AvgSize: int(c.avgSize),
+		MaxSize: int(c.maxSize),
+	}
+
 	log(ctx).Infof("splitting %v blocks of %v each, parallelism %v", c.blockCount, c.blockSize, c.parallel)
 
+	if !params.IsEmpty() {
+		log(ctx).Infof("overriding chunk sizes of dynamic splitters: %+v", params)
+	}
+
 	for _, sp := range splitter.SupportedAlgorithms() {
+		fact, err := splitter.GetFactoryWithParameters(sp, params)
+		if err != nil {
+			if params.IsEmpty() {
+				return errors.Wrap(err, "unable to get splitter")
+			}
+
+			// splitters that don't support the requested chunk sizes are not benchmarked.
+			log(ctx).Debugf("skipping %v: %v", sp, err)
+
+			continue
+		}
+
 		tt := timetrack.Start()
 
 		segmentLengths := runInParallelNoInput(c.parallel, func() []int {
-			fact := splitter.GetFactory(sp)
-
 			var segmentLengths []int
 
 			for _, d := range dataBlocks {
@@ -152,7 +179,22 @@ type benchResult struct {
 	}
 
 	if c.printOption {
-		c.out.printStdout("Fastest option for this machine is: --object-splitter=%s\n", best.splitter)
+		var sizeOptions string
+
+		for _, o := range []struct {
+			name string
+			size int
+		}{
+			{"min", params.MinSize},
+			{"avg", params.AvgSize},
+			{"max", params.MaxSize},
+		} {
+			if o.size != 0 {
+				sizeOptions += fmt.Sprintf(" --object-splitter-%v-size=%vB", o.name, o.size)
+			}
+		}
+
+		c.out.printStdout("Fastest option for this machine is: --object-splitter=%s%s\n", best.splitter, sizeOptions)
 	}
 
 	return nil
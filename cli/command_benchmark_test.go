@@ -17,6 +17,8 @@ func TestCommandBenchmarkCrypto(t *testing.T) {
 	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
 
 	e.RunAndExpectSuccess(t, "benchmark", "crypto", "--repeat=1", "--block-size=1KB", "--print-options")
+	e.RunAndExpectSuccess(t, "benchmark", "crypto", "--repeat=1", "--block-size=1KB", "--block-hash=BLAKE3-256", "--encryption=AES256-GCM-HMAC-SHA256")
+	e.RunAndExpectFailure(t, "benchmark", "crypto", "--repeat=1", "--block-size=1KB", "--block-hash=NO-SUCH-HASH")
 }
 
 func TestCommandBenchmarkEncryption(t *testing.T) {
@@ -44,6 +46,7 @@ func TestCommandBenchmarkSpliter(t *testing.T) {
 	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
 
 	e.RunAndExpectSuccess(t, "benchmark", "splitter", "--block-count=1", "--print-options")
+	e.RunAndExpectSuccess(t, "benchmark", "splitter", "--block-count=1", "--avg-size=64KiB", "--max-size=100KiB", "--print-options")
 }
 
 func TestCommandBenchmarkCompression(t *testing.T) {
@@ -12,19 +12,25 @@
 
 type commandBlobGC struct {
 	delete   string
+	dryRun   bool
+	verbose  bool
 	parallel int
 	prefix   string
 	safety   maintenance.SafetyParameters
 
+	jo  jsonOutput
 	svc appServices
 }
 
 func (c *commandBlobGC) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("gc", "Garbage-collect unused blobs")
 	cmd.Flag("delete", "Whether to delete unused blobs").StringVar(&c.delete)
+	cmd.Flag("dry-run", "Do not delete blobs, even if --delete=yes is specified").BoolVar(&c.dryRun)
+	cmd.Flag("verbose", "Explain in JSON format why each blob is kept or deleted").BoolVar(&c.verbose)
 	cmd.Flag("parallel", "Number of parallel blob scans").Default("16").IntVar(&c.parallel)
 	cmd.Flag("prefix", "Only GC blobs with given prefix").StringVar(&c.prefix)
 	safetyFlagVar(cmd, &c.safety)
+	c.jo.setup(svc, cmd)
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 
 	c.svc = svc
@@ -33,10 +39,29 @@ func (c *commandBlobGC) setup(svc appServices, parent commandParent) {
 func (c *commandBlobGC) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
 	c.svc.advancedCommand(ctx)
 
+	mp, err := maintenance.GetParams(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance params")
+	}
+
 	opts := maintenance.DeleteUnreferencedBlobsOptions{
-		DryRun:   c.delete != "yes",
-		Parallel: c.parallel,
-		Prefix:   blob.ID(c.prefix),
+		DryRun:     c.delete != "yes" || c.dryRun,
+		Parallel:   c.parallel,
+		Prefix:     blob.ID(c.prefix),
+		Quarantine: mp.BlobDeletionQuarantine,
+	}
+
+	if c.verbose || c.jo.jsonOutput {
+		c.jo.jsonOutput = true
+
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		opts.Explain = func(e maintenance.BlobGCExplanation) {
+			jl.emit(e)
+		}
 	}
 
 	n, err := maintenance.DeleteUnreferencedBlobs(ctx, rep, opts, c.safety)
@@ -44,7 +69,7 @@ func (c *commandBlobGC) run(ctx context.Context, rep repo.DirectRepositoryWriter
 		return errors.Wrap(err, "error deleting unreferenced blobs")
 	}
 
-	if opts.DryRun && n > 0 {
+	if opts.DryRun && n > 0 && !c.dryRun {
 		log(ctx).Info("Pass --delete=yes to delete.")
 	}
 
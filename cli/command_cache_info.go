@@ -5,6 +5,7 @@
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/pkg/errors"
@@ -30,6 +31,49 @@ func (c *commandCacheInfo) setup(svc appServices, parent commandParent) {
 	c.out.setup(svc)
 }
 
+// budgetedCacheDirs is the set of cache directories subject to the total cache size limit.
+//
+//nolint:gochecknoglobals
+var budgetedCacheDirs = map[string]bool{
+	"contents":    true,
+	"metadata":    true,
+	"index-blobs": true,
+}
+
+func (c *commandCacheInfo) printCumulativeStats(cacheDir string) error {
+	stats, err := content.ReadCumulativeCacheStats(cacheDir)
+	if err != nil {
+		return errors.Wrap(err, "error reading cache statistics")
+	}
+
+	if len(stats) == 0 {
+		return nil
+	}
+
+	var names []string
+
+	for n := range stats {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	c.out.printStdout("Cache statistics:\n")
+
+	for _, n := range names {
+		s := stats[n]
+
+		c.out.printStdout("  %v: hit ratio %.1f%% (hits: %v, %v, misses: %v, %v, evicted: %v)\n",
+			n,
+			100*s.HitRatio(), //nolint:mnd
+			s.HitCount, units.BytesString(s.HitBytes),
+			s.MissCount, units.BytesString(s.MissBytes),
+			s.EvictedCount)
+	}
+
+	return nil
+}
+
 func (c *commandCacheInfo) run(ctx context.Context, _ repo.Repository) error {
 	opts, err := repo.GetCachingOptions(ctx, c.svc.repositoryConfigFileName())
 	if err != nil {
@@ -65,6 +109,8 @@ func (c *commandCacheInfo) run(ctx context.Context, _ repo.Repository) error {
 		"server-contents": opts.MinContentSweepAge.DurationOrDefault(content.DefaultDataCacheSweepAge),
 	}
 
+	var budgetedBytes int64
+
 	for _, ent := range entries {
 		if !ent.IsDir() {
 			continue
@@ -99,6 +145,18 @@ func (c *commandCacheInfo) run(ctx context.Context, _ repo.Repository) error {
 		}
 
 		c.out.printStdout("%v: %v files %v%v\n", subdir, fileCount, units.BytesString(totalFileSize), maybeLimit)
+
+		if budgetedCacheDirs[ent.Name()] {
+			budgetedBytes += totalFileSize
+		}
+	}
+
+	if opts.TotalCacheSizeBytes > 0 {
+		c.out.printStdout("Total cache size limit: %v (in use: %v)\n", units.BytesString(opts.TotalCacheSizeBytes), units.BytesString(budgetedBytes))
+	}
+
+	if err := c.printCumulativeStats(opts.CacheDirectory); err != nil {
+		return err
 	}
 
 	c.out.printStderr("To adjust cache sizes use 'kopia cache set'.\n")
@@ -23,6 +23,8 @@ type cacheSizeFlags struct {
 
 	maxListCacheDuration time.Duration
 	indexMinSweepAge     time.Duration
+
+	totalCacheSizeMB int64
 }
 
 func (c *cacheSizeFlags) setup(cmd *kingpin.CmdClause) {
@@ -36,6 +38,7 @@ func (c *cacheSizeFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("metadata-min-sweep-age", "Minimal age of metadata cache item to be subject to sweeping").DurationVar(&c.metadataMinSweepAge)
 	cmd.Flag("index-min-sweep-age", "Minimal age of index cache item to be subject to sweeping").DurationVar(&c.indexMinSweepAge)
 	cmd.Flag("max-list-cache-duration", "Duration of index cache").DurationVar(&c.maxListCacheDuration)
+	cmd.Flag("total-cache-size-mb", "Maximum combined size of content, index and metadata caches, evicting contents first and metadata last (0 = unlimited)").PlaceHolder("MB").Int64Var(&c.totalCacheSizeMB)
 }
 
 type commandCacheSetParams struct {
@@ -57,6 +60,7 @@ func (c *commandCacheSetParams) setup(svc appServices, parent commandParent) {
 	c.contentCacheSizeMB = -1
 	c.metadataCacheSizeLimitMB = -1
 	c.metadataCacheSizeMB = -1
+	c.totalCacheSizeMB = -1
 	c.cacheSizeFlags.setup(cmd)
 
 	cmd.Flag("cache-directory", "Directory where to store cache files").StringVar(&c.directory)
@@ -107,6 +111,13 @@ func (c *commandCacheSetParams) run(ctx context.Context, _ repo.RepositoryWriter
 		changed++
 	}
 
+	if v := c.totalCacheSizeMB; v != -1 {
+		v *= 1e6 // convert MB to bytes
+		log(ctx).Infof("changing total cache size to %v", units.BytesString(v))
+		opts.TotalCacheSizeBytes = v
+		changed++
+	}
+
 	if v := c.maxListCacheDuration; v != -1 {
 		log(ctx).Infof("changing list cache duration to %v", v)
 		opts.MaxListCacheDuration = content.DurationSeconds(v.Seconds())
@@ -46,6 +46,15 @@ func TestCacheSet(t *testing.T) {
 	require.Contains(t, mustGetLineContaining(t, out, "min sweep age: 24h0m0s"), "metadata")
 
 	require.Contains(t, mustGetLineContaining(t, out, "55s"), "blob-list")
+
+	env.RunAndExpectSuccess(t,
+		"cache", "set",
+		"--cache-directory", ncd,
+		"--total-cache-size-mb=500",
+	)
+
+	out = env.RunAndExpectSuccess(t, "cache", "info")
+	mustGetLineContaining(t, out, "Total cache size limit: 500 MB")
 }
 
 func mustGetLineContaining(t *testing.T, lines []string, containing string) string {
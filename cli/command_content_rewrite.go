@@ -2,11 +2,13 @@
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/maintenance"
 )
@@ -20,6 +22,11 @@ type commandContentRewrite struct {
 	contentRewriteDryRun        bool
 	contentRewriteSafety        maintenance.SafetyParameters
 
+	contentRewriteChangeCompression string
+	contentRewriteMinAge            time.Duration
+	contentRewriteMaxBytesMB        int64
+	contentRewriteStartContentID    string
+
 	contentRange contentRangeFlags
 	svc          appServices
 }
@@ -33,6 +40,10 @@ func (c *commandContentRewrite) setup(svc appServices, parent commandParent) {
 	cmd.Flag("format-version", "Rewrite contents using the provided format version").Default("-1").IntVar(&c.contentRewriteFormatVersion)
 	cmd.Flag("pack-prefix", "Only rewrite contents from pack blobs with a given prefix").StringVar(&c.contentRewritePackPrefix)
 	cmd.Flag("dry-run", "Do not actually rewrite, only print what would happen").Short('n').BoolVar(&c.contentRewriteDryRun)
+	cmd.Flag("change-compression", "Rewrite contents not compressed using the provided compression method ('none' to decompress)").StringVar(&c.contentRewriteChangeCompression)
+	cmd.Flag("min-age", "Only rewrite contents older than the provided age, used with --change-compression").DurationVar(&c.contentRewriteMinAge)
+	cmd.Flag("max-mb", "Maximum amount of data to rewrite, used with --change-compression").Int64Var(&c.contentRewriteMaxBytesMB)
+	cmd.Flag("start-content-id", "Resume rewriting at the provided content ID, used with --change-compression").StringVar(&c.contentRewriteStartContentID)
 	c.contentRange.setup(cmd)
 	safetyFlagVar(cmd, &c.contentRewriteSafety)
 	cmd.Action(svc.directRepositoryWriteAction(c.runContentRewriteCommand))
@@ -43,6 +54,10 @@ func (c *commandContentRewrite) setup(svc appServices, parent commandParent) {
 func (c *commandContentRewrite) runContentRewriteCommand(ctx context.Context, rep repo.DirectRepositoryWriter) error {
 	c.svc.advancedCommand(ctx)
 
+	if c.contentRewriteChangeCompression != "" {
+		return c.runChangeCompression(ctx, rep)
+	}
+
 	contentIDs, err := toContentIDs(c.contentRewriteIDs)
 	if err != nil {
 		return err
@@ -60,6 +75,36 @@ func (c *commandContentRewrite) runContentRewriteCommand(ctx context.Context, re
 	}, c.contentRewriteSafety)
 }
 
+func (c *commandContentRewrite) runChangeCompression(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if len(c.contentRewriteIDs) > 0 || c.contentRewriteShortPacks {
+		return errors.New("--change-compression can't be combined with content IDs or --short")
+	}
+
+	rng := c.contentRange.contentIDRange()
+	if v := c.contentRewriteStartContentID; v != "" {
+		rng.StartID = content.IDPrefix(v)
+	}
+
+	res, err := maintenance.RecompressContents(ctx, rep, &maintenance.RecompressContentsOptions{
+		Parallel:       c.contentRewriteParallelism,
+		Compression:    compression.Name(c.contentRewriteChangeCompression),
+		ContentIDRange: rng,
+		PackPrefix:     blob.ID(c.contentRewritePackPrefix),
+		MinContentAge:  c.contentRewriteMinAge,
+		MaxBytes:       c.contentRewriteMaxBytesMB << 20, //nolint:mnd
+		DryRun:         c.contentRewriteDryRun,
+	}, c.contentRewriteSafety)
+	if err != nil {
+		return errors.Wrap(err, "error recompressing contents")
+	}
+
+	if res.NextContentID != content.EmptyID {
+		log(ctx).Infof("Reached the limit, to continue use --start-content-id=%v", res.NextContentID)
+	}
+
+	return nil
+}
+
 func toContentIDs(s []string) ([]content.ID, error) {
 	var result []content.ID
 
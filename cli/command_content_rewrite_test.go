@@ -0,0 +1,95 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestContentRewriteChangeCompression(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+	for i := range 5 {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, string(rune('a'+i))), bytes.Repeat([]byte{byte(i), 1, 2, 3}, 100000), 0o600))
+	}
+
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	zstdHeaderID := compression.ByName["zstd"].HeaderID()
+
+	countUncompressed := func() int {
+		var infos []content.Info
+
+		testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "content", "list", "--json", "--non-prefixed"), &infos)
+
+		n := 0
+
+		for _, ci := range infos {
+			if ci.CompressionHeaderID != zstdHeaderID {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	before := countUncompressed()
+	require.Positive(t, before)
+
+	env.RunAndExpectFailure(t, "content", "rewrite", "--change-compression=no-such-compression", "--safety=none")
+	env.RunAndExpectSuccess(t, "content", "rewrite", "--change-compression=zstd", "--min-age=720h", "--safety=none")
+	require.Equal(t, before, countUncompressed())
+
+	env.RunAndExpectSuccess(t, "content", "rewrite", "--change-compression=zstd", "--non-prefixed", "--dry-run", "--safety=none")
+	require.Equal(t, before, countUncompressed())
+
+	env.RunAndExpectSuccess(t, "content", "rewrite", "--change-compression=zstd", "--non-prefixed", "--safety=none")
+	require.Zero(t, countUncompressed())
+
+	env.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+}
+
+func TestMaintenanceRecompressContents(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), bytes.Repeat([]byte{1, 2, 3}, 100000), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	env.RunAndExpectFailure(t, "maintenance", "set", "--recompress-min-age=1h")
+	env.RunAndExpectFailure(t, "maintenance", "set", "--recompress-contents=no-such-compression")
+	env.RunAndExpectSuccess(t, "maintenance", "set", "--recompress-contents=zstd", "--recompress-max-mb-per-run=100")
+	require.Contains(t, env.RunAndExpectSuccess(t, "maintenance", "info"), "  compression:     zstd")
+
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none")
+
+	var infos []content.Info
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "content", "list", "--json", "--non-prefixed"), &infos)
+	require.NotEmpty(t, infos)
+
+	for _, ci := range infos {
+		require.Equal(t, compression.ByName["zstd"].HeaderID(), ci.CompressionHeaderID, ci.ContentID)
+	}
+
+	require.Contains(t, strings.Join(env.RunAndExpectSuccess(t, "maintenance", "info"), "\n"), "completed pass")
+
+	env.RunAndExpectSuccess(t, "maintenance", "set", "--recompress-contents=off")
+	require.NotContains(t, env.RunAndExpectSuccess(t, "maintenance", "info"), "Recompression:")
+}
@@ -124,7 +124,7 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 		return nil
 	}
 
-	return errors.Errorf("encountered %v errors", ec)
+	return withExitCode(ExitCodeCorruption, errors.Errorf("encountered %v errors", ec))
 }
 
 func (c *commandContentVerify) getTotalContentCount(ctx context.Context, rep repo.DirectRepository, totalCount *atomic.Int32) {
@@ -2,13 +2,17 @@
 
 import (
 	"context"
+	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/internal/diff"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
@@ -17,14 +21,18 @@ type commandDiff struct {
 	diffSecondObjectPath string
 	diffCompareFiles     bool
 	diffCommandCommand   string
+	diffAgainstLocal     string
+	diffCompareContents  bool
 
 	out textOutput
 }
 
 func (c *commandDiff) setup(svc appServices, parent commandParent) {
-	cmd := parent.Command("diff", "Displays differences between two repository objects (files or directories)").Alias("compare")
+	cmd := parent.Command("diff", "Displays differences between two repository objects (files or directories) or between repository object and local directory").Alias("compare")
 	cmd.Arg("object-path1", "First object/path").Required().StringVar(&c.diffFirstObjectPath)
-	cmd.Arg("object-path2", "Second object/path").Required().StringVar(&c.diffSecondObjectPath)
+	cmd.Arg("object-path2", "Second object/path").StringVar(&c.diffSecondObjectPath)
+	cmd.Flag("against-local", "Compare the object with the provided directory in the local filesystem instead of second object").StringVar(&c.diffAgainstLocal)
+	cmd.Flag("compare-contents", "When comparing against local filesystem, compare file contents and not just metadata").BoolVar(&c.diffCompareContents)
 	cmd.Flag("files", "Compare files by launching diff command for all pairs of (old,new)").Short('f').BoolVar(&c.diffCompareFiles)
 	cmd.Flag("diff-command", "Displays differences between two repository objects (files or directories)").Default(defaultDiffCommand()).Envar(svc.EnvName("KOPIA_DIFF")).StringVar(&c.diffCommandCommand)
 	cmd.Action(svc.repositoryReaderAction(c.run))
@@ -33,14 +41,17 @@ func (c *commandDiff) setup(svc appServices, parent commandParent) {
 }
 
 func (c *commandDiff) run(ctx context.Context, rep repo.Repository) error {
+	// directories shared by both trees are only decoded once.
+	snapshotfs.EnableDirectoryCache(snapshotfs.DefaultDirectoryCacheEntries)
+
 	ent1, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, c.diffFirstObjectPath, false)
 	if err != nil {
 		return errors.Wrapf(err, "error getting filesystem entry for %v", c.diffFirstObjectPath)
 	}
 
-	ent2, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, c.diffSecondObjectPath, false)
+	ent2, err := c.secondEntry(ctx, rep)
 	if err != nil {
-		return errors.Wrapf(err, "error getting filesystem entry for %v", c.diffSecondObjectPath)
+		return err
 	}
 
 	_, isDir1 := ent1.(fs.Directory)
@@ -62,13 +73,69 @@ func (c *commandDiff) run(ctx context.Context, rep repo.Repository) error {
 		d.DiffArguments = parts[1:]
 	}
 
+	d.CompareContents = c.diffCompareContents
+
 	if isDir1 {
-		return errors.Wrap(d.Compare(ctx, ent1, ent2), "error comparing directories")
+		if err := d.Compare(ctx, ent1, ent2); err != nil {
+			return errors.Wrap(err, "error comparing directories")
+		}
+
+		if c.diffAgainstLocal != "" {
+			c.out.printStdout("Summary: %v added, %v removed, %v changed, %v unchanged files; %v added, %v removed directories.\n",
+				d.Stats.AddedFiles, d.Stats.RemovedFiles, d.Stats.ChangedFiles, d.Stats.UnchangedFiles,
+				d.Stats.AddedDirectories, d.Stats.RemovedDirectories)
+		}
+
+		return nil
 	}
 
 	return errors.New("comparing files not implemented yet")
 }
 
+// secondEntry returns the second entry to compare, which is either a repository object or a local directory
+// filtered using ignore rules of the snapshot policy, so that files excluded from snapshots are not reported.
+func (c *commandDiff) secondEntry(ctx context.Context, rep repo.Repository) (fs.Entry, error) {
+	if c.diffAgainstLocal == "" {
+		if c.diffSecondObjectPath == "" {
+			return nil, errors.New("second object/path or --against-local is required")
+		}
+
+		ent, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, c.diffSecondObjectPath, false)
+
+		return ent, errors.Wrapf(err, "error getting filesystem entry for %v", c.diffSecondObjectPath)
+	}
+
+	if c.diffSecondObjectPath != "" {
+		return nil, errors.New("second object/path cannot be used with --against-local")
+	}
+
+	localPath, err := filepath.Abs(c.diffAgainstLocal)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid local path")
+	}
+
+	ent, err := getLocalFSEntry(ctx, localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, ok := ent.(fs.Directory)
+	if !ok {
+		return ent, nil
+	}
+
+	policyTree, err := policy.TreeForSource(ctx, rep, snapshot.SourceInfo{
+		Path:     filepath.Clean(localPath),
+		Host:     rep.ClientOptions().Hostname,
+		UserName: rep.ClientOptions().Username,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get policy tree")
+	}
+
+	return ignorefs.New(dir, policyTree), nil
+}
+
 func defaultDiffCommand() string {
 	if isWindows() {
 		return "cmp"
@@ -1,11 +1,15 @@
 package cli
 
 type commandIndexEpoch struct {
-	list commandIndexEpochList
+	list    commandIndexEpochList
+	status  commandIndexEpochStatus
+	advance commandIndexEpochAdvance
 }
 
 func (c *commandIndexEpoch) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("epoch", "Manage index manager epochs").Hidden()
 
 	c.list.setup(svc, cmd)
+	c.status.setup(svc, cmd)
+	c.advance.setup(svc, cmd)
 }
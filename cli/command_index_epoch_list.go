@@ -23,13 +23,9 @@ func (c *commandIndexEpochList) setup(svc appServices, parent commandParent) {
 }
 
 func (c *commandIndexEpochList) run(ctx context.Context, rep repo.DirectRepository) error {
-	emgr, ok, err := rep.ContentReader().EpochManager(ctx)
+	emgr, err := epochManagerOf(ctx, rep)
 	if err != nil {
-		return errors.Wrap(err, "epoch manager")
-	}
-
-	if !ok {
-		return errors.New("epoch manager is not active")
+		return err
 	}
 
 	snap, err := emgr.Current(ctx)
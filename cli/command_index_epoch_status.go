@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandIndexEpochStatus struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandIndexEpochStatus) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("status", "Show the state of the epoch manager.")
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+}
+
+func epochManagerOf(ctx context.Context, rep repo.DirectRepository) (*epoch.Manager, error) {
+	emgr, ok, err := rep.ContentReader().EpochManager(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "epoch manager")
+	}
+
+	if !ok {
+		return nil, errors.New("epoch manager is not active")
+	}
+
+	return emgr, nil
+}
+
+func (c *commandIndexEpochStatus) run(ctx context.Context, rep repo.DirectRepository) error {
+	emgr, err := epochManagerOf(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	s, err := emgr.Status(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get epoch status")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(s))
+		return nil
+	}
+
+	now := rep.Time()
+
+	c.out.printStdout("Write epoch:        %v\n", s.WriteEpoch)
+
+	if !s.WriteEpochStartTime.IsZero() {
+		c.out.printStdout("Epoch started:      %v (%v ago)\n", formatTimestamp(s.WriteEpochStartTime), now.Sub(s.WriteEpochStartTime).Round(time.Second))
+	}
+
+	c.out.printStdout("Should advance:     %v\n", s.ShouldAdvance)
+
+	if !s.DeletionWatermark.IsZero() {
+		c.out.printStdout("Deletion watermark: %v\n", formatTimestamp(s.DeletionWatermark))
+	}
+
+	c.out.printStdout("\nEpochs:\n")
+
+	for _, es := range s.Epochs {
+		state := "unsettled"
+
+		switch {
+		case es.Compacted:
+			state = "compacted"
+		case es.Settled:
+			state = "settled, not compacted"
+		}
+
+		c.out.printStdout("  %v: %v uncompacted blobs, %v, %v\n", es.Epoch, es.UncompactedBlobs, units.BytesString(es.UncompactedBytes), state)
+	}
+
+	if len(s.RangeCheckpoints) > 0 {
+		c.out.printStdout("\nRange checkpoints:\n")
+
+		for _, r := range s.RangeCheckpoints {
+			c.out.printStdout("  %v-%v: %v blobs, %v, created %v (%v ago)\n",
+				r.MinEpoch, r.MaxEpoch, r.Blobs, units.BytesString(r.TotalBytes),
+				formatTimestamp(r.CreatedTime), now.Sub(r.CreatedTime).Round(time.Second))
+		}
+	}
+
+	return nil
+}
+
+type commandIndexEpochAdvance struct {
+	compact bool
+
+	out textOutput
+}
+
+func (c *commandIndexEpochAdvance) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("advance", "Force advancement of the write epoch.")
+	cmd.Flag("compact", "Also compact all settled epochs").BoolVar(&c.compact)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandIndexEpochAdvance) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	emgr, err := epochManagerOf(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	ep, err := emgr.ForceAdvanceWriteEpoch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to advance epoch")
+	}
+
+	c.out.printStderr("Advanced write epoch to %v.\n", ep)
+
+	if !c.compact {
+		return nil
+	}
+
+	n, err := emgr.CompactSettledEpochs(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to compact settled epochs")
+	}
+
+	c.out.printStderr("Compacted %v settled epochs.\n", n)
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestIndexEpochStatusAndAdvance(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	var s epoch.Status
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "index", "epoch", "status", "--json"), &s)
+	require.Equal(t, 0, s.WriteEpoch)
+
+	env.RunAndExpectSuccess(t, "index", "epoch", "status")
+
+	for range 3 {
+		env.RunAndExpectSuccess(t, "index", "epoch", "advance")
+	}
+
+	env.RunAndExpectSuccess(t, "index", "epoch", "advance", "--compact")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "index", "epoch", "status", "--json"), &s)
+	require.Equal(t, 4, s.WriteEpoch)
+
+	var compacted int
+
+	for _, es := range s.Epochs {
+		if es.Compacted {
+			compacted++
+		}
+	}
+
+	require.NotZero(t, compacted)
+}
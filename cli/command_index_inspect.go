@@ -3,6 +3,7 @@
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
@@ -21,8 +22,10 @@ type commandIndexInspect struct {
 
 	contentIDs []string
 	parallel   int
+	summary    bool
 
 	out textOutput
+	jo  jsonOutput
 }
 
 func (c *commandIndexInspect) setup(svc appServices, parent commandParent) {
@@ -31,10 +34,12 @@ func (c *commandIndexInspect) setup(svc appServices, parent commandParent) {
 	cmd.Flag("active", "Inspect all active index blobs").BoolVar(&c.active)
 	cmd.Flag("content-id", "Inspect all active index blobs").StringsVar(&c.contentIDs)
 	cmd.Flag("parallel", "Parallelism").Default("8").IntVar(&c.parallel)
+	cmd.Flag("summary", "Print per-index statistics, overlap statistics and detected anomalies instead of individual entries").BoolVar(&c.summary)
 	cmd.Arg("blobs", "Names of index blobs to inspect").StringsVar(&c.blobIDs)
 	cmd.Action(svc.directRepositoryReadAction(c.run))
 
 	c.out.setup(svc)
+	c.jo.setup(svc, cmd)
 }
 
 func (c *commandIndexInspect) run(ctx context.Context, rep repo.DirectRepository) error {
@@ -44,17 +49,29 @@ func (c *commandIndexInspect) run(ctx context.Context, rep repo.DirectRepository
 
 	wg.Add(1)
 
+	var summ *indexInspectSummary
+
 	go func() {
 		defer wg.Done()
 
-		c.dumpIndexBlobEntries(output)
+		if c.summary {
+			summ = c.summarizeIndexBlobEntries(output)
+		} else {
+			c.dumpIndexBlobEntries(output)
+		}
 	}()
 
 	err := c.runWithOutput(ctx, rep, output)
 	close(output)
 	wg.Wait()
 
-	return err
+	if err != nil || summ == nil {
+		return err
+	}
+
+	c.printSummary(summ)
+
+	return nil
 }
 
 func (c *commandIndexInspect) runWithOutput(ctx context.Context, rep repo.DirectRepository, output chan indexBlobPlusContentInfo) error {
@@ -108,6 +125,11 @@ func (c *commandIndexInspect) inspectAllBlobs(ctx context.Context, rep repo.Dire
 }
 
 func (c *commandIndexInspect) dumpIndexBlobEntries(entries chan indexBlobPlusContentInfo) {
+	var jl jsonList
+
+	jl.begin(&c.jo)
+	defer jl.end()
+
 	for ent := range entries {
 		ci := ent.contentInfo
 		bm := ent.indexBlob
@@ -121,6 +143,11 @@ func (c *commandIndexInspect) dumpIndexBlobEntries(entries chan indexBlobPlusCon
 			continue
 		}
 
+		if c.jo.jsonOutput {
+			jl.emit(indexInspectEntry{bm.BlobID, bm.Timestamp, ent.formatVersion, ci})
+			continue
+		}
+
 		c.out.printStdout("%v %v %v %v %v %v %v %v\n",
 			formatTimestampPrecise(bm.Timestamp), bm.BlobID,
 			ci.ContentID, state, formatTimestampPrecise(ci.Timestamp()), ci.PackBlobID, ci.PackOffset, ci.PackedLength)
@@ -144,8 +171,17 @@ func (c *commandIndexInspect) shouldInclude(ci content.Info) bool {
 }
 
 type indexBlobPlusContentInfo struct {
-	indexBlob   blob.Metadata
-	contentInfo content.Info
+	indexBlob     blob.Metadata
+	formatVersion int
+	contentInfo   content.Info
+}
+
+// indexInspectEntry is the JSON representation of a single index entry.
+type indexInspectEntry struct {
+	IndexBlobID        blob.ID   `json:"indexBlobID"`
+	IndexBlobTimestamp time.Time `json:"indexBlobTimestamp"`
+	IndexFormatVersion int       `json:"indexFormatVersion"`
+	content.Info
 }
 
 func (c *commandIndexInspect) inspectSingleIndexBlob(ctx context.Context, rep repo.DirectRepository, blobID blob.ID, output chan indexBlobPlusContentInfo) error {
@@ -163,13 +199,13 @@ func (c *commandIndexInspect) inspectSingleIndexBlob(ctx context.Context, rep re
 		return errors.Wrapf(err, "unable to get data for %v", blobID)
 	}
 
-	entries, err := content.ParseIndexBlob(blobID, data.Bytes(), rep.ContentReader().ContentFormat())
+	version, entries, err := content.ParseIndexBlobWithVersion(blobID, data.Bytes(), rep.ContentReader().ContentFormat())
 	if err != nil {
 		return errors.Wrapf(err, "unable to recover index from %v", blobID)
 	}
 
 	for _, ent := range entries {
-		output <- indexBlobPlusContentInfo{bm, ent}
+		output <- indexBlobPlusContentInfo{bm, version, ent}
 	}
 
 	return nil
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// Types of anomalies detected by 'index inspect --summary'.
+const (
+	indexAnomalyDuplicateAcrossEpochs = "duplicate-across-epochs"
+	indexAnomalyConflictingEntries    = "conflicting-entries"
+)
+
+// maxReportedIndexAnomalies limits the number of anomalies included in the summary.
+const maxReportedIndexAnomalies = 1000
+
+type indexBlobSummary struct {
+	BlobID            blob.ID   `json:"blobID"`
+	Timestamp         time.Time `json:"timestamp"`
+	Length            int64     `json:"length"`
+	FormatVersion     int       `json:"formatVersion"`
+	MinEpoch          *int      `json:"minEpoch,omitempty"`
+	MaxEpoch          *int      `json:"maxEpoch,omitempty"`
+	Entries           int       `json:"entries"`
+	DeletedEntries    int       `json:"deletedEntries"`
+	Packs             int       `json:"packs"`
+	MaxEntriesPerPack int       `json:"maxEntriesPerPack"`
+
+	entriesPerPack map[blob.ID]int
+}
+
+type indexAnomaly struct {
+	Type       string     `json:"type"`
+	ContentID  content.ID `json:"contentID"`
+	IndexBlobs []blob.ID  `json:"indexBlobs"`
+	Details    string     `json:"details"`
+}
+
+type indexInspectSummary struct {
+	IndexBlobs []*indexBlobSummary `json:"indexBlobs"`
+
+	TotalEntries        int `json:"totalEntries"`
+	UniqueContents      int `json:"uniqueContents"`
+	OverlappingContents int `json:"overlappingContents"` // contents present in more than one index blob
+	DuplicateEntries    int `json:"duplicateEntries"`    // entries identical to an entry in another index blob
+
+	AnomalyCount int            `json:"anomalyCount"`
+	Anomalies    []indexAnomaly `json:"anomalies,omitempty"`
+}
+
+type indexEntryLocation struct {
+	indexBlob *indexBlobSummary
+	info      content.Info
+}
+
+func sameIndexEntry(a, b content.Info) bool {
+	return a.PackBlobID == b.PackBlobID &&
+		a.PackOffset == b.PackOffset &&
+		a.PackedLength == b.PackedLength &&
+		a.TimestampSeconds == b.TimestampSeconds &&
+		a.Deleted == b.Deleted
+}
+
+// epochRangesDisjoint returns true if both index blobs belong to known epochs and their epoch ranges do not overlap.
+func epochRangesDisjoint(a, b *indexBlobSummary) bool {
+	if a.MinEpoch == nil || b.MinEpoch == nil {
+		return false
+	}
+
+	return *a.MaxEpoch < *b.MinEpoch || *b.MaxEpoch < *a.MinEpoch
+}
+
+func (c *commandIndexInspect) summarizeIndexBlobEntries(entries chan indexBlobPlusContentInfo) *indexInspectSummary {
+	summ := &indexInspectSummary{}
+	byBlob := map[blob.ID]*indexBlobSummary{}
+	byContent := map[content.ID][]indexEntryLocation{}
+
+	for ent := range entries {
+		ibs := byBlob[ent.indexBlob.BlobID]
+		if ibs == nil {
+			ibs = &indexBlobSummary{
+				BlobID:         ent.indexBlob.BlobID,
+				Timestamp:      ent.indexBlob.Timestamp,
+				Length:         ent.indexBlob.Length,
+				FormatVersion:  ent.formatVersion,
+				entriesPerPack: map[blob.ID]int{},
+			}
+
+			if minEpoch, maxEpoch, ok := epoch.EpochRangeFromIndexBlobID(ent.indexBlob.BlobID); ok {
+				ibs.MinEpoch = &minEpoch
+				ibs.MaxEpoch = &maxEpoch
+			}
+
+			byBlob[ent.indexBlob.BlobID] = ibs
+			summ.IndexBlobs = append(summ.IndexBlobs, ibs)
+		}
+
+		ci := ent.contentInfo
+
+		ibs.Entries++
+		summ.TotalEntries++
+
+		if ci.Deleted {
+			ibs.DeletedEntries++
+		}
+
+		ibs.entriesPerPack[ci.PackBlobID]++
+
+		if c.shouldInclude(ci) {
+			byContent[ci.ContentID] = append(byContent[ci.ContentID], indexEntryLocation{ibs, ci})
+		}
+	}
+
+	for _, ibs := range summ.IndexBlobs {
+		ibs.Packs = len(ibs.entriesPerPack)
+
+		for _, cnt := range ibs.entriesPerPack {
+			ibs.MaxEntriesPerPack = max(ibs.MaxEntriesPerPack, cnt)
+		}
+	}
+
+	sort.Slice(summ.IndexBlobs, func(i, j int) bool {
+		return summ.IndexBlobs[i].BlobID < summ.IndexBlobs[j].BlobID
+	})
+
+	summ.UniqueContents = len(byContent)
+
+	for cid, locs := range byContent {
+		if len(locs) > 1 {
+			summ.OverlappingContents++
+		}
+
+		for i := 1; i < len(locs); i++ {
+			for j := range i {
+				c.checkIndexEntryPair(summ, cid, locs[j], locs[i])
+			}
+		}
+	}
+
+	sort.Slice(summ.Anomalies, func(i, j int) bool {
+		return summ.Anomalies[i].ContentID.String() < summ.Anomalies[j].ContentID.String()
+	})
+
+	return summ
+}
+
+func (c *commandIndexInspect) checkIndexEntryPair(summ *indexInspectSummary, cid content.ID, a, b indexEntryLocation) {
+	var anomaly *indexAnomaly
+
+	switch {
+	case sameIndexEntry(a.info, b.info):
+		summ.DuplicateEntries++
+
+		// identical entries are expected in compacted indexes covering the same epochs,
+		// but an entry copied into an unrelated epoch indicates a problem.
+		if epochRangesDisjoint(a.indexBlob, b.indexBlob) {
+			anomaly = &indexAnomaly{
+				Type:    indexAnomalyDuplicateAcrossEpochs,
+				Details: fmt.Sprintf("identical entry in epochs %v-%v and %v-%v", *a.indexBlob.MinEpoch, *a.indexBlob.MaxEpoch, *b.indexBlob.MinEpoch, *b.indexBlob.MaxEpoch),
+			}
+		}
+
+	case a.info.TimestampSeconds == b.info.TimestampSeconds && !a.info.Deleted && !b.info.Deleted:
+		anomaly = &indexAnomaly{
+			Type:    indexAnomalyConflictingEntries,
+			Details: fmt.Sprintf("same timestamp, different locations %v@%v and %v@%v", a.info.PackBlobID, a.info.PackOffset, b.info.PackBlobID, b.info.PackOffset),
+		}
+	}
+
+	if anomaly == nil {
+		return
+	}
+
+	summ.AnomalyCount++
+
+	if len(summ.Anomalies) < maxReportedIndexAnomalies {
+		anomaly.ContentID = cid
+		anomaly.IndexBlobs = []blob.ID{a.indexBlob.BlobID, b.indexBlob.BlobID}
+		summ.Anomalies = append(summ.Anomalies, *anomaly)
+	}
+}
+
+func (c *commandIndexInspect) printSummary(summ *indexInspectSummary) {
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(summ))
+		return
+	}
+
+	for _, ibs := range summ.IndexBlobs {
+		epochs := "-"
+		if ibs.MinEpoch != nil {
+			epochs = fmt.Sprintf("%v-%v", *ibs.MinEpoch, *ibs.MaxEpoch)
+		}
+
+		c.out.printStdout("%v %v v%v epochs:%v entries:%v deleted:%v packs:%v max-per-pack:%v\n",
+			ibs.BlobID, formatTimestamp(ibs.Timestamp), ibs.FormatVersion, epochs,
+			ibs.Entries, ibs.DeletedEntries, ibs.Packs, ibs.MaxEntriesPerPack)
+	}
+
+	c.out.printStdout("\n")
+	c.out.printStdout("Index blobs:          %v\n", len(summ.IndexBlobs))
+	c.out.printStdout("Total entries:        %v\n", summ.TotalEntries)
+	c.out.printStdout("Unique contents:      %v\n", summ.UniqueContents)
+	c.out.printStdout("Overlapping contents: %v\n", summ.OverlappingContents)
+	c.out.printStdout("Duplicate entries:    %v\n", summ.DuplicateEntries)
+	c.out.printStdout("Anomalies:            %v\n", summ.AnomalyCount)
+
+	for _, a := range summ.Anomalies {
+		c.out.printStdout("  %v %v in %v: %v\n", a.Type, a.ContentID, a.IndexBlobs, a.Details)
+	}
+}
@@ -6,6 +6,7 @@
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/tests/testenv"
 )
 
@@ -27,6 +28,26 @@ func (s *formatSpecificTestSuite) TestIndexInspect(t *testing.T) {
 	env.RunAndExpectSuccess(t, "content", "rewrite", someContentID, "--safety=none")
 	require.Len(t, env.RunAndExpectSuccess(t, "index", "inspect", "--all", "--content-id", someContentID), 2)
 
+	// JSON output of entries.
+	var entries []map[string]any
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "index", "inspect", "--all", "--json", "--content-id", someContentID), &entries)
+	require.Len(t, entries, 2)
+	require.Equal(t, someContentID, entries[0]["contentID"])
+	require.NotZero(t, entries[0]["indexFormatVersion"])
+
+	// summary with overlap statistics.
+	var summ map[string]any
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "index", "inspect", "--all", "--summary", "--json"), &summ)
+	require.NotEmpty(t, summ["indexBlobs"])
+	require.Positive(t, summ["totalEntries"])
+	require.Positive(t, summ["overlappingContents"])
+	require.Zero(t, summ["anomalyCount"])
+
+	lines := env.RunAndExpectSuccess(t, "index", "inspect", "--all", "--summary")
+	require.Contains(t, lines, "Anomalies:            0")
+
 	// no targets specified
 	env.RunAndExpectFailure(t, "index", "inspect")
 }
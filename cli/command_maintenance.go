@@ -4,6 +4,8 @@ type commandMaintenance struct {
 	info commandMaintenanceInfo
 	run  commandMaintenanceRun
 	set  commandMaintenanceSet
+
+	undelete commandMaintenanceUndelete
 }
 
 func (c *commandMaintenance) setup(svc appServices, parent commandParent) {
@@ -12,4 +14,5 @@ func (c *commandMaintenance) setup(svc appServices, parent commandParent) {
 	c.info.setup(svc, cmd)
 	c.run.setup(svc, cmd)
 	c.set.setup(svc, cmd)
+	c.undelete.setup(svc, cmd)
 }
@@ -72,10 +72,49 @@ func (c *commandMaintenanceInfo) run(ctx context.Context, rep repo.DirectReposit
 		c.out.printStdout("Object Lock Extension: disabled\n")
 	}
 
+	if p.SnapshotLegalHold {
+		c.out.printStdout("Snapshot Legal Hold: enabled\n")
+	}
+
 	if p.ListParallelism != 0 {
 		c.out.printStdout("List parallelism: %v\n", p.ListParallelism)
 	}
 
+	if rp := p.Recompression; rp != nil {
+		c.out.printStdout("Recompression:\n")
+		c.out.printStdout("  compression:     %v\n", rp.Compression)
+		c.out.printStdout("  min age:         %v\n", rp.MinAge)
+
+		if rp.MaxBytesPerRun > 0 {
+			c.out.printStdout("  max per run:     %v\n", units.BytesString(rp.MaxBytesPerRun))
+		}
+
+		if prog := s.Recompression; prog != nil && prog.Compression == rp.Compression {
+			if prog.Completed {
+				c.out.printStdout("  progress:        completed pass started %v\n", formatTimestamp(prog.PassStartTime))
+			} else {
+				c.out.printStdout("  progress:        pass started %v, next content %q\n", formatTimestamp(prog.PassStartTime), prog.NextContentID)
+			}
+		}
+	}
+
+	if p.BlobDeletionQuarantine > 0 {
+		q, err := maintenance.GetBlobQuarantine(ctx, rep)
+		if err != nil {
+			return errors.Wrap(err, "unable to get blob quarantine")
+		}
+
+		var totalBytes int64
+
+		for _, qb := range q.Blobs {
+			totalBytes += qb.Length
+		}
+
+		c.out.printStdout("Blob Deletion Quarantine:\n")
+		c.out.printStdout("  period:          %v\n", p.BlobDeletionQuarantine)
+		c.out.printStdout("  quarantined:     %v blobs (%v)\n", len(q.Blobs), units.BytesString(totalBytes))
+	}
+
 	c.out.printStdout("Recent Maintenance Runs:\n")
 
 	for run, timings := range s.Runs {
@@ -8,6 +8,7 @@
 
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/maintenance"
 )
 
@@ -27,6 +28,12 @@ type commandMaintenanceSet struct {
 	extendObjectLocks []bool // optional boolean
 
 	listParallelism int
+
+	recompressContents         string
+	recompressMinAge           time.Duration
+	recompressMaxBytesPerRunMB int64
+
+	blobDeletionQuarantine time.Duration
 }
 
 func (c *commandMaintenanceSet) setup(svc appServices, parent commandParent) {
@@ -43,6 +50,11 @@ func (c *commandMaintenanceSet) setup(svc appServices, parent commandParent) {
 
 	c.listParallelism = -1
 
+	c.recompressMinAge = -1
+	c.recompressMaxBytesPerRunMB = -1
+
+	c.blobDeletionQuarantine = -1
+
 	cmd.Flag("owner", "Set maintenance owner user@hostname").StringVar(&c.maintenanceSetOwner)
 
 	cmd.Flag("enable-quick", "Enable or disable quick maintenance").BoolListVar(&c.maintenanceSetEnableQuick)
@@ -60,6 +72,11 @@ func (c *commandMaintenanceSet) setup(svc appServices, parent commandParent) {
 	cmd.Flag("extend-object-locks", "Extend retention period of locked objects as part of full maintenance.").BoolListVar(&c.extendObjectLocks)
 
 	cmd.Flag("list-parallelism", "Override list parallelism.").IntVar(&c.listParallelism)
+	cmd.Flag("blob-deletion-quarantine", "Keep unreferenced blobs in quarantine for the provided duration before deleting them, allowing them to be undeleted (0 to delete immediately)").DurationVar(&c.blobDeletionQuarantine)
+
+	cmd.Flag("recompress-contents", "Gradually recompress existing contents using the provided compression as part of full maintenance ('off' to disable)").StringVar(&c.recompressContents)
+	cmd.Flag("recompress-min-age", "Only recompress contents older than the provided age").DurationVar(&c.recompressMinAge)
+	cmd.Flag("recompress-max-mb-per-run", "Maximum amount of data to recompress in a single maintenance run").Int64Var(&c.recompressMaxBytesPerRunMB)
 
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 }
@@ -100,6 +117,67 @@ func (c *commandMaintenanceSet) setDeleteUnreferencedBlobsParams(ctx context.Con
 
 		log(ctx).Infof("Setting list parallelism to %v.", v)
 	}
+
+	if v := c.blobDeletionQuarantine; v != -1 {
+		p.BlobDeletionQuarantine = v
+		*changed = true
+
+		if v == 0 {
+			log(ctx).Info("Blob deletion quarantine disabled.")
+		} else {
+			log(ctx).Infof("Setting blob deletion quarantine to %v.", v)
+		}
+	}
+}
+
+func (c *commandMaintenanceSet) setRecompressionParamsFromFlags(ctx context.Context, p *maintenance.Params, changed *bool) error {
+	if v := c.recompressContents; v != "" {
+		if v == "off" {
+			p.Recompression = nil
+			*changed = true
+
+			log(ctx).Info("Recompression of contents disabled.")
+
+			return nil
+		}
+
+		if _, ok := compression.ByName[compression.Name(v)]; !ok && v != "none" {
+			return errors.Errorf("unsupported compression %q", v)
+		}
+
+		if p.Recompression == nil {
+			p.Recompression = &maintenance.RecompressionParams{}
+		}
+
+		p.Recompression.Compression = compression.Name(v)
+		*changed = true
+
+		log(ctx).Infof("Setting recompression of contents to %v.", v)
+	}
+
+	if c.recompressMinAge == -1 && c.recompressMaxBytesPerRunMB == -1 {
+		return nil
+	}
+
+	if p.Recompression == nil {
+		return errors.New("recompression of contents is not enabled, use --recompress-contents")
+	}
+
+	if v := c.recompressMinAge; v != -1 {
+		p.Recompression.MinAge = v
+		*changed = true
+
+		log(ctx).Infof("Setting minimum age of contents to recompress to %v.", v)
+	}
+
+	if v := c.recompressMaxBytesPerRunMB; v != -1 {
+		p.Recompression.MaxBytesPerRun = v << 20 //nolint:mnd
+		*changed = true
+
+		log(ctx).Infof("Setting maximum amount of data to recompress per run to %v.", units.BytesString(p.Recompression.MaxBytesPerRun))
+	}
+
+	return nil
 }
 
 func (c *commandMaintenanceSet) setMaintenanceOwnerFromFlags(ctx context.Context, p *maintenance.Params, rep repo.DirectRepositoryWriter, changed *bool) {
@@ -175,6 +253,10 @@ func (c *commandMaintenanceSet) run(ctx context.Context, rep repo.DirectReposito
 	c.setDeleteUnreferencedBlobsParams(ctx, p, &changedParams)
 	c.setMaintenanceObjectLockExtendFromFlags(ctx, p, &changedParams)
 
+	if err := c.setRecompressionParamsFromFlags(ctx, p, &changedParams); err != nil {
+		return err
+	}
+
 	if pauseDuration := c.maintenanceSetPauseQuick; pauseDuration != -1 {
 		s.NextQuickMaintenanceTime = rep.Time().Add(pauseDuration)
 		changedSchedule = true
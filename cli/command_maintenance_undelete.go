@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandMaintenanceUndelete struct {
+	blobIDs []string
+	all     bool
+	list    bool
+	dryRun  bool
+
+	out textOutput
+}
+
+func (c *commandMaintenanceUndelete) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("undelete", "Recover blobs from the deletion quarantine before they are deleted by maintenance")
+	cmd.Arg("blobID", "Blob IDs to undelete").StringsVar(&c.blobIDs)
+	cmd.Flag("all", "Undelete all quarantined blobs").BoolVar(&c.all)
+	cmd.Flag("list", "List quarantined blobs").BoolVar(&c.list)
+	cmd.Flag("dry-run", "Only show blobs that would be undeleted").BoolVar(&c.dryRun)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandMaintenanceUndelete) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if c.list {
+		q, err := maintenance.GetBlobQuarantine(ctx, rep)
+		if err != nil {
+			return errors.Wrap(err, "unable to get blob quarantine")
+		}
+
+		for _, qb := range q.Sorted() {
+			c.out.printStdout("%v %v quarantined %v\n", qb.BlobID, units.BytesString(qb.Length), formatTimestamp(qb.QuarantineTime))
+		}
+
+		return nil
+	}
+
+	if len(c.blobIDs) == 0 && !c.all {
+		return errors.New("must specify blob IDs to undelete or --all")
+	}
+
+	if len(c.blobIDs) > 0 && c.all {
+		return errors.New("blob IDs can't be specified together with --all")
+	}
+
+	opt := maintenance.UndeleteBlobsOptions{
+		DryRun: c.dryRun,
+	}
+
+	for _, id := range c.blobIDs {
+		opt.BlobIDs = append(opt.BlobIDs, blob.ID(id))
+	}
+
+	undeleted, err := maintenance.UndeleteBlobs(ctx, rep, opt)
+	if err != nil {
+		return errors.Wrap(err, "unable to undelete blobs")
+	}
+
+	for _, qb := range undeleted {
+		c.out.printStdout("%v %v\n", qb.BlobID, units.BytesString(qb.Length))
+	}
+
+	if c.dryRun {
+		log(ctx).Infof("Would undelete %v blobs.", len(undeleted))
+	} else {
+		log(ctx).Infof("Undeleted %v blobs.", len(undeleted))
+	}
+
+	return nil
+}
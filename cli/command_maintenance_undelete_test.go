@@ -0,0 +1,50 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestMaintenanceUndelete(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), bytes.Repeat([]byte{1, 2, 3}, 100000), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	env.RunAndExpectSuccess(t, "maintenance", "set", "--blob-deletion-quarantine=1h")
+	require.Contains(t, env.RunAndExpectSuccess(t, "maintenance", "info"), "Blob Deletion Quarantine:")
+
+	// rewriting contents orphans the original pack blobs, which are quarantined instead of being deleted.
+	env.RunAndExpectSuccess(t, "content", "rewrite", "--change-compression=zstd", "--non-prefixed", "--safety=none")
+	env.RunAndExpectSuccess(t, "blob", "gc", "--delete=yes", "--safety=none")
+
+	quarantined := env.RunAndExpectSuccess(t, "maintenance", "undelete", "--list")
+	require.NotEmpty(t, quarantined)
+
+	for _, l := range quarantined {
+		require.True(t, strings.HasPrefix(l, "p"), l)
+	}
+
+	env.RunAndExpectFailure(t, "maintenance", "undelete")
+	env.RunAndExpectFailure(t, "maintenance", "undelete", "pnosuchblob")
+
+	env.RunAndExpectSuccess(t, "maintenance", "undelete", "--all", "--dry-run")
+	require.Equal(t, quarantined, env.RunAndExpectSuccess(t, "maintenance", "undelete", "--list"))
+
+	env.RunAndExpectSuccess(t, "maintenance", "undelete", "--all")
+	require.Empty(t, env.RunAndExpectSuccess(t, "maintenance", "undelete", "--list"))
+
+	env.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+}
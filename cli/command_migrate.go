@@ -0,0 +1,13 @@
+package cli
+
+type commandMigrate struct {
+	fromRestic commandMigrateFromRestic
+	fromRsync  commandMigrateFromRsync
+}
+
+func (c *commandMigrate) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("migrate", "Import snapshots created by other backup tools.")
+
+	c.fromRestic.setup(svc, cmd)
+	c.fromRsync.setup(svc, cmd)
+}
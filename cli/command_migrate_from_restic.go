@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/restic"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// resticSnapshotTag is the tag recording the ID of the restic snapshot a kopia snapshot was imported from.
+const resticSnapshotTag = "tag:restic-snapshot"
+
+type commandMigrateFromRestic struct {
+	resticRepo         string
+	resticPassword     string
+	resticPasswordFile string
+
+	hosts            []string
+	paths            []string
+	snapshotIDs      []string
+	latestOnly       bool
+	applyIgnoreRules bool
+
+	svc appServices
+	out textOutput
+}
+
+func (c *commandMigrateFromRestic) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("from-restic", "Import snapshots from a local restic repository, preserving timestamps, paths and host/user attribution. Interrupted imports can be resumed by running the command again.")
+	cmd.Arg("restic-repository", "Path to the restic repository").Required().ExistingDirVar(&c.resticRepo)
+	cmd.Flag("restic-password", "Password of the restic repository").Envar("RESTIC_PASSWORD").StringVar(&c.resticPassword)
+	cmd.Flag("restic-password-file", "File containing the password of the restic repository").Envar("RESTIC_PASSWORD_FILE").ExistingFileVar(&c.resticPasswordFile)
+	cmd.Flag("host", "Only import snapshots of the provided hosts").StringsVar(&c.hosts)
+	cmd.Flag("path", "Only import snapshots of the provided paths").StringsVar(&c.paths)
+	cmd.Flag("snapshot", "Only import restic snapshots with the provided IDs (full or abbreviated)").StringsVar(&c.snapshotIDs)
+	cmd.Flag("latest-only", "Only import the latest snapshot of each source").BoolVar(&c.latestOnly)
+	cmd.Flag("apply-ignore-rules", "When importing also apply current ignore rules").BoolVar(&c.applyIgnoreRules)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+func (c *commandMigrateFromRestic) password() (string, error) {
+	if c.resticPassword != "" {
+		return c.resticPassword, nil
+	}
+
+	if c.resticPasswordFile != "" {
+		p, err := readKeyFile(c.resticPasswordFile)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimRight(p, "\r\n"), nil
+	}
+
+	return askPass(c.out.stdout(), "Enter restic repository password: ")
+}
+
+// resticImportItem is a single path of a restic snapshot to be imported as a kopia snapshot.
+type resticImportItem struct {
+	snap   *restic.Snapshot
+	path   string
+	source snapshot.SourceInfo
+}
+
+func (c *commandMigrateFromRestic) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	password, err := c.password()
+	if err != nil {
+		return err
+	}
+
+	rr, err := restic.Open(ctx, c.resticRepo, password)
+	if err != nil {
+		return errors.Wrap(err, "unable to open restic repository")
+	}
+
+	defer rr.Close()
+
+	snapshots, err := rr.Snapshots(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to list restic snapshots")
+	}
+
+	items := c.itemsToImport(snapshots)
+
+	log(ctx).Infof("Found %v restic snapshots, %v paths to import.", len(snapshots), len(items))
+
+	progress := c.svc.getProgress()
+
+	u := snapshotfs.NewUploader(rep)
+	u.Progress = progress
+	u.DisableIgnoreRules = !c.applyIgnoreRules
+
+	c.svc.onTerminate(u.Cancel)
+
+	progress.StartShared()
+	defer progress.FinishShared()
+
+	imported := 0
+
+	for _, it := range items {
+		if u.IsCanceled() {
+			return errors.New("import canceled, run the command again to resume")
+		}
+
+		ok, err := c.importSnapshot(ctx, rep, rr, u, it)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			imported++
+		}
+	}
+
+	c.out.printStderr("\r\n")
+	log(ctx).Infof("Imported %v snapshots.", imported)
+
+	return nil
+}
+
+// itemsToImport returns restic snapshot paths matching the filters, ordered from the oldest snapshot.
+func (c *commandMigrateFromRestic) itemsToImport(snapshots []*restic.Snapshot) []resticImportItem {
+	var result []resticImportItem
+
+	for _, s := range snapshots {
+		if len(c.hosts) > 0 && !slices.Contains(c.hosts, s.Hostname) {
+			continue
+		}
+
+		if len(c.snapshotIDs) > 0 && !slices.ContainsFunc(c.snapshotIDs, func(id string) bool {
+			return id != "" && strings.HasPrefix(string(s.ID), id)
+		}) {
+			continue
+		}
+
+		for _, p := range s.Paths {
+			if len(c.paths) > 0 && !slices.Contains(c.paths, p) {
+				continue
+			}
+
+			result = append(result, resticImportItem{
+				snap: s,
+				path: p,
+				source: snapshot.SourceInfo{
+					Host:     s.Hostname,
+					UserName: s.Username,
+					Path:     p,
+				},
+			})
+		}
+	}
+
+	if !c.latestOnly {
+		return result
+	}
+
+	// snapshots are ordered by time, keep the last item for each source.
+	latest := map[snapshot.SourceInfo]int{}
+	for i, it := range result {
+		latest[it.source] = i
+	}
+
+	var filtered []resticImportItem
+
+	for i, it := range result {
+		if latest[it.source] == i {
+			filtered = append(filtered, it)
+		}
+	}
+
+	return filtered
+}
+
+// importSnapshot imports a single path of a restic snapshot unless it has been imported before.
+func (c *commandMigrateFromRestic) importSnapshot(ctx context.Context, rep repo.RepositoryWriter, rr *restic.Repository, u *snapshotfs.Uploader, it resticImportItem) (bool, error) {
+	startTime := fs.UTCTimestampFromTime(it.snap.Time)
+
+	existing, err := snapshot.ListSnapshots(ctx, rep, it.source)
+	if err != nil {
+		return false, errors.Wrap(err, "error listing snapshots")
+	}
+
+	for _, m := range existing {
+		if m.IncompleteReason == "" && m.StartTime.Equal(startTime) {
+			log(ctx).Infof("already imported restic snapshot %v of %v", it.snap.ShortID(), it.source)
+			return false, nil
+		}
+	}
+
+	entry, err := rr.SnapshotEntry(it.snap, it.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to open restic snapshot %v", it.snap.ShortID())
+	}
+
+	log(ctx).Infof("importing restic snapshot %v of %v at %v", it.snap.ShortID(), it.source, formatTimestamp(it.snap.Time))
+
+	// any existing snapshots (including checkpoints of interrupted imports) speed up hashing of unchanged files.
+	previous, err := findPreviousSnapshotManifest(ctx, rep, it.source, nil)
+	if err != nil {
+		return false, err
+	}
+
+	policyTree, err := policy.TreeForSource(ctx, rep, it.source)
+	if err != nil {
+		return false, errors.Wrap(err, "error generating policy tree")
+	}
+
+	man, err := u.Upload(ctx, entry, policyTree, it.source, previous...)
+	if err != nil {
+		return false, errors.Wrapf(err, "error importing restic snapshot %v", it.snap.ShortID())
+	}
+
+	if man.IncompleteReason != "" {
+		return false, errors.Errorf("import of restic snapshot %v is incomplete: %v", it.snap.ShortID(), man.IncompleteReason)
+	}
+
+	duration := man.EndTime.Sub(man.StartTime)
+	man.StartTime = startTime
+	man.EndTime = startTime.Add(duration)
+	man.Description = "Imported from restic snapshot " + string(it.snap.ID)
+	man.Tags = map[string]string{
+		resticSnapshotTag: it.snap.ShortID(),
+	}
+
+	if _, err := snapshot.SaveSnapshot(ctx, rep, man); err != nil {
+		return false, errors.Wrap(err, "cannot save manifest")
+	}
+
+	// flush after each snapshot, so that an interrupted import can be resumed.
+	if err := rep.Flush(ctx); err != nil {
+		return false, errors.Wrap(err, "flush error")
+	}
+
+	return true, nil
+}
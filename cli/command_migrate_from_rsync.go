@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// Ways of determining the time of each backup directory.
+const (
+	rsyncBackupTimeAuto  = "auto"
+	rsyncBackupTimeName  = "name"
+	rsyncBackupTimeMtime = "mtime"
+)
+
+// backupDirTimestampRegexp matches dates with optional time of day in names of backup directories,
+// such as 2023-05-01, 20230501-1030, 2023-05-01T10:30:00 or backup_2023-05-01_10-30-00.
+var backupDirTimestampRegexp = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})(?:[T_ .-]?(\d{2})[:.-]?(\d{2})(?:[:.-]?(\d{2}))?)?`)
+
+type commandMigrateFromRsync struct {
+	backupRoot       string
+	source           string
+	subdir           string
+	timeSource       string
+	timeZone         string
+	applyIgnoreRules bool
+	uploadHints      bool
+
+	svc appServices
+	out textOutput
+}
+
+func (c *commandMigrateFromRsync) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("from-rsync", "Import a directory of dated rsync --link-dest or rsnapshot backups as a series of backdated snapshots. Interrupted imports can be resumed by running the command again.")
+	cmd.Arg("backup-root", "Directory containing one subdirectory per backup").Required().ExistingDirVar(&c.backupRoot)
+	cmd.Flag("source", "Source the backups were made of, in the user@host:/path format").Required().StringVar(&c.source)
+	cmd.Flag("subdir", "Path within each backup directory to import (e.g. 'localhost/home/user' for rsnapshot)").StringVar(&c.subdir)
+	cmd.Flag("backup-time", "How to determine the time of each backup: from directory name, directory modification time or auto (name if present, otherwise mtime)").Default(rsyncBackupTimeAuto).EnumVar(&c.timeSource, rsyncBackupTimeAuto, rsyncBackupTimeName, rsyncBackupTimeMtime)
+	cmd.Flag("time-zone", "Time zone of timestamps in directory names").Default("Local").StringVar(&c.timeZone)
+	cmd.Flag("apply-ignore-rules", "When importing also apply current ignore rules").BoolVar(&c.applyIgnoreRules)
+	cmd.Flag("upload-hints", "Skip hashing of files hard-linked between backups, based on a local cache").Default("true").BoolVar(&c.uploadHints)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+	c.out.setup(svc)
+}
+
+// rsyncBackup is a single backup directory.
+type rsyncBackup struct {
+	path string
+	time time.Time
+}
+
+func (c *commandMigrateFromRsync) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	si, err := parseFullSource(c.source, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(c.timeZone)
+	if err != nil {
+		return errors.Wrap(err, "invalid time zone")
+	}
+
+	backups, err := findRsyncBackups(ctx, c.backupRoot, c.timeSource, loc)
+	if err != nil {
+		return err
+	}
+
+	log(ctx).Infof("Found %v backups to import as %v.", len(backups), si)
+
+	progress := c.svc.getProgress()
+
+	u := snapshotfs.NewUploader(rep)
+	u.Progress = progress
+	u.DisableIgnoreRules = !c.applyIgnoreRules
+
+	if c.uploadHints {
+		// hard-linked files share the inode, which allows skipping them without hashing.
+		u.HintCache = openUploadHintCache(ctx, c.svc)
+
+		defer func() {
+			if err := u.HintCache.Save(ctx); err != nil {
+				log(ctx).Warnf("unable to save upload hints: %v", err)
+			}
+		}()
+	}
+
+	c.svc.onTerminate(u.Cancel)
+
+	progress.StartShared()
+	defer progress.FinishShared()
+
+	imported := 0
+
+	for _, b := range backups {
+		if u.IsCanceled() {
+			return errors.New("import canceled, run the command again to resume")
+		}
+
+		ok, err := c.importBackup(ctx, rep, u, si, b)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			imported++
+		}
+	}
+
+	c.out.printStderr("\r\n")
+	log(ctx).Infof("Imported %v snapshots.", imported)
+
+	return nil
+}
+
+// importBackup imports a single backup directory unless it has been imported before.
+func (c *commandMigrateFromRsync) importBackup(ctx context.Context, rep repo.RepositoryWriter, u *snapshotfs.Uploader, si snapshot.SourceInfo, b rsyncBackup) (bool, error) {
+	startTime := fs.UTCTimestampFromTime(b.time)
+
+	existing, err := snapshot.ListSnapshots(ctx, rep, si)
+	if err != nil {
+		return false, errors.Wrap(err, "error listing snapshots")
+	}
+
+	for _, m := range existing {
+		if m.IncompleteReason == "" && m.StartTime.Equal(startTime) {
+			log(ctx).Infof("already imported %v", b.path)
+			return false, nil
+		}
+	}
+
+	entry, err := getLocalFSEntry(ctx, filepath.Join(b.path, filepath.FromSlash(c.subdir)))
+	if err != nil {
+		return false, err
+	}
+
+	log(ctx).Infof("importing %v as snapshot at %v", b.path, formatTimestamp(b.time))
+
+	// any existing snapshots (including checkpoints of interrupted imports) speed up hashing of unchanged files.
+	previous, err := findPreviousSnapshotManifest(ctx, rep, si, nil)
+	if err != nil {
+		return false, err
+	}
+
+	policyTree, err := policy.TreeForSource(ctx, rep, si)
+	if err != nil {
+		return false, errors.Wrap(err, "error generating policy tree")
+	}
+
+	man, err := u.Upload(ctx, entry, policyTree, si, previous...)
+	if err != nil {
+		return false, errors.Wrapf(err, "error importing %v", b.path)
+	}
+
+	if man.IncompleteReason != "" {
+		return false, errors.Errorf("import of %v is incomplete: %v", b.path, man.IncompleteReason)
+	}
+
+	duration := man.EndTime.Sub(man.StartTime)
+	man.StartTime = startTime
+	man.EndTime = startTime.Add(duration)
+	man.Description = "Imported from " + b.path
+
+	if _, err := snapshot.SaveSnapshot(ctx, rep, man); err != nil {
+		return false, errors.Wrap(err, "cannot save manifest")
+	}
+
+	// flush after each snapshot, so that an interrupted import can be resumed.
+	if err := rep.Flush(ctx); err != nil {
+		return false, errors.Wrap(err, "flush error")
+	}
+
+	return true, nil
+}
+
+// findRsyncBackups returns backup directories found in the provided root ordered by time.
+// Symbolic links (such as 'latest') and directories without a timestamp are skipped.
+func findRsyncBackups(ctx context.Context, root, timeSource string, loc *time.Location) ([]rsyncBackup, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list backups")
+	}
+
+	var result []rsyncBackup
+
+	seen := map[time.Time]string{}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		t, ok, err := rsyncBackupTime(e, timeSource, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			log(ctx).Infof("skipping %v without a timestamp", e.Name())
+			continue
+		}
+
+		if other, ok := seen[t]; ok {
+			return nil, errors.Errorf("backups %v and %v have the same time %v", other, e.Name(), formatTimestamp(t))
+		}
+
+		seen[t] = e.Name()
+
+		result = append(result, rsyncBackup{filepath.Join(root, e.Name()), t})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].time.Before(result[j].time)
+	})
+
+	return result, nil
+}
+
+func rsyncBackupTime(e os.DirEntry, timeSource string, loc *time.Location) (time.Time, bool, error) {
+	if timeSource != rsyncBackupTimeMtime {
+		if t, ok := parseBackupDirTimestamp(e.Name(), loc); ok {
+			return t, true, nil
+		}
+
+		if timeSource == rsyncBackupTimeName {
+			return time.Time{}, false, nil
+		}
+	}
+
+	fi, err := e.Info()
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "unable to stat %v", e.Name())
+	}
+
+	return fi.ModTime(), true, nil
+}
+
+// parseBackupDirTimestamp extracts the time of the backup from the directory name.
+func parseBackupDirTimestamp(name string, loc *time.Location) (time.Time, bool) {
+	m := backupDirTimestampRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	var v [6]int
+
+	for i := range v {
+		if m[i+1] != "" {
+			v[i], _ = strconv.Atoi(m[i+1])
+		}
+	}
+
+	t := time.Date(v[0], time.Month(v[1]), v[2], v[3], v[4], v[5], 0, loc)
+
+	// reject values that were normalized, such as month 13.
+	if t.Month() != time.Month(v[1]) || t.Day() != v[2] || t.Hour() != v[3] || t.Minute() != v[4] || t.Second() != v[5] {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -2,7 +2,9 @@
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/alecthomas/kingpin/v2"
 	"github.com/pkg/errors"
 	"github.com/skratchdot/open-golang/open"
 
@@ -22,8 +24,10 @@ type commandMount struct {
 	mountFuseAllowOther         bool
 	mountFuseAllowNonEmptyMount bool
 	mountPreferWebDAV           bool
+	mountOverlayDir             string
 	maxCachedEntries            int
 	maxCachedDirectories        int
+	maxDecodedDirEntries        int
 
 	svc appServices
 }
@@ -33,18 +37,25 @@ func (c *commandMount) setup(svc appServices, parent commandParent) {
 
 	cmd.Arg("path", "Identifier of the directory to mount.").Default("all").StringVar(&c.mountObjectID)
 	cmd.Arg("mountPoint", "Mount point").Default("*").StringVar(&c.mountPoint)
+	c.setupMountFlags(svc, cmd)
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandMount) setupMountFlags(svc appServices, cmd *kingpin.CmdClause) {
 	cmd.Flag("browse", "Open file browser").BoolVar(&c.mountPointBrowse)
 	cmd.Flag("trace-fs", "Trace filesystem operations").BoolVar(&c.mountTraceFS)
 
 	cmd.Flag("fuse-allow-other", "Allows other users to access the file system.").BoolVar(&c.mountFuseAllowOther)
 	cmd.Flag("fuse-allow-non-empty-mount", "Allows the mounting over a non-empty directory. The files in it will be shadowed by the freshly created mount.").BoolVar(&c.mountFuseAllowNonEmptyMount)
 	cmd.Flag("webdav", "Use WebDAV to mount the repository object regardless of fuse availability.").BoolVar(&c.mountPreferWebDAV)
+	cmd.Flag("overlay-dir", "Make the mount writable, storing changes in the provided local directory. Changes are never written to the repository.").StringVar(&c.mountOverlayDir)
 
 	cmd.Flag("max-cached-entries", "Limit the number of cached directory entries").Default("100000").IntVar(&c.maxCachedEntries)
 	cmd.Flag("max-cached-dirs", "Limit the number of cached directories").Default("100").IntVar(&c.maxCachedDirectories)
+	cmd.Flag("max-decoded-dir-entries", "Limit the number of decoded directory entries cached across snapshots (0 to disable)").Default(strconv.Itoa(snapshotfs.DefaultDirectoryCacheEntries)).IntVar(&c.maxDecodedDirEntries)
 
 	c.svc = svc
-	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
 func (c *commandMount) newFSCache() cachefs.DirectoryCacher {
@@ -68,6 +79,13 @@ func (c *commandMount) run(ctx context.Context, rep repo.Repository) error {
 		}
 	}
 
+	return c.mountAndWait(ctx, entry, c.mountObjectID)
+}
+
+// mountAndWait mounts the provided directory and waits until it is unmounted or Ctrl-C is pressed.
+func (c *commandMount) mountAndWait(ctx context.Context, entry fs.Directory, description string) error {
+	snapshotfs.EnableDirectoryCache(c.maxDecodedDirEntries)
+
 	if c.mountTraceFS {
 		//nolint:forcetypeassert
 		entry = loggingfs.Wrap(entry, log(ctx).Debugf).(fs.Directory)
@@ -81,13 +99,18 @@ func (c *commandMount) run(ctx context.Context, rep repo.Repository) error {
 			FuseAllowOther:         c.mountFuseAllowOther,
 			FuseAllowNonEmptyMount: c.mountFuseAllowNonEmptyMount,
 			PreferWebDAV:           c.mountPreferWebDAV,
+			OverlayDir:             c.mountOverlayDir,
 		})
 
 	if mountErr != nil {
 		return errors.Wrap(mountErr, "mount error")
 	}
 
-	log(ctx).Infof("Mounted '%v' on %v", c.mountObjectID, ctrl.MountPath())
+	log(ctx).Infof("Mounted '%v' on %v", description, ctrl.MountPath())
+
+	if c.mountOverlayDir != "" {
+		log(ctx).Infof("Changes will be stored in %v and will not be written to the repository.", c.mountOverlayDir)
+	}
 
 	if c.mountPoint == "*" && !c.mountPointBrowse {
 		log(ctx).Info("HINT: Pass --browse to automatically open file browser.")
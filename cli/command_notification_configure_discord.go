@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"github.com/kopia/kopia/notification/sender/discord"
+)
+
+type commandNotificationConfigureDiscord struct {
+	common commonNotificationOptions
+
+	opt discord.Options
+}
+
+func (c *commandNotificationConfigureDiscord) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("discord", "Discord notification.")
+
+	c.common.setup(svc, cmd)
+
+	cmd.Flag("webhook-url", "Discord webhook URL").StringVar(&c.opt.WebhookURL)
+	cmd.Flag("username", "Override the user name of the webhook").StringVar(&c.opt.Username)
+
+	cmd.Action(configureNotificationAction(svc, &c.common, discord.ProviderType, &c.opt, discord.MergeOptions))
+}
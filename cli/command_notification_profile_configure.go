@@ -1,16 +1,20 @@
 package cli
 
 type commandNotificationProfileConfigure struct {
+	commandNotificationConfigureDiscord
 	commandNotificationConfigureEmail
 	commandNotificationConfigurePushover
+	commandNotificationConfigureSlack
 	commandNotificationConfigureWebhook
 	commandNotificationConfigureTestSender
 }
 
 func (c *commandNotificationProfileConfigure) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("configure", "Setup notifications").Alias("setup")
+	c.commandNotificationConfigureDiscord.setup(svc, cmd)
 	c.commandNotificationConfigureEmail.setup(svc, cmd)
 	c.commandNotificationConfigurePushover.setup(svc, cmd)
+	c.commandNotificationConfigureSlack.setup(svc, cmd)
 	c.commandNotificationConfigureWebhook.setup(svc, cmd)
 
 	if svc.enableTestOnlyFlags() {
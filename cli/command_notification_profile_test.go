@@ -127,3 +127,22 @@ func TestNotificationProfile_WebHook(t *testing.T) {
 	// no profiles left
 	require.Empty(t, e.RunAndExpectSuccess(t, "notification", "profile", "list"))
 }
+
+func TestNotificationProfile_SlackAndDiscord(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	e.RunAndExpectFailure(t, "notification", "profile", "configure", "slack", "--profile-name=myslack")
+	e.RunAndExpectSuccess(t, "notification", "profile", "configure", "slack", "--profile-name=myslack", "--webhook-url=http://localhost:12345/slack", "--channel=#backups")
+	e.RunAndExpectSuccess(t, "notification", "profile", "configure", "discord", "--profile-name=mydiscord", "--webhook-url=http://localhost:12345/discord", "--min-severity=error")
+
+	lines := e.RunAndExpectSuccess(t, "notification", "profile", "list")
+
+	require.Contains(t, lines, "Profile \"myslack\" Type \"slack\" Minimum Severity: report")
+	require.Contains(t, lines, "Profile \"mydiscord\" Type \"discord\" Minimum Severity: error")
+}
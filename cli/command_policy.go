@@ -13,13 +13,15 @@
 )
 
 type commandPolicy struct {
-	edit    commandPolicyEdit
-	list    commandPolicyList
-	delete  commandPolicyDelete
-	set     commandPolicySet
-	show    commandPolicyShow
-	export  commandPolicyExport
-	pImport commandPolicyImport
+	edit       commandPolicyEdit
+	list       commandPolicyList
+	delete     commandPolicyDelete
+	set        commandPolicySet
+	show       commandPolicyShow
+	export     commandPolicyExport
+	pImport    commandPolicyImport
+	testIgnore commandPolicyTestIgnore
+	simulate   commandPolicySimulate
 }
 
 func (c *commandPolicy) setup(svc appServices, parent commandParent) {
@@ -32,11 +34,14 @@ func (c *commandPolicy) setup(svc appServices, parent commandParent) {
 	c.show.setup(svc, cmd)
 	c.export.setup(svc, cmd)
 	c.pImport.setup(svc, cmd)
+	c.testIgnore.setup(svc, cmd)
+	c.simulate.setup(svc, cmd)
 }
 
 type policyTargetFlags struct {
-	targets []string
-	global  bool
+	targets  []string
+	global   bool
+	enforced bool
 }
 
 func (c *policyTargetFlags) setup(cmd *kingpin.CmdClause) {
@@ -44,6 +49,19 @@ func (c *policyTargetFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("global", "Select the global policy.").BoolVar(&c.global)
 }
 
+// setupEnforced adds the flag selecting the enforced policy, which must be handled by the command itself.
+func (c *policyTargetFlags) setupEnforced(cmd *kingpin.CmdClause) {
+	cmd.Flag("enforced", "Select the enforced policy, which applies to all sources and overrides policies set by clients.").BoolVar(&c.enforced)
+}
+
+func (c *policyTargetFlags) validateEnforcedTarget() error {
+	if c.global || len(c.targets) > 0 {
+		return errors.New("'--enforced' can't be combined with '--global' or path targets")
+	}
+
+	return nil
+}
+
 func (c *policyTargetFlags) policyTargets(ctx context.Context, rep repo.Repository) ([]snapshot.SourceInfo, error) {
 	if c.global == (len(c.targets) > 0) {
 		return nil, errors.New("must pass either '--global' or a list of path targets")
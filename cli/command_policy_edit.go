@@ -41,6 +41,7 @@
   #   "noParentDotFiles": true
   #   "noParentIgnore": true
   #   "oneFileSystem": false
+  #   "followReparsePoints": false
 `
 
 const policyEditSchedulingHelpText = `
@@ -17,11 +17,16 @@ type commandPolicyDelete struct {
 func (c *commandPolicyDelete) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("delete", "Remove snapshot policy for a single directory, user@host or a global policy.").Alias("remove").Alias("rm")
 	c.policyTargetFlags.setup(cmd)
+	c.policyTargetFlags.setupEnforced(cmd)
 	cmd.Flag("dry-run", "Do not remove").Short('n').BoolVar(&c.dryRun)
 	cmd.Action(svc.repositoryWriterAction(c.run))
 }
 
 func (c *commandPolicyDelete) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	if c.enforced {
+		return c.removeEnforced(ctx, rep)
+	}
+
 	targets, err := c.policyTargets(ctx, rep)
 	if err != nil {
 		return err
@@ -41,3 +46,17 @@ func (c *commandPolicyDelete) run(ctx context.Context, rep repo.RepositoryWriter
 
 	return nil
 }
+
+func (c *commandPolicyDelete) removeEnforced(ctx context.Context, rep repo.RepositoryWriter) error {
+	if err := c.validateEnforcedTarget(); err != nil {
+		return err
+	}
+
+	log(ctx).Info("Removing enforced policy...")
+
+	if c.dryRun {
+		return nil
+	}
+
+	return errors.Wrap(policy.RemoveEnforcedPolicy(ctx, rep), "error removing enforced policy")
+}
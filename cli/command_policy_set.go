@@ -28,11 +28,13 @@ type commandPolicySet struct {
 	policySchedulingFlags
 	policyOSSnapshotFlags
 	policyUploadFlags
+	policyExtendedAttributesFlags
 }
 
 func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("set", "Set snapshot policy for a single directory, user@host or a global policy.")
 	c.policyTargetFlags.setup(cmd)
+	c.policyTargetFlags.setupEnforced(cmd)
 	cmd.Flag(inheritPolicyString, "Enable or disable inheriting policies from the parent").BoolListVar(&c.inherit)
 
 	c.policyActionFlags.setup(cmd)
@@ -46,6 +48,7 @@ func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
 	c.policySchedulingFlags.setup(cmd)
 	c.policyOSSnapshotFlags.setup(cmd)
 	c.policyUploadFlags.setup(cmd)
+	c.policyExtendedAttributesFlags.setup(cmd)
 
 	cmd.Action(svc.repositoryWriterAction(c.run))
 }
@@ -59,6 +62,10 @@ func (c *commandPolicySet) setup(svc appServices, parent commandParent) {
 )
 
 func (c *commandPolicySet) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	if c.enforced {
+		return c.setEnforced(ctx, rep)
+	}
+
 	targets, err := c.policyTargets(ctx, rep)
 	if err != nil {
 		return err
@@ -93,6 +100,34 @@ func (c *commandPolicySet) run(ctx context.Context, rep repo.RepositoryWriter) e
 	return nil
 }
 
+func (c *commandPolicySet) setEnforced(ctx context.Context, rep repo.RepositoryWriter) error {
+	if err := c.validateEnforcedTarget(); err != nil {
+		return err
+	}
+
+	p, err := policy.GetEnforcedPolicy(ctx, rep)
+
+	switch {
+	case errors.Is(err, policy.ErrPolicyNotFound):
+		p = &policy.Policy{}
+	case err != nil:
+		return errors.Wrap(err, "could not get enforced policy")
+	}
+
+	log(ctx).Info("Setting enforced policy")
+
+	changeCount := 0
+	if err := c.setPolicyFromFlags(ctx, p, &changeCount); err != nil {
+		return err
+	}
+
+	if changeCount == 0 {
+		return errors.New("no changes specified")
+	}
+
+	return errors.Wrap(policy.SetEnforcedPolicy(ctx, rep, p), "can't save enforced policy")
+}
+
 func (c *commandPolicySet) setPolicyFromFlags(ctx context.Context, p *policy.Policy, changeCount *int) error {
 	if err := c.setRetentionPolicyFromFlags(ctx, &p.RetentionPolicy, changeCount); err != nil {
 		return errors.Wrap(err, "retention policy")
@@ -138,6 +173,10 @@ func (c *commandPolicySet) setPolicyFromFlags(ctx context.Context, p *policy.Pol
 		return errors.Wrap(err, "upload policy")
 	}
 
+	if err := c.setExtendedAttributesPolicyFromFlags(ctx, &p.ExtendedAttributesPolicy, changeCount); err != nil {
+		return errors.Wrap(err, "extended attributes policy")
+	}
+
 	// It's not really a list, just optional boolean, last one wins.
 	for _, inherit := range c.inherit {
 		*changeCount++
@@ -220,6 +259,14 @@ func applyOptionalInt(ctx context.Context, desc string, val **policy.OptionalInt
 }
 
 func applyOptionalInt64MiB(ctx context.Context, desc string, val **policy.OptionalInt64, str string, changeCount *int) error {
+	return applyOptionalInt64Bytes(ctx, desc, val, str, 1<<20, changeCount) //nolint:mnd
+}
+
+func applyOptionalInt64KiB(ctx context.Context, desc string, val **policy.OptionalInt64, str string, changeCount *int) error {
+	return applyOptionalInt64Bytes(ctx, desc, val, str, 1<<10, changeCount) //nolint:mnd
+}
+
+func applyOptionalInt64Bytes(ctx context.Context, desc string, val **policy.OptionalInt64, str string, unitSize int64, changeCount *int) error {
 	if str == "" {
 		// not changed
 		return nil
@@ -240,8 +287,8 @@ func applyOptionalInt64MiB(ctx context.Context, desc string, val **policy.Option
 		return errors.Wrapf(err, "can't parse the %v %q", desc, str)
 	}
 
-	// convert MiB to bytes
-	v *= 1 << 20 //nolint:mnd
+	// convert units to bytes
+	v *= unitSize
 
 	i := policy.OptionalInt64(v)
 	*changeCount++
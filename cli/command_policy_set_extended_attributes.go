@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type policyExtendedAttributesFlags struct {
+	policyCaptureXattrs           string
+	policyCaptureACLs             string
+	policyCapturePlatformMetadata string
+}
+
+func (c *policyExtendedAttributesFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("capture-xattrs", "Capture extended attributes of files and directories ('true', 'false', 'inherit')").EnumVar(&c.policyCaptureXattrs, booleanEnumValues...)
+	cmd.Flag("capture-acls", "Capture POSIX ACLs and Windows security descriptors ('true', 'false', 'inherit')").EnumVar(&c.policyCaptureACLs, booleanEnumValues...)
+	cmd.Flag("capture-platform-metadata", "Capture macOS Finder info and resource forks and Windows alternate data streams ('true', 'false', 'inherit')").EnumVar(&c.policyCapturePlatformMetadata, booleanEnumValues...)
+}
+
+func (c *policyExtendedAttributesFlags) setExtendedAttributesPolicyFromFlags(ctx context.Context, p *policy.ExtendedAttributesPolicy, changeCount *int) error {
+	if err := applyPolicyBoolPtr(ctx, "capture extended attributes", &p.Xattrs, c.policyCaptureXattrs, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyPolicyBoolPtr(ctx, "capture ACLs", &p.ACLs, c.policyCaptureACLs, changeCount); err != nil {
+		return err
+	}
+
+	return applyPolicyBoolPtr(ctx, "capture platform metadata", &p.PlatformMetadata, c.policyCapturePlatformMetadata, changeCount)
+}
@@ -15,6 +15,11 @@ type policyFilesFlags struct {
 	policySetRemoveIgnore []string
 	policySetClearIgnore  bool
 
+	// Shared ignore files.
+	policySetAddIgnoreFile    []string
+	policySetRemoveIgnoreFile []string
+	policySetClearIgnoreFile  bool
+
 	// Dot-ignore files to look at.
 	policySetAddDotIgnore    []string
 	policySetRemoveDotIgnore []string
@@ -24,6 +29,12 @@ type policyFilesFlags struct {
 	// Ignore other mounted filesystems.
 	policyOneFileSystem string
 
+	// Follow Windows symbolic links and junctions.
+	policyFollowReparsePoints string
+
+	// Record named pipes, sockets and device nodes.
+	policySnapshotSpecialFiles string
+
 	policyIgnoreCacheDirs string
 }
 
@@ -33,6 +44,11 @@ func (c *policyFilesFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("remove-ignore", "List of paths to remove from the ignore list").PlaceHolder("PATTERN").StringsVar(&c.policySetRemoveIgnore)
 	cmd.Flag("clear-ignore", "Clear list of paths in the ignore list").BoolVar(&c.policySetClearIgnore)
 
+	// Shared ignore files.
+	cmd.Flag("add-ignore-file", "List of local paths or http(s) URLs of shared files with ignore rules to add").PlaceHolder("PATH-OR-URL").StringsVar(&c.policySetAddIgnoreFile)
+	cmd.Flag("remove-ignore-file", "List of shared files with ignore rules to remove").PlaceHolder("PATH-OR-URL").StringsVar(&c.policySetRemoveIgnoreFile)
+	cmd.Flag("clear-ignore-files", "Clear list of shared files with ignore rules").BoolVar(&c.policySetClearIgnoreFile)
+
 	// Dot-ignore files to look at.
 	cmd.Flag("add-dot-ignore", "List of paths to add to the dot-ignore list").PlaceHolder("FILENAME").StringsVar(&c.policySetAddDotIgnore)
 	cmd.Flag("remove-dot-ignore", "List of paths to remove from the dot-ignore list").PlaceHolder("FILENAME").StringsVar(&c.policySetRemoveDotIgnore)
@@ -42,6 +58,12 @@ func (c *policyFilesFlags) setup(cmd *kingpin.CmdClause) {
 	// Ignore other mounted filesystems.
 	cmd.Flag("one-file-system", "Stay in parent filesystem when finding files ('true', 'false', 'inherit')").EnumVar(&c.policyOneFileSystem, booleanEnumValues...)
 
+	// Follow Windows symbolic links and junctions.
+	cmd.Flag("follow-reparse-points", "Snapshot contents of Windows symbolic links and directory junctions instead of the links ('true', 'false', 'inherit')").EnumVar(&c.policyFollowReparsePoints, booleanEnumValues...)
+
+	// Record named pipes, sockets and device nodes.
+	cmd.Flag("snapshot-special-files", "Record metadata of named pipes, sockets and device nodes instead of ignoring them ('true', 'false', 'inherit')").EnumVar(&c.policySnapshotSpecialFiles, booleanEnumValues...)
+
 	cmd.Flag("ignore-cache-dirs", "Ignore cache directories ('true', 'false', 'inherit')").EnumVar(&c.policyIgnoreCacheDirs, booleanEnumValues...)
 }
 
@@ -52,10 +74,19 @@ func (c *policyFilesFlags) setFilesPolicyFromFlags(ctx context.Context, fp *poli
 
 	applyPolicyStringList(ctx, "dot-ignore filenames", &fp.DotIgnoreFiles, c.policySetAddDotIgnore, c.policySetRemoveDotIgnore, c.policySetClearDotIgnore, changeCount)
 	applyPolicyStringList(ctx, "ignore rules", &fp.IgnoreRules, c.policySetAddIgnore, c.policySetRemoveIgnore, c.policySetClearIgnore, changeCount)
+	applyPolicyStringList(ctx, "ignore rule files", &fp.IgnoreRuleFiles, c.policySetAddIgnoreFile, c.policySetRemoveIgnoreFile, c.policySetClearIgnoreFile, changeCount)
 
 	if err := applyPolicyBoolPtr(ctx, "ignore cache dirs", &fp.IgnoreCacheDirectories, c.policyIgnoreCacheDirs, changeCount); err != nil {
 		return err
 	}
 
+	if err := applyPolicyBoolPtr(ctx, "follow reparse points", &fp.FollowReparsePoints, c.policyFollowReparsePoints, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyPolicyBoolPtr(ctx, "snapshot special files", &fp.SnapshotSpecialFiles, c.policySnapshotSpecialFiles, changeCount); err != nil {
+		return err
+	}
+
 	return applyPolicyBoolPtr(ctx, "one filesystem", &fp.OneFileSystem, c.policyOneFileSystem, changeCount)
 }
@@ -10,13 +10,20 @@
 )
 
 type policyOSSnapshotFlags struct {
-	policyEnableVolumeShadowCopy string
+	policyEnableVolumeShadowCopy     string
+	policyEnableFilesystemSnapshot   string
+	policyFilesystemSnapshotProvider string
+	policyLVMSnapshotSizeMiB         string
 }
 
 func (c *policyOSSnapshotFlags) setup(cmd *kingpin.CmdClause) {
 	osSnapshotMode := []string{policy.OSSnapshotNeverString, policy.OSSnapshotAlwaysString, policy.OSSnapshotWhenAvailableString, inheritPolicyString}
 
 	cmd.Flag("enable-volume-shadow-copy", "Enable Volume Shadow Copy snapshots ('never', 'always', 'when-available', 'inherit')").PlaceHolder("MODE").EnumVar(&c.policyEnableVolumeShadowCopy, osSnapshotMode...)
+	cmd.Flag("enable-filesystem-snapshot", "Enable LVM, btrfs or ZFS snapshots on Linux ('never', 'always', 'when-available', 'inherit')").PlaceHolder("MODE").EnumVar(&c.policyEnableFilesystemSnapshot, osSnapshotMode...)
+	cmd.Flag("filesystem-snapshot-provider", "Filesystem snapshot provider ('auto', 'btrfs', 'zfs', 'lvm', 'inherit')").PlaceHolder("PROVIDER").EnumVar(&c.policyFilesystemSnapshotProvider,
+		policy.FilesystemSnapshotProviderAuto, policy.FilesystemSnapshotProviderBtrfs, policy.FilesystemSnapshotProviderZFS, policy.FilesystemSnapshotProviderLVM, inheritPolicyString)
+	cmd.Flag("lvm-snapshot-size-mib", "Size of storage allocated for changes made while LVM snapshot exists").StringVar(&c.policyLVMSnapshotSizeMiB)
 }
 
 func (c *policyOSSnapshotFlags) setOSSnapshotPolicyFromFlags(ctx context.Context, fp *policy.OSSnapshotPolicy, changeCount *int) error {
@@ -24,7 +31,35 @@ func (c *policyOSSnapshotFlags) setOSSnapshotPolicyFromFlags(ctx context.Context
 		return errors.Wrap(err, "enable volume shadow copy")
 	}
 
-	return nil
+	if err := applyPolicyOSSnapshotMode(ctx, "enable filesystem snapshot", &fp.FilesystemSnapshot.Enable, c.policyEnableFilesystemSnapshot, changeCount); err != nil {
+		return errors.Wrap(err, "enable filesystem snapshot")
+	}
+
+	applyPolicyFilesystemSnapshotProvider(ctx, &fp.FilesystemSnapshot.Provider, c.policyFilesystemSnapshotProvider, changeCount)
+
+	return applyOptionalInt64MiB(ctx, "LVM snapshot size", &fp.FilesystemSnapshot.LVMSnapshotSize, c.policyLVMSnapshotSizeMiB, changeCount)
+}
+
+func applyPolicyFilesystemSnapshotProvider(ctx context.Context, val *string, str string, changeCount *int) {
+	switch str {
+	case "":
+		// not changed
+		return
+
+	case inheritPolicyString:
+		*changeCount++
+
+		log(ctx).Info(" - resetting filesystem snapshot provider to a default value inherited from parent.")
+
+		*val = ""
+
+	default:
+		*changeCount++
+
+		log(ctx).Infof(" - setting filesystem snapshot provider to %v.", str)
+
+		*val = str
+	}
 }
 
 func applyPolicyOSSnapshotMode(ctx context.Context, desc string, val **policy.OSSnapshotMode, str string, changeCount *int) error {
@@ -46,4 +46,23 @@ func TestSetOSSnapshotPolicy(t *testing.T) {
 	lines = compressSpaces(lines)
 
 	require.Contains(t, lines, " Volume Shadow Copy: never (defined for this target)")
+
+	require.Contains(t, lines, " Filesystem snapshot: never inherited from (global)")
+	require.Contains(t, lines, " Filesystem snapshot provider: auto inherited from (global)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", "--enable-filesystem-snapshot=when-available", "--filesystem-snapshot-provider=zfs", "--lvm-snapshot-size-mib=100", td)
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+
+	require.Contains(t, lines, " Filesystem snapshot: when-available (defined for this target)")
+	require.Contains(t, lines, " Filesystem snapshot provider: zfs (defined for this target)")
+	require.Contains(t, lines, " LVM snapshot size: 104.9 MB (defined for this target)")
+
+	e.RunAndExpectSuccess(t, "policy", "set", "--filesystem-snapshot-provider=inherit", td)
+
+	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
+	lines = compressSpaces(lines)
+
+	require.Contains(t, lines, " Filesystem snapshot provider: auto inherited from (global)")
 }
@@ -2,6 +2,10 @@
 
 import (
 	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/alecthomas/kingpin/v2"
 
@@ -16,6 +20,8 @@ type policyRetentionFlags struct {
 	policySetKeepMonthly              string
 	policySetKeepAnnual               string
 	policySetIgnoreIdenticalSnapshots string
+	policySetKeepTagged               []string
+	policySetClearKeepTagged          bool
 }
 
 func (c *policyRetentionFlags) setup(cmd *kingpin.CmdClause) {
@@ -26,6 +32,8 @@ func (c *policyRetentionFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("keep-monthly", "Number of most-recent monthly backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepMonthly)
 	cmd.Flag("keep-annual", "Number of most-recent annual backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepAnnual)
 	cmd.Flag("ignore-identical-snapshots", "Do not save identical snapshots (or 'inherit')").StringVar(&c.policySetIgnoreIdenticalSnapshots)
+	cmd.Flag("keep-tagged", "Retain snapshots with the given tag (key or key:value) separately from other snapshots, keeping N most recent ones or all when N is not specified").PlaceHolder("TAG[=N]").StringsVar(&c.policySetKeepTagged)
+	cmd.Flag("clear-keep-tagged", "Clear all tag retention rules").BoolVar(&c.policySetClearKeepTagged)
 }
 
 func (c *policyRetentionFlags) setRetentionPolicyFromFlags(ctx context.Context, rp *policy.RetentionPolicy, changeCount *int) error {
@@ -48,5 +56,68 @@ func (c *policyRetentionFlags) setRetentionPolicyFromFlags(ctx context.Context,
 		}
 	}
 
+	if err := c.setTagRetentionRulesFromFlags(ctx, rp, changeCount); err != nil {
+		return err
+	}
+
 	return applyPolicyBoolPtr(ctx, "do not save identical snapshots", &rp.IgnoreIdenticalSnapshots, c.policySetIgnoreIdenticalSnapshots, changeCount)
 }
+
+func (c *policyRetentionFlags) setTagRetentionRulesFromFlags(ctx context.Context, rp *policy.RetentionPolicy, changeCount *int) error {
+	if c.policySetClearKeepTagged {
+		log(ctx).Info(" - removing all tag retention rules")
+
+		*changeCount++
+
+		rp.TagRules = nil
+	}
+
+	for _, v := range c.policySetKeepTagged {
+		rule, err := parseTagRetentionRule(v)
+		if err != nil {
+			return err
+		}
+
+		*changeCount++
+
+		log(ctx).Infof(" - setting retention of snapshots tagged %v", rule)
+
+		rp.TagRules = setTagRetentionRule(rp.TagRules, rule)
+	}
+
+	return nil
+}
+
+// parseTagRetentionRule parses tag retention rule in the form TAG[=N].
+func parseTagRetentionRule(s string) (policy.TagRetentionRule, error) {
+	tag, count, hasCount := strings.Cut(s, "=")
+	if tag == "" || strings.HasPrefix(tag, ":") {
+		return policy.TagRetentionRule{}, errors.Errorf("invalid tag retention rule %q, expected TAG[=N]", s)
+	}
+
+	rule := policy.TagRetentionRule{Tag: tag}
+
+	if hasCount {
+		n, err := strconv.ParseInt(count, 10, 32)
+		if err != nil || n < 0 {
+			return policy.TagRetentionRule{}, errors.Errorf("invalid number of tagged snapshots to keep in %q", s)
+		}
+
+		keep := policy.OptionalInt(n)
+		rule.KeepLatest = &keep
+	}
+
+	return rule, nil
+}
+
+// setTagRetentionRule replaces the rule for the same tag or appends a new one.
+func setTagRetentionRule(rules []policy.TagRetentionRule, rule policy.TagRetentionRule) []policy.TagRetentionRule {
+	for i, r := range rules {
+		if r.Tag == rule.Tag {
+			rules[i] = rule
+			return rules
+		}
+	}
+
+	return append(rules, rule)
+}
@@ -0,0 +1,52 @@
+package cli_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSetTagRetentionPolicy(t *testing.T) {
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	td := testutil.TempDirectory(t)
+
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--keep-latest=1", "--keep-hourly=0", "--keep-daily=0", "--keep-weekly=0", "--keep-monthly=0", "--keep-annual=0", "--keep-tagged=release", "--keep-tagged=pre-upgrade=1")
+
+	lines := compressSpaces(e.RunAndExpectSuccess(t, "policy", "show", td))
+	require.Contains(t, lines, " Tagged snapshots: (defined for this target)")
+	require.Contains(t, lines, " release never expire")
+	require.Contains(t, lines, " pre-upgrade keep latest 1")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", td, "--tags=release:1.0")
+	e.RunAndExpectSuccess(t, "snapshot", "create", td, "--tags=pre-upgrade:a")
+	e.RunAndExpectSuccess(t, "snapshot", "create", td, "--tags=pre-upgrade:b")
+	e.RunAndExpectSuccess(t, "snapshot", "create", td)
+	e.RunAndExpectSuccess(t, "snapshot", "create", td)
+
+	var manifests []cli.SnapshotManifest
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", td, "--json"), &manifests)
+
+	var tags []string
+
+	for _, m := range manifests {
+		tags = append(tags, fmt.Sprintf("%v", m.Tags))
+	}
+
+	require.Equal(t, []string{"map[tag:release:1.0]", "map[tag:pre-upgrade:b]", "map[]"}, tags)
+
+	e.RunAndExpectFailure(t, "policy", "set", td, "--keep-tagged=release=x")
+	e.RunAndExpectSuccess(t, "policy", "set", td, "--clear-keep-tagged")
+
+	lines = compressSpaces(e.RunAndExpectSuccess(t, "policy", "show", td))
+	require.NotContains(t, lines, " Tagged snapshots: (defined for this target)")
+}
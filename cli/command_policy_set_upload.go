@@ -12,12 +12,18 @@ type policyUploadFlags struct {
 	maxParallelUploads            string
 	maxParallelFileReads          string
 	parallelizeUploadAboveSizeMiB string
+	packFilesBelowSizeKiB         string
+	inlineFilesBelowSize          string
+	memoryBudgetMiB               string
 }
 
 func (c *policyUploadFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("max-parallel-file-reads", "Maximum number of parallel file reads").StringVar(&c.maxParallelFileReads)
 	cmd.Flag("max-parallel-snapshots", "Maximum number of parallel snapshots (server, KopiaUI only)").StringVar(&c.maxParallelUploads)
 	cmd.Flag("parallel-upload-above-size-mib", "Use parallel uploads above size").StringVar(&c.parallelizeUploadAboveSizeMiB)
+	cmd.Flag("pack-files-below-size-kib", "Pack contents of files below size into shared objects").StringVar(&c.packFilesBelowSizeKiB)
+	cmd.Flag("inline-files-below-size", "Store contents of files below size in bytes inline in directory entries (at most 16384)").StringVar(&c.inlineFilesBelowSize)
+	cmd.Flag("memory-budget-mib", "Target memory usage of snapshot uploads, parallelism and buffers are adjusted to stay within it").StringVar(&c.memoryBudgetMiB)
 }
 
 func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, up *policy.UploadPolicy, changeCount *int) error {
@@ -29,5 +35,17 @@ func (c *policyUploadFlags) setUploadPolicyFromFlags(ctx context.Context, up *po
 		return err
 	}
 
-	return applyOptionalInt64MiB(ctx, "parallel upload above size", &up.ParallelUploadAboveSize, c.parallelizeUploadAboveSizeMiB, changeCount)
+	if err := applyOptionalInt64MiB(ctx, "parallel upload above size", &up.ParallelUploadAboveSize, c.parallelizeUploadAboveSizeMiB, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyOptionalInt64KiB(ctx, "pack files below size", &up.PackFilesBelowSize, c.packFilesBelowSizeKiB, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyOptionalInt64Bytes(ctx, "inline files below size", &up.InlineFilesBelowSize, c.inlineFilesBelowSize, 1, changeCount); err != nil {
+		return err
+	}
+
+	return applyOptionalInt64MiB(ctx, "memory budget", &up.MemoryBudget, c.memoryBudgetMiB, changeCount)
 }
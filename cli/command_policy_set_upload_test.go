@@ -20,6 +20,7 @@ func TestSetUploadPolicy(t *testing.T) {
 	require.Contains(t, lines, " Max parallel snapshots (server/UI): 1 (defined for this target)")
 	require.Contains(t, lines, " Max parallel file reads: - (defined for this target)")
 	require.Contains(t, lines, " Parallel upload above size: 2.1 GB (defined for this target)")
+	require.Contains(t, lines, " Pack files below size: - (defined for this target)")
 
 	// make some directory we'll be setting policy on
 	td := testutil.TempDirectory(t)
@@ -30,7 +31,7 @@ func TestSetUploadPolicy(t *testing.T) {
 	require.Contains(t, lines, " Max parallel file reads: - inherited from (global)")
 	require.Contains(t, lines, " Parallel upload above size: 2.1 GB inherited from (global)")
 
-	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--max-parallel-snapshots=7", "--max-parallel-file-reads=33", "--parallel-upload-above-size-mib=4096")
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--max-parallel-snapshots=7", "--max-parallel-file-reads=33", "--parallel-upload-above-size-mib=4096", "--pack-files-below-size-kib=4")
 
 	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
 	lines = compressSpaces(lines)
@@ -38,8 +39,9 @@ func TestSetUploadPolicy(t *testing.T) {
 	require.Contains(t, lines, " Max parallel snapshots (server/UI): 7 inherited from (global)")
 	require.Contains(t, lines, " Max parallel file reads: 33 inherited from (global)")
 	require.Contains(t, lines, " Parallel upload above size: 4.3 GB inherited from (global)")
+	require.Contains(t, lines, " Pack files below size: 4.1 KB inherited from (global)")
 
-	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--max-parallel-snapshots=default", "--max-parallel-file-reads=default", "--parallel-upload-above-size-mib=default")
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--max-parallel-snapshots=default", "--max-parallel-file-reads=default", "--parallel-upload-above-size-mib=default", "--pack-files-below-size-kib=default")
 
 	lines = e.RunAndExpectSuccess(t, "policy", "show", td)
 	lines = compressSpaces(lines)
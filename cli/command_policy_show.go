@@ -23,12 +23,17 @@ type commandPolicyShow struct {
 func (c *commandPolicyShow) setup(svc appServices, parent commandParent) {
 	cmd := parent.Command("show", "Show snapshot policy.").Alias("get")
 	c.policyTargetFlags.setup(cmd)
+	c.policyTargetFlags.setupEnforced(cmd)
 	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
 func (c *commandPolicyShow) run(ctx context.Context, rep repo.Repository) error {
+	if c.enforced {
+		return c.showEnforced(ctx, rep)
+	}
+
 	targets, err := c.policyTargets(ctx, rep)
 	if err != nil {
 		return err
@@ -50,6 +55,22 @@ func (c *commandPolicyShow) run(ctx context.Context, rep repo.Repository) error
 	return nil
 }
 
+// showEnforced prints the enforced policy as defined, since it does not inherit values from other policies.
+func (c *commandPolicyShow) showEnforced(ctx context.Context, rep repo.Repository) error {
+	if err := c.validateEnforcedTarget(); err != nil {
+		return err
+	}
+
+	p, err := policy.GetEnforcedPolicy(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "can't get enforced policy")
+	}
+
+	c.out.printStdout("%s\n", c.jo.jsonIndentedBytes(p, "  "))
+
+	return nil
+}
+
 type policyTableRow struct {
 	name  string
 	value string
@@ -134,13 +155,15 @@ func printPolicy(out *textOutput, p *policy.Policy, def *policy.Definition) {
 	rows = append(rows, policyTableRow{})
 	rows = appendOSSnapshotPolicyRows(rows, p, def)
 	rows = append(rows, policyTableRow{})
+	rows = appendExtendedAttributesPolicyRows(rows, p, def)
+	rows = append(rows, policyTableRow{})
 	rows = appendLoggingPolicyRows(rows, p, def)
 
 	out.printStdout("Policy for %v:\n\n%v\n", p.Target(), alignedPolicyTableRows(rows))
 }
 
 func appendRetentionPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy.Definition) []policyTableRow {
-	return append(rows,
+	rows = append(rows,
 		policyTableRow{"Retention:", "", ""},
 		policyTableRow{"  Annual snapshots:", valueOrNotSet(p.RetentionPolicy.KeepAnnual), definitionPointToString(p.Target(), def.RetentionPolicy.KeepAnnual)},
 		policyTableRow{"  Monthly snapshots:", valueOrNotSet(p.RetentionPolicy.KeepMonthly), definitionPointToString(p.Target(), def.RetentionPolicy.KeepMonthly)},
@@ -150,6 +173,24 @@ func appendRetentionPolicyRows(rows []policyTableRow, p *policy.Policy, def *pol
 		policyTableRow{"  Latest snapshots:", valueOrNotSet(p.RetentionPolicy.KeepLatest), definitionPointToString(p.Target(), def.RetentionPolicy.KeepLatest)},
 		policyTableRow{"  Ignore identical snapshots:", boolToString(p.RetentionPolicy.IgnoreIdenticalSnapshots.OrDefault(false)), definitionPointToString(p.Target(), def.RetentionPolicy.IgnoreIdenticalSnapshots)},
 	)
+
+	if len(p.RetentionPolicy.TagRules) > 0 {
+		rows = append(rows, policyTableRow{"  Tagged snapshots:", "", definitionPointToString(p.Target(), def.RetentionPolicy.TagRules)})
+
+		for _, r := range p.RetentionPolicy.TagRules {
+			rows = append(rows, policyTableRow{"    " + r.Tag, tagRetentionRuleValue(r), ""})
+		}
+	}
+
+	return rows
+}
+
+func tagRetentionRuleValue(r policy.TagRetentionRule) string {
+	if r.KeepLatest == nil {
+		return "never expire"
+	}
+
+	return fmt.Sprintf("keep latest %v", *r.KeepLatest)
 }
 
 func boolToString(v bool) string {
@@ -184,6 +225,17 @@ func appendFilesPolicyValue(items []policyTableRow, p *policy.Policy, def *polic
 		items = append(items, policyTableRow{"  No ignore rules:", "", ""})
 	}
 
+	if len(p.FilesPolicy.IgnoreRuleFiles) > 0 {
+		items = append(items, policyTableRow{
+			"  Shared ignore rule files:", "",
+			definitionPointToString(p.Target(), def.FilesPolicy.IgnoreRuleFiles),
+		})
+
+		for _, f := range p.FilesPolicy.IgnoreRuleFiles {
+			items = append(items, policyTableRow{"    " + f, "", ""})
+		}
+	}
+
 	if len(p.FilesPolicy.DotIgnoreFiles) > 0 {
 		items = append(items, policyTableRow{
 			"  Read ignore rules from files:", "",
@@ -207,6 +259,14 @@ func appendFilesPolicyValue(items []policyTableRow, p *policy.Policy, def *polic
 		"  Scan one filesystem only:",
 		boolToString(p.FilesPolicy.OneFileSystem.OrDefault(false)),
 		definitionPointToString(p.Target(), def.FilesPolicy.OneFileSystem),
+	}, policyTableRow{
+		"  Follow reparse points:",
+		boolToString(p.FilesPolicy.FollowReparsePoints.OrDefault(false)),
+		definitionPointToString(p.Target(), def.FilesPolicy.FollowReparsePoints),
+	}, policyTableRow{
+		"  Snapshot special files:",
+		boolToString(p.FilesPolicy.SnapshotSpecialFiles.OrDefault(false)),
+		definitionPointToString(p.Target(), def.FilesPolicy.SnapshotSpecialFiles),
 	})
 
 	return items
@@ -277,6 +337,9 @@ func appendUploadPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy
 		policyTableRow{"  Max parallel snapshots (server/UI):", valueOrNotSet(p.UploadPolicy.MaxParallelSnapshots), definitionPointToString(p.Target(), def.UploadPolicy.MaxParallelSnapshots)},
 		policyTableRow{"  Max parallel file reads:", valueOrNotSet(p.UploadPolicy.MaxParallelFileReads), definitionPointToString(p.Target(), def.UploadPolicy.MaxParallelFileReads)},
 		policyTableRow{"  Parallel upload above size:", valueOrNotSetOptionalInt64Bytes(p.UploadPolicy.ParallelUploadAboveSize), definitionPointToString(p.Target(), def.UploadPolicy.ParallelUploadAboveSize)},
+		policyTableRow{"  Pack files below size:", valueOrNotSetOptionalInt64Bytes(p.UploadPolicy.PackFilesBelowSize), definitionPointToString(p.Target(), def.UploadPolicy.PackFilesBelowSize)},
+		policyTableRow{"  Inline files below size:", valueOrNotSetOptionalInt64Bytes(p.UploadPolicy.InlineFilesBelowSize), definitionPointToString(p.Target(), def.UploadPolicy.InlineFilesBelowSize)},
+		policyTableRow{"  Memory budget:", valueOrNotSetOptionalInt64Bytes(p.UploadPolicy.MemoryBudget), definitionPointToString(p.Target(), def.UploadPolicy.MemoryBudget)},
 	)
 }
 
@@ -483,11 +546,23 @@ func appendOSSnapshotPolicyRows(rows []policyTableRow, p *policy.Policy, def *po
 	rows = append(rows,
 		policyTableRow{"OS-level snapshot support:", "", ""},
 		policyTableRow{"  Volume Shadow Copy:", p.OSSnapshotPolicy.VolumeShadowCopy.Enable.String(), definitionPointToString(p.Target(), def.OSSnapshotPolicy.VolumeShadowCopy.Enable)},
+		policyTableRow{"  Filesystem snapshot:", p.OSSnapshotPolicy.FilesystemSnapshot.Enable.String(), definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.Enable)},
+		policyTableRow{"  Filesystem snapshot provider:", p.OSSnapshotPolicy.FilesystemSnapshot.Provider, definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.Provider)},
+		policyTableRow{"  LVM snapshot size:", valueOrNotSetOptionalInt64Bytes(p.OSSnapshotPolicy.FilesystemSnapshot.LVMSnapshotSize), definitionPointToString(p.Target(), def.OSSnapshotPolicy.FilesystemSnapshot.LVMSnapshotSize)},
 	)
 
 	return rows
 }
 
+func appendExtendedAttributesPolicyRows(rows []policyTableRow, p *policy.Policy, def *policy.Definition) []policyTableRow {
+	return append(rows,
+		policyTableRow{"Extended attributes:", "", ""},
+		policyTableRow{"  Capture xattrs:", boolToString(p.ExtendedAttributesPolicy.Xattrs.OrDefault(false)), definitionPointToString(p.Target(), def.ExtendedAttributesPolicy.Xattrs)},
+		policyTableRow{"  Capture ACLs:", boolToString(p.ExtendedAttributesPolicy.ACLs.OrDefault(false)), definitionPointToString(p.Target(), def.ExtendedAttributesPolicy.ACLs)},
+		policyTableRow{"  Capture platform metadata:", boolToString(p.ExtendedAttributesPolicy.PlatformMetadata.OrDefault(false)), definitionPointToString(p.Target(), def.ExtendedAttributesPolicy.PlatformMetadata)},
+	)
+}
+
 func valueOrNotSet(p *policy.OptionalInt) string {
 	if p == nil {
 		return "-"
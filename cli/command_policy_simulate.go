@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type commandPolicySimulate struct {
+	policyTargetFlags
+	horizon time.Duration
+
+	policyRetentionFlags
+	policySchedulingFlags
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandPolicySimulate) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("simulate", "Simulate snapshots that would be retained over time with the effective scheduling and retention policy, optionally overridden using flags.")
+	c.policyTargetFlags.setup(cmd)
+	cmd.Flag("horizon", "Duration of the simulation").Default("8760h").DurationVar(&c.horizon)
+	c.policyRetentionFlags.setup(cmd)
+	c.policySchedulingFlags.setup(cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandPolicySimulate) run(ctx context.Context, rep repo.Repository) error {
+	targets, err := c.policyTargets(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		effective, _, _, err := policy.GetEffectivePolicy(ctx, rep, target)
+		if err != nil {
+			return errors.Wrapf(err, "can't get effective policy for %q", target)
+		}
+
+		changeCount := 0
+
+		if err := c.setRetentionPolicyFromFlags(ctx, &effective.RetentionPolicy, &changeCount); err != nil {
+			return errors.Wrap(err, "retention policy")
+		}
+
+		if err := c.setSchedulingPolicyFromFlags(ctx, &effective.SchedulingPolicy, &changeCount); err != nil {
+			return errors.Wrap(err, "scheduling policy")
+		}
+
+		sim, err := policy.SimulateRetention(&effective.SchedulingPolicy, &effective.RetentionPolicy, c.horizon, clock.Now())
+		if err != nil {
+			return errors.Wrapf(err, "unable to simulate retention for %v", target)
+		}
+
+		if c.jo.jsonOutput {
+			c.out.printStdout("%s\n", c.jo.jsonBytes(sim))
+			continue
+		}
+
+		c.out.printStdout("Simulated retention for %v from %v until %v:\n\n", target, formatTimestamp(sim.Start), formatTimestamp(sim.End))
+
+		for _, s := range sim.Retained {
+			c.out.printStdout("  %v (%v)\n", formatTimestamp(s.StartTime), strings.Join(policy.CompactRetentionReasons(s.RetentionReasons), ","))
+		}
+
+		c.out.printStdout("\nCreated %v snapshots, expired %v, retained %v at the end and at most %v at any time.\n",
+			sim.CreatedCount, sim.ExpiredCount, len(sim.Retained), sim.MaxRetainedCount)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestPolicySimulate(t *testing.T) {
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	// global policy does not schedule snapshots by default.
+	e.RunAndExpectFailure(t, "policy", "simulate", "--global")
+
+	e.RunAndExpectSuccess(t, "policy", "set", "--global", "--snapshot-interval=1h")
+
+	var sim policy.RetentionSimulation
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "policy", "simulate", "--global", "--keep-latest=5", "--keep-hourly=0", "--keep-daily=2", "--keep-weekly=0", "--keep-monthly=0", "--keep-annual=0", "--horizon=96h", "--json"), &sim)
+
+	require.GreaterOrEqual(t, sim.CreatedCount, 96)
+	require.Equal(t, sim.CreatedCount-len(sim.Retained), sim.ExpiredCount)
+	// the latest snapshot of the previous day may also be one of the 5 latest, depending on the time of day.
+	require.GreaterOrEqual(t, len(sim.Retained), 5)
+	require.LessOrEqual(t, len(sim.Retained), 6)
+	require.Equal(t, []string{"latest-1", "daily-1"}, sim.Retained[0].RetentionReasons)
+
+	// flags only override the simulated policy, the defined one is unchanged.
+	lines := compressSpaces(e.RunAndExpectSuccess(t, "policy", "show", "--global"))
+	require.Contains(t, lines, " Latest snapshots: 10 (defined for this target)")
+
+	lines = e.RunAndExpectSuccess(t, "policy", "simulate", "--global", "--snapshot-interval=24h", "--horizon=72h")
+	require.Contains(t, strings.Join(lines, "\n"), "Created 4 snapshots")
+}
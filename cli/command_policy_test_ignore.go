@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type commandPolicyTestIgnore struct {
+	paths  []string
+	source string
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandPolicyTestIgnore) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("test-ignore", "Evaluate ignore rules and show whether the provided local paths would be included in snapshots.")
+	cmd.Arg("path", "Local paths to evaluate").Required().StringsVar(&c.paths)
+	cmd.Flag("source", "Root directory of the snapshot source (default: the nearest snapshot source containing the path, or its parent directory)").StringVar(&c.source)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandPolicyTestIgnore) run(ctx context.Context, rep repo.Repository) error {
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to list sources")
+	}
+
+	var decisions []ignorefs.Decision
+
+	for _, p := range c.paths {
+		d, err := c.evaluate(ctx, rep, sources, p)
+		if err != nil {
+			return err
+		}
+
+		decisions = append(decisions, d)
+
+		if c.jo.jsonOutput {
+			continue
+		}
+
+		if d.Ignored {
+			c.out.printStdout("IGNORED  %v: %v\n", p, d.Reason)
+		} else {
+			c.out.printStdout("INCLUDED %v\n", p)
+		}
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(decisions))
+	}
+
+	return nil
+}
+
+func (c *commandPolicyTestIgnore) evaluate(ctx context.Context, rep repo.Repository, sources []snapshot.SourceInfo, p string) (ignorefs.Decision, error) {
+	absPath, err := filepath.Abs(p)
+	if err != nil {
+		return ignorefs.Decision{}, errors.Wrapf(err, "invalid path %v", p)
+	}
+
+	root := c.source
+	if root == "" {
+		root = nearestSourceRoot(rep, sources, absPath)
+	}
+
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return ignorefs.Decision{}, errors.Wrapf(err, "invalid source %v", root)
+	}
+
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ignorefs.Decision{}, errors.Errorf("%v is not inside source %v", absPath, root)
+	}
+
+	entry, err := getLocalFSEntry(ctx, root)
+	if err != nil {
+		return ignorefs.Decision{}, err
+	}
+
+	dir, ok := entry.(fs.Directory)
+	if !ok {
+		return ignorefs.Decision{}, errors.Errorf("source %v is not a directory", root)
+	}
+
+	policyTree, err := policy.TreeForSource(ctx, rep, snapshot.SourceInfo{
+		Path:     filepath.Clean(root),
+		Host:     rep.ClientOptions().Hostname,
+		UserName: rep.ClientOptions().Username,
+	})
+	if err != nil {
+		return ignorefs.Decision{}, errors.Wrap(err, "unable to get policy tree")
+	}
+
+	//nolint:wrapcheck
+	return ignorefs.Evaluate(ctx, dir, policyTree, filepath.ToSlash(rel))
+}
+
+// nearestSourceRoot returns the deepest snapshot source of this client containing the provided path,
+// or the parent directory of the path if there is none.
+func nearestSourceRoot(rep repo.Repository, sources []snapshot.SourceInfo, absPath string) string {
+	best := filepath.Dir(absPath)
+	bestLen := -1
+
+	for _, si := range sources {
+		if si.Host != rep.ClientOptions().Hostname || si.UserName != rep.ClientOptions().Username {
+			continue
+		}
+
+		rel, err := filepath.Rel(si.Path, absPath)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		if len(si.Path) > bestLen {
+			best = si.Path
+			bestLen = len(si.Path)
+		}
+	}
+
+	return best
+}
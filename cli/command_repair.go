@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandRepair struct {
+	parallel   int
+	packPrefix string
+	dryRun     bool
+
+	out textOutput
+}
+
+func (c *commandRepair) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("repair", "Repair corrupted contents using error correction data")
+	cmd.Flag("parallel", "Number of parallel workers").IntVar(&c.parallel)
+	cmd.Flag("prefix", "Only check contents in pack blobs with a given prefix").StringVar(&c.packPrefix)
+	cmd.Flag("dry-run", "Only find corrupted contents, do not repair them").BoolVar(&c.dryRun)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandRepair) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	stats, err := maintenance.RepairContents(ctx, rep, maintenance.RepairContentsOptions{
+		Parallel:   c.parallel,
+		PackPrefix: blob.ID(c.packPrefix),
+		DryRun:     c.dryRun,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to repair contents")
+	}
+
+	c.out.printStdout("Checked %v contents, found %v corrupted, repaired %v.\n", stats.CheckedContents, stats.CorruptedContents, stats.RepairedContents)
+
+	if stats.RepairedContents > 0 {
+		log(ctx).Info("Pack blobs with corrupted contents will be deleted by the next full maintenance.")
+	}
+
+	if stats.UnrecoverableContents > 0 {
+		return errors.Errorf("found %v contents that could not be repaired", stats.UnrecoverableContents)
+	}
+
+	return nil
+}
@@ -1,33 +1,57 @@
 package cli
 
 type commandRepository struct {
+	analyze          commandRepositoryAnalyze
+	compressionDict  commandRepositoryCompressionDictionary
 	connect          commandRepositoryConnect
 	create           commandRepositoryCreate
+	dedupReport      commandRepositoryDedupReport
 	disconnect       commandRepositoryDisconnect
+	drBundle         commandRepositoryDRBundle
+	encryptionDomain commandRepositoryEncryptionDomain
+	history          commandRepositoryHistory
+	key              commandRepositoryKey
 	repair           commandRepositoryRepair
+	replicate        commandRepositoryReplicate
 	setClient        commandRepositorySetClient
+	setImmutability  commandRepositorySetImmutability
 	setParameters    commandRepositorySetParameters
+	stats            commandRepositoryStats
 	changePassword   commandRepositoryChangePassword
 	status           commandRepositoryStatus
 	syncTo           commandRepositorySyncTo
 	throttle         commandRepositoryThrottle
 	validateProvider commandRepositoryValidateProvider
 	upgrade          commandRepositoryUpgrade
+	usage            commandRepositoryUsage
+	upgradeKDF       commandRepositoryUpgradeKDF
 }
 
 func (c *commandRepository) setup(svc advancedAppServices, parent commandParent) {
 	cmd := parent.Command("repository", "Commands to manipulate repository.").Alias("repo")
 
+	c.analyze.setup(svc, cmd)
+	c.compressionDict.setup(svc, cmd)
 	c.connect.setup(svc, cmd)
 	c.create.setup(svc, cmd)
+	c.dedupReport.setup(svc, cmd)
 	c.disconnect.setup(svc, cmd)
+	c.drBundle.setup(svc, cmd)
+	c.encryptionDomain.setup(svc, cmd)
+	c.history.setup(svc, cmd)
+	c.key.setup(svc, cmd)
 	c.repair.setup(svc, cmd)
+	c.replicate.setup(svc, cmd)
 	c.setClient.setup(svc, cmd)
+	c.setImmutability.setup(svc, cmd)
 	c.setParameters.setup(svc, cmd)
+	c.stats.setup(svc, cmd)
 	c.status.setup(svc, cmd)
 	c.syncTo.setup(svc, cmd)
 	c.throttle.setup(svc, cmd)
 	c.changePassword.setup(svc, cmd)
 	c.validateProvider.setup(svc, cmd)
 	c.upgrade.setup(svc, cmd)
+	c.usage.setup(svc, cmd)
+	c.upgradeKDF.setup(svc, cmd)
 }
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/paramadvisor"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+type commandRepositoryAnalyze struct {
+	suggest bool
+	apply   bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryAnalyze) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("analyze", "Analyze content sizes, deduplication and compression of the repository.")
+	cmd.Flag("suggest", "Suggest splitter, compression and index format parameters").BoolVar(&c.suggest)
+	cmd.Flag("apply", "Apply suggestions which can be changed in place (implies --suggest)").BoolVar(&c.apply)
+	c.jo.setup(svc, cmd)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryAnalyze) run(ctx context.Context, rep repo.DirectRepository) error {
+	a, err := paramadvisor.Analyze(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to analyze repository")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(a))
+	} else {
+		c.printAnalysis(a)
+	}
+
+	if !c.apply {
+		return nil
+	}
+
+	return repo.DirectWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "cli:repository:analyze",
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		return applySuggestions(ctx, w, a.Suggestions)
+	})
+}
+
+func (c *commandRepositoryAnalyze) printAnalysis(a *paramadvisor.Analysis) {
+	c.out.printStdout("%-12v %12v %12v %7v\n", "SIZE", "CONTENTS", "BYTES", "BYTES%")
+
+	lower := "0"
+
+	for _, b := range a.SizeHistogram {
+		label := "> " + lower
+		if b.MaxSize != 0 {
+			label = "<= " + units.BytesString(b.MaxSize)
+			lower = units.BytesString(b.MaxSize)
+		}
+
+		c.out.printStdout("%-12v %12v %12v %6.1f%%\n", label, units.Count(b.Count), units.BytesString(b.Bytes), percentOf(b.Bytes, a.DataBytes))
+	}
+
+	c.out.printStdout("\nData:         %v in %v contents (%v metadata contents)\n",
+		units.BytesString(a.DataBytes), units.Count(a.DataContents), units.Count(a.MetadataContents))
+	c.out.printStdout("Snapshots:    %v of files, deduplication ratio %.2fx\n", units.BytesString(a.LogicalBytes), a.DedupRatio)
+	c.out.printStdout("Compressed:   %v stored as %v (%.1f%%)\n",
+		units.BytesString(a.CompressedOriginalBytes), units.BytesString(a.CompressedPackedBytes),
+		percentOf(a.CompressedPackedBytes, a.CompressedOriginalBytes))
+
+	if a.SampledContents > 0 {
+		c.out.printStdout("Uncompressed: %v, %v sampled contents compress to %.1f%% with %v\n",
+			units.BytesString(a.UncompressedBytes), a.SampledContents, 100*a.SampledCompressionRatio, paramadvisor.SuggestedCompression) //nolint:mnd
+	}
+
+	c.out.printStdout("Parameters:   splitter %v, compression %v, index version %v\n", a.Splitter, a.Compression, a.IndexVersion)
+
+	if !c.suggest && !c.apply {
+		return
+	}
+
+	if len(a.Suggestions) == 0 {
+		c.out.printStdout("\nNo parameter changes are suggested.\n")
+		return
+	}
+
+	c.out.printStdout("\nSuggestions:\n")
+
+	for _, s := range a.Suggestions {
+		inPlace := ""
+		if s.InPlace {
+			inPlace = " (can be applied in place)"
+		}
+
+		c.out.printStdout("  %v: %v -> %v%v\n    %v\n", s.Parameter, s.Current, s.Suggested, inPlace, s.Reason)
+	}
+}
+
+func applySuggestions(ctx context.Context, w repo.DirectRepositoryWriter, suggestions []*paramadvisor.Suggestion) error {
+	for _, s := range suggestions {
+		if !s.InPlace {
+			log(ctx).Infof("Not applying %v, it can't be changed in place.", s.Parameter)
+			continue
+		}
+
+		switch s.Parameter {
+		case paramadvisor.ParameterCompression:
+			pol, err := paramadvisor.GlobalPolicy(ctx, w)
+			if err != nil {
+				return err
+			}
+
+			pol.CompressionPolicy.CompressorName = compression.Name(s.Suggested)
+
+			if err := policy.SetPolicy(ctx, w, policy.GlobalPolicySourceInfo, pol); err != nil {
+				return errors.Wrap(err, "unable to set global policy")
+			}
+
+			log(ctx).Infof("Set compression of the global policy to %v.", s.Suggested)
+
+		case paramadvisor.ParameterIndexVersion:
+			if err := applyIndexVersion(ctx, w, s.Suggested); err != nil {
+				return err
+			}
+
+			log(ctx).Infof("Set index format version to %v.", s.Suggested)
+
+		default:
+			log(ctx).Infof("Not applying %v.", s.Parameter)
+		}
+	}
+
+	return nil
+}
+
+func applyIndexVersion(ctx context.Context, w repo.DirectRepositoryWriter, version string) error {
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return errors.Wrap(err, "invalid index version")
+	}
+
+	mp, err := w.FormatManager().GetMutableParameters(ctx)
+	if err != nil {
+		return errors.Wrap(err, "mutable parameters")
+	}
+
+	blobcfg, err := w.FormatManager().BlobCfgBlob(ctx)
+	if err != nil {
+		return errors.Wrap(err, "blob configuration")
+	}
+
+	requiredFeatures, err := w.FormatManager().RequiredFeatures(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get required features")
+	}
+
+	if v <= mp.IndexVersion {
+		return nil
+	}
+
+	mp.IndexVersion = v
+
+	return updateRepositoryParameters(ctx, false, mp, w, blobcfg, requiredFeatures)
+}
@@ -0,0 +1,42 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/paramadvisor"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryAnalyze(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "compressible"), bytes.Repeat([]byte("highly compressible contents "), 10000), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	var a paramadvisor.Analysis
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "analyze", "--suggest", "--json"), &a)
+	require.Positive(t, a.DataContents)
+	require.Positive(t, a.SampledContents)
+	require.Equal(t, "none", a.Compression)
+	require.Len(t, a.Suggestions, 1)
+	require.Equal(t, paramadvisor.ParameterCompression, a.Suggestions[0].Parameter)
+	require.Equal(t, string(paramadvisor.SuggestedCompression), a.Suggestions[0].Suggested)
+	require.True(t, a.Suggestions[0].InPlace)
+
+	env.RunAndExpectSuccess(t, "repository", "analyze", "--apply")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "analyze", "--suggest", "--json"), &a)
+	require.Equal(t, string(paramadvisor.SuggestedCompression), a.Compression)
+	require.Empty(t, a.Suggestions)
+}
@@ -6,6 +6,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
 )
 
 type commandRepositoryChangePassword struct {
@@ -42,6 +43,8 @@ func (c *commandRepositoryChangePassword) run(ctx context.Context, rep repo.Dire
 
 	log(ctx).Infof(`NOTE: Repository password has been changed.`)
 
+	eventlog.RecordOrWarn(ctx, rep, eventlog.PasswordChanged, "changed repository password", nil)
+
 	if err := c.svc.passwordPersistenceStrategy().PersistPassword(ctx, c.svc.repositoryConfigFileName(), newPass); err != nil {
 		return errors.Wrap(err, "unable to persist password")
 	}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+type commandRepositoryCompressionDictionary struct {
+	list  commandRepositoryCompressionDictionaryList
+	train commandRepositoryCompressionDictionaryTrain
+}
+
+func (c *commandRepositoryCompressionDictionary) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("compression-dictionary", "Manage compression dictionaries used for metadata contents.").Alias("compression-dict")
+
+	c.list.setup(svc, cmd)
+	c.train.setup(svc, cmd)
+}
+
+type commandRepositoryCompressionDictionaryList struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryCompressionDictionaryList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List compression dictionaries.").Alias("ls")
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryCompressionDictionaryList) run(ctx context.Context, rep repo.DirectRepository) error {
+	dicts := rep.FormatManager().GetCompressionDictionaries()
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(dicts))
+		return nil
+	}
+
+	now := rep.Time()
+
+	for _, d := range dicts {
+		state := "active"
+		if !d.IsActive(now) {
+			state = "pending"
+		}
+
+		c.out.printStdout("%v %v %v %v\n", d.ID, formatTimestamp(d.CreatedTime), units.BytesString(len(d.Data)), state)
+	}
+
+	return nil
+}
+
+type commandRepositoryCompressionDictionaryTrain struct {
+	maxSamples     int
+	maxSampleBytes int64
+	maxSize        int64
+
+	out textOutput
+}
+
+func (c *commandRepositoryCompressionDictionaryTrain) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("train", "Train a new compression dictionary from existing directory and manifest contents.")
+	cmd.Flag("max-samples", "Maximum number of contents to sample").Default("10000").IntVar(&c.maxSamples)
+	cmd.Flag("max-sample-bytes", "Maximum total size of sampled contents").Default("67108864").Int64Var(&c.maxSampleBytes)
+	cmd.Flag("max-size", "Maximum size of the dictionary").Default("65536").Int64Var(&c.maxSize)
+
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryCompressionDictionaryTrain) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if !rep.ContentReader().SupportsContentCompression() {
+		return errors.New("repository does not support compression, upgrade it first")
+	}
+
+	samples, err := content.CompressionDictionarySamples(ctx, rep.ContentReader(),
+		[]content.IDPrefix{content.IDPrefix("k"), manifest.ContentPrefix},
+		c.maxSamples, int(c.maxSampleBytes))
+	if err != nil {
+		return errors.Wrap(err, "unable to sample contents")
+	}
+
+	fm := rep.FormatManager()
+	id := format.NextCompressionDictionaryID(fm.GetCompressionDictionaries(), compression.MinZstdDictionaryID)
+
+	data, err := compression.TrainZstdDictionary(id, samples, int(c.maxSize))
+	if err != nil {
+		return errors.Wrap(err, "unable to train compression dictionary")
+	}
+
+	if err := fm.AddCompressionDictionary(ctx, format.CompressionDictionary{ID: id, Data: data}); err != nil {
+		return errors.Wrap(err, "unable to add compression dictionary")
+	}
+
+	c.out.printStderr("Trained compression dictionary %v (%v) from %v contents, it will be used for writing after %v.\n",
+		id, units.BytesString(len(data)), len(samples), format.CompressionDictionaryActivationDelay)
+
+	return nil
+}
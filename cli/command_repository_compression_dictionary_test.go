@@ -0,0 +1,57 @@
+package cli_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryCompressionDictionary(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	// no metadata to train from yet.
+	e.RunAndExpectFailure(t, "repo", "compression-dictionary", "train")
+
+	src := testutil.TempDirectory(t)
+
+	for i := range 30 {
+		dir := filepath.Join(src, fmt.Sprintf("dir-%v", i))
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+
+		for j := range 10 {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%v-%v.txt", i, j)), []byte(fmt.Sprintf("contents %v %v", i, j)), 0o600))
+		}
+	}
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", src)
+	e.RunAndExpectSuccess(t, "repo", "compression-dictionary", "train", "--max-size=4096")
+	e.RunAndExpectSuccess(t, "repo", "compression-dictionary", "train", "--max-size=4096")
+
+	var dicts []format.CompressionDictionary
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "repo", "compression-dictionary", "list", "--json"), &dicts)
+	require.Len(t, dicts, 2)
+	require.NotEqual(t, dicts[0].ID, dicts[1].ID)
+	require.NotEmpty(t, dicts[0].Data)
+
+	lines := e.RunAndExpectSuccess(t, "repo", "compression-dictionary", "list")
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[0], "pending")
+
+	// the repository remains usable.
+	e.RunAndExpectSuccess(t, "snapshot", "create", src)
+	e.RunAndExpectSuccess(t, "snapshot", "verify")
+}
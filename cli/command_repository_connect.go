@@ -7,10 +7,12 @@
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/keyprovider"
 	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
 )
 
 type commandRepositoryConnect struct {
@@ -53,8 +55,13 @@ type connectOptions struct {
 	connectCheckForUpdates        bool
 	connectReadonly               bool
 	connectPermissiveCacheLoading bool
+	connectVerifyContentHash      bool
 	connectDescription            string
 	connectEnableActions          bool
+	connectKeyProvider            string
+
+	// keyProvider is set when the password was retrieved using --key-provider.
+	keyProvider *keyprovider.Config
 
 	formatBlobCacheDuration time.Duration
 	disableFormatBlobCache  bool
@@ -75,8 +82,10 @@ func (c *connectOptions) setup(svc appServices, cmd *kingpin.CmdClause) {
 	cmd.Flag("check-for-updates", "Periodically check for Kopia updates on GitHub").Default("true").Envar(svc.EnvName(checkForUpdatesEnvar)).BoolVar(&c.connectCheckForUpdates)
 	cmd.Flag("readonly", "Make repository read-only to avoid accidental changes").BoolVar(&c.connectReadonly)
 	cmd.Flag("permissive-cache-loading", "Do not fail when loading bad cache index entries.  Repository must be opened in read-only mode").Hidden().BoolVar(&c.connectPermissiveCacheLoading)
+	cmd.Flag("verify-content-hash-on-read", "Verify hashes of all contents read from the repository").BoolVar(&c.connectVerifyContentHash)
 	cmd.Flag("description", "Human-readable description of the repository").StringVar(&c.connectDescription)
 	cmd.Flag("enable-actions", "Allow snapshot actions").BoolVar(&c.connectEnableActions)
+	cmd.Flag("key-provider", "Retrieve repository password from external key storage: "+keyprovider.SpecHelp()).PlaceHolder("TYPE:PARAMS").Envar(svc.EnvName("KOPIA_KEY_PROVIDER")).StringVar(&c.connectKeyProvider)
 	cmd.Flag("repository-format-cache-duration", "Duration of kopia.repository format blob cache").Hidden().DurationVar(&c.formatBlobCacheDuration)
 	cmd.Flag("disable-repository-format-cache", "Disable caching of kopia.repository format blob").Hidden().BoolVar(&c.disableFormatBlobCache)
 }
@@ -101,21 +110,35 @@ func (c *connectOptions) toRepoConnectOptions() *repo.ConnectOptions {
 			MinContentSweepAge:          content.DurationSeconds(c.contentMinSweepAge.Seconds()),
 			MinMetadataSweepAge:         content.DurationSeconds(c.metadataMinSweepAge.Seconds()),
 			MinIndexSweepAge:            content.DurationSeconds(c.indexMinSweepAge.Seconds()),
+			TotalCacheSizeBytes:         c.totalCacheSizeMB << 20, //nolint:mnd
 		},
 		ClientOptions: repo.ClientOptions{
 			Hostname:                c.connectHostname,
 			Username:                c.connectUsername,
 			ReadOnly:                c.connectReadonly,
 			PermissiveCacheLoading:  c.connectPermissiveCacheLoading,
+			VerifyContentHashOnRead: c.connectVerifyContentHash,
 			Description:             c.connectDescription,
 			EnableActions:           c.connectEnableActions,
 			FormatBlobCacheDuration: c.getFormatBlobCacheDuration(),
+			KeyProvider:             c.keyProvider,
 		},
 	}
 }
 
 func (c *App) runConnectCommandWithStorage(ctx context.Context, co *connectOptions, st blob.Storage) error {
-	pass, err := c.getPasswordFromFlags(ctx, false, false)
+	var (
+		pass string
+		err  error
+	)
+
+	if co.connectKeyProvider == "" && isPasswordlessRepository(ctx, st) {
+		// password-less repositories can't prompt for a password, the key must come from a key file.
+		pass, err = c.getPasswordlessKey(ctx, co, false)
+	} else {
+		pass, err = c.getConnectPassword(ctx, co, false)
+	}
+
 	if err != nil {
 		return errors.Wrap(err, "getting password")
 	}
@@ -123,11 +146,55 @@ func (c *App) runConnectCommandWithStorage(ctx context.Context, co *connectOptio
 	return c.runConnectCommandWithStorageAndPassword(ctx, co, st, pass)
 }
 
+// isPasswordlessRepository returns true if the storage contains a password-less repository.
+func isPasswordlessRepository(ctx context.Context, st blob.Storage) bool {
+	b, err := format.ReadKopiaRepositoryBlob(ctx, st)
+	if err != nil {
+		return false
+	}
+
+	f, err := format.ParseKopiaRepositoryJSON(b)
+	if err != nil {
+		return false
+	}
+
+	return f.IsPasswordless()
+}
+
+// getConnectPassword returns the password from the key provider specified using --key-provider
+// or falls back to regular password flags.
+func (c *App) getConnectPassword(ctx context.Context, co *connectOptions, isCreate bool) (string, error) {
+	if co.connectKeyProvider == "" {
+		return c.getPasswordFromFlags(ctx, isCreate, false)
+	}
+
+	kp, err := keyprovider.ParseSpec(co.connectKeyProvider)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid --key-provider")
+	}
+
+	pass, err := keyprovider.GetPassword(ctx, kp)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get password from key provider")
+	}
+
+	co.keyProvider = kp
+
+	return pass, nil
+}
+
 func (c *App) runConnectCommandWithStorageAndPassword(ctx context.Context, co *connectOptions, st blob.Storage, password string) error {
 	configFile := c.repositoryConfigFileName()
+
+	persist := c.passwordPersistenceStrategy()
+	if co.keyProvider != nil {
+		// the password will be retrieved from the key provider each time, don't store it.
+		persist = passwordpersist.None()
+	}
+
 	if err := passwordpersist.OnSuccess(
 		ctx, repo.Connect(ctx, configFile, st, password, co.toRepoConnectOptions()),
-		c.passwordPersistenceStrategy(), configFile, password); err != nil {
+		persist, configFile, password); err != nil {
 		return errors.Wrap(err, "error connecting to repository")
 	}
 
@@ -16,6 +16,9 @@ type commandRepositoryConnectServer struct {
 	connectAPIServerURL                              string
 	connectAPIServerCertFingerprint                  string
 	connectAPIServerLocalCacheKeyDerivationAlgorithm string
+	connectAPIServerRepositoryName                   string
+
+	sshTunnel sshTunnelFlags
 
 	svc advancedAppServices
 	out textOutput
@@ -29,18 +32,27 @@ func (c *commandRepositoryConnectServer) setup(svc advancedAppServices, parent c
 	cmd := parent.Command("server", "Connect to a repository API Server.")
 	cmd.Flag("url", "Server URL").Required().StringVar(&c.connectAPIServerURL)
 	cmd.Flag("server-cert-fingerprint", "Server certificate fingerprint").StringVar(&c.connectAPIServerCertFingerprint)
+	cmd.Flag("repository-name", "Name of the repository hosted by a multi-repository server").StringVar(&c.connectAPIServerRepositoryName)
 	//nolint:lll
 	cmd.Flag("local-cache-key-derivation-algorithm", "Key derivation algorithm used to derive the local cache encryption key").Hidden().Default(repo.DefaultServerRepoCacheKeyDerivationAlgorithm).EnumVar(&c.connectAPIServerLocalCacheKeyDerivationAlgorithm, repo.SupportedLocalCacheKeyDerivationAlgorithms()...)
+	c.sshTunnel.setup(cmd)
 	cmd.Action(svc.noRepositoryAction(c.run))
 }
 
 func (c *commandRepositoryConnectServer) run(ctx context.Context) error {
 	localCacheKeyDerivationAlgorithm := c.connectAPIServerLocalCacheKeyDerivationAlgorithm
 
+	tunnel, err := c.sshTunnel.options()
+	if err != nil {
+		return err
+	}
+
 	as := &repo.APIServerInfo{
 		BaseURL:                             strings.TrimSuffix(c.connectAPIServerURL, "/"),
 		TrustedServerCertificateFingerprint: strings.ToLower(c.connectAPIServerCertFingerprint),
 		LocalCacheKeyDerivationAlgorithm:    localCacheKeyDerivationAlgorithm,
+		RepositoryName:                      c.connectAPIServerRepositoryName,
+		SSHTunnel:                           tunnel,
 	}
 
 	configFile := c.svc.repositoryConfigFileName()
@@ -5,14 +5,18 @@
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
+	atunits "github.com/alecthomas/units"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/layout"
 	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/hashing"
+	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/repo/splitter"
 	"github.com/kopia/kopia/snapshot/policy"
 )
@@ -28,12 +32,20 @@ type commandRepositoryCreate struct {
 	createBlockEncryptionFormat       string
 	createBlockECCFormat              string
 	createBlockECCOverheadPercent     int
+	createBlockECCDataShards          int
+	createBlockECCParityShards        int
 	createBlockKeyDerivationAlgorithm string
 	createSplitter                    string
+	createSplitterMinSize             atunits.Base2Bytes
+	createSplitterAvgSize             atunits.Base2Bytes
+	createSplitterMaxSize             atunits.Base2Bytes
 	createOnly                        bool
 	createFormatVersion               int
 	retentionMode                     string
 	retentionPeriod                   time.Duration
+	blobLayoutPrefix                  string
+	blobLayoutShards                  string
+	createPasswordless                bool
 
 	co  connectOptions
 	svc advancedAppServices
@@ -47,11 +59,19 @@ func (c *commandRepositoryCreate) setup(svc advancedAppServices, parent commandP
 	cmd.Flag("encryption", "Content encryption algorithm.").PlaceHolder("ALGO").Default(encryption.DefaultAlgorithm).EnumVar(&c.createBlockEncryptionFormat, encryption.SupportedAlgorithms(false)...)
 	cmd.Flag("ecc", "[EXPERIMENTAL] Error correction algorithm.").PlaceHolder("ALGO").Default(ecc.DefaultAlgorithm).EnumVar(&c.createBlockECCFormat, ecc.SupportedAlgorithms()...)
 	cmd.Flag("ecc-overhead-percent", "[EXPERIMENTAL] How much space overhead can be used for error correction, in percentage. Use 0 to disable ECC.").Default("0").IntVar(&c.createBlockECCOverheadPercent)
+	cmd.Flag("ecc-data-shards", "[EXPERIMENTAL] Number of error correction data shards, computed from overhead if not set.").IntVar(&c.createBlockECCDataShards)
+	cmd.Flag("ecc-parity-shards", "[EXPERIMENTAL] Number of error correction parity shards, computed from overhead if not set.").IntVar(&c.createBlockECCParityShards)
 	cmd.Flag("object-splitter", "The splitter to use for new objects in the repository").Default(splitter.DefaultAlgorithm).EnumVar(&c.createSplitter, splitter.SupportedAlgorithms()...)
+	cmd.Flag("object-splitter-min-size", "Override minimum chunk size of the dynamic object splitter").BytesVar(&c.createSplitterMinSize)
+	cmd.Flag("object-splitter-avg-size", "Override average chunk size of the dynamic object splitter, must be a power of two").BytesVar(&c.createSplitterAvgSize)
+	cmd.Flag("object-splitter-max-size", "Override maximum chunk size of the dynamic object splitter").BytesVar(&c.createSplitterMaxSize)
 	cmd.Flag("create-only", "Create repository, but don't connect to it.").Short('c').BoolVar(&c.createOnly)
 	cmd.Flag("format-version", "Force a particular repository format version (1, 2 or 3, 0==default)").IntVar(&c.createFormatVersion)
 	cmd.Flag("retention-mode", "Set the blob retention-mode for supported storage backends.").EnumVar(&c.retentionMode, blob.Governance.String(), blob.Compliance.String())
 	cmd.Flag("retention-period", "Set the blob retention-period for supported storage backends.").DurationVar(&c.retentionPeriod)
+	cmd.Flag("blob-layout-prefix", "Store blobs other than the format blob under the provided prefix, to comply with bucket naming policies.").StringVar(&c.blobLayoutPrefix)
+	cmd.Flag("blob-layout-shards", "Comma-separated lengths of blob name segments separated with '/', for object stores that penalize flat namespaces (e.g. 1,3).").PlaceHolder("N,...").StringVar(&c.blobLayoutShards)
+	cmd.Flag("passwordless", "Create a repository without a password, unlocked using a random key stored in the file specified using --key-file, which is generated if it does not exist.").BoolVar(&c.createPasswordless)
 	//nolint:lll
 	cmd.Flag("format-block-key-derivation-algorithm", "Algorithm to derive the encryption key for the format block from the repository password").Default(format.DefaultKeyDerivationAlgorithm).EnumVar(&c.createBlockKeyDerivationAlgorithm, format.SupportedFormatBlobKeyDerivationAlgorithms()...)
 
@@ -77,7 +97,12 @@ func (c *commandRepositoryCreate) setup(svc advancedAppServices, parent commandP
 	}
 }
 
-func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() *repo.NewRepositoryOptions {
+func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() (*repo.NewRepositoryOptions, error) {
+	shards, err := layout.ParseShards(c.blobLayoutShards)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid blob layout shards")
+	}
+
 	return &repo.NewRepositoryOptions{
 		BlockFormat: format.ContentFormat{
 			MutableParameters: format.MutableParameters{
@@ -87,16 +112,26 @@ func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() *repo.NewRepos
 			Encryption:         c.createBlockEncryptionFormat,
 			ECC:                c.createBlockECCFormat,
 			ECCOverheadPercent: c.createBlockECCOverheadPercent,
+			ECCDataShards:      c.createBlockECCDataShards,
+			ECCParityShards:    c.createBlockECCParityShards,
 		},
 
 		ObjectFormat: format.ObjectFormat{
-			Splitter: c.createSplitter,
+			Splitter:        c.createSplitter,
+			SplitterMinSize: int(c.createSplitterMinSize),
+			SplitterAvgSize: int(c.createSplitterAvgSize),
+			SplitterMaxSize: int(c.createSplitterMaxSize),
 		},
 
 		RetentionMode:                     blob.RetentionMode(c.retentionMode),
 		RetentionPeriod:                   c.retentionPeriod,
 		FormatBlockKeyDerivationAlgorithm: c.createBlockKeyDerivationAlgorithm,
-	}
+		BlobLayout: &layout.Parameters{
+			Prefix: c.blobLayoutPrefix,
+			Shards: shards,
+		},
+		Passwordless: c.createPasswordless,
+	}, nil
 }
 
 func (c *commandRepositoryCreate) ensureEmpty(ctx context.Context, s blob.Storage) error {
@@ -119,9 +154,20 @@ func (c *commandRepositoryCreate) runCreateCommandWithStorage(ctx context.Contex
 		return errors.Wrap(err, "unable to get repository storage")
 	}
 
-	options := c.newRepositoryOptionsFromFlags()
+	options, err := c.newRepositoryOptionsFromFlags()
+	if err != nil {
+		return err
+	}
+
+	var pass string
+
+	if c.createPasswordless && c.co.connectKeyProvider == "" {
+		// the key is read from the key file, which is recorded in the connection configuration.
+		pass, err = c.svc.getPasswordlessKey(ctx, &c.co, true)
+	} else {
+		pass, err = c.svc.getConnectPassword(ctx, &c.co, true)
+	}
 
-	pass, err := c.svc.getPasswordFromFlags(ctx, true, false)
 	if err != nil {
 		return errors.Wrap(err, "getting password")
 	}
@@ -134,14 +180,33 @@ func (c *commandRepositoryCreate) runCreateCommandWithStorage(ctx context.Contex
 
 	log(ctx).Infof("  block hash:          %v", options.BlockFormat.Hash)
 	log(ctx).Infof("  encryption:          %v", options.BlockFormat.Encryption)
-	log(ctx).Infof("  key derivation:      %v", options.FormatBlockKeyDerivationAlgorithm)
+
+	if options.Passwordless {
+		log(ctx).Info("  key derivation:      key file (password-less)")
+	} else {
+		log(ctx).Infof("  key derivation:      %v", options.FormatBlockKeyDerivationAlgorithm)
+	}
 
 	if options.BlockFormat.ECC != "" && options.BlockFormat.ECCOverheadPercent > 0 {
 		log(ctx).Infof("  ecc:                 %v with %v%% overhead", options.BlockFormat.ECC, options.BlockFormat.ECCOverheadPercent)
 	}
 
+	if options.BlockFormat.ECC != "" && options.BlockFormat.ECCDataShards > 0 {
+		log(ctx).Infof("  ecc shards:          %v data, %v parity", options.BlockFormat.ECCDataShards, options.BlockFormat.ECCParityShards)
+	}
+
 	log(ctx).Infof("  splitter:            %v", options.ObjectFormat.Splitter)
 
+	if p := object.SplitterParameters(options.ObjectFormat); !p.IsEmpty() {
+		if p, err := splitter.ResolveParameters(options.ObjectFormat.Splitter, p); err == nil {
+			log(ctx).Infof("  splitter chunk size: min %v, avg %v, max %v", units.BytesString(p.MinSize), units.BytesString(p.AvgSize), units.BytesString(p.MaxSize))
+		}
+	}
+
+	if !options.BlobLayout.IsDefault() {
+		log(ctx).Infof("  blob layout:         %v", options.BlobLayout)
+	}
+
 	if err := repo.Initialize(ctx, st, options, pass); err != nil {
 		return errors.Wrap(err, "cannot initialize repository")
 	}
@@ -0,0 +1,49 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryCreatePasswordless(t *testing.T) {
+	t.Parallel()
+
+	keyFile := filepath.Join(testutil.TempDirectory(t), "repo.key")
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	delete(env.Environment, "KOPIA_PASSWORD")
+
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--passwordless")
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--passwordless", "--key-file", keyFile)
+
+	// the key is generated and readable only by the owner.
+	st, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), st.Mode().Perm())
+
+	// the connection reads the key from the key file without it being specified again.
+	env.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+	require.Contains(t, env.RunAndExpectSuccess(t, "repo", "status"), "Key derivation:      hkdf-sha256-keyfile (password-less)")
+
+	env.RunAndExpectFailure(t, "repo", "change-password", "--new-password", "newPass")
+
+	// password-less repositories don't prompt for passwords.
+	env2 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	delete(env2.Environment, "KOPIA_PASSWORD")
+	env2.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", env.RepoDir)
+
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--key-file", keyFile)
+	env2.RunAndExpectSuccess(t, "snapshot", "ls")
+
+	// an existing key file is used as-is.
+	env3 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	delete(env3.Environment, "KOPIA_PASSWORD")
+	env3.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env3.RepoDir, "--passwordless", "--key-file", keyFile)
+	env3.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--key-file", keyFile)
+}
@@ -61,3 +61,19 @@ func TestRepositoryCreateWithConfigFromStdin(t *testing.T) {
 
 	env.RunAndExpectSuccess(t, "repo", "create", "from-config", "--token-stdin")
 }
+
+func TestRepositoryCreateWithSplitterChunkSizes(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--object-splitter=FIXED-4M", "--object-splitter-avg-size=1MiB")
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--object-splitter=DYNAMIC-4M-FASTCDC", "--object-splitter-avg-size=1000KB")
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir,
+		"--object-splitter=DYNAMIC-4M-FASTCDC", "--object-splitter-avg-size=1MiB", "--object-splitter-max-size=3MiB")
+
+	out := strings.Join(env.RunAndExpectSuccess(t, "repo", "status"), "\n")
+	require.Contains(t, out, "Splitter:            DYNAMIC-4M-FASTCDC")
+	require.Contains(t, out, "Splitter chunk size: min 524.3 KB, avg 1 MB, max 3.1 MB")
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandRepositoryDedupReport struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryDedupReport) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("dedup-report", "Report contents unique to and shared between user@host pairs across all snapshots.")
+	c.jo.setup(svc, cmd)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryDedupReport) run(ctx context.Context, rep repo.Repository) error {
+	r, err := snapshotfs.CalculateDedupReport(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to calculate deduplication report")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(r))
+		return nil
+	}
+
+	c.out.printStdout("%-30v %9v %12v %12v %12v %7v\n", "OWNER", "SNAPSHOTS", "TOTAL", "UNIQUE", "SHARED", "SHARED%")
+
+	for _, o := range r.Owners {
+		c.out.printStdout("%-30v %9v %12v %12v %12v %6.1f%%\n",
+			o.UserName+"@"+o.Host,
+			o.SnapshotCount,
+			units.BytesString(o.Bytes),
+			units.BytesString(o.UniqueBytes),
+			units.BytesString(o.SharedBytes),
+			percentOf(o.SharedBytes, o.Bytes))
+	}
+
+	c.out.printStdout("\nRepository: %v in %v contents, %v in %v contents shared by multiple owners (%.1f%%).\n",
+		units.BytesString(r.Bytes),
+		units.Count(r.ContentCount),
+		units.BytesString(r.SharedBytes),
+		units.Count(r.SharedContentCount),
+		percentOf(r.SharedBytes, r.Bytes))
+
+	return nil
+}
+
+func percentOf(v, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return 100 * float64(v) / float64(total)
+}
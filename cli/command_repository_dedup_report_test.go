@@ -0,0 +1,53 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryDedupReport(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--override-username=user1", "--override-hostname=host1")
+
+	shared := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(shared, "shared"), []byte("contents shared between both hosts"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(shared, "unique"), []byte("contents unique to host1"), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", shared)
+
+	env.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--override-username=user2", "--override-hostname=host2")
+
+	other := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(other, "shared"), []byte("contents shared between both hosts"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "unique"), []byte("contents unique to host2"), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", other)
+
+	out := strings.Join(env.RunAndExpectSuccess(t, "repository", "dedup-report"), "\n")
+	require.Contains(t, out, "user1@host1")
+	require.Contains(t, out, "user2@host2")
+
+	var r snapshotfs.DedupReport
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "dedup-report", "--json"), &r)
+	require.Len(t, r.Owners, 2)
+	require.EqualValues(t, 1, r.SharedContentCount)
+
+	for _, o := range r.Owners {
+		require.Equal(t, 1, o.SnapshotCount)
+		// each snapshot has its own directory and unique file, but shares the other file.
+		require.EqualValues(t, 1, o.SharedContentCount)
+		require.EqualValues(t, 2, o.UniqueContentCount)
+		require.Equal(t, o.Bytes, o.UniqueBytes+o.SharedBytes)
+	}
+
+	require.Equal(t, r.Owners[0].Bytes+r.Owners[1].Bytes-r.SharedBytes, r.Bytes)
+}
@@ -30,5 +30,9 @@ func (c *commandRepositoryDisconnect) run(ctx context.Context) error {
 		return errors.Wrap(err, "unable to remove persisted password")
 	}
 
+	if err := repo.DeleteEncryptionDomainKeys(ctx, c.svc.passwordPersistenceStrategy(), c.svc.repositoryConfigFileName()); err != nil {
+		return errors.Wrap(err, "unable to remove persisted encryption domain keys")
+	}
+
 	return nil
 }
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/drbundle"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+)
+
+type commandRepositoryDRBundle struct {
+	create  commandRepositoryDRBundleCreate
+	restore commandRepositoryDRBundleRestore
+}
+
+func (c *commandRepositoryDRBundle) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("dr-bundle", "Commands to manage disaster-recovery bundles.")
+
+	c.create.setup(svc, cmd)
+	c.restore.setup(svc, cmd)
+}
+
+type commandRepositoryDRBundleCreate struct {
+	file string
+
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryDRBundleCreate) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("create", "Create a disaster-recovery bundle containing repository connection information and format backup, encrypted with the repository password.")
+	cmd.Flag("file", "Output file").Required().StringVar(&c.file)
+
+	c.svc = svc
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryDRBundleCreate) run(ctx context.Context, rep repo.DirectRepository) error {
+	pass, err := c.svc.getPasswordFromFlags(ctx, false, true)
+	if err != nil {
+		return errors.Wrap(err, "getting password")
+	}
+
+	b, err := drbundle.Create(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to create bundle")
+	}
+
+	f, err := os.OpenFile(c.file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return errors.Wrap(err, "unable to create bundle file")
+	}
+
+	if err := drbundle.Write(f, b, pass); err != nil {
+		f.Close()         //nolint:errcheck
+		os.Remove(c.file) //nolint:errcheck
+
+		return errors.Wrap(err, "unable to write bundle")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close bundle file")
+	}
+
+	log(ctx).Infof("Disaster-recovery bundle written to %v. Store it in a safe place, separately from the repository.", c.file)
+
+	return nil
+}
+
+type commandRepositoryDRBundleRestore struct {
+	file               string
+	restoreFormatBlobs bool
+
+	co  connectOptions
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryDRBundleRestore) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("restore", "Connect to the repository described by a disaster-recovery bundle.")
+	cmd.Flag("file", "Bundle file").Required().ExistingFileVar(&c.file)
+	cmd.Flag("restore-format-blobs", "Write backed-up format blobs to the storage before connecting").BoolVar(&c.restoreFormatBlobs)
+	c.co.setup(svc, cmd)
+
+	c.svc = svc
+	cmd.Action(svc.noRepositoryAction(c.run))
+}
+
+func (c *commandRepositoryDRBundleRestore) run(ctx context.Context) error {
+	pass, err := c.svc.getPasswordFromFlags(ctx, false, false)
+	if err != nil {
+		return errors.Wrap(err, "getting password")
+	}
+
+	f, err := os.Open(c.file)
+	if err != nil {
+		return errors.Wrap(err, "unable to open bundle file")
+	}
+	defer f.Close() //nolint:errcheck
+
+	b, err := drbundle.Read(f, pass)
+	if err != nil {
+		return errors.Wrap(err, "unable to read bundle")
+	}
+
+	st, err := blob.NewStorage(ctx, b.Storage, false)
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to storage")
+	}
+
+	defer st.Close(ctx) //nolint:errcheck
+
+	if c.restoreFormatBlobs {
+		if err := c.maybeRestoreFormatBlobs(ctx, st, b); err != nil {
+			return err
+		}
+	}
+
+	co := c.co
+	if co.connectHostname == "" {
+		co.connectHostname = b.ClientOptions.Hostname
+	}
+
+	if co.connectUsername == "" {
+		co.connectUsername = b.ClientOptions.Username
+	}
+
+	if co.connectDescription == "" {
+		co.connectDescription = b.ClientOptions.Description
+	}
+
+	return c.svc.runConnectCommandWithStorageAndPassword(ctx, &co, st, pass)
+}
+
+func (c *commandRepositoryDRBundleRestore) maybeRestoreFormatBlobs(ctx context.Context, st blob.Storage, b *drbundle.Bundle) error {
+	_, err := st.GetMetadata(ctx, format.KopiaRepositoryBlobID)
+	if err == nil {
+		log(ctx).Info("Format blob is present in the storage, not restoring.")
+		return nil
+	}
+
+	if !errors.Is(err, blob.ErrBlobNotFound) {
+		return errors.Wrap(err, "unable to check format blob")
+	}
+
+	log(ctx).Info("Restoring format blobs from the bundle...")
+
+	return errors.Wrap(b.RestoreFormatBlobs(ctx, st), "unable to restore format blobs")
+}
@@ -0,0 +1,58 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryDRBundle(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--override-hostname=dr-host", "--override-username=dr-user")
+
+	srcDir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file1.txt"), []byte("hello"), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", srcDir)
+
+	bundleFile := filepath.Join(testutil.TempDirectory(t), "repo.kopia-dr")
+
+	env.RunAndExpectSuccess(t, "repo", "dr-bundle", "create", "--file", bundleFile)
+
+	// refuse to overwrite existing bundle.
+	env.RunAndExpectFailure(t, "repo", "dr-bundle", "create", "--file", bundleFile)
+
+	// instructions are readable without the password, the connection info is not.
+	bundleData, err := os.ReadFile(bundleFile)
+	require.NoError(t, err)
+	require.Contains(t, string(bundleData), "dr-bundle restore")
+	require.NotContains(t, string(bundleData), env.RepoDir)
+
+	// simulate loss of the format blob and all its replicas.
+	for i := range format.FormatBlobReplicaCount {
+		env.RunAndExpectSuccess(t, "blob", "delete", string(format.ReplicaBlobID(format.KopiaRepositoryBlobID, i)))
+	}
+
+	require.NoError(t, os.Remove(filepath.Join(env.RepoDir, format.KopiaRepositoryBlobID+".f")))
+
+	env2 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	// wrong password.
+	env2.Environment["KOPIA_PASSWORD"] = "wrong-password"
+	env2.RunAndExpectFailure(t, "repo", "dr-bundle", "restore", "--file", bundleFile)
+
+	env2.Environment["KOPIA_PASSWORD"] = env.Environment["KOPIA_PASSWORD"]
+
+	// format blob is missing, connecting without restoring it fails.
+	env2.RunAndExpectFailure(t, "repo", "dr-bundle", "restore", "--file", bundleFile)
+	env2.RunAndExpectSuccess(t, "repo", "dr-bundle", "restore", "--file", bundleFile, "--restore-format-blobs")
+
+	lines := env2.RunAndExpectSuccess(t, "snapshot", "list", "--all")
+	require.Contains(t, lines[0], "dr-user@dr-host:"+srcDir)
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/repo/format"
+)
+
+type commandRepositoryEncryptionDomain struct {
+	list   commandRepositoryEncryptionDomainList
+	create commandRepositoryEncryptionDomainCreate
+	remove commandRepositoryEncryptionDomainRemove
+	unlock commandRepositoryEncryptionDomainUnlock
+	lock   commandRepositoryEncryptionDomainLock
+}
+
+func (c *commandRepositoryEncryptionDomain) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("encryption-domain", "Manage separate data encryption keys for users or sources sharing the repository.")
+
+	c.list.setup(svc, cmd)
+	c.create.setup(svc, cmd)
+	c.remove.setup(svc, cmd)
+	c.unlock.setup(svc, cmd)
+	c.lock.setup(svc, cmd)
+}
+
+type commandRepositoryEncryptionDomainList struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryEncryptionDomainList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List encryption domains.").Alias("ls")
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryEncryptionDomainList) run(ctx context.Context, rep repo.DirectRepository) error {
+	domains := rep.FormatManager().EncryptionDomains()
+	current := rep.ClientOptions().EncryptionDomain
+
+	// do not print key material.
+	for i := range domains {
+		domains[i].Salt = nil
+		domains[i].WrappedKey = nil
+		domains[i].KeyCheck = nil
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(domains))
+		return nil
+	}
+
+	for _, d := range domains {
+		suffix := ""
+		if d.Name == current {
+			suffix = " (current)"
+		}
+
+		c.out.printStdout("%v %v %q%v\n", d.Name, formatTimestamp(d.CreatedTime), d.Description, suffix)
+	}
+
+	return nil
+}
+
+type commandRepositoryEncryptionDomainCreate struct {
+	name        string
+	description string
+	newPassword string
+	algorithm   string
+
+	svc advancedAppServices
+	out textOutput
+}
+
+func (c *commandRepositoryEncryptionDomainCreate) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("create", "Create an encryption domain protected by a separate password.")
+	cmd.Arg("name", "Name of the encryption domain, such as user@host").Required().StringVar(&c.name)
+	cmd.Flag("description", "Description of the encryption domain").StringVar(&c.description)
+	cmd.Flag("new-password", "Password protecting the encryption domain key").Envar(svc.EnvName("KOPIA_NEW_PASSWORD")).StringVar(&c.newPassword)
+	cmd.Flag("key-derivation-algorithm", "Algorithm to derive the key from the password").Default(format.DefaultKeyDerivationAlgorithm).EnumVar(&c.algorithm, format.SupportedFormatBlobKeyDerivationAlgorithms()...)
+
+	c.svc = svc
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryEncryptionDomainCreate) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	pass := c.newPassword
+	if pass == "" {
+		p, err := askForChangedRepositoryPassword(c.svc.stdout())
+		if err != nil {
+			return err
+		}
+
+		pass = p
+	}
+
+	if _, err := rep.FormatManager().AddEncryptionDomain(ctx, c.name, c.description, pass, c.algorithm); err != nil {
+		return errors.Wrap(err, "unable to create encryption domain")
+	}
+
+	log(ctx).Infof("Created encryption domain %v.", c.name)
+
+	eventlog.RecordOrWarn(ctx, rep, eventlog.FormatChanged, "created encryption domain "+c.name, map[string]string{
+		"encryptionDomain": c.name,
+	})
+	log(ctx).Infof("To write contents in the domain, run 'kopia repository encryption-domain unlock %v' on its clients.", c.name)
+
+	return nil
+}
+
+type commandRepositoryEncryptionDomainRemove struct {
+	name    string
+	confirm bool
+}
+
+func (c *commandRepositoryEncryptionDomainRemove) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("remove", "Remove an encryption domain, making contents encrypted in it permanently unreadable.").Alias("rm")
+	cmd.Arg("name", "Name of the encryption domain").Required().StringVar(&c.name)
+	cmd.Flag("delete", "Confirm removal").BoolVar(&c.confirm)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryEncryptionDomainRemove) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if !c.confirm {
+		return errors.New("contents encrypted in the domain will become unreadable, pass --delete to confirm")
+	}
+
+	if err := rep.FormatManager().RemoveEncryptionDomain(ctx, c.name); err != nil {
+		return errors.Wrap(err, "unable to remove encryption domain")
+	}
+
+	log(ctx).Infof("Removed encryption domain %v.", c.name)
+
+	eventlog.RecordOrWarn(ctx, rep, eventlog.FormatChanged, "removed encryption domain "+c.name, map[string]string{
+		"encryptionDomain": c.name,
+	})
+
+	return nil
+}
+
+type commandRepositoryEncryptionDomainUnlock struct {
+	name     string
+	password string
+	readOnly bool
+
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryEncryptionDomainUnlock) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("unlock", "Store the key of an encryption domain in the connection configuration.")
+	cmd.Arg("name", "Name of the encryption domain").Required().StringVar(&c.name)
+	cmd.Flag("domain-password", "Password of the encryption domain").Envar(svc.EnvName("KOPIA_ENCRYPTION_DOMAIN_PASSWORD")).StringVar(&c.password)
+	cmd.Flag("read-only", "Only read contents of the domain, keep writing new contents using the current key").BoolVar(&c.readOnly)
+
+	c.svc = svc
+
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryEncryptionDomainUnlock) run(ctx context.Context, rep repo.DirectRepository) error {
+	pass := c.password
+	if pass == "" {
+		p, err := askPass(c.svc.stdout(), "Enter encryption domain password: ")
+		if err != nil {
+			return err
+		}
+
+		pass = p
+	}
+
+	//nolint:wrapcheck
+	return repo.UnlockEncryptionDomain(ctx, c.svc.repositoryConfigFileName(), c.svc.passwordPersistenceStrategy(), rep, c.name, pass, !c.readOnly)
+}
+
+type commandRepositoryEncryptionDomainLock struct {
+	name string
+
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryEncryptionDomainLock) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("lock", "Remove the key of an encryption domain from the connection configuration.")
+	cmd.Arg("name", "Name of the encryption domain").Required().StringVar(&c.name)
+
+	c.svc = svc
+
+	cmd.Action(svc.noRepositoryAction(c.run))
+}
+
+func (c *commandRepositoryEncryptionDomainLock) run(ctx context.Context) error {
+	//nolint:wrapcheck
+	return repo.LockEncryptionDomain(ctx, c.svc.repositoryConfigFileName(), c.svc.passwordPersistenceStrategy(), c.name)
+}
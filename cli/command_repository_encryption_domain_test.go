@@ -0,0 +1,119 @@
+package cli_test
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryEncryptionDomains(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--disable-repository-format-cache")
+	env.RunAndExpectSuccess(t, "repo", "encryption-domain", "create", "alice@host", "--new-password=alice-password", "--description=Alice")
+	env.RunAndExpectSuccess(t, "repo", "encryption-domain", "create", "bob@host", "--new-password=bob-password")
+	env.RunAndExpectFailure(t, "repo", "encryption-domain", "create", "bob@host", "--new-password=other-password")
+
+	var domains []format.EncryptionDomain
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "encryption-domain", "list", "--json"), &domains)
+	require.Len(t, domains, 2)
+	require.Equal(t, "alice@host", domains[0].Name)
+	require.Equal(t, "Alice", domains[0].Description)
+	require.Empty(t, domains[0].WrappedKey)
+
+	connectAs := func(name, password string) (*testenv.CLITest, string) {
+		e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+		e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--disable-repository-format-cache")
+		e.RunAndExpectFailure(t, "repo", "encryption-domain", "unlock", name, "--domain-password=wrong-password")
+		e.RunAndExpectSuccess(t, "repo", "encryption-domain", "unlock", name, "--domain-password="+password)
+
+		// domain keys are persisted like the repository password and never stored in the config file.
+		cfg, err := os.ReadFile(filepath.Join(e.ConfigDir, ".kopia.config"))
+		require.NoError(t, err)
+		require.NotContains(t, string(cfg), "encryptionDomainKeys")
+		require.FileExists(t, filepath.Join(e.ConfigDir, ".kopia.config.encryption-domains.kopia-password"))
+
+		src := testutil.TempDirectory(t)
+		require.NoError(t, os.WriteFile(filepath.Join(src, "file.txt"), []byte("secret of "+name), 0o600))
+
+		// large file is split into multiple contents referenced from an index content.
+		large := make([]byte, 20<<20)
+		_, err = rand.Read(large)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(src, "large.bin"), large, 0o600))
+
+		e.RunAndExpectSuccess(t, "snapshot", "create", src)
+
+		return e, src
+	}
+
+	aliceEnv, aliceSrc := connectAs("alice@host", "alice-password")
+	bobEnv, bobSrc := connectAs("bob@host", "bob-password")
+
+	require.Contains(t, aliceEnv.RunAndExpectSuccess(t, "repo", "encryption-domain", "list")[0], "(current)")
+
+	// snapshot manifests are visible to all clients, but contents are only readable in their domains.
+	env.RunAndExpectSuccess(t, "snapshot", "list", "--all")
+	aliceEnv.RunAndExpectSuccess(t, "snapshot", "restore", aliceSrc, testutil.TempDirectory(t))
+	aliceEnv.RunAndExpectFailure(t, "snapshot", "restore", bobSrc, testutil.TempDirectory(t))
+	bobEnv.RunAndExpectFailure(t, "snapshot", "restore", aliceSrc, testutil.TempDirectory(t))
+	env.RunAndExpectFailure(t, "snapshot", "restore", aliceSrc, testutil.TempDirectory(t))
+
+	// directory listings are encrypted in their domains as well.
+	var manifests []cli.SnapshotManifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", bobSrc, "--json"), &manifests)
+	require.Len(t, manifests, 1)
+
+	bobRoot := manifests[0].RootObjectID().String()
+	bobEnv.RunAndExpectSuccess(t, "ls", bobRoot)
+	aliceEnv.RunAndExpectFailure(t, "ls", bobRoot)
+	env.RunAndExpectFailure(t, "ls", bobRoot)
+
+	// maintenance and verification with locked domains keep all their contents.
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--force", "--safety=none")
+	env.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+	aliceEnv.RunAndExpectSuccess(t, "snapshot", "restore", aliceSrc, testutil.TempDirectory(t))
+	bobEnv.RunAndExpectSuccess(t, "snapshot", "restore", bobSrc, testutil.TempDirectory(t))
+
+	// the repository owner can unlock all domains to run maintenance.
+	env.RunAndExpectSuccess(t, "repo", "encryption-domain", "unlock", "alice@host", "--domain-password=alice-password", "--read-only")
+	env.RunAndExpectSuccess(t, "repo", "encryption-domain", "unlock", "bob@host", "--domain-password=bob-password", "--read-only")
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--force", "--safety=none")
+	env.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+
+	target := testutil.TempDirectory(t)
+	env.RunAndExpectSuccess(t, "snapshot", "restore", bobSrc, target)
+
+	b, err := os.ReadFile(filepath.Join(target, "file.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "secret of bob@host", string(b))
+
+	// contents rewritten by maintenance stay in their domains.
+	aliceEnv.RunAndExpectSuccess(t, "snapshot", "restore", aliceSrc, testutil.TempDirectory(t))
+	aliceEnv.RunAndExpectFailure(t, "snapshot", "restore", bobSrc, testutil.TempDirectory(t))
+
+	env.RunAndExpectSuccess(t, "repo", "encryption-domain", "lock", "bob@host")
+	env.RunAndExpectFailure(t, "repo", "encryption-domain", "lock", "bob@host")
+	env.RunAndExpectFailure(t, "snapshot", "restore", bobSrc, testutil.TempDirectory(t))
+
+	// disconnecting forgets all domain keys.
+	aliceEnv.RunAndExpectSuccess(t, "repo", "disconnect")
+	require.NoFileExists(t, filepath.Join(aliceEnv.ConfigDir, ".kopia.config.encryption-domains.kopia-password"))
+
+	env.RunAndExpectFailure(t, "repo", "encryption-domain", "remove", "bob@host")
+	env.RunAndExpectSuccess(t, "repo", "encryption-domain", "remove", "bob@host", "--delete")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "encryption-domain", "list", "--json"), &domains)
+	require.Len(t, domains, 1)
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
+)
+
+type commandRepositoryHistory struct {
+	since      time.Duration
+	types      []string
+	maxResults int
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryHistory) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("history", "Show the log of changes to the repository, such as snapshots, maintenance runs and format changes.")
+	cmd.Flag("since", "Only show events recorded during the provided duration, such as 168h").DurationVar(&c.since)
+	cmd.Flag("type", "Only show events of the provided types").EnumsVar(&c.types,
+		string(eventlog.SnapshotCreated),
+		string(eventlog.SnapshotDeleted),
+		string(eventlog.MaintenanceRun),
+		string(eventlog.FormatChanged),
+		string(eventlog.PasswordChanged))
+	cmd.Flag("max-results", "Maximum number of most recent events to show").Default("100").IntVar(&c.maxResults)
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandRepositoryHistory) run(ctx context.Context, rep repo.Repository) error {
+	opt := eventlog.ListOptions{
+		MaxResults: c.maxResults,
+	}
+
+	if c.since > 0 {
+		opt.Since = rep.Time().Add(-c.since)
+	}
+
+	for _, t := range c.types {
+		opt.Types = append(opt.Types, eventlog.Type(t))
+	}
+
+	events, err := eventlog.List(ctx, rep, opt)
+	if err != nil {
+		return errors.Wrap(err, "unable to list events")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(events))
+		return nil
+	}
+
+	for _, ev := range events {
+		desc := ev.Description
+		if e := ev.Details["error"]; e != "" {
+			desc += ": " + e
+		}
+
+		c.out.printStdout("%v %-16v %v %v\n", formatTimestamp(ev.Time), ev.Type, ev.User, desc)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryHistory(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	src := testutil.TempDirectory(t)
+
+	env.RunAndExpectSuccess(t, "snapshot", "create", src)
+
+	snapshots := mustListSnapshots(t, env)
+	require.Len(t, snapshots, 1)
+
+	env.RunAndExpectSuccess(t, "snapshot", "delete", string(snapshots[0].ID), "--delete")
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full")
+	env.RunAndExpectSuccess(t, "repo", "change-password", "--new-password=new-password")
+
+	env.Environment["KOPIA_PASSWORD"] = "new-password"
+
+	var events []*eventlog.Event
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "history", "--json"), &events)
+
+	var types []eventlog.Type
+
+	for _, ev := range events {
+		// maintenance runs automatically after snapshots, so only check its last run.
+		if ev.Type != eventlog.MaintenanceRun {
+			types = append(types, ev.Type)
+		}
+	}
+
+	require.Equal(t, []eventlog.Type{
+		eventlog.SnapshotCreated,
+		eventlog.SnapshotDeleted,
+		eventlog.PasswordChanged,
+	}, types)
+	require.Equal(t, eventlog.MaintenanceRun, events[len(events)-2].Type)
+	require.Equal(t, "full", events[len(events)-2].Details["mode"])
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "history", "--json", "--type=snapshot-deleted", "--since=1h"), &events)
+	require.Len(t, events, 1)
+	require.Equal(t, string(snapshots[0].ID), events[0].Details["snapshotID"])
+
+	require.Len(t, env.RunAndExpectSuccess(t, "repo", "history", "--max-results=2"), 2)
+}
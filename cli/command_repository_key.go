@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/repo/format"
+)
+
+type commandRepositoryKey struct {
+	list   commandRepositoryKeyList
+	add    commandRepositoryKeyAdd
+	revoke commandRepositoryKeyRevoke
+}
+
+func (c *commandRepositoryKey) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("key", "Manage additional passwords and key files that can unlock the repository.")
+
+	c.list.setup(svc, cmd)
+	c.add.setup(svc, cmd)
+	c.revoke.setup(svc, cmd)
+}
+
+type commandRepositoryKeyList struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryKeyList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List additional repository keys.").Alias("ls")
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryKeyList) run(ctx context.Context, rep repo.DirectRepository) error {
+	slots := rep.FormatManager().KeySlots()
+	current := rep.FormatManager().CurrentKeySlotID()
+
+	// do not print key material.
+	for i := range slots {
+		slots[i].Salt = nil
+		slots[i].WrappedKey = nil
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(slots))
+		return nil
+	}
+
+	for _, s := range slots {
+		suffix := ""
+		if s.ID == current {
+			suffix = " (current)"
+		}
+
+		c.out.printStdout("%v %v %v %q%v\n", s.ID, formatTimestamp(s.CreatedTime), s.KeyDerivationAlgorithm, s.Description, suffix)
+	}
+
+	return nil
+}
+
+type commandRepositoryKeyAdd struct {
+	description string
+	newPassword string
+	fromFile    string
+	algorithm   string
+
+	svc advancedAppServices
+	out textOutput
+}
+
+func (c *commandRepositoryKeyAdd) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("add", "Add a password or key file that can unlock the repository.")
+	cmd.Flag("description", "Description of the key").StringVar(&c.description)
+	cmd.Flag("new-password", "New password").Envar(svc.EnvName("KOPIA_NEW_PASSWORD")).StringVar(&c.newPassword)
+	cmd.Flag("from-file", "Use contents of the provided key file instead of a password").ExistingFileVar(&c.fromFile)
+	cmd.Flag("key-derivation-algorithm", "Algorithm to derive the key from the password").Default(format.DefaultKeyDerivationAlgorithm).EnumVar(&c.algorithm, format.SupportedFormatBlobKeyDerivationAlgorithms()...)
+
+	c.svc = svc
+	c.out.setup(svc)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryKeyAdd) getPassword() (string, error) {
+	switch {
+	case c.fromFile != "":
+		return readKeyFile(c.fromFile)
+	case c.newPassword != "":
+		return c.newPassword, nil
+	default:
+		return askForChangedRepositoryPassword(c.svc.stdout())
+	}
+}
+
+func (c *commandRepositoryKeyAdd) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	pass, err := c.getPassword()
+	if err != nil {
+		return err
+	}
+
+	ks, err := rep.FormatManager().AddKeySlot(ctx, c.description, pass, c.algorithm)
+	if err != nil {
+		return errors.Wrap(err, "unable to add key")
+	}
+
+	log(ctx).Infof("Added key %v.", ks.ID)
+
+	eventlog.RecordOrWarn(ctx, rep, eventlog.PasswordChanged, "added repository key "+ks.ID, map[string]string{
+		"keyID":       ks.ID,
+		"description": ks.Description,
+	})
+	c.out.printStdout("%v\n", ks.ID)
+
+	return nil
+}
+
+type commandRepositoryKeyRevoke struct {
+	id string
+}
+
+func (c *commandRepositoryKeyRevoke) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("revoke", "Revoke an additional repository key.").Alias("rm")
+	cmd.Arg("id", "ID of the key to revoke").Required().StringVar(&c.id)
+
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryKeyRevoke) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if err := rep.FormatManager().RevokeKeySlot(ctx, c.id); err != nil {
+		return errors.Wrap(err, "unable to revoke key")
+	}
+
+	log(ctx).Infof("Revoked key %v.", c.id)
+
+	eventlog.RecordOrWarn(ctx, rep, eventlog.PasswordChanged, "revoked repository key "+c.id, map[string]string{
+		"keyID": c.id,
+	})
+	log(ctx).Info("NOTE: Clients already connected using this key remain connected until they disconnect.")
+
+	return nil
+}
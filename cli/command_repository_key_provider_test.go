@@ -0,0 +1,42 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryKeyProvider(t *testing.T) {
+	t.Parallel()
+
+	passFile := filepath.Join(testutil.TempDirectory(t), "pass")
+	require.NoError(t, os.WriteFile(passFile, []byte(testenv.TestRepoPassword+"\n"), 0o600))
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	delete(env.Environment, "KOPIA_PASSWORD")
+
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--key-provider", "no-such-provider:foo")
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--key-provider", "file:"+passFile)
+	env.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+	require.Contains(t, env.RunAndExpectSuccess(t, "repo", "status"), "Key provider:        file")
+
+	env.RunAndExpectSuccess(t, "repo", "disconnect")
+	env.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--key-provider", "file:"+passFile)
+	env.RunAndExpectSuccess(t, "snapshot", "ls")
+
+	// without the key provider there's no way to get the password.
+	env.RunAndExpectSuccess(t, "repo", "set-client", "--clear-key-provider")
+	env.RunAndExpectFailure(t, "snapshot", "ls")
+
+	// connect using regular password and switch to key provider.
+	env.RunAndExpectSuccess(t, "repo", "disconnect")
+	env.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--password", testenv.TestRepoPassword)
+	env.RunAndExpectFailure(t, "repo", "set-client", "--key-provider", "file:"+filepath.Join(testutil.TempDirectory(t), "missing"))
+	env.RunAndExpectSuccess(t, "repo", "set-client", "--key-provider", "file:"+passFile)
+	env.RunAndExpectSuccess(t, "snapshot", "ls")
+}
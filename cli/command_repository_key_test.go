@@ -0,0 +1,68 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryKeys(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--disable-repository-format-cache")
+	env.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+
+	keyFile := filepath.Join(testutil.TempDirectory(t), "repo.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("some-random-key-material"), 0o600))
+
+	passwordKeyID := env.RunAndExpectSuccess(t, "repo", "key", "add", "--description=automation", "--new-password=automation-password")[0]
+	keyFileKeyID := env.RunAndExpectSuccess(t, "repo", "key", "add", "--description=keyfile", "--from-file", keyFile)[0]
+
+	env.RunAndExpectFailure(t, "repo", "key", "add", "--new-password=automation-password")
+
+	var slots []format.KeySlot
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "key", "list", "--json"), &slots)
+	require.Len(t, slots, 2)
+	require.Equal(t, passwordKeyID, slots[0].ID)
+	require.Equal(t, "automation", slots[0].Description)
+	require.Empty(t, slots[0].WrappedKey)
+	require.Equal(t, keyFileKeyID, slots[1].ID)
+
+	// password changes are blocked while there are additional keys.
+	env.RunAndExpectFailure(t, "repo", "change-password", "--new-password", "newPass")
+
+	// connect using the additional password.
+	env2 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env2.Environment["KOPIA_PASSWORD"] = "automation-password"
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--disable-repository-format-cache")
+	env2.RunAndExpectSuccess(t, "snapshot", "ls")
+	require.Contains(t, env2.RunAndExpectSuccess(t, "repo", "key", "list")[0], "(current)")
+
+	// the key used by the current connection can't be revoked.
+	env2.RunAndExpectFailure(t, "repo", "key", "revoke", passwordKeyID)
+
+	// connect using the key file.
+	env3 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	delete(env3.Environment, "KOPIA_PASSWORD")
+	env3.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--key-file", keyFile, "--disable-repository-format-cache")
+	env3.RunAndExpectSuccess(t, "snapshot", "ls")
+
+	// revoke the key file, new connections using it fail.
+	env.RunAndExpectSuccess(t, "repo", "key", "revoke", keyFileKeyID)
+	env.RunAndExpectFailure(t, "repo", "key", "revoke", keyFileKeyID)
+
+	env4 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	delete(env4.Environment, "KOPIA_PASSWORD")
+	env4.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--key-file", keyFile, "--disable-repository-format-cache")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "key", "list", "--json"), &slots)
+	require.Len(t, slots, 1)
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/replication"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type commandRepositoryReplicate struct {
+	to              string
+	continuous      bool
+	interval        time.Duration
+	parallel        int
+	deleteBlobs     bool
+	verifyChecksums bool
+	stateFile       string
+
+	jo  jsonOutput
+	out textOutput
+	svc appServices
+}
+
+func (c *commandRepositoryReplicate) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("replicate", "Incrementally replicate repository blobs to secondary storage")
+	cmd.Flag("to", "Path to configuration file of the destination repository or JSON file with its storage configuration").Required().ExistingFileVar(&c.to)
+	cmd.Flag("continuous", "Keep replicating new blobs until interrupted").BoolVar(&c.continuous)
+	cmd.Flag("interval", "Interval between replication cycles in continuous mode").Default(replication.DefaultInterval.String()).DurationVar(&c.interval)
+	cmd.Flag("parallel", "Number of blobs to copy in parallel").Default("4").IntVar(&c.parallel)
+	cmd.Flag("delete", "Delete blobs removed from this repository from the destination").BoolVar(&c.deleteBlobs)
+	cmd.Flag("verify-checksums", "Read back each copied blob and compare its checksum").BoolVar(&c.verifyChecksums)
+	cmd.Flag("state-file", "Path to JSON file storing the replication state (defaults to a file next to the config file)").StringVar(&c.stateFile)
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	c.svc = svc
+
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryReplicate) run(ctx context.Context, rep repo.DirectRepository) error {
+	ci, err := loadReplicationDestination(c.to)
+	if err != nil {
+		return err
+	}
+
+	dst, err := blob.NewStorage(ctx, *ci, false)
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to destination storage")
+	}
+
+	defer dst.Close(ctx) //nolint:errcheck
+
+	stateFile := c.stateFile
+	if stateFile == "" {
+		stateFile = c.svc.repositoryConfigFileName() + ".replication"
+	}
+
+	r, err := replication.New(rep.BlobReader(), dst, replication.Options{
+		Parallel:        c.parallel,
+		Delete:          c.deleteBlobs,
+		VerifyChecksums: c.verifyChecksums,
+		StateFile:       stateFile,
+		Interval:        c.interval,
+		OnCycle: func(s replication.Status) {
+			log(ctx).Infof("Replicated %v blobs (%v) to %v, lag %v.",
+				s.LastCycleCopiedBlobs, units.BytesString(s.LastCycleCopiedBytes), s.Destination, s.Lag.Truncate(time.Second))
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize replication")
+	}
+
+	if c.continuous {
+		log(ctx).Infof("Replicating to %v every %v...", dst.DisplayName(), c.interval)
+
+		err = r.Run(ctx)
+		if ctx.Err() != nil {
+			err = nil
+		}
+	} else {
+		err = r.RunCycle(ctx)
+	}
+
+	s := r.Status()
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(s))
+	} else {
+		c.out.printStdout("Destination:      %v\n", s.Destination)
+		c.out.printStdout("Replicated:       %v blobs (%v)\n", s.ReplicatedBlobs, units.BytesString(s.ReplicatedBytes))
+		c.out.printStdout("Last cycle:       copied %v blobs (%v), deleted %v blobs\n", s.LastCycleCopiedBlobs, units.BytesString(s.LastCycleCopiedBytes), s.LastCycleDeletedBlobs)
+
+		if !s.ConsistentAsOf.IsZero() {
+			c.out.printStdout("Consistent as of: %v\n", formatTimestamp(s.ConsistentAsOf))
+		}
+	}
+
+	return errors.Wrap(err, "replication failed")
+}
+
+// loadReplicationDestination loads storage configuration from a repository configuration file or a JSON file
+// containing storage configuration.
+func loadReplicationDestination(fname string) (*blob.ConnectionInfo, error) {
+	lc, err := repo.LoadConfigFromFile(fname)
+	if err == nil && lc.Storage != nil {
+		return lc.Storage, nil
+	}
+
+	b, err := os.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read destination configuration")
+	}
+
+	var ci blob.ConnectionInfo
+
+	if err := json.Unmarshal(b, &ci); err != nil || ci.Type == "" {
+		return nil, errors.Errorf("%v does not contain storage configuration", fname)
+	}
+
+	return &ci, nil
+}
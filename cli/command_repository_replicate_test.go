@@ -0,0 +1,81 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/internal/replication"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryReplicate(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+
+	dstDir := testutil.TempDirectory(t)
+	dstConfig := filepath.Join(testutil.TempDirectory(t), "dst.json")
+	cfg, err := json.Marshal(map[string]any{"type": "filesystem", "config": map[string]string{"path": dstDir}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dstConfig, cfg, 0o600))
+
+	var s replication.Status
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "replicate", "--to", dstConfig, "--json"), &s)
+	require.Positive(t, s.LastCycleCopiedBlobs)
+	require.Equal(t, s.LastCycleCopiedBlobs, s.ReplicatedBlobs)
+	require.False(t, s.ConsistentAsOf.IsZero())
+
+	// nothing changed, nothing to copy.
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "replicate", "--to", dstConfig, "--json", "--verify-checksums"), &s)
+	require.Zero(t, s.LastCycleCopiedBlobs)
+
+	// replica can be connected to directly.
+	env2 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env2.Environment["KOPIA_PASSWORD"] = env.Environment["KOPIA_PASSWORD"]
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", dstDir)
+	require.Len(t, mustListSnapshots(t, env2), 1)
+}
+
+func TestRepositoryReadMirrors(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+
+	mirrorDir := testutil.TempDirectory(t)
+	mirrorConfig := filepath.Join(testutil.TempDirectory(t), "mirror.json")
+	cfg, err := json.Marshal(map[string]any{"type": "filesystem", "config": map[string]string{"path": mirrorDir}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(mirrorConfig, cfg, 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "replicate", "--to", mirrorConfig)
+	env.RunAndExpectSuccess(t, "repo", "set-client", "--add-read-mirror", mirrorConfig)
+
+	var rs cli.RepositoryStatus
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "status", "--json"), &rs)
+	require.Len(t, rs.StorageEndpoints, 2)
+	require.True(t, rs.StorageEndpoints[0].Primary)
+	require.True(t, rs.StorageEndpoints[0].Healthy)
+	require.False(t, rs.StorageEndpoints[1].Primary)
+	require.True(t, rs.StorageEndpoints[1].Healthy)
+
+	require.Len(t, mustListSnapshots(t, env), 1)
+
+	env.RunAndExpectSuccess(t, "repo", "set-client", "--clear-read-mirrors")
+
+	var rs2 cli.RepositoryStatus
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repo", "status", "--json"), &rs2)
+	require.Empty(t, rs2.StorageEndpoints)
+}
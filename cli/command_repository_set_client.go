@@ -6,34 +6,49 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/keyprovider"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 )
 
 type commandRepositorySetClient struct {
-	repoClientOptionsReadOnly               bool
-	repoClientOptionsReadWrite              bool
-	repoClientOptionsPermissiveCacheLoading bool
-	repoClientOptionsDescription            []string
-	repoClientOptionsUsername               []string
-	repoClientOptionsHostname               []string
+	repoClientOptionsReadOnly                bool
+	repoClientOptionsReadWrite               bool
+	repoClientOptionsPermissiveCacheLoading  bool
+	repoClientOptionsEnableReadVerification  bool
+	repoClientOptionsDisableReadVerification bool
+	repoClientOptionsDescription             []string
+	repoClientOptionsUsername                []string
+	repoClientOptionsHostname                []string
+	repoClientOptionsKeyProvider             string
+	repoClientOptionsClearKeyProvider        bool
 
 	formatBlobCacheDuration time.Duration
 	disableFormatBlobCache  bool
 
-	svc appServices
+	addReadMirrors   []string
+	clearReadMirrors bool
+
+	svc advancedAppServices
 }
 
-func (c *commandRepositorySetClient) setup(svc appServices, parent commandParent) {
+func (c *commandRepositorySetClient) setup(svc advancedAppServices, parent commandParent) {
 	cmd := parent.Command("set-client", "Set repository client options.")
 
 	cmd.Flag("read-only", "Set repository to read-only").BoolVar(&c.repoClientOptionsReadOnly)
 	cmd.Flag("read-write", "Set repository to read-write").BoolVar(&c.repoClientOptionsReadWrite)
 	cmd.Flag("permissive-cache-loading", "Do not fail when loading bad cache index entries.  Repository must be opened in read-only mode").Hidden().BoolVar(&c.repoClientOptionsPermissiveCacheLoading)
+	cmd.Flag("enable-read-verification", "Verify hashes of all contents read from the repository").BoolVar(&c.repoClientOptionsEnableReadVerification)
+	cmd.Flag("disable-read-verification", "Do not verify hashes of contents read from the repository, unless required by the repository").BoolVar(&c.repoClientOptionsDisableReadVerification)
 	cmd.Flag("description", "Change description").StringsVar(&c.repoClientOptionsDescription)
 	cmd.Flag("username", "Change username").StringsVar(&c.repoClientOptionsUsername)
 	cmd.Flag("hostname", "Change hostname").StringsVar(&c.repoClientOptionsHostname)
+	cmd.Flag("key-provider", "Retrieve repository password from external key storage: "+keyprovider.SpecHelp()).PlaceHolder("TYPE:PARAMS").StringVar(&c.repoClientOptionsKeyProvider)
+	cmd.Flag("clear-key-provider", "Stop retrieving repository password from external key storage").BoolVar(&c.repoClientOptionsClearKeyProvider)
 	cmd.Flag("repository-format-cache-duration", "Duration of kopia.repository format blob cache").DurationVar(&c.formatBlobCacheDuration)
 	cmd.Flag("disable-repository-format-cache", "Disable caching of kopia.repository format blob").BoolVar(&c.disableFormatBlobCache)
+	cmd.Flag("add-read-mirror", "Read from storage described by the repository configuration file or JSON storage configuration when primary storage is unavailable").ExistingFilesVar(&c.addReadMirrors)
+	cmd.Flag("clear-read-mirrors", "Remove all read mirrors").BoolVar(&c.clearReadMirrors)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 
 	c.svc = svc
@@ -77,6 +92,28 @@ func (c *commandRepositorySetClient) run(ctx context.Context, rep repo.Repositor
 		}
 	}
 
+	if c.repoClientOptionsEnableReadVerification {
+		if opt.VerifyContentHashOnRead {
+			log(ctx).Info("Content read verification is already enabled.")
+		} else {
+			opt.VerifyContentHashOnRead = true
+			anyChange = true
+
+			log(ctx).Info("Enabling content read verification.")
+		}
+	}
+
+	if c.repoClientOptionsDisableReadVerification {
+		if !opt.VerifyContentHashOnRead {
+			log(ctx).Info("Content read verification is already disabled.")
+		} else {
+			opt.VerifyContentHashOnRead = false
+			anyChange = true
+
+			log(ctx).Info("Disabling content read verification.")
+		}
+	}
+
 	if v := c.repoClientOptionsDescription; len(v) > 0 {
 		opt.Description = v[0]
 		anyChange = true
@@ -112,10 +149,93 @@ func (c *commandRepositorySetClient) run(ctx context.Context, rep repo.Repositor
 		log(ctx).Info("Disabling format blob cache")
 	}
 
-	if !anyChange {
+	if v := c.repoClientOptionsKeyProvider; v != "" {
+		kp, err := keyprovider.ParseSpec(v)
+		if err != nil {
+			return errors.Wrap(err, "invalid --key-provider")
+		}
+
+		// make sure the key provider works before saving it.
+		if _, err := keyprovider.GetPassword(ctx, kp); err != nil {
+			return errors.Wrap(err, "unable to get password from key provider")
+		}
+
+		opt.KeyProvider = kp
+		anyChange = true
+
+		log(ctx).Infof("Setting key provider to %v", kp.Type)
+
+		// the password no longer needs to be persisted.
+		if err := c.svc.passwordPersistenceStrategy().DeletePassword(ctx, c.svc.repositoryConfigFileName()); err != nil {
+			return errors.Wrap(err, "unable to delete persisted password")
+		}
+	}
+
+	if c.repoClientOptionsClearKeyProvider {
+		if opt.KeyProvider == nil {
+			log(ctx).Info("Key provider is not configured.")
+		} else {
+			opt.KeyProvider = nil
+			anyChange = true
+
+			log(ctx).Info("Clearing key provider. Repository password will need to be provided using other means.")
+		}
+	}
+
+	mirrorsChanged, err := c.updateReadMirrors(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !anyChange && !mirrorsChanged {
 		return errors.New("no changes")
 	}
 
+	if !anyChange {
+		return nil
+	}
+
 	//nolint:wrapcheck
 	return repo.SetClientOptions(ctx, c.svc.repositoryConfigFileName(), opt)
 }
+
+func (c *commandRepositorySetClient) updateReadMirrors(ctx context.Context) (bool, error) {
+	if !c.clearReadMirrors && len(c.addReadMirrors) == 0 {
+		return false, nil
+	}
+
+	lc, err := repo.LoadConfigFromFile(c.svc.repositoryConfigFileName())
+	if err != nil {
+		return false, errors.Wrap(err, "unable to load configuration")
+	}
+
+	mirrors := lc.ReadMirrors
+
+	if c.clearReadMirrors {
+		log(ctx).Info("Clearing read mirrors.")
+
+		mirrors = nil
+	}
+
+	for _, fname := range c.addReadMirrors {
+		ci, err := loadReplicationDestination(fname)
+		if err != nil {
+			return false, err
+		}
+
+		// make sure the mirror is reachable before saving it.
+		st, err := blob.NewStorage(ctx, *ci, false)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to connect to read mirror %v", fname)
+		}
+
+		log(ctx).Infof("Adding read mirror %v.", st.DisplayName())
+
+		st.Close(ctx) //nolint:errcheck
+
+		mirrors = append(mirrors, *ci)
+	}
+
+	//nolint:wrapcheck
+	return true, repo.SetReadMirrors(ctx, c.svc.repositoryConfigFileName(), mirrors)
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandRepositorySetImmutability struct {
+	retentionMode   string
+	retentionPeriod time.Duration
+	extendLocks     []bool
+	legalHold       []bool
+
+	out textOutput
+}
+
+func (c *commandRepositorySetImmutability) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set-immutability", "Configure object lock retention of repository blobs and legal hold of snapshots.")
+	cmd.Flag("retention-mode", "Object lock retention mode of blobs written to the storage").EnumVar(&c.retentionMode, "none", blob.Governance.String(), blob.Compliance.String())
+	cmd.Flag("retention-period", "Object lock retention period of blobs written to the storage").DurationVar(&c.retentionPeriod)
+	cmd.Flag("extend-locks", "Extend retention period of locked blobs as part of full maintenance").BoolListVar(&c.extendLocks)
+	cmd.Flag("legal-hold", "Prevent deletion of snapshots, including by retention policies, until the object locks protecting their data expire").BoolListVar(&c.legalHold)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+
+	c.out.setup(svc)
+}
+
+func (c *commandRepositorySetImmutability) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	mp, err := rep.FormatManager().GetMutableParameters(ctx)
+	if err != nil {
+		return errors.Wrap(err, "mutable parameters")
+	}
+
+	blobcfg, err := rep.FormatManager().BlobCfgBlob(ctx)
+	if err != nil {
+		return errors.Wrap(err, "blob configuration")
+	}
+
+	requiredFeatures, err := rep.FormatManager().RequiredFeatures(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get required features")
+	}
+
+	p, err := maintenance.GetParams(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get current maintenance parameters")
+	}
+
+	blobcfgChanged := false
+	paramsChanged := false
+
+	if c.retentionMode == "none" {
+		if blobcfg.IsRetentionEnabled() {
+			disableBlobRetention(ctx, &blobcfg, &blobcfgChanged)
+		}
+	} else {
+		setRetentionModeParameter(ctx, blob.RetentionMode(c.retentionMode), "storage backend blob retention mode", &blobcfg.RetentionMode, &blobcfgChanged)
+		setDurationParameter(ctx, c.retentionPeriod, "storage backend blob retention period", &blobcfg.RetentionPeriod, &blobcfgChanged)
+	}
+
+	// zero elements == not set, otherwise the last value wins.
+	if len(c.extendLocks) > 0 {
+		setBoolParameter(ctx, c.extendLocks[len(c.extendLocks)-1], "object lock extension during maintenance", &p.ExtendObjectLocks, &paramsChanged)
+	}
+
+	if len(c.legalHold) > 0 {
+		setBoolParameter(ctx, c.legalHold[len(c.legalHold)-1], "snapshot legal hold", &p.SnapshotLegalHold, &paramsChanged)
+	}
+
+	if blobcfgChanged {
+		if err := blobcfg.Validate(); err != nil {
+			return errors.Wrap(err, "invalid retention settings")
+		}
+	}
+
+	if p.SnapshotLegalHold && !blobcfg.IsRetentionEnabled() {
+		return errors.New("snapshot legal hold requires object lock retention, use --retention-mode and --retention-period")
+	}
+
+	if err := maintenance.CheckExtendRetention(ctx, blobcfg, p); err != nil {
+		return errors.Wrap(err, "unable to apply immutability changes")
+	}
+
+	if blobcfgChanged {
+		if err := updateRepositoryParameters(ctx, false, mp, rep, blobcfg, requiredFeatures); err != nil {
+			return errors.Wrap(err, "error updating repository parameters")
+		}
+	}
+
+	if paramsChanged {
+		if err := maintenance.SetParams(ctx, rep, p); err != nil {
+			return errors.Wrap(err, "unable to set maintenance parameters")
+		}
+	}
+
+	if blobcfg.IsRetentionEnabled() {
+		c.out.printStdout("Object lock:          %v for %v\n", blobcfg.RetentionMode, blobcfg.RetentionPeriod)
+	} else {
+		c.out.printStdout("Object lock:          disabled\n")
+	}
+
+	c.out.printStdout("Lock extension:       %v\n", enabledOrDisabled(p.ExtendObjectLocks || p.SnapshotLegalHold))
+	c.out.printStdout("Snapshot legal hold:  %v\n", enabledOrDisabled(p.SnapshotLegalHold))
+
+	if blobcfgChanged {
+		log(ctx).Info("NOTE: Repository parameters updated, you must disconnect and re-connect all other Kopia clients.")
+	}
+
+	return nil
+}
+
+func enabledOrDisabled(v bool) string {
+	if v {
+		return "enabled"
+	}
+
+	return "disabled"
+}
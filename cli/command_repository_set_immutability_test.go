@@ -0,0 +1,27 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositorySetImmutability(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	require.Contains(t, env.RunAndExpectSuccess(t, "repo", "set-immutability"), "Object lock:          disabled")
+
+	// legal hold requires object locks.
+	env.RunAndExpectFailure(t, "repo", "set-immutability", "--legal-hold=true")
+
+	out := env.RunAndExpectSuccess(t, "repo", "set-immutability", "--extend-locks=true")
+	require.Contains(t, out, "Lock extension:       enabled")
+	require.Contains(t, out, "Snapshot legal hold:  disabled")
+
+	require.Contains(t, env.RunAndExpectSuccess(t, "maintenance", "info"), "Object Lock Extension: enabled")
+}
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
@@ -11,6 +12,7 @@
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/eventlog"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/maintenance"
 )
@@ -31,6 +33,9 @@ type commandRepositorySetParameters struct {
 
 	upgradeRepositoryFormat bool
 
+	enableReadVerification  bool
+	disableReadVerification bool
+
 	addRequiredFeature           string
 	removeRequiredFeature        string
 	warnOnMissingRequiredFeature bool
@@ -48,6 +53,9 @@ func (c *commandRepositorySetParameters) setup(svc appServices, parent commandPa
 
 	cmd.Flag("upgrade", "Upgrade repository to the latest stable format").BoolVar(&c.upgradeRepositoryFormat)
 
+	cmd.Flag("enable-read-verification", "Require all clients to verify hashes of contents read from the repository").BoolVar(&c.enableReadVerification)
+	cmd.Flag("disable-read-verification", "Stop requiring clients to verify hashes of contents read from the repository").BoolVar(&c.disableReadVerification)
+
 	cmd.Flag("epoch-refresh-frequency", "Epoch refresh frequency").DurationVar(&c.epochRefreshFrequency)
 	cmd.Flag("epoch-min-duration", "Minimal duration of a single epoch").DurationVar(&c.epochMinDuration)
 	cmd.Flag("epoch-cleanup-safety-margin", "Epoch cleanup safety margin").DurationVar(&c.epochCleanupSafetyMargin)
@@ -89,6 +97,17 @@ func setIntParameter(ctx context.Context, v int, desc string, dst *int, anyChang
 	log(ctx).Infof(" - setting %v to %v.\n", desc, v)
 }
 
+func setBoolParameter(ctx context.Context, v bool, desc string, dst *bool, anyChange *bool) {
+	if *dst == v {
+		return
+	}
+
+	*dst = v
+	*anyChange = true
+
+	log(ctx).Infof(" - setting %v to %v.\n", desc, v)
+}
+
 func setDurationParameter(ctx context.Context, v time.Duration, desc string, dst *time.Duration, anyChange *bool) {
 	if v == 0 {
 		return
@@ -127,6 +146,18 @@ func updateRepositoryParameters(
 		}
 	}
 
+	// the event is recorded before the change, since the new parameters may require features
+	// not supported by this client, which would prevent writing it afterwards.
+	eventlog.RecordOrWarn(ctx, rep, eventlog.FormatChanged, "changed repository parameters", map[string]string{
+		"version":     fmt.Sprint(mp.Version),
+		"maxPackSize": fmt.Sprint(mp.MaxPackSize),
+		"indexFormat": fmt.Sprint(mp.IndexVersion),
+	})
+
+	if err := rep.Flush(ctx); err != nil {
+		return errors.Wrap(err, "error flushing repository")
+	}
+
 	if err := rep.FormatManager().SetParameters(ctx, mp, blobcfg, requiredFeatures); err != nil {
 		return errors.Wrap(err, "error setting parameters")
 	}
@@ -214,6 +245,14 @@ func (c *commandRepositorySetParameters) run(ctx context.Context, rep repo.Direc
 	setIntParameter(ctx, c.epochDeleteParallelism, "epoch delete parallelism", &mp.EpochParameters.DeleteParallelism, &anyChange)
 	setIntParameter(ctx, c.epochCheckpointFrequency, "epoch checkpoint frequency", &mp.EpochParameters.FullCheckpointFrequency, &anyChange)
 
+	if c.enableReadVerification || c.disableReadVerification {
+		if c.enableReadVerification == c.disableReadVerification {
+			return errors.New("cannot both enable and disable read verification")
+		}
+
+		setBoolParameter(ctx, c.enableReadVerification, "content hash verification on read", &mp.VerifyContentHashOnRead, &anyChange)
+	}
+
 	requiredFeatures = c.addRemoveUpdateRequiredFeatures(requiredFeatures, &anyChange)
 
 	if !anyChange {
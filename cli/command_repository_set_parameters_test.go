@@ -52,6 +52,32 @@ func (s *formatSpecificTestSuite) TestRepositorySetParameters(t *testing.T) {
 	require.Contains(t, out, "Max pack length:     46.1 MB")
 }
 
+func (s *formatSpecificTestSuite) TestRepositorySetParametersReadVerification(t *testing.T) {
+	env := s.setupInMemoryRepo(t)
+
+	out := env.RunAndExpectSuccess(t, "repository", "status")
+	require.Contains(t, out, "Read Verification:   false")
+
+	env.RunAndExpectFailure(t, "repository", "set-parameters", "--enable-read-verification", "--disable-read-verification")
+
+	env.RunAndExpectSuccess(t, "repository", "set-parameters", "--enable-read-verification")
+	out = env.RunAndExpectSuccess(t, "repository", "status")
+	require.Contains(t, out, "Read Verification:   true")
+
+	// reads succeed with verification enabled.
+	env.RunAndExpectSuccess(t, "snapshot", "create", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "verify")
+
+	env.RunAndExpectSuccess(t, "repository", "set-parameters", "--disable-read-verification")
+	out = env.RunAndExpectSuccess(t, "repository", "status")
+	require.Contains(t, out, "Read Verification:   false")
+
+	// per-connection setting.
+	env.RunAndExpectSuccess(t, "repository", "set-client", "--enable-read-verification")
+	env.RunAndExpectSuccess(t, "snapshot", "verify")
+	env.RunAndExpectSuccess(t, "repository", "set-client", "--disable-read-verification")
+}
+
 func (s *formatSpecificTestSuite) TestRepositorySetParametersRetention(t *testing.T) {
 	env := s.setupInMemoryRepo(t)
 
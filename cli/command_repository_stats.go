@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandRepositoryStats struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryStats) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("stats", "Display repository statistics computed during the most recent full maintenance.")
+	c.jo.setup(svc, cmd)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryStats) run(ctx context.Context, rep repo.DirectRepository) error {
+	st, err := maintenance.GetRepositoryStats(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get repository statistics")
+	}
+
+	if st == nil {
+		return errors.New("repository statistics are not available yet, run 'kopia maintenance run --full' to compute them")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(st))
+		return nil
+	}
+
+	c.out.printStdout("Updated:             %v\n", formatTimestamp(st.UpdateTime))
+	c.out.printStdout("Contents:            %v\n", formatContentStats(st.Contents))
+
+	var names []compression.Name
+
+	for n := range st.ByCompression {
+		names = append(names, n)
+	}
+
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	c.out.printStdout("By compression:\n")
+
+	for _, n := range names {
+		cs := st.ByCompression[n]
+
+		label := string(n)
+		if label == "" {
+			label = "(none)"
+		}
+
+		ratio := 0.0
+		if cs.OriginalBytes > 0 {
+			ratio = 100 * float64(cs.PackedBytes) / float64(cs.OriginalBytes)
+		}
+
+		c.out.printStdout("  %-18v %v, stored %.1f%% of original\n", label, formatContentStats(*cs), ratio)
+	}
+
+	c.out.printStdout("By content age:\n")
+
+	for _, b := range st.ContentAge {
+		label := "older"
+		if b.MaxAge != 0 {
+			label = "< " + b.MaxAge.String()
+		}
+
+		c.out.printStdout("  %-18v %v\n", label, formatContentStats(b.ContentStats))
+	}
+
+	if len(st.Sources) == 0 {
+		return nil
+	}
+
+	c.out.printStdout("By source (as of %v):\n", formatTimestamp(st.SourcesUpdate))
+
+	for _, s := range st.Sources {
+		c.out.printStdout("  %v\n", s.Source)
+		c.out.printStdout("    snapshots:       %v, latest %v\n", s.SnapshotCount, formatTimestamp(s.LatestSnapshotTime))
+		c.out.printStdout("    unique contents: %v\n", formatContentStats(s.ContentStats))
+	}
+
+	return nil
+}
+
+func formatContentStats(cs maintenance.ContentStats) string {
+	return units.Count(cs.Count) + " contents, " +
+		units.BytesString(cs.OriginalBytes) + " original, " +
+		units.BytesString(cs.PackedBytes) + " stored"
+}
@@ -0,0 +1,44 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryStats(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	// statistics are not available until full maintenance runs.
+	env.RunAndExpectFailure(t, "repository", "stats")
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), bytes.Repeat([]byte{1, 2, 3}, 100000), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none")
+
+	out := strings.Join(env.RunAndExpectSuccess(t, "repository", "stats"), "\n")
+	require.Contains(t, out, "By compression:")
+	require.Contains(t, out, "By content age:")
+	require.Contains(t, out, dir)
+
+	var st maintenance.RepositoryStats
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "stats", "--json"), &st)
+	require.Positive(t, st.Contents.Count)
+	require.Len(t, st.Sources, 1)
+	require.Equal(t, 1, st.Sources[0].SnapshotCount)
+	require.Positive(t, st.Sources[0].Count)
+}
@@ -8,6 +8,7 @@
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,13 +16,18 @@
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/failover"
+	"github.com/kopia/kopia/repo/blob/layout"
 	"github.com/kopia/kopia/repo/content/index"
 	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/repo/splitter"
 )
 
 type commandRepositoryStatus struct {
 	statusReconnectToken                bool
 	statusReconnectTokenIncludePassword bool
+	statusDiagnostics                   bool
 
 	svc advancedAppServices
 	jo  jsonOutput
@@ -39,12 +45,23 @@ type RepositoryStatus struct {
 	ContentFormat format.ContentFormat            `json:"contentFormat"`
 	ObjectFormat  format.ObjectFormat             `json:"objectFormat"`
 	BlobRetention format.BlobStorageConfiguration `json:"blobRetention"`
+	BlobLayout    *layout.Parameters              `json:"blobLayout,omitempty"`
+
+	StorageEndpoints []failover.EndpointStatus `json:"storageEndpoints,omitempty"`
+
+	Diagnostics *repo.ConnectionDiagnostics `json:"diagnostics,omitempty"`
+}
+
+// storageEndpointStatusProvider is implemented by repositories with read mirrors.
+type storageEndpointStatusProvider interface {
+	StorageEndpointStatus(ctx context.Context) []failover.EndpointStatus
 }
 
 func (c *commandRepositoryStatus) setup(svc advancedAppServices, parent commandParent) {
 	cmd := parent.Command("status", "Display the status of connected repository.")
 	cmd.Flag("reconnect-token", "Display reconnect command").Short('t').BoolVar(&c.statusReconnectToken)
 	cmd.Flag("reconnect-token-with-password", "Include password in reconnect token").Short('s').BoolVar(&c.statusReconnectTokenIncludePassword)
+	cmd.Flag("diagnostics", "Include connection diagnostics, such as storage latency and clock skew").BoolVar(&c.statusDiagnostics)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 
 	c.svc = svc
@@ -64,9 +81,14 @@ func (c *commandRepositoryStatus) outputJSON(ctx context.Context, r repo.Reposit
 		s.UniqueIDHex = hex.EncodeToString(dr.UniqueID())
 		s.ObjectFormat = dr.ObjectFormat()
 		s.BlobRetention, _ = dr.FormatManager().BlobCfgBlob(ctx)
+		s.BlobLayout = dr.FormatManager().BlobLayout()
 		s.Storage = scrubber.ScrubSensitiveData(reflect.ValueOf(ci)).Interface().(blob.ConnectionInfo) //nolint:forcetypeassert
 		s.ContentFormat = dr.FormatManager().ScrubbedContentFormat()
 
+		if sp, ok := dr.(storageEndpointStatusProvider); ok {
+			s.StorageEndpoints = sp.StorageEndpointStatus(ctx)
+		}
+
 		switch cp, err := dr.BlobVolume().GetCapacity(ctx); {
 		case err == nil:
 			s.Capacity = &cp
@@ -75,6 +97,15 @@ func (c *commandRepositoryStatus) outputJSON(ctx context.Context, r repo.Reposit
 		default:
 			return errors.Wrap(err, "unable to get storage volume capacity")
 		}
+
+		if c.statusDiagnostics {
+			d, err := dr.Diagnostics(ctx)
+			if err != nil {
+				return errors.Wrap(err, "unable to get diagnostics")
+			}
+
+			s.Diagnostics = d
+		}
 	}
 
 	c.out.printStdout("%s\n", c.jo.jsonBytes(s))
@@ -82,6 +113,26 @@ func (c *commandRepositoryStatus) outputJSON(ctx context.Context, r repo.Reposit
 	return nil
 }
 
+func (c *commandRepositoryStatus) outputStorageEndpoints(ctx context.Context, dr repo.DirectRepository) {
+	sp, ok := dr.(storageEndpointStatusProvider)
+	if !ok {
+		return
+	}
+
+	for _, es := range sp.StorageEndpointStatus(ctx) {
+		role := "Read mirror:        "
+		if es.Primary {
+			role = "Primary storage:    "
+		}
+
+		if es.Healthy {
+			c.out.printStdout("%v %v (healthy)\n", role, es.DisplayName)
+		} else {
+			c.out.printStdout("%v %v (unavailable: %v)\n", role, es.DisplayName, es.LastError)
+		}
+	}
+}
+
 func (c *commandRepositoryStatus) dumpUpgradeStatus(ctx context.Context, dr repo.DirectRepository) error {
 	drw, isDr := dr.(repo.DirectRepositoryWriter)
 	if !isDr {
@@ -120,6 +171,47 @@ func (c *commandRepositoryStatus) dumpUpgradeStatus(ctx context.Context, dr repo
 	return nil
 }
 
+func (c *commandRepositoryStatus) dumpDiagnostics(ctx context.Context, dr repo.DirectRepository) error {
+	d, err := dr.Diagnostics(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get diagnostics")
+	}
+
+	c.out.printStdout("\n")
+	c.out.printStdout("Kopia version:       %v (%v)\n", d.BuildVersion, d.BuildInfo)
+
+	if d.CacheDirectory != "" {
+		c.out.printStdout("Cache directory:     %v\n", d.CacheDirectory)
+	}
+
+	c.out.printStdout("Cache size:          %v", units.BytesString(d.Cache.TotalSizeBytes))
+
+	if d.Cache.TotalSizeLimitBytes > 0 {
+		c.out.printStdout(" of %v", units.BytesString(d.Cache.TotalSizeLimitBytes))
+	}
+
+	c.out.printStdout("\n")
+
+	for _, cs := range d.Cache.Caches {
+		c.out.printStdout("  %-17v  %v in %v items\n", cs.Description+":", units.BytesString(cs.SizeBytes), cs.Count)
+	}
+
+	if l := d.StorageLatency; l != nil {
+		c.out.printStdout("Storage latency:     min %v, avg %v, max %v (%v requests)\n",
+			l.Min.Round(time.Microsecond), l.Average.Round(time.Microsecond), l.Max.Round(time.Microsecond), l.Samples)
+	} else {
+		c.out.printStdout("Storage latency:     %v\n", d.StorageLatencyError)
+	}
+
+	if d.ClockSkew != nil {
+		c.out.printStdout("Clock skew:          %v (storage clock relative to local clock)\n", d.ClockSkew.Round(time.Millisecond))
+	} else {
+		c.out.printStdout("Clock skew:          %v\n", d.ClockSkewError)
+	}
+
+	return nil
+}
+
 func (c *commandRepositoryStatus) dumpRetentionStatus(ctx context.Context, dr repo.DirectRepository) {
 	if blobcfg, _ := dr.FormatManager().BlobCfgBlob(ctx); blobcfg.IsRetentionEnabled() {
 		c.out.printStdout("\n")
@@ -141,6 +233,10 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	c.out.printStdout("Username:            %v\n", rep.ClientOptions().Username)
 	c.out.printStdout("Read-only:           %v\n", rep.ClientOptions().ReadOnly)
 
+	if kp := rep.ClientOptions().KeyProvider; kp != nil {
+		c.out.printStdout("Key provider:        %v\n", kp.Type)
+	}
+
 	t := rep.ClientOptions().FormatBlobCacheDuration
 	if t > 0 {
 		c.out.printStdout("Format blob cache:   %v\n", t)
@@ -155,6 +251,10 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 
 	c.out.printStdout("\n")
 
+	if reason := dr.ContentReader().CacheStats().MemoryFallbackReason; reason != "" {
+		c.out.printStdout("Cache:               in memory, %v\n", reason)
+	}
+
 	ci := dr.BlobReader().ConnectionInfo()
 	c.out.printStdout("Storage type:        %v\n", ci.Type)
 
@@ -172,6 +272,12 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 		c.out.printStdout("Storage config:      %v\n", string(cjson))
 	}
 
+	if l := dr.FormatManager().BlobLayout(); !l.IsDefault() {
+		c.out.printStdout("Blob layout:         %v\n", l)
+	}
+
+	c.outputStorageEndpoints(ctx, dr)
+
 	contentFormat := dr.ContentReader().ContentFormat()
 
 	mp, mperr := contentFormat.GetMutableParameters(ctx)
@@ -183,7 +289,24 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	c.out.printStdout("Unique ID:           %x\n", dr.UniqueID())
 	c.out.printStdout("Hash:                %v\n", contentFormat.GetHashFunction())
 	c.out.printStdout("Encryption:          %v\n", contentFormat.GetEncryptionAlgorithm())
+
+	if contentFormat.GetECCAlgorithm() != "" && contentFormat.GetECCOverheadPercent() > 0 {
+		c.out.printStdout("Error correction:    %v (%v%% overhead)\n", contentFormat.GetECCAlgorithm(), contentFormat.GetECCOverheadPercent())
+	}
+
+	if dr.FormatManager().IsPasswordless() {
+		c.out.printStdout("Key derivation:      %v (password-less)\n", dr.FormatManager().KeyDerivationAlgorithm())
+	} else {
+		c.out.printStdout("Key derivation:      %v\n", dr.FormatManager().KeyDerivationAlgorithm())
+	}
 	c.out.printStdout("Splitter:            %v\n", dr.ObjectFormat().Splitter)
+
+	if p := object.SplitterParameters(dr.ObjectFormat()); !p.IsEmpty() {
+		if p, err := splitter.ResolveParameters(dr.ObjectFormat().Splitter, p); err == nil {
+			c.out.printStdout("Splitter chunk size: min %v, avg %v, max %v\n", units.BytesString(p.MinSize), units.BytesString(p.AvgSize), units.BytesString(p.MaxSize))
+		}
+	}
+
 	c.out.printStdout("Format version:      %v\n", mp.Version)
 	c.out.printStdout("Content compression: %v\n", mp.IndexVersion >= index.Version2)
 	c.out.printStdout("Password changes:    %v\n", contentFormat.SupportsPasswordChange())
@@ -192,6 +315,7 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 
 	c.out.printStdout("Max pack length:     %v\n", units.BytesString(mp.MaxPackSize))
 	c.out.printStdout("Index Format:        v%v\n", mp.IndexVersion)
+	c.out.printStdout("Read Verification:   %v\n", mp.VerifyContentHashOnRead)
 
 	emgr, epochMgrEnabled, emerr := dr.ContentReader().EpochManager(ctx)
 	if emerr != nil {
@@ -222,6 +346,12 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 		return errors.Wrap(err, "failed to dump upgrade status")
 	}
 
+	if c.statusDiagnostics {
+		if err := c.dumpDiagnostics(ctx, dr); err != nil {
+			return err
+		}
+	}
+
 	if !c.statusReconnectToken {
 		return nil
 	}
@@ -0,0 +1,35 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryStatusDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	out := strings.Join(env.RunAndExpectSuccess(t, "repository", "status", "--diagnostics"), "\n")
+	require.Contains(t, out, "Storage latency:     min")
+	require.Contains(t, out, "Clock skew:")
+
+	var rs cli.RepositoryStatus
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "status", "--diagnostics", "--json"), &rs)
+	require.NotNil(t, rs.Diagnostics)
+	require.NotNil(t, rs.Diagnostics.StorageLatency)
+	require.NotNil(t, rs.Diagnostics.ClockSkew)
+
+	var rs2 cli.RepositoryStatus
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "status", "--json"), &rs2)
+	require.Nil(t, rs2.Diagnostics)
+}
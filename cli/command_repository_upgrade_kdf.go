@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/crypto"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/repo/format"
+)
+
+type commandRepositoryUpgradeKDF struct {
+	algorithm string
+}
+
+func (c *commandRepositoryUpgradeKDF) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("upgrade-kdf", "Re-wrap the repository master key using a different key derivation algorithm. Repository data is not rewritten.")
+	cmd.Flag("algorithm", "Key derivation algorithm to derive the format encryption key from the repository password").Default(crypto.Argon2idAlgorithm).EnumVar(&c.algorithm, format.SupportedFormatBlobKeyDerivationAlgorithms()...)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryUpgradeKDF) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	old := rep.FormatManager().KeyDerivationAlgorithm()
+
+	if err := rep.FormatManager().ChangeKeyDerivationAlgorithm(ctx, c.algorithm); err != nil {
+		return errors.Wrap(err, "unable to change key derivation algorithm")
+	}
+
+	log(ctx).Infof("Changed key derivation algorithm from %v to %v.", old, c.algorithm)
+
+	eventlog.RecordOrWarn(ctx, rep, eventlog.FormatChanged, "changed key derivation algorithm to "+c.algorithm, map[string]string{
+		"oldKeyDerivationAlgorithm": old,
+		"keyDerivationAlgorithm":    c.algorithm,
+	})
+	log(ctx).Info("NOTE: Clients running older versions of Kopia may not be able to open the repository.")
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/crypto"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func (s *formatSpecificTestSuite) TestRepositoryUpgradeKDF(t *testing.T) {
+	env1 := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+	env2 := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+
+	env1.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+
+	if s.formatVersion < format.FormatVersion3 {
+		env1.RunAndExpectFailure(t, "repo", "upgrade-kdf", "--algorithm", crypto.Argon2idAlgorithm)
+
+		return
+	}
+
+	env1.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+
+	env1.RunAndExpectSuccess(t, "repo", "upgrade-kdf", "--algorithm", crypto.Argon2idAlgorithm)
+	env1.RunAndExpectFailure(t, "repo", "upgrade-kdf", "--algorithm", crypto.Argon2idAlgorithm)
+
+	require.Contains(t, env1.RunAndExpectSuccess(t, "repo", "status"), "Key derivation:      "+crypto.Argon2idAlgorithm)
+
+	// existing connections keep working using the same password and can read existing data.
+	env2.RunAndExpectSuccess(t, "snapshot", "ls")
+	env2.RunAndExpectSuccess(t, "snapshot", "verify")
+
+	// new connections work with the same password.
+	env3 := testenv.NewCLITest(t, s.formatFlags, testenv.NewInProcRunner(t))
+	env3.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env1.RepoDir)
+	env3.RunAndExpectSuccess(t, "snapshot", "verify")
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandRepositoryUsage struct {
+	byUser bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryUsage) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("usage", "Display storage attributed to user@host pairs, computed during the most recent full maintenance.")
+	cmd.Flag("by-user", "Show storage used by each user@host pair").BoolVar(&c.byUser)
+	c.jo.setup(svc, cmd)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+	c.out.setup(svc)
+}
+
+func (c *commandRepositoryUsage) run(ctx context.Context, rep repo.DirectRepository) error {
+	st, err := maintenance.GetRepositoryStats(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get repository statistics")
+	}
+
+	if st == nil || st.Usage == nil {
+		return errors.New("usage statistics are not available yet, run 'kopia maintenance run --full' to compute them")
+	}
+
+	u := *st.Usage
+	if !c.byUser {
+		u.Users = nil
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(u))
+		return nil
+	}
+
+	if c.byUser {
+		c.out.printStdout("%-30v %9v %12v %12v %12v\n", "USER", "SNAPSHOTS", "UNIQUE", "SHARED", "TOTAL")
+
+		for _, uu := range u.Users {
+			c.out.printStdout("%-30v %9v %12v %12v %12v\n",
+				uu.UserName+"@"+uu.Host,
+				uu.SnapshotCount,
+				units.BytesString(uu.UniqueBytes),
+				units.BytesString(uu.SharedBytes),
+				units.BytesString(uu.UniqueBytes+uu.SharedBytes))
+		}
+
+		c.out.printStdout("\n")
+	}
+
+	c.out.printStdout("Updated:  %v\n", formatTimestamp(u.UpdateTime))
+	c.out.printStdout("Stored:   %v in %v contents referenced by snapshots\n", units.BytesString(u.Bytes), units.Count(u.ContentCount))
+	c.out.printStdout("Shared:   %v in %v contents referenced by multiple user@host pairs (%.1f%%)\n",
+		units.BytesString(u.SharedBytes), units.Count(u.SharedContentCount), percentOf(u.SharedBytes, u.Bytes))
+
+	return nil
+}
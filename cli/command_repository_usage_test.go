@@ -0,0 +1,57 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryUsage(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--override-username=user1", "--override-hostname=host1")
+
+	// usage is not available until full maintenance runs.
+	env.RunAndExpectFailure(t, "repository", "usage")
+
+	dir1 := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir1, "shared"), []byte("contents shared between both hosts"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir1, "unique"), []byte("contents unique to host1"), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir1)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir1)
+
+	env.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--override-username=user2", "--override-hostname=host2")
+
+	dir2 := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir2, "shared"), []byte("contents shared between both hosts"), 0o600))
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir2)
+
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none", "--force")
+
+	out := strings.Join(env.RunAndExpectSuccess(t, "repository", "usage", "--by-user"), "\n")
+	require.Contains(t, out, "user1@host1")
+	require.Contains(t, out, "user2@host2")
+
+	var u maintenance.UsageStats
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "usage", "--json"), &u)
+	require.Positive(t, u.Bytes)
+	require.Empty(t, u.Users)
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "repository", "usage", "--by-user", "--json"), &u)
+	require.Len(t, u.Users, 2)
+	require.EqualValues(t, 1, u.SharedContentCount)
+	require.Equal(t, "user1", u.Users[0].UserName)
+	require.Equal(t, 2, u.Users[0].SnapshotCount)
+	require.Positive(t, u.Users[0].UniqueBytes)
+	require.Equal(t, u.Users[0].SharedBytes, u.Users[1].SharedBytes)
+	require.Equal(t, 1, u.Users[1].SnapshotCount)
+}
@@ -13,6 +13,7 @@
 	"strings"
 	"time"
 
+	atunits "github.com/alecthomas/units"
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
@@ -114,48 +115,65 @@ type commandRestore struct {
 	restoreOverwriteFiles         bool
 	restoreOverwriteSymlinks      bool
 	restoreWriteSparseFiles       bool
+	restoreWriteSparseFilesSet    bool
 	restoreConsistentAttributes   bool
 	restoreMode                   string
 	restoreParallel               int
+	restorePrefetchSize           atunits.Base2Bytes
+	restoreAutoTune               bool
+	restoreVerbose                bool
 	restoreIgnorePermissionErrors bool
 	restoreWriteFilesAtomically   bool
 	restoreSkipTimes              bool
 	restoreSkipOwners             bool
+	restoreSkipXattrs             bool
 	restoreSkipPermissions        bool
 	restoreIncremental            bool
 	restoreIgnoreErrors           bool
+	restoreSpecialFiles           bool
+	restoreVerifyAfter            bool
 	restoreShallowAtDepth         int32
 	minSizeForPlaceholder         int32
 	snapshotTime                  string
+	preflight                     bool
 
 	restores []restoreSourceTarget
 
+	out textOutput
 	svc appServices
 }
 
 func (c *commandRestore) setup(svc appServices, parent commandParent) {
 	c.restoreShallowAtDepth = unlimitedDepth
 	c.svc = svc
+	c.out.setup(svc)
 
 	cmd := parent.Command("restore", restoreCommandHelp)
 	cmd.Arg("sources", restoreCommandSourcePathHelp).Required().StringsVar(&c.restoreTargetPaths)
 	cmd.Flag("overwrite-directories", "Overwrite existing directories").Default("true").BoolVar(&c.restoreOverwriteDirectories)
 	cmd.Flag("overwrite-files", "Specifies whether or not to overwrite already existing files").Default("true").BoolVar(&c.restoreOverwriteFiles)
 	cmd.Flag("overwrite-symlinks", "Specifies whether or not to overwrite already existing symlinks").Default("true").BoolVar(&c.restoreOverwriteSymlinks)
-	cmd.Flag("write-sparse-files", "When doing a restore, attempt to write files sparsely-allocating the minimum amount of disk space needed.").Default("false").BoolVar(&c.restoreWriteSparseFiles)
+	cmd.Flag("write-sparse-files", "When doing a restore, attempt to write files sparsely-allocating the minimum amount of disk space needed. By default only long runs of zeros are written as holes, --no-write-sparse-files disables that.").Default("false").IsSetByUser(&c.restoreWriteSparseFilesSet).BoolVar(&c.restoreWriteSparseFiles)
 	cmd.Flag("consistent-attributes", "When multiple snapshots match, fail if they have inconsistent attributes").Envar(svc.EnvName("KOPIA_RESTORE_CONSISTENT_ATTRIBUTES")).BoolVar(&c.restoreConsistentAttributes)
 	cmd.Flag("mode", "Override restore mode").Default(restoreModeAuto).EnumVar(&c.restoreMode, restoreModeAuto, restoreModeLocal, restoreModeZip, restoreModeZipNoCompress, restoreModeTar, restoreModeTgz)
-	cmd.Flag("parallel", "Restore parallelism (1=disable)").Default("8").IntVar(&c.restoreParallel)
+	cmd.Flag("parallel", "Restore parallelism (1=disable), maximum parallelism when auto-tuning").Default("8").IntVar(&c.restoreParallel)
+	cmd.Flag("prefetch-size", "Prefetch contents of up to this many bytes of files ahead of the restore (0B=disable), maximum when auto-tuning").Default("128MiB").BytesVar(&c.restorePrefetchSize)
+	cmd.Flag("auto-tune", "Adjust parallelism and prefetch size during the restore based on backend latency and write throughput").Default("true").BoolVar(&c.restoreAutoTune)
+	cmd.Flag("verbose", "Show auto-tuning decisions and current parameters in progress output").BoolVar(&c.restoreVerbose)
 	cmd.Flag("skip-owners", "Skip owners during restore").BoolVar(&c.restoreSkipOwners)
 	cmd.Flag("skip-permissions", "Skip permissions during restore").BoolVar(&c.restoreSkipPermissions)
 	cmd.Flag("skip-times", "Skip times during restore").BoolVar(&c.restoreSkipTimes)
+	cmd.Flag("skip-xattrs", "Skip extended attributes, ACLs and platform-specific metadata during restore").BoolVar(&c.restoreSkipXattrs)
 	cmd.Flag("ignore-permission-errors", "Ignore permission errors").Default("true").BoolVar(&c.restoreIgnorePermissionErrors)
 	cmd.Flag("write-files-atomically", "Write files atomically to disk, ensuring they are either fully committed, or not written at all, preventing partially written files").Default("false").BoolVar(&c.restoreWriteFilesAtomically)
 	cmd.Flag("ignore-errors", "Ignore all errors").BoolVar(&c.restoreIgnoreErrors)
+	cmd.Flag("special-files", "Recreate named pipes, sockets and device nodes recorded in the snapshot").BoolVar(&c.restoreSpecialFiles)
+	cmd.Flag("verify-after", "After the restore, read back restored files and compare them with the snapshot, reporting files that don't match").BoolVar(&c.restoreVerifyAfter)
 	cmd.Flag("skip-existing", "Skip files and symlinks that exist in the output").BoolVar(&c.restoreIncremental)
 	cmd.Flag("shallow", "Shallow restore the directory hierarchy starting at this level (default is to deep restore the entire hierarchy.)").Int32Var(&c.restoreShallowAtDepth)
 	cmd.Flag("shallow-minsize", "When doing a shallow restore, write actual files instead of placeholders smaller than this size.").Int32Var(&c.minSizeForPlaceholder)
 	cmd.Flag("snapshot-time", "When using a path as the source, use the latest snapshot available before this date. Default is latest").Default("latest").StringVar(&c.snapshotTime)
+	cmd.Flag("preflight", "Do not restore, only verify that all contents are available and the target has enough space").BoolVar(&c.preflight)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
@@ -266,7 +284,9 @@ func (c *commandRestore) restoreOutput(ctx context.Context, rep repo.Repository)
 			SkipOwners:             c.restoreSkipOwners,
 			SkipPermissions:        c.restoreSkipPermissions,
 			SkipTimes:              c.restoreSkipTimes,
+			SkipExtendedAttributes: c.restoreSkipXattrs,
 			WriteSparseFiles:       c.restoreWriteSparseFiles,
+			AutoSparseFiles:        !c.restoreWriteSparseFilesSet,
 		}
 
 		if err := o.Init(ctx); err != nil {
@@ -334,7 +354,11 @@ func (c *commandRestore) detectRestoreMode(ctx context.Context, m, targetpath st
 }
 
 func printRestoreStats(ctx context.Context, st *restore.Stats) {
-	var maybeSkipped, maybeErrors string
+	var maybeSpecial, maybeSkipped, maybeErrors, maybeVerified string
+
+	if st.RestoredSpecialFileCount > 0 {
+		maybeSpecial = fmt.Sprintf(", %v special files", st.RestoredSpecialFileCount)
+	}
 
 	if st.SkippedCount > 0 {
 		maybeSkipped = fmt.Sprintf(", skipped %v (%v)", st.SkippedCount, units.BytesString(st.SkippedTotalFileSize))
@@ -344,12 +368,17 @@ func printRestoreStats(ctx context.Context, st *restore.Stats) {
 		maybeErrors = fmt.Sprintf(", ignored %v errors", st.IgnoredErrorCount)
 	}
 
-	log(ctx).Infof("Restored %v files, %v directories and %v symbolic links (%v)%v%v.\n",
+	if st.VerifiedFileCount > 0 {
+		maybeVerified = fmt.Sprintf(", verified %v files (%v mismatches)", st.VerifiedFileCount, st.VerificationMismatchCount)
+	}
+
+	log(ctx).Infof("Restored %v files, %v directories and %v symbolic links%v (%v)%v%v%v.\n",
 		st.RestoredFileCount,
 		st.RestoredDirCount,
 		st.RestoredSymlinkCount,
+		maybeSpecial,
 		units.BytesString(st.RestoredTotalFileSize),
-		maybeSkipped, maybeErrors)
+		maybeSkipped, maybeErrors, maybeVerified)
 }
 
 func (c *commandRestore) setupPlaceholderExpansion(ctx context.Context, rep repo.Repository, rstp restoreSourceTarget, output restore.Output) (fs.Entry, error) {
@@ -385,10 +414,15 @@ func (c *commandRestore) getRestoreProgress() RestoreProgress {
 		out:                    pf.out,
 		progressUpdateInterval: pf.progressUpdateInterval,
 		eta:                    timetrack.Start(),
+		verbose:                c.restoreVerbose,
 	}
 }
 
 func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
+	if c.preflight {
+		return c.runPreflight(ctx, rep)
+	}
+
 	output, oerr := c.restoreOutput(ctx, rep)
 	if oerr != nil {
 		return errors.Wrap(oerr, "unable to initialize output")
@@ -405,16 +439,11 @@ func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
 
 			rootEntry = re
 		} else {
-			source, err := c.tryToConvertPathToID(ctx, rep, rstp.source)
+			re, err := c.snapshotRootEntry(ctx, rep, rstp.source)
 			if err != nil {
 				return err
 			}
 
-			re, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, source, c.restoreConsistentAttributes)
-			if err != nil {
-				return errors.Wrap(err, "unable to get filesystem entry")
-			}
-
 			rootEntry = re
 		}
 
@@ -429,20 +458,61 @@ func (c *commandRestore) run(ctx context.Context, rep repo.Repository) error {
 			IgnoreErrors:           c.restoreIgnoreErrors,
 			RestoreDirEntryAtDepth: c.restoreShallowAtDepth,
 			MinSizeForPlaceholder:  c.minSizeForPlaceholder,
+			PrefetchBytes:          int64(c.restorePrefetchSize),
+			AutoTune:               c.restoreAutoTune,
+			RestoreSpecialFiles:    c.restoreSpecialFiles,
+			VerifyAfter:            c.restoreVerifyAfter,
 			ProgressCallback:       progressCallback,
+			OnTuningDecision:       c.onTuningDecision,
 		})
-		if err != nil {
+
+		// files that failed verification were restored, so statistics are printed before the report.
+		var verr *restore.VerificationError
+		if err != nil && !errors.As(err, &verr) {
 			return errors.Wrap(err, "error restoring")
 		}
 
 		progressCallback(ctx, st)
 		restoreProgress.Flush() // Force last progress values to be printed
 		printRestoreStats(ctx, &st)
+
+		if verr != nil {
+			c.printVerificationMismatches(ctx, verr)
+			return errors.Wrap(err, "error restoring")
+		}
 	}
 
 	return nil
 }
 
+func (c *commandRestore) printVerificationMismatches(ctx context.Context, verr *restore.VerificationError) {
+	log(ctx).Errorf("%v restored files do not match the snapshot:", len(verr.Mismatches))
+
+	for _, m := range verr.Mismatches {
+		c.out.printStdout("  %v\n", m)
+	}
+}
+
+func (c *commandRestore) onTuningDecision(ctx context.Context, d restore.TuningDecision) {
+	if c.restoreVerbose {
+		log(ctx).Infof("Auto-tuning: %v", d)
+	}
+}
+
+func (c *commandRestore) snapshotRootEntry(ctx context.Context, rep repo.Repository, source string) (fs.Entry, error) {
+	source, err := c.tryToConvertPathToID(ctx, rep, source)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, source, c.restoreConsistentAttributes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get filesystem entry")
+	}
+
+	return re, nil
+}
+
 // tryToConvertPathToID checks if the source is a path and in this case returns the ID of the snapshot
 // containing the latest version available.
 func (c *commandRestore) tryToConvertPathToID(ctx context.Context, rep repo.Repository, source string) (string, error) {
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// runPreflight checks all restore sources and targets without writing anything.
+func (c *commandRestore) runPreflight(ctx context.Context, rep repo.Repository) error {
+	if err := c.constructTargetPairs(rep); err != nil {
+		return err
+	}
+
+	var failed int
+
+	for _, rstp := range c.restores {
+		var (
+			rootEntry fs.Entry
+			err       error
+		)
+
+		if rstp.isplaceholder {
+			rootEntry, err = snapshotfs.GetEntryFromPlaceholder(ctx, rep, localfs.PlaceholderFilePath(rstp.source))
+			if err != nil {
+				return errors.Wrapf(err, "unable to get filesystem entry for placeholder %q", rstp.source)
+			}
+		} else {
+			rootEntry, err = c.snapshotRootEntry(ctx, rep, rstp.source)
+			if err != nil {
+				return err
+			}
+		}
+
+		targetDir := rstp.target
+		if c.detectRestoreMode(ctx, c.restoreMode, rstp.target) != restoreModeLocal {
+			// archive outputs are written as a single file in the parent directory.
+			targetDir = filepath.Dir(rstp.target)
+		}
+
+		log(ctx).Infof("Running restore preflight check of %v...", rstp.source)
+
+		res, err := restore.Preflight(ctx, rep, rootEntry, restore.PreflightOptions{
+			Parallel:   c.restoreParallel,
+			TargetPath: targetDir,
+		})
+		if err != nil {
+			return errors.Wrap(err, "preflight check failed")
+		}
+
+		c.printPreflightResult(rstp, res)
+
+		if !res.OK() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("preflight check found problems with %v of %v restore sources", failed, len(c.restores))
+	}
+
+	return nil
+}
+
+func (c *commandRestore) printPreflightResult(rstp restoreSourceTarget, res *restore.PreflightResult) {
+	c.out.printStdout("Source:            %v\n", rstp.source)
+	c.out.printStdout("Target:            %v\n", rstp.target)
+	c.out.printStdout("Files:             %v\n", res.FileCount)
+	c.out.printStdout("Directories:       %v\n", res.DirCount)
+	c.out.printStdout("Symlinks:          %v\n", res.SymlinkCount)
+	c.out.printStdout("Required space:    %v\n", units.BytesString(res.TotalFileSize))
+	c.out.printStdout("Download size:     %v (%v contents)\n", units.BytesString(res.DownloadSize), res.ContentCount)
+
+	if res.AvailableSpace >= 0 {
+		c.out.printStdout("Available space:   %v\n", units.BytesString(res.AvailableSpace))
+	} else {
+		c.out.printStdout("Available space:   unknown\n")
+	}
+
+	c.out.printStdout("Target writable:   %v\n", res.TargetWritable)
+
+	if res.OK() {
+		c.out.printStdout("Preflight check passed.\n\n")
+		return
+	}
+
+	c.out.printStdout("Problems:          %v\n", res.ProblemsCount)
+
+	for _, p := range res.Problems {
+		c.out.printStdout("  %v: %v\n", p.Path, p.Error)
+	}
+
+	if len(res.Problems) < res.ProblemsCount {
+		c.out.printStdout("  ... and %v more\n", res.ProblemsCount-len(res.Problems))
+	}
+
+	c.out.printStdout("\n")
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/remoterestore"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRestoreRequests struct {
+	list commandRestoreRequestsList
+	run  commandRestoreRequestsRun
+}
+
+func (c *commandRestoreRequests) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("restore-requests", "Commands to manage restores scheduled by the server.")
+
+	c.list.setup(svc, cmd)
+	c.run.setup(svc, cmd)
+}
+
+type commandRestoreRequestsList struct {
+	all bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRestoreRequestsList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List restore requests scheduled for this client").Alias("ls")
+	cmd.Flag("all", "List restore requests for all clients").BoolVar(&c.all)
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandRestoreRequestsList) run(ctx context.Context, rep repo.Repository) error {
+	var username, hostname string
+
+	if !c.all {
+		username = rep.ClientOptions().Username
+		hostname = rep.ClientOptions().Hostname
+	}
+
+	requests, err := remoterestore.List(ctx, rep, username, hostname)
+	if err != nil {
+		return errors.Wrap(err, "error listing restore requests")
+	}
+
+	var jl jsonList
+
+	jl.begin(&c.jo)
+	defer jl.end()
+
+	for _, r := range requests {
+		if c.jo.jsonOutput {
+			jl.emit(r)
+			continue
+		}
+
+		c.out.printStdout("%v %v@%v %v snapshot:%v target:%v files:%v bytes:%v %v\n",
+			r.ID, r.Username, r.Hostname, r.Status.State, r.SnapshotID, r.Output.TargetPath,
+			r.Status.Stats.RestoredFileCount, r.Status.Stats.RestoredTotalFileSize, r.Status.Error)
+	}
+
+	return nil
+}
+
+type commandRestoreRequestsRun struct {
+	watch    bool
+	interval time.Duration
+
+	svc appServices
+}
+
+func (c *commandRestoreRequestsRun) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("run", "Execute pending restore requests scheduled for this client")
+	cmd.Flag("watch", "Keep running and execute restore requests as they are scheduled").BoolVar(&c.watch)
+	cmd.Flag("interval", "Interval between checks for new restore requests").Default("1m").DurationVar(&c.interval)
+
+	c.svc = svc
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandRestoreRequestsRun) run(ctx context.Context, rep repo.Repository) error {
+	username := rep.ClientOptions().Username
+	hostname := rep.ClientOptions().Hostname
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.svc.onTerminate(cancel)
+
+	for {
+		n, err := remoterestore.ProcessPending(ctx, rep, username, hostname)
+		if err != nil {
+			return errors.Wrap(err, "error processing restore requests")
+		}
+
+		if !c.watch {
+			log(ctx).Infof("Executed %v restore requests.", n)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-time.After(c.interval):
+		}
+
+		if err := rep.Refresh(ctx); err != nil {
+			return errors.Wrap(err, "error refreshing repository")
+		}
+	}
+}
@@ -4,22 +4,34 @@
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 )
 
 type commandServer struct {
-	acl      commandServerACL
-	user     commandServerUser
-	cancel   commandServerCancel
-	flush    commandServerFlush
-	pause    commandServerPause
-	refresh  commandServerRefresh
-	resume   commandServerResume
-	start    commandServerStart
-	status   commandServerStatus
-	throttle commandServerThrottle
-	upload   commandServerUpload
-	shutdown commandServerShutdown
+	acl         commandServerACL
+	agent       commandServerAgent
+	audit       commandServerAudit
+	user        commandServerUser
+	cancel      commandServerCancel
+	enroll      commandServerEnroll
+	enrollment  commandServerEnrollment
+	flush       commandServerFlush
+	leader      commandServerLeader
+	logLevel    commandServerLogLevel
+	maint       commandServerMaintenance
+	pause       commandServerPause
+	quota       commandServerQuota
+	refresh     commandServerRefresh
+	resume      commandServerResume
+	serveNFS    commandServerServeNFS
+	serveS3     commandServerServeS3
+	serveWebDAV commandServerServeWebDAV
+	start       commandServerStart
+	status      commandServerStatus
+	throttle    commandServerThrottle
+	upload      commandServerUpload
+	shutdown    commandServerShutdown
+	webhook     commandServerWebhook
 }
 
 type serverFlags struct {
@@ -72,6 +84,19 @@ func (c *commandServer) setup(svc advancedAppServices, parent commandParent) {
 	c.pause.setup(svc, cmd)
 	c.resume.setup(svc, cmd)
 	c.throttle.setup(svc, cmd)
+	c.quota.setup(svc, cmd)
+	c.maint.setup(svc, cmd)
+	c.audit.setup(svc, cmd)
+	c.leader.setup(svc, cmd)
+	c.logLevel.setup(svc, cmd)
+	c.webhook.setup(svc, cmd)
+	c.enrollment.setup(svc, cmd)
+	c.enroll.setup(svc, cmd)
+
+	c.agent.setup(svc, cmd)
+	c.serveWebDAV.setup(svc, cmd)
+	c.serveNFS.setup(svc, cmd)
+	c.serveS3.setup(svc, cmd)
 }
 
 func (c *serverClientFlags) serverAPIClientOptions() (apiclient.Options, error) {
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/agent"
+	"github.com/kopia/kopia/internal/tlsutil"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandServerAgent struct {
+	listenAddress string
+	token         string
+	allowedPaths  []string
+	tlsCertFile   string
+	tlsKeyFile    string
+
+	svc appServices
+}
+
+func (c *commandServerAgent) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("agent", "Run a gRPC agent which snapshots and restores local paths on request, intended to run as a sidecar (e.g. in Kubernetes pods with mounted volumes).")
+	cmd.Flag("address", "Address to listen on, 'unix:<path>' to listen on a Unix domain socket").Default("127.0.0.1:51600").StringVar(&c.listenAddress)
+	cmd.Flag("token", "Bearer token required from clients").Envar(svc.EnvName("KOPIA_AGENT_TOKEN")).StringVar(&c.token)
+	cmd.Flag("tls-cert-file", "TLS certificate PEM").StringVar(&c.tlsCertFile)
+	cmd.Flag("tls-key-file", "TLS key PEM file").StringVar(&c.tlsKeyFile)
+	cmd.Flag("allowed-path", "Directory which clients can snapshot and restore to, including subdirectories (can be repeated)").Required().StringsVar(&c.allowedPaths)
+	c.svc = svc
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandServerAgent) listen() (net.Listener, error) {
+	if socketPath, ok := strings.CutPrefix(c.listenAddress, "unix:"); ok {
+		// remove stale socket left behind by previous instance.
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "unable to remove existing socket")
+		}
+
+		l, err := net.Listen("unix", socketPath)
+
+		return l, errors.Wrap(err, "listen error")
+	}
+
+	l, err := net.Listen("tcp", c.listenAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "listen error")
+	}
+
+	if a, ok := l.Addr().(*net.TCPAddr); ok && !a.IP.IsLoopback() {
+		if c.token == "" {
+			l.Close() //nolint:errcheck
+
+			return nil, errors.Errorf("refusing to accept requests without authentication on %v, set --token", a)
+		}
+
+		if !c.useTLS() {
+			l.Close() //nolint:errcheck
+
+			return nil, errors.Errorf("refusing to accept the token over an unencrypted connection on %v, set --tls-cert-file and --tls-key-file", a)
+		}
+	}
+
+	return l, nil
+}
+
+func (c *commandServerAgent) useTLS() bool {
+	return c.tlsCertFile != "" && c.tlsKeyFile != ""
+}
+
+// tlsConfig returns the TLS configuration serving the certificate from the provided PEM files, reloaded
+// when modified, or nil if TLS is not enabled.
+func (c *commandServerAgent) tlsConfig() (*tls.Config, error) {
+	if (c.tlsCertFile == "") != (c.tlsKeyFile == "") {
+		return nil, errors.New("--tls-cert-file and --tls-key-file must be specified together")
+	}
+
+	if !c.useTLS() {
+		return nil, nil //nolint:nilnil
+	}
+
+	r, err := tlsutil.NewCertificateReloader(c.tlsCertFile, c.tlsKeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load TLS certificate")
+	}
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}, nil
+}
+
+func (c *commandServerAgent) run(ctx context.Context, rep repo.Repository) error {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	srv, err := agent.New(ctx, rep, agent.Options{
+		Token:        c.token,
+		AllowedPaths: c.allowedPaths,
+		TLSConfig:    tlsConfig,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to create agent")
+	}
+
+	l, err := c.listen()
+	if err != nil {
+		return err
+	}
+
+	gs := srv.Register()
+
+	c.svc.onTerminate(func() {
+		srv.Cancel()
+		gs.GracefulStop()
+	})
+
+	log(ctx).Infof("Agent listening on %v", l.Addr())
+	log(ctx).Info("Press Ctrl-C to stop.")
+
+	serveErr := gs.Serve(l)
+
+	// let canceled operations finish writing their state before closing the repository.
+	srv.Wait()
+
+	return errors.Wrap(serveErr, "error serving agent")
+}
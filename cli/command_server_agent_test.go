@@ -0,0 +1,28 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerAgentRequiresTLSOffLoopback(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	allowed := testutil.TempDirectory(t)
+
+	_, stderr := env.RunAndExpectFailure(t, "server", "agent", "--address=0.0.0.0:0", "--allowed-path", allowed)
+	require.Contains(t, strings.Join(stderr, "\n"), "set --token")
+
+	_, stderr = env.RunAndExpectFailure(t, "server", "agent", "--address=0.0.0.0:0", "--allowed-path", allowed, "--token=secret")
+	require.Contains(t, strings.Join(stderr, "\n"), "set --tls-cert-file and --tls-key-file")
+
+	env.RunAndExpectFailure(t, "server", "agent", "--allowed-path", allowed, "--tls-cert-file", "cert.pem")
+}
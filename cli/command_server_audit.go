@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerAudit struct {
+	list   commandServerAuditList
+	verify commandServerAuditVerify
+}
+
+func (c *commandServerAudit) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("audit", "Query and verify the audit log of a running server")
+	c.list.setup(svc, cmd)
+	c.verify.setup(svc, cmd)
+}
+
+type commandServerAuditList struct {
+	sf serverClientFlags
+
+	actor  string
+	action string
+	since  time.Duration
+	limit  int
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandServerAuditList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List audit log entries").Alias("ls")
+	cmd.Flag("actor", "Only list entries of the given user").StringVar(&c.actor)
+	cmd.Flag("action", "Only list entries with the given action").StringVar(&c.action)
+	cmd.Flag("since", "Only list entries recorded within the given duration").DurationVar(&c.since)
+	cmd.Flag("limit", "Only list the most recent entries").IntVar(&c.limit)
+
+	c.sf.setup(svc, cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerAuditList) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	f := serverapi.AuditLogFilter{
+		Actor:  c.actor,
+		Action: c.action,
+		Limit:  c.limit,
+	}
+
+	if c.since > 0 {
+		f.From = clock.Now().Add(-c.since)
+	}
+
+	resp, err := serverapi.ListAuditLog(ctx, cli, f)
+	if err != nil {
+		return errors.Wrap(err, "unable to list audit log")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(resp))
+		return nil
+	}
+
+	if !resp.Enabled {
+		log(ctx).Warn("Audit logging is not enabled on the server, use --audit-log when starting it.")
+	}
+
+	for _, e := range resp.Entries {
+		c.out.printStdout("%6v %v %-24v %v %v%v\n", e.Sequence, formatTimestamp(e.Time), e.Action, e.Actor, e.Target, auditDetailsString(e.Details))
+	}
+
+	return nil
+}
+
+func auditDetailsString(details map[string]string) string {
+	var parts []string
+
+	for k, v := range details {
+		parts = append(parts, k+"="+v)
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	sort.Strings(parts)
+
+	return " (" + strings.Join(parts, " ") + ")"
+}
+
+type commandServerAuditVerify struct {
+	sf serverClientFlags
+
+	out textOutput
+}
+
+func (c *commandServerAuditVerify) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("verify", "Verify integrity of the audit log")
+
+	c.sf.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerAuditVerify) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	vr, err := serverapi.VerifyAuditLog(ctx, cli)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify audit log")
+	}
+
+	for _, p := range vr.Problems {
+		c.out.printStderr("%v\n", p)
+	}
+
+	c.out.printStdout("Verified %v entries, last sequence %v, last hash %v\n", vr.EntryCount, vr.LastSequence, vr.LastHash)
+
+	if len(vr.Problems) > 0 {
+		return errors.Errorf("audit log verification found %v problems", len(vr.Problems))
+	}
+
+	return nil
+}
@@ -94,6 +94,18 @@ func TestServerControl(t *testing.T) {
 	env.RunAndExpectSuccess(t, "server", "pause", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword, dir1)
 	env.RunAndExpectSuccess(t, "server", "resume", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword, dir1)
 
+	env.RunAndExpectSuccess(t, "server", "log-level", "set", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword, "upload=debug", "blob=warning")
+	env.RunAndExpectFailure(t, "server", "log-level", "set", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword, "no-such-subsystem=debug")
+	env.RunAndExpectFailure(t, "server", "log-level", "set", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword, "upload=no-such-level")
+	env.RunAndExpectSuccess(t, "server", "log-level", "set", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword, "--reset=upload")
+
+	require.Equal(t, []string{
+		"blob       warning",
+		"index      (default)",
+		"server     (default)",
+		"upload     (default)",
+	}, env.RunAndExpectSuccess(t, "server", "log-level", "get", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword))
+
 	env.RunAndExpectSuccess(t, "server", "throttle", "set", "--address", sp.BaseURL, "--server-control-password", sp.ServerControlPassword,
 		"--download-bytes-per-second=1000000000",
 		"--upload-bytes-per-second=2000000000",
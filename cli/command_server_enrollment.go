@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/enrollment"
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerEnrollment struct {
+	createToken commandServerEnrollmentCreateToken
+	list        commandServerEnrollmentList
+	approve     commandServerEnrollmentApprove
+	reject      commandServerEnrollmentReject
+}
+
+func (c *commandServerEnrollment) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("enrollment", "Manage enrollment of new clients with a running server")
+	c.createToken.setup(svc, cmd)
+	c.list.setup(svc, cmd)
+	c.approve.setup(svc, cmd)
+	c.reject.setup(svc, cmd)
+}
+
+type commandServerEnrollmentCreateToken struct {
+	sf serverClientFlags
+
+	description string
+	validity    time.Duration
+
+	out textOutput
+}
+
+func (c *commandServerEnrollmentCreateToken) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("create-token", "Create a one-time token which allows a client to request enrollment")
+	cmd.Flag("description", "Description of the token, shown with enrollment requests").StringVar(&c.description)
+	cmd.Flag("valid-for", "Duration for which the token can be used").Default("24h").DurationVar(&c.validity)
+
+	c.sf.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerEnrollmentCreateToken) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	resp, err := serverapi.CreateEnrollmentToken(ctx, cli, &serverapi.CreateEnrollmentTokenRequest{
+		Description: c.description,
+		Validity:    c.validity,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to create enrollment token")
+	}
+
+	c.out.printStdout("%v\n", resp.Token)
+	log(ctx).Infof("The token can be used once until %v.", formatTimestamp(resp.ExpireTime))
+
+	return nil
+}
+
+type commandServerEnrollmentList struct {
+	sf serverClientFlags
+
+	all bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandServerEnrollmentList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List enrollment requests").Alias("ls")
+	cmd.Flag("all", "Include approved and rejected requests").BoolVar(&c.all)
+
+	c.sf.setup(svc, cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerEnrollmentList) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	state := enrollment.StatePending
+	if c.all {
+		state = ""
+	}
+
+	resp, err := serverapi.ListEnrollments(ctx, cli, state)
+	if err != nil {
+		return errors.Wrap(err, "unable to list enrollment requests")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(resp))
+		return nil
+	}
+
+	for _, r := range resp.Requests {
+		c.out.printStdout("%v %v %v requested:%v from:%v %v\n",
+			r.ID, r.State, r.Username, formatTimestamp(r.RequestTime), r.RemoteAddress, r.TokenDescription)
+	}
+
+	return nil
+}
+
+type commandServerEnrollmentApprove struct {
+	sf serverClientFlags
+
+	id     string
+	target string
+	level  string
+
+	out textOutput
+}
+
+func (c *commandServerEnrollmentApprove) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("approve", "Approve a pending enrollment request, creating the user")
+	cmd.Arg("id", "ID of the enrollment request").Required().StringVar(&c.id)
+	cmd.Flag("target", "Grant the new user access to manifests matching the rule (key1=value1,...,keyN=valueN)").StringVar(&c.target)
+	cmd.Flag("access", "Access the new user gets to the target").Default(acl.AccessLevelRead.String()).EnumVar(&c.level, acl.SupportedAccessLevels()...)
+
+	c.sf.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerEnrollmentApprove) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	req := &serverapi.ApproveEnrollmentRequest{}
+
+	if c.target != "" {
+		r := acl.TargetRule{}
+
+		for _, v := range strings.Split(c.target, ",") {
+			parts := strings.SplitN(v, "=", 2) //nolint:mnd
+			if len(parts) != 2 {               //nolint:mnd
+				return errors.Errorf("invalid target labels %q, must be key=value", v)
+			}
+
+			r[parts[0]] = parts[1]
+		}
+
+		al, err := acl.ParseAccessLevel(c.level)
+		if err != nil {
+			return errors.Wrap(err, "invalid access level")
+		}
+
+		req.ACL = append(req.ACL, &acl.Entry{Target: r, Access: al})
+	}
+
+	r, err := serverapi.ApproveEnrollment(ctx, cli, c.id, req)
+	if err != nil {
+		return errors.Wrap(err, "unable to approve enrollment request")
+	}
+
+	log(ctx).Infof("Approved enrollment of %v.", r.Username)
+
+	return nil
+}
+
+type commandServerEnrollmentReject struct {
+	sf serverClientFlags
+
+	id string
+}
+
+func (c *commandServerEnrollmentReject) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("reject", "Reject a pending enrollment request")
+	cmd.Arg("id", "ID of the enrollment request").Required().StringVar(&c.id)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerEnrollmentReject) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	r, err := serverapi.RejectEnrollment(ctx, cli, c.id)
+	if err != nil {
+		return errors.Wrap(err, "unable to reject enrollment request")
+	}
+
+	log(ctx).Infof("Rejected enrollment of %v.", r.Username)
+
+	return nil
+}
+
+type commandServerEnroll struct {
+	address         string
+	certFingerprint string
+	token           string
+	username        string
+	password        string
+	wait            bool
+	waitInterval    time.Duration
+
+	svc appServices
+}
+
+func (c *commandServerEnroll) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("enroll", "Request access to a server using a one-time enrollment token")
+	cmd.Flag("address", "Address of the server").Envar(svc.EnvName("KOPIA_SERVER_ADDRESS")).Required().StringVar(&c.address)
+	cmd.Flag("server-cert-fingerprint", "Server certificate fingerprint").PlaceHolder("SHA256-FINGERPRINT").Envar(svc.EnvName("KOPIA_SERVER_CERT_FINGERPRINT")).StringVar(&c.certFingerprint)
+	cmd.Flag("token", "Enrollment token").Envar(svc.EnvName("KOPIA_ENROLLMENT_TOKEN")).Required().StringVar(&c.token)
+	cmd.Flag("username", "Username to enroll as (user@hostname), defaults to the current user and hostname").StringVar(&c.username)
+	cmd.Flag("user-password", "Password the user will connect with").StringVar(&c.password)
+	cmd.Flag("wait", "Wait until the enrollment request is approved or rejected").BoolVar(&c.wait)
+	cmd.Flag("wait-interval", "Interval between checks of the enrollment status").Default("10s").DurationVar(&c.waitInterval)
+
+	c.svc = svc
+	cmd.Action(svc.noRepositoryAction(c.run))
+}
+
+func (c *commandServerEnroll) run(ctx context.Context) error {
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             strings.TrimSuffix(c.address, "/"),
+		TrustedServerCertificateFingerprint: strings.ToLower(c.certFingerprint),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to create API client")
+	}
+
+	username := c.username
+	if username == "" {
+		username = repo.GetDefaultUserName(ctx) + "@" + repo.GetDefaultHostName(ctx)
+	}
+
+	if err := user.ValidateUsername(username); err != nil {
+		return errors.Wrap(err, "invalid username")
+	}
+
+	password := c.password
+	if password == "" {
+		if password, err = askForChangedRepositoryPassword(c.svc.stdout()); err != nil {
+			return err
+		}
+	}
+
+	st, err := serverapi.Enroll(ctx, cli, &serverapi.EnrollRequest{
+		Token:    c.token,
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to enroll")
+	}
+
+	log(ctx).Infof("Enrollment of %v requested (ID %v), waiting for approval by the server administrator.", st.Username, st.ID)
+
+	if !c.wait {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c.svc.onTerminate(cancel)
+
+	for st.State == enrollment.StatePending {
+		select {
+		case <-ctx.Done():
+			return errors.New("interrupted while waiting for approval")
+
+		case <-time.After(c.waitInterval):
+		}
+
+		if st, err = serverapi.GetEnrollmentStatus(ctx, cli, st.ID); err != nil {
+			return errors.Wrap(err, "unable to get enrollment status")
+		}
+	}
+
+	if st.State != enrollment.StateApproved {
+		return errors.Errorf("enrollment request was %v", strings.ToLower(st.State))
+	}
+
+	log(ctx).Infof("Enrollment approved, connect using 'kopia repository connect server --url=%v'.", c.address)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerLeader struct {
+	sf serverClientFlags
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandServerLeader) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("leader", "Show leader election status of servers in high-availability mode")
+
+	c.sf.setup(svc, cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerLeader) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	st, err := serverapi.GetLeaderStatus(ctx, cli)
+	if err != nil {
+		return errors.Wrap(err, "unable to get leader status")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(st))
+		return nil
+	}
+
+	if !st.HighAvailability {
+		c.out.printStdout("High-availability mode is not enabled.\n")
+		return nil
+	}
+
+	c.out.printStdout("Instance: %v (leader: %v)\n", st.InstanceID, st.IsLeader)
+
+	if l := st.Leader; l != nil {
+		c.out.printStdout("Leader:   %v %v, lease expires %v\n", l.Holder, l.Address, formatTimestamp(l.Expires))
+	} else {
+		c.out.printStdout("Leader:   none\n")
+	}
+
+	for _, l := range st.Leases {
+		c.out.printStdout("Lease:    %v %v acquired %v, expires %v\n", l.Holder, l.Address, formatTimestamp(l.Acquired), formatTimestamp(l.Expires))
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerLogLevel struct {
+	get commandServerLogLevelGet
+	set commandServerLogLevelSet
+}
+
+func (c *commandServerLogLevel) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("log-level", "Control per-subsystem log levels of a running server")
+	c.get.setup(svc, cmd)
+	c.set.setup(svc, cmd)
+}
+
+type commandServerLogLevelGet struct {
+	sf serverClientFlags
+
+	out textOutput
+	jo  jsonOutput
+}
+
+func (c *commandServerLogLevelGet) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("get", "Get per-subsystem log levels of a running server")
+	c.sf.setup(svc, cmd)
+	c.out.setup(svc)
+	c.jo.setup(svc, cmd)
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerLogLevelGet) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	var resp serverapi.LogLevels
+
+	if err := cli.Get(ctx, "control/log-levels", nil, &resp); err != nil {
+		return errors.Wrap(err, "unable to get log levels")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(resp))
+		return nil
+	}
+
+	for _, sub := range resp.Subsystems {
+		lvl := resp.Overrides[sub]
+		if lvl == "" {
+			lvl = "(default)"
+		}
+
+		c.out.printStdout("%-10v %v\n", sub, lvl)
+	}
+
+	return nil
+}
+
+type commandServerLogLevelSet struct {
+	sf serverClientFlags
+
+	levels []string
+	reset  []string
+}
+
+func (c *commandServerLogLevelSet) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set", "Set per-subsystem log levels of a running server")
+	cmd.Arg("subsystem=level", "Subsystem log level override").StringsVar(&c.levels)
+	cmd.Flag("reset", "Remove log level override for the provided subsystem").StringsVar(&c.reset)
+	c.sf.setup(svc, cmd)
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerLogLevelSet) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	req := serverapi.LogLevels{
+		Overrides: map[string]string{},
+	}
+
+	for _, l := range c.levels {
+		sub, lvl, ok := strings.Cut(l, "=")
+		if !ok || lvl == "" {
+			return errors.Errorf("invalid log level %q, expected subsystem=level", l)
+		}
+
+		req.Overrides[sub] = lvl
+	}
+
+	for _, sub := range c.reset {
+		req.Overrides[sub] = ""
+	}
+
+	if len(req.Overrides) == 0 {
+		return errors.New("no log level changes specified")
+	}
+
+	var resp serverapi.LogLevels
+
+	if err := cli.Put(ctx, "control/log-levels", &req, &resp); err != nil {
+		return errors.Wrap(err, "unable to set log levels")
+	}
+
+	var changed []string
+
+	for sub, lvl := range resp.Overrides {
+		changed = append(changed, sub+"="+lvl)
+	}
+
+	sort.Strings(changed)
+
+	log(ctx).Infof("Current log level overrides: %v", strings.Join(changed, ", "))
+
+	return nil
+}
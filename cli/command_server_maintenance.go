@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerMaintenance struct {
+	info     commandServerMaintenanceInfo
+	set      commandServerMaintenanceSet
+	setOwner commandServerMaintenanceSetOwner
+	run      commandServerMaintenanceRun
+	cancel   commandServerMaintenanceCancel
+}
+
+func (c *commandServerMaintenance) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("maintenance", "Show and control maintenance of the repository a running server is connected to")
+	c.info.setup(svc, cmd)
+	c.set.setup(svc, cmd)
+	c.setOwner.setup(svc, cmd)
+	c.run.setup(svc, cmd)
+	c.cancel.setup(svc, cmd)
+}
+
+type commandServerMaintenanceInfo struct {
+	sf serverClientFlags
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandServerMaintenanceInfo) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("info", "Display maintenance parameters, schedule and status").Alias("status")
+
+	c.sf.setup(svc, cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerMaintenanceInfo) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	mi, err := serverapi.GetMaintenanceInfo(ctx, cli)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance info")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(mi))
+		return nil
+	}
+
+	c.out.printStdout("Owner: %v (server: %v)\n", mi.Params.Owner, mi.ServerUser)
+	c.out.printStdout("Quick Cycle: %v\n", cycleSummary(mi.Params.QuickCycle, mi.Schedule.NextQuickMaintenanceTime))
+	c.out.printStdout("Full Cycle:  %v\n", cycleSummary(mi.Params.FullCycle, mi.Schedule.NextFullMaintenanceTime))
+
+	if mi.NextRunTime != nil {
+		c.out.printStdout("Next run by server: %v\n", formatTimestamp(*mi.NextRunTime))
+	}
+
+	if t := mi.RunningTask; t != nil {
+		c.out.printStdout("Running: %v (task %v) %v\n", t.Description, t.TaskID, t.ProgressInfo)
+	}
+
+	return nil
+}
+
+func cycleSummary(cp maintenance.CycleParams, next time.Time) string {
+	if !cp.Enabled {
+		return "disabled"
+	}
+
+	return "every " + cp.Interval.String() + ", next " + formatTimestamp(next)
+}
+
+type commandServerMaintenanceSet struct {
+	sf serverClientFlags
+
+	enableQuick   []bool // optional boolean
+	enableFull    []bool // optional boolean
+	quickInterval time.Duration
+	fullInterval  time.Duration
+	pauseQuick    time.Duration
+	pauseFull     time.Duration
+}
+
+func (c *commandServerMaintenanceSet) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set", "Set maintenance parameters and schedule")
+
+	c.quickInterval = -1
+	c.fullInterval = -1
+	c.pauseQuick = -1
+	c.pauseFull = -1
+
+	cmd.Flag("enable-quick", "Enable or disable quick maintenance").BoolListVar(&c.enableQuick)
+	cmd.Flag("enable-full", "Enable or disable full maintenance").BoolListVar(&c.enableFull)
+	cmd.Flag("quick-interval", "Set quick maintenance interval").DurationVar(&c.quickInterval)
+	cmd.Flag("full-interval", "Set full maintenance interval").DurationVar(&c.fullInterval)
+	cmd.Flag("pause-quick", "Pause quick maintenance for a specified duration").DurationVar(&c.pauseQuick)
+	cmd.Flag("pause-full", "Pause full maintenance for a specified duration").DurationVar(&c.pauseFull)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerMaintenanceSet) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	mi, err := serverapi.GetMaintenanceInfo(ctx, cli)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance info")
+	}
+
+	req := &serverapi.SetMaintenanceRequest{
+		QuickCycle: updatedCycleParams(mi.Params.QuickCycle, c.enableQuick, c.quickInterval),
+		FullCycle:  updatedCycleParams(mi.Params.FullCycle, c.enableFull, c.fullInterval),
+	}
+
+	if c.pauseQuick != -1 {
+		t := clock.Now().Add(c.pauseQuick)
+		req.NextQuickMaintenanceTime = &t
+	}
+
+	if c.pauseFull != -1 {
+		t := clock.Now().Add(c.pauseFull)
+		req.NextFullMaintenanceTime = &t
+	}
+
+	if req.QuickCycle == nil && req.FullCycle == nil && req.NextQuickMaintenanceTime == nil && req.NextFullMaintenanceTime == nil {
+		return errors.New("no changes specified")
+	}
+
+	_, err = serverapi.SetMaintenance(ctx, cli, req)
+
+	return errors.Wrap(err, "unable to set maintenance parameters")
+}
+
+// updatedCycleParams returns cycle parameters updated with values of the flags or nil if they have not been set.
+func updatedCycleParams(cp maintenance.CycleParams, enable []bool, interval time.Duration) *maintenance.CycleParams {
+	if len(enable) == 0 && interval == -1 {
+		return nil
+	}
+
+	if len(enable) > 0 {
+		cp.Enabled = enable[len(enable)-1]
+	}
+
+	if interval != -1 {
+		cp.Interval = interval
+	}
+
+	return &cp
+}
+
+type commandServerMaintenanceSetOwner struct {
+	sf serverClientFlags
+
+	owner string
+}
+
+func (c *commandServerMaintenanceSetOwner) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set-owner", "Transfer maintenance ownership to another user")
+	cmd.Arg("owner", "New maintenance owner user@hostname, 'me' transfers ownership to the server").Default("me").StringVar(&c.owner)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerMaintenanceSetOwner) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	mi, err := serverapi.SetMaintenanceOwner(ctx, cli, c.owner)
+	if err != nil {
+		return errors.Wrap(err, "unable to set maintenance owner")
+	}
+
+	log(ctx).Infof("Maintenance owner is now %v.", mi.Params.Owner)
+
+	return nil
+}
+
+type commandServerMaintenanceRun struct {
+	sf serverClientFlags
+
+	mode  string
+	force bool
+	wait  bool
+}
+
+func (c *commandServerMaintenanceRun) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("run", "Run maintenance on the server")
+	cmd.Flag("mode", "Maintenance mode").Default(string(maintenance.ModeAuto)).EnumVar(&c.mode, string(maintenance.ModeAuto), string(maintenance.ModeQuick), string(maintenance.ModeFull))
+	cmd.Flag("force", "Run maintenance even if the server is not the maintenance owner").BoolVar(&c.force)
+	cmd.Flag("wait", "Wait for maintenance to finish").BoolVar(&c.wait)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerMaintenanceRun) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	t, err := serverapi.RunMaintenance(ctx, cli, &serverapi.RunMaintenanceRequest{
+		Mode:  c.mode,
+		Force: c.force,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to run maintenance")
+	}
+
+	log(ctx).Infof("Started maintenance task %v.", t.TaskID)
+
+	if !c.wait {
+		return nil
+	}
+
+	lastProgress := ""
+
+	for {
+		mi, err := serverapi.GetMaintenanceInfo(ctx, cli)
+		if err != nil {
+			return errors.Wrap(err, "unable to get maintenance info")
+		}
+
+		if mi.RunningTask == nil || mi.RunningTask.TaskID != t.TaskID {
+			log(ctx).Info("Maintenance finished.")
+			return nil
+		}
+
+		if p := mi.RunningTask.ProgressInfo; p != lastProgress {
+			log(ctx).Info(p)
+			lastProgress = p
+		}
+
+		if !clock.SleepInterruptibly(ctx, time.Second) {
+			return ctx.Err()
+		}
+	}
+}
+
+type commandServerMaintenanceCancel struct {
+	sf serverClientFlags
+}
+
+func (c *commandServerMaintenanceCancel) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("cancel", "Cancel maintenance running on the server")
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerMaintenanceCancel) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	return errors.Wrap(serverapi.CancelMaintenance(ctx, cli), "unable to cancel maintenance")
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerQuota struct {
+	list  commandServerQuotaList
+	reset commandServerQuotaReset
+}
+
+func (c *commandServerQuota) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("quota", "Show and reset per-user quota usage of a running server")
+	c.list.setup(svc, cmd)
+	c.reset.setup(svc, cmd)
+}
+
+type commandServerQuotaList struct {
+	sf serverClientFlags
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandServerQuotaList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List quotas and usage of users").Alias("ls")
+
+	c.sf.setup(svc, cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerQuotaList) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	resp, err := serverapi.ListQuotaUsage(ctx, cli)
+	if err != nil {
+		return errors.Wrap(err, "unable to list quota usage")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(resp))
+		return nil
+	}
+
+	for _, u := range resp.Users {
+		c.out.printStdout("%v storage:%v/%v requests:%v (%v/s) throttled:%v rejected-writes:%v\n",
+			u.Username,
+			units.BytesString(u.StorageBytes), quotaLimitString(float64(u.MaxStorageBytes), units.BytesString(u.MaxStorageBytes)),
+			u.Requests, quotaLimitString(u.MaxRequestsPerSecond, u.MaxRequestsPerSecond),
+			u.ThrottledRequests, u.RejectedWrites)
+	}
+
+	return nil
+}
+
+func quotaLimitString(limit float64, formatted interface{}) interface{} {
+	if limit <= 0 {
+		return "unlimited"
+	}
+
+	return formatted
+}
+
+type commandServerQuotaReset struct {
+	sf serverClientFlags
+
+	username string
+}
+
+func (c *commandServerQuotaReset) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("reset", "Reset usage counters of a user")
+	cmd.Arg("username", "The user@hostname to reset usage for").Required().StringVar(&c.username)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerQuotaReset) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	return errors.Wrap(serverapi.ResetQuotaUsage(ctx, cli, c.username), "unable to reset quota usage")
+}
@@ -3,8 +3,8 @@
 import (
 	"context"
 
-	"github.com/kopia/kopia/internal/apiclient"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/serverapi"
 )
 
 type commandServerRefresh struct {
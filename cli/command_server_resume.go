@@ -3,7 +3,7 @@
 import (
 	"context"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 )
 
 type commandServerResume struct {
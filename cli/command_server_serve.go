@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"net"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/cachefs"
+	"github.com/kopia/kopia/fs/loggingfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// serveSnapshotsFlags are flags common to commands exposing the snapshot tree over a network protocol.
+type serveSnapshotsFlags struct {
+	objectID             string
+	listenAddress        string
+	traceFS              bool
+	maxCachedEntries     int
+	maxCachedDirectories int
+	insecure             bool
+}
+
+func (c *serveSnapshotsFlags) setup(cmd *kingpin.CmdClause, defaultAddress string) {
+	cmd.Arg("path", "Identifier of the directory to serve ('all' for all sources, 'all-snapshots' for all snapshots organized as <source>/<date>/<time>).").Default("all").StringVar(&c.objectID)
+	cmd.Flag("address", "Address to listen on").Default(defaultAddress).StringVar(&c.listenAddress)
+	cmd.Flag("trace-fs", "Trace filesystem operations").BoolVar(&c.traceFS)
+	cmd.Flag("max-cached-entries", "Limit the number of cached directory entries").Default("100000").IntVar(&c.maxCachedEntries)
+	cmd.Flag("max-cached-dirs", "Limit the number of cached directories").Default("100").IntVar(&c.maxCachedDirectories)
+	cmd.Flag("insecure", "Allow serving snapshots without authentication on addresses reachable from other hosts").BoolVar(&c.insecure)
+}
+
+// rootDirectory returns the directory to be served, wrapped in a cache.
+func (c *serveSnapshotsFlags) rootDirectory(ctx context.Context, rep repo.Repository) (fs.Directory, error) {
+	var entry fs.Directory
+
+	switch c.objectID {
+	case "all":
+		entry = snapshotfs.AllSourcesEntry(rep)
+
+	case "all-snapshots":
+		entry = snapshotfs.AllSnapshotsEntry(rep)
+
+	default:
+		var err error
+
+		entry, err = snapshotfs.FilesystemDirectoryFromIDWithPath(ctx, rep, c.objectID, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get directory entry for %v", c.objectID)
+		}
+	}
+
+	if c.traceFS {
+		//nolint:forcetypeassert
+		entry = loggingfs.Wrap(entry, log(ctx).Debugf).(fs.Directory)
+	}
+
+	//nolint:forcetypeassert
+	return cachefs.Wrap(entry, cachefs.NewCache(&cachefs.Options{
+		MaxCachedDirectories: c.maxCachedDirectories,
+		MaxCachedEntries:     c.maxCachedEntries,
+	})).(fs.Directory), nil
+}
+
+// listen starts listening on the configured address. Unless clients are authenticated, addresses reachable
+// from other hosts are refused without --insecure, since anyone who can reach them could read the snapshots.
+func (c *serveSnapshotsFlags) listen(ctx context.Context, authenticated bool) (net.Listener, error) {
+	l, err := net.Listen("tcp", c.listenAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "listen error")
+	}
+
+	if a, ok := l.Addr().(*net.TCPAddr); ok && !a.IP.IsLoopback() && !authenticated {
+		if !c.insecure {
+			l.Close() //nolint:errcheck
+
+			return nil, errors.Errorf("refusing to serve snapshots without authentication on %v, pass --insecure to allow it", a)
+		}
+
+		log(ctx).Warnf("Snapshots are served without authentication on %v and can be read by anyone who can reach this address.", a)
+	}
+
+	return l, nil
+}
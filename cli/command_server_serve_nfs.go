@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/nfsserver"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandServerServeNFS struct {
+	sf serveSnapshotsFlags
+
+	svc appServices
+}
+
+func (c *commandServerServeNFS) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("serve-nfs", "Serve snapshots read-only over NFSv3.")
+	c.sf.setup(cmd, "127.0.0.1:2049")
+	c.svc = svc
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandServerServeNFS) run(ctx context.Context, rep repo.Repository) error {
+	entry, err := c.sf.rootDirectory(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	l, err := c.sf.listen(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	srv := nfsserver.New(entry)
+	defer srv.Close()
+
+	c.svc.onTerminate(func() {
+		l.Close() //nolint:errcheck
+	})
+
+	log(ctx).Infof("Serving '%v' over NFSv3 at %v", c.sf.objectID, l.Addr())
+	log(ctx).Info("The server does not register with portmapper, mount with: -o vers=3,proto=tcp,nolock,port=<port>,mountport=<port>")
+	log(ctx).Info("Press Ctrl-C to stop.")
+
+	return errors.Wrap(srv.Serve(ctx, l), "error serving NFS")
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/s3gateway"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandServerServeS3 struct {
+	sf     serveSnapshotsFlags
+	bucket string
+
+	svc appServices
+}
+
+func (c *commandServerServeS3) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("serve-s3", "Serve snapshots as a read-only S3-compatible bucket (ListObjectsV2/GetObject).")
+	c.sf.setup(cmd, "127.0.0.1:51590")
+	cmd.Flag("bucket", "Name of the bucket").Default("kopia").StringVar(&c.bucket)
+	c.svc = svc
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandServerServeS3) run(ctx context.Context, rep repo.Repository) error {
+	entry, err := c.sf.rootDirectory(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	l, err := c.sf.listen(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		ReadHeaderTimeout: 15 * time.Second, //nolint:mnd
+		Handler:           s3gateway.New(entry, c.bucket),
+	}
+
+	c.svc.onTerminate(func() {
+		shutdownHTTPServer(ctx, srv)
+	})
+
+	log(ctx).Infof("Serving '%v' as bucket '%v' at http://%v", c.sf.objectID, c.bucket, l.Addr())
+	log(ctx).Info("Clients must use path-style requests and may use any credentials.")
+	log(ctx).Info("Press Ctrl-C to stop.")
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "error serving S3")
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServeSnapshotsRequiresAuthenticationOffLoopback(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	for _, cmd := range []string{"serve-s3", "serve-webdav", "serve-nfs"} {
+		_, stderr := env.RunAndExpectFailure(t, "server", cmd, "--address=0.0.0.0:0")
+		require.Contains(t, strings.Join(stderr, "\n"), "pass --insecure to allow it")
+	}
+
+	env.RunAndExpectFailure(t, "server", "serve-webdav", "--address=0.0.0.0:0", "--webdav-username=user")
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/webdav"
+
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/webdavmount"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandServerServeWebDAV struct {
+	sf       serveSnapshotsFlags
+	username string
+	password string
+
+	svc appServices
+}
+
+func (c *commandServerServeWebDAV) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("serve-webdav", "Serve snapshots read-only over WebDAV.")
+	c.sf.setup(cmd, "127.0.0.1:51580")
+	cmd.Flag("webdav-username", "Username required from clients (HTTP basic auth)").Envar(svc.EnvName("KOPIA_WEBDAV_USERNAME")).StringVar(&c.username)
+	cmd.Flag("webdav-password", "Password required from clients (HTTP basic auth)").PlaceHolder("PASSWORD").Envar(svc.EnvName("KOPIA_WEBDAV_PASSWORD")).StringVar(&c.password)
+	c.svc = svc
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandServerServeWebDAV) run(ctx context.Context, rep repo.Repository) error {
+	entry, err := c.sf.rootDirectory(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if (c.username == "") != (c.password == "") {
+		return errors.New("--webdav-username and --webdav-password must be specified together")
+	}
+
+	l, err := c.sf.listen(ctx, c.password != "")
+	if err != nil {
+		return err
+	}
+
+	var handler http.Handler = &webdav.Handler{
+		FileSystem: webdavmount.WebDAVFS(entry),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	if c.password != "" {
+		handler = requireBasicAuth(rep, auth.AuthenticateSingleUser(c.username, c.password), handler)
+	}
+
+	srv := &http.Server{
+		ReadHeaderTimeout: 15 * time.Second, //nolint:mnd
+		Handler:           handler,
+	}
+
+	c.svc.onTerminate(func() {
+		shutdownHTTPServer(ctx, srv)
+	})
+
+	log(ctx).Infof("Serving '%v' over WebDAV at http://%v", c.sf.objectID, l.Addr())
+	log(ctx).Info("Press Ctrl-C to stop.")
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "error serving WebDAV")
+	}
+
+	return nil
+}
+
+// requireBasicAuth returns a handler which only passes requests with valid HTTP basic authentication credentials.
+func requireBasicAuth(rep repo.Repository, authn auth.Authenticator, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || !authn.IsValid(r.Context(), rep, username, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Kopia"`)
+			http.Error(w, "access denied", http.StatusUnauthorized)
+
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/auth"
+)
+
+func TestRequireBasicAuth(t *testing.T) {
+	h := requireBasicAuth(nil, auth.AuthenticateSingleUser("user", "secret"), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tc := range []struct {
+		username, password string
+		want               int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"user", "wrong", http.StatusUnauthorized},
+		{"other", "secret", http.StatusUnauthorized},
+		{"user", "secret", http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		if tc.username != "" {
+			req.SetBasicAuth(tc.username, tc.password)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, tc.want, rec.Code, "%v/%v", tc.username, tc.password)
+	}
+}
@@ -8,8 +8,8 @@
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/apiclient"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/serverapi"
 )
 
 // commandServerSourceManagerAction encapsulates common logic for all commands
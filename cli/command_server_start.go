@@ -11,6 +11,7 @@
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,10 +21,14 @@
 	htpasswd "github.com/tg123/go-htpasswd"
 
 	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/replication"
 	"github.com/kopia/kopia/internal/server"
+	"github.com/kopia/kopia/internal/user"
 	"github.com/kopia/kopia/notification"
 	"github.com/kopia/kopia/notification/sender/jsonsender"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 const (
@@ -39,11 +44,29 @@ type commandServerStart struct {
 	serverStartUI         bool
 	serverStartGRPC       bool
 	serverStartControlAPI bool
+	enableEnrollment      bool
 
 	serverStartRefreshInterval time.Duration
 	serverStartInsecure        bool
 	serverStartMaxConcurrency  int
 
+	userMaxRequestsPerSecond float64
+	userStorageQuotaMB       int64
+	quotaUsageFile           string
+	enableAuditLog           bool
+
+	highAvailability   bool
+	haInstanceID       string
+	haAdvertiseAddress string
+	haLeaseDuration    time.Duration
+
+	verifyBytesPerHour int64
+	verifyStateFile    string
+
+	replicateTo          string
+	replicationStateFile string
+	replicationInterval  time.Duration
+
 	serverStartWithoutPassword bool
 	serverStartRandomPassword  bool
 	serverStartHtpasswdFile    string
@@ -67,6 +90,10 @@ type commandServerStart struct {
 	uiPreferencesFile                   string
 	asyncRepoConnect                    bool
 	persistentLogs                      bool
+	taskHistory                         bool
+	taskHistoryDir                      string
+	taskHistoryMaxCount                 int
+	taskHistoryMaxAge                   time.Duration
 	debugScheduler                      bool
 	minMaintenanceInterval              time.Duration
 
@@ -75,8 +102,17 @@ type commandServerStart struct {
 
 	logServerRequests bool
 
+	metricsEndpoint bool
+
+	maxDecodedDirEntries int
+
 	disableCSRFTokenChecks bool // disable CSRF token checks - used for development/debugging only
 
+	hostedRepositories map[string]string
+
+	oidc serverOIDCFlags
+	acme serverACMEFlags
+
 	sf  serverFlags
 	svc advancedAppServices
 	out textOutput
@@ -89,10 +125,27 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 
 	cmd.Flag("grpc", "Start the GRPC server").Default("true").BoolVar(&c.serverStartGRPC)
 	cmd.Flag("control-api", "Start the control API").Default("true").BoolVar(&c.serverStartControlAPI)
+	cmd.Flag("enable-enrollment", "Allow clients holding a one-time enrollment token to request access, pending approval").BoolVar(&c.enableEnrollment)
 
 	cmd.Flag("refresh-interval", "Frequency for refreshing repository status").Default("4h").DurationVar(&c.serverStartRefreshInterval)
 	cmd.Flag("insecure", "Allow insecure configurations (do not use in production)").Hidden().BoolVar(&c.serverStartInsecure)
 	cmd.Flag("max-concurrency", "Maximum number of server goroutines").Default("0").IntVar(&c.serverStartMaxConcurrency)
+	cmd.Flag("user-max-requests-per-second", "Default maximum rate of repository requests per user, requests above the rate are throttled (0 == unlimited)").Default("0").Float64Var(&c.userMaxRequestsPerSecond)
+	cmd.Flag("user-storage-quota-mb", "Default maximum amount of new data each user can write to the repository, writes above the quota are rejected (0 == unlimited). Usage counts uncompressed contents not already in the repository and is not reduced when snapshots are deleted").PlaceHolder("MB").Default("0").Int64Var(&c.userStorageQuotaMB)
+	cmd.Flag("quota-usage-file", "Path to JSON file storing per-user quota usage").StringVar(&c.quotaUsageFile)
+	cmd.Flag("audit-log", "Record connections, restores, deletions and policy/ACL changes in the repository audit log").BoolVar(&c.enableAuditLog)
+
+	cmd.Flag("high-availability", "Run as one of multiple servers connected to the same repository with the same username@hostname, only the elected leader runs scheduled snapshots and maintenance").BoolVar(&c.highAvailability)
+	cmd.Flag("ha-instance-id", "Unique ID of this server instance in high-availability mode (default: hostname with random suffix)").StringVar(&c.haInstanceID)
+	cmd.Flag("ha-advertise-address", "Address of this server instance advertised to other instances (default: server address)").StringVar(&c.haAdvertiseAddress)
+	cmd.Flag("ha-lease-duration", "Duration of the leader lease, other instances take over when the leader fails to renew it").Default("1m").DurationVar(&c.haLeaseDuration)
+
+	cmd.Flag("verify-bytes-per-hour", "Continuously verify repository contents in the background at the provided rate (0 == disabled)").Default("0").Int64Var(&c.verifyBytesPerHour)
+	cmd.Flag("verify-state-file", "Path to JSON file storing the background verification state").StringVar(&c.verifyStateFile)
+
+	cmd.Flag("replicate-to", "Continuously replicate repository blobs to storage of the repository with the provided configuration file").ExistingFileVar(&c.replicateTo)
+	cmd.Flag("replication-state-file", "Path to JSON file storing the replication state").StringVar(&c.replicationStateFile)
+	cmd.Flag("replication-interval", "Interval between replication cycles").Default(replication.DefaultInterval.String()).DurationVar(&c.replicationInterval)
 
 	cmd.Flag("without-password", "Start the server without a password").Hidden().BoolVar(&c.serverStartWithoutPassword)
 	cmd.Flag("random-password", "Generate random password and print to stderr").Hidden().BoolVar(&c.serverStartRandomPassword)
@@ -118,9 +171,16 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 
 	cmd.Flag("async-repo-connect", "Connect to repository asynchronously").Hidden().BoolVar(&c.asyncRepoConnect)
 	cmd.Flag("persistent-logs", "Persist logs in a file").Default("true").BoolVar(&c.persistentLogs)
+	cmd.Flag("task-history", "Persist history of finished tasks and their logs").Default("true").BoolVar(&c.taskHistory)
+	cmd.Flag("task-history-dir", "Path to directory storing history of finished tasks").StringVar(&c.taskHistoryDir)
+	cmd.Flag("task-history-max-count", "Maximum number of finished tasks to keep in history (0 == unlimited)").Default("1000").IntVar(&c.taskHistoryMaxCount)
+	cmd.Flag("task-history-max-age", "Maximum age of finished tasks to keep in history (0 == unlimited)").Default("720h").DurationVar(&c.taskHistoryMaxAge)
 	cmd.Flag("ui-title-prefix", "UI title prefix").Hidden().Envar(svc.EnvName("KOPIA_UI_TITLE_PREFIX")).StringVar(&c.uiTitlePrefix)
 	cmd.Flag("ui-preferences-file", "Path to JSON file storing UI preferences").StringVar(&c.uiPreferencesFile)
 
+	cmd.Flag("metrics-endpoint", "Expose Prometheus metrics at /metrics without authentication").Default("true").BoolVar(&c.metricsEndpoint)
+	cmd.Flag("max-decoded-dir-entries", "Limit the number of decoded directory entries cached for browsing snapshots (0 to disable)").Default(strconv.Itoa(snapshotfs.DefaultDirectoryCacheEntries)).IntVar(&c.maxDecodedDirEntries)
+
 	cmd.Flag("log-server-requests", "Log server requests").Hidden().BoolVar(&c.logServerRequests)
 	cmd.Flag("disable-csrf-token-checks", "Disable CSRF token").Hidden().BoolVar(&c.disableCSRFTokenChecks)
 
@@ -128,6 +188,11 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 
 	cmd.Flag("kopiaui-notifications", "Enable notifications to be printed to stdout for KopiaUI").BoolVar(&c.kopiauiNotifications)
 
+	c.hostedRepositories = map[string]string{}
+	cmd.Flag("hosted-repository", "Additionally serve repository connected using the provided config file under the given name (NAME=CONFIG_FILE)").PlaceHolder("NAME=CONFIG_FILE").StringMapVar(&c.hostedRepositories)
+
+	c.oidc.setup(svc, cmd)
+	c.acme.setup(cmd)
 	c.sf.setup(svc, cmd)
 	c.co.setup(svc, cmd)
 	c.svc = svc
@@ -137,7 +202,12 @@ func (c *commandServerStart) setup(svc advancedAppServices, parent commandParent
 }
 
 func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Options, error) {
-	authn, err := c.getAuthenticator(ctx)
+	oidcProvider, err := c.oidc.provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authn, err := c.getAuthenticator(ctx, oidcProvider)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to initialize authentication")
 	}
@@ -147,6 +217,40 @@ func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Op
 		uiPreferencesFile = filepath.Join(filepath.Dir(c.svc.repositoryConfigFileName()), "ui-preferences.json")
 	}
 
+	quotaUsageFile := c.quotaUsageFile
+	if quotaUsageFile == "" {
+		quotaUsageFile = filepath.Join(filepath.Dir(c.svc.repositoryConfigFileName()), "quota-usage.json")
+	}
+
+	haAdvertiseAddress := c.haAdvertiseAddress
+	if haAdvertiseAddress == "" {
+		haAdvertiseAddress = c.sf.serverAddress
+	}
+
+	taskHistoryDir := c.taskHistoryDir
+	if taskHistoryDir == "" && c.taskHistory {
+		taskHistoryDir = filepath.Join(filepath.Dir(c.svc.repositoryConfigFileName()), "task-history")
+	}
+
+	verifyStateFile := c.verifyStateFile
+	if verifyStateFile == "" {
+		verifyStateFile = c.svc.repositoryConfigFileName() + ".verify"
+	}
+
+	var replicateTo *blob.ConnectionInfo
+
+	if c.replicateTo != "" {
+		replicateTo, err = loadReplicationDestination(c.replicateTo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replicationStateFile := c.replicationStateFile
+	if replicationStateFile == "" {
+		replicationStateFile = c.svc.repositoryConfigFileName() + ".replication"
+	}
+
 	return &server.Options{
 		ConfigFile:           c.svc.repositoryConfigFileName(),
 		ConnectOptions:       c.co.toRepoConnectOptions(),
@@ -154,6 +258,7 @@ func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Op
 		MaxConcurrency:       c.serverStartMaxConcurrency,
 		Authenticator:        authn,
 		Authorizer:           auth.DefaultAuthorizer(),
+		OIDCProvider:         oidcProvider,
 		AuthCookieSigningKey: c.serverAuthCookieSingingKey,
 		UIUser:               c.sf.serverUsername,
 		ServerControlUser:    c.serverControlUsername,
@@ -162,6 +267,9 @@ func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Op
 		UIPreferencesFile:    uiPreferencesFile,
 		UITitlePrefix:        c.uiTitlePrefix,
 		PersistentLogs:       c.persistentLogs,
+		TaskHistoryDir:       taskHistoryDir,
+		TaskHistoryMaxCount:  c.taskHistoryMaxCount,
+		TaskHistoryMaxAge:    c.taskHistoryMaxAge,
 
 		DebugScheduler:         c.debugScheduler,
 		MinMaintenanceInterval: c.minMaintenanceInterval,
@@ -169,6 +277,26 @@ func (c *commandServerStart) serverStartOptions(ctx context.Context) (*server.Op
 
 		EnableErrorNotifications: c.svc.enableErrorNotifications(),
 		NotifyTemplateOptions:    c.svc.notificationTemplateOptions(),
+		ModuleLogLevels:          c.svc.getModuleLogLevels(),
+
+		DefaultUserQuota: user.Quota{
+			MaxRequestsPerSecond: c.userMaxRequestsPerSecond,
+			MaxStorageBytes:      c.userStorageQuotaMB << 20, //nolint:mnd
+		},
+		QuotaUsageFile: quotaUsageFile,
+		EnableAuditLog: c.enableAuditLog,
+
+		HighAvailability: c.highAvailability,
+		InstanceID:       c.haInstanceID,
+		AdvertiseAddress: haAdvertiseAddress,
+		LeaseDuration:    c.haLeaseDuration,
+
+		VerifyBytesPerHour: c.verifyBytesPerHour,
+		VerifyStateFile:    verifyStateFile,
+
+		ReplicateTo:          replicateTo,
+		ReplicationStateFile: replicationStateFile,
+		ReplicationInterval:  c.replicationInterval,
 	}, nil
 }
 
@@ -195,6 +323,8 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 		return err
 	}
 
+	snapshotfs.EnableDirectoryCache(c.maxDecodedDirEntries)
+
 	srv, err := server.New(ctx, opts)
 	if err != nil {
 		return errors.Wrap(err, "unable to initialize server")
@@ -211,6 +341,16 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 		}
 	}()
 
+	hosted, err := c.startHostedRepositories(ctx, opts)
+
+	defer func() {
+		reterr = stderrors.Join(reterr, closeHostedRepositories(ctx, hosted))
+	}()
+
+	if err != nil {
+		return err
+	}
+
 	httpServer := &http.Server{
 		ReadHeaderTimeout: 15 * time.Second, //nolint:mnd
 		Addr:              stripProtocol(c.sf.serverAddress),
@@ -219,7 +359,7 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 		},
 	}
 
-	srv.OnShutdown = func(ctx context.Context) error {
+	onShutdown := func(ctx context.Context) error {
 		ctx2, cancel := context.WithTimeout(ctx, c.shutdownGracePeriod)
 		defer cancel()
 
@@ -237,6 +377,12 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 		return nil
 	}
 
+	srv.OnShutdown = onShutdown
+
+	for _, h := range hosted {
+		h.srv.OnShutdown = onShutdown
+	}
+
 	c.svc.onTerminate(func() {
 		shutdownHTTPServer(ctx, httpServer)
 	})
@@ -251,9 +397,11 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 
 	c.setupHandlers(srv, m)
 
-	// init prometheus after adding interceptors that require credentials, so that this
-	// handler can be called without auth
-	initPrometheus(m)
+	if c.metricsEndpoint {
+		// init prometheus after adding interceptors that require credentials, so that this
+		// handler can be called without auth
+		initPrometheus(m)
+	}
 
 	var handler http.Handler = m
 
@@ -261,6 +409,10 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 		handler = srv.GRPCRouterHandler(handler)
 	}
 
+	if len(hosted) > 0 {
+		handler = server.MultiRepositoryHandler(handler, c.hostedRepositoryHandlers(hosted))
+	}
+
 	httpServer.Handler = handler
 
 	if c.serverStartShutdownWhenStdinClosed {
@@ -276,6 +428,10 @@ func (c *commandServerStart) run(ctx context.Context) (reterr error) {
 
 	onExternalConfigReloadRequest(srv.Refresh)
 
+	for _, h := range hosted {
+		onExternalConfigReloadRequest(h.srv.Refresh)
+	}
+
 	// enable notification to be printed to stderr where KopiaUI will pick it up
 	if c.kopiauiNotifications {
 		notification.AdditionalSenders = append(notification.AdditionalSenders,
@@ -301,6 +457,10 @@ func (c *commandServerStart) setupHandlers(srv *server.Server, m *mux.Router) {
 		srv.SetupControlAPIHandlers(m)
 	}
 
+	if c.enableEnrollment {
+		srv.SetupEnrollmentAPIHandlers(m)
+	}
+
 	if c.serverStartUI {
 		srv.SetupHTMLUIAPIHandlers(m)
 
@@ -320,7 +480,7 @@ func stripProtocol(addr string) string {
 	return strings.TrimPrefix(strings.TrimPrefix(addr, "https://"), "http://")
 }
 
-func (c *commandServerStart) getAuthenticator(ctx context.Context) (auth.Authenticator, error) {
+func (c *commandServerStart) getAuthenticator(ctx context.Context, oidcProvider *auth.OIDCProvider) (auth.Authenticator, error) {
 	var authenticators []auth.Authenticator
 
 	// handle passwords (UI and remote) from htpasswd file.
@@ -381,6 +541,13 @@ func (c *commandServerStart) getAuthenticator(ctx context.Context) (auth.Authent
 User accounts can be added using 'kopia server user add'.
 `)
 
+	// handle ID tokens issued by OpenID Connect provider
+	if oidcProvider != nil {
+		log(ctx).Infof("Server will allow users authenticated by OpenID Connect provider %v.", c.oidc.issuerURL)
+
+		authenticators = append(authenticators, oidcProvider)
+	}
+
 	// handle user accounts stored in the repository
 	authenticators = append(authenticators, auth.AuthenticateRepositoryUsers())
 
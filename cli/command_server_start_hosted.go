@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/server"
+	"github.com/kopia/kopia/repo"
+)
+
+// hostedRepository is an additional repository served by the same server process.
+type hostedRepository struct {
+	name string
+	srv  *server.Server
+}
+
+// startHostedRepositories creates servers for all repositories specified using --hosted-repository
+// and opens their repositories. Each hosted repository uses its own configuration file, storage,
+// cache and repository users.
+func (c *commandServerStart) startHostedRepositories(ctx context.Context, opts *server.Options) ([]hostedRepository, error) {
+	var names []string
+
+	for name := range c.hostedRepositories {
+		if !server.ValidHostedRepositoryName(name) {
+			return nil, errors.Errorf("invalid hosted repository name: %q", name)
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var result []hostedRepository
+
+	for _, name := range names {
+		configFile, err := filepath.Abs(c.hostedRepositories[name])
+		if err != nil {
+			return result, errors.Wrapf(err, "invalid configuration file for hosted repository %q", name)
+		}
+
+		o := *opts
+		o.ConfigFile = configFile
+		o.UIPreferencesFile = filepath.Join(filepath.Dir(configFile), name+"-ui-preferences.json")
+
+		if o.TaskHistoryDir != "" {
+			o.TaskHistoryDir = filepath.Join(filepath.Dir(configFile), name+"-task-history")
+		}
+
+		srv, err := server.New(ctx, &o)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to initialize server for hosted repository %q", name)
+		}
+
+		result = append(result, hostedRepository{name, srv})
+
+		initialize := func(ctx context.Context) (repo.Repository, error) {
+			pass, err := c.svc.passwordPersistenceStrategy().GetPassword(ctx, configFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to get persisted password")
+			}
+
+			r, err := repo.Open(ctx, configFile, pass, c.svc.optionsFromFlags(ctx))
+
+			return r, errors.Wrap(err, "unable to open repository")
+		}
+
+		if c.asyncRepoConnect {
+			initialize = server.RetryInitRepository(initialize)
+		}
+
+		if _, err := srv.InitRepositoryAsync(ctx, "Open", initialize, !c.asyncRepoConnect); err != nil {
+			return result, errors.Wrapf(err, "unable to initialize hosted repository %q", name)
+		}
+
+		log(ctx).Infof("Serving hosted repository %q from %v", name, configFile)
+	}
+
+	return result, nil
+}
+
+// closeHostedRepositories disconnects all hosted repositories.
+func closeHostedRepositories(ctx context.Context, hosted []hostedRepository) error {
+	var errs []error
+
+	for _, h := range hosted {
+		if err := h.srv.SetRepository(ctx, nil); err != nil {
+			errs = append(errs, errors.Wrapf(err, "error disconnecting hosted repository %q", h.name))
+		}
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// hostedRepositoryHandlers returns HTTP handlers for all hosted repositories.
+func (c *commandServerStart) hostedRepositoryHandlers(hosted []hostedRepository) map[string]http.Handler {
+	handlers := map[string]http.Handler{}
+
+	for _, h := range hosted {
+		m := mux.NewRouter()
+
+		if c.serverStartControlAPI {
+			h.srv.SetupControlAPIHandlers(m)
+		}
+
+		if c.serverStartUI {
+			// only API handlers, the UI itself is served for the default repository.
+			h.srv.SetupHTMLUIAPIHandlers(m)
+		}
+
+		var handler http.Handler = m
+
+		if c.serverStartGRPC {
+			handler = h.srv.GRPCRouterHandler(handler)
+		}
+
+		handlers[h.name] = handler
+	}
+
+	return handlers
+}
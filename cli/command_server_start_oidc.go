@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/server"
+)
+
+// serverOIDCFlags configures authentication using an OpenID Connect provider.
+type serverOIDCFlags struct {
+	issuerURL     string
+	clientID      string
+	clientSecret  string
+	serverURL     string
+	usernameClaim string
+	groupsClaim   string
+	groupUsers    map[string]string
+}
+
+func (c *serverOIDCFlags) setup(svc appServices, cmd *kingpin.CmdClause) {
+	cmd.Flag("oidc-issuer", "URL of the OpenID Connect provider used to authenticate users").StringVar(&c.issuerURL)
+	cmd.Flag("oidc-client-id", "OpenID Connect client ID").StringVar(&c.clientID)
+	cmd.Flag("oidc-client-secret", "OpenID Connect client secret").Envar(svc.EnvName("KOPIA_OIDC_CLIENT_SECRET")).StringVar(&c.clientSecret)
+	cmd.Flag("oidc-server-url", "Externally-visible URL of the server used to build the OpenID Connect redirect URL").StringVar(&c.serverURL)
+	cmd.Flag("oidc-username-claim", "ID token claim holding the username").Default("preferred_username").StringVar(&c.usernameClaim)
+	cmd.Flag("oidc-groups-claim", "ID token claim holding the list of groups").Default("groups").StringVar(&c.groupsClaim)
+
+	c.groupUsers = map[string]string{}
+	cmd.Flag("oidc-group-user", "Allow members of the group to authenticate as the user, whose ACLs apply to them (user@host may use '*' wildcards)").PlaceHolder("GROUP=USER").StringMapVar(&c.groupUsers)
+}
+
+// provider returns OIDC provider configured using flags or nil if OpenID Connect is not enabled.
+func (c *serverOIDCFlags) provider(ctx context.Context) (*auth.OIDCProvider, error) {
+	if c.issuerURL == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	if c.serverURL == "" {
+		return nil, errors.New("--oidc-server-url must be provided when using OpenID Connect")
+	}
+
+	p, err := auth.NewOIDCProvider(ctx, auth.OIDCOptions{
+		IssuerURL:     c.issuerURL,
+		ClientID:      c.clientID,
+		ClientSecret:  c.clientSecret,
+		RedirectURL:   c.serverURL + server.OIDCCallbackPath,
+		UsernameClaim: c.usernameClaim,
+		GroupsClaim:   c.groupsClaim,
+		GroupUsers:    c.groupUsers,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize OpenID Connect")
+	}
+
+	return p, nil
+}
@@ -5,8 +5,8 @@
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/apiclient"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/serverapi"
 )
 
 type commandServerStatus struct {
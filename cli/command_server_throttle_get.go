@@ -5,7 +5,7 @@
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
@@ -5,9 +5,9 @@
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/apiclient"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/serverapi"
 )
 
 type commandServerThrottleSet struct {
@@ -117,12 +117,38 @@ func (c *commandServerStart) startServerWithOptionalTLSAndListener(ctx context.C
 	}
 
 	switch {
+	case c.acme.enabled():
+		// certificates obtained and renewed automatically from ACME certificate authority
+		tc, challengeHandler, err := c.acme.tlsConfig(ctx, c.svc.repositoryConfigFileName())
+		if err != nil {
+			return err
+		}
+
+		defer c.acme.startHTTPChallengeServer(ctx, challengeHandler)()
+
+		httpServer.TLSConfig = tc
+
+		fmt.Fprintf(c.out.stderr(), "SERVER ADDRESS: %shttps://%v\n", udsPfx, httpServer.Addr) //nolint:errcheck
+		c.showServerUIPrompt(ctx)
+
+		return checkErrServerClosed(ctx, httpServer.ServeTLS(listener, "", ""), "error starting TLS server")
+
 	case c.serverStartTLSCertFile != "" && c.serverStartTLSKeyFile != "":
-		// PEM files provided
+		// PEM files provided, reloaded when modified to allow renewing certificates without restarting the server.
+		r, err := tlsutil.NewCertificateReloader(c.serverStartTLSCertFile, c.serverStartTLSKeyFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to load TLS certificate")
+		}
+
+		httpServer.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: r.GetCertificate,
+		}
+
 		fmt.Fprintf(c.out.stderr(), "SERVER ADDRESS: %shttps://%v\n", udsPfx, httpServer.Addr) //nolint:errcheck
 		c.showServerUIPrompt(ctx)
 
-		return checkErrServerClosed(ctx, httpServer.ServeTLS(listener, c.serverStartTLSCertFile, c.serverStartTLSKeyFile), "error starting TLS server")
+		return checkErrServerClosed(ctx, httpServer.ServeTLS(listener, "", ""), "error starting TLS server")
 
 	case c.serverStartTLSGenerateCert:
 		// PEM files not provided, generate in-memory TLS cert/key but don't persit.
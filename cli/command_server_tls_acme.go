@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/kopia/kopia/internal/tlsutil"
+)
+
+const (
+	acmeChallengeHTTP = "http"
+	acmeChallengeDNS  = "dns"
+
+	acmeHTTPReadHeaderTimeout = 10 * time.Second
+)
+
+// serverACMEFlags configures automatic acquisition and renewal of TLS certificates using ACME protocol.
+type serverACMEFlags struct {
+	domains      []string
+	email        string
+	directoryURL string
+	cacheDir     string
+	challenge    string
+	httpAddress  string
+	dnsHook      string
+	acceptTOS    bool
+}
+
+func (c *serverACMEFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("tls-acme-domain", "Obtain TLS certificate for the domain from an ACME certificate authority such as Let's Encrypt").StringsVar(&c.domains)
+	cmd.Flag("tls-acme-email", "Contact email address for the ACME account").StringVar(&c.email)
+	cmd.Flag("tls-acme-directory-url", "ACME directory URL").Default(acme.LetsEncryptURL).StringVar(&c.directoryURL)
+	cmd.Flag("tls-acme-cache-dir", "Directory storing ACME account key and certificates").StringVar(&c.cacheDir)
+	cmd.Flag("tls-acme-challenge", "ACME challenge type: 'http' (HTTP-01 and TLS-ALPN-01, server must be reachable from the internet) or 'dns' (DNS-01)").Default(acmeChallengeHTTP).EnumVar(&c.challenge, acmeChallengeHTTP, acmeChallengeDNS)
+	cmd.Flag("tls-acme-http-address", "Address of HTTP listener answering HTTP-01 challenges and redirecting to HTTPS (empty disables)").Default(":80").StringVar(&c.httpAddress)
+	cmd.Flag("tls-acme-dns-hook", "Command invoked as '<command> present|cleanup <record-name> <value>' to publish or remove DNS-01 TXT records").StringVar(&c.dnsHook)
+	cmd.Flag("tls-acme-accept-tos", "Accept terms of service of the ACME certificate authority").BoolVar(&c.acceptTOS)
+}
+
+func (c *serverACMEFlags) enabled() bool {
+	return len(c.domains) > 0
+}
+
+// tlsConfig returns TLS configuration serving ACME certificates and optional HTTP handler for HTTP-01 challenges,
+// which must be served on port 80.
+func (c *serverACMEFlags) tlsConfig(ctx context.Context, configFile string) (*tls.Config, http.Handler, error) {
+	if !c.acceptTOS {
+		return nil, nil, errors.New("--tls-acme-accept-tos must be provided to obtain certificates from ACME certificate authority")
+	}
+
+	cacheDir := c.cacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(filepath.Dir(configFile), "acme-cache")
+	}
+
+	cache := autocert.DirCache(cacheDir)
+	client := &acme.Client{DirectoryURL: c.directoryURL}
+
+	if c.challenge == acmeChallengeDNS {
+		if c.dnsHook == "" {
+			return nil, nil, errors.New("--tls-acme-dns-hook must be provided when using DNS challenge")
+		}
+
+		m := &tlsutil.DNS01CertManager{
+			Client:  client,
+			Domains: c.domains,
+			Email:   c.email,
+			Cache:   cache,
+			Solver:  dnsHookSolver{c.dnsHook},
+		}
+
+		if err := m.Start(ctx); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to obtain TLS certificate")
+		}
+
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: m.GetCertificate,
+		}, nil, nil
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      cache,
+		HostPolicy: autocert.HostWhitelist(c.domains...),
+		Client:     client,
+		Email:      c.email,
+	}
+
+	tc := m.TLSConfig()
+	tc.MinVersion = tls.VersionTLS12
+
+	return tc, m.HTTPHandler(nil), nil
+}
+
+// startHTTPChallengeServer starts HTTP server answering HTTP-01 challenges, the returned function stops it.
+func (c *serverACMEFlags) startHTTPChallengeServer(ctx context.Context, h http.Handler) func() {
+	if h == nil || c.httpAddress == "" {
+		return func() {}
+	}
+
+	srv := &http.Server{
+		Addr:              c.httpAddress,
+		Handler:           h,
+		ReadHeaderTimeout: acmeHTTPReadHeaderTimeout,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log(ctx).Errorf("ACME HTTP challenge server error: %v", err)
+		}
+	}()
+
+	return func() {
+		srv.Close() //nolint:errcheck
+	}
+}
+
+// dnsHookSolver publishes DNS-01 challenge records by invoking user-provided command.
+type dnsHookSolver struct {
+	command string
+}
+
+func (s dnsHookSolver) Present(ctx context.Context, recordName, value string) error {
+	return s.run(ctx, "present", recordName, value)
+}
+
+func (s dnsHookSolver) CleanUp(ctx context.Context, recordName, value string) error {
+	return s.run(ctx, "cleanup", recordName, value)
+}
+
+func (s dnsHookSolver) run(ctx context.Context, action, recordName, value string) error {
+	out, err := exec.CommandContext(ctx, s.command, action, recordName, value).CombinedOutput() //nolint:gosec
+	if err != nil {
+		return errors.Wrapf(err, "DNS hook %v failed: %s", action, out)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/serverapi"
+)
+
+type commandServerWebhook struct {
+	list   commandServerWebhookList
+	set    commandServerWebhookSet
+	delete commandServerWebhookDelete
+	test   commandServerWebhookTest
+}
+
+func (c *commandServerWebhook) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("webhook", "Manage outbound webhooks invoked by the server on events")
+	c.list.setup(svc, cmd)
+	c.set.setup(svc, cmd)
+	c.delete.setup(svc, cmd)
+	c.test.setup(svc, cmd)
+}
+
+type commandServerWebhookList struct {
+	sf serverClientFlags
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandServerWebhookList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List webhooks").Alias("ls")
+
+	c.sf.setup(svc, cmd)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerWebhookList) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	resp, err := serverapi.ListWebhooks(ctx, cli)
+	if err != nil {
+		return errors.Wrap(err, "unable to list webhooks")
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(resp))
+		return nil
+	}
+
+	for _, wh := range resp.Webhooks {
+		signed := ""
+		if wh.HasSecret {
+			signed = " (signed)"
+		}
+
+		c.out.printStdout("%v %v events:%v%v\n", wh.Name, wh.URL, strings.Join(wh.Events, ","), signed)
+	}
+
+	return nil
+}
+
+type commandServerWebhookSet struct {
+	sf serverClientFlags
+
+	name                  string
+	url                   string
+	events                []string
+	template              string
+	templateFile          string
+	contentType           string
+	headers               []string
+	secret                string
+	clientMissingAfter    time.Duration
+	lowStorageFreePercent float64
+}
+
+func (c *commandServerWebhookSet) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set", "Create or replace a webhook")
+	cmd.Arg("name", "Name of the webhook").Required().StringVar(&c.name)
+	cmd.Flag("url", "URL to send events to").Required().StringVar(&c.url)
+	cmd.Flag("event", "Event to send (repeatable)").Required().EnumsVar(&c.events, webhook.AllEvents...)
+	cmd.Flag("template", "Go template of the request body, JSON-encoded event by default").StringVar(&c.template)
+	cmd.Flag("template-file", "File containing Go template of the request body").ExistingFileVar(&c.templateFile)
+	cmd.Flag("content-type", "Content type of the request body").StringVar(&c.contentType)
+	cmd.Flag("header", "Additional header 'Name: value' (repeatable)").StringsVar(&c.headers)
+	cmd.Flag("secret", "Secret used to sign request bodies").Envar(svc.EnvName("KOPIA_WEBHOOK_SECRET")).StringVar(&c.secret)
+	cmd.Flag("client-missing-after", "Time since a client was last seen after which it is reported missing").DurationVar(&c.clientMissingAfter)
+	cmd.Flag("low-storage-free-percent", "Percentage of free storage capacity below which low storage is reported").Float64Var(&c.lowStorageFreePercent)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerWebhookSet) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	wh := &webhook.Webhook{
+		Name:                  c.name,
+		URL:                   c.url,
+		Events:                c.events,
+		Template:              c.template,
+		ContentType:           c.contentType,
+		Secret:                c.secret,
+		ClientMissingAfter:    c.clientMissingAfter,
+		LowStorageFreePercent: c.lowStorageFreePercent,
+	}
+
+	if c.templateFile != "" {
+		b, err := os.ReadFile(c.templateFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to read template file")
+		}
+
+		wh.Template = string(b)
+	}
+
+	for _, h := range c.headers {
+		const numParts = 2
+
+		parts := strings.SplitN(h, ":", numParts)
+		if len(parts) != numParts {
+			return errors.Errorf("invalid header %q, expected 'Name: value'", h)
+		}
+
+		if wh.Headers == nil {
+			wh.Headers = map[string]string{}
+		}
+
+		wh.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	if err := wh.Validate(); err != nil {
+		return errors.Wrap(err, "invalid webhook")
+	}
+
+	return errors.Wrap(serverapi.SetWebhook(ctx, cli, wh), "unable to set webhook")
+}
+
+type commandServerWebhookDelete struct {
+	sf serverClientFlags
+
+	name string
+}
+
+func (c *commandServerWebhookDelete) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("delete", "Delete a webhook").Alias("rm")
+	cmd.Arg("name", "Name of the webhook").Required().StringVar(&c.name)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerWebhookDelete) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	return errors.Wrap(serverapi.DeleteWebhook(ctx, cli, c.name), "unable to delete webhook")
+}
+
+type commandServerWebhookTest struct {
+	sf serverClientFlags
+
+	name string
+}
+
+func (c *commandServerWebhookTest) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("test", "Send a test event to a webhook")
+	cmd.Arg("name", "Name of the webhook").Required().StringVar(&c.name)
+
+	c.sf.setup(svc, cmd)
+
+	cmd.Action(svc.serverAction(&c.sf, c.run))
+}
+
+func (c *commandServerWebhookTest) run(ctx context.Context, cli *apiclient.KopiaAPIClient) error {
+	return errors.Wrap(serverapi.TestWebhook(ctx, cli, c.name), "unable to test webhook")
+}
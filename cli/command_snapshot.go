@@ -1,18 +1,22 @@
 package cli
 
 type commandSnapshot struct {
-	copyHistory commandSnapshotCopyMoveHistory
-	moveHistory commandSnapshotCopyMoveHistory
-	create      commandSnapshotCreate
-	delete      commandSnapshotDelete
-	estimate    commandSnapshotEstimate
-	expire      commandSnapshotExpire
-	fix         commandSnapshotFix
-	list        commandSnapshotList
-	migrate     commandSnapshotMigrate
-	pin         commandSnapshotPin
-	restore     commandSnapshotRestore
-	verify      commandSnapshotVerify
+	copyHistory  commandSnapshotCopyMoveHistory
+	moveHistory  commandSnapshotCopyMoveHistory
+	create       commandSnapshotCreate
+	createStream commandSnapshotCreateStream
+	delete       commandSnapshotDelete
+	estimate     commandSnapshotEstimate
+	expire       commandSnapshotExpire
+	exportDiff   commandSnapshotExportDiff
+	applyDiff    commandSnapshotApplyDiff
+	fix          commandSnapshotFix
+	list         commandSnapshotList
+	migrate      commandSnapshotMigrate
+	mountAll     commandSnapshotMountAll
+	pin          commandSnapshotPin
+	restore      commandSnapshotRestore
+	verify       commandSnapshotVerify
 }
 
 func (c *commandSnapshot) setup(svc advancedAppServices, parent commandParent) {
@@ -20,12 +24,16 @@ func (c *commandSnapshot) setup(svc advancedAppServices, parent commandParent) {
 	c.copyHistory.setup(svc, cmd, false)
 	c.moveHistory.setup(svc, cmd, true)
 	c.create.setup(svc, cmd)
+	c.createStream.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
 	c.estimate.setup(svc, cmd)
 	c.expire.setup(svc, cmd)
+	c.exportDiff.setup(svc, cmd)
+	c.applyDiff.setup(svc, cmd)
 	c.fix.setup(svc, cmd)
 	c.list.setup(svc, cmd)
 	c.migrate.setup(svc, cmd)
+	c.mountAll.setup(svc, cmd)
 	c.pin.setup(svc, cmd)
 	c.restore.setup(svc, cmd)
 	c.verify.setup(svc, cmd)
@@ -2,16 +2,18 @@
 
 import (
 	"context"
-	"fmt"
 	"io"
+	"maps"
 	"path/filepath"
 	"strings"
 	"time"
 
+	atunits "github.com/alecthomas/units"
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/notification"
 	"github.com/kopia/kopia/notification/notifydata"
 	"github.com/kopia/kopia/repo"
@@ -30,9 +32,13 @@ type commandSnapshotCreate struct {
 	snapshotCreateAll                     bool
 	snapshotCreateDescription             string
 	snapshotCreateCheckpointInterval      time.Duration
+	snapshotCreateDirCheckpointInterval   time.Duration
+	snapshotCreateResumeDirectories       bool
+	snapshotCreateChangedOnly             bool
 	snapshotCreateFailFast                bool
 	snapshotCreateForceHash               float64
 	snapshotCreateParallelUploads         int
+	snapshotCreateMemoryBudget            atunits.Base2Bytes
 	snapshotCreateStartTime               string
 	snapshotCreateEndTime                 string
 	snapshotCreateForceEnableActions      bool
@@ -43,9 +49,13 @@ type commandSnapshotCreate struct {
 	flushPerSource                        bool
 	sourceOverride                        string
 	sendSnapshotReport                    bool
+	uploadHints                           bool
+	timingReport                          int
 
 	pins []string
 
+	scan contentScannerFlags
+
 	logDirDetail   int
 	logEntryDetail int
 
@@ -61,10 +71,14 @@ func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd.Flag("all", "Create snapshots for files or directories previously backed up by this user on this computer. Cannot be used when a source path argument is also specified.").BoolVar(&c.snapshotCreateAll)
 	cmd.Flag("upload-limit-mb", "Stop the backup process after the specified amount of data (in MB) has been uploaded.").PlaceHolder("MB").Default("0").Int64Var(&c.snapshotCreateCheckpointUploadLimitMB)
 	cmd.Flag("checkpoint-interval", "Interval between periodic checkpoints (must be <= 45 minutes).").Hidden().DurationVar(&c.snapshotCreateCheckpointInterval)
+	cmd.Flag("directory-checkpoint-interval", "Also checkpoint after a subdirectory completes, at most this often.").PlaceHolder("DURATION").DurationVar(&c.snapshotCreateDirCheckpointInterval)
+	cmd.Flag("changed-only", "Do not upload anything, only report new and modified files that would be uploaded.").BoolVar(&c.snapshotCreateChangedOnly)
+	cmd.Flag("resume-completed-directories", "Reuse subdirectories completed by an interrupted snapshot without walking them again. Only the subdirectory itself is checked for changes, so edits to files inside reused subdirectories are NOT detected. Snapshots using this are tagged with 'resumed-directories'.").BoolVar(&c.snapshotCreateResumeDirectories)
 	cmd.Flag("description", "Free-form snapshot description.").StringVar(&c.snapshotCreateDescription)
 	cmd.Flag("fail-fast", "Fail fast when creating snapshot.").Envar(svc.EnvName("KOPIA_SNAPSHOT_FAIL_FAST")).BoolVar(&c.snapshotCreateFailFast)
 	cmd.Flag("force-hash", "Force hashing of source files for a given percentage of files [0.0 .. 100.0]").Default("0").Float64Var(&c.snapshotCreateForceHash)
 	cmd.Flag("parallel", "Upload N files in parallel").PlaceHolder("N").Default("0").IntVar(&c.snapshotCreateParallelUploads)
+	cmd.Flag("memory-budget", "Target memory usage, parallelism and buffers are adjusted to stay within it (overrides policy)").PlaceHolder("SIZE").BytesVar(&c.snapshotCreateMemoryBudget)
 	cmd.Flag("start-time", "Override snapshot start timestamp.").StringVar(&c.snapshotCreateStartTime)
 	cmd.Flag("end-time", "Override snapshot end timestamp.").StringVar(&c.snapshotCreateEndTime)
 	cmd.Flag("force-enable-actions", "Enable snapshot actions even if globally disabled on this client").Hidden().BoolVar(&c.snapshotCreateForceEnableActions)
@@ -74,7 +88,10 @@ func (c *commandSnapshotCreate) setup(svc appServices, parent commandParent) {
 	cmd.Flag("pin", "Create a pinned snapshot that will not expire automatically").StringsVar(&c.pins)
 	cmd.Flag("flush-per-source", "Flush writes at the end of each source").Hidden().BoolVar(&c.flushPerSource)
 	cmd.Flag("override-source", "Override the source of the snapshot.").StringVar(&c.sourceOverride)
+	cmd.Flag("upload-hints", "Skip hashing of local files previously uploaded under a different path or source, based on a local cache").Default("true").BoolVar(&c.uploadHints)
+	cmd.Flag("timing-report", "Print time breakdown of the N slowest directories after each snapshot").PlaceHolder("N").IntVar(&c.timingReport)
 	cmd.Flag("send-snapshot-report", "Send a snapshot report notification using configured notification profiles").Default("true").BoolVar(&c.sendSnapshotReport)
+	c.scan.setup(cmd)
 
 	c.logDirDetail = -1
 	c.logEntryDetail = -1
@@ -124,7 +141,17 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 
 	u := c.setupUploader(rep)
 
-	var finalErrors []string
+	if c.uploadHints {
+		u.HintCache = openUploadHintCache(ctx, c.svc)
+
+		defer func() {
+			if err := u.HintCache.Save(ctx); err != nil {
+				log(ctx).Warnf("unable to save upload hints: %v", err)
+			}
+		}()
+	}
+
+	var finalErrors []error
 
 	tags, err := getTags(c.snapshotCreateTags)
 	if err != nil {
@@ -141,15 +168,15 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 
 		fsEntry, sourceInfo, setManual, err := c.getContentToSnapshot(ctx, snapshotDir, rep)
 		if err != nil {
-			finalErrors = append(finalErrors, fmt.Sprintf("failed to prepare source: %s", err))
+			finalErrors = append(finalErrors, errors.Wrap(err, "failed to prepare source"))
 		}
 
 		if err := c.snapshotSingleSource(ctx, fsEntry, setManual, rep, u, sourceInfo, tags, &st); err != nil {
-			finalErrors = append(finalErrors, err.Error())
+			finalErrors = append(finalErrors, err)
 		}
 	}
 
-	if c.sendSnapshotReport {
+	if c.sendSnapshotReport && !c.snapshotCreateChangedOnly {
 		notification.Send(ctx, rep, "snapshot-report", st, notification.SeverityReport, c.svc.notificationTemplateOptions())
 	}
 
@@ -167,10 +194,16 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 	}
 
 	if len(finalErrors) == 1 {
-		return errors.New(finalErrors[0])
+		return finalErrors[0]
+	}
+
+	var msgs []string
+
+	for _, err := range finalErrors {
+		msgs = append(msgs, err.Error())
 	}
 
-	return errors.Errorf("encountered %v errors:\n%v", len(finalErrors), strings.Join(finalErrors, "\n"))
+	return withExitCode(commonExitCode(finalErrors), errors.Errorf("encountered %v errors:\n%v", len(finalErrors), strings.Join(msgs, "\n")))
 }
 
 func getTags(tagStrings []string) (map[string]string, error) {
@@ -245,15 +278,32 @@ func (c *commandSnapshotCreate) setupUploader(rep repo.RepositoryWriter) *snapsh
 
 	c.svc.onTerminate(u.Cancel)
 
+	u.DirectoryCheckpointInterval = c.snapshotCreateDirCheckpointInterval
+	u.ResumeCompletedDirectories = c.snapshotCreateResumeDirectories
+	u.DryRun = c.snapshotCreateChangedOnly
 	u.ForceHashPercentage = c.snapshotCreateForceHash
 	u.ParallelUploads = c.snapshotCreateParallelUploads
+	u.MemoryBudget = int64(c.snapshotCreateMemoryBudget)
 
 	u.FailFast = c.snapshotCreateFailFast
+	u.DirectoryTimingReportSize = c.timingReport
+	u.Scanner = c.scan.scanner()
 	u.Progress = c.svc.getProgress()
 
 	return u
 }
 
+// openUploadHintCache opens the upload hint cache stored in the cache directory of the repository
+// or returns nil if caching is not enabled.
+func openUploadHintCache(ctx context.Context, svc appServices) *snapshotfs.UploadHintCache {
+	opts, err := repo.GetCachingOptions(ctx, svc.repositoryConfigFileName())
+	if err != nil || opts.CacheDirectory == "" {
+		return nil
+	}
+
+	return snapshotfs.OpenUploadHintCache(ctx, opts.CacheDirectory, snapshotfs.DefaultUploadHintCacheMaxEntries)
+}
+
 func parseTimestamp(timestamp string) (time.Time, error) {
 	if timestamp == "" {
 		return time.Time{}, nil
@@ -317,8 +367,25 @@ func (c *commandSnapshotCreate) snapshotSingleSource(
 		return errors.Wrap(finalErr, "upload error")
 	}
 
+	c.printTimingReport(u.SlowestDirectories())
+
+	if c.snapshotCreateChangedOnly {
+		c.svc.getProgress().Finish()
+
+		return c.printChangeReport(sourceInfo, u.ChangeReport())
+	}
+
+	if n := len(manifest.Annotations); n > 0 {
+		log(ctx).Warnf("Content scanner flagged or failed to scan %v file(s), see snapshot annotations.", n)
+	}
+
 	manifest.Description = c.snapshotCreateDescription
-	manifest.Tags = tags
+	// preserve tags set by the uploader, user-defined tags have their own prefix.
+	if manifest.Tags == nil {
+		manifest.Tags = map[string]string{}
+	}
+
+	maps.Copy(manifest.Tags, tags)
 	manifest.UpdatePins(c.pins, nil)
 
 	startTimeOverride, _ := parseTimestamp(c.snapshotCreateStartTime)
@@ -400,7 +467,7 @@ func (c *commandSnapshotCreate) reportSnapshotStatus(ctx context.Context, manife
 		}
 
 		if ds.FatalErrorCount > 0 {
-			return errors.Errorf("Found %v fatal error(s) while snapshotting %v.", ds.FatalErrorCount, sourceInfo) //nolint:revive
+			return withExitCode(ExitCodePartialSuccess, errors.Errorf("Found %v fatal error(s) while snapshotting %v.", ds.FatalErrorCount, sourceInfo)) //nolint:revive
 		}
 	}
 
@@ -541,3 +608,46 @@ func parseFullSource(str, hostname, username string) (snapshot.SourceInfo, error
 
 	return sourceInfo, nil
 }
+
+// printTimingReport prints the per-directory time breakdown of the slowest directories.
+func (c *commandSnapshotCreate) printChangeReport(sourceInfo snapshot.SourceInfo, r snapshotfs.ChangeReport) error {
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonIndentedBytes(r, "  "))
+		return nil
+	}
+
+	c.out.printStdout("Changes in %v:\n", sourceInfo)
+
+	for _, f := range r.Files {
+		c.out.printStdout("  %-8v %10v  %v\n", f.Kind, units.BytesString(f.Size), f.Path)
+	}
+
+	c.out.printStdout("New files: %v (%v), modified files: %v (%v), unchanged files: %v.\n",
+		r.NewFiles, units.BytesString(r.NewBytes),
+		r.ModifiedFiles, units.BytesString(r.ModifiedBytes),
+		r.UnchangedFiles)
+	c.out.printStdout("Estimated upload size: %v (less if contents are already present in the repository).\n", units.BytesString(r.EstimatedUploadBytes()))
+
+	return nil
+}
+
+func (c *commandSnapshotCreate) printTimingReport(timings []snapshotfs.DirectoryTiming) {
+	if len(timings) == 0 {
+		return
+	}
+
+	c.out.printStderr("\nSlowest directories (time excluding subdirectories):\n")
+	c.out.printStderr("%10v %10v %10v %10v %10v %8v %10v  %v\n", "total", "list", "read", "write", "flush", "files", "bytes", "path")
+
+	for _, t := range timings {
+		c.out.printStderr("%10v %10v %10v %10v %10v %8v %10v  %v\n",
+			t.Exclusive().Round(time.Millisecond),
+			t.List.Round(time.Millisecond),
+			t.Read.Round(time.Millisecond),
+			t.Write.Round(time.Millisecond),
+			t.Flush.Round(time.Millisecond),
+			t.Files,
+			units.BytesString(t.Bytes),
+			t.Path)
+	}
+}
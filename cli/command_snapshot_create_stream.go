@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/streamfs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/notification/notifydata"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	streamFormatTar    = "tar"
+	streamFormatFramed = "framed"
+	streamFormatRaw    = "raw"
+
+	streamRootPermissions os.FileMode = 0o755
+)
+
+type commandSnapshotCreateStream struct {
+	source   string
+	format   string
+	fileName string
+	socket   string
+	tags     []string
+
+	// used to reuse snapshotting logic shared with 'snapshot create'.
+	create commandSnapshotCreate
+}
+
+func (c *commandSnapshotCreateStream) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("create-stream", "Creates a snapshot of a stream of files (such as a tar archive) piped to stdin or a unix socket.")
+
+	cmd.Arg("source", "Path under which the stream will be snapshotted.").Required().StringVar(&c.source)
+	cmd.Flag("format", "Stream format: 'tar' archive, 'framed' entries (JSON headers followed by contents) or 'raw' contents of a single file.").Default(streamFormatTar).EnumVar(&c.format, streamFormatTar, streamFormatFramed, streamFormatRaw)
+	cmd.Flag("file-name", "Name of the file to store raw stream contents in.").StringVar(&c.fileName)
+	cmd.Flag("socket", "Read the stream from the first connection to a unix socket created at the provided path instead of stdin.").StringVar(&c.socket)
+	cmd.Flag("description", "Free-form snapshot description.").StringVar(&c.create.snapshotCreateDescription)
+	cmd.Flag("tags", "Tags applied on the snapshot. Must be provided in the <key>:<value> format.").StringsVar(&c.tags)
+	cmd.Flag("pin", "Create a pinned snapshot that will not expire automatically").StringsVar(&c.create.pins)
+	cmd.Flag("fail-fast", "Fail fast when creating snapshot.").Envar(svc.EnvName("KOPIA_SNAPSHOT_FAIL_FAST")).BoolVar(&c.create.snapshotCreateFailFast)
+
+	c.create.logDirDetail = -1
+	c.create.logEntryDetail = -1
+
+	c.create.jo.setup(svc, cmd)
+	c.create.out.setup(svc)
+
+	c.create.svc = svc
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandSnapshotCreateStream) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	if c.format == streamFormatRaw && c.fileName == "" {
+		return errors.New("--file-name is required for raw streams")
+	}
+
+	if len(c.create.snapshotCreateDescription) > maxSnapshotDescriptionLength {
+		return errors.New("description too long")
+	}
+
+	tags, err := getTags(c.tags)
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(c.source)
+	if err != nil {
+		return errors.Wrapf(err, "invalid source %v", c.source)
+	}
+
+	sourceInfo := snapshot.SourceInfo{
+		Path:     filepath.Clean(absPath),
+		Host:     rep.ClientOptions().Hostname,
+		UserName: rep.ClientOptions().Username,
+	}
+
+	r, err := c.openStream(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	u := c.create.setupUploader(rep)
+
+	// entries of the stream are produced as they are read, which requires them to be uploaded
+	// sequentially and in order.
+	u.ParallelUploads = 1
+
+	var st notifydata.MultiSnapshotStatus
+
+	if err := c.create.snapshotSingleSource(ctx, c.streamRoot(sourceInfo.Path, r), true, rep, u, sourceInfo, tags, &st); err != nil {
+		return err
+	}
+
+	return errors.Wrap(rep.Flush(ctx), "flush error")
+}
+
+func (c *commandSnapshotCreateStream) streamRoot(name string, r io.Reader) fs.Directory {
+	switch c.format {
+	case streamFormatRaw:
+		return virtualfs.NewStaticDirectory(name, []fs.Entry{
+			virtualfs.StreamingFileFromReader(c.fileName, io.NopCloser(r)),
+		})
+
+	case streamFormatFramed:
+		return streamfs.NewDirectory(name, c.rootMetadata(), streamfs.FramedSource(r))
+
+	default:
+		return streamfs.NewDirectory(name, c.rootMetadata(), streamfs.TarSource(r))
+	}
+}
+
+func (c *commandSnapshotCreateStream) rootMetadata() virtualfs.EntryMetadata {
+	return virtualfs.EntryMetadata{
+		Mode:    streamRootPermissions,
+		ModTime: clock.Now(),
+	}
+}
+
+// openStream returns the reader of the stream, which is either stdin or the first connection to the unix socket.
+func (c *commandSnapshotCreateStream) openStream(ctx context.Context) (io.ReadCloser, error) {
+	if c.socket == "" {
+		return io.NopCloser(c.create.svc.stdin()), nil
+	}
+
+	l, err := (&net.ListenConfig{}).Listen(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to listen on socket")
+	}
+
+	// closing unix listener also removes the socket file.
+	defer l.Close() //nolint:errcheck
+
+	log(ctx).Infof("Waiting for the stream producer to connect to %v ...", c.socket)
+
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to accept connection")
+	}
+
+	return conn, nil
+}
@@ -91,7 +91,7 @@ func (c *commandSnapshotDelete) deleteSnapshot(ctx context.Context, rep repo.Rep
 
 	log(ctx).Infof("Deleting %v...", desc)
 
-	return errors.Wrap(rep.DeleteManifest(ctx, m.ID), "error deleting manifest")
+	return errors.Wrap(snapshot.DeleteSnapshot(ctx, rep, m.ID, m.Source, "deleted by user"), "error deleting manifest")
 }
 
 func (c *commandSnapshotDelete) deleteSnapshotsByRootObjectID(ctx context.Context, rep repo.RepositoryWriter, rootID string) error {
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/diffbundle"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandSnapshotExportDiff struct {
+	base   string
+	target string
+	file   string
+}
+
+func (c *commandSnapshotExportDiff) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("export-diff", "Export changes between two snapshots to a bundle, which can be applied offline to a directory containing the first snapshot.")
+	cmd.Arg("base", "Base snapshot ID or root object ID").Required().StringVar(&c.base)
+	cmd.Arg("target", "Target snapshot ID or root object ID").Required().StringVar(&c.target)
+	cmd.Flag("to", "Output bundle file").Required().StringVar(&c.file)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotExportDiff) run(ctx context.Context, rep repo.Repository) error {
+	base, baseInfo, err := c.snapshotDirectory(ctx, rep, c.base)
+	if err != nil {
+		return err
+	}
+
+	target, targetInfo, err := c.snapshotDirectory(ctx, rep, c.target)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:mnd
+	if err != nil {
+		return errors.Wrap(err, "unable to create bundle file")
+	}
+
+	st, err := diffbundle.Export(ctx, rep, base, target, diffbundle.Header{Base: baseInfo, Target: targetInfo}, f)
+	if err != nil {
+		f.Close()         //nolint:errcheck
+		os.Remove(c.file) //nolint:errcheck
+
+		return errors.Wrap(err, "unable to export changes")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close bundle file")
+	}
+
+	log(ctx).Infof("Exported %v added and %v changed files, %v deletions and %v other changes to %v.",
+		st.AddedFiles, st.ChangedFiles, st.DeletedEntries, st.OtherChanges, c.file)
+	log(ctx).Infof("Bundle carries %v of file data, %v will be reused from the base.",
+		units.BytesString(st.LiteralBytes), units.BytesString(st.ReusedBytes))
+
+	return nil
+}
+
+func (c *commandSnapshotExportDiff) snapshotDirectory(ctx context.Context, rep repo.Repository, id string) (fs.Directory, diffbundle.SnapshotInfo, error) {
+	info := diffbundle.SnapshotInfo{ID: id}
+
+	dir, err := snapshotfs.FilesystemDirectoryFromIDWithPath(ctx, rep, id, false)
+	if err != nil {
+		return nil, info, errors.Wrapf(err, "unable to get directory for %v", id)
+	}
+
+	if h, ok := dir.(object.HasObjectID); ok {
+		info.RootObjectID = h.ObjectID()
+	}
+
+	if man, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(id)); err == nil {
+		info.Source = man.Source
+		info.StartTime = man.StartTime.ToTime()
+	}
+
+	return dir, info, nil
+}
+
+type commandSnapshotApplyDiff struct {
+	file      string
+	targetDir string
+}
+
+func (c *commandSnapshotApplyDiff) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("apply-diff", "Apply a bundle created with 'snapshot export-diff' to a directory containing the base snapshot.")
+	cmd.Arg("bundle", "Bundle file").Required().ExistingFileVar(&c.file)
+	cmd.Arg("target-dir", "Directory containing the base snapshot, which is updated to the target snapshot").Required().ExistingDirVar(&c.targetDir)
+	cmd.Action(svc.noRepositoryAction(c.run))
+}
+
+func (c *commandSnapshotApplyDiff) run(ctx context.Context) error {
+	f, err := os.Open(c.file)
+	if err != nil {
+		return errors.Wrap(err, "unable to open bundle file")
+	}
+	defer f.Close() //nolint:errcheck
+
+	h, st, err := diffbundle.Apply(ctx, f, c.targetDir)
+	if err != nil {
+		return errors.Wrap(err, "unable to apply changes")
+	}
+
+	log(ctx).Infof("Updated %v from %v to %v: %v added and %v changed files, %v deletions and %v other changes.",
+		c.targetDir, h.Base.ID, h.Target.ID, st.AddedFiles, st.ChangedFiles, st.DeletedEntries, st.OtherChanges)
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotExportApplyDiff(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	srcDir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file1.txt"), []byte("hello"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file2.txt"), []byte("removed"), 0o600))
+
+	var man1, man2 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", srcDir, "--json"), &man1)
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file1.txt"), []byte("hello world"), 0o600))
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "file2.txt")))
+	require.NoError(t, os.Mkdir(filepath.Join(srcDir, "subdir"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "subdir", "file3.txt"), []byte("added"), 0o600))
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", srcDir, "--json"), &man2)
+
+	bundleFile := filepath.Join(testutil.TempDirectory(t), "update.kdiff")
+
+	env.RunAndExpectSuccess(t, "snapshot", "export-diff", string(man1.ID), string(man2.ID), "--to", bundleFile)
+
+	// refuse to overwrite existing bundle.
+	env.RunAndExpectFailure(t, "snapshot", "export-diff", string(man1.ID), string(man2.ID), "--to", bundleFile)
+
+	targetDir := testutil.TempDirectory(t)
+	env.RunAndExpectSuccess(t, "snapshot", "restore", string(man1.ID), targetDir)
+
+	// applying the bundle does not need a repository.
+	env.RunAndExpectSuccess(t, "repo", "disconnect")
+	env.RunAndExpectSuccess(t, "snapshot", "apply-diff", bundleFile, targetDir)
+
+	data, err := os.ReadFile(filepath.Join(targetDir, "file1.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(data))
+
+	data, err = os.ReadFile(filepath.Join(targetDir, "subdir", "file3.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "added", string(data))
+
+	require.NoFileExists(t, filepath.Join(targetDir, "file2.txt"))
+}
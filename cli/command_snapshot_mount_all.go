@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandSnapshotMountAll struct {
+	m commandMount
+}
+
+func (c *commandSnapshotMountAll) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("mount-all", "Mount all snapshots of all sources as a local filesystem organized as <source>/<date>/<time>.")
+	cmd.Arg("mountPoint", "Mount point").Default("*").StringVar(&c.m.mountPoint)
+	c.m.setupMountFlags(svc, cmd)
+
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotMountAll) run(ctx context.Context, rep repo.Repository) error {
+	return c.m.mountAndWait(ctx, snapshotfs.AllSnapshotsEntry(rep), "all snapshots")
+}
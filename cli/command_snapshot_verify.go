@@ -4,9 +4,11 @@
 	"context"
 	"fmt"
 	"runtime"
+	"strings"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/formatcheck"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/manifest"
@@ -26,6 +28,12 @@ type commandSnapshotVerify struct {
 
 	fileQueueLength int
 	fileParallelism int
+
+	scan            contentScannerFlags
+	saveAnnotations bool
+
+	deep         bool
+	formatChecks []string
 }
 
 func (c *commandSnapshotVerify) setup(svc appServices, parent commandParent) {
@@ -42,6 +50,10 @@ func (c *commandSnapshotVerify) setup(svc appServices, parent commandParent) {
 	cmd.Flag("file-queue-length", "Queue length for file verification").Default("20000").IntVar(&c.fileQueueLength)
 	cmd.Flag("file-parallelism", "Parallelism for file verification").IntVar(&c.fileParallelism)
 	cmd.Flag("verify-files-percent", "Randomly verify a percentage of files by downloading them [0.0 .. 100.0]").Default("0").Float64Var(&c.verifyCommandFilesPercent)
+	cmd.Flag("deep", "Read all files and validate integrity of well-known file formats").BoolVar(&c.deep)
+	cmd.Flag("format-check", "File formats to validate with --deep").Default(formatcheck.Names()...).EnumsVar(&c.formatChecks, formatcheck.Names()...)
+	cmd.Flag("save-annotations", "Record findings of the content scanner as annotations on verified snapshots").BoolVar(&c.saveAnnotations)
+	c.scan.setup(cmd)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
@@ -59,6 +71,21 @@ func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) er
 		FileQueueLength:    c.fileQueueLength,
 		Parallelism:        c.fileParallelism,
 		MaxErrors:          c.verifyCommandErrorThreshold,
+		Scanner:            c.scan.scanner(),
+	}
+
+	if c.deep {
+		checkers, err := formatcheck.ByName(c.formatChecks)
+		if err != nil {
+			return errors.Wrap(err, "invalid format check")
+		}
+
+		opts.VerifyFilesPercent = 100 //nolint:mnd
+		opts.FormatCheckers = checkers
+	}
+
+	if c.saveAnnotations && opts.Scanner == nil {
+		return errors.New("--save-annotations requires --scan-command")
 	}
 
 	if dr, ok := rep.(repo.DirectRepository); ok {
@@ -73,61 +100,180 @@ func (c *commandSnapshotVerify) run(ctx context.Context, rep repo.Repository) er
 	v := snapshotfs.NewVerifier(ctx, rep, opts)
 	defer v.ShowFinalStats(ctx)
 
+	if opts.Scanner != nil {
+		return c.verifyAndScan(ctx, rep, v, opts.Scanner.Name())
+	}
+
+	var enqueueErr error
+
+	err := v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
+		enqueueErr = c.enqueue(ctx, rep, tw)
+		return enqueueErr
+	})
+	if err != nil && enqueueErr == nil {
+		// errors reported by the tree walker indicate missing or corrupted data.
+		return withExitCode(ExitCodeCorruption, err)
+	}
+
 	//nolint:wrapcheck
-	return v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
-		manifests, err := c.loadSourceManifests(ctx, rep)
-		if err != nil {
-			return err
+	return err
+}
+
+// verifyAndScan verifies each snapshot separately, so that findings of the content scanner can be
+// attributed to individual snapshots and optionally saved as their annotations.
+func (c *commandSnapshotVerify) verifyAndScan(ctx context.Context, rep repo.Repository, v *snapshotfs.Verifier, scannerName string) error {
+	manifests, err := c.loadManifests(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	var changed []*snapshot.Manifest
+
+	for _, man := range manifests {
+		if man.RootEntry == nil {
+			continue
 		}
 
-		snapIDManifests, err := c.loadSnapIDManifests(ctx, rep)
+		rootPath := snapshotRootPath(man)
+
+		var enqueueErr error
+
+		err := v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
+			enqueueErr = c.enqueueManifest(ctx, rep, tw, man)
+			return enqueueErr
+		})
 		if err != nil {
+			if enqueueErr == nil {
+				return withExitCode(ExitCodeCorruption, err)
+			}
+
+			//nolint:wrapcheck
 			return err
 		}
 
-		manifests = append(manifests, snapIDManifests...)
+		annotations := v.TakeAnnotations()
+		for i := range annotations {
+			annotations[i].Path = strings.TrimPrefix(strings.TrimPrefix(annotations[i].Path, rootPath), "/")
+		}
 
-		for _, man := range manifests {
-			rootPath := fmt.Sprintf("%v@%v", man.Source, formatTimestamp(man.StartTime.ToTime()))
+		log(ctx).Infof("%v: %v file(s) flagged or not scanned.", rootPath, len(annotations))
 
-			if man.RootEntry == nil {
-				continue
-			}
+		if man.ReplaceAnnotations(scannerName, annotations) {
+			changed = append(changed, man)
+		}
+	}
 
-			root, err := snapshotfs.SnapshotRoot(rep, man)
-			if err != nil {
-				return errors.Wrapf(err, "unable to get snapshot root: %q", rootPath)
+	var enqueueErr error
+
+	err = v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
+		enqueueErr = c.enqueueObjectIDs(ctx, rep, tw)
+		return enqueueErr
+	})
+	if err != nil && enqueueErr == nil {
+		return withExitCode(ExitCodeCorruption, err)
+	}
+
+	if err != nil {
+		//nolint:wrapcheck
+		return err
+	}
+
+	if !c.saveAnnotations || len(changed) == 0 {
+		return nil
+	}
+
+	//nolint:wrapcheck
+	return repo.WriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "cli:snapshot-verify-save-annotations",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		for _, man := range changed {
+			if err := snapshot.UpdateSnapshot(ctx, w, man); err != nil {
+				return errors.Wrapf(err, "unable to save annotations of snapshot %v", man.ID)
 			}
+		}
+
+		log(ctx).Infof("Saved annotations of %v snapshot(s).", len(changed))
+
+		return nil
+	})
+}
+
+func snapshotRootPath(man *snapshot.Manifest) string {
+	return fmt.Sprintf("%v@%v", man.Source, formatTimestamp(man.StartTime.ToTime()))
+}
+
+func (c *commandSnapshotVerify) loadManifests(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
+	manifests, err := c.loadSourceManifests(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
 
-			// ignore error now, return aggregate error at a higher level.
-			//nolint:errcheck
-			tw.Process(ctx, root, rootPath)
+	snapIDManifests, err := c.loadSnapIDManifests(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(manifests, snapIDManifests...), nil
+}
+
+func (c *commandSnapshotVerify) enqueueManifest(ctx context.Context, rep repo.Repository, tw *snapshotfs.TreeWalker, man *snapshot.Manifest) error {
+	rootPath := snapshotRootPath(man)
+
+	if man.RootEntry == nil {
+		return nil
+	}
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get snapshot root: %q", rootPath)
+	}
+
+	// ignore error now, return aggregate error at a higher level.
+	//nolint:errcheck
+	tw.Process(ctx, root, rootPath)
+
+	return nil
+}
+
+func (c *commandSnapshotVerify) enqueue(ctx context.Context, rep repo.Repository, tw *snapshotfs.TreeWalker) error {
+	manifests, err := c.loadManifests(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	for _, man := range manifests {
+		if err := c.enqueueManifest(ctx, rep, tw, man); err != nil {
+			return err
 		}
+	}
 
-		for _, oidStr := range c.verifyCommandDirObjectIDs {
-			oid, err := snapshotfs.ParseObjectIDWithPath(ctx, rep, oidStr)
-			if err != nil {
-				return errors.Wrapf(err, "unable to parse: %q", oidStr)
-			}
+	return c.enqueueObjectIDs(ctx, rep, tw)
+}
 
-			// ignore error now, return aggregate error at a higher level.
-			//nolint:errcheck
-			tw.Process(ctx, snapshotfs.DirectoryEntry(rep, oid, nil), oidStr)
+func (c *commandSnapshotVerify) enqueueObjectIDs(ctx context.Context, rep repo.Repository, tw *snapshotfs.TreeWalker) error {
+	for _, oidStr := range c.verifyCommandDirObjectIDs {
+		oid, err := snapshotfs.ParseObjectIDWithPath(ctx, rep, oidStr)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse: %q", oidStr)
 		}
 
-		for _, oidStr := range c.verifyCommandFileObjectIDs {
-			oid, err := snapshotfs.ParseObjectIDWithPath(ctx, rep, oidStr)
-			if err != nil {
-				return errors.Wrapf(err, "unable to parse %q", oidStr)
-			}
+		// ignore error now, return aggregate error at a higher level.
+		//nolint:errcheck
+		tw.Process(ctx, snapshotfs.DirectoryEntry(rep, oid, nil), oidStr)
+	}
 
-			// ignore error now, return aggregate error at a higher level.
-			//nolint:errcheck
-			tw.Process(ctx, snapshotfs.AutoDetectEntryFromObjectID(ctx, rep, oid, oidStr), oidStr)
+	for _, oidStr := range c.verifyCommandFileObjectIDs {
+		oid, err := snapshotfs.ParseObjectIDWithPath(ctx, rep, oidStr)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse %q", oidStr)
 		}
 
-		return nil
-	})
+		// ignore error now, return aggregate error at a higher level.
+		//nolint:errcheck
+		tw.Process(ctx, snapshotfs.AutoDetectEntryFromObjectID(ctx, rep, oid, oidStr), oidStr)
+	}
+
+	return nil
 }
 
 func (c *commandSnapshotVerify) loadSourceManifests(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, error) {
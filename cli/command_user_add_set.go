@@ -17,6 +17,10 @@ type commandServerUserAddSet struct {
 	userSetPassword     string
 	userSetPasswordHash string
 
+	maxRequestsPerSecond float64
+	storageQuotaMB       int64
+	clearQuota           bool
+
 	isNew bool // true == 'add', false == 'update'
 	out   textOutput
 }
@@ -35,6 +39,9 @@ func (c *commandServerUserAddSet) setup(svc appServices, parent commandParent, i
 	cmd.Flag("ask-password", "Ask for user password").BoolVar(&c.userAskPassword)
 	cmd.Flag("user-password", "Password").StringVar(&c.userSetPassword)
 	cmd.Flag("user-password-hash", "Password hash").StringVar(&c.userSetPasswordHash)
+	cmd.Flag("max-requests-per-second", "Maximum rate of repository requests, overrides server default (-1 == unlimited)").Float64Var(&c.maxRequestsPerSecond)
+	cmd.Flag("storage-quota-mb", "Maximum amount of new data the user can write to the repository, overrides server default (-1 == unlimited)").PlaceHolder("MB").Int64Var(&c.storageQuotaMB)
+	cmd.Flag("clear-quota", "Remove quota overrides, so that server defaults apply").BoolVar(&c.clearQuota)
 	cmd.Arg("username", "Username").Required().StringVar(&c.userSetName)
 	cmd.Action(svc.repositoryWriterAction(c.runServerUserAddSet))
 
@@ -79,6 +86,10 @@ func (c *commandServerUserAddSet) runServerUserAddSet(ctx context.Context, rep r
 		changed = true
 	}
 
+	if c.applyQuota(up) {
+		changed = true
+	}
+
 	if up.PasswordHash == nil || c.userAskPassword {
 		pwd, err := askConfirmPass(c.out.stdout(), "Enter new password for user "+username+": ")
 		if err != nil {
@@ -108,6 +119,40 @@ func (c *commandServerUserAddSet) runServerUserAddSet(ctx context.Context, rep r
 	return nil
 }
 
+// applyQuota updates quota overrides in the user profile and returns true if anything changed.
+func (c *commandServerUserAddSet) applyQuota(up *user.Profile) bool {
+	changed := false
+
+	if c.clearQuota {
+		up.Quota = nil
+		changed = true
+	}
+
+	if c.maxRequestsPerSecond == 0 && c.storageQuotaMB == 0 {
+		return changed
+	}
+
+	if up.Quota == nil {
+		up.Quota = &user.Quota{}
+	}
+
+	switch {
+	case c.maxRequestsPerSecond < 0:
+		up.Quota.MaxRequestsPerSecond = -1
+	case c.maxRequestsPerSecond > 0:
+		up.Quota.MaxRequestsPerSecond = c.maxRequestsPerSecond
+	}
+
+	switch {
+	case c.storageQuotaMB < 0:
+		up.Quota.MaxStorageBytes = -1
+	case c.storageQuotaMB > 0:
+		up.Quota.MaxStorageBytes = c.storageQuotaMB << 20 //nolint:mnd
+	}
+
+	return true
+}
+
 func askConfirmPass(out io.Writer, initialPrompt string) (string, error) {
 	pwd, err := askPass(out, initialPrompt)
 	if err != nil {
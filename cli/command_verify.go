@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/bgverify"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandVerify struct {
+	daemon       bool
+	bytesPerHour int64
+	stateFile    string
+	status       bool
+
+	jo  jsonOutput
+	out textOutput
+	svc appServices
+}
+
+func (c *commandVerify) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("verify", "Verify repository contents at a limited rate, resuming where the previous verification stopped")
+	cmd.Flag("daemon", "Keep verifying contents continuously until interrupted").BoolVar(&c.daemon)
+	cmd.Flag("bytes-per-hour", "Maximum number of bytes to verify per hour (0 == unlimited)").Default("1073741824").Int64Var(&c.bytesPerHour)
+	cmd.Flag("state-file", "Path to JSON file storing the verification state (defaults to a file next to the config file)").StringVar(&c.stateFile)
+	cmd.Flag("status", "Only show the status of verification").BoolVar(&c.status)
+
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	c.svc = svc
+
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandVerify) run(ctx context.Context, rep repo.DirectRepository) error {
+	stateFile := c.stateFile
+	if stateFile == "" {
+		stateFile = c.svc.repositoryConfigFileName() + ".verify"
+	}
+
+	v, err := bgverify.New(rep, bgverify.Options{
+		BytesPerHour: c.bytesPerHour,
+		StateFile:    stateFile,
+		OnCheckpoint: func(s bgverify.Status) {
+			log(ctx).Infof("  Verified %v contents (%.1f%% of current pass), %v errors.", s.PassVerifiedContents, s.CoveragePercent, s.PassErrorCount)
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize verification")
+	}
+
+	if !c.status {
+		if c.daemon {
+			log(ctx).Infof("Verifying contents continuously at %v/hour...", units.BytesString(c.bytesPerHour))
+
+			err = v.Run(ctx)
+		} else {
+			err = v.RunPass(ctx)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "verification failed")
+		}
+	}
+
+	s := v.Status()
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(s))
+	} else {
+		c.printStatus(s)
+	}
+
+	if !c.status && s.LastFullPassErrorCount > 0 {
+		return withExitCode(ExitCodeCorruption, errors.Errorf("encountered %v errors", s.LastFullPassErrorCount))
+	}
+
+	return nil
+}
+
+func (c *commandVerify) printStatus(s bgverify.Status) {
+	if s.PassStartTime.IsZero() {
+		c.out.printStdout("Current pass:          none\n")
+	} else {
+		c.out.printStdout("Current pass:          started %v, verified %v contents (%v of %v, %.1f%%), %v errors\n",
+			formatTimestamp(s.PassStartTime),
+			s.PassVerifiedContents,
+			units.BytesString(s.PassVerifiedBytes),
+			units.BytesString(s.PassTotalBytes),
+			s.CoveragePercent,
+			s.PassErrorCount)
+	}
+
+	if s.LastFullPassEndTime.IsZero() {
+		c.out.printStdout("Last full pass:        never\n")
+	} else {
+		c.out.printStdout("Last full pass:        finished %v (%v ago), %v errors\n",
+			formatTimestamp(s.LastFullPassEndTime),
+			s.LastFullPassAge.Truncate(time.Second),
+			s.LastFullPassErrorCount)
+	}
+
+	if s.LastError != "" {
+		c.out.printStdout("Last error:            %v at %v\n", s.LastError, formatTimestamp(s.LastErrorTime))
+	}
+}
@@ -0,0 +1,32 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/bgverify"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+
+	var s bgverify.Status
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "verify", "--status", "--json"), &s)
+	require.True(t, s.LastFullPassEndTime.IsZero())
+
+	env.RunAndExpectSuccess(t, "verify", "--bytes-per-hour=0")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "verify", "--status", "--json"), &s)
+	require.False(t, s.LastFullPassEndTime.IsZero())
+	require.Equal(t, int64(0), s.LastFullPassErrorCount)
+	require.Empty(t, s.Cursor)
+	require.Positive(t, s.PassVerifiedContents)
+}
@@ -54,7 +54,7 @@ func (c *App) openRepository(ctx context.Context, required bool) (repo.Repositor
 			return nil, nil
 		}
 
-		return nil, errors.New("repository is not connected. See https://kopia.io/docs/repositories/")
+		return nil, withExitCode(ExitCodeConnection, errors.New("repository is not connected. See https://kopia.io/docs/repositories/"))
 	}
 
 	c.maybePrintUpdateNotification(ctx)
@@ -66,7 +66,7 @@ func (c *App) openRepository(ctx context.Context, required bool) (repo.Repositor
 
 	r, err := repo.Open(ctx, c.repositoryConfigFileName(), pass, c.optionsFromFlags(ctx))
 	if os.IsNotExist(err) {
-		return nil, errors.New("not connected to a repository, use 'kopia connect'")
+		return nil, withExitCode(ExitCodeConnection, errors.New("not connected to a repository, use 'kopia connect'"))
 	}
 
 	return r, errors.Wrap(err, "unable to open repository")
@@ -79,6 +79,8 @@ func (c *App) optionsFromFlags(ctx context.Context) *repo.Options {
 		UpgradeOwnerID:      c.upgradeOwnerID,
 		DoNotWaitForUpgrade: c.doNotWaitForUpgrade,
 
+		FallbackToMemoryCache: c.cacheMemoryFallback,
+
 		// when a fatal error is encountered in the repository, run all registered callbacks
 		// and exit the program.
 		OnFatalError: func(err error) {
@@ -91,6 +93,8 @@ func (c *App) optionsFromFlags(ctx context.Context) *repo.Options {
 			c.exitWithError(err)
 		},
 
+		PasswordPersist: c.passwordPersistenceStrategy(),
+
 		TestOnlyIgnoreMissingRequiredFeatures: c.testonlyIgnoreMissingRequiredFeatures,
 	}
 }
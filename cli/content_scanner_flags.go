@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// contentScannerFlags configures an external content scanner (antivirus, PII detection, etc.).
+type contentScannerFlags struct {
+	scanCommand string
+	scanArgs    []string
+	scanName    string
+	scanTimeout time.Duration
+}
+
+func (c *contentScannerFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("scan-command", "Stream contents of each file to the standard input of the provided command, exit code 1 flags the file").StringVar(&c.scanCommand)
+	cmd.Flag("scan-arg", "Argument to pass to the scan command").StringsVar(&c.scanArgs)
+	cmd.Flag("scan-name", "Name of the scanner recorded in annotations (defaults to the command)").StringVar(&c.scanName)
+	cmd.Flag("scan-timeout", "Maximum time the scan command may spend on a single file").Default(snapshotfs.DefaultScanTimeout.String()).DurationVar(&c.scanTimeout)
+}
+
+// scanner returns the configured content scanner or nil.
+func (c *contentScannerFlags) scanner() snapshotfs.ContentScanner {
+	if c.scanCommand == "" {
+		return nil
+	}
+
+	return &snapshotfs.ExternalScanner{
+		ScannerName: c.scanName,
+		Command:     c.scanCommand,
+		Arguments:   c.scanArgs,
+		Timeout:     c.scanTimeout,
+	}
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/notification/notifyprofile"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+// ExitCode is the process exit code which classifies the type of failure, so that
+// automation can branch on it.
+type ExitCode int
+
+// Exit codes returned by the CLI.
+const (
+	ExitCodeSuccess        ExitCode = 0
+	ExitCodeGenericError   ExitCode = 1
+	ExitCodeConnection     ExitCode = 2 // repository not connected or storage/server unreachable
+	ExitCodeAuth           ExitCode = 3 // invalid password or credentials, access denied
+	ExitCodeNotFound       ExitCode = 4 // requested snapshot, object, policy, etc. does not exist
+	ExitCodePartialSuccess ExitCode = 5 // operation completed, but some items failed
+	ExitCodeCorruption     ExitCode = 6 // verification detected missing or corrupted data
+)
+
+// String returns the name of the failure class used in JSON error output.
+func (c ExitCode) String() string {
+	switch c {
+	case ExitCodeSuccess:
+		return "success"
+	case ExitCodeConnection:
+		return "connection"
+	case ExitCodeAuth:
+		return "auth"
+	case ExitCodeNotFound:
+		return "not-found"
+	case ExitCodePartialSuccess:
+		return "partial-success"
+	case ExitCodeCorruption:
+		return "corruption"
+	default:
+		return "error"
+	}
+}
+
+// exitCodeError attaches explicit exit code to an error.
+type exitCodeError struct {
+	error
+	code ExitCode
+}
+
+func (e exitCodeError) Unwrap() error {
+	return e.error
+}
+
+// withExitCode wraps the provided error so that the process exits with the provided code.
+func withExitCode(code ExitCode, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return exitCodeError{err, code}
+}
+
+// ExitCodeForError returns the exit code corresponding to the provided error.
+func ExitCodeForError(err error) ExitCode {
+	if err == nil {
+		return ExitCodeSuccess
+	}
+
+	var ece exitCodeError
+	if errors.As(err, &ece) {
+		return ece.code
+	}
+
+	var hse apiclient.HTTPStatusError
+	if errors.As(err, &hse) {
+		switch hse.HTTPStatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ExitCodeAuth
+		case http.StatusNotFound:
+			return ExitCodeNotFound
+		}
+	}
+
+	var ne net.Error
+
+	switch {
+	case errors.Is(err, repo.ErrInvalidPassword), errors.Is(err, blob.ErrInvalidCredentials):
+		return ExitCodeAuth
+
+	case errors.Is(err, repo.ErrRepositoryNotInitialized), errors.As(err, &ne):
+		return ExitCodeConnection
+
+	case errors.Is(err, snapshot.ErrSnapshotNotFound),
+		errors.Is(err, policy.ErrPolicyNotFound),
+		errors.Is(err, manifest.ErrNotFound),
+		errors.Is(err, object.ErrObjectNotFound),
+		errors.Is(err, content.ErrContentNotFound),
+		errors.Is(err, blob.ErrBlobNotFound),
+		errors.Is(err, fs.ErrEntryNotFound),
+		errors.Is(err, notifyprofile.ErrNotFound):
+		return ExitCodeNotFound
+
+	default:
+		return ExitCodeGenericError
+	}
+}
+
+// commonExitCode returns the exit code shared by all provided errors or ExitCodeGenericError if they differ.
+func commonExitCode(errs []error) ExitCode {
+	code := ExitCodeGenericError
+
+	for i, err := range errs {
+		c := ExitCodeForError(err)
+		if i > 0 && c != code {
+			return ExitCodeGenericError
+		}
+
+		code = c
+	}
+
+	return code
+}
+
+// jsonErrorTrailer is printed to stderr when --error-format=json is used.
+type jsonErrorTrailer struct {
+	Error    string   `json:"error"`
+	Kind     string   `json:"kind"`
+	ExitCode ExitCode `json:"exitCode"`
+}
+
+func (c *App) printErrorTrailer(err error) {
+	if c.errorFormat != errorFormatJSON {
+		return
+	}
+
+	code := ExitCodeForError(err)
+
+	b, _ := json.Marshal(jsonErrorTrailer{err.Error(), code.String(), code}) //nolint:errchkjson
+
+	c.Stderr().Write(append(b, '\n')) //nolint:errcheck
+}
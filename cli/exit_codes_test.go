@@ -0,0 +1,63 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestExitCodeForError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want cli.ExitCode
+	}{
+		{nil, cli.ExitCodeSuccess},
+		{errors.New("some error"), cli.ExitCodeGenericError},
+		{errors.Wrap(repo.ErrInvalidPassword, "wrapped"), cli.ExitCodeAuth},
+		{apiclient.HTTPStatusError{HTTPStatusCode: 401, ErrorMessage: "unauthorized"}, cli.ExitCodeAuth},
+		{errors.Wrap(snapshot.ErrSnapshotNotFound, "wrapped"), cli.ExitCodeNotFound},
+		{errors.Wrap(repo.ErrRepositoryNotInitialized, "wrapped"), cli.ExitCodeConnection},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.want, cli.ExitCodeForError(tc.err), "%v", tc.err)
+	}
+}
+
+func TestErrorFormatJSON(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	_, stderr, err := env.Run(t, true, "snapshot", "list", "--error-format=json")
+	require.Error(t, err)
+	require.Equal(t, cli.ExitCodeConnection, cli.ExitCodeForError(err))
+
+	var trailer struct {
+		Error    string `json:"error"`
+		Kind     string `json:"kind"`
+		ExitCode int    `json:"exitCode"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(stderr[len(stderr)-1]), &trailer))
+	require.Equal(t, "connection", trailer.Kind)
+	require.Equal(t, int(cli.ExitCodeConnection), trailer.ExitCode)
+	require.Contains(t, trailer.Error, "not connected")
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	_, stderr, err = env.Run(t, true, "snapshot", "list", "--error-format=json", "--password=wrong-password")
+	require.Error(t, err)
+	require.Equal(t, cli.ExitCodeAuth, cli.ExitCodeForError(err), "%v", err)
+
+	// without --error-format=json there's no trailer
+	_, stderr, _ = env.Run(t, true, "snapshot", "list", "--password=wrong-password")
+	require.False(t, strings.HasPrefix(stderr[len(stderr)-1], "{"))
+}
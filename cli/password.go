@@ -2,17 +2,25 @@
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
 	"golang.org/x/term"
 
+	"github.com/kopia/kopia/internal/keyprovider"
 	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/repo"
 )
 
+// passwordlessKeyLength is the number of random bytes in keys generated for password-less repositories.
+const passwordlessKeyLength = 32
+
 func askForNewRepositoryPassword(out io.Writer) (string, error) {
 	for {
 		p1, err := askPass(out, "Enter password to create new repository: ")
@@ -73,10 +81,19 @@ func (c *App) getPasswordFromFlags(ctx context.Context, isCreate, allowPersisten
 	case c.password != "":
 		// password provided via --password flag or KOPIA_PASSWORD environment variable
 		return strings.TrimSpace(c.password), nil
+	case c.keyFile != "":
+		// password or key provided via --key-file flag or KOPIA_KEY_FILE environment variable
+		return readKeyFile(c.keyFile)
 	case isCreate:
 		// this is a new repository, ask for password
 		return askForNewRepositoryPassword(c.stdoutWriter)
 	case allowPersistent:
+		// try fetching the password from the key provider configured for the connection.
+		if lc, err := repo.LoadConfigFromFile(c.repositoryConfigFileName()); err == nil && lc.KeyProvider != nil {
+			//nolint:wrapcheck
+			return keyprovider.GetPassword(ctx, lc.KeyProvider)
+		}
+
 		// try fetching the password from persistent storage specific to the configuration file.
 		pass, err := c.passwordPersistenceStrategy().GetPassword(ctx, c.repositoryConfigFileName())
 		if err == nil {
@@ -92,6 +109,74 @@ func (c *App) getPasswordFromFlags(ctx context.Context, isCreate, allowPersisten
 	return askForExistingRepositoryPassword(c.stdoutWriter)
 }
 
+// readKeyFile returns the contents of the provided key file to be used as a repository password.
+func readKeyFile(fname string) (string, error) {
+	b, err := os.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read key file")
+	}
+
+	if len(b) == 0 {
+		return "", errors.Errorf("key file %v is empty", fname)
+	}
+
+	return string(b), nil
+}
+
+// getPasswordlessKey returns the key of a password-less repository stored in the file specified using --key-file
+// and configures the connection to read the key from that file when opening the repository. When creating
+// a repository, a new random key is written to the file if it does not exist.
+func (c *App) getPasswordlessKey(ctx context.Context, co *connectOptions, isCreate bool) (string, error) {
+	if c.keyFile == "" {
+		return "", errors.New("password-less repositories require --key-file")
+	}
+
+	fname, err := filepath.Abs(c.keyFile)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to determine key file path")
+	}
+
+	if _, err := os.Stat(fname); isCreate && errors.Is(err, os.ErrNotExist) {
+		if err := writeNewKeyFile(fname); err != nil {
+			return "", err
+		}
+
+		log(ctx).Infof("Generated new repository key in %v, keep a copy of it in a safe place.", fname)
+	}
+
+	kp := &keyprovider.Config{Type: keyprovider.TypeFile, File: fname}
+
+	key, err := keyprovider.GetPassword(ctx, kp)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read key file")
+	}
+
+	co.keyProvider = kp
+
+	return key, nil
+}
+
+// writeNewKeyFile writes a new random repository key to the provided file, which must not exist.
+func writeNewKeyFile(fname string) error {
+	b := make([]byte, passwordlessKeyLength)
+	if _, err := rand.Read(b); err != nil {
+		return errors.Wrap(err, "unable to generate key")
+	}
+
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600) //nolint:gosec,mnd
+	if err != nil {
+		return errors.Wrap(err, "unable to create key file")
+	}
+
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(b)); err != nil {
+		f.Close() //nolint:errcheck
+
+		return errors.Wrap(err, "unable to write key file")
+	}
+
+	return errors.Wrap(f.Close(), "unable to close key file")
+}
+
 // askPass presents a given prompt and asks the user for password.
 func askPass(out io.Writer, prompt string) (string, error) {
 	for range 5 {
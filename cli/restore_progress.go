@@ -17,14 +17,21 @@ type cliRestoreProgress struct {
 	enqueuedCount      atomic.Int32
 	skippedCount       atomic.Int32
 	ignoredErrorsCount atomic.Int32
+	verifiedCount      atomic.Int32
 
 	restoredTotalFileSize atomic.Int64
 	enqueuedTotalFileSize atomic.Int64
 	skippedTotalFileSize  atomic.Int64
 
+	parallel      atomic.Int32
+	prefetchBytes atomic.Int64
+
 	progressUpdateInterval time.Duration
 	enableProgress         bool
 
+	// verbose includes current auto-tuned restore parameters in the output.
+	verbose bool
+
 	outputThrottle timetrack.Throttle
 	outputMutex    sync.Mutex
 	out            textOutput          // +checklocksignore: outputMutex just happens to be held always.
@@ -38,13 +45,17 @@ func (p *cliRestoreProgress) SetCounters(s restore.Stats) {
 	p.enqueuedCount.Store(s.EnqueuedFileCount + s.EnqueuedDirCount + s.EnqueuedSymlinkCount)
 	p.enqueuedTotalFileSize.Store(s.EnqueuedTotalFileSize)
 
-	p.restoredCount.Store(s.RestoredFileCount + s.RestoredDirCount + s.RestoredSymlinkCount)
+	p.restoredCount.Store(s.RestoredFileCount + s.RestoredDirCount + s.RestoredSymlinkCount + s.RestoredSpecialFileCount)
 	p.restoredTotalFileSize.Store(s.RestoredTotalFileSize)
 
 	p.skippedCount.Store(s.SkippedCount)
 	p.skippedTotalFileSize.Store(s.SkippedTotalFileSize)
 
 	p.ignoredErrorsCount.Store(s.IgnoredErrorCount)
+	p.verifiedCount.Store(s.VerifiedFileCount)
+
+	p.parallel.Store(s.Parallel)
+	p.prefetchBytes.Store(s.PrefetchBytes)
 
 	p.maybeOutput()
 }
@@ -73,6 +84,7 @@ func (p *cliRestoreProgress) output(suffix string) {
 	enqueuedCount := p.enqueuedCount.Load()
 	skippedCount := p.skippedCount.Load()
 	ignoredCount := p.ignoredErrorsCount.Load()
+	verifiedCount := p.verifiedCount.Load()
 
 	restoredSize := p.restoredTotalFileSize.Load()
 	enqueuedSize := p.enqueuedTotalFileSize.Load()
@@ -82,7 +94,7 @@ func (p *cliRestoreProgress) output(suffix string) {
 		return
 	}
 
-	var maybeRemaining, maybeSkipped, maybeErrors string
+	var maybeRemaining, maybeSkipped, maybeErrors, maybeVerified, maybeTuning string
 	if est, ok := p.eta.Estimate(float64(restoredSize), float64(enqueuedSize)); ok {
 		maybeRemaining = fmt.Sprintf(" %v (%.1f%%) remaining %v",
 			units.BytesPerSecondsString(est.SpeedPerSecond),
@@ -98,10 +110,18 @@ func (p *cliRestoreProgress) output(suffix string) {
 		maybeErrors = fmt.Sprintf(", ignored %v errors", ignoredCount)
 	}
 
-	line := fmt.Sprintf("Processed %v (%v) of %v (%v)%v%v%v.",
+	if verifiedCount > 0 {
+		maybeVerified = fmt.Sprintf(", verified %v files", verifiedCount)
+	}
+
+	if p.verbose {
+		maybeTuning = fmt.Sprintf(" [parallel %v, prefetch %v]", p.parallel.Load(), units.BytesString(p.prefetchBytes.Load()))
+	}
+
+	line := fmt.Sprintf("Processed %v (%v) of %v (%v)%v%v%v%v.%v",
 		restoredCount+skippedCount, units.BytesString(restoredSize),
 		enqueuedCount, units.BytesString(enqueuedSize),
-		maybeSkipped, maybeErrors, maybeRemaining,
+		maybeSkipped, maybeErrors, maybeVerified, maybeRemaining, maybeTuning,
 	)
 
 	var extraSpaces string
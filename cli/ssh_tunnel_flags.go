@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/sshtunnel"
+)
+
+// sshTunnelFlags configures an SSH tunnel through which connections to the server are made.
+type sshTunnelFlags struct {
+	tunnel         string
+	keyFile        string
+	knownHostsFile string
+}
+
+func (c *sshTunnelFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("ssh-tunnel", "Connect through an SSH tunnel to the provided server (user@host[:port])").StringVar(&c.tunnel)
+	cmd.Flag("ssh-tunnel-key-file", "Private key used to authenticate with the SSH tunnel server").StringVar(&c.keyFile)
+	cmd.Flag("ssh-tunnel-known-hosts", "Known hosts file used to verify the SSH tunnel server (defaults to ~/.ssh/known_hosts)").StringVar(&c.knownHostsFile)
+}
+
+// options returns the SSH tunnel options or nil if no tunnel was requested.
+func (c *sshTunnelFlags) options() (*sshtunnel.Options, error) {
+	if c.tunnel == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	username, hostPort, ok := strings.Cut(c.tunnel, "@")
+	if !ok || username == "" || hostPort == "" {
+		return nil, errors.Errorf("invalid SSH tunnel %q, must be user@host[:port]", c.tunnel)
+	}
+
+	opt := &sshtunnel.Options{
+		Host:     hostPort,
+		Username: username,
+	}
+
+	if i := strings.LastIndex(hostPort, ":"); i >= 0 && !strings.HasSuffix(hostPort, "]") {
+		port, err := strconv.Atoi(hostPort[i+1:])
+		if err != nil {
+			return nil, errors.Errorf("invalid SSH tunnel port in %q", c.tunnel)
+		}
+
+		opt.Host = hostPort[:i]
+		opt.Port = port
+	}
+
+	opt.Host = strings.TrimSuffix(strings.TrimPrefix(opt.Host, "["), "]")
+
+	if c.keyFile == "" {
+		return nil, errors.New("--ssh-tunnel-key-file is required when using an SSH tunnel")
+	}
+
+	keyFile, err := filepath.Abs(c.keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SSH tunnel key file")
+	}
+
+	opt.Keyfile = keyFile
+
+	if c.knownHostsFile != "" {
+		knownHostsFile, err := filepath.Abs(c.knownHostsFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SSH tunnel known hosts file")
+		}
+
+		opt.KnownHostsFile = knownHostsFile
+	}
+
+	return opt, nil
+}
@@ -13,6 +13,7 @@
 type storageWebDAVFlags struct {
 	options     webdav.Options
 	connectFlat bool
+	sshTunnel   sshTunnelFlags
 }
 
 func (c *storageWebDAVFlags) Setup(svc StorageProviderServices, cmd *kingpin.CmdClause) {
@@ -23,12 +24,21 @@ func (c *storageWebDAVFlags) Setup(svc StorageProviderServices, cmd *kingpin.Cmd
 	cmd.Flag("list-parallelism", "Set list parallelism").Hidden().IntVar(&c.options.ListParallelism)
 	cmd.Flag("atomic-writes", "Assume WebDAV provider implements atomic writes").BoolVar(&c.options.AtomicWrites)
 
+	c.sshTunnel.setup(cmd)
+
 	commonThrottlingFlags(cmd, &c.options.Limits)
 }
 
 func (c *storageWebDAVFlags) Connect(ctx context.Context, isCreate bool, formatVersion int) (blob.Storage, error) {
 	wo := c.options
 
+	tunnel, err := c.sshTunnel.options()
+	if err != nil {
+		return nil, err
+	}
+
+	wo.SSHTunnel = tunnel
+
 	if wo.Username != "" && wo.Password == "" {
 		pass, err := askPass(os.Stdout, "Enter WebDAV password: ")
 		if err != nil {
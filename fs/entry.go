@@ -36,6 +36,12 @@ type DeviceInfo struct {
 	Rdev uint64 `json:"rdev"`
 }
 
+// HasInode is implemented by entries that can report the inode number of the underlying local file,
+// which together with the device uniquely identifies the file on a machine.
+type HasInode interface {
+	Inode() uint64
+}
+
 // Reader allows reading from a file and retrieving its up-to-date file info.
 type Reader interface {
 	io.ReadCloser
@@ -50,6 +56,18 @@ type File interface {
 	Open(ctx context.Context) (Reader, error)
 }
 
+// SpecialFileModes are mode bits of special files: named pipes, sockets and device nodes.
+const SpecialFileModes = os.ModeNamedPipe | os.ModeSocket | os.ModeDevice | os.ModeCharDevice
+
+// SpecialFile represents a named pipe, socket or device node, of which only metadata can be captured.
+// The kind of special file is indicated by the bits of Mode() matching SpecialFileModes.
+type SpecialFile interface {
+	Entry
+
+	// RawDevice returns the device number of a device node, zero for other special files.
+	RawDevice() uint64
+}
+
 // StreamingFile represents an entry that is a stream.
 type StreamingFile interface {
 	Entry
@@ -215,6 +233,24 @@ type Symlink interface {
 	Resolve(ctx context.Context) (Entry, error)
 }
 
+// SymlinkType describes the kind of a symbolic link, which is significant on Windows where links to files,
+// links to directories and directory junctions are distinct kinds of reparse points.
+type SymlinkType string
+
+// Supported symbolic link types.
+const (
+	SymlinkTypeUnknown   SymlinkType = ""
+	SymlinkTypeFile      SymlinkType = "file"
+	SymlinkTypeDirectory SymlinkType = "dir"
+	SymlinkTypeJunction  SymlinkType = "junction"
+)
+
+// TypedSymlink is implemented by symbolic links which know their SymlinkType.
+type TypedSymlink interface {
+	Symlink
+	SymlinkType() SymlinkType
+}
+
 // FindByName returns an entry with a given name, or nil if not found. Assumes
 // the given slice of fs.Entry is sorted.
 func FindByName(entries []Entry, n string) Entry {
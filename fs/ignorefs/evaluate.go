@@ -0,0 +1,102 @@
+package ignorefs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// Decision describes whether a path would be included in a snapshot and why.
+type Decision struct {
+	// Path relative to the snapshot root.
+	Path    string `json:"path"`
+	Ignored bool   `json:"ignored"`
+
+	// Human-readable reason the path is ignored, empty if the path is included.
+	Reason string `json:"reason,omitempty"`
+
+	// Rule that caused the path to be ignored and where it was defined, if applicable.
+	Rule       string `json:"rule,omitempty"`
+	RuleSource string `json:"ruleSource,omitempty"`
+}
+
+// Evaluate determines whether the entry at the provided slash-separated path relative to the root directory
+// would be ignored when snapshotting the root with the provided policy tree. Paths inside ignored directories
+// are reported as ignored because of their ignored ancestor.
+func Evaluate(ctx context.Context, root fs.Directory, policyTree *policy.Tree, relativePath string) (Decision, error) {
+	components := strings.Split(strings.Trim(relativePath, "/"), "/")
+
+	d := &ignoreDirectory{".", &ignoreContext{external: newExternalIgnoreFiles()}, policyTree, root}
+	result := Decision{Path: strings.Join(components, "/")}
+
+	for i, name := range components {
+		if d.skipCacheDirectory(ctx, d.relativePath, d.policyTree) {
+			result.Ignored = true
+			result.Reason = "ancestor " + displayPath(d.relativePath) + " is a cache directory"
+
+			return result, nil
+		}
+
+		ic, err := d.buildContext(ctx)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to load ignore rules for %v", displayPath(d.relativePath))
+		}
+
+		e, err := d.Directory.Child(ctx, name)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to find %v", displayPath(d.relativePath+"/"+name))
+		}
+
+		childPath := d.relativePath + "/" + name
+
+		if reason, rule := ic.exclusionReason(childPath, e, d); reason != "" {
+			result.Ignored = true
+			result.Reason = reason
+
+			if rule != nil {
+				result.Rule = rule.matcher.Pattern()
+				result.RuleSource = rule.source
+			}
+
+			if i < len(components)-1 {
+				result.Reason = "ancestor " + displayPath(childPath) + " is ignored: " + reason
+			}
+
+			return result, nil
+		}
+
+		if i == len(components)-1 {
+			break
+		}
+
+		dir, ok := e.(fs.Directory)
+		if !ok {
+			return result, errors.Errorf("%v is not a directory", displayPath(childPath))
+		}
+
+		d = &ignoreDirectory{childPath, ic, d.policyTree.Child(name), dir}
+	}
+
+	return result, nil
+}
+
+// exclusionReason returns the reason the provided entry is excluded or an empty string if it is included.
+func (c *ignoreContext) exclusionReason(path string, e fs.Entry, parent *ignoreDirectory) (string, *ignoreRule) {
+	if r := c.ignoringRule(path, e.IsDir()); r != nil {
+		return "matches ignore rule '" + r.matcher.Pattern() + "' from " + r.source, r
+	}
+
+	if maxSize := c.maxFileSize; maxSize > 0 && e.Size() > maxSize {
+		return "file is larger than the maximum file size", nil
+	}
+
+	if !c.shouldIncludeByDevice(e, parent) {
+		return "entry is on a different filesystem", nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,92 @@
+package ignorefs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestSharedIgnoreRuleFiles(t *testing.T) {
+	localFile := filepath.Join(testutil.TempDirectory(t), "shared.ignore")
+	require.NoError(t, os.WriteFile(localFile, []byte("# comment\r\nfile1\r\n"), 0o600))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("some-*\n!some-src\n")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	tree := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			FilesPolicy: policy.FilesPolicy{
+				IgnoreRuleFiles: []string{localFile},
+			},
+		},
+		"./src": {
+			FilesPolicy: policy.FilesPolicy{
+				IgnoreRuleFiles: []string{srv.URL + "/rules"},
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	root := setupFilesystem(false)
+	root.Subdir("src").AddFile("some-file", dummyFileContents, 0)
+
+	expectedFiles := addAndSubtractFiles(walkTree(t, root), nil, []string{
+		"./file1",
+		"./src/some-file",
+	})
+
+	verifyDirectoryTree(t, ignorefs.New(root, tree), expectedFiles)
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := setupFilesystem(false)
+	root.AddFileLines(".kopiaignore", []string{"bin/", "file[^1]", "!file3"}, 0)
+
+	cases := []struct {
+		path    string
+		ignored bool
+		rule    string
+		source  string
+	}{
+		{path: "file1"},
+		{path: "file2", ignored: true, rule: "file[^1]", source: "/.kopiaignore"},
+		{path: "file3"},
+		{path: "ignored-by-rule", ignored: true, rule: "*-by-rule", source: "policy for /"},
+		{path: "largefile1", ignored: true},
+		{path: "bin", ignored: true, rule: "bin/", source: "/.kopiaignore"},
+		{path: "bin/some-bin", ignored: true, rule: "bin/", source: "/.kopiaignore"},
+		{path: "src/some-src/f1"},
+	}
+
+	for _, tc := range cases {
+		d, err := ignorefs.Evaluate(ctx, root, defaultPolicy, tc.path)
+		require.NoError(t, err, tc.path)
+		require.Equal(t, tc.path, d.Path)
+		require.Equal(t, tc.ignored, d.Ignored, tc.path)
+		require.Equal(t, tc.rule, d.Rule, tc.path)
+		require.Equal(t, tc.source, d.RuleSource, tc.path)
+
+		if tc.ignored {
+			require.NotEmpty(t, d.Reason)
+		}
+	}
+
+	_, err := ignorefs.Evaluate(ctx, root, defaultPolicy, "no-such-file")
+	require.Error(t, err)
+
+	_, err = ignorefs.Evaluate(ctx, mockfs.NewDirectory(), defaultPolicy, "file1/x")
+	require.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package ignorefs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	externalIgnoreFileTimeout = 30 * time.Second
+	maxExternalIgnoreFileSize = 1 << 20
+)
+
+// externalIgnoreFiles loads shared ignore files referenced by policies from local paths or http(s) URLs.
+// Each file is loaded at most once per snapshot, since many directories may reference the same file.
+type externalIgnoreFiles struct {
+	mu sync.Mutex
+
+	// +checklocks:mu
+	loaded map[string][]byte
+}
+
+func newExternalIgnoreFiles() *externalIgnoreFiles {
+	return &externalIgnoreFiles{
+		loaded: map[string][]byte{},
+	}
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func (e *externalIgnoreFiles) load(ctx context.Context, location string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if data, ok := e.loaded[location]; ok {
+		return data, nil
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if isURL(location) {
+		data, err = fetchURL(ctx, location)
+	} else {
+		data, err = os.ReadFile(location) //nolint:gosec
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	e.loaded[location] = data
+
+	return data, nil
+}
+
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, externalIgnoreFileTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid request")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "request failed")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected HTTP status %v", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxExternalIgnoreFileSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response")
+	}
+
+	if len(data) > maxExternalIgnoreFileSize {
+		return nil, errors.Errorf("ignore file too large, maximum size is %v bytes", maxExternalIgnoreFileSize)
+	}
+
+	return data, nil
+}
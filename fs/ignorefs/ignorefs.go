@@ -3,7 +3,9 @@
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"io"
 	"strings"
 	"sync"
 
@@ -30,39 +32,62 @@ type ignoreContext struct {
 
 	onIgnore []IgnoreCallback
 
-	dotIgnoreFiles []string                  // which files to look for more ignore rules
-	matchers       []wcmatch.WildcardMatcher // current set of rules to ignore files
-	maxFileSize    int64                     // maximum size of file allowed
+	dotIgnoreFiles []string     // which files to look for more ignore rules
+	rules          []ignoreRule // current set of rules to ignore files
+	maxFileSize    int64        // maximum size of file allowed
 
 	oneFileSystem bool // should we enter other mounted filesystems
+
+	external *externalIgnoreFiles // shared cache of ignore files referenced by policies
 }
 
-func (c *ignoreContext) shouldIncludeByName(ctx context.Context, path string, e fs.Entry, policyTree *policy.Tree) bool {
-	shouldIgnore := false
+// ignoreRule is a single ignore pattern along with a description of where it was defined.
+type ignoreRule struct {
+	matcher wcmatch.WildcardMatcher
+	source  string
+}
+
+// ignoringRule returns the rule that causes the provided path to be ignored or nil if the path is included.
+// As in git, the last matching rule wins, so rules defined in lower directories can negate rules from their parents.
+func (c *ignoreContext) ignoringRule(path string, isDir bool) *ignoreRule {
+	var decided *ignoreRule
 
 	// Start by checking with any ignores defined in a parent directory (if there is one).
 	// Any matches here may be negated by .ignore-files in lower directories.
 	if c.parent != nil {
-		shouldIgnore = !c.parent.shouldIncludeByName(ctx, path, e, policyTree)
+		decided = c.parent.ignoringRule(path, isDir)
 	}
 
-	for _, m := range c.matchers {
+	for i := range c.rules {
+		r := &c.rules[i]
+
 		// If we already matched a pattern and concluded that the path should be ignored, we only check
 		// negated patterns (and vice versa)
-		if !shouldIgnore && !m.Negated() || shouldIgnore && m.Negated() {
-			shouldIgnore = m.Match(trimLeadingCurrentDir(path), e.IsDir())
+		if (decided != nil) != r.matcher.Negated() {
+			continue
 		}
-	}
 
-	if shouldIgnore {
-		for _, oi := range c.onIgnore {
-			oi(ctx, strings.TrimPrefix(path, "./"), e, policyTree)
+		switch {
+		case !r.matcher.Match(trimLeadingCurrentDir(path), isDir):
+			decided = nil
+		case !r.matcher.Negated():
+			decided = r
 		}
+	}
 
-		return false
+	return decided
+}
+
+func (c *ignoreContext) shouldIncludeByName(ctx context.Context, path string, e fs.Entry, policyTree *policy.Tree) bool {
+	if c.ignoringRule(path, e.IsDir()) == nil {
+		return true
 	}
 
-	return true
+	for _, oi := range c.onIgnore {
+		oi(ctx, strings.TrimPrefix(path, "./"), e, policyTree)
+	}
+
+	return false
 }
 
 func (c *ignoreContext) shouldIncludeByDevice(e fs.Entry, parent *ignoreDirectory) bool {
@@ -332,10 +357,11 @@ func (d *ignoreDirectory) buildContext(ctx context.Context) (*ignoreContext, err
 		dotIgnoreFiles: effectiveDotIgnoreFiles,
 		maxFileSize:    d.parentContext.maxFileSize,
 		oneFileSystem:  d.parentContext.oneFileSystem,
+		external:       d.parentContext.external,
 	}
 
 	if pol != nil {
-		if err := newic.overrideFromPolicy(&pol.FilesPolicy, d.relativePath); err != nil {
+		if err := newic.overrideFromPolicy(ctx, &pol.FilesPolicy, d.relativePath); err != nil {
 			return nil, err
 		}
 	}
@@ -347,13 +373,13 @@ func (d *ignoreDirectory) buildContext(ctx context.Context) (*ignoreContext, err
 	return newic, nil
 }
 
-func (c *ignoreContext) overrideFromPolicy(fp *policy.FilesPolicy, dirPath string) error {
+func (c *ignoreContext) overrideFromPolicy(ctx context.Context, fp *policy.FilesPolicy, dirPath string) error {
 	if fp.NoParentDotIgnoreFiles {
 		c.dotIgnoreFiles = nil
 	}
 
 	if fp.NoParentIgnoreRules {
-		c.matchers = nil
+		c.rules = nil
 	}
 
 	c.dotIgnoreFiles = combineAndDedupe(c.dotIgnoreFiles, fp.DotIgnoreFiles)
@@ -370,7 +396,22 @@ func (c *ignoreContext) overrideFromPolicy(fp *policy.FilesPolicy, dirPath strin
 			return errors.Wrapf(err, "unable to parse ignore entry %v", dirPath)
 		}
 
-		c.matchers = append(c.matchers, *m)
+		c.rules = append(c.rules, ignoreRule{*m, "policy for " + displayPath(dirPath)})
+	}
+
+	// append rules from shared ignore files referenced by the policy, relative to the directory of the policy.
+	for _, location := range fp.IgnoreRuleFiles {
+		data, err := c.external.load(ctx, location)
+		if err != nil {
+			return errors.Wrapf(err, "unable to load ignore file %v", location)
+		}
+
+		rules, err := parseIgnoreRules(bytes.NewReader(data), dirPath, location)
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse ignore file %v", location)
+		}
+
+		c.rules = append(c.rules, rules...)
 	}
 
 	return nil
@@ -378,12 +419,12 @@ func (c *ignoreContext) overrideFromPolicy(fp *policy.FilesPolicy, dirPath strin
 
 func (c *ignoreContext) loadDotIgnoreFiles(ctx context.Context, dirPath string, dotIgnoreFiles []fs.File) error {
 	for _, f := range dotIgnoreFiles {
-		matchers, err := parseIgnoreFile(ctx, dirPath, f)
+		rules, err := parseIgnoreFile(ctx, dirPath, f)
 		if err != nil {
 			return errors.Wrapf(err, "unable to parse ignore file %v", f.Name())
 		}
 
-		c.matchers = append(c.matchers, matchers...)
+		c.rules = append(c.rules, rules...)
 	}
 
 	return nil
@@ -409,14 +450,19 @@ func combineAndDedupe(slices ...[]string) []string {
 	return result
 }
 
-func parseIgnoreFile(ctx context.Context, baseDir string, file fs.File) ([]wcmatch.WildcardMatcher, error) {
+func parseIgnoreFile(ctx context.Context, baseDir string, file fs.File) ([]ignoreRule, error) {
 	f, err := file.Open(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open ignore file")
 	}
 	defer f.Close() //nolint:errcheck
 
-	var matchers []wcmatch.WildcardMatcher
+	return parseIgnoreRules(f, baseDir, displayPath(baseDir+"/"+file.Name()))
+}
+
+// parseIgnoreRules parses rules in gitignore format, relative to the provided base directory.
+func parseIgnoreRules(r io.Reader, baseDir, source string) ([]ignoreRule, error) {
+	var rules []ignoreRule
 
 	// Remove the "current directory" indicator from the baseDir if present, since wcmatch does
 	// not deal with that.
@@ -424,9 +470,9 @@ func parseIgnoreFile(ctx context.Context, baseDir string, file fs.File) ([]wcmat
 		baseDir = baseDir[1:]
 	}
 
-	s := bufio.NewScanner(f)
+	s := bufio.NewScanner(r)
 	for s.Scan() {
-		line := s.Text()
+		line := strings.TrimSuffix(s.Text(), "\r")
 
 		if strings.HasPrefix(line, "#") {
 			// ignore comments
@@ -443,10 +489,15 @@ func parseIgnoreFile(ctx context.Context, baseDir string, file fs.File) ([]wcmat
 			return nil, errors.Wrapf(err, "unable to parse ignore entry %v", line)
 		}
 
-		matchers = append(matchers, *m)
+		rules = append(rules, ignoreRule{*m, source})
 	}
 
-	return matchers, nil
+	return rules, errors.Wrap(s.Err(), "error reading ignore rules")
+}
+
+// displayPath returns the user-friendly representation of a path relative to the snapshot root.
+func displayPath(relativePath string) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(relativePath, "."), "/")
 }
 
 // trimLeadingCurrentDir strips a leading "./" from a directory, or replace with empty string if the directory contains only a ".".
@@ -463,7 +514,9 @@ func trimLeadingCurrentDir(dir string) string {
 
 // New returns a fs.Directory that wraps another fs.Directory and hides files specified in the ignore dotfiles.
 func New(dir fs.Directory, policyTree *policy.Tree, options ...Option) fs.Directory {
-	rootContext := &ignoreContext{}
+	rootContext := &ignoreContext{
+		external: newExternalIgnoreFiles(),
+	}
 
 	for _, opt := range options {
 		opt(rootContext)
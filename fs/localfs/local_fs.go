@@ -20,6 +20,7 @@ type filesystemEntry struct {
 	mode       os.FileMode
 	owner      fs.OwnerInfo
 	device     fs.DeviceInfo
+	inode      uint64
 
 	prefix string
 }
@@ -60,6 +61,10 @@ func (e *filesystemEntry) Device() fs.DeviceInfo {
 	return e.device
 }
 
+func (e *filesystemEntry) Inode() uint64 {
+	return e.inode
+}
+
 func (e *filesystemEntry) LocalFilesystemPath() string {
 	return e.fullPath()
 }
@@ -70,12 +75,17 @@ type filesystemDirectory struct {
 
 type filesystemSymlink struct {
 	filesystemEntry
+	linkType fs.SymlinkType
 }
 
 type filesystemFile struct {
 	filesystemEntry
 }
 
+type filesystemSpecialFile struct {
+	filesystemEntry
+}
+
 type filesystemErrorEntry struct {
 	filesystemEntry
 	err error
@@ -128,6 +138,21 @@ func (fsl *filesystemSymlink) Resolve(ctx context.Context) (fs.Entry, error) {
 	return entry, err
 }
 
+func (fsl *filesystemSymlink) SymlinkType() fs.SymlinkType {
+	return fsl.linkType
+}
+
+func (fss *filesystemSpecialFile) RawDevice() uint64 {
+	if fss.mode&os.ModeDevice == 0 {
+		return 0
+	}
+
+	return fss.device.Rdev
+}
+
+func (fss *filesystemSpecialFile) Close() {
+}
+
 func (e *filesystemErrorEntry) ErrorInfo() error {
 	return e.err
 }
@@ -166,8 +191,10 @@ func Directory(path string) (fs.Directory, error) {
 }
 
 var (
-	_ fs.Directory  = (*filesystemDirectory)(nil)
-	_ fs.File       = (*filesystemFile)(nil)
-	_ fs.Symlink    = (*filesystemSymlink)(nil)
-	_ fs.ErrorEntry = (*filesystemErrorEntry)(nil)
+	_ fs.Directory    = (*filesystemDirectory)(nil)
+	_ fs.File         = (*filesystemFile)(nil)
+	_ fs.Symlink      = (*filesystemSymlink)(nil)
+	_ fs.TypedSymlink = (*filesystemSymlink)(nil)
+	_ fs.SpecialFile  = (*filesystemSpecialFile)(nil)
+	_ fs.ErrorEntry   = (*filesystemErrorEntry)(nil)
 )
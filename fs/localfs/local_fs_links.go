@@ -0,0 +1,43 @@
+package localfs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// FollowSymlink returns the entry a local symbolic link or directory junction points to, under the name of the link,
+// so that it can be snapshotted in place of the link.
+func FollowSymlink(e fs.Symlink) (fs.Entry, error) {
+	fsl, ok := e.(*filesystemSymlink)
+	if !ok {
+		return nil, errors.Errorf("not a local symbolic link: %T", e)
+	}
+
+	path := fsl.fullPath()
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read link")
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+
+	// following a link to its own ancestor would make the snapshot infinitely deep.
+	if rel, err := filepath.Rel(filepath.Clean(target), filepath.Dir(path)); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, errors.Errorf("%v links to its own ancestor %v", path, target)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to stat link target")
+	}
+
+	return entryFromDirEntry(fi, fsl.prefix), nil
+}
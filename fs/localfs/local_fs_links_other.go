@@ -0,0 +1,22 @@
+//go:build !windows
+
+package localfs
+
+import (
+	"os"
+
+	"github.com/kopia/kopia/fs"
+)
+
+//nolint:revive
+func platformSpecificEntryType(fi os.FileInfo, prefix string) (os.FileMode, fs.SymlinkType) {
+	return fi.Mode() & os.ModeType, fs.SymlinkTypeUnknown
+}
+
+// CreateSymlink creates a symbolic link at the provided path, the link type is only significant on Windows.
+//
+//nolint:revive
+func CreateSymlink(target, path string, linkType fs.SymlinkType) error {
+	//nolint:wrapcheck
+	return os.Symlink(target, path)
+}
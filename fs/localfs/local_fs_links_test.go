@@ -0,0 +1,66 @@
+package localfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestFollowSymlink(t *testing.T) {
+	ctx := testlogging.Context(t)
+	tmp := testutil.TempDirectory(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "data", "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "data", "a.txt"), []byte{1, 2, 3}, 0o644))
+	require.NoError(t, CreateSymlink("data", filepath.Join(tmp, "dirlink"), fs.SymlinkTypeDirectory))
+	require.NoError(t, CreateSymlink(filepath.Join("data", "a.txt"), filepath.Join(tmp, "filelink"), fs.SymlinkTypeFile))
+	require.NoError(t, CreateSymlink("..", filepath.Join(tmp, "data", "sub", "parent"), fs.SymlinkTypeDirectory))
+	require.NoError(t, CreateSymlink(".", filepath.Join(tmp, "data", "self"), fs.SymlinkTypeDirectory))
+
+	d := followLink(t, filepath.Join(tmp, "dirlink"))
+	require.Equal(t, "dirlink", d.Name())
+	require.Equal(t, filepath.Join(tmp, "dirlink"), d.LocalFilesystemPath())
+
+	dir, ok := d.(fs.Directory)
+	require.True(t, ok, "not a directory: %T", d)
+
+	child, err := dir.Child(ctx, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), child.Size())
+
+	f := followLink(t, filepath.Join(tmp, "filelink"))
+	require.Equal(t, "filelink", f.Name())
+	require.Equal(t, int64(3), f.Size())
+
+	_, ok = f.(fs.File)
+	require.True(t, ok, "not a file: %T", f)
+
+	for _, p := range []string{filepath.Join(tmp, "data", "sub", "parent"), filepath.Join(tmp, "data", "self")} {
+		e, err := NewEntry(p)
+		require.NoError(t, err)
+
+		_, err = FollowSymlink(e.(fs.Symlink))
+		require.ErrorContains(t, err, "links to its own ancestor", p)
+	}
+}
+
+func followLink(t *testing.T, path string) fs.Entry {
+	t.Helper()
+
+	e, err := NewEntry(path)
+	require.NoError(t, err)
+
+	link, ok := e.(fs.Symlink)
+	require.True(t, ok, "not a symlink: %T", e)
+
+	result, err := FollowSymlink(link)
+	require.NoError(t, err)
+
+	return result
+}
@@ -0,0 +1,237 @@
+package localfs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	symbolicLinkFlagAllowUnprivilegedCreate = 0x2
+
+	// size of ReparseTag, ReparseDataLength and Reserved fields of REPARSE_DATA_BUFFER.
+	reparseHeaderSize = 8
+
+	// size of name offsets and lengths of MountPointReparseBuffer.
+	mountPointHeaderSize = 8
+
+	// prefix of NT paths of volume mount points, which are not treated as junctions.
+	volumeMountPointPrefix = `\??\Volume{`
+)
+
+// platformSpecificEntryType determines the entry type of reparse points, which os.Lstat() reports inconsistently:
+// symbolic links and directory junctions are treated as symbolic links, other reparse points (volume mount points,
+// deduplicated files, cloud file placeholders, etc.) are treated as regular files and directories whose contents are read through.
+func platformSpecificEntryType(fi os.FileInfo, prefix string) (os.FileMode, fs.SymlinkType) {
+	mode := fi.Mode() & os.ModeType
+
+	fad, ok := fi.Sys().(*syscall.Win32FileAttributeData)
+	if !ok || fad.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+		return mode, fs.SymlinkTypeUnknown
+	}
+
+	isDir := fad.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY != 0
+
+	tag, substituteName, err := readReparsePoint(prefix + fi.Name())
+	if err != nil {
+		return mode, fs.SymlinkTypeUnknown
+	}
+
+	switch {
+	case tag == windows.IO_REPARSE_TAG_SYMLINK && isDir:
+		return os.ModeSymlink, fs.SymlinkTypeDirectory
+
+	case tag == windows.IO_REPARSE_TAG_SYMLINK:
+		return os.ModeSymlink, fs.SymlinkTypeFile
+
+	case tag == windows.IO_REPARSE_TAG_MOUNT_POINT && !strings.HasPrefix(substituteName, volumeMountPointPrefix):
+		return os.ModeSymlink, fs.SymlinkTypeJunction
+
+	case isDir:
+		return os.ModeDir, fs.SymlinkTypeUnknown
+
+	default:
+		return 0, fs.SymlinkTypeUnknown
+	}
+}
+
+// readReparsePoint returns the tag of the reparse point at the provided path and, for directory junctions, the substitute name.
+func readReparsePoint(path string) (tag uint32, substituteName string, err error) {
+	h, err := openReparsePoint(path, 0)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	buf := make([]byte, windows.MAXIMUM_REPARSE_DATA_BUFFER_SIZE)
+
+	var n uint32
+	if err := windows.DeviceIoControl(h, windows.FSCTL_GET_REPARSE_POINT, nil, 0, &buf[0], uint32(len(buf)), &n, nil); err != nil {
+		return 0, "", errors.Wrap(err, "FSCTL_GET_REPARSE_POINT")
+	}
+
+	buf = buf[:n]
+	if len(buf) < reparseHeaderSize {
+		return 0, "", errors.New("reparse data too short")
+	}
+
+	tag = binary.LittleEndian.Uint32(buf)
+
+	if tag != windows.IO_REPARSE_TAG_MOUNT_POINT || len(buf) < reparseHeaderSize+mountPointHeaderSize {
+		return tag, "", nil
+	}
+
+	data := buf[reparseHeaderSize:]
+	off := int(binary.LittleEndian.Uint16(data[0:]))
+	length := int(binary.LittleEndian.Uint16(data[2:]))
+	names := data[mountPointHeaderSize:]
+
+	if off+length > len(names) {
+		return 0, "", errors.New("invalid mount point reparse data")
+	}
+
+	return tag, utf16BytesToString(names[off : off+length]), nil
+}
+
+func openReparsePoint(path string, access uint32) (windows.Handle, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return windows.InvalidHandle, errors.Wrap(err, "invalid path")
+	}
+
+	h, err := windows.CreateFile(p, access,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OPEN_REPARSE_POINT|windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return windows.InvalidHandle, errors.Wrapf(err, "unable to open %v", path)
+	}
+
+	return h, nil
+}
+
+func utf16BytesToString(b []byte) string {
+	u := make([]uint16, len(b)/2) //nolint:mnd
+
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+
+	return string(utf16.Decode(u))
+}
+
+func stringToUTF16Bytes(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u)) //nolint:mnd
+
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], c)
+	}
+
+	return b
+}
+
+// CreateSymlink creates a symbolic link or directory junction of the provided type at the provided path.
+// When the type is unknown, the kind of symbolic link is determined based on the target, as done by os.Symlink().
+func CreateSymlink(target, path string, linkType fs.SymlinkType) error {
+	switch linkType {
+	case fs.SymlinkTypeFile:
+		return createSymbolicLink(target, path, 0)
+
+	case fs.SymlinkTypeDirectory:
+		return createSymbolicLink(target, path, windows.SYMBOLIC_LINK_FLAG_DIRECTORY)
+
+	case fs.SymlinkTypeJunction:
+		return createJunction(target, path)
+
+	default:
+		//nolint:wrapcheck
+		return os.Symlink(target, path)
+	}
+}
+
+func createSymbolicLink(target, path string, flags uint32) error {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return errors.Wrap(err, "invalid path")
+	}
+
+	t, err := windows.UTF16PtrFromString(filepath.FromSlash(target))
+	if err != nil {
+		return errors.Wrap(err, "invalid target")
+	}
+
+	err = windows.CreateSymbolicLink(p, t, flags|symbolicLinkFlagAllowUnprivilegedCreate)
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) {
+		// older versions of Windows don't support unprivileged creation of symbolic links.
+		err = windows.CreateSymbolicLink(p, t, flags)
+	}
+
+	return errors.Wrap(err, "unable to create symbolic link")
+}
+
+// createJunction creates a directory junction, which must point to an absolute path.
+func createJunction(target, path string) error {
+	target = filepath.FromSlash(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return errors.Wrap(err, "invalid junction target")
+	}
+
+	if err := os.Mkdir(path, 0o700); err != nil { //nolint:mnd
+		return errors.Wrap(err, "unable to create junction directory")
+	}
+
+	if err := setMountPointReparseData(path, `\??\`+target, target); err != nil {
+		os.Remove(path) //nolint:errcheck
+
+		return err
+	}
+
+	return nil
+}
+
+func setMountPointReparseData(path, substituteName, printName string) error {
+	sub := stringToUTF16Bytes(substituteName)
+	prn := stringToUTF16Bytes(printName)
+
+	// both names are NUL-terminated.
+	names := make([]byte, 0, len(sub)+len(prn)+4) //nolint:mnd
+	names = append(names, sub...)
+	names = append(names, 0, 0)
+	names = append(names, prn...)
+	names = append(names, 0, 0)
+
+	buf := make([]byte, reparseHeaderSize+mountPointHeaderSize+len(names))
+	binary.LittleEndian.PutUint32(buf[0:], windows.IO_REPARSE_TAG_MOUNT_POINT)
+	binary.LittleEndian.PutUint16(buf[4:], uint16(mountPointHeaderSize+len(names))) //nolint:gosec
+	binary.LittleEndian.PutUint16(buf[8:], 0)
+	binary.LittleEndian.PutUint16(buf[10:], uint16(len(sub)))   //nolint:gosec
+	binary.LittleEndian.PutUint16(buf[12:], uint16(len(sub)+2)) //nolint:gosec,mnd
+	binary.LittleEndian.PutUint16(buf[14:], uint16(len(prn)))   //nolint:gosec
+	copy(buf[reparseHeaderSize+mountPointHeaderSize:], names)
+
+	h, err := openReparsePoint(path, windows.GENERIC_WRITE)
+	if err != nil {
+		return err
+	}
+
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	var n uint32
+
+	return errors.Wrap(windows.DeviceIoControl(h, windows.FSCTL_SET_REPARSE_POINT, &buf[0], uint32(len(buf)), nil, 0, &n, nil), "FSCTL_SET_REPARSE_POINT") //nolint:gosec
+}
@@ -104,8 +104,8 @@ func toDirEntryOrNil(dirEntry os.DirEntry, prefix string) (fs.Entry, error) {
 	return entryFromDirEntry(fi, prefix), nil
 }
 
-// NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink
-// or fs.UnsupportedEntry.
+// NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink,
+// fs.SpecialFile or fs.ErrorEntry.
 func NewEntry(path string) (fs.Entry, error) {
 	path = filepath.Clean(path)
 
@@ -136,26 +136,32 @@ func NewEntry(path string) (fs.Entry, error) {
 
 func entryFromDirEntry(fi os.FileInfo, prefix string) fs.Entry {
 	isplaceholder := strings.HasSuffix(fi.Name(), ShallowEntrySuffix)
-	maskedmode := fi.Mode() & os.ModeType
+	maskedmode, linkType := platformSpecificEntryType(fi, prefix)
+
+	e := newEntry(fi, prefix)
+	e.mode = e.mode&^os.ModeType | maskedmode
 
 	switch {
 	case maskedmode == os.ModeDir && !isplaceholder:
-		return newFilesystemDirectory(newEntry(fi, prefix))
+		return newFilesystemDirectory(e)
 
 	case maskedmode == os.ModeDir && isplaceholder:
-		return newShallowFilesystemDirectory(newEntry(fi, prefix))
+		return newShallowFilesystemDirectory(e)
 
 	case maskedmode == os.ModeSymlink && !isplaceholder:
-		return newFilesystemSymlink(newEntry(fi, prefix))
+		return newFilesystemSymlink(e, linkType)
 
 	case maskedmode == 0 && !isplaceholder:
-		return newFilesystemFile(newEntry(fi, prefix))
+		return newFilesystemFile(e)
 
 	case maskedmode == 0 && isplaceholder:
-		return newShallowFilesystemFile(newEntry(fi, prefix))
+		return newShallowFilesystemFile(e)
+
+	case maskedmode&fs.SpecialFileModes != 0 && maskedmode&^fs.SpecialFileModes == 0:
+		return &filesystemSpecialFile{e}
 
 	default:
-		return newFilesystemErrorEntry(newEntry(fi, prefix), fs.ErrUnknown)
+		return newFilesystemErrorEntry(e, fs.ErrUnknown)
 	}
 }
 
@@ -169,6 +175,7 @@ func newEntry(fi os.FileInfo, prefix string) filesystemEntry {
 		fi.Mode(),
 		platformSpecificOwnerInfo(fi),
 		platformSpecificDeviceInfo(fi),
+		platformSpecificInode(fi),
 		prefix,
 	}
 }
@@ -1,6 +1,9 @@
 package localfs
 
-import "github.com/kopia/kopia/internal/freepool"
+import (
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/freepool"
+)
 
 //nolint:gochecknoglobals
 var (
@@ -34,9 +37,10 @@ func (fsd *filesystemDirectory) Close() {
 	filesystemDirectoryPool.Return(fsd)
 }
 
-func newFilesystemSymlink(e filesystemEntry) *filesystemSymlink {
+func newFilesystemSymlink(e filesystemEntry, linkType fs.SymlinkType) *filesystemSymlink {
 	fsd := filesystemSymlinkPool.Take()
 	fsd.filesystemEntry = e
+	fsd.linkType = linkType
 
 	return fsd
 }
@@ -0,0 +1,42 @@
+package localfs
+
+import (
+	"sort"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// ReadExtendedAttributes returns extended attributes of the provided kinds of the local filesystem entry
+// at the provided path, without following symbolic links. Filesystems that don't support extended attributes
+// report no attributes.
+func ReadExtendedAttributes(path string, kinds fs.ExtendedAttributeKind) (fs.ExtendedAttributes, error) {
+	if kinds == fs.ExtendedAttributesNone {
+		return nil, nil
+	}
+
+	return platformReadExtendedAttributes(path, kinds)
+}
+
+// WriteExtendedAttributes sets extended attributes of the provided kinds on the local filesystem entry
+// at the provided path. Attributes that can't be represented on the current platform are skipped.
+func WriteExtendedAttributes(path string, attrs fs.ExtendedAttributes, kinds fs.ExtendedAttributeKind) error {
+	attrs = attrs.Filter(kinds)
+
+	var names []string
+
+	for n := range attrs {
+		if platformSupportsExtendedAttribute(n) {
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, n := range names {
+		if err := platformWriteExtendedAttribute(path, n, attrs[n]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package localfs
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const errNoAttribute = unix.ENOATTR
+
+// platformSupportsExtendedAttribute returns false for attributes that represent metadata of other
+// platforms, which macOS would otherwise store as regular extended attributes.
+func platformSupportsExtendedAttribute(name string) bool {
+	switch {
+	case strings.HasPrefix(name, "system.posix_acl_"):
+		return false
+	case name == fs.WindowsSecurityAttributeName, strings.HasPrefix(name, fs.WindowsAlternateDataStreamAttributePrefix):
+		return false
+	default:
+		return true
+	}
+}
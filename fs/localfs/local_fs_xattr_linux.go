@@ -0,0 +1,20 @@
+package localfs
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const errNoAttribute = unix.ENODATA
+
+// platformSupportsExtendedAttribute returns true for attributes in one of the namespaces supported by Linux.
+func platformSupportsExtendedAttribute(name string) bool {
+	for _, ns := range []string{"user.", "trusted.", "security.", "system."} {
+		if strings.HasPrefix(name, ns) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !windows
+
+package localfs
+
+import (
+	"github.com/kopia/kopia/fs"
+)
+
+// extended attributes are not supported on this platform.
+
+//nolint:revive
+func platformReadExtendedAttributes(path string, kinds fs.ExtendedAttributeKind) (fs.ExtendedAttributes, error) {
+	return nil, nil
+}
+
+//nolint:revive
+func platformSupportsExtendedAttribute(name string) bool {
+	return false
+}
+
+//nolint:revive
+func platformWriteExtendedAttribute(path, name string, value []byte) error {
+	return nil
+}
@@ -0,0 +1,112 @@
+//go:build linux
+
+package localfs
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+// posixACL returns a POSIX ACL in the format of system.posix_acl_access attribute granting read access to the provided user.
+func posixACL(uid uint32) []byte {
+	const (
+		aclVersion  = 2
+		aclUserObj  = 0x01
+		aclUser     = 0x02
+		aclGroupObj = 0x04
+		aclMask     = 0x10
+		aclOther    = 0x20
+		undefinedID = 0xffffffff
+	)
+
+	b := binary.LittleEndian.AppendUint32(nil, aclVersion)
+
+	for _, e := range []struct {
+		tag  uint16
+		perm uint16
+		id   uint32
+	}{
+		{aclUserObj, 6, undefinedID},
+		{aclUser, 4, uid},
+		{aclGroupObj, 4, undefinedID},
+		{aclMask, 4, undefinedID},
+		{aclOther, 0, undefinedID},
+	} {
+		b = binary.LittleEndian.AppendUint16(b, e.tag)
+		b = binary.LittleEndian.AppendUint16(b, e.perm)
+		b = binary.LittleEndian.AppendUint32(b, e.id)
+	}
+
+	return b
+}
+
+func TestExtendedAttributesRoundTrip(t *testing.T) {
+	tmp := testutil.TempDirectory(t)
+
+	src := filepath.Join(tmp, "src")
+	require.NoError(t, os.WriteFile(src, []byte("hello"), 0o600))
+
+	if err := unix.Setxattr(src, "user.kopia.test", []byte("some-value"), 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skip("extended attributes are not supported")
+		}
+
+		require.NoError(t, err)
+	}
+
+	if err := unix.Setxattr(src, "system.posix_acl_access", posixACL(12345), 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skip("POSIX ACLs are not supported")
+		}
+
+		require.NoError(t, err)
+	}
+
+	none, err := ReadExtendedAttributes(src, fs.ExtendedAttributesNone)
+	require.NoError(t, err)
+	require.Empty(t, none)
+
+	generic, err := ReadExtendedAttributes(src, fs.ExtendedAttributesGeneric)
+	require.NoError(t, err)
+	require.Equal(t, fs.ExtendedAttributes{"user.kopia.test": []byte("some-value")}, generic.Filter(fs.ExtendedAttributesGeneric))
+	require.NotContains(t, generic, "system.posix_acl_access")
+
+	all, err := ReadExtendedAttributes(src, fs.ExtendedAttributesAll)
+	require.NoError(t, err)
+	require.Equal(t, []byte("some-value"), all["user.kopia.test"])
+	require.Equal(t, posixACL(12345), all["system.posix_acl_access"])
+
+	// attributes of other platforms are skipped.
+	all[fs.WindowsSecurityAttributeName] = []byte("O:BAG:BAD:(A;;FA;;;BA)")
+	all["com.apple.FinderInfo"] = make([]byte, 32)
+
+	dst := filepath.Join(tmp, "dst")
+	require.NoError(t, os.WriteFile(dst, []byte("hello"), 0o600))
+	require.NoError(t, WriteExtendedAttributes(dst, all, fs.ExtendedAttributesAll))
+
+	got, err := ReadExtendedAttributes(dst, fs.ExtendedAttributesAll)
+	require.NoError(t, err)
+	require.Equal(t, []byte("some-value"), got["user.kopia.test"])
+	require.Equal(t, posixACL(12345), got["system.posix_acl_access"])
+	require.NotContains(t, got, fs.WindowsSecurityAttributeName)
+	require.NotContains(t, got, "com.apple.FinderInfo")
+
+	// only attributes of the requested kinds are written.
+	dst2 := filepath.Join(tmp, "dst2")
+	require.NoError(t, os.WriteFile(dst2, []byte("hello"), 0o600))
+	require.NoError(t, WriteExtendedAttributes(dst2, all, fs.ExtendedAttributesACL))
+
+	got, err = ReadExtendedAttributes(dst2, fs.ExtendedAttributesAll)
+	require.NoError(t, err)
+	require.NotContains(t, got, "user.kopia.test")
+	require.Equal(t, posixACL(12345), got["system.posix_acl_access"])
+}
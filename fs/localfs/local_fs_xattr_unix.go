@@ -0,0 +1,112 @@
+//go:build linux || darwin
+
+package localfs
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func platformReadExtendedAttributes(path string, kinds fs.ExtendedAttributeKind) (fs.ExtendedAttributes, error) {
+	names, err := listxattr(path)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+
+		return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+
+	var result fs.ExtendedAttributes
+
+	for _, n := range names {
+		if !kinds.Includes(n) {
+			continue
+		}
+
+		v, err := getxattr(path, n)
+		if err != nil {
+			if errors.Is(err, errNoAttribute) {
+				// attribute has been removed in the meantime.
+				continue
+			}
+
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+
+		if result == nil {
+			result = fs.ExtendedAttributes{}
+		}
+
+		result[n] = v
+	}
+
+	return result, nil
+}
+
+func platformWriteExtendedAttribute(path, name string, value []byte) error {
+	if err := unix.Lsetxattr(path, name, value, 0); err != nil {
+		return &os.PathError{Op: "setxattr " + name, Path: path, Err: err}
+	}
+
+	return nil
+}
+
+func listxattr(path string) ([]string, error) {
+	for {
+		sz, err := unix.Llistxattr(path, nil)
+		if err != nil || sz == 0 {
+			return nil, err //nolint:wrapcheck
+		}
+
+		buf := make([]byte, sz)
+
+		n, err := unix.Llistxattr(path, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// attributes have been added in the meantime, retry.
+			continue
+		}
+
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		var names []string
+
+		for _, n := range bytes.Split(buf[:n], []byte{0}) {
+			if len(n) > 0 {
+				names = append(names, string(n))
+			}
+		}
+
+		return names, nil
+	}
+}
+
+func getxattr(path, name string) ([]byte, error) {
+	for {
+		sz, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		buf := make([]byte, sz)
+
+		n, err := unix.Lgetxattr(path, name, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// attribute has grown in the meantime, retry.
+			continue
+		}
+
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return buf[:n], nil
+	}
+}
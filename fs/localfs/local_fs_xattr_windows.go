@@ -0,0 +1,151 @@
+package localfs
+
+import (
+	"os"
+	"strings"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	securityInformation = windows.OWNER_SECURITY_INFORMATION | windows.GROUP_SECURITY_INFORMATION | windows.DACL_SECURITY_INFORMATION
+
+	findStreamInfoStandard = 0
+)
+
+var (
+	modkernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	procFindFirstStreamW = modkernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = modkernel32.NewProc("FindNextStreamW")
+)
+
+// win32FindStreamData corresponds to WIN32_FIND_STREAM_DATA.
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [windows.MAX_PATH + 36]uint16
+}
+
+func platformReadExtendedAttributes(path string, kinds fs.ExtendedAttributeKind) (fs.ExtendedAttributes, error) {
+	result := fs.ExtendedAttributes{}
+
+	if kinds&fs.ExtendedAttributesACL != 0 {
+		sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, securityInformation)
+		if err != nil {
+			return nil, &os.PathError{Op: "GetNamedSecurityInfo", Path: path, Err: err}
+		}
+
+		result[fs.WindowsSecurityAttributeName] = []byte(sd.String())
+	}
+
+	if kinds&fs.ExtendedAttributesPlatform != 0 {
+		streams, err := listAlternateDataStreams(path)
+		if err != nil {
+			return nil, &os.PathError{Op: "FindFirstStream", Path: path, Err: err}
+		}
+
+		for _, s := range streams {
+			v, err := os.ReadFile(path + ":" + s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read alternate data stream %q", s)
+			}
+
+			result[fs.WindowsAlternateDataStreamAttributePrefix+s] = v
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+func platformSupportsExtendedAttribute(name string) bool {
+	return name == fs.WindowsSecurityAttributeName || strings.HasPrefix(name, fs.WindowsAlternateDataStreamAttributePrefix)
+}
+
+func platformWriteExtendedAttribute(path, name string, value []byte) error {
+	if name == fs.WindowsSecurityAttributeName {
+		return writeSecurityDescriptor(path, string(value))
+	}
+
+	stream := strings.TrimPrefix(name, fs.WindowsAlternateDataStreamAttributePrefix)
+
+	//nolint:wrapcheck
+	return os.WriteFile(path+":"+stream, value, 0o600) //nolint:mnd
+}
+
+// writeSecurityDescriptor applies the access control list from the provided security descriptor.
+// Owner and group are not restored since this requires additional privileges.
+func writeSecurityDescriptor(path, sddl string) error {
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		return errors.Wrap(err, "invalid security descriptor")
+	}
+
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return errors.Wrap(err, "unable to get DACL")
+	}
+
+	control, _, err := sd.Control()
+	if err != nil {
+		return errors.Wrap(err, "unable to get security descriptor control")
+	}
+
+	si := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION)
+	if control&windows.SE_DACL_PROTECTED != 0 {
+		si |= windows.PROTECTED_DACL_SECURITY_INFORMATION
+	} else {
+		si |= windows.UNPROTECTED_DACL_SECURITY_INFORMATION
+	}
+
+	if err := windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, si, nil, nil, dacl, nil); err != nil {
+		return &os.PathError{Op: "SetNamedSecurityInfo", Path: path, Err: err}
+	}
+
+	return nil
+}
+
+// listAlternateDataStreams returns names of alternate data streams of the provided file, excluding the main stream.
+func listAlternateDataStreams(path string) ([]string, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid path")
+	}
+
+	var data win32FindStreamData
+
+	h, _, err := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(p)), findStreamInfoStandard, uintptr(unsafe.Pointer(&data)), 0)
+	if windows.Handle(h) == windows.InvalidHandle {
+		if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+			// no streams, for example a directory.
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	defer windows.FindClose(windows.Handle(h)) //nolint:errcheck
+
+	var names []string
+
+	for {
+		// stream names are in the form ":name:$DATA", the main stream is "::$DATA".
+		if n := strings.TrimPrefix(strings.TrimSuffix(windows.UTF16ToString(data.StreamName[:]), ":$DATA"), ":"); n != "" {
+			names = append(names, n)
+		}
+
+		if r, _, err := procFindNextStreamW.Call(h, uintptr(unsafe.Pointer(&data))); r == 0 {
+			if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+				return names, nil
+			}
+
+			return nil, err
+		}
+	}
+}
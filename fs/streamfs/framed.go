@@ -0,0 +1,169 @@
+package streamfs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// FramedHeader is the JSON representation of an entry header in the framed stream format.
+//
+// Each entry of a framed stream starts with a header encoded as a single line of JSON.
+// Headers of files are followed by their contents, which is either exactly Size bytes
+// or, when Chunked is set, a sequence of chunks, each preceded by a line with
+// its decimal length and followed by a newline, terminated by a zero-length chunk.
+type FramedHeader struct {
+	Path       string    `json:"path"`
+	Type       EntryType `json:"type"`
+	Mode       uint32    `json:"mode,omitempty"`
+	ModTime    time.Time `json:"mtime"`
+	UserID     uint32    `json:"uid,omitempty"`
+	GroupID    uint32    `json:"gid,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	Chunked    bool      `json:"chunked,omitempty"`
+	LinkTarget string    `json:"target,omitempty"`
+}
+
+type framedSource struct {
+	r *bufio.Reader
+
+	// body of the previous entry, which must be fully consumed before reading the next header.
+	body io.Reader
+}
+
+func (s *framedSource) Next(_ context.Context) (*Header, error) {
+	if s.body != nil {
+		if _, err := io.Copy(io.Discard, s.body); err != nil {
+			return nil, errors.Wrap(err, "error skipping entry contents")
+		}
+
+		s.body = nil
+	}
+
+	line, err := s.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) && len(strings.TrimSpace(string(line))) == 0 {
+		return nil, io.EOF
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "error reading entry header")
+	}
+
+	var fh FramedHeader
+
+	if err := json.Unmarshal(line, &fh); err != nil {
+		return nil, errors.Wrap(err, "invalid entry header")
+	}
+
+	h := &Header{
+		Path:       fh.Path,
+		Type:       fh.Type,
+		Mode:       os.FileMode(fh.Mode).Perm(),
+		ModTime:    fh.ModTime,
+		Owner:      fs.OwnerInfo{UserID: fh.UserID, GroupID: fh.GroupID},
+		Size:       fh.Size,
+		LinkTarget: fh.LinkTarget,
+	}
+
+	if h.Type != EntryTypeFile {
+		return h, nil
+	}
+
+	switch {
+	case fh.Chunked:
+		s.body = &chunkedReader{r: s.r}
+
+	case fh.Size < 0:
+		return nil, errors.Errorf("invalid size of entry %q", fh.Path)
+
+	default:
+		s.body = &exactReader{r: io.LimitReader(s.r, fh.Size), remaining: fh.Size}
+	}
+
+	h.Body = s.body
+
+	return h, nil
+}
+
+// exactReader returns io.ErrUnexpectedEOF if the underlying stream ends before all contents have been read.
+type exactReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (e *exactReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	e.remaining -= int64(n)
+
+	if errors.Is(err, io.EOF) && e.remaining > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// chunkedReader reads contents encoded as a sequence of length-prefixed chunks.
+type chunkedReader struct {
+	r         *bufio.Reader
+	remaining int64
+	inChunk   bool
+	done      bool
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		if c.inChunk {
+			if b, err := c.r.ReadByte(); err != nil || b != '\n' {
+				return 0, errors.New("missing newline after chunk")
+			}
+
+			c.inChunk = false
+		}
+
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return 0, errors.Wrap(io.ErrUnexpectedEOF, "error reading chunk length")
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64) //nolint:mnd
+		if err != nil || n < 0 {
+			return 0, errors.Errorf("invalid chunk length %q", strings.TrimSpace(line))
+		}
+
+		c.remaining = n
+		c.inChunk = n > 0
+		c.done = n == 0
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+
+	if errors.Is(err, io.EOF) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// FramedSource returns a Source that reads entries from a stream in the framed format described by FramedHeader,
+// which allows producers to stream contents of unknown length along with their metadata.
+func FramedSource(r io.Reader) Source {
+	return &framedSource{r: bufio.NewReader(r)}
+}
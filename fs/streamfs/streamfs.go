@@ -0,0 +1,217 @@
+// Package streamfs converts sequential streams of filesystem entries (such as tar archives)
+// into a tree of virtual directories that can be snapshotted in a single pass.
+//
+// Entries are produced on the fly as the tree is iterated, so the tree must be consumed
+// sequentially and depth-first, which requires entries of each directory to appear in the
+// stream contiguously (which is the case for archives produced by tar and similar tools).
+package streamfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+)
+
+const defaultDirectoryPermissions os.FileMode = 0o755
+
+// EntryType is the type of a stream entry.
+type EntryType string
+
+// Supported entry types.
+const (
+	EntryTypeFile      EntryType = "file"
+	EntryTypeDirectory EntryType = "dir"
+	EntryTypeSymlink   EntryType = "symlink"
+)
+
+// Header describes a single entry in the stream.
+type Header struct {
+	// Path is a slash-separated path of the entry relative to the root of the stream.
+	Path string
+
+	Type    EntryType
+	Mode    os.FileMode
+	ModTime time.Time
+	Owner   fs.OwnerInfo
+	Size    int64
+
+	// LinkTarget is the target of a symbolic link.
+	LinkTarget string
+
+	// Body provides contents of a file, it is only valid until the next entry is read.
+	Body io.Reader
+}
+
+// Source provides subsequent entries of a stream.
+type Source interface {
+	// Next returns the next entry in the stream or io.EOF when there are no more entries.
+	Next(ctx context.Context) (*Header, error)
+}
+
+// stream wraps a Source with single-entry lookahead shared by all directories of the tree.
+type stream struct {
+	src Source
+
+	next *Header
+	err  error
+}
+
+func (s *stream) peek(ctx context.Context) (*Header, error) {
+	for s.next == nil && s.err == nil {
+		h, err := s.src.Next(ctx)
+		if err != nil {
+			s.err = err
+			break
+		}
+
+		p := normalizePath(h.Path)
+		if p == "" {
+			// entry describing the root directory itself.
+			continue
+		}
+
+		h.Path = p
+		s.next = h
+	}
+
+	if s.next != nil {
+		return s.next, nil
+	}
+
+	return nil, s.err
+}
+
+func (s *stream) consume() {
+	s.next = nil
+}
+
+// normalizePath converts the provided path to a clean relative path, which cannot escape the root.
+func normalizePath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// subdirectoryState tracks whether the uploader has started iterating a subdirectory.
+type subdirectoryState struct {
+	started bool
+}
+
+// dirIterator returns entries of a single directory, leaving entries outside of it in the stream.
+type dirIterator struct {
+	s       *stream
+	dirPath string
+
+	seen    map[string]*subdirectoryState
+	started *subdirectoryState
+}
+
+func (it *dirIterator) Next(ctx context.Context) (fs.Entry, error) {
+	it.started.started = true
+
+	for {
+		h, err := it.s.peek(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading stream")
+		}
+
+		rel, ok := relativeTo(h.Path, it.dirPath)
+		if !ok {
+			// entry belongs to another directory, let the parent handle it.
+			return nil, nil
+		}
+
+		name, _, nested := strings.Cut(rel, "/")
+
+		st, alreadySeen := it.seen[name]
+
+		switch {
+		case alreadySeen && st == nil:
+			return nil, errors.Errorf("duplicate entry %q in stream", h.Path)
+
+		case alreadySeen && st.started:
+			return nil, errors.Errorf("entry %q appears in stream after its directory has been processed", h.Path)
+
+		case alreadySeen:
+			// subdirectory was skipped by the consumer (for example ignored), skip its contents.
+			it.s.consume()
+			continue
+
+		case nested:
+			// the stream does not describe the subdirectory itself, synthesize it.
+			return it.newSubdirectory(name, virtualfs.EntryMetadata{
+				Mode:    defaultDirectoryPermissions,
+				ModTime: h.ModTime,
+				Owner:   h.Owner,
+			}), nil
+		}
+
+		it.s.consume()
+
+		md := virtualfs.EntryMetadata{
+			Mode:    h.Mode,
+			Size:    h.Size,
+			ModTime: h.ModTime,
+			Owner:   h.Owner,
+		}
+
+		switch h.Type {
+		case EntryTypeDirectory:
+			return it.newSubdirectory(name, md), nil
+
+		case EntryTypeSymlink:
+			it.seen[name] = nil
+			return virtualfs.NewSymlink(name, md, h.LinkTarget), nil
+
+		case EntryTypeFile:
+			it.seen[name] = nil
+			return virtualfs.StreamingFileWithMetadataFromReader(name, md, io.NopCloser(h.Body)), nil
+
+		default:
+			return nil, errors.Errorf("unsupported type %q of entry %q", h.Type, h.Path)
+		}
+	}
+}
+
+func (it *dirIterator) newSubdirectory(name string, md virtualfs.EntryMetadata) fs.Directory {
+	st := &subdirectoryState{}
+	it.seen[name] = st
+
+	return virtualfs.NewStreamingDirectoryWithMetadata(name, md, &dirIterator{
+		s:       it.s,
+		dirPath: path.Join(it.dirPath, name),
+		seen:    map[string]*subdirectoryState{},
+		started: st,
+	})
+}
+
+func (it *dirIterator) Close() {
+}
+
+func relativeTo(p, dir string) (string, bool) {
+	if dir == "" {
+		return p, true
+	}
+
+	return strings.CutPrefix(p, dir+"/")
+}
+
+// NewDirectory returns a directory with the provided name and metadata whose contents are read from the provided source.
+// The returned directory can only be iterated once, sequentially and depth-first.
+func NewDirectory(name string, md virtualfs.EntryMetadata, src Source) fs.Directory {
+	return virtualfs.NewStreamingDirectoryWithMetadata(name, md, &dirIterator{
+		s:       &stream{src: src},
+		seen:    map[string]*subdirectoryState{},
+		started: &subdirectoryState{},
+	})
+}
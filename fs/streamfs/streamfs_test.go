@@ -0,0 +1,262 @@
+package streamfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+var testModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	contents string
+	target   string
+}
+
+func makeTar(t *testing.T, entries ...tarEntry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	for _, e := range entries {
+		mode := int64(0o640)
+		if e.typeflag == tar.TypeDir {
+			mode = 0o750
+		}
+
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Mode:     mode,
+			Uid:      1000,
+			Gid:      100,
+			Size:     int64(len(e.contents)),
+			Linkname: e.target,
+			ModTime:  testModTime,
+		}))
+
+		_, err := tw.Write([]byte(e.contents))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+
+	return &buf
+}
+
+// walk consumes the directory sequentially and depth-first (the way the uploader does when not parallelized),
+// returning descriptions of all entries.
+func walk(ctx context.Context, t *testing.T, dir fs.Directory, prefix string, skip map[string]bool) ([]string, error) {
+	t.Helper()
+
+	var result []string
+
+	err := fs.IterateEntries(ctx, dir, func(ctx context.Context, e fs.Entry) error {
+		p := path.Join(prefix, e.Name())
+
+		if skip[p] {
+			return nil
+		}
+
+		switch e := e.(type) {
+		case fs.Directory:
+			result = append(result, fmt.Sprintf("%v/ %v", p, e.Mode()))
+
+			sub, err := walk(ctx, t, e, p, skip)
+			result = append(result, sub...)
+
+			return err
+
+		case fs.Symlink:
+			target, err := e.Readlink(ctx)
+			require.NoError(t, err)
+
+			result = append(result, fmt.Sprintf("%v -> %v", p, target))
+
+		case fs.StreamingFile:
+			r, err := e.GetReader(ctx)
+			require.NoError(t, err)
+
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+
+			require.Equal(t, testModTime, e.ModTime().UTC())
+			result = append(result, fmt.Sprintf("%v %v %v:%v %q", p, e.Mode(), e.Owner().UserID, e.Owner().GroupID, data))
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func TestTarSource(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	buf := makeTar(t,
+		tarEntry{name: "./", typeflag: tar.TypeDir},
+		tarEntry{name: "./a/", typeflag: tar.TypeDir},
+		tarEntry{name: "./a/f1", typeflag: tar.TypeReg, contents: "hello"},
+		tarEntry{name: "./a/b/", typeflag: tar.TypeDir},
+		tarEntry{name: "./a/b/f2", typeflag: tar.TypeReg, contents: "world"},
+		tarEntry{name: "./a/link", typeflag: tar.TypeSymlink, target: "b/f2"},
+		tarEntry{name: "./a/fifo", typeflag: tar.TypeFifo},
+		tarEntry{name: "implicit/dir/f3", typeflag: tar.TypeReg, contents: "!"},
+		tarEntry{name: "top", typeflag: tar.TypeReg},
+	)
+
+	root := NewDirectory("root", virtualfs.EntryMetadata{}, TarSource(buf))
+
+	got, err := walk(ctx, t, root, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"a/ drwxr-x---",
+		`a/f1 -rw-r----- 1000:100 "hello"`,
+		"a/b/ drwxr-x---",
+		`a/b/f2 -rw-r----- 1000:100 "world"`,
+		"a/link -> b/f2",
+		"implicit/ drwxr-xr-x",
+		"implicit/dir/ drwxr-xr-x",
+		`implicit/dir/f3 -rw-r----- 1000:100 "!"`,
+		`top -rw-r----- 1000:100 ""`,
+	}, got)
+}
+
+func TestTarSource_SkippedDirectory(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	buf := makeTar(t,
+		tarEntry{name: "a/", typeflag: tar.TypeDir},
+		tarEntry{name: "a/f1", typeflag: tar.TypeReg, contents: "hello"},
+		tarEntry{name: "a/b/f2", typeflag: tar.TypeReg, contents: "world"},
+		tarEntry{name: "c", typeflag: tar.TypeReg, contents: "x"},
+	)
+
+	root := NewDirectory("root", virtualfs.EntryMetadata{}, TarSource(buf))
+
+	got, err := walk(ctx, t, root, "", map[string]bool{"a": true})
+	require.NoError(t, err)
+	require.Equal(t, []string{`c -rw-r----- 1000:100 "x"`}, got)
+}
+
+func TestTarSource_OutOfOrder(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	buf := makeTar(t,
+		tarEntry{name: "a/f1", typeflag: tar.TypeReg, contents: "hello"},
+		tarEntry{name: "b/f2", typeflag: tar.TypeReg, contents: "world"},
+		tarEntry{name: "a/f3", typeflag: tar.TypeReg, contents: "!"},
+	)
+
+	_, err := walk(ctx, t, NewDirectory("root", virtualfs.EntryMetadata{}, TarSource(buf)), "", nil)
+	require.ErrorContains(t, err, `entry "a/f3" appears in stream after its directory has been processed`)
+
+	buf = makeTar(t,
+		tarEntry{name: "a", typeflag: tar.TypeReg},
+		tarEntry{name: "a", typeflag: tar.TypeReg},
+	)
+
+	_, err = walk(ctx, t, NewDirectory("root", virtualfs.EntryMetadata{}, TarSource(buf)), "", nil)
+	require.ErrorContains(t, err, `duplicate entry "a" in stream`)
+}
+
+func TestFramedSource(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var buf bytes.Buffer
+
+	writeHeader := func(h FramedHeader) {
+		h.ModTime = testModTime
+		h.UserID = 1000
+		h.GroupID = 100
+
+		b, err := json.Marshal(h)
+		require.NoError(t, err)
+
+		buf.Write(b)
+		buf.WriteString("\n")
+	}
+
+	writeHeader(FramedHeader{Path: "dump.sql", Type: EntryTypeFile, Mode: 0o600, Chunked: true})
+	buf.WriteString("3\nabc\n4\ndefg\n0\n")
+	writeHeader(FramedHeader{Path: "sub", Type: EntryTypeDirectory, Mode: 0o700})
+	writeHeader(FramedHeader{Path: "sub/data", Type: EntryTypeFile, Mode: 0o644, Size: 5})
+	buf.WriteString("12345")
+	writeHeader(FramedHeader{Path: "sub/link", Type: EntryTypeSymlink, LinkTarget: "data"})
+
+	root := NewDirectory("root", virtualfs.EntryMetadata{}, FramedSource(&buf))
+
+	got, err := walk(ctx, t, root, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`dump.sql -rw------- 1000:100 "abcdefg"`,
+		"sub/ drwx------",
+		`sub/data -rw-r--r-- 1000:100 "12345"`,
+		"sub/link -> data",
+	}, got)
+}
+
+func TestFramedSource_Errors(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cases := map[string]string{
+		"not json\n": "invalid entry header",
+		`{"path":"f","type":"file","size":10}` + "\nshort":       io.ErrUnexpectedEOF.Error(),
+		`{"path":"f","type":"file","chunked":true}` + "\nx\n":    `invalid chunk length "x"`,
+		`{"path":"f","type":"file","chunked":true}` + "\n5\nab":  io.ErrUnexpectedEOF.Error(),
+		`{"path":"f","type":"file","chunked":true}` + "\n2\nabc": "missing newline after chunk",
+		`{"path":"f","type":"fifo"}` + "\n":                      `unsupported type "fifo" of entry "f"`,
+	}
+
+	for input, wantErr := range cases {
+		root := NewDirectory("root", virtualfs.EntryMetadata{}, FramedSource(strings.NewReader(input)))
+
+		_, err := walk(ctx, t, root, "", nil)
+		require.ErrorContains(t, err, wantErr, input)
+	}
+}
+
+func TestFramedSource_SkipsUnreadContents(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	input := `{"path":"a","type":"file","size":3}` + "\nabc" +
+		`{"path":"b","type":"file","chunked":true}` + "\n2\nxy\n0\n" +
+		`{"path":"c","type":"file","size":1}` + "\nz"
+
+	src := FramedSource(strings.NewReader(input))
+
+	var names []string
+
+	for {
+		h, err := src.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		names = append(names, h.Path)
+	}
+
+	require.Equal(t, []string{"a", "b", "c"}, names)
+}
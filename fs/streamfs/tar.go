@@ -0,0 +1,62 @@
+package streamfs
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("streamfs")
+
+type tarSource struct {
+	r *tar.Reader
+}
+
+func (s *tarSource) Next(ctx context.Context) (*Header, error) {
+	for {
+		th, err := s.r.Next()
+		if err != nil {
+			//nolint:wrapcheck
+			return nil, err
+		}
+
+		h := &Header{
+			Path:    th.Name,
+			Mode:    os.FileMode(th.Mode).Perm(), //nolint:gosec
+			ModTime: th.ModTime,
+			Owner:   fs.OwnerInfo{UserID: uint32(th.Uid), GroupID: uint32(th.Gid)}, //nolint:gosec
+			Size:    th.Size,
+		}
+
+		switch th.Typeflag {
+		case tar.TypeReg, tar.TypeGNUSparse:
+			h.Type = EntryTypeFile
+			h.Body = s.r
+
+		case tar.TypeDir:
+			h.Type = EntryTypeDirectory
+			h.Size = 0
+
+		case tar.TypeSymlink:
+			h.Type = EntryTypeSymlink
+			h.LinkTarget = th.Linkname
+
+		default:
+			// hard links, devices, FIFOs, etc.
+			log(ctx).Warnf("skipping unsupported tar entry %q of type %q", th.Name, string(th.Typeflag))
+			continue
+		}
+
+		return h, nil
+	}
+}
+
+// TarSource returns a Source that reads entries from a tar archive.
+// Entries other than regular files, directories and symbolic links (such as hard links or devices) are skipped.
+func TarSource(r io.Reader) Source {
+	return &tarSource{tar.NewReader(r)}
+}
@@ -0,0 +1,138 @@
+package virtualfs
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// dynamicIteratorBufferSize is the number of entries produced ahead of the consumer.
+const dynamicIteratorBufferSize = 64
+
+// ListFunc produces entries of a dynamic directory by invoking the provided callback for each entry, in order.
+// When the callback returns an error, listing must stop and the error must be returned as is.
+type ListFunc func(ctx context.Context, callback func(e fs.Entry) error) error
+
+// LookupFunc returns the child of a dynamic directory with the given name or fs.ErrEntryNotFound.
+type LookupFunc func(ctx context.Context, name string) (fs.Entry, error)
+
+// dynamicDirectory is an implementation of fs.Directory whose entries are produced on demand
+// on each iteration, without holding all of them in memory.
+type dynamicDirectory struct {
+	virtualEntry
+
+	list   ListFunc
+	lookup LookupFunc
+}
+
+// Child gets the named child of a directory, using the lookup function if provided and scanning the listing otherwise.
+func (dd *dynamicDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	if dd.lookup != nil {
+		return dd.lookup(ctx, name)
+	}
+
+	//nolint:wrapcheck
+	return fs.IterateEntriesAndFindChild(ctx, dd, name)
+}
+
+func (dd *dynamicDirectory) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	return &dynamicIterator{ctx: ctx, list: dd.list}, nil
+}
+
+func (dd *dynamicDirectory) SupportsMultipleIterations() bool {
+	return true
+}
+
+// NewDynamicDirectory returns a directory with the provided metadata whose entries are produced by invoking
+// the list function on each iteration. The optional lookup function is used to efficiently find
+// individual children, otherwise Child() scans the listing.
+func NewDynamicDirectory(name string, md EntryMetadata, list ListFunc, lookup LookupFunc) fs.Directory {
+	mode := md.Mode.Perm()
+	if mode == 0 {
+		mode = defaultPermissions
+	}
+
+	return &dynamicDirectory{
+		virtualEntry: virtualEntry{
+			name:    name,
+			mode:    mode | os.ModeDir,
+			modTime: md.ModTime,
+			owner:   md.Owner,
+		},
+		list:   list,
+		lookup: lookup,
+	}
+}
+
+// dynamicIterator adapts ListFunc to fs.DirectoryIterator by running it in a goroutine
+// that is started on the first call to Next().
+type dynamicIterator struct {
+	ctx  context.Context //nolint:containedctx
+	list ListFunc
+
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	entries   chan fs.Entry
+
+	// written by the producer goroutine before closing entries.
+	err error
+}
+
+func (it *dynamicIterator) start() {
+	ctx, cancel := context.WithCancel(it.ctx)
+
+	it.cancel = cancel
+	it.entries = make(chan fs.Entry, dynamicIteratorBufferSize)
+
+	go func() {
+		defer close(it.entries)
+
+		it.err = it.list(ctx, func(e fs.Entry) error {
+			select {
+			case it.entries <- e:
+				return nil
+
+			case <-ctx.Done():
+				e.Close()
+				return ctx.Err()
+			}
+		})
+	}()
+}
+
+func (it *dynamicIterator) Next(ctx context.Context) (fs.Entry, error) {
+	it.startOnce.Do(it.start)
+
+	select {
+	case e, ok := <-it.entries:
+		if !ok {
+			return nil, it.err
+		}
+
+		return e, nil
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops the producer and releases entries that have not been consumed.
+func (it *dynamicIterator) Close() {
+	started := true
+
+	it.startOnce.Do(func() { started = false })
+
+	if !started {
+		return
+	}
+
+	it.cancel()
+
+	for e := range it.entries {
+		e.Close()
+	}
+}
+
+var _ fs.Directory = &dynamicDirectory{}
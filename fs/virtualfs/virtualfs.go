@@ -17,6 +17,14 @@
 	defaultPermissions os.FileMode = 0o777
 )
 
+// EntryMetadata describes metadata of a virtual entry.
+type EntryMetadata struct {
+	Mode    os.FileMode
+	Size    int64
+	ModTime time.Time
+	Owner   fs.OwnerInfo
+}
+
 // virtualEntry is an in-memory implementation of a directory entry.
 type virtualEntry struct {
 	name    string
@@ -147,6 +155,24 @@ func NewStreamingDirectory(
 	}
 }
 
+// NewStreamingDirectoryWithMetadata returns a directory with the provided metadata that will invoke
+// the provided iterator on Iterate().
+func NewStreamingDirectoryWithMetadata(
+	name string,
+	md EntryMetadata,
+	iter fs.DirectoryIterator,
+) fs.Directory {
+	return &streamingDirectory{
+		virtualEntry: virtualEntry{
+			name:    name,
+			mode:    md.Mode.Perm() | os.ModeDir,
+			modTime: md.ModTime,
+			owner:   md.Owner,
+		},
+		iter: iter,
+	}
+}
+
 // virtualFile is an implementation of fs.StreamingFile with an io.Reader.
 type virtualFile struct {
 	virtualEntry
@@ -187,9 +213,54 @@ func StreamingFileWithModTimeFromReader(name string, t time.Time, reader io.Read
 	}
 }
 
+// StreamingFileWithMetadataFromReader returns a streaming file with given name, metadata, and reader.
+func StreamingFileWithMetadataFromReader(name string, md EntryMetadata, reader io.ReadCloser) fs.StreamingFile {
+	return &virtualFile{
+		virtualEntry: virtualEntry{
+			name:    name,
+			mode:    md.Mode.Perm(),
+			size:    md.Size,
+			modTime: md.ModTime,
+			owner:   md.Owner,
+		},
+		reader: reader,
+	}
+}
+
+// virtualSymlink is an in-memory implementation of fs.Symlink.
+type virtualSymlink struct {
+	virtualEntry
+	target string
+}
+
+func (vs *virtualSymlink) Readlink(ctx context.Context) (string, error) {
+	return vs.target, nil
+}
+
+var errResolveNotSupported = errors.New("virtual symlinks cannot be resolved")
+
+func (vs *virtualSymlink) Resolve(ctx context.Context) (fs.Entry, error) {
+	return nil, errResolveNotSupported
+}
+
+// NewSymlink returns a symbolic link with given name, metadata, and target.
+func NewSymlink(name string, md EntryMetadata, target string) fs.Symlink {
+	return &virtualSymlink{
+		virtualEntry: virtualEntry{
+			name:    name,
+			mode:    md.Mode.Perm() | os.ModeSymlink,
+			size:    int64(len(target)),
+			modTime: md.ModTime,
+			owner:   md.Owner,
+		},
+		target: target,
+	}
+}
+
 var (
 	_ fs.Directory     = &staticDirectory{}
 	_ fs.Directory     = &streamingDirectory{}
 	_ fs.StreamingFile = &virtualFile{}
+	_ fs.Symlink       = &virtualSymlink{}
 	_ fs.Entry         = &virtualEntry{}
 )
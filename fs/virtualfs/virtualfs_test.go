@@ -4,6 +4,7 @@
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"reflect"
@@ -200,3 +201,95 @@ func TestStreamingDirectory_ReturnsCallbackError(t *testing.T) {
 	})
 	require.ErrorIs(t, err, errCallback)
 }
+
+func TestDynamicDirectory(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	const numEntries = 10000
+
+	var listCount int
+
+	list := func(ctx context.Context, cb func(e fs.Entry) error) error {
+		listCount++
+
+		for i := range numEntries {
+			if err := cb(NewStaticDirectory(fmt.Sprintf("d%05d", i), nil)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	dir := NewDynamicDirectory("root", EntryMetadata{Mode: 0o755}, list, nil)
+
+	require.True(t, dir.IsDir())
+	require.Equal(t, os.FileMode(0o755)|os.ModeDir, dir.Mode())
+	require.True(t, dir.SupportsMultipleIterations())
+
+	var count int
+
+	require.NoError(t, fs.IterateEntries(ctx, dir, func(_ context.Context, e fs.Entry) error {
+		require.Equal(t, fmt.Sprintf("d%05d", count), e.Name())
+		count++
+
+		return nil
+	}))
+
+	require.Equal(t, numEntries, count)
+
+	// lookup without a lookup function scans the listing.
+	e, err := dir.Child(ctx, "d00123")
+	require.NoError(t, err)
+	require.Equal(t, "d00123", e.Name())
+
+	_, err = dir.Child(ctx, "no-such-entry")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+
+	// stopping iteration early must stop the producer.
+	iter, err := dir.Iterate(ctx)
+	require.NoError(t, err)
+
+	e, err = iter.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "d00000", e.Name())
+	iter.Close()
+
+	// closing iterator that was never started is fine.
+	iter, err = dir.Iterate(ctx)
+	require.NoError(t, err)
+	iter.Close()
+
+	require.Equal(t, 4, listCount)
+}
+
+func TestDynamicDirectoryErrorAndLookup(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	errList := errors.New("list failed")
+
+	dir := NewDynamicDirectory("root", EntryMetadata{}, func(ctx context.Context, cb func(e fs.Entry) error) error {
+		if err := cb(NewStaticDirectory("a", nil)); err != nil {
+			return err
+		}
+
+		return errList
+	}, func(ctx context.Context, name string) (fs.Entry, error) {
+		if name == "x" {
+			return NewStaticDirectory("x", nil), nil
+		}
+
+		return nil, fs.ErrEntryNotFound
+	})
+
+	entries, err := fs.GetAllEntries(ctx, dir)
+	require.ErrorIs(t, err, errList)
+	require.Len(t, entries, 1)
+
+	e, err := dir.Child(ctx, "x")
+	require.NoError(t, err)
+	require.Equal(t, "x", e.Name())
+
+	_, err = dir.Child(ctx, "a")
+	require.ErrorIs(t, err, fs.ErrEntryNotFound)
+}
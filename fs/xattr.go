@@ -0,0 +1,88 @@
+package fs
+
+import "strings"
+
+// ExtendedAttributes contains extended metadata of a filesystem entry (extended attributes, ACLs,
+// platform-specific metadata) keyed by attribute name.
+type ExtendedAttributes map[string][]byte
+
+// ExtendedAttributeKind is a bit mask describing kinds of extended attributes.
+type ExtendedAttributeKind uint8
+
+// Supported kinds of extended attributes.
+const (
+	// ExtendedAttributesGeneric represents user-defined and system extended attributes (xattrs).
+	ExtendedAttributesGeneric ExtendedAttributeKind = 1 << iota
+
+	// ExtendedAttributesACL represents access control lists (POSIX ACLs, Windows security descriptors).
+	ExtendedAttributesACL
+
+	// ExtendedAttributesPlatform represents platform-specific metadata
+	// (macOS Finder info and resource forks, Windows alternate data streams).
+	ExtendedAttributesPlatform
+
+	// ExtendedAttributesNone represents no extended attributes.
+	ExtendedAttributesNone ExtendedAttributeKind = 0
+
+	// ExtendedAttributesAll represents all kinds of extended attributes.
+	ExtendedAttributesAll = ExtendedAttributesGeneric | ExtendedAttributesACL | ExtendedAttributesPlatform
+)
+
+// Names of pseudo-attributes used to represent metadata that is not stored as extended attributes by the OS.
+const (
+	// WindowsSecurityAttributeName holds the security descriptor of a file in SDDL format.
+	WindowsSecurityAttributeName = "kopia.win.security"
+
+	// WindowsAlternateDataStreamAttributePrefix is the prefix of attributes holding contents
+	// of Windows alternate data streams.
+	WindowsAlternateDataStreamAttributePrefix = "kopia.win.ads:"
+)
+
+// ExtendedAttributeKindOf returns the kind of the extended attribute with the provided name.
+func ExtendedAttributeKindOf(name string) ExtendedAttributeKind {
+	switch {
+	case name == "system.posix_acl_access", name == "system.posix_acl_default", name == WindowsSecurityAttributeName:
+		return ExtendedAttributesACL
+
+	case name == "com.apple.FinderInfo", name == "com.apple.ResourceFork", strings.HasPrefix(name, WindowsAlternateDataStreamAttributePrefix):
+		return ExtendedAttributesPlatform
+
+	default:
+		return ExtendedAttributesGeneric
+	}
+}
+
+// Includes returns true if the mask includes the kind of the extended attribute with the provided name.
+func (k ExtendedAttributeKind) Includes(name string) bool {
+	return k&ExtendedAttributeKindOf(name) != 0
+}
+
+// Filter returns attributes whose kinds are included in the mask or nil if there are none.
+func (a ExtendedAttributes) Filter(k ExtendedAttributeKind) ExtendedAttributes {
+	var result ExtendedAttributes
+
+	for n, v := range a {
+		if !k.Includes(n) {
+			continue
+		}
+
+		if result == nil {
+			result = ExtendedAttributes{}
+		}
+
+		result[n] = v
+	}
+
+	return result
+}
+
+// TotalSize returns the total size of names and values of all attributes.
+func (a ExtendedAttributes) TotalSize() int {
+	var total int
+
+	for n, v := range a {
+		total += len(n) + len(v)
+	}
+
+	return total
+}
@@ -7,6 +7,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/remoterestore"
 	"github.com/kopia/kopia/internal/user"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
@@ -23,6 +24,10 @@
 	OwnHost = "OWN_HOST"
 )
 
+// PathWildcard is the suffix of a 'path' label value in TargetRule which matches the path
+// and all paths under it, for example "/data/projects/*" or "C:\Data\*".
+const PathWildcard = "*"
+
 // TargetRule specifies a list of key and values that must match labels on the target manifest.
 // The value can have two special placeholders - OWN_USER and OWN_VALUE representing the matched user
 // and host respectively if wildcards are being used.
@@ -46,12 +51,20 @@ func (r TargetRule) String() string {
 
 // matches returns true if a given subject rule matches the given target
 // for the provided username & hostname. The rule can use
-// OwnUser / OwnHost placeholders.
+// OwnUser / OwnHost placeholders and PathWildcard in the path label.
 func (r TargetRule) matches(target map[string]string, username, hostname string) bool {
 	for k, v := range r {
 		v = strings.ReplaceAll(v, OwnUser, username)
 		v = strings.ReplaceAll(v, OwnHost, hostname)
 
+		if k == snapshot.PathLabel {
+			if !pathMatches(v, target[k]) {
+				return false
+			}
+
+			continue
+		}
+
 		if target[k] != v {
 			return false
 		}
@@ -60,6 +73,22 @@ func (r TargetRule) matches(target map[string]string, username, hostname string)
 	return true
 }
 
+// pathMatches returns true if the path equals the rule or, if the rule ends with a separator
+// followed by PathWildcard, is equal to or nested under the path preceding the wildcard.
+func pathMatches(rule, path string) bool {
+	prefix, ok := strings.CutSuffix(rule, PathWildcard)
+	if !ok || !strings.HasSuffix(prefix, "/") && !strings.HasSuffix(prefix, "\\") {
+		return rule == path
+	}
+
+	if path+prefix[len(prefix)-1:] == prefix {
+		// the directory itself
+		return path != ""
+	}
+
+	return strings.HasPrefix(path, prefix)
+}
+
 // Entry defines access control list entry stored in a manifest which grants the given
 // user certain level of access to a target.
 type Entry struct {
@@ -103,6 +132,7 @@ func oneOf(allowed ...string) valueValidatorFunc {
 			policy.PolicyTypeHost,
 			policy.PolicyTypeUser,
 			policy.PolicyTypePath,
+			policy.PolicyTypeEnforced,
 		),
 	},
 	snapshot.ManifestType: {
@@ -113,6 +143,11 @@ func oneOf(allowed ...string) valueValidatorFunc {
 	user.ManifestType: {
 		user.UsernameAtHostnameLabel: nonEmptyString,
 	},
+	remoterestore.ManifestType: {
+		snapshot.HostnameLabel:       nonEmptyString,
+		snapshot.UsernameLabel:       nonEmptyString,
+		remoterestore.RequestIDLabel: nonEmptyString,
+	},
 	aclManifestType: {},
 }
 
@@ -149,6 +149,71 @@ func TestEffectivePermissions(t *testing.T) {
 	}
 }
 
+func TestEffectivePermissions_PathWildcard(t *testing.T) {
+	entries := []*acl.Entry{
+		{
+			Target: acl.TargetRule{
+				manifest.TypeLabelKey:  snapshot.ManifestType,
+				snapshot.UsernameLabel: acl.OwnUser,
+				snapshot.HostnameLabel: acl.OwnHost,
+				snapshot.PathLabel:     "/data/projects/*",
+			},
+			User:   actualUserAtHostname,
+			Access: acl.AccessLevelFull,
+		},
+		{
+			Target: acl.TargetRule{
+				manifest.TypeLabelKey:  snapshot.ManifestType,
+				snapshot.UsernameLabel: "alice",
+				snapshot.HostnameLabel: anotherHostname,
+				snapshot.PathLabel:     "C:\\Users\\*",
+			},
+			User:   actualUserAtHostname,
+			Access: acl.AccessLevelRead,
+		},
+		{
+			Target: acl.TargetRule{
+				manifest.TypeLabelKey:  snapshot.ManifestType,
+				snapshot.UsernameLabel: "alice",
+				snapshot.HostnameLabel: actualHostname,
+				snapshot.PathLabel:     "/exact*",
+			},
+			User:   actualUserAtHostname,
+			Access: acl.AccessLevelRead,
+		},
+	}
+
+	cases := []struct {
+		user string
+		host string
+		path string
+		want acl.AccessLevel
+	}{
+		{actualUser, actualHostname, "/data/projects", acl.AccessLevelFull},
+		{actualUser, actualHostname, "/data/projects/", acl.AccessLevelFull},
+		{actualUser, actualHostname, "/data/projects/a/b", acl.AccessLevelFull},
+		{actualUser, actualHostname, "/data/projects2", acl.AccessLevelNone},
+		{actualUser, actualHostname, "/data", acl.AccessLevelNone},
+		{actualUser, anotherHostname, "/data/projects/a", acl.AccessLevelNone},
+		{"alice", anotherHostname, "C:\\Users", acl.AccessLevelRead},
+		{"alice", anotherHostname, "C:\\Users\\alice", acl.AccessLevelRead},
+		{"alice", anotherHostname, "C:\\Data", acl.AccessLevelNone},
+		{"alice", actualHostname, "/exact*", acl.AccessLevelRead},
+		{"alice", actualHostname, "/exactly", acl.AccessLevelNone},
+	}
+
+	for _, tc := range cases {
+		target := map[string]string{
+			manifest.TypeLabelKey:  snapshot.ManifestType,
+			snapshot.UsernameLabel: tc.user,
+			snapshot.HostnameLabel: tc.host,
+			snapshot.PathLabel:     tc.path,
+		}
+
+		require.Equal(t, tc.want, acl.EffectivePermissions(actualUser, actualHostname, target, entries), "path %v", tc.path)
+	}
+}
+
 func TestLoadEntries(t *testing.T) {
 	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
 
@@ -220,7 +285,7 @@ func TestACLEntryValidation(t *testing.T) {
 				},
 				Access: acl.AccessLevelFull,
 			},
-			WantErr: "invalid 'type' label, must be one of: acl, content, policy, snapshot, user",
+			WantErr: "invalid 'type' label, must be one of: acl, content, policy, restoreRequest, snapshot, user",
 		},
 		{
 			Entry: &acl.Entry{
@@ -253,7 +318,7 @@ func TestACLEntryValidation(t *testing.T) {
 				},
 				Access: acl.AccessLevelFull,
 			},
-			WantErr: "invalid label 'policyType=blah' for type 'policy': must be one of: global, host, user, path",
+			WantErr: "invalid label 'policyType=blah' for type 'policy': must be one of: global, host, user, path, enforced",
 		},
 		{
 			Entry: &acl.Entry{
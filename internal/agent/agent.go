@@ -0,0 +1,355 @@
+// Package agent implements a gRPC service for snapshotting local paths and restoring them,
+// intended to run as a sidecar next to workloads such as Kubernetes pods with mounted volumes.
+//
+// Snapshots and restores run asynchronously as operations, which can be polled, watched and canceled
+// by the caller, so that backup operators can embed kopia without executing the CLI.
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/grpcapi"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var log = logging.Module("kopia/agent")
+
+const (
+	// maxFinishedOperations is the number of finished operations remembered by the agent.
+	maxFinishedOperations = 100
+
+	defaultWatchInterval = time.Second
+)
+
+// Options provides configuration of the agent.
+type Options struct {
+	// Token, when set, must be provided by clients as a bearer token in the 'authorization' metadata.
+	Token string
+
+	// AllowedPaths are the directories which can be snapshotted and restored to, including their subdirectories.
+	AllowedPaths []string
+
+	// WatchInterval is the interval between updates sent by WatchOperation.
+	WatchInterval time.Duration
+
+	// TLSConfig, when set, is used to serve requests over TLS.
+	TLSConfig *tls.Config
+}
+
+// Server implements the KopiaAgent gRPC service.
+type Server struct {
+	grpcapi.UnimplementedKopiaAgentServer
+
+	// context used by operations, which outlive the requests that started them.
+	//nolint:containedctx
+	baseCtx context.Context
+	rep     repo.Repository
+	opt     Options
+
+	// allowed paths with symbolic links evaluated.
+	allowedRoots []string
+
+	mu sync.Mutex
+	// +checklocks:mu
+	operations map[string]*operation
+
+	wg sync.WaitGroup
+}
+
+// New creates a new agent operating on the provided repository.
+func New(ctx context.Context, rep repo.Repository, opt Options) (*Server, error) {
+	if opt.WatchInterval <= 0 {
+		opt.WatchInterval = defaultWatchInterval
+	}
+
+	if len(opt.AllowedPaths) == 0 {
+		return nil, errors.New("at least one allowed path is required")
+	}
+
+	var roots []string
+
+	for _, p := range opt.AllowedPaths {
+		root, err := resolvePath(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid allowed path %v", p)
+		}
+
+		roots = append(roots, root)
+	}
+
+	return &Server{
+		baseCtx:      ctx,
+		rep:          rep,
+		opt:          opt,
+		allowedRoots: roots,
+		operations:   map[string]*operation{},
+	}, nil
+}
+
+// Register registers the agent service and authentication interceptors with a new gRPC server.
+func (s *Server) Register() *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := s.authorize(ctx); err != nil {
+				return nil, err
+			}
+
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context()); err != nil {
+				return err
+			}
+
+			return handler(srv, ss)
+		}),
+	}
+
+	if s.opt.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.opt.TLSConfig)))
+	}
+
+	gs := grpc.NewServer(opts...)
+
+	grpcapi.RegisterKopiaAgentServer(gs, s)
+
+	return gs
+}
+
+func (s *Server) authorize(ctx context.Context) error {
+	if s.opt.Token == "" {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(s.opt.Token)) == 1 {
+			return nil
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// Cancel cancels all running operations.
+func (s *Server) Cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, op := range s.operations {
+		op.requestCancel()
+	}
+}
+
+// Wait waits for all operations to finish.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// start registers a new operation and runs it in a separate goroutine.
+func (s *Server) start(op *operation, run func(ctx context.Context, op *operation) error) *grpcapi.Operation {
+	op.id = uuid.NewString()
+	op.startTime = clock.Now()
+
+	s.mu.Lock()
+	s.operations[op.id] = op
+	s.pruneFinishedLocked()
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		err := run(s.baseCtx, op)
+		if err != nil {
+			log(s.baseCtx).Errorf("operation %v failed: %v", op.id, err)
+		}
+
+		op.finish(err)
+	}()
+
+	return op.toProto()
+}
+
+// +checklocks:s.mu
+func (s *Server) pruneFinishedLocked() {
+	var finished []*operation
+
+	for _, op := range s.operations {
+		if op.isFinished() {
+			finished = append(finished, op)
+		}
+	}
+
+	if len(finished) <= maxFinishedOperations {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].startTime.Before(finished[j].startTime)
+	})
+
+	for _, op := range finished[:len(finished)-maxFinishedOperations] {
+		delete(s.operations, op.id)
+	}
+}
+
+func (s *Server) getOperation(id string) (*operation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op := s.operations[id]
+	if op == nil {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", id)
+	}
+
+	return op, nil
+}
+
+// GetOperation implements grpcapi.KopiaAgentServer.
+func (s *Server) GetOperation(_ context.Context, req *grpcapi.OperationRequest) (*grpcapi.Operation, error) {
+	op, err := s.getOperation(req.GetOperationId())
+	if err != nil {
+		return nil, err
+	}
+
+	return op.toProto(), nil
+}
+
+// WatchOperation implements grpcapi.KopiaAgentServer.
+func (s *Server) WatchOperation(req *grpcapi.OperationRequest, srv grpcapi.KopiaAgent_WatchOperationServer) error {
+	op, err := s.getOperation(req.GetOperationId())
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTicker(s.opt.WatchInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-op.done:
+			return errors.Wrap(srv.Send(op.toProto()), "send error")
+
+		default:
+		}
+
+		if err := srv.Send(op.toProto()); err != nil {
+			return errors.Wrap(err, "send error")
+		}
+
+		select {
+		case <-op.done:
+		case <-t.C:
+		case <-srv.Context().Done():
+			return errors.Wrap(srv.Context().Err(), "watch canceled")
+		}
+	}
+}
+
+// CancelOperation implements grpcapi.KopiaAgentServer.
+func (s *Server) CancelOperation(_ context.Context, req *grpcapi.OperationRequest) (*grpcapi.Operation, error) {
+	op, err := s.getOperation(req.GetOperationId())
+	if err != nil {
+		return nil, err
+	}
+
+	op.requestCancel()
+
+	return op.toProto(), nil
+}
+
+// ListSnapshots implements grpcapi.KopiaAgentServer.
+func (s *Server) ListSnapshots(ctx context.Context, req *grpcapi.ListSnapshotsRequest) (*grpcapi.ListSnapshotsResponse, error) {
+	si := s.sourceInfo(req.GetSource(), "")
+
+	if si.Path == "" {
+		return nil, status.Error(codes.InvalidArgument, "source path is required")
+	}
+
+	mans, err := snapshot.ListSnapshots(ctx, s.rep, si)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to list snapshots: %v", err)
+	}
+
+	resp := &grpcapi.ListSnapshotsResponse{}
+
+	for _, m := range snapshot.SortByTime(mans, false) {
+		resp.Snapshots = append(resp.Snapshots, snapshotInfo(m))
+	}
+
+	return resp, nil
+}
+
+// sourceInfo returns the source with defaults filled in from the client options of the repository.
+func (s *Server) sourceInfo(src *grpcapi.SourceInfo, defaultPath string) snapshot.SourceInfo {
+	si := snapshot.SourceInfo{
+		Host:     src.GetHost(),
+		UserName: src.GetUserName(),
+		Path:     src.GetPath(),
+	}
+
+	if si.Host == "" {
+		si.Host = s.rep.ClientOptions().Hostname
+	}
+
+	if si.UserName == "" {
+		si.UserName = s.rep.ClientOptions().Username
+	}
+
+	if si.Path == "" {
+		si.Path = defaultPath
+	}
+
+	return si
+}
+
+func sourceInfoToProto(si snapshot.SourceInfo) *grpcapi.SourceInfo {
+	return &grpcapi.SourceInfo{
+		Host:     si.Host,
+		UserName: si.UserName,
+		Path:     si.Path,
+	}
+}
+
+func snapshotInfo(m *snapshot.Manifest) *grpcapi.SnapshotInfo {
+	tags := map[string]string{}
+
+	for k, v := range m.Tags {
+		tags[strings.TrimPrefix(k, snapshotTagPrefix)] = v
+	}
+
+	return &grpcapi.SnapshotInfo{
+		Id:                  string(m.ID),
+		Source:              sourceInfoToProto(m.Source),
+		Description:         m.Description,
+		StartTimeNanos:      int64(m.StartTime),
+		EndTimeNanos:        int64(m.EndTime),
+		RootObjectId:        m.RootObjectID().String(),
+		IncompleteReason:    m.IncompleteReason,
+		TotalFileSize:       m.Stats.TotalFileSize,
+		TotalFileCount:      m.Stats.TotalFileCount,
+		TotalDirectoryCount: m.Stats.TotalDirectoryCount,
+		ErrorCount:          m.Stats.ErrorCount,
+		Tags:                tags,
+	}
+}
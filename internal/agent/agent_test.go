@@ -0,0 +1,239 @@
+package agent_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/kopia/kopia/internal/agent"
+	"github.com/kopia/kopia/internal/grpcapi"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/internal/tlsutil"
+	"github.com/kopia/kopia/repo/format"
+)
+
+const testToken = "secret-token"
+
+// startAgent starts the agent allowed to access the returned directory.
+func startAgent(t *testing.T) (context.Context, grpcapi.KopiaAgentClient, string) {
+	t.Helper()
+
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	allowed := testutil.TempDirectory(t)
+
+	_, err := agent.New(ctx, env.Repository, agent.Options{})
+	require.Error(t, err)
+
+	srv, err := agent.New(ctx, env.Repository, agent.Options{
+		Token:         testToken,
+		AllowedPaths:  []string{allowed},
+		WatchInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	gs := srv.Register()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go gs.Serve(l) //nolint:errcheck
+
+	t.Cleanup(func() {
+		gs.Stop()
+		srv.Cancel()
+		srv.Wait()
+	})
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return ctx, grpcapi.NewKopiaAgentClient(conn), allowed
+}
+
+func withToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testToken)
+}
+
+func waitForOperation(ctx context.Context, t *testing.T, cli grpcapi.KopiaAgentClient, id string) *grpcapi.Operation {
+	t.Helper()
+
+	stream, err := cli.WatchOperation(ctx, &grpcapi.OperationRequest{OperationId: id})
+	require.NoError(t, err)
+
+	var last *grpcapi.Operation
+
+	for {
+		op, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+
+		last = op
+	}
+
+	require.NotNil(t, last)
+
+	return last
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	ctx, cli, allowed := startAgent(t)
+	ctx = withToken(ctx)
+
+	volume := filepath.Join(allowed, "volume")
+	require.NoError(t, os.MkdirAll(filepath.Join(volume, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(volume, "a.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(volume, "sub", "b.txt"), []byte("world!"), 0o644))
+
+	op, err := cli.Snapshot(ctx, &grpcapi.SnapshotRequest{
+		Path:        volume,
+		Source:      &grpcapi.SourceInfo{Host: "cluster", UserName: "ns", Path: "/pvc/data"},
+		Description: "nightly",
+		Tags:        map[string]string{"pvc": "data"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, op.GetId())
+
+	op = waitForOperation(ctx, t, cli, op.GetId())
+	require.Equal(t, grpcapi.Operation_SUCCESS, op.GetStatus(), op.GetErrorMessage())
+	require.NotZero(t, op.GetEndTimeNanos())
+	require.Equal(t, int64(2), op.GetSnapshotProgress().GetHashedFiles())
+
+	snap := op.GetSnapshot()
+	require.NotEmpty(t, snap.GetId())
+	require.Equal(t, "/pvc/data", snap.GetSource().GetPath())
+	require.Equal(t, "nightly", snap.GetDescription())
+	require.Equal(t, map[string]string{"pvc": "data"}, snap.GetTags())
+	require.Equal(t, int64(11), snap.GetTotalFileSize())
+
+	list, err := cli.ListSnapshots(ctx, &grpcapi.ListSnapshotsRequest{Source: snap.GetSource()})
+	require.NoError(t, err)
+	require.Len(t, list.GetSnapshots(), 1)
+	require.Equal(t, snap.GetId(), list.GetSnapshots()[0].GetId())
+
+	target := filepath.Join(allowed, "restored")
+
+	op, err = cli.Restore(ctx, &grpcapi.RestoreRequest{
+		Snapshot:   snap.GetId(),
+		TargetPath: target,
+	})
+	require.NoError(t, err)
+
+	op = waitForOperation(ctx, t, cli, op.GetId())
+	require.Equal(t, grpcapi.Operation_SUCCESS, op.GetStatus(), op.GetErrorMessage())
+	require.Equal(t, int64(2), op.GetRestoreProgress().GetRestoredFiles())
+	require.Equal(t, int64(11), op.GetRestoreProgress().GetRestoredBytes())
+
+	b, err := os.ReadFile(filepath.Join(target, "sub", "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "world!", string(b))
+
+	op, err = cli.GetOperation(ctx, &grpcapi.OperationRequest{OperationId: op.GetId()})
+	require.NoError(t, err)
+	require.Equal(t, grpcapi.Operation_SUCCESS, op.GetStatus())
+}
+
+func TestErrors(t *testing.T) {
+	ctx, cli, allowed := startAgent(t)
+
+	_, err := cli.GetOperation(ctx, &grpcapi.OperationRequest{OperationId: "x"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = withToken(ctx)
+
+	_, err = cli.GetOperation(ctx, &grpcapi.OperationRequest{OperationId: "x"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = cli.Snapshot(ctx, &grpcapi.SnapshotRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = cli.Snapshot(ctx, &grpcapi.SnapshotRequest{Path: filepath.Join(allowed, "no-such-dir")})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = cli.Restore(ctx, &grpcapi.RestoreRequest{Snapshot: "no-such-snapshot", TargetPath: allowed})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// paths outside of allowed paths are rejected, including through symbolic links and '..'.
+	outside := testutil.TempDirectory(t)
+	require.NoError(t, os.Symlink(outside, filepath.Join(allowed, "link")))
+
+	for _, p := range []string{
+		outside,
+		filepath.Join(allowed, "link"),
+		filepath.Join(allowed, "link", "new-dir"),
+		filepath.Join(allowed, "..", filepath.Base(outside)),
+	} {
+		_, err = cli.Snapshot(ctx, &grpcapi.SnapshotRequest{Path: p})
+		require.Equal(t, codes.PermissionDenied, status.Code(err), p)
+
+		_, err = cli.Restore(ctx, &grpcapi.RestoreRequest{Snapshot: "no-such-snapshot", TargetPath: p})
+		require.Equal(t, codes.PermissionDenied, status.Code(err), p)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	cert, key, err := tlsutil.GenerateServerCertificate(ctx, 2048, time.Hour, []string{"127.0.0.1"})
+	require.NoError(t, err)
+
+	srv, err := agent.New(ctx, env.Repository, agent.Options{
+		Token:        testToken,
+		AllowedPaths: []string{testutil.TempDirectory(t)},
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+		},
+	})
+	require.NoError(t, err)
+
+	gs := srv.Register()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go gs.Serve(l) //nolint:errcheck
+
+	t.Cleanup(func() {
+		gs.Stop()
+		srv.Cancel()
+		srv.Wait()
+	})
+
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	call := func(creds credentials.TransportCredentials) error {
+		conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(creds))
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		_, err = grpcapi.NewKopiaAgentClient(conn).GetOperation(withToken(ctx), &grpcapi.OperationRequest{OperationId: "x"})
+
+		return err
+	}
+
+	require.Equal(t, codes.NotFound, status.Code(call(credentials.NewTLS(tlsutil.TLSConfigTrustingSingleCertificate(hex.EncodeToString(fingerprint[:]))))))
+	require.Equal(t, codes.Unavailable, status.Code(call(insecure.NewCredentials())))
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resolvePath returns the absolute path with symbolic links evaluated. Trailing components which
+// don't exist yet, such as a new restore target, are appended to their nearest existing parent.
+func resolvePath(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get absolute path")
+	}
+
+	var missing []string
+
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+
+		parent := filepath.Dir(p)
+		if !os.IsNotExist(err) || parent == p {
+			return "", errors.Wrap(err, "unable to evaluate symbolic links")
+		}
+
+		missing = append([]string{filepath.Base(p)}, missing...)
+		p = parent
+	}
+}
+
+// isWithin returns true if the path is the root or one of its descendants, both paths must be resolved.
+func isWithin(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// allowedPath resolves the path requested by the client and returns an error unless it is within one
+// of the allowed roots.
+func (s *Server) allowedPath(p string) (string, error) {
+	resolved, err := resolvePath(p)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid path: %v", err)
+	}
+
+	for _, root := range s.allowedRoots {
+		if isWithin(root, resolved) {
+			return resolved, nil
+		}
+	}
+
+	return "", status.Errorf(codes.PermissionDenied, "path %v is outside of allowed paths", p)
+}
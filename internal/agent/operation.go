@@ -0,0 +1,350 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/grpcapi"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// snapshotTagPrefix is the prefix of keys of user-defined snapshot tags.
+const snapshotTagPrefix = "tag:"
+
+// operation is a snapshot or restore running in the background.
+type operation struct {
+	id        string
+	startTime time.Time
+	done      chan struct{}
+
+	// set for snapshot operations.
+	uploadProgress *snapshotfs.CountingUploadProgress
+
+	mu sync.Mutex
+	// +checklocks:mu
+	status grpcapi.Operation_Status
+	// +checklocks:mu
+	endTime time.Time
+	// +checklocks:mu
+	err error
+	// +checklocks:mu
+	cancel func()
+	// +checklocks:mu
+	restoreStats *restore.Stats
+	// +checklocks:mu
+	manifest *snapshot.Manifest
+}
+
+func newOperation() *operation {
+	return &operation{
+		status: grpcapi.Operation_RUNNING,
+		done:   make(chan struct{}),
+	}
+}
+
+func (o *operation) isFinished() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// setCancel sets the function canceling the operation, invoking it immediately if cancellation has already been requested.
+func (o *operation) setCancel(cancel func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.cancel = cancel
+
+	if o.status == grpcapi.Operation_CANCELING {
+		cancel()
+	}
+}
+
+func (o *operation) requestCancel() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.status != grpcapi.Operation_RUNNING {
+		return
+	}
+
+	o.status = grpcapi.Operation_CANCELING
+
+	if o.cancel != nil {
+		o.cancel()
+	}
+}
+
+func (o *operation) finish(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.endTime = clock.Now()
+	o.err = err
+
+	switch {
+	case o.status == grpcapi.Operation_CANCELING:
+		o.status = grpcapi.Operation_CANCELED
+	case err != nil:
+		o.status = grpcapi.Operation_FAILED
+	default:
+		o.status = grpcapi.Operation_SUCCESS
+	}
+
+	close(o.done)
+}
+
+func (o *operation) setRestoreStats(st restore.Stats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.restoreStats = &st
+}
+
+func (o *operation) setManifest(m *snapshot.Manifest) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.manifest = m
+}
+
+func (o *operation) toProto() *grpcapi.Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result := &grpcapi.Operation{
+		Id:             o.id,
+		Status:         o.status,
+		StartTimeNanos: o.startTime.UnixNano(),
+	}
+
+	if !o.endTime.IsZero() {
+		result.EndTimeNanos = o.endTime.UnixNano()
+	}
+
+	if o.err != nil {
+		result.ErrorMessage = o.err.Error()
+	}
+
+	if o.uploadProgress != nil {
+		c := o.uploadProgress.Snapshot()
+
+		result.Progress = &grpcapi.Operation_SnapshotProgress{
+			SnapshotProgress: &grpcapi.SnapshotProgress{
+				HashedFiles:      int64(c.TotalHashedFiles),
+				HashedBytes:      c.TotalHashedBytes,
+				CachedFiles:      int64(c.TotalCachedFiles),
+				CachedBytes:      c.TotalCachedBytes,
+				UploadedBytes:    c.TotalUploadedBytes,
+				EstimatedFiles:   c.EstimatedFiles,
+				EstimatedBytes:   c.EstimatedBytes,
+				Errors:           c.FatalErrorCount,
+				IgnoredErrors:    c.IgnoredErrorCount,
+				CurrentDirectory: c.CurrentDirectory,
+			},
+		}
+	}
+
+	if st := o.restoreStats; st != nil {
+		result.Progress = &grpcapi.Operation_RestoreProgress{
+			RestoreProgress: &grpcapi.RestoreProgress{
+				RestoredFiles:       int64(st.RestoredFileCount),
+				RestoredDirectories: int64(st.RestoredDirCount),
+				RestoredSymlinks:    int64(st.RestoredSymlinkCount),
+				RestoredBytes:       st.RestoredTotalFileSize,
+				EnqueuedFiles:       int64(st.EnqueuedFileCount),
+				EnqueuedBytes:       st.EnqueuedTotalFileSize,
+				SkippedFiles:        int64(st.SkippedCount),
+				SkippedBytes:        st.SkippedTotalFileSize,
+				IgnoredErrors:       st.IgnoredErrorCount,
+			},
+		}
+	}
+
+	if o.manifest != nil {
+		result.Snapshot = snapshotInfo(o.manifest)
+	}
+
+	return result
+}
+
+// Snapshot implements grpcapi.KopiaAgentServer.
+func (s *Server) Snapshot(_ context.Context, req *grpcapi.SnapshotRequest) (*grpcapi.Operation, error) {
+	if req.GetPath() == "" {
+		return nil, status.Error(codes.InvalidArgument, "path is required")
+	}
+
+	path, err := s.allowedPath(req.GetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := localfs.NewEntry(path)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "unable to read path: %v", err)
+	}
+
+	si := s.sourceInfo(req.GetSource(), path)
+
+	tags := map[string]string{}
+	for k, v := range req.GetTags() {
+		tags[snapshotTagPrefix+k] = v
+	}
+
+	op := newOperation()
+	op.uploadProgress = &snapshotfs.CountingUploadProgress{}
+
+	log(s.baseCtx).Infof("snapshotting %v as %v", path, si)
+
+	return s.start(op, func(ctx context.Context, op *operation) error {
+		return s.snapshot(ctx, op, entry, si, req.GetDescription(), tags)
+	}), nil
+}
+
+func (s *Server) snapshot(ctx context.Context, op *operation, entry fs.Entry, si snapshot.SourceInfo, description string, tags map[string]string) error {
+	//nolint:wrapcheck
+	return repo.WriteSession(ctx, s.rep, repo.WriteSessionOptions{
+		Purpose:  "Agent Snapshot",
+		OnUpload: op.uploadProgress.UploadedBytes,
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := snapshotfs.NewUploader(w)
+		u.Progress = op.uploadProgress
+
+		op.setCancel(u.Cancel)
+
+		policyTree, err := policy.TreeForSource(ctx, w, si)
+		if err != nil {
+			return errors.Wrap(err, "unable to create policy getter")
+		}
+
+		previous, err := previousSnapshot(ctx, w, si)
+		if err != nil {
+			return err
+		}
+
+		man, err := u.Upload(ctx, entry, policyTree, si, previous...)
+		if err != nil {
+			return errors.Wrap(err, "upload error")
+		}
+
+		if man.IncompleteReason != "" {
+			return errors.Errorf("snapshot is incomplete: %v", man.IncompleteReason)
+		}
+
+		man.Description = description
+		if len(tags) > 0 {
+			man.Tags = tags
+		}
+
+		id, err := snapshot.SaveSnapshot(ctx, w, man)
+		if err != nil {
+			return errors.Wrap(err, "unable to save snapshot")
+		}
+
+		man.ID = id
+
+		if _, err := policy.ApplyRetentionPolicy(ctx, w, si, true); err != nil {
+			return errors.Wrap(err, "unable to apply retention policy")
+		}
+
+		op.setManifest(man)
+
+		return nil
+	})
+}
+
+// previousSnapshot returns the latest complete snapshot of the source, if any.
+func previousSnapshot(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) ([]*snapshot.Manifest, error) {
+	mans, err := snapshot.ListSnapshots(ctx, rep, si)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing previous snapshots")
+	}
+
+	for _, m := range snapshot.SortByTime(mans, true) {
+		if m.IncompleteReason == "" {
+			return []*snapshot.Manifest{m}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Restore implements grpcapi.KopiaAgentServer.
+func (s *Server) Restore(ctx context.Context, req *grpcapi.RestoreRequest) (*grpcapi.Operation, error) {
+	if req.GetSnapshot() == "" || req.GetTargetPath() == "" {
+		return nil, status.Error(codes.InvalidArgument, "snapshot and target path are required")
+	}
+
+	targetPath, err := s.allowedPath(req.GetTargetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	rootEntry, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, s.rep, req.GetSnapshot(), false)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "unable to find snapshot %v: %v", req.GetSnapshot(), err)
+	}
+
+	out := &restore.FilesystemOutput{
+		TargetPath:           targetPath,
+		OverwriteFiles:       req.GetOverwriteFiles(),
+		OverwriteDirectories: req.GetOverwriteDirectories(),
+		OverwriteSymlinks:    req.GetOverwriteSymlinks(),
+		SkipOwners:           req.GetSkipOwners(),
+		SkipPermissions:      req.GetSkipPermissions(),
+		SkipTimes:            req.GetSkipTimes(),
+	}
+
+	if err := out.Init(ctx); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "unable to initialize restore target: %v", err)
+	}
+
+	opt := restore.Options{
+		Incremental:            req.GetIncremental(),
+		IgnoreErrors:           req.GetIgnoreErrors(),
+		RestoreDirEntryAtDepth: math.MaxInt32,
+	}
+
+	op := newOperation()
+	op.setRestoreStats(restore.Stats{})
+
+	log(s.baseCtx).Infof("restoring %v to %v", req.GetSnapshot(), targetPath)
+
+	return s.start(op, func(ctx context.Context, op *operation) error {
+		cancelChan := make(chan struct{})
+
+		opt.Cancel = cancelChan
+		opt.ProgressCallback = func(_ context.Context, st restore.Stats) {
+			op.setRestoreStats(st)
+		}
+
+		op.setCancel(func() {
+			close(cancelChan)
+		})
+
+		st, err := restore.Entry(ctx, s.rep, out, rootEntry, opt)
+		if err != nil {
+			return errors.Wrap(err, "error restoring")
+		}
+
+		op.setRestoreStats(st)
+
+		return nil
+	}), nil
+}
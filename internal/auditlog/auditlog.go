@@ -0,0 +1,291 @@
+// Package auditlog implements append-only, tamper-evident log of server operations stored in the repository.
+//
+// Each entry is stored as a separate manifest and includes the hash of the previous entry, forming a hash chain.
+// Modification or removal of any entry other than the most recent ones breaks the chain and is detected by Verify.
+// To detect truncation of the log, the hash of the last entry reported by Verify can be recorded externally.
+package auditlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// ManifestType is the type of manifest holding audit log entries.
+const ManifestType = "auditEntry"
+
+// Labels of audit log entry manifests.
+const (
+	SequenceLabel = "sequence"
+	ActorLabel    = "actor"
+	ActionLabel   = "action"
+)
+
+// Audited actions.
+const (
+	ActionSessionStart      = "session.start"
+	ActionSessionDenied     = "session.denied"
+	ActionSnapshotRestore   = "snapshot.restore"
+	ActionSnapshotStream    = "snapshot.download"
+	ActionSnapshotDelete    = "snapshot.delete"
+	ActionSourceDelete      = "source.delete"
+	ActionManifestDelete    = "manifest.delete"
+	ActionRestoreRequest    = "restore-request.create"
+	ActionPolicySet         = "policy.set"
+	ActionPolicyDelete      = "policy.delete"
+	ActionACLAdd            = "acl.add"
+	ActionACLDelete         = "acl.delete"
+	ActionMaintenanceSet    = "maintenance.set"
+	ActionMaintenanceOwner  = "maintenance.owner"
+	ActionWebhookSet        = "webhook.set"
+	ActionWebhookDelete     = "webhook.delete"
+	ActionIndexEpochAdvance = "index-epoch.advance"
+	ActionEnrollmentToken   = "enrollment-token.create"
+	ActionEnrollmentApprove = "enrollment.approve"
+	ActionEnrollmentReject  = "enrollment.reject"
+)
+
+// Entry is a single entry in the audit log.
+type Entry struct {
+	Sequence int64             `json:"sequence"`
+	Time     time.Time         `json:"time"`
+	Actor    string            `json:"actor"`
+	Action   string            `json:"action"`
+	Target   string            `json:"target,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	PrevHash string            `json:"prevHash"`
+	Hash     string            `json:"hash"`
+}
+
+// computeHash returns the hash of the entry contents, including the hash of the previous entry.
+func (e *Entry) computeHash() (string, error) {
+	c := *e
+	c.Hash = ""
+
+	// JSON encoding is deterministic since struct fields are ordered and map keys are sorted.
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal audit entry")
+	}
+
+	h := sha256.Sum256(b)
+
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Filter specifies audit log entries to return.
+type Filter struct {
+	Actor  string
+	Action string
+	From   time.Time // inclusive, zero == no limit
+	To     time.Time // exclusive, zero == no limit
+	Limit  int       // only return the most recent entries, 0 == all
+}
+
+// Record appends the entry to the audit log, setting its sequence number, time and hashes.
+// Callers must serialize calls to Record to keep the hash chain linear.
+func Record(ctx context.Context, w repo.RepositoryWriter, e *Entry) error {
+	last, err := latestEntry(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	e.Sequence = 1
+	e.PrevHash = ""
+
+	if last != nil {
+		e.Sequence = last.Sequence + 1
+		e.PrevHash = last.Hash
+	}
+
+	if e.Time.IsZero() {
+		e.Time = clock.Now()
+	}
+
+	e.Time = e.Time.UTC()
+
+	if e.Hash, err = e.computeHash(); err != nil {
+		return err
+	}
+
+	if _, err := w.PutManifest(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+		SequenceLabel:         formatSequence(e.Sequence),
+		ActorLabel:            e.Actor,
+		ActionLabel:           e.Action,
+	}, e); err != nil {
+		return errors.Wrap(err, "unable to write audit entry")
+	}
+
+	return nil
+}
+
+// List returns audit log entries matching the filter ordered by sequence.
+func List(ctx context.Context, rep repo.Repository, f Filter) ([]*Entry, error) {
+	labels := map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	}
+
+	if f.Actor != "" {
+		labels[ActorLabel] = f.Actor
+	}
+
+	if f.Action != "" {
+		labels[ActionLabel] = f.Action
+	}
+
+	entries, err := loadEntries(ctx, rep, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Entry
+
+	for _, e := range entries {
+		if !f.From.IsZero() && e.Time.Before(f.From) {
+			continue
+		}
+
+		if !f.To.IsZero() && !e.Time.Before(f.To) {
+			continue
+		}
+
+		result = append(result, e)
+	}
+
+	if f.Limit > 0 && len(result) > f.Limit {
+		result = result[len(result)-f.Limit:]
+	}
+
+	return result, nil
+}
+
+// VerificationResult describes the result of audit log verification.
+type VerificationResult struct {
+	EntryCount   int      `json:"entryCount"`
+	LastSequence int64    `json:"lastSequence"`
+	LastHash     string   `json:"lastHash"`
+	Problems     []string `json:"problems,omitempty"`
+}
+
+// Verify checks integrity of the hash chain of all audit log entries.
+func Verify(ctx context.Context, rep repo.Repository) (*VerificationResult, error) {
+	entries, err := loadEntries(ctx, rep, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerificationResult{
+		EntryCount: len(entries),
+	}
+
+	var prev *Entry
+
+	for _, e := range entries {
+		h, err := e.computeHash()
+		if err != nil {
+			return nil, err
+		}
+
+		if h != e.Hash {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v has been modified", e.Sequence))
+		}
+
+		switch {
+		case prev == nil && e.Sequence != 1:
+			result.Problems = append(result.Problems, fmt.Sprintf("entries before %v are missing", e.Sequence))
+
+		case prev != nil && e.Sequence == prev.Sequence:
+			result.Problems = append(result.Problems, fmt.Sprintf("duplicate entries with sequence %v", e.Sequence))
+
+		case prev != nil && e.Sequence != prev.Sequence+1:
+			result.Problems = append(result.Problems, fmt.Sprintf("entries %v..%v are missing", prev.Sequence+1, e.Sequence-1))
+
+		case prev != nil && e.PrevHash != prev.Hash:
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %v does not follow entry %v", e.Sequence, prev.Sequence))
+		}
+
+		prev = e
+	}
+
+	if prev != nil {
+		result.LastSequence = prev.Sequence
+		result.LastHash = prev.Hash
+	}
+
+	return result, nil
+}
+
+func latestEntry(ctx context.Context, rep repo.Repository) (*Entry, error) {
+	md, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find audit entries")
+	}
+
+	var latest *manifest.EntryMetadata
+
+	for _, m := range md {
+		if latest == nil || parseSequence(m.Labels[SequenceLabel]) > parseSequence(latest.Labels[SequenceLabel]) {
+			latest = m
+		}
+	}
+
+	if latest == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	e := &Entry{}
+	if _, err := rep.GetManifest(ctx, latest.ID, e); err != nil {
+		return nil, errors.Wrap(err, "unable to load audit entry")
+	}
+
+	return e, nil
+}
+
+func loadEntries(ctx context.Context, rep repo.Repository, labels map[string]string) ([]*Entry, error) {
+	md, err := rep.FindManifests(ctx, labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find audit entries")
+	}
+
+	var result []*Entry
+
+	for _, m := range md {
+		e := &Entry{}
+		if _, err := rep.GetManifest(ctx, m.ID, e); err != nil {
+			return nil, errors.Wrap(err, "unable to load audit entry")
+		}
+
+		result = append(result, e)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Sequence < result[j].Sequence
+	})
+
+	return result, nil
+}
+
+func formatSequence(seq int64) string {
+	return fmt.Sprintf("%016d", seq)
+}
+
+func parseSequence(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
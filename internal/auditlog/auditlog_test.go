@@ -0,0 +1,91 @@
+package auditlog_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, e := range []auditlog.Entry{
+		{Actor: "alice@host", Action: auditlog.ActionSessionStart},
+		{Actor: "alice@host", Action: auditlog.ActionSnapshotDelete, Target: "snap1"},
+		{Actor: "admin", Action: auditlog.ActionPolicySet, Target: "(global)", Details: map[string]string{"b": "2", "a": "1"}},
+		{Actor: "bob@host", Action: auditlog.ActionSessionStart},
+	} {
+		e.Time = t0.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, auditlog.Record(ctx, env.RepositoryWriter, &e))
+		require.Equal(t, int64(i+1), e.Sequence)
+		require.NotEmpty(t, e.Hash)
+	}
+
+	all, err := auditlog.List(ctx, env.RepositoryWriter, auditlog.Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	require.Empty(t, all[0].PrevHash)
+
+	for i := 1; i < len(all); i++ {
+		require.Equal(t, all[i-1].Hash, all[i].PrevHash)
+	}
+
+	entries, err := auditlog.List(ctx, env.RepositoryWriter, auditlog.Filter{Actor: "alice@host"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	entries, err = auditlog.List(ctx, env.RepositoryWriter, auditlog.Filter{Action: auditlog.ActionSessionStart, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "bob@host", entries[0].Actor)
+
+	entries, err = auditlog.List(ctx, env.RepositoryWriter, auditlog.Filter{From: t0.Add(time.Hour), To: t0.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, int64(2), entries[0].Sequence)
+
+	vr, err := auditlog.Verify(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, vr.Problems)
+	require.Equal(t, 4, vr.EntryCount)
+	require.Equal(t, int64(4), vr.LastSequence)
+	require.Equal(t, all[3].Hash, vr.LastHash)
+
+	md, err := env.RepositoryWriter.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey:  auditlog.ManifestType,
+		auditlog.SequenceLabel: "0000000000000002",
+	})
+	require.NoError(t, err)
+	require.Len(t, md, 1)
+
+	// modify an entry.
+	tampered := *all[1]
+	tampered.Target = "other"
+
+	require.NoError(t, env.RepositoryWriter.DeleteManifest(ctx, md[0].ID))
+	_, err = env.RepositoryWriter.PutManifest(ctx, md[0].Labels, &tampered)
+	require.NoError(t, err)
+
+	vr, err = auditlog.Verify(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, []string{"entry 2 has been modified"}, vr.Problems)
+
+	// remove an entry.
+	md, err = env.RepositoryWriter.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey:  auditlog.ManifestType,
+		auditlog.SequenceLabel: "0000000000000002",
+	})
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.DeleteManifest(ctx, md[0].ID))
+
+	vr, err = auditlog.Verify(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, []string{"entries 2..2 are missing"}, vr.Problems)
+}
@@ -0,0 +1,409 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+)
+
+const (
+	defaultOIDCUsernameClaim = "preferred_username"
+	defaultOIDCGroupsClaim   = "groups"
+
+	// minimum time between refreshes of signing keys caused by tokens signed with unknown keys.
+	minOIDCKeyRefreshInterval = time.Minute
+
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+)
+
+// OIDCOptions configures authentication using OpenID Connect ID tokens.
+type OIDCOptions struct {
+	IssuerURL     string            // URL of the OpenID Connect provider, must match 'iss' claim of ID tokens
+	ClientID      string            // client ID registered with the provider, must be the audience of ID tokens
+	ClientSecret  string            // client secret used to exchange authorization codes
+	RedirectURL   string            // URL of the server callback handling authorization codes
+	UsernameClaim string            // name of the claim holding the username, "preferred_username" if empty
+	GroupsClaim   string            // name of the claim holding the list of groups, "groups" if empty
+	GroupUsers    map[string]string // maps groups to users (possibly with '*' wildcards in user@host) that group members can act as
+	HTTPClient    *http.Client      // HTTP client used to talk to the provider, http.DefaultClient if nil
+}
+
+// OIDCIdentity describes the holder of a verified ID token.
+type OIDCIdentity struct {
+	Username string
+	Groups   []string
+	Expiry   time.Time
+}
+
+// CanActAs returns true if the identity can authenticate as the provided user, either
+// because the username matches or because one of its groups is mapped to that user.
+func (id *OIDCIdentity) CanActAs(username string, groupUsers map[string]string) bool {
+	if id.Username != "" && id.Username == username {
+		return true
+	}
+
+	for _, g := range id.Groups {
+		if pattern, ok := groupUsers[g]; ok && userPatternMatches(pattern, username) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// userPatternMatches matches the username against the pattern which may use '*' in place of the user or host part.
+func userPatternMatches(pattern, username string) bool {
+	if pattern == username {
+		return true
+	}
+
+	pu, ph, ok := strings.Cut(pattern, "@")
+	if !ok {
+		return false
+	}
+
+	u, h, ok := strings.Cut(username, "@")
+	if !ok {
+		return false
+	}
+
+	return (pu == "*" || pu == u) && (ph == "*" || ph == h)
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider verifies ID tokens issued by an OpenID Connect provider and implements the
+// authorization code flow used by the web UI.
+//
+// As an Authenticator it accepts ID tokens passed as passwords, which allows repository clients
+// and API users to authenticate using tokens obtained from the provider.
+type OIDCProvider struct {
+	opts OIDCOptions
+
+	mu sync.Mutex
+	// +checklocks:mu
+	discovery oidcDiscovery
+	// +checklocks:mu
+	keys map[string]interface{}
+	// +checklocks:mu
+	lastKeyRefresh time.Time
+}
+
+// NewOIDCProvider returns a new OIDCProvider after fetching the provider configuration and signing keys.
+func NewOIDCProvider(ctx context.Context, opts OIDCOptions) (*OIDCProvider, error) {
+	if opts.IssuerURL == "" || opts.ClientID == "" {
+		return nil, errors.New("OIDC issuer URL and client ID must be provided")
+	}
+
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = defaultOIDCUsernameClaim
+	}
+
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = defaultOIDCGroupsClaim
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	p := &OIDCProvider{opts: opts}
+
+	if err := p.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Refresh refreshes the provider configuration and signing keys.
+func (p *OIDCProvider) Refresh(ctx context.Context) error {
+	var d oidcDiscovery
+
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.opts.IssuerURL, "/")+oidcDiscoveryPath, &d); err != nil {
+		return errors.Wrap(err, "unable to get OIDC provider configuration")
+	}
+
+	if d.Issuer != p.opts.IssuerURL {
+		return errors.Errorf("OIDC issuer mismatch: %q, expected %q", d.Issuer, p.opts.IssuerURL)
+	}
+
+	keys, err := p.fetchKeys(ctx, d.JWKSURI)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.discovery = d
+	p.keys = keys
+	p.lastKeyRefresh = clock.Now()
+
+	return nil
+}
+
+func (p *OIDCProvider) fetchKeys(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := p.getJSON(ctx, jwksURI, &jwks); err != nil {
+		return nil, errors.Wrap(err, "unable to get OIDC signing keys")
+	}
+
+	keys := map[string]interface{}{}
+
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		pk, err := k.publicKey()
+		if err != nil {
+			log(ctx).Debugf("ignoring OIDC signing key %q: %v", k.Kid, err)
+			continue
+		}
+
+		keys[k.Kid] = pk
+	}
+
+	return keys, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+
+	resp, err := p.opts.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "error fetching %v", url)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("error fetching %v: %v", url, resp.Status)
+	}
+
+	return errors.Wrapf(json.NewDecoder(resp.Body).Decode(v), "invalid response from %v", url)
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid modulus")
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid exponent")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid x coordinate")
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid y coordinate")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	default:
+		return nil, errors.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// signingKey returns the key with a given ID, refreshing the keys if not found since the provider may have rotated them.
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	canRefresh := clock.Now().Sub(p.lastKeyRefresh) >= minOIDCKeyRefreshInterval
+	jwksURI := p.discovery.JWKSURI
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	if !canRefresh {
+		return nil, errors.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx, jwksURI)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.lastKeyRefresh = clock.Now()
+	key, ok = p.keys[kid]
+	p.mu.Unlock()
+
+	if !ok {
+		return nil, errors.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+// VerifyIDToken verifies the signature and claims of the provided ID token and returns the identity of its holder.
+// If nonce is not empty, the token must contain a matching 'nonce' claim.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawToken, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+
+	if _, err := parser.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		return p.signingKey(ctx, kid)
+	}); err != nil {
+		return nil, errors.Wrap(err, "invalid ID token")
+	}
+
+	if !claims.VerifyIssuer(p.opts.IssuerURL, true) {
+		return nil, errors.New("invalid ID token issuer")
+	}
+
+	if !claims.VerifyAudience(p.opts.ClientID, true) {
+		return nil, errors.New("invalid ID token audience")
+	}
+
+	if !claims.VerifyExpiresAt(clock.Now().Unix(), true) {
+		return nil, errors.New("ID token expired")
+	}
+
+	if nonce != "" && claims["nonce"] != nonce {
+		return nil, errors.New("invalid ID token nonce")
+	}
+
+	id := &OIDCIdentity{}
+
+	id.Username, _ = claims[p.opts.UsernameClaim].(string)
+
+	if groups, ok := claims[p.opts.GroupsClaim].([]interface{}); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+
+	if exp, ok := claims["exp"].(float64); ok {
+		id.Expiry = time.Unix(int64(exp), 0)
+	}
+
+	return id, nil
+}
+
+// CanActAs returns true if the identity can authenticate as the provided user.
+func (p *OIDCProvider) CanActAs(id *OIDCIdentity, username string) bool {
+	return id.CanActAs(username, p.opts.GroupUsers)
+}
+
+// IsValid implements Authenticator by treating the password as an ID token.
+func (p *OIDCProvider) IsValid(ctx context.Context, _ repo.Repository, username, password string) bool {
+	id, err := p.VerifyIDToken(ctx, password, "")
+	if err != nil {
+		return false
+	}
+
+	return p.CanActAs(id, username)
+}
+
+func (p *OIDCProvider) oauth2Config() *oauth2.Config {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return &oauth2.Config{
+		ClientID:     p.opts.ClientID,
+		ClientSecret: p.opts.ClientSecret,
+		RedirectURL:  p.opts.RedirectURL,
+		Scopes:       []string{"openid", "profile", "email"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.discovery.AuthorizationEndpoint,
+			TokenURL: p.discovery.TokenEndpoint,
+		},
+	}
+}
+
+// AuthCodeURL returns the URL of the provider login page which redirects back with an authorization code.
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	return p.oauth2Config().AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// Exchange exchanges the authorization code for an ID token and returns the verified identity.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*OIDCIdentity, error) {
+	tok, err := p.oauth2Config().Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.opts.HTTPClient), code)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to exchange authorization code")
+	}
+
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response does not include ID token")
+	}
+
+	return p.VerifyIDToken(ctx, rawIDToken, nonce)
+}
+
+var _ Authenticator = (*OIDCProvider)(nil)
@@ -0,0 +1,209 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+const (
+	testOIDCClientID = "kopia-client"
+	testOIDCKeyID    = "key1"
+	testOIDCCode     = "some-code"
+)
+
+type fakeOIDCProvider struct {
+	*httptest.Server
+
+	key *rsa.PrivateKey
+
+	// claims of the ID token returned by the token endpoint
+	codeClaims jwt.MapClaims
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &fakeOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{ //nolint:errcheck
+			"keys": []map[string]string{{
+				"kid": testOIDCKeyID,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != testOIDCCode {
+			http.Error(w, "invalid code", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck
+			"access_token": "some-access-token",
+			"token_type":   "Bearer",
+			"id_token":     p.sign(t, p.codeClaims),
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+
+	return p
+}
+
+func (p *fakeOIDCProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = testOIDCKeyID
+
+	s, err := tok.SignedString(p.key)
+	require.NoError(t, err)
+
+	return s
+}
+
+func (p *fakeOIDCProvider) claims(username string, groups ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                p.URL,
+		"aud":                testOIDCClientID,
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"preferred_username": username,
+		"groups":             groups,
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+	fp := newFakeOIDCProvider(t)
+
+	p, err := auth.NewOIDCProvider(ctx, auth.OIDCOptions{
+		IssuerURL:   fp.URL,
+		ClientID:    testOIDCClientID,
+		RedirectURL: "https://kopia.example.com/callback",
+		GroupUsers: map[string]string{
+			"backup-admins":  "admin",
+			"backup-clients": "*@workstation",
+		},
+	})
+	require.NoError(t, err)
+
+	id, err := p.VerifyIDToken(ctx, fp.sign(t, fp.claims("alice", "backup-admins")), "")
+	require.NoError(t, err)
+	require.Equal(t, "alice", id.Username)
+	require.Equal(t, []string{"backup-admins"}, id.Groups)
+	require.True(t, p.CanActAs(id, "alice"))
+	require.True(t, p.CanActAs(id, "admin"))
+	require.False(t, p.CanActAs(id, "bob@workstation"))
+
+	// group mapped to user pattern
+	tok := fp.sign(t, fp.claims("bob", "backup-clients"))
+	verifyAuthenticator(t, p, "bob@workstation", tok, true)
+	verifyAuthenticator(t, p, "alice@workstation", tok, true)
+	verifyAuthenticator(t, p, "bob@laptop", tok, false)
+	verifyAuthenticator(t, p, "admin", tok, false)
+
+	expired := fp.claims("alice")
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	verifyAuthenticator(t, p, "alice", fp.sign(t, expired), false)
+
+	wrongAudience := fp.claims("alice")
+	wrongAudience["aud"] = "other-client"
+	verifyAuthenticator(t, p, "alice", fp.sign(t, wrongAudience), false)
+
+	wrongIssuer := fp.claims("alice")
+	wrongIssuer["iss"] = "https://evil.example.com"
+	verifyAuthenticator(t, p, "alice", fp.sign(t, wrongIssuer), false)
+
+	verifyAuthenticator(t, p, "alice", "not-a-token", false)
+
+	// token signed with a different key
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, fp.claims("alice"))
+	forged.Header["kid"] = testOIDCKeyID
+	forgedToken, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	verifyAuthenticator(t, p, "alice", forgedToken, false)
+
+	require.NoError(t, p.Refresh(ctx))
+}
+
+func TestOIDCProvider_AuthorizationCodeFlow(t *testing.T) {
+	ctx := testlogging.Context(t)
+	fp := newFakeOIDCProvider(t)
+
+	p, err := auth.NewOIDCProvider(ctx, auth.OIDCOptions{
+		IssuerURL:    fp.URL,
+		ClientID:     testOIDCClientID,
+		ClientSecret: "some-secret",
+		RedirectURL:  "https://kopia.example.com/callback",
+	})
+	require.NoError(t, err)
+
+	u, err := url.Parse(p.AuthCodeURL("some-state", "some-nonce"))
+	require.NoError(t, err)
+	require.Equal(t, fp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	require.Equal(t, "some-state", u.Query().Get("state"))
+	require.Equal(t, "some-nonce", u.Query().Get("nonce"))
+	require.Equal(t, testOIDCClientID, u.Query().Get("client_id"))
+
+	fp.codeClaims = fp.claims("alice")
+	fp.codeClaims["nonce"] = "some-nonce"
+
+	id, err := p.Exchange(ctx, testOIDCCode, "some-nonce")
+	require.NoError(t, err)
+	require.Equal(t, "alice", id.Username)
+
+	_, err = p.Exchange(ctx, testOIDCCode, "other-nonce")
+	require.Error(t, err)
+
+	_, err = p.Exchange(ctx, "bad-code", "some-nonce")
+	require.Error(t, err)
+}
+
+func TestNewOIDCProvider_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := auth.NewOIDCProvider(ctx, auth.OIDCOptions{ClientID: testOIDCClientID})
+	require.Error(t, err)
+
+	fp := newFakeOIDCProvider(t)
+
+	_, err = auth.NewOIDCProvider(ctx, auth.OIDCOptions{IssuerURL: fp.URL + "/other", ClientID: testOIDCClientID})
+	require.Error(t, err)
+}
@@ -51,6 +51,23 @@ func NoAccess() AuthorizationInfo {
 	return noAccessAuthorizationInfo{}
 }
 
+type readOnlyAuthorizationInfo struct {
+	base AuthorizationInfo
+}
+
+func (ro readOnlyAuthorizationInfo) ContentAccessLevel() AccessLevel {
+	return min(ro.base.ContentAccessLevel(), AccessLevelRead)
+}
+
+func (ro readOnlyAuthorizationInfo) ManifestAccessLevel(labels map[string]string) AccessLevel {
+	return min(ro.base.ManifestAccessLevel(labels), AccessLevelRead)
+}
+
+// ReadOnly returns AuthorizationInfo which grants at most read access to whatever the provided one permits.
+func ReadOnly(base AuthorizationInfo) AuthorizationInfo {
+	return readOnlyAuthorizationInfo{base}
+}
+
 type legacyAuthorizationInfo struct {
 	usernameAtHostname string
 }
@@ -58,9 +75,9 @@ type legacyAuthorizationInfo struct {
 func (la legacyAuthorizationInfo) ContentAccessLevel() AccessLevel { return AccessLevelFull }
 func (la legacyAuthorizationInfo) ManifestAccessLevel(labels map[string]string) AccessLevel {
 	if labels[manifest.TypeLabelKey] == policy.ManifestType {
-		// everybody can read global policy.
+		// everybody can read global and enforced policy.
 		switch labels[policy.PolicyTypeLabel] {
-		case policy.PolicyTypeGlobal:
+		case policy.PolicyTypeGlobal, policy.PolicyTypeEnforced:
 			return AccessLevelRead
 
 		case policy.PolicyTypeHost:
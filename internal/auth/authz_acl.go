@@ -8,6 +8,7 @@
 
 	"github.com/kopia/kopia/internal/acl"
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/remoterestore"
 	"github.com/kopia/kopia/internal/user"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
@@ -46,6 +47,15 @@
 		},
 		Access: AccessLevelRead,
 	},
+	{
+		// everybody can read enforced policy.
+		User: anyUser,
+		Target: acl.TargetRule{
+			manifest.TypeLabelKey:  policy.ManifestType,
+			policy.PolicyTypeLabel: policy.PolicyTypeEnforced,
+		},
+		Access: AccessLevelRead,
+	},
 	{
 		// users *@host can read own host's policy.
 		User: anyUser,
@@ -76,6 +86,16 @@
 		},
 		Access: acl.AccessLevelFull,
 	},
+	{
+		// username@hostname can read and report progress of restore requests scheduled for them
+		User: anyUser,
+		Target: acl.TargetRule{
+			manifest.TypeLabelKey:  remoterestore.ManifestType,
+			snapshot.UsernameLabel: acl.OwnUser,
+			snapshot.HostnameLabel: acl.OwnHost,
+		},
+		Access: acl.AccessLevelFull,
+	},
 	{
 		// username@hostname has full access to their user account and can change password
 		User: anyUser,
@@ -91,6 +91,28 @@ func TestNoAccess(t *testing.T) {
 	verifyManifestAccessLevel(t, na, fooAtBazSnapshot, auth.AccessLevelNone)
 }
 
+func TestReadOnly(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	ro := auth.ReadOnly(auth.LegacyAuthorizer().Authorize(ctx, env.Repository, "foo@bar"))
+
+	if got, want := ro.ContentAccessLevel(), auth.AccessLevelRead; got != want {
+		t.Errorf("invalid content access level: %v, want %v", got, want)
+	}
+
+	verifyManifestAccessLevel(t, ro, globalPolicyLabels, auth.AccessLevelRead)
+	verifyManifestAccessLevel(t, ro, fooAtBarPathPolicy, auth.AccessLevelRead)
+	verifyManifestAccessLevel(t, ro, fooAtBazPathPolicy, auth.AccessLevelNone)
+	verifyManifestAccessLevel(t, ro, fooAtBarSnapshot, auth.AccessLevelRead)
+	verifyManifestAccessLevel(t, ro, fooAtBazSnapshot, auth.AccessLevelNone)
+
+	na := auth.ReadOnly(auth.NoAccess())
+
+	if got, want := na.ContentAccessLevel(), auth.AccessLevelNone; got != want {
+		t.Errorf("invalid content access level: %v, want %v", got, want)
+	}
+}
+
 func TestLegacyAuthorizer(t *testing.T) {
 	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
 
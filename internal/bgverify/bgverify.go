@@ -0,0 +1,365 @@
+// Package bgverify implements continuous verification of repository contents in the background.
+//
+// Contents are verified in the order of their IDs at a limited rate, so that over time every content
+// in the repository is downloaded, decrypted and checked against its hash without putting noticeable load
+// on the storage. The position of verification is persisted, so passes resume after restarts.
+package bgverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("kopia/bgverify")
+
+const (
+	// DefaultBytesPerHour is the default verification rate.
+	DefaultBytesPerHour = 1 << 30
+
+	defaultCheckpointInterval = time.Minute
+
+	// minimum burst of the rate limiter, so that large contents don't need to be verified in many steps.
+	minBurstBytes = 1 << 20
+
+	secondsPerHour = 3600
+)
+
+// Options provides configuration of the verifier.
+type Options struct {
+	// BytesPerHour limits the rate of verification, 0 == unlimited.
+	BytesPerHour int64
+
+	// StateFile is the name of the JSON file storing the verification state, empty if not persisted.
+	StateFile string
+
+	// CheckpointInterval is the interval between saving the verification state during a pass.
+	CheckpointInterval time.Duration
+
+	// OnCheckpoint, when set, is invoked with the current status whenever the state is saved.
+	OnCheckpoint func(s Status)
+}
+
+// State describes the position and results of verification, which is persisted across restarts.
+type State struct {
+	// Cursor is the ID of the last content verified in the current pass, empty if no pass is in progress.
+	Cursor string `json:"cursor,omitempty"`
+
+	PassStartTime        time.Time `json:"passStartTime"`
+	PassTotalBytes       int64     `json:"passTotalBytes"`
+	PassVerifiedBytes    int64     `json:"passVerifiedBytes"`
+	PassVerifiedContents int64     `json:"passVerifiedContents"`
+	PassErrorCount       int64     `json:"passErrorCount"`
+
+	LastFullPassStartTime  time.Time `json:"lastFullPassStartTime"`
+	LastFullPassEndTime    time.Time `json:"lastFullPassEndTime"`
+	LastFullPassErrorCount int64     `json:"lastFullPassErrorCount"`
+
+	LastErrorTime time.Time `json:"lastErrorTime"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// Status describes the current state of verification.
+type Status struct {
+	State
+
+	Running      bool  `json:"running"`
+	BytesPerHour int64 `json:"bytesPerHour"`
+
+	// CoveragePercent is the percentage of bytes verified in the current pass.
+	CoveragePercent float64 `json:"coveragePercent"`
+
+	// LastFullPassAge is the time elapsed since the end of the last full pass, 0 if there was none.
+	LastFullPassAge time.Duration `json:"lastFullPassAge"`
+}
+
+// Verifier verifies contents of a repository at a limited rate.
+type Verifier struct {
+	rep     repo.DirectRepository
+	opt     Options
+	limiter *rate.Limiter
+
+	mu sync.Mutex
+	// +checklocks:mu
+	state State
+	// +checklocks:mu
+	running bool
+	// +checklocks:mu
+	lastCheckpoint time.Time
+}
+
+// New creates a verifier of the provided repository, loading previously persisted state, if any.
+func New(rep repo.DirectRepository, opt Options) (*Verifier, error) {
+	if opt.CheckpointInterval <= 0 {
+		opt.CheckpointInterval = defaultCheckpointInterval
+	}
+
+	v := &Verifier{
+		rep: rep,
+		opt: opt,
+	}
+
+	if opt.BytesPerHour > 0 {
+		bytesPerSecond := float64(opt.BytesPerHour) / secondsPerHour
+
+		v.limiter = rate.NewLimiter(rate.Limit(bytesPerSecond), max(int(bytesPerSecond), minBurstBytes))
+	}
+
+	if err := v.loadState(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (v *Verifier) loadState() error {
+	if v.opt.StateFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(v.opt.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to read verification state")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return errors.Wrap(json.Unmarshal(b, &v.state), "invalid verification state")
+}
+
+// +checklocks:v.mu
+func (v *Verifier) saveStateLocked() error {
+	v.lastCheckpoint = clock.Now()
+
+	if v.opt.StateFile == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(v.state, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal verification state")
+	}
+
+	return errors.Wrap(atomic.WriteFile(v.opt.StateFile, bytes.NewReader(b)), "unable to write verification state")
+}
+
+// Status returns the current status of verification.
+func (v *Verifier) Status() Status {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.statusLocked()
+}
+
+// +checklocks:v.mu
+func (v *Verifier) statusLocked() Status {
+	s := Status{
+		State:        v.state,
+		Running:      v.running,
+		BytesPerHour: v.opt.BytesPerHour,
+	}
+
+	if s.PassTotalBytes > 0 {
+		s.CoveragePercent = min(100, 100*float64(s.PassVerifiedBytes)/float64(s.PassTotalBytes))
+	}
+
+	if !s.LastFullPassEndTime.IsZero() {
+		s.LastFullPassAge = clock.Now().Sub(s.LastFullPassEndTime)
+	}
+
+	return s
+}
+
+// Run verifies contents continuously until the context is canceled.
+func (v *Verifier) Run(ctx context.Context) error {
+	for {
+		if err := v.RunPass(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// RunPass verifies contents until the end of the current pass, starting a new pass if none is in progress.
+func (v *Verifier) RunPass(ctx context.Context) error {
+	v.mu.Lock()
+	if v.running {
+		v.mu.Unlock()
+		return errors.New("verification is already running")
+	}
+
+	v.running = true
+	v.mu.Unlock()
+
+	defer func() {
+		v.mu.Lock()
+		v.running = false
+		v.mu.Unlock()
+	}()
+
+	if err := v.maybeStartPass(ctx); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	cursor := v.state.Cursor
+	v.mu.Unlock()
+
+	err := v.rep.ContentReader().IterateContents(ctx, content.IterateOptions{
+		Range: index.IDRange{StartID: index.IDPrefix(cursor), EndID: index.AllIDs.EndID},
+	}, func(ci content.Info) error {
+		if ci.ContentID.String() == cursor {
+			return nil
+		}
+
+		return v.verifyContent(ctx, ci)
+	})
+
+	if err != nil {
+		if serr := v.checkpoint(); serr != nil {
+			log(ctx).Errorf("unable to save verification state: %v", serr)
+		}
+
+		return errors.Wrap(err, "verification interrupted")
+	}
+
+	v.mu.Lock()
+	log(ctx).Infof("Finished verification pass of %v contents (%v bytes), found %v errors.",
+		v.state.PassVerifiedContents, v.state.PassVerifiedBytes, v.state.PassErrorCount)
+
+	v.state.LastFullPassStartTime = v.state.PassStartTime
+	v.state.LastFullPassEndTime = clock.Now()
+	v.state.LastFullPassErrorCount = v.state.PassErrorCount
+	v.state.Cursor = ""
+	v.state.PassStartTime = time.Time{}
+	v.mu.Unlock()
+
+	return v.checkpoint()
+}
+
+// maybeStartPass starts a new pass if none is in progress, estimating the number of bytes to verify.
+func (v *Verifier) maybeStartPass(ctx context.Context) error {
+	v.mu.Lock()
+	inProgress := !v.state.PassStartTime.IsZero()
+	v.mu.Unlock()
+
+	if inProgress {
+		return nil
+	}
+
+	var totalBytes int64
+
+	if err := v.rep.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		totalBytes += int64(ci.PackedLength)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "unable to estimate size of contents")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.state.Cursor = ""
+	v.state.PassStartTime = clock.Now()
+	v.state.PassTotalBytes = totalBytes
+	v.state.PassVerifiedBytes = 0
+	v.state.PassVerifiedContents = 0
+	v.state.PassErrorCount = 0
+
+	log(ctx).Infof("Starting verification pass of %v bytes.", totalBytes)
+
+	return v.saveStateLocked()
+}
+
+func (v *Verifier) verifyContent(ctx context.Context, ci content.Info) error {
+	if err := v.throttle(ctx, int(ci.PackedLength)); err != nil {
+		return err
+	}
+
+	_, verr := v.rep.ContentReader().GetContent(ctx, ci.ContentID)
+	if verr != nil && ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "canceled")
+	}
+
+	v.mu.Lock()
+
+	switch {
+	case errors.Is(verr, content.ErrEncryptionDomainLocked):
+		// contents of other encryption domains can't be verified by this connection.
+		log(ctx).Debugf("skipping content %v: %v", ci.ContentID, verr)
+
+	case verr != nil:
+		log(ctx).Errorf("content %v is invalid: %v", ci.ContentID, verr)
+
+		v.state.PassErrorCount++
+		v.state.LastErrorTime = clock.Now()
+		v.state.LastError = errors.Wrapf(verr, "content %v", ci.ContentID).Error()
+	}
+
+	v.state.Cursor = ci.ContentID.String()
+	v.state.PassVerifiedBytes += int64(ci.PackedLength)
+	v.state.PassVerifiedContents++
+
+	due := clock.Now().Sub(v.lastCheckpoint) >= v.opt.CheckpointInterval
+	v.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	return v.checkpoint()
+}
+
+// checkpoint saves the verification state and notifies the OnCheckpoint callback.
+func (v *Verifier) checkpoint() error {
+	v.mu.Lock()
+	err := v.saveStateLocked()
+	s := v.statusLocked()
+	v.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if v.opt.OnCheckpoint != nil {
+		v.opt.OnCheckpoint(s)
+	}
+
+	return nil
+}
+
+// throttle waits until verifying n bytes is allowed by the rate limit.
+func (v *Verifier) throttle(ctx context.Context, n int) error {
+	if v.limiter == nil {
+		return nil
+	}
+
+	for n > 0 {
+		chunk := min(n, v.limiter.Burst())
+
+		if err := v.limiter.WaitN(ctx, chunk); err != nil {
+			return errors.Wrap(err, "throttle")
+		}
+
+		n -= chunk
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package bgverify_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/bgverify"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+)
+
+const numContents = 20
+
+func TestVerifierResumesPass(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+	stateFile := filepath.Join(testutil.TempDirectory(t), "verify.json")
+
+	for i := range numContents {
+		_, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice([]byte{byte(i), 1, 2, 3}), "", content.NoCompression)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	var total int64
+
+	require.NoError(t, env.RepositoryWriter.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(content.Info) error {
+		total++
+		return nil
+	}))
+
+	// interrupt the first pass after a few contents.
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	v, err := bgverify.New(env.RepositoryWriter, bgverify.Options{
+		StateFile:          stateFile,
+		CheckpointInterval: 1,
+		OnCheckpoint: func(s bgverify.Status) {
+			if s.PassVerifiedContents >= 5 {
+				cancel()
+			}
+		},
+	})
+	require.NoError(t, err)
+	require.Error(t, v.RunPass(cctx))
+
+	s := v.Status()
+	require.False(t, s.Running)
+	require.NotEmpty(t, s.Cursor)
+	require.Less(t, s.CoveragePercent, 100.0)
+	require.True(t, s.LastFullPassEndTime.IsZero())
+
+	// new verifier continues where the previous one stopped.
+	v, err = bgverify.New(env.RepositoryWriter, bgverify.Options{StateFile: stateFile})
+	require.NoError(t, err)
+	require.Equal(t, s.Cursor, v.Status().Cursor)
+	require.NoError(t, v.RunPass(ctx))
+
+	s = v.Status()
+	require.Empty(t, s.Cursor)
+	require.False(t, s.LastFullPassEndTime.IsZero())
+	require.Equal(t, int64(0), s.LastFullPassErrorCount)
+	require.Equal(t, total, s.PassVerifiedContents)
+}
+
+func TestVerifierReportsErrors(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	for i := range numContents {
+		_, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice([]byte{byte(i), 1, 2, 3}), "", content.NoCompression)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	require.NoError(t, env.RootStorage().ListBlobs(ctx, content.PackBlobIDPrefixRegular, func(bm blob.Metadata) error {
+		return env.RootStorage().DeleteBlob(ctx, bm.BlobID)
+	}))
+
+	env.MustReopen(t)
+
+	v, err := bgverify.New(env.RepositoryWriter, bgverify.Options{})
+	require.NoError(t, err)
+	require.NoError(t, v.RunPass(ctx))
+
+	s := v.Status()
+	require.Equal(t, int64(numContents), s.LastFullPassErrorCount)
+	require.NotEmpty(t, s.LastError)
+	require.InDelta(t, 100.0, s.CoveragePercent, 0.01)
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"sync"
+)
+
+// Priority determines the order in which items are evicted from caches sharing a Budget.
+// Items in lower-priority caches are evicted first and writes to a cache never evict items
+// from caches with higher priority.
+type Priority int
+
+// Supported cache priorities.
+const (
+	PriorityData Priority = iota
+	PriorityIndex
+	PriorityMetadata
+)
+
+// Budget is a size limit shared by multiple persistent caches.
+type Budget struct {
+	maxSizeBytes int64
+
+	mu sync.Mutex
+	// +checklocks:mu
+	members []*PersistentCache
+}
+
+// NewBudget returns a new shared cache budget with the provided size or nil if the size is not positive.
+func NewBudget(maxSizeBytes int64) *Budget {
+	if maxSizeBytes <= 0 {
+		return nil
+	}
+
+	return &Budget{maxSizeBytes: maxSizeBytes}
+}
+
+// MaxSizeBytes returns the maximum size of the budget.
+func (b *Budget) MaxSizeBytes() int64 {
+	if b == nil {
+		return 0
+	}
+
+	return b.maxSizeBytes
+}
+
+// UsedBytes returns the total size of all caches sharing the budget.
+func (b *Budget) UsedBytes() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.usedBytesLocked()
+}
+
+// +checklocks:b.mu
+func (b *Budget) usedBytesLocked() int64 {
+	var total int64
+
+	for _, m := range b.members {
+		total += m.usedBytes()
+	}
+
+	return total
+}
+
+func (b *Budget) register(c *PersistentCache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.members = append(b.members, c)
+
+	sort.SliceStable(b.members, func(i, j int) bool {
+		return b.members[i].sweep.Priority < b.members[j].sweep.Priority
+	})
+}
+
+func (b *Budget) unregister(c *PersistentCache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, m := range b.members {
+		if m == c {
+			b.members = append(b.members[:i], b.members[i+1:]...)
+			return
+		}
+	}
+}
+
+// makeRoom evicts items from caches with priority lower or equal to the requesting cache, starting from
+// the lowest priority until there's room for the provided number of bytes.
+//
+// Must not be called while holding the listCacheMutex of any cache.
+func (b *Budget) makeRoom(ctx context.Context, requester *PersistentCache, extraBytes int64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	excess := b.usedBytesLocked() + extraBytes - b.maxSizeBytes
+
+	for _, m := range b.members {
+		if excess <= 0 || m.sweep.Priority > requester.sweep.Priority {
+			return
+		}
+
+		excess -= m.evictOldest(ctx, excess)
+	}
+}
+
+// usedBytes returns the number of bytes used by the cache, including pending writes.
+func (c *PersistentCache) usedBytes() int64 {
+	c.listCacheMutex.Lock()
+	defer c.listCacheMutex.Unlock()
+
+	return c.listCache.totalDataBytes + c.pendingWriteBytes
+}
+
+// evictOldest evicts the oldest items that are older than minimum sweep age until
+// the provided number of bytes is freed and returns the number of bytes freed.
+func (c *PersistentCache) evictOldest(ctx context.Context, bytesToFree int64) int64 {
+	c.listCacheMutex.Lock()
+	defer c.listCacheMutex.Unlock()
+
+	var (
+		freed int64
+		now   = c.timeNow()
+	)
+
+	for freed < bytesToFree && len(c.listCache.data) > 0 {
+		oldest := c.listCache.data[0]
+
+		if now.Sub(oldest.Timestamp) < c.sweep.MinSweepAge {
+			break
+		}
+
+		if err := c.cacheStorage.DeleteBlob(ctx, oldest.BlobID); err != nil {
+			log(ctx).Warnw("unable to remove cache item", "cache", c.description, "item", oldest.BlobID, "err", err)
+			break
+		}
+
+		heap.Pop(&c.listCache)
+
+		freed += oldest.Length
+
+		c.reportEviction()
+	}
+
+	return freed
+}
@@ -0,0 +1,118 @@
+package cache_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestBudgetPriorityEviction(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	budget := cache.NewBudget(1000)
+	require.Equal(t, int64(1000), budget.MaxSizeBytes())
+	require.Nil(t, cache.NewBudget(0))
+
+	newCache := func(desc string, prio cache.Priority) (*cache.PersistentCache, cache.Storage) {
+		cs := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil).(cache.Storage)
+
+		pc, err := cache.NewPersistentCache(ctx, desc, cs, nil, cache.SweepSettings{
+			MaxSizeBytes: 10000,
+			Budget:       budget,
+			Priority:     prio,
+		}, nil, clock.Now)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { pc.Close(ctx) })
+
+		return pc, cs
+	}
+
+	dataCache, dataStorage := newCache("data", cache.PriorityData)
+	indexCache, indexStorage := newCache("index", cache.PriorityIndex)
+	metadataCache, metadataStorage := newCache("metadata", cache.PriorityMetadata)
+
+	item := gather.FromSlice(bytes.Repeat([]byte{1}, 300))
+
+	metadataCache.Put(ctx, "m1", item)
+	metadataCache.Put(ctx, "m2", item)
+	dataCache.Put(ctx, "d1", item)
+	require.Equal(t, int64(900), budget.UsedBytes())
+
+	// data can only evict other data.
+	dataCache.Put(ctx, "d2", item)
+	require.Equal(t, int64(900), budget.UsedBytes())
+	verifyBlobDoesNotExist(ctx, t, dataStorage, "d1")
+	verifyBlobExists(ctx, t, dataStorage, "d2")
+
+	// index evicts data.
+	indexCache.Put(ctx, "i1", item)
+	require.Equal(t, int64(900), budget.UsedBytes())
+	verifyBlobDoesNotExist(ctx, t, dataStorage, "d2")
+	verifyBlobExists(ctx, t, indexStorage, "i1")
+
+	// data cache has nothing to evict, the budget is temporarily exceeded but metadata and index are retained.
+	dataCache.Put(ctx, "d3", item)
+	require.Equal(t, int64(1200), budget.UsedBytes())
+	verifyBlobExists(ctx, t, metadataStorage, "m1")
+	verifyBlobExists(ctx, t, indexStorage, "i1")
+
+	// metadata evicts data first, then indexes.
+	metadataCache.Put(ctx, "m3", item)
+	require.Equal(t, int64(900), budget.UsedBytes())
+	verifyBlobDoesNotExist(ctx, t, dataStorage, "d3")
+	verifyBlobDoesNotExist(ctx, t, indexStorage, "i1")
+	verifyBlobExists(ctx, t, metadataStorage, "m1")
+	verifyBlobExists(ctx, t, metadataStorage, "m2")
+	verifyBlobExists(ctx, t, metadataStorage, "m3")
+
+	require.Equal(t, int64(3), dataCache.Stats().EvictedCount)
+	require.Equal(t, int64(1), indexCache.Stats().EvictedCount)
+	require.Equal(t, int64(0), metadataCache.Stats().EvictedCount)
+	require.Equal(t, 3, metadataCache.Stats().Count)
+}
+
+func TestPersistentCacheStats(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cs := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil).(cache.Storage)
+
+	pc, err := cache.NewPersistentCache(ctx, "testing", cs, nil, cache.SweepSettings{MaxSizeBytes: 10000}, nil, clock.Now)
+	require.NoError(t, err)
+
+	defer pc.Close(ctx)
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	require.NoError(t, pc.GetOrLoad(ctx, "key", func(output *gather.WriteBuffer) error {
+		output.Append([]byte{1, 2, 3})
+		return nil
+	}, &tmp))
+	require.True(t, pc.GetFull(ctx, "key", &tmp))
+	require.True(t, pc.GetFull(ctx, "key", &tmp))
+
+	s := pc.Stats()
+	require.Equal(t, "testing", s.Description)
+	require.Equal(t, int64(2), s.HitCount)
+	require.Equal(t, int64(6), s.HitBytes)
+	require.Equal(t, int64(1), s.MissCount)
+	require.Equal(t, int64(3), s.MissBytes)
+	require.InDelta(t, 2.0/3, s.HitRatio(), 0.001)
+	require.Equal(t, 1, s.Count)
+	require.Equal(t, int64(3), s.SizeBytes)
+
+	require.Zero(t, cache.Stats{}.HitRatio())
+	require.Equal(t, int64(4), s.Add(s).HitCount)
+
+	var nilCache *cache.PersistentCache
+
+	require.Equal(t, cache.Stats{}, nilCache.Stats())
+}
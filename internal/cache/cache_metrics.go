@@ -1,8 +1,23 @@
 package cache
 
-import "github.com/kopia/kopia/internal/metrics"
+import (
+	"sync/atomic"
+
+	"github.com/kopia/kopia/internal/metrics"
+)
+
+// statsCounters keeps cumulative cache statistics that are not reset when metrics are pushed.
+type statsCounters struct {
+	hitCount     atomic.Int64
+	hitBytes     atomic.Int64
+	missCount    atomic.Int64
+	missBytes    atomic.Int64
+	evictedCount atomic.Int64
+}
 
 type metricsStruct struct {
+	counters *statsCounters
+
 	metricHitCount                *metrics.Counter
 	metricHitBytes                *metrics.Counter
 	metricMissCount               *metrics.Counter
@@ -18,6 +33,8 @@ func initMetricsStruct(mr *metrics.Registry, cacheID string) metricsStruct {
 	}
 
 	return metricsStruct{
+		counters: &statsCounters{},
+
 		metricHitCount: mr.CounterInt64(
 			"cache_hit",
 			"Number of time content was retrieved from the cache", labels),
@@ -52,14 +69,28 @@ func (s *metricsStruct) reportMissError() {
 	s.metricMissErrors.Add(1)
 }
 
-func (s *metricsStruct) reportMissBytes(length int64) {
+// reportLookupMiss reports an item that was not found in the cache, it does not affect
+// cumulative statistics since the subsequent fetch is reported using reportMissBytes.
+func (s *metricsStruct) reportLookupMiss(length int64) {
 	s.metricMissCount.Add(1)
 	s.metricMissBytes.Add(length)
 }
 
+func (s *metricsStruct) reportMissBytes(length int64) {
+	s.reportLookupMiss(length)
+	s.counters.missCount.Add(1)
+	s.counters.missBytes.Add(length)
+}
+
 func (s *metricsStruct) reportHitBytes(length int64) {
 	s.metricHitCount.Add(1)
 	s.metricHitBytes.Add(length)
+	s.counters.hitCount.Add(1)
+	s.counters.hitBytes.Add(length)
+}
+
+func (s *metricsStruct) reportEviction() {
+	s.counters.evictedCount.Add(1)
 }
 
 func (s *metricsStruct) reportMalformedData() {
@@ -18,7 +18,17 @@ type ContentCache interface {
 	Close(ctx context.Context)
 	GetContent(ctx context.Context, contentID string, blobID blob.ID, offset, length int64, output *gather.WriteBuffer) error
 	PrefetchBlob(ctx context.Context, blobID blob.ID) error
+	PrefetchContentRange(ctx context.Context, blobID blob.ID, offset, length int64, contents []ContentRange) error
+	GetContentRange(ctx context.Context, blobID blob.ID, contents []ContentRange, outputs []*gather.WriteBuffer) error
 	CacheStorage() Storage
+	Stats() Stats
+}
+
+// ContentRange describes the location of a content in a pack blob.
+type ContentRange struct {
+	ContentID string
+	Offset    int64
+	Length    int64
 }
 
 // Options encapsulates all content cache options.
@@ -167,10 +177,169 @@ func (c *contentCacheImpl) PrefetchBlob(ctx context.Context, blobID blob.ID) err
 	return c.fetchBlobInternal(ctx, blobID, &blobData)
 }
 
+// PrefetchContentRange fetches the provided range of a blob with a single read and caches
+// all provided contents located within that range.
+func (c *contentCacheImpl) PrefetchContentRange(ctx context.Context, blobID blob.ID, offset, length int64, contents []ContentRange) error {
+	if c.fetchFullBlobs {
+		return c.PrefetchBlob(ctx, blobID)
+	}
+
+	// acquire shared lock on a blob, PrefetchBlob will acquire exclusive lock here.
+	c.pc.sharedLock(string(blobID))
+	defer c.pc.sharedUnlock(string(blobID))
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	// see if the full blob or all the contents are already cached before fetching.
+	if c.pc.GetPartial(ctx, BlobIDCacheKey(blobID), 0, 1, &tmp) || c.allContentsCached(ctx, contents, &tmp) {
+		return nil
+	}
+
+	var rangeData gather.WriteBuffer
+	defer rangeData.Close()
+
+	if err := c.st.GetBlob(ctx, blobID, offset, length, &rangeData); err != nil {
+		c.pc.reportMissError()
+
+		return errors.Wrapf(err, "failed to get blob with ID %s", blobID)
+	}
+
+	c.pc.reportMissBytes(int64(rangeData.Length()))
+
+	for _, cr := range contents {
+		if cr.Offset < offset || cr.Offset+cr.Length > offset+int64(rangeData.Length()) {
+			return errors.Errorf("content %v (offset=%v,length=%v) is outside of fetched range (offset=%v,length=%v) of blob %q", cr.ContentID, cr.Offset, cr.Length, offset, rangeData.Length(), blobID)
+		}
+
+		tmp.Reset()
+
+		impossible.PanicOnError(rangeData.AppendSectionTo(&tmp, int(cr.Offset-offset), int(cr.Length)))
+
+		c.pc.exclusiveLock(cr.ContentID)
+		c.pc.Put(ctx, ContentIDCacheKey(cr.ContentID), tmp.Bytes())
+		c.pc.exclusiveUnlock(cr.ContentID)
+	}
+
+	return nil
+}
+
+// GetContentRange returns data of the provided contents of a single blob, which is written to the corresponding outputs.
+// Contents that are not cached are fetched with a single ranged read spanning all of them and added to the cache.
+func (c *contentCacheImpl) GetContentRange(ctx context.Context, blobID blob.ID, contents []ContentRange, outputs []*gather.WriteBuffer) error {
+	if c.fetchFullBlobs {
+		for i, cr := range contents {
+			if err := c.getContentFromFullBlob(ctx, blobID, cr.Offset, cr.Length, outputs[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// acquire shared lock on a blob, PrefetchBlob will acquire exclusive lock here.
+	c.pc.sharedLock(string(blobID))
+	defer c.pc.sharedUnlock(string(blobID))
+
+	var (
+		missing        []ContentRange
+		missingOutputs []*gather.WriteBuffer
+	)
+
+	for i, cr := range contents {
+		outputs[i].Reset()
+
+		if c.pc.GetPartial(ctx, BlobIDCacheKey(blobID), cr.Offset, cr.Length, outputs[i]) {
+			continue
+		}
+
+		outputs[i].Reset()
+
+		if c.pc.GetFull(ctx, ContentIDCacheKey(cr.ContentID), outputs[i]) {
+			continue
+		}
+
+		outputs[i].Reset()
+
+		missing = append(missing, cr)
+		missingOutputs = append(missingOutputs, outputs[i])
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	offset, length := contentRangeSpan(missing)
+
+	var rangeData gather.WriteBuffer
+	defer rangeData.Close()
+
+	if err := c.st.GetBlob(ctx, blobID, offset, length, &rangeData); err != nil {
+		c.pc.reportMissError()
+
+		return errors.Wrapf(err, "failed to get blob with ID %s", blobID)
+	}
+
+	c.pc.reportMissBytes(int64(rangeData.Length()))
+
+	for i, cr := range missing {
+		out := missingOutputs[i]
+
+		if err := extractContentFromRange(blobID, rangeData.Bytes(), offset, cr, out); err != nil {
+			return err
+		}
+
+		c.pc.exclusiveLock(cr.ContentID)
+		c.pc.Put(ctx, ContentIDCacheKey(cr.ContentID), out.Bytes())
+		c.pc.exclusiveUnlock(cr.ContentID)
+	}
+
+	return nil
+}
+
+// contentRangeSpan returns the smallest range of a blob that includes all provided contents.
+func contentRangeSpan(contents []ContentRange) (offset, length int64) {
+	offset, end := contents[0].Offset, contents[0].Offset+contents[0].Length
+
+	for _, cr := range contents[1:] {
+		offset = min(offset, cr.Offset)
+		end = max(end, cr.Offset+cr.Length)
+	}
+
+	return offset, end - offset
+}
+
+// extractContentFromRange writes the data of the provided content from range of a blob starting at the provided offset.
+func extractContentFromRange(blobID blob.ID, rangeData gather.Bytes, offset int64, cr ContentRange, output *gather.WriteBuffer) error {
+	if cr.Offset < offset || cr.Offset+cr.Length > offset+int64(rangeData.Length()) {
+		return errors.Errorf("content %v (offset=%v,length=%v) is outside of fetched range (offset=%v,length=%v) of blob %q", cr.ContentID, cr.Offset, cr.Length, offset, rangeData.Length(), blobID)
+	}
+
+	output.Reset()
+
+	impossible.PanicOnError(rangeData.AppendSectionTo(output, int(cr.Offset-offset), int(cr.Length)))
+
+	return nil
+}
+
+func (c *contentCacheImpl) allContentsCached(ctx context.Context, contents []ContentRange, tmp *gather.WriteBuffer) bool {
+	for _, cr := range contents {
+		if !c.pc.GetPartial(ctx, ContentIDCacheKey(cr.ContentID), 0, 1, tmp) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (c *contentCacheImpl) CacheStorage() Storage {
 	return c.pc.cacheStorage
 }
 
+func (c *contentCacheImpl) Stats() Stats {
+	return c.pc.Stats()
+}
+
 // NewContentCache creates new content cache for data contents.
 func NewContentCache(ctx context.Context, st blob.Storage, opt Options, mr *metrics.Registry) (ContentCache, error) {
 	cacheStorage := opt.Storage
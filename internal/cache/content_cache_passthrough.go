@@ -3,6 +3,8 @@
 import (
 	"context"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/blob"
 )
@@ -27,6 +29,34 @@ func (c passthroughContentCache) PrefetchBlob(ctx context.Context, blobID blob.I
 	return nil
 }
 
+func (c passthroughContentCache) PrefetchContentRange(ctx context.Context, blobID blob.ID, offset, length int64, contents []ContentRange) error {
+	_ = blobID
+	_ = offset
+	_ = length
+	_ = contents
+
+	return nil
+}
+
+func (c passthroughContentCache) GetContentRange(ctx context.Context, blobID blob.ID, contents []ContentRange, outputs []*gather.WriteBuffer) error {
+	offset, length := contentRangeSpan(contents)
+
+	var rangeData gather.WriteBuffer
+	defer rangeData.Close()
+
+	if err := c.st.GetBlob(ctx, blobID, offset, length, &rangeData); err != nil {
+		return errors.Wrapf(err, "failed to get blob with ID %s", blobID)
+	}
+
+	for i, cr := range contents {
+		if err := extractContentFromRange(blobID, rangeData.Bytes(), offset, cr, outputs[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (c passthroughContentCache) Sync(ctx context.Context, blobPrefix blob.ID) error {
 	_ = blobPrefix
 
@@ -36,3 +66,7 @@ func (c passthroughContentCache) Sync(ctx context.Context, blobPrefix blob.ID) e
 func (c passthroughContentCache) CacheStorage() Storage {
 	return nil
 }
+
+func (c passthroughContentCache) Stats() Stats {
+	return Stats{}
+}
@@ -173,6 +173,117 @@ func TestDiskContentCache(t *testing.T) {
 	verifyContentCache(t, cc, cacheStorage)
 }
 
+func TestDiskContentCache_PrefetchContentRange(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tmpDir := testutil.TempDirectory(t)
+
+	const maxBytes = 10000
+
+	cacheStorage, err := cache.NewStorageOrNil(ctx, tmpDir, maxBytes, "contents")
+	require.NoError(t, err)
+
+	st := newUnderlyingStorageForContentCacheTesting(t)
+
+	cc, err := cache.NewContentCache(ctx, st, cache.Options{
+		Storage: cacheStorage,
+		Sweep: cache.SweepSettings{
+			MaxSizeBytes: maxBytes,
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	defer cc.Close(ctx)
+
+	// content outside of the range is rejected.
+	require.Error(t, cc.PrefetchContentRange(ctx, "content-1", 2, 4, []cache.ContentRange{
+		{ContentID: "xf0f0f1", Offset: 5, Length: 3},
+	}))
+
+	require.NoError(t, cc.PrefetchContentRange(ctx, "content-1", 1, 8, []cache.ContentRange{
+		{ContentID: "xf0f0f1", Offset: 1, Length: 2},
+		{ContentID: "xf0f0f2", Offset: 5, Length: 4},
+	}))
+
+	verifyStorageContentList(t, cacheStorage, "f0f0f1x", "f0f0f2x")
+
+	// contents are now served from the cache without accessing the underlying storage.
+	require.NoError(t, st.DeleteBlob(ctx, "content-1"))
+
+	var v gather.WriteBuffer
+	defer v.Close()
+
+	require.NoError(t, cc.GetContent(ctx, "xf0f0f1", "content-1", 1, 2, &v))
+	require.Equal(t, []byte{2, 3}, v.ToByteSlice())
+
+	require.NoError(t, cc.GetContent(ctx, "xf0f0f2", "content-1", 5, 4, &v))
+	require.Equal(t, []byte{6, 7, 8, 9}, v.ToByteSlice())
+
+	// already cached contents are not fetched again.
+	require.NoError(t, cc.PrefetchContentRange(ctx, "content-1", 1, 8, []cache.ContentRange{
+		{ContentID: "xf0f0f1", Offset: 1, Length: 2},
+		{ContentID: "xf0f0f2", Offset: 5, Length: 4},
+	}))
+}
+
+func TestDiskContentCache_GetContentRange(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tmpDir := testutil.TempDirectory(t)
+
+	const maxBytes = 10000
+
+	cacheStorage, err := cache.NewStorageOrNil(ctx, tmpDir, maxBytes, "contents")
+	require.NoError(t, err)
+
+	st := newUnderlyingStorageForContentCacheTesting(t)
+
+	cc, err := cache.NewContentCache(ctx, st, cache.Options{
+		Storage: cacheStorage,
+		Sweep: cache.SweepSettings{
+			MaxSizeBytes: maxBytes,
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	defer cc.Close(ctx)
+
+	verifyGetContentRange(ctx, t, cc)
+	verifyStorageContentList(t, cacheStorage, "f0f0f1x", "f0f0f2x")
+
+	// contents are now served from the cache without accessing the underlying storage.
+	require.NoError(t, st.DeleteBlob(ctx, "content-1"))
+	verifyGetContentRange(ctx, t, cc)
+}
+
+func TestPassthroughContentCache_GetContentRange(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	cc, err := cache.NewContentCache(ctx, newUnderlyingStorageForContentCacheTesting(t), cache.Options{}, nil)
+	require.NoError(t, err)
+
+	defer cc.Close(ctx)
+
+	verifyGetContentRange(ctx, t, cc)
+}
+
+func verifyGetContentRange(ctx context.Context, t *testing.T, cc cache.ContentCache) {
+	t.Helper()
+
+	var v1, v2 gather.WriteBuffer
+
+	defer v1.Close()
+	defer v2.Close()
+
+	require.NoError(t, cc.GetContentRange(ctx, "content-1", []cache.ContentRange{
+		{ContentID: "xf0f0f2", Offset: 5, Length: 4},
+		{ContentID: "xf0f0f1", Offset: 1, Length: 2},
+	}, []*gather.WriteBuffer{&v1, &v2}))
+
+	require.Equal(t, []byte{6, 7, 8, 9}, v1.ToByteSlice())
+	require.Equal(t, []byte{2, 3}, v2.ToByteSlice())
+}
+
 func verifyContentCache(t *testing.T, cc cache.ContentCache, cacheStorage blob.Storage) {
 	t.Helper()
 
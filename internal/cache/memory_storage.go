@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type memoryStorageEntry struct {
+	data      []byte
+	touchTime time.Time
+}
+
+// memoryStorage is a cache storage which keeps blobs in memory and evicts least recently
+// touched blobs when the total size would exceed the limit.
+type memoryStorage struct {
+	blob.DefaultProviderImplementation
+
+	maxBytes int64
+	timeNow  func() time.Time
+
+	mu sync.Mutex
+	// +checklocks:mu
+	entries map[blob.ID]*memoryStorageEntry
+	// +checklocks:mu
+	totalBytes int64
+}
+
+func (s *memoryStorage) GetCapacity(ctx context.Context) (blob.Capacity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return blob.Capacity{
+		SizeB: uint64(s.maxBytes),                //nolint:gosec
+		FreeB: uint64(s.maxBytes - s.totalBytes), //nolint:gosec
+	}, nil
+}
+
+func (s *memoryStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	output.Reset()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return blob.ErrBlobNotFound
+	}
+
+	data := e.data
+
+	if length >= 0 {
+		if offset < 0 || offset > int64(len(data)) || offset+length > int64(len(data)) {
+			return errors.Wrapf(blob.ErrInvalidRange, "invalid range %v+%v", offset, length)
+		}
+
+		data = data[offset : offset+length]
+	}
+
+	if _, err := output.Write(data); err != nil {
+		return errors.Wrap(err, "error writing data to output")
+	}
+
+	return nil
+}
+
+func (s *memoryStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return blob.Metadata{}, blob.ErrBlobNotFound
+	}
+
+	return blob.Metadata{BlobID: id, Length: int64(len(e.data)), Timestamp: e.touchTime}, nil
+}
+
+func (s *memoryStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	if opts.HasRetentionOptions() || opts.DoNotRecreate {
+		return errors.Wrap(blob.ErrUnsupportedPutBlobOption, "memory cache")
+	}
+
+	var b bytes.Buffer
+
+	data.WriteTo(&b) //nolint:errcheck
+
+	if int64(b.Len()) > s.maxBytes {
+		// too large to cache, pretend it was stored and evicted immediately.
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(id)
+	s.evictLocked(s.maxBytes - int64(b.Len()))
+
+	e := &memoryStorageEntry{data: b.Bytes(), touchTime: s.timeNow()}
+	if !opts.SetModTime.IsZero() {
+		e.touchTime = opts.SetModTime
+	}
+
+	s.entries[id] = e
+	s.totalBytes += int64(len(e.data))
+
+	if opts.GetModTime != nil {
+		*opts.GetModTime = e.touchTime
+	}
+
+	return nil
+}
+
+// evictLocked removes least recently touched entries until the total size does not exceed maxBytes.
+//
+// +checklocks:s.mu
+func (s *memoryStorage) evictLocked(maxBytes int64) {
+	if s.totalBytes <= maxBytes {
+		return
+	}
+
+	ids := make([]blob.ID, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return s.entries[ids[i]].touchTime.Before(s.entries[ids[j]].touchTime)
+	})
+
+	for _, id := range ids {
+		if s.totalBytes <= maxBytes {
+			return
+		}
+
+		s.removeLocked(id)
+	}
+}
+
+// +checklocks:s.mu
+func (s *memoryStorage) removeLocked(id blob.ID) {
+	if e, ok := s.entries[id]; ok {
+		s.totalBytes -= int64(len(e.data))
+		delete(s.entries, id)
+	}
+}
+
+func (s *memoryStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(id)
+
+	return nil
+}
+
+func (s *memoryStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	s.mu.Lock()
+
+	var result []blob.Metadata
+
+	for id, e := range s.entries {
+		if strings.HasPrefix(string(id), string(prefix)) {
+			result = append(result, blob.Metadata{BlobID: id, Length: int64(len(e.data)), Timestamp: e.touchTime})
+		}
+	}
+
+	s.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlobID < result[j].BlobID
+	})
+
+	for _, bm := range result {
+		if err := callback(bm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStorage) TouchBlob(ctx context.Context, id blob.ID, threshold time.Duration) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return time.Time{}, blob.ErrBlobNotFound
+	}
+
+	if n := s.timeNow(); n.Sub(e.touchTime) >= threshold {
+		e.touchTime = n
+	}
+
+	return e.touchTime, nil
+}
+
+func (s *memoryStorage) ConnectionInfo() blob.ConnectionInfo {
+	return blob.ConnectionInfo{}
+}
+
+func (s *memoryStorage) DisplayName() string {
+	return "Memory Cache"
+}
+
+// NewMemoryStorage returns cache.Storage which keeps up to maxBytes of blobs in memory, evicting
+// least recently touched blobs to make room for new ones. It is used when the cache directory is not usable.
+func NewMemoryStorage(maxBytes int64, timeNow func() time.Time) Storage {
+	if timeNow == nil {
+		timeNow = clock.Now
+	}
+
+	return &memoryStorage{
+		maxBytes: maxBytes,
+		timeNow:  timeNow,
+		entries:  map[blob.ID]*memoryStorageEntry{},
+	}
+}
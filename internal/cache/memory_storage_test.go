@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+	ft := faketime.NewClockTimeWithOffset(0)
+
+	st := NewMemoryStorage(100, ft.NowFunc())
+
+	put := func(id blob.ID, n int) {
+		t.Helper()
+		require.NoError(t, st.PutBlob(ctx, id, gather.FromSlice(make([]byte, n)), blob.PutOptions{}))
+		ft.Advance(time.Second)
+	}
+
+	put("a", 40)
+	put("b", 40)
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	require.NoError(t, st.GetBlob(ctx, "a", 10, 5, &tmp))
+	require.Equal(t, 5, tmp.Length())
+	require.ErrorIs(t, st.GetBlob(ctx, "a", 30, 20, &tmp), blob.ErrInvalidRange)
+
+	// touching makes "a" more recent than "b", which is evicted first.
+	_, err := st.TouchBlob(ctx, "a", 0)
+	require.NoError(t, err)
+
+	put("c", 40)
+
+	require.ErrorIs(t, st.GetBlob(ctx, "b", 0, -1, &tmp), blob.ErrBlobNotFound)
+	require.NoError(t, st.GetBlob(ctx, "a", 0, -1, &tmp))
+	require.NoError(t, st.GetBlob(ctx, "c", 0, -1, &tmp))
+
+	// blobs larger than the limit are not stored.
+	put("d", 101)
+	require.ErrorIs(t, st.GetBlob(ctx, "d", 0, -1, &tmp), blob.ErrBlobNotFound)
+
+	mds, err := blob.ListAllBlobs(ctx, st, "")
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{"a", "c"}, blob.IDsFromMetadata(mds))
+	require.LessOrEqual(t, blob.TotalLength(mds), int64(100))
+
+	require.NoError(t, st.DeleteBlob(ctx, "a"))
+
+	c, err := st.GetCapacity(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 60, c.FreeB)
+}
@@ -160,7 +160,7 @@ func (c *PersistentCache) GetPartial(ctx context.Context, key string, offset, le
 		l = 0
 	}
 
-	c.reportMissBytes(l)
+	c.reportLookupMiss(l)
 
 	return false
 }
@@ -171,11 +171,15 @@ func (c *PersistentCache) Put(ctx context.Context, key string, data gather.Bytes
 		return
 	}
 
+	// make sure the cache has enough room for the new item including any protection overhead.
+	l := data.Length() + c.storageProtection.OverheadBytes()
+
+	// evict items from lower-priority caches sharing the budget, if any.
+	c.sweep.Budget.makeRoom(ctx, c, int64(l))
+
 	c.listCacheMutex.Lock()
 	defer c.listCacheMutex.Unlock()
 
-	// make sure the cache has enough room for the new item including any protection overhead.
-	l := data.Length() + c.storageProtection.OverheadBytes()
 	c.pendingWriteBytes += int64(l)
 	c.sweepLocked(ctx)
 
@@ -216,9 +220,69 @@ func (c *PersistentCache) Close(ctx context.Context) {
 		return
 	}
 
+	if c.sweep.Budget != nil {
+		c.sweep.Budget.unregister(c)
+	}
+
 	releasable.Released("persistent-cache", c)
 }
 
+// Stats describes the usage and effectiveness of a cache.
+type Stats struct {
+	Description  string   `json:"description"`
+	Priority     Priority `json:"priority"`
+	SizeBytes    int64    `json:"sizeBytes"`
+	Count        int      `json:"count"`
+	HitCount     int64    `json:"hitCount"`
+	HitBytes     int64    `json:"hitBytes"`
+	MissCount    int64    `json:"missCount"`
+	MissBytes    int64    `json:"missBytes"`
+	EvictedCount int64    `json:"evictedCount"`
+}
+
+// HitRatio returns the fraction of cache lookups that were hits.
+func (s Stats) HitRatio() float64 {
+	if s.HitCount+s.MissCount == 0 {
+		return 0
+	}
+
+	return float64(s.HitCount) / float64(s.HitCount+s.MissCount)
+}
+
+// Add returns the sum of cumulative counters of two stats.
+func (s Stats) Add(other Stats) Stats {
+	s.HitCount += other.HitCount
+	s.HitBytes += other.HitBytes
+	s.MissCount += other.MissCount
+	s.MissBytes += other.MissBytes
+	s.EvictedCount += other.EvictedCount
+
+	return s
+}
+
+// Stats returns the current usage and cumulative statistics of the cache since it was opened.
+func (c *PersistentCache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.listCacheMutex.Lock()
+	size, count := c.listCache.totalDataBytes, len(c.listCache.data)
+	c.listCacheMutex.Unlock()
+
+	return Stats{
+		Description:  c.description,
+		Priority:     c.sweep.Priority,
+		SizeBytes:    size,
+		Count:        count,
+		HitCount:     c.counters.hitCount.Load(),
+		HitBytes:     c.counters.hitBytes.Load(),
+		MissCount:    c.counters.missCount.Load(),
+		MissBytes:    c.counters.missBytes.Load(),
+		EvictedCount: c.counters.evictedCount.Load(),
+	}
+}
+
 // A contentMetadataHeap implements heap.Interface and holds blob.Metadata.
 //
 //nolint:recvcheck
@@ -311,6 +375,8 @@ func (c *PersistentCache) sweepLocked(ctx context.Context) {
 
 		heap.Pop(&c.listCache)
 
+		c.reportEviction()
+
 		if delerr := c.cacheStorage.DeleteBlob(ctx, oldest.BlobID); delerr != nil {
 			log(ctx).Warnw("unable to remove cache item", "cache", c.description, "item", oldest.BlobID, "err", delerr)
 
@@ -422,6 +488,12 @@ type SweepSettings struct {
 
 	// on each use, items will be touched if they have not been touched in this long.
 	TouchThreshold time.Duration
+
+	// optional size limit shared with other caches.
+	Budget *Budget
+
+	// priority of items in this cache relative to other caches sharing the same Budget.
+	Priority Priority
 }
 
 func (s SweepSettings) applyDefaults() SweepSettings {
@@ -470,5 +542,10 @@ func NewPersistentCache(ctx context.Context, description string, cacheStorage St
 		return nil, errors.Wrapf(err, "error during initial scan of %s", c.description)
 	}
 
+	if sweep.Budget != nil {
+		sweep.Budget.register(c)
+		sweep.Budget.makeRoom(ctx, c, 0)
+	}
+
 	return c, nil
 }
@@ -36,3 +36,43 @@ func TestDeriveKeyFromMasterKey(t *testing.T) {
 		})
 	})
 }
+
+func TestDeriveKeyFromPasswordArgon2id(t *testing.T) {
+	key1, err := crypto.DeriveKeyFromPassword("password", TestSalt, 32, crypto.Argon2idAlgorithm)
+	require.NoError(t, err)
+	require.Len(t, key1, 32)
+
+	key2, err := crypto.DeriveKeyFromPassword("password", TestSalt, 32, crypto.Argon2idAlgorithm)
+	require.NoError(t, err)
+	require.Equal(t, key1, key2)
+
+	key3, err := crypto.DeriveKeyFromPassword("other-password", TestSalt, 32, crypto.Argon2idAlgorithm)
+	require.NoError(t, err)
+	require.NotEqual(t, key1, key3)
+
+	_, err = crypto.DeriveKeyFromPassword("password", []byte("short"), 32, crypto.Argon2idAlgorithm)
+	require.Error(t, err)
+}
+
+func TestDeriveKeyFromPasswordKeyFile(t *testing.T) {
+	const key = "0123456789abcdef0123456789abcdef"
+
+	key1, err := crypto.DeriveKeyFromPassword(key, TestSalt, 32, crypto.KeyFileAlgorithm)
+	require.NoError(t, err)
+	require.Len(t, key1, 32)
+
+	key2, err := crypto.DeriveKeyFromPassword(key, TestSalt, 32, crypto.KeyFileAlgorithm)
+	require.NoError(t, err)
+	require.Equal(t, key1, key2)
+
+	key3, err := crypto.DeriveKeyFromPassword(key+"x", TestSalt, 32, crypto.KeyFileAlgorithm)
+	require.NoError(t, err)
+	require.NotEqual(t, key1, key3)
+
+	// human-chosen passwords are too short to be used as key material.
+	_, err = crypto.DeriveKeyFromPassword("password", TestSalt, 32, crypto.KeyFileAlgorithm)
+	require.Error(t, err)
+
+	_, err = crypto.DeriveKeyFromPassword(key, []byte("short"), 32, crypto.KeyFileAlgorithm)
+	require.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package crypto
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// Argon2idAlgorithm is the registration name for the argon2id algorithm instance
+	// using 3 iterations, 64 MiB of memory and 4 threads.
+	Argon2idAlgorithm = "argon2id-3-65536-4"
+
+	// The recommended minimum size for a salt to be used for argon2id.
+	// See: https://www.rfc-editor.org/rfc/rfc9106.html#section-3.1
+	argon2idMinSaltLength = 16 // 128 bits
+)
+
+func init() {
+	registerPBKeyDeriver(Argon2idAlgorithm, &argon2idKeyDeriver{
+		time:          3,         //nolint:mnd
+		memoryKiB:     64 * 1024, //nolint:mnd
+		threads:       4,         //nolint:mnd
+		minSaltLength: argon2idMinSaltLength,
+	})
+}
+
+type argon2idKeyDeriver struct {
+	// time is the number of passes over the memory.
+	time uint32
+	// memoryKiB is the size of the memory in KiB.
+	memoryKiB uint32
+	// threads is the degree of parallelism.
+	threads uint8
+
+	minSaltLength int
+}
+
+func (s *argon2idKeyDeriver) deriveKeyFromPassword(password string, salt []byte, keySize int) ([]byte, error) {
+	if len(salt) < s.minSaltLength {
+		return nil, errors.Errorf("required salt size is at least %d bytes", s.minSaltLength)
+	}
+
+	return argon2.IDKey([]byte(password), salt, s.time, s.memoryKiB, s.threads, uint32(keySize)), nil //nolint:gosec
+}
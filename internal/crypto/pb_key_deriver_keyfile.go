@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// KeyFileAlgorithm is the registration name for the key derivation used by password-less repositories,
+	// where the "password" is a random key stored in a local key file. Since the key has full entropy,
+	// it is expanded using HKDF-SHA256 without any stretching.
+	KeyFileAlgorithm = "hkdf-sha256-keyfile"
+
+	// keyFileMinKeyLength is the minimum length of key material accepted by KeyFileAlgorithm,
+	// which prevents it from being used with human-chosen passwords.
+	keyFileMinKeyLength = 32
+
+	keyFileMinSaltLength = 16 // 128 bits
+)
+
+func init() {
+	registerPBKeyDeriver(KeyFileAlgorithm, keyFileKeyDeriver{})
+}
+
+type keyFileKeyDeriver struct{}
+
+func (keyFileKeyDeriver) deriveKeyFromPassword(key string, salt []byte, keySize int) ([]byte, error) {
+	if len(key) < keyFileMinKeyLength {
+		return nil, errors.Errorf("key file must contain at least %d bytes of key material", keyFileMinKeyLength)
+	}
+
+	if len(salt) < keyFileMinSaltLength {
+		return nil, errors.Errorf("required salt size is at least %d bytes", keyFileMinSaltLength)
+	}
+
+	result := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(key), salt, []byte("kopia-keyfile")), result); err != nil {
+		return nil, errors.Wrap(err, "unable to derive key")
+	}
+
+	return result, nil
+}
@@ -9,6 +9,10 @@
 	// ScryptAlgorithm is the registration name for the scrypt algorithm instance.
 	ScryptAlgorithm = "scrypt-65536-8-1"
 
+	// ScryptStrongAlgorithm is the registration name for the scrypt algorithm instance
+	// with 4x higher CPU/memory cost than ScryptAlgorithm.
+	ScryptStrongAlgorithm = "scrypt-262144-8-1"
+
 	// The recommended minimum size for a salt to be used for scrypt.
 	// Currently set to 16 bytes (128 bits).
 	//
@@ -27,6 +31,13 @@ func init() {
 		p:             1,
 		minSaltLength: scryptMinSaltLength,
 	})
+
+	registerPBKeyDeriver(ScryptStrongAlgorithm, &scryptKeyDeriver{
+		n:             262144, //nolint:mnd
+		r:             8,      //nolint:mnd
+		p:             1,
+		minSaltLength: scryptMinSaltLength,
+	})
 }
 
 type scryptKeyDeriver struct {
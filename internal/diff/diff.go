@@ -2,7 +2,9 @@
 package diff
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -21,6 +23,16 @@
 
 var log = logging.Module("diff")
 
+// Stats summarizes differences found by the comparer.
+type Stats struct {
+	AddedFiles         int `json:"addedFiles"`
+	RemovedFiles       int `json:"removedFiles"`
+	ChangedFiles       int `json:"changedFiles"`
+	UnchangedFiles     int `json:"unchangedFiles"`
+	AddedDirectories   int `json:"addedDirectories"`
+	RemovedDirectories int `json:"removedDirectories"`
+}
+
 // Comparer outputs diff information between two filesystems.
 type Comparer struct {
 	out    io.Writer
@@ -28,6 +40,12 @@ type Comparer struct {
 
 	DiffCommand   string
 	DiffArguments []string
+
+	// CompareContents causes contents of files without object IDs (such as files in the local filesystem)
+	// to be compared by hash, otherwise such files are compared by metadata only.
+	CompareContents bool
+
+	Stats Stats
 }
 
 // Compare compares two filesystem entries and emits their diff information.
@@ -88,10 +106,13 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 	if e1 == nil {
 		if dir2, isDir2 := e2.(fs.Directory); isDir2 {
 			c.output("added directory %v\n", path)
+			c.Stats.AddedDirectories++
+
 			return c.compareDirectories(ctx, nil, dir2, path)
 		}
 
 		c.output("added file %v (%v bytes)\n", path, e2.Size())
+		c.Stats.AddedFiles++
 
 		if f, ok := e2.(fs.File); ok {
 			if err := c.compareFiles(ctx, nil, f, path); err != nil {
@@ -105,10 +126,13 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 	if e2 == nil {
 		if dir1, isDir1 := e1.(fs.Directory); isDir1 {
 			c.output("removed directory %v\n", path)
+			c.Stats.RemovedDirectories++
+
 			return c.compareDirectories(ctx, dir1, nil, path)
 		}
 
 		c.output("removed file %v (%v bytes)\n", path, e1.Size())
+		c.Stats.RemovedFiles++
 
 		if f, ok := e1.(fs.File); ok {
 			if err := c.compareFiles(ctx, f, nil, path); err != nil {
@@ -119,7 +143,7 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 		return nil
 	}
 
-	compareEntry(e1, e2, path, c.out)
+	metadataEqual := compareEntry(e1, e2, path, c.out)
 
 	dir1, isDir1 := e1.(fs.Directory)
 	dir2, isDir2 := e2.(fs.Directory)
@@ -142,7 +166,21 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 
 	if f1, ok := e1.(fs.File); ok {
 		if f2, ok := e2.(fs.File); ok {
+			if !hasObjectIDs(e1, e2) {
+				// without object IDs, files are only known to differ if their metadata or contents differ.
+				unchanged, err := c.sameFileWithoutObjectIDs(ctx, f1, f2, path, metadataEqual)
+				if err != nil {
+					return err
+				}
+
+				if unchanged {
+					c.Stats.UnchangedFiles++
+					return nil
+				}
+			}
+
 			c.output("changed %v at %v (size %v -> %v)\n", path, e2.ModTime().String(), e1.Size(), e2.Size())
+			c.Stats.ChangedFiles++
 
 			if err := c.compareFiles(ctx, f1, f2, path); err != nil {
 				return err
@@ -153,6 +191,57 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 	return nil
 }
 
+func hasObjectIDs(e1, e2 fs.Entry) bool {
+	_, ok1 := e1.(object.HasObjectID)
+	_, ok2 := e2.(object.HasObjectID)
+
+	return ok1 && ok2
+}
+
+// sameFileWithoutObjectIDs determines whether two files are the same based on metadata and, optionally, contents.
+func (c *Comparer) sameFileWithoutObjectIDs(ctx context.Context, f1, f2 fs.File, path string, metadataEqual bool) (bool, error) {
+	if !c.CompareContents {
+		return metadataEqual, nil
+	}
+
+	if f1.Size() != f2.Size() {
+		return false, nil
+	}
+
+	h1, err := hashFile(ctx, f1)
+	if err != nil {
+		return false, errors.Wrapf(err, "error hashing %v", path)
+	}
+
+	h2, err := hashFile(ctx, f2)
+	if err != nil {
+		return false, errors.Wrapf(err, "error hashing %v", path)
+	}
+
+	if !bytes.Equal(h1, h2) {
+		c.output("%v contents differ\n", path)
+		return false, nil
+	}
+
+	return metadataEqual, nil
+}
+
+func hashFile(ctx context.Context, f fs.File) ([]byte, error) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening file")
+	}
+	defer r.Close() //nolint:errcheck
+
+	h := sha256.New()
+
+	if err := iocopy.JustCopy(h, r); err != nil {
+		return nil, errors.Wrap(err, "error reading file")
+	}
+
+	return h.Sum(nil), nil
+}
+
 func compareEntry(e1, e2 fs.Entry, fullpath string, out io.Writer) bool {
 	if e1 == e2 { // in particular e1 == nil && e2 == nil
 		return true
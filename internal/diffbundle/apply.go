@@ -0,0 +1,281 @@
+package diffbundle
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const dirMode = 0o700
+
+type directoryTimes struct {
+	path    string
+	modTime time.Time
+}
+
+type applier struct {
+	targetDir string
+	in        *bufio.Reader
+	stats     Stats
+
+	// directory modification times are restored after all changes, because changes of
+	// directory contents update them.
+	dirTimes []directoryTimes
+}
+
+// ReadHeader reads the header of the bundle.
+func ReadHeader(input io.Reader) (*Header, error) {
+	gz, err := gzip.NewReader(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a valid bundle")
+	}
+
+	defer gz.Close() //nolint:errcheck
+
+	return readHeader(bufio.NewReader(gz))
+}
+
+func readHeader(r *bufio.Reader) (*Header, error) {
+	h := &Header{}
+	if err := readRecord(r, h); err != nil {
+		return nil, errors.Wrap(err, "unable to read bundle header")
+	}
+
+	if h.Magic != bundleMagic {
+		return nil, errors.New("not a valid bundle")
+	}
+
+	if h.Version != bundleFormatVersion {
+		return nil, errors.Errorf("unsupported bundle version %v", h.Version)
+	}
+
+	return h, nil
+}
+
+// Apply applies changes from the bundle to the target directory, which must contain the base snapshot.
+//
+// Each changed file is written to a temporary file and verified before replacing the original,
+// so an interrupted or failed apply leaves the directory with a mix of old and new files,
+// but never with partially written ones.
+func Apply(ctx context.Context, input io.Reader, targetDir string) (*Header, *Stats, error) {
+	gz, err := gzip.NewReader(input)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "not a valid bundle")
+	}
+
+	defer gz.Close() //nolint:errcheck
+
+	a := &applier{
+		targetDir: targetDir,
+		in:        bufio.NewReader(gz),
+	}
+
+	h, err := readHeader(a.in)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if st, err := os.Stat(targetDir); err != nil || !st.IsDir() {
+		return nil, nil, errors.Errorf("target directory %v does not exist", targetDir)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, errors.Wrap(err, "canceled")
+		}
+
+		en := &Entry{}
+		if err := readRecord(a.in, en); err != nil {
+			return nil, nil, err
+		}
+
+		if en.Op == OpEnd {
+			break
+		}
+
+		if err := validatePath(en.Path); err != nil {
+			return nil, nil, err
+		}
+
+		if err := a.apply(en); err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to apply %v of %v", en.Op, en.Path)
+		}
+	}
+
+	for i := len(a.dirTimes) - 1; i >= 0; i-- {
+		dt := a.dirTimes[i]
+
+		if err := os.Chtimes(dt.path, dt.modTime, dt.modTime); err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to set modification time of %v", dt.path)
+		}
+	}
+
+	return h, &a.stats, nil
+}
+
+func (a *applier) apply(en *Entry) error {
+	localPath := filepath.Join(a.targetDir, filepath.FromSlash(en.Path))
+
+	switch en.Op {
+	case OpDelete:
+		a.stats.DeletedEntries++
+
+		return errors.Wrap(os.RemoveAll(localPath), "unable to delete")
+
+	case OpDirectory:
+		a.stats.OtherChanges++
+
+		if err := os.MkdirAll(localPath, dirMode); err != nil {
+			return errors.Wrap(err, "unable to create directory")
+		}
+
+		a.dirTimes = append(a.dirTimes, directoryTimes{localPath, en.ModTime})
+
+		return errors.Wrap(os.Chmod(localPath, en.Mode), "unable to set mode")
+
+	case OpSymlink:
+		a.stats.OtherChanges++
+
+		if err := os.RemoveAll(localPath); err != nil {
+			return errors.Wrap(err, "unable to replace symbolic link")
+		}
+
+		return errors.Wrap(os.Symlink(en.LinkTarget, localPath), "unable to create symbolic link")
+
+	case OpAttributes:
+		a.stats.OtherChanges++
+
+		return setFileAttributes(localPath, en)
+
+	case OpFile:
+		return a.applyFile(localPath, en)
+
+	default:
+		return errors.Errorf("unsupported operation %q", en.Op)
+	}
+}
+
+func setFileAttributes(localPath string, en *Entry) error {
+	if err := os.Chmod(localPath, en.Mode); err != nil {
+		return errors.Wrap(err, "unable to set mode")
+	}
+
+	return errors.Wrap(os.Chtimes(localPath, en.ModTime, en.ModTime), "unable to set modification time")
+}
+
+func (a *applier) applyFile(localPath string, en *Entry) error {
+	_, statErr := os.Lstat(localPath)
+	existed := statErr == nil
+
+	var base *os.File
+
+	if slices.ContainsFunc(en.Segments, func(s Segment) bool { return !s.Literal }) {
+		f, err := os.Open(localPath) //nolint:gosec
+		if err != nil {
+			return errors.Wrap(err, "unable to open the previous version of the file")
+		}
+
+		defer f.Close() //nolint:errcheck
+
+		base = f
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), dirMode); err != nil {
+		return errors.Wrap(err, "unable to create parent directory")
+	}
+
+	// the new version is written next to the file and only replaces it once verified.
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), ".kopia-diff-*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	hasher := sha256.New()
+	err = a.writeSegments(io.MultiWriter(tmp, hasher), base, en)
+
+	if cerr := tmp.Close(); err == nil {
+		err = errors.Wrap(cerr, "unable to close temporary file")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var checksum [sha256.Size]byte
+	if _, err := io.ReadFull(a.in, checksum[:]); err != nil {
+		return errors.Wrap(err, "unable to read checksum, the bundle may be truncated")
+	}
+
+	if !bytes.Equal(checksum[:], hasher.Sum(nil)) {
+		return errors.New("checksum mismatch, the directory does not contain the base snapshot")
+	}
+
+	if err := setFileAttributes(tmp.Name(), en); err != nil {
+		return err
+	}
+
+	if base != nil {
+		// Windows does not allow replacing open files.
+		base.Close() //nolint:errcheck
+	}
+
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		return errors.Wrap(err, "unable to replace file")
+	}
+
+	if existed {
+		a.stats.ChangedFiles++
+	} else {
+		a.stats.AddedFiles++
+	}
+
+	return nil
+}
+
+// writeSegments writes the new contents of the file, copying segments from the previous version
+// or from the bundle.
+func (a *applier) writeSegments(w io.Writer, base *os.File, en *Entry) error {
+	var written int64
+
+	for _, s := range en.Segments {
+		var r io.Reader = a.in
+
+		if !s.Literal {
+			r = io.NewSectionReader(base, s.BaseOffset, s.Length)
+		} else {
+			a.stats.LiteralBytes += s.Length
+		}
+
+		n, err := io.CopyN(w, r, s.Length)
+		written += n
+
+		if err != nil {
+			if s.Literal {
+				return errors.Wrap(err, "unable to read file data, the bundle may be truncated")
+			}
+
+			return errors.Wrap(err, "the previous version of the file is shorter than expected, the directory does not contain the base snapshot")
+		}
+
+		if !s.Literal {
+			a.stats.ReusedBytes += s.Length
+		}
+	}
+
+	if written != en.Size {
+		return errors.Errorf("invalid bundle, file size %v does not match its segments", en.Size)
+	}
+
+	return nil
+}
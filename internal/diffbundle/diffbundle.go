@@ -0,0 +1,145 @@
+// Package diffbundle implements differential bundles, which carry changes between two snapshots
+// of the same source and can be applied offline to a directory containing the first snapshot.
+//
+// A bundle is a gzip-compressed stream of length-prefixed JSON records. The first record is the
+// Header, followed by an Entry record for each changed filesystem entry and a final entry with
+// the OpEnd operation. File entries are followed by the literal data of their segments and
+// the SHA256 checksum of the resulting file.
+//
+// Contents of changed files are split along the chunks of the repository objects, chunks also present
+// in the previous version of the file are not included in the bundle but copied from the file
+// being updated.
+package diffbundle
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var log = logging.Module("diffbundle")
+
+const (
+	bundleMagic         = "kopia-diff-bundle"
+	bundleFormatVersion = 1
+
+	// maximum length of a single JSON record.
+	maxRecordLength = 64 << 20
+
+	modeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+)
+
+// Operations of bundle entries.
+const (
+	OpDelete     = "delete"
+	OpDirectory  = "dir"
+	OpFile       = "file"
+	OpSymlink    = "symlink"
+	OpAttributes = "attr"
+	OpEnd        = "end"
+)
+
+// SnapshotInfo identifies a snapshot the bundle was created from.
+type SnapshotInfo struct {
+	ID           string              `json:"id"`
+	Source       snapshot.SourceInfo `json:"source"`
+	StartTime    time.Time           `json:"startTime"`
+	RootObjectID object.ID           `json:"rootObjectID"`
+}
+
+// Header describes the bundle.
+type Header struct {
+	Magic     string       `json:"magic"`
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Base      SnapshotInfo `json:"base"`
+	Target    SnapshotInfo `json:"target"`
+}
+
+// Segment is a contiguous range of a file, either copied from the previous version of the file
+// or carried in the bundle.
+type Segment struct {
+	Literal    bool  `json:"literal,omitempty"`
+	BaseOffset int64 `json:"baseOffset,omitempty"`
+	Length     int64 `json:"length"`
+}
+
+// Entry describes a change of a single filesystem entry.
+type Entry struct {
+	Op         string      `json:"op"`
+	Path       string      `json:"path,omitempty"`
+	Mode       os.FileMode `json:"mode,omitempty"`
+	ModTime    time.Time   `json:"mtime"`
+	LinkTarget string      `json:"linkTarget,omitempty"`
+	Size       int64       `json:"size,omitempty"`
+	Segments   []Segment   `json:"segments,omitempty"`
+}
+
+// Stats summarizes the contents of a bundle.
+type Stats struct {
+	AddedFiles     int   `json:"addedFiles"`
+	ChangedFiles   int   `json:"changedFiles"`
+	DeletedEntries int   `json:"deletedEntries"`
+	OtherChanges   int   `json:"otherChanges"`
+	LiteralBytes   int64 `json:"literalBytes"`
+	ReusedBytes    int64 `json:"reusedBytes"`
+}
+
+func writeRecord(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode record")
+	}
+
+	var l [4]byte
+
+	binary.BigEndian.PutUint32(l[:], uint32(len(b))) //nolint:gosec
+
+	if _, err := w.Write(l[:]); err != nil {
+		return errors.Wrap(err, "write error")
+	}
+
+	_, err = w.Write(b)
+
+	return errors.Wrap(err, "write error")
+}
+
+func readRecord(r *bufio.Reader, v any) error {
+	var l [4]byte
+
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return errors.Wrap(err, "unable to read record, the bundle may be truncated")
+	}
+
+	n := binary.BigEndian.Uint32(l[:])
+	if n > maxRecordLength {
+		return errors.Errorf("invalid record length %v", n)
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return errors.Wrap(err, "unable to read record, the bundle may be truncated")
+	}
+
+	return errors.Wrap(json.Unmarshal(b, v), "invalid record")
+}
+
+// validatePath ensures the path in the bundle is relative and stays within the target directory.
+func validatePath(p string) error {
+	if p == "" || p != path.Clean(p) || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return errors.Errorf("invalid path in bundle: %q", p)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package diffbundle_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/diffbundle"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const largeFileSize = 24 << 20
+
+func snapshotDirectory(ctx context.Context, t *testing.T, env *repotesting.Environment, dir string) fs.Directory {
+	t.Helper()
+
+	src, err := localfs.Directory(dir)
+	require.NoError(t, err)
+
+	var root fs.Directory
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		man, err := snapshotfs.NewUploader(w).Upload(ctx, src, nil, env.LocalPathSourceInfo(dir))
+		require.NoError(t, err)
+
+		e, err := snapshotfs.SnapshotRoot(w, man)
+		require.NoError(t, err)
+
+		root = e.(fs.Directory) //nolint:forcetypeassert
+
+		return nil
+	}))
+
+	return root
+}
+
+func writeFile(t *testing.T, fname string, data []byte) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0o700))
+	require.NoError(t, os.WriteFile(fname, data, 0o600))
+}
+
+func copyDirectory(t *testing.T, src, dst string) {
+	t.Helper()
+
+	require.NoError(t, os.CopyFS(dst, os.DirFS(src)))
+}
+
+func TestExportApply(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	large := make([]byte, largeFileSize)
+	rand.Read(large)
+
+	baseDir := testutil.TempDirectory(t)
+	writeFile(t, filepath.Join(baseDir, "large.bin"), large)
+	writeFile(t, filepath.Join(baseDir, "unchanged.txt"), []byte("unchanged"))
+	writeFile(t, filepath.Join(baseDir, "removed.txt"), []byte("removed"))
+	writeFile(t, filepath.Join(baseDir, "sub", "changed.txt"), []byte("old contents"))
+	writeFile(t, filepath.Join(baseDir, "becomes-dir"), []byte("file"))
+
+	baseRoot := snapshotDirectory(ctx, t, env, baseDir)
+
+	targetDir := testutil.TempDirectory(t)
+	copyDirectory(t, baseDir, targetDir)
+
+	// change a few bytes in the middle of the large file.
+	modified := bytes.Clone(large)
+	copy(modified[largeFileSize/2:], "modified")
+
+	writeFile(t, filepath.Join(targetDir, "large.bin"), modified)
+	writeFile(t, filepath.Join(targetDir, "sub", "changed.txt"), []byte("new contents"))
+	writeFile(t, filepath.Join(targetDir, "sub", "added", "new.txt"), []byte("new file"))
+	require.NoError(t, os.Remove(filepath.Join(targetDir, "removed.txt")))
+	require.NoError(t, os.Remove(filepath.Join(targetDir, "becomes-dir")))
+	writeFile(t, filepath.Join(targetDir, "becomes-dir", "file.txt"), []byte("nested"))
+	require.NoError(t, os.Symlink("unchanged.txt", filepath.Join(targetDir, "link")))
+	require.NoError(t, os.Chmod(filepath.Join(targetDir, "unchanged.txt"), 0o640))
+
+	targetRoot := snapshotDirectory(ctx, t, env, targetDir)
+
+	var bundle bytes.Buffer
+
+	st, err := diffbundle.Export(ctx, env.Repository, baseRoot, targetRoot, diffbundle.Header{}, &bundle)
+	require.NoError(t, err)
+	require.Equal(t, 2, st.AddedFiles)
+	require.Equal(t, 2, st.ChangedFiles)
+	require.Equal(t, 2, st.DeletedEntries)
+	require.Positive(t, st.ReusedBytes)
+
+	// most of the large file is carried over from the previous version.
+	require.Less(t, bundle.Len(), largeFileSize/2)
+
+	applyDir := testutil.TempDirectory(t)
+	copyDirectory(t, baseDir, applyDir)
+
+	_, ast, err := diffbundle.Apply(ctx, bytes.NewReader(bundle.Bytes()), applyDir)
+	require.NoError(t, err)
+	require.Equal(t, st.ChangedFiles, ast.ChangedFiles)
+	require.Equal(t, st.ReusedBytes, ast.ReusedBytes)
+
+	// the result snapshots identically to the target directory.
+	appliedRoot := snapshotDirectory(ctx, t, env, applyDir)
+
+	st, err = diffbundle.Export(ctx, env.Repository, targetRoot, appliedRoot, diffbundle.Header{}, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.Equal(t, diffbundle.Stats{}, *st)
+
+	link, err := os.Readlink(filepath.Join(applyDir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "unchanged.txt", link)
+
+	// applying to a directory which does not contain the base snapshot fails.
+	otherDir := testutil.TempDirectory(t)
+	copyDirectory(t, baseDir, otherDir)
+	writeFile(t, filepath.Join(otherDir, "large.bin"), large[:largeFileSize/2])
+
+	_, _, err = diffbundle.Apply(ctx, bytes.NewReader(bundle.Bytes()), otherDir)
+	require.ErrorContains(t, err, "does not contain the base snapshot")
+
+	// truncated bundle
+	_, _, err = diffbundle.Apply(ctx, bytes.NewReader(bundle.Bytes()[:bundle.Len()/2]), testutil.TempDirectory(t))
+	require.Error(t, err)
+}
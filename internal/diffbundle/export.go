@@ -0,0 +1,316 @@
+package diffbundle
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+)
+
+type exporter struct {
+	rep   repo.Repository
+	out   io.Writer
+	stats Stats
+}
+
+// Export writes the bundle of changes needed to turn the base directory into the target directory.
+func Export(ctx context.Context, rep repo.Repository, base, target fs.Directory, h Header, output io.Writer) (*Stats, error) {
+	h.Magic = bundleMagic
+	h.Version = bundleFormatVersion
+	h.CreatedAt = clock.Now()
+
+	bw := bufio.NewWriter(output)
+	gz := gzip.NewWriter(bw)
+
+	e := &exporter{rep: rep, out: gz}
+
+	if err := writeRecord(gz, h); err != nil {
+		return nil, err
+	}
+
+	if err := e.compareDirectories(ctx, base, target, ""); err != nil {
+		return nil, err
+	}
+
+	if err := writeRecord(gz, &Entry{Op: OpEnd}); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, errors.Wrap(err, "error compressing bundle")
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, errors.Wrap(err, "error writing bundle")
+	}
+
+	return &e.stats, nil
+}
+
+func sortedEntries(ctx context.Context, d fs.Directory) ([]fs.Entry, error) {
+	if d == nil {
+		return nil, nil
+	}
+
+	entries, err := fs.GetAllEntries(ctx, d)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read directory %v", d.Name())
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func entryKind(e fs.Entry) string {
+	switch e.(type) {
+	case fs.Directory:
+		return OpDirectory
+	case fs.Symlink:
+		return OpSymlink
+	case fs.File:
+		return OpFile
+	default:
+		return ""
+	}
+}
+
+func sameObject(e1, e2 fs.Entry) bool {
+	h1, ok1 := e1.(object.HasObjectID)
+	h2, ok2 := e2.(object.HasObjectID)
+
+	return ok1 && ok2 && h1.ObjectID() == h2.ObjectID()
+}
+
+func sameAttributes(e1, e2 fs.Entry) bool {
+	if _, ok := e2.(fs.Symlink); ok {
+		// attributes of symbolic links are not restored.
+		return true
+	}
+
+	return e1.Mode()&modeMask == e2.Mode()&modeMask && e1.ModTime().Equal(e2.ModTime())
+}
+
+func (e *exporter) compareDirectories(ctx context.Context, base, target fs.Directory, parent string) error {
+	baseEntries, err := sortedEntries(ctx, base)
+	if err != nil {
+		return err
+	}
+
+	targetEntries, err := sortedEntries(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	baseByName := map[string]fs.Entry{}
+	targetByName := map[string]fs.Entry{}
+
+	for _, be := range baseEntries {
+		baseByName[be.Name()] = be
+	}
+
+	for _, te := range targetEntries {
+		targetByName[te.Name()] = te
+	}
+
+	// deletions go first, so that entries can change their type.
+	for _, be := range baseEntries {
+		if te := targetByName[be.Name()]; te == nil || entryKind(te) != entryKind(be) {
+			if err := e.write(&Entry{Op: OpDelete, Path: path.Join(parent, be.Name())}); err != nil {
+				return err
+			}
+
+			e.stats.DeletedEntries++
+
+			delete(baseByName, be.Name())
+		}
+	}
+
+	for _, te := range targetEntries {
+		if err := e.compareEntry(ctx, baseByName[te.Name()], te, path.Join(parent, te.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *exporter) compareEntry(ctx context.Context, base, target fs.Entry, entryPath string) error {
+	if base != nil && sameObject(base, target) && sameAttributes(base, target) {
+		return nil
+	}
+
+	switch t := target.(type) {
+	case fs.Directory:
+		if err := e.write(&Entry{Op: OpDirectory, Path: entryPath, Mode: t.Mode() & modeMask, ModTime: t.ModTime()}); err != nil {
+			return err
+		}
+
+		e.stats.OtherChanges++
+
+		bd, _ := base.(fs.Directory)
+
+		if base != nil && sameObject(base, target) {
+			// only attributes have changed.
+			return nil
+		}
+
+		return e.compareDirectories(ctx, bd, t, entryPath)
+
+	case fs.Symlink:
+		linkTarget, err := t.Readlink(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read symbolic link %v", entryPath)
+		}
+
+		e.stats.OtherChanges++
+
+		return e.write(&Entry{Op: OpSymlink, Path: entryPath, LinkTarget: linkTarget})
+
+	case fs.File:
+		if base != nil && sameObject(base, target) {
+			e.stats.OtherChanges++
+
+			return e.write(&Entry{Op: OpAttributes, Path: entryPath, Mode: t.Mode() & modeMask, ModTime: t.ModTime()})
+		}
+
+		return e.exportFile(ctx, base, t, entryPath)
+
+	default:
+		log(ctx).Warnf("skipping unsupported entry %v", entryPath)
+
+		return nil
+	}
+}
+
+func (e *exporter) write(en *Entry) error {
+	return writeRecord(e.out, en)
+}
+
+// chunksOf returns chunks of the object or a single chunk covering the entire object.
+func chunksOf(or object.Reader, oid object.ID) []object.IndirectObjectEntry {
+	if cr, ok := or.(object.ChunkedReader); ok {
+		return cr.ChunksInRange(0, or.Length())
+	}
+
+	return []object.IndirectObjectEntry{{Start: 0, Length: or.Length(), Object: oid}}
+}
+
+// baseChunkOffsets returns offsets of chunks of the previous version of the file.
+func (e *exporter) baseChunkOffsets(ctx context.Context, base fs.Entry) (map[object.ID]int64, error) {
+	result := map[object.ID]int64{}
+
+	h, ok := base.(object.HasObjectID)
+	if !ok {
+		return result, nil
+	}
+
+	or, err := e.rep.OpenObject(ctx, h.ObjectID())
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open object %v", h.ObjectID())
+	}
+
+	defer or.Close() //nolint:errcheck
+
+	for _, c := range chunksOf(or, h.ObjectID()) {
+		if _, ok := result[c.Object]; !ok {
+			result[c.Object] = c.Start
+		}
+	}
+
+	return result, nil
+}
+
+func (e *exporter) exportFile(ctx context.Context, base fs.Entry, target fs.File, entryPath string) error {
+	h, ok := target.(object.HasObjectID)
+	if !ok {
+		return errors.Errorf("file %v does not have an object ID", entryPath)
+	}
+
+	baseChunks, err := e.baseChunkOffsets(ctx, base)
+	if err != nil {
+		return err
+	}
+
+	or, err := e.rep.OpenObject(ctx, h.ObjectID())
+	if err != nil {
+		return errors.Wrapf(err, "unable to open object %v", h.ObjectID())
+	}
+
+	defer or.Close() //nolint:errcheck
+
+	var segments []Segment
+
+	for _, c := range chunksOf(or, h.ObjectID()) {
+		baseOffset, reused := baseChunks[c.Object]
+
+		if n := len(segments); n > 0 {
+			last := &segments[n-1]
+
+			if last.Literal && !reused {
+				last.Length += c.Length
+				continue
+			}
+
+			if !last.Literal && reused && last.BaseOffset+last.Length == baseOffset {
+				last.Length += c.Length
+				continue
+			}
+		}
+
+		segments = append(segments, Segment{Literal: !reused, BaseOffset: baseOffset, Length: c.Length})
+	}
+
+	if err := e.write(&Entry{
+		Op:       OpFile,
+		Path:     entryPath,
+		Mode:     target.Mode() & modeMask,
+		ModTime:  target.ModTime(),
+		Size:     or.Length(),
+		Segments: segments,
+	}); err != nil {
+		return err
+	}
+
+	// read the entire file in order to compute its checksum, writing only literal segments.
+	hasher := sha256.New()
+
+	for _, s := range segments {
+		var w io.Writer = hasher
+
+		if s.Literal {
+			w = io.MultiWriter(hasher, e.out)
+			e.stats.LiteralBytes += s.Length
+		} else {
+			e.stats.ReusedBytes += s.Length
+		}
+
+		if _, err := io.CopyN(w, or, s.Length); err != nil {
+			return errors.Wrapf(err, "error reading %v", entryPath)
+		}
+	}
+
+	if _, err := e.out.Write(hasher.Sum(nil)); err != nil {
+		return errors.Wrap(err, "write error")
+	}
+
+	if base == nil {
+		e.stats.AddedFiles++
+	} else {
+		e.stats.ChangedFiles++
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+// Package drbundle implements disaster-recovery bundles, which are single password-protected
+// files containing everything needed to reconnect to a repository from a fresh machine.
+package drbundle
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/crypto"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+)
+
+const (
+	bundleFormatVersion = 1
+	bundleMagic         = "kopia-dr-bundle"
+	saltLength          = 32
+	keyLength           = 32
+)
+
+// Instructions are the human-readable recovery instructions embedded in every bundle.
+const Instructions = `This file is a Kopia disaster-recovery bundle.
+
+It contains the repository storage connection information and a backup of the
+repository format blobs, encrypted with the repository password.
+
+To reconnect to the repository on a new machine:
+
+  1. Install Kopia (https://kopia.io/docs/installation/).
+  2. Run: kopia repository dr-bundle restore --file=<this-file>
+  3. Enter the repository password when prompted.
+
+If the 'kopia.repository' blob has been lost or damaged in the storage, add the
+--restore-format-blobs flag to write the backed-up format blobs back to the
+storage before connecting.
+`
+
+// Bundle is the decrypted content of a disaster-recovery bundle.
+type Bundle struct {
+	CreatedAt     time.Time           `json:"createdAt"`
+	Storage       blob.ConnectionInfo `json:"storage"`
+	ClientOptions repo.ClientOptions  `json:"clientOptions"`
+
+	// raw contents of 'kopia.repository' and 'kopia.blobcfg' blobs
+	FormatBlob  []byte `json:"formatBlob"`
+	BlobCfgBlob []byte `json:"blobCfgBlob,omitempty"`
+}
+
+// envelope is the on-disk representation of the bundle.
+type envelope struct {
+	Magic                  string `json:"magic"`
+	Version                int    `json:"version"`
+	Instructions           string `json:"instructions"`
+	KeyDerivationAlgorithm string `json:"keyAlgo"`
+	Salt                   []byte `json:"salt"`
+	EncryptedBundle        []byte `json:"encryptedBundle"`
+}
+
+// Create captures the bundle for the provided repository.
+func Create(ctx context.Context, rep repo.DirectRepository) (*Bundle, error) {
+	b := &Bundle{
+		CreatedAt:     rep.Time(),
+		Storage:       rep.BlobReader().ConnectionInfo(),
+		ClientOptions: rep.ClientOptions(),
+	}
+
+	var err error
+
+	if b.FormatBlob, err = readBlob(ctx, rep.BlobReader(), format.KopiaRepositoryBlobID); err != nil {
+		return nil, errors.Wrap(err, "unable to read format blob")
+	}
+
+	b.BlobCfgBlob, err = readBlob(ctx, rep.BlobReader(), format.KopiaBlobCfgBlobID)
+	if err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+		return nil, errors.Wrap(err, "unable to read blob configuration blob")
+	}
+
+	return b, nil
+}
+
+func readBlob(ctx context.Context, br blob.Reader, id blob.ID) ([]byte, error) {
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	if err := br.GetBlob(ctx, id, 0, -1, &tmp); err != nil {
+		return nil, errors.Wrapf(err, "error reading %v", id)
+	}
+
+	return tmp.ToByteSlice(), nil
+}
+
+// Write encrypts the bundle with the provided password and writes it to the provided writer.
+func Write(w io.Writer, b *Bundle, password string) error {
+	plainText, err := json.Marshal(b)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize bundle")
+	}
+
+	env := &envelope{
+		Magic:                  bundleMagic,
+		Version:                bundleFormatVersion,
+		Instructions:           Instructions,
+		KeyDerivationAlgorithm: format.DefaultKeyDerivationAlgorithm,
+		Salt:                   make([]byte, saltLength),
+	}
+
+	if _, err := io.ReadFull(rand.Reader, env.Salt); err != nil {
+		return errors.Wrap(err, "unable to generate salt")
+	}
+
+	key, err := crypto.DeriveKeyFromPassword(password, env.Salt, keyLength, env.KeyDerivationAlgorithm)
+	if err != nil {
+		return errors.Wrap(err, "unable to derive key")
+	}
+
+	if env.EncryptedBundle, err = crypto.EncryptAes256Gcm(plainText, key, env.Salt); err != nil {
+		return errors.Wrap(err, "unable to encrypt bundle")
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	return errors.Wrap(e.Encode(env), "unable to write bundle")
+}
+
+// Read reads the bundle from the provided reader and decrypts it using the provided password.
+func Read(r io.Reader, password string) (*Bundle, error) {
+	var env envelope
+
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return nil, errors.Wrap(err, "unable to parse bundle")
+	}
+
+	if env.Magic != bundleMagic {
+		return nil, errors.New("not a disaster-recovery bundle")
+	}
+
+	if env.Version != bundleFormatVersion {
+		return nil, errors.Errorf("unsupported bundle version %v", env.Version)
+	}
+
+	key, err := crypto.DeriveKeyFromPassword(password, env.Salt, keyLength, env.KeyDerivationAlgorithm)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive key")
+	}
+
+	plainText, err := crypto.DecryptAes256Gcm(env.EncryptedBundle, key, env.Salt)
+	if err != nil {
+		return nil, format.ErrInvalidPassword
+	}
+
+	b := &Bundle{}
+	if err := json.Unmarshal(plainText, b); err != nil {
+		return nil, errors.Wrap(err, "unable to parse bundle contents")
+	}
+
+	return b, nil
+}
+
+// RestoreFormatBlobs writes the format blobs captured in the bundle back to the provided storage.
+func (b *Bundle) RestoreFormatBlobs(ctx context.Context, st blob.Storage) error {
+	if err := st.PutBlob(ctx, format.KopiaRepositoryBlobID, gather.FromSlice(b.FormatBlob), blob.PutOptions{}); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	if len(b.BlobCfgBlob) == 0 {
+		return nil
+	}
+
+	if err := st.PutBlob(ctx, format.KopiaBlobCfgBlobID, gather.FromSlice(b.BlobCfgBlob), blob.PutOptions{}); err != nil {
+		return errors.Wrap(err, "unable to write blob configuration blob")
+	}
+
+	return nil
+}
@@ -0,0 +1,350 @@
+// Package enrollment manages self-registration of repository clients with a server.
+//
+// An administrator creates one-time enrollment tokens and hands them out to new clients.
+// A client exchanges the token for a pending enrollment request carrying its username and
+// password hash, which appears on the server until the administrator approves or rejects it.
+// Approving the request creates the user profile, which allows the client to connect.
+package enrollment
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+var log = logging.Module("kopia/enrollment")
+
+const (
+	// TokenManifestType is the type of the manifest used to represent enrollment tokens.
+	TokenManifestType = "enrollmentToken"
+
+	// RequestManifestType is the type of the manifest used to represent enrollment requests.
+	RequestManifestType = "enrollmentRequest"
+
+	// TokenHashLabel is the manifest label holding the hash of an enrollment token.
+	TokenHashLabel = "tokenHash"
+
+	// RequestIDLabel is the manifest label holding the stable identifier of an enrollment request.
+	RequestIDLabel = "enrollmentRequestID"
+
+	tokenLength = 24
+)
+
+// States of enrollment requests.
+const (
+	StatePending  = "PENDING"
+	StateApproved = "APPROVED"
+	StateRejected = "REJECTED"
+)
+
+var (
+	// ErrNotFound is returned when an enrollment request is not found.
+	ErrNotFound = errors.New("enrollment request not found")
+
+	// ErrEnrollmentFailed is returned for all rejected enrollment attempts, including invalid, used or expired
+	// tokens, so that unauthenticated clients can't learn anything about the token or existing users.
+	ErrEnrollmentFailed = errors.New("enrollment failed")
+
+	// ErrNotPending is returned when approving or rejecting a request which was already decided.
+	ErrNotPending = errors.New("enrollment request is not pending")
+)
+
+// Token describes a one-time enrollment token. Only the hash of the token is stored in the repository.
+type Token struct {
+	TokenHash   string    `json:"tokenHash"`
+	Description string    `json:"description,omitempty"`
+	CreatedBy   string    `json:"createdBy,omitempty"`
+	CreateTime  time.Time `json:"createTime"`
+	ExpireTime  time.Time `json:"expireTime"`
+}
+
+// Request describes a request of a client to become a user of the repository.
+type Request struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+
+	// password hash of the user to be created when the request is approved,
+	// removed once the request is decided.
+	PasswordHashVersion int    `json:"passwordHashVersion,omitempty"`
+	PasswordHash        []byte `json:"passwordHash,omitempty"`
+
+	// TokenDescription is the description of the token used to enroll, which helps administrators
+	// identify the client.
+	TokenDescription string `json:"tokenDescription,omitempty"`
+
+	RemoteAddress string    `json:"remoteAddress,omitempty"`
+	RequestTime   time.Time `json:"requestTime"`
+
+	State        string     `json:"state"`
+	DecidedBy    string     `json:"decidedBy,omitempty"`
+	DecisionTime *time.Time `json:"decisionTime,omitempty"`
+}
+
+func (r *Request) labels() map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey:        RequestManifestType,
+		user.UsernameAtHostnameLabel: r.Username,
+		RequestIDLabel:               r.ID,
+	}
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateToken creates a new one-time enrollment token valid for the provided duration and returns it.
+// The token is only returned once and can't be retrieved later.
+func CreateToken(ctx context.Context, w repo.RepositoryWriter, description, createdBy string, validity time.Duration) (string, *Token, error) {
+	if validity <= 0 {
+		return "", nil, errors.New("token validity must be positive")
+	}
+
+	b := make([]byte, tokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, errors.Wrap(err, "unable to generate token")
+	}
+
+	token := hex.EncodeToString(b)
+	now := clock.Now()
+
+	t := &Token{
+		TokenHash:   hashToken(token),
+		Description: description,
+		CreatedBy:   createdBy,
+		CreateTime:  now,
+		ExpireTime:  now.Add(validity),
+	}
+
+	if _, err := w.PutManifest(ctx, map[string]string{
+		manifest.TypeLabelKey: TokenManifestType,
+		TokenHashLabel:        t.TokenHash,
+	}, t); err != nil {
+		return "", nil, errors.Wrap(err, "error writing enrollment token")
+	}
+
+	return token, t, nil
+}
+
+// findToken returns the manifests and information of the provided token if it is valid.
+func findToken(ctx context.Context, w repo.RepositoryWriter, token string) ([]*manifest.EntryMetadata, *Token, error) {
+	entries, err := w.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: TokenManifestType,
+		TokenHashLabel:        hashToken(token),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error looking for enrollment token")
+	}
+
+	if len(entries) == 0 {
+		return nil, nil, errors.New("token not found")
+	}
+
+	t := &Token{}
+	if _, err := w.GetManifest(ctx, manifest.PickLatestID(entries), t); err != nil {
+		return nil, nil, errors.Wrap(err, "error loading enrollment token")
+	}
+
+	if !clock.Now().Before(t.ExpireTime) {
+		return nil, nil, errors.New("token has expired")
+	}
+
+	return entries, t, nil
+}
+
+// Submit consumes the enrollment token and creates a pending enrollment request for the provided
+// username (user@hostname) and password.
+//
+// All failures are reported as ErrEnrollmentFailed and the reason is only logged, since the caller
+// is not authenticated. Callers must serialize calls to Submit and flush the writer before the next one,
+// otherwise a token may be redeemed more than once.
+func Submit(ctx context.Context, w repo.RepositoryWriter, token, username, password, remoteAddress string) (*Request, error) {
+	r, err := submit(ctx, w, token, username, password, remoteAddress)
+	if err != nil {
+		log(ctx).Warnf("enrollment of %q from %v failed: %v", username, remoteAddress, err)
+
+		return nil, ErrEnrollmentFailed
+	}
+
+	return r, nil
+}
+
+func submit(ctx context.Context, w repo.RepositoryWriter, token, username, password, remoteAddress string) (*Request, error) {
+	// validate the token before anything else, so that clients without one learn nothing about users.
+	tokenEntries, t, err := findToken(ctx, w, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+
+	// check the username before the token gets consumed.
+	p, err := user.GetNewProfile(ctx, w, username)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid username")
+	}
+
+	pending, err := List(ctx, w, StatePending)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range pending {
+		if r.Username == username {
+			return nil, errors.Errorf("enrollment of %v is already pending", username)
+		}
+	}
+
+	if err := p.SetPassword(password); err != nil {
+		return nil, errors.Wrap(err, "unable to hash password")
+	}
+
+	for _, e := range tokenEntries {
+		if err := w.DeleteManifest(ctx, e.ID); err != nil {
+			return nil, errors.Wrap(err, "error deleting enrollment token")
+		}
+	}
+
+	r := &Request{
+		ID:                  uuid.NewString(),
+		Username:            username,
+		PasswordHashVersion: p.PasswordHashVersion,
+		PasswordHash:        p.PasswordHash,
+		TokenDescription:    t.Description,
+		RemoteAddress:       remoteAddress,
+		RequestTime:         clock.Now(),
+		State:               StatePending,
+	}
+
+	return r, update(ctx, w, r)
+}
+
+func update(ctx context.Context, w repo.RepositoryWriter, r *Request) error {
+	if _, err := w.ReplaceManifests(ctx, r.labels(), r); err != nil {
+		return errors.Wrap(err, "error writing enrollment request")
+	}
+
+	return nil
+}
+
+// Get returns the enrollment request with the provided ID.
+func Get(ctx context.Context, rep repo.Repository, id string) (*Request, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: RequestManifestType,
+		RequestIDLabel:        id,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error looking for enrollment request")
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.Wrap(ErrNotFound, id)
+	}
+
+	r := &Request{}
+	if _, err := rep.GetManifest(ctx, manifest.PickLatestID(entries), r); err != nil {
+		return nil, errors.Wrap(err, "error loading enrollment request")
+	}
+
+	return r, nil
+}
+
+// List returns enrollment requests in the provided state ordered by request time, empty state matches all.
+func List(ctx context.Context, rep repo.Repository, state string) ([]*Request, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: RequestManifestType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing enrollment requests")
+	}
+
+	result := []*Request{}
+
+	for _, e := range manifest.DedupeEntryMetadataByLabel(entries, RequestIDLabel) {
+		r := &Request{}
+		if _, err := rep.GetManifest(ctx, e.ID, r); err != nil {
+			return nil, errors.Wrap(err, "error loading enrollment request")
+		}
+
+		if state == "" || r.State == state {
+			result = append(result, r)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RequestTime.Before(result[j].RequestTime)
+	})
+
+	return result, nil
+}
+
+// Approve approves the pending enrollment request and creates the user profile.
+func Approve(ctx context.Context, w repo.RepositoryWriter, id, approvedBy string) (*Request, error) {
+	r, err := getPending(ctx, w, id)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := user.GetNewProfile(ctx, w, r.Username)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create user")
+	}
+
+	p.PasswordHashVersion = r.PasswordHashVersion
+	p.PasswordHash = r.PasswordHash
+
+	if err := user.SetUserProfile(ctx, w, p); err != nil {
+		return nil, errors.Wrap(err, "unable to create user")
+	}
+
+	return r, decide(ctx, w, r, StateApproved, approvedBy)
+}
+
+// Reject rejects the pending enrollment request.
+func Reject(ctx context.Context, w repo.RepositoryWriter, id, rejectedBy string) (*Request, error) {
+	r, err := getPending(ctx, w, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, decide(ctx, w, r, StateRejected, rejectedBy)
+}
+
+func getPending(ctx context.Context, rep repo.Repository, id string) (*Request, error) {
+	r, err := Get(ctx, rep, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.State != StatePending {
+		return nil, errors.Wrapf(ErrNotPending, "%v is %v", id, r.State)
+	}
+
+	return r, nil
+}
+
+func decide(ctx context.Context, w repo.RepositoryWriter, r *Request, state, decidedBy string) error {
+	now := clock.Now()
+
+	r.State = state
+	r.DecidedBy = decidedBy
+	r.DecisionTime = &now
+	r.PasswordHashVersion = 0
+	r.PasswordHash = nil
+
+	return update(ctx, w, r)
+}
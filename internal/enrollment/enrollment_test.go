@@ -0,0 +1,111 @@
+package enrollment_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/enrollment"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/user"
+)
+
+func TestEnrollment(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+	w := env.RepositoryWriter
+
+	token, tok, err := enrollment.CreateToken(ctx, w, "laptops", "admin@server", time.Hour)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.NotContains(t, tok.TokenHash, token)
+
+	_, _, err = enrollment.CreateToken(ctx, w, "", "", 0)
+	require.Error(t, err)
+
+	_, err = enrollment.Submit(ctx, w, "no-such-token", "alice@laptop", "pass", "")
+	require.ErrorIs(t, err, enrollment.ErrEnrollmentFailed)
+
+	// without a valid token all failures look the same.
+	_, err = enrollment.Submit(ctx, w, "no-such-token", "Alice", "", "")
+	require.Equal(t, enrollment.ErrEnrollmentFailed, err)
+
+	// invalid username or password does not consume the token.
+	_, err = enrollment.Submit(ctx, w, token, "Alice", "pass", "")
+	require.Equal(t, enrollment.ErrEnrollmentFailed, err)
+
+	_, err = enrollment.Submit(ctx, w, token, "alice@laptop", "", "")
+	require.Equal(t, enrollment.ErrEnrollmentFailed, err)
+
+	r, err := enrollment.Submit(ctx, w, token, "alice@laptop", "alice-pass", "10.0.0.1:1234")
+	require.NoError(t, err)
+	require.Equal(t, enrollment.StatePending, r.State)
+	require.Equal(t, "laptops", r.TokenDescription)
+
+	// token is one-time.
+	_, err = enrollment.Submit(ctx, w, token, "bob@laptop", "bob-pass", "")
+	require.ErrorIs(t, err, enrollment.ErrEnrollmentFailed)
+
+	pending, err := enrollment.List(ctx, w, enrollment.StatePending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, r.ID, pending[0].ID)
+
+	_, err = user.GetUserProfile(ctx, w, "alice@laptop")
+	require.ErrorIs(t, err, user.ErrUserNotFound)
+
+	approved, err := enrollment.Approve(ctx, w, r.ID, "admin@server")
+	require.NoError(t, err)
+	require.Equal(t, enrollment.StateApproved, approved.State)
+	require.Empty(t, approved.PasswordHash)
+
+	p, err := user.GetUserProfile(ctx, w, "alice@laptop")
+	require.NoError(t, err)
+
+	valid, err := p.IsValidPassword("alice-pass")
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	_, err = enrollment.Approve(ctx, w, r.ID, "admin@server")
+	require.ErrorIs(t, err, enrollment.ErrNotPending)
+
+	// existing users can't enroll again.
+	token2, _, err := enrollment.CreateToken(ctx, w, "", "", time.Hour)
+	require.NoError(t, err)
+
+	_, err = enrollment.Submit(ctx, w, token2, "alice@laptop", "other-pass", "")
+	require.Equal(t, enrollment.ErrEnrollmentFailed, err)
+
+	r2, err := enrollment.Submit(ctx, w, token2, "bob@laptop", "bob-pass", "")
+	require.NoError(t, err)
+
+	rejected, err := enrollment.Reject(ctx, w, r2.ID, "admin@server")
+	require.NoError(t, err)
+	require.Equal(t, enrollment.StateRejected, rejected.State)
+
+	_, err = user.GetUserProfile(ctx, w, "bob@laptop")
+	require.ErrorIs(t, err, user.ErrUserNotFound)
+
+	_, err = enrollment.Get(ctx, w, "no-such-id")
+	require.ErrorIs(t, err, enrollment.ErrNotFound)
+
+	all, err := enrollment.List(ctx, w, "")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	pending, err = enrollment.List(ctx, w, enrollment.StatePending)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestEnrollmentTokenExpiration(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	token, _, err := enrollment.CreateToken(ctx, env.RepositoryWriter, "", "", time.Nanosecond)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	_, err = enrollment.Submit(ctx, env.RepositoryWriter, token, "alice@laptop", "pass", "")
+	require.ErrorIs(t, err, enrollment.ErrEnrollmentFailed)
+}
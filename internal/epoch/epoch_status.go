@@ -0,0 +1,175 @@
+package epoch
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Status describes the state of the epoch manager for troubleshooting.
+type Status struct {
+	WriteEpoch          int                     `json:"writeEpoch"`
+	WriteEpochStartTime time.Time               `json:"writeEpochStartTime,omitempty"`
+	DeletionWatermark   time.Time               `json:"deletionWatermark"`
+	ShouldAdvance       bool                    `json:"shouldAdvance"`
+	Epochs              []EpochStatus           `json:"epochs"`
+	RangeCheckpoints    []RangeCheckpointStatus `json:"rangeCheckpoints"`
+}
+
+// EpochStatus describes the index blobs of a single epoch not covered by range checkpoints.
+//
+//nolint:revive
+type EpochStatus struct {
+	Epoch            int       `json:"epoch"`
+	Settled          bool      `json:"settled"`
+	Compacted        bool      `json:"compacted"`
+	UncompactedBlobs int       `json:"uncompactedBlobs"`
+	UncompactedBytes int64     `json:"uncompactedBytes"`
+	OldestBlobTime   time.Time `json:"oldestBlobTime,omitempty"`
+	NewestBlobTime   time.Time `json:"newestBlobTime,omitempty"`
+}
+
+// RangeCheckpointStatus describes a range checkpoint.
+type RangeCheckpointStatus struct {
+	MinEpoch    int       `json:"minEpoch"`
+	MaxEpoch    int       `json:"maxEpoch"`
+	Blobs       int       `json:"blobs"`
+	TotalBytes  int64     `json:"totalBytes"`
+	CreatedTime time.Time `json:"createdTime"`
+}
+
+// Status returns the current status of the epoch manager, including uncompacted index blobs of all
+// epochs that are not covered by range checkpoints.
+func (e *Manager) Status(ctx context.Context) (*Status, error) {
+	p, err := e.getParameters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := e.committedState(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	firstNonRangeCompacted := 0
+	if n := len(cs.LongestRangeCheckpointSets); n > 0 {
+		firstNonRangeCompacted = cs.LongestRangeCheckpointSets[n-1].MaxEpoch + 1
+	}
+
+	// committed state only has uncompacted blobs of the most recent epochs.
+	uncompacted, err := e.loadUncompactedEpochs(ctx, firstNonRangeCompacted, cs.WriteEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Status{
+		WriteEpoch:          cs.WriteEpoch,
+		WriteEpochStartTime: cs.EpochStartTime[cs.WriteEpoch],
+		DeletionWatermark:   cs.DeletionWatermark,
+		ShouldAdvance:       shouldAdvance(uncompacted[cs.WriteEpoch], p.MinEpochDuration, p.EpochAdvanceOnCountThreshold, p.EpochAdvanceOnTotalSizeBytesThreshold),
+	}
+
+	for ep := firstNonRangeCompacted; ep <= cs.WriteEpoch; ep++ {
+		bms := uncompacted[ep]
+
+		es := EpochStatus{
+			Epoch:            ep,
+			Settled:          cs.isSettledEpochNumber(ep),
+			Compacted:        cs.SingleEpochCompactionSets[ep] != nil,
+			UncompactedBlobs: len(bms),
+			UncompactedBytes: blob.TotalLength(bms),
+		}
+
+		if len(bms) > 0 {
+			es.OldestBlobTime = blob.MinTimestamp(bms)
+			es.NewestBlobTime = blob.MaxTimestamp(bms)
+		}
+
+		s.Epochs = append(s.Epochs, es)
+	}
+
+	for _, r := range cs.LongestRangeCheckpointSets {
+		s.RangeCheckpoints = append(s.RangeCheckpoints, RangeCheckpointStatus{
+			MinEpoch:    r.MinEpoch,
+			MaxEpoch:    r.MaxEpoch,
+			Blobs:       len(r.Blobs),
+			TotalBytes:  blob.TotalLength(r.Blobs),
+			CreatedTime: blob.MaxTimestamp(r.Blobs),
+		})
+	}
+
+	return s, nil
+}
+
+// ForceAdvanceWriteEpoch starts a new write epoch regardless of whether the current one
+// is eligible for advancement and returns the new write epoch.
+func (e *Manager) ForceAdvanceWriteEpoch(ctx context.Context) (int, error) {
+	cs, err := e.committedState(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := e.advanceEpochMarker(ctx, cs); err != nil {
+		return 0, errors.Wrap(err, "error advancing epoch")
+	}
+
+	e.Invalidate()
+
+	cs, err = e.committedState(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return cs.WriteEpoch, nil
+}
+
+// CompactSettledEpochs compacts all settled epochs that have not been compacted yet
+// and generates a range checkpoint if enough epochs are settled. It returns the number
+// of compacted epochs.
+func (e *Manager) CompactSettledEpochs(ctx context.Context) (int, error) {
+	cs, err := e.committedState(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	firstNonRangeCompacted := 0
+	if n := len(cs.LongestRangeCheckpointSets); n > 0 {
+		firstNonRangeCompacted = cs.LongestRangeCheckpointSets[n-1].MaxEpoch + 1
+	}
+
+	compacted := 0
+
+	for ep := firstNonRangeCompacted; cs.isSettledEpochNumber(ep); ep++ {
+		if cs.SingleEpochCompactionSets[ep] != nil {
+			continue
+		}
+
+		bms, err := blob.ListAllBlobs(ctx, e.st, UncompactedEpochBlobPrefix(ep))
+		if err != nil {
+			return compacted, errors.Wrapf(err, "error listing uncompacted indexes for epoch %v", ep)
+		}
+
+		if len(bms) == 0 {
+			continue
+		}
+
+		if err := e.compact(ctx, blob.IDsFromMetadata(bms), compactedEpochBlobPrefix(ep)); err != nil {
+			return compacted, errors.Wrapf(err, "unable to compact blobs for epoch %v", ep)
+		}
+
+		compacted++
+	}
+
+	e.Invalidate()
+
+	if err := e.MaybeGenerateRangeCheckpoint(ctx); err != nil {
+		return compacted, err
+	}
+
+	e.Invalidate()
+
+	return compacted, nil
+}
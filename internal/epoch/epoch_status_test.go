@@ -0,0 +1,67 @@
+package epoch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestStatusAndForcedAdvancement(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	te := newTestEnv(t)
+
+	s, err := te.mgr.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, s.WriteEpoch)
+	require.False(t, s.ShouldAdvance)
+
+	for i := range 4 {
+		te.mustWriteIndexFiles(ctx, t, newFakeIndexWithEntries(i))
+		te.ft.Advance(time.Hour)
+
+		if i < 3 {
+			ep, err := te.mgr.ForceAdvanceWriteEpoch(ctx)
+			require.NoError(t, err)
+			require.Equal(t, i+1, ep)
+		}
+	}
+
+	te.verifyCurrentWriteEpoch(t, 3)
+
+	s, err = te.mgr.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, s.WriteEpoch)
+	require.Empty(t, s.RangeCheckpoints)
+	require.Len(t, s.Epochs, 4)
+
+	for _, es := range s.Epochs {
+		require.Equal(t, es.Epoch <= 1, es.Settled, "epoch %v", es.Epoch)
+		require.False(t, es.Compacted)
+		require.Equal(t, 1, es.UncompactedBlobs)
+		require.Positive(t, es.UncompactedBytes)
+		require.False(t, es.OldestBlobTime.IsZero())
+	}
+
+	n, err := te.mgr.CompactSettledEpochs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	s, err = te.mgr.Status(ctx)
+	require.NoError(t, err)
+
+	for _, es := range s.Epochs {
+		require.Equal(t, es.Epoch <= 1, es.Compacted, "epoch %v", es.Epoch)
+	}
+
+	// nothing left to compact.
+	n, err = te.mgr.CompactSettledEpochs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	te.verifyCompleteIndexSet(ctx, t, LatestEpoch, newFakeIndexWithEntries(0, 1, 2, 3), time.Time{})
+}
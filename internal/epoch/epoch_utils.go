@@ -56,6 +56,22 @@ func epochRangeFromBlobID(blobID blob.ID) (minEpoch, maxEpoch int, ok bool) {
 	return n1, n2, err1 == nil && err2 == nil
 }
 
+// EpochRangeFromIndexBlobID returns the range of epochs covered by the provided epoch index blob.
+// Range checkpoints cover multiple epochs, all other epoch index blobs cover a single epoch.
+func EpochRangeFromIndexBlobID(blobID blob.ID) (minEpoch, maxEpoch int, ok bool) {
+	if strings.HasPrefix(string(blobID), string(RangeCheckpointIndexBlobPrefix)) {
+		return epochRangeFromBlobID(blobID)
+	}
+
+	if !strings.HasPrefix(string(blobID), EpochManagerIndexUberPrefix) {
+		return 0, 0, false
+	}
+
+	n, ok := epochNumberFromBlobID(blobID)
+
+	return n, n, ok
+}
+
 func groupByEpochNumber(bms []blob.Metadata) map[int][]blob.Metadata {
 	result := map[int][]blob.Metadata{}
 
@@ -416,3 +416,27 @@ func TestGetFirstContiguousKeyRange(t *testing.T) {
 		})
 	}
 }
+
+func TestEpochRangeFromIndexBlobID(t *testing.T) {
+	cases := []struct {
+		input    blob.ID
+		min, max int
+		ok       bool
+	}{
+		{"xn3_abc", 3, 3, true},
+		{"xs12_abc", 12, 12, true},
+		{"xr4_9_abc", 4, 9, true},
+		{"n123456", 0, 0, false},
+		{"xnabc", 0, 0, false},
+	}
+
+	for _, tc := range cases {
+		minEpoch, maxEpoch, ok := EpochRangeFromIndexBlobID(tc.input)
+		require.Equal(t, tc.ok, ok, "EpochRangeFromIndexBlobID(%v)", tc.input)
+
+		if ok {
+			require.Equal(t, tc.min, minEpoch, tc.input)
+			require.Equal(t, tc.max, maxEpoch, tc.input)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package formatcheck validates integrity of well-known file formats (gzip, zip, SQLite),
+// which allows reporting corruption of individual files in terms users understand.
+package formatcheck
+
+import (
+	"bytes"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// HeaderLength is the number of bytes at the beginning of a file needed to detect its format.
+const HeaderLength = 16
+
+// Checker validates the structure of files in a particular format.
+type Checker interface {
+	// Name returns the name of the format.
+	Name() string
+
+	// Matches determines whether the file with the provided header is in the checker's format.
+	Matches(header []byte) bool
+
+	// Check validates the file positioned at its beginning. Parts of the file not needed
+	// for the check may be left unread.
+	Check(r io.ReadSeeker, length int64) error
+}
+
+var allCheckers = []Checker{
+	gzipChecker{},
+	zipChecker{},
+	sqliteChecker{},
+}
+
+// Names returns the names of all supported formats.
+func Names() []string {
+	var result []string
+
+	for _, c := range allCheckers {
+		result = append(result, c.Name())
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// ByName returns checkers for formats with the provided names.
+func ByName(names []string) ([]Checker, error) {
+	var result []Checker
+
+	for _, n := range names {
+		c := find(n)
+		if c == nil {
+			return nil, errors.Errorf("unsupported file format %q", n)
+		}
+
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+func find(name string) Checker {
+	for _, c := range allCheckers {
+		if c.Name() == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Detect returns the checker among the provided ones matching the file header or nil.
+func Detect(checkers []Checker, header []byte) Checker {
+	for _, c := range checkers {
+		if c.Matches(header) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// ReadHeader reads up to HeaderLength bytes from the beginning of the provided reader.
+func ReadHeader(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if _, err := io.CopyN(&buf, r, HeaderLength); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "unable to read file header")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readerAt adapts io.ReadSeeker to io.ReaderAt for non-concurrent use.
+type readerAt struct {
+	r io.ReadSeeker
+}
+
+func (a readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := a.r.Seek(off, io.SeekStart); err != nil {
+		return 0, errors.Wrap(err, "seek error")
+	}
+
+	n, err := io.ReadFull(a.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+
+	return n, err //nolint:wrapcheck
+}
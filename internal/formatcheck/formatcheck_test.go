@@ -0,0 +1,155 @@
+package formatcheck_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/formatcheck"
+)
+
+func check(t *testing.T, data []byte) (string, error) {
+	t.Helper()
+
+	all, err := formatcheck.ByName(formatcheck.Names())
+	require.NoError(t, err)
+
+	hdr, err := formatcheck.ReadHeader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	c := formatcheck.Detect(all, hdr)
+	if c == nil {
+		return "", nil
+	}
+
+	return c.Name(), c.Check(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestGzip(t *testing.T) {
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	gw.Write(bytes.Repeat([]byte("hello world "), 1000))
+	require.NoError(t, gw.Close())
+
+	good := buf.Bytes()
+
+	name, err := check(t, good)
+	require.Equal(t, "gzip", name)
+	require.NoError(t, err)
+
+	// flip a bit in the CRC
+	bad := bytes.Clone(good)
+	bad[len(bad)-6] ^= 1
+
+	_, err = check(t, bad)
+	require.ErrorContains(t, err, "invalid gzip data")
+
+	_, err = check(t, good[:len(good)-10])
+	require.Error(t, err)
+}
+
+func TestZip(t *testing.T) {
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	for _, n := range []string{"a.txt", "b.txt"} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: n, Method: zip.Store})
+		require.NoError(t, err)
+
+		w.Write([]byte("contents of " + n))
+	}
+
+	require.NoError(t, zw.Close())
+
+	good := buf.Bytes()
+
+	name, err := check(t, good)
+	require.Equal(t, "zip", name)
+	require.NoError(t, err)
+
+	// corrupt contents of the stored second entry.
+	bad := bytes.Clone(good)
+	i := bytes.Index(bad, []byte("contents of b.txt"))
+	bad[i] ^= 1
+
+	_, err = check(t, bad)
+	require.ErrorContains(t, err, `invalid zip entry "b.txt"`)
+
+	_, err = check(t, good[:len(good)-10])
+	require.ErrorContains(t, err, "invalid zip central directory")
+}
+
+// sqliteDatabase builds a minimal database whose schema table has the provided pages.
+func sqliteDatabase(pageSize, pageCount int, setup func(pages [][]byte)) []byte {
+	data := make([]byte, pageSize*pageCount)
+	copy(data, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(data[16:], uint16(pageSize)) //nolint:gosec
+	data[21], data[22], data[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(data[24:], 1)
+	binary.BigEndian.PutUint32(data[28:], uint32(pageCount)) //nolint:gosec
+	binary.BigEndian.PutUint32(data[92:], 1)
+
+	var pages [][]byte
+	for i := range pageCount {
+		pages = append(pages, data[i*pageSize:(i+1)*pageSize])
+	}
+
+	setup(pages)
+
+	return data
+}
+
+func TestSQLite(t *testing.T) {
+	const pageSize = 1024
+
+	empty := sqliteDatabase(pageSize, 1, func(pages [][]byte) {
+		pages[0][100] = 13
+	})
+
+	name, err := check(t, empty)
+	require.Equal(t, "sqlite", name)
+	require.NoError(t, err)
+
+	// schema table with an interior root page pointing at leaves on pages 2 and 3.
+	twoLevel := func(leafType byte) []byte {
+		return sqliteDatabase(pageSize, 3, func(pages [][]byte) {
+			root := pages[0][100:]
+			root[0] = 5
+			binary.BigEndian.PutUint16(root[3:], 1)     // one cell
+			binary.BigEndian.PutUint32(root[8:], 3)     // right-most child
+			binary.BigEndian.PutUint16(root[12:], 1000) // cell offset within page
+			binary.BigEndian.PutUint32(pages[0][1000:], 2)
+
+			pages[1][0] = leafType
+			pages[2][0] = 13
+		})
+	}
+
+	_, err = check(t, twoLevel(13))
+	require.NoError(t, err)
+
+	_, err = check(t, twoLevel(0))
+	require.ErrorContains(t, err, "sqlite schema table page 2 has invalid type 0")
+
+	truncated := twoLevel(13)
+	_, err = check(t, truncated[:2*pageSize])
+	require.ErrorContains(t, err, "the file is truncated")
+
+	_, err = check(t, truncated[:2*pageSize+100])
+	require.ErrorContains(t, err, "not a multiple of page size")
+}
+
+func TestUnknownFormat(t *testing.T) {
+	name, err := check(t, []byte("plain text"))
+	require.Empty(t, name)
+	require.NoError(t, err)
+
+	_, err = formatcheck.ByName([]string{"no-such-format"})
+	require.Error(t, err)
+}
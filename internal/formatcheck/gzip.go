@@ -0,0 +1,39 @@
+package formatcheck
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gzipChecker decompresses all members of the gzip stream, which verifies their CRC and length.
+type gzipChecker struct{}
+
+func (gzipChecker) Name() string {
+	return "gzip"
+}
+
+func (gzipChecker) Matches(header []byte) bool {
+	return bytes.HasPrefix(header, gzipMagic)
+}
+
+func (gzipChecker) Check(r io.ReadSeeker, length int64) error {
+	_ = length
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "invalid gzip header")
+	}
+
+	defer gz.Close() //nolint:errcheck
+
+	if _, err := io.Copy(io.Discard, gz); err != nil {
+		return errors.Wrap(err, "invalid gzip data")
+	}
+
+	return nil
+}
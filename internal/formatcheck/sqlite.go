@@ -0,0 +1,169 @@
+package formatcheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+const (
+	sqliteHeaderLength = 100
+	sqliteMinPageSize  = 512
+	sqliteMaxPageSize  = 65536
+
+	// page size of 65536 does not fit in 16 bits and is stored as 1.
+	sqliteMaxPageSizeMarker = 1
+
+	// b-tree page types.
+	sqliteInteriorIndexPage = 2
+	sqliteInteriorTablePage = 5
+	sqliteLeafIndexPage     = 10
+	sqliteLeafTablePage     = 13
+
+	sqliteInteriorPageHeaderLength = 12
+)
+
+// sqliteChecker validates the database header, reads all pages and walks the b-tree of the schema table.
+//
+// Only the schema table is walked, because locating other tables requires decoding records.
+// Pages which aren't part of the schema table (including free pages which may contain
+// leftover data) are only checked to be readable.
+type sqliteChecker struct{}
+
+func (sqliteChecker) Name() string {
+	return "sqlite"
+}
+
+func (sqliteChecker) Matches(header []byte) bool {
+	return bytes.HasPrefix(header, sqliteMagic)
+}
+
+//nolint:gocyclo
+func (sqliteChecker) Check(r io.ReadSeeker, length int64) error {
+	hdr := make([]byte, sqliteHeaderLength)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return errors.Wrap(err, "truncated sqlite header")
+	}
+
+	pageSize := int64(binary.BigEndian.Uint16(hdr[16:18]))
+	if pageSize == sqliteMaxPageSizeMarker {
+		pageSize = sqliteMaxPageSize
+	}
+
+	if pageSize < sqliteMinPageSize || pageSize > sqliteMaxPageSize || pageSize&(pageSize-1) != 0 {
+		return errors.Errorf("invalid sqlite page size %v", pageSize)
+	}
+
+	// maximum embedded payload fraction, minimum embedded payload fraction and leaf payload fraction are fixed.
+	if hdr[21] != 64 || hdr[22] != 32 || hdr[23] != 32 {
+		return errors.New("invalid sqlite payload fractions")
+	}
+
+	if length%pageSize != 0 {
+		return errors.Errorf("sqlite file length %v is not a multiple of page size %v", length, pageSize)
+	}
+
+	pageCount := length / pageSize
+
+	// the database size in the header is only valid when the change counter matches version-valid-for.
+	changeCounter := binary.BigEndian.Uint32(hdr[24:28])
+	databaseSize := binary.BigEndian.Uint32(hdr[28:32])
+	versionValidFor := binary.BigEndian.Uint32(hdr[92:96])
+
+	if databaseSize != 0 && changeCounter == versionValidFor && int64(databaseSize) > pageCount {
+		return errors.Errorf("sqlite database has %v pages but the file only contains %v, the file is truncated", databaseSize, pageCount)
+	}
+
+	if freelistTrunk := binary.BigEndian.Uint32(hdr[32:36]); int64(freelistTrunk) > pageCount {
+		return errors.Errorf("sqlite freelist starts at page %v beyond the end of file", freelistTrunk)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "seek error")
+	}
+
+	pageTypes := make([]byte, pageCount+1)
+	children := map[uint32][]uint32{}
+	page := make([]byte, pageSize)
+
+	for n := int64(1); n <= pageCount; n++ {
+		if _, err := io.ReadFull(r, page); err != nil {
+			return errors.Wrapf(err, "error reading sqlite page %v", n)
+		}
+
+		off := 0
+		if n == 1 {
+			off = sqliteHeaderLength
+		}
+
+		pageTypes[n] = page[off]
+
+		if t := page[off]; t == sqliteInteriorTablePage || t == sqliteInteriorIndexPage {
+			children[uint32(n)] = sqliteChildPages(page, off) //nolint:gosec
+		}
+	}
+
+	return checkSQLiteSchemaTree(pageTypes, children)
+}
+
+// sqliteChildPages returns page numbers of children of an interior b-tree page,
+// invalid cell pointers are returned as page 0.
+func sqliteChildPages(page []byte, off int) []uint32 {
+	if off+sqliteInteriorPageHeaderLength > len(page) {
+		return []uint32{0}
+	}
+
+	cellCount := int(binary.BigEndian.Uint16(page[off+3:]))
+	result := []uint32{binary.BigEndian.Uint32(page[off+8:])}
+
+	for i := range cellCount {
+		p := off + sqliteInteriorPageHeaderLength + 2*i
+		if p+2 > len(page) {
+			return append(result, 0)
+		}
+
+		cellOffset := int(binary.BigEndian.Uint16(page[p:]))
+		if cellOffset+4 > len(page) {
+			result = append(result, 0)
+			continue
+		}
+
+		result = append(result, binary.BigEndian.Uint32(page[cellOffset:]))
+	}
+
+	return result
+}
+
+func checkSQLiteSchemaTree(pageTypes []byte, children map[uint32][]uint32) error {
+	visited := map[uint32]bool{}
+	pending := []uint32{1}
+
+	for len(pending) > 0 {
+		p := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if p == 0 || int(p) >= len(pageTypes) {
+			return errors.Errorf("sqlite schema table references invalid page %v", p)
+		}
+
+		if visited[p] {
+			return errors.Errorf("sqlite schema table references page %v more than once", p)
+		}
+
+		visited[p] = true
+
+		switch pageTypes[p] {
+		case sqliteLeafTablePage:
+		case sqliteInteriorTablePage:
+			pending = append(pending, children[p]...)
+		default:
+			return errors.Errorf("sqlite schema table page %v has invalid type %v", p, pageTypes[p])
+		}
+	}
+
+	return nil
+}
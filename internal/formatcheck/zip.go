@@ -0,0 +1,53 @@
+package formatcheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	zipLocalFileMagic = []byte("PK\x03\x04")
+	zipEmptyMagic     = []byte("PK\x05\x06")
+)
+
+// zipChecker reads the central directory and extracts all entries, which verifies their CRC.
+type zipChecker struct{}
+
+func (zipChecker) Name() string {
+	return "zip"
+}
+
+func (zipChecker) Matches(header []byte) bool {
+	return bytes.HasPrefix(header, zipLocalFileMagic) || bytes.HasPrefix(header, zipEmptyMagic)
+}
+
+func (zipChecker) Check(r io.ReadSeeker, length int64) error {
+	zr, err := zip.NewReader(readerAt{r}, length)
+	if err != nil {
+		return errors.Wrap(err, "invalid zip central directory")
+	}
+
+	for _, f := range zr.File {
+		if err := checkZipEntry(f); err != nil {
+			return errors.Wrapf(err, "invalid zip entry %q", f.Name)
+		}
+	}
+
+	return nil
+}
+
+func checkZipEntry(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return errors.Wrap(err, "open error")
+	}
+
+	defer rc.Close() //nolint:errcheck
+
+	_, err = io.Copy(io.Discard, rc)
+
+	return errors.Wrap(err, "read error")
+}
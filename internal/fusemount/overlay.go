@@ -0,0 +1,640 @@
+//go:build !windows && !openbsd && !freebsd
+// +build !windows,!openbsd,!freebsd
+
+package fusemount
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	gofusefs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+)
+
+const writeOpenFlags = syscall.O_WRONLY | syscall.O_RDWR | syscall.O_TRUNC | syscall.O_APPEND
+
+// overlayNode is a FUSE node of a writable copy-on-write overlay on top of a snapshot entry.
+// Changes are stored in a local scratch directory and never written to the repository.
+type overlayNode struct {
+	gofusefs.Inode
+
+	store *overlayStore
+
+	// mu serializes operations that modify the scratch directory, shared by all nodes.
+	mu *sync.Mutex
+
+	// lower is the snapshot entry, nil if the entry only exists in the scratch directory.
+	lower fs.Entry
+}
+
+func (n *overlayNode) rel() string {
+	return n.Path(nil)
+}
+
+func (n *overlayNode) newChild(lower fs.Entry) *overlayNode {
+	return &overlayNode{store: n.store, mu: n.mu, lower: lower}
+}
+
+func (n *overlayNode) lowerDir() fs.Directory {
+	d, _ := n.lower.(fs.Directory)
+	return d
+}
+
+func (n *overlayNode) parentNode() *overlayNode {
+	_, p := n.Parent()
+	if p == nil {
+		return nil
+	}
+
+	pn, _ := p.Operations().(*overlayNode)
+
+	return pn
+}
+
+// copyUp ensures the entry and all its parents are stored in the scratch directory.
+func (n *overlayNode) copyUp(ctx context.Context) error {
+	rel := n.rel()
+	if rel == "" || n.store.exists(rel) {
+		return nil
+	}
+
+	if p := n.parentNode(); p != nil {
+		if err := p.copyUp(ctx); err != nil {
+			return err
+		}
+	}
+
+	if n.lower == nil {
+		return errors.Errorf("%v does not exist", rel)
+	}
+
+	return n.store.materialize(ctx, rel, n.lower)
+}
+
+// lowerChild returns the snapshot entry for a child with the provided name or nil if it does not exist or has been deleted.
+func (n *overlayNode) lowerChild(ctx context.Context, name string) (fs.Entry, error) {
+	d := n.lowerDir()
+	if d == nil || n.store.isOpaque(n.rel()) || n.store.isDeleted(path.Join(n.rel(), name)) {
+		return nil, nil
+	}
+
+	e, err := d.Child(ctx, name)
+	if errors.Is(err, fs.ErrEntryNotFound) || os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	//nolint:wrapcheck
+	return e, err
+}
+
+func (n *overlayNode) fillAttr(a *fuse.Attr) syscall.Errno {
+	var st syscall.Stat_t
+
+	if err := syscall.Lstat(n.store.path(n.rel()), &st); err == nil {
+		a.FromStat(&st)
+	} else {
+		if n.lower == nil {
+			return syscall.ENOENT
+		}
+
+		populateAttributes(a, n.lower)
+	}
+
+	a.Ino = n.StableAttr().Ino
+
+	return gofusefs.OK
+}
+
+func (n *overlayNode) Getattr(ctx context.Context, fh gofusefs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if fga, ok := fh.(gofusefs.FileGetattrer); ok {
+		return fga.Getattr(ctx, out)
+	}
+
+	return n.fillAttr(&out.Attr)
+}
+
+func (n *overlayNode) Setattr(ctx context.Context, fh gofusefs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.copyUp(ctx); err != nil {
+		log(ctx).Errorf("unable to copy %v to scratch directory: %v", n.rel(), err)
+		return syscall.EIO
+	}
+
+	p := n.store.path(n.rel())
+
+	if m, ok := in.GetMode(); ok {
+		if err := syscall.Chmod(p, m); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	uid, uok := in.GetUID()
+	gid, gok := in.GetGID()
+
+	if uok || gok {
+		u, g := -1, -1
+		if uok {
+			u = int(uid)
+		}
+
+		if gok {
+			g = int(gid)
+		}
+
+		if err := syscall.Lchown(p, u, g); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	if sz, ok := in.GetSize(); ok {
+		if err := syscall.Truncate(p, int64(sz)); err != nil { //nolint:gosec
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	mtime, mok := in.GetMTime()
+	atime, aok := in.GetATime()
+
+	if mok || aok {
+		st, err := os.Lstat(p)
+		if err != nil {
+			return gofusefs.ToErrno(err)
+		}
+
+		if !mok {
+			mtime = st.ModTime()
+		}
+
+		if !aok {
+			atime = clock.Now()
+		}
+
+		if err := os.Chtimes(p, atime, mtime); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	return n.fillAttr(&out.Attr)
+}
+
+func (n *overlayNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*gofusefs.Inode, syscall.Errno) {
+	if strings.HasPrefix(name, whiteoutPrefix) {
+		return nil, syscall.ENOENT
+	}
+
+	lower, err := n.lowerChild(ctx, name)
+	if err != nil {
+		log(ctx).Errorf("lookup error %v in %v: %v", name, n.rel(), err)
+		return nil, syscall.EIO
+	}
+
+	var (
+		st   syscall.Stat_t
+		mode uint32
+	)
+
+	switch {
+	case syscall.Lstat(n.store.path(path.Join(n.rel(), name)), &st) == nil:
+		out.Attr.FromStat(&st)
+		mode = uint32(st.Mode) & syscall.S_IFMT //nolint:unconvert
+
+	case lower != nil:
+		populateAttributes(&out.Attr, lower)
+		mode = entryToFuseMode(lower)
+
+	default:
+		return nil, syscall.ENOENT
+	}
+
+	return n.NewInode(ctx, n.newChild(lower), gofusefs.StableAttr{Mode: mode}), gofusefs.OK
+}
+
+func (n *overlayNode) Readdir(ctx context.Context) (gofusefs.DirStream, syscall.Errno) {
+	names, err := n.store.mergedEntryNames(ctx, n.rel(), n.lowerDir())
+	if err != nil {
+		log(ctx).Errorf("error reading directory %v: %v", n.rel(), err)
+		return nil, syscall.EIO
+	}
+
+	result := make([]fuse.DirEntry, 0, len(names))
+
+	for _, name := range names {
+		var st syscall.Stat_t
+
+		mode := uint32(fuse.S_IFREG)
+
+		if syscall.Lstat(n.store.path(path.Join(n.rel(), name)), &st) == nil {
+			mode = uint32(st.Mode) & syscall.S_IFMT //nolint:unconvert
+		} else if lower, err := n.lowerChild(ctx, name); err == nil && lower != nil {
+			mode = entryToFuseMode(lower)
+		}
+
+		result = append(result, fuse.DirEntry{Name: name, Mode: mode})
+	}
+
+	return gofusefs.NewListDirStream(result), gofusefs.OK
+}
+
+func (n *overlayNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	if n.store.exists(n.rel()) {
+		v, err := os.Readlink(n.store.path(n.rel()))
+		if err != nil {
+			return nil, gofusefs.ToErrno(err)
+		}
+
+		return []byte(v), gofusefs.OK
+	}
+
+	sl, ok := n.lower.(fs.Symlink)
+	if !ok {
+		return nil, syscall.EINVAL
+	}
+
+	v, err := sl.Readlink(ctx)
+	if err != nil {
+		log(ctx).Errorf("error reading symlink %v: %v", n.rel(), err)
+		return nil, syscall.EIO
+	}
+
+	return []byte(v), gofusefs.OK
+}
+
+func (n *overlayNode) Open(ctx context.Context, flags uint32) (gofusefs.FileHandle, uint32, syscall.Errno) {
+	if flags&writeOpenFlags != 0 {
+		n.mu.Lock()
+		err := n.copyUp(ctx)
+		n.mu.Unlock()
+
+		if err != nil {
+			log(ctx).Errorf("unable to copy %v to scratch directory: %v", n.rel(), err)
+			return nil, 0, syscall.EIO
+		}
+	}
+
+	if n.store.exists(n.rel()) {
+		fd, err := syscall.Open(n.store.path(n.rel()), int(flags)&^syscall.O_CREAT, 0)
+		if err != nil {
+			return nil, 0, gofusefs.ToErrno(err)
+		}
+
+		return gofusefs.NewLoopbackFile(fd), 0, gofusefs.OK
+	}
+
+	f, ok := n.lower.(fs.File)
+	if !ok {
+		return nil, 0, syscall.EISDIR
+	}
+
+	reader, err := f.Open(ctx)
+	if err != nil {
+		log(ctx).Errorf("error opening %v: %v", n.rel(), err)
+		return nil, 0, syscall.EIO
+	}
+
+	return &fuseFileHandle{reader: reader, file: f}, 0, gofusefs.OK
+}
+
+// prepareChild ensures the directory is stored in the scratch directory and removes whiteout of the child,
+// returning true if the child has previously been deleted.
+func (n *overlayNode) prepareChild(ctx context.Context, name string) (bool, syscall.Errno) {
+	if strings.HasPrefix(name, whiteoutPrefix) {
+		return false, syscall.EPERM
+	}
+
+	if err := n.copyUp(ctx); err != nil {
+		log(ctx).Errorf("unable to copy %v to scratch directory: %v", n.rel(), err)
+		return false, syscall.EIO
+	}
+
+	wasDeleted, err := n.store.clearDeleted(path.Join(n.rel(), name))
+	if err != nil {
+		log(ctx).Errorf("unable to undelete %v in %v: %v", name, n.rel(), err)
+		return false, syscall.EIO
+	}
+
+	return wasDeleted, gofusefs.OK
+}
+
+// newCreatedChild returns inode for an entry created in the scratch directory.
+func (n *overlayNode) newCreatedChild(ctx context.Context, p string, out *fuse.EntryOut) (*gofusefs.Inode, syscall.Errno) {
+	if caller, ok := fuse.FromContext(ctx); ok {
+		// ownership can only be changed when running as root.
+		syscall.Lchown(p, int(caller.Uid), int(caller.Gid)) //nolint:errcheck
+	}
+
+	var st syscall.Stat_t
+
+	if err := syscall.Lstat(p, &st); err != nil {
+		return nil, gofusefs.ToErrno(err)
+	}
+
+	out.Attr.FromStat(&st)
+
+	return n.NewInode(ctx, n.newChild(nil), gofusefs.StableAttr{Mode: uint32(st.Mode) & syscall.S_IFMT}), gofusefs.OK //nolint:unconvert
+}
+
+func (n *overlayNode) Create(ctx context.Context, name string, flags, mode uint32, out *fuse.EntryOut) (*gofusefs.Inode, gofusefs.FileHandle, uint32, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, errno := n.prepareChild(ctx, name); errno != gofusefs.OK {
+		return nil, nil, 0, errno
+	}
+
+	p := n.store.path(path.Join(n.rel(), name))
+
+	fd, err := syscall.Open(p, int(flags)|syscall.O_CREAT, mode)
+	if err != nil {
+		return nil, nil, 0, gofusefs.ToErrno(err)
+	}
+
+	child, errno := n.newCreatedChild(ctx, p, out)
+	if errno != gofusefs.OK {
+		syscall.Close(fd) //nolint:errcheck
+		return nil, nil, 0, errno
+	}
+
+	return child, gofusefs.NewLoopbackFile(fd), 0, gofusefs.OK
+}
+
+func (n *overlayNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*gofusefs.Inode, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wasDeleted, errno := n.prepareChild(ctx, name)
+	if errno != gofusefs.OK {
+		return nil, errno
+	}
+
+	rel := path.Join(n.rel(), name)
+	p := n.store.path(rel)
+
+	if err := syscall.Mkdir(p, mode); err != nil {
+		return nil, gofusefs.ToErrno(err)
+	}
+
+	if wasDeleted {
+		// new directory replaces the deleted one, do not show its previous contents.
+		if err := n.store.markOpaque(rel); err != nil {
+			return nil, gofusefs.ToErrno(err)
+		}
+	}
+
+	return n.newCreatedChild(ctx, p, out)
+}
+
+func (n *overlayNode) Symlink(ctx context.Context, target, name string, out *fuse.EntryOut) (*gofusefs.Inode, syscall.Errno) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, errno := n.prepareChild(ctx, name); errno != gofusefs.OK {
+		return nil, errno
+	}
+
+	p := n.store.path(path.Join(n.rel(), name))
+
+	if err := os.Symlink(target, p); err != nil {
+		return nil, gofusefs.ToErrno(err)
+	}
+
+	return n.newCreatedChild(ctx, p, out)
+}
+
+func (n *overlayNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.remove(ctx, name, false)
+}
+
+func (n *overlayNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.remove(ctx, name, true)
+}
+
+// remove deletes the child entry, removing it from the scratch directory and hiding the snapshot entry.
+func (n *overlayNode) remove(ctx context.Context, name string, isDir bool) syscall.Errno {
+	rel := path.Join(n.rel(), name)
+
+	lower, err := n.lowerChild(ctx, name)
+	if err != nil {
+		log(ctx).Errorf("lookup error %v in %v: %v", name, n.rel(), err)
+		return syscall.EIO
+	}
+
+	st, err := n.store.stat(rel)
+	if err != nil {
+		return gofusefs.ToErrno(err)
+	}
+
+	if errno := n.checkRemovable(ctx, rel, st, lower, isDir); errno != gofusefs.OK {
+		return errno
+	}
+
+	if st != nil {
+		if err := os.RemoveAll(n.store.path(rel)); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	if lower != nil {
+		if err := n.copyUp(ctx); err != nil {
+			log(ctx).Errorf("unable to copy %v to scratch directory: %v", n.rel(), err)
+			return syscall.EIO
+		}
+
+		if err := n.store.markDeleted(rel); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	return gofusefs.OK
+}
+
+// checkRemovable verifies that an entry with the provided scratch and snapshot state can be removed by unlink or rmdir.
+func (n *overlayNode) checkRemovable(ctx context.Context, rel string, st os.FileInfo, lower fs.Entry, isDir bool) syscall.Errno {
+	var entryIsDir bool
+
+	switch {
+	case st != nil:
+		entryIsDir = st.IsDir()
+	case lower != nil:
+		entryIsDir = lower.IsDir()
+	default:
+		return syscall.ENOENT
+	}
+
+	if !isDir {
+		if entryIsDir {
+			return syscall.EISDIR
+		}
+
+		return gofusefs.OK
+	}
+
+	if !entryIsDir {
+		return syscall.ENOTDIR
+	}
+
+	lowerDir, _ := lower.(fs.Directory)
+
+	names, err := n.store.mergedEntryNames(ctx, rel, lowerDir)
+	if err != nil {
+		log(ctx).Errorf("error reading directory %v: %v", rel, err)
+		return syscall.EIO
+	}
+
+	if len(names) > 0 {
+		return syscall.ENOTEMPTY
+	}
+
+	return gofusefs.OK
+}
+
+func (n *overlayNode) Rename(ctx context.Context, name string, newParent gofusefs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags != 0 {
+		return syscall.ENOTSUP
+	}
+
+	np, ok := newParent.(*overlayNode)
+	if !ok {
+		return syscall.EXDEV
+	}
+
+	if strings.HasPrefix(newName, whiteoutPrefix) {
+		return syscall.EPERM
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	srcRel := path.Join(n.rel(), name)
+	dstRel := path.Join(np.rel(), newName)
+
+	srcLower, err := n.lowerChild(ctx, name)
+	if err != nil {
+		log(ctx).Errorf("lookup error %v in %v: %v", name, n.rel(), err)
+		return syscall.EIO
+	}
+
+	dstLower, err := np.lowerChild(ctx, newName)
+	if err != nil {
+		log(ctx).Errorf("lookup error %v in %v: %v", newName, np.rel(), err)
+		return syscall.EIO
+	}
+
+	if err := n.copyUp(ctx); err != nil {
+		log(ctx).Errorf("unable to copy %v to scratch directory: %v", n.rel(), err)
+		return syscall.EIO
+	}
+
+	// the source will no longer be visible under its original path, copy it with all its contents.
+	if srcLower != nil {
+		if err := n.store.materializeTree(ctx, srcRel, srcLower); err != nil {
+			log(ctx).Errorf("unable to copy %v to scratch directory: %v", srcRel, err)
+			return syscall.EIO
+		}
+	}
+
+	srcSt, err := n.store.stat(srcRel)
+	if err != nil || srcSt == nil {
+		return syscall.ENOENT
+	}
+
+	if err := np.copyUp(ctx); err != nil {
+		log(ctx).Errorf("unable to copy %v to scratch directory: %v", np.rel(), err)
+		return syscall.EIO
+	}
+
+	if errno := np.replaceDestination(ctx, dstRel, dstLower, srcSt.IsDir()); errno != gofusefs.OK {
+		return errno
+	}
+
+	if err := os.Rename(n.store.path(srcRel), n.store.path(dstRel)); err != nil {
+		return gofusefs.ToErrno(err)
+	}
+
+	if _, err := n.store.clearDeleted(dstRel); err != nil {
+		return gofusefs.ToErrno(err)
+	}
+
+	if dstLower != nil && srcSt.IsDir() {
+		// do not merge the renamed directory with the snapshot directory it replaced.
+		if err := n.store.markOpaque(dstRel); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	if srcLower != nil {
+		if err := n.store.markDeleted(srcRel); err != nil {
+			return gofusefs.ToErrno(err)
+		}
+	}
+
+	return gofusefs.OK
+}
+
+// replaceDestination removes the existing destination of a rename from the scratch directory
+// after verifying that it can be replaced.
+func (n *overlayNode) replaceDestination(ctx context.Context, dstRel string, dstLower fs.Entry, srcIsDir bool) syscall.Errno {
+	dstSt, err := n.store.stat(dstRel)
+	if err != nil {
+		return gofusefs.ToErrno(err)
+	}
+
+	if dstSt == nil && dstLower == nil {
+		return gofusefs.OK
+	}
+
+	if errno := n.checkRemovable(ctx, dstRel, dstSt, dstLower, srcIsDir); errno != gofusefs.OK {
+		return errno
+	}
+
+	if dstSt != nil {
+		return gofusefs.ToErrno(os.RemoveAll(n.store.path(dstRel)))
+	}
+
+	return gofusefs.OK
+}
+
+// NewOverlayDirectoryNode returns FUSE Node for a given fs.Directory that allows modifications,
+// which are stored in the provided scratch directory and never written to the repository.
+// Changes stored in the scratch directory are preserved and applied again when it is reused.
+func NewOverlayDirectoryNode(dir fs.Directory, scratchDir string) (gofusefs.InodeEmbedder, error) {
+	if err := os.MkdirAll(scratchDir, 0o700); err != nil { //nolint:mnd
+		return nil, errors.Wrap(err, "unable to create scratch directory")
+	}
+
+	return &overlayNode{
+		store: &overlayStore{dir: scratchDir},
+		mu:    &sync.Mutex{},
+		lower: dir,
+	}, nil
+}
+
+var (
+	_ gofusefs.NodeGetattrer  = (*overlayNode)(nil)
+	_ gofusefs.NodeSetattrer  = (*overlayNode)(nil)
+	_ gofusefs.NodeLookuper   = (*overlayNode)(nil)
+	_ gofusefs.NodeReaddirer  = (*overlayNode)(nil)
+	_ gofusefs.NodeReadlinker = (*overlayNode)(nil)
+	_ gofusefs.NodeOpener     = (*overlayNode)(nil)
+	_ gofusefs.NodeCreater    = (*overlayNode)(nil)
+	_ gofusefs.NodeMkdirer    = (*overlayNode)(nil)
+	_ gofusefs.NodeSymlinker  = (*overlayNode)(nil)
+	_ gofusefs.NodeUnlinker   = (*overlayNode)(nil)
+	_ gofusefs.NodeRmdirer    = (*overlayNode)(nil)
+	_ gofusefs.NodeRenamer    = (*overlayNode)(nil)
+)
@@ -0,0 +1,246 @@
+//go:build !windows && !openbsd && !freebsd
+// +build !windows,!openbsd,!freebsd
+
+package fusemount
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	// whiteoutPrefix is the prefix of marker files representing deleted entries, same as used by overlayfs and aufs.
+	whiteoutPrefix = ".wh."
+
+	// opaqueMarker is the name of a marker file indicating that contents of the directory are not merged with the snapshot.
+	opaqueMarker = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// overlayStore keeps changes made to a read-only directory tree in a scratch directory.
+//
+// Files and directories that have been created or modified are stored in the scratch directory
+// under their relative paths, deleted entries are represented by whiteout markers.
+type overlayStore struct {
+	dir string
+}
+
+func (s *overlayStore) path(rel string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(rel))
+}
+
+func (s *overlayStore) whiteoutPath(rel string) string {
+	dir, name := filepath.Split(s.path(rel))
+	return filepath.Join(dir, whiteoutPrefix+name)
+}
+
+// stat returns information about the modified entry or nil if the entry has not been modified.
+func (s *overlayStore) stat(rel string) (os.FileInfo, error) {
+	st, err := os.Lstat(s.path(rel))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	//nolint:wrapcheck
+	return st, err
+}
+
+func (s *overlayStore) exists(rel string) bool {
+	_, err := os.Lstat(s.path(rel))
+	return err == nil
+}
+
+func (s *overlayStore) isDeleted(rel string) bool {
+	_, err := os.Lstat(s.whiteoutPath(rel))
+	return err == nil
+}
+
+func (s *overlayStore) isOpaque(rel string) bool {
+	_, err := os.Lstat(filepath.Join(s.path(rel), opaqueMarker))
+	return err == nil
+}
+
+func (s *overlayStore) markDeleted(rel string) error {
+	//nolint:wrapcheck
+	return os.WriteFile(s.whiteoutPath(rel), nil, 0o600)
+}
+
+// clearDeleted removes the whiteout marker of the provided entry and returns true if it existed.
+func (s *overlayStore) clearDeleted(rel string) (bool, error) {
+	err := os.Remove(s.whiteoutPath(rel))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrap(err, "unable to remove whiteout")
+	}
+
+	return true, nil
+}
+
+func (s *overlayStore) markOpaque(rel string) error {
+	//nolint:wrapcheck
+	return os.WriteFile(filepath.Join(s.path(rel), opaqueMarker), nil, 0o600)
+}
+
+// materialize stores a copy of the provided snapshot entry under the provided path. Parent directory must already exist.
+// Directories are copied without their contents.
+func (s *overlayStore) materialize(ctx context.Context, rel string, e fs.Entry) error {
+	p := s.path(rel)
+
+	switch e := e.(type) {
+	case fs.Directory:
+		if err := os.Mkdir(p, e.Mode().Perm()|0o700); err != nil { //nolint:mnd
+			return errors.Wrap(err, "unable to create directory")
+		}
+
+	case fs.Symlink:
+		target, err := e.Readlink(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to read symlink")
+		}
+
+		if err := os.Symlink(target, p); err != nil {
+			return errors.Wrap(err, "unable to create symlink")
+		}
+
+		return nil
+
+	case fs.File:
+		if err := copyFileContents(ctx, p, e); err != nil {
+			return err
+		}
+
+	default:
+		return errors.Errorf("unsupported entry type: %v", e.Mode())
+	}
+
+	if err := os.Chmod(p, e.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return errors.Wrap(err, "unable to set permissions")
+	}
+
+	// ownership can only be preserved when running as root.
+	os.Lchown(p, int(e.Owner().UserID), int(e.Owner().GroupID)) //nolint:errcheck
+
+	return errors.Wrap(os.Chtimes(p, e.ModTime(), e.ModTime()), "unable to set modification time")
+}
+
+// materializeTree stores a copy of the provided snapshot entry including all its contents.
+func (s *overlayStore) materializeTree(ctx context.Context, rel string, e fs.Entry) error {
+	st, err := s.stat(rel)
+	if err != nil {
+		return errors.Wrap(err, "unable to stat scratch entry")
+	}
+
+	if st == nil {
+		if err := s.materialize(ctx, rel, e); err != nil {
+			return err
+		}
+	} else if !st.IsDir() {
+		// snapshot entry has been replaced.
+		return nil
+	}
+
+	dir, ok := e.(fs.Directory)
+	if !ok || s.isOpaque(rel) {
+		return nil
+	}
+
+	if err := fs.IterateEntries(ctx, dir, func(ctx context.Context, child fs.Entry) error {
+		childRel := path.Join(rel, child.Name())
+		if s.isDeleted(childRel) {
+			return nil
+		}
+
+		return s.materializeTree(ctx, childRel, child)
+	}); err != nil {
+		return errors.Wrapf(err, "unable to copy contents of %v", rel)
+	}
+
+	// all contents are now in the scratch directory.
+	return s.markOpaque(rel)
+}
+
+func copyFileContents(ctx context.Context, dst string, f fs.File) error {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to open snapshot file")
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	// temporary file name is hidden from the merged view.
+	w, err := os.CreateTemp(filepath.Dir(dst), whiteoutPrefix+"tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create file")
+	}
+
+	tmp := w.Name()
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()      //nolint:errcheck
+		os.Remove(tmp) //nolint:errcheck
+
+		return errors.Wrap(err, "unable to copy file contents")
+	}
+
+	if err := w.Close(); err != nil {
+		os.Remove(tmp) //nolint:errcheck
+
+		return errors.Wrap(err, "unable to close file")
+	}
+
+	return errors.Wrap(os.Rename(tmp, dst), "unable to rename file")
+}
+
+// mergedEntryNames returns sorted names of entries of the provided directory, combining modified entries
+// with entries of the snapshot directory (if any) that have not been deleted.
+func (s *overlayStore) mergedEntryNames(ctx context.Context, rel string, lower fs.Directory) ([]string, error) {
+	names := map[string]bool{}
+
+	if lower != nil && !s.isOpaque(rel) {
+		if err := fs.IterateEntries(ctx, lower, func(_ context.Context, e fs.Entry) error {
+			names[e.Name()] = true
+			return nil
+		}); err != nil {
+			return nil, errors.Wrap(err, "unable to read snapshot directory")
+		}
+	}
+
+	upper, err := os.ReadDir(s.path(rel))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "unable to read scratch directory")
+	}
+
+	for _, de := range upper {
+		if deleted, ok := strings.CutPrefix(de.Name(), whiteoutPrefix); ok {
+			if de.Name() != opaqueMarker {
+				delete(names, deleted)
+			}
+		}
+	}
+
+	for _, de := range upper {
+		if !strings.HasPrefix(de.Name(), whiteoutPrefix) {
+			names[de.Name()] = true
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for n := range names {
+		result = append(result, n)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
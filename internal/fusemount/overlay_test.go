@@ -0,0 +1,177 @@
+//go:build linux
+// +build linux
+
+package fusemount
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	gofusefs "github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func newTestSnapshotDirectory() *mockfs.Directory {
+	root := mockfs.NewDirectory()
+	root.AddFile("file1", []byte("contents1"), 0o644)
+	root.AddSymlink("link1", "file1", 0o777)
+
+	d := root.AddDir("dir1", 0o755)
+	d.AddFile("file2", []byte("contents2"), 0o600)
+	d.AddDir("subdir", 0o700).AddFile("file3", []byte("contents3"), 0o644)
+
+	return root
+}
+
+func TestOverlayStore(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := newTestSnapshotDirectory()
+	s := &overlayStore{dir: testutil.TempDirectory(t)}
+
+	names, err := s.mergedEntryNames(ctx, "", root)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir1", "file1", "link1"}, names)
+
+	// delete a file and add a new one.
+	require.NoError(t, s.markDeleted("file1"))
+	require.NoError(t, os.WriteFile(s.path("new"), []byte("x"), 0o600))
+
+	names, err = s.mergedEntryNames(ctx, "", root)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dir1", "link1", "new"}, names)
+	require.True(t, s.isDeleted("file1"))
+
+	wasDeleted, err := s.clearDeleted("file1")
+	require.NoError(t, err)
+	require.True(t, wasDeleted)
+
+	// copy a directory with all its contents.
+	dir1 := root.Subdir("dir1")
+	require.NoError(t, s.materializeTree(ctx, "dir1", dir1))
+	require.True(t, s.isOpaque("dir1"))
+
+	data, err := os.ReadFile(s.path("dir1/subdir/file3"))
+	require.NoError(t, err)
+	require.Equal(t, "contents3", string(data))
+
+	st, err := os.Stat(s.path("dir1/file2"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), st.Mode().Perm())
+	require.True(t, mockfs.DefaultModTime.Equal(st.ModTime()))
+
+	// contents of opaque directories are not merged with the snapshot.
+	require.NoError(t, os.Remove(s.path("dir1/file2")))
+
+	names, err = s.mergedEntryNames(ctx, "dir1", dir1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"subdir"}, names)
+}
+
+// mountOverlay mounts a writable overlay of the provided directory and returns paths of the mount point and scratch directory.
+func mountOverlay(t *testing.T, dir *mockfs.Directory) (mountPoint, scratchDir string) {
+	t.Helper()
+
+	mountPoint = testutil.TempDirectory(t)
+	scratchDir = filepath.Join(testutil.TempDirectory(t), "scratch")
+
+	root, err := NewOverlayDirectoryNode(dir, scratchDir)
+	require.NoError(t, err)
+
+	server, err := gofusefs.Mount(mountPoint, root, &gofusefs.Options{
+		MountOptions: fuse.MountOptions{DirectMount: true},
+	})
+	if err != nil {
+		t.Skipf("FUSE is not available: %v", err)
+	}
+
+	t.Cleanup(func() {
+		require.NoError(t, server.Unmount())
+	})
+
+	return mountPoint, scratchDir
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func TestOverlayMount(t *testing.T) {
+	root := newTestSnapshotDirectory()
+	mnt, scratch := mountOverlay(t, root)
+
+	require.Equal(t, []string{"dir1", "file1", "link1"}, listDir(t, mnt))
+
+	// modify existing file.
+	f, err := os.OpenFile(filepath.Join(mnt, "dir1", "file2"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+
+	_, err = f.WriteString("-modified")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(filepath.Join(mnt, "dir1", "file2"))
+	require.NoError(t, err)
+	require.Equal(t, "contents2-modified", string(data))
+
+	// create new files and directories.
+	require.NoError(t, os.Mkdir(filepath.Join(mnt, "newdir"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(mnt, "newdir", "newfile"), []byte("new"), 0o644))
+	require.NoError(t, os.Symlink("newfile", filepath.Join(mnt, "newdir", "newlink")))
+
+	target, err := os.Readlink(filepath.Join(mnt, "newdir", "newlink"))
+	require.NoError(t, err)
+	require.Equal(t, "newfile", target)
+
+	// delete snapshot entries.
+	require.NoError(t, os.Remove(filepath.Join(mnt, "file1")))
+	require.Error(t, os.Remove(filepath.Join(mnt, "dir1", "subdir")))
+	require.NoError(t, os.RemoveAll(filepath.Join(mnt, "dir1", "subdir")))
+
+	require.Equal(t, []string{"dir1", "link1", "newdir"}, listDir(t, mnt))
+	require.Equal(t, []string{"file2"}, listDir(t, filepath.Join(mnt, "dir1")))
+
+	// recreating a deleted directory does not bring back its contents.
+	require.NoError(t, os.Mkdir(filepath.Join(mnt, "dir1", "subdir"), 0o755))
+	require.Empty(t, listDir(t, filepath.Join(mnt, "dir1", "subdir")))
+
+	// rename snapshot directory.
+	require.NoError(t, os.Rename(filepath.Join(mnt, "dir1"), filepath.Join(mnt, "renamed")))
+	require.Equal(t, []string{"link1", "newdir", "renamed"}, listDir(t, mnt))
+	require.Equal(t, []string{"file2", "subdir"}, listDir(t, filepath.Join(mnt, "renamed")))
+
+	// change attributes.
+	require.NoError(t, os.Chmod(filepath.Join(mnt, "renamed", "file2"), 0o640))
+
+	st, err := os.Stat(filepath.Join(mnt, "renamed", "file2"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o640), st.Mode().Perm())
+
+	// snapshot itself is unchanged, all changes are in the scratch directory.
+	entries, err := fs.GetAllEntries(testlogging.Context(t), root.Subdir("dir1"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.FileExists(t, filepath.Join(scratch, "newdir", "newfile"))
+	require.FileExists(t, filepath.Join(scratch, ".wh.file1"))
+}
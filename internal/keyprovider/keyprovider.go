@@ -0,0 +1,140 @@
+// Package keyprovider retrieves repository passwords from external key storage, such as
+// OS keychains, cloud key management services or helper commands, so that they don't need to
+// be stored in plaintext in scripts or configuration files.
+package keyprovider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("keyprovider")
+
+// Config describes how to obtain the repository password. Only the fields relevant to the Type are used.
+type Config struct {
+	Type string `json:"type"`
+
+	// keyring
+	KeyringService string `json:"keyringService,omitempty"`
+	KeyringAccount string `json:"keyringAccount,omitempty"`
+
+	// file and gcp-kms (ciphertext file)
+	File string `json:"file,omitempty"`
+
+	// gcp-kms
+	KMSKeyName string `json:"kmsKeyName,omitempty"`
+
+	// command
+	Command []string `json:"command,omitempty"`
+}
+
+// Provider retrieves the repository password.
+type Provider interface {
+	GetPassword(ctx context.Context) (string, error)
+}
+
+type factory struct {
+	description string
+	specHelp    string
+	parseSpec   func(spec string) (*Config, error)
+	create      func(cfg *Config) (Provider, error)
+}
+
+//nolint:gochecknoglobals
+var factories = map[string]factory{}
+
+func registerProvider(typ string, f factory) {
+	if _, ok := factories[typ]; ok {
+		panic(fmt.Sprintf("key provider (%s) is already registered", typ))
+	}
+
+	factories[typ] = f
+}
+
+// SupportedTypes returns the list of supported key provider types.
+func SupportedTypes() []string {
+	var result []string
+
+	for k := range factories {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// SpecHelp returns a human-readable description of supported key provider specifications.
+func SpecHelp() string {
+	var lines []string
+
+	for _, t := range SupportedTypes() {
+		lines = append(lines, fmt.Sprintf("%v:%v (%v)", t, factories[t].specHelp, factories[t].description))
+	}
+
+	return strings.Join(lines, ", ")
+}
+
+// ParseSpec parses a key provider specification in the form 'type:parameters'.
+func ParseSpec(spec string) (*Config, error) {
+	typ, params, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, errors.Errorf("invalid key provider %q, expected 'type:parameters'", spec)
+	}
+
+	f, ok := factories[typ]
+	if !ok {
+		return nil, errors.Errorf("unsupported key provider type %q, supported types: %v", typ, SupportedTypes())
+	}
+
+	cfg, err := f.parseSpec(params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %v key provider", typ)
+	}
+
+	cfg.Type = typ
+
+	return cfg, nil
+}
+
+// Create creates a key provider for the provided configuration.
+func Create(cfg *Config) (Provider, error) {
+	if cfg == nil {
+		return nil, errors.New("missing key provider configuration")
+	}
+
+	f, ok := factories[cfg.Type]
+	if !ok {
+		return nil, errors.Errorf("unsupported key provider type %q, supported types: %v", cfg.Type, SupportedTypes())
+	}
+
+	return f.create(cfg)
+}
+
+// GetPassword retrieves the repository password using the key provider with the provided configuration.
+func GetPassword(ctx context.Context, cfg *Config) (string, error) {
+	p, err := Create(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	pass, err := p.GetPassword(ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get password from %v key provider", cfg.Type)
+	}
+
+	pass = strings.TrimSpace(pass)
+	if pass == "" {
+		return "", errors.Errorf("%v key provider returned an empty password", cfg.Type)
+	}
+
+	log(ctx).Debugf("password retrieved from %v key provider", cfg.Type)
+
+	return pass, nil
+}
@@ -0,0 +1,54 @@
+package keyprovider
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TypeCommand runs a helper command and uses its standard output as the password.
+// This can be used to integrate with TPM tools, cloud KMS command-line tools, password managers, etc.
+const TypeCommand = "command"
+
+func init() {
+	registerProvider(TypeCommand, factory{
+		description: "standard output of a helper command, e.g. 'tpm2_unseal' or 'aws kms decrypt'",
+		specHelp:    "COMMAND [ARGS...]",
+		parseSpec: func(spec string) (*Config, error) {
+			args := strings.Fields(spec)
+			if len(args) == 0 {
+				return nil, errors.New("missing command")
+			}
+
+			return &Config{Command: args}, nil
+		},
+		create: func(cfg *Config) (Provider, error) {
+			if len(cfg.Command) == 0 {
+				return nil, errors.New("missing command")
+			}
+
+			return commandProvider{cfg.Command}, nil
+		},
+	})
+}
+
+type commandProvider struct {
+	args []string
+}
+
+func (p commandProvider) GetPassword(ctx context.Context) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, p.args[0], p.args[1:]...) //nolint:gosec
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "error running %v: %v", p.args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,45 @@
+package keyprovider
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// TypeFile reads the password from a file, such as one provided by systemd credentials or a secret mount.
+const TypeFile = "file"
+
+func init() {
+	registerProvider(TypeFile, factory{
+		description: "password stored in a file, such as a secret mount",
+		specHelp:    "PATH",
+		parseSpec: func(spec string) (*Config, error) {
+			if spec == "" {
+				return nil, errors.New("missing file name")
+			}
+
+			return &Config{File: spec}, nil
+		},
+		create: func(cfg *Config) (Provider, error) {
+			if cfg.File == "" {
+				return nil, errors.New("missing file name")
+			}
+
+			return fileProvider{cfg.File}, nil
+		},
+	})
+}
+
+type fileProvider struct {
+	fname string
+}
+
+func (p fileProvider) GetPassword(ctx context.Context) (string, error) {
+	b, err := os.ReadFile(p.fname)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read password file")
+	}
+
+	return string(b), nil
+}
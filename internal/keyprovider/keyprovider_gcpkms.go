@@ -0,0 +1,119 @@
+package keyprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+)
+
+// TypeGCPKMS decrypts a password ciphertext file using Google Cloud KMS
+// with application default credentials.
+const TypeGCPKMS = "gcp-kms"
+
+const (
+	gcpKMSScope         = "https://www.googleapis.com/auth/cloudkms"
+	maxKMSResponseBytes = 1 << 20
+)
+
+//nolint:gochecknoglobals
+var (
+	gcpKMSEndpoint   = "https://cloudkms.googleapis.com"
+	gcpKMSHTTPClient = func(ctx context.Context) (*http.Client, error) {
+		//nolint:wrapcheck
+		return google.DefaultClient(ctx, gcpKMSScope)
+	}
+)
+
+func init() {
+	registerProvider(TypeGCPKMS, factory{
+		description: "ciphertext file decrypted using Google Cloud KMS",
+		specHelp:    "projects/P/locations/L/keyRings/R/cryptoKeys/K,CIPHERTEXT-FILE",
+		parseSpec: func(spec string) (*Config, error) {
+			keyName, fname, ok := strings.Cut(spec, ",")
+			if !ok || keyName == "" || fname == "" {
+				return nil, errors.New("expected KEY-NAME,CIPHERTEXT-FILE")
+			}
+
+			return &Config{KMSKeyName: keyName, File: fname}, nil
+		},
+		create: func(cfg *Config) (Provider, error) {
+			if cfg.KMSKeyName == "" || cfg.File == "" {
+				return nil, errors.New("missing key name or ciphertext file")
+			}
+
+			return gcpKMSProvider{cfg.KMSKeyName, cfg.File}, nil
+		},
+	})
+}
+
+type gcpKMSProvider struct {
+	keyName string
+	fname   string
+}
+
+type gcpKMSDecryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type gcpKMSDecryptResponse struct {
+	Plaintext string `json:"plaintext"`
+}
+
+func (p gcpKMSProvider) GetPassword(ctx context.Context) (string, error) {
+	ciphertext, err := os.ReadFile(p.fname)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read ciphertext file")
+	}
+
+	reqBody, err := json.Marshal(gcpKMSDecryptRequest{Ciphertext: base64.StdEncoding.EncodeToString(ciphertext)})
+	if err != nil {
+		return "", errors.Wrap(err, "unable to marshal request")
+	}
+
+	cli, err := gcpKMSHTTPClient(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get Google Cloud credentials")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gcpKMSEndpoint+"/v1/"+p.keyName+":decrypt", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "error calling Cloud KMS")
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxKMSResponseBytes))
+	if err != nil {
+		return "", errors.Wrap(err, "error reading Cloud KMS response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("Cloud KMS returned %v: %v", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var dr gcpKMSDecryptResponse
+	if err := json.Unmarshal(respBody, &dr); err != nil {
+		return "", errors.Wrap(err, "invalid Cloud KMS response")
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(dr.Plaintext)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid plaintext in Cloud KMS response")
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,55 @@
+package keyprovider
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/zalando/go-keyring"
+)
+
+// TypeKeyring retrieves the password from the OS-specific keyring:
+// macOS Keychain, Windows Credential Manager or Linux Secret Service.
+const TypeKeyring = "keyring"
+
+const defaultKeyringAccount = "kopia"
+
+func init() {
+	registerProvider(TypeKeyring, factory{
+		description: "macOS Keychain, Windows Credential Manager or Linux Secret Service",
+		specHelp:    "SERVICE[/ACCOUNT]",
+		parseSpec: func(spec string) (*Config, error) {
+			service, account, _ := strings.Cut(spec, "/")
+			if service == "" {
+				return nil, errors.New("missing keyring service name")
+			}
+
+			if account == "" {
+				account = defaultKeyringAccount
+			}
+
+			return &Config{KeyringService: service, KeyringAccount: account}, nil
+		},
+		create: func(cfg *Config) (Provider, error) {
+			if cfg.KeyringService == "" {
+				return nil, errors.New("missing keyring service name")
+			}
+
+			return keyringProvider{cfg.KeyringService, cfg.KeyringAccount}, nil
+		},
+	})
+}
+
+type keyringProvider struct {
+	service string
+	account string
+}
+
+func (p keyringProvider) GetPassword(ctx context.Context) (string, error) {
+	pass, err := keyring.Get(p.service, p.account)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get keyring item %v/%v", p.service, p.account)
+	}
+
+	return pass, nil
+}
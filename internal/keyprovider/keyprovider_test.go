@@ -0,0 +1,147 @@
+package keyprovider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestParseSpec(t *testing.T) {
+	cases := []struct {
+		spec    string
+		want    *Config
+		wantErr bool
+	}{
+		{"keyring:kopia-repo", &Config{Type: TypeKeyring, KeyringService: "kopia-repo", KeyringAccount: defaultKeyringAccount}, false},
+		{"keyring:kopia-repo/bob", &Config{Type: TypeKeyring, KeyringService: "kopia-repo", KeyringAccount: "bob"}, false},
+		{"file:/run/secrets/pass", &Config{Type: TypeFile, File: "/run/secrets/pass"}, false},
+		{"command:tpm2_unseal -c 0x81000001", &Config{Type: TypeCommand, Command: []string{"tpm2_unseal", "-c", "0x81000001"}}, false},
+		{"gcp-kms:projects/p/locations/l/keyRings/r/cryptoKeys/k,/tmp/pass.enc", &Config{Type: TypeGCPKMS, KMSKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k", File: "/tmp/pass.enc"}, false},
+		{"no-colon", nil, true},
+		{"unknown:foo", nil, true},
+		{"keyring:", nil, true},
+		{"file:", nil, true},
+		{"command:", nil, true},
+		{"gcp-kms:projects/p", nil, true},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseSpec(tc.spec)
+		if tc.wantErr {
+			require.Error(t, err, tc.spec)
+			continue
+		}
+
+		require.NoError(t, err, tc.spec)
+		require.Equal(t, tc.want, got, tc.spec)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	ctx := testlogging.Context(t)
+	fname := filepath.Join(t.TempDir(), "pass")
+
+	require.NoError(t, os.WriteFile(fname, []byte("my-password\n"), 0o600))
+
+	pass, err := GetPassword(ctx, &Config{Type: TypeFile, File: fname})
+	require.NoError(t, err)
+	require.Equal(t, "my-password", pass)
+
+	require.NoError(t, os.WriteFile(fname, []byte("\n"), 0o600))
+
+	_, err = GetPassword(ctx, &Config{Type: TypeFile, File: fname})
+	require.ErrorContains(t, err, "empty password")
+
+	_, err = GetPassword(ctx, &Config{Type: TypeFile, File: filepath.Join(t.TempDir(), "missing")})
+	require.Error(t, err)
+}
+
+func TestCommandProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not supported on windows")
+	}
+
+	ctx := testlogging.Context(t)
+
+	pass, err := GetPassword(ctx, &Config{Type: TypeCommand, Command: []string{"echo", "command-password"}})
+	require.NoError(t, err)
+	require.Equal(t, "command-password", pass)
+
+	_, err = GetPassword(ctx, &Config{Type: TypeCommand, Command: []string{"false"}})
+	require.Error(t, err)
+}
+
+func TestKeyringProvider(t *testing.T) {
+	keyring.MockInit()
+
+	ctx := testlogging.Context(t)
+
+	require.NoError(t, keyring.Set("some-service", "some-account", "keyring-password"))
+
+	pass, err := GetPassword(ctx, &Config{Type: TypeKeyring, KeyringService: "some-service", KeyringAccount: "some-account"})
+	require.NoError(t, err)
+	require.Equal(t, "keyring-password", pass)
+
+	_, err = GetPassword(ctx, &Config{Type: TypeKeyring, KeyringService: "other-service", KeyringAccount: "some-account"})
+	require.Error(t, err)
+}
+
+//nolint:paralleltest
+func TestGCPKMSProvider(t *testing.T) {
+	const keyName = "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+
+	ciphertext := []byte{1, 2, 3, 4}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/"+keyName+":decrypt" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		var req gcpKMSDecryptRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Ciphertext != base64.StdEncoding.EncodeToString(ciphertext) {
+			http.Error(w, "bad ciphertext", http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(gcpKMSDecryptResponse{ //nolint:errcheck
+			Plaintext: base64.StdEncoding.EncodeToString([]byte("kms-password")),
+		})
+	}))
+	defer srv.Close()
+
+	oldEndpoint, oldClient := gcpKMSEndpoint, gcpKMSHTTPClient
+
+	defer func() {
+		gcpKMSEndpoint, gcpKMSHTTPClient = oldEndpoint, oldClient
+	}()
+
+	gcpKMSEndpoint = srv.URL
+	gcpKMSHTTPClient = func(ctx context.Context) (*http.Client, error) {
+		return srv.Client(), nil
+	}
+
+	ctx := testlogging.Context(t)
+	fname := filepath.Join(t.TempDir(), "pass.enc")
+
+	require.NoError(t, os.WriteFile(fname, ciphertext, 0o600))
+
+	pass, err := GetPassword(ctx, &Config{Type: TypeGCPKMS, KMSKeyName: keyName, File: fname})
+	require.NoError(t, err)
+	require.Equal(t, "kms-password", pass)
+
+	_, err = GetPassword(ctx, &Config{Type: TypeGCPKMS, KMSKeyName: "projects/other", File: fname})
+	require.ErrorContains(t, err, "404")
+}
@@ -0,0 +1,393 @@
+// Package leaderelection implements election of a single leader among multiple servers connected to the same
+// repository using time-limited leases stored in dedicated blobs, so that renewals don't write manifests
+// or index blobs.
+//
+// An instance becomes a candidate by writing its lease when no valid lease exists and becomes the leader
+// on the next election round if its lease is still the oldest valid one, which resolves races between
+// candidates campaigning at the same time. The leader keeps renewing its lease and other instances take over
+// after it expires. Clocks of all instances are assumed to be reasonably synchronized.
+package leaderelection
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// LeaseBlobPrefix is the prefix of blobs holding leases, one per lease holder.
+const LeaseBlobPrefix blob.ID = "_lease_"
+
+const (
+	leaseKeySize      = 32
+	leaseBlobIDLength = 16
+)
+
+//nolint:gochecknoglobals
+var (
+	leaseKeyPurpose    = []byte("server lease")
+	leaseAEADExtraData = []byte("lease")
+)
+
+// Lease describes a lease held by a server instance.
+type Lease struct {
+	Holder   string    `json:"holder"`
+	Address  string    `json:"address,omitempty"`
+	Acquired time.Time `json:"acquired"`
+	Renewed  time.Time `json:"renewed"`
+	Expires  time.Time `json:"expires"`
+}
+
+// IsValid returns true if the lease has not expired at the provided time.
+func (l *Lease) IsValid(now time.Time) bool {
+	return now.Before(l.Expires)
+}
+
+// Options provides options for the Elector.
+type Options struct {
+	Holder        string        // unique ID of this instance
+	Address       string        // address of this instance advertised to others
+	LeaseDuration time.Duration // duration of the lease, must be greater than the interval between election rounds
+
+	TimeNow func() time.Time
+}
+
+// Elector participates in leader election on behalf of a single instance.
+type Elector struct {
+	opt Options
+
+	mu sync.Mutex
+	// +checklocks:mu
+	isLeader bool
+	// +checklocks:mu
+	leader *Lease
+}
+
+// NewElector creates a new Elector.
+func NewElector(opt Options) *Elector {
+	if opt.TimeNow == nil {
+		opt.TimeNow = clock.Now
+	}
+
+	return &Elector{opt: opt}
+}
+
+// Holder returns the ID of the instance on behalf of which the elector campaigns.
+func (e *Elector) Holder() string {
+	return e.opt.Holder
+}
+
+// IsLeader returns true if the instance is currently the leader.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.isLeader && !e.leader.IsValid(e.opt.TimeNow()) {
+		// we were unable to renew the lease in time, somebody else may have taken over.
+		e.isLeader = false
+	}
+
+	return e.isLeader
+}
+
+// Leader returns the lease of the last known leader or nil if there's none.
+func (e *Elector) Leader() *Lease {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.leader == nil {
+		return nil
+	}
+
+	l := *e.leader
+
+	return &l
+}
+
+// Campaign performs a single round of the election and returns true if the instance is the leader.
+// It must be called periodically, at intervals that are a fraction of the lease duration.
+func (e *Elector) Campaign(ctx context.Context, rep repo.DirectRepository) (bool, error) {
+	leases, err := loadLeases(ctx, rep)
+	if err != nil {
+		return e.IsLeader(), err
+	}
+
+	now := e.opt.TimeNow()
+	current := currentLease(leases, now)
+
+	switch {
+	case current != nil && current.lease.Holder == e.opt.Holder:
+		renewed := *current.lease
+		renewed.Address = e.opt.Address
+		renewed.Renewed = now
+		renewed.Expires = now.Add(e.opt.LeaseDuration)
+
+		if err := e.writeLease(ctx, rep, &renewed, expiredLeases(leases, now)); err != nil {
+			return e.IsLeader(), err
+		}
+
+		e.setLeader(&renewed, true)
+
+	case current != nil:
+		// somebody else is the leader, withdraw our candidacy if we lost a race.
+		if own := leaseOf(leases, e.opt.Holder); own != nil {
+			if err := deleteLeases(ctx, rep, []*leaseEntry{own}); err != nil {
+				return false, err
+			}
+		}
+
+		e.setLeader(current.lease, false)
+
+	default:
+		candidate := &Lease{
+			Holder:   e.opt.Holder,
+			Address:  e.opt.Address,
+			Acquired: now,
+			Renewed:  now,
+			Expires:  now.Add(e.opt.LeaseDuration),
+		}
+
+		if err := e.writeLease(ctx, rep, candidate, expiredLeases(leases, now)); err != nil {
+			return false, err
+		}
+
+		// we will become the leader in the next round unless another candidate was faster.
+		e.setLeader(nil, false)
+	}
+
+	return e.IsLeader(), nil
+}
+
+// Resign gives up leadership and candidacy of the instance, allowing others to take over immediately.
+func (e *Elector) Resign(ctx context.Context, rep repo.DirectRepository) error {
+	e.setLeader(nil, false)
+
+	leases, err := loadLeases(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if own := leaseOf(leases, e.opt.Holder); own != nil {
+		return deleteLeases(ctx, rep, []*leaseEntry{own})
+	}
+
+	return nil
+}
+
+// ListLeases returns all leases stored in the repository ordered by acquisition time.
+func ListLeases(ctx context.Context, rep repo.DirectRepository) ([]*Lease, error) {
+	leases, err := loadLeases(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Lease{}
+
+	for _, l := range leases {
+		result = append(result, l.lease)
+	}
+
+	return result, nil
+}
+
+func (e *Elector) setLeader(l *Lease, isLeader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.leader = l
+	e.isLeader = isLeader
+}
+
+func (e *Elector) writeLease(ctx context.Context, rep repo.DirectRepository, l *Lease, expired []*leaseEntry) error {
+	var others []*leaseEntry
+
+	for _, x := range expired {
+		if x.lease.Holder != l.Holder {
+			others = append(others, x)
+		}
+	}
+
+	v, err := json.Marshal(l)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize lease")
+	}
+
+	c, err := encryptLease(rep, v)
+	if err != nil {
+		return errors.Wrap(err, "unable to encrypt lease")
+	}
+
+	return errors.Wrap(repo.DirectWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "LeaderElection",
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		if err := deleteLeaseBlobs(ctx, w, others); err != nil {
+			return errors.Wrap(err, "unable to delete expired leases")
+		}
+
+		return errors.Wrap(w.BlobStorage().PutBlob(ctx, leaseBlobID(l.Holder), gather.FromSlice(c), blob.PutOptions{}), "unable to write lease")
+	}), "error writing lease")
+}
+
+type leaseEntry struct {
+	id    blob.ID
+	lease *Lease
+}
+
+// leaseBlobID returns the ID of the blob holding the lease of the provided holder.
+func leaseBlobID(holder string) blob.ID {
+	h := sha256.Sum256([]byte(holder))
+
+	return LeaseBlobPrefix + blob.ID(hex.EncodeToString(h[0:leaseBlobIDLength]))
+}
+
+func loadLeases(ctx context.Context, rep repo.DirectRepository) ([]*leaseEntry, error) {
+	bms, err := blob.ListAllBlobs(ctx, rep.BlobReader(), LeaseBlobPrefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list leases")
+	}
+
+	var (
+		result []*leaseEntry
+		tmp    gather.WriteBuffer
+	)
+
+	defer tmp.Close()
+
+	for _, bm := range bms {
+		if err := rep.BlobReader().GetBlob(ctx, bm.BlobID, 0, -1, &tmp); err != nil {
+			if errors.Is(err, blob.ErrBlobNotFound) {
+				// lease deleted since it was listed.
+				continue
+			}
+
+			return nil, errors.Wrap(err, "unable to read lease")
+		}
+
+		v, err := decryptLease(rep, tmp.ToByteSlice())
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decrypt lease %v", bm.BlobID)
+		}
+
+		l := &Lease{}
+		if err := json.Unmarshal(v, l); err != nil {
+			return nil, errors.Wrapf(err, "malformed lease %v", bm.BlobID)
+		}
+
+		result = append(result, &leaseEntry{bm.BlobID, l})
+	}
+
+	// oldest leases first, ties broken by holder ID so that all instances agree on the order.
+	sort.Slice(result, func(i, j int) bool {
+		if a, b := result[i].lease, result[j].lease; !a.Acquired.Equal(b.Acquired) {
+			return a.Acquired.Before(b.Acquired)
+		}
+
+		return result[i].lease.Holder < result[j].lease.Holder
+	})
+
+	return result, nil
+}
+
+func deleteLeases(ctx context.Context, rep repo.DirectRepository, leases []*leaseEntry) error {
+	return errors.Wrap(repo.DirectWriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "LeaderElection",
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		return deleteLeaseBlobs(ctx, w, leases)
+	}), "error deleting leases")
+}
+
+func deleteLeaseBlobs(ctx context.Context, w repo.DirectRepositoryWriter, leases []*leaseEntry) error {
+	for _, l := range leases {
+		if err := w.BlobStorage().DeleteBlob(ctx, l.id); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return errors.Wrap(err, "unable to delete lease")
+		}
+	}
+
+	return nil
+}
+
+func getAES256GCM(rep repo.DirectRepository) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(rep.DeriveKey(leaseKeyPurpose, leaseKeySize))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256 cipher")
+	}
+
+	//nolint:wrapcheck
+	return cipher.NewGCM(c)
+}
+
+// encryptLease encrypts the contents of a lease blob with AES-256-GCM and random nonce.
+func encryptLease(rep repo.DirectRepository, v []byte) ([]byte, error) {
+	c, err := getAES256GCM(rep)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, c.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to initialize nonce")
+	}
+
+	return c.Seal(append([]byte(nil), nonce...), nonce, v, leaseAEADExtraData), nil
+}
+
+// decryptLease decrypts the contents of a lease blob encrypted with encryptLease.
+func decryptLease(rep repo.DirectRepository, v []byte) ([]byte, error) {
+	c, err := getAES256GCM(rep)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) < c.NonceSize() {
+		return nil, errors.New("invalid lease blob")
+	}
+
+	//nolint:wrapcheck
+	return c.Open(nil, v[0:c.NonceSize()], v[c.NonceSize():], leaseAEADExtraData)
+}
+
+// currentLease returns the oldest valid lease, which determines the leader.
+func currentLease(leases []*leaseEntry, now time.Time) *leaseEntry {
+	for _, l := range leases {
+		if l.lease.IsValid(now) {
+			return l
+		}
+	}
+
+	return nil
+}
+
+func expiredLeases(leases []*leaseEntry, now time.Time) []*leaseEntry {
+	var result []*leaseEntry
+
+	for _, l := range leases {
+		if !l.lease.IsValid(now) {
+			result = append(result, l)
+		}
+	}
+
+	return result
+}
+
+func leaseOf(leases []*leaseEntry, holder string) *leaseEntry {
+	for _, l := range leases {
+		if l.lease.Holder == holder {
+			return l
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package leaderelection_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/leaderelection"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestLeaderElection(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	ft := faketime.NewTimeAdvance(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	newElector := func(holder string) *leaderelection.Elector {
+		return leaderelection.NewElector(leaderelection.Options{
+			Holder:        holder,
+			Address:       holder + ":51515",
+			LeaseDuration: time.Minute,
+			TimeNow:       ft.NowFunc(),
+		})
+	}
+
+	mustCampaign := func(e *leaderelection.Elector) bool {
+		t.Helper()
+
+		isLeader, err := e.Campaign(ctx, env.RepositoryWriter)
+		require.NoError(t, err)
+
+		return isLeader
+	}
+
+	e1 := newElector("server1")
+	e2 := newElector("server2")
+
+	// both instances become candidates in the first round, the one that campaigned first wins in the next round.
+	require.False(t, mustCampaign(e1))
+	ft.Advance(time.Second)
+	require.False(t, mustCampaign(e2))
+
+	ft.Advance(time.Second)
+	require.True(t, mustCampaign(e1))
+	require.False(t, mustCampaign(e2))
+	require.Equal(t, "server1", e2.Leader().Holder)
+	require.Equal(t, "server1:51515", e2.Leader().Address)
+
+	leases, err := leaderelection.ListLeases(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+
+	leaseBlobs, err := blob.ListAllBlobs(ctx, env.RootStorage(), leaderelection.LeaseBlobPrefix)
+	require.NoError(t, err)
+	require.Len(t, leaseBlobs, 1)
+
+	blobsBefore, err := blob.ListAllBlobs(ctx, env.RootStorage(), "")
+	require.NoError(t, err)
+
+	// the leader keeps renewing the lease.
+	for range 5 {
+		ft.Advance(20 * time.Second)
+		require.True(t, mustCampaign(e1))
+		require.False(t, mustCampaign(e2))
+	}
+
+	// renewals only rewrite the lease blob, without writing any contents or indexes.
+	blobsAfter, err := blob.ListAllBlobs(ctx, env.RootStorage(), "")
+	require.NoError(t, err)
+	require.ElementsMatch(t, blobIDs(blobsBefore), blobIDs(blobsAfter))
+
+	// the leader stops renewing and loses leadership after the lease expires, another instance takes over.
+	ft.Advance(30 * time.Second)
+	require.True(t, e1.IsLeader())
+	require.False(t, mustCampaign(e2))
+
+	ft.Advance(31 * time.Second)
+	require.False(t, e1.IsLeader())
+	require.False(t, mustCampaign(e2))
+	require.True(t, mustCampaign(e2))
+	require.False(t, mustCampaign(e1))
+	require.Equal(t, "server2", e1.Leader().Holder)
+
+	// resignation allows others to take over immediately.
+	require.NoError(t, e2.Resign(ctx, env.RepositoryWriter))
+	require.False(t, e2.IsLeader())
+	require.False(t, mustCampaign(e1))
+	require.True(t, mustCampaign(e1))
+}
+
+func blobIDs(bms []blob.Metadata) []blob.ID {
+	var result []blob.ID
+
+	for _, bm := range bms {
+		result = append(result, bm.BlobID)
+	}
+
+	return result
+}
@@ -51,8 +51,10 @@ type loggingFlags struct {
 	disableColor                bool
 	consoleLogTimestamps        bool
 	waitForLogSweep             bool
+	subsystemLogLevels          string
 
-	cliApp *cli.App
+	moduleLevels *logging.ModuleLevels
+	cliApp       *cli.App
 }
 
 func (c *loggingFlags) setup(cliApp *cli.App, app *kingpin.Application) {
@@ -69,8 +71,9 @@ func (c *loggingFlags) setup(cliApp *cli.App, app *kingpin.Application) {
 	app.Flag("content-log-dir-max-age", "Maximum age of content log files to retain").Envar(cliApp.EnvName("KOPIA_CONTENT_LOG_DIR_MAX_AGE")).Default("720h").Hidden().DurationVar(&c.contentLogDirMaxAge)
 	app.Flag("content-log-dir-max-total-size-mb", "Maximum total size of log files to retain").Envar(cliApp.EnvName("KOPIA_CONTENT_LOG_DIR_MAX_SIZE_MB")).Hidden().Default("1000").Float64Var(&c.contentLogDirMaxTotalSizeMB)
 	app.Flag("log-level", "Console log level").Default("info").EnumVar(&c.logLevel, logLevels...)
-	app.Flag("json-log-console", "JSON log file").Hidden().BoolVar(&c.jsonLogConsole)
-	app.Flag("json-log-file", "JSON log file").Hidden().BoolVar(&c.jsonLogFile)
+	app.Flag("log-level-subsystems", "Per-subsystem log level overrides (subsystem=level,...), subsystems: "+strings.Join(logging.Subsystems(), ", ")).PlaceHolder("SUBSYSTEM=LEVEL").Envar(cliApp.EnvName("KOPIA_LOG_LEVEL_SUBSYSTEMS")).StringVar(&c.subsystemLogLevels)
+	app.Flag("json-log-console", "Emit console logs as JSON").Envar(cliApp.EnvName("KOPIA_JSON_LOG_CONSOLE")).BoolVar(&c.jsonLogConsole)
+	app.Flag("json-log-file", "Emit file logs as JSON").Envar(cliApp.EnvName("KOPIA_JSON_LOG_FILE")).BoolVar(&c.jsonLogFile)
 	app.Flag("file-log-level", "File log level").Default("debug").EnumVar(&c.fileLogLevel, logLevels...)
 	app.Flag("file-log-local-tz", "When logging to a file, use local timezone").Hidden().Envar(cliApp.EnvName("KOPIA_FILE_LOG_LOCAL_TZ")).BoolVar(&c.fileLogLocalTimezone)
 	app.Flag("force-color", "Force color output").Hidden().Envar(cliApp.EnvName("KOPIA_FORCE_COLOR")).BoolVar(&c.forceColor)
@@ -79,6 +82,8 @@ func (c *loggingFlags) setup(cliApp *cli.App, app *kingpin.Application) {
 
 	app.PreAction(c.initialize)
 	c.cliApp = cliApp
+	c.moduleLevels = logging.NewModuleLevels()
+	cliApp.SetModuleLogLevels(c.moduleLevels)
 }
 
 // Attach attaches logging flags to the provided application.
@@ -112,10 +117,21 @@ func (c *loggingFlags) initialize(ctx *kingpin.ParseContext) error {
 		suffix = strings.ReplaceAll(c.FullCommand(), " ", "-")
 	}
 
-	rootLogger := zap.New(zapcore.NewTee(
-		c.setupConsoleCore(),
-		c.setupLogFileCore(now, suffix),
-	), zap.WithClock(zaplogutil.Clock()))
+	if err := c.moduleLevels.SetFromString(c.subsystemLogLevels); err != nil {
+		return errors.Wrap(err, "invalid --log-level-subsystems")
+	}
+
+	logFileWriter := c.setupLogFileBasedLogger(now, "cli-logs", suffix, c.logFile, c.logDirMaxFiles, c.logDirMaxTotalSizeMB, c.logDirMaxAge)
+
+	// root loggers for each subsystem, keyed by subsystem name ("" for modules not belonging to any subsystem).
+	rootLoggers := map[string]*zap.Logger{}
+
+	for _, sub := range append([]string{""}, logging.Subsystems()...) {
+		rootLoggers[sub] = zap.New(zapcore.NewTee(
+			c.setupConsoleCore(c.moduleLevels.Enabler(sub, logLevelFromFlag(c.logLevel))),
+			c.setupLogFileCore(logFileWriter, c.moduleLevels.Enabler(sub, logLevelFromFlag(c.fileLogLevel))),
+		), zap.WithClock(zaplogutil.Clock()))
+	}
 
 	contentLogger := zap.New(c.setupContentLogFileBackend(now, suffix), zap.WithClock(zaplogutil.Clock())).Sugar()
 
@@ -124,7 +140,7 @@ func (c *loggingFlags) initialize(ctx *kingpin.ParseContext) error {
 			return contentLogger
 		}
 
-		return rootLogger.Named(module).Sugar()
+		return rootLoggers[logging.SubsystemForModule(module)].Named(module).Sugar()
 	})
 
 	if c.forceColor {
@@ -138,7 +154,7 @@ func (c *loggingFlags) initialize(ctx *kingpin.ParseContext) error {
 	return nil
 }
 
-func (c *loggingFlags) setupConsoleCore() zapcore.Core {
+func (c *loggingFlags) setupConsoleCore(level zapcore.LevelEnabler) zapcore.Core {
 	ec := zapcore.EncoderConfig{
 		LevelKey:         "l",
 		MessageKey:       "m",
@@ -184,7 +200,7 @@ func (c *loggingFlags) setupConsoleCore() zapcore.Core {
 	return zapcore.NewCore(
 		c.jsonOrConsoleEncoder(stec, ec, c.jsonLogConsole),
 		zapcore.AddSync(c.cliApp.Stderr()),
-		logLevelFromFlag(c.logLevel),
+		level,
 	)
 }
 
@@ -251,7 +267,7 @@ func (c *loggingFlags) setupLogFileBasedLogger(now time.Time, subdir, suffix, lo
 	return odf
 }
 
-func (c *loggingFlags) setupLogFileCore(now time.Time, suffix string) zapcore.Core {
+func (c *loggingFlags) setupLogFileCore(w zapcore.WriteSyncer, level zapcore.LevelEnabler) zapcore.Core {
 	return zapcore.NewCore(
 		c.jsonOrConsoleEncoder(
 			zaplogutil.StdConsoleEncoderConfig{
@@ -272,8 +288,8 @@ func (c *loggingFlags) setupLogFileCore(now time.Time, suffix string) zapcore.Co
 				ConsoleSeparator: " ",
 			},
 			c.jsonLogFile),
-		c.setupLogFileBasedLogger(now, "cli-logs", suffix, c.logFile, c.logDirMaxFiles, c.logDirMaxTotalSizeMB, c.logDirMaxAge),
-		logLevelFromFlag(c.fileLogLevel),
+		w,
+		level,
 	)
 }
 
@@ -294,7 +310,7 @@ func (c *loggingFlags) setupContentLogFileBackend(now time.Time, suffix string)
 		},
 		),
 		c.setupLogFileBasedLogger(now, "content-logs", suffix, c.contentLogFile, c.contentLogDirMaxFiles, c.contentLogDirMaxTotalSizeMB, c.contentLogDirMaxAge),
-		zap.DebugLevel)
+		c.moduleLevels.Enabler(logging.SubsystemForModule(content.FormatLogModule), zap.DebugLevel))
 }
 
 func shouldSweepLog(maxFiles int, maxAge time.Duration) bool {
@@ -96,6 +96,22 @@ func TestLoggingFlags(t *testing.T) {
 		"--no-auto-maintenance", "--log-dir", tmpLogDir)
 	require.NoError(t, err)
 	require.Empty(t, stderr)
+
+	// subsystem override makes upload debug logs visible despite --log-level=error
+	_, stderr, err = env.Run(t, false, "snap", "create", dir1,
+		"--no-progress", "--log-level=error", "--log-level-subsystems=upload=debug",
+		"--no-auto-maintenance", "--log-dir", tmpLogDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, stderr)
+
+	for _, l := range stderr {
+		require.Contains(t, l, "DEBUG")
+	}
+
+	_, _, err = env.Run(t, true, "snap", "create", dir1,
+		"--no-progress", "--log-level-subsystems=no-such-subsystem=debug",
+		"--no-auto-maintenance", "--log-dir", tmpLogDir)
+	require.Error(t, err)
 }
 
 func TestLogFileRotation(t *testing.T) {
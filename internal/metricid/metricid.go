@@ -40,6 +40,9 @@
 	"content_uploaded_bytes":                       33,
 	"content_write_bytes":                          34,
 	"content_write_duration_nanos":                 35,
+	"content_verified_bytes":                       36,
+	"content_verified_duration_nanos":              37,
+	"content_verify_error_count":                   38,
 	// add new items here, use consecutive values
 })
 
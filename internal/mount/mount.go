@@ -25,4 +25,7 @@ type Options struct {
 	FuseAllowNonEmptyMount bool
 	// Use WebDAV even on platforms that support FUSE.
 	PreferWebDAV bool
+	// When set, the mount is writable and changes are stored in the provided local scratch directory
+	// instead of the repository. Supported only on FUSE.
+	OverlayDir string
 }
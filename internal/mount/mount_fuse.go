@@ -21,6 +21,11 @@
 //nolint:gochecknoglobals
 var cacheTimeout = 30 * time.Second
 
+// writable overlay can change, so attributes are only cached briefly.
+//
+//nolint:gochecknoglobals
+var overlayCacheTimeout = time.Second
+
 func (mo *Options) toFuseMountOptions() *gofusefs.Options {
 	o := &gofusefs.Options{
 		MountOptions: fuse.MountOptions{
@@ -34,6 +39,12 @@ func (mo *Options) toFuseMountOptions() *gofusefs.Options {
 		NegativeTimeout: &cacheTimeout,
 	}
 
+	if mo.OverlayDir != "" {
+		o.EntryTimeout = &overlayCacheTimeout
+		o.AttrTimeout = &overlayCacheTimeout
+		o.NegativeTimeout = &overlayCacheTimeout
+	}
+
 	o.Options = append(o.Options, "noatime")
 	if mo.FuseAllowNonEmptyMount {
 		o.Options = append(o.Options, "nonempty")
@@ -58,11 +69,24 @@ func Directory(ctx context.Context, entry fs.Directory, mountPoint string, mount
 	}
 
 	if mountOptions.PreferWebDAV {
+		if mountOptions.OverlayDir != "" {
+			return nil, errors.New("writable overlay is not supported with WebDAV")
+		}
+
 		return newPosixWedavController(ctx, entry, mountPoint, isTempDir)
 	}
 
 	rootNode := fusemount.NewDirectoryNode(entry)
 
+	if mountOptions.OverlayDir != "" {
+		var err error
+
+		rootNode, err = fusemount.NewOverlayDirectoryNode(entry, mountOptions.OverlayDir)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create writable overlay")
+		}
+	}
+
 	fuseServer, err := gofusefs.Mount(mountPoint, rootNode, mountOptions.toFuseMountOptions())
 	if err != nil {
 		return nil, errors.Wrap(err, "mounting error")
@@ -15,11 +15,15 @@
 )
 
 // Directory mounts a given directory under a provided drive letter.
-func Directory(ctx context.Context, entry fs.Directory, driveLetter string, _ Options) (Controller, error) {
+func Directory(ctx context.Context, entry fs.Directory, driveLetter string, mountOptions Options) (Controller, error) {
 	if !isValidWindowsDriveOrAsterisk(driveLetter) {
 		return nil, errors.New("must be a valid drive letter or asterisk")
 	}
 
+	if mountOptions.OverlayDir != "" {
+		return nil, errors.New("writable overlay is not supported on this operating system")
+	}
+
 	c, err := DirectoryWebDAV(ctx, entry)
 	if err != nil {
 		return nil, err
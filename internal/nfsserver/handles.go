@@ -0,0 +1,90 @@
+package nfsserver
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	rootNodeID   = 1
+	handleLength = 8
+)
+
+// node is a filesystem entry that has been assigned a file handle.
+type node struct {
+	id       uint64
+	parentID uint64
+	entry    fs.Entry
+}
+
+// handleTable assigns stable file handles to entries discovered while browsing the tree.
+//
+// Handles are never reused for the lifetime of the server, which is safe since the exported
+// snapshot tree is immutable.
+type handleTable struct {
+	mu sync.Mutex
+
+	// +checklocks:mu
+	nextID uint64
+	// +checklocks:mu
+	nodes map[uint64]*node
+	// +checklocks:mu
+	byName map[childKey]uint64
+}
+
+type childKey struct {
+	parentID uint64
+	name     string
+}
+
+func newHandleTable(root fs.Directory) *handleTable {
+	return &handleTable{
+		nextID: rootNodeID + 1,
+		nodes: map[uint64]*node{
+			rootNodeID: {id: rootNodeID, parentID: rootNodeID, entry: root},
+		},
+		byName: map[childKey]uint64{},
+	}
+}
+
+// child returns the node for the child entry of the provided parent, assigning a new handle if needed.
+func (t *handleTable) child(parent *node, e fs.Entry) *node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := childKey{parent.id, e.Name()}
+
+	if id, ok := t.byName[k]; ok {
+		return t.nodes[id]
+	}
+
+	n := &node{id: t.nextID, parentID: parent.id, entry: e}
+	t.nextID++
+
+	t.nodes[n.id] = n
+	t.byName[k] = n.id
+
+	return n
+}
+
+func (t *handleTable) byID(id uint64) *node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.nodes[id]
+}
+
+// lookup returns the node corresponding to the provided file handle or nil if the handle is not known.
+func (t *handleTable) lookup(fh []byte) *node {
+	if len(fh) != handleLength {
+		return nil
+	}
+
+	return t.byID(binary.BigEndian.Uint64(fh))
+}
+
+func (n *node) handle() []byte {
+	return binary.BigEndian.AppendUint64(nil, n.id)
+}
@@ -0,0 +1,72 @@
+package nfsserver
+
+import (
+	"context"
+)
+
+// MOUNT protocol version 3 constants (RFC 1813 Appendix I).
+const (
+	mountProgram = 100005
+	mountVersion = 3
+
+	maxPathLength = 1024
+
+	mountOK = 0
+
+	exportPath = "/"
+)
+
+func (s *Server) mountProgram() rpcProgram {
+	return rpcProgram{
+		version: mountVersion,
+		procs: map[uint32]procHandler{
+			0: s.nfsNull,
+			1: s.mountMnt,
+			2: s.mountDump,
+			3: s.mountUmnt,
+			4: s.nfsNull, // UMNTALL
+			5: s.mountExport,
+		},
+	}
+}
+
+// mountMnt returns the handle of the root directory regardless of the requested path,
+// since the server exports a single tree.
+func (s *Server) mountMnt(ctx context.Context, args *xdrReader, res *xdrWriter) bool {
+	p := args.string(maxPathLength)
+	if args.err != nil {
+		return false
+	}
+
+	log(ctx).Debugf("mount request for %q", p)
+
+	res.uint32(mountOK)
+	res.opaque(s.handles.byID(rootNodeID).handle())
+	res.uint32(2) //nolint:mnd
+	res.uint32(rpcAuthNone)
+	res.uint32(rpcAuthUnix)
+
+	return true
+}
+
+// mountDump returns an empty list of mounts, the server does not track clients.
+func (s *Server) mountDump(_ context.Context, _ *xdrReader, res *xdrWriter) bool {
+	res.bool(false)
+
+	return true
+}
+
+func (s *Server) mountUmnt(_ context.Context, args *xdrReader, _ *xdrWriter) bool {
+	args.string(maxPathLength)
+
+	return args.err == nil
+}
+
+func (s *Server) mountExport(_ context.Context, _ *xdrReader, res *xdrWriter) bool {
+	res.bool(true)
+	res.string(exportPath)
+	res.bool(false) // no groups
+	res.bool(false)
+
+	return true
+}
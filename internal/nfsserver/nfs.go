@@ -0,0 +1,569 @@
+package nfsserver
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// NFSv3 constants (RFC 1813).
+const (
+	nfsProgram = 100003
+	nfsVersion = 3
+
+	maxReadSize    = 1 << 20
+	maxNameLength  = 255
+	maxHandleBytes = 64
+
+	nfsOK             = 0
+	nfsErrNoEnt       = 2
+	nfsErrIO          = 5
+	nfsErrNotDir      = 20
+	nfsErrIsDir       = 21
+	nfsErrInval       = 22
+	nfsErrRofs        = 30
+	nfsErrNameTooLong = 63
+	nfsErrStale       = 70
+	nfsErrBadHandle   = 10001
+	nfsErrBadCookie   = 10003
+
+	nfsTypeReg = 1
+	nfsTypeDir = 2
+	nfsTypeLnk = 5
+
+	accessRead    = 0x01
+	accessLookup  = 0x02
+	accessExecute = 0x20
+
+	fsfSymlink     = 0x02
+	fsfHomogeneous = 0x08
+	fsfCanSetTime  = 0x10
+
+	// approximate encoded sizes used to honor client-provided reply size limits.
+	readdirEntryOverhead     = 24
+	readdirPlusEntryOverhead = 24 + 84 + 8 + handleLength
+	readdirReplyOverhead     = 128
+)
+
+func (s *Server) nfsProgram() rpcProgram {
+	return rpcProgram{
+		version: nfsVersion,
+		procs: map[uint32]procHandler{
+			0:  s.nfsNull,
+			1:  s.nfsGetAttr,
+			2:  readOnlyProc(2), // SETATTR
+			3:  s.nfsLookup,
+			4:  s.nfsAccess,
+			5:  s.nfsReadLink,
+			6:  s.nfsRead,
+			7:  readOnlyProc(2), // WRITE
+			8:  readOnlyProc(4), // CREATE
+			9:  readOnlyProc(4), // MKDIR
+			10: readOnlyProc(4), // SYMLINK
+			11: readOnlyProc(4), // MKNOD
+			12: readOnlyProc(2), // REMOVE
+			13: readOnlyProc(2), // RMDIR
+			14: readOnlyProc(4), // RENAME
+			15: readOnlyProc(3), // LINK
+			16: s.nfsReadDir,
+			17: s.nfsReadDirPlus,
+			18: s.nfsFSStat,
+			19: s.nfsFSInfo,
+			20: s.nfsPathConf,
+			21: readOnlyProc(2), // COMMIT
+		},
+	}
+}
+
+// readOnlyProc returns a handler that rejects a modifying procedure with NFS3ERR_ROFS followed by the
+// provided number of empty optional attributes, which matches the failure results of all such procedures.
+func readOnlyProc(emptyAttrs int) procHandler {
+	return func(_ context.Context, _ *xdrReader, res *xdrWriter) bool {
+		res.uint32(nfsErrRofs)
+
+		for range emptyAttrs {
+			res.bool(false)
+		}
+
+		return true
+	}
+}
+
+func (s *Server) nfsNull(_ context.Context, _ *xdrReader, _ *xdrWriter) bool {
+	return true
+}
+
+// readHandle reads a file handle argument and resolves it, returning the NFS status on failure.
+func (s *Server) readHandle(args *xdrReader) (*node, uint32) {
+	fh := args.opaque(maxHandleBytes)
+	if args.err != nil {
+		return nil, nfsErrBadHandle
+	}
+
+	n := s.handles.lookup(fh)
+	if n == nil {
+		return nil, nfsErrStale
+	}
+
+	return n, nfsOK
+}
+
+func (s *Server) nfsGetAttr(_ context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	if args.err != nil {
+		return false
+	}
+
+	res.uint32(st)
+
+	if st == nfsOK {
+		writeAttrs(res, n)
+	}
+
+	return true
+}
+
+func (s *Server) nfsLookup(ctx context.Context, args *xdrReader, res *xdrWriter) bool {
+	dir, st := s.readHandle(args)
+	name := args.string(maxNameLength + 1)
+
+	if args.err != nil {
+		return false
+	}
+
+	if st != nfsOK {
+		res.uint32(st)
+		res.bool(false)
+
+		return true
+	}
+
+	n, st := s.lookupChild(ctx, dir, name)
+
+	res.uint32(st)
+
+	if st == nfsOK {
+		res.opaque(n.handle())
+		writePostOpAttrs(res, n)
+	}
+
+	writePostOpAttrs(res, dir)
+
+	return true
+}
+
+func (s *Server) lookupChild(ctx context.Context, dir *node, name string) (*node, uint32) {
+	d, ok := dir.entry.(fs.Directory)
+	if !ok {
+		return nil, nfsErrNotDir
+	}
+
+	switch name {
+	case ".":
+		return dir, nfsOK
+
+	case "..":
+		return s.handles.byID(dir.parentID), nfsOK
+	}
+
+	if len(name) > maxNameLength {
+		return nil, nfsErrNameTooLong
+	}
+
+	e, err := d.Child(ctx, name)
+	if err != nil {
+		return nil, errorStatus(ctx, err)
+	}
+
+	return s.handles.child(dir, e), nfsOK
+}
+
+func (s *Server) nfsAccess(_ context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	requested := args.uint32()
+
+	if args.err != nil {
+		return false
+	}
+
+	res.uint32(st)
+
+	if st != nfsOK {
+		res.bool(false)
+		return true
+	}
+
+	writePostOpAttrs(res, n)
+	res.uint32(requested & (accessRead | accessLookup | accessExecute))
+
+	return true
+}
+
+func (s *Server) nfsReadLink(ctx context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	if args.err != nil {
+		return false
+	}
+
+	if st != nfsOK {
+		res.uint32(st)
+		res.bool(false)
+
+		return true
+	}
+
+	sl, ok := n.entry.(fs.Symlink)
+	if !ok {
+		res.uint32(nfsErrInval)
+		writePostOpAttrs(res, n)
+
+		return true
+	}
+
+	target, err := sl.Readlink(ctx)
+	if err != nil {
+		res.uint32(errorStatus(ctx, err))
+		writePostOpAttrs(res, n)
+
+		return true
+	}
+
+	res.uint32(nfsOK)
+	writePostOpAttrs(res, n)
+	res.string(target)
+
+	return true
+}
+
+func (s *Server) nfsRead(ctx context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	offset := args.uint64()
+	count := min(args.uint32(), maxReadSize)
+
+	if args.err != nil {
+		return false
+	}
+
+	if st != nfsOK {
+		res.uint32(st)
+		res.bool(false)
+
+		return true
+	}
+
+	if n.entry.IsDir() {
+		res.uint32(nfsErrIsDir)
+		writePostOpAttrs(res, n)
+
+		return true
+	}
+
+	data, eof, err := s.readAt(ctx, n, offset, int(count))
+	if err != nil {
+		res.uint32(errorStatus(ctx, err))
+		writePostOpAttrs(res, n)
+
+		return true
+	}
+
+	res.uint32(nfsOK)
+	writePostOpAttrs(res, n)
+	res.uint32(uint32(len(data))) //nolint:gosec
+	res.bool(eof)
+	res.opaque(data)
+
+	return true
+}
+
+func (s *Server) readAt(ctx context.Context, n *node, offset uint64, count int) (data []byte, eof bool, err error) {
+	size := uint64(max(n.entry.Size(), 0))
+	if offset >= size || count == 0 {
+		return nil, offset >= size, nil
+	}
+
+	f, ok := n.entry.(fs.File)
+	if !ok {
+		return nil, false, errors.Errorf("%v is not a file", n.entry.Name())
+	}
+
+	count = int(min(uint64(count), size-offset)) //nolint:gosec
+
+	r, release, err := s.readers.acquire(ctx, n.id, f)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer release()
+
+	if _, err := r.Seek(int64(offset), io.SeekStart); err != nil { //nolint:gosec
+		return nil, false, errors.Wrap(err, "seek error")
+	}
+
+	data = make([]byte, count)
+
+	got, err := io.ReadFull(r, data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, false, errors.Wrap(err, "read error")
+	}
+
+	return data[:got], offset+uint64(got) >= size, nil //nolint:gosec
+}
+
+// dirEntries returns the child nodes of the provided directory.
+func (s *Server) dirEntries(ctx context.Context, dir *node) ([]*node, uint32) {
+	d, ok := dir.entry.(fs.Directory)
+	if !ok {
+		return nil, nfsErrNotDir
+	}
+
+	entries, err := fs.GetAllEntries(ctx, d)
+	if err != nil {
+		return nil, errorStatus(ctx, err)
+	}
+
+	result := make([]*node, 0, len(entries))
+
+	for _, e := range entries {
+		result = append(result, s.handles.child(dir, e))
+	}
+
+	return result, nfsOK
+}
+
+func (s *Server) nfsReadDir(ctx context.Context, args *xdrReader, res *xdrWriter) bool {
+	return s.readDir(ctx, args, res, false)
+}
+
+func (s *Server) nfsReadDirPlus(ctx context.Context, args *xdrReader, res *xdrWriter) bool {
+	return s.readDir(ctx, args, res, true)
+}
+
+// readDir implements READDIR and READDIRPLUS, using 1-based positions of entries as cookies.
+func (s *Server) readDir(ctx context.Context, args *xdrReader, res *xdrWriter, plus bool) bool {
+	dir, st := s.readHandle(args)
+	cookie := args.uint64()
+	args.fixedOpaque(8) //nolint:mnd
+
+	maxCount := args.uint32()
+
+	if plus {
+		// READDIRPLUS has separate dircount and maxcount, only the latter limits the reply size.
+		maxCount = args.uint32()
+	}
+
+	if args.err != nil {
+		return false
+	}
+
+	if st != nfsOK {
+		res.uint32(st)
+		res.bool(false)
+
+		return true
+	}
+
+	children, st := s.dirEntries(ctx, dir)
+	if st == nfsOK && cookie > uint64(len(children)) {
+		st = nfsErrBadCookie
+	}
+
+	res.uint32(st)
+	writePostOpAttrs(res, dir)
+
+	if st != nfsOK {
+		return true
+	}
+
+	res.fixedOpaque(make([]byte, 8)) //nolint:mnd
+
+	size := readdirReplyOverhead
+	eof := true
+
+	for i := int(cookie); i < len(children); i++ { //nolint:gosec
+		c := children[i]
+
+		entrySize := readdirEntryOverhead + len(c.entry.Name())
+		if plus {
+			entrySize = readdirPlusEntryOverhead + len(c.entry.Name())
+		}
+
+		if size+entrySize > int(maxCount) {
+			eof = false
+			break
+		}
+
+		size += entrySize
+
+		res.bool(true)
+		res.uint64(c.id)
+		res.string(c.entry.Name())
+		res.uint64(uint64(i + 1)) //nolint:gosec
+
+		if plus {
+			writePostOpAttrs(res, c)
+			res.bool(true)
+			res.opaque(c.handle())
+		}
+	}
+
+	res.bool(false)
+	res.bool(eof)
+
+	return true
+}
+
+func (s *Server) nfsFSStat(_ context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	if args.err != nil {
+		return false
+	}
+
+	res.uint32(st)
+
+	if st != nfsOK {
+		res.bool(false)
+		return true
+	}
+
+	writePostOpAttrs(res, n)
+
+	// total size is unknown, report no free space since the filesystem is read-only.
+	res.uint64(uint64(max(n.entry.Size(), 0))) // tbytes
+	res.uint64(0)                              // fbytes
+	res.uint64(0)                              // abytes
+	res.uint64(0)                              // tfiles
+	res.uint64(0)                              // ffiles
+	res.uint64(0)                              // afiles
+	res.uint32(0)                              // invarsec
+
+	return true
+}
+
+func (s *Server) nfsFSInfo(_ context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	if args.err != nil {
+		return false
+	}
+
+	res.uint32(st)
+
+	if st != nfsOK {
+		res.bool(false)
+		return true
+	}
+
+	writePostOpAttrs(res, n)
+	res.uint32(maxReadSize)  // rtmax
+	res.uint32(maxReadSize)  // rtpref
+	res.uint32(xdrAlignment) // rtmult
+	res.uint32(maxReadSize)  // wtmax
+	res.uint32(maxReadSize)  // wtpref
+	res.uint32(xdrAlignment) // wtmult
+	res.uint32(maxReadSize)  // dtpref
+	res.uint64(1<<63 - 1)    // maxfilesize
+	res.uint32(0)            // time_delta.seconds
+	res.uint32(1)            // time_delta.nseconds
+	res.uint32(fsfSymlink | fsfHomogeneous | fsfCanSetTime)
+
+	return true
+}
+
+func (s *Server) nfsPathConf(_ context.Context, args *xdrReader, res *xdrWriter) bool {
+	n, st := s.readHandle(args)
+	if args.err != nil {
+		return false
+	}
+
+	res.uint32(st)
+
+	if st != nfsOK {
+		res.bool(false)
+		return true
+	}
+
+	writePostOpAttrs(res, n)
+	res.uint32(1)             // linkmax
+	res.uint32(maxNameLength) // name_max
+	res.bool(true)            // no_trunc
+	res.bool(true)            // chown_restricted
+	res.bool(false)           // case_insensitive
+	res.bool(true)            // case_preserving
+
+	return true
+}
+
+func writePostOpAttrs(res *xdrWriter, n *node) {
+	res.bool(true)
+	writeAttrs(res, n)
+}
+
+// writeAttrs writes fattr3 structure describing the provided node.
+func writeAttrs(res *xdrWriter, n *node) {
+	e := n.entry
+	mode := e.Mode()
+
+	var (
+		typ   uint32 = nfsTypeReg
+		nlink uint32 = 1
+	)
+
+	switch {
+	case mode.IsDir():
+		typ = nfsTypeDir
+		nlink = 2 //nolint:mnd
+	case mode&os.ModeSymlink != 0:
+		typ = nfsTypeLnk
+	}
+
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 0o4000
+	}
+
+	if mode&os.ModeSetgid != 0 {
+		perm |= 0o2000
+	}
+
+	if mode&os.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+
+	size := uint64(max(e.Size(), 0))
+	owner := e.Owner()
+
+	res.uint32(typ)
+	res.uint32(perm)
+	res.uint32(nlink)
+	res.uint32(owner.UserID)
+	res.uint32(owner.GroupID)
+	res.uint64(size) // size
+	res.uint64(size) // used
+	res.uint32(0)    // rdev.specdata1
+	res.uint32(0)    // rdev.specdata2
+	res.uint64(0)    // fsid
+	res.uint64(n.id) // fileid
+
+	for range 3 { // atime, mtime, ctime
+		writeTime(res, e.ModTime())
+	}
+}
+
+func writeTime(res *xdrWriter, t time.Time) {
+	res.uint32(uint32(t.Unix()))       //nolint:gosec
+	res.uint32(uint32(t.Nanosecond())) //nolint:gosec
+}
+
+func errorStatus(ctx context.Context, err error) uint32 {
+	if errors.Is(err, fs.ErrEntryNotFound) || errors.Is(err, os.ErrNotExist) {
+		return nfsErrNoEnt
+	}
+
+	log(ctx).Errorf("NFS request failed: %v", err)
+
+	return nfsErrIO
+}
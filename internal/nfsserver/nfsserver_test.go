@@ -0,0 +1,178 @@
+package nfsserver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	xid  uint32
+}
+
+// call invokes the provided procedure and returns the reader positioned at the results.
+func (c *testClient) call(prog, proc uint32, args func(w *xdrWriter)) *xdrReader {
+	c.t.Helper()
+
+	c.xid++
+
+	w := &xdrWriter{}
+	w.uint32(c.xid)
+	w.uint32(rpcMsgCall)
+	w.uint32(rpcVersion)
+	w.uint32(prog)
+
+	if prog == mountProgram {
+		w.uint32(mountVersion)
+	} else {
+		w.uint32(nfsVersion)
+	}
+
+	w.uint32(proc)
+	w.uint32(rpcAuthNone)
+	w.opaque(nil)
+	w.uint32(rpcAuthNone)
+	w.opaque(nil)
+
+	if args != nil {
+		args(w)
+	}
+
+	require.NoError(c.t, writeRecord(c.conn, w.buf))
+
+	rec, err := readRecord(c.conn)
+	require.NoError(c.t, err)
+
+	r := &xdrReader{buf: rec}
+	require.Equal(c.t, c.xid, r.uint32())
+	require.Equal(c.t, uint32(rpcMsgReply), r.uint32())
+	require.Equal(c.t, uint32(rpcReplyAccepted), r.uint32())
+	r.uint32()
+	r.opaque(maxAuthLength)
+	require.Equal(c.t, uint32(rpcAcceptSuccess), r.uint32())
+
+	return r
+}
+
+func skipAttrs(r *xdrReader) {
+	r.next(84) //nolint:mnd
+}
+
+func TestServer(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := mockfs.NewDirectory()
+	root.AddFile("file1", []byte("hello world"), 0o644)
+	root.AddSymlink("link1", "file1", 0o777)
+	root.AddDir("dir1", 0o755).AddFile("file2", []byte("contents2"), 0o600)
+
+	s := New(root)
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error)
+
+	go func() { done <- s.Serve(ctx, l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+
+	c := &testClient{t: t, conn: conn}
+
+	r := c.call(mountProgram, 1, func(w *xdrWriter) { w.string("/") })
+	require.Equal(t, uint32(mountOK), r.uint32())
+	rootFH := r.opaque(maxHandleBytes)
+
+	lookup := func(dir []byte, name string) (uint32, []byte) {
+		r := c.call(nfsProgram, 3, func(w *xdrWriter) {
+			w.opaque(dir)
+			w.string(name)
+		})
+
+		st := r.uint32()
+		if st != nfsOK {
+			return st, nil
+		}
+
+		return st, r.opaque(maxHandleBytes)
+	}
+
+	st, _ := lookup(rootFH, "no-such-file")
+	require.Equal(t, uint32(nfsErrNoEnt), st)
+
+	st, dirFH := lookup(rootFH, "dir1")
+	require.Equal(t, uint32(nfsOK), st)
+
+	st, fileFH := lookup(dirFH, "file2")
+	require.Equal(t, uint32(nfsOK), st)
+
+	// read part of the file.
+	r = c.call(nfsProgram, 6, func(w *xdrWriter) {
+		w.opaque(fileFH)
+		w.uint64(3)
+		w.uint32(100)
+	})
+	require.Equal(t, uint32(nfsOK), r.uint32())
+	require.Equal(t, uint32(1), r.uint32())
+	skipAttrs(r)
+	require.Equal(t, uint32(6), r.uint32())
+	require.Equal(t, uint32(1), r.uint32())
+	require.Equal(t, []byte("tents2"), r.opaque(maxReadSize))
+
+	// symlinks
+	st, linkFH := lookup(rootFH, "link1")
+	require.Equal(t, uint32(nfsOK), st)
+
+	r = c.call(nfsProgram, 5, func(w *xdrWriter) { w.opaque(linkFH) })
+	require.Equal(t, uint32(nfsOK), r.uint32())
+	r.uint32()
+	skipAttrs(r)
+	require.Equal(t, "file1", r.string(maxPathLength))
+
+	// list root directory.
+	r = c.call(nfsProgram, 16, func(w *xdrWriter) {
+		w.opaque(rootFH)
+		w.uint64(0)
+		w.fixedOpaque(make([]byte, 8))
+		w.uint32(4096)
+	})
+	require.Equal(t, uint32(nfsOK), r.uint32())
+	r.uint32()
+	skipAttrs(r)
+	r.fixedOpaque(8)
+
+	var names []string
+
+	for r.uint32() == 1 {
+		r.uint64()
+		names = append(names, r.string(maxNameLength))
+		r.uint64()
+	}
+
+	require.Equal(t, uint32(1), r.uint32())
+	require.NoError(t, r.err)
+	require.ElementsMatch(t, []string{"file1", "link1", "dir1"}, names)
+
+	// modifications are rejected.
+	r = c.call(nfsProgram, 12, func(w *xdrWriter) {
+		w.opaque(rootFH)
+		w.string("file1")
+	})
+	require.Equal(t, uint32(nfsErrRofs), r.uint32())
+
+	// stale handle
+	r = c.call(nfsProgram, 1, func(w *xdrWriter) { w.opaque([]byte{1, 2, 3, 4, 5, 6, 7, 8}) })
+	require.Equal(t, uint32(nfsErrStale), r.uint32())
+
+	conn.Close()
+	l.Close()
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,101 @@
+package nfsserver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const defaultMaxOpenReaders = 64
+
+// readerCache keeps a bounded number of open file readers so that sequential READ calls
+// do not need to reopen the underlying object each time.
+type readerCache struct {
+	maxOpen int
+
+	mu sync.Mutex
+
+	// +checklocks:mu
+	idle map[uint64]*idleReader
+	// +checklocks:mu
+	counter uint64
+}
+
+type idleReader struct {
+	r        fs.Reader
+	lastUsed uint64
+}
+
+func newReaderCache(maxOpen int) *readerCache {
+	return &readerCache{
+		maxOpen: maxOpen,
+		idle:    map[uint64]*idleReader{},
+	}
+}
+
+// acquire returns an idle reader for the provided node or opens a new one. The returned function
+// must be called to return the reader to the cache.
+func (c *readerCache) acquire(ctx context.Context, id uint64, f fs.File) (fs.Reader, func(), error) {
+	var r fs.Reader
+
+	c.mu.Lock()
+	if ir := c.idle[id]; ir != nil {
+		r = ir.r
+		delete(c.idle, id)
+	}
+	c.mu.Unlock()
+
+	if r == nil {
+		var err error
+
+		r, err = f.Open(ctx)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error opening file")
+		}
+	}
+
+	return r, func() { c.release(id, r) }, nil
+}
+
+func (c *readerCache) release(id uint64, r fs.Reader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.idle[id]; ok {
+		// another reader for the same file was returned concurrently.
+		r.Close() //nolint:errcheck
+		return
+	}
+
+	c.counter++
+	c.idle[id] = &idleReader{r, c.counter}
+
+	if len(c.idle) <= c.maxOpen {
+		return
+	}
+
+	// evict least recently used reader.
+	var oldest uint64
+
+	for k, v := range c.idle {
+		if c.idle[oldest] == nil || v.lastUsed < c.idle[oldest].lastUsed {
+			oldest = k
+		}
+	}
+
+	c.idle[oldest].r.Close() //nolint:errcheck
+	delete(c.idle, oldest)
+}
+
+func (c *readerCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, ir := range c.idle {
+		ir.r.Close() //nolint:errcheck
+		delete(c.idle, id)
+	}
+}
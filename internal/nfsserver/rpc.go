@@ -0,0 +1,159 @@
+package nfsserver
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ONC RPC constants (RFC 5531).
+const (
+	rpcVersion = 2
+
+	rpcMsgCall  = 0
+	rpcMsgReply = 1
+
+	rpcReplyAccepted = 0
+	rpcReplyDenied   = 1
+
+	rpcAcceptSuccess      = 0
+	rpcAcceptProgUnavail  = 1
+	rpcAcceptProgMismatch = 2
+	rpcAcceptProcUnavail  = 3
+	rpcAcceptGarbageArgs  = 4
+
+	rpcRejectMismatch = 0
+
+	rpcAuthNone = 0
+	rpcAuthUnix = 1
+
+	maxAuthLength = 400
+
+	recordLastFragment = 1 << 31
+	maxRecordSize      = maxReadSize + 64<<10
+)
+
+// procHandler decodes arguments of a procedure and encodes its results, returning false if arguments could not be decoded.
+type procHandler func(ctx context.Context, args *xdrReader, res *xdrWriter) bool
+
+// rpcProgram describes a single version of an RPC program.
+type rpcProgram struct {
+	version uint32
+	procs   map[uint32]procHandler
+}
+
+// readRecord reads a single RPC record, which consists of one or more fragments when using TCP (RFC 5531 section 11).
+func readRecord(r io.Reader) ([]byte, error) {
+	var (
+		record []byte
+		hdr    [4]byte
+	)
+
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			//nolint:wrapcheck
+			return nil, err
+		}
+
+		h := binary.BigEndian.Uint32(hdr[:])
+		n := int(h &^ recordLastFragment)
+
+		if len(record)+n > maxRecordSize {
+			return nil, errors.Errorf("RPC record too large: %v", len(record)+n)
+		}
+
+		frag := make([]byte, n)
+		if _, err := io.ReadFull(r, frag); err != nil {
+			return nil, errors.Wrap(err, "error reading RPC record fragment")
+		}
+
+		record = append(record, frag...)
+
+		if h&recordLastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// writeRecord writes RPC record as a single fragment.
+func writeRecord(w io.Writer, data []byte) error {
+	buf := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), recordLastFragment|uint32(len(data))) //nolint:gosec,mnd
+
+	_, err := w.Write(append(buf, data...))
+
+	return errors.Wrap(err, "error writing RPC record")
+}
+
+// handleCall decodes the RPC call in the provided record, dispatches it to the appropriate
+// procedure handler and returns the encoded reply or nil if the record must be dropped.
+func handleCall(ctx context.Context, programs map[uint32]rpcProgram, record []byte) []byte {
+	r := &xdrReader{buf: record}
+
+	xid := r.uint32()
+	if r.uint32() != rpcMsgCall || r.err != nil {
+		return nil
+	}
+
+	rpcvers := r.uint32()
+	prog := r.uint32()
+	vers := r.uint32()
+	proc := r.uint32()
+
+	// credentials and verifier are not used, the server is read-only.
+	r.uint32()
+	r.opaque(maxAuthLength)
+	r.uint32()
+	r.opaque(maxAuthLength)
+
+	w := &xdrWriter{}
+	w.uint32(xid)
+	w.uint32(rpcMsgReply)
+
+	if rpcvers != rpcVersion {
+		w.uint32(rpcReplyDenied)
+		w.uint32(rpcRejectMismatch)
+		w.uint32(rpcVersion)
+		w.uint32(rpcVersion)
+
+		return w.buf
+	}
+
+	w.uint32(rpcReplyAccepted)
+	w.uint32(rpcAuthNone)
+	w.opaque(nil)
+
+	if r.err != nil {
+		w.uint32(rpcAcceptGarbageArgs)
+		return w.buf
+	}
+
+	p, ok := programs[prog]
+
+	switch {
+	case !ok:
+		w.uint32(rpcAcceptProgUnavail)
+
+	case p.version != vers:
+		w.uint32(rpcAcceptProgMismatch)
+		w.uint32(p.version)
+		w.uint32(p.version)
+
+	case p.procs[proc] == nil:
+		w.uint32(rpcAcceptProcUnavail)
+
+	default:
+		res := &xdrWriter{}
+
+		if !p.procs[proc](ctx, r, res) || r.err != nil {
+			w.uint32(rpcAcceptGarbageArgs)
+			return w.buf
+		}
+
+		w.uint32(rpcAcceptSuccess)
+		w.buf = append(w.buf, res.buf...)
+	}
+
+	return w.buf
+}
@@ -0,0 +1,99 @@
+// Package nfsserver implements a minimal read-only NFSv3 server for serving snapshots.
+//
+// The server speaks NFSv3 and MOUNTv3 on a single TCP port without registering with portmapper,
+// so clients must specify the ports explicitly, for example on Linux:
+//
+//	mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock,ro server:/ /mnt/kopia
+package nfsserver
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("kopia/nfsserver")
+
+// Server serves a directory tree read-only over NFSv3.
+type Server struct {
+	handles  *handleTable
+	readers  *readerCache
+	programs map[uint32]rpcProgram
+
+	wg sync.WaitGroup
+}
+
+// New creates a new NFS server exporting the provided directory.
+func New(root fs.Directory) *Server {
+	s := &Server{
+		handles: newHandleTable(root),
+		readers: newReaderCache(defaultMaxOpenReaders),
+	}
+
+	s.programs = map[uint32]rpcProgram{
+		nfsProgram:   s.nfsProgram(),
+		mountProgram: s.mountProgram(),
+	}
+
+	return s
+}
+
+// Serve accepts connections on the provided listener until it is closed.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	defer s.wg.Wait()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return errors.Wrap(err, "accept error")
+		}
+
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close() //nolint:errcheck
+
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// Close releases resources held by the server.
+func (s *Server) Close() {
+	s.readers.close()
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	log(ctx).Debugf("NFS client connected from %v", conn.RemoteAddr())
+
+	r := bufio.NewReader(conn)
+
+	for {
+		record, err := readRecord(r)
+		if err != nil {
+			log(ctx).Debugf("NFS client %v disconnected: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		reply := handleCall(ctx, s.programs, record)
+		if reply == nil {
+			continue
+		}
+
+		if err := writeRecord(conn, reply); err != nil {
+			log(ctx).Debugf("error writing reply to %v: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package nfsserver
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+const xdrAlignment = 4
+
+var errShortBuffer = errors.New("short XDR buffer")
+
+// xdrReader decodes values encoded using XDR (RFC 4506).
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if n < 0 || len(r.buf) < n {
+		r.err = errShortBuffer
+		return nil
+	}
+
+	v := r.buf[:n]
+	r.buf = r.buf[n:]
+
+	return v
+}
+
+func (r *xdrReader) uint32() uint32 {
+	b := r.next(4) //nolint:mnd
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *xdrReader) uint64() uint64 {
+	b := r.next(8) //nolint:mnd
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(b)
+}
+
+// opaque reads variable-length opaque data of up to maxLen bytes.
+func (r *xdrReader) opaque(maxLen int) []byte {
+	n := int(r.uint32())
+	if r.err != nil {
+		return nil
+	}
+
+	if n > maxLen {
+		r.err = errors.Errorf("XDR opaque value too long: %v", n)
+		return nil
+	}
+
+	v := r.next(n)
+	r.next(padding(n))
+
+	return v
+}
+
+// fixedOpaque reads fixed-length opaque data.
+func (r *xdrReader) fixedOpaque(n int) []byte {
+	v := r.next(n)
+	r.next(padding(n))
+
+	return v
+}
+
+func (r *xdrReader) string(maxLen int) string {
+	return string(r.opaque(maxLen))
+}
+
+// xdrWriter encodes values using XDR (RFC 4506).
+type xdrWriter struct {
+	buf []byte
+}
+
+func (w *xdrWriter) uint32(v uint32) {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+}
+
+func (w *xdrWriter) uint64(v uint64) {
+	w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+}
+
+func (w *xdrWriter) bool(v bool) {
+	if v {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+func (w *xdrWriter) opaque(v []byte) {
+	w.uint32(uint32(len(v))) //nolint:gosec
+	w.fixedOpaque(v)
+}
+
+func (w *xdrWriter) fixedOpaque(v []byte) {
+	w.buf = append(w.buf, v...)
+	w.buf = append(w.buf, make([]byte, padding(len(v)))...)
+}
+
+func (w *xdrWriter) string(v string) {
+	w.opaque([]byte(v))
+}
+
+func padding(n int) int {
+	return (xdrAlignment - n%xdrAlignment) % xdrAlignment
+}
@@ -21,6 +21,9 @@ type Queue struct {
 	activeWorkerCount int64
 	completedWork     int64
 
+	// maximum number of workers processing items at the same time, 0 == all workers.
+	maxActiveWorkers int64
+
 	nextReportTime time.Time
 
 	ProgressCallback func(ctx context.Context, enqueued, active, completed int64)
@@ -94,18 +97,34 @@ func (v *Queue) Process(ctx context.Context, workers int) error {
 	return eg.Wait()
 }
 
+// SetMaxActiveWorkers limits the number of workers concurrently processing work items,
+// which allows adjusting parallelism while the queue is being processed. 0 removes the limit.
+func (v *Queue) SetMaxActiveWorkers(n int) {
+	v.monitor.L.Lock()
+	defer v.monitor.L.Unlock()
+
+	v.maxActiveWorkers = int64(n)
+
+	v.monitor.Broadcast()
+}
+
 func (v *Queue) dequeue(ctx context.Context) CallbackFunc {
 	v.monitor.L.Lock()
 	defer v.monitor.L.Unlock()
 
-	for v.queueItems.Len() == 0 && v.activeWorkerCount > 0 {
-		// no items in queue, but some workers are active, they may add more.
-		v.monitor.Wait()
-	}
+	for {
+		// no items in queue, no workers are active, no more work.
+		if v.queueItems.Len() == 0 && v.activeWorkerCount == 0 {
+			return nil
+		}
 
-	// no items in queue, no workers are active, no more work.
-	if v.queueItems.Len() == 0 {
-		return nil
+		if v.queueItems.Len() > 0 && (v.maxActiveWorkers <= 0 || v.activeWorkerCount < v.maxActiveWorkers) {
+			break
+		}
+
+		// no items in queue, but some workers are active, they may add more,
+		// or the number of active workers is at the limit.
+		v.monitor.Wait()
 	}
 
 	v.activeWorkerCount++
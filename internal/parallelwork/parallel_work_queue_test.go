@@ -127,6 +127,41 @@ func TestWaitForActiveWorkers(t *testing.T) {
 	require.Equal(t, 3, sum)
 }
 
+func TestSetMaxActiveWorkers(t *testing.T) {
+	queue := parallelwork.NewQueue()
+	queue.SetMaxActiveWorkers(2)
+
+	var active, maxActive, completed atomic.Int32
+
+	for i := range 20 {
+		queue.EnqueueBack(context.Background(), func() error {
+			n := active.Add(1)
+			defer active.Add(-1)
+
+			for {
+				m := maxActive.Load()
+				if n <= m || maxActive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+
+			if i == 10 {
+				queue.SetMaxActiveWorkers(3)
+			}
+
+			completed.Add(1)
+
+			return nil
+		})
+	}
+
+	require.NoError(t, queue.Process(context.Background(), 8))
+	require.Equal(t, int32(20), completed.Load())
+	require.LessOrEqual(t, maxActive.Load(), int32(3))
+}
+
 func TestProgressCallback(t *testing.T) {
 	queue := parallelwork.NewQueue()
 
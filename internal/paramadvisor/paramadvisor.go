@@ -0,0 +1,390 @@
+// Package paramadvisor analyzes the contents of a repository and recommends splitter, compression
+// and index format parameters.
+package paramadvisor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// Names of parameters the advisor makes suggestions for.
+const (
+	ParameterSplitter     = "splitter"
+	ParameterCompression  = "compression"
+	ParameterIndexVersion = "index-version"
+)
+
+const (
+	// SuggestedCompression is the compression algorithm suggested for compressible data.
+	SuggestedCompression compression.Name = "zstd-fastest"
+
+	// maximum number of uncompressed contents sampled to estimate compressibility.
+	maxCompressionSamples = 64
+
+	// compression is worth enabling when sampled data compresses to less than this fraction.
+	compressibleRatio = 0.8
+
+	// compression is not worth it when compressed data is stored at more than this fraction.
+	incompressibleRatio = 0.95
+
+	// deduplication ratios above which smaller chunks are suggested and below which larger chunks are suggested.
+	highDedupRatio = 2.0
+	lowDedupRatio  = 1.2
+
+	// larger chunks are only suggested for repositories with many contents, where index size matters.
+	manyContents = 1_000_000
+
+	smallerSplitterSize = "2M"
+	largerSplitterSize  = "8M"
+)
+
+// SizeBucket is a bucket of the content size histogram.
+type SizeBucket struct {
+	// MaxSize is the upper bound of the content size in the bucket, zero for unbounded.
+	MaxSize int64 `json:"maxSize"`
+	Count   int64 `json:"count"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// SizeBuckets are the upper bounds of the content size histogram buckets, the last bucket is unbounded.
+//
+//nolint:gochecknoglobals
+var SizeBuckets = []int64{
+	16 << 10,
+	128 << 10,
+	1 << 20,
+	4 << 20,
+}
+
+// Suggestion is a recommended change of a repository parameter.
+type Suggestion struct {
+	Parameter string `json:"parameter"`
+	Current   string `json:"current"`
+	Suggested string `json:"suggested"`
+	Reason    string `json:"reason"`
+
+	// InPlace indicates that the suggestion can be applied to the existing repository without rewriting data.
+	InPlace bool `json:"inPlace"`
+}
+
+// Analysis contains statistics of the repository and suggested parameter changes.
+type Analysis struct {
+	DataContents     int64 `json:"dataContents"`
+	DataBytes        int64 `json:"dataBytes"`
+	MetadataContents int64 `json:"metadataContents"`
+
+	// histogram of sizes of data contents before compression.
+	SizeHistogram []*SizeBucket `json:"sizeHistogram"`
+
+	CompressedOriginalBytes int64 `json:"compressedOriginalBytes"`
+	CompressedPackedBytes   int64 `json:"compressedPackedBytes"`
+	UncompressedBytes       int64 `json:"uncompressedBytes"`
+
+	// estimated ratio of compressed to original size of uncompressed contents, zero when not sampled.
+	SampledContents         int           `json:"sampledContents"`
+	SampledCompressionRatio float64       `json:"sampledCompressionRatio,omitempty"`
+	LogicalBytes            int64         `json:"logicalBytes"`
+	DedupRatio              float64       `json:"dedupRatio"`
+	Splitter                string        `json:"splitter"`
+	Compression             string        `json:"compression"`
+	IndexVersion            int           `json:"indexVersion"`
+	EpochManagerEnabled     bool          `json:"epochManagerEnabled"`
+	Suggestions             []*Suggestion `json:"suggestions"`
+}
+
+// Analyze computes repository statistics and parameter suggestions.
+func Analyze(ctx context.Context, rep repo.DirectRepository) (*Analysis, error) {
+	a := &Analysis{}
+
+	for _, s := range SizeBuckets {
+		a.SizeHistogram = append(a.SizeHistogram, &SizeBucket{MaxSize: s})
+	}
+
+	a.SizeHistogram = append(a.SizeHistogram, &SizeBucket{})
+
+	samples, err := a.analyzeContents(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.sampleCompression(ctx, rep, samples); err != nil {
+		return nil, err
+	}
+
+	if err := a.analyzeSnapshots(ctx, rep); err != nil {
+		return nil, err
+	}
+
+	if err := a.analyzeParameters(ctx, rep); err != nil {
+		return nil, err
+	}
+
+	a.suggestCompression()
+	a.suggestSplitter()
+	a.suggestIndexVersion()
+
+	return a, nil
+}
+
+func (a *Analysis) analyzeContents(ctx context.Context, rep repo.DirectRepository) ([]content.ID, error) {
+	var (
+		samples      []content.ID
+		uncompressed int
+	)
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		if ci.ContentID.HasPrefix() {
+			a.MetadataContents++
+			return nil
+		}
+
+		a.DataContents++
+		a.DataBytes += int64(ci.OriginalLength)
+
+		for _, b := range a.SizeHistogram {
+			if b.MaxSize == 0 || int64(ci.OriginalLength) <= b.MaxSize {
+				b.Count++
+				b.Bytes += int64(ci.OriginalLength)
+
+				break
+			}
+		}
+
+		if ci.CompressionHeaderID != 0 {
+			a.CompressedOriginalBytes += int64(ci.OriginalLength)
+			a.CompressedPackedBytes += int64(ci.PackedLength)
+
+			return nil
+		}
+
+		a.UncompressedBytes += int64(ci.OriginalLength)
+
+		// reservoir sampling of uncompressed contents.
+		uncompressed++
+
+		if len(samples) < maxCompressionSamples {
+			samples = append(samples, ci.ContentID)
+		} else if i := rand.Intn(uncompressed); i < maxCompressionSamples { //nolint:gosec
+			samples[i] = ci.ContentID
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	return samples, nil
+}
+
+func (a *Analysis) sampleCompression(ctx context.Context, rep repo.DirectRepository, samples []content.ID) error {
+	comp := compression.ByName[SuggestedCompression]
+
+	var original, compressed int64
+
+	for _, cid := range samples {
+		data, err := rep.ContentReader().GetContent(ctx, cid)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read content %v", cid)
+		}
+
+		var buf bytes.Buffer
+
+		if err := comp.Compress(&buf, bytes.NewReader(data)); err != nil {
+			return errors.Wrap(err, "compression error")
+		}
+
+		original += int64(len(data))
+		compressed += int64(buf.Len())
+	}
+
+	a.SampledContents = len(samples)
+
+	if original > 0 {
+		a.SampledCompressionRatio = float64(compressed) / float64(original)
+	}
+
+	return nil
+}
+
+func (a *Analysis) analyzeSnapshots(ctx context.Context, rep repo.Repository) error {
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to list snapshots")
+	}
+
+	mans, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return errors.Wrap(err, "unable to load snapshots")
+	}
+
+	for _, m := range mans {
+		a.LogicalBytes += m.Stats.TotalFileSize
+	}
+
+	if a.DataBytes > 0 {
+		a.DedupRatio = float64(a.LogicalBytes) / float64(a.DataBytes)
+	}
+
+	return nil
+}
+
+func (a *Analysis) analyzeParameters(ctx context.Context, rep repo.DirectRepository) error {
+	mp, err := rep.FormatManager().GetMutableParameters(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get repository parameters")
+	}
+
+	a.IndexVersion = mp.IndexVersion
+	a.EpochManagerEnabled = mp.EpochParameters.Enabled
+
+	pol, err := GlobalPolicy(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	a.Splitter = pol.SplitterPolicy.Algorithm
+	if a.Splitter == "" {
+		a.Splitter = rep.ObjectFormat().Splitter
+	}
+
+	a.Compression = string(pol.CompressionPolicy.CompressorName)
+	if a.Compression == "" {
+		a.Compression = "none"
+	}
+
+	return nil
+}
+
+// GlobalPolicy returns the global policy defined in the repository or the default policy.
+func GlobalPolicy(ctx context.Context, rep repo.Repository) (*policy.Policy, error) {
+	pol, err := policy.GetDefinedPolicy(ctx, rep, policy.GlobalPolicySourceInfo)
+	if errors.Is(err, policy.ErrPolicyNotFound) {
+		p := *policy.DefaultPolicy
+		return &p, nil
+	}
+
+	return pol, errors.Wrap(err, "unable to get global policy")
+}
+
+func (a *Analysis) suggestCompression() {
+	compressionEnabled := a.Compression != "none"
+
+	switch {
+	case !compressionEnabled && a.SampledContents > 0 && a.SampledCompressionRatio < compressibleRatio:
+		reason := fmt.Sprintf("sampled uncompressed data compresses to %.0f%% of its size", 100*a.SampledCompressionRatio) //nolint:mnd
+
+		a.Suggestions = append(a.Suggestions, &Suggestion{
+			Parameter: ParameterCompression,
+			Current:   a.Compression,
+			Suggested: string(SuggestedCompression),
+			Reason:    reason,
+			InPlace:   a.IndexVersion >= index.Version2,
+		})
+
+	case compressionEnabled && a.CompressedOriginalBytes > 0 &&
+		float64(a.CompressedPackedBytes) > incompressibleRatio*float64(a.CompressedOriginalBytes):
+		a.Suggestions = append(a.Suggestions, &Suggestion{
+			Parameter: ParameterCompression,
+			Current:   a.Compression,
+			Suggested: "none",
+			Reason: fmt.Sprintf("compressed data is stored at %.0f%% of its size, compression costs CPU without saving space",
+				100*float64(a.CompressedPackedBytes)/float64(a.CompressedOriginalBytes)), //nolint:mnd
+			InPlace: true,
+		})
+	}
+}
+
+func (a *Analysis) suggestSplitter() {
+	// only dynamic splitters named DYNAMIC-<size>-<algorithm> are adjusted.
+	parts := strings.Split(a.Splitter, "-")
+	if len(parts) != 3 || parts[0] != "DYNAMIC" || a.DataContents == 0 { //nolint:mnd
+		return
+	}
+
+	// bytes in contents larger than 1MB.
+	var largeBytes int64
+
+	for _, b := range a.SizeHistogram {
+		if b.MaxSize == 0 || b.MaxSize > 1<<20 {
+			largeBytes += b.Bytes
+		}
+	}
+
+	var suggestedSize, reason string
+
+	switch {
+	case a.DedupRatio >= highDedupRatio && splitterSizeAbove(parts[1], smallerSplitterSize):
+		suggestedSize = smallerSplitterSize
+		reason = fmt.Sprintf("snapshots reference %.1fx the stored data, smaller chunks are likely to deduplicate more of the changes", a.DedupRatio)
+
+	case a.DedupRatio > 0 && a.DedupRatio < lowDedupRatio && a.DataContents >= manyContents &&
+		2*largeBytes > a.DataBytes && splitterSizeAbove(largerSplitterSize, parts[1]):
+		suggestedSize = largerSplitterSize
+		reason = fmt.Sprintf("data deduplicates poorly (%.1fx) and is dominated by large chunks, larger chunks reduce the size of indexes (%v contents)",
+			a.DedupRatio, units.Count(a.DataContents))
+
+	default:
+		return
+	}
+
+	a.Suggestions = append(a.Suggestions, &Suggestion{
+		Parameter: ParameterSplitter,
+		Current:   a.Splitter,
+		Suggested: "DYNAMIC-" + suggestedSize + "-" + parts[2],
+		Reason:    reason + "; changing the splitter prevents deduplication against existing data, apply with 'kopia policy set --global --splitter' when appropriate",
+		InPlace:   false,
+	})
+}
+
+//nolint:gochecknoglobals
+var splitterSizeOrder = []string{"128K", "256K", "512K", "1M", "2M", "4M", "8M"}
+
+func splitterSizeAbove(size, other string) bool {
+	i := indexOf(splitterSizeOrder, size)
+	j := indexOf(splitterSizeOrder, other)
+
+	return i >= 0 && j >= 0 && i > j
+}
+
+func indexOf(s []string, v string) int {
+	for i, x := range s {
+		if x == v {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (a *Analysis) suggestIndexVersion() {
+	if a.IndexVersion >= index.Version2 {
+		return
+	}
+
+	s := &Suggestion{
+		Parameter: ParameterIndexVersion,
+		Current:   fmt.Sprint(a.IndexVersion),
+		Suggested: fmt.Sprint(index.Version2),
+		Reason:    "index version 2 is more compact and supports content compression",
+		InPlace:   a.EpochManagerEnabled,
+	}
+
+	if !a.EpochManagerEnabled {
+		s.Reason += ", upgrade the repository format first with 'kopia repository set-parameters --upgrade'"
+	}
+
+	a.Suggestions = append(a.Suggestions, s)
+}
@@ -0,0 +1,80 @@
+package paramadvisor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestions(t *testing.T) {
+	cases := []struct {
+		desc     string
+		analysis Analysis
+		want     []*Suggestion
+	}{
+		{
+			desc: "compressible data",
+			analysis: Analysis{
+				Compression: "none", IndexVersion: 2, SampledContents: 10, SampledCompressionRatio: 0.3,
+				Splitter: "DYNAMIC-4M-BUZHASH", DataContents: 10, DedupRatio: 1.5,
+			},
+			want: []*Suggestion{{Parameter: ParameterCompression, Current: "none", Suggested: "zstd-fastest", InPlace: true}},
+		},
+		{
+			desc: "incompressible data",
+			analysis: Analysis{
+				Compression: "zstd", IndexVersion: 2, CompressedOriginalBytes: 1000, CompressedPackedBytes: 990,
+				Splitter: "DYNAMIC-4M-BUZHASH", DataContents: 10, DedupRatio: 1.5,
+			},
+			want: []*Suggestion{{Parameter: ParameterCompression, Current: "zstd", Suggested: "none", InPlace: true}},
+		},
+		{
+			desc: "high deduplication",
+			analysis: Analysis{
+				Compression: "none", IndexVersion: 2, Splitter: "DYNAMIC-4M-RABINKARP", DataContents: 10, DedupRatio: 5,
+			},
+			want: []*Suggestion{{Parameter: ParameterSplitter, Current: "DYNAMIC-4M-RABINKARP", Suggested: "DYNAMIC-2M-RABINKARP"}},
+		},
+		{
+			desc: "low deduplication of many large contents",
+			analysis: Analysis{
+				Compression: "none", IndexVersion: 2, Splitter: "DYNAMIC-4M-BUZHASH", DataContents: 2_000_000, DedupRatio: 1.01,
+				DataBytes: 100, SizeHistogram: []*SizeBucket{{MaxSize: 1 << 20, Bytes: 10}, {MaxSize: 4 << 20, Bytes: 40}, {Bytes: 50}},
+			},
+			want: []*Suggestion{{Parameter: ParameterSplitter, Current: "DYNAMIC-4M-BUZHASH", Suggested: "DYNAMIC-8M-BUZHASH"}},
+		},
+		{
+			desc: "old index version",
+			analysis: Analysis{
+				Compression: "none", IndexVersion: 1, EpochManagerEnabled: true, Splitter: "FIXED-4M", DedupRatio: 5,
+			},
+			want: []*Suggestion{{Parameter: ParameterIndexVersion, Current: "1", Suggested: "2", InPlace: true}},
+		},
+		{
+			desc: "old repository format",
+			analysis: Analysis{
+				Compression: "none", IndexVersion: 1, SampledContents: 10, SampledCompressionRatio: 0.3,
+			},
+			want: []*Suggestion{
+				{Parameter: ParameterCompression, Current: "none", Suggested: "zstd-fastest"},
+				{Parameter: ParameterIndexVersion, Current: "1", Suggested: "2"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			a := tc.analysis
+			a.suggestCompression()
+			a.suggestSplitter()
+			a.suggestIndexVersion()
+
+			for _, s := range a.Suggestions {
+				require.NotEmpty(t, s.Reason)
+				s.Reason = ""
+			}
+
+			require.Equal(t, tc.want, a.Suggestions)
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Package remoterestore manages restore requests scheduled by the server and executed by repository clients.
+//
+// Restore requests are stored as manifests labeled with username and hostname of the client
+// which is supposed to execute them, so they are subject to the same access control rules as snapshots.
+// Clients update the manifests to report progress back to the server.
+package remoterestore
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+)
+
+// ManifestType is the type of the manifest used to represent restore requests.
+const ManifestType = "restoreRequest"
+
+// RequestIDLabel is the manifest label holding the stable identifier of a restore request.
+// Manifest IDs change each time the request is updated.
+const RequestIDLabel = "restoreRequestID"
+
+// States of restore requests.
+const (
+	StatePending   = "PENDING"
+	StateRunning   = "RUNNING"
+	StateSucceeded = "SUCCEEDED"
+	StateFailed    = "FAILED"
+	StateCanceled  = "CANCELED"
+)
+
+// ErrNotFound is returned when a restore request is not found.
+var ErrNotFound = errors.New("restore request not found")
+
+// Request describes restore of a snapshot to be executed by a particular client.
+type Request struct {
+	ID string `json:"id"`
+
+	// client which executes the restore.
+	Username string `json:"username"`
+	Hostname string `json:"hostname"`
+
+	SnapshotID manifest.ID `json:"snapshotID"`
+
+	// Source and root entry of the snapshot, populated when the request is created so that
+	// the client does not need access to snapshot manifests of other users.
+	Source    snapshot.SourceInfo `json:"source"`
+	RootEntry *snapshot.DirEntry  `json:"rootEntry,omitempty"`
+
+	// Path within the snapshot to restore, empty restores the entire snapshot.
+	Path string `json:"path,omitempty"`
+
+	// Output describes the destination on the client filesystem and overwrite options.
+	Output restore.FilesystemOutput `json:"output"`
+
+	// Options of the restore, zero RestoreDirEntryAtDepth restores to full depth.
+	Options restore.Options `json:"options"`
+
+	RequestedBy string    `json:"requestedBy,omitempty"`
+	RequestTime time.Time `json:"requestTime"`
+
+	Status Status `json:"status"`
+}
+
+// Status contains the progress of restore request reported by the client.
+type Status struct {
+	State           string        `json:"state"`
+	CancelRequested bool          `json:"cancelRequested,omitempty"`
+	StartTime       *time.Time    `json:"startTime,omitempty"`
+	EndTime         *time.Time    `json:"endTime,omitempty"`
+	UpdateTime      *time.Time    `json:"updateTime,omitempty"`
+	Stats           restore.Stats `json:"stats"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// IsFinished returns true if the request has reached a final state.
+func (s *Status) IsFinished() bool {
+	switch s.State {
+	case StateSucceeded, StateFailed, StateCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate returns an error if the request is not valid.
+func (r *Request) Validate() error {
+	if r.Username == "" || r.Hostname == "" {
+		return errors.New("username and hostname of the client must be provided")
+	}
+
+	if r.SnapshotID == "" {
+		return errors.New("snapshot ID must be provided")
+	}
+
+	if r.Output.TargetPath == "" {
+		return errors.New("target path must be provided")
+	}
+
+	return nil
+}
+
+func (r *Request) labels() map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey:  ManifestType,
+		snapshot.UsernameLabel: r.Username,
+		snapshot.HostnameLabel: r.Hostname,
+		RequestIDLabel:         r.ID,
+	}
+}
+
+// Create validates and stores a new pending restore request, assigning its ID.
+func Create(ctx context.Context, w repo.RepositoryWriter, r *Request) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	var man snapshot.Manifest
+
+	md, err := w.GetManifest(ctx, r.SnapshotID, &man)
+	if err != nil {
+		return errors.Wrap(err, "unable to find snapshot")
+	}
+
+	if md.Labels[manifest.TypeLabelKey] != snapshot.ManifestType || man.RootEntry == nil {
+		return errors.Errorf("%v is not a valid snapshot", r.SnapshotID)
+	}
+
+	if r.Options.RestoreDirEntryAtDepth == 0 {
+		r.Options.RestoreDirEntryAtDepth = math.MaxInt32
+	}
+
+	r.Source = man.Source
+	r.RootEntry = man.RootEntry
+	r.ID = uuid.NewString()
+	r.RequestTime = clock.Now()
+	r.Status = Status{State: StatePending}
+
+	return Update(ctx, w, r)
+}
+
+// Update stores the provided restore request, replacing its previous version.
+func Update(ctx context.Context, w repo.RepositoryWriter, r *Request) error {
+	if _, err := w.ReplaceManifests(ctx, r.labels(), r); err != nil {
+		return errors.Wrap(err, "error writing restore request")
+	}
+
+	return nil
+}
+
+// Get returns the restore request with the provided ID.
+func Get(ctx context.Context, rep repo.Repository, id string) (*Request, error) {
+	entries, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+		RequestIDLabel:        id,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error looking for restore request")
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.Wrap(ErrNotFound, id)
+	}
+
+	r := &Request{}
+	if _, err := rep.GetManifest(ctx, manifest.PickLatestID(entries), r); err != nil {
+		return nil, errors.Wrap(err, "error loading restore request")
+	}
+
+	return r, nil
+}
+
+// List returns restore requests to be executed by the provided client, empty username or hostname matches all.
+func List(ctx context.Context, rep repo.Repository, username, hostname string) ([]*Request, error) {
+	labels := map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	}
+
+	if username != "" {
+		labels[snapshot.UsernameLabel] = username
+	}
+
+	if hostname != "" {
+		labels[snapshot.HostnameLabel] = hostname
+	}
+
+	entries, err := rep.FindManifests(ctx, labels)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing restore requests")
+	}
+
+	result := []*Request{}
+
+	for _, e := range manifest.DedupeEntryMetadataByLabel(entries, RequestIDLabel) {
+		r := &Request{}
+		if _, err := rep.GetManifest(ctx, e.ID, r); err != nil {
+			return nil, errors.Wrap(err, "error loading restore request")
+		}
+
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RequestTime.Before(result[j].RequestTime)
+	})
+
+	return result, nil
+}
+
+// Cancel cancels the restore request. Pending requests are canceled immediately,
+// running requests are canceled by the client when it next reports progress.
+func Cancel(ctx context.Context, w repo.RepositoryWriter, id string) (*Request, error) {
+	r, err := Get(ctx, w, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Status.State {
+	case StatePending:
+		now := clock.Now()
+		r.Status.State = StateCanceled
+		r.Status.EndTime = &now
+
+	case StateRunning:
+		r.Status.CancelRequested = true
+
+	default:
+		return r, nil
+	}
+
+	return r, Update(ctx, w, r)
+}
+
+// Delete removes the restore request.
+func Delete(ctx context.Context, w repo.RepositoryWriter, id string) error {
+	entries, err := w.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+		RequestIDLabel:        id,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error looking for restore request")
+	}
+
+	if len(entries) == 0 {
+		return errors.Wrap(ErrNotFound, id)
+	}
+
+	for _, e := range entries {
+		if err := w.DeleteManifest(ctx, e.ID); err != nil {
+			return errors.Wrap(err, "error deleting restore request")
+		}
+	}
+
+	return nil
+}
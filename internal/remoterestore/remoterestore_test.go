@@ -0,0 +1,139 @@
+package remoterestore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/remoterestore"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func TestRemoteRestore(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	sourceRoot := mockfs.NewDirectory()
+	dir1 := sourceRoot.AddDir("dir1", 0o755)
+	dir1.AddFile("file11", []byte{1, 2, 3}, 0o644)
+	dir1.AddDir("dir2", 0o755).AddFile("file21", []byte{1, 2, 3, 4}, 0o644)
+	sourceRoot.AddFile("file1", []byte{1}, 0o644)
+
+	src := snapshot.SourceInfo{Host: "other-host", UserName: "other-user", Path: "/dummy"}
+
+	man, err := snapshotfs.NewUploader(env.RepositoryWriter).Upload(ctx, sourceRoot, nil, src)
+	require.NoError(t, err)
+
+	snapID, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, man)
+	require.NoError(t, err)
+
+	username := env.Repository.ClientOptions().Username
+	hostname := env.Repository.ClientOptions().Hostname
+
+	// invalid requests
+	require.Error(t, remoterestore.Create(ctx, env.RepositoryWriter, &remoterestore.Request{
+		Username:   username,
+		Hostname:   hostname,
+		SnapshotID: snapID,
+	}))
+	require.Error(t, remoterestore.Create(ctx, env.RepositoryWriter, &remoterestore.Request{
+		Username:   username,
+		Hostname:   hostname,
+		SnapshotID: "no-such-snapshot",
+		Output:     restore.FilesystemOutput{TargetPath: t.TempDir()},
+	}))
+
+	targetDir := t.TempDir()
+
+	r1 := &remoterestore.Request{
+		Username:   username,
+		Hostname:   hostname,
+		SnapshotID: snapID,
+		Path:       "dir1",
+		Output:     restore.FilesystemOutput{TargetPath: targetDir},
+	}
+	require.NoError(t, remoterestore.Create(ctx, env.RepositoryWriter, r1))
+	require.NotEmpty(t, r1.ID)
+	require.Equal(t, remoterestore.StatePending, r1.Status.State)
+	require.Equal(t, src, r1.Source)
+
+	// request for another client
+	r2 := &remoterestore.Request{
+		Username:   "another-user",
+		Hostname:   hostname,
+		SnapshotID: snapID,
+		Output:     restore.FilesystemOutput{TargetPath: t.TempDir()},
+	}
+	require.NoError(t, remoterestore.Create(ctx, env.RepositoryWriter, r2))
+
+	// canceled before it was started
+	r3 := &remoterestore.Request{
+		Username:   username,
+		Hostname:   hostname,
+		SnapshotID: snapID,
+		Output:     restore.FilesystemOutput{TargetPath: t.TempDir()},
+	}
+	require.NoError(t, remoterestore.Create(ctx, env.RepositoryWriter, r3))
+
+	r3c, err := remoterestore.Cancel(ctx, env.RepositoryWriter, r3.ID)
+	require.NoError(t, err)
+	require.Equal(t, remoterestore.StateCanceled, r3c.Status.State)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	all, err := remoterestore.List(ctx, env.RepositoryWriter, "", "")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	mine, err := remoterestore.List(ctx, env.RepositoryWriter, username, hostname)
+	require.NoError(t, err)
+	require.Len(t, mine, 2)
+	require.Equal(t, r1.ID, mine[0].ID)
+	require.Equal(t, r3.ID, mine[1].ID)
+
+	n, err := remoterestore.ProcessPending(ctx, env.RepositoryWriter, username, hostname)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	got, err := remoterestore.Get(ctx, env.RepositoryWriter, r1.ID)
+	require.NoError(t, err)
+	require.Equal(t, remoterestore.StateSucceeded, got.Status.State, got.Status.Error)
+	require.True(t, got.Status.IsFinished())
+	require.NotNil(t, got.Status.EndTime)
+	require.Equal(t, int32(2), got.Status.Stats.RestoredFileCount)
+
+	b, err := os.ReadFile(filepath.Join(targetDir, "dir2", "file21"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 4}, b)
+
+	// nothing left to do
+	n, err = remoterestore.ProcessPending(ctx, env.RepositoryWriter, username, hostname)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// restore of a path that does not exist fails
+	r4 := &remoterestore.Request{
+		Username:   username,
+		Hostname:   hostname,
+		SnapshotID: snapID,
+		Path:       "no-such-dir",
+		Output:     restore.FilesystemOutput{TargetPath: t.TempDir()},
+	}
+	require.NoError(t, remoterestore.Create(ctx, env.RepositoryWriter, r4))
+	require.Error(t, remoterestore.Execute(ctx, env.RepositoryWriter, r4))
+
+	got, err = remoterestore.Get(ctx, env.RepositoryWriter, r4.ID)
+	require.NoError(t, err)
+	require.Equal(t, remoterestore.StateFailed, got.Status.State)
+	require.NotEmpty(t, got.Status.Error)
+
+	require.NoError(t, remoterestore.Delete(ctx, env.RepositoryWriter, r4.ID))
+
+	_, err = remoterestore.Get(ctx, env.RepositoryWriter, r4.ID)
+	require.ErrorIs(t, err, remoterestore.ErrNotFound)
+	require.ErrorIs(t, remoterestore.Delete(ctx, env.RepositoryWriter, r4.ID), remoterestore.ErrNotFound)
+}
@@ -0,0 +1,182 @@
+package remoterestore
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+var log = logging.Module("remoterestore")
+
+// ProgressReportInterval is the interval between progress updates written to the repository.
+//
+//nolint:gochecknoglobals
+var ProgressReportInterval = 5 * time.Second
+
+// ProcessPending executes all pending restore requests for the provided client and returns the number of requests executed.
+// Failures of individual restores are reported in their status.
+func ProcessPending(ctx context.Context, rep repo.Repository, username, hostname string) (int, error) {
+	requests, err := List(ctx, rep, username, hostname)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+
+	for _, r := range requests {
+		if r.Status.State != StatePending {
+			continue
+		}
+
+		cnt++
+
+		log(ctx).Infof("executing restore request %v of %v to %v", r.ID, r.SnapshotID, r.Output.TargetPath)
+
+		if err := Execute(ctx, rep, r); err != nil {
+			log(ctx).Errorf("restore request %v failed: %v", r.ID, err)
+		}
+	}
+
+	return cnt, nil
+}
+
+// Execute restores the snapshot to the local filesystem as described by the request
+// and periodically reports progress by updating the request in the repository.
+func Execute(ctx context.Context, rep repo.Repository, r *Request) error {
+	now := clock.Now()
+
+	r.Status = Status{
+		State:      StateRunning,
+		StartTime:  &now,
+		UpdateTime: &now,
+	}
+
+	if _, err := updateStatus(ctx, rep, r); err != nil {
+		return err
+	}
+
+	var (
+		mu        sync.Mutex
+		lastStats restore.Stats
+		cancel    = make(chan struct{})
+		done      = make(chan struct{})
+		canceled  bool
+		reporting sync.WaitGroup
+	)
+
+	reporting.Add(1)
+
+	go func() {
+		defer reporting.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(ProgressReportInterval):
+			}
+
+			mu.Lock()
+			r.Status.Stats = lastStats
+			mu.Unlock()
+
+			cancelRequested, err := updateStatus(ctx, rep, r)
+			if err != nil {
+				log(ctx).Errorf("unable to report restore progress: %v", err)
+				continue
+			}
+
+			if cancelRequested && !canceled {
+				log(ctx).Infof("restore request %v canceled", r.ID)
+
+				canceled = true
+
+				close(cancel)
+			}
+		}
+	}()
+
+	opt := r.Options
+	opt.Cancel = cancel
+	opt.ProgressCallback = func(_ context.Context, s restore.Stats) {
+		mu.Lock()
+		lastStats = s
+		mu.Unlock()
+	}
+
+	st, err := run(ctx, rep, r, opt)
+
+	close(done)
+	reporting.Wait()
+
+	end := clock.Now()
+	r.Status.EndTime = &end
+
+	switch {
+	case err != nil:
+		r.Status.State = StateFailed
+		r.Status.Error = err.Error()
+
+	case canceled:
+		r.Status.State = StateCanceled
+		r.Status.Stats = st
+
+	default:
+		r.Status.State = StateSucceeded
+		r.Status.Stats = st
+	}
+
+	if _, uerr := updateStatus(ctx, rep, r); uerr != nil {
+		return uerr
+	}
+
+	return err
+}
+
+func run(ctx context.Context, rep repo.Repository, r *Request, opt restore.Options) (restore.Stats, error) {
+	if r.RootEntry == nil {
+		return restore.Stats{}, errors.New("missing snapshot root")
+	}
+
+	entry, err := snapshotfs.GetNestedEntry(ctx, snapshotfs.EntryFromDirEntry(rep, r.RootEntry), strings.Split(r.Path, "/"))
+	if err != nil {
+		return restore.Stats{}, errors.Wrapf(err, "unable to find %q in snapshot", r.Path)
+	}
+
+	out := r.Output
+	if err := out.Init(ctx); err != nil {
+		return restore.Stats{}, errors.Wrap(err, "unable to initialize output")
+	}
+
+	st, err := restore.Entry(ctx, rep, &out, entry, opt)
+
+	return st, errors.Wrap(err, "error restoring")
+}
+
+// updateStatus writes the request with updated status to the repository, preserving cancellation
+// requested by the server in the meantime, and returns whether cancellation was requested.
+func updateStatus(ctx context.Context, rep repo.Repository, r *Request) (bool, error) {
+	err := repo.WriteSession(ctx, rep, repo.WriteSessionOptions{
+		Purpose: "RestoreRequestStatus",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		if cur, err := Get(ctx, w, r.ID); err == nil && cur.Status.CancelRequested {
+			r.Status.CancelRequested = true
+		}
+
+		now := clock.Now()
+		r.Status.UpdateTime = &now
+
+		return Update(ctx, w, r)
+	})
+
+	return r.Status.CancelRequested, errors.Wrap(err, "unable to update restore request status")
+}
@@ -0,0 +1,579 @@
+// Package replication implements incremental replication of repository blobs to secondary storage.
+//
+// Blobs are copied in an order which keeps the secondary repository consistent at all times: pack blobs
+// are copied first, followed by indexes and other metadata, with repository format blobs copied last.
+// Blobs which have been replicated along with their checksums are recorded in a local state file, so
+// subsequent cycles only transfer new or changed blobs and don't need to list the destination.
+//
+// In case of failover, the secondary repository can be connected to directly and contains all blobs
+// which existed in the source repository at the time reported as Status.ConsistentAsOf.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("kopia/replication")
+
+const (
+	// DefaultInterval is the default interval between replication cycles in continuous mode.
+	DefaultInterval = 5 * time.Minute
+
+	defaultParallel = 4
+)
+
+// Options provides configuration of replication.
+type Options struct {
+	// Parallel is the number of blobs copied in parallel.
+	Parallel int
+
+	// Delete causes blobs deleted from the source to also be deleted from the destination.
+	Delete bool
+
+	// VerifyChecksums causes each copied blob to be read back from the destination and compared
+	// against the checksum of the source.
+	VerifyChecksums bool
+
+	// StateFile is the name of the JSON file storing the replication state, empty if not persisted.
+	StateFile string
+
+	// Interval between replication cycles in continuous mode.
+	Interval time.Duration
+
+	// OnCycle, when set, is invoked with the current status after each replication cycle.
+	OnCycle func(s Status)
+}
+
+// ReplicatedBlob describes a blob which has been copied to the destination.
+type ReplicatedBlob struct {
+	Length    int64     `json:"length"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// SHA256 is the checksum of blob contents, empty for blobs which were found in the destination.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// State describes the replicated blobs, which is persisted across restarts.
+type State struct {
+	Blobs map[blob.ID]ReplicatedBlob `json:"blobs"`
+
+	LastSuccessfulCycleStart time.Time `json:"lastSuccessfulCycleStart"`
+	LastSuccessfulCycleEnd   time.Time `json:"lastSuccessfulCycleEnd"`
+}
+
+// Status describes the current state of replication.
+type Status struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Running     bool   `json:"running"`
+
+	ReplicatedBlobs int   `json:"replicatedBlobs"`
+	ReplicatedBytes int64 `json:"replicatedBytes"`
+
+	PendingBlobs int   `json:"pendingBlobs"`
+	PendingBytes int64 `json:"pendingBytes"`
+
+	LastCycleStart        time.Time `json:"lastCycleStart"`
+	LastCycleEnd          time.Time `json:"lastCycleEnd"`
+	LastCycleCopiedBlobs  int       `json:"lastCycleCopiedBlobs"`
+	LastCycleCopiedBytes  int64     `json:"lastCycleCopiedBytes"`
+	LastCycleDeletedBlobs int       `json:"lastCycleDeletedBlobs"`
+
+	// ConsistentAsOf is the time as of which the destination contains all blobs of the source,
+	// zero if replication has never completed.
+	ConsistentAsOf time.Time `json:"consistentAsOf"`
+
+	// Lag is the time elapsed since ConsistentAsOf.
+	Lag time.Duration `json:"lag"`
+
+	LastErrorTime time.Time `json:"lastErrorTime"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// Replicator copies blobs from the source to the destination storage.
+type Replicator struct {
+	src blob.Reader
+	dst blob.Storage
+	opt Options
+
+	mu sync.Mutex
+	// +checklocks:mu
+	state State
+	// +checklocks:mu
+	status Status
+}
+
+// New creates a replicator between the provided storages, loading previously persisted state, if any.
+func New(src blob.Reader, dst blob.Storage, opt Options) (*Replicator, error) {
+	if opt.Parallel <= 0 {
+		opt.Parallel = defaultParallel
+	}
+
+	if opt.Interval <= 0 {
+		opt.Interval = DefaultInterval
+	}
+
+	r := &Replicator{
+		src: src,
+		dst: dst,
+		opt: opt,
+	}
+
+	r.state.Blobs = map[blob.ID]ReplicatedBlob{}
+	r.status.Source = src.DisplayName()
+	r.status.Destination = dst.DisplayName()
+
+	if err := r.loadState(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Replicator) loadState() error {
+	if r.opt.StateFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(r.opt.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to read replication state")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := json.Unmarshal(b, &r.state); err != nil {
+		return errors.Wrap(err, "invalid replication state")
+	}
+
+	if r.state.Blobs == nil {
+		r.state.Blobs = map[blob.ID]ReplicatedBlob{}
+	}
+
+	return nil
+}
+
+func (r *Replicator) saveState() error {
+	if r.opt.StateFile == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.Marshal(r.state)
+	r.mu.Unlock()
+
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal replication state")
+	}
+
+	return errors.Wrap(atomic.WriteFile(r.opt.StateFile, bytes.NewReader(b)), "unable to write replication state")
+}
+
+// Status returns the current status of replication.
+func (r *Replicator) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.status
+	s.ReplicatedBlobs = len(r.state.Blobs)
+	s.ReplicatedBytes = 0
+
+	for _, rb := range r.state.Blobs {
+		s.ReplicatedBytes += rb.Length
+	}
+
+	s.ConsistentAsOf = r.state.LastSuccessfulCycleStart
+	if !s.ConsistentAsOf.IsZero() {
+		s.Lag = clock.Now().Sub(s.ConsistentAsOf)
+	}
+
+	return s
+}
+
+// Run performs replication cycles at the configured interval until the context is canceled.
+// Errors of individual cycles are logged and reported in the status, after which replication is retried.
+func (r *Replicator) Run(ctx context.Context) error {
+	for {
+		if err := r.RunCycle(ctx); err != nil {
+			if ctx.Err() != nil {
+				return errors.Wrap(ctx.Err(), "replication canceled")
+			}
+
+			log(ctx).Errorf("replication cycle failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "replication canceled")
+
+		case <-time.After(r.opt.Interval):
+		}
+	}
+}
+
+// RunCycle copies all new or changed blobs to the destination and optionally deletes blobs removed from the source.
+func (r *Replicator) RunCycle(ctx context.Context) error {
+	r.mu.Lock()
+	if r.status.Running {
+		r.mu.Unlock()
+		return errors.New("replication is already running")
+	}
+
+	start := clock.Now()
+
+	r.status.Running = true
+	r.status.LastCycleStart = start
+	r.status.LastCycleCopiedBlobs = 0
+	r.status.LastCycleCopiedBytes = 0
+	r.status.LastCycleDeletedBlobs = 0
+	r.mu.Unlock()
+
+	err := r.runCycle(ctx, start)
+
+	r.mu.Lock()
+	r.status.Running = false
+	r.status.LastCycleEnd = clock.Now()
+
+	if err != nil {
+		r.status.LastError = err.Error()
+		r.status.LastErrorTime = clock.Now()
+	} else {
+		r.state.LastSuccessfulCycleStart = start
+		r.state.LastSuccessfulCycleEnd = clock.Now()
+	}
+	r.mu.Unlock()
+
+	if serr := r.saveState(); serr != nil && err == nil {
+		err = serr
+	}
+
+	if r.opt.OnCycle != nil {
+		r.opt.OnCycle(r.Status())
+	}
+
+	return err
+}
+
+func (r *Replicator) runCycle(ctx context.Context, start time.Time) error {
+	if err := r.ensureSameRepository(ctx); err != nil {
+		return err
+	}
+
+	if err := r.maybeSeedFromDestination(ctx); err != nil {
+		return err
+	}
+
+	toCopy, toDelete, err := r.findChanges(ctx)
+	if err != nil {
+		return err
+	}
+
+	log(ctx).Debugf("replication cycle started at %v: %v blobs to copy, %v to delete", start, len(toCopy), len(toDelete))
+
+	for _, phase := range groupByPhase(toCopy) {
+		if err := r.copyBlobs(ctx, phase); err != nil {
+			return err
+		}
+
+		if err := r.saveState(); err != nil {
+			return err
+		}
+	}
+
+	return r.deleteBlobs(ctx, toDelete)
+}
+
+// ensureSameRepository verifies that the destination does not contain a different repository.
+func (r *Replicator) ensureSameRepository(ctx context.Context) error {
+	var srcData, dstData gather.WriteBuffer
+	defer srcData.Close()
+	defer dstData.Close()
+
+	if err := r.src.GetBlob(ctx, format.KopiaRepositoryBlobID, 0, -1, &srcData); err != nil {
+		return errors.Wrap(err, "error reading format blob")
+	}
+
+	if err := r.dst.GetBlob(ctx, format.KopiaRepositoryBlobID, 0, -1, &dstData); err != nil {
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			// format blob will be replicated last.
+			return nil
+		}
+
+		return errors.Wrap(err, "error reading destination format blob")
+	}
+
+	srcID, err := uniqueID(srcData.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "error parsing unique ID of source repository")
+	}
+
+	dstID, err := uniqueID(dstData.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "error parsing unique ID of destination repository")
+	}
+
+	if srcID != dstID {
+		return errors.New("destination repository contains incompatible data")
+	}
+
+	return nil
+}
+
+func uniqueID(b gather.Bytes) (string, error) {
+	var f struct {
+		UniqueID string `json:"uniqueID"`
+	}
+
+	if err := json.NewDecoder(b.Reader()).Decode(&f); err != nil {
+		return "", errors.Wrap(err, "invalid JSON")
+	}
+
+	if f.UniqueID == "" {
+		return "", errors.New("unique ID not found")
+	}
+
+	return f.UniqueID, nil
+}
+
+// maybeSeedFromDestination records blobs already present in the destination when there's no replication state,
+// so that replication can resume after the state was lost or the destination was populated by other means.
+func (r *Replicator) maybeSeedFromDestination(ctx context.Context) error {
+	r.mu.Lock()
+	hasState := len(r.state.Blobs) > 0
+	r.mu.Unlock()
+
+	if hasState {
+		return nil
+	}
+
+	seeded := map[blob.ID]ReplicatedBlob{}
+
+	if err := r.dst.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		seeded[bm.BlobID] = ReplicatedBlob{Length: bm.Length, Timestamp: bm.Timestamp}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error listing destination blobs")
+	}
+
+	log(ctx).Debugf("found %v blobs in the destination", len(seeded))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state.Blobs = seeded
+
+	return nil
+}
+
+// findChanges lists the source and returns blobs which need to be copied and deleted.
+func (r *Replicator) findChanges(ctx context.Context) (toCopy, toDelete []blob.Metadata, err error) {
+	var srcBlobs []blob.Metadata
+
+	if err := r.src.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		srcBlobs = append(srcBlobs, bm)
+		return nil
+	}); err != nil {
+		return nil, nil, errors.Wrap(err, "error listing source blobs")
+	}
+
+	seen := map[blob.ID]bool{}
+
+	var pendingBytes int64
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, bm := range srcBlobs {
+		seen[bm.BlobID] = true
+
+		rb, ok := r.state.Blobs[bm.BlobID]
+		if !ok || rb.Length != bm.Length || bm.Timestamp.After(rb.Timestamp) {
+			toCopy = append(toCopy, bm)
+			pendingBytes += bm.Length
+		}
+	}
+
+	if r.opt.Delete {
+		for id, rb := range r.state.Blobs {
+			if !seen[id] {
+				toDelete = append(toDelete, blob.Metadata{BlobID: id, Length: rb.Length})
+			}
+		}
+	}
+
+	r.status.PendingBlobs = len(toCopy)
+	r.status.PendingBytes = pendingBytes
+
+	return toCopy, toDelete, nil
+}
+
+// groupByPhase splits blobs into groups which must be copied in order to keep the destination consistent.
+func groupByPhase(bms []blob.Metadata) [][]blob.Metadata {
+	const numPhases = 3
+
+	phases := make([][]blob.Metadata, numPhases)
+
+	for _, bm := range bms {
+		p := phaseOf(bm.BlobID)
+		phases[p] = append(phases[p], bm)
+	}
+
+	for _, p := range phases {
+		sort.Slice(p, func(i, j int) bool {
+			return p[i].BlobID < p[j].BlobID
+		})
+	}
+
+	return phases
+}
+
+func phaseOf(id blob.ID) int {
+	switch {
+	case strings.HasPrefix(string(id), "kopia."):
+		// format and blob configuration blobs.
+		return 2 //nolint:mnd
+
+	case strings.HasPrefix(string(id), "p"), strings.HasPrefix(string(id), "q"):
+		// pack blobs.
+		return 0
+
+	default:
+		// indexes and other metadata referencing packs.
+		return 1
+	}
+}
+
+func (r *Replicator) copyBlobs(ctx context.Context, bms []blob.Metadata) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	ch := make(chan blob.Metadata)
+
+	eg.Go(func() error {
+		defer close(ch)
+
+		for _, bm := range bms {
+			select {
+			case ch <- bm:
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err(), "canceled")
+			}
+		}
+
+		return nil
+	})
+
+	for range r.opt.Parallel {
+		eg.Go(func() error {
+			for bm := range ch {
+				if err := r.copyBlob(ctx, bm); err != nil {
+					return errors.Wrapf(err, "error replicating %v", bm.BlobID)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return errors.Wrap(eg.Wait(), "error copying blobs")
+}
+
+func (r *Replicator) copyBlob(ctx context.Context, bm blob.Metadata) error {
+	var data gather.WriteBuffer
+	defer data.Close()
+
+	if err := r.src.GetBlob(ctx, bm.BlobID, 0, -1, &data); err != nil {
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			// blob was deleted from the source after it was listed.
+			return nil
+		}
+
+		return errors.Wrap(err, "error reading source blob")
+	}
+
+	h := sha256.New()
+	data.Bytes().WriteTo(h) //nolint:errcheck
+
+	checksum := hex.EncodeToString(h.Sum(nil))
+
+	if err := r.dst.PutBlob(ctx, bm.BlobID, data.Bytes(), blob.PutOptions{}); err != nil {
+		return errors.Wrap(err, "error writing destination blob")
+	}
+
+	if r.opt.VerifyChecksums {
+		if err := r.verifyChecksum(ctx, bm.BlobID, checksum); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state.Blobs[bm.BlobID] = ReplicatedBlob{
+		Length:    int64(data.Length()),
+		Timestamp: bm.Timestamp,
+		SHA256:    checksum,
+	}
+
+	r.status.LastCycleCopiedBlobs++
+	r.status.LastCycleCopiedBytes += int64(data.Length())
+	r.status.PendingBlobs--
+	r.status.PendingBytes -= bm.Length
+
+	return nil
+}
+
+func (r *Replicator) verifyChecksum(ctx context.Context, id blob.ID, want string) error {
+	var data gather.WriteBuffer
+	defer data.Close()
+
+	if err := r.dst.GetBlob(ctx, id, 0, -1, &data); err != nil {
+		return errors.Wrap(err, "error reading back destination blob")
+	}
+
+	h := sha256.New()
+	data.Bytes().WriteTo(h) //nolint:errcheck
+
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return errors.Errorf("checksum mismatch: %v, expected %v", got, want)
+	}
+
+	return nil
+}
+
+func (r *Replicator) deleteBlobs(ctx context.Context, bms []blob.Metadata) error {
+	for _, bm := range bms {
+		if err := r.dst.DeleteBlob(ctx, bm.BlobID); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return errors.Wrapf(err, "error deleting %v", bm.BlobID)
+		}
+
+		r.mu.Lock()
+		delete(r.state.Blobs, bm.BlobID)
+		r.status.LastCycleDeletedBlobs++
+		r.mu.Unlock()
+	}
+
+	return nil
+}
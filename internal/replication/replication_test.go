@@ -0,0 +1,99 @@
+package replication_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/replication"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+)
+
+// putRecordingStorage records the order in which blobs are written.
+type putRecordingStorage struct {
+	blob.Storage
+
+	mu  sync.Mutex
+	ids []blob.ID
+}
+
+func (s *putRecordingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	s.mu.Lock()
+	s.ids = append(s.ids, id)
+	s.mu.Unlock()
+
+	return s.Storage.PutBlob(ctx, id, data, opts)
+}
+
+func TestReplication(t *testing.T) {
+	ctx := testlogging.Context(t)
+	stateFile := filepath.Join(testutil.TempDirectory(t), "replication.json")
+
+	srcData := blobtesting.DataMap{}
+	dstData := blobtesting.DataMap{}
+
+	src := blobtesting.NewMapStorage(srcData, nil, nil)
+	dst := &putRecordingStorage{Storage: blobtesting.NewMapStorage(dstData, nil, nil)}
+
+	for _, id := range []blob.ID{format.KopiaRepositoryBlobID, "xn0_abc", "p123", "q456"} {
+		require.NoError(t, src.PutBlob(ctx, id, gather.FromSlice([]byte(`{"uniqueID":"AAAA"}`)), blob.PutOptions{}))
+	}
+
+	opt := replication.Options{Parallel: 1, StateFile: stateFile, Delete: true}
+
+	r, err := replication.New(src, dst, opt)
+	require.NoError(t, err)
+	require.NoError(t, r.RunCycle(ctx))
+
+	// packs are copied first, format blob last.
+	require.Equal(t, []blob.ID{"p123", "q456", "xn0_abc", format.KopiaRepositoryBlobID}, dst.ids)
+	require.Equal(t, srcData, dstData)
+
+	s := r.Status()
+	require.Equal(t, 4, s.ReplicatedBlobs)
+	require.Equal(t, 4, s.LastCycleCopiedBlobs)
+	require.Zero(t, s.PendingBlobs)
+	require.False(t, s.ConsistentAsOf.IsZero())
+
+	// replication resumes from persisted state and only copies new blobs.
+	require.NoError(t, src.PutBlob(ctx, "p789", gather.FromSlice([]byte("new")), blob.PutOptions{}))
+	require.NoError(t, src.DeleteBlob(ctx, "q456"))
+
+	dst.ids = nil
+
+	r, err = replication.New(src, dst, opt)
+	require.NoError(t, err)
+	require.NoError(t, r.RunCycle(ctx))
+	require.Equal(t, []blob.ID{"p789"}, dst.ids)
+	require.Equal(t, srcData, dstData)
+
+	s = r.Status()
+	require.Equal(t, 1, s.LastCycleCopiedBlobs)
+	require.Equal(t, 1, s.LastCycleDeletedBlobs)
+	require.Equal(t, 4, s.ReplicatedBlobs)
+}
+
+func TestReplicationIncompatibleDestination(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	src := blobtesting.NewMapStorage(blobtesting.DataMap{
+		format.KopiaRepositoryBlobID: []byte(`{"uniqueID":"AAAA"}`),
+	}, nil, nil)
+	dst := blobtesting.NewMapStorage(blobtesting.DataMap{
+		format.KopiaRepositoryBlobID: []byte(`{"uniqueID":"BBBB"}`),
+	}, nil, nil)
+
+	r, err := replication.New(src, dst, replication.Options{})
+	require.NoError(t, err)
+	require.ErrorContains(t, r.RunCycle(ctx), "incompatible")
+	require.NotEmpty(t, r.Status().LastError)
+	require.True(t, r.Status().ConsistentAsOf.IsZero())
+}
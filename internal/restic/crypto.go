@@ -0,0 +1,135 @@
+package restic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/poly1305" //nolint:staticcheck
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	aesKeySize = 32
+	macKeySize = 32 // K (16 bytes) followed by R (16 bytes)
+	ivSize     = aes.BlockSize
+	macSize    = poly1305.TagSize
+
+	// overhead of each encrypted file or blob.
+	cipherOverhead = ivSize + macSize
+)
+
+var errUnauthenticated = errors.New("ciphertext verification failed")
+
+// macKey is the Poly1305-AES key as used by restic.
+type macKey struct {
+	K [16]byte
+	R [16]byte
+}
+
+// key is a pair of encryption and authentication keys.
+type key struct {
+	MAC     macKey
+	Encrypt [aesKeySize]byte
+}
+
+// jsonMasterKey is the JSON representation of the master key stored encrypted in key files.
+type jsonMasterKey struct {
+	MAC struct {
+		K []byte `json:"k"`
+		R []byte `json:"r"`
+	} `json:"mac"`
+	Encrypt []byte `json:"encrypt"`
+}
+
+func (k *key) UnmarshalJSON(data []byte) error {
+	var jk jsonMasterKey
+
+	if err := json.Unmarshal(data, &jk); err != nil {
+		return errors.Wrap(err, "invalid master key")
+	}
+
+	if len(jk.MAC.K) != len(k.MAC.K) || len(jk.MAC.R) != len(k.MAC.R) || len(jk.Encrypt) != len(k.Encrypt) {
+		return errors.New("invalid master key length")
+	}
+
+	copy(k.MAC.K[:], jk.MAC.K)
+	copy(k.MAC.R[:], jk.MAC.R)
+	copy(k.Encrypt[:], jk.Encrypt)
+
+	return nil
+}
+
+func (k *key) MarshalJSON() ([]byte, error) {
+	var jk jsonMasterKey
+
+	jk.MAC.K = k.MAC.K[:]
+	jk.MAC.R = k.MAC.R[:]
+	jk.Encrypt = k.Encrypt[:]
+
+	//nolint:wrapcheck
+	return json.Marshal(jk)
+}
+
+// deriveKey derives the user key from the password using scrypt, the way restic does.
+func deriveKey(password string, salt []byte, n, r, p int) (*key, error) {
+	b, err := scrypt.Key([]byte(password), salt, n, r, p, aesKeySize+macKeySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "scrypt")
+	}
+
+	k := &key{}
+
+	copy(k.Encrypt[:], b[0:aesKeySize])
+	copy(k.MAC.K[:], b[aesKeySize:aesKeySize+16])
+	copy(k.MAC.R[:], b[aesKeySize+16:])
+
+	return k, nil
+}
+
+func (k *key) mac(nonce, data []byte) [macSize]byte {
+	var (
+		polyKey [32]byte
+		out     [macSize]byte
+	)
+
+	c, err := aes.NewCipher(k.MAC.K[:])
+	if err != nil {
+		panic("invalid MAC key: " + err.Error())
+	}
+
+	c.Encrypt(polyKey[16:], nonce)
+	copy(polyKey[0:16], k.MAC.R[:])
+
+	poly1305.Sum(&out, data, &polyKey)
+
+	return out
+}
+
+// decrypt verifies and decrypts data in the format IV || AES-256-CTR(plaintext) || Poly1305-AES(ciphertext).
+func (k *key) decrypt(data []byte) ([]byte, error) {
+	if len(data) < cipherOverhead {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	iv := data[0:ivSize]
+	ciphertext := data[ivSize : len(data)-macSize]
+	tag := data[len(data)-macSize:]
+
+	expected := k.mac(iv, ciphertext)
+	if subtle.ConstantTimeCompare(expected[:], tag) != 1 {
+		return nil, errUnauthenticated
+	}
+
+	c, err := aes.NewCipher(k.Encrypt[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid encryption key")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(c, iv).XORKeyStream(plaintext, ciphertext)
+
+	return plaintext, nil
+}
@@ -0,0 +1,303 @@
+package restic
+
+import (
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const (
+	nodeTypeFile    = "file"
+	nodeTypeDir     = "dir"
+	nodeTypeSymlink = "symlink"
+)
+
+// PathComponents splits an absolute path stored in a restic snapshot into names of nested tree nodes,
+// the way restic stores them ("/home/user" becomes [home user] and `C:\Users` becomes [C Users]).
+func PathComponents(p string) []string {
+	p = strings.ReplaceAll(p, "\\", "/")
+
+	var result []string
+
+	for i, c := range strings.Split(p, "/") {
+		if i == 0 && len(c) == 2 && c[1] == ':' { //nolint:mnd
+			// drive letter
+			c = c[0:1]
+		}
+
+		if c != "" && c != "." {
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// SnapshotEntry returns the filesystem entry for the provided path of the snapshot, which must
+// be one of the snapshot paths or a path nested in one of them.
+func (r *Repository) SnapshotEntry(snap *Snapshot, snapshotPath string) (fs.Entry, error) {
+	components := PathComponents(snapshotPath)
+
+	// synthesize node for the root of the tree.
+	cur := &Node{
+		Name:    "/",
+		Type:    nodeTypeDir,
+		Mode:    os.ModeDir | 0o755, //nolint:mnd
+		ModTime: snap.Time,
+		UID:     snap.UID,
+		GID:     snap.GID,
+		Subtree: snap.Tree,
+	}
+
+	for _, c := range components {
+		if cur.Type != nodeTypeDir {
+			return nil, errors.Errorf("%q is not a directory in snapshot %v", cur.Name, snap.ShortID())
+		}
+
+		t, err := r.LoadTree(cur.Subtree)
+		if err != nil {
+			return nil, err
+		}
+
+		var next *Node
+
+		for _, n := range t.Nodes {
+			if n.Name == c {
+				next = n
+				break
+			}
+		}
+
+		if next == nil {
+			return nil, errors.Errorf("path %q not found in snapshot %v", snapshotPath, snap.ShortID())
+		}
+
+		cur = next
+	}
+
+	return r.newEntry(cur), nil
+}
+
+func (r *Repository) newEntry(n *Node) fs.Entry {
+	e := entry{r, n}
+
+	switch n.Type {
+	case nodeTypeDir:
+		return &directory{e}
+
+	case nodeTypeFile:
+		return &file{e}
+
+	case nodeTypeSymlink:
+		return &symlink{e}
+
+	default:
+		// devices, fifos, sockets and irregular files have no contents to import.
+		return &unsupportedEntry{e}
+	}
+}
+
+// entry implements common fs.Entry methods on top of a tree node.
+type entry struct {
+	repo *Repository
+	node *Node
+}
+
+func (e *entry) Name() string {
+	return e.node.Name
+}
+
+func (e *entry) Size() int64 {
+	return int64(e.node.Size) //nolint:gosec
+}
+
+func (e *entry) Mode() os.FileMode {
+	switch e.node.Type {
+	case nodeTypeDir:
+		return os.ModeDir | e.node.Mode.Perm()
+
+	case nodeTypeSymlink:
+		return os.ModeSymlink | e.node.Mode.Perm()
+
+	default:
+		return e.node.Mode
+	}
+}
+
+func (e *entry) ModTime() time.Time {
+	return e.node.ModTime
+}
+
+func (e *entry) IsDir() bool {
+	return e.node.Type == nodeTypeDir
+}
+
+func (e *entry) Sys() any {
+	return nil
+}
+
+func (e *entry) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{UserID: e.node.UID, GroupID: e.node.GID}
+}
+
+func (e *entry) Device() fs.DeviceInfo {
+	return fs.DeviceInfo{Dev: e.node.DeviceID}
+}
+
+func (e *entry) LocalFilesystemPath() string {
+	return ""
+}
+
+func (e *entry) Close() {
+}
+
+type directory struct {
+	entry
+}
+
+func (d *directory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	//nolint:wrapcheck
+	return fs.IterateEntriesAndFindChild(ctx, d, name)
+}
+
+func (d *directory) Iterate(_ context.Context) (fs.DirectoryIterator, error) {
+	t, err := d.repo.LoadTree(d.node.Subtree)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]fs.Entry, 0, len(t.Nodes))
+
+	for _, n := range t.Nodes {
+		entries = append(entries, d.repo.newEntry(n))
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+func (d *directory) SupportsMultipleIterations() bool {
+	return true
+}
+
+type file struct {
+	entry
+}
+
+func (f *file) Open(_ context.Context) (fs.Reader, error) {
+	// offsets[i] is the offset of the first byte of content blob i within the file.
+	offsets := make([]int64, len(f.node.Content)+1)
+
+	for i, id := range f.node.Content {
+		l, err := f.repo.blobLength(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to open %v", f.node.Name)
+		}
+
+		offsets[i+1] = offsets[i] + l
+	}
+
+	return &fileReader{f: f, offsets: offsets, current: -1}, nil
+}
+
+type symlink struct {
+	entry
+}
+
+func (s *symlink) Readlink(_ context.Context) (string, error) {
+	return s.node.LinkTarget, nil
+}
+
+func (s *symlink) Resolve(_ context.Context) (fs.Entry, error) {
+	return nil, errors.New("symlinks in restic snapshots can't be resolved")
+}
+
+type unsupportedEntry struct {
+	entry
+}
+
+func (e *unsupportedEntry) ErrorInfo() error {
+	return fs.ErrUnknown
+}
+
+// fileReader reads file contents by decrypting content blobs one at a time.
+type fileReader struct {
+	f       *file
+	offsets []int64
+	pos     int64
+
+	current     int // index of the blob in currentData or -1
+	currentData []byte
+}
+
+func (r *fileReader) length() int64 {
+	return r.offsets[len(r.offsets)-1]
+}
+
+func (r *fileReader) Read(b []byte) (int, error) {
+	if r.pos >= r.length() {
+		return 0, io.EOF
+	}
+
+	// find the blob containing the current position
+	i := sort.Search(len(r.offsets)-1, func(i int) bool {
+		return r.offsets[i+1] > r.pos
+	})
+
+	if i != r.current {
+		data, err := r.f.repo.readBlob(r.f.node.Content[i])
+		if err != nil {
+			return 0, err
+		}
+
+		r.current = i
+		r.currentData = data
+	}
+
+	n := copy(b, r.currentData[r.pos-r.offsets[i]:])
+	r.pos += int64(n)
+
+	return n, nil
+}
+
+func (r *fileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.length()
+	default:
+		return 0, errors.Errorf("invalid whence %v", whence)
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative seek offset")
+	}
+
+	r.pos = offset
+
+	return offset, nil
+}
+
+func (r *fileReader) Close() error {
+	r.currentData = nil
+	return nil
+}
+
+func (r *fileReader) Entry() (fs.Entry, error) {
+	return r.f, nil
+}
+
+var (
+	_ fs.Directory  = (*directory)(nil)
+	_ fs.File       = (*file)(nil)
+	_ fs.Symlink    = (*symlink)(nil)
+	_ fs.ErrorEntry = (*unsupportedEntry)(nil)
+)
@@ -0,0 +1,405 @@
+// Package restic implements read-only access to repositories created by restic (format versions 1 and 2),
+// used to import restic snapshots into kopia.
+package restic
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("restic")
+
+// ErrInvalidPassword is returned when none of the repository keys can be opened with the provided password.
+var ErrInvalidPassword = errors.New("invalid restic repository password")
+
+const (
+	// header byte of compressed unpacked files (index, snapshots) in format version 2.
+	compressedFileHeader = 2
+
+	maxScryptN = 1 << 24
+)
+
+// ID identifies a file or blob in a restic repository (hex-encoded SHA-256).
+type ID string
+
+// Snapshot is a restic snapshot.
+type Snapshot struct {
+	ID       ID        `json:"-"`
+	Time     time.Time `json:"time"`
+	Parent   ID        `json:"parent,omitempty"`
+	Tree     ID        `json:"tree"`
+	Paths    []string  `json:"paths"`
+	Hostname string    `json:"hostname,omitempty"`
+	Username string    `json:"username,omitempty"`
+	UID      uint32    `json:"uid,omitempty"`
+	GID      uint32    `json:"gid,omitempty"`
+	Excludes []string  `json:"excludes,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Original ID        `json:"original,omitempty"`
+}
+
+// ShortID returns the abbreviated snapshot ID, as displayed by restic.
+func (s *Snapshot) ShortID() string {
+	const shortIDLength = 8
+
+	if len(s.ID) > shortIDLength {
+		return string(s.ID[0:shortIDLength])
+	}
+
+	return string(s.ID)
+}
+
+// Node is an entry of a restic tree.
+type Node struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Mode       os.FileMode `json:"mode,omitempty"`
+	ModTime    time.Time   `json:"mtime,omitempty"`
+	UID        uint32      `json:"uid"`
+	GID        uint32      `json:"gid"`
+	DeviceID   uint64      `json:"device_id,omitempty"`
+	Size       uint64      `json:"size,omitempty"`
+	LinkTarget string      `json:"linktarget,omitempty"`
+	Content    []ID        `json:"content"`
+	Subtree    ID          `json:"subtree,omitempty"`
+}
+
+// Tree is a restic directory listing.
+type Tree struct {
+	Nodes []*Node `json:"nodes"`
+}
+
+type keyFile struct {
+	KDF  string `json:"kdf"`
+	N    int    `json:"N"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt []byte `json:"salt"`
+	Data []byte `json:"data"`
+}
+
+type config struct {
+	Version int    `json:"version"`
+	ID      string `json:"id"`
+}
+
+type indexFile struct {
+	Packs []indexPack `json:"packs"`
+}
+
+type indexPack struct {
+	ID    ID          `json:"id"`
+	Blobs []indexBlob `json:"blobs"`
+}
+
+type indexBlob struct {
+	ID                 ID     `json:"id"`
+	Type               string `json:"type"`
+	Offset             int64  `json:"offset"`
+	Length             int64  `json:"length"`
+	UncompressedLength int64  `json:"uncompressed_length,omitempty"`
+}
+
+// blobLocation describes where an encrypted blob is stored.
+type blobLocation struct {
+	pack               ID
+	offset             int64
+	length             int64
+	uncompressedLength int64
+}
+
+// plaintextLength returns the length of the blob contents.
+func (l blobLocation) plaintextLength() int64 {
+	if l.uncompressedLength > 0 {
+		return l.uncompressedLength
+	}
+
+	return l.length - cipherOverhead
+}
+
+// Repository provides read-only access to a restic repository stored in a local directory.
+type Repository struct {
+	path    string
+	key     *key
+	version int
+	decoder *zstd.Decoder
+
+	// immutable after Open()
+	blobs map[ID]blobLocation
+}
+
+// Open opens the restic repository at the provided path using the provided password.
+func Open(ctx context.Context, path, password string) (*Repository, error) {
+	r := &Repository{
+		path:  path,
+		blobs: map[ID]blobLocation{},
+	}
+
+	k, err := r.openMasterKey(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+
+	r.key = k
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create decompressor")
+	}
+
+	r.decoder = dec
+
+	var cfg config
+
+	// config file is never compressed.
+	if err := r.readJSON(filepath.Join(path, "config"), false, &cfg); err != nil {
+		r.Close()
+		return nil, errors.Wrap(err, "unable to read repository config")
+	}
+
+	if cfg.Version != 1 && cfg.Version != 2 { //nolint:mnd
+		r.Close()
+		return nil, errors.Errorf("unsupported restic repository version %v", cfg.Version)
+	}
+
+	r.version = cfg.Version
+
+	if err := r.loadIndexes(ctx); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Close releases resources associated with the repository.
+func (r *Repository) Close() {
+	if r.decoder != nil {
+		r.decoder.Close()
+	}
+}
+
+// Version returns the repository format version.
+func (r *Repository) Version() int {
+	return r.version
+}
+
+func (r *Repository) openMasterKey(ctx context.Context, password string) (*key, error) {
+	keyDir := filepath.Join(r.path, "keys")
+
+	entries, err := os.ReadDir(keyDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list restic repository keys")
+	}
+
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(keyDir, e.Name())) //nolint:gosec
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read key file")
+		}
+
+		var kf keyFile
+
+		if err := json.Unmarshal(b, &kf); err != nil {
+			log(ctx).Debugf("ignoring invalid key file %v: %v", e.Name(), err)
+			continue
+		}
+
+		if kf.KDF != "scrypt" || kf.N <= 0 || kf.N > maxScryptN {
+			log(ctx).Debugf("ignoring key file %v with unsupported parameters", e.Name())
+			continue
+		}
+
+		userKey, err := deriveKey(password, kf.Salt, kf.N, kf.R, kf.P)
+		if err != nil {
+			return nil, err
+		}
+
+		plaintext, err := userKey.decrypt(kf.Data)
+		if errors.Is(err, errUnauthenticated) {
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decrypt key %v", e.Name())
+		}
+
+		master := &key{}
+		if err := json.Unmarshal(plaintext, master); err != nil {
+			return nil, errors.Wrapf(err, "invalid master key in %v", e.Name())
+		}
+
+		log(ctx).Debugf("opened restic repository using key %v", e.Name())
+
+		return master, nil
+	}
+
+	return nil, ErrInvalidPassword
+}
+
+// readJSON reads, decrypts and parses the provided unpacked repository file.
+func (r *Repository) readJSON(fname string, allowCompression bool, v any) error {
+	b, err := os.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to read file")
+	}
+
+	plaintext, err := r.key.decrypt(b)
+	if err != nil {
+		return errors.Wrapf(err, "unable to decrypt %v", fname)
+	}
+
+	if allowCompression && r.version >= 2 && len(plaintext) > 0 && plaintext[0] == compressedFileHeader {
+		plaintext, err = r.decoder.DecodeAll(plaintext[1:], nil)
+		if err != nil {
+			return errors.Wrapf(err, "unable to decompress %v", fname)
+		}
+	}
+
+	return errors.Wrapf(json.Unmarshal(plaintext, v), "unable to parse %v", fname)
+}
+
+// listFiles returns names of files in the provided repository subdirectory.
+func (r *Repository) listFiles(subdir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(r.path, subdir))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list %v", subdir)
+	}
+
+	var result []string
+
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			result = append(result, e.Name())
+		}
+	}
+
+	return result, nil
+}
+
+func (r *Repository) loadIndexes(ctx context.Context) error {
+	names, err := r.listFiles("index")
+	if err != nil {
+		return err
+	}
+
+	for _, n := range names {
+		var ndx indexFile
+
+		if err := r.readJSON(filepath.Join(r.path, "index", n), true, &ndx); err != nil {
+			return errors.Wrap(err, "unable to read index")
+		}
+
+		for _, p := range ndx.Packs {
+			for _, b := range p.Blobs {
+				r.blobs[b.ID] = blobLocation{p.ID, b.Offset, b.Length, b.UncompressedLength}
+			}
+		}
+	}
+
+	log(ctx).Debugf("loaded %v index files with %v blobs", len(names), len(r.blobs))
+
+	return nil
+}
+
+// Snapshots returns all snapshots in the repository ordered by time.
+func (r *Repository) Snapshots(ctx context.Context) ([]*Snapshot, error) {
+	names, err := r.listFiles("snapshots")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Snapshot
+
+	for _, n := range names {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(ctx.Err(), "canceled")
+		}
+
+		s := &Snapshot{}
+		if err := r.readJSON(filepath.Join(r.path, "snapshots", n), true, s); err != nil {
+			return nil, errors.Wrap(err, "unable to read snapshot")
+		}
+
+		s.ID = ID(n)
+
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	return result, nil
+}
+
+// blobLength returns the length of the blob contents.
+func (r *Repository) blobLength(id ID) (int64, error) {
+	l, ok := r.blobs[id]
+	if !ok {
+		return 0, errors.Errorf("blob %v not found in index", id)
+	}
+
+	return l.plaintextLength(), nil
+}
+
+// readBlob reads and decrypts the provided blob.
+func (r *Repository) readBlob(id ID) ([]byte, error) {
+	l, ok := r.blobs[id]
+	if !ok {
+		return nil, errors.Errorf("blob %v not found in index", id)
+	}
+
+	if len(l.pack) < 2 { //nolint:mnd
+		return nil, errors.Errorf("invalid pack ID %q", l.pack)
+	}
+
+	f, err := os.Open(filepath.Join(r.path, "data", string(l.pack[0:2]), string(l.pack))) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open pack")
+	}
+	defer f.Close() //nolint:errcheck
+
+	b := make([]byte, l.length)
+	if _, err := f.ReadAt(b, l.offset); err != nil {
+		return nil, errors.Wrapf(err, "unable to read blob %v from pack %v", id, l.pack)
+	}
+
+	plaintext, err := r.key.decrypt(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt blob %v", id)
+	}
+
+	if l.uncompressedLength > 0 {
+		plaintext, err = r.decoder.DecodeAll(plaintext, make([]byte, 0, l.uncompressedLength))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decompress blob %v", id)
+		}
+	}
+
+	return plaintext, nil
+}
+
+// LoadTree reads the tree with the provided ID.
+func (r *Repository) LoadTree(id ID) (*Tree, error) {
+	b, err := r.readBlob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tree{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse tree %v", id)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,290 @@
+package restic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+const testPassword = "restic-password"
+
+// encrypt is the inverse of key.decrypt.
+func (k *key) encrypt(t *testing.T, plaintext []byte) []byte {
+	t.Helper()
+
+	iv := make([]byte, ivSize)
+
+	_, err := rand.Read(iv)
+	require.NoError(t, err)
+
+	c, err := aes.NewCipher(k.Encrypt[:])
+	require.NoError(t, err)
+
+	result := make([]byte, ivSize+len(plaintext))
+	copy(result, iv)
+	cipher.NewCTR(c, iv).XORKeyStream(result[ivSize:], plaintext)
+
+	tag := k.mac(iv, result[ivSize:])
+
+	return append(result, tag[:]...)
+}
+
+func randomID(t *testing.T) ID {
+	t.Helper()
+
+	var b [32]byte
+
+	_, err := rand.Read(b[:])
+	require.NoError(t, err)
+
+	return ID(hex.EncodeToString(b[:]))
+}
+
+// testRepoWriter writes a minimal restic repository with a single pack file.
+type testRepoWriter struct {
+	t       *testing.T
+	dir     string
+	version int
+	master  *key
+	enc     *zstd.Encoder
+
+	pack  []byte
+	index indexFile
+}
+
+func newTestRepoWriter(t *testing.T, version int) *testRepoWriter {
+	t.Helper()
+
+	dir := testutil.TempDirectory(t)
+
+	for _, d := range []string{"keys", "index", "snapshots", "data"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, d), 0o700))
+	}
+
+	master := &key{}
+
+	_, err := rand.Read(master.Encrypt[:])
+	require.NoError(t, err)
+	_, err = rand.Read(master.MAC.K[:])
+	require.NoError(t, err)
+	_, err = rand.Read(master.MAC.R[:])
+	require.NoError(t, err)
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+
+	w := &testRepoWriter{t: t, dir: dir, version: version, master: master, enc: enc}
+
+	w.addKey("other-password")
+	w.addKey(testPassword)
+	w.writeFile("config", w.master.encrypt(t, w.mustJSON(config{Version: version, ID: string(randomID(t))})))
+
+	w.index.Packs = []indexPack{{ID: randomID(t)}}
+
+	return w
+}
+
+func (w *testRepoWriter) mustJSON(v any) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(w.t, err)
+
+	return b
+}
+
+func (w *testRepoWriter) writeFile(name string, data []byte) {
+	require.NoError(w.t, os.MkdirAll(filepath.Dir(filepath.Join(w.dir, name)), 0o700))
+	require.NoError(w.t, os.WriteFile(filepath.Join(w.dir, name), data, 0o600))
+}
+
+func (w *testRepoWriter) addKey(password string) {
+	salt := make([]byte, 16)
+
+	_, err := rand.Read(salt)
+	require.NoError(w.t, err)
+
+	userKey, err := deriveKey(password, salt, 1024, 8, 1)
+	require.NoError(w.t, err)
+
+	masterJSON, err := w.master.MarshalJSON()
+	require.NoError(w.t, err)
+
+	w.writeFile(filepath.Join("keys", string(randomID(w.t))), w.mustJSON(keyFile{
+		KDF:  "scrypt",
+		N:    1024,
+		R:    8,
+		P:    1,
+		Salt: salt,
+		Data: userKey.encrypt(w.t, masterJSON),
+	}))
+}
+
+// writeUnpacked writes an encrypted JSON file, compressed in format version 2.
+func (w *testRepoWriter) writeUnpacked(subdir string, v any) ID {
+	plaintext := w.mustJSON(v)
+
+	if w.version >= 2 {
+		plaintext = append([]byte{compressedFileHeader}, w.enc.EncodeAll(plaintext, nil)...)
+	}
+
+	id := randomID(w.t)
+	w.writeFile(filepath.Join(subdir, string(id)), w.master.encrypt(w.t, plaintext))
+
+	return id
+}
+
+// addBlob appends the blob to the pack and returns its ID.
+func (w *testRepoWriter) addBlob(blobType string, data []byte) ID {
+	h := sha256.Sum256(data)
+	id := ID(hex.EncodeToString(h[:]))
+
+	var uncompressedLength int64
+
+	if w.version >= 2 {
+		uncompressedLength = int64(len(data))
+		data = w.enc.EncodeAll(data, nil)
+	}
+
+	encrypted := w.master.encrypt(w.t, data)
+
+	w.index.Packs[0].Blobs = append(w.index.Packs[0].Blobs, indexBlob{id, blobType, int64(len(w.pack)), int64(len(encrypted)), uncompressedLength})
+
+	w.pack = append(w.pack, encrypted...)
+
+	return id
+}
+
+func (w *testRepoWriter) addTree(nodes ...*Node) ID {
+	return w.addBlob("tree", w.mustJSON(Tree{Nodes: nodes}))
+}
+
+func (w *testRepoWriter) finish() {
+	packID := w.index.Packs[0].ID
+
+	w.writeFile(filepath.Join("data", string(packID[0:2]), string(packID)), w.pack)
+	w.writeUnpacked("index", w.index)
+}
+
+func TestRepository(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		t.Run(fmt.Sprintf("v%v", version), func(t *testing.T) {
+			testRepository(t, version)
+		})
+	}
+}
+
+//nolint:thelper
+func testRepository(t *testing.T, version int) {
+	ctx := testlogging.Context(t)
+	w := newTestRepoWriter(t, version)
+
+	mtime := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	c1 := w.addBlob("data", []byte("hello, "))
+	c2 := w.addBlob("data", []byte("world!"))
+
+	docs := w.addTree(
+		&Node{Name: "a.txt", Type: "file", Mode: 0o644, ModTime: mtime, UID: 1000, GID: 1000, Size: 13, Content: []ID{c1, c2}},
+		&Node{Name: "empty", Type: "file", Mode: 0o600, ModTime: mtime, Content: nil},
+		&Node{Name: "link", Type: "symlink", Mode: os.ModeSymlink | 0o777, LinkTarget: "a.txt"},
+		&Node{Name: "pipe", Type: "fifo", Mode: os.ModeNamedPipe | 0o600},
+	)
+	user := w.addTree(&Node{Name: "docs", Type: "dir", Mode: os.ModeDir | 0o750, ModTime: mtime, Subtree: docs})
+	home := w.addTree(&Node{Name: "user", Type: "dir", Mode: os.ModeDir | 0o755, Subtree: user})
+	root := w.addTree(&Node{Name: "home", Type: "dir", Mode: os.ModeDir | 0o755, Subtree: home})
+
+	w.finish()
+
+	snapTime := time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC)
+
+	w.writeUnpacked("snapshots", &Snapshot{Time: snapTime, Tree: root, Paths: []string{"/home/user/docs"}, Hostname: "host1", Username: "user1"})
+	w.writeUnpacked("snapshots", &Snapshot{Time: snapTime.Add(-time.Hour), Tree: root, Paths: []string{"/home/user"}, Hostname: "host1", Username: "user1"})
+
+	_, err := Open(ctx, w.dir, "wrong")
+	require.ErrorIs(t, err, ErrInvalidPassword)
+
+	r, err := Open(ctx, w.dir, testPassword)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	require.Equal(t, version, r.Version())
+
+	snaps, err := r.Snapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snaps, 2)
+	require.Equal(t, []string{"/home/user"}, snaps[0].Paths)
+	require.Equal(t, snapTime, snaps[1].Time)
+	require.Len(t, snaps[1].ShortID(), 8)
+
+	_, err = r.SnapshotEntry(snaps[1], "/home/other")
+	require.ErrorContains(t, err, "not found")
+
+	e, err := r.SnapshotEntry(snaps[1], "/home/user/docs")
+	require.NoError(t, err)
+
+	dir, ok := e.(fs.Directory)
+	require.True(t, ok)
+	require.Equal(t, "docs", dir.Name())
+	require.Equal(t, os.ModeDir|0o750, dir.Mode())
+
+	entries, err := fs.GetAllEntries(ctx, dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	f, ok := entries[0].(fs.File)
+	require.True(t, ok)
+	require.Equal(t, int64(13), f.Size())
+	require.Equal(t, mtime, f.ModTime())
+	require.Equal(t, fs.OwnerInfo{UserID: 1000, GroupID: 1000}, f.Owner())
+
+	rd, err := f.Open(ctx)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Equal(t, "hello, world!", string(data))
+
+	_, err = rd.Seek(5, io.SeekStart)
+	require.NoError(t, err)
+
+	data, err = io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Equal(t, ", world!", string(data))
+	require.NoError(t, rd.Close())
+
+	rd, err = entries[1].(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	data, err = io.ReadAll(rd)
+	require.NoError(t, err)
+	require.Empty(t, data)
+
+	target, err := entries[2].(fs.Symlink).Readlink(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "a.txt", target)
+
+	require.ErrorIs(t, entries[3].(fs.ErrorEntry).ErrorInfo(), fs.ErrUnknown)
+}
+
+func TestPathComponents(t *testing.T) {
+	require.Equal(t, []string{"home", "user"}, PathComponents("/home/user"))
+	require.Equal(t, []string{"home", "user"}, PathComponents("/home//user/"))
+	require.Equal(t, []string{"C", "Users", "x"}, PathComponents(`C:\Users\x`))
+	require.Empty(t, PathComponents("/"))
+}
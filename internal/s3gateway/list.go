@@ -0,0 +1,300 @@
+package s3gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// maxListKeys is the maximum number of keys returned in a single ListObjectsV2 response.
+const maxListKeys = 1000
+
+var errListFull = errors.New("list is full")
+
+type objectInfo struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectInfo   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// lister collects keys and common prefixes in lexicographical order.
+type lister struct {
+	prefix    string
+	delimiter string
+	marker    string // only keys greater than the marker are returned
+	maxKeys   int
+
+	contents       []objectInfo
+	commonPrefixes []commonPrefix
+	last           string
+	truncated      bool
+}
+
+func (l *lister) full() bool {
+	return len(l.contents)+len(l.commonPrefixes) >= l.maxKeys
+}
+
+func (l *lister) addObject(key string, f fs.File) error {
+	if l.full() {
+		l.truncated = true
+		return errListFull
+	}
+
+	l.contents = append(l.contents, objectInfo{
+		Key:          key,
+		LastModified: formatTime(f.ModTime()),
+		ETag:         etag(f),
+		Size:         f.Size(),
+		StorageClass: "STANDARD",
+	})
+	l.last = key
+
+	return nil
+}
+
+func (l *lister) addCommonPrefix(p string) error {
+	if p <= l.marker || p == l.last {
+		return nil
+	}
+
+	if l.full() {
+		l.truncated = true
+		return errListFull
+	}
+
+	l.commonPrefixes = append(l.commonPrefixes, commonPrefix{p})
+	l.last = p
+
+	return nil
+}
+
+// commonPrefixOf returns the common prefix the key is rolled up into when a delimiter is used.
+func (l *lister) commonPrefixOf(key string) (string, bool) {
+	if l.delimiter == "" || !strings.HasPrefix(key, l.prefix) {
+		return "", false
+	}
+
+	p := strings.Index(key[len(l.prefix):], l.delimiter)
+	if p < 0 {
+		return "", false
+	}
+
+	return key[:len(l.prefix)+p+len(l.delimiter)], true
+}
+
+type listItem struct {
+	key   string
+	entry fs.Entry
+}
+
+// walk lists the provided directory whose key prefix is dirKey.
+func (l *lister) walk(ctx context.Context, dir fs.Directory, dirKey string) error {
+	entries, err := fs.GetAllEntries(ctx, dir)
+	if err != nil {
+		return errors.Wrapf(err, "error listing %q", dirKey)
+	}
+
+	var items []listItem
+
+	for _, e := range entries {
+		switch e.(type) {
+		case fs.Directory:
+			items = append(items, listItem{dirKey + e.Name() + "/", e})
+		case fs.File:
+			items = append(items, listItem{dirKey + e.Name(), e})
+		}
+	}
+
+	// keys of subdirectories end with '/', which affects the order in relation to sibling files.
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].key < items[j].key
+	})
+
+	for _, it := range items {
+		if err := l.walkItem(ctx, it); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *lister) walkItem(ctx context.Context, it listItem) error {
+	if cp, ok := l.commonPrefixOf(it.key); ok {
+		// all keys below a directory are rolled up into the same common prefix.
+		return l.addCommonPrefix(cp)
+	}
+
+	switch e := it.entry.(type) {
+	case fs.Directory:
+		if !strings.HasPrefix(it.key, l.prefix) && !strings.HasPrefix(l.prefix, it.key) {
+			return nil
+		}
+
+		// skip subtrees that are entirely before the marker.
+		if it.key <= l.marker && !strings.HasPrefix(l.marker, it.key) {
+			return nil
+		}
+
+		return l.walk(ctx, e, it.key)
+
+	case fs.File:
+		if !strings.HasPrefix(it.key, l.prefix) || it.key <= l.marker {
+			return nil
+		}
+
+		return l.addObject(it.key, e)
+	}
+
+	return nil
+}
+
+func (h *Handler) listObjectsV2(w http.ResponseWriter, r *http.Request) *s3Error {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	l := &lister{
+		prefix:    q.Get("prefix"),
+		delimiter: q.Get("delimiter"),
+		marker:    q.Get("start-after"),
+		maxKeys:   maxListKeys,
+	}
+
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return invalidArgument("Invalid max-keys.")
+		}
+
+		l.maxKeys = min(n, maxListKeys)
+	}
+
+	token := q.Get("continuation-token")
+	if token != "" {
+		k, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return invalidArgument("The continuation token provided is incorrect.")
+		}
+
+		l.marker = max(l.marker, string(k))
+	}
+
+	encodingType := q.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return invalidArgument("Invalid Encoding Method specified in Request.")
+	}
+
+	if err := h.list(ctx, l); err != nil {
+		return h.internalError(ctx, l.prefix, err)
+	}
+
+	result := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              h.bucket,
+		Prefix:            l.prefix,
+		Delimiter:         l.delimiter,
+		StartAfter:        q.Get("start-after"),
+		ContinuationToken: token,
+		KeyCount:          len(l.contents) + len(l.commonPrefixes),
+		MaxKeys:           l.maxKeys,
+		EncodingType:      encodingType,
+		IsTruncated:       l.truncated,
+		Contents:          l.contents,
+		CommonPrefixes:    l.commonPrefixes,
+	}
+
+	if l.truncated {
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(l.last))
+	}
+
+	if encodingType == "url" {
+		encodeKeys(&result)
+	}
+
+	return writeXML(w, result)
+}
+
+// list walks the directory containing the prefix.
+func (h *Handler) list(ctx context.Context, l *lister) error {
+	if l.maxKeys == 0 {
+		return nil
+	}
+
+	dirKey := l.prefix[:strings.LastIndex(l.prefix, "/")+1]
+
+	components := strings.Split(strings.TrimSuffix(dirKey, "/"), "/")
+	if dirKey == "" {
+		components = nil
+	}
+
+	if slices.Contains(components, "") {
+		return nil
+	}
+
+	e, err := h.lookup(ctx, components)
+	if err != nil {
+		return err
+	}
+
+	d, ok := e.(fs.Directory)
+	if !ok {
+		return nil
+	}
+
+	if err := l.walk(ctx, d, dirKey); err != nil && !errors.Is(err, errListFull) {
+		return err
+	}
+
+	return nil
+}
+
+func encodeKeys(r *listBucketResult) {
+	r.Prefix = urlEncode(r.Prefix)
+	r.Delimiter = urlEncode(r.Delimiter)
+	r.StartAfter = urlEncode(r.StartAfter)
+
+	for i := range r.Contents {
+		r.Contents[i].Key = urlEncode(r.Contents[i].Key)
+	}
+
+	for i := range r.CommonPrefixes {
+		r.CommonPrefixes[i].Prefix = urlEncode(r.CommonPrefixes[i].Prefix)
+	}
+}
+
+func urlEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,251 @@
+// Package s3gateway implements a minimal read-only S3-compatible HTTP API for serving snapshots.
+//
+// The directory tree is exposed as a single bucket, where each file is an object whose key is the
+// slash-separated path relative to the root. Only path-style requests are supported, for example:
+//
+//	aws s3 ls --endpoint-url http://127.0.0.1:51590 s3://kopia/
+//
+// Requests are not authenticated, clients can be configured with any credentials.
+package s3gateway
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var log = logging.Module("kopia/s3gateway")
+
+const (
+	s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	// timestamp format used in XML responses.
+	s3TimeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// Handler serves a directory tree as a read-only S3 bucket.
+type Handler struct {
+	root   fs.Directory
+	bucket string
+}
+
+// New returns a handler serving the provided directory as a bucket with a given name.
+func New(root fs.Directory, bucket string) *Handler {
+	return &Handler{root, bucket}
+}
+
+// s3Error is an error reported to the client in the S3 format.
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+var (
+	errNoSuchBucket     = &s3Error{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist."}
+	errNoSuchKey        = &s3Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+	errMethodNotAllowed = &s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "The bucket is read-only."}
+	errNotImplemented   = &s3Error{http.StatusNotImplemented, "NotImplemented", "The requested functionality is not implemented."}
+	errInternal         = &s3Error{http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again."}
+)
+
+func invalidArgument(message string) *s3Error {
+	return &s3Error{http.StatusBadRequest, "InvalidArgument", message}
+}
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type locationConstraint struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Xmlns   string   `xml:"xmlns,attr"`
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.writeError(w, r, errMethodNotAllowed)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	var err *s3Error
+
+	switch {
+	case bucket == "":
+		err = h.listBuckets(w)
+
+	case bucket != h.bucket:
+		err = errNoSuchBucket
+
+	case key != "":
+		err = h.getObject(w, r, key)
+
+	case r.URL.Query().Has("location"):
+		err = writeXML(w, locationConstraint{Xmlns: s3Namespace})
+
+	case r.Method == http.MethodHead:
+		// HeadBucket
+		w.WriteHeader(http.StatusOK)
+
+	case r.URL.Query().Get("list-type") == "2":
+		err = h.listObjectsV2(w, r)
+
+	default:
+		err = errNotImplemented
+	}
+
+	if err != nil {
+		h.writeError(w, r, err)
+	}
+}
+
+func (h *Handler) listBuckets(w http.ResponseWriter) *s3Error {
+	return writeXML(w, listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: owner{ID: "kopia", DisplayName: "kopia"},
+		Buckets: []bucketInfo{
+			{Name: h.bucket, CreationDate: formatTime(h.root.ModTime())},
+		},
+	})
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, key string) *s3Error {
+	ctx := r.Context()
+
+	components := strings.Split(key, "/")
+	if slices.Contains(components, "") {
+		return errNoSuchKey
+	}
+
+	e, err := h.lookup(ctx, components)
+	if err != nil {
+		return h.internalError(ctx, key, err)
+	}
+
+	f, ok := e.(fs.File)
+	if !ok {
+		return errNoSuchKey
+	}
+
+	rd, err := f.Open(ctx)
+	if err != nil {
+		return h.internalError(ctx, key, err)
+	}
+
+	defer rd.Close() //nolint:errcheck
+
+	w.Header().Set("ETag", etag(f))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	// ServeContent handles HEAD, ranges and conditional requests.
+	http.ServeContent(w, r, f.Name(), f.ModTime(), rd)
+
+	return nil
+}
+
+// lookup returns the entry with the provided path components or nil if not found.
+func (h *Handler) lookup(ctx context.Context, components []string) (fs.Entry, error) {
+	var e fs.Entry = h.root
+
+	for _, c := range components {
+		d, ok := e.(fs.Directory)
+		if !ok {
+			return nil, nil
+		}
+
+		child, err := d.Child(ctx, c)
+		if errors.Is(err, fs.ErrEntryNotFound) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "error looking up %v", c)
+		}
+
+		e = child
+	}
+
+	return e, nil
+}
+
+func (h *Handler) internalError(ctx context.Context, key string, err error) *s3Error {
+	log(ctx).Errorf("error serving %v: %v", key, err)
+
+	return errInternal
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, e *s3Error) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(e.status)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	xml.NewEncoder(w).Encode(errorResponse{ //nolint:errcheck
+		Code:     e.code,
+		Message:  e.message,
+		Resource: r.URL.Path,
+	})
+}
+
+func writeXML(w http.ResponseWriter, v any) *s3Error {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return errInternal
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header)) //nolint:errcheck
+	w.Write(b)                  //nolint:errcheck
+
+	return nil
+}
+
+// etag returns the quoted entity tag of a file, based on the object ID when available.
+func etag(f fs.File) string {
+	if h, ok := f.(snapshot.HasDirEntry); ok {
+		if de := h.DirEntry(); de != nil {
+			return `"` + de.ObjectID.String() + `"`
+		}
+	}
+
+	return fmt.Sprintf(`"%x-%x"`, f.ModTime().UnixNano(), f.Size())
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(s3TimeFormat)
+}
@@ -0,0 +1,204 @@
+package s3gateway
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	root := mockfs.NewDirectory()
+	root.AddFile("a-b", []byte("a-b"), 0o644)
+	root.AddFile("a0", []byte("a0"), 0o644)
+	root.AddDir("empty", 0o755)
+	root.AddSymlink("link", "a0", 0o777)
+
+	a := root.AddDir("a", 0o755)
+	a.AddFile("x.txt", []byte("hello, world"), 0o644)
+	a.AddFile("y y.txt", []byte("y"), 0o644)
+	a.AddDir("sub", 0o755).AddFile("z", []byte("z"), 0o644)
+
+	srv := httptest.NewServer(New(root, "kopia"))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func get(t *testing.T, srv *httptest.Server, path string, headers ...string) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(testlogging.Context(t), http.MethodGet, srv.URL+path, http.NoBody)
+	require.NoError(t, err)
+
+	for i := 0; i < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, body
+}
+
+func list(t *testing.T, srv *httptest.Server, query string) listBucketResult {
+	t.Helper()
+
+	resp, body := get(t, srv, "/kopia?list-type=2&"+query)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	var result listBucketResult
+
+	require.NoError(t, xml.Unmarshal(body, &result))
+
+	return result
+}
+
+func keys(r listBucketResult) []string {
+	var result []string
+
+	for _, c := range r.Contents {
+		result = append(result, c.Key)
+	}
+
+	for _, p := range r.CommonPrefixes {
+		result = append(result, p.Prefix)
+	}
+
+	return result
+}
+
+func TestListObjectsV2(t *testing.T) {
+	srv := newTestServer(t)
+
+	r := list(t, srv, "")
+	require.Equal(t, []string{"a-b", "a/sub/z", "a/x.txt", "a/y y.txt", "a0"}, keys(r))
+	require.False(t, r.IsTruncated)
+	require.Equal(t, 5, r.KeyCount)
+	require.Equal(t, int64(12), r.Contents[2].Size)
+
+	r = list(t, srv, "delimiter=/")
+	require.Equal(t, []string{"a-b", "a0", "a/", "empty/"}, keys(r))
+
+	r = list(t, srv, "delimiter=/&prefix=a/")
+	require.Equal(t, []string{"a/x.txt", "a/y y.txt", "a/sub/"}, keys(r))
+
+	r = list(t, srv, "prefix=a/s")
+	require.Equal(t, []string{"a/sub/z"}, keys(r))
+
+	r = list(t, srv, "prefix=a/x.txt/")
+	require.Empty(t, keys(r))
+
+	r = list(t, srv, "prefix=nosuchdir/")
+	require.Empty(t, keys(r))
+
+	r = list(t, srv, "start-after=a/x.txt")
+	require.Equal(t, []string{"a/y y.txt", "a0"}, keys(r))
+
+	r = list(t, srv, "prefix=a/&encoding-type=url")
+	require.Equal(t, []string{"a%2Fsub%2Fz", "a%2Fx.txt", "a%2Fy%20y.txt"}, keys(r))
+}
+
+func TestListObjectsV2Pagination(t *testing.T) {
+	srv := newTestServer(t)
+
+	var all []string
+
+	token := ""
+
+	for {
+		r := list(t, srv, "max-keys=2&delimiter=/&continuation-token="+token)
+		require.LessOrEqual(t, r.KeyCount, 2)
+
+		all = append(all, keys(r)...)
+
+		if !r.IsTruncated {
+			require.Empty(t, r.NextContinuationToken)
+			break
+		}
+
+		token = r.NextContinuationToken
+	}
+
+	require.ElementsMatch(t, []string{"a-b", "a/", "a0", "empty/"}, all)
+
+	resp, _ := get(t, srv, "/kopia?list-type=2&continuation-token=!!!")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetObject(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := get(t, srv, "/kopia/a/x.txt")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "hello, world", string(body))
+	require.NotEmpty(t, resp.Header.Get("ETag"))
+
+	resp, body = get(t, srv, "/kopia/a/x.txt", "Range", "bytes=7-")
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, "world", string(body))
+
+	resp, body = get(t, srv, "/kopia/a/y%20y.txt")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "y", string(body))
+
+	for _, p := range []string{"/kopia/a", "/kopia/a/", "/kopia/no-such-file", "/kopia/a//x.txt", "/kopia/link"} {
+		resp, body = get(t, srv, p)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, p)
+		require.Contains(t, string(body), "<Code>NoSuchKey</Code>", p)
+	}
+
+	resp, body = get(t, srv, "/other/a/x.txt")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Contains(t, string(body), "<Code>NoSuchBucket</Code>")
+}
+
+func TestReadOnly(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, method := range []string{http.MethodPut, http.MethodDelete, http.MethodPost} {
+		req, err := http.NewRequestWithContext(testlogging.Context(t), method, srv.URL+"/kopia/a/x.txt", http.NoBody)
+		require.NoError(t, err)
+
+		resp, err := srv.Client().Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, method)
+	}
+}
+
+func TestListBuckets(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := get(t, srv, "/")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result listAllMyBucketsResult
+
+	require.NoError(t, xml.Unmarshal(body, &result))
+	require.Len(t, result.Buckets, 1)
+	require.Equal(t, "kopia", result.Buckets[0].Name)
+
+	req, err := http.NewRequestWithContext(testlogging.Context(t), http.MethodHead, srv.URL+"/kopia", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err = srv.Client().Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleACLList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	entries, err := acl.LoadEntries(ctx, rc.rep, nil)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.ACLListResponse{
+		Entries: []*serverapi.ACLEntry{},
+	}
+
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, &serverapi.ACLEntry{ID: e.ManifestID, Entry: e})
+	}
+
+	return resp, nil
+}
+
+func handleACLAdd(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.AddACLEntryRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	if err := req.Entry.Validate(); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "ACLAdd",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return errors.Wrap(acl.AddACL(ctx, w, req.Entry, req.Overwrite), "unable to add ACL entry")
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionACLAdd, string(req.Entry.ManifestID), aclAuditDetails(req.Entry))
+
+	if err := rc.srv.getAuthorizer().Refresh(ctx); err != nil {
+		log(ctx).Errorf("unable to refresh authorizer: %v", err)
+	}
+
+	return &serverapi.ACLEntry{ID: req.Entry.ManifestID, Entry: req.Entry}, nil
+}
+
+func handleACLDelete(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	id := manifest.ID(rc.muxVar("id"))
+
+	entries, err := acl.LoadEntries(ctx, rc.rep, nil)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	var found *acl.Entry
+
+	for _, e := range entries {
+		if e.ManifestID == id {
+			found = e
+			break
+		}
+	}
+
+	if found == nil {
+		return nil, notFoundError("ACL entry not found")
+	}
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "ACLDelete",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return errors.Wrap(w.DeleteManifest(ctx, id), "unable to delete ACL entry")
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionACLDelete, string(id), aclAuditDetails(found))
+
+	if err := rc.srv.getAuthorizer().Refresh(ctx); err != nil {
+		log(ctx).Errorf("unable to refresh authorizer: %v", err)
+	}
+
+	return &serverapi.Empty{}, nil
+}
+
+func aclAuditDetails(e *acl.Entry) map[string]string {
+	return map[string]string{
+		"user":   e.User,
+		"target": e.Target.String(),
+		"access": e.Access.String(),
+	}
+}
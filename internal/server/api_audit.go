@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleAuditLogList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	opt, err := parseListOptions(rc.req.URL.Query())
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	entries, err := auditlog.List(ctx, rc.rep, auditlog.Filter{
+		Actor:  rc.queryParam("actor"),
+		Action: rc.queryParam("action"),
+		From:   opt.from,
+		To:     opt.to,
+		Limit:  opt.limit,
+	})
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if entries == nil {
+		entries = []*auditlog.Entry{}
+	}
+
+	return &serverapi.AuditLogResponse{
+		Enabled: rc.srv.getOptions().EnableAuditLog,
+		Entries: entries,
+	}, nil
+}
+
+func handleAuditLogVerify(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	vr, err := auditlog.Verify(ctx, rc.rep)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return vr, nil
+}
@@ -5,7 +5,7 @@
 	"os"
 	"strings"
 
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func handleCLIInfo(ctx context.Context, rc requestContext) (interface{}, *apiError) {
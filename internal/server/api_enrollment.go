@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/enrollment"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+const defaultEnrollmentTokenValidity = 24 * time.Hour
+
+func handleEnrollmentTokenCreate(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.CreateEnrollmentTokenRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	if req.Validity == 0 {
+		req.Validity = defaultEnrollmentTokenValidity
+	}
+
+	var (
+		token string
+		t     *enrollment.Token
+	)
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "EnrollmentTokenCreate",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		token, t, err = enrollment.CreateToken(ctx, w, req.Description, authenticatedUsername(rc), req.Validity)
+
+		return err
+	}); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionEnrollmentToken, t.TokenHash, map[string]string{
+		"description": t.Description,
+		"expires":     t.ExpireTime.Format(time.RFC3339),
+	})
+
+	return &serverapi.CreateEnrollmentTokenResponse{Token: token, ExpireTime: t.ExpireTime}, nil
+}
+
+func handleEnrollmentList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	requests, err := enrollment.List(ctx, rc.rep, rc.queryParam("state"))
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	for _, r := range requests {
+		r.PasswordHash = nil
+	}
+
+	return &serverapi.EnrollmentRequestsResponse{Requests: requests}, nil
+}
+
+func handleEnrollmentApprove(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.ApproveEnrollmentRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	var r *enrollment.Request
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "EnrollmentApprove",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		r, err = enrollment.Approve(ctx, w, rc.muxVar("id"), authenticatedUsername(rc))
+		if err != nil {
+			return err
+		}
+
+		for _, e := range req.ACL {
+			if e.User == "" {
+				e.User = r.Username
+			}
+
+			if err := e.Validate(); err != nil {
+				return errors.Wrap(err, "invalid ACL entry")
+			}
+
+			if err := acl.AddACL(ctx, w, e, false); err != nil {
+				return errors.Wrap(err, "unable to add ACL entry")
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, enrollmentRequestError(err)
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionEnrollmentApprove, r.ID, map[string]string{"user": r.Username})
+
+	for _, e := range req.ACL {
+		auditRequest(ctx, rc, auditlog.ActionACLAdd, string(e.ManifestID), aclAuditDetails(e))
+	}
+
+	if authn := rc.srv.getAuthenticator(); authn != nil {
+		if err := authn.Refresh(ctx); err != nil {
+			log(ctx).Errorf("unable to refresh authenticator: %v", err)
+		}
+	}
+
+	if err := rc.srv.getAuthorizer().Refresh(ctx); err != nil {
+		log(ctx).Errorf("unable to refresh authorizer: %v", err)
+	}
+
+	return r, nil
+}
+
+func handleEnrollmentReject(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var r *enrollment.Request
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "EnrollmentReject",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		r, err = enrollment.Reject(ctx, w, rc.muxVar("id"), authenticatedUsername(rc))
+
+		return err
+	}); err != nil {
+		return nil, enrollmentRequestError(err)
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionEnrollmentReject, r.ID, map[string]string{"user": r.Username})
+
+	return r, nil
+}
+
+// handleEnroll handles unauthenticated enrollment requests from clients holding an enrollment token.
+func handleEnroll(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.EnrollRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	var r *enrollment.Request
+
+	// tokens are redeemed by deleting their manifests, hold the lock until the session is flushed
+	// so that concurrent requests can't redeem the same token.
+	mu := rc.srv.enrollmentLock()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "Enroll",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		r, err = enrollment.Submit(ctx, w, req.Token, req.Username, req.Password, rc.req.RemoteAddr)
+
+		return err
+	}); err != nil {
+		if !errors.Is(err, enrollment.ErrEnrollmentFailed) {
+			log(ctx).Errorf("unable to submit enrollment request from client %s: %v", rc.req.RemoteAddr, err)
+		}
+
+		// the client is not authenticated, don't reveal why the enrollment failed.
+		return nil, requestError(serverapi.ErrorInvalidToken, enrollment.ErrEnrollmentFailed.Error())
+	}
+
+	log(ctx).Infof("enrollment of %v requested by client %s, pending approval", r.Username, rc.req.RemoteAddr)
+
+	return enrollmentStatus(r), nil
+}
+
+func handleEnrollmentStatus(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	r, err := enrollment.Get(ctx, rc.rep, rc.muxVar("id"))
+	if err != nil {
+		return nil, enrollmentRequestError(err)
+	}
+
+	return enrollmentStatus(r), nil
+}
+
+func enrollmentStatus(r *enrollment.Request) *serverapi.EnrollmentStatus {
+	return &serverapi.EnrollmentStatus{
+		ID:       r.ID,
+		Username: r.Username,
+		State:    r.State,
+	}
+}
+
+func enrollmentRequestError(err error) *apiError {
+	if errors.Is(err, enrollment.ErrNotFound) {
+		return notFoundError("enrollment request not found")
+	}
+
+	return requestError(serverapi.ErrorMalformedRequest, err.Error())
+}
@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/enrollment"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func TestEnrollment(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+	srvInfo := servertesting.StartServer(t, env, true)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	// enrolling clients don't have credentials.
+	anon, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+	})
+	require.NoError(t, err)
+
+	var tok serverapi.CreateEnrollmentTokenResponse
+
+	require.NoError(t, cli.Post(ctx, "enrollment-tokens", &serverapi.CreateEnrollmentTokenRequest{Description: "laptops"}, &tok))
+	require.NotEmpty(t, tok.Token)
+
+	// anonymous clients can't manage enrollments.
+	require.Error(t, anon.Post(ctx, "enrollment-tokens", &serverapi.CreateEnrollmentTokenRequest{}, &tok))
+	require.Error(t, anon.Get(ctx, "enrollments", nil, &serverapi.EnrollmentRequestsResponse{}))
+
+	_, err = serverapi.Enroll(ctx, anon, &serverapi.EnrollRequest{Token: "bad-token", Username: "alice@laptop", Password: "pass"})
+	require.ErrorContains(t, err, "enrollment failed")
+
+	// failures don't reveal why the enrollment was rejected.
+	_, err2 := serverapi.Enroll(ctx, anon, &serverapi.EnrollRequest{Token: tok.Token, Username: "Alice", Password: ""})
+	require.Equal(t, err.Error(), err2.Error())
+
+	// the token can only be redeemed once, even by concurrent requests.
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int32
+	)
+
+	for i := range 5 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if st, err := serverapi.Enroll(ctx, anon, &serverapi.EnrollRequest{Token: tok.Token, Username: fmt.Sprintf("user%v@laptop", i), Password: "pass"}); err == nil {
+				succeeded.Add(1)
+
+				assert.NoError(t, cli.Post(ctx, "enrollments/"+st.ID+"/reject", &serverapi.Empty{}, &enrollment.Request{}))
+			}
+		}()
+	}
+
+	wg.Wait()
+	require.EqualValues(t, 1, succeeded.Load())
+
+	require.NoError(t, cli.Post(ctx, "enrollment-tokens", &serverapi.CreateEnrollmentTokenRequest{Description: "laptops"}, &tok))
+
+	st, err := serverapi.Enroll(ctx, anon, &serverapi.EnrollRequest{Token: tok.Token, Username: "alice@laptop", Password: "alice-pass"})
+	require.NoError(t, err)
+	require.Equal(t, enrollment.StatePending, st.State)
+
+	var list serverapi.EnrollmentRequestsResponse
+
+	require.NoError(t, cli.Get(ctx, "enrollments?state="+enrollment.StatePending, nil, &list))
+	require.Len(t, list.Requests, 1)
+	require.Equal(t, "alice@laptop", list.Requests[0].Username)
+	require.Equal(t, "laptops", list.Requests[0].TokenDescription)
+	require.Empty(t, list.Requests[0].PasswordHash)
+
+	var approved enrollment.Request
+
+	require.NoError(t, cli.Post(ctx, "enrollments/"+st.ID+"/approve", &serverapi.ApproveEnrollmentRequest{
+		ACL: []*acl.Entry{{
+			Target: acl.TargetRule{manifest.TypeLabelKey: "snapshot", "hostname": acl.OwnHost},
+			Access: acl.AccessLevelRead,
+		}},
+	}, &approved))
+	require.Equal(t, enrollment.StateApproved, approved.State)
+	require.Equal(t, servertesting.TestUIUsername, approved.DecidedBy)
+
+	st, err = serverapi.GetEnrollmentStatus(ctx, anon, st.ID)
+	require.NoError(t, err)
+	require.Equal(t, enrollment.StateApproved, st.State)
+
+	p, err := user.GetUserProfile(ctx, env.Repository, "alice@laptop")
+	require.NoError(t, err)
+
+	valid, err := p.IsValidPassword("alice-pass")
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	entries, err := acl.LoadEntries(ctx, env.Repository, nil)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "alice@laptop", entries[0].User)
+
+	// already decided requests can't be approved or rejected.
+	require.Error(t, cli.Post(ctx, "enrollments/"+st.ID+"/reject", &serverapi.Empty{}, &approved))
+
+	_, err = serverapi.GetEnrollmentStatus(ctx, anon, "no-such-id")
+	require.Error(t, err)
+}
@@ -6,7 +6,7 @@
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/serverapi"
 )
 
 type apiError struct {
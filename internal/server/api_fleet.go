@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// fleetOverdueGracePeriod is the time after the expected snapshot time after which the source is considered overdue.
+const fleetOverdueGracePeriod = time.Hour
+
+func handleFleetList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	resp, err := fleetInventory(ctx, rc)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return resp, nil
+}
+
+func handleFleetHealth(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	resp, err := fleetInventory(ctx, rc)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return resp.Summary, nil
+}
+
+// fleetInventory consolidates information about all sources from snapshot manifests, policies,
+// server-managed sources and client sessions.
+func fleetInventory(ctx context.Context, rc requestContext) (*serverapi.FleetResponse, error) {
+	now := clock.Now()
+
+	sources, err := snapshot.ListSources(ctx, rc.rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list sources")
+	}
+
+	managed := rc.srv.snapshotAllSourceManagers()
+	for src := range managed {
+		if !slices.Contains(sources, src) {
+			sources = append(sources, src)
+		}
+	}
+
+	clients := rc.srv.knownClients()
+
+	resp := &serverapi.FleetResponse{
+		Sources: []*serverapi.FleetSource{},
+		Summary: serverapi.FleetHealthSummary{
+			ClientVersions: map[string]int{},
+			KnownClients:   len(clients),
+		},
+	}
+
+	for _, ci := range clients {
+		if ci.activeSessions > 0 {
+			resp.Summary.ActiveClients++
+		}
+
+		if ci.clientVersion != "" {
+			resp.Summary.ClientVersions[ci.clientVersion]++
+		}
+	}
+
+	for _, src := range sources {
+		fs, err := fleetSourceInfo(ctx, rc.rep, src, now)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := managed[src]; ok {
+			fs.ServerManaged = true
+			fs.ClientVersion = repo.BuildVersion
+			fs.LastSeen = &now
+		}
+
+		if ci, ok := clients[src.UserName+"@"+src.Host]; ok {
+			fs.LastSeen = laterTime(fs.LastSeen, ci.lastSeen)
+			fs.ClientVersion = ci.clientVersion
+			fs.RemoteAddress = ci.remoteAddress
+			fs.ActiveSessions = ci.activeSessions
+		}
+
+		resp.Sources = append(resp.Sources, fs)
+
+		resp.Summary.TotalSources++
+
+		switch fs.Health {
+		case serverapi.FleetHealthOK:
+			resp.Summary.Healthy++
+		case serverapi.FleetHealthWarning:
+			resp.Summary.Warning++
+		case serverapi.FleetHealthCritical:
+			resp.Summary.Critical++
+		}
+
+		if fs.Overdue {
+			resp.Summary.Overdue++
+		}
+	}
+
+	sort.Slice(resp.Sources, func(i, j int) bool {
+		return resp.Sources[i].Source.String() < resp.Sources[j].Source.String()
+	})
+
+	return resp, nil
+}
+
+// fleetSourceInfo returns the snapshot status and schedule adherence of a single source.
+func fleetSourceInfo(ctx context.Context, rep repo.Repository, src snapshot.SourceInfo, now time.Time) (*serverapi.FleetSource, error) {
+	fs := &serverapi.FleetSource{
+		Source: src,
+		Health: serverapi.FleetHealthCritical,
+	}
+
+	manifests, err := snapshot.ListSnapshots(ctx, rep, src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list snapshots of %v", src)
+	}
+
+	manifests = snapshot.SortByTime(manifests, true)
+	if len(manifests) == 0 {
+		return fs, nil
+	}
+
+	last := manifests[0]
+	lastStart := last.StartTime.ToTime()
+
+	fs.LastSnapshotTime = &lastStart
+	fs.LastSnapshotIncomplete = last.IncompleteReason
+	fs.LastSnapshotErrorCount = int(last.Stats.ErrorCount)
+
+	if last.RootEntry != nil && last.RootEntry.DirSummary != nil {
+		fs.LastSnapshotErrorCount = max(fs.LastSnapshotErrorCount, last.RootEntry.DirSummary.FatalErrorCount)
+	}
+
+	for _, m := range manifests {
+		if m.IncompleteReason == "" {
+			t := m.StartTime.ToTime()
+			fs.LastSuccessfulSnapshotTime = &t
+
+			break
+		}
+	}
+
+	pol, _, _, err := policy.GetEffectivePolicy(ctx, rep, src)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get effective policy of %v", src)
+	}
+
+	if fs.LastSuccessfulSnapshotTime != nil {
+		// passing the time of the last snapshot as current time yields the first scheduled time after it.
+		if expected, ok := pol.SchedulingPolicy.NextSnapshotTime(*fs.LastSuccessfulSnapshotTime, *fs.LastSuccessfulSnapshotTime); ok {
+			fs.ExpectedSnapshotTime = &expected
+			fs.Overdue = now.After(expected.Add(fleetOverdueGracePeriod))
+		}
+	}
+
+	switch {
+	case fs.LastSuccessfulSnapshotTime == nil:
+		fs.Health = serverapi.FleetHealthCritical
+	case fs.Overdue || fs.LastSnapshotIncomplete != "" || fs.LastSnapshotErrorCount > 0:
+		fs.Health = serverapi.FleetHealthWarning
+	default:
+		fs.Health = serverapi.FleetHealthOK
+	}
+
+	return fs, nil
+}
+
+func laterTime(t *time.Time, other time.Time) *time.Time {
+	if t != nil && t.After(other) {
+		return t
+	}
+
+	return &other
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+var (
+	errIndexEpochsNotSupported = errors.New("index epochs require direct repository connection")
+	errEpochManagerNotActive   = errors.New("epoch manager is not active")
+)
+
+func indexEpochManager(ctx context.Context, rc requestContext) (*epoch.Manager, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, errIndexEpochsNotSupported.Error())
+	}
+
+	em, ok, err := dr.ContentReader().EpochManager(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, errEpochManagerNotActive.Error())
+	}
+
+	return em, nil
+}
+
+func handleIndexEpochStatus(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	em, apiErr := indexEpochManager(ctx, rc)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s, err := em.Status(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.IndexEpochStatusResponse{Status: *s}, nil
+}
+
+func handleIndexEpochAdvance(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.AdvanceIndexEpochRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	em, apiErr := indexEpochManager(ctx, rc)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	resp := &serverapi.AdvanceIndexEpochResponse{}
+
+	ep, err := em.ForceAdvanceWriteEpoch(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp.WriteEpoch = ep
+
+	auditRequest(ctx, rc, auditlog.ActionIndexEpochAdvance, strconv.Itoa(ep), map[string]string{
+		"compact": strconv.FormatBool(req.Compact),
+	})
+
+	if req.Compact {
+		resp.CompactedEpochs, err = em.CompactSettledEpochs(ctx)
+		if err != nil {
+			return nil, internalServerError(err)
+		}
+	}
+
+	if err := rc.rep.Refresh(ctx); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return resp, nil
+}
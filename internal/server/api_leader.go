@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/leaderelection"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+var errLeaderElectionNotSupported = errors.New("leader election requires direct repository connection")
+
+func handleLeaderStatus(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	e := rc.srv.leaderElector()
+	if e == nil {
+		return &serverapi.LeaderStatusResponse{IsLeader: true}, nil
+	}
+
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, errLeaderElectionNotSupported.Error())
+	}
+
+	leases, err := leaderelection.ListLeases(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.LeaderStatusResponse{
+		HighAvailability: true,
+		InstanceID:       e.Holder(),
+		IsLeader:         e.IsLeader(),
+		Leader:           e.Leader(),
+		Leases:           leases,
+	}, nil
+}
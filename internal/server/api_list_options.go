@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/wcmatch"
+)
+
+// maxListLimit is the maximum number of items returned in a single page of a list response.
+const maxListLimit = 10000
+
+// listOptions contains pagination, filtering and field selection parameters of list requests.
+// All parameters are optional, when none are provided list endpoints return all items.
+type listOptions struct {
+	limit      int      // maximum number of items in a page, 0 == unlimited
+	afterKey   string   // sort key of the last item on the previous page
+	descending bool     // sort items in descending order
+	fields     []string // JSON fields of list items to return, empty == all
+
+	pathGlob *wcmatch.WildcardMatcher
+	from     time.Time
+	to       time.Time
+}
+
+// parseListOptions parses list options from 'limit', 'cursor', 'order', 'fields', 'pathGlob', 'from' and 'to'
+// query parameters, where 'from' and 'to' are RFC 3339 timestamps.
+func parseListOptions(query url.Values) (*listOptions, error) {
+	opt := &listOptions{}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxListLimit {
+			return nil, errors.Errorf("invalid limit, must be between 1 and %v", maxListLimit)
+		}
+
+		opt.limit = n
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		k, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+
+		opt.afterKey = string(k)
+	}
+
+	switch v := query.Get("order"); v {
+	case "", "asc":
+	case "desc":
+		opt.descending = true
+	default:
+		return nil, errors.Errorf("invalid order %q, must be 'asc' or 'desc'", v)
+	}
+
+	if v := query.Get("fields"); v != "" {
+		opt.fields = strings.Split(v, ",")
+	}
+
+	if v := query.Get("pathGlob"); v != "" {
+		m, err := wcmatch.NewWildcardMatcher(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid path glob")
+		}
+
+		opt.pathGlob = m
+	}
+
+	for param, dst := range map[string]*time.Time{"from": &opt.from, "to": &opt.to} {
+		if v := query.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, errors.Errorf("invalid '%v' time, must be in RFC 3339 format", param)
+			}
+
+			*dst = t
+		}
+	}
+
+	return opt, nil
+}
+
+// matchesPath returns true if the path matches the path glob.
+func (o *listOptions) matchesPath(p string) bool {
+	return o.pathGlob == nil || o.pathGlob.Match(p, true)
+}
+
+// matchesTime returns true if the time is within the requested range, the 'to' time is exclusive.
+func (o *listOptions) matchesTime(t time.Time) bool {
+	if !o.from.IsZero() && t.Before(o.from) {
+		return false
+	}
+
+	if !o.to.IsZero() && !t.Before(o.to) {
+		return false
+	}
+
+	return true
+}
+
+// paginate sorts the items by their unique keys in the requested order and returns the page
+// of items following the cursor along with the cursor of the next page, empty on the last page.
+func paginate[T any](items []T, key func(T) string, opt *listOptions) (page []T, nextCursor string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return (key(items[i]) < key(items[j])) != opt.descending
+	})
+
+	if opt.afterKey != "" {
+		start := sort.Search(len(items), func(i int) bool {
+			if opt.descending {
+				return key(items[i]) < opt.afterKey
+			}
+
+			return key(items[i]) > opt.afterKey
+		})
+
+		items = items[start:]
+	}
+
+	if opt.limit == 0 || len(items) <= opt.limit {
+		return items, ""
+	}
+
+	items = items[:opt.limit]
+
+	return items, base64.RawURLEncoding.EncodeToString([]byte(key(items[len(items)-1])))
+}
+
+// selectFields returns the response with items of the provided list reduced to the selected JSON fields.
+func (o *listOptions) selectFields(resp interface{}, listField string) (interface{}, error) {
+	if len(o.fields) == 0 {
+		return resp, nil
+	}
+
+	var m map[string]json.RawMessage
+
+	if err := remarshal(resp, &m); err != nil {
+		return nil, err
+	}
+
+	var items []map[string]json.RawMessage
+
+	if err := json.Unmarshal(m[listField], &items); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal list items")
+	}
+
+	selected := make([]map[string]json.RawMessage, 0, len(items))
+
+	for _, it := range items {
+		s := map[string]json.RawMessage{}
+
+		for _, f := range o.fields {
+			if v, ok := it[f]; ok {
+				s[f] = v
+			}
+		}
+
+		selected = append(selected, s)
+	}
+
+	b, err := json.Marshal(selected)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal list items")
+	}
+
+	m[listField] = b
+
+	return m, nil
+}
+
+func remarshal(v, dst interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal")
+	}
+
+	return errors.Wrap(json.Unmarshal(b, dst), "unable to unmarshal")
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleGetLogLevels(_ context.Context, rc requestContext) (interface{}, *apiError) {
+	ml := rc.srv.getOptions().ModuleLogLevels
+	if ml == nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "log levels cannot be adjusted on this server")
+	}
+
+	return &serverapi.LogLevels{
+		Subsystems: logging.Subsystems(),
+		Overrides:  ml.Overrides(),
+	}, nil
+}
+
+func handleSetLogLevels(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	ml := rc.srv.getOptions().ModuleLogLevels
+	if ml == nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "log levels cannot be adjusted on this server")
+	}
+
+	var req serverapi.LogLevels
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	// validate all levels before applying any of them.
+	for sub, lvl := range req.Overrides {
+		if lvl == "" {
+			continue
+		}
+
+		if _, err := logging.ParseLevel(lvl); err != nil {
+			return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+		}
+
+		if !logging.IsSubsystem(sub) {
+			return nil, requestError(serverapi.ErrorMalformedRequest, "unknown logging subsystem: "+sub)
+		}
+	}
+
+	for sub, lvl := range req.Overrides {
+		if lvl == "" {
+			ml.Clear(sub)
+			continue
+		}
+
+		l, _ := logging.ParseLevel(lvl)
+
+		if err := ml.Set(sub, l); err != nil {
+			return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+		}
+
+		log(ctx).Infof("log level for subsystem %v changed to %v", sub, logging.LevelName(l))
+	}
+
+	return &serverapi.LogLevels{
+		Subsystems: logging.Subsystems(),
+		Overrides:  ml.Overrides(),
+	}, nil
+}
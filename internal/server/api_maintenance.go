@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleMaintenanceInfo(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, errMaintenanceNotSupported.Error())
+	}
+
+	return maintenanceInfo(ctx, rc, dr)
+}
+
+func maintenanceInfo(ctx context.Context, rc requestContext, dr repo.DirectRepository) (interface{}, *apiError) {
+	p, err := maintenance.GetParams(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	sched, err := maintenance.GetSchedule(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.MaintenanceInfoResponse{
+		Params:        *p,
+		Schedule:      *sched,
+		ServerUser:    dr.ClientOptions().UsernameAtHost(),
+		OwnedByServer: p.Owner == dr.ClientOptions().UsernameAtHost(),
+	}
+
+	if m := rc.srv.maintenanceManager(); m != nil {
+		if t := m.nextMaintenanceTime(); !t.IsZero() {
+			resp.NextRunTime = &t
+		}
+	}
+
+	if t, ok := rc.srv.runningMaintenanceTask(); ok {
+		resp.RunningTask = &t
+	}
+
+	return resp, nil
+}
+
+func handleMaintenanceSet(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, errMaintenanceNotSupported.Error())
+	}
+
+	var req serverapi.SetMaintenanceRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	for _, cp := range []*maintenance.CycleParams{req.QuickCycle, req.FullCycle} {
+		if cp != nil && cp.Enabled && cp.Interval <= 0 {
+			return nil, requestError(serverapi.ErrorMalformedRequest, "maintenance interval must be positive")
+		}
+	}
+
+	if err := repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
+		Purpose: "MaintenanceSet",
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		return applyMaintenanceChanges(ctx, w, &req)
+	}); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	if b, err := json.Marshal(req); err == nil {
+		auditRequest(ctx, rc, auditlog.ActionMaintenanceSet, "", map[string]string{
+			"changes": string(b),
+		})
+	}
+
+	refreshMaintenance(ctx, rc)
+
+	return maintenanceInfo(ctx, rc, dr)
+}
+
+func applyMaintenanceChanges(ctx context.Context, w repo.DirectRepositoryWriter, req *serverapi.SetMaintenanceRequest) error {
+	p, err := maintenance.GetParams(ctx, w)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance params")
+	}
+
+	if req.QuickCycle != nil {
+		p.QuickCycle = *req.QuickCycle
+	}
+
+	if req.FullCycle != nil {
+		p.FullCycle = *req.FullCycle
+	}
+
+	if req.LogRetention != nil {
+		p.LogRetention = *req.LogRetention
+	}
+
+	if req.ExtendObjectLocks != nil {
+		p.ExtendObjectLocks = *req.ExtendObjectLocks
+	}
+
+	if req.ListParallelism != nil {
+		p.ListParallelism = *req.ListParallelism
+	}
+
+	blobCfg, err := w.FormatManager().BlobCfgBlob(ctx)
+	if err != nil {
+		return errors.Wrap(err, "blob configuration")
+	}
+
+	if err := maintenance.CheckExtendRetention(ctx, blobCfg, p); err != nil {
+		return errors.Wrap(err, "unable to apply maintenance changes")
+	}
+
+	if req.NextQuickMaintenanceTime != nil || req.NextFullMaintenanceTime != nil {
+		s, err := maintenance.GetSchedule(ctx, w)
+		if err != nil {
+			return errors.Wrap(err, "unable to get maintenance schedule")
+		}
+
+		if req.NextQuickMaintenanceTime != nil {
+			s.NextQuickMaintenanceTime = *req.NextQuickMaintenanceTime
+		}
+
+		if req.NextFullMaintenanceTime != nil {
+			s.NextFullMaintenanceTime = *req.NextFullMaintenanceTime
+		}
+
+		if err := maintenance.SetSchedule(ctx, w, s); err != nil {
+			return errors.Wrap(err, "unable to set maintenance schedule")
+		}
+	}
+
+	return errors.Wrap(maintenance.SetParams(ctx, w, p), "unable to set maintenance params")
+}
+
+func handleMaintenanceSetOwner(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, errMaintenanceNotSupported.Error())
+	}
+
+	var req serverapi.SetMaintenanceOwnerRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	owner := req.Owner
+	if owner == "" || owner == "me" {
+		owner = dr.ClientOptions().UsernameAtHost()
+	}
+
+	if u, h, ok := strings.Cut(owner, "@"); !ok || u == "" || h == "" {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "owner must be in the form user@hostname")
+	}
+
+	if err := repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
+		Purpose: "MaintenanceSetOwner",
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		p, err := maintenance.GetParams(ctx, w)
+		if err != nil {
+			return errors.Wrap(err, "unable to get maintenance params")
+		}
+
+		log(ctx).Infof("transferring maintenance ownership from %v to %v", p.Owner, owner)
+
+		p.Owner = owner
+
+		return errors.Wrap(maintenance.SetParams(ctx, w, p), "unable to set maintenance params")
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionMaintenanceOwner, owner, nil)
+
+	refreshMaintenance(ctx, rc)
+
+	return maintenanceInfo(ctx, rc, dr)
+}
+
+func handleMaintenanceRun(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.RunMaintenanceRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	mode := maintenance.Mode(req.Mode)
+
+	switch mode {
+	case "":
+		mode = maintenance.ModeAuto
+	case maintenance.ModeAuto, maintenance.ModeQuick, maintenance.ModeFull:
+	default:
+		return nil, requestError(serverapi.ErrorMalformedRequest, "invalid maintenance mode, must be 'auto', 'quick' or 'full'")
+	}
+
+	t, err := rc.srv.runMaintenanceAsync(ctx, mode, req.Force)
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	return t, nil
+}
+
+func handleMaintenanceCancel(_ context.Context, rc requestContext) (interface{}, *apiError) {
+	t, ok := rc.srv.runningMaintenanceTask()
+	if !ok {
+		return nil, notFoundError("maintenance is not running")
+	}
+
+	rc.srv.taskManager().CancelTask(t.TaskID)
+
+	return &serverapi.Empty{}, nil
+}
+
+// refreshMaintenance recomputes the time of the next maintenance after parameters or schedule have changed.
+func refreshMaintenance(ctx context.Context, rc requestContext) {
+	if m := rc.srv.maintenanceManager(); m != nil {
+		m.refresh(ctx, true)
+	}
+}
@@ -0,0 +1,91 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func TestMaintenanceAPI(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	serverUser := env.Repository.ClientOptions().UsernameAtHost()
+
+	var mi serverapi.MaintenanceInfoResponse
+
+	// transfer ownership away from the server and back.
+	require.NoError(t, cli.Post(ctx, "maintenance/owner", &serverapi.SetMaintenanceOwnerRequest{Owner: "someone@elsewhere"}, &mi))
+	require.Equal(t, "someone@elsewhere", mi.Params.Owner)
+	require.Equal(t, serverUser, mi.ServerUser)
+	require.False(t, mi.OwnedByServer)
+
+	require.Error(t, cli.Post(ctx, "maintenance/owner", &serverapi.SetMaintenanceOwnerRequest{Owner: "no-host"}, &mi))
+
+	// change parameters and pause full maintenance while the server is not the owner,
+	// so that periodic maintenance does not update the schedule.
+	pauseUntil := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+
+	require.NoError(t, cli.Put(ctx, "maintenance", &serverapi.SetMaintenanceRequest{
+		QuickCycle:              &maintenance.CycleParams{Enabled: true, Interval: 2 * time.Hour},
+		NextFullMaintenanceTime: &pauseUntil,
+	}, &mi))
+	require.Equal(t, 2*time.Hour, mi.Params.QuickCycle.Interval)
+	require.True(t, mi.Params.FullCycle.Enabled)
+	require.True(t, pauseUntil.Equal(mi.Schedule.NextFullMaintenanceTime))
+
+	require.Error(t, cli.Put(ctx, "maintenance", &serverapi.SetMaintenanceRequest{
+		FullCycle: &maintenance.CycleParams{Enabled: true},
+	}, &mi))
+
+	require.NoError(t, cli.Get(ctx, "maintenance", nil, &mi))
+	require.Equal(t, 2*time.Hour, mi.Params.QuickCycle.Interval)
+
+	// run maintenance although the server is not the owner and wait for it to finish.
+	require.Error(t, cli.Post(ctx, "maintenance/run", &serverapi.RunMaintenanceRequest{Mode: "bogus"}, &uitask.Info{}))
+
+	var ti uitask.Info
+
+	require.NoError(t, cli.Post(ctx, "maintenance/run", &serverapi.RunMaintenanceRequest{Mode: "quick", Force: true}, &ti))
+	require.Equal(t, "Maintenance", ti.Kind)
+
+	require.Eventually(t, func() bool {
+		info, err := serverapi.GetTask(ctx, cli, ti.TaskID)
+		require.NoError(t, err)
+
+		return info.Status.IsFinished()
+	}, 30*time.Second, 100*time.Millisecond)
+
+	info, err := serverapi.GetTask(ctx, cli, ti.TaskID)
+	require.NoError(t, err)
+	require.Equal(t, uitask.StatusSuccess, info.Status)
+
+	require.NoError(t, cli.Get(ctx, "maintenance", nil, &mi))
+	require.Nil(t, mi.RunningTask)
+	require.NotEmpty(t, mi.Schedule.Runs)
+
+	// nothing to cancel.
+	require.Error(t, cli.Post(ctx, "maintenance/cancel", &serverapi.Empty{}, &serverapi.Empty{}))
+
+	require.NoError(t, cli.Post(ctx, "maintenance/owner", &serverapi.SetMaintenanceOwnerRequest{Owner: "me"}, &mi))
+	require.Equal(t, serverUser, mi.Params.Owner)
+	require.True(t, mi.OwnedByServer)
+}
@@ -4,8 +4,8 @@
 	"context"
 	"encoding/json"
 
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func handleMountCreate(ctx context.Context, rc requestContext) (interface{}, *apiError) {
@@ -4,11 +4,11 @@
 	"context"
 	"encoding/json"
 
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/notification"
 	"github.com/kopia/kopia/notification/notifyprofile"
 	"github.com/kopia/kopia/notification/sender"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func handleNotificationProfileCreate(ctx context.Context, rc requestContext) (any, *apiError) {
@@ -7,13 +7,13 @@
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/internal/repotesting"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/servertesting"
 	"github.com/kopia/kopia/notification/notifyprofile"
 	"github.com/kopia/kopia/notification/sender"
 	"github.com/kopia/kopia/notification/sender/testsender"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func TestNotificationProfile(t *testing.T) {
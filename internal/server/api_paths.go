@@ -6,7 +6,7 @@
 	"path/filepath"
 
 	"github.com/kopia/kopia/internal/ospath"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 )
 
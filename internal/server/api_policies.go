@@ -8,9 +8,10 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/auditlog"
 	"github.com/kopia/kopia/internal/clock"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
@@ -86,7 +87,12 @@ func handlePolicyResolve(ctx context.Context, rc requestContext) (interface{}, *
 		policies[0].Labels = policy.LabelsForSource(target)
 	}
 
-	resp.Effective, resp.Definition = policy.MergePolicies(policies, target)
+	enforced, err := policy.GetEnforcedPolicy(ctx, rc.rep)
+	if err != nil && !errors.Is(err, policy.ErrPolicyNotFound) {
+		return nil, internalServerError(err)
+	}
+
+	resp.Effective, resp.Definition = policy.MergePoliciesWithEnforced(enforced, policies, target)
 	resp.UpcomingSnapshotTimes = []time.Time{}
 
 	if err := policy.ValidateSchedulingPolicy(policies[0].SchedulingPolicy); err != nil {
@@ -123,6 +129,8 @@ func handlePolicyDelete(ctx context.Context, rc requestContext) (interface{}, *a
 		return nil, internalServerError(err)
 	}
 
+	auditRequest(ctx, rc, auditlog.ActionPolicyDelete, sourceInfo.String(), nil)
+
 	rc.srv.Refresh()
 
 	return &serverapi.Empty{}, nil
@@ -148,6 +156,8 @@ func handlePolicyPut(ctx context.Context, rc requestContext) (interface{}, *apiE
 		return nil, internalServerError(err)
 	}
 
+	auditRequest(ctx, rc, auditlog.ActionPolicySet, sourceInfo.String(), nil)
+
 	rc.srv.Refresh()
 
 	return &serverapi.Empty{}, nil
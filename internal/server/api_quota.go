@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleQuotaUsageList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	resp := &serverapi.QuotaUsageResponse{
+		Users: []*serverapi.UserQuotaUsage{},
+	}
+
+	for u, v := range rc.srv.userQuotas().snapshot() {
+		resp.Users = append(resp.Users, &serverapi.UserQuotaUsage{
+			Username:             u,
+			MaxRequestsPerSecond: v.quota.MaxRequestsPerSecond,
+			MaxStorageBytes:      v.quota.MaxStorageBytes,
+			Requests:             v.requests,
+			ThrottledRequests:    v.throttledRequests,
+			RejectedWrites:       v.rejectedWrites,
+			StorageBytes:         v.storageBytes,
+		})
+	}
+
+	sort.Slice(resp.Users, func(i, j int) bool {
+		return resp.Users[i].Username < resp.Users[j].Username
+	})
+
+	return resp, nil
+}
+
+func handleQuotaUsageReset(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.ResetQuotaUsageRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	if !rc.srv.userQuotas().reset(req.Username) {
+		return nil, notFoundError("user not found")
+	}
+
+	if err := rc.srv.userQuotas().save(); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.Empty{}, nil
+}
@@ -4,24 +4,27 @@
 	"context"
 	"encoding/json"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/passwordpersist"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/encryption"
+	"github.com/kopia/kopia/repo/eventlog"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/hashing"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/splitter"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 const syncConnectWaitTime = 5 * time.Second
@@ -54,6 +57,7 @@ func handleRepoStatus(ctx context.Context, rc requestContext) (interface{}, *api
 			Storage:                    dr.BlobReader().ConnectionInfo().Type,
 			ClientOptions:              dr.ClientOptions(),
 			SupportsContentCompression: dr.ContentReader().SupportsContentCompression(),
+			CacheMemoryFallbackReason:  dr.ContentReader().CacheStats().MemoryFallbackReason,
 		}, nil
 	}
 
@@ -75,6 +79,117 @@ type remoteRepository interface {
 	return result, nil
 }
 
+func handleRepoCacheStats(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "cache statistics require direct repository connection")
+	}
+
+	return &serverapi.CacheStatsResponse{CacheStats: dr.ContentReader().CacheStats()}, nil
+}
+
+func handleRepoStats(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "repository statistics require direct repository connection")
+	}
+
+	st, err := maintenance.GetRepositoryStats(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if st == nil {
+		return nil, notFoundError("repository statistics are not available yet")
+	}
+
+	return &serverapi.RepositoryStatsResponse{RepositoryStats: *st}, nil
+}
+
+func handleRepoUsage(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "usage statistics require direct repository connection")
+	}
+
+	st, err := maintenance.GetRepositoryStats(ctx, dr)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if st == nil || st.Usage == nil {
+		return nil, notFoundError("usage statistics are not available yet")
+	}
+
+	return &serverapi.RepositoryUsageResponse{UsageStats: *st.Usage}, nil
+}
+
+func handleRepoDiagnostics(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	dr, ok := rc.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "diagnostics require direct repository connection")
+	}
+
+	d, err := dr.Diagnostics(ctx)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.RepositoryDiagnosticsResponse{ConnectionDiagnostics: *d}, nil
+}
+
+func handleRepoDedupReport(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	r, err := snapshotfs.CalculateDedupReport(ctx, rc.rep)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.DedupReportResponse{DedupReport: *r}, nil
+}
+
+func handleRepoHistory(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	q := rc.req.URL.Query()
+
+	var opt eventlog.ListOptions
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"since", &opt.Since},
+		{"until", &opt.Until},
+	} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, requestError(serverapi.ErrorMalformedRequest, "invalid "+p.name+": "+err.Error())
+			}
+
+			*p.dst = t
+		}
+	}
+
+	for _, t := range q["type"] {
+		opt.Types = append(opt.Types, eventlog.Type(t))
+	}
+
+	if v := q.Get("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, requestError(serverapi.ErrorMalformedRequest, "invalid max: "+err.Error())
+		}
+
+		opt.MaxResults = n
+	}
+
+	events, err := eventlog.List(ctx, rc.rep, opt)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.RepositoryHistoryResponse{Events: events}, nil
+}
+
 func maybeDecodeToken(req *serverapi.ConnectRepositoryRequest) *apiError {
 	if req.Token != "" {
 		ci, password, err := repo.DecodeToken(req.Token)
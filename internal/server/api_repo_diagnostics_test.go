@@ -0,0 +1,33 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func TestRepositoryDiagnosticsAPI(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	resp, err := serverapi.GetRepositoryDiagnostics(ctx, cli)
+	require.NoError(t, err)
+	require.Equal(t, env.RepositoryWriter.ObjectFormat().Splitter, resp.Splitter)
+	require.NotNil(t, resp.StorageLatency)
+	require.NotNil(t, resp.ClockSkew)
+}
@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func TestRepositoryUsageAPI(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	_, err = serverapi.GetRepositoryUsage(ctx, cli)
+	require.ErrorContains(t, err, "not available yet")
+
+	_, err = maintenance.UpdateRepositoryStats(ctx, env.RepositoryWriter, nil, &maintenance.UsageStats{
+		Bytes: 300,
+		Users: []*maintenance.UserUsageStats{
+			{UserName: "user1", Host: "host1", SnapshotCount: 2, UniqueBytes: 100, SharedBytes: 50},
+			{UserName: "user2", Host: "host2", SnapshotCount: 1, UniqueBytes: 150, SharedBytes: 50},
+		},
+	})
+	require.NoError(t, err)
+
+	resp, err := serverapi.GetRepositoryUsage(ctx, cli)
+	require.NoError(t, err)
+	require.EqualValues(t, 300, resp.Bytes)
+	require.Len(t, resp.Users, 2)
+	require.Equal(t, "user1", resp.Users[0].UserName)
+	require.Equal(t, 2, resp.Users[0].SnapshotCount)
+}
@@ -8,21 +8,27 @@
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/auditlog"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot/restore"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 func restoreCounters(s restore.Stats) map[string]uitask.CounterValue {
 	return map[string]uitask.CounterValue{
-		"Restored Files":       uitask.SimpleCounter(int64(s.RestoredFileCount)),
-		"Restored Directories": uitask.SimpleCounter(int64(s.RestoredDirCount)),
-		"Restored Symlinks":    uitask.SimpleCounter(int64(s.RestoredSymlinkCount)),
-		"Restored Bytes":       uitask.BytesCounter(s.RestoredTotalFileSize),
-		"Ignored Errors":       uitask.SimpleCounter(int64(s.IgnoredErrorCount)),
-		"Skipped Files":        uitask.SimpleCounter(int64(s.SkippedCount)),
-		"Skipped Bytes":        uitask.BytesCounter(s.SkippedTotalFileSize),
+		"Restored Files":          uitask.SimpleCounter(int64(s.RestoredFileCount)),
+		"Restored Directories":    uitask.SimpleCounter(int64(s.RestoredDirCount)),
+		"Restored Symlinks":       uitask.SimpleCounter(int64(s.RestoredSymlinkCount)),
+		"Restored Special Files":  uitask.SimpleCounter(int64(s.RestoredSpecialFileCount)),
+		"Restored Bytes":          uitask.BytesCounter(s.RestoredTotalFileSize),
+		"Ignored Errors":          uitask.SimpleCounter(int64(s.IgnoredErrorCount)),
+		"Skipped Files":           uitask.SimpleCounter(int64(s.SkippedCount)),
+		"Skipped Bytes":           uitask.BytesCounter(s.SkippedTotalFileSize),
+		"Verified Files":          uitask.SimpleCounter(int64(s.VerifiedFileCount)),
+		"Verification Mismatches": uitask.SimpleCounter(int64(s.VerificationMismatchCount)),
+		"Parallelism":             uitask.SimpleCounter(int64(s.Parallel)),
+		"Prefetch Bytes":          uitask.BytesCounter(s.PrefetchBytes),
 	}
 }
 
@@ -85,6 +91,10 @@ func handleRestore(ctx context.Context, rc requestContext) (interface{}, *apiErr
 		return nil, requestError(serverapi.ErrorMalformedRequest, "output not specified")
 	}
 
+	auditRequest(ctx, rc, auditlog.ActionSnapshotRestore, req.Root, map[string]string{
+		"destination": description,
+	})
+
 	taskIDChan := make(chan string)
 
 	// launch a goroutine that will continue the restore and can be observed in the Tasks UI.
@@ -99,6 +109,10 @@ func handleRestore(ctx context.Context, rc requestContext) (interface{}, *apiErr
 			ctrl.ReportCounters(restoreCounters(s))
 		}
 
+		opt.OnTuningDecision = func(ctx context.Context, d restore.TuningDecision) {
+			log(ctx).Infof("Auto-tuning: %v", d)
+		}
+
 		cancelChan := make(chan struct{})
 		opt.Cancel = cancelChan
 
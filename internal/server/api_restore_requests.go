@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/remoterestore"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleRestoreRequestList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	requests, err := remoterestore.List(ctx, rc.rep, rc.queryParam("username"), rc.queryParam("hostname"))
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return &serverapi.RestoreRequestsResponse{Requests: requests}, nil
+}
+
+func handleRestoreRequestCreate(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.CreateRestoreRequestRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	r := &remoterestore.Request{
+		Username:    req.Username,
+		Hostname:    req.Hostname,
+		SnapshotID:  req.SnapshotID,
+		Path:        req.Path,
+		Output:      req.Output,
+		Options:     req.Options,
+		RequestedBy: authenticatedUsername(rc),
+	}
+
+	if err := r.Validate(); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "RestoreRequestCreate",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return remoterestore.Create(ctx, w, r)
+	}); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionRestoreRequest, string(r.SnapshotID), map[string]string{
+		"id":     r.ID,
+		"client": r.Username + "@" + r.Hostname,
+	})
+
+	return r, nil
+}
+
+func handleRestoreRequestGet(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	r, err := remoterestore.Get(ctx, rc.rep, rc.muxVar("id"))
+	if err != nil {
+		return nil, restoreRequestError(err)
+	}
+
+	return r, nil
+}
+
+func handleRestoreRequestCancel(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var r *remoterestore.Request
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "RestoreRequestCancel",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		r, err = remoterestore.Cancel(ctx, w, rc.muxVar("id"))
+
+		return err
+	}); err != nil {
+		return nil, restoreRequestError(err)
+	}
+
+	return r, nil
+}
+
+func handleRestoreRequestDelete(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "RestoreRequestDelete",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return remoterestore.Delete(ctx, w, rc.muxVar("id"))
+	}); err != nil {
+		return nil, restoreRequestError(err)
+	}
+
+	return &serverapi.Empty{}, nil
+}
+
+func restoreRequestError(err error) *apiError {
+	if errors.Is(err, remoterestore.ErrNotFound) {
+		return notFoundError("restore request not found")
+	}
+
+	return internalServerError(err)
+}
@@ -0,0 +1,114 @@
+package server_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/remoterestore"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func TestRestoreRequests(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	var snapID manifest.ID
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		dir1 := mockfs.NewDirectory()
+		dir1.AddFile("file1", []byte{1, 2, 3}, 0o644)
+		dir1.AddDir("subdir", 0o755).AddFile("file2", []byte{1, 2, 4}, 0o644)
+
+		man, err := snapshotfs.NewUploader(w).Upload(ctx, dir1, nil, env.LocalPathSourceInfo("/dummy/path"))
+		require.NoError(t, err)
+
+		snapID, err = snapshot.SaveSnapshot(ctx, w, man)
+		require.NoError(t, err)
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, true)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	targetDir := t.TempDir()
+
+	var created remoterestore.Request
+
+	require.NoError(t, cli.Post(ctx, "restore-requests", &serverapi.CreateRestoreRequestRequest{
+		Username:   servertesting.TestUsername,
+		Hostname:   servertesting.TestHostname,
+		SnapshotID: snapID,
+		Output:     restore.FilesystemOutput{TargetPath: targetDir},
+	}, &created))
+	require.Equal(t, remoterestore.StatePending, created.Status.State)
+	require.Equal(t, servertesting.TestUIUsername, created.RequestedBy)
+
+	// invalid requests are rejected.
+	require.Error(t, cli.Post(ctx, "restore-requests", &serverapi.CreateRestoreRequestRequest{
+		Username:   servertesting.TestUsername,
+		Hostname:   servertesting.TestHostname,
+		SnapshotID: "no-such-snapshot",
+		Output:     restore.FilesystemOutput{TargetPath: targetDir},
+	}, &remoterestore.Request{}))
+
+	// the client connects to the server and executes the restore.
+	rep, err := servertesting.ConnectAndOpenAPIServer(t, ctx, srvInfo, repo.ClientOptions{
+		Username: servertesting.TestUsername,
+		Hostname: servertesting.TestHostname,
+	}, content.CachingOptions{
+		CacheDirectory: testutil.TempDirectory(t),
+	}, servertesting.TestPassword, &repo.Options{})
+	require.NoError(t, err)
+
+	defer rep.Close(ctx)
+
+	n, err := remoterestore.ProcessPending(ctx, rep, servertesting.TestUsername, servertesting.TestHostname)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	b, err := os.ReadFile(filepath.Join(targetDir, "subdir", "file2"))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 4}, b)
+
+	// the server sees progress reported by the client.
+	var got remoterestore.Request
+
+	require.NoError(t, cli.Get(ctx, "restore-requests/"+created.ID, nil, &got))
+	require.Equal(t, remoterestore.StateSucceeded, got.Status.State, got.Status.Error)
+	require.Equal(t, int32(2), got.Status.Stats.RestoredFileCount)
+
+	var list serverapi.RestoreRequestsResponse
+
+	require.NoError(t, cli.Get(ctx, "restore-requests?username="+servertesting.TestUsername, nil, &list))
+	require.Len(t, list.Requests, 1)
+
+	// finished requests can't be canceled.
+	require.NoError(t, cli.Post(ctx, "restore-requests/"+created.ID+"/cancel", &serverapi.Empty{}, &got))
+	require.Equal(t, remoterestore.StateSucceeded, got.Status.State)
+
+	require.NoError(t, cli.Delete(ctx, "restore-requests/"+created.ID, nil, nil, &serverapi.Empty{}))
+	require.Error(t, cli.Get(ctx, "restore-requests/"+created.ID, nil, &got))
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// maxPreviewSize is the maximum size of a file that can be previewed.
+const maxPreviewSize = 1 << 20
+
+// handleSnapshotBrowse returns entries of a directory in a snapshot.
+func handleSnapshotBrowse(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	man, root, aerr := snapshotRootForRequest(ctx, rc)
+	if aerr != nil {
+		return nil, aerr
+	}
+
+	relPath := strings.Trim(rc.queryParam("path"), "/")
+
+	entry, err := snapshotfs.GetNestedEntry(ctx, root, strings.Split(relPath, "/"))
+	if err != nil {
+		return nil, notFoundError("path not found")
+	}
+
+	dir, ok := entry.(fs.Directory)
+	if !ok {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "not a directory")
+	}
+
+	resp := &serverapi.SnapshotBrowseResponse{
+		SnapshotID: man.ID,
+		Path:       relPath,
+		Entries:    []*snapshot.DirEntry{},
+	}
+
+	if err := fs.IterateEntries(ctx, dir, func(_ context.Context, e fs.Entry) error {
+		if h, ok := e.(snapshot.HasDirEntry); ok {
+			resp.Entries = append(resp.Entries, h.DirEntry())
+		}
+
+		return nil
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return resp, nil
+}
+
+// handleSnapshotPreview returns contents of a small text or image file in a snapshot so that it can be displayed inline.
+func handleSnapshotPreview(ctx context.Context, rc requestContext) {
+	_, root, aerr := snapshotRootForRequest(ctx, rc)
+	if aerr != nil {
+		http.Error(rc.w, aerr.message, aerr.httpErrorCode)
+		return
+	}
+
+	entry, err := snapshotfs.GetNestedEntry(ctx, root, strings.Split(strings.Trim(rc.queryParam("path"), "/"), "/"))
+	if err != nil {
+		http.Error(rc.w, "path not found", http.StatusNotFound)
+		return
+	}
+
+	f, ok := entry.(fs.File)
+	if !ok {
+		http.Error(rc.w, "not a file", http.StatusBadRequest)
+		return
+	}
+
+	if f.Size() > maxPreviewSize {
+		http.Error(rc.w, "file too large to preview", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	r, err := f.Open(ctx)
+	if err != nil {
+		http.Error(rc.w, "unable to open file", http.StatusInternalServerError)
+		return
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(r, maxPreviewSize))
+	if err != nil {
+		http.Error(rc.w, "unable to read file", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := previewContentType(f.Name(), data)
+	if contentType == "" {
+		http.Error(rc.w, "preview is not supported for this file type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	rc.w.Header().Set("Content-Type", contentType)
+	rc.w.Header().Set("X-Content-Type-Options", "nosniff")
+	// do not allow previewed content to run scripts in the context of the UI.
+	rc.w.Header().Set("Content-Security-Policy", "sandbox")
+
+	http.ServeContent(rc.w, rc.req, f.Name(), f.ModTime(), bytes.NewReader(data))
+}
+
+// previewContentType returns the content type to preview a file with or an empty string if the file can't be previewed.
+// Text files, including markup, are always previewed as plain text.
+func previewContentType(name string, data []byte) string {
+	ct := mime.TypeByExtension(path.Ext(name))
+	if ct == "" {
+		ct = http.DetectContentType(data)
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case strings.HasPrefix(mt, "image/"):
+		return mt
+
+	case strings.HasPrefix(mt, "text/"), mt == "application/json", mt == "application/xml":
+		return "text/plain; charset=utf-8"
+
+	default:
+		return ""
+	}
+}
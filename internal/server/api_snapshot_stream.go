@@ -0,0 +1,185 @@
+package server
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// Archive formats supported by the snapshot stream endpoint.
+const (
+	StreamFormatTar   = "tar"
+	StreamFormatTarGz = "tgz"
+	StreamFormatZip   = "zip"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// canReadSnapshot returns true if the user authenticated in the request can read the snapshot manifest with given labels.
+// The UI user can read all snapshots, other users are subject to repository authorization rules.
+func canReadSnapshot(ctx context.Context, rc requestContext, labels map[string]string) bool {
+	if requireUIUser(ctx, rc) {
+		return true
+	}
+
+	username := authenticatedUsername(rc)
+	if username == "" {
+		return false
+	}
+
+	return rc.srv.getAuthorizer().Authorize(ctx, rc.rep, username).ManifestAccessLevel(labels) >= auth.AccessLevelRead
+}
+
+// snapshotRootForRequest loads the snapshot identified in the URL and returns its root entry
+// after verifying that the authenticated user can read it.
+func snapshotRootForRequest(ctx context.Context, rc requestContext) (*snapshot.Manifest, fs.Entry, *apiError) {
+	if rc.rep == nil {
+		return nil, nil, requestError(serverapi.ErrorNotConnected, "not connected")
+	}
+
+	var man snapshot.Manifest
+
+	md, err := rc.rep.GetManifest(ctx, manifest.ID(rc.muxVar("snapshotID")), &man)
+	if errors.Is(err, manifest.ErrNotFound) || err == nil && md.Labels[manifest.TypeLabelKey] != snapshot.ManifestType {
+		return nil, nil, notFoundError("snapshot not found")
+	}
+
+	if err != nil {
+		return nil, nil, internalServerError(errors.Wrap(err, "unable to load snapshot"))
+	}
+
+	if !canReadSnapshot(ctx, rc, md.Labels) {
+		return nil, nil, accessDeniedError()
+	}
+
+	man.ID = md.ID
+
+	root, err := snapshotfs.SnapshotRoot(rc.rep, &man)
+	if err != nil {
+		return nil, nil, internalServerError(errors.Wrap(err, "invalid snapshot root"))
+	}
+
+	return &man, root, nil
+}
+
+// selectedSnapshotEntry returns the entry to stream and its name. When multiple paths are selected
+// the entry is a virtual directory containing all of them, named after the snapshot.
+func selectedSnapshotEntry(ctx context.Context, man *snapshot.Manifest, root fs.Entry, paths []string) (fs.Entry, string, *apiError) {
+	if len(paths) <= 1 {
+		var relPath string
+
+		if len(paths) == 1 {
+			relPath = strings.Trim(paths[0], "/")
+		}
+
+		entry, err := snapshotfs.GetNestedEntry(ctx, root, strings.Split(relPath, "/"))
+		if err != nil {
+			return nil, "", notFoundError("path not found")
+		}
+
+		if relPath == "" {
+			return entry, string(man.ID), nil
+		}
+
+		return entry, path.Base(relPath), nil
+	}
+
+	var entries []fs.Entry
+
+	names := map[string]bool{}
+
+	for _, p := range paths {
+		relPath := strings.Trim(p, "/")
+		if relPath == "" {
+			return nil, "", requestError(serverapi.ErrorMalformedRequest, "snapshot root can't be selected along with other paths")
+		}
+
+		entry, err := snapshotfs.GetNestedEntry(ctx, root, strings.Split(relPath, "/"))
+		if err != nil {
+			return nil, "", notFoundError("path not found: " + relPath)
+		}
+
+		if names[entry.Name()] {
+			return nil, "", requestError(serverapi.ErrorMalformedRequest, "duplicate name of selected entries: "+entry.Name())
+		}
+
+		names[entry.Name()] = true
+
+		entries = append(entries, entry)
+	}
+
+	return virtualfs.NewStaticDirectory(string(man.ID), entries), string(man.ID), nil
+}
+
+// handleSnapshotStream streams the contents of a snapshot or selected entries in it as an archive,
+// which allows clients to restore files without repository access.
+func handleSnapshotStream(ctx context.Context, rc requestContext) {
+	man, root, aerr := snapshotRootForRequest(ctx, rc)
+	if aerr != nil {
+		http.Error(rc.w, aerr.message, aerr.httpErrorCode)
+		return
+	}
+
+	entry, name, aerr := selectedSnapshotEntry(ctx, man, root, rc.req.URL.Query()["path"])
+	if aerr != nil {
+		http.Error(rc.w, aerr.message, aerr.httpErrorCode)
+		return
+	}
+
+	var (
+		out         restore.Output
+		contentType string
+		w           = nopWriteCloser{rc.w}
+	)
+
+	switch format := rc.queryParam("format"); format {
+	case "", StreamFormatTar:
+		out, contentType, name = restore.NewTarOutput(w), "application/x-tar", name+".tar"
+
+	case StreamFormatTarGz:
+		out, contentType, name = restore.NewTarOutput(gzip.NewWriter(rc.w)), "application/gzip", name+".tar.gz"
+
+	case StreamFormatZip:
+		out, contentType, name = restore.NewZipOutput(w, zip.Deflate), "application/zip", name+".zip"
+
+	default:
+		http.Error(rc.w, "unsupported format", http.StatusBadRequest)
+		return
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionSnapshotStream, string(man.ID), map[string]string{
+		"paths": strings.Join(rc.req.URL.Query()["path"], ","),
+	})
+
+	rc.w.Header().Set("Content-Type", contentType)
+	rc.w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	rc.w.WriteHeader(http.StatusOK)
+
+	if _, err := restore.Entry(ctx, rc.rep, out, entry, restore.Options{
+		RestoreDirEntryAtDepth: math.MaxInt32,
+	}); err != nil {
+		// response headers have already been sent, the client will observe truncated archive.
+		log(ctx).Errorf("error streaming snapshot %v: %v", man.ID, err)
+	}
+}
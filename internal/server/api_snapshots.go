@@ -3,55 +3,133 @@
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/url"
+	"slices"
+	"strconv"
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/auditlog"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
+// userTagKeyPrefix is the prefix of user-defined snapshot tags, must match the one used by 'snapshot create --tags'.
+const userTagKeyPrefix = "tag:"
+
 func handleListSnapshots(ctx context.Context, rc requestContext) (interface{}, *apiError) {
 	si := getSnapshotSourceFromURL(rc.req.URL)
 
-	manifestIDs, err := snapshot.ListSnapshotManifests(ctx, rc.rep, &si, nil)
+	opt, err := parseListOptions(rc.req.URL.Query())
 	if err != nil {
-		return nil, internalServerError(err)
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
 	}
 
-	manifests, err := snapshot.LoadSnapshots(ctx, rc.rep, manifestIDs)
+	entries, err := rc.rep.FindManifests(ctx, snapshotLabelsMatchingSource(si))
 	if err != nil {
 		return nil, internalServerError(err)
 	}
 
-	manifests = snapshot.SortByTime(manifests, false)
+	// retention and uniqueness are determined separately for each source, the filter may match many.
+	manifestIDsBySource := map[snapshot.SourceInfo][]manifest.ID{}
+
+	for _, e := range entries {
+		if !opt.matchesPath(e.Labels[snapshot.PathLabel]) {
+			continue
+		}
+
+		src := snapshot.SourceInfo{
+			Host:     e.Labels[snapshot.HostnameLabel],
+			UserName: e.Labels[snapshot.UsernameLabel],
+			Path:     e.Labels[snapshot.PathLabel],
+		}
+
+		manifestIDsBySource[src] = append(manifestIDsBySource[src], e.ID)
+	}
 
 	resp := &serverapi.SnapshotsResponse{
 		Snapshots: []*serverapi.Snapshot{},
 	}
 
-	pol, _, _, err := policy.GetEffectivePolicy(ctx, rc.rep, si)
-	if err == nil {
-		pol.RetentionPolicy.ComputeRetentionReasons(manifests)
+	for src, manifestIDs := range manifestIDsBySource {
+		snaps, err := rc.srv.snapshotListCache().sourceSnapshots(ctx, rc.rep, src, manifestIDs)
+		if err != nil {
+			return nil, internalServerError(err)
+		}
+
+		var matching []*serverapi.Snapshot
+
+		for _, s := range snaps {
+			// retention reasons are computed for all snapshots of the source, time range only limits the results.
+			if opt.matchesTime(s.StartTime.ToTime()) {
+				matching = append(matching, s)
+			}
+		}
+
+		unique := uniqueSnapshots(cloneSnapshots(matching))
+
+		resp.UnfilteredCount += len(matching)
+		resp.UniqueCount += len(unique)
+
+		if rc.queryParam("all") == "" {
+			resp.Snapshots = append(resp.Snapshots, unique...)
+		} else {
+			resp.Snapshots = append(resp.Snapshots, cloneSnapshots(matching)...)
+		}
+	}
+
+	resp.Snapshots, resp.NextCursor = paginate(resp.Snapshots, snapshotSortKey, opt)
+
+	sresp, err := opt.selectFields(resp, "snapshots")
+	if err != nil {
+		return nil, internalServerError(err)
 	}
 
-	for _, m := range manifests {
-		resp.Snapshots = append(resp.Snapshots, convertSnapshotManifest(m))
+	return sresp, nil
+}
+
+// snapshotLabelsMatchingSource returns manifest labels matching snapshots of sources with the provided
+// host, username and path, empty values match all.
+func snapshotLabelsMatchingSource(si snapshot.SourceInfo) map[string]string {
+	labels := map[string]string{
+		manifest.TypeLabelKey: snapshot.ManifestType,
 	}
 
-	resp.UnfilteredCount = len(resp.Snapshots)
+	if si.Host != "" {
+		labels[snapshot.HostnameLabel] = si.Host
+	}
 
-	if rc.queryParam("all") == "" {
-		resp.Snapshots = uniqueSnapshots(resp.Snapshots)
-		resp.UniqueCount = len(resp.Snapshots)
-	} else {
-		resp.UniqueCount = len(uniqueSnapshots(resp.Snapshots))
+	if si.UserName != "" {
+		labels[snapshot.UsernameLabel] = si.UserName
 	}
 
-	return resp, nil
+	if si.Path != "" {
+		labels[snapshot.PathLabel] = si.Path
+	}
+
+	return labels
+}
+
+// snapshotSortKey orders snapshots by start time, the ID makes keys unique.
+func snapshotSortKey(s *serverapi.Snapshot) string {
+	return fmt.Sprintf("%020d/%v", int64(s.StartTime), s.ID)
+}
+
+func cloneSnapshots(rows []*serverapi.Snapshot) []*serverapi.Snapshot {
+	var result []*serverapi.Snapshot
+
+	for _, r := range rows {
+		c := *r
+		c.RetentionReasons = slices.Clone(r.RetentionReasons)
+		c.Pins = slices.Clone(r.Pins)
+		result = append(result, &c)
+	}
+
+	return result
 }
 
 func handleDeleteSnapshots(ctx context.Context, rc requestContext) (interface{}, *apiError) {
@@ -73,11 +151,11 @@ func handleDeleteSnapshots(ctx context.Context, rc requestContext) (interface{},
 		}
 	}
 
+	var manifestIDs []manifest.ID
+
 	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
 		Purpose: "DeleteSnapshots",
 	}, func(ctx context.Context, w repo.RepositoryWriter) error {
-		var manifestIDs []manifest.ID
-
 		if req.DeleteSourceAndPolicy {
 			mans, err := snapshot.ListSnapshotManifests(ctx, w, &req.SourceInfo, nil)
 			if err != nil {
@@ -101,7 +179,7 @@ func handleDeleteSnapshots(ctx context.Context, rc requestContext) (interface{},
 		}
 
 		for _, m := range manifestIDs {
-			if err := w.DeleteManifest(ctx, m); err != nil {
+			if err := snapshot.DeleteSnapshot(ctx, w, m, req.SourceInfo, "deleted by user"); err != nil {
 				return errors.Wrap(err, "uanble to delete snapshot")
 			}
 		}
@@ -120,6 +198,18 @@ func handleDeleteSnapshots(ctx context.Context, rc requestContext) (interface{},
 		return nil, internalServerError(err)
 	}
 
+	if req.DeleteSourceAndPolicy {
+		auditRequest(ctx, rc, auditlog.ActionSourceDelete, req.SourceInfo.String(), map[string]string{
+			"snapshots": strconv.Itoa(len(manifestIDs)),
+		})
+	} else {
+		for _, m := range manifestIDs {
+			auditRequest(ctx, rc, auditlog.ActionSnapshotDelete, string(m), map[string]string{
+				"source": req.SourceInfo.String(),
+			})
+		}
+	}
+
 	return &serverapi.Empty{}, nil
 }
 
@@ -201,6 +291,61 @@ func handleCancel(ctx context.Context, rc requestContext) (interface{}, *apiErro
 	return forAllSourceManagersMatchingURLFilter(ctx, rc.srv.snapshotAllSourceManagers(), (*sourceManager).cancel, rc.req.URL.Query())
 }
 
+func handleSourceSnapshot(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var req serverapi.SnapshotSourceRequest
+
+	if err := json.Unmarshal(rc.body, &req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request body")
+	}
+
+	sm := rc.srv.snapshotAllSourceManagers()[req.Source]
+	if sm == nil {
+		return nil, notFoundError("source not found")
+	}
+
+	if req.UploadBytesPerSecond < 0 {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "invalid upload speed")
+	}
+
+	if _, ok := rc.rep.(repo.DirectRepository); !ok && req.UploadBytesPerSecond > 0 {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "upload speed can only be overridden for direct repository connections")
+	}
+
+	ov := &snapshotOverrides{
+		description:          req.Description,
+		uploadBytesPerSecond: req.UploadBytesPerSecond,
+	}
+
+	if len(req.Tags) > 0 {
+		ov.tags = map[string]string{}
+
+		for k, v := range req.Tags {
+			ov.tags[userTagKeyPrefix+k] = v
+		}
+	}
+
+	started, err := sm.scheduleSnapshotWithOverrides(ov)
+	if errors.Is(err, errSnapshotInProgress) {
+		return nil, requestError(serverapi.ErrorSnapshotInProgress, err.Error())
+	}
+
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	select {
+	case taskID, ok := <-started:
+		if !ok {
+			return nil, internalServerError(errors.New("snapshot was not started"))
+		}
+
+		return &serverapi.SnapshotSourceResponse{TaskID: taskID}, nil
+
+	case <-ctx.Done():
+		return nil, internalServerError(ctx.Err())
+	}
+}
+
 func handlePause(ctx context.Context, rc requestContext) (interface{}, *apiError) {
 	return forAllSourceManagersMatchingURLFilter(ctx, rc.srv.snapshotAllSourceManagers(), (*sourceManager).pause, rc.req.URL.Query())
 }
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// snapshotListCache holds snapshots of each source converted for the list API along with their retention
+// reasons, so that paging through sources with many snapshots does not load and parse all their
+// manifests and recompute retention on every request.
+type snapshotListCache struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	sources map[snapshot.SourceInfo]*cachedSourceSnapshots
+}
+
+type cachedSourceSnapshots struct {
+	key       string                // hash of manifest IDs and retention policy the snapshots were computed from
+	snapshots []*serverapi.Snapshot // sorted by start time, must not be modified
+}
+
+// sourceSnapshots returns snapshots of a single source with the provided manifest IDs, sorted by
+// start time, with retention reasons computed using the effective policy of the source.
+// The returned snapshots are shared and must be cloned before being modified.
+func (c *snapshotListCache) sourceSnapshots(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo, manifestIDs []manifest.ID) ([]*serverapi.Snapshot, error) {
+	pol, _, _, err := policy.GetEffectivePolicy(ctx, rep, si)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get effective policy")
+	}
+
+	key, err := sourceSnapshotsCacheKey(manifestIDs, &pol.RetentionPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	cached := c.sources[si]
+	c.mu.Unlock()
+
+	if cached != nil && cached.key == key {
+		return cached.snapshots, nil
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, manifestIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshots")
+	}
+
+	manifests = snapshot.SortByTime(manifests, false)
+	pol.RetentionPolicy.ComputeRetentionReasons(manifests)
+
+	snaps := make([]*serverapi.Snapshot, 0, len(manifests))
+	for _, m := range manifests {
+		snaps = append(snaps, convertSnapshotManifest(m))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sources == nil {
+		c.sources = map[snapshot.SourceInfo]*cachedSourceSnapshots{}
+	}
+
+	c.sources[si] = &cachedSourceSnapshots{key, snaps}
+
+	return snaps, nil
+}
+
+// sourceSnapshotsCacheKey returns the key identifying snapshots with the provided manifest IDs and retention policy,
+// snapshot manifests are immutable, so any change to them or to the set of snapshots changes the key.
+func sourceSnapshotsCacheKey(manifestIDs []manifest.ID, rp *policy.RetentionPolicy) (string, error) {
+	h := sha256.New()
+
+	if err := json.NewEncoder(h).Encode(rp); err != nil {
+		return "", errors.Wrap(err, "unable to encode retention policy")
+	}
+
+	for _, id := range slices.Sorted(slices.Values(manifestIDs)) {
+		h.Write([]byte(id))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (s *Server) snapshotListCache() *snapshotListCache {
+	return &s.snapshotList
+}
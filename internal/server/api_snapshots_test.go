@@ -1,18 +1,31 @@
 package server_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/stretchr/testify/require"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/mockfs"
 	"github.com/kopia/kopia/internal/repotesting"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/server"
 	"github.com/kopia/kopia/internal/servertesting"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
@@ -179,6 +192,174 @@ func TestListAndDeleteSnapshots(t *testing.T) {
 	require.Empty(t, sourceList.Sources)
 }
 
+func TestListSnapshotsPagination(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	si1 := env.LocalPathSourceInfo("/dummy/path")
+	si2 := env.LocalPathSourceInfo("/another/path")
+
+	baseTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var (
+		ids   []manifest.ID
+		si2ID manifest.ID
+	)
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := snapshotfs.NewUploader(w)
+		dir := mockfs.NewDirectory()
+
+		for i := range 5 {
+			dir.AddFile(fmt.Sprintf("file%v", i), []byte{1, 2, byte(i)}, 0o644)
+
+			man, err := u.Upload(ctx, dir, nil, si1)
+			require.NoError(t, err)
+
+			man.StartTime = fs.UTCTimestampFromTime(baseTime.Add(time.Duration(i) * time.Hour))
+
+			id, err := snapshot.SaveSnapshot(ctx, w, man)
+			require.NoError(t, err)
+
+			ids = append(ids, id)
+		}
+
+		// same contents as the first snapshot of si1, but in a different source.
+		dir2 := mockfs.NewDirectory()
+		dir2.AddFile("file0", []byte{1, 2, 0}, 0o644)
+
+		man, err := u.Upload(ctx, dir2, nil, si2)
+		require.NoError(t, err)
+
+		man.StartTime = fs.UTCTimestampFromTime(baseTime.Add(-time.Hour))
+
+		si2ID, err = snapshot.SaveSnapshot(ctx, w, man)
+
+		return err
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	// page through all snapshots of the source, 2 at a time.
+	var (
+		got    []manifest.ID
+		cursor string
+		pages  int
+	)
+
+	for {
+		resp, err := serverapi.ListSnapshotsPage(ctx, cli, si1, true, serverapi.ListOptions{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+
+		pages++
+
+		for _, s := range resp.Snapshots {
+			got = append(got, s.ID)
+		}
+
+		if resp.NextCursor == "" {
+			break
+		}
+
+		cursor = resp.NextCursor
+	}
+
+	require.Equal(t, 3, pages)
+	require.Equal(t, ids, got)
+
+	// descending order.
+	resp, err := serverapi.ListSnapshotsPage(ctx, cli, si1, true, serverapi.ListOptions{Limit: 1, Descending: true})
+	require.NoError(t, err)
+	require.Len(t, resp.Snapshots, 1)
+	require.Equal(t, ids[4], resp.Snapshots[0].ID)
+	require.NotEmpty(t, resp.NextCursor)
+
+	resp, err = serverapi.ListSnapshotsPage(ctx, cli, si1, true, serverapi.ListOptions{Limit: 1, Descending: true, Cursor: resp.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, resp.Snapshots, 1)
+	require.Equal(t, ids[3], resp.Snapshots[0].ID)
+
+	// date range, 'to' is exclusive.
+	resp, err = serverapi.ListSnapshotsPage(ctx, cli, si1, true, serverapi.ListOptions{
+		From: baseTime.Add(1 * time.Hour),
+		To:   baseTime.Add(3 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []manifest.ID{ids[1], ids[2]}, snapshotIDs(resp.Snapshots))
+
+	// path glob across all paths of the user.
+	allPaths := snapshot.SourceInfo{Host: si1.Host, UserName: si1.UserName}
+
+	resp, err = serverapi.ListSnapshotsPage(ctx, cli, allPaths, true, serverapi.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, append([]manifest.ID{si2ID}, ids...), snapshotIDs(resp.Snapshots))
+
+	// identical snapshots of different sources are not merged and retention is computed for each source.
+	resp, err = serverapi.ListSnapshotsPage(ctx, cli, allPaths, false, serverapi.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, append([]manifest.ID{si2ID}, ids...), snapshotIDs(resp.Snapshots))
+	require.Equal(t, 6, resp.UniqueCount)
+	require.Contains(t, resp.Snapshots[0].RetentionReasons, "latest-1")
+	require.Contains(t, resp.Snapshots[5].RetentionReasons, "latest-1")
+
+	resp, err = serverapi.ListSnapshotsPage(ctx, cli, allPaths, true, serverapi.ListOptions{PathGlob: "/dummy/*"})
+	require.NoError(t, err)
+	require.Equal(t, ids, snapshotIDs(resp.Snapshots))
+
+	// field selection.
+	var raw struct {
+		Snapshots []map[string]any `json:"snapshots"`
+	}
+
+	require.NoError(t, cli.Get(ctx, "snapshots?all=1&path="+url.QueryEscape(si1.Path)+"&fields=id,startTime&limit=1", nil, &raw))
+	require.Len(t, raw.Snapshots, 1)
+	require.Len(t, raw.Snapshots[0], 2)
+	require.Equal(t, string(ids[0]), raw.Snapshots[0]["id"])
+
+	// sources.
+	sources, err := serverapi.ListSourcesPage(ctx, cli, nil, serverapi.ListOptions{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, sources.Sources, 1)
+	require.Equal(t, si2, sources.Sources[0].Source)
+	require.NotEmpty(t, sources.NextCursor)
+
+	sources, err = serverapi.ListSourcesPage(ctx, cli, nil, serverapi.ListOptions{Limit: 1, Cursor: sources.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, sources.Sources, 1)
+	require.Equal(t, si1, sources.Sources[0].Source)
+	require.Empty(t, sources.NextCursor)
+
+	sources, err = serverapi.ListSourcesPage(ctx, cli, nil, serverapi.ListOptions{PathGlob: "/dummy/**"})
+	require.NoError(t, err)
+	require.Len(t, sources.Sources, 1)
+	require.Equal(t, si1, sources.Sources[0].Source)
+
+	// invalid options.
+	_, err = serverapi.ListSnapshotsPage(ctx, cli, si1, true, serverapi.ListOptions{Cursor: "!"})
+	require.ErrorContains(t, err, "invalid cursor")
+
+	require.ErrorContains(t, cli.Get(ctx, "snapshots?limit=0", nil, &raw), "invalid limit")
+}
+
+func snapshotIDs(snaps []*serverapi.Snapshot) []manifest.ID {
+	var result []manifest.ID
+
+	for _, s := range snaps {
+		result = append(result, s.ID)
+	}
+
+	return result
+}
+
 func TestEditSnapshots(t *testing.T) {
 	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
 
@@ -256,3 +437,218 @@ func TestEditSnapshots(t *testing.T) {
 	require.EqualValues(t, []string{"pin2"}, updated[0].Pins)
 	require.EqualValues(t, newDesc2, updated[0].Description)
 }
+
+func TestStreamSnapshot(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	si1 := env.LocalPathSourceInfo("/dummy/path")
+
+	var id11 manifest.ID
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := snapshotfs.NewUploader(w)
+
+		dir1 := mockfs.NewDirectory()
+
+		dir1.AddFile("file1", []byte{1, 2, 3}, 0o644)
+		dir1.AddDir("subdir", 0o755).AddFile("file2", []byte{1, 2, 4}, 0o644)
+
+		man11, err := u.Upload(ctx, dir1, nil, si1)
+		require.NoError(t, err)
+		id11, err = snapshot.SaveSnapshot(ctx, w, man11)
+		require.NoError(t, err)
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+
+	require.Equal(t, map[string][]byte{
+		"file1":        {1, 2, 3},
+		"subdir/file2": {1, 2, 4},
+	}, readStreamedTar(ctx, t, cli, id11, "", server.StreamFormatTar))
+
+	require.Equal(t, map[string][]byte{
+		"file2": {1, 2, 4},
+	}, readStreamedTar(ctx, t, cli, id11, "/subdir", server.StreamFormatTarGz))
+
+	r, err := serverapi.StreamSnapshot(ctx, cli, id11, "", server.StreamFormatZip)
+	require.NoError(t, err)
+
+	zipData, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+
+	var hse apiclient.HTTPStatusError
+
+	_, err = serverapi.StreamSnapshot(ctx, cli, "no-such-snapshot", "", server.StreamFormatTar)
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusNotFound, hse.HTTPStatusCode)
+
+	_, err = serverapi.StreamSnapshot(ctx, cli, id11, "no-such-dir", server.StreamFormatTar)
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusNotFound, hse.HTTPStatusCode)
+
+	_, err = serverapi.StreamSnapshot(ctx, cli, id11, "", "rar")
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusBadRequest, hse.HTTPStatusCode)
+}
+
+func TestBrowseAndPreviewSnapshot(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	si1 := env.LocalPathSourceInfo("/dummy/path")
+
+	var id11 manifest.ID
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		u := snapshotfs.NewUploader(w)
+
+		dir1 := mockfs.NewDirectory()
+
+		dir1.AddFile("notes.txt", []byte("hello world"), 0o644)
+		dir1.AddFile("data.bin", []byte{0, 1, 2, 3}, 0o644)
+		dir1.AddFile("large.txt", bytes.Repeat([]byte("a"), 2<<20), 0o644)
+		dir1.AddDir("subdir", 0o755).AddFile("file2", []byte{1, 2, 4}, 0o644)
+
+		man11, err := u.Upload(ctx, dir1, nil, si1)
+		require.NoError(t, err)
+		id11, err = snapshot.SaveSnapshot(ctx, w, man11)
+		require.NoError(t, err)
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	resp, err := serverapi.BrowseSnapshot(ctx, cli, id11, "")
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range resp.Entries {
+		names = append(names, e.Name)
+	}
+
+	require.ElementsMatch(t, []string{"notes.txt", "data.bin", "large.txt", "subdir"}, names)
+
+	resp, err = serverapi.BrowseSnapshot(ctx, cli, id11, "/subdir")
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+	require.Equal(t, "file2", resp.Entries[0].Name)
+	require.Equal(t, snapshot.EntryTypeFile, resp.Entries[0].Type)
+
+	_, err = serverapi.BrowseSnapshot(ctx, cli, id11, "notes.txt")
+	require.ErrorContains(t, err, "not a directory")
+
+	r, err := serverapi.PreviewSnapshotFile(ctx, cli, id11, "notes.txt")
+	require.NoError(t, err)
+
+	preview, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "hello world", string(preview))
+
+	var hse apiclient.HTTPStatusError
+
+	_, err = serverapi.PreviewSnapshotFile(ctx, cli, id11, "data.bin")
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusUnsupportedMediaType, hse.HTTPStatusCode)
+
+	_, err = serverapi.PreviewSnapshotFile(ctx, cli, id11, "large.txt")
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusRequestEntityTooLarge, hse.HTTPStatusCode)
+
+	_, err = serverapi.PreviewSnapshotFile(ctx, cli, id11, "subdir")
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusBadRequest, hse.HTTPStatusCode)
+
+	// download selected entries as zip.
+	r, err = serverapi.StreamSnapshotEntries(ctx, cli, id11, []string{"notes.txt", "subdir"}, server.StreamFormatZip)
+	require.NoError(t, err)
+
+	zipData, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	require.NoError(t, err)
+
+	var zipNames []string
+
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() {
+			zipNames = append(zipNames, f.Name)
+		}
+	}
+
+	require.ElementsMatch(t, []string{"notes.txt", "subdir/file2"}, zipNames)
+
+	_, err = serverapi.StreamSnapshotEntries(ctx, cli, id11, []string{"notes.txt", "/"}, server.StreamFormatZip)
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusBadRequest, hse.HTTPStatusCode)
+
+	_, err = serverapi.StreamSnapshotEntries(ctx, cli, id11, []string{"notes.txt", "subdir/no-such-file"}, server.StreamFormatZip)
+	require.ErrorAs(t, err, &hse)
+	require.Equal(t, http.StatusNotFound, hse.HTTPStatusCode)
+}
+
+// readStreamedTar streams the snapshot and returns the contents of regular files in the archive.
+func readStreamedTar(ctx context.Context, t *testing.T, cli *apiclient.KopiaAPIClient, id manifest.ID, relPath, format string) map[string][]byte {
+	t.Helper()
+
+	r, err := serverapi.StreamSnapshot(ctx, cli, id, relPath, format)
+	require.NoError(t, err)
+
+	defer r.Close() //nolint:errcheck
+
+	var src io.Reader = r
+
+	if format == server.StreamFormatTarGz {
+		gz, err := gzip.NewReader(r)
+		require.NoError(t, err)
+
+		src = gz
+	}
+
+	result := map[string][]byte{}
+	tr := tar.NewReader(src)
+
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+
+		require.NoError(t, err)
+
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		result[h.Name], err = io.ReadAll(tr)
+		require.NoError(t, err)
+	}
+}
@@ -4,13 +4,12 @@
 	"context"
 	"encoding/json"
 	"os"
-	"sort"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/ospath"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
@@ -18,6 +17,11 @@
 func handleSourcesList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
 	_, multiUser := rc.rep.(repo.DirectRepository)
 
+	opt, err := parseListOptions(rc.req.URL.Query())
+	if err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
 	resp := &serverapi.SourcesResponse{
 		Sources:       []*serverapi.SourceStatus{},
 		LocalHost:     rc.rep.ClientOptions().Hostname,
@@ -26,16 +30,32 @@ func handleSourcesList(ctx context.Context, rc requestContext) (interface{}, *ap
 	}
 
 	for src, v := range rc.srv.snapshotAllSourceManagers() {
-		if sourceMatchesURLFilter(src, rc.req.URL.Query()) {
-			resp.Sources = append(resp.Sources, v.Status())
+		if !sourceMatchesURLFilter(src, rc.req.URL.Query()) || !opt.matchesPath(src.Path) {
+			continue
+		}
+
+		st := v.Status()
+
+		if !opt.from.IsZero() || !opt.to.IsZero() {
+			// with a time range, only return sources whose last snapshot was started within it.
+			if st.LastSnapshot == nil || !opt.matchesTime(st.LastSnapshot.StartTime.ToTime()) {
+				continue
+			}
 		}
+
+		resp.Sources = append(resp.Sources, st)
 	}
 
-	sort.Slice(resp.Sources, func(i, j int) bool {
-		return resp.Sources[i].Source.String() < resp.Sources[j].Source.String()
-	})
+	resp.Sources, resp.NextCursor = paginate(resp.Sources, func(s *serverapi.SourceStatus) string {
+		return s.Source.String()
+	}, opt)
 
-	return resp, nil
+	sresp, err := opt.selectFields(resp, "sources")
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return sresp, nil
 }
 
 func handleSourcesCreate(ctx context.Context, rc requestContext) (interface{}, *apiError) {
@@ -1,6 +1,7 @@
 package server_test
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -8,13 +9,13 @@
 
 	"github.com/stretchr/testify/require"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/repotesting"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/servertesting"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 )
@@ -159,3 +160,141 @@ func TestSourceRefreshesAfterPolicy(t *testing.T) {
 
 	require.True(t, match)
 }
+
+func TestSnapshotSourceWithOverrides(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	dir := testutil.TempDirectory(t)
+	si := env.LocalPathSourceInfo(dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file-a"), []byte{1, 2}, 0o644))
+
+	mustCreateSource(t, cli, dir, &policy.Policy{})
+
+	_, err = serverapi.SnapshotSource(ctx, cli, &serverapi.SnapshotSourceRequest{
+		Source: env.LocalPathSourceInfo(filepath.Join(dir, "no-such-source")),
+	})
+	require.ErrorContains(t, err, "source not found")
+
+	resp, err := serverapi.SnapshotSource(ctx, cli, &serverapi.SnapshotSourceRequest{
+		Source:               si,
+		Description:          "triggered remotely",
+		Tags:                 map[string]string{"reason": "test"},
+		UploadBytesPerSecond: 1e9,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.TaskID)
+
+	ti := waitForTask(t, cli, resp.TaskID, 15*time.Second)
+	require.Equal(t, uitask.StatusSuccess, ti.Status)
+
+	snaps, err := serverapi.ListSnapshots(ctx, cli, si, true)
+	require.NoError(t, err)
+	require.Len(t, snaps.Snapshots, 1)
+	require.Equal(t, "triggered remotely", snaps.Snapshots[0].Description)
+
+	man, err := snapshot.LoadSnapshot(ctx, env.RepositoryWriter, snaps.Snapshots[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"tag:reason": "test"}, man.Tags)
+
+	tasks, err := serverapi.QueryTasks(ctx, cli, uitask.Query{Kind: "Snapshot", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, tasks.TotalCount)
+	require.Equal(t, resp.TaskID, tasks.Tasks[0].TaskID)
+
+	tasks, err = serverapi.QueryTasks(ctx, cli, uitask.Query{Kind: "Snapshot", Status: uitask.StatusFailed})
+	require.NoError(t, err)
+	require.Equal(t, 0, tasks.TotalCount)
+	require.Empty(t, tasks.Tasks)
+}
+
+func TestEventStream(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+	srvInfo := servertesting.StartServer(t, env, false)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             srvInfo.BaseURL,
+		TrustedServerCertificateFingerprint: srvInfo.TrustedServerCertificateFingerprint,
+		Username:                            servertesting.TestUIUsername,
+		Password:                            servertesting.TestUIPassword,
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, cli.FetchCSRFTokenForTesting(ctx))
+
+	dir := testutil.TempDirectory(t)
+	si := env.LocalPathSourceInfo(dir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file-a"), []byte{1, 2}, 0o644))
+
+	mustCreateSource(t, cli, dir, &policy.Policy{})
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan *serverapi.Event, 1000)
+	watchErr := make(chan error, 1)
+
+	go func() {
+		watchErr <- serverapi.WatchEvents(watchCtx, cli, func(ev *serverapi.Event) error {
+			events <- ev
+			return nil
+		})
+	}()
+
+	// wait until the event stream is established by running tasks until we observe one.
+	require.Eventually(t, func() bool {
+		_, err := serverapi.Estimate(ctx, cli, &serverapi.EstimateRequest{Root: dir})
+		require.NoError(t, err)
+
+		select {
+		case <-events:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}, 30*time.Second, 10*time.Millisecond)
+
+	resp, err := serverapi.SnapshotSource(ctx, cli, &serverapi.SnapshotSourceRequest{Source: si})
+	require.NoError(t, err)
+
+	seen := map[string]bool{}
+
+	for !seen[serverapi.EventSnapshotFinished] {
+		select {
+		case ev := <-events:
+			if ev.Task != nil && ev.Task.TaskID == resp.TaskID {
+				seen[ev.Type] = true
+			}
+
+			if ev.Source != nil && *ev.Source == si {
+				seen[ev.Type] = true
+
+				if ev.Type == serverapi.EventSnapshotFinished {
+					require.Empty(t, ev.Error)
+					require.NotEmpty(t, ev.SnapshotID)
+				}
+			}
+
+		case <-time.After(30 * time.Second):
+			t.Fatalf("timed out waiting for events, got %v", seen)
+		}
+	}
+
+	require.True(t, seen[serverapi.EventTaskStarted])
+	require.True(t, seen[serverapi.EventSnapshotStarted])
+
+	cancel()
+	require.NoError(t, <-watchErr)
+}
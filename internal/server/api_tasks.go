@@ -2,19 +2,37 @@
 
 import (
 	"context"
+	"strconv"
 
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func handleTaskList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
-	tasks := rc.srv.taskManager().ListTasks()
-	if tasks == nil {
-		tasks = []uitask.Info{}
+	q := uitask.Query{
+		Kind:   rc.queryParam("kind"),
+		Status: uitask.Status(rc.queryParam("status")),
 	}
 
+	for param, target := range map[string]*int{
+		"offset": &q.Offset,
+		"limit":  &q.Limit,
+	} {
+		if s := rc.queryParam(param); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 0 {
+				return nil, requestError(serverapi.ErrorMalformedRequest, "invalid "+param)
+			}
+
+			*target = v
+		}
+	}
+
+	tasks, total := rc.srv.taskManager().QueryTasks(q)
+
 	return serverapi.TaskListResponse{
-		Tasks: tasks,
+		Tasks:      tasks,
+		TotalCount: total,
 	}, nil
 }
 
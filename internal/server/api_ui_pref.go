@@ -9,7 +9,7 @@
 	"github.com/natefinch/atomic"
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func getUIPreferencesOrEmpty(s serverInterface) (serverapi.UIPreferences, error) {
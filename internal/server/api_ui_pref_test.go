@@ -5,10 +5,10 @@
 
 	"github.com/stretchr/testify/require"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/internal/repotesting"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func TestUIPreferences(t *testing.T) {
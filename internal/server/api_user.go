@@ -3,8 +3,8 @@
 import (
 	"context"
 
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
 )
 
 func handleCurrentUser(ctx context.Context, _ requestContext) (interface{}, *apiError) {
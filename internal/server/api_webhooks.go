@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+func handleWebhookList(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	hooks, err := webhook.List(ctx, rc.rep)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.WebhooksResponse{
+		Webhooks: []*serverapi.WebhookInfo{},
+	}
+
+	for _, wh := range hooks {
+		wi := &serverapi.WebhookInfo{Webhook: *wh, HasSecret: wh.Secret != ""}
+		wi.Secret = ""
+
+		resp.Webhooks = append(resp.Webhooks, wi)
+	}
+
+	return resp, nil
+}
+
+func handleWebhookSet(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	var wh webhook.Webhook
+
+	if err := json.Unmarshal(rc.body, &wh); err != nil {
+		return nil, unableToDecodeRequest(err)
+	}
+
+	wh.Name = rc.muxVar("name")
+
+	if err := wh.Validate(); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "WebhookSet",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return webhook.Set(ctx, w, &wh)
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionWebhookSet, wh.Name, map[string]string{
+		"url":    wh.URL,
+		"events": strings.Join(wh.Events, ","),
+	})
+
+	rc.srv.checkWebhookConditionsSoon()
+
+	return &serverapi.Empty{}, nil
+}
+
+func handleWebhookDelete(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	name := rc.muxVar("name")
+
+	var found bool
+
+	if err := repo.WriteSession(ctx, rc.rep, repo.WriteSessionOptions{
+		Purpose: "WebhookDelete",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		var err error
+
+		found, err = webhook.Delete(ctx, w, name)
+
+		return err
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if !found {
+		return nil, notFoundError("webhook not found")
+	}
+
+	auditRequest(ctx, rc, auditlog.ActionWebhookDelete, name, nil)
+
+	return &serverapi.Empty{}, nil
+}
+
+func handleWebhookTest(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+	name := rc.muxVar("name")
+
+	hooks, err := webhook.List(ctx, rc.rep)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	for _, wh := range hooks {
+		if wh.Name != name {
+			continue
+		}
+
+		if err := rc.srv.sendWebhook(ctx, wh, &webhook.Event{Type: webhook.EventTest}); err != nil {
+			return nil, requestError(serverapi.ErrorInternal, errors.Wrap(err, "webhook test failed").Error())
+		}
+
+		return &serverapi.Empty{}, nil
+	}
+
+	return nil, notFoundError("webhook not found")
+}
@@ -0,0 +1,81 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/servertesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestGRPCDeleteSnapshotUnderLegalHold(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3, repotesting.Options{
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.RetentionMode = blob.Governance
+			nro.RetentionPeriod = 48 * time.Hour
+		},
+	})
+
+	src := snapshot.SourceInfo{Host: servertesting.TestHostname, UserName: servertesting.TestUsername, Path: "/some/path"}
+
+	var heldID, oldID manifest.ID
+
+	require.NoError(t, repo.WriteSession(ctx, env.Repository, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		p, err := maintenance.GetParams(ctx, w)
+		require.NoError(t, err)
+
+		p.SnapshotLegalHold = true
+		require.NoError(t, maintenance.SetParams(ctx, w, p))
+
+		heldID, err = snapshot.SaveSnapshot(ctx, w, &snapshot.Manifest{Source: src, StartTime: fs.UTCTimestampFromTime(clock.Now())})
+		require.NoError(t, err)
+
+		oldID, err = snapshot.SaveSnapshot(ctx, w, &snapshot.Manifest{Source: src, StartTime: fs.UTCTimestampFromTime(clock.Now().Add(-72 * time.Hour))})
+		require.NoError(t, err)
+
+		return nil
+	}))
+
+	srvInfo := servertesting.StartServer(t, env, true)
+
+	rep, err := servertesting.ConnectAndOpenAPIServer(t, ctx, srvInfo, repo.ClientOptions{
+		Username: servertesting.TestUsername,
+		Hostname: servertesting.TestHostname,
+	}, content.CachingOptions{
+		CacheDirectory: testutil.TempDirectory(t),
+	}, servertesting.TestPassword, &repo.Options{})
+	require.NoError(t, err)
+
+	defer rep.Close(ctx)
+
+	// the client can't determine the legal hold period, the server refuses to delete the held snapshot.
+	err = repo.WriteSession(ctx, rep, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return snapshot.DeleteSnapshot(ctx, w, heldID, src, "test")
+	})
+	require.ErrorContains(t, err, snapshot.ErrLegalHold.Error())
+
+	require.NoError(t, repo.WriteSession(ctx, rep, repo.WriteSessionOptions{Purpose: "Test"}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return snapshot.DeleteSnapshot(ctx, w, oldID, src, "test")
+	}))
+
+	require.NoError(t, env.Repository.Refresh(ctx))
+
+	_, err = snapshot.LoadSnapshot(ctx, env.Repository, heldID)
+	require.NoError(t, err)
+
+	_, err = snapshot.LoadSnapshot(ctx, env.Repository, oldID)
+	require.ErrorIs(t, err, snapshot.ErrSnapshotNotFound)
+}
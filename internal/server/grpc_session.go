@@ -18,6 +18,7 @@
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"github.com/kopia/kopia/internal/auditlog"
 	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/grpcapi"
@@ -37,6 +38,9 @@ type grpcServerState struct {
 	grpcapi.UnimplementedKopiaRepositoryServer
 
 	sem *semaphore.Weighted
+
+	clients clientSessionTracker
+	quotas  userQuotaTracker
 }
 
 // send sends the provided session response with the provided request ID.
@@ -73,6 +77,34 @@ func (s *Server) authenticateGRPCSession(ctx context.Context, rep repo.Repositor
 	return "", status.Errorf(codes.PermissionDenied, "missing credentials")
 }
 
+// grpcClientVersion returns the version of kopia reported by the client or an empty string.
+func grpcClientVersion(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if v := md.Get("kopia-version"); len(v) == 1 {
+		return v[0]
+	}
+
+	return ""
+}
+
+// grpcClaimedUsername returns the username@hostname the client attempted to authenticate as or an empty string.
+func grpcClaimedUsername(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if u, h := md.Get("kopia-username"), md.Get("kopia-hostname"); len(u) == 1 && len(h) == 1 {
+		return u[0] + "@" + h[0]
+	}
+
+	return ""
+}
+
 // Session handles GRPC session from a repository client.
 func (s *Server) Session(srv grpcapi.KopiaRepository_SessionServer) error {
 	ctx := srv.Context()
@@ -85,8 +117,14 @@ func (s *Server) Session(srv grpcapi.KopiaRepository_SessionServer) error {
 		return status.Errorf(codes.Unavailable, "not connected to a direct repository")
 	}
 
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "peer not found in context")
+	}
+
 	usernameAtHostname, err := s.authenticateGRPCSession(ctx, dr)
 	if err != nil {
+		s.recordAudit(ctx, dr, grpcClaimedUsername(ctx), auditlog.ActionSessionDenied, p.Addr.String(), nil)
 		return err
 	}
 
@@ -95,20 +133,28 @@ func (s *Server) Session(srv grpcapi.KopiaRepository_SessionServer) error {
 		authz = auth.NoAccess()
 	}
 
-	p, ok := peer.FromContext(ctx)
-	if !ok {
-		return status.Errorf(codes.PermissionDenied, "peer not found in context")
-	}
-
 	log(ctx).Infof("starting session for user %q from %v", usernameAtHostname, p.Addr)
 	defer log(ctx).Infof("session ended for user %q from %v", usernameAtHostname, p.Addr)
 
-	opt, err := s.handleInitialSessionHandshake(srv, dr)
+	s.grpcServerState.clients.sessionStarted(usernameAtHostname, grpcClientVersion(ctx), p.Addr.String())
+	s.recordAudit(ctx, dr, usernameAtHostname, auditlog.ActionSessionStart, p.Addr.String(), map[string]string{
+		"version": grpcClientVersion(ctx),
+	})
+	defer s.grpcServerState.clients.sessionEnded(usernameAtHostname)
+
+	s.grpcServerState.quotas.sessionStarted(usernameAtHostname, s.grpcServerState.quotas.effectiveQuota(ctx, dr, usernameAtHostname))
+
+	opt, readOnly, err := s.handleInitialSessionHandshake(srv, dr)
 	if err != nil {
 		log(ctx).Errorf("session handshake error: %v", err)
 		return err
 	}
 
+	if readOnly {
+		// client connected in read-only mode, reject all mutations regardless of its permissions.
+		authz = auth.ReadOnly(authz)
+	}
+
 	//nolint:wrapcheck
 	return repo.DirectWriteSession(ctx, dr, opt, func(ctx context.Context, dw repo.DirectRepositoryWriter) error {
 		// channel to which workers will be sending errors, only holds 1 slot and sends are non-blocking.
@@ -124,6 +170,11 @@ func (s *Server) Session(srv grpcapi.KopiaRepository_SessionServer) error {
 			default:
 			}
 
+			// throttle requests exceeding the rate quota of the user before taking up concurrency slots.
+			if err := s.grpcServerState.quotas.waitForRequest(ctx, usernameAtHostname); err != nil {
+				return err
+			}
+
 			// enforce limit on concurrent handling
 			if err := s.grpcServerState.sem.Acquire(ctx, 1); err != nil {
 				return errors.Wrap(err, "unable to acquire semaphore")
@@ -155,6 +206,9 @@ func (s *Server) handleSessionRequest(ctx context.Context, dw repo.DirectReposit
 		ctx = tc.Extract(ctx, propagation.MapCarrier(req.GetTraceContext()))
 	}
 
+	metricSessionRequests.WithLabelValues(usernameAtHostname).Inc()
+	s.grpcServerState.clients.touch(usernameAtHostname)
+
 	switch inner := req.GetRequest().(type) {
 	case *grpcapi.SessionRequest_GetContentInfo:
 		respond(handleGetContentInfoRequest(ctx, dw, authz, inner.GetContentInfo))
@@ -163,22 +217,26 @@ func (s *Server) handleSessionRequest(ctx context.Context, dw repo.DirectReposit
 		respond(handleGetContentRequest(ctx, dw, authz, inner.GetContent))
 
 	case *grpcapi.SessionRequest_WriteContent:
-		respond(handleWriteContentRequest(ctx, dw, authz, inner.WriteContent))
+		respond(s.handleWriteContentRequest(ctx, dw, authz, usernameAtHostname, inner.WriteContent))
 
 	case *grpcapi.SessionRequest_Flush:
 		respond(handleFlushRequest(ctx, dw, authz, inner.Flush))
 
+		if err := s.grpcServerState.quotas.save(); err != nil {
+			log(ctx).Errorf("unable to save quota usage: %v", err)
+		}
+
 	case *grpcapi.SessionRequest_GetManifest:
 		respond(handleGetManifestRequest(ctx, dw, authz, inner.GetManifest))
 
 	case *grpcapi.SessionRequest_PutManifest:
-		respond(handlePutManifestRequest(ctx, dw, authz, inner.PutManifest))
+		respond(s.handlePutManifestRequest(ctx, dw, authz, usernameAtHostname, inner.PutManifest))
 
 	case *grpcapi.SessionRequest_FindManifests:
 		handleFindManifestsRequest(ctx, dw, authz, inner.FindManifests, respond)
 
 	case *grpcapi.SessionRequest_DeleteManifest:
-		respond(handleDeleteManifestRequest(ctx, dw, authz, inner.DeleteManifest))
+		respond(s.handleDeleteManifestRequest(ctx, dw, authz, usernameAtHostname, inner.DeleteManifest))
 
 	case *grpcapi.SessionRequest_PrefetchContents:
 		respond(handlePrefetchContentsRequest(ctx, dw, authz, inner.PrefetchContents))
@@ -260,7 +318,7 @@ func handleGetContentRequest(ctx context.Context, dw repo.DirectRepositoryWriter
 	}
 }
 
-func handleWriteContentRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, req *grpcapi.WriteContentRequest) *grpcapi.SessionResponse {
+func (s *Server) handleWriteContentRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, usernameAtHostname string, req *grpcapi.WriteContentRequest) *grpcapi.SessionResponse {
 	ctx, span := tracer.Start(ctx, "GRPCSession.WriteContent")
 	defer span.End()
 
@@ -273,11 +331,32 @@ func handleWriteContentRequest(ctx context.Context, dw repo.DirectRepositoryWrit
 		return accessDeniedResponse()
 	}
 
-	contentID, err := dw.ContentManager().WriteContent(ctx, gather.FromSlice(req.GetData()), content.IDPrefix(req.GetPrefix()), compression.HeaderID(req.GetCompression()))
+	data := gather.FromSlice(req.GetData())
+	prefix := content.IDPrefix(req.GetPrefix())
+
+	// only contents which the repository does not already have count towards the storage quota.
+	isNew, err := isNewContent(ctx, dw, data, prefix)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if isNew {
+		if err := s.grpcServerState.quotas.checkStorage(usernameAtHostname, int64(data.Length())); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	contentID, err := dw.ContentManager().WriteContent(ctx, data, prefix, compression.HeaderID(req.GetCompression()))
 	if err != nil {
 		return errorResponse(err)
 	}
 
+	metricSessionUploadedBytes.WithLabelValues(usernameAtHostname).Add(float64(len(req.GetData())))
+
+	if isNew {
+		s.grpcServerState.quotas.recordStorage(usernameAtHostname, int64(data.Length()))
+	}
+
 	return &grpcapi.SessionResponse{
 		Response: &grpcapi.SessionResponse_WriteContent{
 			WriteContent: &grpcapi.WriteContentResponse{
@@ -287,6 +366,25 @@ func handleWriteContentRequest(ctx context.Context, dw repo.DirectRepositoryWrit
 	}
 }
 
+// isNewContent returns true if writing the provided data would add a content which the repository does not have yet.
+func isNewContent(ctx context.Context, dw repo.DirectRepositoryWriter, data gather.Bytes, prefix content.IDPrefix) (bool, error) {
+	contentID, err := dw.ContentManager().ContentIDForData(data, prefix)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to compute content ID")
+	}
+
+	ci, err := dw.ContentInfo(ctx, contentID)
+	if errors.Is(err, content.ErrContentNotFound) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrap(err, "unable to get content info")
+	}
+
+	return ci.Deleted, nil
+}
+
 func handleFlushRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, _ *grpcapi.FlushRequest) *grpcapi.SessionResponse {
 	if authz.ContentAccessLevel() < auth.AccessLevelAppend {
 		return accessDeniedResponse()
@@ -329,7 +427,7 @@ func handleGetManifestRequest(ctx context.Context, dw repo.DirectRepositoryWrite
 	}
 }
 
-func handlePutManifestRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, req *grpcapi.PutManifestRequest) *grpcapi.SessionResponse {
+func (s *Server) handlePutManifestRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, usernameAtHostname string, req *grpcapi.PutManifestRequest) *grpcapi.SessionResponse {
 	ctx, span := tracer.Start(ctx, "GRPCSession.PutManifest")
 	defer span.End()
 
@@ -342,6 +440,15 @@ func handlePutManifestRequest(ctx context.Context, dw repo.DirectRepositoryWrite
 		return errorResponse(err)
 	}
 
+	recordRemoteSnapshotMetrics(req.GetLabels(), req.GetJsonData())
+	s.notifySnapshotWebhooks(req.GetLabels(), manifestID, req.GetJsonData())
+
+	if req.GetLabels()[manifest.TypeLabelKey] == policy.ManifestType {
+		s.recordAudit(ctx, dw, usernameAtHostname, auditlog.ActionPolicySet, manifestAuditSource(req.GetLabels()), map[string]string{
+			"manifestID": string(manifestID),
+		})
+	}
+
 	return &grpcapi.SessionResponse{
 		Response: &grpcapi.SessionResponse_PutManifest{
 			PutManifest: &grpcapi.PutManifestResponse{
@@ -397,7 +504,7 @@ func handleFindManifestsRequest(ctx context.Context, dw repo.DirectRepositoryWri
 	})
 }
 
-func handleDeleteManifestRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, req *grpcapi.DeleteManifestRequest) *grpcapi.SessionResponse {
+func (s *Server) handleDeleteManifestRequest(ctx context.Context, dw repo.DirectRepositoryWriter, authz auth.AuthorizationInfo, usernameAtHostname string, req *grpcapi.DeleteManifestRequest) *grpcapi.SessionResponse {
 	ctx, span := tracer.Start(ctx, "GRPCSession.DeleteManifest")
 	defer span.End()
 
@@ -412,10 +519,21 @@ func handleDeleteManifestRequest(ctx context.Context, dw repo.DirectRepositoryWr
 		return accessDeniedResponse()
 	}
 
+	// clients can't determine the legal hold period, so it must be enforced here.
+	if em.Labels[manifest.TypeLabelKey] == snapshot.ManifestType {
+		if err := snapshot.CheckLegalHold(ctx, dw, em.ID); err != nil {
+			return errorResponse(err)
+		}
+	}
+
 	if err := dw.DeleteManifest(ctx, manifest.ID(req.GetManifestId())); err != nil {
 		return errorResponse(err)
 	}
 
+	s.recordAudit(ctx, dw, usernameAtHostname, manifestDeleteAuditAction(em.Labels), manifestAuditSource(em.Labels), map[string]string{
+		"manifestID": req.GetManifestId(),
+	})
+
 	return &grpcapi.SessionResponse{
 		Response: &grpcapi.SessionResponse_DeleteManifest{
 			DeleteManifest: &grpcapi.DeleteManifestResponse{},
@@ -423,6 +541,30 @@ func handleDeleteManifestRequest(ctx context.Context, dw repo.DirectRepositoryWr
 	}
 }
 
+// manifestAuditSource returns the source of the snapshot or policy manifest with given labels.
+func manifestAuditSource(labels map[string]string) string {
+	if t := labels[manifest.TypeLabelKey]; t != snapshot.ManifestType && t != policy.ManifestType {
+		return ""
+	}
+
+	return snapshot.SourceInfo{
+		Host:     labels[snapshot.HostnameLabel],
+		UserName: labels[snapshot.UsernameLabel],
+		Path:     labels[snapshot.PathLabel],
+	}.String()
+}
+
+func manifestDeleteAuditAction(labels map[string]string) string {
+	switch labels[manifest.TypeLabelKey] {
+	case snapshot.ManifestType:
+		return auditlog.ActionSnapshotDelete
+	case policy.ManifestType:
+		return auditlog.ActionPolicyDelete
+	default:
+		return auditlog.ActionManifestDelete
+	}
+}
+
 func handlePrefetchContentsRequest(ctx context.Context, rep repo.Repository, authz auth.AuthorizationInfo, req *grpcapi.PrefetchContentsRequest) *grpcapi.SessionResponse {
 	ctx, span := tracer.Start(ctx, "GRPCSession.PrefetchContents")
 	defer span.End()
@@ -532,6 +674,8 @@ func errorResponse(err error) *grpcapi.SessionResponse {
 		errorCode = grpcapi.ErrorResponse_MANIFEST_NOT_FOUND
 	case errors.Is(err, object.ErrObjectNotFound):
 		errorCode = grpcapi.ErrorResponse_OBJECT_NOT_FOUND
+	case errors.Is(err, errStorageQuotaExceeded), errors.Is(err, snapshot.ErrLegalHold):
+		errorCode = grpcapi.ErrorResponse_CLIENT_ERROR
 	default:
 		errorCode = grpcapi.ErrorResponse_UNKNOWN_ERROR
 	}
@@ -565,15 +709,15 @@ func makeEntryMetadata(em *manifest.EntryMetadata) *grpcapi.ManifestEntryMetadat
 	}
 }
 
-func (s *Server) handleInitialSessionHandshake(srv grpcapi.KopiaRepository_SessionServer, dr repo.DirectRepository) (repo.WriteSessionOptions, error) {
+func (s *Server) handleInitialSessionHandshake(srv grpcapi.KopiaRepository_SessionServer, dr repo.DirectRepository) (repo.WriteSessionOptions, bool, error) {
 	initializeReq, err := srv.Recv()
 	if err != nil {
-		return repo.WriteSessionOptions{}, errors.Wrap(err, "unable to read initialization request")
+		return repo.WriteSessionOptions{}, false, errors.Wrap(err, "unable to read initialization request")
 	}
 
 	ir := initializeReq.GetInitializeSession()
 	if ir == nil {
-		return repo.WriteSessionOptions{}, errors.New("missing initialization request")
+		return repo.WriteSessionOptions{}, false, errors.New("missing initialization request")
 	}
 
 	scc := dr.ContentReader().SupportsContentCompression()
@@ -590,12 +734,12 @@ func (s *Server) handleInitialSessionHandshake(srv grpcapi.KopiaRepository_Sessi
 			},
 		},
 	}); err != nil {
-		return repo.WriteSessionOptions{}, errors.Wrap(err, "unable to send response")
+		return repo.WriteSessionOptions{}, false, errors.Wrap(err, "unable to send response")
 	}
 
 	return repo.WriteSessionOptions{
 		Purpose: ir.GetPurpose(),
-	}, nil
+	}, ir.GetReadOnly(), nil
 }
 
 // RegisterGRPCHandlers registers server gRPC handler.
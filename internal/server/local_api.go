@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+// LocalAPI invokes the server API handlers in-process against an open repository,
+// without listening on a network socket. Unlike a regular server, it does not run
+// the snapshot scheduler or maintenance.
+type LocalAPI struct {
+	srv    *Server
+	router *mux.Router
+}
+
+// NewLocalAPI creates LocalAPI for the provided repository.
+// Authentication and CSRF checks are disabled since requests never leave the process.
+func NewLocalAPI(ctx context.Context, rep repo.Repository, options *Options) (*LocalAPI, error) {
+	opts := *options
+	opts.Authenticator = nil
+	opts.DisableCSRFTokenChecks = true
+
+	srv, err := New(ctx, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.serverMutex.Lock()
+	defer srv.serverMutex.Unlock()
+
+	srv.rep = rep
+
+	if err := srv.syncSourcesLocked(ctx); err != nil {
+		srv.stopAllSourceManagersLocked(ctx)
+
+		return nil, err
+	}
+
+	m := mux.NewRouter()
+	srv.SetupHTMLUIAPIHandlers(m)
+	srv.SetupControlAPIHandlers(m)
+
+	return &LocalAPI{srv, m}, nil
+}
+
+// Invoke invokes the handler registered for the provided HTTP method and API path
+// (relative to /api/v1/, optionally with query parameters) and returns the JSON response.
+// When the handler fails, the JSON-encoded error response is returned together with a non-nil error.
+func (l *LocalAPI) Invoke(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "/api/v1/"+strings.TrimPrefix(path, "/"), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid request")
+	}
+
+	if !l.router.Match(req, &mux.RouteMatch{}) {
+		return nil, errors.Errorf("unknown API method: %v %v", method, path)
+	}
+
+	rec := httptest.NewRecorder()
+	l.router.ServeHTTP(rec, req)
+
+	resp := rec.Body.Bytes()
+	if rec.Code == http.StatusOK {
+		return resp, nil
+	}
+
+	var er serverapi.ErrorResponse
+
+	if json.Unmarshal(resp, &er) == nil && er.Error != "" {
+		return resp, errors.Errorf("%v: %v", er.Code, er.Error)
+	}
+
+	return resp, errors.Errorf("API request failed with HTTP status %v", rec.Code)
+}
+
+// Close stops all background activity started by LocalAPI. It does not close the repository.
+func (l *LocalAPI) Close(ctx context.Context) {
+	l.srv.serverMutex.Lock()
+	defer l.srv.serverMutex.Unlock()
+
+	l.srv.unmountAllLocked(ctx)
+	l.srv.stopAllSourceManagersLocked(ctx)
+	l.srv.rep = nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/kopia/kopia/repo"
+)
+
+// HostedRepositoryPathPrefix is the URL path prefix used to route HTTP API requests
+// to one of the repositories hosted by a multi-repository server (/repos/<name>/api/v1/...).
+const HostedRepositoryPathPrefix = "/repos/"
+
+var validHostedRepositoryName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidHostedRepositoryName returns true if the provided name can be used to identify a hosted repository.
+func ValidHostedRepositoryName(name string) bool {
+	return validHostedRepositoryName.MatchString(name)
+}
+
+// MultiRepositoryHandler returns HTTP handler which routes requests to handlers of repositories hosted
+// in a single server process.
+//
+// The repository is selected by the GRPC metadata header sent by repository clients or by the
+// HostedRepositoryPathPrefix in the URL. All other requests are routed to the default handler.
+func MultiRepositoryHandler(defaultHandler http.Handler, hosted map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.Header.Get(repo.GRPCRepositoryNameMetadataKey); name != "" {
+			h := hosted[name]
+			if h == nil {
+				http.Error(w, "repository not found", http.StatusNotFound)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+
+			return
+		}
+
+		if rest, ok := strings.CutPrefix(r.URL.Path, HostedRepositoryPathPrefix); ok {
+			name, _, _ := strings.Cut(rest, "/")
+
+			h := hosted[name]
+			if h == nil {
+				http.Error(w, "repository not found", http.StatusNotFound)
+				return
+			}
+
+			http.StripPrefix(HostedRepositoryPathPrefix+name, h).ServeHTTP(w, r)
+
+			return
+		}
+
+		defaultHandler.ServeHTTP(w, r)
+	})
+}
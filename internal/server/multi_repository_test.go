@@ -0,0 +1,59 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/server"
+	"github.com/kopia/kopia/repo"
+)
+
+func TestMultiRepositoryHandler(t *testing.T) {
+	handlerFor := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + ":" + r.URL.Path)) //nolint:errcheck
+		})
+	}
+
+	h := server.MultiRepositoryHandler(handlerFor("default"), map[string]http.Handler{
+		"r1": handlerFor("r1"),
+		"r2": handlerFor("r2"),
+	})
+
+	cases := []struct {
+		path       string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/api/v1/repo/status", "", http.StatusOK, "default:/api/v1/repo/status"},
+		{"/repos/r1/api/v1/repo/status", "", http.StatusOK, "r1:/api/v1/repo/status"},
+		{"/repos/r2/api/v1/sources", "", http.StatusOK, "r2:/api/v1/sources"},
+		{"/repos/r3/api/v1/sources", "", http.StatusNotFound, ""},
+		{"/kopia_repository.KopiaRepository/Session", "r2", http.StatusOK, "r2:/kopia_repository.KopiaRepository/Session"},
+		{"/kopia_repository.KopiaRepository/Session", "r3", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, http.NoBody)
+		if tc.header != "" {
+			req.Header.Set(repo.GRPCRepositoryNameMetadataKey, tc.header)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, tc.wantStatus, rec.Code, tc.path)
+
+		if tc.wantBody != "" {
+			require.Equal(t, tc.wantBody, rec.Body.String(), tc.path)
+		}
+	}
+
+	require.True(t, server.ValidHostedRepositoryName("my-repo.1"))
+	require.False(t, server.ValidHostedRepositoryName("my/repo"))
+	require.False(t, server.ValidHostedRepositoryName(""))
+}
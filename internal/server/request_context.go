@@ -3,14 +3,18 @@
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/leaderelection"
 	"github.com/kopia/kopia/internal/mount"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/internal/webhook"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
 )
@@ -20,11 +24,26 @@ type serverInterface interface {
 	deleteSourceManager(ctx context.Context, src snapshot.SourceInfo) bool
 	generateShortTermAuthCookie(username string, now time.Time) (string, error)
 	isAuthCookieValid(username, cookieValue string) bool
+	oidcSessionUsername(r *http.Request) string
 	getAuthorizer() auth.Authorizer
 	getAuthenticator() auth.Authenticator
 	getOptions() *Options
 	snapshotAllSourceManagers() map[snapshot.SourceInfo]*sourceManager
+	knownClients() map[string]clientSessionInfo
+	sendWebhook(ctx context.Context, wh *webhook.Webhook, ev *webhook.Event) error
+	checkWebhookConditionsSoon()
+	userQuotas() *userQuotaTracker
+	snapshotListCache() *snapshotListCache
 	taskManager() *uitask.Manager
+	maintenanceManager() *srvMaintenance
+	verifier() *srvVerifier
+	replicator() *srvReplicator
+	runningMaintenanceTask() (uitask.Info, bool)
+	runMaintenanceAsync(ctx context.Context, mode maintenance.Mode, force bool) (uitask.Info, error)
+	eventBroker() *eventBroker
+	enrollmentLock() *sync.Mutex
+	leaderElector() *leaderelection.Elector
+	recordAudit(ctx context.Context, rep repo.Repository, actor, action, target string, details map[string]string)
 	Refresh()
 	getMountController(ctx context.Context, rep repo.Repository, oid object.ID, createIfNotFound bool) (mount.Controller, error)
 	deleteMount(oid object.ID)
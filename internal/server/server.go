@@ -19,20 +19,25 @@
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/leaderelection"
 	"github.com/kopia/kopia/internal/mount"
 	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/internal/scheduler"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/internal/webhook"
 	"github.com/kopia/kopia/notification"
 	"github.com/kopia/kopia/notification/notifydata"
 	"github.com/kopia/kopia/notification/notifytemplate"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
@@ -95,13 +100,26 @@ type Server struct {
 	// +checklocks:serverMutex
 	maint *srvMaintenance
 	// +checklocks:serverMutex
+	verify *srvVerifier
+	// +checklocks:serverMutex
+	replicate *srvReplicator
+	// +checklocks:serverMutex
 	sourceManagers map[snapshot.SourceInfo]*sourceManager
 	// +checklocks:serverMutex
 	mounts map[object.ID]mount.Controller
 
-	taskmgr              *uitask.Manager
+	taskmgr *uitask.Manager
+	events  *eventBroker
+
+	authCookieSigningKeyMutex sync.Mutex
+	// +checklocks:authCookieSigningKeyMutex
 	authCookieSigningKey []byte
 
+	// elects the instance running scheduled snapshots and maintenance, nil if high-availability mode is disabled.
+	elector *leaderelection.Elector
+	// +checklocks:serverMutex
+	stopLeaderElection func(ctx context.Context)
+
 	// channel to which we can post to trigger scheduler re-evaluation.
 	schedulerRefresh chan string
 
@@ -113,6 +131,16 @@ type Server struct {
 	// +checklocks:nextRefreshTimeLock
 	nextRefreshTime time.Time
 
+	// serializes writes to the audit log.
+	auditMutex sync.Mutex
+
+	// serializes redemption of one-time enrollment tokens.
+	enrollmentMutex sync.Mutex
+
+	webhooks webhookState
+
+	snapshotList snapshotListCache
+
 	grpcServerState
 }
 
@@ -123,11 +151,15 @@ func (s *Server) SetupHTMLUIAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/sources", s.handleUI(handleSourcesCreate)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/sources/upload", s.handleUI(handleUpload)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/sources/cancel", s.handleUI(handleCancel)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/sources/snapshot", s.handleUI(handleSourceSnapshot)).Methods(http.MethodPost)
 
 	// snapshots
 	m.HandleFunc("/api/v1/snapshots", s.handleUI(handleListSnapshots)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/snapshots/delete", s.handleUI(handleDeleteSnapshots)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/snapshots/edit", s.handleUI(handleEditSnapshots)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/snapshots/{snapshotID}/stream", s.requireAuth(csrfTokenNotRequired, handleSnapshotStream)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/snapshots/{snapshotID}/browse", s.handleUI(handleSnapshotBrowse)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/snapshots/{snapshotID}/preview", s.requireAuth(csrfTokenNotRequired, handleSnapshotPreview)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/policy", s.handleUI(handlePolicyGet)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/policy", s.handleUI(handlePolicyPut)).Methods(http.MethodPut)
 	m.HandleFunc("/api/v1/policy", s.handleUI(handlePolicyDelete)).Methods(http.MethodDelete)
@@ -136,7 +168,27 @@ func (s *Server) SetupHTMLUIAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/refresh", s.handleUI(handleRefresh)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/objects/{objectID}", s.requireAuth(csrfTokenNotRequired, handleObjectGet)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/restore", s.handleUI(handleRestore)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/restore-requests", s.handleUI(handleRestoreRequestList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/restore-requests", s.handleUI(handleRestoreRequestCreate)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/restore-requests/{id}", s.handleUI(handleRestoreRequestGet)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/restore-requests/{id}", s.handleUI(handleRestoreRequestDelete)).Methods(http.MethodDelete)
+	m.HandleFunc("/api/v1/restore-requests/{id}/cancel", s.handleUI(handleRestoreRequestCancel)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/estimate", s.handleUI(handleEstimate)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/maintenance", s.handleUI(handleMaintenanceInfo)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/maintenance", s.handleUI(handleMaintenanceSet)).Methods(http.MethodPut)
+	m.HandleFunc("/api/v1/maintenance/owner", s.handleUI(handleMaintenanceSetOwner)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/maintenance/run", s.handleUI(handleMaintenanceRun)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/maintenance/cancel", s.handleUI(handleMaintenanceCancel)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/verification", s.handleUI(handleVerificationStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/replication", s.handleUI(handleReplicationStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/index/epoch", s.handleUI(handleIndexEpochStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/index/epoch/advance", s.handleUI(handleIndexEpochAdvance)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/repo/cache", s.handleUI(handleRepoCacheStats)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/stats", s.handleUI(handleRepoStats)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/usage", s.handleUI(handleRepoUsage)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/diagnostics", s.handleUI(handleRepoDiagnostics)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/dedup-report", s.handleUI(handleRepoDedupReport)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/history", s.handleUI(handleRepoHistory)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/paths/resolve", s.handleUI(handlePathResolve)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/cli", s.handleUI(handleCLIInfo)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/repo/status", s.handleUIPossiblyNotConnected(handleRepoStatus)).Methods(http.MethodGet)
@@ -159,6 +211,11 @@ func (s *Server) SetupHTMLUIAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/ui-preferences", s.handleUIPossiblyNotConnected(handleGetUIPreferences)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/ui-preferences", s.handleUIPossiblyNotConnected(handleSetUIPreferences)).Methods(http.MethodPut)
 
+	m.HandleFunc("/api/v1/events", s.requireAuth(csrfTokenNotRequired, handleEvents(requireUIUser))).Methods(http.MethodGet)
+
+	m.HandleFunc(OIDCLoginPath, s.handleOIDCLogin).Methods(http.MethodGet)
+	m.HandleFunc(OIDCCallbackPath, s.handleOIDCCallback).Methods(http.MethodGet)
+
 	m.HandleFunc("/api/v1/tasks-summary", s.handleUIPossiblyNotConnected(handleTaskSummary)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/tasks", s.handleUIPossiblyNotConnected(handleTaskList)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/tasks/{taskID}", s.handleUIPossiblyNotConnected(handleTaskInfo)).Methods(http.MethodGet)
@@ -171,6 +228,11 @@ func (s *Server) SetupHTMLUIAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/notificationProfiles", s.handleUI(handleNotificationProfileList)).Methods(http.MethodGet)
 
 	m.HandleFunc("/api/v1/testNotificationProfile", s.handleUI(handleNotificationProfileTest)).Methods(http.MethodPost)
+
+	m.HandleFunc("/api/v1/enrollment-tokens", s.handleUI(handleEnrollmentTokenCreate)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/enrollments", s.handleUI(handleEnrollmentList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/enrollments/{id}/approve", s.handleUI(handleEnrollmentApprove)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/enrollments/{id}/reject", s.handleUI(handleEnrollmentReject)).Methods(http.MethodPost)
 }
 
 // SetupControlAPIHandlers registers control API handlers.
@@ -183,10 +245,53 @@ func (s *Server) SetupControlAPIHandlers(m *mux.Router) {
 	m.HandleFunc("/api/v1/control/shutdown", s.handleServerControlAPIPossiblyNotConnected(handleShutdown)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/control/trigger-snapshot", s.handleServerControlAPI(handleUpload)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/control/cancel-snapshot", s.handleServerControlAPI(handleCancel)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/snapshot-source", s.handleServerControlAPI(handleSourceSnapshot)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/control/pause-source", s.handleServerControlAPI(handlePause)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/control/resume-source", s.handleServerControlAPI(handleResume)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/control/throttle", s.handleServerControlAPI(handleRepoGetThrottle)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/control/throttle", s.handleServerControlAPI(handleRepoSetThrottle)).Methods(http.MethodPut)
+	m.HandleFunc("/api/v1/control/events", s.requireAuth(csrfTokenNotRequired, handleEvents(requireServerControlUser))).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/fleet", s.handleServerControlAPI(handleFleetList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/fleet/health", s.handleServerControlAPI(handleFleetHealth)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/quotas", s.handleServerControlAPI(handleQuotaUsageList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/quotas/reset", s.handleServerControlAPI(handleQuotaUsageReset)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/maintenance", s.handleServerControlAPI(handleMaintenanceInfo)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/maintenance", s.handleServerControlAPI(handleMaintenanceSet)).Methods(http.MethodPut)
+	m.HandleFunc("/api/v1/control/maintenance/owner", s.handleServerControlAPI(handleMaintenanceSetOwner)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/maintenance/run", s.handleServerControlAPI(handleMaintenanceRun)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/maintenance/cancel", s.handleServerControlAPI(handleMaintenanceCancel)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/verification", s.handleServerControlAPI(handleVerificationStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/replication", s.handleServerControlAPI(handleReplicationStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/restore-requests", s.handleServerControlAPI(handleRestoreRequestList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/restore-requests", s.handleServerControlAPI(handleRestoreRequestCreate)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/restore-requests/{id}", s.handleServerControlAPI(handleRestoreRequestGet)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/restore-requests/{id}", s.handleServerControlAPI(handleRestoreRequestDelete)).Methods(http.MethodDelete)
+	m.HandleFunc("/api/v1/control/restore-requests/{id}/cancel", s.handleServerControlAPI(handleRestoreRequestCancel)).Methods(http.MethodPost)
+
+	m.HandleFunc("/api/v1/control/acl", s.handleServerControlAPI(handleACLList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/acl", s.handleServerControlAPI(handleACLAdd)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/acl/{id}", s.handleServerControlAPI(handleACLDelete)).Methods(http.MethodDelete)
+	m.HandleFunc("/api/v1/control/leader", s.handleServerControlAPI(handleLeaderStatus)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/audit", s.handleServerControlAPI(handleAuditLogList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/audit/verify", s.handleServerControlAPI(handleAuditLogVerify)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/webhooks", s.handleServerControlAPI(handleWebhookList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/webhooks/{name}", s.handleServerControlAPI(handleWebhookSet)).Methods(http.MethodPut)
+	m.HandleFunc("/api/v1/control/webhooks/{name}", s.handleServerControlAPI(handleWebhookDelete)).Methods(http.MethodDelete)
+	m.HandleFunc("/api/v1/control/webhooks/{name}/test", s.handleServerControlAPI(handleWebhookTest)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/log-levels", s.handleServerControlAPIPossiblyNotConnected(handleGetLogLevels)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/log-levels", s.handleServerControlAPIPossiblyNotConnected(handleSetLogLevels)).Methods(http.MethodPut)
+	m.HandleFunc("/api/v1/control/enrollment-tokens", s.handleServerControlAPI(handleEnrollmentTokenCreate)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/enrollments", s.handleServerControlAPI(handleEnrollmentList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/control/enrollments/{id}/approve", s.handleServerControlAPI(handleEnrollmentApprove)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/control/enrollments/{id}/reject", s.handleServerControlAPI(handleEnrollmentReject)).Methods(http.MethodPost)
+}
+
+// SetupEnrollmentAPIHandlers registers handlers which allow clients holding a one-time enrollment token
+// to request access to the repository without credentials. Requests remain pending until approved
+// using the control API or the UI.
+func (s *Server) SetupEnrollmentAPIHandlers(m *mux.Router) {
+	m.HandleFunc("/api/v1/enroll", s.handleUnauthenticated(handleEnroll)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/enroll/{id}", s.handleUnauthenticated(handleEnrollmentStatus)).Methods(http.MethodGet)
 }
 
 func (s *Server) rootContext() context.Context {
@@ -201,6 +306,11 @@ func (s *Server) isAuthenticated(rc requestContext) bool {
 
 	username, password, ok := rc.req.BasicAuth()
 	if !ok {
+		if rc.srv.oidcSessionUsername(rc.req) != "" {
+			// web UI session started using OpenID Connect login.
+			return true
+		}
+
 		rc.w.Header().Set("WWW-Authenticate", `Basic realm="Kopia"`)
 		http.Error(rc.w, "Missing credentials.\n", http.StatusUnauthorized)
 
@@ -249,7 +359,7 @@ func (s *Server) isAuthenticated(rc requestContext) bool {
 
 func (s *Server) isAuthCookieValid(username, cookieValue string) bool {
 	tok, err := jwt.ParseWithClaims(cookieValue, &jwt.RegisteredClaims{}, func(_ *jwt.Token) (interface{}, error) {
-		return s.authCookieSigningKey, nil
+		return s.getAuthCookieSigningKey(), nil
 	})
 	if err != nil {
 		return false
@@ -273,7 +383,7 @@ func (s *Server) generateShortTermAuthCookie(username string, now time.Time) (st
 		Audience:  jwt.ClaimStrings{kopiaAuthCookieAudience},
 		ID:        uuid.New().String(),
 		Issuer:    kopiaAuthCookieIssuer,
-	}).SignedString(s.authCookieSigningKey)
+	}).SignedString(s.getAuthCookieSigningKey())
 }
 
 func (s *Server) captureRequestContext(w http.ResponseWriter, r *http.Request) requestContext {
@@ -304,6 +414,14 @@ func (s *Server) taskManager() *uitask.Manager {
 	return s.taskmgr
 }
 
+func (s *Server) eventBroker() *eventBroker {
+	return s.events
+}
+
+func (s *Server) enrollmentLock() *sync.Mutex {
+	return &s.enrollmentMutex
+}
+
 func (s *Server) requireAuth(checkCSRFToken csrfTokenOption, f func(ctx context.Context, rc requestContext)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		rc := s.captureRequestContext(w, r)
@@ -315,7 +433,7 @@ func (s *Server) requireAuth(checkCSRFToken csrfTokenOption, f func(ctx context.
 
 		if checkCSRFToken == csrfTokenRequired {
 			if !s.validateCSRFToken(r) {
-				http.Error(w, "Invalid or missing CSRF token.\n", http.StatusUnauthorized)
+				http.Error(w, apiclient.InvalidCSRFTokenMessage+"\n", http.StatusUnauthorized)
 				return
 			}
 		}
@@ -357,7 +475,26 @@ func (s *Server) handleUIPossiblyNotConnected(f apiRequestFunc) http.HandlerFunc
 }
 
 func (s *Server) handleRequestPossiblyNotConnected(isAuthorized isAuthorizedFunc, checkCSRFToken csrfTokenOption, f apiRequestFunc) http.HandlerFunc {
-	return s.requireAuth(checkCSRFToken, func(ctx context.Context, rc requestContext) {
+	return s.requireAuth(checkCSRFToken, s.serveAPIRequest(isAuthorized, f))
+}
+
+// handleUnauthenticated handles API requests which don't require credentials, such as enrollment of new clients.
+func (s *Server) handleUnauthenticated(f apiRequestFunc) http.HandlerFunc {
+	serve := s.serveAPIRequest(func(context.Context, requestContext) bool { return true }, func(ctx context.Context, rc requestContext) (interface{}, *apiError) {
+		if rc.rep == nil {
+			return nil, requestError(serverapi.ErrorNotConnected, "not connected")
+		}
+
+		return f(ctx, rc)
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		serve(r.Context(), s.captureRequestContext(w, r))
+	}
+}
+
+func (s *Server) serveAPIRequest(isAuthorized isAuthorizedFunc, f apiRequestFunc) func(ctx context.Context, rc requestContext) {
+	return func(ctx context.Context, rc requestContext) {
 		// we must pre-read request body before acquiring the lock as it sometimes leads to deadlock
 		// in HTTP/2 server.
 		// See https://github.com/golang/go/issues/40816
@@ -418,7 +555,7 @@ func (s *Server) handleRequestPossiblyNotConnected(isAuthorized isAuthorizedFunc
 			Code:  err.apiErrorCode,
 			Error: err.message,
 		})
-	})
+	}
 }
 
 func (s *Server) refreshAsync() {
@@ -596,6 +733,8 @@ func (s *Server) SetRepository(ctx context.Context, rep repo.Repository) error {
 	}
 
 	if s.rep != nil {
+		s.stopLeaderElectionLocked(ctx)
+
 		// stop previous scheduler asynchronously to avoid deadlock when
 		// scheduler is inside s.getSchedulerItems which needs a lock, which we're holding right now.
 		go s.sched.Stop()
@@ -609,6 +748,17 @@ func (s *Server) SetRepository(ctx context.Context, rep repo.Repository) error {
 		s.stopAllSourceManagersLocked(ctx)
 		log(ctx).Debug("stopped all source managers")
 
+		// stop background verification and replication before closing the repository they read from
+		if s.verify != nil {
+			s.verify.stop(ctx)
+			s.verify = nil
+		}
+
+		if s.replicate != nil {
+			s.replicate.stop(ctx)
+			s.replicate = nil
+		}
+
 		if err := s.rep.Close(ctx); err != nil {
 			return errors.Wrap(err, "unable to close previous repository")
 		}
@@ -634,6 +784,30 @@ func (s *Server) SetRepository(ctx context.Context, rep repo.Repository) error {
 
 	if dr, ok := s.rep.(repo.DirectRepository); ok {
 		s.maint = startMaintenanceManager(ctx, dr, s, s.options.MinMaintenanceInterval)
+
+		if s.options.HighAvailability && s.options.AuthCookieSigningKey == "" {
+			s.deriveAuthCookieSigningKey(dr)
+		}
+
+		if s.options.VerifyBytesPerHour > 0 {
+			v, err := startVerifier(context.WithoutCancel(ctx), dr, s.taskmgr, &s.options)
+			if err != nil {
+				log(ctx).Errorf("unable to start background verification: %v", err)
+			}
+
+			s.verify = v
+		}
+
+		if s.options.ReplicateTo != nil {
+			r, err := startReplicator(context.WithoutCancel(ctx), dr, s.taskmgr, &s.options)
+			if err != nil {
+				log(ctx).Errorf("unable to start replication: %v", err)
+			}
+
+			s.replicate = r
+		}
+
+		s.startLeaderElectionLocked(ctx, dr)
 	} else {
 		s.maint = nil
 	}
@@ -794,6 +968,11 @@ func (s *Server) ServeStaticFiles(m *mux.Router, fs http.FileSystem) {
 			r = r2
 		}
 
+		if s.options.OIDCProvider != nil && r.Header.Get("Authorization") == "" && s.oidcSessionUsername(r) == "" {
+			http.Redirect(w, r, OIDCLoginPath, http.StatusFound)
+			return
+		}
+
 		rc := s.captureRequestContext(w, r)
 
 		//nolint:contextcheck
@@ -840,6 +1019,7 @@ type Options struct {
 	MaxConcurrency           int
 	Authenticator            auth.Authenticator
 	Authorizer               auth.Authorizer
+	OIDCProvider             *auth.OIDCProvider // provider used for web UI login using OpenID Connect, nil if disabled
 	PasswordPersist          passwordpersist.Strategy
 	AuthCookieSigningKey     string
 	LogRequests              bool
@@ -848,11 +1028,27 @@ type Options struct {
 	ServerControlUser        string // name of the user allowed to access the server control API
 	DisableCSRFTokenChecks   bool
 	PersistentLogs           bool
+	TaskHistoryDir           string        // directory storing history of finished tasks, empty if not persisted
+	TaskHistoryMaxCount      int           // maximum number of finished tasks in history, 0 == unlimited
+	TaskHistoryMaxAge        time.Duration // maximum age of finished tasks in history, 0 == unlimited
 	UITitlePrefix            string
 	DebugScheduler           bool
 	MinMaintenanceInterval   time.Duration
 	EnableErrorNotifications bool
 	NotifyTemplateOptions    notifytemplate.Options
+	ModuleLogLevels          *logging.ModuleLevels // runtime-adjustable per-subsystem log levels, nil if not supported
+	DefaultUserQuota         user.Quota            // quotas of repository users, can be overridden in user profiles
+	QuotaUsageFile           string                // name of the JSON file storing per-user quota usage, empty if not persisted
+	EnableAuditLog           bool                  // record connections, restores, deletions and policy/ACL changes in the repository audit log
+	HighAvailability         bool                  // elect a single leader among servers connected to the same repository to run scheduled snapshots and maintenance
+	InstanceID               string                // unique ID of the server instance in high-availability mode
+	AdvertiseAddress         string                // address of the server instance advertised to other instances
+	LeaseDuration            time.Duration         // duration of the leader lease in high-availability mode
+	VerifyBytesPerHour       int64                 // rate of continuous background verification of repository contents, 0 == disabled
+	VerifyStateFile          string                // name of the JSON file storing the background verification state, empty if not persisted
+	ReplicateTo              *blob.ConnectionInfo  // storage to continuously replicate repository blobs to, nil == disabled
+	ReplicationStateFile     string                // name of the JSON file storing the replication state, empty if not persisted
+	ReplicationInterval      time.Duration         // interval between replication cycles
 }
 
 // InitRepositoryFunc is a function that attempts to connect to/open repository.
@@ -964,40 +1160,146 @@ func RetryInitRepository(initialize InitRepositoryFunc) InitRepositoryFunc {
 	}
 }
 
-func (s *Server) runSnapshotTask(ctx context.Context, src snapshot.SourceInfo, inner func(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error) error {
-	if !s.beginUpload(ctx, src) {
-		return nil
-	}
-
+// runSnapshotTask runs the snapshot task, the optional onStart callback is invoked with the ID of the task
+// as soon as it is created, before waiting for parallel upload slot to become available.
+func (s *Server) runSnapshotTask(ctx context.Context, src snapshot.SourceInfo, onStart func(taskID string), inner func(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error) error {
 	var result notifydata.ManifestWithError
 	result.Manifest.Source = src
 
-	defer s.endUpload(ctx, src, &result)
-
-	err := errors.Wrap(s.taskmgr.Run(
+	return errors.Wrap(s.taskmgr.Run(
 		ctx,
 		"Snapshot",
 		fmt.Sprintf("%v at %v", src, clock.Now().Format(time.RFC3339)),
 		func(ctx context.Context, ctrl uitask.Controller) error {
-			return inner(ctx, ctrl, &result)
-		}), "snapshot task")
-	if err != nil {
-		result.Error = err.Error()
-	}
+			if onStart != nil {
+				onStart(ctrl.CurrentTaskID())
+			}
+
+			if !s.beginUpload(ctx, src) {
+				return nil
+			}
+
+			defer s.endUpload(ctx, src, &result)
+
+			s.events.publish(serverapi.Event{
+				Type:   serverapi.EventSnapshotStarted,
+				Source: &src,
+			})
+
+			err := inner(ctx, ctrl, &result)
+			if err != nil {
+				result.Error = errors.Wrap(err, "snapshot task").Error()
+			}
+
+			s.events.publish(serverapi.Event{
+				Type:       serverapi.EventSnapshotFinished,
+				Source:     &src,
+				SnapshotID: result.Manifest.ID,
+				Error:      result.Error,
+			})
 
-	return err
+			s.notifyWebhooks(&webhook.Event{
+				Type:       webhook.EventSnapshotFinished,
+				Source:     &src,
+				SnapshotID: result.Manifest.ID,
+				Error:      result.Error,
+			})
+
+			return err
+		}), "snapshot task")
 }
 
 func (s *Server) runMaintenanceTask(ctx context.Context, dr repo.DirectRepository) error {
-	return errors.Wrap(s.taskmgr.Run(ctx, "Maintenance", "Periodic maintenance", func(ctx context.Context, _ uitask.Controller) error {
+	return s.runMaintenance(ctx, dr, "Periodic maintenance", maintenance.ModeAuto, false, nil)
+}
+
+// runMaintenance runs maintenance in the provided mode as a task which can be observed and canceled
+// in the Tasks UI. The ID of the task is sent to taskIDChan, if provided.
+func (s *Server) runMaintenance(ctx context.Context, dr repo.DirectRepository, description string, mode maintenance.Mode, force bool, taskIDChan chan<- string) error {
+	return errors.Wrap(s.taskmgr.Run(ctx, maintenanceTaskKind, description, func(ctx context.Context, ctrl uitask.Controller) error {
+		if taskIDChan != nil {
+			taskIDChan <- ctrl.CurrentTaskID()
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ctrl.OnCancel(cancel)
+
+		ctx = s.events.withMaintenancePhaseEvents(ctx, ctrl)
+
 		return repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
 			Purpose: "periodicMaintenance",
 		}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
-			return snapshotmaintenance.Run(ctx, w, maintenance.ModeAuto, false, maintenance.SafetyFull)
+			return snapshotmaintenance.Run(ctx, w, mode, force, maintenance.SafetyFull)
 		})
 	}), "unable to run maintenance")
 }
 
+// runMaintenanceAsync starts maintenance in the provided mode and returns the task running it.
+func (s *Server) runMaintenanceAsync(ctx context.Context, mode maintenance.Mode, force bool) (uitask.Info, error) {
+	m := s.maintenanceManager()
+	if m == nil {
+		return uitask.Info{}, errMaintenanceNotSupported
+	}
+
+	if _, ok := s.runningMaintenanceTask(); ok {
+		return uitask.Info{}, errMaintenanceAlreadyRunning
+	}
+
+	taskIDChan := make(chan string)
+
+	go func() {
+		m.beforeRun()
+
+		if err := s.runMaintenance(ctx, m.dr, fmt.Sprintf("Requested %v maintenance", mode), mode, force, taskIDChan); err != nil {
+			log(ctx).Errorf("requested maintenance failed: %v", err)
+		}
+
+		m.refresh(ctx, true)
+	}()
+
+	ti, ok := s.taskmgr.GetTask(<-taskIDChan)
+	if !ok {
+		return uitask.Info{}, errors.New("task not found")
+	}
+
+	return ti, nil
+}
+
+// runningMaintenanceTask returns the maintenance task currently running, if any.
+func (s *Server) runningMaintenanceTask() (uitask.Info, bool) {
+	for _, t := range s.taskmgr.ListTasks() {
+		if t.Kind == maintenanceTaskKind && !t.Status.IsFinished() {
+			return t, true
+		}
+	}
+
+	return uitask.Info{}, false
+}
+
+// maintenanceManager returns the maintenance manager or nil if the server isn't directly connected to the repository.
+func (s *Server) maintenanceManager() *srvMaintenance {
+	s.serverMutex.RLock()
+	defer s.serverMutex.RUnlock()
+
+	return s.maint
+}
+
+func (s *Server) verifier() *srvVerifier {
+	s.serverMutex.RLock()
+	defer s.serverMutex.RUnlock()
+
+	return s.verify
+}
+
+func (s *Server) replicator() *srvReplicator {
+	s.serverMutex.RLock()
+	defer s.serverMutex.RUnlock()
+
+	return s.replicate
+}
+
 // +checklocksread:s.serverMutex
 func (s *Server) isLocal(src snapshot.SourceInfo) bool {
 	return s.rep.ClientOptions().Hostname == src.Host && !s.rep.ClientOptions().ReadOnly
@@ -1064,6 +1366,18 @@ func (s *Server) getSchedulerItems(ctx context.Context, now time.Time) []schedul
 		NextTime:    nrt,
 	})
 
+	if !s.isLeader() {
+		// in high-availability mode only the leader runs scheduled snapshots and maintenance.
+		return result
+	}
+
+	// add an item to check conditions reported to webhooks.
+	result = append(result, scheduler.Item{
+		Description: "webhook checks",
+		Trigger:     s.checkWebhookConditionsAsync,
+		NextTime:    s.webhooks.getNextCheckTime(),
+	})
+
 	if s.maint != nil {
 		// If we have a direct repository, add an item to run maintenance.
 		// If we're the owner then nextMaintenanceTime will be zero.
@@ -1085,7 +1399,7 @@ func (s *Server) getSchedulerItems(ctx context.Context, now time.Time) []schedul
 		if nst, ok := sm.getNextSnapshotTime(); ok {
 			result = append(result, scheduler.Item{
 				Description: fmt.Sprintf("snapshot %q", sm.src.Path),
-				Trigger:     sm.scheduleSnapshotNow,
+				Trigger:     func() { sm.triggerScheduledSnapshot(nst) },
 				NextTime:    nst,
 			})
 		} else {
@@ -1114,9 +1428,10 @@ func New(ctx context.Context, options *Options) (*Server, error) {
 		return nil, errors.New("missing password persistence")
 	}
 
-	if options.AuthCookieSigningKey == "" {
-		// generate random signing key
-		options.AuthCookieSigningKey = uuid.New().String()
+	authCookieSigningKey := options.AuthCookieSigningKey
+	if authCookieSigningKey == "" {
+		// generate random signing key, in high-availability mode it will be replaced with a key derived from the repository.
+		authCookieSigningKey = uuid.New().String()
 	}
 
 	s := &Server{
@@ -1128,13 +1443,48 @@ func New(ctx context.Context, options *Options) (*Server, error) {
 		authenticator:        options.Authenticator,
 		authorizer:           options.Authorizer,
 		taskmgr:              uitask.NewManager(options.PersistentLogs),
+		events:               newEventBroker(),
 		mounts:               map[object.ID]mount.Controller{},
-		authCookieSigningKey: []byte(options.AuthCookieSigningKey),
+		authCookieSigningKey: []byte(authCookieSigningKey),
 		nextRefreshTime:      clock.Now().Add(options.RefreshInterval),
 		schedulerRefresh:     make(chan string, 1),
 	}
 
+	// give clients a chance to reconnect before checking for missing ones.
+	s.webhooks.setNextCheckTime(clock.Now().Add(webhookCheckInterval))
+
+	if err := s.grpcServerState.quotas.init(options.DefaultUserQuota, options.QuotaUsageFile); err != nil {
+		return nil, err
+	}
+
+	if options.HighAvailability {
+		if s.options.InstanceID == "" {
+			s.options.InstanceID = repo.GetDefaultHostName(ctx) + "-" + uuid.New().String()[0:8]
+		}
+
+		if s.options.LeaseDuration <= 0 {
+			s.options.LeaseDuration = defaultLeaseDuration
+		}
+
+		s.elector = leaderelection.NewElector(leaderelection.Options{
+			Holder:        s.options.InstanceID,
+			Address:       s.options.AdvertiseAddress,
+			LeaseDuration: s.options.LeaseDuration,
+		})
+	}
+
 	s.parallelSnapshotsChanged = sync.NewCond(&s.parallelSnapshotsMutex)
+	s.taskmgr.AddListener(s.events.onTaskChange)
+
+	if options.TaskHistoryDir != "" {
+		if err := s.taskmgr.EnableHistory(uitask.HistoryOptions{
+			Directory: options.TaskHistoryDir,
+			MaxTasks:  options.TaskHistoryMaxCount,
+			MaxAge:    options.TaskHistoryMaxAge,
+		}); err != nil {
+			return nil, errors.Wrap(err, "unable to initialize task history")
+		}
+	}
 
 	return s, nil
 }
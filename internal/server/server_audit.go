@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/repo"
+)
+
+// recordAudit appends an entry to the audit log stored in the repository if audit logging is enabled.
+// Failures are logged but do not fail the audited operation.
+func (s *Server) recordAudit(ctx context.Context, rep repo.Repository, actor, action, target string, details map[string]string) {
+	if !s.options.EnableAuditLog {
+		return
+	}
+
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return
+	}
+
+	s.auditMutex.Lock()
+	defer s.auditMutex.Unlock()
+
+	if err := repo.WriteSession(ctx, dr, repo.WriteSessionOptions{
+		Purpose: "AuditLog",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		return auditlog.Record(ctx, w, &auditlog.Entry{
+			Actor:   actor,
+			Action:  action,
+			Target:  target,
+			Details: details,
+		})
+	}); err != nil {
+		log(ctx).Errorf("unable to record audit entry %v by %v: %v", action, actor, err)
+	}
+}
+
+// auditRequest records an audit entry for an operation performed by the user making the API request.
+func auditRequest(ctx context.Context, rc requestContext, action, target string, details map[string]string) {
+	rc.srv.recordAudit(ctx, rc.rep, authenticatedUsername(rc), action, target, details)
+}
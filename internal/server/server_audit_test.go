@@ -0,0 +1,71 @@
+package server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestRecordAudit(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	// nothing is recorded when audit logging is disabled.
+	disabled := &Server{}
+	disabled.recordAudit(ctx, env.Repository, "alice@host", auditlog.ActionSessionStart, "", nil)
+
+	entries, err := auditlog.List(ctx, env.Repository, auditlog.Filter{})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	s := &Server{options: Options{EnableAuditLog: true}}
+
+	var wg sync.WaitGroup
+
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.recordAudit(ctx, env.Repository, "alice@host", auditlog.ActionSessionStart, "127.0.0.1:1234", nil)
+		}()
+	}
+
+	wg.Wait()
+
+	require.NoError(t, env.Repository.Refresh(ctx))
+
+	vr, err := auditlog.Verify(ctx, env.Repository)
+	require.NoError(t, err)
+	require.Equal(t, 10, vr.EntryCount)
+	require.Empty(t, vr.Problems)
+}
+
+func TestManifestDeleteAudit(t *testing.T) {
+	snap := map[string]string{
+		manifest.TypeLabelKey:  snapshot.ManifestType,
+		snapshot.UsernameLabel: "alice",
+		snapshot.HostnameLabel: "host",
+		snapshot.PathLabel:     "/home",
+	}
+
+	require.Equal(t, auditlog.ActionSnapshotDelete, manifestDeleteAuditAction(snap))
+	require.Equal(t, "alice@host:/home", manifestAuditSource(snap))
+
+	pol := map[string]string{manifest.TypeLabelKey: policy.ManifestType}
+
+	require.Equal(t, auditlog.ActionPolicyDelete, manifestDeleteAuditAction(pol))
+	require.Equal(t, "(global)", manifestAuditSource(pol))
+
+	other := map[string]string{manifest.TypeLabelKey: "acl"}
+
+	require.Equal(t, auditlog.ActionManifestDelete, manifestDeleteAuditAction(other))
+	require.Empty(t, manifestAuditSource(other))
+}
@@ -9,7 +9,7 @@
 	"io"
 	"net/http"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
 )
 
 // kopiaSessionCookie is the name of the session cookie that Kopia server will generate for all
@@ -17,7 +17,7 @@
 const kopiaSessionCookie = "Kopia-Session-Cookie"
 
 func (s *Server) generateCSRFToken(sessionID string) string {
-	h := hmac.New(sha256.New, s.authCookieSigningKey)
+	h := hmac.New(sha256.New, s.getAuthCookieSigningKey())
 
 	if _, err := io.WriteString(h, sessionID); err != nil {
 		panic("io.WriteString() failed: " + err.Error())
@@ -67,9 +67,7 @@ func requireUIUser(ctx context.Context, rc requestContext) bool {
 		return false
 	}
 
-	user, _, _ := rc.req.BasicAuth()
-
-	return user == rc.srv.getOptions().UIUser
+	return authenticatedUsername(rc) == rc.srv.getOptions().UIUser
 }
 
 func requireServerControlUser(ctx context.Context, rc requestContext) bool {
@@ -81,9 +79,7 @@ func requireServerControlUser(ctx context.Context, rc requestContext) bool {
 		return false
 	}
 
-	user, _, _ := rc.req.BasicAuth()
-
-	return user == rc.srv.getOptions().ServerControlUser
+	return authenticatedUsername(rc) == rc.srv.getOptions().ServerControlUser
 }
 
 func anyAuthenticatedUser(ctx context.Context, _ requestContext) bool {
@@ -6,9 +6,13 @@
 	"net/http"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/require"
 
-	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
 )
 
 func TestGenerateCSRFToken(t *testing.T) {
@@ -97,3 +101,41 @@ func TestValidateCSRFToken(t *testing.T) {
 		})
 	}
 }
+
+func TestCanReadSnapshotWithOIDCSession(t *testing.T) {
+	s := &Server{
+		options: Options{
+			UIUser:       "ui-user",
+			OIDCProvider: &auth.OIDCProvider{},
+		},
+		authenticator:        auth.AuthenticateSingleUser("ui-user", "password"),
+		authorizer:           auth.LegacyAuthorizer(),
+		authCookieSigningKey: []byte("some-key"),
+	}
+
+	labels := map[string]string{
+		manifest.TypeLabelKey:  snapshot.ManifestType,
+		snapshot.UsernameLabel: "alice",
+		snapshot.HostnameLabel: "host",
+	}
+
+	ctx := context.Background()
+
+	canRead := func(subject string) bool {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/somepath", http.NoBody)
+		require.NoError(t, err)
+
+		tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{
+			Subject:  subject,
+			Audience: jwt.ClaimStrings{kopiaOIDCSessionAudience},
+		}).SignedString(s.authCookieSigningKey)
+		require.NoError(t, err)
+
+		req.AddCookie(&http.Cookie{Name: kopiaOIDCSessionCookie, Value: tok})
+
+		return canReadSnapshot(ctx, requestContext{req: req, srv: s}, labels)
+	}
+
+	require.True(t, canRead("alice@host"))
+	require.False(t, canRead("bob@host"))
+}
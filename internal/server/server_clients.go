@@ -0,0 +1,84 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// clientSessionInfo describes a repository client that has connected to the server.
+type clientSessionInfo struct {
+	lastSeen       time.Time
+	clientVersion  string
+	remoteAddress  string
+	activeSessions int
+}
+
+// clientSessionTracker keeps track of repository clients connecting via GRPC sessions.
+type clientSessionTracker struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	clients map[string]*clientSessionInfo // keyed by user@host
+}
+
+// sessionStarted records the start of a session by the provided client.
+func (t *clientSessionTracker) sessionStarted(usernameAtHostname, clientVersion, remoteAddress string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.clients == nil {
+		t.clients = map[string]*clientSessionInfo{}
+	}
+
+	ci := t.clients[usernameAtHostname]
+	if ci == nil {
+		ci = &clientSessionInfo{}
+		t.clients[usernameAtHostname] = ci
+	}
+
+	ci.lastSeen = clock.Now()
+	ci.clientVersion = clientVersion
+	ci.remoteAddress = remoteAddress
+	ci.activeSessions++
+}
+
+// sessionEnded records the end of a session by the provided client.
+func (t *clientSessionTracker) sessionEnded(usernameAtHostname string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ci := t.clients[usernameAtHostname]; ci != nil {
+		ci.lastSeen = clock.Now()
+		ci.activeSessions--
+	}
+}
+
+// touch updates the last-seen time of the provided client.
+func (t *clientSessionTracker) touch(usernameAtHostname string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ci := t.clients[usernameAtHostname]; ci != nil {
+		ci.lastSeen = clock.Now()
+	}
+}
+
+// snapshot returns a copy of information about all clients seen by the server.
+func (t *clientSessionTracker) snapshot() map[string]clientSessionInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := map[string]clientSessionInfo{}
+
+	for k, v := range t.clients {
+		result[k] = *v
+	}
+
+	return result
+}
+
+// knownClients returns information about repository clients that connected since the server started, keyed by user@host.
+func (s *Server) knownClients() map[string]clientSessionInfo {
+	return s.grpcServerState.clients.snapshot()
+}
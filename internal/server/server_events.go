@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/serverapi"
+)
+
+const (
+	eventSubscriberBufferSize = 100
+	minTaskProgressInterval   = time.Second
+	eventStreamKeepAlive      = 30 * time.Second
+)
+
+// eventBroker distributes server events to all subscribers of the event stream.
+// Events are dropped for subscribers which are not keeping up.
+type eventBroker struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	subscribers map[chan serverapi.Event]struct{}
+	// +checklocks:mu
+	lastTaskProgress map[string]time.Time
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers:      map[chan serverapi.Event]struct{}{},
+		lastTaskProgress: map[string]time.Time{},
+	}
+}
+
+// subscribe returns a channel receiving published events and a function to unsubscribe.
+func (b *eventBroker) subscribe() (ch <-chan serverapi.Event, unsubscribe func()) {
+	c := make(chan serverapi.Event, eventSubscriberBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[c] = struct{}{}
+	b.mu.Unlock()
+
+	return c, func() {
+		b.mu.Lock()
+		delete(b.subscribers, c)
+		b.mu.Unlock()
+	}
+}
+
+func (b *eventBroker) publish(ev serverapi.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = clock.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.subscribers {
+		select {
+		case c <- ev:
+		default:
+		}
+	}
+}
+
+// onTaskChange is a uitask.Listener which publishes task events, progress events are rate-limited per task.
+func (b *eventBroker) onTaskChange(change uitask.ChangeType, info uitask.Info) {
+	now := clock.Now()
+
+	b.mu.Lock()
+	switch change {
+	case uitask.TaskProgress:
+		if now.Sub(b.lastTaskProgress[info.TaskID]) < minTaskProgressInterval {
+			b.mu.Unlock()
+			return
+		}
+
+		b.lastTaskProgress[info.TaskID] = now
+
+	case uitask.TaskFinished:
+		delete(b.lastTaskProgress, info.TaskID)
+
+	default:
+	}
+	b.mu.Unlock()
+
+	b.publish(serverapi.Event{
+		Type: string(change),
+		Time: now,
+		Task: &info,
+	})
+
+	if change == uitask.TaskFinished && info.Status == uitask.StatusFailed {
+		b.publish(serverapi.Event{
+			Type:  serverapi.EventError,
+			Time:  now,
+			Task:  &info,
+			Error: info.ErrorMessage,
+		})
+	}
+}
+
+// withMaintenancePhaseEvents returns a context which publishes events for each maintenance phase
+// and reports the current phase as progress of the maintenance task.
+func (b *eventBroker) withMaintenancePhaseEvents(ctx context.Context, ctrl uitask.Controller) context.Context {
+	return maintenance.WithPhaseObserver(ctx, func(taskType maintenance.TaskType, finished bool, err error) {
+		ev := serverapi.Event{
+			Type:          serverapi.EventMaintenancePhase,
+			Phase:         string(taskType),
+			PhaseFinished: finished,
+		}
+
+		if err != nil {
+			ev.Error = err.Error()
+		}
+
+		if finished {
+			ctrl.ReportProgressInfo(fmt.Sprintf("Finished %v", taskType))
+		} else {
+			ctrl.ReportProgressInfo(fmt.Sprintf("Running %v", taskType))
+		}
+
+		b.publish(ev)
+	})
+}
+
+// handleEvents streams server events to the client using Server-Sent Events.
+func handleEvents(isAuthorized isAuthorizedFunc) func(ctx context.Context, rc requestContext) {
+	return func(ctx context.Context, rc requestContext) {
+		if !isAuthorized(ctx, rc) {
+			http.Error(rc.w, "access denied", http.StatusForbidden)
+			return
+		}
+
+		flusher, ok := rc.w.(http.Flusher)
+		if !ok {
+			http.Error(rc.w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		events, unsubscribe := rc.srv.eventBroker().subscribe()
+		defer unsubscribe()
+
+		rc.w.Header().Set("Content-Type", "text/event-stream")
+		rc.w.Header().Set("Cache-Control", "no-cache")
+		rc.w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventStreamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-keepAlive.C:
+				// comment line, ignored by clients
+				if _, err := fmt.Fprint(rc.w, ":\n\n"); err != nil {
+					return
+				}
+
+			case ev := <-events:
+				b, err := json.Marshal(ev)
+				if err != nil {
+					log(ctx).Errorf("unable to encode event: %v", err)
+					continue
+				}
+
+				if _, err := fmt.Fprintf(rc.w, "event: %v\ndata: %s\n\n", ev.Type, b); err != nil {
+					return
+				}
+			}
+
+			flusher.Flush()
+		}
+	}
+}
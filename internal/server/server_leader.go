@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/leaderelection"
+	"github.com/kopia/kopia/repo"
+)
+
+const (
+	defaultLeaseDuration = 1 * time.Minute
+
+	// number of election rounds during each lease duration.
+	leaseRenewalsPerDuration = 3
+
+	authCookieSigningKeyLength = 32
+)
+
+// authCookieSigningKeyPurpose is the purpose of the auth cookie signing key derived from the repository.
+var authCookieSigningKeyPurpose = []byte("server-auth-cookie-signing-key") //nolint:gochecknoglobals
+
+// isLeader returns true if the server should run scheduled snapshots and maintenance,
+// which is always the case unless high-availability mode is enabled.
+func (s *Server) isLeader() bool {
+	return s.elector == nil || s.elector.IsLeader()
+}
+
+func (s *Server) leaderElector() *leaderelection.Elector {
+	return s.elector
+}
+
+// +checklocks:s.serverMutex
+func (s *Server) startLeaderElectionLocked(ctx context.Context, rep repo.DirectRepository) {
+	if s.elector == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+
+	s.stopLeaderElection = func(ctx context.Context) {
+		cancel()
+		<-done
+
+		if err := s.elector.Resign(ctx, rep); err != nil {
+			log(ctx).Errorf("unable to resign leadership: %v", err)
+		}
+	}
+
+	go func() {
+		defer close(done)
+
+		wasLeader := false
+
+		for {
+			isLeader, err := s.elector.Campaign(ctx, rep)
+			if err != nil && ctx.Err() == nil {
+				log(ctx).Errorf("leader election error: %v", err)
+			}
+
+			if isLeader != wasLeader {
+				if isLeader {
+					log(ctx).Infof("instance %v is now the leader", s.elector.Holder())
+				} else {
+					log(ctx).Infof("instance %v is no longer the leader", s.elector.Holder())
+				}
+
+				wasLeader = isLeader
+
+				s.refreshScheduler("leadership changed")
+			}
+
+			if !clock.SleepInterruptibly(ctx, s.options.LeaseDuration/leaseRenewalsPerDuration) {
+				return
+			}
+		}
+	}()
+}
+
+// +checklocks:s.serverMutex
+func (s *Server) stopLeaderElectionLocked(ctx context.Context) {
+	if s.stopLeaderElection != nil {
+		s.stopLeaderElection(ctx)
+		s.stopLeaderElection = nil
+	}
+}
+
+// deriveAuthCookieSigningKey sets the auth cookie signing key to a key derived from the repository,
+// so that auth cookies, OIDC sessions and CSRF tokens issued by one instance are valid on all others.
+func (s *Server) deriveAuthCookieSigningKey(dr repo.DirectRepository) {
+	s.authCookieSigningKeyMutex.Lock()
+	defer s.authCookieSigningKeyMutex.Unlock()
+
+	s.authCookieSigningKey = dr.DeriveKey(authCookieSigningKeyPurpose, authCookieSigningKeyLength)
+}
+
+func (s *Server) getAuthCookieSigningKey() []byte {
+	s.authCookieSigningKeyMutex.Lock()
+	defer s.authCookieSigningKeyMutex.Unlock()
+
+	return s.authCookieSigningKey
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func TestHighAvailabilityLeaderElection(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	// all instances connect as the same user, which owns maintenance.
+	mp := maintenance.DefaultParams()
+	mp.Owner = env.Repository.ClientOptions().UsernameAtHost()
+
+	require.NoError(t, maintenance.SetParams(ctx, env.RepositoryWriter, &mp))
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	newServer := func(instanceID string) *Server {
+		s, err := New(ctx, &Options{
+			ConfigFile:       env.ConfigFile(),
+			PasswordPersist:  passwordpersist.File(),
+			Authorizer:       auth.LegacyAuthorizer(),
+			RefreshInterval:  time.Minute,
+			HighAvailability: true,
+			InstanceID:       instanceID,
+			AdvertiseAddress: instanceID + ":51515",
+			LeaseDuration:    3 * time.Second,
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.SetRepository(ctx, env.MustConnectOpenAnother(t)))
+
+		t.Cleanup(func() { s.SetRepository(ctx, nil) })
+
+		return s
+	}
+
+	s1 := newServer("server1")
+	s2 := newServer("server2")
+
+	// exactly one of the instances becomes the leader.
+	require.Eventually(t, func() bool {
+		return s1.isLeader() != s2.isLeader()
+	}, 30*time.Second, 100*time.Millisecond)
+
+	leader, follower := s1, s2
+	if s2.isLeader() {
+		leader, follower = s2, s1
+	}
+
+	require.Equal(t, leader.options.InstanceID, follower.leaderElector().Leader().Holder)
+
+	// only the leader schedules maintenance.
+	hasMaintenance := func(s *Server) bool {
+		for _, it := range s.getSchedulerItems(ctx, clock.Now()) {
+			if it.Description == "maintenance" {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	require.Eventually(t, func() bool { return hasMaintenance(leader) }, 10*time.Second, 100*time.Millisecond)
+	require.False(t, hasMaintenance(follower))
+
+	// auth cookies issued by one instance are accepted by the other.
+	cookie, err := leader.generateShortTermAuthCookie("someuser", clock.Now())
+	require.NoError(t, err)
+	require.True(t, follower.isAuthCookieValid("someuser", cookie))
+	require.Equal(t, leader.generateCSRFToken("session"), follower.generateCSRFToken("session"))
+
+	// when the leader disconnects, the other instance takes over.
+	require.NoError(t, leader.SetRepository(ctx, nil))
+
+	require.Eventually(t, follower.isLeader, 30*time.Second, 100*time.Millisecond)
+}
@@ -15,6 +15,14 @@
 	"github.com/kopia/kopia/repo/maintenance"
 )
 
+// maintenanceTaskKind is the kind of tasks running maintenance.
+const maintenanceTaskKind = "Maintenance"
+
+var (
+	errMaintenanceNotSupported   = errors.New("maintenance requires direct repository connection")
+	errMaintenanceAlreadyRunning = errors.New("maintenance is already running")
+)
+
 type srvMaintenance struct {
 	triggerChan chan struct{}
 	closed      chan struct{}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	snapshotStatusSuccess    = "success"
+	snapshotStatusFailed     = "failed"
+	snapshotStatusIncomplete = "incomplete"
+)
+
+//nolint:gochecknoglobals
+var (
+	sourceLabelNames = []string{"username", "hostname", "path"}
+
+	metricSnapshotDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kopia_server_snapshot_duration_seconds",
+		Help:    "Duration of snapshots by source and status",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10), //nolint:mnd
+	}, append(append([]string(nil), sourceLabelNames...), "status"))
+
+	metricSnapshotsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopia_server_snapshots_total",
+		Help: "Number of snapshots by source and status",
+	}, append(append([]string(nil), sourceLabelNames...), "status"))
+
+	metricLastSuccessfulSnapshot = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kopia_server_last_successful_snapshot_timestamp_seconds",
+		Help: "Time when the last successful snapshot of a source has completed",
+	}, sourceLabelNames)
+
+	metricSnapshotUploadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopia_server_snapshot_uploaded_bytes_total",
+		Help: "Number of bytes uploaded by snapshots of a source",
+	}, sourceLabelNames)
+
+	metricSchedulerLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kopia_server_snapshot_scheduler_lag_seconds",
+		Help:    "Delay between scheduled and actual start time of snapshots",
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 10), //nolint:mnd
+	}, sourceLabelNames)
+
+	metricSessionRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopia_server_session_requests_total",
+		Help: "Number of repository session requests by user",
+	}, []string{"user"})
+
+	metricSessionUploadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kopia_server_session_uploaded_bytes_total",
+		Help: "Number of content bytes written by repository clients by user",
+	}, []string{"user"})
+)
+
+func sourceLabelValues(src snapshot.SourceInfo) []string {
+	return []string{src.UserName, src.Host, src.Path}
+}
+
+// recordSnapshotMetrics records metrics of a completed snapshot attempt.
+func recordSnapshotMetrics(src snapshot.SourceInfo, status string, duration time.Duration, endTime time.Time) {
+	lv := sourceLabelValues(src)
+
+	metricSnapshotDuration.WithLabelValues(append(lv, status)...).Observe(duration.Seconds())
+	metricSnapshotsTotal.WithLabelValues(append(lv, status)...).Inc()
+
+	if status == snapshotStatusSuccess {
+		metricLastSuccessfulSnapshot.WithLabelValues(lv...).Set(float64(endTime.Unix()))
+	}
+}
+
+// recordRemoteSnapshotMetrics records snapshot metrics based on snapshot manifest written by a repository client.
+func recordRemoteSnapshotMetrics(labels map[string]string, jsonData []byte) {
+	if labels[manifest.TypeLabelKey] != snapshot.ManifestType {
+		return
+	}
+
+	var m snapshot.Manifest
+
+	if err := json.Unmarshal(jsonData, &m); err != nil {
+		return
+	}
+
+	status := snapshotStatusSuccess
+	if m.IncompleteReason != "" {
+		status = snapshotStatusIncomplete
+	}
+
+	recordSnapshotMetrics(m.Source, status, m.EndTime.Sub(m.StartTime), m.EndTime.ToTime())
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestRecordRemoteSnapshotMetrics(t *testing.T) {
+	src := snapshot.SourceInfo{UserName: "user", Host: "host", Path: "/remote/snapshot/metrics"}
+	startTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	man := &snapshot.Manifest{
+		Source:    src,
+		StartTime: fs.UTCTimestampFromTime(startTime),
+		EndTime:   fs.UTCTimestampFromTime(startTime.Add(90 * time.Second)),
+	}
+
+	b, err := json.Marshal(man)
+	require.NoError(t, err)
+
+	// not a snapshot manifest
+	recordRemoteSnapshotMetrics(map[string]string{manifest.TypeLabelKey: "policy"}, b)
+	require.Zero(t, testutil.ToFloat64(metricSnapshotsTotal.WithLabelValues(src.UserName, src.Host, src.Path, snapshotStatusSuccess)))
+
+	recordRemoteSnapshotMetrics(map[string]string{manifest.TypeLabelKey: snapshot.ManifestType}, b)
+	require.InDelta(t, 1, testutil.ToFloat64(metricSnapshotsTotal.WithLabelValues(src.UserName, src.Host, src.Path, snapshotStatusSuccess)), 0)
+	require.InDelta(t, float64(startTime.Add(90*time.Second).Unix()), testutil.ToFloat64(metricLastSuccessfulSnapshot.WithLabelValues(src.UserName, src.Host, src.Path)), 0)
+
+	man.IncompleteReason = "canceled"
+
+	b, err = json.Marshal(man)
+	require.NoError(t, err)
+
+	recordRemoteSnapshotMetrics(map[string]string{manifest.TypeLabelKey: snapshot.ManifestType}, b)
+	require.InDelta(t, 1, testutil.ToFloat64(metricSnapshotsTotal.WithLabelValues(src.UserName, src.Host, src.Path, snapshotStatusIncomplete)), 0)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+const (
+	// OIDCLoginPath is the path of the handler starting the OpenID Connect login flow for the web UI.
+	OIDCLoginPath = "/api/v1/oidc/login"
+
+	// OIDCCallbackPath is the path of the handler receiving authorization codes from the OpenID Connect provider.
+	OIDCCallbackPath = "/api/v1/oidc/callback"
+
+	kopiaOIDCStateCookie     = "Kopia-OIDC-State"
+	kopiaOIDCStateTTL        = 10 * time.Minute
+	kopiaOIDCSessionCookie   = "Kopia-OIDC-Session"
+	kopiaOIDCSessionAudience = "kopia-oidc-session"
+	kopiaOIDCMaxSessionTTL   = 12 * time.Hour
+
+	oidcRandomValueLength = 16
+)
+
+func randomHexString() string {
+	b := make([]byte, oidcRandomValueLength)
+	io.ReadFull(rand.Reader, b) //nolint:errcheck
+
+	return hex.EncodeToString(b)
+}
+
+// handleOIDCLogin redirects the browser to the login page of the OpenID Connect provider.
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	p := s.options.OIDCProvider
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, nonce := randomHexString(), randomHexString()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     kopiaOIDCStateCookie,
+		Value:    state + "." + nonce,
+		Path:     "/",
+		Expires:  clock.Now().Add(kopiaOIDCStateTTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, p.AuthCodeURL(state, nonce), http.StatusFound)
+}
+
+// handleOIDCCallback completes the OpenID Connect login flow and starts a web UI session
+// if the authenticated identity can act as the UI user.
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p := s.options.OIDCProvider
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		http.Error(w, "Login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	c, err := r.Cookie(kopiaOIDCStateCookie)
+	if err != nil {
+		http.Error(w, "Missing login state.", http.StatusBadRequest)
+		return
+	}
+
+	state, nonce, _ := strings.Cut(c.Value, ".")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(r.URL.Query().Get("state"))) != 1 {
+		http.Error(w, "Invalid login state.", http.StatusBadRequest)
+		return
+	}
+
+	// state cookie is single-use
+	http.SetCookie(w, &http.Cookie{Name: kopiaOIDCStateCookie, Path: "/", MaxAge: -1})
+
+	id, err := p.Exchange(ctx, r.URL.Query().Get("code"), nonce)
+	if err != nil {
+		log(ctx).Warnf("failed OIDC login by client %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Access denied.", http.StatusUnauthorized)
+
+		return
+	}
+
+	username := s.options.UIUser
+	if username == "" || !p.CanActAs(id, username) {
+		log(ctx).Warnf("OIDC user %q (groups %v) is not allowed to access the UI", id.Username, id.Groups)
+		http.Error(w, "UI Access denied.", http.StatusForbidden)
+
+		return
+	}
+
+	now := clock.Now()
+
+	expiry := id.Expiry
+	if expiry.IsZero() || expiry.After(now.Add(kopiaOIDCMaxSessionTTL)) {
+		expiry = now.Add(kopiaOIDCMaxSessionTTL)
+	}
+
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.RegisteredClaims{
+		Subject:   username,
+		NotBefore: jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(expiry),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Audience:  jwt.ClaimStrings{kopiaOIDCSessionAudience},
+		ID:        uuid.New().String(),
+		Issuer:    kopiaAuthCookieIssuer,
+	}).SignedString(s.getAuthCookieSigningKey())
+	if err != nil {
+		log(ctx).Errorf("unable to generate OIDC session cookie: %v", err)
+		http.Error(w, "Internal error.", http.StatusInternalServerError)
+
+		return
+	}
+
+	if s.options.LogRequests {
+		log(ctx).Infof("successful OIDC login by client %s for user %s as %s", r.RemoteAddr, id.Username, username)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     kopiaOIDCSessionCookie,
+		Value:    tok,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// oidcSessionUsername returns the user authenticated by a valid OIDC session cookie or an empty string.
+func (s *Server) oidcSessionUsername(r *http.Request) string {
+	if s.options.OIDCProvider == nil {
+		return ""
+	}
+
+	c, err := r.Cookie(kopiaOIDCSessionCookie)
+	if err != nil {
+		return ""
+	}
+
+	tok, err := jwt.ParseWithClaims(c.Value, &jwt.RegisteredClaims{}, func(_ *jwt.Token) (interface{}, error) {
+		return s.getAuthCookieSigningKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return ""
+	}
+
+	sc, ok := tok.Claims.(*jwt.RegisteredClaims)
+	if !ok || !sc.VerifyAudience(kopiaOIDCSessionAudience, true) {
+		return ""
+	}
+
+	return sc.Subject
+}
+
+// authenticatedUsername returns the name of the user who authenticated the request.
+func authenticatedUsername(rc requestContext) string {
+	if user, _, ok := rc.req.BasicAuth(); ok {
+		return user
+	}
+
+	return rc.srv.oidcSessionUsername(rc.req)
+}
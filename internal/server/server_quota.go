@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/natefinch/atomic"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo"
+)
+
+var errStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// userQuotaUsage describes quota and resource usage of a single user.
+type userQuotaUsage struct {
+	quota   user.Quota
+	limiter *rate.Limiter
+
+	requests          int64
+	throttledRequests int64
+	rejectedWrites    int64
+	storageBytes      int64 // bytes of new content data written through the server, see recordStorage
+}
+
+// persistedQuotaUsage is the format of the file storing usage across server restarts.
+type persistedQuotaUsage struct {
+	StorageBytes map[string]int64 `json:"storageBytes"`
+}
+
+// userQuotaTracker enforces per-user request rate and storage quotas for GRPC sessions.
+type userQuotaTracker struct {
+	defaults  user.Quota
+	usageFile string
+
+	mu sync.Mutex
+	// +checklocks:mu
+	users map[string]*userQuotaUsage // keyed by user@host
+}
+
+// init sets default quotas and loads usage persisted in the provided file, if any.
+func (t *userQuotaTracker) init(defaults user.Quota, usageFile string) error {
+	t.defaults = defaults.Apply(user.Quota{})
+	t.usageFile = usageFile
+
+	if usageFile == "" {
+		return nil
+	}
+
+	b, err := os.ReadFile(usageFile) //nolint:gosec
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to read quota usage file")
+	}
+
+	var p persistedQuotaUsage
+
+	if err := json.Unmarshal(b, &p); err != nil {
+		return errors.Wrap(err, "invalid quota usage file")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for u, v := range p.StorageBytes {
+		t.getLocked(u).storageBytes = v
+	}
+
+	return nil
+}
+
+// +checklocks:t.mu
+func (t *userQuotaTracker) getLocked(usernameAtHostname string) *userQuotaUsage {
+	if t.users == nil {
+		t.users = map[string]*userQuotaUsage{}
+	}
+
+	u := t.users[usernameAtHostname]
+	if u == nil {
+		u = &userQuotaUsage{quota: t.defaults}
+		u.limiter = newRequestLimiter(u.quota.MaxRequestsPerSecond)
+		t.users[usernameAtHostname] = u
+	}
+
+	return u
+}
+
+// effectiveQuota returns the quota for the provided user, taking overrides from the user profile into account.
+func (t *userQuotaTracker) effectiveQuota(ctx context.Context, rep repo.Repository, usernameAtHostname string) user.Quota {
+	up, err := user.GetUserProfile(ctx, rep, usernameAtHostname)
+	if err != nil {
+		// users not defined in the repository get default quotas.
+		return t.defaults
+	}
+
+	return up.Quota.Apply(t.defaults)
+}
+
+// sessionStarted sets the quota for the provided user, all sessions of the user share the same limits.
+func (t *userQuotaTracker) sessionStarted(usernameAtHostname string, q user.Quota) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.getLocked(usernameAtHostname)
+	if u.quota.MaxRequestsPerSecond != q.MaxRequestsPerSecond {
+		u.limiter = newRequestLimiter(q.MaxRequestsPerSecond)
+	}
+
+	u.quota = q
+}
+
+// waitForRequest records a request by the provided user and blocks until it is allowed by the request rate quota.
+func (t *userQuotaTracker) waitForRequest(ctx context.Context, usernameAtHostname string) error {
+	t.mu.Lock()
+	u := t.getLocked(usernameAtHostname)
+	u.requests++
+	lim := u.limiter
+	t.mu.Unlock()
+
+	if lim == nil || lim.Allow() {
+		return nil
+	}
+
+	t.mu.Lock()
+	u.throttledRequests++
+	t.mu.Unlock()
+
+	return errors.Wrap(lim.Wait(ctx), "request throttled")
+}
+
+// checkStorage returns an error if writing the provided number of bytes would exceed the storage quota of the user.
+func (t *userQuotaTracker) checkStorage(usernameAtHostname string, n int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.getLocked(usernameAtHostname)
+	if u.quota.MaxStorageBytes > 0 && u.storageBytes+n > u.quota.MaxStorageBytes {
+		u.rejectedWrites++
+
+		return errors.Wrapf(errStorageQuotaExceeded, "%v has used %v of %v bytes", usernameAtHostname, u.storageBytes, u.quota.MaxStorageBytes)
+	}
+
+	return nil
+}
+
+// recordStorage adds the provided number of bytes to the storage usage of the user.
+//
+// Usage counts uncompressed size of contents which the repository did not have when they were written,
+// it is not reduced when snapshots are deleted and contents garbage-collected, so it differs from the actual
+// storage used by the repository. Usage can be cleared with 'server quota reset'.
+func (t *userQuotaTracker) recordStorage(usernameAtHostname string, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.getLocked(usernameAtHostname).storageBytes += n
+}
+
+// reset clears usage counters of the provided user.
+func (t *userQuotaTracker) reset(usernameAtHostname string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.users[usernameAtHostname]
+	if u == nil {
+		return false
+	}
+
+	u.requests = 0
+	u.throttledRequests = 0
+	u.rejectedWrites = 0
+	u.storageBytes = 0
+
+	return true
+}
+
+// snapshot returns a copy of quotas and usage of all users seen by the server.
+func (t *userQuotaTracker) snapshot() map[string]userQuotaUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := map[string]userQuotaUsage{}
+
+	for k, v := range t.users {
+		result[k] = *v
+	}
+
+	return result
+}
+
+// save persists storage usage in the usage file.
+func (t *userQuotaTracker) save() error {
+	if t.usageFile == "" {
+		return nil
+	}
+
+	p := persistedQuotaUsage{StorageBytes: map[string]int64{}}
+
+	for k, v := range t.snapshot() {
+		p.StorageBytes[k] = v.storageBytes
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal quota usage")
+	}
+
+	return errors.Wrap(atomic.WriteFile(t.usageFile, bytes.NewReader(b)), "unable to write quota usage file")
+}
+
+func newRequestLimiter(requestsPerSecond float64) *rate.Limiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(requestsPerSecond), int(math.Max(1, math.Ceil(requestsPerSecond))))
+}
+
+// userQuotas returns the tracker of per-user quotas and usage.
+func (s *Server) userQuotas() *userQuotaTracker {
+	return &s.grpcServerState.quotas
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/user"
+)
+
+func TestUserQuotaTracker(t *testing.T) {
+	ctx := context.Background()
+	usageFile := filepath.Join(t.TempDir(), "quota-usage.json")
+
+	var tr userQuotaTracker
+
+	require.NoError(t, tr.init(user.Quota{MaxStorageBytes: 100}, usageFile))
+
+	tr.sessionStarted("foo@bar", (&user.Quota{MaxRequestsPerSecond: 1000}).Apply(tr.defaults))
+	tr.sessionStarted("alice@wonderland", (&user.Quota{MaxStorageBytes: -1}).Apply(tr.defaults))
+
+	// storage quota is enforced per user.
+	require.NoError(t, tr.checkStorage("foo@bar", 60))
+	tr.recordStorage("foo@bar", 60)
+	require.ErrorIs(t, tr.checkStorage("foo@bar", 60), errStorageQuotaExceeded)
+	require.NoError(t, tr.checkStorage("foo@bar", 40))
+	require.NoError(t, tr.checkStorage("alice@wonderland", 1000))
+	tr.recordStorage("alice@wonderland", 1000)
+
+	// requests above the burst are throttled but eventually allowed.
+	for range 1010 {
+		require.NoError(t, tr.waitForRequest(ctx, "foo@bar"))
+	}
+
+	u := tr.snapshot()["foo@bar"]
+	require.EqualValues(t, 1010, u.requests)
+	require.Positive(t, u.throttledRequests)
+	require.EqualValues(t, 1, u.rejectedWrites)
+	require.EqualValues(t, 60, u.storageBytes)
+	require.Equal(t, user.Quota{MaxRequestsPerSecond: 1000, MaxStorageBytes: 100}, u.quota)
+
+	// canceled context aborts throttled request.
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	tr.sessionStarted("foo@bar", user.Quota{MaxRequestsPerSecond: 0.001})
+	require.NoError(t, tr.waitForRequest(canceledCtx, "foo@bar"))
+	require.Error(t, tr.waitForRequest(canceledCtx, "foo@bar"))
+
+	// storage usage is persisted.
+	require.NoError(t, tr.save())
+
+	var tr2 userQuotaTracker
+
+	require.NoError(t, tr2.init(user.Quota{}, usageFile))
+	require.EqualValues(t, 60, tr2.snapshot()["foo@bar"].storageBytes)
+	require.EqualValues(t, 1000, tr2.snapshot()["alice@wonderland"].storageBytes)
+
+	require.True(t, tr2.reset("foo@bar"))
+	require.False(t, tr2.reset("no-such@user"))
+	require.EqualValues(t, 0, tr2.snapshot()["foo@bar"].storageBytes)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/replication"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/serverapi"
+)
+
+// replicationTaskKind is the kind of the task replicating repository blobs to secondary storage.
+const replicationTaskKind = "Replication"
+
+// srvReplicator continuously replicates repository blobs to secondary storage as a long-running task.
+type srvReplicator struct {
+	r      *replication.Replicator
+	dst    blob.Storage
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (r *srvReplicator) stop(ctx context.Context) {
+	r.cancel()
+	r.wg.Wait()
+
+	if err := r.dst.Close(ctx); err != nil {
+		log(ctx).Errorf("unable to close replication destination: %v", err)
+	}
+
+	log(ctx).Debug("replication stopped")
+}
+
+func startReplicator(ctx context.Context, dr repo.DirectRepository, taskmgr *uitask.Manager, opts *Options) (*srvReplicator, error) {
+	dst, err := blob.NewStorage(ctx, *opts.ReplicateTo, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to connect to replication destination")
+	}
+
+	var ctrl uitask.Controller
+
+	r, err := replication.New(dr.BlobReader(), dst, replication.Options{
+		StateFile: opts.ReplicationStateFile,
+		Interval:  opts.ReplicationInterval,
+		Delete:    true,
+		OnCycle: func(s replication.Status) {
+			// cycles are reported on the goroutine running the task, after ctrl has been set.
+			ctrl.ReportCounters(map[string]uitask.CounterValue{
+				"Replicated Blobs": uitask.SimpleCounter(int64(s.ReplicatedBlobs)),
+				"Replicated Bytes": uitask.BytesCounter(s.ReplicatedBytes),
+				"Pending Blobs":    uitask.SimpleCounter(int64(s.PendingBlobs)),
+				"Lag Seconds":      uitask.SimpleCounter(int64(s.Lag.Seconds())),
+			})
+		},
+	})
+	if err != nil {
+		dst.Close(ctx) //nolint:errcheck
+
+		return nil, errors.Wrap(err, "unable to initialize replication")
+	}
+
+	rctx, cancel := context.WithCancel(ctx)
+
+	sr := &srvReplicator{
+		r:      r,
+		dst:    dst,
+		cancel: cancel,
+	}
+
+	sr.wg.Add(1)
+
+	go func() {
+		defer sr.wg.Done()
+
+		err := taskmgr.Run(rctx, replicationTaskKind, "Replication to "+dst.DisplayName(), func(ctx context.Context, c uitask.Controller) error {
+			ctrl = c
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			ctrl.OnCancel(cancel)
+
+			return r.Run(ctx)
+		})
+
+		if err != nil && rctx.Err() == nil {
+			log(ctx).Errorf("replication stopped: %v", err)
+		}
+	}()
+
+	return sr, nil
+}
+
+func handleReplicationStatus(_ context.Context, rc requestContext) (interface{}, *apiError) {
+	r := rc.srv.replicator()
+	if r == nil {
+		return nil, notFoundError("replication is not enabled")
+	}
+
+	return &serverapi.ReplicationStatusResponse{Status: r.r.Status()}, nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/bgverify"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+)
+
+// verificationTaskKind is the kind of the task verifying repository contents in the background.
+const verificationTaskKind = "Verification"
+
+// srvVerifier continuously verifies repository contents at a limited rate as a long-running task.
+type srvVerifier struct {
+	v      *bgverify.Verifier
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (v *srvVerifier) stop(ctx context.Context) {
+	v.cancel()
+	v.wg.Wait()
+
+	log(ctx).Debug("background verification stopped")
+}
+
+func startVerifier(ctx context.Context, dr repo.DirectRepository, taskmgr *uitask.Manager, opts *Options) (*srvVerifier, error) {
+	var ctrl uitask.Controller
+
+	v, err := bgverify.New(dr, bgverify.Options{
+		BytesPerHour: opts.VerifyBytesPerHour,
+		StateFile:    opts.VerifyStateFile,
+		OnCheckpoint: func(s bgverify.Status) {
+			// checkpoints are reported on the goroutine running the task, after ctrl has been set.
+			ctrl.ReportCounters(map[string]uitask.CounterValue{
+				"Verified Contents": uitask.SimpleCounter(s.PassVerifiedContents),
+				"Verified Bytes":    uitask.BytesCounter(s.PassVerifiedBytes),
+				"Total Bytes":       uitask.BytesCounter(s.PassTotalBytes),
+				"Errors":            uitask.ErrorCounter(s.PassErrorCount),
+			})
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize background verification")
+	}
+
+	vctx, cancel := context.WithCancel(ctx)
+
+	sv := &srvVerifier{
+		v:      v,
+		cancel: cancel,
+	}
+
+	sv.wg.Add(1)
+
+	go func() {
+		defer sv.wg.Done()
+
+		err := taskmgr.Run(vctx, verificationTaskKind, "Background verification", func(ctx context.Context, c uitask.Controller) error {
+			ctrl = c
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			ctrl.OnCancel(cancel)
+
+			return v.Run(ctx)
+		})
+
+		if err != nil && vctx.Err() == nil {
+			log(ctx).Errorf("background verification stopped: %v", err)
+		}
+	}()
+
+	return sv, nil
+}
+
+func handleVerificationStatus(_ context.Context, rc requestContext) (interface{}, *apiError) {
+	v := rc.srv.verifier()
+	if v == nil {
+		return nil, notFoundError("background verification is not enabled")
+	}
+
+	return &serverapi.VerificationStatusResponse{Status: v.v.Status()}, nil
+}
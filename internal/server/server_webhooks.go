@@ -0,0 +1,292 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const (
+	webhookCheckInterval = 15 * time.Minute
+	webhookSendTimeout   = 30 * time.Second
+)
+
+// webhookState keeps track of conditions reported to webhooks, so that each condition is reported
+// once when it starts rather than on every check.
+type webhookState struct {
+	mu sync.Mutex
+	// +checklocks:mu
+	nextCheckTime time.Time
+	// +checklocks:mu
+	reported map[string]bool // keyed by webhook name and condition
+}
+
+// markReported records whether the condition is present and returns true if it has just started.
+func (st *webhookState) markReported(key string, present bool) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.reported == nil {
+		st.reported = map[string]bool{}
+	}
+
+	wasPresent := st.reported[key]
+
+	if present {
+		st.reported[key] = true
+	} else {
+		delete(st.reported, key)
+	}
+
+	return present && !wasPresent
+}
+
+func (st *webhookState) getNextCheckTime() time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.nextCheckTime
+}
+
+func (st *webhookState) setNextCheckTime(t time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.nextCheckTime = t
+}
+
+// notifyWebhooks delivers the event to all webhooks subscribed to it in the background.
+//
+// The repository is looked up in the background because this may be called from a snapshot task
+// while SetRepository() holds the server mutex and waits for the task to complete.
+func (s *Server) notifyWebhooks(ev *webhook.Event) {
+	go func() {
+		hooks, err := s.listWebhooks(s.rootctx)
+		if err != nil {
+			log(s.rootctx).Errorf("unable to list webhooks: %v", err)
+			return
+		}
+
+		s.sendWebhooks(s.rootctx, hooks, ev)
+	}()
+}
+
+// listWebhooks returns webhooks defined in the repository while holding the server mutex,
+// which prevents the repository from being closed while they are being read.
+func (s *Server) listWebhooks(ctx context.Context) ([]*webhook.Webhook, error) {
+	s.serverMutex.RLock()
+	defer s.serverMutex.RUnlock()
+
+	// it's possible that repository was closed in the meantime.
+	if s.rep == nil {
+		return nil, nil
+	}
+
+	//nolint:wrapcheck
+	return webhook.List(ctx, s.rep)
+}
+
+// sendWebhooks delivers the event to subscribed webhooks.
+func (s *Server) sendWebhooks(ctx context.Context, hooks []*webhook.Webhook, ev *webhook.Event) {
+	for _, wh := range hooks {
+		if !wh.Subscribes(ev.Type) {
+			continue
+		}
+
+		if err := s.sendWebhook(ctx, wh, ev); err != nil {
+			log(ctx).Errorf("unable to send %v event to webhook %v: %v", ev.Type, wh.Name, err)
+		}
+	}
+}
+
+func (s *Server) sendWebhook(ctx context.Context, wh *webhook.Webhook, ev *webhook.Event) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookSendTimeout)
+	defer cancel()
+
+	if ev.Time.IsZero() {
+		ev.Time = clock.Now()
+	}
+
+	if ev.Hostname == "" {
+		ev.Hostname, _ = os.Hostname()
+	}
+
+	//nolint:wrapcheck
+	return wh.Send(ctx, http.DefaultClient, ev)
+}
+
+// notifySnapshotWebhooks notifies webhooks about a snapshot manifest written by a repository client.
+func (s *Server) notifySnapshotWebhooks(labels map[string]string, manifestID manifest.ID, jsonData []byte) {
+	if labels[manifest.TypeLabelKey] != snapshot.ManifestType {
+		return
+	}
+
+	var m snapshot.Manifest
+
+	if err := json.Unmarshal(jsonData, &m); err != nil || m.IncompleteReason == snapshotfs.IncompleteReasonCheckpoint {
+		return
+	}
+
+	ev := &webhook.Event{
+		Type:       webhook.EventSnapshotFinished,
+		Source:     &m.Source,
+		SnapshotID: manifestID,
+	}
+
+	if m.IncompleteReason != "" {
+		ev.Error = "snapshot incomplete: " + m.IncompleteReason
+	}
+
+	s.notifyWebhooks(ev)
+}
+
+// checkWebhookConditionsSoon schedules checking of webhook conditions as soon as possible,
+// so that changes to webhooks take effect immediately.
+func (s *Server) checkWebhookConditionsSoon() {
+	s.webhooks.setNextCheckTime(clock.Now())
+	s.refreshScheduler("webhooks changed")
+}
+
+func (s *Server) checkWebhookConditionsAsync() {
+	// prevent the check from being runnable.
+	s.webhooks.setNextCheckTime(clock.Now().Add(webhookCheckInterval))
+
+	go s.checkWebhookConditions(s.rootctx)
+}
+
+// checkWebhookConditions reports missing clients and low storage to subscribed webhooks.
+func (s *Server) checkWebhookConditions(ctx context.Context) {
+	s.serverMutex.RLock()
+	rep := s.rep
+	s.serverMutex.RUnlock()
+
+	if rep == nil {
+		return
+	}
+
+	hooks, err := webhook.List(ctx, rep)
+	if err != nil {
+		log(ctx).Errorf("unable to list webhooks: %v", err)
+		return
+	}
+
+	for _, wh := range hooks {
+		if wh.Subscribes(webhook.EventClientMissing) {
+			if err := s.checkMissingClients(ctx, rep, wh); err != nil {
+				log(ctx).Errorf("unable to check missing clients for webhook %v: %v", wh.Name, err)
+			}
+		}
+
+		if wh.Subscribes(webhook.EventLowStorage) {
+			if err := s.checkLowStorage(ctx, rep, wh); err != nil {
+				log(ctx).Errorf("unable to check storage capacity for webhook %v: %v", wh.Name, err)
+			}
+		}
+	}
+}
+
+// checkMissingClients reports clients which have neither connected nor written a snapshot recently.
+func (s *Server) checkMissingClients(ctx context.Context, rep repo.Repository, wh *webhook.Webhook) error {
+	now := clock.Now()
+
+	sources, err := snapshot.ListSources(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to list sources")
+	}
+
+	clients := s.knownClients()
+	lastSeen := map[string]time.Time{}
+
+	for _, src := range sources {
+		if src.Host == rep.ClientOptions().Hostname {
+			// sources of the server itself.
+			continue
+		}
+
+		fs, err := fleetSourceInfo(ctx, rep, src, now)
+		if err != nil {
+			return err
+		}
+
+		client := src.UserName + "@" + src.Host
+		if fs.LastSnapshotTime != nil && fs.LastSnapshotTime.After(lastSeen[client]) {
+			lastSeen[client] = *fs.LastSnapshotTime
+		} else if _, ok := lastSeen[client]; !ok {
+			lastSeen[client] = time.Time{}
+		}
+	}
+
+	for client, ci := range clients {
+		if ci.activeSessions > 0 {
+			ci.lastSeen = now
+		}
+
+		if ci.lastSeen.After(lastSeen[client]) {
+			lastSeen[client] = ci.lastSeen
+		}
+	}
+
+	for client, t := range lastSeen {
+		missing := !t.IsZero() && now.Sub(t) > wh.ClientMissingThreshold()
+
+		if !s.webhooks.markReported(wh.Name+"/"+webhook.EventClientMissing+"/"+client, missing) {
+			continue
+		}
+
+		if err := s.sendWebhook(ctx, wh, &webhook.Event{
+			Type:     webhook.EventClientMissing,
+			Client:   client,
+			LastSeen: &t,
+		}); err != nil {
+			log(ctx).Errorf("unable to send %v event to webhook %v: %v", webhook.EventClientMissing, wh.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkLowStorage reports when free capacity of the repository storage drops below the threshold.
+func (s *Server) checkLowStorage(ctx context.Context, rep repo.Repository, wh *webhook.Webhook) error {
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return nil
+	}
+
+	c, err := dr.BlobVolume().GetCapacity(ctx)
+	if errors.Is(err, blob.ErrNotAVolume) || (err == nil && c.SizeB == 0) {
+		// capacity is unknown.
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to get storage capacity")
+	}
+
+	si := &webhook.StorageInfo{
+		SizeBytes:   c.SizeB,
+		FreeBytes:   c.FreeB,
+		FreePercent: 100 * float64(c.FreeB) / float64(c.SizeB), //nolint:mnd
+	}
+
+	if !s.webhooks.markReported(wh.Name+"/"+webhook.EventLowStorage, si.FreePercent < wh.LowStorageThreshold()) {
+		return nil
+	}
+
+	return s.sendWebhook(ctx, wh, &webhook.Event{
+		Type:    webhook.EventLowStorage,
+		Storage: si,
+	})
+}
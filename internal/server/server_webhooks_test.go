@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestWebhookMissingClients(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	var (
+		mu     sync.Mutex
+		events []webhook.Event
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev webhook.Event
+
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	wh := &webhook.Webhook{
+		Name:               "hook",
+		URL:                srv.URL,
+		Events:             []string{webhook.EventClientMissing},
+		ClientMissingAfter: 24 * time.Hour,
+	}
+
+	s := &Server{}
+
+	writeSnapshot := func(src snapshot.SourceInfo, age time.Duration) {
+		_, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, &snapshot.Manifest{
+			Source:    src,
+			StartTime: fs.UTCTimestampFromTime(clock.Now().Add(-age)),
+			EndTime:   fs.UTCTimestampFromTime(clock.Now().Add(-age)),
+		})
+		require.NoError(t, err)
+	}
+
+	bob := snapshot.SourceInfo{UserName: "bob", Host: "laptop", Path: "/home/bob"}
+	carol := snapshot.SourceInfo{UserName: "carol", Host: "desktop", Path: "/home/carol"}
+
+	writeSnapshot(bob, 48*time.Hour)
+	writeSnapshot(carol, time.Hour)
+
+	// sources of the server itself are never reported.
+	writeSnapshot(snapshot.SourceInfo{UserName: "srv", Host: env.Repository.ClientOptions().Hostname, Path: "/data"}, 48*time.Hour)
+
+	// only bob is missing and only reported once.
+	require.NoError(t, s.checkMissingClients(ctx, env.RepositoryWriter, wh))
+	require.NoError(t, s.checkMissingClients(ctx, env.RepositoryWriter, wh))
+
+	mu.Lock()
+	require.Len(t, events, 1)
+	require.Equal(t, webhook.EventClientMissing, events[0].Type)
+	require.Equal(t, "bob@laptop", events[0].Client)
+	mu.Unlock()
+
+	// bob is back and goes missing again.
+	s.grpcServerState.clients.sessionStarted("bob@laptop", "v1", "127.0.0.1")
+	require.NoError(t, s.checkMissingClients(ctx, env.RepositoryWriter, wh))
+	s.grpcServerState.clients.sessionEnded("bob@laptop")
+
+	wh.ClientMissingAfter = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	require.NoError(t, s.checkMissingClients(ctx, env.RepositoryWriter, wh))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	clients := map[string]int{}
+	for _, ev := range events {
+		clients[ev.Client]++
+	}
+
+	require.Equal(t, map[string]int{"bob@laptop": 2, "carol@desktop": 1}, clients)
+}
@@ -11,15 +11,17 @@
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/localfs"
 	"github.com/kopia/kopia/internal/clock"
-	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/notification/notifydata"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
 	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
+var errSnapshotInProgress = errors.New("snapshot is already in progress")
+
 const (
 	failedSnapshotRetryInterval = 5 * time.Minute
 	refreshTimeout              = 30 * time.Second // max amount of time to refresh a single source
@@ -27,7 +29,7 @@
 )
 
 type sourceManagerServerInterface interface {
-	runSnapshotTask(ctx context.Context, src snapshot.SourceInfo, inner func(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error) error
+	runSnapshotTask(ctx context.Context, src snapshot.SourceInfo, onStart func(taskID string), inner func(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error) error
 	refreshScheduler(reason string)
 }
 
@@ -73,6 +75,10 @@ type sourceManager struct {
 	lastAttemptedSnapshotTime fs.UTCTimestamp
 	// +checklocks:sourceMutex
 	isReadOnly bool
+	// +checklocks:sourceMutex
+	pendingOverrides *snapshotOverrides
+	// +checklocks:sourceMutex
+	scheduledSnapshotTime time.Time // time when the pending snapshot was scheduled to run, used to measure scheduler lag
 
 	progress *snapshotfs.CountingUploadProgress
 }
@@ -188,14 +194,23 @@ func (s *sourceManager) runLocal(ctx context.Context) {
 			return
 
 		case <-s.snapshotRequests:
+			ov := s.takePendingOverrides()
+
 			if s.isPaused() {
 				s.setStatus("PAUSED")
+				ov.finished()
 			} else {
 				s.setStatus("PENDING")
 
 				log(ctx).Debugw("snapshotting", "source", s.src)
 
-				if err := s.server.runSnapshotTask(ctx, s.src, s.snapshotInternal); err != nil {
+				err := s.server.runSnapshotTask(ctx, s.src, ov.started, func(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error {
+					return s.snapshotInternal(ctx, ctrl, result, ov)
+				})
+
+				ov.finished()
+
+				if err != nil {
 					log(ctx).Errorf("snapshot error: %v", err)
 
 					s.backoffBeforeNextSnapshot()
@@ -249,6 +264,111 @@ func (s *sourceManager) scheduleSnapshotNow() {
 	}
 }
 
+// triggerScheduledSnapshot schedules a snapshot which was due at the provided time.
+func (s *sourceManager) triggerScheduledSnapshot(scheduledTime time.Time) {
+	s.sourceMutex.Lock()
+	s.scheduledSnapshotTime = scheduledTime
+	s.sourceMutex.Unlock()
+
+	s.scheduleSnapshotNow()
+}
+
+// snapshotOverrides are optional settings applied to a single snapshot triggered via the API.
+type snapshotOverrides struct {
+	description          string
+	tags                 map[string]string
+	uploadBytesPerSecond float64
+
+	// receives the ID of the snapshot task once it has been created, closed when done.
+	taskStarted chan string
+}
+
+func (o *snapshotOverrides) started(taskID string) {
+	if o == nil {
+		return
+	}
+
+	select {
+	case o.taskStarted <- taskID:
+	default:
+	}
+}
+
+func (o *snapshotOverrides) finished() {
+	if o == nil {
+		return
+	}
+
+	close(o.taskStarted)
+}
+
+func (s *sourceManager) takePendingOverrides() *snapshotOverrides {
+	s.sourceMutex.Lock()
+	defer s.sourceMutex.Unlock()
+
+	ov := s.pendingOverrides
+	s.pendingOverrides = nil
+
+	return ov
+}
+
+// scheduleSnapshotWithOverrides schedules immediate snapshot with the provided overrides and returns
+// a channel which will receive the ID of the snapshot task.
+func (s *sourceManager) scheduleSnapshotWithOverrides(ov *snapshotOverrides) (<-chan string, error) {
+	s.sourceMutex.Lock()
+	defer s.sourceMutex.Unlock()
+
+	if s.isReadOnly {
+		return nil, errors.New("source is not local")
+	}
+
+	if s.paused {
+		return nil, errors.New("source is paused")
+	}
+
+	if s.currentTask != "" || s.pendingOverrides != nil {
+		return nil, errSnapshotInProgress
+	}
+
+	ov.taskStarted = make(chan string, 1)
+	s.pendingOverrides = ov
+
+	// next snapshot time will be recalculated by refreshStatus()
+	s.nextSnapshotTime = nil
+
+	select {
+	case s.snapshotRequests <- struct{}{}: // scheduled snapshot
+	default: // already scheduled
+	}
+
+	return ov.taskStarted, nil
+}
+
+// overrideUploadSpeed temporarily overrides upload speed limit of the repository and returns
+// a function which restores the original limits.
+func (s *sourceManager) overrideUploadSpeed(ctx context.Context, bytesPerSecond float64) (func(), error) {
+	dr, ok := s.rep.(repo.DirectRepository)
+	if !ok {
+		return nil, errors.New("upload speed can only be overridden for direct repository connections")
+	}
+
+	t := dr.Throttler()
+	original := t.Limits()
+
+	l := original
+	l.UploadBytesPerSecond = bytesPerSecond
+
+	if err := t.SetLimits(l); err != nil {
+		return nil, errors.Wrap(err, "unable to set upload speed")
+	}
+
+	return func() {
+		if err := t.SetLimits(original); err != nil {
+			log(ctx).Errorf("unable to restore throttling limits: %v", err)
+		}
+	}, nil
+}
+
 func (s *sourceManager) upload(ctx context.Context) serverapi.SourceActionResponse {
 	log(ctx).Infof("upload triggered via API: %v", s.src)
 	s.scheduleSnapshotNow()
@@ -309,7 +429,7 @@ func (s *sourceManager) waitUntilStopped() {
 	s.wg.Wait()
 }
 
-func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError) error {
+func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Controller, result *notifydata.ManifestWithError, ov *snapshotOverrides) (reterr error) {
 	s.setStatus("UPLOADING")
 
 	s.setCurrentTaskID(ctrl.CurrentTaskID())
@@ -324,11 +444,39 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 	default:
 	}
 
+	startTime := clock.Now()
+
+	s.sourceMutex.Lock()
+	scheduledTime := s.scheduledSnapshotTime
+	s.scheduledSnapshotTime = time.Time{}
+	s.sourceMutex.Unlock()
+
+	if !scheduledTime.IsZero() && startTime.After(scheduledTime) {
+		metricSchedulerLag.WithLabelValues(sourceLabelValues(s.src)...).Observe(startTime.Sub(scheduledTime).Seconds())
+	}
+
+	defer func() {
+		status := snapshotStatusSuccess
+
+		switch {
+		case reterr != nil:
+			status = snapshotStatusFailed
+		case result.Manifest.IncompleteReason != "":
+			status = snapshotStatusIncomplete
+		}
+
+		endTime := clock.Now()
+
+		recordSnapshotMetrics(s.src, status, endTime.Sub(startTime), endTime)
+	}()
+
 	localEntry, err := localfs.NewEntry(s.src.Path)
 	if err != nil {
 		return errors.Wrap(err, "unable to create local filesystem")
 	}
 
+	uploadedBytes := metricSnapshotUploadedBytes.WithLabelValues(sourceLabelValues(s.src)...)
+
 	onUpload := func(int64) {}
 
 	s.sourceMutex.Lock()
@@ -347,6 +495,7 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			// extra indirection to allow changing onUpload function later
 			// once we have the uploader
 			onUpload(numBytes)
+			uploadedBytes.Add(float64(numBytes))
 		},
 	}, func(ctx context.Context, w repo.RepositoryWriter) error {
 		log(ctx).Debugf("uploading %v", s.src)
@@ -370,6 +519,15 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			u.Progress.UploadedBytes(numBytes)
 		}
 
+		if ov != nil && ov.uploadBytesPerSecond > 0 {
+			restoreLimits, err := s.overrideUploadSpeed(ctx, ov.uploadBytesPerSecond)
+			if err != nil {
+				return err
+			}
+
+			defer restoreLimits()
+		}
+
 		log(ctx).Debugf("starting upload of %v", s.src)
 		s.setUploader(u)
 
@@ -382,6 +540,16 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "upload error")
 		}
 
+		if ov != nil {
+			if ov.description != "" {
+				manifest.Description = ov.description
+			}
+
+			if len(ov.tags) > 0 {
+				manifest.Tags = ov.tags
+			}
+		}
+
 		result.Manifest = *manifest
 
 		ignoreIdenticalSnapshot := policyTree.EffectivePolicy().RetentionPolicy.IgnoreIdenticalSnapshots.OrDefault(false)
@@ -401,6 +569,8 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "unable to apply retention policy")
 		}
 
+		result.Manifest.ID = snapshotID
+
 		log(ctx).Debugf("created snapshot %v", snapshotID)
 
 		return nil
@@ -70,6 +70,7 @@ func StartServerContext(ctx context.Context, t *testing.T, env *repotesting.Envi
 	m := mux.NewRouter()
 	s.SetupHTMLUIAPIHandlers(m)
 	s.SetupControlAPIHandlers(m)
+	s.SetupEnrollmentAPIHandlers(m)
 	s.ServeStaticFiles(m, server.AssetFile())
 
 	hs := httptest.NewUnstartedServer(s.GRPCRouterHandler(m))
@@ -3,6 +3,7 @@
 
 import (
 	"io"
+	"os"
 
 	"github.com/pkg/errors"
 
@@ -10,60 +11,153 @@
 )
 
 // Copy copies a file sparsely (omitting holes) from src to dst, while recycling
-// shared buffers.
-func Copy(dst io.WriteSeeker, src io.Reader, bufSize uint64) (int64, error) {
+// shared buffers. Every block of zeros of the provided size becomes a hole.
+func Copy(dst io.WriteSeeker, src io.Reader, blockSize uint64) (int64, error) {
+	return CopyWithMinHoleSize(dst, src, blockSize, 0)
+}
+
+// CopyWithMinHoleSize copies a file from src to dst, writing runs of zero blocks that are at least
+// minHoleSize bytes long as holes and other data (including shorter runs of zeros) as-is.
+//
+// When dst is an *os.File, the skipped ranges are explicitly deallocated using the platform-specific
+// mechanism (fallocate on Linux, FSCTL_SET_ZERO_DATA on Windows) if the file system supports it,
+// otherwise holes are created by seeking past them, which requires dst to be pre-sized.
+func CopyWithMinHoleSize(dst io.WriteSeeker, src io.Reader, blockSize, minHoleSize uint64) (int64, error) {
+	if blockSize == 0 || blockSize > iocopy.BufSize {
+		return 0, errors.Errorf("invalid block size %v", blockSize)
+	}
+
 	buf := iocopy.GetBuffer()
 	defer iocopy.ReleaseBuffer(buf)
 
-	return copyBuffer(dst, src, buf[0:bufSize])
+	// read multiple of block size to keep blocks aligned.
+	buf = buf[0 : uint64(len(buf))/blockSize*blockSize]
+
+	c := &copier{
+		dst:         dst,
+		blockSize:   int(blockSize),                     //nolint:gosec
+		minHoleSize: int64(max(minHoleSize, blockSize)), //nolint:gosec
+	}
+
+	if f, ok := dst.(*os.File); ok {
+		c.file = f
+	}
+
+	err := c.copy(src, buf)
+
+	return c.written, err
+}
+
+type copier struct {
+	dst         io.WriteSeeker
+	file        *os.File
+	blockSize   int
+	minHoleSize int64
+
+	written     int64
+	pendingZero int64 // length of the run of zeros ending at the current position which was not written yet
+	zeros       []byte
 }
 
-// Copy copies bits from src to dst, seeking past blocks of zero bits in src. These
-// blocks are omitted, creating a file with holes in dst.
-func copyBuffer(dst io.WriteSeeker, src io.Reader, buf []byte) (written int64, err error) {
+func (c *copier) copy(src io.Reader, buf []byte) error {
 	for {
-		nr, er := src.Read(buf)
-		if nr > 0 { //nolint:nestif
-			// If non-zero data is read, write it. Otherwise, skip forwards.
-			if isAllZero(buf) {
-				dst.Seek(int64(nr), io.SeekCurrent) //nolint:errcheck
-				written += int64(nr)
+		nr, er := io.ReadFull(src, buf)
+
+		for off := 0; off < nr; off += c.blockSize {
+			blk := buf[off:min(off+c.blockSize, nr)]
 
+			if isAllZero(blk) {
+				c.pendingZero += int64(len(blk))
 				continue
 			}
 
-			nw, ew := dst.Write(buf[0:nr])
-			if nw < 0 || nr < nw {
-				nw = 0
+			if err := c.flushZeros(); err != nil {
+				return err
+			}
 
-				if ew == nil {
-					ew = errors.New("invalid write result")
-				}
+			if err := c.write(blk); err != nil {
+				return err
 			}
+		}
 
-			written += int64(nw)
+		switch {
+		case er == nil:
+		case errors.Is(er, io.EOF), errors.Is(er, io.ErrUnexpectedEOF):
+			return c.flushZeros()
+		default:
+			return er //nolint:wrapcheck
+		}
+	}
+}
 
-			if ew != nil {
-				err = ew
-				break
-			}
+// flushZeros writes the pending run of zeros, either as a hole or as regular data if it's too short.
+func (c *copier) flushZeros() error {
+	n := c.pendingZero
+	if n == 0 {
+		return nil
+	}
 
-			if nr != nw {
-				err = io.ErrShortWrite
-				break
-			}
+	c.pendingZero = 0
+
+	if n < c.minHoleSize {
+		if c.zeros == nil {
+			c.zeros = make([]byte, c.blockSize)
 		}
 
-		if er != nil {
-			if er != io.EOF {
-				err = er
+		for n > 0 {
+			chunk := min(n, int64(len(c.zeros)))
+			if err := c.write(c.zeros[0:chunk]); err != nil {
+				return err
 			}
 
-			break
+			n -= chunk
+		}
+
+		return nil
+	}
+
+	if c.file != nil {
+		if err := punchHole(c.file, c.written, n); err != nil {
+			return errors.Wrap(err, "unable to punch hole")
 		}
 	}
 
-	return written, err
+	if _, err := c.dst.Seek(n, io.SeekCurrent); err != nil {
+		return errors.Wrap(err, "seek error")
+	}
+
+	c.written += n
+
+	return nil
+}
+
+func (c *copier) write(b []byte) error {
+	nw, ew := c.dst.Write(b)
+	if nw < 0 || len(b) < nw {
+		nw = 0
+
+		if ew == nil {
+			ew = errors.New("invalid write result")
+		}
+	}
+
+	c.written += int64(nw)
+
+	if ew != nil {
+		return ew //nolint:wrapcheck
+	}
+
+	if nw != len(b) {
+		return io.ErrShortWrite
+	}
+
+	return nil
+}
+
+// Prepare prepares the provided file to hold holes. On Windows this marks the file as sparse,
+// which must happen before the file is extended, elsewhere this is a no-op.
+func Prepare(f *os.File) error {
+	return prepare(f)
 }
 
 func isAllZero(buf []byte) bool {
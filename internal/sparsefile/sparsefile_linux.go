@@ -0,0 +1,23 @@
+package sparsefile
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func prepare(*os.File) error {
+	return nil
+}
+
+// punchHole deallocates the provided range of the file, keeping its size.
+func punchHole(f *os.File, offset, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length) //nolint:gosec
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		// file system does not support punching holes, the caller will seek past the range instead.
+		return nil
+	}
+
+	return err //nolint:wrapcheck
+}
@@ -0,0 +1,17 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package sparsefile
+
+import (
+	"os"
+)
+
+func prepare(*os.File) error {
+	return nil
+}
+
+// punchHole is not supported, holes are created by seeking past them in pre-sized files.
+func punchHole(*os.File, int64, int64) error {
+	return nil
+}
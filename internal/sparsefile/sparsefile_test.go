@@ -2,6 +2,7 @@
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -110,3 +111,55 @@ type chunk struct {
 		}
 	}
 }
+
+func TestSparseCopyMinHoleSize(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("physical file sizes are only verified on linux")
+	}
+
+	dir := t.TempDir()
+
+	blk, err := stat.GetBlockSize(dir)
+	require.NoError(t, err)
+
+	// data, short run of zeros, data, long run of zeros, data
+	var src []byte
+
+	src = append(src, bytes.Repeat([]byte{1}, int(blk))...)
+	src = append(src, make([]byte, blk)...)
+	src = append(src, bytes.Repeat([]byte{2}, int(blk))...)
+	src = append(src, make([]byte, 64*blk)...)
+	src = append(src, bytes.Repeat([]byte{3}, int(blk)-7)...)
+
+	for _, tc := range []struct {
+		minHoleSize uint64
+		wantBlocks  uint64
+	}{
+		{0, 3},
+		{2 * blk, 4},
+		{128 * blk, 68},
+	} {
+		dst := filepath.Join(dir, fmt.Sprintf("dst-%v", tc.minHoleSize))
+
+		// existing contents of the file are replaced, including ranges that become holes.
+		require.NoError(t, os.WriteFile(dst, bytes.Repeat([]byte{0xff}, len(src)), 0o600))
+
+		df, err := os.OpenFile(dst, os.O_RDWR, 0)
+		require.NoError(t, err)
+
+		n, err := CopyWithMinHoleSize(df, bytes.NewReader(src), blk, tc.minHoleSize)
+		require.NoError(t, err)
+		require.EqualValues(t, len(src), n)
+		require.NoError(t, df.Close())
+
+		d, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		require.Equal(t, src, d)
+
+		alloc, err := stat.GetFileAllocSize(dst)
+		require.NoError(t, err)
+		require.Equal(t, tc.wantBlocks*blk, alloc, "min hole size %v", tc.minHoleSize)
+	}
+}
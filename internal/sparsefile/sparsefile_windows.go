@@ -0,0 +1,48 @@
+package sparsefile
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileZeroDataInformation corresponds to FILE_ZERO_DATA_INFORMATION.
+type fileZeroDataInformation struct {
+	FileOffset      int64
+	BeyondFinalZero int64
+}
+
+// prepare marks the file as sparse, without which NTFS allocates all ranges of the file.
+func prepare(f *os.File) error {
+	var bytesReturned uint32
+
+	err := windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_SET_SPARSE, nil, 0, nil, 0, &bytesReturned, nil)
+	if isNotSupported(err) {
+		return nil
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// punchHole deallocates the provided range of the file, keeping its size.
+func punchHole(f *os.File, offset, length int64) error {
+	var bytesReturned uint32
+
+	zd := fileZeroDataInformation{
+		FileOffset:      offset,
+		BeyondFinalZero: offset + length,
+	}
+
+	err := windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_SET_ZERO_DATA,
+		(*byte)(unsafe.Pointer(&zd)), uint32(unsafe.Sizeof(zd)), nil, 0, &bytesReturned, nil)
+	if isNotSupported(err) {
+		return nil
+	}
+
+	return err //nolint:wrapcheck
+}
+
+func isNotSupported(err error) bool {
+	return err == windows.ERROR_INVALID_FUNCTION || err == windows.ERROR_NOT_SUPPORTED //nolint:errorlint
+}
@@ -0,0 +1,262 @@
+// Package sshtunnel dials network connections through an SSH server, which allows reaching
+// repository servers and storage endpoints that are only accessible from behind a firewall.
+package sshtunnel
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("sshtunnel")
+
+const (
+	defaultPort = 22
+
+	defaultDialTimeout       = 30 * time.Second
+	defaultKeepAliveInterval = 30 * time.Second
+)
+
+// Options describes the SSH server used to tunnel connections.
+type Options struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username"`
+
+	// Keyfile is the path to the private key used to authenticate, must be absolute.
+	Keyfile string `json:"keyfile"`
+
+	// KnownHostsFile is the path to the known_hosts file used to verify the server,
+	// defaults to ~/.ssh/known_hosts.
+	KnownHostsFile string `json:"knownHostsFile,omitempty"`
+}
+
+func (o *Options) address() string {
+	port := o.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	return net.JoinHostPort(o.Host, strconv.Itoa(port))
+}
+
+func (o *Options) knownHostsFile() string {
+	if o.KnownHostsFile == "" {
+		d, _ := os.UserHomeDir()
+
+		return filepath.Join(d, ".ssh", "known_hosts")
+	}
+
+	return o.KnownHostsFile
+}
+
+// String returns the user@host:port representation of the tunnel.
+func (o *Options) String() string {
+	return o.Username + "@" + o.address()
+}
+
+func (o *Options) clientConfig() (*ssh.ClientConfig, error) {
+	if o.Host == "" || o.Username == "" {
+		return nil, errors.New("SSH tunnel host and username must be provided")
+	}
+
+	if !filepath.IsAbs(o.Keyfile) {
+		return nil, errors.New("SSH tunnel key file path must be absolute")
+	}
+
+	if !filepath.IsAbs(o.knownHostsFile()) {
+		return nil, errors.New("SSH tunnel known hosts path must be absolute")
+	}
+
+	keyData, err := os.ReadFile(o.Keyfile)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading private key file")
+	}
+
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing private key")
+	}
+
+	hostKeyCallback, err := knownhosts.New(o.knownHostsFile())
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading known hosts")
+	}
+
+	return &ssh.ClientConfig{
+		User:            o.Username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         defaultDialTimeout,
+	}, nil
+}
+
+// Tunnel dials connections through an SSH server, transparently reconnecting to the server
+// when the SSH connection is lost.
+type Tunnel struct {
+	opt    Options
+	config *ssh.ClientConfig
+
+	// KeepAliveInterval is the interval between keep-alive requests used to detect lost connections.
+	KeepAliveInterval time.Duration
+
+	mu sync.Mutex
+	// +checklocks:mu
+	client *ssh.Client
+	// +checklocks:mu
+	closed bool
+}
+
+// Open establishes the SSH connection described by the provided options.
+func Open(ctx context.Context, opt *Options) (*Tunnel, error) {
+	config, err := opt.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tunnel{
+		opt:               *opt,
+		config:            config,
+		KeepAliveInterval: defaultKeepAliveInterval,
+	}
+
+	if _, err := t.getClient(ctx); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// getClient returns the current SSH client, connecting to the server if there isn't one.
+func (t *Tunnel) getClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, errors.New("SSH tunnel is closed")
+	}
+
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", t.opt.address())
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to connect to SSH server %v", t.opt.address())
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.opt.address(), t.config)
+	if err != nil {
+		conn.Close() //nolint:errcheck
+
+		return nil, errors.Wrapf(err, "unable to establish SSH connection to %v", t.opt.address())
+	}
+
+	client := ssh.NewClient(c, chans, reqs)
+	t.client = client
+
+	log(ctx).Debugf("established SSH tunnel %v", &t.opt)
+
+	go t.keepAlive(context.WithoutCancel(ctx), client)
+
+	return client, nil
+}
+
+// keepAlive periodically checks that the SSH connection is alive and discards it
+// when it is lost, so that the next dial reconnects.
+func (t *Tunnel) keepAlive(ctx context.Context, client *ssh.Client) {
+	done := make(chan struct{})
+
+	go func() {
+		client.Wait() //nolint:errcheck
+		close(done)
+	}()
+
+	ticker := time.NewTicker(t.KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			t.discardClient(ctx, client)
+			return
+
+		case <-ticker.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				client.Close() //nolint:errcheck
+			}
+		}
+	}
+}
+
+// discardClient forgets the provided SSH client if it is still the current one.
+func (t *Tunnel) discardClient(ctx context.Context, client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.client == client {
+		t.client = nil
+
+		if !t.closed {
+			log(ctx).Infof("lost connection to SSH server %v, will reconnect", t.opt.address())
+		}
+	}
+}
+
+// DialContext establishes a connection to the provided address as seen from the SSH server.
+// If the SSH connection was lost, it is re-established before dialing.
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.DialContext(ctx, network, addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	// the connection may have been dropped without us noticing, reconnect and retry once.
+	client.Close() //nolint:errcheck
+	t.discardClient(ctx, client)
+
+	if client, err = t.getClient(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err = client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to dial %v through SSH tunnel", addr)
+	}
+
+	return conn, nil
+}
+
+// Close closes the SSH connection, connections dialed through the tunnel are closed as well.
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+
+	if t.client == nil {
+		return nil
+	}
+
+	err := t.client.Close()
+	t.client = nil
+
+	return errors.Wrap(err, "error closing SSH connection")
+}
@@ -0,0 +1,263 @@
+package sshtunnel_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/kopia/kopia/internal/sshtunnel"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+// testSSHServer is a minimal SSH server which only supports forwarding of TCP connections.
+type testSSHServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "direct-tcpip" {
+			nc.Reject(ssh.UnknownChannelType, "unsupported") //nolint:errcheck
+			continue
+		}
+
+		var payload struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+
+		if err := ssh.Unmarshal(nc.ExtraData(), &payload); err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error()) //nolint:errcheck
+			continue
+		}
+
+		target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error()) //nolint:errcheck
+			continue
+		}
+
+		ch, creqs, err := nc.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+
+		go ssh.DiscardRequests(creqs)
+
+		go func() {
+			io.Copy(ch, target) //nolint:errcheck
+			ch.Close()
+		}()
+
+		go func() {
+			io.Copy(target, ch) //nolint:errcheck
+			target.Close()
+		}()
+	}
+}
+
+// dropConnections simulates network failure by closing all SSH connections.
+func (s *testSSHServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.conns {
+		c.Close()
+	}
+
+	s.conns = nil
+}
+
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer c.Close()
+
+				io.Copy(c, c) //nolint:errcheck
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func setupServer(t *testing.T) (*testSSHServer, *sshtunnel.Options) {
+	t.Helper()
+
+	dir := testutil.TempDirectory(t)
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+
+	_, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	clientSigner, err := ssh.NewSignerFromKey(clientPriv)
+	require.NoError(t, err)
+
+	pemBlock, err := ssh.MarshalPrivateKey(clientPriv, "")
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(dir, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(pemBlock), 0o600))
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "tunnel-user" && string(key.Marshal()) == string(clientSigner.PublicKey().Marshal()) {
+				return &ssh.Permissions{}, nil
+			}
+
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &testSSHServer{listener: l, config: config}
+
+	t.Cleanup(func() {
+		l.Close()
+		s.dropConnections()
+	})
+
+	go s.serve()
+
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHostsFile,
+		[]byte(knownhosts.Line([]string{knownhosts.Normalize(l.Addr().String())}, hostSigner.PublicKey())+"\n"), 0o600))
+
+	addr := l.Addr().(*net.TCPAddr) //nolint:forcetypeassert
+
+	return s, &sshtunnel.Options{
+		Host:           "127.0.0.1",
+		Port:           addr.Port,
+		Username:       "tunnel-user",
+		Keyfile:        keyFile,
+		KnownHostsFile: knownHostsFile,
+	}
+}
+
+func verifyEcho(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	defer conn.Close()
+
+	_, err := conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestTunnel(t *testing.T) {
+	ctx := testlogging.Context(t)
+	srv, opt := setupServer(t)
+	echoAddr := startEchoServer(t)
+
+	tun, err := sshtunnel.Open(ctx, opt)
+	require.NoError(t, err)
+
+	defer tun.Close()
+
+	conn, err := tun.DialContext(ctx, "tcp", echoAddr)
+	require.NoError(t, err)
+	verifyEcho(t, conn)
+
+	// the tunnel reconnects after the SSH connection is lost.
+	srv.dropConnections()
+
+	conn, err = tun.DialContext(ctx, "tcp", echoAddr)
+	require.NoError(t, err)
+	verifyEcho(t, conn)
+
+	require.NoError(t, tun.Close())
+
+	_, err = tun.DialContext(ctx, "tcp", echoAddr)
+	require.Error(t, err)
+}
+
+func TestTunnel_InvalidCredentials(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, opt := setupServer(t)
+
+	badUser := *opt
+	badUser.Username = "someone-else"
+
+	_, err := sshtunnel.Open(ctx, &badUser)
+	require.Error(t, err)
+
+	// host key not in known hosts.
+	unknownHost := *opt
+	unknownHost.KnownHostsFile = filepath.Join(testutil.TempDirectory(t), "empty")
+	require.NoError(t, os.WriteFile(unknownHost.KnownHostsFile, nil, 0o600))
+
+	_, err = sshtunnel.Open(ctx, &unknownHost)
+	require.Error(t, err)
+
+	relativeKey := *opt
+	relativeKey.Keyfile = "id_ed25519"
+
+	_, err = sshtunnel.Open(ctx, &relativeKey)
+	require.Error(t, err)
+}
@@ -45,3 +45,16 @@ func GetBlockSize(path string) (uint64, error) {
 
 	return uint64(st.F_bsize), nil
 }
+
+// GetAvailableSpace returns the number of bytes available to unprivileged users
+// on the filesystem containing 'path'.
+func GetAvailableSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return uint64(st.F_bavail) * uint64(st.F_bsize), nil //nolint:gosec
+}
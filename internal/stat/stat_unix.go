@@ -46,3 +46,16 @@ func GetBlockSize(path string) (uint64, error) {
 
 	return uint64(st.Bsize), nil //nolint:unconvert,nolintlint
 }
+
+// GetAvailableSpace returns the number of bytes available to unprivileged users
+// on the filesystem containing 'path'.
+func GetAvailableSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+
+	err := syscall.Statfs(path, &st)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return uint64(st.Bavail) * uint64(st.Bsize), nil //nolint:gosec,unconvert,nolintlint
+}
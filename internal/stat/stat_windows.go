@@ -5,7 +5,11 @@
 // common stat commands.
 package stat
 
-import "errors"
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
 
 var errNotImplemented = errors.New("not implemented")
 
@@ -23,3 +27,20 @@ func GetFileAllocSize(fname string) (uint64, error) {
 func GetBlockSize(path string) (uint64, error) {
 	return 0, errNotImplemented
 }
+
+// GetAvailableSpace returns the number of bytes available to the current user
+// on the volume containing 'path'.
+func GetAvailableSpace(path string) (uint64, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	var avail uint64
+
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &avail, nil, nil); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return avail, nil
+}
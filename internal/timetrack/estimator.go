@@ -65,6 +65,11 @@ func (v Estimator) Completed(total float64) (totalTime time.Duration, speed floa
 	return dur, total / dur.Seconds()
 }
 
+// Elapsed returns the time elapsed since the start of the task.
+func (v Estimator) Elapsed() time.Duration {
+	return time.Since(v.startTime) //nolint:forbidigo
+}
+
 // Start returns an Estimator object.
 func Start() Estimator {
 	return Estimator{startTime: time.Now()} //nolint:forbidigo
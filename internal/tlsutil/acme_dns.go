@@ -0,0 +1,359 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+const (
+	dns01ChallengeType         = "dns-01"
+	dns01AccountKeyCacheKey    = "acme_account+key"
+	dns01CertCacheKeySuffix    = "+dns01"
+	dns01DefaultRenewBefore    = 30 * 24 * time.Hour
+	dns01RenewalCheckInterval  = time.Hour
+	dns01RenewalRetryInterval  = 10 * time.Minute
+	dns01ChallengeRecordPrefix = "_acme-challenge."
+)
+
+// DNS01Solver publishes and removes DNS TXT records used to complete ACME DNS-01 challenges.
+type DNS01Solver interface {
+	Present(ctx context.Context, recordName, value string) error
+	CleanUp(ctx context.Context, recordName, value string) error
+}
+
+// DNS01CertManager obtains and renews certificates from an ACME certificate authority
+// using DNS-01 challenges, which unlike HTTP-01 and TLS-ALPN-01 do not require the server
+// to be reachable from the internet and support wildcard names.
+type DNS01CertManager struct {
+	Client      *acme.Client
+	Domains     []string
+	Email       string
+	Cache       autocert.Cache
+	Solver      DNS01Solver
+	RenewBefore time.Duration // defaults to 30 days
+
+	mu sync.Mutex
+	// +checklocks:mu
+	cert *tls.Certificate
+}
+
+// GetCertificate returns the current certificate. It can be used as tls.Config.GetCertificate.
+func (m *DNS01CertManager) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cert == nil {
+		return nil, errors.New("certificate not available")
+	}
+
+	return m.cert, nil
+}
+
+// Start loads the cached certificate or obtains a new one and keeps renewing it in the background until the context is canceled.
+func (m *DNS01CertManager) Start(ctx context.Context) error {
+	if len(m.Domains) == 0 {
+		return errors.New("no domains provided")
+	}
+
+	cert, err := m.loadCachedCertificate(ctx)
+	if err != nil {
+		log(ctx).Debugf("no cached certificate: %v", err)
+	}
+
+	m.setCertificate(cert)
+
+	if m.needsRenewal(clock.Now()) {
+		if err := m.Renew(ctx); err != nil {
+			return err
+		}
+	}
+
+	go m.renewLoop(ctx)
+
+	return nil
+}
+
+func (m *DNS01CertManager) renewLoop(ctx context.Context) {
+	next := dns01RenewalCheckInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+
+		next = dns01RenewalCheckInterval
+
+		if !m.needsRenewal(clock.Now()) {
+			continue
+		}
+
+		if err := m.Renew(ctx); err != nil {
+			log(ctx).Errorf("unable to renew TLS certificate: %v", err)
+
+			next = dns01RenewalRetryInterval
+		}
+	}
+}
+
+func (m *DNS01CertManager) setCertificate(cert *tls.Certificate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cert = cert
+}
+
+// needsRenewal returns true if there is no certificate or it is about to expire.
+func (m *DNS01CertManager) needsRenewal(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cert == nil || m.cert.Leaf == nil {
+		return true
+	}
+
+	renewBefore := m.RenewBefore
+	if renewBefore == 0 {
+		renewBefore = dns01DefaultRenewBefore
+	}
+
+	return now.Add(renewBefore).After(m.cert.Leaf.NotAfter)
+}
+
+// Renew obtains a new certificate from the certificate authority and stores it in the cache.
+func (m *DNS01CertManager) Renew(ctx context.Context) error {
+	log(ctx).Infof("requesting TLS certificate for %v", m.Domains)
+
+	if err := m.ensureAccount(ctx); err != nil {
+		return err
+	}
+
+	order, err := m.Client.AuthorizeOrder(ctx, acme.DomainIDs(m.Domains...))
+	if err != nil {
+		return errors.Wrap(err, "unable to create order")
+	}
+
+	for _, u := range order.AuthzURLs {
+		if err := m.authorize(ctx, u); err != nil {
+			return err
+		}
+	}
+
+	if _, err := m.Client.WaitOrder(ctx, order.URI); err != nil {
+		return errors.Wrap(err, "order failed")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate certificate key")
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: m.Domains[0]},
+		DNSNames: m.Domains,
+	}, key)
+	if err != nil {
+		return errors.Wrap(err, "unable to create certificate request")
+	}
+
+	der, _, err := m.Client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return errors.Wrap(err, "unable to obtain certificate")
+	}
+
+	cert, err := certificateFromDER(der, key)
+	if err != nil {
+		return err
+	}
+
+	if err := m.storeCertificate(ctx, cert, key); err != nil {
+		return err
+	}
+
+	log(ctx).Infof("obtained TLS certificate for %v valid until %v", m.Domains, cert.Leaf.NotAfter)
+
+	m.setCertificate(cert)
+
+	return nil
+}
+
+// authorize completes DNS-01 challenge for a single authorization.
+func (m *DNS01CertManager) authorize(ctx context.Context, authzURL string) error {
+	z, err := m.Client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return errors.Wrap(err, "unable to get authorization")
+	}
+
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+
+	for _, c := range z.Challenges {
+		if c.Type == dns01ChallengeType {
+			chal = c
+			break
+		}
+	}
+
+	if chal == nil {
+		return errors.Errorf("certificate authority did not offer %v challenge for %v", dns01ChallengeType, z.Identifier.Value)
+	}
+
+	value, err := m.Client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return errors.Wrap(err, "unable to compute challenge record")
+	}
+
+	recordName := dns01ChallengeRecordName(z.Identifier.Value)
+
+	if err := m.Solver.Present(ctx, recordName, value); err != nil {
+		return errors.Wrapf(err, "unable to publish DNS record %v", recordName)
+	}
+
+	defer func() {
+		if err := m.Solver.CleanUp(ctx, recordName, value); err != nil {
+			log(ctx).Errorf("unable to remove DNS record %v: %v", recordName, err)
+		}
+	}()
+
+	if _, err := m.Client.Accept(ctx, chal); err != nil {
+		return errors.Wrap(err, "unable to accept challenge")
+	}
+
+	if _, err := m.Client.WaitAuthorization(ctx, z.URI); err != nil {
+		return errors.Wrapf(err, "authorization of %v failed", z.Identifier.Value)
+	}
+
+	return nil
+}
+
+// dns01ChallengeRecordName returns the name of TXT record for the domain, wildcard names use the record of the base domain.
+func dns01ChallengeRecordName(domain string) string {
+	return dns01ChallengeRecordPrefix + strings.TrimPrefix(domain, "*.")
+}
+
+// ensureAccount loads or generates the account key and registers the account.
+func (m *DNS01CertManager) ensureAccount(ctx context.Context) error {
+	if m.Client.Key != nil {
+		return nil
+	}
+
+	key, err := m.loadOrGenerateAccountKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.Client.Key = key
+
+	var contact []string
+	if m.Email != "" {
+		contact = []string{"mailto:" + m.Email}
+	}
+
+	if _, err := m.Client.Register(ctx, &acme.Account{Contact: contact}, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		m.Client.Key = nil
+		return errors.Wrap(err, "unable to register ACME account")
+	}
+
+	return nil
+}
+
+func (m *DNS01CertManager) loadOrGenerateAccountKey(ctx context.Context) (crypto.Signer, error) {
+	data, err := m.Cache.Get(ctx, dns01AccountKeyCacheKey)
+	if err == nil {
+		if b, _ := pem.Decode(data); b != nil {
+			key, err := x509.ParseECPrivateKey(b.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid account key")
+			}
+
+			return key, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate account key")
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal account key")
+	}
+
+	if err := m.Cache.Put(ctx, dns01AccountKeyCacheKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, errors.Wrap(err, "unable to store account key")
+	}
+
+	return key, nil
+}
+
+func (m *DNS01CertManager) certCacheKey() string {
+	return m.Domains[0] + dns01CertCacheKeySuffix
+}
+
+// storeCertificate stores PEM-encoded private key followed by certificate chain in the cache.
+func (m *DNS01CertManager) storeCertificate(ctx context.Context, cert *tls.Certificate, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal certificate key")
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	for _, c := range cert.Certificate {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+	}
+
+	return errors.Wrap(m.Cache.Put(ctx, m.certCacheKey(), data), "unable to store certificate")
+}
+
+func (m *DNS01CertManager) loadCachedCertificate(ctx context.Context) (*tls.Certificate, error) {
+	data, err := m.Cache.Get(ctx, m.certCacheKey())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read cached certificate")
+	}
+
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cached certificate")
+	}
+
+	return &cert, nil
+}
+
+func certificateFromDER(der [][]byte, key crypto.Signer) (*tls.Certificate, error) {
+	if len(der) == 0 {
+		return nil, errors.New("empty certificate chain")
+	}
+
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid certificate")
+	}
+
+	return &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package tlsutil_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/kopia/kopia/internal/tlsutil"
+)
+
+type noopDNS01Solver struct{}
+
+func (noopDNS01Solver) Present(context.Context, string, string) error { return nil }
+func (noopDNS01Solver) CleanUp(context.Context, string, string) error { return nil }
+
+func TestDNS01CertManager_CachedCertificate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// fake ACME server which fails all requests with non-retriable error.
+	acmeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer acmeServer.Close()
+
+	cache := autocert.DirCache(t.TempDir())
+
+	newManager := func() *tlsutil.DNS01CertManager {
+		return &tlsutil.DNS01CertManager{
+			Client:  &acme.Client{DirectoryURL: acmeServer.URL},
+			Domains: []string{"kopia.example.com"},
+			Cache:   cache,
+			Solver:  noopDNS01Solver{},
+		}
+	}
+
+	// no cached certificate, obtaining one fails.
+	require.Error(t, newManager().Start(ctx))
+
+	// cached certificate that is valid for a long time is used without contacting the CA.
+	require.NoError(t, cache.Put(ctx, "kopia.example.com+dns01", selfSignedECPEM(t, "kopia.example.com", 90*24*time.Hour)))
+
+	m := newManager()
+	require.NoError(t, m.Start(ctx))
+
+	c, err := m.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"kopia.example.com"}, c.Leaf.DNSNames)
+
+	// cached certificate that is about to expire must be renewed, which fails.
+	require.NoError(t, cache.Put(ctx, "kopia.example.com+dns01", selfSignedECPEM(t, "kopia.example.com", 24*time.Hour)))
+	require.Error(t, newManager().Start(ctx))
+}
+
+// selfSignedECPEM returns PEM-encoded private key followed by self-signed certificate.
+func selfSignedECPEM(t *testing.T, name string, valid time.Duration) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(valid),
+	}, &x509.Certificate{SerialNumber: big.NewInt(1)}, key.Public(), key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return append(
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+}
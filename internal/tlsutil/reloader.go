@@ -0,0 +1,95 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CertificateReloader serves TLS certificate loaded from PEM files and reloads it
+// when either of the files is modified, which allows certificates to be renewed
+// without restarting the server.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu sync.Mutex
+	// +checklocks:mu
+	cert *tls.Certificate
+	// +checklocks:mu
+	certModTime time.Time
+	// +checklocks:mu
+	keyModTime time.Time
+}
+
+// NewCertificateReloader loads the certificate from the provided PEM files.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reloadLocked(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, reloading it if the files have changed.
+// It can be used as tls.Config.GetCertificate.
+func (r *CertificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	ctx := context.Background()
+	if hello != nil {
+		ctx = hello.Context()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reloadLocked(ctx); err != nil {
+		// keep serving the previous certificate, the files may be in the middle of being replaced.
+		log(ctx).Errorf("unable to reload TLS certificate: %v", err)
+	}
+
+	return r.cert, nil
+}
+
+// +checklocks:r.mu
+func (r *CertificateReloader) reloadLocked(ctx context.Context) error {
+	cst, err := os.Stat(r.certFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to stat certificate file")
+	}
+
+	kst, err := os.Stat(r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to stat key file")
+	}
+
+	if r.cert != nil && cst.ModTime().Equal(r.certModTime) && kst.ModTime().Equal(r.keyModTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load TLS certificate")
+	}
+
+	if r.cert != nil {
+		log(ctx).Infof("reloaded TLS certificate from %v", r.certFile)
+	}
+
+	r.cert = &cert
+	r.certModTime = cst.ModTime()
+	r.keyModTime = kst.ModTime()
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package tlsutil_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/tlsutil"
+)
+
+func TestCertificateReloader(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeCert := func(modTime time.Time) []byte {
+		cert, key, err := tlsutil.GenerateServerCertificate(ctx, 2048, time.Hour, []string{"localhost"})
+		require.NoError(t, err)
+		require.NoError(t, tlsutil.WriteCertificateToFile(certFile, cert))
+		require.NoError(t, tlsutil.WritePrivateKeyToFile(keyFile, key))
+		require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+
+		return cert.Raw
+	}
+
+	_, err := tlsutil.NewCertificateReloader(certFile, keyFile)
+	require.Error(t, err)
+
+	t0 := time.Now().Add(-time.Hour)
+	cert1 := writeCert(t0)
+
+	r, err := tlsutil.NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+
+	c, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cert1, c.Certificate[0])
+
+	cert2 := writeCert(t0.Add(time.Minute))
+
+	c, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cert2, c.Certificate[0])
+
+	// invalid files keep serving the previous certificate.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+
+	c, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cert2, c.Certificate[0])
+}
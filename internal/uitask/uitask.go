@@ -68,7 +68,8 @@ type Info struct {
 type runningTaskInfo struct {
 	Info
 
-	maxLogMessages int // +checklocksignore
+	maxLogMessages int    // +checklocksignore
+	onProgress     func() // +checklocksignore
 
 	mu sync.Mutex
 	// +checklocks:mu
@@ -111,17 +112,19 @@ func (t *runningTaskInfo) cancel() {
 // ReportProgressInfo implements the Controller interface.
 func (t *runningTaskInfo) ReportProgressInfo(pi string) {
 	t.mu.Lock()
-	defer t.mu.Unlock()
-
 	t.ProgressInfo = pi
+	t.mu.Unlock()
+
+	t.onProgress()
 }
 
 // ReportCounters implements the Controller interface.
 func (t *runningTaskInfo) ReportCounters(c map[string]CounterValue) {
 	t.mu.Lock()
-	defer t.mu.Unlock()
-
 	t.Counters = cloneCounters(c)
+	t.mu.Unlock()
+
+	t.onProgress()
 }
 
 // info returns a copy of task information while holding a lock.
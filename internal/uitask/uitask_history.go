@@ -0,0 +1,215 @@
+package uitask
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/clock"
+)
+
+const (
+	historyFilePrefix = "task-"
+	historyFileSuffix = ".json"
+	historyDirMode    = 0o700
+)
+
+// HistoryOptions configures persistent history of finished tasks.
+type HistoryOptions struct {
+	Directory string        // directory where records of finished tasks and their logs are stored
+	MaxTasks  int           // maximum number of finished tasks to keep, 0 == unlimited
+	MaxAge    time.Duration // maximum age of finished tasks to keep, 0 == unlimited
+}
+
+// Query describes a filtered page of tasks returned by QueryTasks.
+type Query struct {
+	Kind   string // only return tasks of given kind
+	Status Status // only return tasks with given status
+	Offset int    // number of matching tasks to skip
+	Limit  int    // maximum number of tasks to return, 0 == unlimited
+}
+
+// persistedTask is the on-disk representation of a finished task.
+type persistedTask struct {
+	Info
+
+	SequenceNumber int               `json:"sequenceNumber"`
+	Logs           []json.RawMessage `json:"logs,omitempty"`
+}
+
+func historyFileName(dir string, sequenceNumber int) string {
+	return filepath.Join(dir, fmt.Sprintf("%v%016x%v", historyFilePrefix, sequenceNumber, historyFileSuffix))
+}
+
+// EnableHistory enables persisting of finished tasks with their logs in the provided directory
+// and loads tasks persisted by previous instances of the manager.
+func (m *Manager) EnableHistory(opt HistoryOptions) error {
+	if err := os.MkdirAll(opt.Directory, historyDirMode); err != nil {
+		return errors.Wrap(err, "unable to create task history directory")
+	}
+
+	entries, err := os.ReadDir(opt.Directory)
+	if err != nil {
+		return errors.Wrap(err, "unable to list task history directory")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), historyFilePrefix) || !strings.HasSuffix(e.Name(), historyFileSuffix) {
+			continue
+		}
+
+		pt, err := readPersistedTask(filepath.Join(opt.Directory, e.Name()))
+		if err != nil {
+			return err
+		}
+
+		info := pt.Info
+		info.sequenceNumber = pt.SequenceNumber
+
+		m.finished[info.TaskID] = &info
+
+		if pt.SequenceNumber > m.nextTaskID {
+			m.nextTaskID = pt.SequenceNumber
+		}
+	}
+
+	m.history = &opt
+
+	m.enforceRetentionLocked()
+
+	return nil
+}
+
+func readPersistedTask(fname string) (*persistedTask, error) {
+	b, err := os.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read task history")
+	}
+
+	pt := &persistedTask{}
+	if err := json.Unmarshal(b, pt); err != nil {
+		return nil, errors.Wrapf(err, "invalid task history file %v", fname)
+	}
+
+	return pt, nil
+}
+
+// persistTaskLocked writes the record of a finished task with its logs to the history directory,
+// after which the logs are only kept on disk.
+//
+// +checklocks:m.mu
+func (m *Manager) persistTaskLocked(i *Info) error {
+	var buf bytes.Buffer
+
+	if err := json.NewEncoder(&buf).Encode(persistedTask{*i, i.sequenceNumber, i.LogLines}); err != nil {
+		return errors.Wrap(err, "unable to encode task")
+	}
+
+	if err := atomicfile.Write(historyFileName(m.history.Directory, i.sequenceNumber), &buf); err != nil {
+		return errors.Wrap(err, "unable to write task history")
+	}
+
+	i.LogLines = nil
+
+	return nil
+}
+
+// persistedTaskLogLocked returns the log of a finished task stored in the history directory.
+//
+// +checklocks:m.mu
+func (m *Manager) persistedTaskLogLocked(i *Info) []json.RawMessage {
+	pt, err := readPersistedTask(historyFileName(m.history.Directory, i.sequenceNumber))
+	if err != nil {
+		return nil
+	}
+
+	return pt.Logs
+}
+
+// enforceRetentionLocked deletes oldest finished tasks above configured limits.
+//
+// +checklocks:m.mu
+func (m *Manager) enforceRetentionLocked() {
+	maxTasks := m.MaxFinishedTasks
+
+	var minEndTime time.Time
+
+	if m.history != nil {
+		maxTasks = m.history.MaxTasks
+
+		if m.history.MaxAge > 0 {
+			minEndTime = clock.Now().Add(-m.history.MaxAge)
+		}
+	}
+
+	var byAge []*Info
+
+	for _, v := range m.finished {
+		byAge = append(byAge, v)
+	}
+
+	// oldest first
+	sort.Slice(byAge, func(i, j int) bool {
+		return byAge[i].sequenceNumber < byAge[j].sequenceNumber
+	})
+
+	for i, v := range byAge {
+		tooMany := maxTasks > 0 && len(byAge)-i > maxTasks
+		tooOld := !minEndTime.IsZero() && v.EndTime != nil && v.EndTime.Before(minEndTime)
+
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		delete(m.finished, v.TaskID)
+
+		if m.history != nil {
+			os.Remove(historyFileName(m.history.Directory, v.sequenceNumber)) //nolint:errcheck
+		}
+	}
+}
+
+// QueryTasks returns a page of running and finished tasks matching the provided query,
+// most recent first, and the total number of matching tasks.
+func (m *Manager) QueryTasks(q Query) (tasks []Info, totalCount int) {
+	result := []Info{}
+
+	for _, t := range m.ListTasks() {
+		if q.Kind != "" && t.Kind != q.Kind {
+			continue
+		}
+
+		if q.Status != "" && t.Status != q.Status {
+			continue
+		}
+
+		result = append(result, t)
+	}
+
+	totalCount = len(result)
+
+	if q.Offset > 0 {
+		if q.Offset >= len(result) {
+			return []Info{}, totalCount
+		}
+
+		result = result[q.Offset:]
+	}
+
+	if q.Limit > 0 && q.Limit < len(result) {
+		result = result[0:q.Limit]
+	}
+
+	return result, totalCount
+}
@@ -20,6 +20,8 @@
 	maxWaitInterval       = time.Second
 )
 
+var log = logging.Module("uitask")
+
 // Manager manages UI tasks.
 type Manager struct {
 	mu sync.Mutex
@@ -29,6 +31,10 @@ type Manager struct {
 	running map[string]*runningTaskInfo
 	// +checklocks:mu
 	finished map[string]*Info
+	// +checklocks:mu
+	history *HistoryOptions // nil if task history is not persisted
+	// +checklocks:mu
+	listeners []Listener
 
 	MaxFinishedTasks      int // +checklocksignore
 	MaxLogMessagesPerTask int // +checklocksignore
@@ -44,6 +50,19 @@ type Controller interface {
 	ReportProgressInfo(text string)
 }
 
+// ChangeType describes the type of task state change reported to listeners.
+type ChangeType string
+
+// Supported change types.
+const (
+	TaskStarted  ChangeType = "task-started"
+	TaskProgress ChangeType = "task-progress"
+	TaskFinished ChangeType = "task-finished"
+)
+
+// Listener is notified about task state changes, it must not block.
+type Listener func(change ChangeType, info Info)
+
 // TaskFunc represents a task function.
 type TaskFunc func(ctx context.Context, ctrl Controller) error
 
@@ -58,6 +77,12 @@ func (m *Manager) Run(ctx context.Context, kind, description string, task TaskFu
 		maxLogMessages: m.MaxLogMessagesPerTask,
 	}
 
+	r.onProgress = func() {
+		m.notifyListeners(TaskProgress, r)
+	}
+
+	origCtx := ctx
+
 	if m.persistentLogs {
 		// log to regular file logger in addition to in-memory buffers.
 		ctx = logging.WithAdditionalLogger(ctx, r.loggerForModule)
@@ -67,13 +92,43 @@ func (m *Manager) Run(ctx context.Context, kind, description string, task TaskFu
 	}
 
 	m.startTask(r)
+	m.notifyListeners(TaskStarted, r)
 
 	err := task(ctx, r)
-	m.completeTask(r, err)
+
+	if perr := m.completeTask(r, err); perr != nil {
+		log(origCtx).Errorf("unable to persist task %v: %v", r.TaskID, perr)
+	}
+
+	m.notifyListeners(TaskFinished, r)
 
 	return err
 }
 
+// AddListener registers a function which will be notified about task state changes.
+func (m *Manager) AddListener(l Listener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.listeners = append(m.listeners, l)
+}
+
+func (m *Manager) notifyListeners(change ChangeType, r *runningTaskInfo) {
+	m.mu.Lock()
+	listeners := append([]Listener(nil), m.listeners...)
+	m.mu.Unlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	info := r.info()
+
+	for _, l := range listeners {
+		l(change, info)
+	}
+}
+
 // ListTasks lists all running and some recently-finished tasks up to configured limits.
 func (m *Manager) ListTasks() []Info {
 	m.mu.Lock()
@@ -159,6 +214,10 @@ func (m *Manager) TaskLog(taskID string) []json.RawMessage {
 	}
 
 	if f, ok := m.finished[taskID]; ok {
+		if m.history != nil && f.LogLines == nil {
+			return m.persistedTaskLogLocked(f)
+		}
+
 		return append([]json.RawMessage(nil), f.LogLines...)
 	}
 
@@ -209,7 +268,8 @@ func (m *Manager) startTask(r *runningTaskInfo) string {
 	return taskID
 }
 
-func (m *Manager) completeTask(r *runningTaskInfo, err error) {
+// completeTask marks the task as finished and returns an error if it could not be persisted.
+func (m *Manager) completeTask(r *runningTaskInfo, err error) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -240,22 +300,15 @@ func (m *Manager) completeTask(r *runningTaskInfo, err error) {
 	delete(m.running, r.TaskID)
 	m.finished[r.TaskID] = &r.Info
 
-	// delete oldest finished tasks up to configured limit.
-	for len(m.finished) > m.MaxFinishedTasks {
-		var (
-			oldestSequenceNumber int
-			oldestID             string
-		)
-
-		for _, v := range m.finished {
-			if oldestSequenceNumber == 0 || v.sequenceNumber < oldestSequenceNumber {
-				oldestSequenceNumber = v.sequenceNumber
-				oldestID = v.TaskID
-			}
-		}
+	var perr error
 
-		delete(m.finished, oldestID)
+	if m.history != nil {
+		perr = m.persistTaskLocked(&r.Info)
 	}
+
+	m.enforceRetentionLocked()
+
+	return perr
 }
 
 // NewManager creates new UI Task Manager.
@@ -385,3 +385,75 @@ func mustFindTask(t *testing.T, tasks []uitask.Info, tid string) uitask.Info {
 
 	return uitask.Info{}
 }
+
+func TestUITask_History(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	opt := uitask.HistoryOptions{Directory: dir, MaxTasks: 3}
+
+	m := uitask.NewManager(false)
+	require.NoError(t, m.EnableHistory(opt))
+
+	m.Run(ctx, "kind-a", "task-1", func(ctx context.Context, ctrl uitask.Controller) error {
+		log(ctx).Info("hello from task 1")
+		return nil
+	})
+	m.Run(ctx, "kind-b", "task-2", func(ctx context.Context, ctrl uitask.Controller) error {
+		return errors.New("some error")
+	})
+	m.Run(ctx, "kind-a", "task-3", func(ctx context.Context, ctrl uitask.Controller) error {
+		return nil
+	})
+
+	tid1 := getTaskID(t, m, "task-1")
+
+	// new manager loads tasks persisted by the previous one.
+	m2 := uitask.NewManager(false)
+	require.NoError(t, m2.EnableHistory(opt))
+
+	tsk, ok := m2.GetTask(tid1)
+	require.True(t, ok)
+	require.Equal(t, uitask.StatusSuccess, tsk.Status)
+	require.Equal(t, "hello from task 1", logText(m2.TaskLog(tid1)))
+
+	tsk2, ok := m2.GetTask(getTaskID(t, m2, "task-2"))
+	require.True(t, ok)
+	require.Equal(t, uitask.StatusFailed, tsk2.Status)
+	require.Equal(t, "some error", tsk2.ErrorMessage)
+
+	// filtering and pagination
+	tasks, total := m2.QueryTasks(uitask.Query{Kind: "kind-a"})
+	require.Equal(t, 2, total)
+	require.Equal(t, "task-3", tasks[0].Description)
+	require.Equal(t, "task-1", tasks[1].Description)
+
+	tasks, total = m2.QueryTasks(uitask.Query{Status: uitask.StatusFailed})
+	require.Equal(t, 1, total)
+	require.Equal(t, "task-2", tasks[0].Description)
+
+	tasks, total = m2.QueryTasks(uitask.Query{Offset: 1, Limit: 1})
+	require.Equal(t, 3, total)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "task-2", tasks[0].Description)
+
+	// task IDs are not reused after restart and oldest tasks are deleted above the limit.
+	var tid4 string
+
+	m2.Run(ctx, "kind-a", "task-4", func(ctx context.Context, ctrl uitask.Controller) error {
+		tid4 = ctrl.CurrentTaskID()
+		return nil
+	})
+
+	require.NotContains(t, []string{tid1, tsk2.TaskID}, tid4)
+
+	_, ok = m2.GetTask(tid1)
+	require.False(t, ok)
+
+	m3 := uitask.NewManager(false)
+	require.NoError(t, m3.EnableHistory(opt))
+	require.Len(t, m3.ListTasks(), 3)
+
+	_, ok = m3.GetTask(tid1)
+	require.False(t, ok)
+}
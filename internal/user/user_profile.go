@@ -30,6 +30,9 @@ type Profile struct {
 	Username            string `json:"username"`
 	PasswordHashVersion int    `json:"passwordHashVersion,omitempty"`
 	PasswordHash        []byte `json:"passwordHash"`
+
+	// Quota overrides server-wide default quotas for the user.
+	Quota *Quota `json:"quota,omitempty"`
 }
 
 // SetPassword changes the password for a user profile.
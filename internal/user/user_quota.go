@@ -0,0 +1,35 @@
+package user
+
+// Quota describes limits enforced by the repository server for a single user.
+//
+// In user profiles zero values inherit the server-wide defaults and negative values remove the limit.
+// In effective quotas returned by Apply zero means no limit.
+type Quota struct {
+	MaxRequestsPerSecond float64 `json:"maxRequestsPerSecond,omitempty"`
+	MaxStorageBytes      int64   `json:"maxStorageBytes,omitempty"`
+}
+
+// Apply returns the effective quota for a user with the provided overrides on top of server-wide defaults.
+func (q *Quota) Apply(defaults Quota) Quota {
+	result := defaults
+
+	if q != nil {
+		if q.MaxRequestsPerSecond != 0 {
+			result.MaxRequestsPerSecond = q.MaxRequestsPerSecond
+		}
+
+		if q.MaxStorageBytes != 0 {
+			result.MaxStorageBytes = q.MaxStorageBytes
+		}
+	}
+
+	if result.MaxRequestsPerSecond < 0 {
+		result.MaxRequestsPerSecond = 0
+	}
+
+	if result.MaxStorageBytes < 0 {
+		result.MaxStorageBytes = 0
+	}
+
+	return result
+}
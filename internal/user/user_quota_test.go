@@ -0,0 +1,21 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/user"
+)
+
+func TestQuotaApply(t *testing.T) {
+	defaults := user.Quota{MaxRequestsPerSecond: 10, MaxStorageBytes: 1000}
+
+	var none *user.Quota
+
+	require.Equal(t, defaults, none.Apply(defaults))
+	require.Equal(t, defaults, (&user.Quota{}).Apply(defaults))
+	require.Equal(t, user.Quota{MaxRequestsPerSecond: 5, MaxStorageBytes: 1000}, (&user.Quota{MaxRequestsPerSecond: 5}).Apply(defaults))
+	require.Equal(t, user.Quota{MaxRequestsPerSecond: 10, MaxStorageBytes: 0}, (&user.Quota{MaxStorageBytes: -1}).Apply(defaults))
+	require.Equal(t, user.Quota{MaxStorageBytes: 500}, (&user.Quota{MaxStorageBytes: 500}).Apply(user.Quota{MaxRequestsPerSecond: -1}))
+}
@@ -177,7 +177,8 @@ func NewWildcardMatcher(pattern string, options ...Option) (matcher *WildcardMat
 
 		case '[':
 			ch = p.read()
-			negatedSeq := ch == '!'
+			// both '!' and '^' negate a sequence, as in git.
+			negatedSeq := ch == '!' || ch == '^'
 
 			if negatedSeq {
 				ch = p.read()
@@ -77,6 +77,8 @@ func TestMatch(t *testing.T) {
 		// Sequences
 		{"ab[cd]", "abc", true, true},
 		{"ab[!de]", "abc", true, true},
+		{"ab[^de]", "abc", true, true},
+		{"ab[^de]", "abd", false, false},
 		{"[\\\\]", "\\", true, true},
 		{"[!\\\\]", "a", true, true},
 		{"[!\\\\]", "\\", false, false},
@@ -0,0 +1,323 @@
+// Package webhook implements outbound webhooks invoked by the server on events, with payloads rendered
+// from Go templates and signed using HMAC-SHA256.
+//
+// Webhook configurations are stored as manifests in the repository, so that all servers connected
+// to the same repository share them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// ManifestType is the type of manifest holding webhook configurations.
+const ManifestType = "serverWebhook"
+
+// NameLabel is the label of webhook manifests containing the name of the webhook.
+const NameLabel = "name"
+
+// Event types that can trigger webhooks.
+const (
+	EventSnapshotFinished = "snapshot-finished"
+	EventClientMissing    = "client-missing"
+	EventLowStorage       = "low-storage"
+	EventTest             = "test"
+)
+
+// AllEvents lists all event types webhooks can subscribe to.
+//
+//nolint:gochecknoglobals
+var AllEvents = []string{EventSnapshotFinished, EventClientMissing, EventLowStorage}
+
+// HTTP headers sent with each webhook request.
+const (
+	EventHeader     = "X-Kopia-Event"
+	TimestampHeader = "X-Kopia-Timestamp"
+	SignatureHeader = "X-Kopia-Signature"
+)
+
+// Defaults of webhook thresholds.
+const (
+	DefaultClientMissingAfter    = 24 * time.Hour
+	DefaultLowStorageFreePercent = 10
+)
+
+const defaultContentType = "application/json"
+
+// Webhook describes an outbound webhook.
+type Webhook struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+
+	// Template is a Go template rendering the request body from the Event, JSON-encoded event by default.
+	Template    string            `json:"template,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+
+	// Secret used to sign the request body, no signature is sent if empty.
+	Secret string `json:"secret,omitempty"`
+
+	// ClientMissingAfter is the time since a client was last seen after which it is reported missing.
+	ClientMissingAfter time.Duration `json:"clientMissingAfter,omitempty"`
+
+	// LowStorageFreePercent is the percentage of free storage capacity below which low storage is reported.
+	LowStorageFreePercent float64 `json:"lowStorageFreePercent,omitempty"`
+}
+
+// Validate checks the webhook configuration.
+func (w *Webhook) Validate() error {
+	if w.Name == "" {
+		return errors.New("name is required")
+	}
+
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("invalid URL %q", w.URL)
+	}
+
+	if len(w.Events) == 0 {
+		return errors.New("at least one event is required")
+	}
+
+	for _, ev := range w.Events {
+		if !slices.Contains(AllEvents, ev) {
+			return errors.Errorf("unknown event %q", ev)
+		}
+	}
+
+	if w.ClientMissingAfter < 0 {
+		return errors.New("client missing threshold must not be negative")
+	}
+
+	if w.LowStorageFreePercent < 0 || w.LowStorageFreePercent >= 100 { //nolint:mnd
+		return errors.New("low storage threshold must be between 0 and 100 percent")
+	}
+
+	if _, err := w.parseTemplate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Subscribes returns true if the webhook is invoked for a given event type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	return eventType == EventTest || slices.Contains(w.Events, eventType)
+}
+
+// ClientMissingThreshold returns the time since a client was last seen after which it is reported missing.
+func (w *Webhook) ClientMissingThreshold() time.Duration {
+	if w.ClientMissingAfter > 0 {
+		return w.ClientMissingAfter
+	}
+
+	return DefaultClientMissingAfter
+}
+
+// LowStorageThreshold returns the percentage of free storage capacity below which low storage is reported.
+func (w *Webhook) LowStorageThreshold() float64 {
+	if w.LowStorageFreePercent > 0 {
+		return w.LowStorageFreePercent
+	}
+
+	return DefaultLowStorageFreePercent
+}
+
+// Event describes an event delivered to webhooks and is the data passed to payload templates.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname,omitempty"` // host name of the server sending the event
+
+	// snapshot-finished
+	Source     *snapshot.SourceInfo `json:"source,omitempty"`
+	SnapshotID manifest.ID          `json:"snapshotID,omitempty"`
+	Error      string               `json:"error,omitempty"`
+
+	// client-missing
+	Client   string     `json:"client,omitempty"`
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+
+	// low-storage
+	Storage *StorageInfo `json:"storage,omitempty"`
+}
+
+// StorageInfo describes the capacity of repository storage.
+type StorageInfo struct {
+	SizeBytes   uint64  `json:"sizeBytes"`
+	FreeBytes   uint64  `json:"freeBytes"`
+	FreePercent float64 `json:"freePercent"`
+}
+
+//nolint:gochecknoglobals
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), errors.Wrap(err, "unable to marshal JSON")
+	},
+}
+
+func (w *Webhook) parseTemplate() (*template.Template, error) {
+	if w.Template == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	t, err := template.New(w.Name).Funcs(templateFuncs).Parse(w.Template)
+
+	return t, errors.Wrap(err, "invalid payload template")
+}
+
+// RenderPayload returns the request body for a given event.
+func (w *Webhook) RenderPayload(ev *Event) ([]byte, error) {
+	t, err := w.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	if t == nil {
+		b, err := json.Marshal(ev)
+		return b, errors.Wrap(err, "unable to marshal event")
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ev); err != nil {
+		return nil, errors.Wrap(err, "unable to render payload template")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Signature returns the signature of the request body sent at the provided Unix timestamp,
+// which is the hex-encoded HMAC-SHA256 of "<timestamp>.<body>" using the webhook secret.
+func Signature(secret string, timestamp int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10) + ".")) //nolint:errcheck
+	h.Write(body)                                           //nolint:errcheck
+
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Send delivers the event to the webhook.
+func (w *Webhook) Send(ctx context.Context, cli *http.Client, ev *Event) error {
+	body, err := w.RenderPayload(ev)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "error preparing webhook request")
+	}
+
+	contentType := w.ContentType
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	ts := ev.Time.Unix()
+
+	req.Header.Set(EventHeader, ev.Type)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Signature(w.Secret, ts, body))
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending webhook request")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.Errorf("webhook returned %v", resp.Status)
+	}
+
+	return nil
+}
+
+// List returns all webhooks stored in the repository ordered by name.
+func List(ctx context.Context, rep repo.Repository) ([]*Webhook, error) {
+	md, err := rep.FindManifests(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find webhooks")
+	}
+
+	result := []*Webhook{}
+
+	for _, m := range md {
+		w := &Webhook{}
+		if _, err := rep.GetManifest(ctx, m.ID, w); err != nil {
+			return nil, errors.Wrap(err, "unable to load webhook")
+		}
+
+		result = append(result, w)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// Set creates or replaces the webhook with the same name.
+func Set(ctx context.Context, w repo.RepositoryWriter, wh *Webhook) error {
+	if err := wh.Validate(); err != nil {
+		return err
+	}
+
+	_, err := w.ReplaceManifests(ctx, labels(wh.Name), wh)
+
+	return errors.Wrap(err, "unable to write webhook")
+}
+
+// Delete deletes the webhook with a given name and returns false if it did not exist.
+func Delete(ctx context.Context, w repo.RepositoryWriter, name string) (bool, error) {
+	md, err := w.FindManifests(ctx, labels(name))
+	if err != nil {
+		return false, errors.Wrap(err, "unable to find webhook")
+	}
+
+	for _, m := range md {
+		if err := w.DeleteManifest(ctx, m.ID); err != nil {
+			return false, errors.Wrap(err, "unable to delete webhook")
+		}
+	}
+
+	return len(md) > 0, nil
+}
+
+func labels(name string) map[string]string {
+	return map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+		NameLabel:             name,
+	}
+}
@@ -0,0 +1,116 @@
+package webhook_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestValidate(t *testing.T) {
+	valid := webhook.Webhook{Name: "a", URL: "https://example.com/hook", Events: []string{webhook.EventLowStorage}}
+	require.NoError(t, valid.Validate())
+
+	for _, mod := range []func(w *webhook.Webhook){
+		func(w *webhook.Webhook) { w.Name = "" },
+		func(w *webhook.Webhook) { w.URL = "ftp://example.com" },
+		func(w *webhook.Webhook) { w.URL = "example.com" },
+		func(w *webhook.Webhook) { w.Events = nil },
+		func(w *webhook.Webhook) { w.Events = []string{"bogus"} },
+		func(w *webhook.Webhook) { w.LowStorageFreePercent = 100 },
+		func(w *webhook.Webhook) { w.ClientMissingAfter = -time.Hour },
+		func(w *webhook.Webhook) { w.Template = "{{ .Bogus" },
+	} {
+		w := valid
+		mod(&w)
+		require.Error(t, w.Validate())
+	}
+}
+
+func TestRenderPayload(t *testing.T) {
+	ev := &webhook.Event{
+		Type:   webhook.EventSnapshotFinished,
+		Time:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source: &snapshot.SourceInfo{UserName: "alice", Host: "wonderland", Path: "/home"},
+	}
+
+	w := &webhook.Webhook{Name: "a"}
+
+	b, err := w.RenderPayload(ev)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type":"snapshot-finished","time":"2024-01-02T03:04:05Z","source":{"host":"wonderland","userName":"alice","path":"/home"}}`, string(b))
+
+	w.Template = `{"text":"{{.Type}} of {{.Source}}","source":{{json .Source.Path}}}`
+
+	b, err = w.RenderPayload(ev)
+	require.NoError(t, err)
+	require.Equal(t, `{"text":"snapshot-finished of alice@wonderland:/home","source":"/home"}`, string(b))
+}
+
+func TestSend(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	var (
+		gotBody   []byte
+		gotHeader http.Header
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header
+	}))
+	defer srv.Close()
+
+	wh := &webhook.Webhook{
+		Name:    "hook",
+		URL:     srv.URL,
+		Events:  []string{webhook.EventClientMissing},
+		Secret:  "secret",
+		Headers: map[string]string{"X-Extra": "extra"},
+	}
+
+	require.NoError(t, webhook.Set(ctx, env.RepositoryWriter, wh))
+
+	hooks, err := webhook.List(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, []*webhook.Webhook{wh}, hooks)
+	require.True(t, hooks[0].Subscribes(webhook.EventClientMissing))
+	require.True(t, hooks[0].Subscribes(webhook.EventTest))
+	require.False(t, hooks[0].Subscribes(webhook.EventLowStorage))
+
+	ev := &webhook.Event{Type: webhook.EventClientMissing, Time: time.Unix(1700000000, 0), Client: "bob@host"}
+	require.NoError(t, hooks[0].Send(ctx, http.DefaultClient, ev))
+
+	require.Equal(t, webhook.EventClientMissing, gotHeader.Get(webhook.EventHeader))
+	require.Equal(t, "application/json", gotHeader.Get("Content-Type"))
+	require.Equal(t, "extra", gotHeader.Get("X-Extra"))
+	require.Equal(t, "1700000000", gotHeader.Get(webhook.TimestampHeader))
+	require.Equal(t, webhook.Signature("secret", 1700000000, gotBody), gotHeader.Get(webhook.SignatureHeader))
+	require.NotEqual(t, webhook.Signature("other", 1700000000, gotBody), gotHeader.Get(webhook.SignatureHeader))
+
+	found, err := webhook.Delete(ctx, env.RepositoryWriter, "hook")
+	require.NoError(t, err)
+	require.True(t, found)
+
+	found, err = webhook.Delete(ctx, env.RepositoryWriter, "hook")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	hooks, err = webhook.List(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, hooks)
+
+	// failed delivery.
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	wh.URL = notFound.URL
+	require.Error(t, wh.Send(ctx, http.DefaultClient, ev))
+}
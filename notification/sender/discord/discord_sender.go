@@ -0,0 +1,85 @@
+// Package discord provides Discord notification support using webhooks.
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/notification/sender"
+)
+
+// ProviderType defines the type of the Discord notification provider.
+const ProviderType = "discord"
+
+// maxContentLength is the maximum length of the message content accepted by Discord.
+const maxContentLength = 2000
+
+type discordProvider struct {
+	opt Options
+}
+
+type discordMessage struct {
+	Content  string `json:"content"`
+	Username string `json:"username,omitempty"`
+}
+
+func (p *discordProvider) Send(ctx context.Context, msg *sender.Message) error {
+	content := []rune("**" + msg.Subject + "**\n\n" + msg.Body)
+	if len(content) > maxContentLength {
+		content = append(content[:maxContentLength-1], '…')
+	}
+
+	body, err := json.Marshal(discordMessage{
+		Content:  string(content),
+		Username: p.opt.Username,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error preparing discord notification")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opt.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "error preparing discord notification")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending discord notification")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	// Discord responds with 204 No Content on success.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return errors.Errorf("error sending discord notification: %v", resp.Status)
+	}
+
+	return nil
+}
+
+func (p *discordProvider) Summary() string {
+	return "Discord webhook"
+}
+
+// Format returns the message format, Discord messages are always rendered from plain-text templates.
+func (p *discordProvider) Format() string {
+	return sender.FormatPlainText
+}
+
+func init() {
+	sender.Register(ProviderType, func(ctx context.Context, options *Options) (sender.Provider, error) {
+		if err := options.ApplyDefaultsAndValidate(ctx); err != nil {
+			return nil, errors.Wrap(err, "invalid notification configuration")
+		}
+
+		return &discordProvider{
+			opt: *options,
+		}, nil
+	})
+}
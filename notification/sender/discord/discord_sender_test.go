@@ -0,0 +1,90 @@
+package discord_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/notification/sender"
+	"github.com/kopia/kopia/notification/sender/discord"
+)
+
+func TestDiscord(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	mux := http.NewServeMux()
+
+	var requestBodies []bytes.Buffer
+
+	mux.HandleFunc("/some-path", func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		io.Copy(&b, r.Body)
+
+		requestBodies = append(requestBodies, b)
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p, err := sender.GetSender(ctx, "my-profile", "discord", &discord.Options{
+		WebhookURL: server.URL + "/some-path",
+		Username:   "kopia",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Discord webhook", p.Summary())
+
+	require.NoError(t, p.Send(ctx, &sender.Message{Subject: "Test", Body: "This is a test."}))
+	require.NoError(t, p.Send(ctx, &sender.Message{Subject: "Long", Body: strings.Repeat("x", 5000)}))
+
+	require.Len(t, requestBodies, 2)
+
+	var body map[string]interface{}
+
+	require.NoError(t, json.NewDecoder(&requestBodies[0]).Decode(&body))
+	require.Equal(t, "**Test**\n\nThis is a test.", body["content"])
+	require.Equal(t, "kopia", body["username"])
+
+	// long messages are truncated to the maximum length accepted by Discord.
+	require.NoError(t, json.NewDecoder(&requestBodies[1]).Decode(&body))
+	require.Equal(t, 2000, utf8.RuneCountInString(body["content"].(string)))
+
+	p2, err := sender.GetSender(ctx, "my-profile", "discord", &discord.Options{
+		WebhookURL: server.URL + "/not-found-path",
+	})
+	require.NoError(t, err)
+	require.ErrorContains(t, p2.Send(ctx, &sender.Message{Subject: "Test", Body: "test"}), "error sending discord notification")
+}
+
+func TestDiscord_Invalid(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, err := sender.GetSender(ctx, "my-profile", "discord", &discord.Options{})
+	require.ErrorContains(t, err, "Webhook URL must be provided")
+}
+
+func TestMergeOptions(t *testing.T) {
+	var dst discord.Options
+
+	require.NoError(t, discord.MergeOptions(context.Background(), discord.Options{
+		WebhookURL: "https://discord.com/api/webhooks/1",
+		Username:   "a",
+	}, &dst, false))
+
+	require.NoError(t, discord.MergeOptions(context.Background(), discord.Options{
+		Username: "b",
+	}, &dst, true))
+
+	require.Equal(t, "https://discord.com/api/webhooks/1", dst.WebhookURL)
+	require.Equal(t, "b", dst.Username)
+}
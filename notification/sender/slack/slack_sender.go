@@ -0,0 +1,83 @@
+// Package slack provides Slack notification support using incoming webhooks.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/notification/sender"
+)
+
+// ProviderType defines the type of the Slack notification provider.
+const ProviderType = "slack"
+
+type slackProvider struct {
+	opt Options
+}
+
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+func (p *slackProvider) Send(ctx context.Context, msg *sender.Message) error {
+	body, err := json.Marshal(slackMessage{
+		Text:     "*" + msg.Subject + "*\n\n" + msg.Body,
+		Channel:  p.opt.Channel,
+		Username: p.opt.Username,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error preparing slack notification")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opt.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "error preparing slack notification")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error sending slack notification")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("error sending slack notification: %v", resp.Status)
+	}
+
+	return nil
+}
+
+func (p *slackProvider) Summary() string {
+	if p.opt.Channel != "" {
+		return fmt.Sprintf("Slack channel %q", p.opt.Channel)
+	}
+
+	return "Slack webhook"
+}
+
+// Format returns the message format, Slack messages are always rendered from plain-text templates.
+func (p *slackProvider) Format() string {
+	return sender.FormatPlainText
+}
+
+func init() {
+	sender.Register(ProviderType, func(ctx context.Context, options *Options) (sender.Provider, error) {
+		if err := options.ApplyDefaultsAndValidate(ctx); err != nil {
+			return nil, errors.Wrap(err, "invalid notification configuration")
+		}
+
+		return &slackProvider{
+			opt: *options,
+		}, nil
+	})
+}
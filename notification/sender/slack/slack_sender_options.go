@@ -0,0 +1,50 @@
+package slack
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// Options defines Slack notification sender options.
+type Options struct {
+	WebhookURL string `json:"webhookURL"`        // Slack incoming webhook URL
+	Channel    string `json:"channel,omitempty"` // optional channel override
+	Username   string `json:"username,omitempty"`
+}
+
+// ApplyDefaultsAndValidate applies default values and validates the configuration.
+func (o *Options) ApplyDefaultsAndValidate(ctx context.Context) error {
+	if o.WebhookURL == "" {
+		return errors.New("Webhook URL must be provided")
+	}
+
+	u, err := url.ParseRequestURI(o.WebhookURL)
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("invalid webhook URL scheme, must be http:// or https://")
+	}
+
+	return nil
+}
+
+// MergeOptions updates the destination options with the source options.
+func MergeOptions(ctx context.Context, src Options, dst *Options, isUpdate bool) error {
+	copyOrMerge(&dst.WebhookURL, src.WebhookURL, isUpdate)
+	copyOrMerge(&dst.Channel, src.Channel, isUpdate)
+	copyOrMerge(&dst.Username, src.Username, isUpdate)
+
+	return dst.ApplyDefaultsAndValidate(ctx)
+}
+
+func copyOrMerge[T comparable](dst *T, src T, isUpdate bool) {
+	var defaultT T
+
+	if !isUpdate || src != defaultT {
+		*dst = src
+	}
+}
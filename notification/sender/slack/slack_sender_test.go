@@ -0,0 +1,88 @@
+package slack_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/notification/sender"
+	"github.com/kopia/kopia/notification/sender/slack"
+)
+
+func TestSlack(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	mux := http.NewServeMux()
+
+	var requests []*http.Request
+	var requestBodies []bytes.Buffer
+
+	mux.HandleFunc("/some-path", func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		io.Copy(&b, r.Body)
+
+		requestBodies = append(requestBodies, b)
+		requests = append(requests, r)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p, err := sender.GetSender(ctx, "my-profile", "slack", &slack.Options{
+		WebhookURL: server.URL + "/some-path",
+		Channel:    "#backups",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Slack channel \"#backups\"", p.Summary())
+	require.Equal(t, sender.FormatPlainText, p.Format())
+
+	require.NoError(t, p.Send(ctx, &sender.Message{Subject: "Test", Body: "This is a test."}))
+
+	require.Len(t, requests, 1)
+	require.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+
+	var body map[string]interface{}
+
+	require.NoError(t, json.NewDecoder(&requestBodies[0]).Decode(&body))
+	require.Equal(t, "*Test*\n\nThis is a test.", body["text"])
+	require.Equal(t, "#backups", body["channel"])
+
+	p2, err := sender.GetSender(ctx, "my-profile", "slack", &slack.Options{
+		WebhookURL: server.URL + "/not-found-path",
+	})
+	require.NoError(t, err)
+	require.ErrorContains(t, p2.Send(ctx, &sender.Message{Subject: "Test", Body: "test"}), "error sending slack notification")
+}
+
+func TestSlack_Invalid(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, err := sender.GetSender(ctx, "my-profile", "slack", &slack.Options{})
+	require.ErrorContains(t, err, "Webhook URL must be provided")
+
+	_, err = sender.GetSender(ctx, "my-profile", "slack", &slack.Options{WebhookURL: "ftp://some-host/"})
+	require.ErrorContains(t, err, "invalid webhook URL scheme")
+}
+
+func TestMergeOptions(t *testing.T) {
+	var dst slack.Options
+
+	require.NoError(t, slack.MergeOptions(context.Background(), slack.Options{
+		WebhookURL: "https://hooks.slack.com/services/1",
+		Channel:    "#a",
+	}, &dst, false))
+
+	require.NoError(t, slack.MergeOptions(context.Background(), slack.Options{
+		Channel: "#b",
+	}, &dst, true))
+
+	require.Equal(t, "https://hooks.slack.com/services/1", dst.WebhookURL)
+	require.Equal(t, "#b", dst.Channel)
+}
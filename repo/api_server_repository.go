@@ -4,6 +4,8 @@
 	"context"
 
 	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/sshtunnel"
 )
 
 // APIServerInfo is remote repository configuration stored in local configuration.
@@ -16,6 +18,13 @@ type APIServerInfo struct {
 	BaseURL                             string `json:"url"`
 	TrustedServerCertificateFingerprint string `json:"serverCertFingerprint"`
 	LocalCacheKeyDerivationAlgorithm    string `json:"localCacheKeyDerivationAlgorithm,omitempty"`
+
+	// RepositoryName selects one of the repositories hosted by a multi-repository server,
+	// empty selects the default repository.
+	RepositoryName string `json:"repositoryName,omitempty"`
+
+	// SSHTunnel, when set, causes connections to the server to be established through an SSH tunnel.
+	SSHTunnel *sshtunnel.Options `json:"sshTunnel,omitempty"`
 }
 
 // ConnectAPIServer sets up repository connection to a particular API server.
@@ -33,5 +42,5 @@ func ConnectAPIServer(ctx context.Context, configFile string, si *APIServerInfo,
 		return errors.Wrap(err, "unable to write config file")
 	}
 
-	return verifyConnect(ctx, configFile, password)
+	return verifyConnect(ctx, configFile, password, false)
 }
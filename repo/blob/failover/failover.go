@@ -0,0 +1,327 @@
+// Package failover implements a wrapper around Storage that reads from mirror endpoints when the primary endpoint
+// is unavailable.
+package failover
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.Module("failover")
+
+// DefaultRetryInterval is the default interval after which an unhealthy endpoint is tried again.
+const DefaultRetryInterval = time.Minute
+
+// HealthCheckBlobID is the blob used to check health of endpoints, it does not need to exist.
+const HealthCheckBlobID blob.ID = "kopia.repository"
+
+// Options provides options for the failover wrapper.
+type Options struct {
+	// RetryInterval is the duration for which an endpoint that failed is skipped before being tried again.
+	RetryInterval time.Duration
+
+	// TimeNow returns the current time, defaults to clock.Now.
+	TimeNow func() time.Time
+}
+
+// EndpointStatus describes the health of a single storage endpoint.
+type EndpointStatus struct {
+	DisplayName string    `json:"displayName"`
+	Primary     bool      `json:"primary"`
+	Healthy     bool      `json:"healthy"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastFailure time.Time `json:"lastFailure,omitempty"`
+	RetryAfter  time.Time `json:"retryAfter,omitempty"`
+}
+
+// StatusProvider is implemented by storage that reports health of its endpoints.
+type StatusProvider interface {
+	EndpointStatus() []EndpointStatus
+	CheckHealth(ctx context.Context)
+}
+
+type endpoint struct {
+	st blob.Storage
+
+	// +checklocks:failoverStorage.mu
+	lastError error
+	// +checklocks:failoverStorage.mu
+	lastFailure time.Time
+	// +checklocks:failoverStorage.mu
+	retryAfter time.Time
+}
+
+// failoverStorage writes to the primary endpoint and reads from the first healthy endpoint.
+type failoverStorage struct {
+	opt       Options
+	endpoints []*endpoint
+
+	mu sync.Mutex
+}
+
+// candidates returns endpoints to try in order, healthy ones first.
+func (s *failoverStorage) candidates() []*endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.opt.TimeNow()
+
+	var healthy, unhealthy []*endpoint
+
+	for _, e := range s.endpoints {
+		if now.Before(e.retryAfter) {
+			unhealthy = append(unhealthy, e)
+		} else {
+			healthy = append(healthy, e)
+		}
+	}
+
+	// if all endpoints are unhealthy, try them anyway.
+	return append(healthy, unhealthy...)
+}
+
+func (s *failoverStorage) markHealthy(e *endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e.lastError = nil
+	e.retryAfter = time.Time{}
+}
+
+func (s *failoverStorage) markUnhealthy(ctx context.Context, e *endpoint, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.opt.TimeNow()
+
+	if e.lastError == nil {
+		log(ctx).Warnf("storage endpoint %v is unavailable, failing over: %v", e.st.DisplayName(), err)
+	}
+
+	e.lastError = err
+	e.lastFailure = now
+	e.retryAfter = now.Add(s.opt.RetryInterval)
+}
+
+// isAuthoritative returns true if the error is a valid response from a healthy endpoint.
+func isAuthoritative(ctx context.Context, err error) bool {
+	return err == nil || errors.Is(err, blob.ErrBlobNotFound) || errors.Is(err, blob.ErrInvalidRange) || ctx.Err() != nil
+}
+
+// read invokes the provided function on endpoints in order of health until it succeeds.
+// The function returns true if it produced partial results, in which case failover is not possible.
+func (s *failoverStorage) read(ctx context.Context, f func(st blob.Storage) (partial bool, err error)) error {
+	var lastErr error
+
+	for _, e := range s.candidates() {
+		partial, err := f(e.st)
+		if isAuthoritative(ctx, err) {
+			if ctx.Err() == nil {
+				s.markHealthy(e)
+			}
+
+			return err
+		}
+
+		s.markUnhealthy(ctx, e, err)
+
+		if partial {
+			return err
+		}
+
+		lastErr = err
+	}
+
+	return lastErr
+}
+
+func (s *failoverStorage) primary() blob.Storage {
+	return s.endpoints[0].st
+}
+
+func (s *failoverStorage) GetCapacity(ctx context.Context) (blob.Capacity, error) {
+	//nolint:wrapcheck
+	return s.primary().GetCapacity(ctx)
+}
+
+func (s *failoverStorage) IsReadOnly() bool {
+	return s.primary().IsReadOnly()
+}
+
+func (s *failoverStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	return s.read(ctx, func(st blob.Storage) (bool, error) {
+		output.Reset()
+
+		//nolint:wrapcheck
+		return false, st.GetBlob(ctx, id, offset, length, output)
+	})
+}
+
+func (s *failoverStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	var result blob.Metadata
+
+	err := s.read(ctx, func(st blob.Storage) (bool, error) {
+		var err error
+
+		result, err = st.GetMetadata(ctx, id)
+
+		//nolint:wrapcheck
+		return false, err
+	})
+
+	return result, err
+}
+
+func (s *failoverStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	var callbackErr error
+
+	err := s.read(ctx, func(st blob.Storage) (bool, error) {
+		partial := false
+
+		err := st.ListBlobs(ctx, prefix, func(bm blob.Metadata) error {
+			partial = true
+
+			if err := callback(bm); err != nil {
+				callbackErr = err
+				return err
+			}
+
+			return nil
+		})
+
+		if callbackErr != nil {
+			// errors returned by the callback are not endpoint failures.
+			return partial, nil
+		}
+
+		//nolint:wrapcheck
+		return partial, err
+	})
+
+	if callbackErr != nil {
+		return callbackErr
+	}
+
+	return err
+}
+
+func (s *failoverStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	//nolint:wrapcheck
+	return s.primary().PutBlob(ctx, id, data, opts)
+}
+
+func (s *failoverStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	//nolint:wrapcheck
+	return s.primary().DeleteBlob(ctx, id)
+}
+
+func (s *failoverStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, opts blob.ExtendOptions) error {
+	//nolint:wrapcheck
+	return s.primary().ExtendBlobRetention(ctx, id, opts)
+}
+
+func (s *failoverStorage) Close(ctx context.Context) error {
+	var firstErr error
+
+	for _, e := range s.endpoints {
+		if err := e.st.Close(ctx); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "error closing %v", e.st.DisplayName())
+		}
+	}
+
+	return firstErr
+}
+
+func (s *failoverStorage) FlushCaches(ctx context.Context) error {
+	for _, e := range s.endpoints {
+		if err := e.st.FlushCaches(ctx); err != nil {
+			return errors.Wrapf(err, "error flushing caches of %v", e.st.DisplayName())
+		}
+	}
+
+	return nil
+}
+
+func (s *failoverStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.primary().ConnectionInfo()
+}
+
+func (s *failoverStorage) DisplayName() string {
+	return s.primary().DisplayName()
+}
+
+// EndpointStatus returns the health of all endpoints, the primary endpoint is first.
+func (s *failoverStorage) EndpointStatus() []EndpointStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.opt.TimeNow()
+
+	var result []EndpointStatus
+
+	for i, e := range s.endpoints {
+		es := EndpointStatus{
+			DisplayName: e.st.DisplayName(),
+			Primary:     i == 0,
+			Healthy:     e.lastError == nil,
+			LastFailure: e.lastFailure,
+		}
+
+		if e.lastError != nil {
+			es.LastError = e.lastError.Error()
+
+			if now.Before(e.retryAfter) {
+				es.RetryAfter = e.retryAfter
+			}
+		}
+
+		result = append(result, es)
+	}
+
+	return result
+}
+
+// CheckHealth probes all endpoints and updates their health.
+func (s *failoverStorage) CheckHealth(ctx context.Context) {
+	for _, e := range s.endpoints {
+		_, err := e.st.GetMetadata(ctx, HealthCheckBlobID)
+		if isAuthoritative(ctx, err) {
+			s.markHealthy(e)
+		} else {
+			s.markUnhealthy(ctx, e, err)
+		}
+	}
+}
+
+// NewWrapper returns a Storage wrapper that writes to the primary storage and reads from the first healthy
+// endpoint, starting with the primary and followed by the mirrors in order. Endpoints that fail with errors
+// other than ErrBlobNotFound are skipped for the retry interval.
+func NewWrapper(primary blob.Storage, mirrors []blob.Storage, opt Options) blob.Storage {
+	if opt.RetryInterval <= 0 {
+		opt.RetryInterval = DefaultRetryInterval
+	}
+
+	if opt.TimeNow == nil {
+		opt.TimeNow = clock.Now
+	}
+
+	s := &failoverStorage{opt: opt}
+
+	for _, st := range append([]blob.Storage{primary}, mirrors...) {
+		s.endpoints = append(s.endpoints, &endpoint{st: st})
+	}
+
+	return s
+}
+
+var (
+	_ blob.Storage   = (*failoverStorage)(nil)
+	_ StatusProvider = (*failoverStorage)(nil)
+)
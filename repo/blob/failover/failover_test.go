@@ -0,0 +1,106 @@
+package failover_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/failover"
+)
+
+var errOutage = errors.New("provider outage")
+
+func TestFailover(t *testing.T) {
+	ctx := testlogging.Context(t)
+	ft := faketime.NewClockTimeWithOffset(0)
+
+	primaryData := blobtesting.DataMap{}
+	mirrorData := blobtesting.DataMap{}
+
+	primary := blobtesting.NewFaultyStorage(blobtesting.NewMapStorage(primaryData, nil, nil))
+	mirror := blobtesting.NewFaultyStorage(blobtesting.NewMapStorage(mirrorData, nil, nil))
+
+	st := failover.NewWrapper(primary, []blob.Storage{mirror}, failover.Options{
+		RetryInterval: time.Minute,
+		TimeNow:       ft.NowFunc(),
+	})
+
+	defer st.Close(ctx)
+
+	require.NoError(t, st.PutBlob(ctx, "b1", gather.FromSlice([]byte("primary")), blob.PutOptions{}))
+	require.Contains(t, primaryData, blob.ID("b1"))
+	require.NotContains(t, mirrorData, blob.ID("b1"), "mirrors are not written to")
+
+	mirrorData["b1"] = []byte("mirror")
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	require.NoError(t, st.GetBlob(ctx, "b1", 0, -1, &tmp))
+	require.Equal(t, []byte("primary"), tmp.ToByteSlice())
+
+	// not found on a healthy primary is not a reason to fail over.
+	require.ErrorIs(t, st.GetBlob(ctx, "b2", 0, -1, &tmp), blob.ErrBlobNotFound)
+
+	// primary outage - reads are served by the mirror.
+	primary.AddFault(blobtesting.MethodGetBlob).ErrorInstead(errOutage)
+
+	require.NoError(t, st.GetBlob(ctx, "b1", 0, -1, &tmp))
+	require.Equal(t, []byte("mirror"), tmp.ToByteSlice())
+
+	sp, ok := st.(failover.StatusProvider)
+	require.True(t, ok)
+
+	es := sp.EndpointStatus()
+	require.Len(t, es, 2)
+	require.True(t, es[0].Primary)
+	require.False(t, es[0].Healthy)
+	require.Contains(t, es[0].LastError, "provider outage")
+	require.True(t, es[1].Healthy)
+
+	// unhealthy primary is skipped until the retry interval elapses.
+	primaryCalls := primary.NumCalls(blobtesting.MethodGetBlob)
+
+	require.NoError(t, st.GetBlob(ctx, "b1", 0, -1, &tmp))
+	require.Equal(t, []byte("mirror"), tmp.ToByteSlice())
+	require.Equal(t, primaryCalls, primary.NumCalls(blobtesting.MethodGetBlob))
+
+	ft.Advance(2 * time.Minute)
+
+	require.NoError(t, st.GetBlob(ctx, "b1", 0, -1, &tmp))
+	require.Equal(t, []byte("primary"), tmp.ToByteSlice())
+	require.True(t, sp.EndpointStatus()[0].Healthy)
+
+	// listing fails over when no items were returned.
+	primary.AddFault(blobtesting.MethodListBlobs).ErrorInstead(errOutage)
+
+	var listed []blob.ID
+
+	require.NoError(t, st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+		listed = append(listed, bm.BlobID)
+		return nil
+	}))
+	require.Equal(t, []blob.ID{"b1"}, listed)
+
+	// health check probes all endpoints.
+	mirror.AddFault(blobtesting.MethodGetMetadata).ErrorInstead(errOutage)
+	sp.CheckHealth(ctx)
+
+	es = sp.EndpointStatus()
+	require.True(t, es[0].Healthy)
+	require.False(t, es[1].Healthy)
+
+	// all endpoints failing returns the last error.
+	primary.AddFault(blobtesting.MethodGetMetadata).ErrorInstead(errOutage)
+	mirror.AddFault(blobtesting.MethodGetMetadata).ErrorInstead(errOutage)
+
+	_, err := st.GetMetadata(ctx, "b1")
+	require.ErrorIs(t, err, errOutage)
+}
@@ -0,0 +1,219 @@
+// Package layout implements a wrapper around blob.Storage that maps blob IDs to custom object names,
+// so that repositories can be stored in buckets with naming or prefix policies.
+package layout
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// CentralBlobPrefix is the prefix of central blobs, such as 'kopia.repository', which are always stored
+// under their own names, so that they can be found before the layout is known.
+const CentralBlobPrefix = "kopia."
+
+const (
+	shardSeparator = "/"
+	maxShards      = 5
+	maxShardLength = 8
+)
+
+// Parameters describes how blob IDs are mapped to object names in the underlying storage.
+type Parameters struct {
+	// Prefix is prepended to names of all blobs other than central blobs.
+	Prefix string `json:"prefix,omitempty"`
+
+	// Shards is the list of lengths of leading blob ID segments which are separated using '/'.
+	// For example [1,3] stores blob 'p0123abcd' as 'p/012/3abcd'.
+	Shards []int `json:"shards,omitempty"`
+}
+
+// IsDefault returns true if the parameters describe the default flat layout.
+func (p *Parameters) IsDefault() bool {
+	return p == nil || (p.Prefix == "" && len(p.Shards) == 0)
+}
+
+// Validate validates the layout parameters.
+func (p *Parameters) Validate() error {
+	if strings.HasPrefix(p.Prefix, shardSeparator) {
+		return errors.Errorf("layout prefix must not start with %q", shardSeparator)
+	}
+
+	if strings.HasPrefix(p.Prefix, CentralBlobPrefix) {
+		return errors.Errorf("layout prefix must not start with %q", CentralBlobPrefix)
+	}
+
+	if len(p.Shards) > maxShards {
+		return errors.Errorf("too many shards, at most %v are supported", maxShards)
+	}
+
+	for _, s := range p.Shards {
+		if s <= 0 || s > maxShardLength {
+			return errors.Errorf("invalid shard length %v, must be between 1 and %v", s, maxShardLength)
+		}
+	}
+
+	return nil
+}
+
+func (p *Parameters) String() string {
+	if p.IsDefault() {
+		return "flat"
+	}
+
+	var shards []string
+
+	for _, s := range p.Shards {
+		shards = append(shards, strconv.Itoa(s))
+	}
+
+	return fmt.Sprintf("prefix %q, shards [%v]", p.Prefix, strings.Join(shards, ","))
+}
+
+// ParseShards parses comma-separated list of shard lengths, such as '1,3'.
+func ParseShards(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var result []int
+
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, errors.Errorf("invalid shard length %q", part)
+		}
+
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// ObjectName returns the name under which the provided blob is stored in the underlying storage.
+func (p *Parameters) ObjectName(id blob.ID) blob.ID {
+	if strings.HasPrefix(string(id), CentralBlobPrefix) {
+		return id
+	}
+
+	return p.layoutName(id)
+}
+
+// layoutName returns the name of the blob (or a prefix of blob IDs) according to the layout.
+func (p *Parameters) layoutName(id blob.ID) blob.ID {
+	var sb strings.Builder
+
+	sb.WriteString(p.Prefix)
+
+	rest := string(id)
+
+	for _, size := range p.Shards {
+		if len(rest) <= size {
+			break
+		}
+
+		sb.WriteString(rest[0:size])
+		sb.WriteString(shardSeparator)
+
+		rest = rest[size:]
+	}
+
+	sb.WriteString(rest)
+
+	return blob.ID(sb.String())
+}
+
+// blobIDFromObjectName converts the name of the object in the underlying storage to blob ID.
+func (p *Parameters) blobIDFromObjectName(name blob.ID) (blob.ID, bool) {
+	s, ok := strings.CutPrefix(string(name), p.Prefix)
+	if !ok {
+		return "", false
+	}
+
+	return blob.ID(strings.ReplaceAll(s, shardSeparator, "")), true
+}
+
+type layoutStorage struct {
+	blob.Storage
+
+	params Parameters
+}
+
+func (s *layoutStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	//nolint:wrapcheck
+	return s.Storage.GetBlob(ctx, s.params.ObjectName(id), offset, length, output)
+}
+
+func (s *layoutStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	m, err := s.Storage.GetMetadata(ctx, s.params.ObjectName(id))
+	if err != nil {
+		//nolint:wrapcheck
+		return blob.Metadata{}, err
+	}
+
+	m.BlobID = id
+
+	return m, nil
+}
+
+func (s *layoutStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes, opts blob.PutOptions) error {
+	//nolint:wrapcheck
+	return s.Storage.PutBlob(ctx, s.params.ObjectName(id), data, opts)
+}
+
+func (s *layoutStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	//nolint:wrapcheck
+	return s.Storage.DeleteBlob(ctx, s.params.ObjectName(id))
+}
+
+func (s *layoutStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, opts blob.ExtendOptions) error {
+	//nolint:wrapcheck
+	return s.Storage.ExtendBlobRetention(ctx, s.params.ObjectName(id), opts)
+}
+
+func (s *layoutStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	// central blobs are stored under their own names.
+	centralPrefix := blob.ID("")
+
+	switch {
+	case strings.HasPrefix(string(prefix), CentralBlobPrefix):
+		centralPrefix = prefix
+	case strings.HasPrefix(CentralBlobPrefix, string(prefix)):
+		centralPrefix = CentralBlobPrefix
+	}
+
+	if centralPrefix != "" {
+		if err := s.Storage.ListBlobs(ctx, centralPrefix, func(m blob.Metadata) error {
+			if strings.Contains(string(m.BlobID), shardSeparator) {
+				return nil
+			}
+
+			return callback(m)
+		}); err != nil {
+			//nolint:wrapcheck
+			return err
+		}
+	}
+
+	//nolint:wrapcheck
+	return s.Storage.ListBlobs(ctx, s.params.layoutName(prefix), func(m blob.Metadata) error {
+		id, ok := s.params.blobIDFromObjectName(m.BlobID)
+		if !ok || !strings.HasPrefix(string(id), string(prefix)) || strings.HasPrefix(string(id), CentralBlobPrefix) {
+			return nil
+		}
+
+		m.BlobID = id
+
+		return callback(m)
+	})
+}
+
+// NewWrapper returns a Storage wrapper that stores blobs according to the provided layout.
+func NewWrapper(wrapped blob.Storage, params Parameters) blob.Storage {
+	return &layoutStorage{Storage: wrapped, params: params}
+}
@@ -0,0 +1,91 @@
+package layout_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/layout"
+)
+
+func TestLayoutStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	for _, p := range []layout.Parameters{
+		{Prefix: "data/"},
+		{Shards: []int{1, 3}},
+		{Prefix: "kopia-", Shards: []int{2}},
+	} {
+		t.Run(p.String(), func(t *testing.T) {
+			st := layout.NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), p)
+			blobtesting.VerifyStorage(ctx, t, st, blob.PutOptions{})
+		})
+	}
+}
+
+func TestLayoutObjectNames(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	p := layout.Parameters{Prefix: "backups/kopia/", Shards: []int{1, 3}}
+	st := layout.NewWrapper(blobtesting.NewMapStorage(data, nil, nil), p)
+
+	for _, id := range []blob.ID{"kopia.repository", "kopia.blobcfg", "p0123456789", "q0123", "xn0_1"} {
+		require.NoError(t, st.PutBlob(ctx, id, gather.FromSlice([]byte{1}), blob.PutOptions{}))
+	}
+
+	var names []string
+
+	for k := range data {
+		names = append(names, string(k))
+	}
+
+	sort.Strings(names)
+
+	require.Equal(t, []string{
+		"backups/kopia/p/012/3456789",
+		"backups/kopia/q/012/3",
+		"backups/kopia/x/n0_/1",
+		"kopia.blobcfg",
+		"kopia.repository",
+	}, names)
+
+	verifyList := func(prefix blob.ID, want ...blob.ID) {
+		t.Helper()
+
+		bms, err := blob.ListAllBlobs(ctx, st, prefix)
+		require.NoError(t, err)
+		require.ElementsMatch(t, want, blob.IDsFromMetadata(bms))
+	}
+
+	verifyList("", "kopia.repository", "kopia.blobcfg", "p0123456789", "q0123", "xn0_1")
+	verifyList("k", "kopia.repository", "kopia.blobcfg")
+	verifyList("kopia.r", "kopia.repository")
+	verifyList("p", "p0123456789")
+	verifyList("p01234", "p0123456789")
+	verifyList("q0", "q0123")
+	verifyList("y")
+
+	bm, err := st.GetMetadata(ctx, "q0123")
+	require.NoError(t, err)
+	require.Equal(t, blob.ID("q0123"), bm.BlobID)
+}
+
+func TestLayoutValidate(t *testing.T) {
+	require.NoError(t, (&layout.Parameters{Prefix: "a/b/", Shards: []int{1, 3}}).Validate())
+	require.Error(t, (&layout.Parameters{Prefix: "/a"}).Validate())
+	require.Error(t, (&layout.Parameters{Prefix: "kopia.x"}).Validate())
+	require.Error(t, (&layout.Parameters{Shards: []int{0}}).Validate())
+	require.Error(t, (&layout.Parameters{Shards: []int{1, 1, 1, 1, 1, 1}}).Validate())
+
+	shards, err := layout.ParseShards("1, 3")
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 3}, shards)
+
+	_, err = layout.ParseShards("1,x")
+	require.Error(t, err)
+}
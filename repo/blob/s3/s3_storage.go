@@ -257,6 +257,8 @@ func (s *s3Storage) ListBlobs(ctx context.Context, prefix blob.ID, callback func
 
 	oi := s.cli.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{
 		Prefix: s.getObjectNameString(prefix),
+		// blobs stored using sharded layouts have names containing '/'.
+		Recursive: true,
 	})
 	for o := range oi {
 		if err := o.Err; err != nil {
@@ -14,6 +14,7 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/encryption"
+	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/object"
 )
 
@@ -52,7 +53,7 @@ func (s *formatSpecificTestSuite) TestExtendBlobRetention(t *testing.T) {
 	blobsBefore, err := blob.ListAllBlobs(ctx, env.RepositoryWriter.BlobStorage(), "")
 	require.NoError(t, err)
 
-	if got, want := len(blobsBefore), 4; got != want {
+	if got, want := len(blobsBefore), 4+2*format.FormatBlobReplicaCount; got != want {
 		t.Fatalf("unexpected number of blobs after writing: %v", blobsBefore)
 	}
 
@@ -115,7 +116,7 @@ func (s *formatSpecificTestSuite) TestExtendBlobRetentionUnsupported(t *testing.
 		t.Fatal(err)
 	}
 
-	if got, want := len(blobsBefore), 4; got != want {
+	if got, want := len(blobsBefore), 4+2*format.FormatBlobReplicaCount; got != want {
 		t.Fatalf("unexpected number of blobs after writing: %v", blobsBefore)
 	}
 
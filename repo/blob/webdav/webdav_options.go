@@ -1,6 +1,7 @@
 package webdav
 
 import (
+	"github.com/kopia/kopia/internal/sshtunnel"
 	"github.com/kopia/kopia/repo/blob/sharded"
 	"github.com/kopia/kopia/repo/blob/throttling"
 )
@@ -13,6 +14,9 @@ type Options struct {
 	TrustedServerCertificateFingerprint string `json:"trustedServerCertificateFingerprint,omitempty"`
 	AtomicWrites                        bool   `json:"atomicWrites"`
 
+	// SSHTunnel, when set, causes connections to the WebDAV server to be established through an SSH tunnel.
+	SSHTunnel *sshtunnel.Options `json:"sshTunnel,omitempty"`
+
 	sharded.Options
 	throttling.Limits
 }
@@ -17,6 +17,7 @@
 
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/internal/sshtunnel"
 	"github.com/kopia/kopia/internal/tlsutil"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
@@ -37,6 +38,8 @@
 type davStorage struct {
 	sharded.Storage
 	blob.DefaultProviderImplementation
+
+	tunnel *sshtunnel.Tunnel
 }
 
 type davStorageImpl struct {
@@ -230,6 +233,14 @@ func (d *davStorage) ConnectionInfo() blob.ConnectionInfo {
 	}
 }
 
+func (d *davStorage) Close(ctx context.Context) error {
+	if d.tunnel == nil {
+		return nil
+	}
+
+	return errors.Wrap(d.tunnel.Close(), "error closing SSH tunnel")
+}
+
 func (d *davStorage) DisplayName() string {
 	o := d.Storage.Impl.(*davStorageImpl).Options //nolint:forcetypeassert
 	return fmt.Sprintf("WebDAV: %v", o.URL)
@@ -264,15 +275,34 @@ func New(ctx context.Context, opts *Options, isCreate bool) (blob.Storage, error
 	// Since we're handling encrypted data, there's no point compressing it server-side.
 	cli.SetHeader("Accept-Encoding", "identity")
 
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+
 	if opts.TrustedServerCertificateFingerprint != "" {
-		cli.SetTransport(tlsutil.TransportTrustingSingleCertificate(opts.TrustedServerCertificateFingerprint))
+		transport.TLSClientConfig = tlsutil.TLSConfigTrustingSingleCertificate(opts.TrustedServerCertificateFingerprint)
 	}
 
+	var tunnel *sshtunnel.Tunnel
+
+	if opts.SSHTunnel != nil {
+		t, err := sshtunnel.Open(ctx, opts.SSHTunnel)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to open SSH tunnel")
+		}
+
+		tunnel = t
+		transport.DialContext = tunnel.DialContext
+		// proxies configured in the environment are not reachable through the tunnel.
+		transport.Proxy = nil
+	}
+
+	cli.SetTransport(transport)
+
 	s := retrying.NewWrapper(&davStorage{
 		Storage: sharded.New(&davStorageImpl{
 			Options: *opts,
 			cli:     cli,
 		}, "", opts.Options, isCreate),
+		tunnel: tunnel,
 	})
 
 	return s, nil
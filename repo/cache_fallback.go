@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// cacheProbeSize is the size of the file written to verify that the cache directory has room for new entries.
+const cacheProbeSize = 64 << 10
+
+// checkCacheDirectory verifies that the cache directory exists or can be created and that files can be written to it.
+func checkCacheDirectory(dir string) error {
+	if err := os.MkdirAll(dir, cache.DirMode); err != nil {
+		return errors.Wrap(err, "unable to create cache directory")
+	}
+
+	f, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return errors.Wrap(err, "cache directory is not writable")
+	}
+
+	defer os.Remove(f.Name()) //nolint:errcheck
+
+	_, err = f.Write(make([]byte, cacheProbeSize))
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return errors.Wrap(err, "unable to write to cache directory")
+}
+
+// withMemoryCacheFallback returns caching options which use memory-bounded caches when the cache directory
+// is missing and can't be created, read-only or full.
+func withMemoryCacheFallback(ctx context.Context, opt *content.CachingOptions) *content.CachingOptions {
+	if opt == nil || opt.CacheDirectory == "" {
+		return opt
+	}
+
+	err := checkCacheDirectory(opt.CacheDirectory)
+	if err == nil {
+		return opt
+	}
+
+	log(ctx).Warnf("Cache directory %v is not usable, falling back to in-memory caches: %v", opt.CacheDirectory, err)
+
+	opt = opt.CloneOrDefault()
+	opt.CacheDirectory = ""
+	opt.MemoryFallbackReason = err.Error()
+
+	return opt
+}
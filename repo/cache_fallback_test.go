@@ -0,0 +1,49 @@
+package repo_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestOpenWithUnusableCacheDirectory(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	data := []byte("some data")
+	oid := writeObject(ctx, t, env.RepositoryWriter, data, "test")
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	// the cache directory can't be created below a regular file.
+	notADir := filepath.Join(testutil.TempDirectory(t), "file")
+	require.NoError(t, os.WriteFile(notADir, nil, 0o600))
+
+	require.NoError(t, repo.SetCachingOptions(ctx, env.ConfigFile(), &content.CachingOptions{
+		CacheDirectory:         filepath.Join(notADir, "cache"),
+		ContentCacheSizeBytes:  10 << 20,
+		MetadataCacheSizeBytes: 10 << 20,
+	}))
+
+	_, err := repo.Open(ctx, env.ConfigFile(), env.Password, &repo.Options{})
+	require.Error(t, err)
+
+	r, err := repo.Open(ctx, env.ConfigFile(), env.Password, &repo.Options{FallbackToMemoryCache: true})
+	require.NoError(t, err)
+
+	defer r.Close(ctx)
+
+	st := r.(repo.DirectRepository).ContentReader().CacheStats()
+	require.Contains(t, st.MemoryFallbackReason, "unable to create cache directory")
+	require.NotEmpty(t, st.Caches)
+
+	// reads are served through in-memory caches.
+	for range 2 {
+		verify(ctx, t, r, oid, data, "test")
+	}
+}
@@ -14,6 +14,10 @@
 	HeaderZstdBetterCompression HeaderID = 0x1102
 	HeaderZstdBestCompression   HeaderID = 0x1103
 
+	// HeaderZstdDictionary is used for contents compressed with zstd using one of the repository
+	// compression dictionaries, it's not globally registered since dictionaries are per-repository.
+	HeaderZstdDictionary HeaderID = 0x1110
+
 	headerS2Default   HeaderID = 0x1200
 	headerS2Better    HeaderID = 0x1201
 	headerS2Parallel4 HeaderID = 0x1202
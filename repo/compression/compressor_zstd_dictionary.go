@@ -0,0 +1,190 @@
+package compression
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/iocopy"
+)
+
+const (
+	// MinZstdDictionaryID is the smallest ID of a dictionary that can be trained, lower IDs are reserved by zstd.
+	MinZstdDictionaryID = 32768
+
+	minZstdDictionarySize         = 256
+	minZstdDictionarySampleLength = 256
+)
+
+func init() {
+	// dictionary compression is per-repository, so only the name is known globally.
+	HeaderIDToName[HeaderZstdDictionary] = "zstd-dictionary"
+}
+
+// TrainZstdDictionary trains zstd dictionary with the provided ID of at most maxSize bytes from the provided samples.
+func TrainZstdDictionary(id uint32, samples [][]byte, maxSize int) ([]byte, error) {
+	if id < MinZstdDictionaryID {
+		return nil, errors.Errorf("dictionary ID must be at least %v", MinZstdDictionaryID)
+	}
+
+	if maxSize < minZstdDictionarySize {
+		return nil, errors.Errorf("dictionary size must be at least %v", minZstdDictionarySize)
+	}
+
+	var nonEmpty [][]byte
+
+	for _, s := range samples {
+		if len(s) > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+
+	if len(nonEmpty) == 0 {
+		return nil, errors.New("no samples provided")
+	}
+
+	// history is made of the leading bytes of each sample, which for metadata is where
+	// the repeated structure is, spread evenly so that all samples are represented.
+	perSample := max(maxSize/len(nonEmpty), minZstdDictionarySampleLength)
+
+	var history []byte
+
+	for _, s := range nonEmpty {
+		n := min(len(s), perSample, maxSize-len(history))
+		if n <= 0 {
+			break
+		}
+
+		history = append(history, s[:n]...)
+	}
+
+	if len(history) < minZstdDictionarySize {
+		return nil, errors.New("samples are too small to train a dictionary")
+	}
+
+	return buildZstdDictionary(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: nonEmpty,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8}, //nolint:mnd
+		Level:    zstd.SpeedDefault,
+	})
+}
+
+func buildZstdDictionary(opt zstd.BuildDictOptions) (dict []byte, err error) {
+	// BuildDict panics on some degenerate inputs, such as samples fully contained in the history.
+	defer func() {
+		if r := recover(); r != nil {
+			dict, err = nil, errors.Errorf("unable to build dictionary from the provided samples: %v", r)
+		}
+	}()
+
+	dict, err = zstd.BuildDict(opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build dictionary")
+	}
+
+	return dict, nil
+}
+
+// NewZstdDictionaryCompressor returns a compressor that compresses data with zstd using the provided
+// dictionary and decompresses data compressed using any of the provided dictionaries.
+// Compression fails if compressDict is nil.
+func NewZstdDictionaryCompressor(compressDict []byte, decompressDicts [][]byte) (Compressor, error) {
+	c := &zstdDictionaryCompressor{header: compressionHeader(HeaderZstdDictionary)}
+
+	// validate the dictionaries upfront, so that pools below never fail.
+	if compressDict != nil {
+		w, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderDict(compressDict))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid compression dictionary")
+		}
+
+		c.encoders.New = func() interface{} {
+			w, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderDict(compressDict))
+			mustSucceed(err)
+			return w
+		}
+
+		c.encoders.Put(w)
+	}
+
+	r, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderDicts(decompressDicts...))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid decompression dictionary")
+	}
+
+	c.decoders.New = func() interface{} {
+		r, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderDicts(decompressDicts...))
+		mustSucceed(err)
+		return r
+	}
+
+	c.decoders.Put(r)
+
+	return c, nil
+}
+
+type zstdDictionaryCompressor struct {
+	header   []byte
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+func (c *zstdDictionaryCompressor) HeaderID() HeaderID {
+	return HeaderZstdDictionary
+}
+
+func (c *zstdDictionaryCompressor) Compress(output io.Writer, input io.Reader) error {
+	v := c.encoders.Get()
+	if v == nil {
+		return errors.New("no compression dictionary")
+	}
+
+	//nolint:forcetypeassert
+	w := v.(*zstd.Encoder)
+	defer c.encoders.Put(w)
+
+	if _, err := output.Write(c.header); err != nil {
+		return errors.Wrap(err, "unable to write header")
+	}
+
+	w.Reset(output)
+
+	if err := iocopy.JustCopy(w, input); err != nil {
+		return errors.Wrap(err, "compression error")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "compression close error")
+	}
+
+	return nil
+}
+
+func (c *zstdDictionaryCompressor) Decompress(output io.Writer, input io.Reader, withHeader bool) error {
+	if withHeader {
+		if err := verifyCompressionHeader(input, c.header); err != nil {
+			return err
+		}
+	}
+
+	//nolint:forcetypeassert
+	dec := c.decoders.Get().(*zstd.Decoder)
+	defer func() {
+		mustSucceed(dec.Reset(nil))
+		c.decoders.Put(dec)
+	}()
+
+	if err := dec.Reset(input); err != nil {
+		return errors.Wrap(err, "decompression reset error")
+	}
+
+	if err := iocopy.JustCopy(output, dec); err != nil {
+		return errors.Wrap(err, "decompression error")
+	}
+
+	return nil
+}
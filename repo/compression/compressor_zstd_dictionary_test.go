@@ -0,0 +1,74 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func metadataLikeSamples(n int) [][]byte {
+	var result [][]byte
+
+	for i := range n {
+		result = append(result, []byte(fmt.Sprintf(
+			`{"stream":"kopia:directory","entries":[{"name":"file-%v.txt","type":"f","mode":"0644","mtime":"2024-01-%02dT10:00:00Z","uid":1000,"gid":1000,"obj":"k%032x","size":%v}]}`,
+			i, i%28+1, i*7919, i*13)))
+	}
+
+	return result
+}
+
+func TestZstdDictionaryCompressor(t *testing.T) {
+	samples := metadataLikeSamples(500)
+
+	_, err := TrainZstdDictionary(1, samples, 4096)
+	require.Error(t, err)
+
+	_, err = TrainZstdDictionary(MinZstdDictionaryID, nil, 4096)
+	require.Error(t, err)
+
+	dict1, err := TrainZstdDictionary(MinZstdDictionaryID, samples, 4096)
+	require.NoError(t, err)
+
+	dict2, err := TrainZstdDictionary(MinZstdDictionaryID+1, samples, 4096)
+	require.NoError(t, err)
+
+	old, err := NewZstdDictionaryCompressor(dict1, [][]byte{dict1})
+	require.NoError(t, err)
+
+	cur, err := NewZstdDictionaryCompressor(dict2, [][]byte{dict1, dict2})
+	require.NoError(t, err)
+
+	readOnly, err := NewZstdDictionaryCompressor(nil, [][]byte{dict1, dict2})
+	require.NoError(t, err)
+
+	require.Error(t, readOnly.Compress(&bytes.Buffer{}, bytes.NewReader(samples[0])))
+
+	_, err = NewZstdDictionaryCompressor([]byte("not a dictionary"), nil)
+	require.Error(t, err)
+
+	input := metadataLikeSamples(1001)[1000]
+
+	var withDict, withoutDict bytes.Buffer
+
+	require.NoError(t, cur.Compress(&withDict, bytes.NewReader(input)))
+	require.NoError(t, ByHeaderID[HeaderZstdDefault].Compress(&withoutDict, bytes.NewReader(input)))
+	require.Less(t, withDict.Len(), withoutDict.Len()/2)
+
+	// data compressed with either dictionary can be decompressed by the newer compressor.
+	var withOldDict bytes.Buffer
+
+	require.NoError(t, old.Compress(&withOldDict, bytes.NewReader(input)))
+
+	for _, compressed := range [][]byte{withDict.Bytes(), withOldDict.Bytes()} {
+		var out bytes.Buffer
+
+		require.NoError(t, readOnly.Decompress(&out, bytes.NewReader(compressed), true))
+		require.Equal(t, input, out.Bytes())
+	}
+
+	// the older compressor does not know the newer dictionary.
+	require.Error(t, old.Decompress(&bytes.Buffer{}, bytes.NewReader(withDict.Bytes()), true))
+}
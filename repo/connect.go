@@ -6,7 +6,6 @@
 
 	"github.com/pkg/errors"
 
-	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/ospath"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
@@ -30,10 +29,8 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		opt = &ConnectOptions{}
 	}
 
-	var formatBytes gather.WriteBuffer
-	defer formatBytes.Close()
-
-	if err := st.GetBlob(ctx, format.KopiaRepositoryBlobID, 0, -1, &formatBytes); err != nil {
+	formatBytes, err := format.ReadKopiaRepositoryBlob(ctx, st)
+	if err != nil {
 		if errors.Is(err, blob.ErrBlobNotFound) {
 			return ErrRepositoryNotInitialized
 		}
@@ -41,7 +38,7 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		return errors.Wrap(err, "unable to read format blob")
 	}
 
-	f, err := format.ParseKopiaRepositoryJSON(formatBytes.ToByteSlice())
+	f, err := format.ParseKopiaRepositoryJSON(formatBytes)
 	if err != nil {
 		//nolint:wrapcheck
 		return err
@@ -61,10 +58,10 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 		return errors.Wrap(err, "unable to write config file")
 	}
 
-	return verifyConnect(ctx, configFile, password)
+	return verifyConnect(ctx, configFile, password, !lc.ReadOnly)
 }
 
-func verifyConnect(ctx context.Context, configFile, password string) error {
+func verifyConnect(ctx context.Context, configFile, password string, verifyFormatReplicas bool) error {
 	// now verify that the repository can be opened with the provided config file.
 	r, err := Open(ctx, configFile, password, nil)
 	if err != nil {
@@ -77,6 +74,15 @@ func verifyConnect(ctx context.Context, configFile, password string) error {
 		return err
 	}
 
+	if dr, ok := r.(DirectRepository); ok && verifyFormatReplicas {
+		// repair replicas of format blobs that may have been lost or written by older versions.
+		if n, err := dr.FormatManager().VerifyReplicas(ctx); err != nil {
+			log(ctx).Warnf("unable to verify format blob replicas: %v", err)
+		} else if n > 0 {
+			log(ctx).Infof("Repaired %v format blob replicas.", n)
+		}
+	}
+
 	return errors.Wrap(r.Close(ctx), "error closing repository")
 }
 
@@ -117,3 +123,21 @@ func SetClientOptions(ctx context.Context, configFile string, cliOpt ClientOptio
 
 	return lc.writeToFile(configFile)
 }
+
+// SetReadMirrors sets storage endpoints used for reads when the primary storage is unavailable.
+func SetReadMirrors(ctx context.Context, configFile string, mirrors []blob.ConnectionInfo) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	if lc.Storage == nil {
+		return errors.New("read mirrors are only supported for direct repository connections")
+	}
+
+	lc.ReadMirrors = mirrors
+
+	log(ctx).Debugf("setting %v read mirrors", len(mirrors))
+
+	return lc.writeToFile(configFile)
+}
@@ -28,7 +28,19 @@ type CachingOptions struct {
 	MinMetadataSweepAge         DurationSeconds `json:"minMetadataSweepAge,omitempty"`
 	MinContentSweepAge          DurationSeconds `json:"minContentSweepAge,omitempty"`
 	MinIndexSweepAge            DurationSeconds `json:"minIndexSweepAge,omitempty"`
+	TotalCacheSizeBytes         int64           `json:"totalCacheSizeBytes,omitempty"`
 	HMACSecret                  []byte          `json:"-"`
+
+	// MemoryFallbackReason is set when the cache directory is not usable and memory-bounded caches are used instead.
+	MemoryFallbackReason string `json:"-"`
+}
+
+// MaxMemoryCacheSizeBytes is the maximum size of each cache kept in memory when the cache directory is not usable.
+const MaxMemoryCacheSizeBytes = 64 << 20
+
+// MemoryCacheSizeBytes returns the size of the cache kept in memory instead of the cache with the provided size.
+func MemoryCacheSizeBytes(size int64) int64 {
+	return min(size, MaxMemoryCacheSizeBytes)
 }
 
 // EffectiveMetadataCacheSizeBytes returns the effective metadata cache size.
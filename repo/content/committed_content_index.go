@@ -3,6 +3,7 @@
 import (
 	"bytes"
 	"context"
+	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
@@ -13,6 +14,7 @@
 
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/tempfile"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content/index"
 	"github.com/kopia/kopia/repo/format"
@@ -25,6 +27,10 @@
 // large index operations (such as verification of all contents).
 const smallIndexEntryCountThreshold = 100
 
+// contentFilterFalsePositiveRate is the false positive rate of the filter used to quickly determine
+// that a content is not present, so that lookups during deduplication don't need to go through all indexes.
+const contentFilterFalsePositiveRate = 0.01
+
 type committedContentIndex struct {
 	rev   atomic.Int64
 	cache committedContentIndexCache
@@ -38,6 +44,8 @@ type committedContentIndex struct {
 	inUse map[blob.ID]index.Index
 	// +checklocks:mu
 	merged index.Merged
+	// +checklocks:mu
+	filter *index.BloomFilter
 
 	v1PerContentOverhead func() int
 	formatProvider       format.Provider
@@ -63,6 +71,10 @@ func (c *committedContentIndex) getContent(contentID ID) (Info, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if c.filter != nil && !c.filter.MayContain(contentID) {
+		return index.Info{}, ErrContentNotFound
+	}
+
 	var info Info
 
 	ok, err := c.merged.GetInfo(contentID, &info)
@@ -123,6 +135,8 @@ func (c *committedContentIndex) addIndexBlob(ctx context.Context, indexBlobID bl
 	c.inUse[indexBlobID] = ndx
 	c.merged = append(c.merged, ndx)
 
+	c.updateFilterLocked([]index.Index{ndx}, false)
+
 	return nil
 }
 
@@ -225,6 +239,25 @@ func (c *committedContentIndex) use(ctx context.Context, indexFiles []blob.ID, i
 	oldInUse := c.inUse
 	c.inUse = newInUse
 
+	var (
+		added   []index.Index
+		removed bool
+	)
+
+	for k, ndx := range newInUse {
+		if oldInUse[k] == nil {
+			added = append(added, ndx)
+		}
+	}
+
+	for k := range oldInUse {
+		if newInUse[k] == nil {
+			removed = true
+		}
+	}
+
+	c.updateFilterLocked(added, removed)
+
 	// close indices that were previously in use but are no longer.
 	for k, old := range oldInUse {
 		if newInUse[k] == nil {
@@ -241,6 +274,45 @@ func (c *committedContentIndex) use(ctx context.Context, indexFiles []blob.ID, i
 	return nil
 }
 
+// updateFilterLocked adds entries of the provided newly used indexes to the content filter.
+// The filter is rebuilt from all merged indexes instead when it does not exist yet, when indexes
+// were removed, since entries can't be removed from it, or when it would exceed its capacity.
+// On failure lookups fall back to using indexes directly until the filter is rebuilt.
+// +checklocks:c.mu
+func (c *committedContentIndex) updateFilterLocked(added []index.Index, removed bool) {
+	toAdd := index.Merged(added)
+
+	addedCount := 0
+	for _, ndx := range added {
+		addedCount += ndx.ApproximateCount()
+	}
+
+	if c.filter == nil || removed || c.filter.Count()+addedCount > c.filter.Capacity() {
+		total := 0
+		for _, ndx := range c.merged {
+			total += ndx.ApproximateCount()
+		}
+
+		// leave room for growth to avoid rebuilding on each new index.
+		c.filter = index.NewBloomFilter(2*total, contentFilterFalsePositiveRate) //nolint:mnd
+		toAdd = c.merged
+	}
+
+	for _, ndx := range toAdd {
+		if err := ndx.Iterate(index.AllIDs, func(i index.Info) error {
+			c.filter.Add(i.ContentID)
+			return nil
+		}); err != nil {
+			c.log.Errorf("unable to populate content filter: %v", err)
+
+			// don't use partially-populated filter.
+			c.filter = nil
+
+			return
+		}
+	}
+}
+
 func (c *committedContentIndex) combineSmallIndexes(ctx context.Context, m index.Merged) (index.Merged, error) {
 	var toKeep, toMerge index.Merged
 
@@ -382,8 +454,11 @@ func newCommittedContentIndex(caching *CachingOptions,
 		cache = &diskCommittedContentIndexCache{dirname, clock.Now, v1PerContentOverhead, log, minSweepAge}
 	} else {
 		cache = &memoryCommittedContentIndexCache{
-			contents:             map[blob.ID]index.Index{},
+			contents:             map[blob.ID]*mappedIndexFile{},
 			v1PerContentOverhead: v1PerContentOverhead,
+			createTemp: func() (*os.File, error) {
+				return tempfile.Create("")
+			},
 		}
 	}
 
@@ -2,6 +2,7 @@
 
 import (
 	"bytes"
+	"os"
 	"testing"
 	"time"
 
@@ -9,6 +10,7 @@
 
 	"github.com/kopia/kopia/internal/faketime"
 	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/tempfile"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/blob"
@@ -27,11 +29,76 @@ func TestCommittedContentIndexCache_Memory(t *testing.T) {
 	t.Parallel()
 
 	testCache(t, &memoryCommittedContentIndexCache{
-		contents:             map[blob.ID]index.Index{},
+		contents:             map[blob.ID]*mappedIndexFile{},
 		v1PerContentOverhead: func() int { return 3 },
 	}, nil)
 }
 
+func TestCommittedContentIndexCache_MemoryMapped(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.TempDirectory(t)
+
+	testCache(t, &memoryCommittedContentIndexCache{
+		contents:             map[blob.ID]*mappedIndexFile{},
+		v1PerContentOverhead: func() int { return 3 },
+		createTemp: func() (*os.File, error) {
+			return tempfile.Create(td)
+		},
+	}, nil)
+
+	// temporary files are unnamed.
+	entries, err := os.ReadDir(td)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestCommittedContentIndexCache_DiskSharedMapping(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	ta := faketime.NewClockTimeWithOffset(0)
+	td := testutil.TempDirectory(t)
+
+	// two caches using the same directory, as if used by two repository instances.
+	c1 := &diskCommittedContentIndexCache{td, ta.NowFunc(), func() int { return 3 }, testlogging.Printf(t.Logf, ""), DefaultIndexCacheSweepAge}
+	c2 := &diskCommittedContentIndexCache{td, ta.NowFunc(), func() int { return 3 }, testlogging.Printf(t.Logf, ""), DefaultIndexCacheSweepAge}
+
+	require.NoError(t, c1.addContentToCache(ctx, "ndx1", mustBuildIndex(t, index.Builder{
+		mustParseID(t, "c1"): Info{PackBlobID: "p1234", ContentID: mustParseID(t, "c1")},
+	})))
+
+	ndx1, err := c1.openIndex(ctx, "ndx1")
+	require.NoError(t, err)
+
+	ndx2, err := c2.openIndex(ctx, "ndx1")
+	require.NoError(t, err)
+
+	path := c1.indexBlobPath("ndx1")
+
+	mappedIndexFilesMutex.Lock()
+	m := mappedIndexFiles[path]
+	require.NotNil(t, m)
+	require.Equal(t, 2, m.refCount)
+	mappedIndexFilesMutex.Unlock()
+
+	require.NoError(t, ndx1.Close())
+
+	// the mapping is still used by the second index.
+	var i Info
+
+	ok, err := ndx2.GetInfo(mustParseID(t, "c1"), &i)
+	require.True(t, ok)
+	require.NoError(t, err)
+	require.Equal(t, blob.ID("p1234"), i.PackBlobID)
+
+	require.NoError(t, ndx2.Close())
+
+	mappedIndexFilesMutex.Lock()
+	require.Nil(t, mappedIndexFiles[path])
+	mappedIndexFilesMutex.Unlock()
+}
+
 //nolint:thelper
 func testCache(t *testing.T, cache committedContentIndexCache, fakeTime *faketime.ClockTimeWithOffset) {
 	ctx := testlogging.Context(t)
@@ -34,16 +34,16 @@ func (c *diskCommittedContentIndexCache) indexBlobPath(indexBlobID blob.ID) stri
 }
 
 func (c *diskCommittedContentIndexCache) openIndex(ctx context.Context, indexBlobID blob.ID) (index.Index, error) {
-	fullpath := c.indexBlobPath(indexBlobID)
-
-	f, closeMmap, err := c.mmapOpenWithRetry(fullpath)
+	// index files are immutable, so all sessions in a process can share a single mapping of each file.
+	m, err := openSharedMappedIndexFile(c.indexBlobPath(indexBlobID), c.mmapOpenWithRetry)
 	if err != nil {
 		return nil, err
 	}
 
-	ndx, err := index.Open(f, closeMmap, c.v1PerContentOverhead)
+	defer m.release() //nolint:errcheck
+
+	ndx, err := m.openIndex(c.v1PerContentOverhead)
 	if err != nil {
-		closeMmap() //nolint:errcheck
 		return nil, errors.Wrapf(err, "error opening index from %v", indexBlobID)
 	}
 
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"os"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -11,13 +12,20 @@
 	"github.com/kopia/kopia/repo/content/index"
 )
 
+// memoryCommittedContentIndexCache keeps index blobs for the lifetime of the process when
+// there's no cache directory. To avoid keeping all indexes resident, the blobs are stored in
+// unnamed temporary files mapped into memory, falling back to heap memory when temporary files
+// can't be created.
 type memoryCommittedContentIndexCache struct {
 	mu sync.Mutex
 
 	// +checklocks:mu
-	contents map[blob.ID]index.Index
+	contents map[blob.ID]*mappedIndexFile
 
 	v1PerContentOverhead func() int // +checklocksignore
+
+	// createTemp creates an unnamed temporary file used to store an index blob, nil to use heap memory.
+	createTemp func() (*os.File, error) // +checklocksignore
 }
 
 func (m *memoryCommittedContentIndexCache) hasIndexBlobID(ctx context.Context, indexBlobID blob.ID) (bool, error) {
@@ -28,19 +36,41 @@ func (m *memoryCommittedContentIndexCache) hasIndexBlobID(ctx context.Context, i
 }
 
 func (m *memoryCommittedContentIndexCache) addContentToCache(ctx context.Context, indexBlobID blob.ID, data gather.Bytes) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	f := m.newIndexFile(data)
 
-	ndx, err := index.Open(data.ToByteSlice(), nil, m.v1PerContentOverhead)
+	// validate the index before storing it.
+	ndx, err := f.openIndex(m.v1PerContentOverhead)
 	if err != nil {
+		f.release() //nolint:errcheck
+
 		return errors.Wrapf(err, "error opening index blob %v", indexBlobID)
 	}
 
-	m.contents[indexBlobID] = ndx
+	ndx.Close() //nolint:errcheck
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old := m.contents[indexBlobID]; old != nil {
+		old.release() //nolint:errcheck
+	}
+
+	m.contents[indexBlobID] = f
 
 	return nil
 }
 
+func (m *memoryCommittedContentIndexCache) newIndexFile(data gather.Bytes) *mappedIndexFile {
+	if m.createTemp != nil {
+		f, err := newAnonymousMappedIndexFile(m.createTemp, data)
+		if err == nil {
+			return f
+		}
+	}
+
+	return newHeapIndexFile(data.ToByteSlice())
+}
+
 func (m *memoryCommittedContentIndexCache) openIndex(ctx context.Context, indexBlobID blob.ID) (index.Index, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -50,21 +80,27 @@ func (m *memoryCommittedContentIndexCache) openIndex(ctx context.Context, indexB
 		return nil, errors.Errorf("content not found in cache: %v", indexBlobID)
 	}
 
-	return v, nil
+	return v.openIndex(m.v1PerContentOverhead)
 }
 
 func (m *memoryCommittedContentIndexCache) expireUnused(ctx context.Context, used []blob.ID) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	n := map[blob.ID]index.Index{}
+	n := map[blob.ID]*mappedIndexFile{}
 
 	for _, u := range used {
 		if v, ok := m.contents[u]; ok {
 			n[u] = v
+			delete(m.contents, u)
 		}
 	}
 
+	// release files that are no longer used, they will be unmapped once all indexes using them are closed.
+	for _, v := range m.contents {
+		v.release() //nolint:errcheck
+	}
+
 	m.contents = n
 
 	return nil
@@ -0,0 +1,169 @@
+package content
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/edsrzf/mmap-go"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content/index"
+)
+
+// mappedIndexFilesMutex protects the reference counts of all mapped index files and the
+// registry of mapped index files in the cache directory.
+var mappedIndexFilesMutex sync.Mutex
+
+// mappedIndexFiles keeps track of index files in cache directories that are currently mapped
+// into memory, so that all repository instances in a process that use the same cache directory
+// share a single mapping of each index.
+//
+// +checklocks:mappedIndexFilesMutex
+var mappedIndexFiles = map[string]*mappedIndexFile{}
+
+// mappedIndexFile is a read-only, reference-counted memory mapping of an index file.
+// Index pages are loaded lazily by the operating system when accessed and can be
+// reclaimed under memory pressure, unlike heap-allocated index data.
+type mappedIndexFile struct {
+	// path in the mappedIndexFiles registry, empty for unregistered (anonymous) mappings.
+	path string
+
+	data  []byte
+	unmap func() error
+
+	// +checklocks:mappedIndexFilesMutex
+	refCount int
+}
+
+// openIndex returns a new index backed by the mapping, which must be closed by the caller.
+func (m *mappedIndexFile) openIndex(v1PerContentOverhead func() int) (index.Index, error) {
+	m.addRef()
+
+	var once sync.Once
+
+	// closing the index more than once must not release the mapping still used by others.
+	releaseOnce := func() error {
+		var err error
+
+		once.Do(func() { err = m.release() })
+
+		return err
+	}
+
+	ndx, err := index.Open(m.data, releaseOnce, v1PerContentOverhead)
+	if err != nil {
+		releaseOnce() //nolint:errcheck
+
+		return nil, errors.Wrap(err, "error opening mapped index")
+	}
+
+	return ndx, nil
+}
+
+func (m *mappedIndexFile) addRef() {
+	mappedIndexFilesMutex.Lock()
+	defer mappedIndexFilesMutex.Unlock()
+
+	m.refCount++
+}
+
+// release decrements the reference count and unmaps the file when it's no longer used.
+func (m *mappedIndexFile) release() error {
+	mappedIndexFilesMutex.Lock()
+	defer mappedIndexFilesMutex.Unlock()
+
+	m.refCount--
+	if m.refCount > 0 {
+		return nil
+	}
+
+	if m.path != "" {
+		delete(mappedIndexFiles, m.path)
+	}
+
+	if m.unmap == nil {
+		return nil
+	}
+
+	return m.unmap()
+}
+
+// openSharedMappedIndexFile returns the shared mapping of the index file at the provided path,
+// mapping it into memory using the provided function if it's not currently mapped.
+// The caller must release the returned mapping.
+func openSharedMappedIndexFile(path string, mapFile func(path string) (mmap.MMap, func() error, error)) (*mappedIndexFile, error) {
+	mappedIndexFilesMutex.Lock()
+	defer mappedIndexFilesMutex.Unlock()
+
+	if m := mappedIndexFiles[path]; m != nil {
+		m.refCount++
+		return m, nil
+	}
+
+	mm, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	adviseRandomAccess(mm)
+
+	m := &mappedIndexFile{
+		path:     path,
+		data:     mm,
+		unmap:    unmap,
+		refCount: 1,
+	}
+
+	mappedIndexFiles[path] = m
+
+	return m, nil
+}
+
+// newAnonymousMappedIndexFile stores the provided index data in an unnamed temporary file and maps it into memory.
+// The caller must release the returned mapping.
+func newAnonymousMappedIndexFile(createTemp func() (*os.File, error), data io.WriterTo) (*mappedIndexFile, error) {
+	f, err := createTemp()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create temporary index file")
+	}
+
+	if _, err := data.WriteTo(f); err != nil {
+		f.Close() //nolint:errcheck
+
+		return nil, errors.Wrap(err, "unable to write temporary index file")
+	}
+
+	mm, err := mmap.Map(f, mmap.RDONLY, 0)
+	if err != nil {
+		f.Close() //nolint:errcheck
+
+		return nil, errors.Wrap(err, "mmap error")
+	}
+
+	adviseRandomAccess(mm)
+
+	m := &mappedIndexFile{
+		data: mm,
+		unmap: func() error {
+			if err := mm.Unmap(); err != nil {
+				return errors.Wrap(err, "error unmapping temporary index")
+			}
+
+			return errors.Wrap(f.Close(), "error closing temporary index")
+		},
+	}
+
+	m.addRef()
+
+	return m, nil
+}
+
+// newHeapIndexFile returns a reference-counted index file backed by the provided data in memory.
+func newHeapIndexFile(data []byte) *mappedIndexFile {
+	m := &mappedIndexFile{data: data}
+
+	m.addRef()
+
+	return m
+}
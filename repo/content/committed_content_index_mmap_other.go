@@ -0,0 +1,8 @@
+//go:build !linux && !freebsd && !darwin && !openbsd
+// +build !linux,!freebsd,!darwin,!openbsd
+
+package content
+
+// adviseRandomAccess is a no-op on platforms that don't support madvise().
+func adviseRandomAccess(_ []byte) {
+}
@@ -0,0 +1,18 @@
+//go:build linux || freebsd || darwin || openbsd
+// +build linux freebsd darwin openbsd
+
+package content
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// adviseRandomAccess tells the kernel that index pages will be accessed randomly, which disables read-ahead
+// so that only pages that are actually used for lookups are loaded into memory.
+func adviseRandomAccess(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	unix.Madvise(data, unix.MADV_RANDOM) //nolint:errcheck
+}
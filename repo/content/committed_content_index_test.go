@@ -0,0 +1,70 @@
+package content
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/format"
+)
+
+func TestCommittedContentIndexFilter(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	fo := mustCreateFormatProvider(t, &format.ContentFormat{
+		Hash:              "HMAC-SHA256",
+		Encryption:        "AES256-GCM-HMAC-SHA256",
+		HMACSecret:        hmacSecret,
+		MutableParameters: format.MutableParameters{Version: 2, IndexVersion: index.Version2},
+	})
+
+	c := newCommittedContentIndex(&CachingOptions{}, func() int { return 3 }, fo, false,
+		func(ctx context.Context, blobID blob.ID, output *gather.WriteBuffer) error {
+			return blob.ErrBlobNotFound
+		}, testlogging.Printf(t.Logf, ""), DefaultIndexCacheSweepAge)
+
+	filterCount := func() int {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+
+		return c.filter.Count()
+	}
+
+	require.NoError(t, c.addIndexBlob(ctx, "ndx1", mustBuildIndex(t, index.Builder{
+		mustParseID(t, "c1"): Info{PackBlobID: "p1234", ContentID: mustParseID(t, "c1")},
+		mustParseID(t, "c2"): Info{PackBlobID: "p1234", ContentID: mustParseID(t, "c2")},
+	}), true))
+	require.Equal(t, 2, filterCount())
+
+	_, err := c.getContent(mustParseID(t, "c1"))
+	require.NoError(t, err)
+
+	_, err = c.getContent(mustParseID(t, "c3"))
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	// adding indexes extends the filter.
+	require.NoError(t, c.addIndexBlob(ctx, "ndx2", mustBuildIndex(t, index.Builder{
+		mustParseID(t, "c3"): Info{PackBlobID: "p2345", ContentID: mustParseID(t, "c3")},
+	}), false))
+	require.NoError(t, c.use(ctx, []blob.ID{"ndx1", "ndx2"}, time.Time{}))
+	require.Equal(t, 3, filterCount())
+
+	_, err = c.getContent(mustParseID(t, "c3"))
+	require.NoError(t, err)
+
+	// removing indexes rebuilds the filter.
+	require.NoError(t, c.use(ctx, []blob.ID{"ndx2"}, time.Time{}))
+	require.Equal(t, 1, filterCount())
+
+	_, err = c.getContent(mustParseID(t, "c1"))
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	_, err = c.getContent(mustParseID(t, "c3"))
+	require.NoError(t, err)
+}
@@ -1,6 +1,7 @@
 package content
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"path/filepath"
@@ -25,6 +26,8 @@
 	"github.com/kopia/kopia/repo/blob/sharded"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content/indexblob"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/hashing"
 	"github.com/kopia/kopia/repo/logging"
@@ -78,11 +81,16 @@ type SharedManager struct {
 	indexBlobManagerV0 *indexblob.ManagerV0
 	indexBlobManagerV1 *indexblob.ManagerV1
 
-	contentCache      cache.ContentCache
-	metadataCache     cache.ContentCache
-	indexBlobCache    *cache.PersistentCache
-	committedContents *committedContentIndex
-	timeNow           func() time.Time
+	contentCache   cache.ContentCache
+	metadataCache  cache.ContentCache
+	indexBlobCache *cache.PersistentCache
+	cacheBudget    *cache.Budget
+	cacheDirectory string
+
+	// non-empty when memory-bounded caches are used because the cache directory is not usable.
+	memoryFallbackReason string
+	committedContents    *committedContentIndex
+	timeNow              func() time.Time
 
 	// lock to protect the set of committed indexes
 	// shared lock will be acquired when writing new content to allow it to happen in parallel
@@ -90,12 +98,25 @@ type SharedManager struct {
 	indexesLock            sync.RWMutex
 	permissiveCacheLoading bool
 
+	// when set, decrypted contents are always re-hashed and compared against their IDs.
+	verifyContentHashOnRead bool
+
 	// maybeRefreshIndexes() will call Refresh() after this point in ime.
 	// +checklocks:indexesLock
 	refreshIndexesAfter time.Time
 
 	format format.Provider
 
+	// crypters of encryption domains unlocked by this connection, keyed by encryption key ID.
+	encryptionDomains map[byte]*format.EncryptionDomainCrypter
+
+	// when set, new contents other than manifests are written in this encryption domain.
+	writeEncryptionDomain *format.EncryptionDomainCrypter
+
+	dictionaryCompressorMutex sync.Mutex
+	// +checklocks:dictionaryCompressorMutex
+	dictionaryCompressor *cachedDictionaryCompressor
+
 	checkInvariantsOnUnlock bool
 	minPreambleLength       int
 	maxPreambleLength       int
@@ -196,7 +217,7 @@ func (sm *SharedManager) attemptReadPackFileLocalIndex(ctx context.Context, pack
 	}
 
 	return errors.Wrap(
-		sm.decryptAndVerify(encryptedLocalIndexBytes.Bytes(), postamble.localIndexIV, output),
+		sm.decryptAndVerify(sm.format.Encryptor(), encryptedLocalIndexBytes.Bytes(), postamble.localIndexIV, output),
 		"unable to decrypt local index")
 }
 
@@ -283,33 +304,59 @@ func (sm *SharedManager) indexBlobManager(ctx context.Context) (indexblob.Manage
 	return q, nil
 }
 
-func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
+func (sm *SharedManager) decryptContentAndVerify(ctx context.Context, payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
+	if err := sm.decryptAndDecompressContent(payload, bi, output); err != nil {
+		return err
+	}
+
+	if !sm.shouldVerifyContentHashOnRead(ctx) {
+		return nil
+	}
+
+	return sm.verifyContentHash(output.Bytes(), bi)
+}
+
+func (sm *SharedManager) decryptAndDecompressContent(payload gather.Bytes, bi Info, output *gather.WriteBuffer) error {
 	sm.Stats.readContent(payload.Length())
 
 	var hashBuf [hashing.MaxHashSize]byte
 
 	iv := getPackedContentIV(hashBuf[:0], bi.ContentID)
 
-	// reserved for future use
-	if k := bi.EncryptionKeyID; k != 0 {
-		return errors.Errorf("unsupported encryption key ID: %v", k)
+	crypter, err := sm.crypterForKeyID(bi.EncryptionKeyID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to decrypt %v", bi.ContentID)
 	}
 
 	h := bi.CompressionHeaderID
 	if h == 0 {
 		return errors.Wrapf(
-			sm.decryptAndVerify(payload, iv, output),
+			sm.decryptAndVerify(crypter.Encryptor(), payload, iv, output),
 			"invalid checksum at %v offset %v length %v/%v", bi.PackBlobID, bi.PackOffset, bi.PackedLength, payload.Length())
 	}
 
 	var tmp gather.WriteBuffer
 	defer tmp.Close()
 
-	if err := sm.decryptAndVerify(payload, iv, &tmp); err != nil {
+	if err := sm.decryptAndVerify(crypter.Encryptor(), payload, iv, &tmp); err != nil {
 		return errors.Wrapf(err, "invalid checksum at %v offset %v length %v/%v", bi.PackBlobID, bi.PackOffset, bi.PackedLength, payload.Length())
 	}
 
 	c := compression.ByHeaderID[h]
+
+	if h == compression.HeaderZstdDictionary {
+		dc, err := sm.getDictionaryCompressor()
+		if err != nil {
+			return err
+		}
+
+		if dc == nil {
+			return errors.New("content was compressed using a compression dictionary unknown to this client, try reconnecting")
+		}
+
+		c = dc.compressor
+	}
+
 	if c == nil {
 		return errors.Errorf("unsupported compressor %x", h)
 	}
@@ -325,10 +372,50 @@ func (sm *SharedManager) decryptContentAndVerify(payload gather.Bytes, bi Info,
 	return nil
 }
 
-func (sm *SharedManager) decryptAndVerify(encrypted gather.Bytes, iv []byte, output *gather.WriteBuffer) error {
+// shouldVerifyContentHashOnRead returns true if either the connection or the repository
+// requires content hashes to be verified on every read.
+func (sm *SharedManager) shouldVerifyContentHashOnRead(ctx context.Context) bool {
+	if sm.verifyContentHashOnRead {
+		return true
+	}
+
+	mp, err := sm.format.GetMutableParameters(ctx)
+	if err != nil {
+		return false
+	}
+
+	return mp.VerifyContentHashOnRead
+}
+
+// verifyContentHash re-hashes the decrypted and decompressed content and compares the
+// result against the content ID.
+func (sm *SharedManager) verifyContentHash(data gather.Bytes, bi Info) error {
+	crypter, err := sm.crypterForKeyID(bi.EncryptionKeyID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to verify %v", bi.ContentID)
+	}
+
+	var hashBuf [hashing.MaxHashSize]byte
+
+	t0 := timetrack.StartTimer()
+	h := crypter.HashFunc()(hashBuf[:0], data)
+
+	sm.verifiedBytes.Observe(int64(data.Length()), t0.Elapsed())
+
+	if !bytes.Equal(h, bi.ContentID.Hash()) {
+		sm.Stats.foundInvalidContent()
+		sm.verifyContentErrorCount.Add(1)
+
+		return errors.Errorf("content hash mismatch for %v at %v offset %v length %v", bi.ContentID, bi.PackBlobID, bi.PackOffset, bi.PackedLength)
+	}
+
+	return nil
+}
+
+func (sm *SharedManager) decryptAndVerify(enc encryption.Encryptor, encrypted gather.Bytes, iv []byte, output *gather.WriteBuffer) error {
 	t0 := timetrack.StartTimer()
 
-	if err := sm.format.Encryptor().Decrypt(encrypted, iv, output); err != nil {
+	if err := enc.Decrypt(encrypted, iv, output); err != nil {
 		sm.Stats.foundInvalidContent()
 		return errors.Wrap(err, "decrypt")
 	}
@@ -341,6 +428,34 @@ func (sm *SharedManager) decryptAndVerify(encrypted gather.Bytes, iv []byte, out
 	return nil
 }
 
+// ErrErrorCorrectionNotEnabled is returned when checking error correction data in a repository without it.
+var ErrErrorCorrectionNotEnabled = errors.New("error correction is not enabled in this repository")
+
+// CorruptedShards reads the stored data of the provided content directly from the storage, bypassing
+// the cache, and returns the number of error correction shards that are corrupted and will be corrected
+// when reading. Returns an error if the content is corrupted beyond repair.
+func (sm *SharedManager) CorruptedShards(ctx context.Context, bi Info) (int, error) {
+	d, ok := sm.format.Encryptor().(ecc.CorruptionDetector)
+	if !ok {
+		return 0, ErrErrorCorrectionNotEnabled
+	}
+
+	var payload gather.WriteBuffer
+	defer payload.Close()
+
+	if err := sm.st.GetBlob(ctx, bi.PackBlobID, int64(bi.PackOffset), int64(bi.PackedLength), &payload); err != nil {
+		return 0, errors.Wrapf(err, "error reading content %v from blob %q", bi.ContentID, bi.PackBlobID)
+	}
+
+	n, err := d.CorruptedShards(payload.Bytes())
+	if err != nil {
+		sm.Stats.foundInvalidContent()
+		return n, errors.Wrapf(err, "unrecoverable content %v at %v offset %v length %v", bi.ContentID, bi.PackBlobID, bi.PackOffset, bi.PackedLength)
+	}
+
+	return n, nil
+}
+
 // IndexBlobs returns the list of active index blobs.
 func (sm *SharedManager) IndexBlobs(ctx context.Context, includeInactive bool) ([]indexblob.Metadata, error) {
 	if includeInactive {
@@ -421,35 +536,63 @@ func (sm *SharedManager) namedLogger(n string) logging.Logger {
 	return sm.contextLogger
 }
 
-func contentCacheSweepSettings(caching *CachingOptions) cache.SweepSettings {
+func contentCacheSweepSettings(caching *CachingOptions, budget *cache.Budget) cache.SweepSettings {
 	return cache.SweepSettings{
 		MaxSizeBytes: caching.ContentCacheSizeBytes,
 		LimitBytes:   caching.ContentCacheSizeLimitBytes,
 		MinSweepAge:  caching.MinContentSweepAge.DurationOrDefault(DefaultDataCacheSweepAge),
+		Budget:       budget,
+		Priority:     cache.PriorityData,
 	}
 }
 
-func metadataCacheSizeSweepSettings(caching *CachingOptions) cache.SweepSettings {
+func metadataCacheSizeSweepSettings(caching *CachingOptions, budget *cache.Budget) cache.SweepSettings {
 	return cache.SweepSettings{
 		MaxSizeBytes: caching.EffectiveMetadataCacheSizeBytes(),
 		LimitBytes:   caching.MetadataCacheSizeLimitBytes,
 		MinSweepAge:  caching.MinMetadataSweepAge.DurationOrDefault(DefaultMetadataCacheSweepAge),
+		Budget:       budget,
+		Priority:     cache.PriorityMetadata,
 	}
 }
 
-func indexBlobCacheSweepSettings(caching *CachingOptions) cache.SweepSettings {
+func indexBlobCacheSweepSettings(caching *CachingOptions, budget *cache.Budget) cache.SweepSettings {
 	return cache.SweepSettings{
 		MaxSizeBytes: caching.EffectiveMetadataCacheSizeBytes(),
 		MinSweepAge:  caching.MinMetadataSweepAge.DurationOrDefault(DefaultMetadataCacheSweepAge),
+		Budget:       budget,
+		Priority:     cache.PriorityIndex,
 	}
 }
 
+// newMemoryCacheStorageOrNil returns memory-bounded cache storage replacing the cache of the provided size,
+// nil if the cache is disabled.
+func newMemoryCacheStorageOrNil(size int64, timeNow func() time.Time) cache.Storage {
+	if size <= 0 {
+		return nil
+	}
+
+	return cache.NewMemoryStorage(MemoryCacheSizeBytes(size), timeNow)
+}
+
 func (sm *SharedManager) setupCachesAndIndexManagers(ctx context.Context, caching *CachingOptions, mr *metrics.Registry) error {
+	// optional size limit shared by all caches, evicting data before indexes before metadata.
+	budget := cache.NewBudget(caching.TotalCacheSizeBytes)
+
+	var dataCacheStorage, metadataCacheStorage, indexBlobStorage cache.Storage
+
+	if caching.MemoryFallbackReason != "" {
+		dataCacheStorage = newMemoryCacheStorageOrNil(caching.ContentCacheSizeBytes, sm.timeNow)
+		metadataCacheStorage = newMemoryCacheStorageOrNil(caching.EffectiveMetadataCacheSizeBytes(), sm.timeNow)
+		indexBlobStorage = newMemoryCacheStorageOrNil(caching.EffectiveMetadataCacheSizeBytes(), sm.timeNow)
+	}
+
 	dataCache, err := cache.NewContentCache(ctx, sm.st, cache.Options{
 		BaseCacheDirectory: caching.CacheDirectory,
 		CacheSubDir:        "contents",
+		Storage:            dataCacheStorage,
 		HMACSecret:         caching.HMACSecret,
-		Sweep:              contentCacheSweepSettings(caching),
+		Sweep:              contentCacheSweepSettings(caching, budget),
 	}, mr)
 	if err != nil {
 		return errors.Wrap(err, "unable to initialize content cache")
@@ -458,23 +601,26 @@ func (sm *SharedManager) setupCachesAndIndexManagers(ctx context.Context, cachin
 	metadataCache, err := cache.NewContentCache(ctx, sm.st, cache.Options{
 		BaseCacheDirectory: caching.CacheDirectory,
 		CacheSubDir:        "metadata",
+		Storage:            metadataCacheStorage,
 		HMACSecret:         caching.HMACSecret,
 		FetchFullBlobs:     true,
-		Sweep:              metadataCacheSizeSweepSettings(caching),
+		Sweep:              metadataCacheSizeSweepSettings(caching, budget),
 	}, mr)
 	if err != nil {
 		return errors.Wrap(err, "unable to initialize metadata cache")
 	}
 
-	indexBlobStorage, err := cache.NewStorageOrNil(ctx, caching.CacheDirectory, caching.EffectiveMetadataCacheSizeBytes(), "index-blobs")
-	if err != nil {
-		return errors.Wrap(err, "unable to initialize index blob cache storage")
+	if indexBlobStorage == nil {
+		indexBlobStorage, err = cache.NewStorageOrNil(ctx, caching.CacheDirectory, caching.EffectiveMetadataCacheSizeBytes(), "index-blobs")
+		if err != nil {
+			return errors.Wrap(err, "unable to initialize index blob cache storage")
+		}
 	}
 
 	indexBlobCache, err := cache.NewPersistentCache(ctx, "index-blobs",
 		indexBlobStorage,
 		cacheprot.ChecksumProtection(caching.HMACSecret),
-		indexBlobCacheSweepSettings(caching),
+		indexBlobCacheSweepSettings(caching, budget),
 		mr, sm.timeNow)
 	if err != nil {
 		return errors.Wrap(err, "unable to create index blob cache")
@@ -525,6 +671,9 @@ func(ctx context.Context, blobIDs []blob.ID, outputPrefix blob.ID) error {
 	sm.contentCache = dataCache
 	sm.metadataCache = metadataCache
 	sm.indexBlobCache = indexBlobCache
+	sm.cacheBudget = budget
+	sm.cacheDirectory = caching.CacheDirectory
+	sm.memoryFallbackReason = caching.MemoryFallbackReason
 	sm.committedContents = newCommittedContentIndex(caching,
 		sm.format.Encryptor().Overhead,
 		sm.format,
@@ -570,6 +719,8 @@ func (sm *SharedManager) CloseShared(ctx context.Context) error {
 		return errors.Wrap(err, "error closing committed content index")
 	}
 
+	sm.persistCacheStats()
+
 	sm.contentCache.Close(ctx)
 	sm.metadataCache.Close(ctx)
 	sm.indexBlobCache.Close(ctx)
@@ -619,6 +770,7 @@ func NewSharedManager(ctx context.Context, st blob.Storage, prov format.Provider
 		timeNow:                 opts.TimeNow,
 		format:                  prov,
 		permissiveCacheLoading:  opts.PermissiveCacheLoading,
+		verifyContentHashOnRead: opts.VerifyContentHashOnRead,
 		minPreambleLength:       defaultMinPreambleLength,
 		maxPreambleLength:       defaultMaxPreambleLength,
 		paddingUnit:             defaultPaddingUnit,
@@ -635,6 +787,10 @@ func NewSharedManager(ctx context.Context, st blob.Storage, prov format.Provider
 
 	sm.log = sm.namedLogger("shared-manager")
 
+	if err := sm.setupEncryptionDomains(opts); err != nil {
+		return nil, err
+	}
+
 	caching = caching.CloneOrDefault()
 
 	if err := sm.setupCachesAndIndexManagers(ctx, caching, mr); err != nil {
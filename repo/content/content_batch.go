@@ -0,0 +1,174 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// contents of the same pack blob separated by no more than this many bytes are read together.
+	maxBatchRangeGap = 64 << 10
+
+	// maximum length of a single ranged read issued by GetContents.
+	maxBatchRangeLength = 8 << 20
+)
+
+// BatchReader is implemented by content readers that can retrieve multiple contents at once.
+type BatchReader interface {
+	GetContents(ctx context.Context, contentIDs []ID) ([][]byte, error)
+}
+
+// GetContents gets the contents of the provided content IDs, returned in the same order.
+// Contents located close to each other in the same pack blob are retrieved using a single ranged read,
+// which significantly reduces the number of storage requests compared to calling GetContent for each one.
+// If any of the contents is not found, returns an error wrapping ErrContentNotFound.
+func (bm *WriteManager) GetContents(ctx context.Context, contentIDs []ID) ([][]byte, error) {
+	t0 := timetrack.StartTimer()
+
+	results := make([][]byte, len(contentIDs))
+
+	// acquire read lock to prevent flush from happening between getting infos and reading data.
+	bm.mu.RLock()
+	defer bm.mu.RUnlock()
+
+	var (
+		// indexes of results for each unique content ID
+		resultIndexes  = map[ID][]int{}
+		contentsByBlob = map[blob.ID][]Info{}
+	)
+
+	for i, cid := range contentIDs {
+		if _, ok := resultIndexes[cid]; ok {
+			resultIndexes[cid] = append(resultIndexes[cid], i)
+			continue
+		}
+
+		pp, bi, err := bm.getContentInfoReadLocked(ctx, cid)
+		if err != nil {
+			bm.getContentNotFoundCount.Add(1)
+			return nil, errors.Wrapf(err, "content %v", cid)
+		}
+
+		resultIndexes[cid] = []int{i}
+
+		if pp != nil && pp.packBlobID == bi.PackBlobID {
+			// content is not written to storage yet.
+			var tmp gather.WriteBuffer
+			defer tmp.Close() //nolint:gocritic
+
+			if err := bm.getContentDataReadLocked(ctx, pp, bi, &tmp); err != nil {
+				bm.getContentErrorCount.Add(1)
+				return nil, err
+			}
+
+			results[i] = tmp.ToByteSlice()
+
+			continue
+		}
+
+		contentsByBlob[bi.PackBlobID] = append(contentsByBlob[bi.PackBlobID], bi)
+	}
+
+	var ranges []*prefetchRange
+
+	for _, infos := range contentsByBlob {
+		ranges = append(ranges, coalescePrefetchRanges(infos, maxBatchRangeGap, maxBatchRangeLength)...)
+	}
+
+	rangeCh := make(chan *prefetchRange)
+
+	eg, egctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		defer close(rangeCh)
+
+		for _, r := range ranges {
+			select {
+			case rangeCh <- r:
+			case <-egctx.Done():
+				return nil
+			}
+		}
+
+		return nil
+	})
+
+	for range min(parallelFetches, len(ranges)) {
+		eg.Go(func() error {
+			for r := range rangeCh {
+				if err := bm.getContentRangeReadLocked(egctx, r, results, resultIndexes); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		bm.getContentErrorCount.Add(1)
+		return nil, err
+	}
+
+	var totalBytes int64
+
+	for _, idx := range resultIndexes {
+		for _, i := range idx[1:] {
+			results[i] = results[idx[0]]
+		}
+
+		totalBytes += int64(len(results[idx[0]]))
+	}
+
+	bm.getContentBytes.Observe(totalBytes, t0.Elapsed())
+
+	return results, nil
+}
+
+// getContentRangeReadLocked reads contents located in a range of a pack blob and stores their decrypted data in results.
+func (bm *WriteManager) getContentRangeReadLocked(ctx context.Context, r *prefetchRange, results [][]byte, resultIndexes map[ID][]int) error {
+	contents := make([]cache.ContentRange, len(r.infos))
+	payloads := make([]*gather.WriteBuffer, len(r.infos))
+
+	for i, bi := range r.infos {
+		contents[i] = cache.ContentRange{
+			ContentID: contentCacheKeyForInfo(bi),
+			Offset:    int64(bi.PackOffset),
+			Length:    int64(bi.PackedLength),
+		}
+
+		payloads[i] = gather.NewWriteBuffer()
+		defer payloads[i].Close() //nolint:gocritic
+	}
+
+	blobID := r.infos[0].PackBlobID
+
+	if err := bm.getCacheForContentID(r.infos[0].ContentID).GetContentRange(ctx, blobID, contents, payloads); err != nil {
+		return errors.Wrapf(err, "error getting contents from blob %q", blobID)
+	}
+
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	for i, bi := range r.infos {
+		tmp.Reset()
+
+		if err := bm.decryptContentAndVerify(ctx, payloads[i].Bytes(), bi, &tmp); err != nil {
+			return errors.Wrapf(err, "content %v", bi.ContentID)
+		}
+
+		// each result index is written by exactly one goroutine.
+		results[resultIndexes[bi.ContentID][0]] = tmp.ToByteSlice()
+	}
+
+	return nil
+}
+
+var _ BatchReader = (*WriteManager)(nil)
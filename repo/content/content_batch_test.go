@@ -0,0 +1,77 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+type getBlobCountingStorage struct {
+	blob.Storage
+
+	getBlobCount atomic.Int32
+}
+
+func (s *getBlobCountingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64, output blob.OutputBuffer) error {
+	s.getBlobCount.Add(1)
+
+	//nolint:wrapcheck
+	return s.Storage.GetBlob(ctx, id, offset, length, output)
+}
+
+func (s *contentManagerSuite) TestGetContents(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := &getBlobCountingStorage{Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)}
+	bm := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		maxPackSize: 20e6,
+	})
+
+	defer bm.CloseShared(ctx)
+
+	var (
+		ids      []ID
+		contents [][]byte
+	)
+
+	for i := range 100 {
+		b := bytes.Repeat([]byte{byte(i), byte(i + 1)}, 100+i)
+		ids = append(ids, writeContentAndVerify(ctx, t, bm, b))
+		contents = append(contents, b)
+	}
+
+	require.NoError(t, bm.Flush(ctx))
+
+	// pending contents are returned too.
+	pending := []byte("pending content")
+	ids = append(ids, writeContentAndVerify(ctx, t, bm, pending))
+	contents = append(contents, pending)
+
+	// duplicates are allowed.
+	ids = append(ids, ids[3])
+	contents = append(contents, contents[3])
+
+	st.getBlobCount.Store(0)
+
+	got, err := bm.GetContents(ctx, ids)
+	require.NoError(t, err)
+	require.Equal(t, contents, got)
+
+	// all committed contents are in a single pack blob and are read with a single request.
+	require.EqualValues(t, 1, st.getBlobCount.Load())
+
+	// missing content
+	_, err = bm.GetContents(ctx, []ID{ids[0], mustParseID(t, "abcdef")})
+	require.ErrorIs(t, err, ErrContentNotFound)
+
+	// empty input
+	got, err = bm.GetContents(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
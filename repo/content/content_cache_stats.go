@@ -0,0 +1,113 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/cache"
+)
+
+// CacheStatsFileName is the name of the file in the cache directory that keeps cumulative cache statistics.
+const CacheStatsFileName = "cache-stats.json"
+
+// CacheStats describes current usage and statistics of local caches.
+type CacheStats struct {
+	TotalSizeLimitBytes int64         `json:"totalSizeLimitBytes,omitempty"`
+	TotalSizeBytes      int64         `json:"totalSizeBytes"`
+	Caches              []cache.Stats `json:"caches"`
+
+	// non-empty when memory-bounded caches are used because the cache directory is not usable.
+	MemoryFallbackReason string `json:"memoryFallbackReason,omitempty"`
+}
+
+// CacheStats returns the usage and statistics of caches since the repository was opened.
+func (sm *SharedManager) CacheStats() CacheStats {
+	result := CacheStats{
+		TotalSizeLimitBytes:  sm.cacheBudget.MaxSizeBytes(),
+		MemoryFallbackReason: sm.memoryFallbackReason,
+	}
+
+	for _, s := range []cache.Stats{
+		sm.metadataCache.Stats(),
+		sm.indexBlobCache.Stats(),
+		sm.contentCache.Stats(),
+	} {
+		if s.Description == "" {
+			// cache is not enabled.
+			continue
+		}
+
+		result.TotalSizeBytes += s.SizeBytes
+		result.Caches = append(result.Caches, s)
+	}
+
+	return result
+}
+
+// ReadCumulativeCacheStats returns cache statistics accumulated across all sessions using the provided cache directory.
+func ReadCumulativeCacheStats(cacheDir string) (map[string]cache.Stats, error) {
+	result := map[string]cache.Stats{}
+
+	b, err := os.ReadFile(filepath.Join(cacheDir, CacheStatsFileName)) //nolint:gosec
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read cache stats")
+	}
+
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrap(err, "invalid cache stats")
+	}
+
+	return result, nil
+}
+
+// persistCacheStats adds statistics of the current session to the cumulative statistics in the cache directory.
+func (sm *SharedManager) persistCacheStats() {
+	if sm.cacheDirectory == "" {
+		return
+	}
+
+	current := sm.CacheStats()
+
+	var anyActivity bool
+
+	for _, s := range current.Caches {
+		if s.HitCount+s.MissCount+s.EvictedCount > 0 {
+			anyActivity = true
+		}
+	}
+
+	if !anyActivity {
+		return
+	}
+
+	cumulative, err := ReadCumulativeCacheStats(sm.cacheDirectory)
+	if err != nil {
+		// start over if the stats are corrupted.
+		cumulative = map[string]cache.Stats{}
+	}
+
+	for _, s := range current.Caches {
+		merged := s.Add(cumulative[s.Description])
+
+		cumulative[s.Description] = merged
+	}
+
+	b, err := json.MarshalIndent(cumulative, "", "  ")
+	if err != nil {
+		sm.log.Debugf("unable to marshal cache stats: %v", err)
+		return
+	}
+
+	if err := atomicfile.Write(filepath.Join(sm.cacheDirectory, CacheStatsFileName), bytes.NewReader(b)); err != nil {
+		sm.log.Debugf("unable to write cache stats: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package content
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/blobcrypto"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/format"
+)
+
+// manifestContentPrefix is the prefix of manifest contents (see manifest.ContentPrefix), which are always
+// encrypted using the repository master key, so that all clients can list snapshots and policies.
+// Directory listings are encrypted in the encryption domain like all other contents.
+const manifestContentPrefix index.IDPrefix = "m"
+
+// ErrEncryptionDomainLocked is returned when reading a content encrypted in an encryption domain
+// that has not been unlocked by this connection.
+var ErrEncryptionDomainLocked = errors.New("content is encrypted in an encryption domain which is not unlocked by this connection")
+
+func (sm *SharedManager) setupEncryptionDomains(opts *ManagerOptions) error {
+	sm.encryptionDomains = map[byte]*format.EncryptionDomainCrypter{}
+
+	for _, d := range opts.EncryptionDomains {
+		sm.encryptionDomains[d.KeyID] = d
+
+		if d.Name == opts.WriteEncryptionDomain {
+			sm.writeEncryptionDomain = d
+		}
+	}
+
+	if opts.WriteEncryptionDomain != "" && sm.writeEncryptionDomain == nil {
+		return errors.Errorf("encryption domain %v is not unlocked", opts.WriteEncryptionDomain)
+	}
+
+	return nil
+}
+
+// encryptionKeyIDForPrefix returns the ID of the key used to encrypt new contents with the provided prefix,
+// 0 denotes the repository master key.
+func (sm *SharedManager) encryptionKeyIDForPrefix(prefix index.IDPrefix) byte {
+	if sm.writeEncryptionDomain == nil || prefix == manifestContentPrefix {
+		return 0
+	}
+
+	return sm.writeEncryptionDomain.KeyID
+}
+
+// WriteEncryptionDomain returns the name of the encryption domain in which new contents are written,
+// empty if they are encrypted using the repository master key.
+func (sm *SharedManager) WriteEncryptionDomain() string {
+	if sm.writeEncryptionDomain == nil {
+		return ""
+	}
+
+	return sm.writeEncryptionDomain.Name
+}
+
+// IsEncryptionKeyLocked returns true if contents encrypted using the key with a given ID can't be read
+// by this connection because their encryption domain is not unlocked.
+func (sm *SharedManager) IsEncryptionKeyLocked(keyID byte) bool {
+	return keyID != 0 && sm.encryptionDomains[keyID] == nil
+}
+
+// crypterForKeyID returns the hash function and encryptor for contents encrypted using the key with a given ID.
+func (sm *SharedManager) crypterForKeyID(keyID byte) (blobcrypto.Crypter, error) {
+	if keyID == 0 {
+		return sm.format, nil
+	}
+
+	if d := sm.encryptionDomains[keyID]; d != nil {
+		return d, nil
+	}
+
+	return nil, errors.Wrapf(ErrEncryptionDomainLocked, "key ID %v", keyID)
+}
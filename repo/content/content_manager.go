@@ -18,6 +18,7 @@
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content/index"
 	"github.com/kopia/kopia/repo/content/indexblob"
@@ -63,6 +64,9 @@
 // ErrContentNotFound is returned when content is not found.
 var ErrContentNotFound = errors.New("content not found")
 
+// ErrRepositoryReadOnly is returned when attempting to modify contents of a repository connected in read-only mode.
+var ErrRepositoryReadOnly = errors.Wrap(readonly.ErrReadonly, "repository is connected in read-only mode")
+
 // WriteManager builds content-addressable storage with encryption, deduplication and packaging on top of BLOB store.
 type WriteManager struct {
 	revision            atomic.Int64 // changes on each local write
@@ -78,7 +82,8 @@ type WriteManager struct {
 	sessionHost string
 
 	currentSessionInfo   SessionInfo
-	sessionMarkerBlobIDs []blob.ID // session marker blobs written so far
+	sessionMarkerBlobIDs []blob.ID       // session marker blobs written so far
+	sessionJournal       *sessionJournal // local journal of the current session, nil if not enabled
 
 	// +checklocks:mu
 	pendingPacks map[blob.ID]*pendingPackInfo
@@ -93,6 +98,8 @@ type WriteManager struct {
 	disableIndexFlushCount int
 	// +checklocks:mu
 	flushPackIndexesAfter time.Time // time when those indexes should be flushed
+	// +checklocks:mu
+	maxPendingIndexEntries int // flush indexes when they have this many entries, 0 == no limit
 
 	onUpload func(int64)
 
@@ -210,9 +217,19 @@ func (bm *WriteManager) contentWriteTime(previousUnixTimeSeconds int64) int64 {
 	return previousUnixTimeSeconds + 1
 }
 
-func (bm *WriteManager) maybeFlushBasedOnTimeUnlocked(ctx context.Context) error {
+// SetMaxPendingIndexEntries limits the number of index entries buffered in memory before indexes are flushed,
+// 0 removes the limit.
+func (bm *WriteManager) SetMaxPendingIndexEntries(n int) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	bm.maxPendingIndexEntries = n
+}
+
+func (bm *WriteManager) maybeFlushIndexesUnlocked(ctx context.Context) error {
 	bm.lock()
-	shouldFlush := bm.timeNow().After(bm.flushPackIndexesAfter)
+	shouldFlush := bm.timeNow().After(bm.flushPackIndexesAfter) ||
+		(bm.maxPendingIndexEntries > 0 && len(bm.packIndexBuilder) >= bm.maxPendingIndexEntries)
 	bm.unlock(ctx)
 
 	if !shouldFlush {
@@ -250,13 +267,6 @@ func (bm *WriteManager) maybeRetryWritingFailedPacksUnlocked(ctx context.Context
 }
 
 func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, data gather.Bytes, isDeleted bool, comp compression.HeaderID, previousWriteTime int64, mp format.MutableParameters) error {
-	// see if the current index is old enough to cause automatic flush.
-	if err := bm.maybeFlushBasedOnTimeUnlocked(ctx); err != nil {
-		return errors.Wrap(err, "unable to flush old pending writes")
-	}
-
-	prefix := packPrefixForContentID(contentID)
-
 	var compressedAndEncrypted gather.WriteBuffer
 	defer compressedAndEncrypted.Close()
 
@@ -266,10 +276,30 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 		return errors.Wrapf(err, "unable to encrypt %q", contentID)
 	}
 
+	return bm.addPackedToPackUnlocked(ctx, Info{
+		ContentID:           contentID,
+		Deleted:             isDeleted,
+		CompressionHeaderID: actualComp,
+		EncryptionKeyID:     bm.encryptionKeyIDForPrefix(contentID.Prefix()),
+		OriginalLength:      uint32(data.Length()), //nolint:gosec
+	}, compressedAndEncrypted.Bytes(), previousWriteTime, mp)
+}
+
+// addPackedToPackUnlocked appends already compressed and encrypted content data to a pending pack.
+// The provided info describes the content, its pack location and timestamp are filled in.
+func (bm *WriteManager) addPackedToPackUnlocked(ctx context.Context, info Info, packed gather.Bytes, previousWriteTime int64, mp format.MutableParameters) error {
+	// see if the current index is old enough to cause automatic flush.
+	if err := bm.maybeFlushIndexesUnlocked(ctx); err != nil {
+		return errors.Wrap(err, "unable to flush old pending writes")
+	}
+
+	contentID := info.ContentID
+	prefix := packPrefixForContentID(contentID)
+
 	bm.lock()
 
 	if previousWriteTime < 0 {
-		if _, _, err = bm.getContentInfoReadLocked(ctx, contentID); err == nil {
+		if _, _, err := bm.getContentInfoReadLocked(ctx, contentID); err == nil {
 			// we lost the race while compressing the content, the content now exists.
 			bm.unlock(ctx)
 			return nil
@@ -293,7 +323,7 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 	for _, pp := range fp {
 		bm.log.Debugf("retry-write %v", pp.packBlobID)
 
-		if err = bm.writePackAndAddToIndexLocked(ctx, pp); err != nil {
+		if err := bm.writePackAndAddToIndexLocked(ctx, pp); err != nil {
 			bm.unlock(ctx)
 			return errors.Wrap(err, "error writing previously failed pack")
 		}
@@ -305,22 +335,16 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 		return errors.Wrap(err, "unable to create pending pack")
 	}
 
-	info := Info{
-		Deleted:          isDeleted,
-		ContentID:        contentID,
-		PackBlobID:       pp.packBlobID,
-		PackOffset:       uint32(pp.currentPackData.Length()), //nolint:gosec
-		TimestampSeconds: bm.contentWriteTime(previousWriteTime),
-		FormatVersion:    byte(mp.Version),
-		OriginalLength:   uint32(data.Length()), //nolint:gosec
-	}
+	info.PackBlobID = pp.packBlobID
+	info.PackOffset = uint32(pp.currentPackData.Length()) //nolint:gosec
+	info.TimestampSeconds = bm.contentWriteTime(previousWriteTime)
+	info.FormatVersion = byte(mp.Version)
 
-	if _, err := compressedAndEncrypted.Bytes().WriteTo(pp.currentPackData); err != nil {
+	if _, err := packed.WriteTo(pp.currentPackData); err != nil {
 		bm.unlock(ctx)
 		return errors.Wrapf(err, "unable to append %q to pack data", contentID)
 	}
 
-	info.CompressionHeaderID = actualComp
 	info.PackedLength = uint32(pp.currentPackData.Length()) - info.PackOffset //nolint:gosec
 
 	pp.currentPackItems[contentID] = info
@@ -662,6 +686,70 @@ func (bm *WriteManager) RewriteContent(ctx context.Context, contentID ID) error
 	return bm.rewriteContent(ctx, contentID, false, mp)
 }
 
+// RewriteContentWithCompression reads and re-writes a given content using the provided compression,
+// preserving its timestamp and deleted status.
+func (bm *WriteManager) RewriteContentWithCompression(ctx context.Context, contentID ID, comp compression.HeaderID) error {
+	bm.log.Debugf("rewrite-content-with-compression %v %x", contentID, comp)
+
+	mp, mperr := bm.format.GetMutableParameters(ctx)
+	if mperr != nil {
+		return errors.Wrap(mperr, "mutable parameters")
+	}
+
+	if copied, err := bm.maybeCopyPackedContent(ctx, contentID, false, mp); copied || err != nil {
+		return err
+	}
+
+	var data gather.WriteBuffer
+	defer data.Close()
+
+	bi, err := bm.getContentDataAndInfo(ctx, contentID, &data)
+	if err != nil {
+		return errors.Wrap(err, "unable to get content data and info")
+	}
+
+	return bm.addToPackUnlocked(ctx, contentID, data.Bytes(), bi.Deleted, comp, bi.TimestampSeconds, mp)
+}
+
+// maybeCopyPackedContent copies the content without decrypting it, if it's encrypted using a key different
+// from the one this connection uses for new contents, such as a key of another encryption domain.
+// Returns true if the content was copied.
+func (bm *WriteManager) maybeCopyPackedContent(ctx context.Context, contentID ID, onlyRewriteDeleted bool, mp format.MutableParameters) (bool, error) {
+	var packed gather.WriteBuffer
+	defer packed.Close()
+
+	bm.mu.RLock()
+
+	pp, bi, err := bm.getContentInfoReadLocked(ctx, contentID)
+	if err != nil {
+		bm.mu.RUnlock()
+		return false, errors.Wrap(err, "unable to get content info")
+	}
+
+	if bi.EncryptionKeyID == bm.encryptionKeyIDForPrefix(contentID.Prefix()) {
+		bm.mu.RUnlock()
+		return false, nil
+	}
+
+	err = bm.getPackedContentReadLocked(ctx, pp, bi, &packed)
+
+	bm.mu.RUnlock()
+
+	if err != nil {
+		return false, err
+	}
+
+	if onlyRewriteDeleted {
+		if !bi.Deleted {
+			return true, nil
+		}
+
+		bi.Deleted = false
+	}
+
+	return true, bm.addPackedToPackUnlocked(ctx, bi, packed.Bytes(), bi.TimestampSeconds, mp)
+}
+
 func (bm *WriteManager) getContentDataAndInfo(ctx context.Context, contentID ID, output *gather.WriteBuffer) (Info, error) {
 	// acquire read lock since to prevent flush from happening between getContentInfoReadLocked() and getContentDataReadLocked().
 	bm.mu.RLock()
@@ -700,6 +788,10 @@ func (bm *WriteManager) UndeleteContent(ctx context.Context, contentID ID) error
 //
 // and the content's deleted status is preserved.
 func (bm *WriteManager) rewriteContent(ctx context.Context, contentID ID, onlyRewriteDeleted bool, mp format.MutableParameters) error {
+	if copied, err := bm.maybeCopyPackedContent(ctx, contentID, onlyRewriteDeleted, mp); copied || err != nil {
+		return err
+	}
+
 	var data gather.WriteBuffer
 	defer data.Close()
 
@@ -735,6 +827,11 @@ func (bm *WriteManager) getOrCreatePendingPackInfoLocked(ctx context.Context, pr
 		return pp, nil
 	}
 
+	// fail before starting a session, since none of the blobs could be written anyway.
+	if bm.IsReadOnly() {
+		return nil, ErrRepositoryReadOnly
+	}
+
 	bm.repoLogManager.Enable() // signal to the log manager that a write operation will be attempted so it is OK to write log blobs to the repo
 
 	b := gather.NewWriteBuffer()
@@ -761,9 +858,15 @@ func (bm *WriteManager) getOrCreatePendingPackInfoLocked(ctx context.Context, pr
 		return nil, errors.Wrap(err, "unable to prepare content preamble")
 	}
 
+	packBlobID := blob.ID(fmt.Sprintf("%v%x-%v", prefix, blobID, sessionID))
+
+	if err := bm.sessionJournal.addPackBlob(packBlobID); err != nil {
+		bm.log.Debugf("unable to record pack blob in session journal: %v", err)
+	}
+
 	bm.pendingPacks[prefix] = &pendingPackInfo{
 		prefix:           prefix,
-		packBlobID:       blob.ID(fmt.Sprintf("%v%x-%v", prefix, blobID, sessionID)),
+		packBlobID:       packBlobID,
 		currentPackItems: map[ID]Info{},
 		currentPackData:  b,
 	}
@@ -795,15 +898,9 @@ func (bm *WriteManager) WriteContent(ctx context.Context, data gather.Bytes, pre
 		return EmptyID, err
 	}
 
-	if err := prefix.ValidateSingle(); err != nil {
-		return EmptyID, errors.Wrap(err, "invalid prefix")
-	}
-
-	var hashOutput [hashing.MaxHashSize]byte
-
-	contentID, err := IDFromHash(prefix, bm.hashData(hashOutput[:0], data))
+	contentID, err := bm.ContentIDForData(data, prefix)
 	if err != nil {
-		return EmptyID, errors.Wrap(err, "invalid hash")
+		return EmptyID, err
 	}
 
 	previousWriteTime := int64(-1)
@@ -838,6 +935,27 @@ func (bm *WriteManager) WriteContent(ctx context.Context, data gather.Bytes, pre
 	return contentID, bm.addToPackUnlocked(ctx, contentID, data, false, comp, previousWriteTime, mp)
 }
 
+// ContentIDForData returns the ID of the content which WriteContent would write for the provided data and prefix.
+func (bm *WriteManager) ContentIDForData(data gather.Bytes, prefix index.IDPrefix) (ID, error) {
+	if err := prefix.ValidateSingle(); err != nil {
+		return EmptyID, errors.Wrap(err, "invalid prefix")
+	}
+
+	var hashOutput [hashing.MaxHashSize]byte
+
+	crypter, err := bm.crypterForKeyID(bm.encryptionKeyIDForPrefix(prefix))
+	if err != nil {
+		return EmptyID, err
+	}
+
+	contentID, err := IDFromHash(prefix, bm.hashDataWithCrypter(crypter, hashOutput[:0], data))
+	if err != nil {
+		return EmptyID, errors.Wrap(err, "invalid hash")
+	}
+
+	return contentID, nil
+}
+
 // GetContent gets the contents of a given content. If the content is not found returns ErrContentNotFound.
 func (bm *WriteManager) GetContent(ctx context.Context, contentID ID) (v []byte, err error) {
 	t0 := timetrack.StartTimer()
@@ -951,6 +1069,16 @@ type ManagerOptions struct {
 	TimeNow                func() time.Time // Time provider
 	DisableInternalLog     bool
 	PermissiveCacheLoading bool
+
+	// VerifyContentHashOnRead causes all contents to be re-hashed after decryption and compared
+	// against their content IDs, regardless of the repository-level setting.
+	VerifyContentHashOnRead bool
+
+	// EncryptionDomains are the encryption domains unlocked by this connection, whose contents can be read.
+	EncryptionDomains []*format.EncryptionDomainCrypter
+
+	// WriteEncryptionDomain is the name of the encryption domain in which new contents other than manifests are written.
+	WriteEncryptionDomain string
 }
 
 // CloneOrDefault returns a clone of provided ManagerOptions or default empty struct if nil.
@@ -0,0 +1,145 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/format"
+)
+
+// cachedDictionaryCompressor is a compressor built from a particular set of repository compression dictionaries.
+type cachedDictionaryCompressor struct {
+	numDictionaries int
+	activeID        uint32
+
+	compressor  compression.Compressor
+	canCompress bool
+}
+
+// getDictionaryCompressor returns the compressor using repository compression dictionaries
+// or nil if the repository has none.
+func (sm *SharedManager) getDictionaryCompressor() (*cachedDictionaryCompressor, error) {
+	dicts := sm.format.GetCompressionDictionaries()
+	if len(dicts) == 0 {
+		return nil, nil
+	}
+
+	// the newest active dictionary is used for compression.
+	var active *format.CompressionDictionary
+
+	now := sm.timeNow()
+
+	for i := range dicts {
+		if dicts[i].IsActive(now) && (active == nil || dicts[i].ID > active.ID) {
+			active = &dicts[i]
+		}
+	}
+
+	var activeID uint32
+
+	var activeData []byte
+
+	if active != nil {
+		activeID = active.ID
+		activeData = active.Data
+	}
+
+	sm.dictionaryCompressorMutex.Lock()
+	defer sm.dictionaryCompressorMutex.Unlock()
+
+	// dictionaries are only ever added, so their count and the active one identify the set.
+	if dc := sm.dictionaryCompressor; dc != nil && dc.numDictionaries == len(dicts) && dc.activeID == activeID {
+		return dc, nil
+	}
+
+	var all [][]byte
+
+	for _, d := range dicts {
+		all = append(all, d.Data)
+	}
+
+	c, err := compression.NewZstdDictionaryCompressor(activeData, all)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dictionary compressor")
+	}
+
+	sm.dictionaryCompressor = &cachedDictionaryCompressor{
+		numDictionaries: len(dicts),
+		activeID:        activeID,
+		compressor:      c,
+		canCompress:     active != nil,
+	}
+
+	return sm.dictionaryCompressor, nil
+}
+
+// compressorForContent returns the compressor to use for the provided content when the given compression is requested.
+// Metadata contents are compressed using the repository compression dictionary when one is active.
+func (sm *SharedManager) compressorForContent(contentID ID, comp compression.HeaderID) (compression.Compressor, error) {
+	if contentID.HasPrefix() || comp == compression.HeaderZstdDictionary {
+		dc, err := sm.getDictionaryCompressor()
+		if err != nil {
+			return nil, err
+		}
+
+		if dc != nil && dc.canCompress {
+			return dc.compressor, nil
+		}
+
+		// rewriting content that was compressed with a dictionary, but none is active.
+		if comp == compression.HeaderZstdDictionary {
+			comp = compression.HeaderZstdDefault
+		}
+	}
+
+	c := compression.ByHeaderID[comp]
+	if c == nil {
+		return nil, errors.Errorf("unsupported compressor %x", comp)
+	}
+
+	return c, nil
+}
+
+var errEnoughSamples = errors.New("enough samples")
+
+// CompressionDictionarySamples returns payloads of up to maxCount contents with the provided prefixes
+// totaling at most maxBytes, to be used for training compression dictionaries.
+func CompressionDictionarySamples(ctx context.Context, r Reader, prefixes []IDPrefix, maxCount, maxBytes int) ([][]byte, error) {
+	var (
+		samples    [][]byte
+		totalBytes int
+	)
+
+	for i, prefix := range prefixes {
+		// split the remaining budget evenly among prefixes that are still to be sampled.
+		prefixMaxCount := len(samples) + (maxCount-len(samples))/(len(prefixes)-i)
+
+		err := r.IterateContents(ctx, IterateOptions{Range: index.PrefixRange(prefix)}, func(ci Info) error {
+			if len(samples) >= prefixMaxCount {
+				return errEnoughSamples
+			}
+
+			if totalBytes+int(ci.OriginalLength) > maxBytes {
+				return nil
+			}
+
+			data, err := r.GetContent(ctx, ci.ContentID)
+			if err != nil {
+				return errors.Wrapf(err, "unable to read content %v", ci.ContentID)
+			}
+
+			samples = append(samples, data)
+			totalBytes += len(data)
+
+			return nil
+		})
+		if err != nil && !errors.Is(err, errEnoughSamples) {
+			return nil, errors.Wrap(err, "error sampling contents")
+		}
+	}
+
+	return samples, nil
+}
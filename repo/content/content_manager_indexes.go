@@ -63,16 +63,30 @@ func (sm *SharedManager) CompactIndexes(ctx context.Context, opt indexblob.Compa
 
 // ParseIndexBlob loads entries in a given index blob and returns them.
 func ParseIndexBlob(blobID blob.ID, encrypted gather.Bytes, crypter blobcrypto.Crypter) ([]Info, error) {
+	_, results, err := ParseIndexBlobWithVersion(blobID, encrypted, crypter)
+
+	return results, err
+}
+
+// ParseIndexBlobWithVersion is like ParseIndexBlob but also returns the index format version.
+func ParseIndexBlobWithVersion(blobID blob.ID, encrypted gather.Bytes, crypter blobcrypto.Crypter) (int, []Info, error) {
 	var data gather.WriteBuffer
 	defer data.Close()
 
 	if err := blobcrypto.Decrypt(crypter, encrypted, blobID, &data); err != nil {
-		return nil, errors.Wrap(err, "unable to decrypt index blob")
+		return 0, nil, errors.Wrap(err, "unable to decrypt index blob")
+	}
+
+	b := data.Bytes().ToByteSlice()
+
+	version, err := index.FormatVersion(b)
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "unable to open index blob")
 	}
 
-	ndx, err := index.Open(data.Bytes().ToByteSlice(), nil, crypter.Encryptor().Overhead)
+	ndx, err := index.Open(b, nil, crypter.Encryptor().Overhead)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to open index blob")
+		return 0, nil, errors.Wrapf(err, "unable to open index blob")
 	}
 
 	var results []Info
@@ -82,5 +96,5 @@ func ParseIndexBlob(blobID blob.ID, encrypted gather.Bytes, crypter blobcrypto.C
 		return nil
 	})
 
-	return results, errors.Wrap(err, "error iterating index entries")
+	return version, results, errors.Wrap(err, "error iterating index entries")
 }
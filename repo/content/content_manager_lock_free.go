@@ -12,6 +12,7 @@
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/kopia/kopia/internal/blobcrypto"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/repo/blob"
@@ -29,6 +30,11 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes
 
 	iv := getPackedContentIV(hashOutput[:0], contentID)
 
+	crypter, err := sm.crypterForKeyID(sm.encryptionKeyIDForPrefix(contentID.Prefix()))
+	if err != nil {
+		return NoCompression, err
+	}
+
 	// If the content is prefixed (which represents Kopia's own metadata as opposed to user data),
 	// and we're on < V2 format, disable compression even when its requested.
 	if contentID.HasPrefix() && mp.IndexVersion < index.Version2 {
@@ -45,11 +51,13 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes
 		defer tmp.Close()
 
 		// allocate temporary buffer to hold the compressed bytes.
-		c := compression.ByHeaderID[comp]
-		if c == nil {
-			return NoCompression, errors.Errorf("unsupported compressor %x", comp)
+		c, err := sm.compressorForContent(contentID, comp)
+		if err != nil {
+			return NoCompression, err
 		}
 
+		comp = c.HeaderID()
+
 		t0 := timetrack.StartTimer()
 
 		if err := c.Compress(&tmp, data.Reader()); err != nil {
@@ -74,7 +82,7 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(data gather.Bytes
 
 	t1 := timetrack.StartTimer()
 
-	if err := sm.format.Encryptor().Encrypt(data, iv, output); err != nil {
+	if err := crypter.Encryptor().Encrypt(data, iv, output); err != nil {
 		return NoCompression, errors.Wrap(err, "unable to encrypt")
 	}
 
@@ -107,17 +115,26 @@ func (sm *SharedManager) getContentDataReadLocked(ctx context.Context, pp *pendi
 	var payload gather.WriteBuffer
 	defer payload.Close()
 
+	if err := sm.getPackedContentReadLocked(ctx, pp, bi, &payload); err != nil {
+		return err
+	}
+
+	return sm.decryptContentAndVerify(ctx, payload.Bytes(), bi, output)
+}
+
+// getPackedContentReadLocked reads compressed and encrypted content data, as stored in the pack.
+func (sm *SharedManager) getPackedContentReadLocked(ctx context.Context, pp *pendingPackInfo, bi Info, payload *gather.WriteBuffer) error {
 	if pp != nil && pp.packBlobID == bi.PackBlobID {
 		// we need to use a lock here in case somebody else writes to the pack at the same time.
-		if err := pp.currentPackData.AppendSectionTo(&payload, int(bi.PackOffset), int(bi.PackedLength)); err != nil {
+		if err := pp.currentPackData.AppendSectionTo(payload, int(bi.PackOffset), int(bi.PackedLength)); err != nil {
 			// should never happen
 			return errors.Wrap(err, "error appending pending content data to buffer")
 		}
-	} else if err := sm.getCacheForContentID(bi.ContentID).GetContent(ctx, contentCacheKeyForInfo(bi), bi.PackBlobID, int64(bi.PackOffset), int64(bi.PackedLength), &payload); err != nil {
+	} else if err := sm.getCacheForContentID(bi.ContentID).GetContent(ctx, contentCacheKeyForInfo(bi), bi.PackBlobID, int64(bi.PackOffset), int64(bi.PackedLength), payload); err != nil {
 		return errors.Wrapf(err, "error getting cached content from blob %q", bi.PackBlobID)
 	}
 
-	return sm.decryptContentAndVerify(payload.Bytes(), bi, output)
+	return nil
 }
 
 func (sm *SharedManager) preparePackDataContent(mp format.MutableParameters, pp *pendingPackInfo) (index.Builder, error) {
@@ -194,9 +211,13 @@ func (sm *SharedManager) writePackFileNotLocked(ctx context.Context, packFile bl
 }
 
 func (sm *SharedManager) hashData(output []byte, data gather.Bytes) []byte {
+	return sm.hashDataWithCrypter(sm.format, output, data)
+}
+
+func (sm *SharedManager) hashDataWithCrypter(crypter blobcrypto.Crypter, output []byte, data gather.Bytes) []byte {
 	// Hash the content and compute encryption key.
 	t0 := timetrack.StartTimer()
-	contentID := sm.format.HashFunc()(output, data)
+	contentID := crypter.HashFunc()(output, data)
 	sm.Stats.hashedContent(data.Length())
 
 	sm.hashedBytes.Observe(int64(data.Length()), t0.Elapsed())
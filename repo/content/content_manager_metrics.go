@@ -27,6 +27,9 @@ type metricsStruct struct {
 	decryptedBytes            *metrics.Throughput
 	compressionAttemptedBytes *metrics.Throughput
 	decompressedBytes         *metrics.Throughput
+	verifiedBytes             *metrics.Throughput
+
+	verifyContentErrorCount *metrics.Counter
 
 	deduplicatedBytes    *metrics.Counter
 	deduplicatedContents *metrics.Counter
@@ -37,6 +40,7 @@ func initMetricsStruct(mr *metrics.Registry) metricsStruct {
 		uploadedBytes:           mr.CounterInt64("content_uploaded_bytes", "Number of bytes uploaded from content manager.", nil),
 		getContentErrorCount:    mr.CounterInt64("content_get_error_count", "Number of time GetContent() was called and the result was an error", nil),
 		getContentNotFoundCount: mr.CounterInt64("content_get_not_found_count", "Number of time GetContent() was called and the result was not found", nil),
+		verifyContentErrorCount: mr.CounterInt64("content_verify_error_count", "Number of contents whose hash did not match their ID when verified on read", nil),
 		deduplicatedContents:    mr.CounterInt64("content_deduplicated", "Number of contents deduplicated.", nil),
 		deduplicatedBytes:       mr.CounterInt64("content_deduplicated_bytes", "Number of bytes deduplicated.", nil),
 
@@ -54,5 +58,6 @@ func initMetricsStruct(mr *metrics.Registry) metricsStruct {
 		getContentBytes:   mr.Throughput("content_read", "Number of bytes read", nil),
 		decryptedBytes:    mr.Throughput("content_decrypted", "Decryption throughput.", nil),
 		decompressedBytes: mr.Throughput("content_decompressed", "Decompression throughput.", nil),
+		verifiedBytes:     mr.Throughput("content_verified", "Content hash verification throughput.", nil),
 	}
 }
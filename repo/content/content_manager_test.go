@@ -32,6 +32,7 @@
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/logging"
+	"github.com/kopia/kopia/repo/blob/readonly"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content/index"
 	"github.com/kopia/kopia/repo/content/indexblob"
@@ -293,6 +294,26 @@ func (s *contentManagerSuite) TestContentManagerInternalFlush(t *testing.T) {
 	}
 }
 
+func (s *contentManagerSuite) TestContentManagerMaxPendingIndexEntries(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	bm := s.newTestContentManager(t, st)
+
+	defer bm.CloseShared(ctx)
+
+	bm.SetMaxPendingIndexEntries(10)
+
+	itemsToOverflow := (maxPackCapacity)/(25+encryptionOverhead) + 2
+	for range itemsToOverflow {
+		b := make([]byte, 25)
+		cryptorand.Read(b)
+		writeContentAndVerify(ctx, t, bm, b)
+	}
+
+	verifyActiveIndexBlobCount(ctx, t, bm, 1)
+}
+
 func (s *contentManagerSuite) TestContentManagerWriteMultiple(t *testing.T) {
 	ctx := testlogging.Context(t)
 	data := blobtesting.DataMap{}
@@ -615,6 +636,37 @@ func (s *contentManagerSuite) TestDeleteContent(t *testing.T) {
 	verifyContentNotFound(ctx, t, bm, content2)
 }
 
+func (s *contentManagerSuite) TestReadOnlyRepositoryRejectsWrites(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	bm := s.newTestContentManager(t, st)
+	content1 := writeContentAndVerify(ctx, t, bm, seededRandomData(10, 100))
+	require.NoError(t, bm.Flush(ctx))
+	require.NoError(t, bm.CloseShared(ctx))
+
+	blobsBefore := len(data)
+
+	bm = s.newTestContentManager(t, readonly.NewWrapper(st))
+	defer bm.CloseShared(ctx)
+
+	require.True(t, bm.IsReadOnly())
+
+	// writing existing content is a no-op and succeeds.
+	require.Equal(t, content1, writeContentAndVerify(ctx, t, bm, seededRandomData(10, 100)))
+
+	_, err := bm.WriteContent(ctx, gather.FromSlice(seededRandomData(11, 100)), "", NoCompression)
+	require.ErrorIs(t, err, ErrRepositoryReadOnly)
+	require.ErrorIs(t, err, readonly.ErrReadonly)
+
+	require.ErrorIs(t, bm.DeleteContent(ctx, content1), ErrRepositoryReadOnly)
+	require.NoError(t, bm.Flush(ctx))
+
+	verifyContent(ctx, t, bm, content1, seededRandomData(10, 100))
+	require.Len(t, data, blobsBefore)
+}
+
 func (s *contentManagerSuite) TestDeletionAfterCreationWithFrozenTime(t *testing.T) {
 	ctx := testlogging.Context(t)
 	data := blobtesting.DataMap{}
@@ -2055,6 +2107,116 @@ func verifyContentManagerDataSet(ctx context.Context, t *testing.T, mgr *WriteMa
 	}
 }
 
+func (s *contentManagerSuite) TestCompressionDictionaryForMetadata(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	dictData, err := compression.TrainZstdDictionary(compression.MinZstdDictionaryID,
+		[][]byte{dirMetadataContent().ToByteSlice(), indirectMetadataContent().ToByteSlice()}, 16384)
+	require.NoError(t, err)
+
+	pendingDictData, err := compression.TrainZstdDictionary(compression.MinZstdDictionaryID+1,
+		[][]byte{indirectMetadataContent().ToByteSlice(), dirMetadataContent().ToByteSlice()}, 16384)
+	require.NoError(t, err)
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	bm := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+		compressionDictionaries: []format.CompressionDictionary{
+			{ID: compression.MinZstdDictionaryID, CreatedTime: fakeTime.Add(-format.CompressionDictionaryActivationDelay), Data: dictData},
+			{ID: compression.MinZstdDictionaryID + 1, CreatedTime: fakeTime, Data: pendingDictData},
+		},
+	})
+
+	if !bm.SupportsContentCompression() {
+		return
+	}
+
+	// metadata is compressed using the active dictionary.
+	dirID, err := bm.WriteContent(ctx, dirMetadataContent(), "k", compression.HeaderZstdFastest)
+	require.NoError(t, err)
+
+	info, err := bm.ContentInfo(ctx, dirID)
+	require.NoError(t, err)
+	require.Equal(t, compression.HeaderZstdDictionary, info.CompressionHeaderID)
+
+	// regular contents are not.
+	dataID, err := bm.WriteContent(ctx, dirMetadataContent(), "", compression.HeaderZstdFastest)
+	require.NoError(t, err)
+
+	dataInfo, err := bm.ContentInfo(ctx, dataID)
+	require.NoError(t, err)
+	require.Equal(t, compression.HeaderZstdFastest, dataInfo.CompressionHeaderID)
+	require.Less(t, info.PackedLength, dataInfo.PackedLength)
+
+	verifyContent(ctx, t, bm, dirID, dirMetadataContent().ToByteSlice())
+	require.NoError(t, bm.Flush(ctx))
+
+	// another client that knows the dictionaries can read the content.
+	bm2 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+		compressionDictionaries: []format.CompressionDictionary{
+			{ID: compression.MinZstdDictionaryID, CreatedTime: fakeTime, Data: dictData},
+		},
+	})
+
+	verifyContent(ctx, t, bm2, dirID, dirMetadataContent().ToByteSlice())
+
+	// the client does not consider the dictionary active yet and uses regular compression.
+	dirID2, err := bm2.WriteContent(ctx, indirectMetadataContent(), "x", compression.HeaderZstdFastest)
+	require.NoError(t, err)
+
+	info2, err := bm2.ContentInfo(ctx, dirID2)
+	require.NoError(t, err)
+	require.Equal(t, compression.HeaderZstdFastest, info2.CompressionHeaderID)
+
+	// client that does not know the dictionary fails to read.
+	bm3 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		indexVersion: index.Version2,
+	})
+
+	_, err = bm3.GetContent(ctx, dirID)
+	require.ErrorContains(t, err, "compression dictionary unknown")
+}
+
+func (s *contentManagerSuite) TestVerifyContentHashOnRead(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	bm := s.newTestContentManager(t, st)
+	require.False(t, bm.shouldVerifyContentHashOnRead(ctx))
+
+	// repository-level setting.
+	bm2 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		verifyContentHashOnRead: true,
+	})
+	require.True(t, bm2.shouldVerifyContentHashOnRead(ctx))
+
+	// per-connection setting.
+	bm3 := s.newTestContentManagerWithTweaks(t, st, &contentManagerTestTweaks{
+		ManagerOptions: ManagerOptions{VerifyContentHashOnRead: true},
+	})
+	require.True(t, bm3.shouldVerifyContentHashOnRead(ctx))
+
+	data1 := seededRandomData(10, 100)
+	data2 := seededRandomData(11, 100)
+
+	id1 := writeContentAndVerify(ctx, t, bm3, data1)
+	id2 := writeContentAndVerify(ctx, t, bm3, data2)
+	require.NoError(t, bm3.Flush(ctx))
+
+	verifyContent(ctx, t, bm3, id1, data1)
+
+	info2, err := bm3.ContentInfo(ctx, id2)
+	require.NoError(t, err)
+
+	// data that does not match the content ID is rejected and counted.
+	invalidBefore := bm3.Stats.InvalidContents()
+
+	require.NoError(t, bm3.verifyContentHash(gather.FromSlice(data2), info2))
+	require.ErrorContains(t, bm3.verifyContentHash(gather.FromSlice(data1), info2), "content hash mismatch")
+	require.Equal(t, invalidBefore+1, bm3.Stats.InvalidContents())
+}
+
 func (s *contentManagerSuite) TestCompression_Disabled(t *testing.T) {
 	data := blobtesting.DataMap{}
 	st := blobtesting.NewMapStorage(data, nil, nil)
@@ -2226,7 +2388,7 @@ func (s *contentManagerSuite) TestPrefetchContent(t *testing.T) {
 	ccm := bm.metadataCache
 
 	hints := []string{
-		"", "default", "contents", "blobs", "none",
+		"", "default", "contents", "blobs", "none", PrefetchHintRanges,
 	}
 
 	cases := []struct {
@@ -2240,11 +2402,12 @@ func (s *contentManagerSuite) TestPrefetchContent(t *testing.T) {
 			input:      []ID{id1, id2, id3, id4, id5, id6},
 			wantResult: []ID{id1, id2, id3, id4, id5, id6},
 			wantDataCacheKeys: map[string][]string{
-				"":         {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
-				"default":  {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
-				"contents": {contentIDCacheKey(id1), contentIDCacheKey(id2), contentIDCacheKey(id3), contentIDCacheKey(id4), contentIDCacheKey(id5), contentIDCacheKey(id6)},
-				"blobs":    {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
-				"none":     {},
+				"":                 {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
+				"default":          {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
+				"contents":         {contentIDCacheKey(id1), contentIDCacheKey(id2), contentIDCacheKey(id3), contentIDCacheKey(id4), contentIDCacheKey(id5), contentIDCacheKey(id6)},
+				PrefetchHintRanges: {contentIDCacheKey(id1), contentIDCacheKey(id2), contentIDCacheKey(id3), contentIDCacheKey(id4), contentIDCacheKey(id5), contentIDCacheKey(id6)},
+				"blobs":            {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
+				"none":             {},
 			},
 		},
 		{
@@ -2252,11 +2415,12 @@ func (s *contentManagerSuite) TestPrefetchContent(t *testing.T) {
 			input:      []ID{id1},
 			wantResult: []ID{id1},
 			wantDataCacheKeys: map[string][]string{
-				"":         {contentIDCacheKey(id1)},
-				"default":  {contentIDCacheKey(id1)},
-				"contents": {contentIDCacheKey(id1)},
-				"blobs":    {cache.BlobIDCacheKey(blob1)},
-				"none":     {},
+				"":                 {contentIDCacheKey(id1)},
+				"default":          {contentIDCacheKey(id1)},
+				"contents":         {contentIDCacheKey(id1)},
+				PrefetchHintRanges: {contentIDCacheKey(id1)},
+				"blobs":            {cache.BlobIDCacheKey(blob1)},
+				"none":             {},
 			},
 		},
 		{
@@ -2264,11 +2428,12 @@ func (s *contentManagerSuite) TestPrefetchContent(t *testing.T) {
 			input:      []ID{id1, id4},
 			wantResult: []ID{id1, id4},
 			wantDataCacheKeys: map[string][]string{
-				"":         {contentIDCacheKey(id1), contentIDCacheKey(id4)},
-				"default":  {contentIDCacheKey(id1), contentIDCacheKey(id4)},
-				"contents": {contentIDCacheKey(id1), contentIDCacheKey(id4)},
-				"blobs":    {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
-				"none":     {},
+				"":                 {contentIDCacheKey(id1), contentIDCacheKey(id4)},
+				"default":          {contentIDCacheKey(id1), contentIDCacheKey(id4)},
+				"contents":         {contentIDCacheKey(id1), contentIDCacheKey(id4)},
+				PrefetchHintRanges: {contentIDCacheKey(id1), contentIDCacheKey(id4)},
+				"blobs":            {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
+				"none":             {},
 			},
 		},
 		{
@@ -2276,11 +2441,12 @@ func (s *contentManagerSuite) TestPrefetchContent(t *testing.T) {
 			input:      []ID{id1, id4, id5},
 			wantResult: []ID{id1, id4, id5},
 			wantDataCacheKeys: map[string][]string{
-				"":         {contentIDCacheKey(id1), cache.BlobIDCacheKey(blob2)},
-				"default":  {contentIDCacheKey(id1), cache.BlobIDCacheKey(blob2)},
-				"contents": {contentIDCacheKey(id1), contentIDCacheKey(id4), contentIDCacheKey(id5)},
-				"blobs":    {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
-				"none":     {},
+				"":                 {contentIDCacheKey(id1), cache.BlobIDCacheKey(blob2)},
+				"default":          {contentIDCacheKey(id1), cache.BlobIDCacheKey(blob2)},
+				"contents":         {contentIDCacheKey(id1), contentIDCacheKey(id4), contentIDCacheKey(id5)},
+				PrefetchHintRanges: {contentIDCacheKey(id1), contentIDCacheKey(id4), contentIDCacheKey(id5)},
+				"blobs":            {cache.BlobIDCacheKey(blob1), cache.BlobIDCacheKey(blob2)},
+				"none":             {},
 			},
 		},
 	}
@@ -2459,9 +2625,11 @@ type contentManagerTestTweaks struct {
 	CachingOptions
 	ManagerOptions
 
-	indexVersion  int
-	maxPackSize   int
-	formatVersion format.Version
+	indexVersion            int
+	maxPackSize             int
+	formatVersion           format.Version
+	compressionDictionaries []format.CompressionDictionary
+	verifyContentHashOnRead bool
 }
 
 func (s *contentManagerSuite) newTestContentManagerWithTweaks(t *testing.T, st blob.Storage, tweaks *contentManagerTestTweaks) *WriteManager {
@@ -2490,12 +2658,16 @@ func (s *contentManagerSuite) newTestContentManagerWithTweaks(t *testing.T, st b
 		mp.Version = tweaks.formatVersion
 	}
 
+	mp.VerifyContentHashOnRead = tweaks.verifyContentHashOnRead
+
 	ctx := testlogging.Context(t)
 	fo := mustCreateFormatProvider(t, &format.ContentFormat{
 		Hash:              "HMAC-SHA256",
 		Encryption:        "AES256-GCM-HMAC-SHA256",
 		HMACSecret:        hmacSecret,
 		MutableParameters: mp,
+
+		CompressionDictionaries: tweaks.compressionDictionaries,
 	})
 
 	bm, err := NewManagerForTesting(ctx, st, fo, &tweaks.CachingOptions, &tweaks.ManagerOptions)
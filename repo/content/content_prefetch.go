@@ -3,20 +3,36 @@
 import (
 	"context"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 
+	"github.com/kopia/kopia/internal/cache"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/blob"
 )
 
+// PrefetchHintRanges is a prefetch hint that causes contents of the same pack blob located close
+// to each other to be fetched using a single ranged read, which is suitable for sequential reads
+// from high-latency storage.
+const PrefetchHintRanges = "ranges"
+
+const (
+	defaultMaxPrefetchRangeGap    = 256 << 10 // max number of unneeded bytes between contents fetched in one read
+	defaultMaxPrefetchRangeLength = 16 << 20  // max length of a single ranged read
+)
+
 type prefetchOptions struct {
 	fullBlobPrefetchCountThreshold int
 	fullBlobPrefetchBytesThreshold int64
+
+	// when non-zero, contents of each blob are fetched using coalesced ranged reads.
+	maxRangeGap    int64
+	maxRangeLength int64
 }
 
 //nolint:gochecknoglobals
-var defaultPrefetchOptions = &prefetchOptions{2, 5e6}
+var defaultPrefetchOptions = &prefetchOptions{fullBlobPrefetchCountThreshold: 2, fullBlobPrefetchBytesThreshold: 5e6}
 
 //nolint:gochecknoglobals
 var prefetchHintToOptions = map[string]*prefetchOptions{
@@ -30,6 +46,12 @@ type prefetchOptions struct {
 		fullBlobPrefetchCountThreshold: 0,
 		fullBlobPrefetchBytesThreshold: 0,
 	},
+	PrefetchHintRanges: {
+		fullBlobPrefetchCountThreshold: math.MaxInt,
+		fullBlobPrefetchBytesThreshold: math.MaxInt64,
+		maxRangeGap:                    defaultMaxPrefetchRangeGap,
+		maxRangeLength:                 defaultMaxPrefetchRangeLength,
+	},
 }
 
 func (o *prefetchOptions) shouldPrefetchEntireBlob(infos []Info) bool {
@@ -45,6 +67,45 @@ func (o *prefetchOptions) shouldPrefetchEntireBlob(infos []Info) bool {
 	return total >= o.fullBlobPrefetchBytesThreshold
 }
 
+// prefetchRange is a range of a pack blob containing one or more contents, which is fetched using a single read.
+type prefetchRange struct {
+	offset int64
+	length int64
+	infos  []Info
+}
+
+// coalescePrefetchRanges groups contents of a single pack blob into ranges, such that contents
+// separated by no more than maxGap bytes are fetched together as long as the range does not
+// exceed maxLength bytes.
+func coalescePrefetchRanges(infos []Info, maxGap, maxLength int64) []*prefetchRange {
+	sorted := append([]Info(nil), infos...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PackOffset < sorted[j].PackOffset
+	})
+
+	var (
+		result []*prefetchRange
+		cur    *prefetchRange
+	)
+
+	for _, bi := range sorted {
+		start, end := int64(bi.PackOffset), int64(bi.PackOffset)+int64(bi.PackedLength)
+
+		if cur != nil && start-(cur.offset+cur.length) <= maxGap && end-cur.offset <= maxLength {
+			cur.length = max(cur.length, end-cur.offset)
+			cur.infos = append(cur.infos, bi)
+
+			continue
+		}
+
+		cur = &prefetchRange{offset: start, length: end - start, infos: []Info{bi}}
+		result = append(result, cur)
+	}
+
+	return result
+}
+
 // PrefetchContents fetches the provided content IDs into the cache.
 // Note that due to cache configuration, it's not guaranteed that all contents will
 // actually be added to the cache.
@@ -83,6 +144,7 @@ func (bm *WriteManager) PrefetchContents(ctx context.Context, contentIDs []ID, h
 	type work struct {
 		blobID    blob.ID
 		contentID ID
+		rng       *prefetchRange
 	}
 
 	workCh := make(chan work)
@@ -93,9 +155,16 @@ type work struct {
 		defer close(workCh)
 
 		for b, infos := range contentsByBlob {
-			if o.shouldPrefetchEntireBlob(infos) {
+			switch {
+			case o.shouldPrefetchEntireBlob(infos):
 				workCh <- work{blobID: b}
-			} else {
+
+			case o.maxRangeLength > 0:
+				for _, r := range coalescePrefetchRanges(infos, o.maxRangeGap, o.maxRangeLength) {
+					workCh <- work{blobID: b, rng: r}
+				}
+
+			default:
 				for _, bi := range infos {
 					workCh <- work{contentID: bi.ContentID}
 				}
@@ -114,6 +183,10 @@ type work struct {
 
 			for w := range workCh {
 				switch {
+				case w.rng != nil:
+					if err := bm.prefetchRange(ctx, w.blobID, w.rng); err != nil {
+						bm.log.Debugw("error prefetching blob range", "blobID", w.blobID, "offset", w.rng.offset, "length", w.rng.length, "err", err)
+					}
 				case strings.HasPrefix(string(w.blobID), string(PackBlobIDPrefixRegular)):
 					if err := bm.contentCache.PrefetchBlob(ctx, w.blobID); err != nil {
 						bm.log.Debugw("error prefetching data blob", "blobID", w.blobID, "err", err)
@@ -137,3 +210,18 @@ type work struct {
 
 	return prefetched
 }
+
+func (bm *WriteManager) prefetchRange(ctx context.Context, blobID blob.ID, r *prefetchRange) error {
+	contents := make([]cache.ContentRange, 0, len(r.infos))
+
+	for _, bi := range r.infos {
+		contents = append(contents, cache.ContentRange{
+			ContentID: contentCacheKeyForInfo(bi),
+			Offset:    int64(bi.PackOffset),
+			Length:    int64(bi.PackedLength),
+		})
+	}
+
+	//nolint:wrapcheck
+	return bm.getCacheForContentID(r.infos[0].ContentID).PrefetchContentRange(ctx, blobID, r.offset, r.length, contents)
+}
@@ -0,0 +1,62 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescePrefetchRanges(t *testing.T) {
+	info := func(offset, length uint32) Info {
+		return Info{PackOffset: offset, PackedLength: length}
+	}
+
+	type rng struct {
+		offset, length int64
+		count          int
+	}
+
+	cases := []struct {
+		name  string
+		infos []Info
+		want  []rng
+	}{
+		{
+			name:  "Single",
+			infos: []Info{info(100, 10)},
+			want:  []rng{{100, 10, 1}},
+		},
+		{
+			name:  "AdjacentUnsorted",
+			infos: []Info{info(120, 10), info(100, 10), info(110, 10)},
+			want:  []rng{{100, 30, 3}},
+		},
+		{
+			name:  "SmallGap",
+			infos: []Info{info(0, 10), info(20, 10)},
+			want:  []rng{{0, 30, 2}},
+		},
+		{
+			name:  "LargeGap",
+			infos: []Info{info(0, 10), info(100, 10)},
+			want:  []rng{{0, 10, 1}, {100, 10, 1}},
+		},
+		{
+			name:  "ExceedsMaxLength",
+			infos: []Info{info(0, 400), info(400, 400), info(800, 400)},
+			want:  []rng{{0, 800, 2}, {800, 400, 1}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []rng
+
+			for _, r := range coalescePrefetchRanges(tc.infos, 50, 1000) {
+				got = append(got, rng{r.offset, r.length, len(r.infos)})
+			}
+
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
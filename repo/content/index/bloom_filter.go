@@ -0,0 +1,83 @@
+package index
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+const (
+	minBloomFilterCapacity = 1024
+	bloomFilterBitsPerWord = 64
+)
+
+// BloomFilter is a probabilistic set of content IDs, which can tell with certainty that an ID has not been added
+// and so avoids lookups in index structures for contents that are not present.
+// BloomFilter is not safe for concurrent use when IDs are being added.
+type BloomFilter struct {
+	seed      maphash.Seed
+	bits      []uint64
+	numBits   uint64
+	numHashes int
+	count     int
+	capacity  int
+}
+
+// NewBloomFilter returns a bloom filter sized for the provided number of IDs with a given false positive rate.
+func NewBloomFilter(capacity int, falsePositiveRate float64) *BloomFilter {
+	capacity = max(capacity, minBloomFilterCapacity)
+
+	numBits := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	numWords := (numBits + bloomFilterBitsPerWord - 1) / bloomFilterBitsPerWord
+	numHashes := max(int(math.Round(float64(numBits)/float64(capacity)*math.Ln2)), 1)
+
+	return &BloomFilter{
+		seed:      maphash.MakeSeed(),
+		bits:      make([]uint64, numWords),
+		numBits:   numWords * bloomFilterBitsPerWord,
+		numHashes: numHashes,
+		capacity:  capacity,
+	}
+}
+
+// hashes returns two hashes of the ID used to derive all bit positions (Kirsch-Mitzenmacher).
+func (f *BloomFilter) hashes(id ID) (h1, h2 uint64) {
+	h := maphash.Bytes(f.seed, id.data[:id.idLen]) ^ (uint64(id.prefix) * 0x9e3779b97f4a7c15) //nolint:mnd
+
+	return h, (h>>32 | h<<32) | 1 //nolint:mnd
+}
+
+// Add adds the provided ID to the filter.
+func (f *BloomFilter) Add(id ID) {
+	h1, h2 := f.hashes(id)
+
+	for i := range f.numHashes {
+		b := (h1 + uint64(i)*h2) % f.numBits
+		f.bits[b/bloomFilterBitsPerWord] |= 1 << (b % bloomFilterBitsPerWord)
+	}
+
+	f.count++
+}
+
+// MayContain returns false if the provided ID has certainly not been added to the filter.
+func (f *BloomFilter) MayContain(id ID) bool {
+	h1, h2 := f.hashes(id)
+
+	for i := range f.numHashes {
+		b := (h1 + uint64(i)*h2) % f.numBits
+		if f.bits[b/bloomFilterBitsPerWord]&(1<<(b%bloomFilterBitsPerWord)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Count returns the number of IDs added to the filter.
+func (f *BloomFilter) Count() int {
+	return f.count
+}
+
+// Capacity returns the number of IDs the filter was sized for.
+func (f *BloomFilter) Capacity() int {
+	return f.capacity
+}
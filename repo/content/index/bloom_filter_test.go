@@ -0,0 +1,44 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const (
+		n    = 100000
+		rate = 0.01
+	)
+
+	f := NewBloomFilter(n, rate)
+	require.Equal(t, n, f.Capacity())
+
+	for i := range n {
+		f.Add(deterministicContentID(t, "present", i))
+	}
+
+	require.Equal(t, n, f.Count())
+
+	// no false negatives.
+	for i := range n {
+		require.True(t, f.MayContain(deterministicContentID(t, "present", i)))
+	}
+
+	falsePositives := 0
+
+	for i := range n {
+		if f.MayContain(deterministicContentID(t, "absent", i)) {
+			falsePositives++
+		}
+	}
+
+	require.Less(t, float64(falsePositives)/n, 2*rate)
+}
+
+func TestBloomFilterMinimumCapacity(t *testing.T) {
+	f := NewBloomFilter(0, 0.01)
+	require.Equal(t, minBloomFilterCapacity, f.Capacity())
+	require.False(t, f.MayContain(deterministicContentID(t, "absent", 1)))
+}
@@ -43,6 +43,16 @@ func Open(data []byte, closer func() error, v1PerContentOverhead func() int) (In
 	}
 }
 
+// FormatVersion returns the format version of the provided serialized index.
+func FormatVersion(data []byte) (int, error) {
+	h, err := v1ReadHeader(data)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid header")
+	}
+
+	return h.version, nil
+}
+
 func safeSlice(data []byte, offset int64, length int) (v []byte, err error) {
 	defer func() {
 		if recover() != nil {
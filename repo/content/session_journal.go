@@ -0,0 +1,334 @@
+package content
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/cache"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// sessionJournalDirName is the name of the cache subdirectory containing journals of in-flight write sessions.
+	sessionJournalDirName = "sessions"
+
+	sessionJournalSuffix     = ".json"
+	sessionJournalLockSuffix = ".lock"
+)
+
+// SessionJournalEntry is persisted in the local cache directory while a write session is in flight
+// and removed when the session is committed. Entries that remain after the writer is gone indicate
+// that the writer crashed before flushing its session.
+type SessionJournalEntry struct {
+	SessionInfo
+	PackBlobIDs []blob.ID `json:"packBlobIDs,omitempty"`
+}
+
+// CrashedSession describes a write session that was never committed because its writer crashed.
+type CrashedSession struct {
+	SessionInfo
+
+	// IndexWritten is true if the writer managed to write the index of the session, in which
+	// case its contents are preserved.
+	IndexWritten bool `json:"indexWritten"`
+
+	// DeletedPackBlobs contains orphaned pack blobs of the session that were deleted, their
+	// contents were lost.
+	DeletedPackBlobs []blob.Metadata `json:"deletedPackBlobs,omitempty"`
+}
+
+// sessionJournal maintains the journal entry of the current write session, which is locked
+// for as long as the session is in flight.
+type sessionJournal struct {
+	fname string
+	lock  *flock.Flock
+
+	mu sync.Mutex
+	// +checklocks:mu
+	entry SessionJournalEntry
+}
+
+// startSessionJournal creates the journal entry for a new session, returns nil if journals are not enabled.
+func (sm *SharedManager) startSessionJournal(si SessionInfo) (*sessionJournal, error) {
+	if sm.cacheDirectory == "" {
+		return nil, nil
+	}
+
+	dir := filepath.Join(sm.cacheDirectory, sessionJournalDirName)
+
+	if err := os.MkdirAll(dir, cache.DirMode); err != nil {
+		return nil, errors.Wrap(err, "unable to create session journal directory")
+	}
+
+	j := &sessionJournal{
+		fname: filepath.Join(dir, string(si.ID)+sessionJournalSuffix),
+		lock:  flock.New(filepath.Join(dir, string(si.ID)+sessionJournalLockSuffix)),
+		entry: SessionJournalEntry{SessionInfo: si},
+	}
+
+	// the lock is held until the session is committed or the process exits.
+	ok, err := j.lock.TryLock()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lock session journal")
+	}
+
+	if !ok {
+		return nil, errors.Errorf("session journal %v is locked", j.fname)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.writeLocked(); err != nil {
+		j.lock.Unlock() //nolint:errcheck
+
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// addPackBlob records the ID of the pack blob that is about to be written by the session.
+func (j *sessionJournal) addPackBlob(packBlobID blob.ID) error {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.entry.PackBlobIDs = append(j.entry.PackBlobIDs, packBlobID)
+
+	return j.writeLocked()
+}
+
+// +checklocks:j.mu
+func (j *sessionJournal) writeLocked() error {
+	v, err := json.Marshal(j.entry)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize session journal")
+	}
+
+	return errors.Wrap(atomicfile.Write(j.fname, bytes.NewReader(v)), "unable to write session journal")
+}
+
+// commit removes the journal entry after the session has been committed.
+func (j *sessionJournal) commit() error {
+	if j == nil {
+		return nil
+	}
+
+	if err := os.Remove(j.fname); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove session journal")
+	}
+
+	return releaseSessionJournalLock(j.lock)
+}
+
+func releaseSessionJournalLock(l *flock.Flock) error {
+	if err := l.Unlock(); err != nil {
+		return errors.Wrap(err, "unable to unlock session journal")
+	}
+
+	if err := os.Remove(l.Path()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "unable to remove session journal lock")
+	}
+
+	return nil
+}
+
+// RecoverCrashedSessions finds journals of write sessions left behind by writers that crashed before
+// committing them, deletes orphaned pack blobs and session markers of such sessions and returns
+// information about what was lost. Sessions of writers that are still running are not affected.
+func (bm *WriteManager) RecoverCrashedSessions(ctx context.Context) ([]*CrashedSession, error) {
+	if bm.cacheDirectory == "" {
+		return nil, nil
+	}
+
+	dir := filepath.Join(bm.cacheDirectory, sessionJournalDirName)
+
+	journals, err := filepath.Glob(filepath.Join(dir, "*"+sessionJournalSuffix))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list session journals")
+	}
+
+	var result []*CrashedSession
+
+	for _, fname := range journals {
+		cs, err := bm.recoverCrashedSession(ctx, fname)
+		if err != nil {
+			return result, err
+		}
+
+		if cs == nil {
+			continue
+		}
+
+		bm.log.Debugf("recovered-session %v index-written:%v deleted-packs:%v", cs.ID, cs.IndexWritten, len(cs.DeletedPackBlobs))
+
+		result = append(result, cs)
+	}
+
+	return result, nil
+}
+
+// LostBytes returns the total size of deleted pack blobs of the session.
+func (cs *CrashedSession) LostBytes() int64 {
+	var total int64
+
+	for _, md := range cs.DeletedPackBlobs {
+		total += md.Length
+	}
+
+	return total
+}
+
+// recoverCrashedSession recovers the session with the provided journal file, returns nil if the session is still in flight.
+func (bm *WriteManager) recoverCrashedSession(ctx context.Context, fname string) (*CrashedSession, error) {
+	l := flock.New(strings.TrimSuffix(fname, sessionJournalSuffix) + sessionJournalLockSuffix)
+
+	ok, err := l.TryLock()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lock session journal")
+	}
+
+	if !ok {
+		// the writer is still running.
+		return nil, nil
+	}
+
+	//nolint:errcheck
+	defer releaseSessionJournalLock(l)
+
+	v, err := os.ReadFile(fname) //nolint:gosec
+	if os.IsNotExist(err) {
+		// recovered by another process.
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read session journal")
+	}
+
+	var e SessionJournalEntry
+
+	if err := json.Unmarshal(v, &e); err != nil {
+		bm.log.Debugf("removing malformed session journal %v: %v", fname, err)
+		return nil, errors.Wrap(os.Remove(fname), "unable to remove malformed session journal")
+	}
+
+	cs := &CrashedSession{SessionInfo: e.SessionInfo}
+
+	cs.IndexWritten, err = bm.sessionIndexWritten(ctx, e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, packBlobID := range e.PackBlobIDs {
+		md, err := bm.st.GetMetadata(ctx, packBlobID)
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get metadata of %v", packBlobID)
+		}
+
+		// the session index may have been compacted into index blobs which don't carry the session ID,
+		// so each pack is checked against the committed index before deleting it.
+		if bm.packBlobMayBeReferenced(ctx, md) {
+			cs.IndexWritten = true
+			continue
+		}
+
+		if err := bm.st.DeleteBlob(ctx, packBlobID); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return nil, errors.Wrapf(err, "unable to delete orphaned pack blob %v", packBlobID)
+		}
+
+		cs.DeletedPackBlobs = append(cs.DeletedPackBlobs, md)
+	}
+
+	if err := bm.deleteSessionMarkers(ctx, e.ID); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(fname); err != nil {
+		return nil, errors.Wrap(err, "unable to remove session journal")
+	}
+
+	return cs, nil
+}
+
+// packBlobMayBeReferenced returns true if any content of the provided pack blob is present in the committed
+// index or if that can't be determined, in which case removal of the pack is left to blob garbage collection.
+func (bm *WriteManager) packBlobMayBeReferenced(ctx context.Context, md blob.Metadata) bool {
+	infos, err := bm.RecoverIndexFromPackBlob(ctx, md.BlobID, md.Length, false)
+	if err != nil {
+		bm.log.Debugf("unable to read local index of %v, keeping it: %v", md.BlobID, err)
+		return true
+	}
+
+	for _, ci := range infos {
+		committed, err := bm.ContentInfo(ctx, ci.ContentID)
+
+		switch {
+		case errors.Is(err, ErrContentNotFound):
+		case err != nil:
+			bm.log.Debugf("unable to look up %v from %v, keeping it: %v", ci.ContentID, md.BlobID, err)
+			return true
+		case committed.PackBlobID == md.BlobID:
+			return true
+		}
+	}
+
+	return false
+}
+
+// sessionIndexWritten returns true if any active index blob has been written by the provided session.
+func (bm *WriteManager) sessionIndexWritten(ctx context.Context, sid SessionID) (bool, error) {
+	ibm, err := bm.indexBlobManager(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	indexBlobs, _, err := ibm.ListActiveIndexBlobs(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to list index blobs")
+	}
+
+	for _, ib := range indexBlobs {
+		if SessionIDFromBlobID(ib.BlobID) == sid {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (bm *WriteManager) deleteSessionMarkers(ctx context.Context, sid SessionID) error {
+	markers, err := blob.ListAllBlobs(ctx, bm.st, BlobIDPrefixSession)
+	if err != nil {
+		return errors.Wrap(err, "unable to list session blobs")
+	}
+
+	for _, m := range markers {
+		if SessionIDFromBlobID(m.BlobID) != sid {
+			continue
+		}
+
+		if err := bm.st.DeleteBlob(ctx, m.BlobID); err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return errors.Wrapf(err, "unable to delete session marker %v", m.BlobID)
+		}
+	}
+
+	return nil
+}
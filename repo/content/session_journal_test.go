@@ -0,0 +1,133 @@
+package content
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func (s *contentManagerSuite) TestRecoverCrashedSessions(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	tweaks := &contentManagerTestTweaks{
+		CachingOptions: CachingOptions{CacheDirectory: testutil.TempDirectory(t)},
+	}
+
+	blobsWithPrefix := func(prefix string) int {
+		n := 0
+
+		for k := range data {
+			if strings.HasPrefix(string(k), prefix) {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	journals := func() []string {
+		fnames, err := filepath.Glob(filepath.Join(tweaks.CacheDirectory, sessionJournalDirName, "*"+sessionJournalSuffix))
+		require.NoError(t, err)
+
+		return fnames
+	}
+
+	// write a pack blob without writing its index, then simulate a crash by releasing the journal lock.
+	bm1 := s.newTestContentManagerWithTweaks(t, st, tweaks)
+
+	_, err := bm1.WriteContent(ctx, gather.FromSlice(seededRandomData(1, 1000)), "", NoCompression)
+	require.NoError(t, err)
+
+	bm1.lock()
+	require.NoError(t, bm1.finishAllPacksLocked(ctx))
+	bm1.unlock(ctx)
+
+	require.Equal(t, 1, blobsWithPrefix("p"))
+	require.Equal(t, 1, blobsWithPrefix("s"))
+	require.Len(t, journals(), 1)
+
+	require.NoError(t, bm1.sessionJournal.lock.Unlock())
+
+	// sessions of running writers are not recovered.
+	bm2 := s.newTestContentManagerWithTweaks(t, st, tweaks)
+
+	_, err = bm2.WriteContent(ctx, gather.FromSlice(seededRandomData(2, 1000)), "", NoCompression)
+	require.NoError(t, err)
+	require.Len(t, journals(), 2)
+
+	bm3 := s.newTestContentManagerWithTweaks(t, st, tweaks)
+
+	crashed, err := bm3.RecoverCrashedSessions(ctx)
+	require.NoError(t, err)
+	require.Len(t, crashed, 1)
+	require.Equal(t, bm1.currentSessionInfo.ID, crashed[0].ID)
+	require.False(t, crashed[0].IndexWritten)
+	require.Len(t, crashed[0].DeletedPackBlobs, 1)
+	require.Positive(t, crashed[0].LostBytes())
+
+	require.Equal(t, 0, blobsWithPrefix("p"))
+	require.Equal(t, 1, blobsWithPrefix("s"), "session marker of the running writer must be preserved")
+	require.Len(t, journals(), 1)
+
+	// committing the session removes its journal.
+	require.NoError(t, bm2.Flush(ctx))
+	require.Empty(t, journals())
+
+	crashed, err = bm3.RecoverCrashedSessions(ctx)
+	require.NoError(t, err)
+	require.Empty(t, crashed)
+	require.Equal(t, 1, blobsWithPrefix("p"))
+}
+
+func (s *contentManagerSuite) TestRecoverCrashedSessionsKeepsReferencedPacks(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+	tweaks := &contentManagerTestTweaks{
+		CachingOptions: CachingOptions{CacheDirectory: testutil.TempDirectory(t)},
+	}
+
+	bm1 := s.newTestContentManagerWithTweaks(t, st, tweaks)
+
+	cid, err := bm1.WriteContent(ctx, gather.FromSlice(seededRandomData(1, 1000)), "", NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, bm1.Flush(ctx))
+
+	ci, err := bm1.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+
+	// simulate a crash after writing the index but before removing the journal, where the index
+	// was later compacted into index blobs which don't carry the session ID.
+	v, err := json.Marshal(SessionJournalEntry{
+		SessionInfo: SessionInfo{ID: "s0123456789abcdef"},
+		PackBlobIDs: []blob.ID{ci.PackBlobID},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(tweaks.CacheDirectory, sessionJournalDirName, "s0123456789abcdef"+sessionJournalSuffix), v, 0o600))
+
+	bm2 := s.newTestContentManagerWithTweaks(t, st, tweaks)
+
+	crashed, err := bm2.RecoverCrashedSessions(ctx)
+	require.NoError(t, err)
+	require.Len(t, crashed, 1)
+	require.True(t, crashed[0].IndexWritten)
+	require.Empty(t, crashed[0].DeletedPackBlobs)
+
+	_, err = st.GetMetadata(ctx, ci.PackBlobID)
+	require.NoError(t, err)
+
+	ci2, err := bm2.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+	require.Equal(t, ci.PackBlobID, ci2.PackBlobID)
+}
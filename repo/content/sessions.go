@@ -74,6 +74,14 @@ func (bm *WriteManager) getOrStartSessionLocked(ctx context.Context) (SessionID,
 		Host:      bm.sessionHost,
 	}
 
+	j, err := bm.startSessionJournal(bm.currentSessionInfo)
+	if err != nil {
+		// the journal only helps recovering from crashes, so the session can proceed without it.
+		bm.log.Debugf("unable to start session journal: %v", err)
+	}
+
+	bm.sessionJournal = j
+
 	bm.sessionMarkerBlobIDs = nil
 	if err := bm.writeSessionMarkerLocked(ctx); err != nil {
 		return "", errors.Wrap(err, "unable to write session marker")
@@ -94,7 +102,10 @@ func (bm *WriteManager) commitSession(ctx context.Context) error {
 	bm.currentSessionInfo.ID = ""
 	bm.sessionMarkerBlobIDs = nil
 
-	return nil
+	j := bm.sessionJournal
+	bm.sessionJournal = nil
+
+	return errors.Wrap(j.commit(), "unable to commit session journal")
 }
 
 // writeSessionMarkerLocked writes a session marker indicating last time the session
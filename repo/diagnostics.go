@@ -0,0 +1,190 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/format"
+)
+
+// storageLatencySamples is the number of storage round-trips used to measure latency.
+const storageLatencySamples = 3
+
+// StorageLatency summarizes the round-trip latency of repeated storage requests.
+type StorageLatency struct {
+	Samples int           `json:"samples"`
+	Min     time.Duration `json:"min"`
+	Average time.Duration `json:"average"`
+	Max     time.Duration `json:"max"`
+}
+
+// ConnectionDiagnostics describes the negotiated repository format, enabled features, caches and
+// health of the storage connection, in a form suitable for attaching to support requests.
+type ConnectionDiagnostics struct {
+	Time         time.Time `json:"time"`
+	BuildVersion string    `json:"buildVersion"`
+	BuildInfo    string    `json:"buildInfo"`
+	ReadOnly     bool      `json:"readOnly"`
+	StorageType  string    `json:"storageType"`
+
+	FormatVersion      format.Version `json:"formatVersion"`
+	IndexVersion       int            `json:"indexVersion"`
+	EpochManager       bool           `json:"epochManager"`
+	ContentCompression bool           `json:"contentCompression"`
+	Hash               string         `json:"hash"`
+	Encryption         string         `json:"encryption"`
+	ECC                string         `json:"ecc,omitempty"`
+	Splitter           string         `json:"splitter"`
+	KeyDerivation      string         `json:"keyDerivation"`
+	BlobRetention      bool           `json:"blobRetention"`
+	RequiredFeatures   []string       `json:"requiredFeatures,omitempty"`
+
+	CacheDirectory string             `json:"cacheDirectory,omitempty"`
+	Cache          content.CacheStats `json:"cache"`
+
+	StorageLatency      *StorageLatency `json:"storageLatency,omitempty"`
+	StorageLatencyError string          `json:"storageLatencyError,omitempty"`
+
+	// ClockSkew is the difference between the storage clock and the local clock, positive when the storage clock is ahead.
+	ClockSkew      *time.Duration `json:"clockSkew,omitempty"`
+	ClockSkewError string         `json:"clockSkewError,omitempty"`
+}
+
+// Diagnostics returns diagnostic information about the repository connection. Failures to measure
+// storage latency or clock skew are reported in the result instead of failing the call.
+func (r *directRepository) Diagnostics(ctx context.Context) (*ConnectionDiagnostics, error) {
+	mp, err := r.fmgr.GetMutableParameters(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "mutable parameters")
+	}
+
+	blobcfg, err := r.fmgr.BlobCfgBlob(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "blob configuration")
+	}
+
+	required, err := r.fmgr.RequiredFeatures(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "required features")
+	}
+
+	_, epochEnabled, err := r.sm.EpochManager(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "epoch manager")
+	}
+
+	d := &ConnectionDiagnostics{
+		Time:               r.Time(),
+		BuildVersion:       BuildVersion,
+		BuildInfo:          BuildInfo,
+		ReadOnly:           r.cliOpts.ReadOnly,
+		StorageType:        r.blobs.ConnectionInfo().Type,
+		FormatVersion:      mp.Version,
+		IndexVersion:       mp.IndexVersion,
+		EpochManager:       epochEnabled,
+		ContentCompression: mp.IndexVersion >= index.Version2,
+		Hash:               r.fmgr.GetHashFunction(),
+		Encryption:         r.fmgr.GetEncryptionAlgorithm(),
+		ECC:                r.fmgr.GetECCAlgorithm(),
+		Splitter:           r.ObjectFormat().Splitter,
+		KeyDerivation:      r.fmgr.KeyDerivationAlgorithm(),
+		BlobRetention:      blobcfg.IsRetentionEnabled(),
+		CacheDirectory:     r.cachingOptions.CacheDirectory,
+		Cache:              r.sm.CacheStats(),
+	}
+
+	for _, f := range required {
+		d.RequiredFeatures = append(d.RequiredFeatures, string(f.Feature))
+	}
+
+	if l, err := measureStorageLatency(ctx, r.blobs); err != nil {
+		d.StorageLatencyError = err.Error()
+	} else {
+		d.StorageLatency = l
+	}
+
+	switch {
+	case d.ReadOnly:
+		d.ClockSkewError = "not measured, repository is read-only"
+	case d.BlobRetention:
+		d.ClockSkewError = "not measured, blob retention is enabled"
+	default:
+		if skew, err := measureClockSkew(ctx, r.blobs, r.Time); err != nil {
+			d.ClockSkewError = err.Error()
+		} else {
+			d.ClockSkew = &skew
+		}
+	}
+
+	return d, nil
+}
+
+// measureStorageLatency measures the round-trip latency of fetching metadata of the format blob.
+func measureStorageLatency(ctx context.Context, st blob.Reader) (*StorageLatency, error) {
+	l := &StorageLatency{}
+
+	var total time.Duration
+
+	for range storageLatencySamples {
+		timer := timetrack.StartTimer()
+
+		if _, err := st.GetMetadata(ctx, format.KopiaRepositoryBlobID); err != nil {
+			return nil, errors.Wrap(err, "unable to get format blob metadata")
+		}
+
+		dur := timer.Elapsed()
+
+		if l.Samples == 0 || dur < l.Min {
+			l.Min = dur
+		}
+
+		l.Max = max(l.Max, dur)
+		l.Samples++
+		total += dur
+	}
+
+	l.Average = total / time.Duration(l.Samples)
+
+	return l, nil
+}
+
+// measureClockSkew writes a short-lived probe blob and compares its storage timestamp with the local time
+// at the midpoint of the write.
+func measureClockSkew(ctx context.Context, st blob.Storage, timeNow func() time.Time) (time.Duration, error) {
+	probeID := blob.ID("z" + uuid.NewString())
+
+	var storageTime time.Time
+
+	before := timeNow()
+
+	if err := st.PutBlob(ctx, probeID, gather.FromSlice([]byte{0}), blob.PutOptions{GetModTime: &storageTime}); err != nil {
+		return 0, errors.Wrap(err, "unable to write probe blob")
+	}
+
+	after := timeNow()
+
+	defer func() {
+		if err := st.DeleteBlob(ctx, probeID); err != nil {
+			log(ctx).Errorf("unable to delete probe blob %v: %v", probeID, err)
+		}
+	}()
+
+	if storageTime.IsZero() {
+		bm, err := st.GetMetadata(ctx, probeID)
+		if err != nil {
+			return 0, errors.Wrap(err, "unable to get probe blob metadata")
+		}
+
+		storageTime = bm.Timestamp
+	}
+
+	return storageTime.Sub(before.Add(after.Sub(before) / 2)), nil //nolint:mnd
+}
@@ -0,0 +1,37 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+)
+
+func TestDiagnostics(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	d, err := env.RepositoryWriter.Diagnostics(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, format.FormatVersion3, d.FormatVersion)
+	require.Equal(t, env.RepositoryWriter.ObjectFormat().Splitter, d.Splitter)
+	require.True(t, d.EpochManager)
+	require.False(t, d.ReadOnly)
+
+	require.NotNil(t, d.StorageLatency)
+	require.Empty(t, d.StorageLatencyError)
+	require.Equal(t, 3, d.StorageLatency.Samples)
+	require.LessOrEqual(t, d.StorageLatency.Min, d.StorageLatency.Average)
+	require.LessOrEqual(t, d.StorageLatency.Average, d.StorageLatency.Max)
+
+	require.NotNil(t, d.ClockSkew)
+	require.Empty(t, d.ClockSkewError)
+
+	// the probe blob used to measure clock skew is removed.
+	probes, err := blob.ListAllBlobs(ctx, env.RepositoryWriter.BlobStorage(), "z")
+	require.NoError(t, err)
+	require.Empty(t, probes)
+}
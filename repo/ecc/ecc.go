@@ -6,6 +6,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/encryption"
 )
 
@@ -45,10 +46,20 @@ func CreateAlgorithm(opts *Options) (encryption.Encryptor, error) {
 	return factory(opts)
 }
 
+// CorruptionDetector is implemented by error correction algorithms that can report corruption of
+// the stored data that is corrected when decrypting it.
+type CorruptionDetector interface {
+	// CorruptedShards returns the number of corrupted shards found in the stored data.
+	// Returns an error if the data is corrupted beyond repair.
+	CorruptedShards(input gather.Bytes) (int, error)
+}
+
 // Parameters encapsulates all ECC parameters.
 type Parameters interface {
 	GetECCAlgorithm() string
 	GetECCOverheadPercent() int
+	GetECCDataShards() int
+	GetECCParityShards() int
 }
 
 // CreateEncryptor returns new encryption.Encryptor with error correction.
@@ -56,5 +67,7 @@ func CreateEncryptor(p Parameters) (encryption.Encryptor, error) {
 	return CreateAlgorithm(&Options{
 		Algorithm:       p.GetECCAlgorithm(),
 		OverheadPercent: p.GetECCOverheadPercent(),
+		DataShards:      p.GetECCDataShards(),
+		ParityShards:    p.GetECCParityShards(),
 	})
 }
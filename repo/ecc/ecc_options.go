@@ -13,6 +13,11 @@ type Options struct {
 	// Use 0 to compute based on file size.
 	MaxShardSize int `json:"maxShardSize,omitempty"`
 
+	// DataShards and ParityShards override the number of shards computed from OverheadPercent.
+	// Both must be set to take effect.
+	DataShards   int `json:"dataShards,omitempty"`
+	ParityShards int `json:"parityShards,omitempty"`
+
 	// Only set to true during benchmark tests
 	DeleteFirstShardForTests bool
 }
@@ -19,6 +19,7 @@
 	crcSize                = 4
 	smallFilesDataShards   = 6
 	smallFilesParityShards = 2
+	maxTotalShards         = 256
 )
 
 // ReedSolomonCrcECC implements Reed-Solomon error codes with CRC32 error detection.
@@ -58,10 +59,18 @@ func newReedSolomonCrcECC(opts *Options) (*ReedSolomonCrcECC, error) {
 		}
 	}
 
-	// Remove the space used for the crc from the allowed space overhead, if possible
-	freeSpaceOverhead := float32(opts.OverheadPercent) - 100*crcSize/float32(result.MaxShardSize)
-	freeSpaceOverhead = maxFloat32(freeSpaceOverhead, 0.01) //nolint:mnd
-	result.DataShards, result.ParityShards = computeShards(freeSpaceOverhead)
+	if opts.DataShards > 0 || opts.ParityShards > 0 {
+		if opts.DataShards <= 0 || opts.ParityShards <= 0 || opts.DataShards+opts.ParityShards > maxTotalShards {
+			return nil, errors.Errorf("invalid number of shards: %v data and %v parity, must be positive and at most %v in total", opts.DataShards, opts.ParityShards, maxTotalShards)
+		}
+
+		result.DataShards, result.ParityShards = opts.DataShards, opts.ParityShards
+	} else {
+		// Remove the space used for the crc from the allowed space overhead, if possible
+		freeSpaceOverhead := float32(opts.OverheadPercent) - 100*crcSize/float32(result.MaxShardSize)
+		freeSpaceOverhead = maxFloat32(freeSpaceOverhead, 0.01) //nolint:mnd
+		result.DataShards, result.ParityShards = computeShards(freeSpaceOverhead)
+	}
 
 	// Bellow this threshold the data will be split in less shards
 	result.ThresholdParityInput = 2 * crcSize * (result.DataShards + result.ParityShards) //nolint:mnd
@@ -248,6 +257,21 @@ func (r *ReedSolomonCrcECC) Encrypt(input gather.Bytes, _ []byte, output *gather
 // Decrypt corrects the data from input based on the ECC data.
 // See Encrypt comments for a description of the layout.
 func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather.WriteBuffer) error {
+	_, err := r.decode(input, output)
+
+	return err
+}
+
+// CorruptedShards implements CorruptionDetector.
+func (r *ReedSolomonCrcECC) CorruptedShards(input gather.Bytes) (int, error) {
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	return r.decode(input, &tmp)
+}
+
+// decode corrects the data from input and returns the number of shards that had to be reconstructed.
+func (r *ReedSolomonCrcECC) decode(input gather.Bytes, output *gather.WriteBuffer) (corruptedShards int, err error) {
 	sizes := r.computeSizesFromStored(input.Length())
 	dataPlusCrcSizeInBlock := sizes.DataShards * (crcSize + sizes.ShardSize)
 	parityPlusCrcSizeInBlock := sizes.ParityShards * (crcSize + sizes.ShardSize)
@@ -293,6 +317,7 @@ func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather
 				if crc != crc32.ChecksumIEEE(shards[i]) {
 					// The data was corrupted, so we need to reconstruct it
 					shards[i] = nil
+					corruptedShards++
 				}
 			}
 		}
@@ -309,6 +334,7 @@ func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather
 			if crc != crc32.ChecksumIEEE(shards[s]) {
 				// The data was corrupted, so we need to reconstruct it
 				shards[s] = nil
+				corruptedShards++
 			}
 		}
 
@@ -316,9 +342,8 @@ func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather
 			shards[0] = nil
 		}
 
-		err := sizes.enc.ReconstructData(shards)
-		if err != nil {
-			return errors.Wrap(err, "Error computing ECC")
+		if err := sizes.enc.ReconstructData(shards); err != nil {
+			return corruptedShards, errors.Wrap(err, "Error computing ECC")
 		}
 
 		startShard := 0
@@ -340,7 +365,7 @@ func (r *ReedSolomonCrcECC) Decrypt(input gather.Bytes, _ []byte, output *gather
 		}
 	}
 
-	return nil
+	return corruptedShards, nil
 }
 
 func readLength(shards [][]byte, sizes *sizesInfo) (originalSize, startShard, startByte int) {
@@ -406,6 +431,8 @@ func computeFinalFileSizeWithPadding(dataShards, parityShards, shardSize, blocks
 	return (parityShards + dataShards) * (crcSize + shardSize) * blocks
 }
 
+var _ CorruptionDetector = (*ReedSolomonCrcECC)(nil)
+
 func init() {
 	RegisterAlgorithm(AlgorithmReedSolomonWithCrc32, func(opts *Options) (encryption.Encryptor, error) {
 		return newReedSolomonCrcECC(opts)
@@ -5,6 +5,7 @@
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/encryption"
 )
 
@@ -102,6 +103,79 @@ func Test_RsCrc32_10p_1mb(t *testing.T) {
 	testRsCrc32ChangeInParityCrc(t, opts, originalSize, 12, eccSize, true)
 }
 
+func Test_RsCrc32_ExplicitShards(t *testing.T) {
+	t.Parallel()
+
+	impl, err := newReedSolomonCrcECC(&Options{
+		Algorithm:       AlgorithmReedSolomonWithCrc32,
+		OverheadPercent: 50,
+		DataShards:      4,
+		ParityShards:    2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, impl.DataShards)
+	require.Equal(t, 2, impl.ParityShards)
+
+	for _, opts := range []*Options{
+		{Algorithm: AlgorithmReedSolomonWithCrc32, OverheadPercent: 50, DataShards: 4},
+		{Algorithm: AlgorithmReedSolomonWithCrc32, OverheadPercent: 50, DataShards: 200, ParityShards: 100},
+	} {
+		_, err := newReedSolomonCrcECC(opts)
+		require.ErrorContains(t, err, "invalid number of shards")
+	}
+}
+
+func Test_RsCrc32_CorruptedShards(t *testing.T) {
+	t.Parallel()
+
+	impl, err := newReedSolomonCrcECC(&Options{
+		Algorithm:       AlgorithmReedSolomonWithCrc32,
+		OverheadPercent: 50,
+		DataShards:      4,
+		ParityShards:    2,
+	})
+	require.NoError(t, err)
+
+	original := make([]byte, 10000)
+	for i := range original {
+		original[i] = byte(i%255) + 1
+	}
+
+	var output gather.WriteBuffer
+	defer output.Close()
+
+	require.NoError(t, impl.Encrypt(gather.FromSlice(original), nil, &output))
+
+	stored := output.ToByteSlice()
+
+	n, err := impl.CorruptedShards(gather.FromSlice(stored))
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// corrupt one data shard, which can be corrected.
+	sizes := impl.computeSizesFromOriginal(len(original))
+	parity := sizes.ParityShards * (crcSize + sizes.ShardSize) * sizes.Blocks
+	flipByte(stored, parity+crcSize)
+
+	n, err = impl.CorruptedShards(gather.FromSlice(stored))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	var decrypted gather.WriteBuffer
+	defer decrypted.Close()
+
+	require.NoError(t, impl.Decrypt(gather.FromSlice(stored), nil, &decrypted))
+	require.Equal(t, original, decrypted.ToByteSlice())
+
+	// corrupt more shards in the same block than there are parity shards.
+	for i := 1; i < 3; i++ {
+		flipByte(stored, parity+i*(crcSize+sizes.ShardSize)+crcSize)
+	}
+
+	_, err = impl.CorruptedShards(gather.FromSlice(stored))
+	require.Error(t, err)
+}
+
 func testRsCrc32NoChange(t *testing.T, opts *Options, originalSize, expectedEccSize int) {
 	t.Helper()
 
@@ -0,0 +1,92 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"hash"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+)
+
+// AEADKeySize is the size of per-content keys passed to AEAD constructors by NewAEADEncryptorFactory.
+const AEADKeySize = sha256.Size
+
+const purposeAEADKeyDerivation = "aead"
+
+// NewAEADEncryptorFactory returns an EncryptorFactory for an AEAD cipher constructed by newAEAD.
+//
+// Each content is encrypted using AEADKeySize-byte key derived from the content ID with HMAC-SHA256
+// keyed with a secret derived from the master key, prefixed by a random nonce and authenticated with
+// the content ID as additional data, which matches the construction of built-in algorithms.
+func NewAEADEncryptorFactory(newAEAD func(key []byte) (cipher.AEAD, error)) EncryptorFactory {
+	return func(p Parameters) (Encryptor, error) {
+		secret, err := deriveKey(p, []byte(purposeAEADKeyDerivation+":"+p.GetEncryptionAlgorithm()), AEADKeySize)
+		if err != nil {
+			return nil, err
+		}
+
+		a, err := newAEAD(make([]byte, AEADKeySize))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create AEAD")
+		}
+
+		return &aeadEncryptor{
+			newAEAD:  newAEAD,
+			overhead: a.NonceSize() + a.Overhead(),
+			hmacPool: &sync.Pool{
+				New: func() interface{} {
+					return hmac.New(sha256.New, secret)
+				},
+			},
+		}, nil
+	}
+}
+
+type aeadEncryptor struct {
+	newAEAD  func(key []byte) (cipher.AEAD, error)
+	overhead int
+	hmacPool *sync.Pool
+}
+
+func (e *aeadEncryptor) aeadForContent(contentID []byte) (cipher.AEAD, error) {
+	//nolint:forcetypeassert
+	h := e.hmacPool.Get().(hash.Hash)
+	defer e.hmacPool.Put(h)
+	h.Reset()
+
+	if _, err := h.Write(contentID); err != nil {
+		return nil, errors.Wrap(err, "unable to derive encryption key")
+	}
+
+	var hashBuf [AEADKeySize]byte
+
+	a, err := e.newAEAD(h.Sum(hashBuf[:0]))
+
+	return a, errors.Wrap(err, "unable to create AEAD")
+}
+
+func (e *aeadEncryptor) Encrypt(input gather.Bytes, contentID []byte, output *gather.WriteBuffer) error {
+	a, err := e.aeadForContent(contentID)
+	if err != nil {
+		return err
+	}
+
+	return aeadSealWithRandomNonce(a, input, contentID, output)
+}
+
+func (e *aeadEncryptor) Decrypt(input gather.Bytes, contentID []byte, output *gather.WriteBuffer) error {
+	a, err := e.aeadForContent(contentID)
+	if err != nil {
+		return err
+	}
+
+	return aeadOpenPrefixedWithNonce(a, input, contentID, output)
+}
+
+func (e *aeadEncryptor) Overhead() int {
+	return e.overhead
+}
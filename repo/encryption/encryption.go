@@ -1,10 +1,15 @@
 // Package encryption manages content encryption algorithms.
+//
+// Additional algorithms (such as implementations backed by validated cryptographic modules) can be
+// registered by embedders using Register before repositories are created or opened, after which they
+// become selectable at repository creation and are included in benchmarks.
 package encryption
 
 import (
 	"crypto/sha256"
 	"io"
 	"sort"
+	"sync"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/hkdf"
@@ -41,7 +46,7 @@ type Parameters interface {
 
 // CreateEncryptor creates an Encryptor for given parameters.
 func CreateEncryptor(p Parameters) (Encryptor, error) {
-	e := encryptors[p.GetEncryptionAlgorithm()]
+	e := lookup(p.GetEncryptionAlgorithm())
 	if e == nil {
 		return nil, errors.Errorf("unknown encryption algorithm: %v", p.GetEncryptionAlgorithm())
 	}
@@ -58,6 +63,9 @@ func CreateEncryptor(p Parameters) (Encryptor, error) {
 // SupportedAlgorithms returns the names of the supported encryption
 // methods.
 func SupportedAlgorithms(includeDeprecated bool) []string {
+	encryptorsMutex.RLock()
+	defer encryptorsMutex.RUnlock()
+
 	var result []string
 
 	for k, e := range encryptors {
@@ -74,7 +82,19 @@ func SupportedAlgorithms(includeDeprecated bool) []string {
 }
 
 // Register registers new encryption algorithm.
+// It panics if the name is empty, the factory is nil or the name has already been registered.
 func Register(name, description string, deprecated bool, newEncryptor EncryptorFactory) {
+	if name == "" || newEncryptor == nil {
+		panic("encryption: invalid registration of " + name)
+	}
+
+	encryptorsMutex.Lock()
+	defer encryptorsMutex.Unlock()
+
+	if _, ok := encryptors[name]; ok {
+		panic("encryption: Register called twice for " + name)
+	}
+
 	encryptors[name] = &encryptorInfo{
 		description,
 		deprecated,
@@ -82,6 +102,34 @@ func Register(name, description string, deprecated bool, newEncryptor EncryptorF
 	}
 }
 
+// IsSupported returns true if an encryption algorithm with a given name has been registered.
+func IsSupported(name string) bool {
+	return lookup(name) != nil
+}
+
+// IsDeprecated returns true if a given encryption algorithm has been registered as deprecated.
+func IsDeprecated(name string) bool {
+	e := lookup(name)
+
+	return e != nil && e.deprecated
+}
+
+// Description returns the description of a given encryption algorithm.
+func Description(name string) string {
+	if e := lookup(name); e != nil {
+		return e.description
+	}
+
+	return ""
+}
+
+func lookup(name string) *encryptorInfo {
+	encryptorsMutex.RLock()
+	defer encryptorsMutex.RUnlock()
+
+	return encryptors[name]
+}
+
 type encryptorInfo struct {
 	description  string
 	deprecated   bool
@@ -89,7 +137,16 @@ type encryptorInfo struct {
 }
 
 //nolint:gochecknoglobals
-var encryptors = map[string]*encryptorInfo{}
+var (
+	encryptorsMutex sync.RWMutex
+	encryptors      = map[string]*encryptorInfo{}
+)
+
+// DeriveKey derives a key of a given length and a given purpose from the master key of the repository.
+// It can be used by registered algorithms to obtain their encryption keys.
+func DeriveKey(p Parameters, purpose []byte, length int) ([]byte, error) {
+	return deriveKey(p, purpose, length)
+}
 
 // deriveKey uses HKDF to derive a key of a given length and a given purpose from parameters.
 func deriveKey(p Parameters, purpose []byte, length int) ([]byte, error) {
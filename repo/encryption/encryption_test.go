@@ -2,6 +2,8 @@
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"encoding/hex"
 	mathrand "math/rand"
@@ -94,6 +96,47 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestRegisterAEAD(t *testing.T) {
+	const name = "TEST-AES256-GCM"
+
+	encryption.Register(name, "AES-256-GCM registered by a test", false, encryption.NewAEADEncryptorFactory(func(key []byte) (cipher.AEAD, error) {
+		c, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return cipher.NewGCM(c)
+	}))
+
+	require.True(t, encryption.IsSupported(name))
+	require.False(t, encryption.IsDeprecated(name))
+	require.Equal(t, "AES-256-GCM registered by a test", encryption.Description(name))
+	require.Contains(t, encryption.SupportedAlgorithms(false), name)
+
+	require.Panics(t, func() {
+		encryption.Register(name, "duplicate", false, encryption.NewAEADEncryptorFactory(nil))
+	})
+
+	masterKey := make([]byte, 32)
+	rand.Read(masterKey)
+
+	e, err := encryption.CreateEncryptor(parameters{name, masterKey})
+	require.NoError(t, err)
+	require.Equal(t, 28, e.Overhead())
+
+	contentID := []byte{1, 2, 3, 4}
+
+	var cipherText, plainText gather.WriteBuffer
+	defer cipherText.Close()
+	defer plainText.Close()
+
+	require.NoError(t, e.Encrypt(gather.FromSlice([]byte("hello")), contentID, &cipherText))
+	require.Equal(t, 5+e.Overhead(), cipherText.Length())
+	require.NoError(t, e.Decrypt(cipherText.Bytes(), contentID, &plainText))
+	require.Equal(t, []byte("hello"), plainText.ToByteSlice())
+	require.Error(t, e.Decrypt(cipherText.Bytes(), []byte{5, 6, 7, 8}, &plainText))
+}
+
 func TestCiphertextSamples(t *testing.T) {
 	cases := []struct {
 		masterKey []byte
@@ -110,6 +153,7 @@ func TestCiphertextSamples(t *testing.T) {
 			samples: map[string]string{
 				"AES256-GCM-HMAC-SHA256":        "e43ba07f85a6d70c5f1102ca06cf19c597e5f91e527b21f00fb76e8bec3fd1",
 				"CHACHA20-POLY1305-HMAC-SHA256": "118359f3d4d589d939efbbc3168ae4c77c51bcebce6845fe6ef5d11342faa6",
+				"TEST-AES256-GCM":               "bc3d155b26c72dbd01718963f250cac90fab06488333862bf76996a4485dd0",
 			},
 		},
 		{
@@ -121,6 +165,7 @@ func TestCiphertextSamples(t *testing.T) {
 			samples: map[string]string{
 				"AES256-GCM-HMAC-SHA256":        "eaad755a238f1daa4052db2e5ccddd934790b6cca415b3ccfd46ac5746af33d9d30f4400ffa9eb3a64fb1ce21b888c12c043bf6787d4a5c15ad10f21f6a6027ee3afe0",
 				"CHACHA20-POLY1305-HMAC-SHA256": "836d2ba87892711077adbdbe1452d3b2c590bbfdf6fd3387dc6810220a32ec19de862e1a4f865575e328424b5f178afac1b7eeff11494f719d119b7ebb924d1d0846a3",
+				"TEST-AES256-GCM":               "703bf43ef8d8f5d93a62ddc5bdc20a33c62986049cd5e8184fddefe6e99107909794c7edaad68023a2c2169d3db1cd0b414d1dcc557fffcd28c439e26bf78ec973be45",
 			},
 		},
 	}
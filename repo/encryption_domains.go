@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/repo/format"
+)
+
+// EncryptionDomainKey is the key of an encryption domain unlocked by a connection.
+type EncryptionDomainKey struct {
+	Name string `json:"name"`
+	Key  []byte `json:"key"`
+}
+
+// encryptionDomainKeysID returns the identifier under which keys of encryption domains unlocked by the connection
+// with a given config file are persisted, separately from the repository password.
+func encryptionDomainKeysID(configFile string) string {
+	return configFile + ".encryption-domains"
+}
+
+// loadEncryptionDomainKeys returns keys of encryption domains unlocked by the connection.
+func loadEncryptionDomainKeys(ctx context.Context, persist passwordpersist.Strategy, configFile string) ([]EncryptionDomainKey, error) {
+	if persist == nil {
+		return nil, nil
+	}
+
+	v, err := persist.GetPassword(ctx, encryptionDomainKeysID(configFile))
+	if errors.Is(err, passwordpersist.ErrPasswordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get encryption domain keys")
+	}
+
+	var keys []EncryptionDomainKey
+
+	if err := json.Unmarshal([]byte(v), &keys); err != nil {
+		return nil, errors.Wrap(err, "invalid encryption domain keys")
+	}
+
+	return keys, nil
+}
+
+func saveEncryptionDomainKeys(ctx context.Context, persist passwordpersist.Strategy, configFile string, keys []EncryptionDomainKey) error {
+	if len(keys) == 0 {
+		return DeleteEncryptionDomainKeys(ctx, persist, configFile)
+	}
+
+	v, err := json.Marshal(keys)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal encryption domain keys")
+	}
+
+	if err := persist.PersistPassword(ctx, encryptionDomainKeysID(configFile), string(v)); err != nil {
+		return errors.Wrap(err, "unable to persist encryption domain keys")
+	}
+
+	// strategies which don't persist anything silently succeed.
+	if _, err := persist.GetPassword(ctx, encryptionDomainKeysID(configFile)); err != nil {
+		return errors.Wrap(err, "unable to persist encryption domain keys, credentials persistence must be enabled")
+	}
+
+	return nil
+}
+
+// DeleteEncryptionDomainKeys deletes persisted keys of all encryption domains unlocked by the connection.
+func DeleteEncryptionDomainKeys(ctx context.Context, persist passwordpersist.Strategy, configFile string) error {
+	err := persist.DeletePassword(ctx, encryptionDomainKeysID(configFile))
+	if err != nil && !errors.Is(err, passwordpersist.ErrPasswordNotFound) && !errors.Is(err, passwordpersist.ErrUnsupported) {
+		return errors.Wrap(err, "unable to delete encryption domain keys")
+	}
+
+	return nil
+}
+
+func encryptionDomainCrypters(fmgr *format.Manager, keys []EncryptionDomainKey) ([]*format.EncryptionDomainCrypter, error) {
+	var result []*format.EncryptionDomainCrypter
+
+	for _, k := range keys {
+		c, err := fmgr.NewEncryptionDomainCrypter(k.Name, k.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to open encryption domain %v", k.Name)
+		}
+
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+// UnlockEncryptionDomain unwraps the key of the encryption domain using the provided password and persists it
+// using the provided strategy, so that contents of the domain can be read. When write is true, contents written
+// using the connection are encrypted in the domain.
+func UnlockEncryptionDomain(ctx context.Context, configFile string, persist passwordpersist.Strategy, rep DirectRepository, name, password string, write bool) error {
+	key, err := rep.FormatManager().UnlockEncryptionDomain(name, password)
+	if err != nil {
+		return errors.Wrapf(err, "unable to unlock encryption domain %v", name)
+	}
+
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := loadEncryptionDomainKeys(ctx, persist, configFile)
+	if err != nil {
+		return err
+	}
+
+	keys = slices.DeleteFunc(keys, func(k EncryptionDomainKey) bool { return k.Name == name })
+	keys = append(keys, EncryptionDomainKey{Name: name, Key: key})
+
+	if err := saveEncryptionDomainKeys(ctx, persist, configFile, keys); err != nil {
+		return err
+	}
+
+	log(ctx).Debugf("unlocked encryption domain %v", name)
+
+	if !write {
+		return nil
+	}
+
+	lc.EncryptionDomain = name
+
+	return lc.writeToFile(configFile)
+}
+
+// LockEncryptionDomain removes the persisted key of the encryption domain.
+func LockEncryptionDomain(ctx context.Context, configFile string, persist passwordpersist.Strategy, name string) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := loadEncryptionDomainKeys(ctx, persist, configFile)
+	if err != nil {
+		return err
+	}
+
+	n := len(keys)
+
+	keys = slices.DeleteFunc(keys, func(k EncryptionDomainKey) bool { return k.Name == name })
+	if len(keys) == n {
+		return errors.Errorf("encryption domain %v is not unlocked", name)
+	}
+
+	if err := saveEncryptionDomainKeys(ctx, persist, configFile, keys); err != nil {
+		return err
+	}
+
+	log(ctx).Debugf("locked encryption domain %v", name)
+
+	if lc.EncryptionDomain != name {
+		return nil
+	}
+
+	lc.EncryptionDomain = ""
+
+	return lc.writeToFile(configFile)
+}
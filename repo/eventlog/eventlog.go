@@ -0,0 +1,279 @@
+// Package eventlog records structural changes of the repository, such as snapshots being created or deleted
+// and format or password changes, as small manifests stored in the repository itself, so that the history
+// of the repository can be queried without external logging.
+//
+// Maintenance runs are not stored as manifests, since that would make every maintenance run write new contents,
+// instead they are read from the run history kept in the maintenance schedule.
+package eventlog
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+var log = logging.Module("kopia/eventlog")
+
+// ManifestType is the type of manifests holding events.
+const ManifestType = "event"
+
+// typeLabelKey is the manifest label holding the event type.
+const typeLabelKey = "eventType"
+
+// DefaultRetention is the age after which events are removed during full maintenance.
+const DefaultRetention = 180 * 24 * time.Hour
+
+// Type describes the kind of the event.
+type Type string
+
+// Supported event types.
+const (
+	SnapshotCreated Type = "snapshot-created"
+	SnapshotDeleted Type = "snapshot-deleted"
+	MaintenanceRun  Type = "maintenance-run"
+	FormatChanged   Type = "format-changed"
+	PasswordChanged Type = "password-changed"
+)
+
+// Event describes a single change of the repository.
+type Event struct {
+	ID          manifest.ID       `json:"id,omitempty"`
+	Time        time.Time         `json:"time"`
+	Type        Type              `json:"type"`
+	User        string            `json:"user"`
+	Description string            `json:"description"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// Record stores an event of the provided type in the repository, the event is persisted when the repository is flushed.
+func Record(ctx context.Context, rep repo.RepositoryWriter, typ Type, description string, details map[string]string) error {
+	ev := &Event{
+		Time:        rep.Time().UTC(),
+		Type:        typ,
+		User:        rep.ClientOptions().UsernameAtHost(),
+		Description: description,
+		Details:     details,
+	}
+
+	if _, err := rep.PutManifest(ctx, map[string]string{
+		manifest.TypeLabelKey: ManifestType,
+		typeLabelKey:          string(typ),
+	}, ev); err != nil {
+		return errors.Wrap(err, "unable to record event")
+	}
+
+	return nil
+}
+
+// RecordOrWarn records an event, logging a warning instead of failing when it can't be recorded,
+// so that changes don't fail because of the event log.
+func RecordOrWarn(ctx context.Context, rep repo.RepositoryWriter, typ Type, description string, details map[string]string) {
+	if err := Record(ctx, rep, typ, description, details); err != nil {
+		log(ctx).Warnf("unable to record %v event: %v", typ, err)
+	}
+}
+
+// ListOptions specifies which events to return.
+type ListOptions struct {
+	Since time.Time // when non-zero, only return events recorded at or after this time
+	Until time.Time // when non-zero, only return events recorded before this time
+	Types []Type    // when non-empty, only return events of these types
+
+	// MaxResults, when positive, limits the results to the most recent events.
+	MaxResults int
+}
+
+func (o ListOptions) matches(t time.Time) bool {
+	if !o.Since.IsZero() && t.Before(o.Since) {
+		return false
+	}
+
+	if !o.Until.IsZero() && !t.Before(o.Until) {
+		return false
+	}
+
+	return true
+}
+
+func findEventManifests(ctx context.Context, rep repo.Repository, types []Type) ([]*manifest.EntryMetadata, error) {
+	if len(types) == 0 {
+		//nolint:wrapcheck
+		return rep.FindManifests(ctx, map[string]string{manifest.TypeLabelKey: ManifestType})
+	}
+
+	var result []*manifest.EntryMetadata
+
+	for _, t := range types {
+		entries, err := rep.FindManifests(ctx, map[string]string{
+			manifest.TypeLabelKey: ManifestType,
+			typeLabelKey:          string(t),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to find events")
+		}
+
+		result = append(result, entries...)
+	}
+
+	return result, nil
+}
+
+func (o ListOptions) includesType(t Type) bool {
+	return len(o.Types) == 0 || slices.Contains(o.Types, t)
+}
+
+// List returns the events matching the provided options, ordered by time.
+func List(ctx context.Context, rep repo.Repository, opt ListOptions) ([]*Event, error) {
+	var manifestTypes []Type
+
+	for _, t := range opt.Types {
+		if t != MaintenanceRun {
+			manifestTypes = append(manifestTypes, t)
+		}
+	}
+
+	var entries []*manifest.EntryMetadata
+
+	if len(opt.Types) == 0 || len(manifestTypes) > 0 {
+		var err error
+
+		entries, err = findEventManifests(ctx, rep, manifestTypes)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to find events")
+		}
+	}
+
+	// filter on manifest modification time, which is the time the event was recorded, before loading events.
+	var matching []*manifest.EntryMetadata
+
+	for _, e := range entries {
+		if opt.matches(e.ModTime) {
+			matching = append(matching, e)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].ModTime.Before(matching[j].ModTime)
+	})
+
+	if opt.MaxResults > 0 && len(matching) > opt.MaxResults {
+		matching = matching[len(matching)-opt.MaxResults:]
+	}
+
+	var result []*Event
+
+	for _, e := range matching {
+		ev := &Event{}
+
+		if _, err := rep.GetManifest(ctx, e.ID, ev); err != nil {
+			return nil, errors.Wrapf(err, "unable to load event %v", e.ID)
+		}
+
+		ev.ID = e.ID
+		result = append(result, ev)
+	}
+
+	if opt.includesType(MaintenanceRun) {
+		runs, err := maintenanceRunEvents(ctx, rep)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ev := range runs {
+			if opt.matches(ev.Time) {
+				result = append(result, ev)
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	if opt.MaxResults > 0 && len(result) > opt.MaxResults {
+		result = result[len(result)-opt.MaxResults:]
+	}
+
+	return result, nil
+}
+
+// maintenanceRunEvents returns events describing maintenance runs found in the maintenance schedule,
+// which is only accessible using direct repository connections.
+func maintenanceRunEvents(ctx context.Context, rep repo.Repository) ([]*Event, error) {
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return nil, nil
+	}
+
+	p, err := maintenance.GetParams(ctx, dr)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get maintenance parameters")
+	}
+
+	s, err := maintenance.GetSchedule(ctx, dr)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get maintenance schedule")
+	}
+
+	var result []*Event
+
+	for mode, taskType := range map[maintenance.Mode]maintenance.TaskType{
+		maintenance.ModeQuick: maintenance.TaskQuickMaintenance,
+		maintenance.ModeFull:  maintenance.TaskFullMaintenance,
+	} {
+		for _, ri := range s.Runs[taskType] {
+			ev := &Event{
+				Time:        ri.End.UTC(),
+				Type:        MaintenanceRun,
+				User:        p.Owner,
+				Description: fmt.Sprintf("%v maintenance succeeded", mode),
+				Details: map[string]string{
+					"mode":     string(mode),
+					"duration": ri.End.Sub(ri.Start).String(),
+				},
+			}
+
+			if !ri.Success {
+				ev.Description = fmt.Sprintf("%v maintenance failed", mode)
+				ev.Details["error"] = ri.Error
+			}
+
+			result = append(result, ev)
+		}
+	}
+
+	return result, nil
+}
+
+// Prune removes events recorded before the provided time and returns the number of removed events.
+func Prune(ctx context.Context, rep repo.RepositoryWriter, olderThan time.Time) (int, error) {
+	entries, err := findEventManifests(ctx, rep, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to find events")
+	}
+
+	cnt := 0
+
+	for _, e := range entries {
+		if !e.ModTime.Before(olderThan) {
+			continue
+		}
+
+		if err := rep.DeleteManifest(ctx, e.ID); err != nil {
+			return cnt, errors.Wrapf(err, "unable to delete event %v", e.ID)
+		}
+
+		cnt++
+	}
+
+	return cnt, nil
+}
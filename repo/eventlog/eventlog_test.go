@@ -0,0 +1,83 @@
+package eventlog_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func TestRecordListPrune(t *testing.T) {
+	ta := faketime.NewTimeAdvance(time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC))
+
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ta.NowFunc()
+		},
+	})
+
+	rw := env.RepositoryWriter
+
+	require.NoError(t, eventlog.Record(ctx, rw, eventlog.SnapshotCreated, "first", map[string]string{"snapshotID": "a"}))
+	ta.Advance(24 * time.Hour)
+	require.NoError(t, eventlog.Record(ctx, rw, eventlog.MaintenanceRun, "second", nil))
+	ta.Advance(24 * time.Hour)
+	require.NoError(t, eventlog.Record(ctx, rw, eventlog.SnapshotDeleted, "third", nil))
+	require.NoError(t, rw.Flush(ctx))
+
+	descriptions := func(opt eventlog.ListOptions) []string {
+		t.Helper()
+
+		events, err := eventlog.List(ctx, rw, opt)
+		require.NoError(t, err)
+
+		var result []string
+
+		for _, ev := range events {
+			if ev.Type != eventlog.MaintenanceRun {
+				require.NotEmpty(t, ev.ID)
+				require.Equal(t, rw.ClientOptions().UsernameAtHost(), ev.User)
+			}
+
+			result = append(result, ev.Description)
+		}
+
+		return result
+	}
+
+	require.Equal(t, []string{"first", "second", "third"}, descriptions(eventlog.ListOptions{}))
+	require.Equal(t, []string{"second", "third"}, descriptions(eventlog.ListOptions{MaxResults: 2}))
+	require.Equal(t, []string{"second", "third"}, descriptions(eventlog.ListOptions{Since: ta.NowFunc()().Add(-36 * time.Hour)}))
+	require.Equal(t, []string{"first"}, descriptions(eventlog.ListOptions{Until: ta.NowFunc()().Add(-36 * time.Hour)}))
+	require.Equal(t, []string{"first", "third"}, descriptions(eventlog.ListOptions{
+		Types: []eventlog.Type{eventlog.SnapshotCreated, eventlog.SnapshotDeleted},
+	}))
+
+	events, err := eventlog.List(ctx, rw, eventlog.ListOptions{Types: []eventlog.Type{eventlog.SnapshotCreated}})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, map[string]string{"snapshotID": "a"}, events[0].Details)
+
+	require.NoError(t, maintenance.RunExclusive(ctx, rw, maintenance.ModeQuick, true, func(context.Context, maintenance.RunParameters) error {
+		return nil
+	}))
+
+	events, err = eventlog.List(ctx, rw, eventlog.ListOptions{Types: []eventlog.Type{eventlog.MaintenanceRun}})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "quick", events[0].Details["mode"])
+	require.Equal(t, []string{"second", "third", "quick maintenance succeeded"}, descriptions(eventlog.ListOptions{MaxResults: 3}))
+
+	n, err := eventlog.Prune(ctx, rw, ta.NowFunc()().Add(-12*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"third", "quick maintenance succeeded"}, descriptions(eventlog.ListOptions{}))
+}
@@ -0,0 +1,48 @@
+package format
+
+import (
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/repo/blob/layout"
+)
+
+// BlobLayoutFeature is the feature required to open repositories which store blobs using a custom layout.
+const BlobLayoutFeature feature.Feature = "blob-layout"
+
+// BlobLayout returns the layout of blobs in the storage or nil if the default flat layout is used.
+func (m *Manager) BlobLayout() *layout.Parameters {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.j.BlobLayout
+}
+
+// applyBlobLayout validates the blob layout of a new repository and marks the format as requiring
+// support for custom layouts, since older clients would write blobs to wrong locations.
+func applyBlobLayout(formatBlob *KopiaRepositoryJSON, repoConfig *RepositoryConfig) error {
+	if formatBlob.BlobLayout.IsDefault() {
+		formatBlob.BlobLayout = nil
+
+		return nil
+	}
+
+	if err := formatBlob.BlobLayout.Validate(); err != nil {
+		return errors.Wrap(err, "invalid blob layout")
+	}
+
+	if !slices.ContainsFunc(repoConfig.RequiredFeatures, func(r feature.Required) bool {
+		return r.Feature == BlobLayoutFeature
+	}) {
+		repoConfig.RequiredFeatures = append(slices.Clone(repoConfig.RequiredFeatures), feature.Required{
+			Feature: BlobLayoutFeature,
+			IfNotUnderstood: feature.IfNotUnderstood{
+				Message: "The repository stores blobs using a custom layout.",
+			},
+		})
+	}
+
+	return nil
+}
@@ -92,19 +92,15 @@ func deserializeBlobCfgBytes(j *KopiaRepositoryJSON, encryptedBlobCfgBytes, form
 	return r, nil
 }
 
-// WriteBlobCfgBlob writes `kopia.blobcfg` encrypted using the provided key.
+// WriteBlobCfgBlob writes `kopia.blobcfg` and its replicas encrypted using the provided key.
 func (f *KopiaRepositoryJSON) WriteBlobCfgBlob(ctx context.Context, st blob.Storage, blobcfg BlobStorageConfiguration, formatEncryptionKey []byte) error {
 	blobCfgBytes, err := serializeBlobCfgBytes(f, blobcfg, formatEncryptionKey)
 	if err != nil {
 		return errors.Wrap(err, "unable to encrypt blobcfg bytes")
 	}
 
-	if err := st.PutBlob(ctx, KopiaBlobCfgBlobID, gather.FromSlice(blobCfgBytes), blob.PutOptions{
+	return putBlobWithReplicas(ctx, st, KopiaBlobCfgBlobID, gather.FromSlice(blobCfgBytes), blob.PutOptions{
 		RetentionMode:   blobcfg.RetentionMode,
 		RetentionPeriod: blobcfg.RetentionPeriod,
-	}); err != nil {
-		return errors.Wrapf(err, "PutBlob() failed for %q", KopiaBlobCfgBlobID)
-	}
-
-	return nil
+	})
 }
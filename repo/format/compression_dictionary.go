@@ -0,0 +1,83 @@
+package format
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/feature"
+)
+
+// CompressionDictionariesFeature is the feature required to open repositories that have compression dictionaries.
+const CompressionDictionariesFeature feature.Feature = "compression-dictionaries"
+
+// CompressionDictionaryActivationDelay is the time after a compression dictionary is added before writers
+// start using it, which gives all clients time to refresh the format blob and learn about the dictionary.
+const CompressionDictionaryActivationDelay = 2 * DefaultRepositoryBlobCacheDuration
+
+// CompressionDictionary is a trained compression dictionary stored in the repository format.
+type CompressionDictionary struct {
+	ID          uint32    `json:"id"`
+	CreatedTime time.Time `json:"created"`
+	Data        []byte    `json:"data"`
+}
+
+// IsActive returns true if the dictionary can be used for compression at the provided time.
+func (d CompressionDictionary) IsActive(now time.Time) bool {
+	return !now.Before(d.CreatedTime.Add(CompressionDictionaryActivationDelay))
+}
+
+// NextCompressionDictionaryID returns the ID to be used for the next trained compression dictionary.
+func NextCompressionDictionaryID(dicts []CompressionDictionary, minID uint32) uint32 {
+	next := minID
+
+	for _, d := range dicts {
+		if d.ID >= next {
+			next = d.ID + 1
+		}
+	}
+
+	return next
+}
+
+// AddCompressionDictionary adds the provided compression dictionary to the repository format and
+// marks the format as requiring support for compression dictionaries.
+func (m *Manager) AddCompressionDictionary(ctx context.Context, d CompressionDictionary) error {
+	if err := m.maybeRefreshNotLocked(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(d.Data) == 0 {
+		return errors.New("empty compression dictionary")
+	}
+
+	for _, existing := range m.repoConfig.CompressionDictionaries {
+		if existing.ID == d.ID {
+			return errors.Errorf("compression dictionary %v already exists", d.ID)
+		}
+	}
+
+	if d.CreatedTime.IsZero() {
+		d.CreatedTime = m.timeNow()
+	}
+
+	m.repoConfig.CompressionDictionaries = append(slices.Clone(m.repoConfig.CompressionDictionaries), d)
+
+	if !slices.ContainsFunc(m.repoConfig.RequiredFeatures, func(r feature.Required) bool {
+		return r.Feature == CompressionDictionariesFeature
+	}) {
+		m.repoConfig.RequiredFeatures = append(slices.Clone(m.repoConfig.RequiredFeatures), feature.Required{
+			Feature: CompressionDictionariesFeature,
+			IfNotUnderstood: feature.IfNotUnderstood{
+				Message: "The repository uses compression dictionaries for metadata.",
+			},
+		})
+	}
+
+	return m.updateRepoConfigLocked(ctx)
+}
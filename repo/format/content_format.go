@@ -16,11 +16,15 @@ type ContentFormat struct {
 	Encryption         string `json:"encryption,omitempty"`                  // identifier of the encryption algorithm used
 	ECC                string `json:"ecc,omitempty"`                         // identifier of the ecc algorithm used
 	ECCOverheadPercent int    `json:"eccOverheadPercent,omitempty"`          // space overhead for ecc
+	ECCDataShards      int    `json:"eccDataShards,omitempty"`               // number of ecc data shards, computed from overhead if not set
+	ECCParityShards    int    `json:"eccParityShards,omitempty"`             // number of ecc parity shards, computed from overhead if not set
 	HMACSecret         []byte `json:"secret,omitempty" kopia:"sensitive"`    // HMAC secret used to generate encryption keys
 	MasterKey          []byte `json:"masterKey,omitempty" kopia:"sensitive"` // master encryption key (SIV-mode encryption only)
 	MutableParameters
 
 	EnablePasswordChange bool `json:"enablePasswordChange"` // disables replication of kopia.repository blob in packs
+
+	CompressionDictionaries []CompressionDictionary `json:"compressionDictionaries,omitempty"` // trained compression dictionaries for metadata contents
 }
 
 // ResolveFormatVersion applies format options parameters based on the format version.
@@ -55,6 +59,11 @@ func (f *ContentFormat) GetCachedMutableParameters() MutableParameters {
 	return f.MutableParameters
 }
 
+// GetCompressionDictionaries implements FormattingOptionsProvider.
+func (f *ContentFormat) GetCompressionDictionaries() []CompressionDictionary {
+	return f.CompressionDictionaries
+}
+
 // SupportsPasswordChange implements FormattingOptionsProvider.
 func (f *ContentFormat) SupportsPasswordChange() bool {
 	return f.EnablePasswordChange
@@ -67,6 +76,8 @@ type MutableParameters struct {
 	MaxPackSize     int              `json:"maxPackSize,omitempty"`     // maximum size of a pack object
 	IndexVersion    int              `json:"indexVersion,omitempty"`    // force particular index format version (1,2,..)
 	EpochParameters epoch.Parameters `json:"epochParameters,omitempty"` // epoch manager parameters
+
+	VerifyContentHashOnRead bool `json:"verifyContentHashOnRead,omitempty"` // re-hash decrypted contents on read and compare against content ID
 }
 
 // Validate validates the parameters.
@@ -110,6 +121,16 @@ func (f *ContentFormat) GetECCOverheadPercent() int {
 	return f.ECCOverheadPercent
 }
 
+// GetECCDataShards implements ecc.Parameters.
+func (f *ContentFormat) GetECCDataShards() int {
+	return f.ECCDataShards
+}
+
+// GetECCParityShards implements ecc.Parameters.
+func (f *ContentFormat) GetECCParityShards() int {
+	return f.ECCParityShards
+}
+
 // GetHashFunction implements hashing.Parameters.
 func (f *ContentFormat) GetHashFunction() string {
 	return f.Hash
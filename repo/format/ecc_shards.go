@@ -0,0 +1,31 @@
+package format
+
+import (
+	"slices"
+
+	"github.com/kopia/kopia/internal/feature"
+)
+
+// ECCShardsFeature is the feature required to open repositories which use explicit numbers of ECC shards.
+const ECCShardsFeature feature.Feature = "ecc-shards"
+
+// applyECCShards marks the format of a new repository with explicit numbers of ECC shards as requiring
+// support for them, since older clients would compute shard counts from the overhead and fail to decode packs.
+func applyECCShards(repoConfig *RepositoryConfig) {
+	if repoConfig.ECCDataShards == 0 && repoConfig.ECCParityShards == 0 {
+		return
+	}
+
+	if slices.ContainsFunc(repoConfig.RequiredFeatures, func(r feature.Required) bool {
+		return r.Feature == ECCShardsFeature
+	}) {
+		return
+	}
+
+	repoConfig.RequiredFeatures = append(slices.Clone(repoConfig.RequiredFeatures), feature.Required{
+		Feature: ECCShardsFeature,
+		IfNotUnderstood: feature.IfNotUnderstood{
+			Message: "The repository uses explicit numbers of error correction shards.",
+		},
+	})
+}
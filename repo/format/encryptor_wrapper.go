@@ -2,6 +2,7 @@
 
 import (
 	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/encryption"
 )
 
@@ -39,3 +40,14 @@ func (p *encryptorWrapper) Decrypt(cipherText gather.Bytes, contentID []byte, ou
 func (p *encryptorWrapper) Overhead() int {
 	panic("Should not be called")
 }
+
+// CorruptedShards implements ecc.CorruptionDetector.
+func (p *encryptorWrapper) CorruptedShards(cipherText gather.Bytes) (int, error) {
+	d, ok := p.next.(ecc.CorruptionDetector)
+	if !ok {
+		return 0, nil
+	}
+
+	//nolint:wrapcheck
+	return d.CorruptedShards(cipherText)
+}
@@ -12,6 +12,7 @@
 	"github.com/kopia/kopia/internal/crypto"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/layout"
 )
 
 // DefaultFormatEncryption is the identifier of the default format blob encryption algorithm.
@@ -55,6 +56,29 @@ type KopiaRepositoryJSON struct {
 	EncryptionAlgorithm string `json:"encryption"`
 	// encrypted, serialized JSON encryptedRepositoryConfig{}
 	EncryptedFormatBytes []byte `json:"encryptedBlockFormat,omitempty"`
+
+	// additional keys that can unlock the format encryption key.
+	KeySlots []KeySlot `json:"keySlots,omitempty"`
+
+	// wrapped keys of encryption domains, each protecting contents written by a subset of clients.
+	EncryptionDomains []EncryptionDomain `json:"encryptionDomains,omitempty"`
+
+	// layout of blobs in the storage, nil for the default flat layout.
+	BlobLayout *layout.Parameters `json:"blobLayout,omitempty"`
+}
+
+// validateKopiaRepositoryJSON verifies that the provided bytes hold a well-formed format blob.
+func validateKopiaRepositoryJSON(b []byte) error {
+	f, err := ParseKopiaRepositoryJSON(b)
+	if err != nil {
+		return err
+	}
+
+	if len(f.UniqueID) == 0 {
+		return errors.New("format blob is missing unique ID")
+	}
+
+	return nil
 }
 
 // ParseKopiaRepositoryJSON parses the provided byte slice into KopiaRepositoryJSON.
@@ -170,21 +194,30 @@ func verifyFormatBlobChecksum(b []byte) ([]byte, bool) {
 	return data, true
 }
 
-// WriteKopiaRepositoryBlob writes `kopia.repository` blob to a given storage.
+// WriteKopiaRepositoryBlob writes `kopia.repository` blob and its replicas to a given storage.
 func (f *KopiaRepositoryJSON) WriteKopiaRepositoryBlob(ctx context.Context, st blob.Storage, blobCfg BlobStorageConfiguration) error {
-	return f.WriteKopiaRepositoryBlobWithID(ctx, st, blobCfg, KopiaRepositoryBlobID)
+	buf, err := f.marshal()
+	if err != nil {
+		return err
+	}
+
+	defer buf.Close()
+
+	return putBlobWithReplicas(ctx, st, KopiaRepositoryBlobID, buf.Bytes(), blob.PutOptions{
+		RetentionMode:   blobCfg.RetentionMode,
+		RetentionPeriod: blobCfg.RetentionPeriod,
+	})
 }
 
 // WriteKopiaRepositoryBlobWithID writes `kopia.repository` blob to a given storage under an alternate blobID.
 func (f *KopiaRepositoryJSON) WriteKopiaRepositoryBlobWithID(ctx context.Context, st blob.Storage, blobCfg BlobStorageConfiguration, id blob.ID) error {
-	buf := gather.NewWriteBuffer()
-	e := json.NewEncoder(buf)
-	e.SetIndent("", "  ")
-
-	if err := e.Encode(f); err != nil {
-		return errors.Wrap(err, "unable to marshal format blob")
+	buf, err := f.marshal()
+	if err != nil {
+		return err
 	}
 
+	defer buf.Close()
+
 	if err := st.PutBlob(ctx, id, buf.Bytes(), blob.PutOptions{
 		RetentionMode:   blobCfg.RetentionMode,
 		RetentionPeriod: blobCfg.RetentionPeriod,
@@ -195,6 +228,19 @@ func (f *KopiaRepositoryJSON) WriteKopiaRepositoryBlobWithID(ctx context.Context
 	return nil
 }
 
+func (f *KopiaRepositoryJSON) marshal() (*gather.WriteBuffer, error) {
+	buf := gather.NewWriteBuffer()
+	e := json.NewEncoder(buf)
+	e.SetIndent("", "  ")
+
+	if err := e.Encode(f); err != nil {
+		buf.Close()
+		return nil, errors.Wrap(err, "unable to marshal format blob")
+	}
+
+	return buf, nil
+}
+
 func encryptRepositoryBlobBytesAes256Gcm(data, masterKey, repositoryID []byte) ([]byte, error) {
 	res, err := crypto.EncryptAes256Gcm(data, masterKey, repositoryID)
 	if err != nil {
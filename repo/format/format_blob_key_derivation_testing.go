@@ -12,5 +12,5 @@
 // for deriving the local cache encryption key when connecting to a repository
 // via the kopia API server.
 func SupportedFormatBlobKeyDerivationAlgorithms() []string {
-	return []string{crypto.ScryptAlgorithm, crypto.Pbkdf2Algorithm, crypto.TestingOnlyInsecurePBKeyDerivationAlgorithm}
+	return []string{crypto.ScryptAlgorithm, crypto.ScryptStrongAlgorithm, crypto.Pbkdf2Algorithm, crypto.Argon2idAlgorithm, crypto.TestingOnlyInsecurePBKeyDerivationAlgorithm}
 }
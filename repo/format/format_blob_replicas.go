@@ -0,0 +1,210 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// FormatBlobReplicaCount is the number of redundant copies of `kopia.repository` and `kopia.blobcfg`
+// stored in addition to the primary copy, which allow the repository to survive loss or corruption of
+// the primary copy.
+const FormatBlobReplicaCount = 2
+
+// ReplicaBlobID returns the identifier of n-th replica of the provided format blob.
+func ReplicaBlobID(id blob.ID, n int) blob.ID {
+	return blob.ID(fmt.Sprintf("%v.replica.%v", id, n))
+}
+
+// putBlobWithReplicas writes the provided blob followed by all its replicas.
+// The primary copy is written first so that it is never older than any of the replicas.
+func putBlobWithReplicas(ctx context.Context, st blob.Storage, id blob.ID, data gather.Bytes, opts blob.PutOptions) error {
+	if err := st.PutBlob(ctx, id, data, opts); err != nil {
+		return errors.Wrapf(err, "PutBlob() failed for %q", id)
+	}
+
+	return putReplicas(ctx, st, id, data, opts)
+}
+
+// putReplicas writes all replicas of the provided blob.
+func putReplicas(ctx context.Context, st blob.Storage, id blob.ID, data gather.Bytes, opts blob.PutOptions) error {
+	for i := range FormatBlobReplicaCount {
+		rid := ReplicaBlobID(id, i)
+
+		if err := st.PutBlob(ctx, rid, data, opts); err != nil {
+			return errors.Wrapf(err, "PutBlob() failed for %q", rid)
+		}
+	}
+
+	return nil
+}
+
+// readBlobWithReplicas reads the provided format blob, falling back to its replicas when the primary
+// copy is missing or fails validation. It returns true if the output came from the replicas and the primary
+// copy should be restored from it. Because it's impossible to tell which copy is authoritative, replicas
+// which are valid but differ from each other are never used.
+// If no valid copy is found, the error encountered when reading the primary copy is returned.
+func readBlobWithReplicas(ctx context.Context, st blob.Storage, id blob.ID, validate func(b []byte) error, output *gather.WriteBuffer) (bool, error) {
+	err := st.GetBlob(ctx, id, 0, -1, output)
+
+	switch {
+	case err == nil:
+		verr := validate(output.ToByteSlice())
+		if verr == nil {
+			return false, nil
+		}
+
+		err = errors.Wrapf(verr, "invalid %v blob", id)
+
+	case !errors.Is(err, blob.ErrBlobNotFound):
+		// the primary copy could not be read for reasons other than it being missing, don't attempt repair.
+		return false, errors.Wrapf(err, "error getting %v blob", id)
+	}
+
+	var (
+		tmp     gather.WriteBuffer
+		validID blob.ID
+	)
+
+	defer tmp.Close()
+
+	output.Reset()
+
+	for i := range FormatBlobReplicaCount {
+		rid := ReplicaBlobID(id, i)
+
+		if rerr := st.GetBlob(ctx, rid, 0, -1, &tmp); rerr != nil {
+			log(ctx).Debugf("unable to read format blob replica %v: %v", rid, rerr)
+			continue
+		}
+
+		if verr := validate(tmp.ToByteSlice()); verr != nil {
+			log(ctx).Warnf("format blob replica %v is invalid: %v", rid, verr)
+			continue
+		}
+
+		if validID == "" {
+			validID = rid
+
+			output.Append(tmp.ToByteSlice())
+
+			continue
+		}
+
+		if !bytes.Equal(tmp.ToByteSlice(), output.ToByteSlice()) {
+			output.Reset()
+
+			return false, errors.Errorf("%v blob is missing or corrupt (%v) and its replicas %v and %v differ, refusing to restore it", id, err, validID, rid)
+		}
+	}
+
+	if validID == "" {
+		return false, errors.Wrapf(err, "error getting %v blob", id)
+	}
+
+	log(ctx).Warnf("%v blob is missing or corrupt (%v), using %v", id, err, validID)
+
+	return true, nil
+}
+
+// restorePrimaryBlob rewrites the primary copy of a format blob using contents read from its replicas.
+func restorePrimaryBlob(ctx context.Context, st blob.Storage, id blob.ID, data []byte, opts blob.PutOptions) {
+	log(ctx).Warnf("restoring %v blob from its replicas", id)
+
+	if err := st.PutBlob(ctx, id, gather.FromSlice(data), opts); err != nil {
+		log(ctx).Errorf("unable to restore %v blob: %v", id, err)
+	}
+}
+
+// ReadKopiaRepositoryBlob reads the contents of `kopia.repository` blob, falling back to one of its
+// replicas if it is missing or corrupt. The primary copy is not restored here, since the retention
+// options needed to write it are only known after the repository has been opened.
+func ReadKopiaRepositoryBlob(ctx context.Context, st blob.Storage) ([]byte, error) {
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	if _, err := readBlobWithReplicas(ctx, st, KopiaRepositoryBlobID, validateKopiaRepositoryJSON, &tmp); err != nil {
+		return nil, err
+	}
+
+	return tmp.ToByteSlice(), nil
+}
+
+// VerifyReplicas verifies that all replicas of `kopia.repository` and `kopia.blobcfg` match their
+// primary copies and rewrites the ones that are missing or different. It returns the number of
+// replicas that have been rewritten.
+func (m *Manager) VerifyReplicas(ctx context.Context) (int, error) {
+	m.mu.RLock()
+	j := m.j
+	formatEncryptionKey := m.formatEncryptionKey
+	blobCfg := m.blobCfgBlob
+	m.mu.RUnlock()
+
+	opts := blob.PutOptions{
+		RetentionMode:   blobCfg.RetentionMode,
+		RetentionPeriod: blobCfg.RetentionPeriod,
+	}
+
+	repaired, err := verifyBlobReplicas(ctx, m.blobs, KopiaRepositoryBlobID, validateKopiaRepositoryJSON, opts)
+	if err != nil {
+		return repaired, err
+	}
+
+	n, err := verifyBlobReplicas(ctx, m.blobs, KopiaBlobCfgBlobID, func(b []byte) error {
+		_, err := deserializeBlobCfgBytes(j, b, formatEncryptionKey)
+		return err
+	}, opts)
+
+	return repaired + n, err
+}
+
+// verifyBlobReplicas rewrites replicas of the provided blob that are missing or differ from a valid primary copy.
+func verifyBlobReplicas(ctx context.Context, st blob.Storage, id blob.ID, validate func(b []byte) error, opts blob.PutOptions) (int, error) {
+	var primary, tmp gather.WriteBuffer
+
+	defer primary.Close()
+	defer tmp.Close()
+
+	if err := st.GetBlob(ctx, id, 0, -1, &primary); err != nil {
+		if errors.Is(err, blob.ErrBlobNotFound) {
+			// nothing to replicate.
+			return 0, nil
+		}
+
+		return 0, errors.Wrapf(err, "error getting %v blob", id)
+	}
+
+	if err := validate(primary.ToByteSlice()); err != nil {
+		return 0, errors.Wrapf(err, "invalid %v blob", id)
+	}
+
+	var repaired int
+
+	for i := range FormatBlobReplicaCount {
+		rid := ReplicaBlobID(id, i)
+
+		err := st.GetBlob(ctx, rid, 0, -1, &tmp)
+		if err == nil && bytes.Equal(tmp.ToByteSlice(), primary.ToByteSlice()) {
+			continue
+		}
+
+		if err != nil && !errors.Is(err, blob.ErrBlobNotFound) {
+			return repaired, errors.Wrapf(err, "error getting %v blob", rid)
+		}
+
+		log(ctx).Debugf("rewriting format blob replica %v", rid)
+
+		if err := st.PutBlob(ctx, rid, primary.Bytes(), opts); err != nil {
+			return repaired, errors.Wrapf(err, "PutBlob() failed for %q", rid)
+		}
+
+		repaired++
+	}
+
+	return repaired, nil
+}
@@ -0,0 +1,151 @@
+package format_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+)
+
+func TestFormatBlobReplicas(t *testing.T) {
+	ctx := testlogging.Context(t)
+	nowFunc := time.Now
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, rc, format.BlobStorageConfiguration{}, "some-password"))
+
+	formatBytes := mustGetBytes(t, st, format.KopiaRepositoryBlobID)
+	blobCfgBytes := mustGetBytes(t, st, format.KopiaBlobCfgBlobID)
+
+	for i := range format.FormatBlobReplicaCount {
+		require.Equal(t, formatBytes, mustGetBytes(t, st, format.ReplicaBlobID(format.KopiaRepositoryBlobID, i)))
+		require.Equal(t, blobCfgBytes, mustGetBytes(t, st, format.ReplicaBlobID(format.KopiaBlobCfgBlobID, i)))
+	}
+
+	// missing format blob and corrupt blobcfg are restored from replicas.
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaRepositoryBlobID))
+	require.NoError(t, st.PutBlob(ctx, format.KopiaBlobCfgBlobID, gather.FromSlice([]byte("corrupt")), blob.PutOptions{}))
+
+	// first replica is corrupt too, second one is used.
+	require.NoError(t, st.PutBlob(ctx, format.ReplicaBlobID(format.KopiaRepositoryBlobID, 0), gather.FromSlice([]byte("{")), blob.PutOptions{}))
+
+	// reading the format blob before the repository is opened does not restore it.
+	got, err := format.ReadKopiaRepositoryBlob(ctx, st)
+	require.NoError(t, err)
+	require.Equal(t, formatBytes, got)
+
+	_, err = st.GetMetadata(ctx, format.KopiaRepositoryBlobID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	mgr, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+	require.Equal(t, cf.HMACSecret, mgr.GetHmacSecret())
+
+	require.Equal(t, formatBytes, mustGetBytes(t, st, format.KopiaRepositoryBlobID))
+	require.Equal(t, blobCfgBytes, mustGetBytes(t, st, format.KopiaBlobCfgBlobID))
+
+	got, err = format.ReadKopiaRepositoryBlob(ctx, st)
+	require.NoError(t, err)
+	require.Equal(t, formatBytes, got)
+
+	// verification rewrites missing and corrupt replicas.
+	require.NoError(t, st.DeleteBlob(ctx, format.ReplicaBlobID(format.KopiaBlobCfgBlobID, 1)))
+
+	n, err := mgr.VerifyReplicas(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	for i := range format.FormatBlobReplicaCount {
+		require.Equal(t, formatBytes, mustGetBytes(t, st, format.ReplicaBlobID(format.KopiaRepositoryBlobID, i)))
+		require.Equal(t, blobCfgBytes, mustGetBytes(t, st, format.ReplicaBlobID(format.KopiaBlobCfgBlobID, i)))
+	}
+
+	n, err = mgr.VerifyReplicas(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// repository can't be re-initialized while replicas exist.
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaRepositoryBlobID))
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaBlobCfgBlobID))
+	require.ErrorContains(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, rc, format.BlobStorageConfiguration{}, "some-password"), "format blob replica")
+}
+
+func TestFormatBlobReplicasAllCorrupt(t *testing.T) {
+	ctx := testlogging.Context(t)
+	nowFunc := time.Now
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, rc, format.BlobStorageConfiguration{}, "some-password"))
+
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaRepositoryBlobID))
+
+	for i := range format.FormatBlobReplicaCount {
+		require.NoError(t, st.PutBlob(ctx, format.ReplicaBlobID(format.KopiaRepositoryBlobID, i), gather.FromSlice([]byte("{}")), blob.PutOptions{}))
+	}
+
+	_, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	_, err = format.ReadKopiaRepositoryBlob(ctx, st)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+}
+
+func TestFormatBlobReplicasRestoreWithRetention(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	mode := blob.Governance
+	period := 48 * time.Hour
+
+	ta := faketime.NewClockTimeWithOffset(0)
+	nowFunc := ta.NowFunc()
+
+	st := blobtesting.NewVersionedMapStorage(nowFunc)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, rc, format.BlobStorageConfiguration{
+		RetentionMode:   mode,
+		RetentionPeriod: period,
+	}, "some-password"))
+
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaRepositoryBlobID))
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaBlobCfgBlobID))
+
+	_, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+
+	for _, id := range []blob.ID{format.KopiaRepositoryBlobID, format.KopiaBlobCfgBlobID} {
+		gotMode, expiry, err := st.GetRetention(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, mode, gotMode)
+		require.WithinDuration(t, nowFunc().Add(period), expiry, time.Minute)
+	}
+}
+
+func TestFormatBlobReplicasDisagree(t *testing.T) {
+	ctx := testlogging.Context(t)
+	nowFunc := time.Now
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, rc, format.BlobStorageConfiguration{}, "some-password"))
+
+	// replace one replica with a valid format blob of a different repository.
+	other := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, other, &format.KopiaRepositoryJSON{}, rc, format.BlobStorageConfiguration{}, "some-password"))
+	require.NoError(t, st.PutBlob(ctx, format.ReplicaBlobID(format.KopiaRepositoryBlobID, 1), gather.FromSlice(mustGetBytes(t, other, format.KopiaRepositoryBlobID)), blob.PutOptions{}))
+
+	require.NoError(t, st.DeleteBlob(ctx, format.KopiaRepositoryBlobID))
+
+	_, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.ErrorContains(t, err, "differ")
+
+	_, err = format.ReadKopiaRepositoryBlob(ctx, st)
+	require.ErrorContains(t, err, "differ")
+
+	_, err = st.GetMetadata(ctx, format.KopiaRepositoryBlobID)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+}
@@ -18,6 +18,14 @@ func (m *Manager) ChangePassword(ctx context.Context, newPassword string) error
 		return errors.New("password changes are not supported for repositories created using Kopia v0.8 or older")
 	}
 
+	if m.j.IsPasswordless() {
+		return errPasswordless
+	}
+
+	if err := m.ensureNoKeySlotsLocked("changing password"); err != nil {
+		return err
+	}
+
 	newFormatEncryptionKey, err := m.j.DeriveFormatEncryptionKeyFromPassword(newPassword)
 	if err != nil {
 		return errors.Wrap(err, "unable to derive master key")
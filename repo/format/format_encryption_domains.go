@@ -0,0 +1,264 @@
+package format
+
+import (
+	"context"
+	"crypto/hmac"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/crypto"
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/repo/content/index"
+	"github.com/kopia/kopia/repo/encryption"
+	"github.com/kopia/kopia/repo/hashing"
+)
+
+const (
+	encryptionDomainKeySize      = 32
+	encryptionDomainSaltLength   = 32
+	encryptionDomainKeyCheckSize = 32
+
+	// key ID 0 denotes the repository master key and 0xFF is reserved by the index format.
+	maxEncryptionDomainKeyID = 0xFE
+)
+
+//nolint:gochecknoglobals
+var (
+	encryptionDomainKeyCheckPurpose   = []byte("encryption-domain-key-check")
+	encryptionDomainHMACSecretPurpose = []byte("encryption-domain-hmac-secret")
+	encryptionDomainMasterKeyPurpose  = []byte("encryption-domain-master-key")
+)
+
+// EncryptionDomainsFeature is the feature required to open repositories with encryption domains.
+const EncryptionDomainsFeature feature.Feature = "encryption-domains"
+
+// EncryptionDomainsRequirement marks the repository as having encryption domains, older clients would
+// drop their keys when rewriting `kopia.repository`, making contents written in the domains unreadable.
+//
+//nolint:gochecknoglobals
+var EncryptionDomainsRequirement = feature.Required{
+	Feature: EncryptionDomainsFeature,
+	IfNotUnderstood: feature.IfNotUnderstood{
+		Message: "The repository uses encryption domains.",
+	},
+}
+
+// ErrEncryptionDomainNotFound is returned when the requested encryption domain does not exist.
+var ErrEncryptionDomainNotFound = errors.New("encryption domain not found")
+
+// EncryptionDomain describes a separate data encryption key used by a subset of clients (such as a user or source)
+// of a shared repository. The key is random and stored wrapped using a key derived from the domain password,
+// so clients that only know the repository password can't decrypt contents written in the domain.
+type EncryptionDomain struct {
+	Name                   string    `json:"name"`
+	Description            string    `json:"description,omitempty"`
+	CreatedTime            time.Time `json:"created"`
+	KeyID                  byte      `json:"keyID"`
+	KeyDerivationAlgorithm string    `json:"keyAlgo"`
+	Salt                   []byte    `json:"salt"`
+	WrappedKey             []byte    `json:"wrappedKey"`
+	KeyCheck               []byte    `json:"keyCheck"`
+}
+
+func (d *EncryptionDomain) unwrapKey(password string) ([]byte, error) {
+	wrappingKey, err := crypto.DeriveKeyFromPassword(password, d.Salt, encryptionDomainKeySize, d.KeyDerivationAlgorithm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to derive key for encryption domain %v", d.Name)
+	}
+
+	key, err := crypto.DecryptAes256Gcm(d.WrappedKey, wrappingKey, d.Salt)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	return key, nil
+}
+
+func (d *EncryptionDomain) verifyKey(key []byte) error {
+	if len(key) != encryptionDomainKeySize || !hmac.Equal(encryptionDomainKeyCheck(key, d.Salt), d.KeyCheck) {
+		return errors.Errorf("invalid key for encryption domain %v", d.Name)
+	}
+
+	return nil
+}
+
+func encryptionDomainKeyCheck(key, salt []byte) []byte {
+	return crypto.DeriveKeyFromMasterKey(key, salt, encryptionDomainKeyCheckPurpose, encryptionDomainKeyCheckSize)
+}
+
+// EncryptionDomainCrypter computes content IDs and encrypts contents using keys of an encryption domain.
+type EncryptionDomainCrypter struct {
+	Name  string
+	KeyID byte
+
+	h hashing.HashFunc
+	e encryption.Encryptor
+}
+
+// HashFunc returns the hash function computing content IDs in the encryption domain.
+func (c *EncryptionDomainCrypter) HashFunc() hashing.HashFunc {
+	return c.h
+}
+
+// Encryptor returns the encryptor of contents in the encryption domain.
+func (c *EncryptionDomainCrypter) Encryptor() encryption.Encryptor {
+	return c.e
+}
+
+// EncryptionDomains returns the encryption domains defined in the repository.
+func (m *Manager) EncryptionDomains() []EncryptionDomain {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.j.EncryptionDomains)
+}
+
+// +checklocksread:m.mu
+func (m *Manager) findEncryptionDomainLocked(name string) (*EncryptionDomain, error) {
+	idx := slices.IndexFunc(m.j.EncryptionDomains, func(d EncryptionDomain) bool { return d.Name == name })
+	if idx < 0 {
+		return nil, errors.Wrap(ErrEncryptionDomainNotFound, name)
+	}
+
+	return &m.j.EncryptionDomains[idx], nil
+}
+
+// AddEncryptionDomain generates a new encryption domain key, protects it with the provided password
+// and rewrites `kopia.repository`.
+func (m *Manager) AddEncryptionDomain(ctx context.Context, name, description, password, algorithm string) (*EncryptionDomain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.repoConfig.IndexVersion < index.Version2 {
+		return nil, errors.New("encryption domains are not supported for repositories created using Kopia v0.8 or older")
+	}
+
+	if name == "" {
+		return nil, errors.New("encryption domain name must not be empty")
+	}
+
+	if password == "" {
+		return nil, errors.New("password must not be empty")
+	}
+
+	if err := ValidateKeyDerivationAlgorithm(algorithm, m.repoConfig.ContentFormat.Version); err != nil {
+		return nil, err
+	}
+
+	var keyID byte
+
+	for _, d := range m.j.EncryptionDomains {
+		if d.Name == name {
+			return nil, errors.Errorf("encryption domain %v already exists", name)
+		}
+
+		keyID = max(keyID, d.KeyID)
+	}
+
+	if keyID >= maxEncryptionDomainKeyID {
+		return nil, errors.New("too many encryption domains")
+	}
+
+	d := EncryptionDomain{
+		Name:                   name,
+		Description:            description,
+		CreatedTime:            m.timeNow().UTC(),
+		KeyID:                  keyID + 1,
+		KeyDerivationAlgorithm: algorithm,
+		Salt:                   randomBytes(encryptionDomainSaltLength),
+	}
+
+	key := randomBytes(encryptionDomainKeySize)
+
+	wrappingKey, err := crypto.DeriveKeyFromPassword(password, d.Salt, encryptionDomainKeySize, algorithm)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive encryption domain wrapping key")
+	}
+
+	d.WrappedKey, err = crypto.EncryptAes256Gcm(key, wrappingKey, d.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to wrap encryption domain key")
+	}
+
+	d.KeyCheck = encryptionDomainKeyCheck(key, d.Salt)
+
+	newFormatBlob := *m.j
+	newFormatBlob.EncryptionDomains = append(slices.Clone(m.j.EncryptionDomains), d)
+
+	if err := m.writeFormatBlobWithFeatureLocked(ctx, &newFormatBlob, EncryptionDomainsRequirement); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// RemoveEncryptionDomain removes the encryption domain with a given name and rewrites `kopia.repository`.
+// Contents encrypted in the domain are not removed, but can no longer be decrypted by clients that connect afterwards.
+func (m *Manager) RemoveEncryptionDomain(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := slices.IndexFunc(m.j.EncryptionDomains, func(d EncryptionDomain) bool { return d.Name == name })
+	if idx < 0 {
+		return errors.Wrap(ErrEncryptionDomainNotFound, name)
+	}
+
+	newFormatBlob := *m.j
+	newFormatBlob.EncryptionDomains = slices.Delete(slices.Clone(m.j.EncryptionDomains), idx, idx+1)
+
+	return m.writeFormatBlobLocked(ctx, &newFormatBlob)
+}
+
+// UnlockEncryptionDomain returns the key of the encryption domain with a given name, unwrapped using the provided password.
+func (m *Manager) UnlockEncryptionDomain(name, password string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, err := m.findEncryptionDomainLocked(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.unwrapKey(password)
+}
+
+// NewEncryptionDomainCrypter returns the crypter of the encryption domain with a given name using the provided key
+// previously returned by UnlockEncryptionDomain. Content IDs and encryption keys of the domain are derived
+// from the domain key, so contents can't be deduplicated or decrypted across domains.
+func (m *Manager) NewEncryptionDomainCrypter(name string, key []byte) (*EncryptionDomainCrypter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, err := m.findEncryptionDomainLocked(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.verifyKey(key); err != nil {
+		return nil, err
+	}
+
+	f := m.repoConfig.ContentFormat
+
+	if len(f.HMACSecret) > 0 {
+		f.HMACSecret = crypto.DeriveKeyFromMasterKey(key, m.j.UniqueID, encryptionDomainHMACSecretPurpose, len(f.HMACSecret))
+	}
+
+	if len(f.MasterKey) > 0 {
+		f.MasterKey = crypto.DeriveKeyFromMasterKey(key, m.j.UniqueID, encryptionDomainMasterKeyPurpose, len(f.MasterKey))
+	}
+
+	h, e, err := createHashAndEncryptor(&f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encryption domain %v", name)
+	}
+
+	return &EncryptionDomainCrypter{
+		Name:  d.Name,
+		KeyID: d.KeyID,
+		h:     h,
+		e:     e,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package format
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/crypto"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// keyDerivationAlgorithmMinFormatVersion contains the minimum format version required to use
+// key derivation algorithms that are not understood by older clients.
+//
+//nolint:gochecknoglobals
+var keyDerivationAlgorithmMinFormatVersion = map[string]Version{
+	crypto.ScryptStrongAlgorithm: FormatVersion3,
+	crypto.Argon2idAlgorithm:     FormatVersion3,
+}
+
+// ValidateKeyDerivationAlgorithm ensures that the provided key derivation algorithm is supported
+// and can be used by repositories with the given format version.
+func ValidateKeyDerivationAlgorithm(algorithm string, v Version) error {
+	if !slices.Contains(SupportedFormatBlobKeyDerivationAlgorithms(), algorithm) {
+		return errors.Errorf("unsupported key derivation algorithm: %v, supported algorithms %v", algorithm, SupportedFormatBlobKeyDerivationAlgorithms())
+	}
+
+	if minVersion, ok := keyDerivationAlgorithmMinFormatVersion[algorithm]; ok && v < minVersion {
+		return errors.Errorf("key derivation algorithm %v requires repository format version %v or newer, current version is %v", algorithm, minVersion, v)
+	}
+
+	return nil
+}
+
+// KeyDerivationAlgorithm returns the algorithm used to derive the format encryption key from the password.
+func (m *Manager) KeyDerivationAlgorithm() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.j.KeyDerivationAlgorithm
+}
+
+// ChangeKeyDerivationAlgorithm re-wraps the repository configuration using a format encryption key
+// derived with the provided algorithm and rewrites `kopia.repository` & `kopia.blobcfg`.
+// Contents and their encryption keys are not affected.
+func (m *Manager) ChangeKeyDerivationAlgorithm(ctx context.Context, algorithm string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.repoConfig.EnablePasswordChange {
+		return errors.New("key derivation changes are not supported for repositories created using Kopia v0.8 or older")
+	}
+
+	if m.j.IsPasswordless() {
+		return errPasswordless
+	}
+
+	if err := m.ensureNoKeySlotsLocked("changing key derivation"); err != nil {
+		return err
+	}
+
+	if m.j.KeyDerivationAlgorithm == algorithm {
+		return errors.Errorf("repository already uses %v key derivation", algorithm)
+	}
+
+	if err := ValidateKeyDerivationAlgorithm(algorithm, m.repoConfig.ContentFormat.Version); err != nil {
+		return err
+	}
+
+	newFormatBlob := *m.j
+	newFormatBlob.KeyDerivationAlgorithm = algorithm
+
+	newFormatEncryptionKey, err := newFormatBlob.DeriveFormatEncryptionKeyFromPassword(m.password)
+	if err != nil {
+		return errors.Wrap(err, "unable to derive format encryption key")
+	}
+
+	if err := newFormatBlob.EncryptRepositoryConfig(m.repoConfig, newFormatEncryptionKey); err != nil {
+		return errors.Wrap(err, "unable to encrypt format bytes")
+	}
+
+	if err := newFormatBlob.WriteBlobCfgBlob(ctx, m.blobs, m.blobCfgBlob, newFormatEncryptionKey); err != nil {
+		return errors.Wrap(err, "unable to write blobcfg blob")
+	}
+
+	if err := newFormatBlob.WriteKopiaRepositoryBlob(ctx, m.blobs, m.blobCfgBlob); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	m.j = &newFormatBlob
+	m.formatEncryptionKey = newFormatEncryptionKey
+
+	m.cache.Remove(ctx, []blob.ID{KopiaRepositoryBlobID, KopiaBlobCfgBlobID})
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package format
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/crypto"
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	keySlotIDLength   = 4
+	keySlotSaltLength = 32
+)
+
+// KeySlotsFeature is the feature required to open repositories with additional key slots.
+const KeySlotsFeature feature.Feature = "key-slots"
+
+// KeySlotsRequirement marks the repository as having key slots, older clients would drop them
+// when rewriting `kopia.repository`.
+//
+//nolint:gochecknoglobals
+var KeySlotsRequirement = feature.Required{
+	Feature: KeySlotsFeature,
+	IfNotUnderstood: feature.IfNotUnderstood{
+		Message: "The repository can be unlocked using additional keys.",
+	},
+}
+
+// ErrKeySlotNotFound is returned when the requested key slot does not exist.
+var ErrKeySlotNotFound = errors.New("key slot not found")
+
+// KeySlot is an additional key which can be used to unlock the repository instead of the primary password.
+// Each slot stores the format encryption key wrapped using a key derived from the slot password or key file.
+type KeySlot struct {
+	ID                     string    `json:"id"`
+	Description            string    `json:"description,omitempty"`
+	CreatedTime            time.Time `json:"created"`
+	KeyDerivationAlgorithm string    `json:"keyAlgo"`
+	Salt                   []byte    `json:"salt"`
+	WrappedKey             []byte    `json:"wrappedKey"`
+}
+
+func (s *KeySlot) unwrapFormatEncryptionKey(password string) ([]byte, error) {
+	slotKey, err := crypto.DeriveKeyFromPassword(password, s.Salt, formatBlobEncryptionKeySize, s.KeyDerivationAlgorithm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to derive key for key slot %v", s.ID)
+	}
+
+	//nolint:wrapcheck
+	return crypto.DecryptAes256Gcm(s.WrappedKey, slotKey, s.Salt)
+}
+
+// unlockFormatEncryptionKey returns the format encryption key derived from the primary password or
+// unwrapped from a key slot matching the provided password, along with the ID of the slot ("" for primary).
+func (f *KopiaRepositoryJSON) unlockFormatEncryptionKey(password string) (key []byte, keySlotID string, err error) {
+	key, err = f.DeriveFormatEncryptionKeyFromPassword(password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(f.KeySlots) == 0 {
+		return key, "", nil
+	}
+
+	if _, err := f.decryptRepositoryConfig(key); err == nil {
+		return key, "", nil
+	}
+
+	for i := range f.KeySlots {
+		if key, err := f.KeySlots[i].unwrapFormatEncryptionKey(password); err == nil {
+			return key, f.KeySlots[i].ID, nil
+		}
+	}
+
+	return nil, "", ErrInvalidPassword
+}
+
+// KeySlots returns the additional keys that can be used to unlock the repository.
+func (m *Manager) KeySlots() []KeySlot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.j.KeySlots)
+}
+
+// CurrentKeySlotID returns the ID of the key slot used to unlock the repository or empty string
+// if the primary password was used.
+func (m *Manager) CurrentKeySlotID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.keySlotID
+}
+
+// AddKeySlot adds a key slot that allows the repository to be unlocked with the provided password
+// (or key file contents) and rewrites `kopia.repository`.
+func (m *Manager) AddKeySlot(ctx context.Context, description, password, algorithm string) (*KeySlot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.repoConfig.EnablePasswordChange {
+		return nil, errors.New("key slots are not supported for repositories created using Kopia v0.8 or older")
+	}
+
+	if password == "" {
+		return nil, errors.New("password must not be empty")
+	}
+
+	if err := ValidateKeyDerivationAlgorithm(algorithm, m.repoConfig.ContentFormat.Version); err != nil {
+		return nil, err
+	}
+
+	if key, slotID, err := m.j.unlockFormatEncryptionKey(password); err == nil && bytes.Equal(key, m.formatEncryptionKey) {
+		if slotID == "" {
+			return nil, errors.New("the password is already used as the primary repository password")
+		}
+
+		return nil, errors.Errorf("the password is already used by key slot %v", slotID)
+	}
+
+	ks := KeySlot{
+		ID:                     hex.EncodeToString(randomBytes(keySlotIDLength)),
+		Description:            description,
+		CreatedTime:            m.timeNow().UTC(),
+		KeyDerivationAlgorithm: algorithm,
+		Salt:                   randomBytes(keySlotSaltLength),
+	}
+
+	slotKey, err := crypto.DeriveKeyFromPassword(password, ks.Salt, formatBlobEncryptionKeySize, algorithm)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to derive key slot key")
+	}
+
+	ks.WrappedKey, err = crypto.EncryptAes256Gcm(m.formatEncryptionKey, slotKey, ks.Salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to wrap format encryption key")
+	}
+
+	newFormatBlob := *m.j
+	newFormatBlob.KeySlots = append(slices.Clone(m.j.KeySlots), ks)
+
+	if err := m.writeFormatBlobWithFeatureLocked(ctx, &newFormatBlob, KeySlotsRequirement); err != nil {
+		return nil, err
+	}
+
+	return &ks, nil
+}
+
+// RevokeKeySlot removes the key slot with a given ID and rewrites `kopia.repository`.
+// Revoking a key slot does not change the format encryption key, so clients that are already
+// connected using the slot keep working until they reconnect.
+func (m *Manager) RevokeKeySlot(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id == m.keySlotID {
+		return errors.Errorf("key slot %v is used by the current connection and cannot be revoked", id)
+	}
+
+	idx := slices.IndexFunc(m.j.KeySlots, func(s KeySlot) bool { return s.ID == id })
+	if idx < 0 {
+		return errors.Wrap(ErrKeySlotNotFound, id)
+	}
+
+	newFormatBlob := *m.j
+	newFormatBlob.KeySlots = slices.Delete(slices.Clone(m.j.KeySlots), idx, idx+1)
+
+	return m.writeFormatBlobLocked(ctx, &newFormatBlob)
+}
+
+// +checklocks:m.mu
+func (m *Manager) writeFormatBlobLocked(ctx context.Context, newFormatBlob *KopiaRepositoryJSON) error {
+	if err := newFormatBlob.WriteKopiaRepositoryBlob(ctx, m.blobs, m.blobCfgBlob); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	m.j = newFormatBlob
+
+	m.cache.Remove(ctx, []blob.ID{KopiaRepositoryBlobID})
+
+	return nil
+}
+
+// writeFormatBlobWithFeatureLocked writes the provided format blob after marking the repository as requiring
+// the provided feature, which prevents older clients that would drop unknown fields of `kopia.repository`
+// when rewriting it from opening the repository.
+// +checklocks:m.mu
+func (m *Manager) writeFormatBlobWithFeatureLocked(ctx context.Context, newFormatBlob *KopiaRepositoryJSON, r feature.Required) error {
+	if slices.ContainsFunc(m.repoConfig.RequiredFeatures, func(existing feature.Required) bool {
+		return existing.Feature == r.Feature
+	}) {
+		return m.writeFormatBlobLocked(ctx, newFormatBlob)
+	}
+
+	repoConfig := *m.repoConfig
+	repoConfig.RequiredFeatures = append(slices.Clone(repoConfig.RequiredFeatures), r)
+
+	if err := newFormatBlob.EncryptRepositoryConfig(&repoConfig, m.formatEncryptionKey); err != nil {
+		return errors.Wrap(err, "unable to encrypt format bytes")
+	}
+
+	if err := m.writeFormatBlobLocked(ctx, newFormatBlob); err != nil {
+		return err
+	}
+
+	m.repoConfig = &repoConfig
+
+	return nil
+}
+
+// +checklocks:m.mu
+func (m *Manager) ensureNoKeySlotsLocked(op string) error {
+	if len(m.j.KeySlots) > 0 {
+		return errors.Errorf("%v is not supported while the repository has additional keys, revoke them first", op)
+	}
+
+	return nil
+}
@@ -41,6 +41,8 @@ type Manager struct {
 	// +checklocks:mu
 	j *KopiaRepositoryJSON
 	// +checklocks:mu
+	keySlotID string // key slot used to unlock the repository, empty if primary password was used
+	// +checklocks:mu
 	repoConfig *RepositoryConfig
 	// +checklocks:mu
 	blobCfgBlob BlobStorageConfiguration
@@ -81,15 +83,17 @@ func (m *Manager) maybeRefreshNotLocked(ctx context.Context) error {
 }
 
 // readAndCacheRepositoryBlobBytes reads the provided blob from the repository or cache directory.
+// When reading from the repository, blobs that are missing or fail validation are read from their replicas,
+// in which case the returned bool is true and the caller is responsible for restoring the primary copy.
 // +checklocks:m.mu
-func (m *Manager) readAndCacheRepositoryBlobBytes(ctx context.Context, blobID blob.ID) ([]byte, time.Time, error) {
+func (m *Manager) readAndCacheRepositoryBlobBytes(ctx context.Context, blobID blob.ID, validate func(b []byte) error) ([]byte, time.Time, bool, error) {
 	if !m.ignoreCacheOnFirstRefresh {
 		if data, mtime, ok := m.cache.Get(ctx, blobID); ok {
 			// read from cache and still valid
 			age := m.timeNow().Sub(mtime)
 
 			if age < m.validDuration {
-				return data, mtime, nil
+				return data, mtime, false, nil
 			}
 		}
 	}
@@ -97,15 +101,16 @@ func (m *Manager) readAndCacheRepositoryBlobBytes(ctx context.Context, blobID bl
 	var b gather.WriteBuffer
 	defer b.Close()
 
-	if err := m.blobs.GetBlob(ctx, blobID, 0, -1, &b); err != nil {
-		return nil, time.Time{}, errors.Wrapf(err, "error getting %s blob", blobID)
+	restore, err := readBlobWithReplicas(ctx, m.blobs, blobID, validate, &b)
+	if err != nil {
+		return nil, time.Time{}, false, err
 	}
 
 	data := b.ToByteSlice()
 
 	mtime, err := m.cache.Put(ctx, blobID, data)
 
-	return data, mtime, errors.Wrapf(err, "error adding %s blob", blobID)
+	return data, mtime, restore, errors.Wrapf(err, "error adding %s blob", blobID)
 }
 
 // ValidCacheDuration returns the duration for which each blob in the cache is valid.
@@ -126,25 +131,32 @@ func (m *Manager) refresh(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	b, cacheMTime, err := m.readAndCacheRepositoryBlobBytes(ctx, KopiaRepositoryBlobID)
+	formatBytes, cacheMTime, restoreFormat, err := m.readAndCacheRepositoryBlobBytes(ctx, KopiaRepositoryBlobID, validateKopiaRepositoryJSON)
 	if err != nil {
 		return errors.Wrap(err, "unable to read format blob")
 	}
 
-	j, err := ParseKopiaRepositoryJSON(b)
+	j, err := ParseKopiaRepositoryJSON(formatBytes)
 	if err != nil {
 		return errors.Wrap(err, "can't parse format blob")
 	}
 
-	b, err = addFormatBlobChecksumAndLength(b)
+	b, err := addFormatBlobChecksumAndLength(formatBytes)
 	if err != nil {
 		return errors.New("unable to add checksum")
 	}
 
-	// use old key, if present to avoid deriving it, which is expensive
+	// use old key, if present to avoid deriving it, which is expensive,
+	// unless the key derivation algorithm has changed in the meantime.
 	formatEncryptionKey := m.formatEncryptionKey
-	if len(m.formatEncryptionKey) == 0 {
-		formatEncryptionKey, err = j.DeriveFormatEncryptionKeyFromPassword(m.password)
+	keySlotID := m.keySlotID
+
+	if len(m.formatEncryptionKey) == 0 || m.j == nil || m.j.KeyDerivationAlgorithm != j.KeyDerivationAlgorithm {
+		formatEncryptionKey, keySlotID, err = j.unlockFormatEncryptionKey(m.password)
+		if errors.Is(err, ErrInvalidPassword) {
+			return ErrInvalidPassword
+		}
+
 		if err != nil {
 			return errors.Wrap(err, "derive format encryption key")
 		}
@@ -157,7 +169,13 @@ func (m *Manager) refresh(ctx context.Context) error {
 
 	var blobCfg BlobStorageConfiguration
 
-	if b2, _, err2 := m.readAndCacheRepositoryBlobBytes(ctx, KopiaBlobCfgBlobID); err2 == nil {
+	validateBlobCfg := func(b []byte) error {
+		_, err := deserializeBlobCfgBytes(j, b, formatEncryptionKey)
+		return err
+	}
+
+	b2, _, restoreBlobCfg, err2 := m.readAndCacheRepositoryBlobBytes(ctx, KopiaBlobCfgBlobID, validateBlobCfg)
+	if err2 == nil {
 		var e2 error
 
 		blobCfg, e2 = deserializeBlobCfgBytes(j, b2, formatEncryptionKey)
@@ -168,6 +186,20 @@ func (m *Manager) refresh(ctx context.Context) error {
 		return errors.Wrap(err2, "load blob config")
 	}
 
+	// primary copies read from replicas are restored only now that the retention options are known.
+	restoreOpts := blob.PutOptions{
+		RetentionMode:   blobCfg.RetentionMode,
+		RetentionPeriod: blobCfg.RetentionPeriod,
+	}
+
+	if restoreFormat {
+		restorePrimaryBlob(ctx, m.blobs, KopiaRepositoryBlobID, formatBytes, restoreOpts)
+	}
+
+	if restoreBlobCfg {
+		restorePrimaryBlob(ctx, m.blobs, KopiaBlobCfgBlobID, b2, restoreOpts)
+	}
+
 	prov, err := NewFormattingOptionsProvider(&repoConfig.ContentFormat, b)
 	if err != nil {
 		return errors.Wrap(err, "error creating format provider")
@@ -178,6 +210,7 @@ func (m *Manager) refresh(ctx context.Context) error {
 	m.repoConfig = repoConfig
 	m.validUntil = cacheMTime.Add(m.validDuration)
 	m.formatEncryptionKey = formatEncryptionKey
+	m.keySlotID = keySlotID
 	m.loadedTime = cacheMTime
 	m.blobCfgBlob = blobCfg
 	m.ignoreCacheOnFirstRefresh = false
@@ -212,6 +245,16 @@ func (m *Manager) GetECCOverheadPercent() int {
 	return m.immutable.GetECCOverheadPercent()
 }
 
+// GetECCDataShards returns the number of ECC data shards.
+func (m *Manager) GetECCDataShards() int {
+	return m.immutable.GetECCDataShards()
+}
+
+// GetECCParityShards returns the number of ECC parity shards.
+func (m *Manager) GetECCParityShards() int {
+	return m.immutable.GetECCParityShards()
+}
+
 // GetHmacSecret returns the HMAC function.
 func (m *Manager) GetHmacSecret() []byte {
 	return m.immutable.GetHmacSecret()
@@ -273,6 +316,18 @@ func (m *Manager) GetCachedMutableParameters() MutableParameters {
 	return m.current.GetCachedMutableParameters()
 }
 
+// GetCompressionDictionaries returns compression dictionaries of the repository without blocking.
+func (m *Manager) GetCompressionDictionaries() []CompressionDictionary {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.repoConfig == nil {
+		return nil
+	}
+
+	return m.repoConfig.CompressionDictionaries
+}
+
 // UpgradeLockIntent returns the current lock intent.
 func (m *Manager) UpgradeLockIntent(ctx context.Context) (*UpgradeLockIntent, error) {
 	if err := m.maybeRefreshNotLocked(ctx); err != nil {
@@ -365,6 +420,12 @@ func (m *Manager) ScrubbedContentFormat() ContentFormat {
 	cf := m.repoConfig.ContentFormat
 	cf.MasterKey = nil
 	cf.HMACSecret = nil
+	cf.CompressionDictionaries = nil
+
+	for _, d := range m.repoConfig.CompressionDictionaries {
+		d.Data = nil
+		cf.CompressionDictionaries = append(cf.CompressionDictionaries, d)
+	}
 
 	return cf
 }
@@ -446,6 +507,19 @@ func Initialize(ctx context.Context, st blob.Storage, formatBlob *KopiaRepositor
 		return errors.Wrap(err, "unexpected error when checking for blobcfg blob")
 	}
 
+	for i := range FormatBlobReplicaCount {
+		rid := ReplicaBlobID(KopiaRepositoryBlobID, i)
+
+		err = st.GetBlob(ctx, rid, 0, -1, &tmp)
+		if err == nil {
+			return errors.Errorf("possible corruption: format blob replica %v exists, but format blob is not found", rid)
+		}
+
+		if !errors.Is(err, blob.ErrBlobNotFound) {
+			return errors.Wrapf(err, "unexpected error when checking for %v blob", rid)
+		}
+	}
+
 	if formatBlob.EncryptionAlgorithm == "" {
 		formatBlob.EncryptionAlgorithm = DefaultFormatEncryption
 	}
@@ -460,6 +534,10 @@ func Initialize(ctx context.Context, st blob.Storage, formatBlob *KopiaRepositor
 		formatBlob.UniqueID = randomBytes(UniqueIDLengthBytes)
 	}
 
+	if err = validateFormatKeyDerivationAlgorithm(formatBlob.KeyDerivationAlgorithm, repoConfig.ContentFormat.Version); err != nil {
+		return errors.Wrap(err, "invalid key derivation algorithm")
+	}
+
 	formatEncryptionKey, err := formatBlob.DeriveFormatEncryptionKeyFromPassword(password)
 	if err != nil {
 		return errors.Wrap(err, "unable to derive format encryption key")
@@ -473,6 +551,12 @@ func Initialize(ctx context.Context, st blob.Storage, formatBlob *KopiaRepositor
 		return errors.Wrap(err, "blob config")
 	}
 
+	if err = applyBlobLayout(formatBlob, repoConfig); err != nil {
+		return err
+	}
+
+	applyECCShards(repoConfig)
+
 	if err = formatBlob.EncryptRepositoryConfig(repoConfig, formatEncryptionKey); err != nil {
 		return errors.Wrap(err, "unable to encrypt format bytes")
 	}
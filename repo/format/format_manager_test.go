@@ -10,6 +10,7 @@
 	"github.com/stretchr/testify/require"
 
 	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/crypto"
 	"github.com/kopia/kopia/internal/epoch"
 	"github.com/kopia/kopia/internal/faketime"
 	"github.com/kopia/kopia/internal/feature"
@@ -367,6 +368,241 @@ func TestChangePassword(t *testing.T) {
 	require.ErrorIs(t, err, format.ErrInvalidPassword)
 }
 
+func TestChangeKeyDerivationAlgorithm(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	startTime := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	ta := faketime.NewTimeAdvance(startTime)
+	nowFunc := ta.NowFunc()
+	blobCache := format.NewMemoryBlobCache(nowFunc)
+
+	cf2 := cf
+	cf2.Version = format.FormatVersion3
+	cf2.EnablePasswordChange = true
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, &format.RepositoryConfig{ContentFormat: cf2}, format.BlobStorageConfiguration{}, "some-password"))
+
+	mgr, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, blobCache)
+	require.NoError(t, err)
+
+	mgr2, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+
+	require.Equal(t, format.DefaultKeyDerivationAlgorithm, mgr.KeyDerivationAlgorithm())
+	require.Error(t, mgr.ChangeKeyDerivationAlgorithm(ctx, format.DefaultKeyDerivationAlgorithm))
+	require.Error(t, mgr.ChangeKeyDerivationAlgorithm(ctx, "no-such-algorithm"))
+
+	masterKey := mgr.GetMasterKey()
+	uniqueID := mgr.UniqueID()
+
+	require.NoError(t, mgr.ChangeKeyDerivationAlgorithm(ctx, crypto.Argon2idAlgorithm))
+	require.Equal(t, crypto.Argon2idAlgorithm, mgr.KeyDerivationAlgorithm())
+	mustGetMutableParameters(t, mgr)
+
+	// the other manager picks up the new algorithm after its cache expires.
+	ta.Advance(cacheDuration)
+	mustGetMutableParameters(t, mgr2)
+	require.Equal(t, crypto.Argon2idAlgorithm, mgr2.KeyDerivationAlgorithm())
+
+	// new managers derive the key using the new algorithm, the master key is unchanged.
+	mgr3, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+	require.Equal(t, crypto.Argon2idAlgorithm, mgr3.KeyDerivationAlgorithm())
+	require.Equal(t, masterKey, mgr3.GetMasterKey())
+	require.Equal(t, uniqueID, mgr3.UniqueID())
+
+	_, err = format.NewManagerWithCache(ctx, st, cacheDuration, "wrong-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.ErrorIs(t, err, format.ErrInvalidPassword)
+}
+
+func TestKeySlots(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	startTime := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	ta := faketime.NewTimeAdvance(startTime)
+	nowFunc := ta.NowFunc()
+
+	cf2 := cf
+	cf2.Version = format.FormatVersion3
+	cf2.EnablePasswordChange = true
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, &format.RepositoryConfig{ContentFormat: cf2}, format.BlobStorageConfiguration{}, "some-password"))
+
+	mgr, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+	require.Empty(t, mgr.KeySlots())
+	require.Empty(t, mgr.CurrentKeySlotID())
+
+	_, err = mgr.AddKeySlot(ctx, "dup", "some-password", format.DefaultKeyDerivationAlgorithm)
+	require.ErrorContains(t, err, "primary repository password")
+
+	ks1, err := mgr.AddKeySlot(ctx, "first", "first-password", format.DefaultKeyDerivationAlgorithm)
+	require.NoError(t, err)
+
+	ks2, err := mgr.AddKeySlot(ctx, "second", "second-password", crypto.Pbkdf2Algorithm)
+	require.NoError(t, err)
+	require.Len(t, mgr.KeySlots(), 2)
+
+	required, err := mgr.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []feature.Required{format.KeySlotsRequirement}, required)
+
+	_, err = mgr.AddKeySlot(ctx, "dup", "first-password", format.DefaultKeyDerivationAlgorithm)
+	require.ErrorContains(t, err, ks1.ID)
+
+	// password changes are not possible while there are key slots.
+	require.Error(t, mgr.ChangePassword(ctx, "new-password"))
+	require.Error(t, mgr.ChangeKeyDerivationAlgorithm(ctx, crypto.Argon2idAlgorithm))
+
+	// each password unlocks the repository.
+	for _, tc := range []struct {
+		password string
+		slotID   string
+	}{
+		{"some-password", ""},
+		{"first-password", ks1.ID},
+		{"second-password", ks2.ID},
+	} {
+		m, err := format.NewManagerWithCache(ctx, st, cacheDuration, tc.password, nowFunc, format.NewMemoryBlobCache(nowFunc))
+		require.NoError(t, err, tc.password)
+		require.Equal(t, tc.slotID, m.CurrentKeySlotID())
+		require.Equal(t, mgr.GetMasterKey(), m.GetMasterKey())
+		require.Equal(t, mustGetMutableParameters(t, mgr), mustGetMutableParameters(t, m))
+	}
+
+	_, err = format.NewManagerWithCache(ctx, st, cacheDuration, "wrong-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.ErrorIs(t, err, format.ErrInvalidPassword)
+
+	// the slot used by the connection can't be revoked.
+	mgr1, err := format.NewManagerWithCache(ctx, st, cacheDuration, "first-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+	require.Error(t, mgr1.RevokeKeySlot(ctx, ks1.ID))
+	require.ErrorIs(t, mgr1.RevokeKeySlot(ctx, "no-such-slot"), format.ErrKeySlotNotFound)
+
+	require.NoError(t, mgr.RevokeKeySlot(ctx, ks1.ID))
+	require.Len(t, mgr.KeySlots(), 1)
+
+	_, err = format.NewManagerWithCache(ctx, st, cacheDuration, "first-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.ErrorIs(t, err, format.ErrInvalidPassword)
+
+	// existing connection using the revoked slot keeps working.
+	ta.Advance(cacheDuration)
+	mustGetMutableParameters(t, mgr1)
+	require.Len(t, mgr1.KeySlots(), 1)
+
+	require.NoError(t, mgr.RevokeKeySlot(ctx, ks2.ID))
+	require.NoError(t, mgr.ChangePassword(ctx, "new-password"))
+}
+
+func TestEncryptionDomains(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	startTime := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	ta := faketime.NewTimeAdvance(startTime)
+	nowFunc := ta.NowFunc()
+
+	cf2 := cf
+	cf2.Version = format.FormatVersion3
+	cf2.EnablePasswordChange = true
+	cf2.MasterKey = bytes.Repeat([]byte{7}, 32)
+
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	require.NoError(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{}, &format.RepositoryConfig{ContentFormat: cf2}, format.BlobStorageConfiguration{}, "some-password"))
+
+	mgr, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+	require.Empty(t, mgr.EncryptionDomains())
+
+	alice, err := mgr.AddEncryptionDomain(ctx, "alice@host", "", "alice-password", format.DefaultKeyDerivationAlgorithm)
+	require.NoError(t, err)
+	require.Equal(t, byte(1), alice.KeyID)
+
+	// clients that don't understand encryption domains can't open the repository anymore.
+	required, err := mgr.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []feature.Required{format.EncryptionDomainsRequirement}, required)
+
+	bob, err := mgr.AddEncryptionDomain(ctx, "bob@host", "", "bob-password", crypto.Pbkdf2Algorithm)
+	require.NoError(t, err)
+	require.Equal(t, byte(2), bob.KeyID)
+
+	_, err = mgr.AddEncryptionDomain(ctx, "alice@host", "", "other-password", format.DefaultKeyDerivationAlgorithm)
+	require.ErrorContains(t, err, "already exists")
+
+	// domains are visible to other connections.
+	mgr2, err := format.NewManagerWithCache(ctx, st, cacheDuration, "some-password", nowFunc, format.NewMemoryBlobCache(nowFunc))
+	require.NoError(t, err)
+	require.Len(t, mgr2.EncryptionDomains(), 2)
+
+	required, err = mgr2.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []feature.Required{format.EncryptionDomainsRequirement}, required)
+
+	_, err = mgr2.UnlockEncryptionDomain("alice@host", "bob-password")
+	require.ErrorIs(t, err, format.ErrInvalidPassword)
+
+	_, err = mgr2.UnlockEncryptionDomain("carol@host", "bob-password")
+	require.ErrorIs(t, err, format.ErrEncryptionDomainNotFound)
+
+	aliceKey, err := mgr2.UnlockEncryptionDomain("alice@host", "alice-password")
+	require.NoError(t, err)
+
+	bobKey, err := mgr2.UnlockEncryptionDomain("bob@host", "bob-password")
+	require.NoError(t, err)
+
+	_, err = mgr2.NewEncryptionDomainCrypter("bob@host", aliceKey)
+	require.ErrorContains(t, err, "invalid key")
+
+	aliceCrypter, err := mgr.NewEncryptionDomainCrypter("alice@host", aliceKey)
+	require.NoError(t, err)
+	require.Equal(t, byte(1), aliceCrypter.KeyID)
+
+	bobCrypter, err := mgr2.NewEncryptionDomainCrypter("bob@host", bobKey)
+	require.NoError(t, err)
+
+	data := gather.FromSlice([]byte("some data"))
+
+	// content IDs differ between domains and the master key.
+	aliceID := aliceCrypter.HashFunc()(nil, data)
+	require.NotEqual(t, mgr.HashFunc()(nil, data), aliceID)
+	require.NotEqual(t, bobCrypter.HashFunc()(nil, data), aliceID)
+
+	var encrypted, decrypted gather.WriteBuffer
+	defer encrypted.Close()
+	defer decrypted.Close()
+
+	require.NoError(t, aliceCrypter.Encryptor().Encrypt(data, aliceID, &encrypted))
+	require.Error(t, bobCrypter.Encryptor().Decrypt(encrypted.Bytes(), aliceID, &decrypted))
+	require.Error(t, mgr.Encryptor().Decrypt(encrypted.Bytes(), aliceID, &decrypted))
+	require.NoError(t, aliceCrypter.Encryptor().Decrypt(encrypted.Bytes(), aliceID, &decrypted))
+	require.Equal(t, []byte("some data"), decrypted.ToByteSlice())
+
+	require.NoError(t, mgr.RemoveEncryptionDomain(ctx, "alice@host"))
+	require.ErrorIs(t, mgr.RemoveEncryptionDomain(ctx, "alice@host"), format.ErrEncryptionDomainNotFound)
+	require.Len(t, mgr.EncryptionDomains(), 1)
+
+	// domains survive password changes.
+	require.NoError(t, mgr.ChangePassword(ctx, "new-password"))
+	require.Len(t, mgr.EncryptionDomains(), 1)
+}
+
+func TestValidateKeyDerivationAlgorithm(t *testing.T) {
+	require.NoError(t, format.ValidateKeyDerivationAlgorithm(crypto.ScryptAlgorithm, format.FormatVersion1))
+	require.NoError(t, format.ValidateKeyDerivationAlgorithm(crypto.Argon2idAlgorithm, format.FormatVersion3))
+	require.NoError(t, format.ValidateKeyDerivationAlgorithm(crypto.ScryptStrongAlgorithm, format.FormatVersion3))
+	require.ErrorContains(t, format.ValidateKeyDerivationAlgorithm(crypto.Argon2idAlgorithm, format.FormatVersion2), "requires repository format version 3")
+	require.Error(t, format.ValidateKeyDerivationAlgorithm("no-such-algorithm", format.FormatVersion3))
+
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+
+	require.ErrorContains(t, format.Initialize(ctx, st, &format.KopiaRepositoryJSON{
+		KeyDerivationAlgorithm: crypto.Argon2idAlgorithm,
+	}, &format.RepositoryConfig{ContentFormat: cf}, format.BlobStorageConfiguration{}, "some-password"), "invalid key derivation algorithm")
+}
+
 func TestFormatManagerValidDuration(t *testing.T) {
 	cases := map[time.Duration]time.Duration{
 		-1:               15 * time.Minute,
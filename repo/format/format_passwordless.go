@@ -0,0 +1,38 @@
+package format
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/crypto"
+)
+
+// errPasswordless is returned when attempting to change the password of a password-less repository.
+var errPasswordless = errors.New("password-less repositories are unlocked using a key file and don't have a password")
+
+// IsPasswordless returns true if the format encryption key is derived from a random key stored
+// in a local key file instead of a password.
+func (f *KopiaRepositoryJSON) IsPasswordless() bool {
+	return f.KeyDerivationAlgorithm == crypto.KeyFileAlgorithm
+}
+
+// IsPasswordless returns true if the repository is unlocked using a key file instead of a password.
+func (m *Manager) IsPasswordless() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.j.IsPasswordless()
+}
+
+// validateFormatKeyDerivationAlgorithm validates the key derivation algorithm of a new format blob.
+// Unlike key slots and encryption domains, the format blob may also use key file derivation.
+func validateFormatKeyDerivationAlgorithm(algorithm string, v Version) error {
+	if algorithm != crypto.KeyFileAlgorithm {
+		return ValidateKeyDerivationAlgorithm(algorithm, v)
+	}
+
+	if v < FormatVersion3 {
+		return errors.Errorf("password-less repositories require format version %v or newer, current version is %v", FormatVersion3, v)
+	}
+
+	return nil
+}
@@ -60,6 +60,7 @@ type Provider interface {
 	// the repository so the results should not be cached.
 	GetMutableParameters(ctx context.Context) (MutableParameters, error)
 	GetCachedMutableParameters() MutableParameters
+	GetCompressionDictionaries() []CompressionDictionary
 	SupportsPasswordChange() bool
 	GetMasterKey() []byte
 
@@ -103,20 +104,36 @@ func NewFormattingOptionsProvider(f0 *ContentFormat, formatBytes []byte) (Provid
 		f.MaxPackSize = 20 << 20 //nolint:mnd
 	}
 
+	h, e, err := createHashAndEncryptor(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &formattingOptionsProvider{
+		ContentFormat: f,
+
+		h:           h,
+		e:           e,
+		formatBytes: formatBytes,
+	}, nil
+}
+
+// createHashAndEncryptor creates and validates the hash function and encryptor described by the content format.
+func createHashAndEncryptor(f *ContentFormat) (hashing.HashFunc, encryption.Encryptor, error) {
 	h, err := hashing.CreateHashFunc(f)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create hash")
+		return nil, nil, errors.Wrap(err, "unable to create hash")
 	}
 
 	e, err := encryption.CreateEncryptor(f)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create encryptor")
+		return nil, nil, errors.Wrap(err, "unable to create encryptor")
 	}
 
 	if f.GetECCAlgorithm() != "" && f.GetECCOverheadPercent() > 0 {
 		eccEncryptor, err := ecc.CreateEncryptor(f)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to create ECC")
+			return nil, nil, errors.Wrap(err, "unable to create ECC")
 		}
 
 		e = &encryptorWrapper{
@@ -130,18 +147,11 @@ func NewFormattingOptionsProvider(f0 *ContentFormat, formatBytes []byte) (Provid
 	var tmp gather.WriteBuffer
 	defer tmp.Close()
 
-	err = e.Encrypt(gather.FromSlice(nil), contentID, &tmp)
-	if err != nil {
-		return nil, errors.Wrap(err, "invalid encryptor")
+	if err := e.Encrypt(gather.FromSlice(nil), contentID, &tmp); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid encryptor")
 	}
 
-	return &formattingOptionsProvider{
-		ContentFormat: f,
-
-		h:           h,
-		e:           e,
-		formatBytes: formatBytes,
-	}, nil
+	return h, e, nil
 }
 
 func (f *formattingOptionsProvider) Encryptor() encryption.Encryptor {
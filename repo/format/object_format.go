@@ -3,4 +3,9 @@
 // ObjectFormat describes the format of objects in a repository.
 type ObjectFormat struct {
 	Splitter string `json:"splitter,omitempty"` // splitter used to break objects into pieces of content
+
+	// chunk sizes overriding defaults of a dynamic splitter, zero values use sizes determined by the splitter name
+	SplitterMinSize int `json:"splitterMinSize,omitempty"`
+	SplitterAvgSize int `json:"splitterAvgSize,omitempty"`
+	SplitterMaxSize int `json:"splitterMaxSize,omitempty"`
 }
@@ -0,0 +1,55 @@
+package format
+
+import (
+	"context"
+	"slices"
+
+	"github.com/kopia/kopia/internal/feature"
+)
+
+// PackedObjectIDsFeature is the feature required to open repositories with objects stored as ranges of shared packed objects.
+const PackedObjectIDsFeature feature.Feature = "packed-object-ids"
+
+// InlineObjectIDsFeature is the feature required to open repositories with file contents stored inline in object IDs.
+const InlineObjectIDsFeature feature.Feature = "inline-object-ids"
+
+// PackedObjectIDsRequirement marks the repository as containing packed object IDs.
+//
+//nolint:gochecknoglobals
+var PackedObjectIDsRequirement = feature.Required{
+	Feature: PackedObjectIDsFeature,
+	IfNotUnderstood: feature.IfNotUnderstood{
+		Message: "The repository contains small files packed into shared objects.",
+	},
+}
+
+// InlineObjectIDsRequirement marks the repository as containing inline object IDs.
+//
+//nolint:gochecknoglobals
+var InlineObjectIDsRequirement = feature.Required{
+	Feature: InlineObjectIDsFeature,
+	IfNotUnderstood: feature.IfNotUnderstood{
+		Message: "The repository contains contents of small files stored inline in directory entries.",
+	},
+}
+
+// AddRequiredFeature marks the repository format as requiring the provided feature, which prevents clients that
+// don't support it from opening the repository. It does nothing if the feature is already required.
+func (m *Manager) AddRequiredFeature(ctx context.Context, r feature.Required) error {
+	if err := m.maybeRefreshNotLocked(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.repoConfig.RequiredFeatures, func(existing feature.Required) bool {
+		return existing.Feature == r.Feature
+	}) {
+		return nil
+	}
+
+	m.repoConfig.RequiredFeatures = append(slices.Clone(m.repoConfig.RequiredFeatures), r)
+
+	return m.updateRepoConfigLocked(ctx)
+}
@@ -174,6 +174,10 @@ func (m *Manager) RollbackUpgrade(ctx context.Context) error {
 			return errors.Wrapf(err, "failed to restore format blob from backup %q", oldestBackup.BlobID)
 		}
 
+		if err := putReplicas(ctx, m.blobs, KopiaRepositoryBlobID, d.Bytes(), blob.PutOptions{}); err != nil {
+			return errors.Wrapf(err, "failed to restore format blob replicas from backup %q", oldestBackup.BlobID)
+		}
+
 		// delete the backup after we have restored the format-blob
 		if err := m.blobs.DeleteBlob(ctx, oldestBackup.BlobID); err != nil {
 			return errors.Wrapf(err, "failed to delete the format blob backup %q", oldestBackup.BlobID)
@@ -7,6 +7,7 @@
 	"net"
 	"net/url"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +22,7 @@
 	"github.com/kopia/kopia/internal/gather"
 	apipb "github.com/kopia/kopia/internal/grpcapi"
 	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/internal/sshtunnel"
 	"github.com/kopia/kopia/internal/tlsutil"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/compression"
@@ -36,6 +38,10 @@
 // defined by supported splitters.
 const MaxGRPCMessageSize = 20 << 20
 
+// GRPCRepositoryNameMetadataKey is the GRPC metadata key (sent as HTTP header) used to select one of
+// the repositories hosted by a multi-repository server.
+const GRPCRepositoryNameMetadataKey = "kopia-repository-name"
+
 const (
 	// when writing contents of this size or above, make a round-trip to the server to
 	// check if the content exists.
@@ -298,6 +304,10 @@ func decodeManifestEntryMetadata(md *apipb.ManifestEntryMetadata) *manifest.Entr
 }
 
 func (r *grpcRepositoryClient) PutManifest(ctx context.Context, labels map[string]string, payload interface{}) (manifest.ID, error) {
+	if r.isReadOnly {
+		return "", content.ErrRepositoryReadOnly
+	}
+
 	return inSessionWithoutRetry(ctx, r, func(ctx context.Context, sess *grpcInnerSession) (manifest.ID, error) {
 		return sess.PutManifest(ctx, labels, payload)
 	})
@@ -372,6 +382,10 @@ func (r *grpcInnerSession) FindManifests(ctx context.Context, labels map[string]
 }
 
 func (r *grpcRepositoryClient) DeleteManifest(ctx context.Context, id manifest.ID) error {
+	if r.isReadOnly {
+		return content.ErrRepositoryReadOnly
+	}
+
 	_, err := inSessionWithoutRetry(ctx, r, func(ctx context.Context, sess *grpcInnerSession) (bool, error) {
 		return false, sess.DeleteManifest(ctx, id)
 	})
@@ -517,10 +531,13 @@ func (r *grpcRepositoryClient) Flush(ctx context.Context) error {
 		return errors.Wrap(err, "before flush")
 	}
 
-	if _, err := inSessionWithoutRetry(ctx, r, func(ctx context.Context, sess *grpcInnerSession) (bool, error) {
-		return false, sess.Flush(ctx)
-	}); err != nil {
-		return err
+	// read-only sessions can't write anything, so there is nothing for the server to flush.
+	if !r.isReadOnly {
+		if _, err := inSessionWithoutRetry(ctx, r, func(ctx context.Context, sess *grpcInnerSession) (bool, error) {
+			return false, sess.Flush(ctx)
+		}); err != nil {
+			return err
+		}
 	}
 
 	if err := invokeCallbacks(ctx, r, r.afterFlush); err != nil {
@@ -767,6 +784,11 @@ func (r *grpcRepositoryClient) WriteContent(ctx context.Context, data gather.Byt
 		return contentID, nil
 	}
 
+	// the server would reject this write, fail early.
+	if r.isReadOnly {
+		return content.EmptyID, content.ErrRepositoryReadOnly
+	}
+
 	// clone so that caller can reuse the buffer
 	clone := data.ToByteSlice()
 
@@ -825,15 +847,16 @@ func (r *grpcRepositoryClient) OnSuccessfulFlush(callback RepositoryWriterCallba
 var _ Repository = (*grpcRepositoryClient)(nil)
 
 type grpcCreds struct {
-	hostname string
-	username string
-	password string
+	hostname       string
+	username       string
+	password       string
+	repositoryName string
 }
 
 func (c grpcCreds) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
 	_ = uri
 
-	return map[string]string{
+	md := map[string]string{
 		"kopia-hostname":   c.hostname,
 		"kopia-username":   c.username,
 		"kopia-password":   c.password,
@@ -842,7 +865,13 @@ func (c grpcCreds) GetRequestMetadata(ctx context.Context, uri ...string) (map[s
 		"kopia-repo":       BuildGitHubRepo,
 		"kopia-os":         runtime.GOOS,
 		"kopia-arch":       runtime.GOARCH,
-	}, nil
+	}
+
+	if c.repositoryName != "" {
+		md[GRPCRepositoryNameMetadataKey] = c.repositoryName
+	}
+
+	return md, nil
 }
 
 func (c grpcCreds) RequireTransportSecurity() bool {
@@ -865,16 +894,40 @@ func openGRPCAPIRepository(ctx context.Context, si *APIServerInfo, password stri
 		return nil, errors.Wrap(err, "parsing base URL")
 	}
 
-	conn, err := grpc.NewClient(
-		uri,
-		grpc.WithPerRPCCredentials(grpcCreds{par.cliOpts.Hostname, par.cliOpts.Username, password}),
+	dialOpts := []grpc.DialOption{
+		grpc.WithPerRPCCredentials(grpcCreds{par.cliOpts.Hostname, par.cliOpts.Username, password, si.RepositoryName}),
 		grpc.WithTransportCredentials(transportCreds),
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(MaxGRPCMessageSize),
 			grpc.MaxCallSendMsgSize(MaxGRPCMessageSize),
 		),
-	)
+	}
+
+	var tun *sshtunnel.Tunnel
+
+	if si.SSHTunnel != nil {
+		if strings.HasPrefix(uri, "unix:") {
+			return nil, errors.New("SSH tunnel can't be used with unix socket server address")
+		}
+
+		if tun, err = sshtunnel.Open(ctx, si.SSHTunnel); err != nil {
+			return nil, errors.Wrap(err, "unable to open SSH tunnel")
+		}
+
+		// the server address is resolved on the far side of the tunnel.
+		uri = "passthrough:///" + uri
+
+		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return tun.DialContext(ctx, "tcp", addr)
+		}))
+	}
+
+	conn, err := grpc.NewClient(uri, dialOpts...)
 	if err != nil {
+		if tun != nil {
+			tun.Close() //nolint:errcheck
+		}
+
 		return nil, errors.Wrap(err, "gRPC client creation error")
 	}
 
@@ -883,6 +936,13 @@ func(ctx context.Context) error {
 			return errors.Wrap(conn.Close(), "error closing GRPC connection")
 		})
 
+	if tun != nil {
+		par.refCountedCloser.registerEarlyCloseFunc(
+			func(ctx context.Context) error {
+				return tun.Close()
+			})
+	}
+
 	rep, err := newGRPCAPIRepositoryForConnection(ctx, conn, WriteSessionOptions{}, true, par)
 	if err != nil {
 		return nil, err
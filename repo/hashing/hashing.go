@@ -1,4 +1,8 @@
 // Package hashing encapsulates all keyed hashing algorithms.
+//
+// Additional algorithms (such as implementations backed by validated cryptographic modules) can be
+// registered by embedders using Register before repositories are created or opened, after which they
+// become selectable at repository creation and are included in benchmarks.
 package hashing
 
 import (
@@ -28,15 +32,41 @@ type Parameters interface {
 type HashFuncFactory func(p Parameters) (HashFunc, error)
 
 //nolint:gochecknoglobals
-var hashFunctions = map[string]HashFuncFactory{}
+var (
+	hashFunctionsMutex sync.RWMutex
+	hashFunctions      = map[string]HashFuncFactory{}
+)
 
 // Register registers a hash function with a given name.
+// It panics if the name is empty, the factory is nil or the name has already been registered.
 func Register(name string, newHashFunc HashFuncFactory) {
+	if name == "" || newHashFunc == nil {
+		panic("hashing: invalid registration of " + name)
+	}
+
+	hashFunctionsMutex.Lock()
+	defer hashFunctionsMutex.Unlock()
+
+	if _, ok := hashFunctions[name]; ok {
+		panic("hashing: Register called twice for " + name)
+	}
+
 	hashFunctions[name] = newHashFunc
 }
 
+// IsSupported returns true if a hash function with a given name has been registered.
+func IsSupported(name string) bool {
+	hashFunctionsMutex.RLock()
+	defer hashFunctionsMutex.RUnlock()
+
+	return hashFunctions[name] != nil
+}
+
 // SupportedAlgorithms returns the names of the supported hashing schemes.
 func SupportedAlgorithms() []string {
+	hashFunctionsMutex.RLock()
+	defer hashFunctionsMutex.RUnlock()
+
 	var result []string
 	for k := range hashFunctions {
 		result = append(result, k)
@@ -50,6 +80,16 @@ func SupportedAlgorithms() []string {
 // DefaultAlgorithm is the name of the default hash algorithm.
 const DefaultAlgorithm = "BLAKE2B-256-128"
 
+// TruncatedHMAC returns a HashFuncFactory that computes HMAC of a given content using the provided hash
+// and the HMAC secret of the repository, truncating results to the given size.
+func TruncatedHMAC(hf func() hash.Hash, truncate int) HashFuncFactory {
+	if truncate <= 0 || truncate > MaxHashSize {
+		panic("hashing: invalid hash size")
+	}
+
+	return truncatedHMACHashFuncFactory(hf, truncate)
+}
+
 // truncatedHMACHashFuncFactory returns a HashFuncFactory that computes HMAC(hash, secret) of a given content of bytes
 // and truncates results to the given size.
 func truncatedHMACHashFuncFactory(hf func() hash.Hash, truncate int) HashFuncFactory {
@@ -104,7 +144,10 @@ func truncatedKeyedHashFuncFactory(hf func(key []byte) (hash.Hash, error), trunc
 
 // CreateHashFunc creates hash function from a given parameters.
 func CreateHashFunc(p Parameters) (HashFunc, error) {
+	hashFunctionsMutex.RLock()
 	h := hashFunctions[p.GetHashFunction()]
+	hashFunctionsMutex.RUnlock()
+
 	if h == nil {
 		return nil, errors.Errorf("unknown hash function %v", p.GetHashFunction())
 	}
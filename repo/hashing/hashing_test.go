@@ -3,8 +3,11 @@
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha512"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo/hashing"
 )
@@ -53,3 +56,22 @@ func TestRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestRegister(t *testing.T) {
+	const name = "TEST-HMAC-SHA512-256"
+
+	hashing.Register(name, hashing.TruncatedHMAC(sha512.New, 32))
+
+	require.True(t, hashing.IsSupported(name))
+	require.Contains(t, hashing.SupportedAlgorithms(), name)
+	require.Panics(t, func() {
+		hashing.Register(name, hashing.TruncatedHMAC(sha512.New, 32))
+	})
+	require.Panics(t, func() {
+		hashing.TruncatedHMAC(sha512.New, hashing.MaxHashSize+1)
+	})
+
+	f, err := hashing.CreateHashFunc(parameters{name, []byte("secret")})
+	require.NoError(t, err)
+	require.Len(t, f(nil, gather.FromSlice([]byte("hello"))), 32)
+}
@@ -9,12 +9,15 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/crypto"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/layout"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/hashing"
+	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/repo/splitter"
 )
 
@@ -42,6 +45,11 @@ type NewRepositoryOptions struct {
 	RetentionMode                     blob.RetentionMode   `json:"retentionMode,omitempty"`
 	RetentionPeriod                   time.Duration        `json:"retentionPeriod,omitempty"`
 	FormatBlockKeyDerivationAlgorithm string               `json:"formatBlockKeyDerivationAlgorithm,omitempty"`
+	BlobLayout                        *layout.Parameters   `json:"blobLayout,omitempty"` // custom layout of blobs in the storage
+
+	// Passwordless creates a repository unlocked using a random key stored in a key file, which is passed
+	// in place of the password. FormatBlockKeyDerivationAlgorithm is ignored.
+	Passwordless bool `json:"passwordless,omitempty"`
 }
 
 // Initialize creates initial repository data structures in the specified storage with given credentials.
@@ -63,13 +71,19 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 }
 
 func formatBlobFromOptions(opt *NewRepositoryOptions) *format.KopiaRepositoryJSON {
+	keyDerivationAlgorithm := opt.FormatBlockKeyDerivationAlgorithm
+	if opt.Passwordless {
+		keyDerivationAlgorithm = crypto.KeyFileAlgorithm
+	}
+
 	return &format.KopiaRepositoryJSON{
 		Tool:                   "https://github.com/kopia/kopia",
 		BuildInfo:              BuildInfo,
 		BuildVersion:           BuildVersion,
-		KeyDerivationAlgorithm: opt.FormatBlockKeyDerivationAlgorithm,
+		KeyDerivationAlgorithm: keyDerivationAlgorithm,
 		UniqueID:               applyDefaultRandomBytes(opt.UniqueID, format.UniqueIDLengthBytes),
 		EncryptionAlgorithm:    format.DefaultFormatEncryption,
+		BlobLayout:             opt.BlobLayout,
 	}
 }
 
@@ -101,6 +115,8 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) (*format.Repos
 			Encryption:         applyDefaultString(opt.BlockFormat.Encryption, encryption.DefaultAlgorithm),
 			ECC:                applyDefaultString(opt.BlockFormat.ECC, ecc.DefaultAlgorithm),
 			ECCOverheadPercent: applyDefaultIntRange(opt.BlockFormat.ECCOverheadPercent, 0, 100), //nolint:mnd
+			ECCDataShards:      opt.BlockFormat.ECCDataShards,
+			ECCParityShards:    opt.BlockFormat.ECCParityShards,
 			HMACSecret:         applyDefaultRandomBytes(opt.BlockFormat.HMACSecret, hmacSecretLength),
 			MasterKey:          applyDefaultRandomBytes(opt.BlockFormat.MasterKey, masterKeyLength),
 			MutableParameters: format.MutableParameters{
@@ -112,7 +128,10 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) (*format.Repos
 			EnablePasswordChange: opt.BlockFormat.EnablePasswordChange,
 		},
 		ObjectFormat: format.ObjectFormat{
-			Splitter: applyDefaultString(opt.ObjectFormat.Splitter, splitter.DefaultAlgorithm),
+			Splitter:        applyDefaultString(opt.ObjectFormat.Splitter, splitter.DefaultAlgorithm),
+			SplitterMinSize: opt.ObjectFormat.SplitterMinSize,
+			SplitterAvgSize: opt.ObjectFormat.SplitterAvgSize,
+			SplitterMaxSize: opt.ObjectFormat.SplitterMaxSize,
 		},
 	}
 
@@ -120,9 +139,20 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) (*format.Repos
 		f.HMACSecret = nil
 	}
 
+	if d, p := f.ContentFormat.ECCDataShards, f.ContentFormat.ECCParityShards; d > 0 && p > 0 && f.ContentFormat.ECCOverheadPercent == 0 {
+		// explicit number of shards enables ECC, overhead determines the maximum shard size.
+		f.ContentFormat.ECCOverheadPercent = applyDefaultIntRange((100*p+d-1)/d, 1, 100) //nolint:mnd
+	}
+
 	if fv == format.FormatVersion1 || f.ContentFormat.ECCOverheadPercent == 0 {
 		f.ContentFormat.ECC = ""
 		f.ContentFormat.ECCOverheadPercent = 0
+		f.ContentFormat.ECCDataShards = 0
+		f.ContentFormat.ECCParityShards = 0
+	}
+
+	if _, err := splitter.GetFactoryWithParameters(f.ObjectFormat.Splitter, object.SplitterParameters(f.ObjectFormat)); err != nil {
+		return nil, errors.Wrap(err, "invalid object format")
 	}
 
 	if err := f.ContentFormat.ResolveFormatVersion(); err != nil {
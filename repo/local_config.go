@@ -11,6 +11,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/keyprovider"
 	"github.com/kopia/kopia/internal/ospath"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/throttling"
@@ -31,6 +32,9 @@ type ClientOptions struct {
 	ReadOnly               bool `json:"readonly,omitempty"`
 	PermissiveCacheLoading bool `json:"permissiveCacheLoading,omitempty"`
 
+	// VerifyContentHashOnRead forces verification of content hashes on every read from this connection.
+	VerifyContentHashOnRead bool `json:"verifyContentHashOnRead,omitempty"`
+
 	// Description is human-readable description of the repository to use in the UI.
 	Description string `json:"description,omitempty"`
 
@@ -39,6 +43,12 @@ type ClientOptions struct {
 	FormatBlobCacheDuration time.Duration `json:"formatBlobCacheDuration,omitempty"`
 
 	Throttling *throttling.Limits `json:"throttlingLimits,omitempty"`
+
+	// KeyProvider specifies external key storage from which the repository password is retrieved.
+	KeyProvider *keyprovider.Config `json:"keyProvider,omitempty"`
+
+	// EncryptionDomain is the name of the encryption domain in which this connection writes new contents.
+	EncryptionDomain string `json:"encryptionDomain,omitempty"`
 }
 
 // ApplyDefaults returns a copy of ClientOptions with defaults filled out.
@@ -96,6 +106,9 @@ type LocalConfig struct {
 	// Storage is only provided for direct repository access.
 	Storage *blob.ConnectionInfo `json:"storage,omitempty"`
 
+	// ReadMirrors are storage endpoints holding copies of the repository, used for reads when Storage is unavailable.
+	ReadMirrors []blob.ConnectionInfo `json:"readMirrors,omitempty"`
+
 	Caching *content.CachingOptions `json:"caching,omitempty"`
 
 	ClientOptions
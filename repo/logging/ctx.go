@@ -52,3 +52,17 @@ func loggerFactoryFromContext(ctx context.Context) LoggerFactory {
 
 	return v.(*loggerCache).getLogger //nolint:forcetypeassert
 }
+
+// WithFields returns a derived context where all loggers include the provided structured fields
+// as key-value pairs, e.g. WithFields(ctx, "source", src, "user", user).
+func WithFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	if len(keysAndValues) == 0 {
+		return ctx
+	}
+
+	originalLogFactory := loggerFactoryFromContext(ctx)
+
+	return WithLogger(ctx, func(module string) Logger {
+		return originalLogFactory(module).With(keysAndValues...)
+	})
+}
@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Names of logging subsystems whose levels can be adjusted independently.
+const (
+	SubsystemUpload = "upload"
+	SubsystemBlob   = "blob"
+	SubsystemIndex  = "index"
+	SubsystemServer = "server"
+)
+
+// subsystemModules maps each subsystem to the module names (or module name prefixes) it covers.
+//
+//nolint:gochecknoglobals
+var subsystemModules = map[string][]string{
+	SubsystemUpload: {"uploader", "repofs", "estimate", "kopia/snapshot"},
+	SubsystemBlob:   {"blob", "sharded", "throttling", "retry", "repo/filesystem", "rclone", "sftp", "gdrive", "azure-immutability", "eventually-consistent"},
+	SubsystemIndex:  {"kopia/format", "kopia/repo/format", "kopia/manifest"},
+	SubsystemServer: {"kopia/server", "auth", "scheduler", "kopia/webdavmount", "mount", "fuse"},
+}
+
+// Subsystems returns the sorted list of subsystem names.
+func Subsystems() []string {
+	var result []string
+
+	for k := range subsystemModules {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// IsSubsystem returns true if the provided name is a valid subsystem name.
+func IsSubsystem(name string) bool {
+	_, ok := subsystemModules[name]
+	return ok
+}
+
+// SubsystemForModule returns the subsystem that the provided module belongs to or an empty string.
+func SubsystemForModule(module string) string {
+	for sub, modules := range subsystemModules {
+		for _, m := range modules {
+			if module == m || strings.HasPrefix(module, m+"/") {
+				return sub
+			}
+		}
+	}
+
+	return ""
+}
+
+// ParseLevel parses the provided log level name, accepting both "warn" and "warning".
+func ParseLevel(s string) (zapcore.Level, error) {
+	if strings.EqualFold(s, "warning") {
+		return zapcore.WarnLevel, nil
+	}
+
+	l, err := zapcore.ParseLevel(s)
+	if err != nil {
+		return l, errors.Wrapf(err, "invalid log level %q", s)
+	}
+
+	return l, nil
+}
+
+// LevelName returns the name of the log level in the format accepted by command-line flags.
+func LevelName(l zapcore.Level) string {
+	if l == zapcore.WarnLevel {
+		return "warning"
+	}
+
+	return l.String()
+}
+
+// ModuleLevels holds runtime-adjustable log level overrides for logging subsystems.
+// Subsystems without an override use the level configured for each log output.
+type ModuleLevels struct {
+	mu sync.RWMutex
+	// +checklocks:mu
+	overrides map[string]zapcore.Level
+}
+
+// NewModuleLevels returns new ModuleLevels without any overrides.
+func NewModuleLevels() *ModuleLevels {
+	return &ModuleLevels{
+		overrides: map[string]zapcore.Level{},
+	}
+}
+
+// Set sets the level override for a given subsystem.
+func (m *ModuleLevels) Set(subsystem string, level zapcore.Level) error {
+	if !IsSubsystem(subsystem) {
+		return errors.Errorf("unknown logging subsystem %q, must be one of %v", subsystem, Subsystems())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.overrides[subsystem] = level
+
+	return nil
+}
+
+// Clear removes the level override for a given subsystem.
+func (m *ModuleLevels) Clear(subsystem string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.overrides, subsystem)
+}
+
+// Get returns the level override for a given subsystem, if any.
+func (m *ModuleLevels) Get(subsystem string) (zapcore.Level, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.overrides[subsystem]
+
+	return l, ok
+}
+
+// Overrides returns a copy of all level overrides keyed by subsystem with level names as values.
+func (m *ModuleLevels) Overrides() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := map[string]string{}
+
+	for k, v := range m.overrides {
+		result[k] = LevelName(v)
+	}
+
+	return result
+}
+
+// SetFromString applies overrides in the form 'subsystem=level[,subsystem=level...]'.
+func (m *ModuleLevels) SetFromString(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sub, lvl, ok := strings.Cut(part, "=")
+		if !ok {
+			return errors.Errorf("invalid subsystem log level %q, expected subsystem=level", part)
+		}
+
+		l, err := ParseLevel(lvl)
+		if err != nil {
+			return err
+		}
+
+		if err := m.Set(sub, l); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Enabler returns a zapcore.LevelEnabler for a given subsystem, which uses the override when set
+// and falls back to the provided default otherwise. The override is evaluated on each call, so changes
+// made at runtime take effect immediately.
+func (m *ModuleLevels) Enabler(sub string, def zapcore.LevelEnabler) zapcore.LevelEnabler {
+	if sub == "" {
+		return def
+	}
+
+	return zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		if ov, ok := m.Get(sub); ok {
+			return l >= ov
+		}
+
+		return def.Enabled(l)
+	})
+}
@@ -0,0 +1,51 @@
+package logging_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/kopia/kopia/repo/logging"
+)
+
+func TestSubsystemForModule(t *testing.T) {
+	require.Equal(t, logging.SubsystemUpload, logging.SubsystemForModule("uploader"))
+	require.Equal(t, logging.SubsystemUpload, logging.SubsystemForModule("kopia/snapshot/policy"))
+	require.Equal(t, logging.SubsystemBlob, logging.SubsystemForModule("sharded"))
+	require.Equal(t, logging.SubsystemIndex, logging.SubsystemForModule("kopia/format"))
+	require.Equal(t, logging.SubsystemServer, logging.SubsystemForModule("kopia/server"))
+	require.Equal(t, "", logging.SubsystemForModule("kopia/cli"))
+	require.Equal(t, "", logging.SubsystemForModule("kopia/snapshotgc"))
+}
+
+func TestModuleLevels(t *testing.T) {
+	ml := logging.NewModuleLevels()
+
+	en := ml.Enabler(logging.SubsystemUpload, zap.InfoLevel)
+	require.False(t, en.Enabled(zapcore.DebugLevel))
+	require.True(t, en.Enabled(zapcore.InfoLevel))
+
+	require.NoError(t, ml.SetFromString("upload=debug, blob=warning"))
+	require.True(t, en.Enabled(zapcore.DebugLevel))
+
+	blobEnabler := ml.Enabler(logging.SubsystemBlob, zap.DebugLevel)
+	require.False(t, blobEnabler.Enabled(zapcore.InfoLevel))
+	require.True(t, blobEnabler.Enabled(zapcore.WarnLevel))
+
+	require.Equal(t, map[string]string{
+		"upload": "debug",
+		"blob":   "warning",
+	}, ml.Overrides())
+
+	ml.Clear(logging.SubsystemUpload)
+	require.False(t, en.Enabled(zapcore.DebugLevel))
+
+	// modules outside of any subsystem are unaffected.
+	require.Equal(t, zap.InfoLevel, ml.Enabler("", zap.InfoLevel))
+
+	require.Error(t, ml.SetFromString("no-such-subsystem=debug"))
+	require.Error(t, ml.SetFromString("upload=verbose"))
+	require.Error(t, ml.SetFromString("upload"))
+}
@@ -2,6 +2,8 @@
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -12,17 +14,76 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
 )
 
+// Reasons reported in BlobGCExplanation.
+const (
+	BlobGCReasonReferenced          = "referenced by index entries"
+	BlobGCReasonReferencedByDeleted = "referenced only by deleted index entries"
+	BlobGCReasonCreatedAfterStart   = "created after maintenance started"
+	BlobGCReasonTooNew              = "too new"
+	BlobGCReasonActiveSession       = "part of an active session"
+	BlobGCReasonUnreferenced        = "not referenced by any index entry"
+	BlobGCReasonQuarantined         = "not referenced by any index entry, quarantined until deletion"
+)
+
+// maxSampleContentIDs is the maximum number of content IDs included in BlobGCExplanation.
+const maxSampleContentIDs = 5
+
+// BlobGCExplanation describes why blob garbage collection decided to keep or delete a blob.
+type BlobGCExplanation struct {
+	BlobID    blob.ID   `json:"blobID"`
+	Length    int64     `json:"length"`
+	Timestamp time.Time `json:"timestamp"`
+	Delete    bool      `json:"delete"`
+	Reason    string    `json:"reason"`
+	SessionID string    `json:"sessionID,omitempty"`
+
+	// statistics about index entries referencing the blob, only set for referenced blobs.
+	ContentCount         int          `json:"contentCount,omitempty"`
+	DeletedContentCount  int          `json:"deletedContentCount,omitempty"`
+	ManifestContentCount int          `json:"manifestContentCount,omitempty"`
+	SampleContentIDs     []content.ID `json:"sampleContentIDs,omitempty"`
+}
+
 // DeleteUnreferencedBlobsOptions provides option for blob garbage collection algorithm.
 type DeleteUnreferencedBlobsOptions struct {
 	Parallel     int
 	Prefix       blob.ID
 	DryRun       bool
 	NotAfterTime time.Time
+
+	// Quarantine, when set, causes unreferenced blobs to be recorded in the deletion quarantine
+	// and only deleted when they are still unreferenced after the provided duration, which
+	// allows them to be recovered using UndeleteBlobs.
+	Quarantine time.Duration
+
+	// Explain, when set, is invoked (serially) for each blob considered by garbage collection,
+	// including blobs kept alive by index entries.
+	Explain func(e BlobGCExplanation)
+}
+
+func (o *DeleteUnreferencedBlobsOptions) explainer() func(bm blob.Metadata, e BlobGCExplanation) {
+	if o.Explain == nil {
+		return func(blob.Metadata, BlobGCExplanation) {}
+	}
+
+	var mu sync.Mutex
+
+	return func(bm blob.Metadata, e BlobGCExplanation) {
+		e.BlobID = bm.BlobID
+		e.Length = bm.Length
+		e.Timestamp = bm.Timestamp
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		o.Explain(e)
+	}
 }
 
-// DeleteUnreferencedBlobs deletes o was created after maintenance startederenced by index entries.
+// DeleteUnreferencedBlobs deletes blobs that are not referenced by index entries.
 //
 //nolint:gocyclo,funlen
 func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, opt DeleteUnreferencedBlobsOptions, safety SafetyParameters) (int, error) {
@@ -85,16 +146,39 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 
 	cutoffTime = cutoffTime.Add(cutoffTimeSlack)
 
+	explain := opt.explainer()
+
+	var quarantine *gcQuarantine
+
+	if opt.Quarantine > 0 {
+		q, qerr := GetBlobQuarantine(ctx, rep)
+		if qerr != nil {
+			return 0, errors.Wrap(qerr, "unable to load blob quarantine")
+		}
+
+		quarantine = newGCQuarantine(q, rep.Time(), opt.Quarantine, prefixes)
+	}
+
+	if opt.Explain != nil {
+		if err := explainReferencedBlobs(ctx, rep, prefixes, opt.Parallel, explain); err != nil {
+			return 0, errors.Wrap(err, "error explaining referenced blobs")
+		}
+	}
+
 	// iterate all pack blobs + session blobs and keep ones that are too young or
 	// belong to alive sessions.
 	if err := rep.ContentManager().IterateUnreferencedBlobs(ctx, prefixes, opt.Parallel, func(bm blob.Metadata) error {
 		if bm.Timestamp.After(cutoffTime) {
 			log(ctx).Debugf("  preserving %v because it was created after maintenance started", bm.BlobID)
+			explain(bm, BlobGCExplanation{Reason: BlobGCReasonCreatedAfterStart})
+
 			return nil
 		}
 
 		if age := cutoffTime.Sub(bm.Timestamp); age < safety.BlobDeleteMinAge {
 			log(ctx).Debugf("  preserving %v because it's too new (age: %v<%v)", bm.BlobID, age, safety.BlobDeleteMinAge)
+			explain(bm, BlobGCExplanation{Reason: BlobGCReasonTooNew})
+
 			return nil
 		}
 
@@ -102,11 +186,21 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 		if s, ok := activeSessions[sid]; ok {
 			if age := cutoffTime.Sub(s.CheckpointTime); age < safety.SessionExpirationAge {
 				log(ctx).Debugf("  preserving %v because it's part of an active session (%v)", bm.BlobID, sid)
+				explain(bm, BlobGCExplanation{Reason: BlobGCReasonActiveSession, SessionID: string(sid)})
+
 				return nil
 			}
 		}
 
+		if opt.Quarantine > 0 && !quarantine.shouldDelete(bm) {
+			log(ctx).Debugf("  preserving %v because it's quarantined", bm.BlobID)
+			explain(bm, BlobGCExplanation{Reason: BlobGCReasonQuarantined})
+
+			return nil
+		}
+
 		unreferenced.Add(bm.Length)
+		explain(bm, BlobGCExplanation{Reason: BlobGCReasonUnreferenced, Delete: true})
 
 		if !opt.DryRun {
 			unused <- bm
@@ -131,9 +225,165 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 		return int(unreferencedCount), nil
 	}
 
+	if quarantine != nil {
+		if err := quarantine.save(ctx, rep); err != nil {
+			return 0, err
+		}
+	}
+
 	del, cnt := deleted.Approximate()
 
 	log(ctx).Infof("Deleted total %v unreferenced blobs (%v)", del, units.BytesString(cnt))
 
 	return int(del), nil
 }
+
+// gcQuarantine tracks changes to the blob quarantine during a single garbage collection run.
+type gcQuarantine struct {
+	q        *BlobQuarantine
+	now      time.Time
+	period   time.Duration
+	prefixes []blob.ID
+
+	mu sync.Mutex
+	// +checklocks:mu
+	seen map[blob.ID]bool
+	// +checklocks:mu
+	added int
+}
+
+func newGCQuarantine(q *BlobQuarantine, now time.Time, period time.Duration, prefixes []blob.ID) *gcQuarantine {
+	return &gcQuarantine{
+		q:        q,
+		now:      now,
+		period:   period,
+		prefixes: prefixes,
+		seen:     map[blob.ID]bool{},
+	}
+}
+
+// shouldDelete returns true if the provided unreferenced blob has been quarantined for long enough
+// to be deleted, otherwise it adds the blob to the quarantine if it's not already there.
+func (g *gcQuarantine) shouldDelete(bm blob.Metadata) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.seen[bm.BlobID] = true
+
+	qb, ok := g.q.Blobs[bm.BlobID]
+	if !ok {
+		g.q.Blobs[bm.BlobID] = QuarantinedBlob{
+			BlobID:         bm.BlobID,
+			Length:         bm.Length,
+			Timestamp:      bm.Timestamp,
+			QuarantineTime: g.now,
+		}
+		g.added++
+
+		return false
+	}
+
+	return g.now.Sub(qb.QuarantineTime) >= g.period
+}
+
+// save persists the quarantine after removing blobs that have been deleted or are no longer unreferenced.
+func (g *gcQuarantine) save(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var removed int
+
+	for id, qb := range g.q.Blobs {
+		if !g.coversBlob(id) {
+			continue
+		}
+
+		if g.seen[id] && g.now.Sub(qb.QuarantineTime) < g.period {
+			continue
+		}
+
+		delete(g.q.Blobs, id)
+
+		removed++
+	}
+
+	if g.added == 0 && removed == 0 {
+		return nil
+	}
+
+	log(ctx).Infof("Quarantined %v unreferenced blobs, %v blobs remain in quarantine.", g.added, len(g.q.Blobs))
+
+	return errors.Wrap(SetBlobQuarantine(ctx, rep, g.q), "unable to save blob quarantine")
+}
+
+// coversBlob determines whether the provided blob was examined by this garbage collection run.
+func (g *gcQuarantine) coversBlob(id blob.ID) bool {
+	for _, p := range g.prefixes {
+		if strings.HasPrefix(string(id), string(p)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// explainReferencedBlobs reports all blobs matching the provided prefixes that are kept alive by index entries.
+func explainReferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, prefixes []blob.ID, parallel int, explain func(bm blob.Metadata, e BlobGCExplanation)) error {
+	var mu sync.Mutex
+
+	refs := map[blob.ID]*BlobGCExplanation{}
+
+	if err := rep.ContentManager().IterateContents(ctx, content.IterateOptions{
+		IncludeDeleted: true,
+		Parallel:       parallel,
+	}, func(ci content.Info) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		e := refs[ci.PackBlobID]
+		if e == nil {
+			e = &BlobGCExplanation{}
+			refs[ci.PackBlobID] = e
+		}
+
+		e.ContentCount++
+
+		if ci.Deleted {
+			e.DeletedContentCount++
+		}
+
+		if ci.ContentID.Prefix() == manifest.ContentPrefix {
+			e.ManifestContentCount++
+		}
+
+		if len(e.SampleContentIDs) < maxSampleContentIDs {
+			e.SampleContentIDs = append(e.SampleContentIDs, ci.ContentID)
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error iterating contents")
+	}
+
+	//nolint:wrapcheck
+	return blob.IterateAllPrefixesInParallel(ctx, parallel, rep.BlobStorage(), prefixes, func(bm blob.Metadata) error {
+		mu.Lock()
+		e := refs[bm.BlobID]
+		mu.Unlock()
+
+		if e == nil {
+			return nil
+		}
+
+		r := *e
+		r.Reason = BlobGCReasonReferenced
+
+		if r.DeletedContentCount == r.ContentCount {
+			r.Reason = BlobGCReasonReferencedByDeleted
+		}
+
+		explain(bm, r)
+
+		return nil
+	})
+}
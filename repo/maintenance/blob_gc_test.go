@@ -56,7 +56,7 @@ func (s *formatSpecificTestSuite) TestDeleteUnreferencedBlobs(t *testing.T) {
 	blobsBefore, err := blob.ListAllBlobs(ctx, env.RepositoryWriter.BlobStorage(), "")
 
 	require.NoError(t, err)
-	require.Len(t, blobsBefore, 4, "unexpected number of blobs after writing")
+	require.Len(t, blobsBefore, 4+2*format.FormatBlobReplicaCount, "unexpected number of blobs after writing")
 
 	// add some more unreferenced blobs
 	const (
@@ -149,6 +149,150 @@ func (s *formatSpecificTestSuite) TestDeleteUnreferencedBlobs(t *testing.T) {
 	require.Empty(t, diff, "unexpected blobs")
 }
 
+func (s *formatSpecificTestSuite) TestDeleteUnreferencedBlobsExplain(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, s.formatVersion)
+
+	w := env.RepositoryWriter.NewObjectWriter(ctx, object.WriterOptions{MetadataCompressor: "zstd-fastest"})
+	io.WriteString(w, "hello world!")
+	w.Result()
+	w.Close()
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	const extraBlobID blob.ID = "pdeadbeef1"
+
+	mustPutDummyBlob(t, env.RepositoryWriter.BlobStorage(), extraBlobID)
+
+	explain := func(safety maintenance.SafetyParameters) map[blob.ID]maintenance.BlobGCExplanation {
+		result := map[blob.ID]maintenance.BlobGCExplanation{}
+
+		n, err := maintenance.DeleteUnreferencedBlobs(ctx, env.RepositoryWriter, maintenance.DeleteUnreferencedBlobsOptions{
+			DryRun: true,
+			Explain: func(e maintenance.BlobGCExplanation) {
+				result[e.BlobID] = e
+			},
+		}, safety)
+		require.NoError(t, err)
+
+		deleteCount := 0
+
+		for _, e := range result {
+			if e.Delete {
+				deleteCount++
+			}
+		}
+
+		require.Equal(t, n, deleteCount)
+
+		return result
+	}
+
+	res := explain(maintenance.SafetyFull)
+	require.Equal(t, maintenance.BlobGCReasonTooNew, res[extraBlobID].Reason)
+	require.False(t, res[extraBlobID].Delete)
+
+	res = explain(maintenance.SafetyNone)
+	require.Equal(t, maintenance.BlobGCReasonUnreferenced, res[extraBlobID].Reason)
+	require.True(t, res[extraBlobID].Delete)
+
+	var referenced int
+
+	for id, e := range res {
+		if id == extraBlobID {
+			continue
+		}
+
+		require.Equal(t, maintenance.BlobGCReasonReferenced, e.Reason, id)
+		require.False(t, e.Delete)
+		require.NotZero(t, e.ContentCount)
+		require.NotEmpty(t, e.SampleContentIDs)
+
+		referenced++
+	}
+
+	require.NotZero(t, referenced)
+
+	// dry run did not delete anything
+	verifyBlobExists(t, env.RepositoryWriter.BlobStorage(), extraBlobID)
+}
+
+func (s *formatSpecificTestSuite) TestDeleteUnreferencedBlobsQuarantine(t *testing.T) {
+	ta := faketime.NewClockTimeWithOffset(0)
+
+	ctx, env := repotesting.NewEnvironment(t, s.formatVersion, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ta.NowFunc()
+		},
+	})
+
+	const (
+		extraBlobID1 blob.ID = "pdeadbeef1"
+		extraBlobID2 blob.ID = "pdeadbeef2"
+	)
+
+	st := env.RepositoryWriter.BlobStorage()
+
+	mustPutDummyBlob(t, st, extraBlobID1)
+	mustPutDummyBlob(t, st, extraBlobID2)
+
+	gc := func() {
+		t.Helper()
+
+		_, err := maintenance.DeleteUnreferencedBlobs(ctx, env.RepositoryWriter, maintenance.DeleteUnreferencedBlobsOptions{
+			Quarantine: time.Hour,
+		}, maintenance.SafetyNone)
+		require.NoError(t, err)
+	}
+
+	// unreferenced blobs are quarantined instead of being deleted.
+	gc()
+	verifyBlobExists(t, st, extraBlobID1)
+	verifyBlobExists(t, st, extraBlobID2)
+
+	q, err := maintenance.GetBlobQuarantine(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, q.Blobs, 2)
+
+	ta.Advance(30 * time.Minute)
+	gc()
+	verifyBlobExists(t, st, extraBlobID1)
+	verifyBlobExists(t, st, extraBlobID2)
+
+	// undeleting a blob removes it from the quarantine, dummy blob is not a valid pack
+	// so there are no index entries to recover.
+	undeleted, err := maintenance.UndeleteBlobs(ctx, env.RepositoryWriter, maintenance.UndeleteBlobsOptions{
+		BlobIDs: []blob.ID{extraBlobID1},
+	})
+	require.NoError(t, err)
+	require.Len(t, undeleted, 1)
+	require.Equal(t, extraBlobID1, undeleted[0].BlobID)
+
+	_, err = maintenance.UndeleteBlobs(ctx, env.RepositoryWriter, maintenance.UndeleteBlobsOptions{
+		BlobIDs: []blob.ID{"pnosuchblob"},
+	})
+	require.Error(t, err)
+
+	// after the quarantine period only the blob that was not undeleted gets deleted,
+	// the undeleted blob starts a new quarantine period.
+	ta.Advance(31 * time.Minute)
+	gc()
+	verifyBlobExists(t, st, extraBlobID1)
+	verifyBlobNotFound(t, st, extraBlobID2)
+
+	q, err = maintenance.GetBlobQuarantine(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, q.Blobs, 1)
+	require.WithinDuration(t, ta.NowFunc()(), q.Blobs[extraBlobID1].QuarantineTime, time.Minute)
+
+	ta.Advance(time.Hour)
+	gc()
+	verifyBlobNotFound(t, st, extraBlobID1)
+
+	q, err = maintenance.GetBlobQuarantine(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, q.Blobs)
+}
+
 func verifyBlobExists(t *testing.T, st blob.Storage, blobID blob.ID) {
 	t.Helper()
 
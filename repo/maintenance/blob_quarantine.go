@@ -0,0 +1,165 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+const blobQuarantineBlobID = "kopia.maintenance.quarantine"
+
+//nolint:gochecknoglobals
+var blobQuarantineAEADExtraData = []byte("maintenance-quarantine")
+
+// QuarantinedBlob describes a blob that was found to be unreferenced by blob garbage collection
+// and will be deleted once the quarantine period has elapsed.
+type QuarantinedBlob struct {
+	BlobID         blob.ID   `json:"blobID"`
+	Length         int64     `json:"length"`
+	Timestamp      time.Time `json:"timestamp"`
+	QuarantineTime time.Time `json:"quarantineTime"`
+}
+
+// BlobQuarantine is the list of blobs awaiting deletion, persisted in the repository.
+type BlobQuarantine struct {
+	Blobs map[blob.ID]QuarantinedBlob `json:"blobs"`
+}
+
+// Sorted returns quarantined blobs sorted by blob ID.
+func (q *BlobQuarantine) Sorted() []QuarantinedBlob {
+	var result []QuarantinedBlob
+
+	for _, qb := range q.Blobs {
+		result = append(result, qb)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BlobID < result[j].BlobID
+	})
+
+	return result
+}
+
+// GetBlobQuarantine returns the list of blobs awaiting deletion.
+func GetBlobQuarantine(ctx context.Context, rep repo.DirectRepository) (*BlobQuarantine, error) {
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	q := &BlobQuarantine{Blobs: map[blob.ID]QuarantinedBlob{}}
+
+	err := rep.BlobReader().GetBlob(ctx, blobQuarantineBlobID, 0, -1, &tmp)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		return q, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading quarantine blob")
+	}
+
+	j, err := decryptMaintenanceBlob(rep, tmp.ToByteSlice(), blobQuarantineAEADExtraData)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt quarantine blob")
+	}
+
+	if err := json.Unmarshal(j, q); err != nil {
+		return nil, errors.Wrap(err, "malformed quarantine blob")
+	}
+
+	if q.Blobs == nil {
+		q.Blobs = map[blob.ID]QuarantinedBlob{}
+	}
+
+	return q, nil
+}
+
+// SetBlobQuarantine persists the list of blobs awaiting deletion.
+func SetBlobQuarantine(ctx context.Context, rep repo.DirectRepositoryWriter, q *BlobQuarantine) error {
+	v, err := json.Marshal(q)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize JSON")
+	}
+
+	ciphertext, err := encryptMaintenanceBlob(rep, v, blobQuarantineAEADExtraData)
+	if err != nil {
+		return err
+	}
+
+	//nolint:wrapcheck
+	return rep.BlobStorage().PutBlob(ctx, blobQuarantineBlobID, gather.FromSlice(ciphertext), blob.PutOptions{})
+}
+
+// UndeleteBlobsOptions provides options for UndeleteBlobs.
+type UndeleteBlobsOptions struct {
+	// BlobIDs to undelete, all quarantined blobs if empty.
+	BlobIDs []blob.ID
+
+	DryRun bool
+}
+
+// UndeleteBlobs removes blobs from the deletion quarantine and recovers index entries for contents in
+// pack blobs, so that contents lost due to incorrect garbage collection become visible again.
+// Returns the list of blobs that were undeleted.
+func UndeleteBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, opt UndeleteBlobsOptions) ([]QuarantinedBlob, error) {
+	q, err := GetBlobQuarantine(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	var toUndelete []QuarantinedBlob
+
+	if len(opt.BlobIDs) == 0 {
+		toUndelete = q.Sorted()
+	} else {
+		for _, id := range opt.BlobIDs {
+			qb, ok := q.Blobs[id]
+			if !ok {
+				return nil, errors.Errorf("blob %v is not quarantined", id)
+			}
+
+			toUndelete = append(toUndelete, qb)
+		}
+	}
+
+	if opt.DryRun {
+		return toUndelete, nil
+	}
+
+	for _, qb := range toUndelete {
+		if isPackBlob(qb.BlobID) {
+			// blobs that are not valid packs are still removed from quarantine, giving them another full
+			// quarantine period before they are deleted.
+			infos, err := rep.ContentManager().RecoverIndexFromPackBlob(ctx, qb.BlobID, qb.Length, true)
+			if err != nil {
+				log(ctx).Errorf("unable to recover index entries from %v: %v", qb.BlobID, err)
+			} else {
+				log(ctx).Infof("Recovered %v index entries from %v", len(infos), qb.BlobID)
+			}
+		}
+
+		delete(q.Blobs, qb.BlobID)
+	}
+
+	if err := rep.ContentManager().Flush(ctx); err != nil {
+		return nil, errors.Wrap(err, "error flushing content manager")
+	}
+
+	if err := SetBlobQuarantine(ctx, rep, q); err != nil {
+		return nil, errors.Wrap(err, "unable to update quarantine")
+	}
+
+	return toUndelete, nil
+}
+
+func isPackBlob(id blob.ID) bool {
+	return strings.HasPrefix(string(id), string(content.PackBlobIDPrefixRegular)) ||
+		strings.HasPrefix(string(id), string(content.PackBlobIDPrefixSpecial))
+}
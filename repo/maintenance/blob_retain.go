@@ -125,7 +125,7 @@ func ExtendBlobRetentionTime(ctx context.Context, rep repo.DirectRepositoryWrite
 
 // CheckExtendRetention verifies if extension can be enabled due to maintenance and blob parameters.
 func CheckExtendRetention(ctx context.Context, blobCfg format.BlobStorageConfiguration, p *Params) error {
-	if !p.ExtendObjectLocks {
+	if !p.ExtendObjectLocks && !p.SnapshotLegalHold {
 		return nil
 	}
 
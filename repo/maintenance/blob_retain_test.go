@@ -15,6 +15,7 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/encryption"
+	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -53,7 +54,7 @@ func (s *formatSpecificTestSuite) TestExtendBlobRetentionTime(t *testing.T) {
 	blobsBefore, err := blob.ListAllBlobs(ctx, env.RepositoryWriter.BlobStorage(), "")
 
 	require.NoError(t, err)
-	require.Len(t, blobsBefore, 4, "unexpected number of blobs after writing")
+	require.Len(t, blobsBefore, 4+2*format.FormatBlobReplicaCount, "unexpected number of blobs after writing")
 
 	lastBlobIdx := len(blobsBefore) - 1
 	st := env.RootStorage().(blobtesting.RetentionStorage)
@@ -106,7 +107,7 @@ func (s *formatSpecificTestSuite) TestExtendBlobRetentionTimeDisabled(t *testing
 	blobsBefore, err := blob.ListAllBlobs(ctx, env.RepositoryWriter.BlobStorage(), "")
 
 	require.NoError(t, err)
-	require.Len(t, blobsBefore, 4, "unexpected number of blobs after writing")
+	require.Len(t, blobsBefore, 4+2*format.FormatBlobReplicaCount, "unexpected number of blobs after writing")
 
 	// Need to continue using TouchBlob because the environment only supports the
 	// locking map if no retention time is given.
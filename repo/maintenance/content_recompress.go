@@ -0,0 +1,167 @@
+package maintenance
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/content/index"
+)
+
+const noCompressionName compression.Name = "none"
+
+// RecompressContentsOptions provides options for RecompressContents.
+type RecompressContentsOptions struct {
+	Parallel       int
+	Compression    compression.Name
+	ContentIDRange content.IDRange
+	PackPrefix     blob.ID
+
+	// MinContentAge excludes contents written more recently than the provided duration.
+	MinContentAge time.Duration
+
+	// MaxBytes limits the number of bytes rewritten in a single invocation, 0 means no limit.
+	MaxBytes int64
+
+	DryRun bool
+}
+
+// RecompressContentsResult describes the outcome of RecompressContents.
+type RecompressContentsResult struct {
+	RewrittenCount int   `json:"rewrittenCount"`
+	RewrittenBytes int64 `json:"rewrittenBytes"`
+
+	// NextContentID is the ID of the first content that was not examined because MaxBytes
+	// has been reached, empty if the entire range has been processed.
+	NextContentID content.ID `json:"nextContentID"`
+}
+
+// RecompressContents rewrites contents that are not stored using the requested compression method.
+// This allows reclaiming space from contents written before compression was enabled, or
+// switching existing contents to a different compression algorithm.
+func RecompressContents(ctx context.Context, rep repo.DirectRepositoryWriter, opt *RecompressContentsOptions, safety SafetyParameters) (*RecompressContentsResult, error) {
+	if opt == nil {
+		return nil, errors.New("missing options")
+	}
+
+	headerID := content.NoCompression
+
+	if opt.Compression != "" && opt.Compression != noCompressionName {
+		comp, ok := compression.ByName[opt.Compression]
+		if !ok {
+			return nil, errors.Errorf("unsupported compression %q", opt.Compression)
+		}
+
+		headerID = comp.HeaderID()
+	}
+
+	minAge := max(opt.MinContentAge, safety.RewriteMinAge)
+
+	if opt.Parallel == 0 {
+		opt.Parallel = runtime.NumCPU() * parallelContentRewritesCPUMultiplier
+	}
+
+	if opt.Compression == "" {
+		opt.Compression = noCompressionName
+	}
+
+	log(ctx).Infof("Recompressing contents using %v...", opt.Compression)
+
+	var (
+		mu          sync.Mutex
+		result      RecompressContentsResult
+		failedCount int
+		wg          sync.WaitGroup
+	)
+
+	ch := make(chan content.Info)
+
+	for range opt.Parallel {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for ci := range ch {
+				log(ctx).Debugf("Recompressing content %v (%v bytes) from pack %v", ci.ContentID, ci.PackedLength, ci.PackBlobID)
+
+				if opt.DryRun {
+					continue
+				}
+
+				if err := rep.ContentManager().RewriteContentWithCompression(ctx, ci.ContentID, headerID); err != nil {
+					log(ctx).Infof("unable to recompress content %q: %v", ci.ContentID, err)
+
+					mu.Lock()
+					failedCount++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	errStop := errors.New("stop")
+
+	err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{Range: opt.ContentIDRange}, func(ci content.Info) error {
+		if ci.CompressionHeaderID == headerID || !hasPackPrefix(ci.PackBlobID, opt.PackPrefix) {
+			return nil
+		}
+
+		if rep.Time().Sub(ci.Timestamp()) < minAge {
+			return nil
+		}
+
+		if opt.MaxBytes > 0 && result.RewrittenBytes+int64(ci.PackedLength) > opt.MaxBytes && result.RewrittenCount > 0 {
+			result.NextContentID = ci.ContentID
+			return errStop
+		}
+
+		result.RewrittenCount++
+		result.RewrittenBytes += int64(ci.PackedLength)
+
+		ch <- ci
+
+		return nil
+	})
+
+	close(ch)
+	wg.Wait()
+
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	log(ctx).Infof("Recompressed %v contents (%v).", result.RewrittenCount, units.BytesString(result.RewrittenBytes))
+
+	if failedCount > 0 {
+		return nil, errors.Errorf("failed to recompress %v contents", failedCount)
+	}
+
+	if err := rep.ContentManager().Flush(ctx); err != nil {
+		return nil, errors.Wrap(err, "error flushing content manager")
+	}
+
+	return &result, nil
+}
+
+func hasPackPrefix(packID, prefix blob.ID) bool {
+	return strings.HasPrefix(string(packID), string(prefix))
+}
+
+// rangeStartingAt returns the ID range that starts at a given content ID.
+func rangeStartingAt(cid content.ID) content.IDRange {
+	if cid == content.EmptyID {
+		return index.AllIDs
+	}
+
+	return content.IDRange{StartID: index.IDPrefix(cid.String()), EndID: index.AllIDs.EndID}
+}
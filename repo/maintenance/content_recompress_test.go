@@ -0,0 +1,100 @@
+package maintenance_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+func (s *formatSpecificTestSuite) TestRecompressContents(t *testing.T) {
+	if s.formatVersion < format.FormatVersion2 {
+		t.Skip("compression is not supported")
+	}
+
+	ctx, env := repotesting.NewEnvironment(t, s.formatVersion)
+
+	payloads := map[content.ID][]byte{}
+
+	for i := range 10 {
+		data := bytes.Repeat([]byte{byte(i), 1, 2, 3}, 10000)
+
+		cid, err := env.RepositoryWriter.ContentManager().WriteContent(ctx, gather.FromSlice(data), "", content.NoCompression)
+		require.NoError(t, err)
+
+		payloads[cid] = data
+	}
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	zstdHeaderID := compression.ByName["zstd"].HeaderID()
+
+	// contents are too recent.
+	var res *maintenance.RecompressContentsResult
+
+	require.NoError(t, repo.DirectWriteSession(ctx, env.RepositoryWriter, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		var err error
+
+		res, err = maintenance.RecompressContents(ctx, w, &maintenance.RecompressContentsOptions{
+			Compression:   "zstd",
+			MinContentAge: 24 * time.Hour,
+		}, maintenance.SafetyNone)
+
+		return err
+	}))
+
+	require.Zero(t, res.RewrittenCount)
+
+	// recompress in bounded batches until all contents are processed.
+	var (
+		next    content.ID
+		batches int
+	)
+
+	for {
+		require.NoError(t, repo.DirectWriteSession(ctx, env.RepositoryWriter, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+			var err error
+
+			rng := content.IDRange{StartID: content.IDPrefix(next.String()), EndID: "\x7b"}
+
+			res, err = maintenance.RecompressContents(ctx, w, &maintenance.RecompressContentsOptions{
+				Compression:    "zstd",
+				ContentIDRange: rng,
+				MaxBytes:       100000,
+			}, maintenance.SafetyNone)
+
+			return err
+		}))
+
+		batches++
+		next = res.NextContentID
+
+		if next == content.EmptyID {
+			break
+		}
+	}
+
+	require.Greater(t, batches, 1)
+
+	env.MustReopen(t)
+
+	for cid, data := range payloads {
+		ci, err := env.RepositoryWriter.ContentInfo(ctx, cid)
+		require.NoError(t, err)
+		require.Equal(t, zstdHeaderID, ci.CompressionHeaderID)
+
+		got, err := env.RepositoryWriter.ContentReader().GetContent(ctx, cid)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	}
+}
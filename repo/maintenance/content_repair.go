@@ -0,0 +1,97 @@
+package maintenance
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// RepairContentsOptions provides options for RepairContents.
+type RepairContentsOptions struct {
+	Parallel   int
+	PackPrefix blob.ID
+	DryRun     bool
+}
+
+// RepairContentsStats returns statistics about contents checked by RepairContents.
+type RepairContentsStats struct {
+	CheckedContents       int `json:"checkedContents"`
+	CorruptedContents     int `json:"corruptedContents"`
+	RepairedContents      int `json:"repairedContents"`
+	UnrecoverableContents int `json:"unrecoverableContents"`
+}
+
+// RepairContents uses error correction data to find contents whose stored data is corrupted and rewrites them
+// into new pack blobs, using the corrected data. Pack blobs that held the corrupted contents are deleted by
+// subsequent blob garbage collection after all their contents have been rewritten.
+func RepairContents(ctx context.Context, rep repo.DirectRepositoryWriter, opt RepairContentsOptions) (*RepairContentsStats, error) {
+	if rep.ContentReader().ContentFormat().GetECCOverheadPercent() == 0 {
+		return nil, content.ErrErrorCorrectionNotEnabled
+	}
+
+	if opt.Parallel == 0 {
+		opt.Parallel = runtime.NumCPU()
+	}
+
+	var (
+		mu    sync.Mutex
+		stats RepairContentsStats
+	)
+
+	log(ctx).Info("Checking contents for corruption...")
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{
+		IncludeDeleted: true,
+		Parallel:       opt.Parallel,
+	}, func(ci content.Info) error {
+		if !strings.HasPrefix(string(ci.PackBlobID), string(opt.PackPrefix)) {
+			return nil
+		}
+
+		n, err := rep.ContentManager().CorruptedShards(ctx, ci)
+		if err == nil && n > 0 {
+			log(ctx).Infof("Content %v in %v has %v corrupted shards.", ci.ContentID, ci.PackBlobID, n)
+
+			if !opt.DryRun {
+				err = errors.Wrapf(rep.ContentManager().RewriteContent(ctx, ci.ContentID), "unable to rewrite content %v", ci.ContentID)
+			}
+		}
+
+		if err != nil {
+			log(ctx).Errorf("%v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		stats.CheckedContents++
+
+		if n > 0 {
+			stats.CorruptedContents++
+		}
+
+		switch {
+		case err != nil:
+			stats.UnrecoverableContents++
+		case n > 0 && !opt.DryRun:
+			stats.RepairedContents++
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	if err := rep.ContentManager().Flush(ctx); err != nil {
+		return nil, errors.Wrap(err, "error flushing content manager")
+	}
+
+	return &stats, nil
+}
@@ -7,6 +7,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/manifest"
 )
 
@@ -26,7 +27,30 @@ type Params struct {
 
 	ExtendObjectLocks bool `json:"extendObjectLocks"`
 
+	// SnapshotLegalHold, when set, prevents snapshots from being deleted before the object locks protecting
+	// their data expire and extends object locks during full maintenance, even if ExtendObjectLocks is not set.
+	SnapshotLegalHold bool `json:"snapshotLegalHold,omitempty"`
+
 	ListParallelism int `json:"listParallelism"`
+
+	Recompression *RecompressionParams `json:"recompression,omitempty"`
+
+	// BlobDeletionQuarantine, when set, causes unreferenced blobs to be kept in quarantine for
+	// the provided duration before being deleted, during which they can be undeleted.
+	BlobDeletionQuarantine time.Duration `json:"blobDeletionQuarantine,omitempty"`
+}
+
+// RecompressionParams specifies parameters for gradual recompression of existing contents
+// during full maintenance.
+type RecompressionParams struct {
+	// Compression is the desired compression method of existing contents.
+	Compression compression.Name `json:"compression"`
+
+	// MinAge excludes contents written more recently than the provided duration.
+	MinAge time.Duration `json:"minAge,omitempty"`
+
+	// MaxBytesPerRun limits the number of bytes rewritten in a single maintenance run.
+	MaxBytesPerRun int64 `json:"maxBytesPerRun,omitempty"`
 }
 
 // isOwnedByByThisUser determines whether current user is the maintenance owner.
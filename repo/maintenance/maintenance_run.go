@@ -21,6 +21,10 @@
 
 const maxClockSkew = 5 * time.Minute
 
+// manifestConsolidationMinContents is the number of small manifest contents that must accumulate
+// before maintenance merges them, so that infrequent snapshots don't cause rewrites on every run.
+const manifestConsolidationMinContents = 8
+
 // Mode describes the mode of maintenance to perform.
 type Mode string
 
@@ -42,15 +46,22 @@
 	TaskDeleteOrphanedBlobsFull      = "full-delete-blobs"
 	TaskRewriteContentsQuick         = "quick-rewrite-contents"
 	TaskRewriteContentsFull          = "full-rewrite-contents"
+	TaskRecompressContentsFull       = "full-recompress-contents"
 	TaskDropDeletedContentsFull      = "full-drop-deleted-content"
 	TaskIndexCompaction              = "index-compaction"
+	TaskManifestConsolidation        = "manifest-consolidation"
 	TaskExtendBlobRetentionTimeFull  = "extend-blob-retention-time"
 	TaskCleanupLogs                  = "cleanup-logs"
+	TaskUpdateRepositoryStats        = "update-repository-stats"
 	TaskEpochAdvance                 = "advance-epoch"
 	TaskEpochDeleteSupersededIndexes = "delete-superseded-epoch-indexes"
 	TaskEpochCleanupMarkers          = "cleanup-epoch-markers"
 	TaskEpochGenerateRange           = "generate-epoch-range-index"
 	TaskEpochCompactSingle           = "compact-single-epoch"
+
+	// runs of entire quick and full maintenance cycles.
+	TaskQuickMaintenance = "quick-maintenance"
+	TaskFullMaintenance  = "full-maintenance"
 )
 
 // shouldRun returns Mode if repository is due for periodic maintenance.
@@ -134,6 +145,12 @@ type RunParameters struct {
 
 	// timestamp of the last update of maintenance schedule blob
 	MaintenanceStartTime time.Time
+
+	// per-source statistics computed by snapshot garbage collection, nil if not available.
+	SourceStats []*SourceStats
+
+	// per user@host usage statistics computed from snapshots, nil if not available.
+	UsageStats *UsageStats
 }
 
 // NotOwnedError is returned when maintenance cannot run because it is owned by another user.
@@ -192,7 +209,7 @@ func RunExclusive(ctx context.Context, rep repo.DirectRepositoryWriter, mode Mod
 
 	defer l.Unlock() //nolint:errcheck
 
-	runParams := RunParameters{rep, mode, p, time.Time{}}
+	runParams := RunParameters{rep: rep, Mode: mode, Params: p}
 
 	// update schedule so that we don't run the maintenance again immediately if
 	// this process crashes.
@@ -218,7 +235,44 @@ func RunExclusive(ctx context.Context, rep repo.DirectRepositoryWriter, mode Mod
 		return errors.Wrap(err, "error refreshing indexes before maintenance")
 	}
 
-	return cb(ctx, runParams)
+	start := rep.Time()
+	err = cb(ctx, runParams)
+
+	reportMaintenanceRun(ctx, runParams, start, err)
+
+	return err
+}
+
+// reportMaintenanceRun records the outcome of the entire maintenance run in the schedule, where it's picked up
+// by the repository event log without maintenance having to write any contents.
+func reportMaintenanceRun(ctx context.Context, runParams RunParameters, start time.Time, runErr error) {
+	s, err := GetSchedule(ctx, runParams.rep)
+	if err != nil {
+		log(ctx).Errorf("unable to report maintenance run: %v", err)
+		return
+	}
+
+	ri := RunInfo{
+		Start: start,
+		End:   runParams.rep.Time(),
+	}
+
+	if runErr != nil {
+		ri.Error = runErr.Error()
+	} else {
+		ri.Success = true
+	}
+
+	taskType := TaskType(TaskQuickMaintenance)
+	if runParams.Mode == ModeFull {
+		taskType = TaskFullMaintenance
+	}
+
+	s.ReportRun(taskType, ri)
+
+	if err := SetSchedule(ctx, runParams.rep, s); err != nil {
+		log(ctx).Errorf("unable to report maintenance run: %v", err)
+	}
 }
 
 func checkClockSkewBounds(rp RunParameters) error {
@@ -257,6 +311,11 @@ func runQuickMaintenance(ctx context.Context, runParams RunParameters, safety Sa
 		return errors.Wrap(err, "unable to get schedule")
 	}
 
+	// merge manifests written by frequent snapshots into compact segments.
+	if err := runTaskManifestConsolidation(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error consolidating manifests")
+	}
+
 	em, ok, emerr := runParams.rep.ContentManager().EpochManager(ctx)
 	if ok {
 		log(ctx).Debug("running quick epoch maintenance only")
@@ -323,6 +382,34 @@ func notDeletingOrphanedBlobs(ctx context.Context, s *Schedule, safety SafetyPar
 	log(ctx).Infof("Skipping blob deletion because not enough time has passed yet (%v left).", left)
 }
 
+func runTaskUpdateRepositoryStats(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	return ReportRun(ctx, runParams.rep, TaskUpdateRepositoryStats, s, func() error {
+		st, err := UpdateRepositoryStats(ctx, runParams.rep, runParams.SourceStats, runParams.UsageStats)
+		if err != nil {
+			return err
+		}
+
+		log(ctx).Infof("Updated repository statistics: %v contents, %v sources.", st.Contents.Count, len(st.Sources))
+
+		return nil
+	})
+}
+
+func runTaskManifestConsolidation(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	return ReportRun(ctx, runParams.rep, TaskManifestConsolidation, s, func() error {
+		st, err := runParams.rep.ManifestManager().Consolidate(ctx, manifestConsolidationMinContents)
+		if err != nil {
+			return errors.Wrap(err, "error consolidating manifests")
+		}
+
+		if st.MergedContents > 0 {
+			log(ctx).Infof("Consolidated %v manifest contents into %v (%v manifests rewritten).", st.ContentsBefore, st.ContentsAfter, st.RewrittenEntries)
+		}
+
+		return nil
+	})
+}
+
 func runTaskCleanupLogs(ctx context.Context, runParams RunParameters, s *Schedule) error {
 	return ReportRun(ctx, runParams.rep, TaskCleanupLogs, s, func() error {
 		deleted, err := CleanupLogs(ctx, runParams.rep, runParams.Params.LogRetention.OrDefault())
@@ -441,11 +528,54 @@ func runTaskRewriteContentsFull(ctx context.Context, runParams RunParameters, s
 	})
 }
 
+func runTaskRecompressContentsFull(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
+	rp := runParams.Params.Recompression
+	if rp == nil || rp.Compression == "" {
+		log(ctx).Debug("Recompression of contents is disabled.")
+		return nil
+	}
+
+	now := runParams.rep.Time()
+
+	prog := s.Recompression
+	if prog == nil || prog.Compression != rp.Compression || (prog.Completed && now.Sub(prog.PassStartTime) >= rp.MinAge) {
+		// start a new pass, contents that were too recent during previous pass are now eligible.
+		prog = &RecompressionProgress{
+			Compression:   rp.Compression,
+			PassStartTime: now,
+		}
+	}
+
+	if prog.Completed {
+		log(ctx).Debugf("Recompression of contents using %v is complete.", prog.Compression)
+		return nil
+	}
+
+	return ReportRun(ctx, runParams.rep, TaskRecompressContentsFull, s, func() error {
+		res, err := RecompressContents(ctx, runParams.rep, &RecompressContentsOptions{
+			Compression:    rp.Compression,
+			ContentIDRange: rangeStartingAt(prog.NextContentID),
+			MinContentAge:  rp.MinAge,
+			MaxBytes:       rp.MaxBytesPerRun,
+		}, safety)
+		if err != nil {
+			return err
+		}
+
+		prog.NextContentID = res.NextContentID
+		prog.Completed = res.NextContentID == content.EmptyID
+		s.Recompression = prog
+
+		return nil
+	})
+}
+
 func runTaskDeleteOrphanedBlobsFull(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
 	return ReportRun(ctx, runParams.rep, TaskDeleteOrphanedBlobsFull, s, func() error {
 		_, err := DeleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{
 			NotAfterTime: runParams.MaintenanceStartTime,
 			Parallel:     runParams.Params.ListParallelism,
+			Quarantine:   runParams.Params.BlobDeletionQuarantine,
 		}, safety)
 
 		return err
@@ -458,6 +588,7 @@ func runTaskDeleteOrphanedBlobsQuick(ctx context.Context, runParams RunParameter
 			NotAfterTime: runParams.MaintenanceStartTime,
 			Prefix:       content.PackBlobIDPrefixSpecial,
 			Parallel:     runParams.Params.ListParallelism,
+			Quarantine:   runParams.Params.BlobDeletionQuarantine,
 		}, safety)
 
 		return err
@@ -477,12 +608,22 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		return errors.Wrap(err, "unable to get schedule")
 	}
 
+	// merge manifests written by frequent snapshots into compact segments.
+	if err := runTaskManifestConsolidation(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error consolidating manifests")
+	}
+
 	if shouldFullRewriteContents(s, safety) {
 		// find packs that are less than 80% full and rewrite contents in them into
 		// new consolidated packs, orphaning old packs in the process.
 		if err := runTaskRewriteContentsFull(ctx, runParams, s, safety); err != nil {
 			return errors.Wrap(err, "error rewriting contents in short packs")
 		}
+
+		// gradually recompress existing contents, if requested.
+		if err := runTaskRecompressContentsFull(ctx, runParams, s, safety); err != nil {
+			return errors.Wrap(err, "error recompressing contents")
+		}
 	} else {
 		notRewritingContents(ctx)
 	}
@@ -503,7 +644,7 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 	}
 
 	// extend retention-time on supported storage.
-	if runParams.Params.ExtendObjectLocks {
+	if runParams.Params.ExtendObjectLocks || runParams.Params.SnapshotLegalHold {
 		if err := runTaskExtendBlobRetentionTimeFull(ctx, runParams, s); err != nil {
 			return errors.Wrap(err, "error extending object lock retention time")
 		}
@@ -515,6 +656,10 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		return errors.Wrap(err, "error cleaning up epoch manager")
 	}
 
+	if err := runTaskUpdateRepositoryStats(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error updating repository statistics")
+	}
+
 	// clean up logs last
 	if err := runTaskCleanupLogs(ctx, runParams, s); err != nil {
 		return errors.Wrap(err, "error cleaning up logs")
@@ -527,7 +672,7 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 // since each content rewrite will require deleting of orphaned blobs after some time passes,
 // we don't want to starve blob deletion by constantly doing rewrites.
 func shouldQuickRewriteContents(s *Schedule, safety SafetyParameters) bool {
-	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRewriteContentsQuick])
+	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRewriteContentsQuick], s.Runs[TaskRecompressContentsFull])
 	latestBlobDeleteTime := maxEndTime(s.Runs[TaskDeleteOrphanedBlobsFull], s.Runs[TaskDeleteOrphanedBlobsQuick])
 
 	// never did rewrite - safe to do so.
@@ -544,7 +689,7 @@ func shouldQuickRewriteContents(s *Schedule, safety SafetyParameters) bool {
 func shouldFullRewriteContents(s *Schedule, safety SafetyParameters) bool {
 	// NOTE - we're not looking at TaskRewriteContentsQuick here, this allows full rewrite to sometimes
 	// follow quick rewrite.
-	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull])
+	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRecompressContentsFull])
 	latestBlobDeleteTime := maxEndTime(s.Runs[TaskDeleteOrphanedBlobsFull], s.Runs[TaskDeleteOrphanedBlobsQuick])
 
 	// never did rewrite - safe to do so.
@@ -565,7 +710,7 @@ func shouldDeleteOrphanedPacks(now time.Time, s *Schedule, safety SafetyParamete
 }
 
 func nextBlobDeleteTime(s *Schedule, safety SafetyParameters) time.Time {
-	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRewriteContentsQuick])
+	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRewriteContentsQuick], s.Runs[TaskRecompressContentsFull])
 	if latestContentRewriteEndTime.IsZero() {
 		return time.Time{}
 	}
@@ -128,6 +128,19 @@ func TestShouldRewriteContents(t *testing.T) {
 			wantFull:  true,
 			wantQuick: true,
 		},
+		{
+			runs: map[TaskType][]RunInfo{
+				TaskDeleteOrphanedBlobsQuick: {
+					RunInfo{Success: true, End: t0700},
+				},
+				TaskRecompressContentsFull: {
+					RunInfo{Success: true, End: t0715},
+				},
+			},
+			safety:    SafetyFull,
+			wantFull:  false,
+			wantQuick: false,
+		},
 		{
 			runs: map[TaskType][]RunInfo{
 				TaskDeleteOrphanedBlobsQuick: {
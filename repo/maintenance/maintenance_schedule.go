@@ -14,6 +14,8 @@
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
 )
 
 const (
@@ -44,6 +46,16 @@ type Schedule struct {
 	NextQuickMaintenanceTime time.Time `json:"nextQuickMaintenance"`
 
 	Runs map[TaskType][]RunInfo `json:"runs"`
+
+	Recompression *RecompressionProgress `json:"recompression,omitempty"`
+}
+
+// RecompressionProgress keeps track of the progress of gradual recompression of contents.
+type RecompressionProgress struct {
+	Compression   compression.Name `json:"compression"`
+	PassStartTime time.Time        `json:"passStart"`
+	NextContentID content.ID       `json:"nextContentID"`
+	Completed     bool             `json:"completed,omitempty"`
 }
 
 // ReportRun adds the provided run information to the history and discards oldest entried.
@@ -72,6 +84,39 @@ func getAES256GCM(rep repo.DirectRepository) (cipher.AEAD, error) {
 	return cipher.NewGCM(c)
 }
 
+// decryptMaintenanceBlob decrypts the contents of a maintenance blob encrypted with encryptMaintenanceBlob.
+func decryptMaintenanceBlob(rep repo.DirectRepository, v, extraData []byte) ([]byte, error) {
+	c, err := getAES256GCM(rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get cipher")
+	}
+
+	if len(v) < c.NonceSize() {
+		return nil, errors.New("invalid maintenance blob")
+	}
+
+	//nolint:wrapcheck
+	return c.Open(nil, v[0:c.NonceSize()], v[c.NonceSize():], extraData)
+}
+
+// encryptMaintenanceBlob encrypts the contents of a maintenance blob with AES-256-GCM and random nonce.
+func encryptMaintenanceBlob(rep repo.DirectRepository, v, extraData []byte) ([]byte, error) {
+	c, err := getAES256GCM(rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get cipher")
+	}
+
+	// generate random nonce
+	nonce := make([]byte, c.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to initialize nonce")
+	}
+
+	result := append([]byte(nil), nonce...)
+
+	return c.Seal(result, nonce, v, extraData), nil
+}
+
 // TimeToAttemptNextMaintenance returns the time when we should attempt next maintenance.
 // if the maintenance is not owned by this user, returns time.Time{}.
 func TimeToAttemptNextMaintenance(ctx context.Context, rep repo.DirectRepository) (time.Time, error) {
@@ -126,19 +171,7 @@ func GetSchedule(ctx context.Context, rep repo.DirectRepository) (*Schedule, err
 		return nil, errors.Wrap(err, "error reading schedule blob")
 	}
 
-	// decrypt
-	c, err := getAES256GCM(rep)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to get cipher")
-	}
-
-	v := tmp.ToByteSlice()
-
-	if len(v) < c.NonceSize() {
-		return nil, errors.New("invalid schedule blob")
-	}
-
-	j, err := c.Open(nil, v[0:c.NonceSize()], v[c.NonceSize():], maintenanceScheduleAEADExtraData)
+	j, err := decryptMaintenanceBlob(rep, tmp.ToByteSlice(), maintenanceScheduleAEADExtraData)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to decrypt schedule blob")
 	}
@@ -160,25 +193,26 @@ func SetSchedule(ctx context.Context, rep repo.DirectRepositoryWriter, s *Schedu
 		return errors.Wrap(err, "unable to serialize JSON")
 	}
 
-	// encrypt with AES-256-GCM and random nonce
-	c, err := getAES256GCM(rep)
+	ciphertext, err := encryptMaintenanceBlob(rep, v, maintenanceScheduleAEADExtraData)
 	if err != nil {
-		return errors.Wrap(err, "unable to get cipher")
-	}
-
-	// generate random nonce
-	nonce := make([]byte, c.NonceSize())
-	if _, err := rand.Read(nonce); err != nil {
-		return errors.Wrap(err, "unable to initialize nonce")
+		return err
 	}
 
-	result := append([]byte(nil), nonce...)
-	ciphertext := c.Seal(result, nonce, v, maintenanceScheduleAEADExtraData)
-
 	//nolint:wrapcheck
 	return rep.BlobStorage().PutBlob(ctx, maintenanceScheduleBlobID, gather.FromSlice(ciphertext), blob.PutOptions{})
 }
 
+// PhaseObserver is notified when individual maintenance tasks start and finish.
+type PhaseObserver func(taskType TaskType, finished bool, err error)
+
+type phaseObserverKey struct{}
+
+// WithPhaseObserver returns a context which causes ReportRun to notify the provided observer
+// about maintenance tasks as they run.
+func WithPhaseObserver(ctx context.Context, o PhaseObserver) context.Context {
+	return context.WithValue(ctx, phaseObserverKey{}, o)
+}
+
 // ReportRun reports timing of a maintenance run and persists it in repository.
 func ReportRun(ctx context.Context, rep repo.DirectRepositoryWriter, taskType TaskType, s *Schedule, run func() error) error {
 	if s == nil {
@@ -194,8 +228,17 @@ func ReportRun(ctx context.Context, rep repo.DirectRepositoryWriter, taskType Ta
 		Start: rep.Time(),
 	}
 
+	observer, _ := ctx.Value(phaseObserverKey{}).(PhaseObserver)
+	if observer != nil {
+		observer(taskType, false, nil)
+	}
+
 	runErr := run()
 
+	if observer != nil {
+		observer(taskType, true, runErr)
+	}
+
 	ri.End = rep.Time()
 
 	if runErr != nil {
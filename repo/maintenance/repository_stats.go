@@ -0,0 +1,249 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+)
+
+const repositoryStatsBlobID = "kopia.maintenance.stats"
+
+//nolint:gochecknoglobals
+var repositoryStatsAEADExtraData = []byte("maintenance-stats")
+
+// ContentAgeBuckets are the upper bounds of content age histogram buckets, the last bucket is unbounded.
+//
+//nolint:gochecknoglobals
+var ContentAgeBuckets = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	90 * 24 * time.Hour,
+	365 * 24 * time.Hour,
+}
+
+// ContentStats contains totals for a set of contents.
+type ContentStats struct {
+	Count         int64 `json:"count"`
+	OriginalBytes int64 `json:"originalBytes"`
+	PackedBytes   int64 `json:"packedBytes"`
+}
+
+// Add adds the provided content to the totals.
+func (s *ContentStats) Add(ci content.Info) {
+	s.Count++
+	s.OriginalBytes += int64(ci.OriginalLength)
+	s.PackedBytes += int64(ci.PackedLength)
+}
+
+// ContentAgeBucket contains totals for contents written within an age range.
+type ContentAgeBucket struct {
+	// MaxAge is the upper bound of the content age in the bucket, zero for unbounded.
+	MaxAge time.Duration `json:"maxAge"`
+	ContentStats
+}
+
+// SourceStats contains statistics of contents attributed to a snapshot source.
+// Each content is attributed to a single source, which first referenced it.
+type SourceStats struct {
+	Source             string    `json:"source"`
+	SnapshotCount      int       `json:"snapshotCount"`
+	LatestSnapshotTime time.Time `json:"latestSnapshotTime"`
+	ContentStats
+}
+
+// UserUsageStats contains storage attributed to snapshots of a single user@host pair.
+// All byte counts refer to contents as stored in the repository.
+type UserUsageStats struct {
+	UserName      string `json:"userName"`
+	Host          string `json:"host"`
+	SnapshotCount int    `json:"snapshotCount"`
+
+	// contents referenced only by snapshots of this user@host pair.
+	UniqueContentCount int64 `json:"uniqueContentCount"`
+	UniqueBytes        int64 `json:"uniqueBytes"`
+
+	// contents also referenced by snapshots of other user@host pairs.
+	SharedContentCount int64 `json:"sharedContentCount"`
+	SharedBytes        int64 `json:"sharedBytes"`
+}
+
+// UsageStats attributes storage used by snapshots to user@host pairs, for chargeback in shared repositories.
+type UsageStats struct {
+	UpdateTime time.Time `json:"updateTime"`
+
+	// distinct contents referenced by all snapshots.
+	ContentCount int64 `json:"contentCount"`
+	Bytes        int64 `json:"bytes"`
+
+	// distinct contents referenced by snapshots of more than one user@host pair.
+	SharedContentCount int64 `json:"sharedContentCount"`
+	SharedBytes        int64 `json:"sharedBytes"`
+
+	// per user@host statistics, ordered by user@host.
+	Users []*UserUsageStats `json:"users"`
+}
+
+// RepositoryStats is a summary of repository statistics persisted in the repository and refreshed during full maintenance,
+// so that it can be displayed without scanning all indexes.
+type RepositoryStats struct {
+	UpdateTime time.Time `json:"updateTime"`
+
+	// Contents contains totals of all contents that are not deleted.
+	Contents ContentStats `json:"contents"`
+
+	// ByCompression contains totals of contents by the name of compression method, empty for uncompressed contents.
+	ByCompression map[compression.Name]*ContentStats `json:"byCompression,omitempty"`
+
+	// ContentAge is the histogram of ages of contents.
+	ContentAge []*ContentAgeBucket `json:"contentAge,omitempty"`
+
+	// Sources contains per-source attribution computed during the most recent snapshot garbage collection.
+	Sources       []*SourceStats `json:"sources,omitempty"`
+	SourcesUpdate time.Time      `json:"sourcesUpdateTime,omitempty"`
+
+	// Usage contains per user@host storage attribution computed during the most recent full maintenance.
+	Usage *UsageStats `json:"usage,omitempty"`
+}
+
+// GetRepositoryStats returns the persisted repository statistics, nil if statistics have not been computed yet.
+func GetRepositoryStats(ctx context.Context, rep repo.DirectRepository) (*RepositoryStats, error) {
+	var tmp gather.WriteBuffer
+	defer tmp.Close()
+
+	err := rep.BlobReader().GetBlob(ctx, repositoryStatsBlobID, 0, -1, &tmp)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading statistics blob")
+	}
+
+	j, err := decryptMaintenanceBlob(rep, tmp.ToByteSlice(), repositoryStatsAEADExtraData)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt statistics blob")
+	}
+
+	s := &RepositoryStats{}
+
+	if err := json.Unmarshal(j, s); err != nil {
+		return nil, errors.Wrap(err, "malformed statistics blob")
+	}
+
+	return s, nil
+}
+
+// SetRepositoryStats persists the repository statistics.
+func SetRepositoryStats(ctx context.Context, rep repo.DirectRepositoryWriter, s *RepositoryStats) error {
+	v, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "unable to serialize JSON")
+	}
+
+	ciphertext, err := encryptMaintenanceBlob(rep, v, repositoryStatsAEADExtraData)
+	if err != nil {
+		return err
+	}
+
+	//nolint:wrapcheck
+	return rep.BlobStorage().PutBlob(ctx, repositoryStatsBlobID, gather.FromSlice(ciphertext), blob.PutOptions{})
+}
+
+// UpdateRepositoryStats recomputes content statistics from the index and persists them together with the
+// provided source and usage statistics. When sources or usage is nil, the corresponding statistics from
+// the previous update are preserved.
+func UpdateRepositoryStats(ctx context.Context, rep repo.DirectRepositoryWriter, sources []*SourceStats, usage *UsageStats) (*RepositoryStats, error) {
+	now := rep.Time()
+
+	s := &RepositoryStats{
+		UpdateTime:    now,
+		ByCompression: map[compression.Name]*ContentStats{},
+	}
+
+	for _, maxAge := range ContentAgeBuckets {
+		s.ContentAge = append(s.ContentAge, &ContentAgeBucket{MaxAge: maxAge})
+	}
+
+	s.ContentAge = append(s.ContentAge, &ContentAgeBucket{})
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		s.Contents.Add(ci)
+
+		cname := compression.HeaderIDToName[ci.CompressionHeaderID]
+
+		cs := s.ByCompression[cname]
+		if cs == nil {
+			cs = &ContentStats{}
+			s.ByCompression[cname] = cs
+		}
+
+		cs.Add(ci)
+
+		age := now.Sub(ci.Timestamp())
+
+		for _, b := range s.ContentAge {
+			if b.MaxAge == 0 || age < b.MaxAge {
+				b.Add(ci)
+				break
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	var prev *RepositoryStats
+
+	if sources == nil || usage == nil {
+		p, err := GetRepositoryStats(ctx, rep)
+		if err != nil {
+			return nil, err
+		}
+
+		prev = p
+	}
+
+	if sources != nil {
+		sort.Slice(sources, func(i, j int) bool {
+			return sources[i].Source < sources[j].Source
+		})
+
+		s.Sources = sources
+		s.SourcesUpdate = now
+	} else if prev != nil {
+		s.Sources = prev.Sources
+		s.SourcesUpdate = prev.SourcesUpdate
+	}
+
+	if usage != nil {
+		sort.Slice(usage.Users, func(i, j int) bool {
+			if usage.Users[i].UserName != usage.Users[j].UserName {
+				return usage.Users[i].UserName < usage.Users[j].UserName
+			}
+
+			return usage.Users[i].Host < usage.Users[j].Host
+		})
+
+		usage.UpdateTime = now
+		s.Usage = usage
+	} else if prev != nil {
+		s.Usage = prev.Usage
+	}
+
+	if err := SetRepositoryStats(ctx, rep, s); err != nil {
+		return nil, errors.Wrap(err, "unable to persist repository statistics")
+	}
+
+	return s, nil
+}
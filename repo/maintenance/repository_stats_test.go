@@ -0,0 +1,70 @@
+package maintenance_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func (s *formatSpecificTestSuite) TestRepositoryStats(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, s.formatVersion)
+
+	st, err := maintenance.GetRepositoryStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Nil(t, st)
+
+	for _, data := range []string{"hello", "world", "foo"} {
+		w := env.RepositoryWriter.NewObjectWriter(ctx, object.WriterOptions{})
+		_, err = w.Write([]byte(data))
+		require.NoError(t, err)
+
+		_, err = w.Result()
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	sources := []*maintenance.SourceStats{
+		{Source: "b@h:/y", SnapshotCount: 1},
+		{Source: "a@h:/x", SnapshotCount: 2},
+	}
+
+	usage := &maintenance.UsageStats{
+		Users: []*maintenance.UserUsageStats{
+			{UserName: "b", Host: "h", SnapshotCount: 1, UniqueBytes: 10},
+			{UserName: "a", Host: "h", SnapshotCount: 2, UniqueBytes: 20},
+		},
+	}
+
+	st, err = maintenance.UpdateRepositoryStats(ctx, env.RepositoryWriter, sources, usage)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, st.Contents.Count, int64(3))
+	require.Len(t, st.ContentAge, len(maintenance.ContentAgeBuckets)+1)
+	require.Equal(t, st.Contents, st.ContentAge[0].ContentStats, "all contents are recent")
+	require.Equal(t, "a@h:/x", st.Sources[0].Source)
+	require.Equal(t, "a", st.Usage.Users[0].UserName)
+	require.False(t, st.Usage.UpdateTime.IsZero())
+
+	var byCompression int64
+
+	for _, cs := range st.ByCompression {
+		byCompression += cs.Count
+	}
+
+	require.Equal(t, st.Contents.Count, byCompression)
+
+	// updating without sources and usage preserves statistics from the previous update.
+	st2, err := maintenance.UpdateRepositoryStats(ctx, env.RepositoryWriter, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, toJSON(t, st.Sources), toJSON(t, st2.Sources))
+	require.Equal(t, toJSON(t, st.Usage), toJSON(t, st2.Usage))
+
+	got, err := maintenance.GetRepositoryStats(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Equal(t, toJSON(t, st2), toJSON(t, got))
+}
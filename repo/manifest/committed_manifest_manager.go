@@ -32,8 +32,17 @@ type committedManifestManager struct {
 	locked bool
 	// +checklocks:cmmu
 	committedEntries map[ID]*manifestEntry
+	// latest deletion markers of entries which are not in committedEntries
+	// +checklocks:cmmu
+	deletedEntries map[ID]*manifestEntry
 	// +checklocks:cmmu
 	committedContentIDs map[content.ID]struct{}
+	// entries stored in each committed content, including deletion markers
+	// +checklocks:cmmu
+	contentEntries map[content.ID][]*manifestEntry
+	// IDs of committed entries for each "key=value" label
+	// +checklocks:cmmu
+	labelIndex map[string]map[ID]struct{}
 
 	// autoCompactionThreshold controls the threshold after which the manager auto-compacts
 	// manifest contents
@@ -77,7 +86,7 @@ func (m *committedManifestManager) findCommittedEntries(ctx context.Context, lab
 		return nil, err
 	}
 
-	return findEntriesMatchingLabels(m.committedEntries, labels), nil
+	return m.findCommittedEntriesLocked(labels), nil
 }
 
 func (m *committedManifestManager) commitEntries(ctx context.Context, entries map[ID]*manifestEntry) (map[content.ID]struct{}, error) {
@@ -93,7 +102,7 @@ func (m *committedManifestManager) commitEntries(ctx context.Context, entries ma
 
 // writeEntriesLocked writes entries in the provided map as manifest contents
 // and removes all entries from the map when complete and returns the set of content IDs written
-// (typically one).
+// (typically one, large sets of entries are split into multiple contents).
 //
 // NOTE: this function is used in two cases - to write pending entries (where the caller acquires
 // the lock via commitEntries()) and to compact existing committed entries during compaction
@@ -104,12 +113,24 @@ func (m *committedManifestManager) writeEntriesLocked(ctx context.Context, entri
 		return nil, nil
 	}
 
-	man := manifest{}
+	written := map[content.ID]struct{}{}
 
-	for _, e := range entries {
-		man.Entries = append(man.Entries, e)
+	for _, segment := range splitIntoSegments(entries) {
+		contentID, err := m.writeSegmentLocked(ctx, segment)
+		if err != nil {
+			return nil, err
+		}
+
+		written[contentID] = struct{}{}
 	}
 
+	return written, nil
+}
+
+// +checklocks:m.cmmu
+func (m *committedManifestManager) writeSegmentLocked(ctx context.Context, entries []*manifestEntry) (content.ID, error) {
+	man := manifest{Entries: entries}
+
 	var buf gather.WriteBuffer
 	defer buf.Close()
 
@@ -121,16 +142,42 @@ func (m *committedManifestManager) writeEntriesLocked(ctx context.Context, entri
 	// TODO: Configure manifest metadata compression with Policy setting
 	contentID, err := m.b.WriteContent(ctx, buf.Bytes(), ContentPrefix, compression.HeaderZstdFastest)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to write content")
+		return content.EmptyID, errors.Wrap(err, "unable to write content")
 	}
 
+	// resolve entries against existing committed state the same way as when loading contents,
+	// so that older versions or deletion markers being rewritten don't replace newer ones.
 	for _, e := range entries {
+		if prev := m.latestEntryLocked(e.ID); prev != nil && !e.ModTime.After(prev.ModTime) {
+			continue
+		}
+
+		if e.Deleted {
+			delete(m.committedEntries, e.ID)
+			m.deletedEntries[e.ID] = e
+
+			continue
+		}
+
+		delete(m.deletedEntries, e.ID)
 		m.committedEntries[e.ID] = e
+		m.addToLabelIndexLocked(e)
 	}
 
 	m.committedContentIDs[contentID] = struct{}{}
+	m.contentEntries[contentID] = entries
 
-	return map[content.ID]struct{}{contentID: {}}, nil
+	return contentID, nil
+}
+
+// latestEntryLocked returns the latest known version of the provided entry, including deletion markers.
+// +checklocks:m.cmmu
+func (m *committedManifestManager) latestEntryLocked(id ID) *manifestEntry {
+	if e := m.committedEntries[id]; e != nil {
+		return e
+	}
+
+	return m.deletedEntries[id]
 }
 
 // +checklocks:m.cmmu
@@ -193,10 +240,13 @@ func (m *committedManifestManager) loadCommittedContentsLocked(ctx context.Conte
 // +checklocks:m.cmmu
 func (m *committedManifestManager) loadManifestContentsLocked(manifests map[content.ID]manifest) {
 	m.committedEntries = map[ID]*manifestEntry{}
+	m.deletedEntries = map[ID]*manifestEntry{}
 	m.committedContentIDs = map[content.ID]struct{}{}
+	m.contentEntries = map[content.ID][]*manifestEntry{}
 
-	for contentID := range manifests {
+	for contentID, man := range manifests {
 		m.committedContentIDs[contentID] = struct{}{}
+		m.contentEntries[contentID] = man.Entries
 	}
 
 	for _, man := range manifests {
@@ -209,8 +259,11 @@ func (m *committedManifestManager) loadManifestContentsLocked(manifests map[cont
 	for k, e := range m.committedEntries {
 		if e.Deleted {
 			delete(m.committedEntries, k)
+			m.deletedEntries[k] = e
 		}
 	}
+
+	m.rebuildLabelIndexLocked()
 }
 
 func (m *committedManifestManager) compact(ctx context.Context) error {
@@ -226,13 +279,14 @@ func (m *committedManifestManager) maybeCompactLocked(ctx context.Context) error
 
 	// Don't attempt to compact manifests if the repo was opened in read only mode
 	// since we'll just end up failing.
-	if m.b.IsReadOnly() || len(m.committedContentIDs) < m.autoCompactionThreshold {
+	// Large segments don't count towards the threshold, they are only rewritten by full compaction.
+	if m.b.IsReadOnly() || len(m.smallContentsLocked()) < m.autoCompactionThreshold {
 		return nil
 	}
 
 	log(ctx).Debugf("performing automatic compaction of %v contents", len(m.committedContentIDs))
 
-	if err := m.compactLocked(ctx); err != nil {
+	if _, err := m.consolidateLocked(ctx, m.autoCompactionThreshold); err != nil {
 		return errors.Wrap(err, "unable to compact manifest contents")
 	}
 
@@ -275,6 +329,7 @@ func (m *committedManifestManager) compactLocked(ctx context.Context) error {
 		}
 
 		delete(m.committedContentIDs, b)
+		delete(m.contentEntries, b)
 	}
 
 	return nil
@@ -370,7 +425,10 @@ func newCommittedManager(b contentManager, autoCompactionThreshold int) *committ
 		b:                       b,
 		debugID:                 debugID,
 		committedEntries:        map[ID]*manifestEntry{},
+		deletedEntries:          map[ID]*manifestEntry{},
 		committedContentIDs:     map[content.ID]struct{}{},
+		contentEntries:          map[content.ID][]*manifestEntry{},
+		labelIndex:              map[string]map[ID]struct{}{},
 		autoCompactionThreshold: autoCompactionThreshold,
 	}
 }
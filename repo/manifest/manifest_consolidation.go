@@ -0,0 +1,278 @@
+package manifest
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/content"
+)
+
+const (
+	// maxEntriesPerManifestContent is the maximum number of entries written to a single manifest content,
+	// so that sources with very frequent snapshots result in multiple bounded segments instead of one huge content.
+	maxEntriesPerManifestContent = 5000
+
+	// manifest contents with fewer entries than this are merged together by consolidation,
+	// larger ones are left intact.
+	smallManifestContentEntries = maxEntriesPerManifestContent / 2
+)
+
+// ConsolidationStats describes the result of manifest consolidation.
+type ConsolidationStats struct {
+	ContentsBefore   int `json:"contentsBefore"`
+	ContentsAfter    int `json:"contentsAfter"`
+	MergedContents   int `json:"mergedContents"`
+	RewrittenEntries int `json:"rewrittenEntries"`
+	DroppedEntries   int `json:"droppedEntries"`
+}
+
+// Consolidate merges small manifest contents, such as those written by frequent snapshots, into larger
+// segments grouped by labels. Segments that are already large enough are not rewritten, so the cost of
+// consolidation is proportional to the number of manifests written since the last consolidation.
+// Nothing is merged unless there are at least minContents small contents.
+func (m *Manager) Consolidate(ctx context.Context, minContents int) (ConsolidationStats, error) {
+	if err := m.Flush(ctx); err != nil {
+		return ConsolidationStats{}, err
+	}
+
+	return m.committed.consolidate(ctx, minContents)
+}
+
+func labelIndexKey(k, v string) string {
+	return k + "=" + v
+}
+
+// +checklocks:m.cmmu
+func (m *committedManifestManager) addToLabelIndexLocked(e *manifestEntry) {
+	for k, v := range e.Labels {
+		key := labelIndexKey(k, v)
+
+		ids := m.labelIndex[key]
+		if ids == nil {
+			ids = map[ID]struct{}{}
+			m.labelIndex[key] = ids
+		}
+
+		ids[e.ID] = struct{}{}
+	}
+}
+
+// +checklocks:m.cmmu
+func (m *committedManifestManager) rebuildLabelIndexLocked() {
+	m.labelIndex = map[string]map[ID]struct{}{}
+
+	for _, e := range m.committedEntries {
+		m.addToLabelIndexLocked(e)
+	}
+}
+
+// findCommittedEntriesLocked uses the label index to find entries matching all provided labels
+// without scanning all entries.
+// +checklocks:m.cmmu
+func (m *committedManifestManager) findCommittedEntriesLocked(labels map[string]string) map[ID]*manifestEntry {
+	var smallest map[ID]struct{}
+
+	for k, v := range labels {
+		ids := m.labelIndex[labelIndexKey(k, v)]
+		if len(ids) == 0 {
+			return map[ID]*manifestEntry{}
+		}
+
+		if smallest == nil || len(ids) < len(smallest) {
+			smallest = ids
+		}
+	}
+
+	if smallest == nil {
+		// no labels, all entries match.
+		return findEntriesMatchingLabels(m.committedEntries, labels)
+	}
+
+	matches := map[ID]*manifestEntry{}
+
+	for id := range smallest {
+		if e := m.committedEntries[id]; e != nil && matchesLabels(e.Labels, labels) {
+			matches[id] = e
+		}
+	}
+
+	return matches
+}
+
+// sortedLabelsKey returns a string representation of labels used to group related entries together.
+func sortedLabelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var sb strings.Builder
+
+	for _, k := range keys {
+		sb.WriteString(labelIndexKey(k, labels[k]))
+		sb.WriteByte(0)
+	}
+
+	return sb.String()
+}
+
+// splitIntoSegments orders entries so that entries with the same labels (such as snapshots of a single source)
+// are adjacent and splits them into segments of bounded size.
+func splitIntoSegments(entries map[ID]*manifestEntry) [][]*manifestEntry {
+	sorted := make([]*manifestEntry, 0, len(entries))
+	keys := make(map[ID]string, len(entries))
+
+	for _, e := range entries {
+		sorted = append(sorted, e)
+		keys[e.ID] = sortedLabelsKey(e.Labels)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if ki, kj := keys[sorted[i].ID], keys[sorted[j].ID]; ki != kj {
+			return ki < kj
+		}
+
+		if ti, tj := sorted[i].ModTime, sorted[j].ModTime; !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	var result [][]*manifestEntry
+
+	for len(sorted) > 0 {
+		n := min(len(sorted), maxEntriesPerManifestContent)
+		result = append(result, sorted[:n])
+		sorted = sorted[n:]
+	}
+
+	return result
+}
+
+// +checklocks:m.cmmu
+func (m *committedManifestManager) smallContentsLocked() []content.ID {
+	var result []content.ID
+
+	for cid := range m.committedContentIDs {
+		if len(m.contentEntries[cid]) < smallManifestContentEntries {
+			result = append(result, cid)
+		}
+	}
+
+	return result
+}
+
+func (m *committedManifestManager) consolidate(ctx context.Context, minContents int) (ConsolidationStats, error) {
+	m.lock()
+	defer m.unlock()
+
+	if err := m.ensureInitializedLocked(ctx); err != nil {
+		return ConsolidationStats{}, err
+	}
+
+	stats, err := m.consolidateLocked(ctx, minContents)
+	if err != nil {
+		return stats, err
+	}
+
+	if stats.MergedContents > 0 {
+		if err := m.b.Flush(ctx); err != nil {
+			return stats, errors.Wrap(err, "unable to flush contents after consolidation")
+		}
+	}
+
+	return stats, nil
+}
+
+// consolidateLocked rewrites entries of small manifest contents into new segments and deletes the small contents
+// if there are at least minContents of them.
+// +checklocks:m.cmmu
+func (m *committedManifestManager) consolidateLocked(ctx context.Context, minContents int) (ConsolidationStats, error) {
+	m.verifyLocked()
+
+	stats := ConsolidationStats{
+		ContentsBefore: len(m.committedContentIDs),
+		ContentsAfter:  len(m.committedContentIDs),
+	}
+
+	small := m.smallContentsLocked()
+	if len(small) <= 1 || len(small) < minContents {
+		return stats, nil
+	}
+
+	isSmall := map[content.ID]bool{}
+	for _, cid := range small {
+		isSmall[cid] = true
+	}
+
+	// IDs of entries present in contents which are kept, tombstones for them must be preserved.
+	inLargeContents := map[ID]bool{}
+
+	for cid := range m.committedContentIDs {
+		if isSmall[cid] {
+			continue
+		}
+
+		for _, e := range m.contentEntries[cid] {
+			inLargeContents[e.ID] = true
+		}
+	}
+
+	// latest version of each entry found in small contents, including deletion markers.
+	merged := map[ID]*manifestEntry{}
+
+	for _, cid := range small {
+		for _, e := range m.contentEntries[cid] {
+			if prev := merged[e.ID]; prev == nil || e.ModTime.After(prev.ModTime) {
+				merged[e.ID] = e
+			}
+		}
+	}
+
+	for id, e := range merged {
+		if e.Deleted && !inLargeContents[id] {
+			// deletion marker does not hide anything anymore.
+			delete(merged, id)
+
+			stats.DroppedEntries++
+		}
+	}
+
+	log(ctx).Debugf("consolidating %v small manifest contents with %v entries", len(small), len(merged))
+
+	// deletes and rewrite should show up in one index blob or not show up at all.
+	m.b.DisableIndexFlush(ctx)
+	defer m.b.EnableIndexFlush(ctx)
+
+	written, err := m.writeEntriesLocked(ctx, merged)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, cid := range small {
+		if _, ok := written[cid]; ok {
+			// do not delete content that was just written.
+			continue
+		}
+
+		if err := m.b.DeleteContent(ctx, cid); err != nil {
+			return stats, errors.Wrapf(err, "unable to delete content %q", cid)
+		}
+
+		delete(m.committedContentIDs, cid)
+		delete(m.contentEntries, cid)
+	}
+
+	stats.MergedContents = len(small)
+	stats.RewrittenEntries = len(merged)
+	stats.ContentsAfter = len(m.committedContentIDs)
+
+	return stats, nil
+}
@@ -0,0 +1,182 @@
+package manifest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestManifestConsolidation(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	// disable auto-compaction.
+	mgr := newManagerForTesting(ctx, t, data, ManagerOptions{AutoCompactionThreshold: 1000})
+
+	frequent := map[string]string{"type": "snapshot", "path": "/frequent"}
+	other := map[string]string{"type": "snapshot", "path": "/other"}
+
+	// a source with many snapshots, written in a single flush.
+	for range maxEntriesPerManifestContent + 100 {
+		_, err := mgr.Put(ctx, frequent, map[string]int{"foo": 1})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, mgr.Flush(ctx))
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	// large number of entries is split into bounded segments.
+	require.Equal(t, 2, getManifestContentCount(ctx, t, mgr))
+
+	// snapshots written in separate sessions.
+	var deleted ID
+
+	for i := range 10 {
+		id := addAndVerify(ctx, t, mgr, other, map[string]int{"i": i})
+		if i == 0 {
+			deleted = id
+		}
+
+		require.NoError(t, mgr.Flush(ctx))
+		require.NoError(t, mgr.b.Flush(ctx))
+	}
+
+	require.NoError(t, mgr.Delete(ctx, deleted))
+	require.NoError(t, mgr.Flush(ctx))
+	require.NoError(t, mgr.b.Flush(ctx))
+
+	require.Equal(t, 13, getManifestContentCount(ctx, t, mgr))
+
+	// not enough small contents to consolidate.
+	stats, err := mgr.Consolidate(ctx, 13)
+	require.NoError(t, err)
+	require.Zero(t, stats.MergedContents)
+	require.Equal(t, 13, getManifestContentCount(ctx, t, mgr))
+
+	stats, err = mgr.Consolidate(ctx, 0)
+	require.NoError(t, err)
+
+	// the large segment is kept, the remaining ones are merged into one.
+	require.Equal(t, 13, stats.ContentsBefore)
+	require.Equal(t, 12, stats.MergedContents)
+	require.Equal(t, 2, stats.ContentsAfter)
+	require.Equal(t, 2, getManifestContentCount(ctx, t, mgr))
+
+	verifyFindCount := func(mgr *Manager, labels map[string]string, want int) {
+		t.Helper()
+
+		found, ferr := mgr.Find(ctx, labels)
+		require.NoError(t, ferr)
+		require.Len(t, found, want)
+	}
+
+	// nothing left to consolidate.
+	stats, err = mgr.Consolidate(ctx, 0)
+	require.NoError(t, err)
+	require.Zero(t, stats.MergedContents)
+
+	// verify using fresh manager.
+	mgr2 := newManagerForTesting(ctx, t, data, ManagerOptions{})
+
+	verifyFindCount(mgr2, frequent, maxEntriesPerManifestContent+100)
+	verifyFindCount(mgr2, other, 9)
+	verifyFindCount(mgr2, map[string]string{"type": "snapshot"}, maxEntriesPerManifestContent+109)
+	verifyFindCount(mgr2, map[string]string{"type": "snapshot", "path": "/no-such-path"}, 0)
+	verifyFindCount(mgr2, nil, maxEntriesPerManifestContent+109)
+
+	_, err = mgr2.GetMetadata(ctx, deleted)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManifestConsolidationKeepsNewerEntries(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+
+	mgr := newManagerForTesting(ctx, t, data, ManagerOptions{AutoCompactionThreshold: 1000})
+	cm := mgr.committed
+
+	t0 := clock.Now()
+	labels := map[string]string{"type": "snapshot"}
+
+	// large content with the latest versions of "updated" and "deleted".
+	large := map[ID]*manifestEntry{
+		"updated": {ID: "updated", Labels: labels, ModTime: t0.Add(time.Hour), Content: []byte(`{"v":2}`)},
+		"deleted": {ID: "deleted", Labels: labels, ModTime: t0.Add(time.Hour), Deleted: true},
+	}
+
+	for i := range smallManifestContentEntries {
+		id := ID(fmt.Sprintf("filler%v", i))
+		large[id] = &manifestEntry{ID: id, Labels: labels, ModTime: t0, Content: []byte(`{}`)}
+	}
+
+	_, err := cm.commitEntries(ctx, large)
+	require.NoError(t, err)
+
+	// small contents with older versions of the same entries.
+	for _, e := range []*manifestEntry{
+		{ID: "updated", Labels: labels, ModTime: t0, Content: []byte(`{"v":1}`)},
+		{ID: "deleted", Labels: labels, ModTime: t0, Content: []byte(`{"v":1}`)},
+		{ID: "other", Labels: labels, ModTime: t0, Content: []byte(`{}`)},
+	} {
+		_, err = cm.commitEntries(ctx, map[ID]*manifestEntry{e.ID: e})
+		require.NoError(t, err)
+	}
+
+	verify := func() {
+		t.Helper()
+
+		cm.lock()
+		defer cm.unlock()
+
+		require.JSONEq(t, `{"v":2}`, string(cm.committedEntries["updated"].Content))
+		require.Nil(t, cm.committedEntries["deleted"])
+		require.NotNil(t, cm.committedEntries["other"])
+	}
+
+	verify()
+
+	stats, err := cm.consolidate(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.MergedContents)
+
+	verify()
+
+	// in-memory state matches the state loaded from the repository.
+	mgr2 := newManagerForTesting(ctx, t, data, ManagerOptions{})
+
+	var v map[string]int
+
+	_, err = mgr2.Get(ctx, "updated", &v)
+	require.NoError(t, err)
+	require.Equal(t, 2, v["v"])
+
+	_, err = mgr2.GetMetadata(ctx, "deleted")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSplitIntoSegments(t *testing.T) {
+	entries := map[ID]*manifestEntry{}
+
+	for i := range maxEntriesPerManifestContent * 2 {
+		id := ID(fmt.Sprintf("id%06v", i))
+		entries[id] = &manifestEntry{ID: id, Labels: map[string]string{"path": fmt.Sprintf("/p%v", i%2)}}
+	}
+
+	segments := splitIntoSegments(entries)
+	require.Len(t, segments, 2)
+
+	// entries with the same labels are kept together.
+	for _, seg := range segments {
+		require.Len(t, seg, maxEntriesPerManifestContent)
+
+		for _, e := range seg {
+			require.Equal(t, seg[0].Labels["path"], e.Labels["path"])
+		}
+	}
+}
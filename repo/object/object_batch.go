@@ -0,0 +1,89 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ReadObjects reads the entire contents of the provided objects, returned in the same order.
+// When the content reader supports batch retrieval, objects stored in a single content are
+// retrieved together, which reduces the number of storage requests for large numbers of small objects.
+func ReadObjects(ctx context.Context, cr contentReader, oids []ID) ([][]byte, error) {
+	results := make([][]byte, len(oids))
+
+	br, _ := cr.(content.BatchReader)
+
+	var (
+		batchIndexes    []int
+		batchContentIDs []content.ID
+	)
+
+	for i, oid := range oids {
+		if cid, _, ok := oid.ContentID(); ok && br != nil {
+			batchIndexes = append(batchIndexes, i)
+			batchContentIDs = append(batchContentIDs, cid)
+
+			continue
+		}
+
+		// indirect and packed objects, or no batch support.
+		v, err := readObject(ctx, cr, oid)
+		if err != nil {
+			return nil, err
+		}
+
+		results[i] = v
+	}
+
+	if len(batchContentIDs) == 0 {
+		return results, nil
+	}
+
+	payloads, err := br.GetContents(ctx, batchContentIDs)
+	if errors.Is(err, content.ErrContentNotFound) {
+		return nil, errors.Wrapf(ErrObjectNotFound, "%v", err)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unexpected content error")
+	}
+
+	for j, i := range batchIndexes {
+		payload := payloads[j]
+
+		if _, compressed, _ := oids[i].ContentID(); compressed {
+			var b bytes.Buffer
+
+			if err := compression.DecompressByHeader(&b, bytes.NewReader(payload)); err != nil {
+				return nil, errors.Wrapf(err, "decompression error in %v", oids[i])
+			}
+
+			payload = b.Bytes()
+		}
+
+		results[i] = payload
+	}
+
+	return results, nil
+}
+
+func readObject(ctx context.Context, cr contentReader, oid ID) ([]byte, error) {
+	r, err := Open(ctx, cr, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	v, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read object %v", oid)
+	}
+
+	return v, nil
+}
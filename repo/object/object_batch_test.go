@@ -0,0 +1,81 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/content"
+)
+
+type fakeBatchContentManager struct {
+	*fakeContentManager
+
+	batches int
+}
+
+func (f *fakeBatchContentManager) GetContents(ctx context.Context, contentIDs []content.ID) ([][]byte, error) {
+	f.batches++
+
+	var result [][]byte
+
+	for _, cid := range contentIDs {
+		v, err := f.GetContent(ctx, cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "content %v", cid)
+		}
+
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+func TestReadObjects(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, fcm, om := setupTest(t, nil)
+
+	small := []byte("small object")
+	compressible := bytes.Repeat([]byte("compressible"), 1000)
+	large := makeMaybeCompressibleData(3<<20, false)
+
+	oids := []ID{
+		mustWriteObject(t, om, small, ""),
+		mustWriteObject(t, om, compressible, "gzip"),
+		mustWriteObject(t, om, large, ""),
+	}
+
+	_, compressed, ok := oids[1].ContentID()
+	require.True(t, ok)
+	require.True(t, compressed)
+
+	_, _, ok = oids[2].ContentID()
+	require.False(t, ok, "large object must be indirect")
+
+	want := [][]byte{small, compressible, large}
+
+	// without batch support
+	got, err := ReadObjects(ctx, fcm, oids)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	bcm := &fakeBatchContentManager{fakeContentManager: fcm}
+
+	got, err = ReadObjects(ctx, bcm, oids)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+	require.Equal(t, 1, bcm.batches)
+
+	missing, err := ParseID("deadbeef")
+	require.NoError(t, err)
+
+	_, err = ReadObjects(ctx, bcm, []ID{oids[0], missing})
+	require.ErrorIs(t, err, ErrObjectNotFound)
+
+	_, err = ReadObjects(ctx, fcm, []ID{oids[0], missing})
+	require.ErrorIs(t, err, ErrObjectNotFound)
+}
@@ -27,6 +27,15 @@ type Reader interface {
 	Length() int64
 }
 
+// ChunkedReader is implemented by readers of objects composed of multiple chunks, which allows callers
+// to prefetch or reuse chunks backing ranges of the object without reading them.
+type ChunkedReader interface {
+	Reader
+
+	// ChunksInRange returns entries describing chunks overlapping the provided range of the object.
+	ChunksInRange(offset, length int64) []IndirectObjectEntry
+}
+
 type contentReader interface {
 	ContentInfo(ctx context.Context, contentID content.ID) (content.Info, error)
 	GetContent(ctx context.Context, contentID content.ID) ([]byte, error)
@@ -213,6 +222,15 @@ func PrefetchBackingContents(ctx context.Context, contentMgr contentManager, obj
 	return contentMgr.PrefetchContents(ctx, tracker.contentIDs(), hint), nil
 }
 
+// SplitterParameters returns chunk sizes of the default splitter specified by the object format.
+func SplitterParameters(f format.ObjectFormat) splitter.Parameters {
+	return splitter.Parameters{
+		MinSize: f.SplitterMinSize,
+		AvgSize: f.SplitterAvgSize,
+		MaxSize: f.SplitterMaxSize,
+	}
+}
+
 // NewObjectManager creates an ObjectManager with the specified content manager and format.
 func NewObjectManager(ctx context.Context, bm contentManager, f format.ObjectFormat, mr *metrics.Registry) (*Manager, error) {
 	_ = mr
@@ -233,9 +251,9 @@ func NewObjectManager(ctx context.Context, bm contentManager, f format.ObjectFor
 		splitterID = "FIXED"
 	}
 
-	os := splitter.GetFactory(splitterID)
-	if os == nil {
-		return nil, errors.Errorf("unsupported splitter %q", f.Splitter)
+	os, err := splitter.GetFactoryWithParameters(splitterID, SplitterParameters(f))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid splitter")
 	}
 
 	om.newDefaultSplitter = os
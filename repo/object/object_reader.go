@@ -5,6 +5,7 @@
 	"context"
 	"encoding/json"
 	"io"
+	"sort"
 
 	"github.com/pkg/errors"
 
@@ -192,6 +193,24 @@ func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
 	return r.currentPosition, nil
 }
 
+func (r *objectReader) ChunksInRange(offset, length int64) []IndirectObjectEntry {
+	var result []IndirectObjectEntry
+
+	first := sort.Search(len(r.seekTable), func(i int) bool {
+		return r.seekTable[i].endOffset() > offset
+	})
+
+	for _, st := range r.seekTable[first:] {
+		if st.Start >= offset+length {
+			break
+		}
+
+		result = append(result, st)
+	}
+
+	return result
+}
+
 func (r *objectReader) Close() error {
 	return nil
 }
@@ -200,7 +219,21 @@ func (r *objectReader) Length() int64 {
 	return r.totalLength
 }
 
+var _ ChunkedReader = (*objectReader)(nil)
+
 func openAndAssertLength(ctx context.Context, cr contentReader, objectID ID, assertLength int64) (Reader, error) {
+	if data, ok := objectID.InlineData(); ok {
+		if assertLength != -1 && int64(len(data)) != assertLength {
+			return nil, errors.Errorf("unexpected inline object length %v, expected %v", len(data), assertLength)
+		}
+
+		return newObjectReaderWithData(data), nil
+	}
+
+	if packObjectID, offset, length, ok := objectID.PackedObject(); ok {
+		return openPackedObject(ctx, cr, packObjectID, offset, length, assertLength)
+	}
+
 	if indexObjectID, ok := objectID.IndexObjectID(); ok {
 		// recursively calls openAndAssertLength
 		seekTable, err := LoadIndexObject(ctx, cr, indexObjectID)
@@ -242,6 +275,15 @@ func iterateIndirectObjectContents(ctx context.Context, cr contentReader, indexO
 }
 
 func iterateBackingContents(ctx context.Context, r contentReader, oid ID, tracker *contentIDTracker, callbackFunc func(contentID content.ID) error) error {
+	if _, ok := oid.InlineData(); ok {
+		// inline objects are not backed by any contents.
+		return nil
+	}
+
+	if packObjectID, _, _, ok := oid.PackedObject(); ok {
+		return iterateBackingContents(ctx, r, packObjectID, tracker, callbackFunc)
+	}
+
 	if indexObjectID, ok := oid.IndexObjectID(); ok {
 		return iterateIndirectObjectContents(ctx, r, indexObjectID, tracker, callbackFunc)
 	}
@@ -313,6 +355,35 @@ func newRawReader(ctx context.Context, cr contentReader, objectID ID, assertLeng
 	return newObjectReaderWithData(payload), nil
 }
 
+// openPackedObject returns a reader for the provided range of bytes of a packed object.
+func openPackedObject(ctx context.Context, cr contentReader, packObjectID ID, offset, length, assertLength int64) (Reader, error) {
+	if assertLength != -1 && length != assertLength {
+		return nil, errors.Errorf("unexpected packed object length %v, expected %v", length, assertLength)
+	}
+
+	r, err := openAndAssertLength(ctx, cr, packObjectID, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	if offset+length > r.Length() {
+		return nil, errors.Errorf("packed object range %v+%v is outside of %v (length %v)", offset, length, packObjectID, r.Length())
+	}
+
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, "unable to seek in packed object")
+	}
+
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.Wrap(err, "unable to read packed object")
+	}
+
+	return newObjectReaderWithData(payload), nil
+}
+
 type readerWithData struct {
 	io.ReadSeeker
 	length int64
@@ -1,7 +1,9 @@
 package object
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -15,12 +17,23 @@
 //  1. In a single content block, this is the most common case for small objects.
 //  2. In a series of content blocks with an indirect block pointing at them (multiple indirections are allowed).
 //     This is used for larger files. Object IDs using indirect blocks start with "I"
+//  3. As a range of bytes of a packed object shared by many small objects. Object IDs of packed objects
+//     start with "P" followed by the offset and length of the range and the ID of the packed object.
+//  4. Inline, with the data embedded in the ID itself, which is used for very small files. Object IDs of
+//     inline objects start with "E" followed by the base64-encoded data.
 //
 //nolint:recvcheck
 type ID struct {
 	cid         content.ID
 	indirection byte
 	compression bool
+
+	packed     bool
+	packOffset int64
+	packLength int64
+
+	// inline holds data of inline objects, a string keeps IDs comparable.
+	inline string
 }
 
 // MarshalJSON implements JSON serialization of IDs.
@@ -61,6 +74,10 @@ type HasObjectID interface {
 
 // String returns string representation of ObjectID that is suitable for displaying in the UI.
 func (i ID) String() string {
+	if i.packed || i.inline != "" {
+		return string(i.Append(nil))
+	}
+
 	var (
 		indirectPrefix    string
 		compressionPrefix string
@@ -84,6 +101,21 @@ func (i ID) String() string {
 
 // Append appends string representation of ObjectID that is suitable for displaying in the UI.
 func (i ID) Append(out []byte) []byte {
+	if i.inline != "" {
+		out = append(out, 'E')
+		return base64.RawURLEncoding.AppendEncode(out, []byte(i.inline))
+	}
+
+	if i.packed {
+		out = append(out, 'P')
+		out = strconv.AppendInt(out, i.packOffset, 10) //nolint:mnd
+		out = append(out, packedIDSeparator)
+		out = strconv.AppendInt(out, i.packLength, 10) //nolint:mnd
+		out = append(out, packedIDSeparator)
+
+		i.packed = false
+	}
+
 	for range i.indirection {
 		out = append(out, 'I')
 	}
@@ -97,6 +129,10 @@ func (i ID) Append(out []byte) []byte {
 
 // IndexObjectID returns the object ID of the underlying index object.
 func (i ID) IndexObjectID() (ID, bool) {
+	if i.packed || i.inline != "" {
+		return i, false
+	}
+
 	if i.indirection > 0 {
 		i2 := i
 		i2.indirection--
@@ -109,13 +145,31 @@ func (i ID) IndexObjectID() (ID, bool) {
 
 // ContentID returns the ID of the underlying content.
 func (i ID) ContentID() (id content.ID, compressed, ok bool) {
-	if i.indirection > 0 {
+	if i.packed || i.inline != "" || i.indirection > 0 {
 		return content.EmptyID, false, false
 	}
 
 	return i.cid, i.compression, true
 }
 
+// PackedObject returns the ID of the packed object and the range of bytes within it where the object is stored.
+func (i ID) PackedObject() (packObjectID ID, offset, length int64, ok bool) {
+	if !i.packed {
+		return i, 0, 0, false
+	}
+
+	return ID{cid: i.cid, indirection: i.indirection, compression: i.compression}, i.packOffset, i.packLength, true
+}
+
+// InlineData returns the data embedded in an inline object ID.
+func (i ID) InlineData() ([]byte, bool) {
+	if i.inline == "" {
+		return nil, false
+	}
+
+	return []byte(i.inline), true
+}
+
 // IDsFromStrings converts strings to IDs.
 func IDsFromStrings(str []string) ([]ID, error) {
 	var result []ID
@@ -160,8 +214,33 @@ func IndirectObjectID(indexObjectID ID) ID {
 	return indexObjectID
 }
 
+// PackedObjectID returns ID of an object stored as a range of bytes of the provided packed object.
+func PackedObjectID(packObjectID ID, offset, length int64) ID {
+	packObjectID.packed = true
+	packObjectID.packOffset = offset
+	packObjectID.packLength = length
+
+	return packObjectID
+}
+
+// InlineObjectID returns ID of an object whose data is embedded in the ID. The data must not be empty.
+func InlineObjectID(data []byte) ID {
+	return ID{inline: string(data)}
+}
+
+// packedIDSeparator separates the offset, length and packed object ID in string representation of packed object IDs.
+const packedIDSeparator = '-'
+
 // ParseID converts the specified string into object ID.
 func ParseID(s string) (ID, error) {
+	if s != "" && s[0] == 'P' {
+		return parsePackedID(s[1:])
+	}
+
+	if s != "" && s[0] == 'E' {
+		return parseInlineID(s[1:])
+	}
+
 	var id ID
 
 	for s != "" && s[0] == 'I' {
@@ -194,3 +273,43 @@ func ParseID(s string) (ID, error) {
 
 	return id, nil
 }
+
+// packedIDParts is the number of parts of a packed object ID: offset, length and packed object ID.
+const packedIDParts = 3
+
+func parsePackedID(s string) (ID, error) {
+	parts := strings.SplitN(s, string(packedIDSeparator), packedIDParts)
+	if len(parts) != packedIDParts {
+		return EmptyID, errors.Errorf("malformed packed object ID: %q", s)
+	}
+
+	offset, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || offset < 0 {
+		return EmptyID, errors.Errorf("malformed packed object offset: %q", parts[0])
+	}
+
+	length, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || length < 0 {
+		return EmptyID, errors.Errorf("malformed packed object length: %q", parts[1])
+	}
+
+	if parts[2] == "" || parts[2][0] == 'P' || parts[2][0] == 'E' {
+		return EmptyID, errors.Errorf("invalid packed object: %q", parts[2])
+	}
+
+	packObjectID, err := ParseID(parts[2])
+	if err != nil {
+		return EmptyID, err
+	}
+
+	return PackedObjectID(packObjectID, offset, length), nil
+}
+
+func parseInlineID(s string) (ID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return EmptyID, errors.Errorf("malformed inline object ID: %q", s)
+	}
+
+	return InlineObjectID(data), nil
+}
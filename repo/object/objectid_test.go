@@ -40,6 +40,21 @@ func TestParseObjectID(t *testing.T) {
 		{"I-1,X", false},
 		{"Xsomething", false},
 		{"IZabcd", false},
+		{"P0-5-f0f0", true},
+		{"P10-5-IDf0f0", true},
+		{"P0-0-Zf0f0", true},
+		{"P", false},
+		{"P0-5", false},
+		{"P0-5-", false},
+		{"P-1-5-f0f0", false},
+		{"Px-5-f0f0", false},
+		{"P0-x-f0f0", false},
+		{"P0-5-P0-5-f0f0", false},
+		{"P0-5-Xf0f0", false},
+		{"EaGVsbG8", true},
+		{"E", false},
+		{"E!!", false},
+		{"P0-5-EaGVsbG8", false},
 	}
 
 	for _, tc := range cases {
@@ -74,6 +89,9 @@ func TestString(t *testing.T) {
 		mustParseID(t, "abcd"):   "abcd",
 		mustParseID(t, "IIabcd"): "IIabcd",
 		mustParseID(t, "Zabcd"):  "Zabcd",
+
+		mustParseID(t, "P10-20-Dabcd"): "P10-20-abcd",
+		mustParseID(t, "P10-20-Zabcd"): "P10-20-Zabcd",
 	}
 
 	for id, str := range cases {
@@ -0,0 +1,117 @@
+package object
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxPackedObjectSize is the default maximum size of a packed object.
+const DefaultMaxPackedObjectSize = 4 << 20
+
+// Packer combines data of many small objects into shared packed objects, which reduces the number of
+// contents and index entries needed to store them. Each small object is identified by the range of bytes
+// of the packed object, so IDs of small objects are only known after the packed object has been written.
+//
+// Packer is safe for concurrent use.
+type Packer struct {
+	newWriter   func(ctx context.Context, opt WriterOptions) Writer
+	opt         WriterOptions
+	maxPackSize int
+
+	mu sync.Mutex
+	// +checklocks:mu
+	data []byte
+	// +checklocks:mu
+	offsets map[[sha256.Size]byte]int64 // offsets of objects in the current pack indexed by hash of their data
+	// +checklocks:mu
+	pending []pendingPackedObject
+}
+
+type pendingPackedObject struct {
+	offset int64
+	length int64
+	done   func(oid ID)
+}
+
+// NewPacker returns a new Packer that writes packed objects using the provided writer factory and options.
+func NewPacker(newWriter func(ctx context.Context, opt WriterOptions) Writer, opt WriterOptions, maxPackSize int) *Packer {
+	if maxPackSize <= 0 {
+		maxPackSize = DefaultMaxPackedObjectSize
+	}
+
+	return &Packer{
+		newWriter:   newWriter,
+		opt:         opt,
+		maxPackSize: maxPackSize,
+		offsets:     map[[sha256.Size]byte]int64{},
+	}
+}
+
+// Add adds the provided object data to the current packed object. The provided callback is invoked
+// with the ID of the object when the packed object has been written by Flush, which happens
+// automatically when the packed object reaches its maximum size.
+func (p *Packer) Add(ctx context.Context, data []byte, done func(oid ID)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.data) > 0 && len(p.data)+len(data) > p.maxPackSize {
+		if err := p.flushLocked(ctx); err != nil {
+			return err
+		}
+	}
+
+	h := sha256.Sum256(data)
+
+	offset, ok := p.offsets[h]
+	if !ok {
+		// identical objects within a pack are stored once.
+		offset = int64(len(p.data))
+		p.offsets[h] = offset
+		p.data = append(p.data, data...)
+	}
+
+	p.pending = append(p.pending, pendingPackedObject{offset, int64(len(data)), done})
+
+	return nil
+}
+
+// Flush writes the current packed object and invokes callbacks of all objects added to it.
+// When Flush returns, callbacks of all previously added objects have completed.
+func (p *Packer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.flushLocked(ctx)
+}
+
+// +checklocks:p.mu
+func (p *Packer) flushLocked(ctx context.Context) error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+
+	w := p.newWriter(ctx, p.opt)
+	defer w.Close() //nolint:errcheck
+
+	if _, err := w.Write(p.data); err != nil {
+		return errors.Wrap(err, "error writing packed object")
+	}
+
+	packObjectID, err := w.Result()
+	if err != nil {
+		return errors.Wrap(err, "error writing packed object")
+	}
+
+	for _, po := range p.pending {
+		po.done(PackedObjectID(packObjectID, po.offset, po.length))
+	}
+
+	p.data = p.data[:0]
+	p.pending = nil
+	clear(p.offsets)
+
+	return nil
+}
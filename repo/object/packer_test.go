@@ -0,0 +1,117 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+)
+
+func TestPacker(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data, fcm, om := setupTest(t, nil)
+
+	p := NewPacker(om.NewWriter, WriterOptions{}, 1000)
+
+	var (
+		mu      sync.Mutex
+		results = map[int]ID{}
+		objects [][]byte
+	)
+
+	for i := range 30 {
+		// every third object is a duplicate of the previous one.
+		b := append([]byte(fmt.Sprintf("object-%v-", i)), bytes.Repeat([]byte{byte(i)}, 100)...)
+		if i%3 == 2 {
+			b = objects[i-1]
+		}
+
+		objects = append(objects, b)
+
+		require.NoError(t, p.Add(ctx, b, func(oid ID) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			results[i] = oid
+		}))
+	}
+
+	// some objects were written when the pack became full.
+	require.NotEmpty(t, results)
+	require.Less(t, len(results), len(objects))
+
+	require.NoError(t, p.Flush(ctx))
+	require.Len(t, results, len(objects))
+
+	// flushing again is a no-op.
+	require.NoError(t, p.Flush(ctx))
+
+	packObjects := map[ID]bool{}
+
+	for i, oid := range results {
+		packObjectID, _, length, ok := oid.PackedObject()
+		require.True(t, ok)
+		require.EqualValues(t, len(objects[i]), length)
+
+		packObjects[packObjectID] = true
+
+		verify(ctx, t, fcm, oid, objects[i], fmt.Sprintf("packed-%v", i))
+		verifyFull(ctx, t, om, oid, objects[i])
+
+		cids, err := VerifyObject(ctx, fcm, oid)
+		require.NoError(t, err)
+		require.Len(t, cids, 1)
+
+		parsed, err := ParseID(oid.String())
+		require.NoError(t, err)
+		require.Equal(t, oid, parsed)
+	}
+
+	require.Len(t, data, len(packObjects))
+	require.Greater(t, len(packObjects), 1)
+
+	// identical objects within a pack share the range.
+	require.Equal(t, results[1], results[2])
+}
+
+func TestInlineObject(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, fcm, om := setupTest(t, nil)
+
+	oid := InlineObjectID([]byte("hello world"))
+	require.Equal(t, "EaGVsbG8gd29ybGQ", oid.String())
+
+	parsed, err := ParseID(oid.String())
+	require.NoError(t, err)
+	require.Equal(t, oid, parsed)
+
+	verifyFull(ctx, t, om, oid, []byte("hello world"))
+
+	_, _, ok := oid.ContentID()
+	require.False(t, ok)
+
+	cids, err := VerifyObject(ctx, fcm, oid)
+	require.NoError(t, err)
+	require.Empty(t, cids)
+}
+
+func TestPackedObjectOutOfRange(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, fcm, om := setupTest(t, nil)
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	_, err := w.Write([]byte("hello world"))
+	require.NoError(t, err)
+
+	packObjectID, err := w.Result()
+	require.NoError(t, err)
+
+	verifyFull(ctx, t, om, PackedObjectID(packObjectID, 6, 5), []byte("world"))
+
+	_, err = Open(ctx, fcm, PackedObjectID(packObjectID, 6, 10))
+	require.ErrorContains(t, err, "outside of")
+}
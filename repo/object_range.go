@@ -0,0 +1,237 @@
+package repo
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/object"
+)
+
+// ObjectRange describes a contiguous range of bytes of an object.
+type ObjectRange struct {
+	ObjectID object.ID
+	Offset   int64
+
+	// Length of the range, negative value means the range extends to the end of the object.
+	Length int64
+}
+
+// WholeObject returns the range covering the entire object.
+func WholeObject(oid object.ID) ObjectRange {
+	return ObjectRange{ObjectID: oid, Length: -1}
+}
+
+// ReadRangeOptions describes options for reading ranges of objects.
+type ReadRangeOptions struct {
+	// Readahead is the number of bytes past the end of the range, whose backing contents
+	// are fetched into the cache in the background in anticipation of subsequent reads.
+	Readahead int64
+
+	// PrefetchHint is passed to PrefetchObjects() when fetching backing contents.
+	PrefetchHint string
+}
+
+// rangeReader reads a range of an object.
+type rangeReader struct {
+	io.Reader
+
+	or object.Reader
+	wg sync.WaitGroup
+}
+
+func (r *rangeReader) Close() error {
+	r.wg.Wait()
+
+	//nolint:wrapcheck
+	return r.or.Close()
+}
+
+// resolveRange returns the offset and length of the range within the object of the provided length.
+func resolveRange(rng ObjectRange, objectLength int64) (offset, length int64, err error) {
+	length = rng.Length
+	if length < 0 {
+		length = objectLength - rng.Offset
+	}
+
+	if rng.Offset < 0 || length < 0 || rng.Offset+length > objectLength {
+		return 0, 0, errors.Errorf("range [%v,+%v) is out of bounds of object %v of length %v", rng.Offset, rng.Length, rng.ObjectID, objectLength)
+	}
+
+	return rng.Offset, length, nil
+}
+
+// OpenObjectRange opens a reader of the provided range of an object.
+//
+// Backing contents of multi-chunk objects are fetched in parallel before reading the range, and backing contents
+// of the following bytes up to the requested readahead are fetched in the background until the reader is closed.
+func OpenObjectRange(ctx context.Context, rep Repository, rng ObjectRange, opt ReadRangeOptions) (io.ReadCloser, error) {
+	or, err := rep.OpenObject(ctx, rng.ObjectID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open object %v", rng.ObjectID)
+	}
+
+	offset, length, err := resolveRange(rng, or.Length())
+	if err != nil {
+		or.Close() //nolint:errcheck
+		return nil, err
+	}
+
+	result := &rangeReader{or: or}
+
+	if cr, ok := or.(object.ChunkedReader); ok {
+		if _, err := rep.PrefetchObjects(ctx, chunkObjectIDs(cr.ChunksInRange(offset, length)), opt.PrefetchHint); err != nil {
+			or.Close() //nolint:errcheck
+			return nil, errors.Wrap(err, "unable to prefetch object range")
+		}
+
+		if readahead := min(opt.Readahead, or.Length()-offset-length); readahead > 0 {
+			readaheadChunks := chunkObjectIDs(cr.ChunksInRange(offset+length, readahead))
+
+			result.wg.Add(1)
+
+			go func() {
+				defer result.wg.Done()
+
+				if _, err := rep.PrefetchObjects(ctx, readaheadChunks, opt.PrefetchHint); err != nil {
+					log(ctx).Debugf("readahead of %v failed: %v", rng.ObjectID, err)
+				}
+			}()
+		}
+	}
+
+	if _, err := or.Seek(offset, io.SeekStart); err != nil {
+		result.Close() //nolint:errcheck
+		return nil, errors.Wrapf(err, "unable to seek to %v", offset)
+	}
+
+	result.Reader = io.LimitReader(or, length)
+
+	return result, nil
+}
+
+func chunkObjectIDs(chunks []object.IndirectObjectEntry) []object.ID {
+	var result []object.ID
+
+	for _, c := range chunks {
+		result = append(result, c.Object)
+	}
+
+	return result
+}
+
+// ReadObjectRange reads the provided range of an object into memory.
+func ReadObjectRange(ctx context.Context, rep Repository, rng ObjectRange, opt ReadRangeOptions) ([]byte, error) {
+	r, err := OpenObjectRange(ctx, rep, rng, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	b, err := io.ReadAll(r)
+
+	return b, errors.Wrapf(err, "error reading object %v", rng.ObjectID)
+}
+
+// ConcatenateObjectRanges creates an object by concatenating ranges of existing objects.
+//
+// Chunks of existing objects which are entirely contained in the ranges are reused without reading or
+// rewriting their data, only the remaining parts of ranges that cover partial chunks are copied.
+func ConcatenateObjectRanges(ctx context.Context, w RepositoryWriter, ranges []ObjectRange, opt ConcatenateOptions) (object.ID, error) {
+	var parts []object.ID
+
+	for _, rng := range ranges {
+		p, err := objectRangeParts(ctx, w, rng, opt)
+		if err != nil {
+			return object.EmptyID, err
+		}
+
+		parts = append(parts, p...)
+	}
+
+	if len(parts) == 0 {
+		return object.EmptyID, errors.New("empty list of object ranges")
+	}
+
+	//nolint:wrapcheck
+	return w.ConcatenateObjects(ctx, parts, opt)
+}
+
+// objectRangeParts returns IDs of objects, whose concatenation is equal to the provided range of an object.
+func objectRangeParts(ctx context.Context, w RepositoryWriter, rng ObjectRange, opt ConcatenateOptions) ([]object.ID, error) {
+	or, err := w.OpenObject(ctx, rng.ObjectID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open object %v", rng.ObjectID)
+	}
+
+	defer or.Close() //nolint:errcheck
+
+	offset, length, err := resolveRange(rng, or.Length())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case length == 0:
+		return nil, nil
+
+	case offset == 0 && length == or.Length():
+		return []object.ID{rng.ObjectID}, nil
+	}
+
+	cr, ok := or.(object.ChunkedReader)
+	if !ok {
+		oid, err := copyObjectRange(ctx, w, rng.ObjectID, offset, length, opt)
+
+		return []object.ID{oid}, err
+	}
+
+	var result []object.ID
+
+	end := offset + length
+
+	for _, c := range cr.ChunksInRange(offset, length) {
+		if c.Start >= offset && c.Start+c.Length <= end {
+			result = append(result, c.Object)
+			continue
+		}
+
+		// partial chunk at the beginning or end of the range.
+		start := max(offset, c.Start)
+
+		oid, err := copyObjectRange(ctx, w, rng.ObjectID, start, min(end, c.Start+c.Length)-start, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, oid)
+	}
+
+	return result, nil
+}
+
+func copyObjectRange(ctx context.Context, w RepositoryWriter, oid object.ID, offset, length int64, opt ConcatenateOptions) (object.ID, error) {
+	r, err := OpenObjectRange(ctx, w, ObjectRange{ObjectID: oid, Offset: offset, Length: length}, ReadRangeOptions{})
+	if err != nil {
+		return object.EmptyID, err
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	ow := w.NewObjectWriter(ctx, object.WriterOptions{
+		Description:        "RANGE:" + oid.String(),
+		MetadataCompressor: opt.Compressor,
+	})
+	defer ow.Close() //nolint:errcheck
+
+	if _, err := io.Copy(ow, r); err != nil {
+		return object.EmptyID, errors.Wrapf(err, "error copying range of %v", oid)
+	}
+
+	result, err := ow.Result()
+
+	return result, errors.Wrap(err, "error writing object range")
+}
@@ -0,0 +1,113 @@
+package repo_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/object"
+)
+
+const rangeTestChunkSize = 128 << 10
+
+func writeRangeTestObject(ctx context.Context, t *testing.T, w repo.RepositoryWriter, data []byte) object.ID {
+	t.Helper()
+
+	ow := w.NewObjectWriter(ctx, object.WriterOptions{Splitter: "FIXED-128K"})
+	defer ow.Close()
+
+	_, err := ow.Write(data)
+	require.NoError(t, err)
+
+	oid, err := ow.Result()
+	require.NoError(t, err)
+
+	return oid
+}
+
+func TestReadObjectRange(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	data := make([]byte, 5*rangeTestChunkSize+1000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	oid := writeRangeTestObject(ctx, t, env.RepositoryWriter, data)
+
+	cases := []struct {
+		offset, length int64
+	}{
+		{0, 10},
+		{rangeTestChunkSize - 5, 10},
+		{rangeTestChunkSize, 2 * rangeTestChunkSize},
+		{1000, -1},
+		{int64(len(data)), 0},
+	}
+
+	for _, tc := range cases {
+		b, err := repo.ReadObjectRange(ctx, env.RepositoryWriter, repo.ObjectRange{ObjectID: oid, Offset: tc.offset, Length: tc.length}, repo.ReadRangeOptions{
+			Readahead: rangeTestChunkSize,
+		})
+		require.NoError(t, err)
+
+		want := data[tc.offset:]
+		if tc.length >= 0 {
+			want = want[:tc.length]
+		}
+
+		require.True(t, bytes.Equal(want, b), "invalid data for %+v", tc)
+	}
+
+	b, err := repo.ReadObjectRange(ctx, env.RepositoryWriter, repo.WholeObject(oid), repo.ReadRangeOptions{})
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+
+	_, err = repo.ReadObjectRange(ctx, env.RepositoryWriter, repo.ObjectRange{ObjectID: oid, Offset: 10, Length: int64(len(data))}, repo.ReadRangeOptions{})
+	require.ErrorContains(t, err, "out of bounds")
+}
+
+func TestConcatenateObjectRanges(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3)
+
+	r := rand.New(rand.NewSource(1))
+
+	data1 := make([]byte, 4*rangeTestChunkSize)
+	r.Read(data1)
+
+	data2 := []byte("small object")
+
+	oid1 := writeRangeTestObject(ctx, t, env.RepositoryWriter, data1)
+	oid2 := writeRangeTestObject(ctx, t, env.RepositoryWriter, data2)
+
+	result, err := repo.ConcatenateObjectRanges(ctx, env.RepositoryWriter, []repo.ObjectRange{
+		{ObjectID: oid1, Offset: 100, Length: 2*rangeTestChunkSize + 200},
+		repo.WholeObject(oid2),
+		{ObjectID: oid2, Offset: 6, Length: 3},
+		{ObjectID: oid1, Offset: rangeTestChunkSize, Length: rangeTestChunkSize},
+	}, repo.ConcatenateOptions{})
+	require.NoError(t, err)
+
+	var want []byte
+
+	want = append(want, data1[100:2*rangeTestChunkSize+300]...)
+	want = append(want, data2...)
+	want = append(want, data2[6:9]...)
+	want = append(want, data1[rangeTestChunkSize:2*rangeTestChunkSize]...)
+
+	got, err := repo.ReadObjectRange(ctx, env.RepositoryWriter, repo.WholeObject(result), repo.ReadRangeOptions{})
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(want, got))
+
+	// whole objects are reused as is.
+	result, err = repo.ConcatenateObjectRanges(ctx, env.RepositoryWriter, []repo.ObjectRange{repo.WholeObject(oid1)}, repo.ConcatenateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, oid1, result)
+
+	_, err = repo.ConcatenateObjectRanges(ctx, env.RepositoryWriter, nil, repo.ConcatenateOptions{})
+	require.Error(t, err)
+}
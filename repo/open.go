@@ -9,6 +9,7 @@
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/cache"
@@ -16,10 +17,14 @@
 	"github.com/kopia/kopia/internal/crypto"
 	"github.com/kopia/kopia/internal/feature"
 	"github.com/kopia/kopia/internal/metrics"
+	"github.com/kopia/kopia/internal/passwordpersist"
 	"github.com/kopia/kopia/internal/repodiag"
 	"github.com/kopia/kopia/internal/retry"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/beforeop"
+	"github.com/kopia/kopia/repo/blob/failover"
+	"github.com/kopia/kopia/repo/blob/layout"
 	loggingwrapper "github.com/kopia/kopia/repo/blob/logging"
 	"github.com/kopia/kopia/repo/blob/readonly"
 	"github.com/kopia/kopia/repo/blob/storagemetrics"
@@ -46,6 +51,13 @@
 var supportedFeatures = []feature.Feature{
 	"index-v1",
 	"index-v2",
+	format.CompressionDictionariesFeature,
+	format.BlobLayoutFeature,
+	format.ECCShardsFeature,
+	format.PackedObjectIDsFeature,
+	format.InlineObjectIDsFeature,
+	format.EncryptionDomainsFeature,
+	format.KeySlotsFeature,
 }
 
 // throttlingWindow is the duration window during which the throttling token bucket fully replenishes.
@@ -72,8 +84,14 @@ type Options struct {
 	DoNotWaitForUpgrade bool                       // Disable the exponential forever backoff on an upgrade lock.
 	BeforeFlush         []RepositoryWriterCallback // list of callbacks to invoke before every flush
 
+	// FallbackToMemoryCache uses memory-bounded caches instead of failing when the cache directory is missing, read-only or full.
+	FallbackToMemoryCache bool
+
 	OnFatalError func(err error) // function to invoke when repository encounters a fatal error, usually invokes os.Exit
 
+	// PasswordPersist retrieves keys of encryption domains unlocked by the connection, when nil no domains are unlocked.
+	PasswordPersist passwordpersist.Strategy
+
 	// test-only flags
 	TestOnlyIgnoreMissingRequiredFeatures bool // ignore missing features
 }
@@ -124,6 +142,10 @@ func Open(ctx context.Context, configFile, password string, options *Options) (r
 		return nil, ErrCannotWriteToRepoConnectionWithPermissiveCacheLoading
 	}
 
+	if options.FallbackToMemoryCache {
+		lc.Caching = withMemoryCacheFallback(ctx, lc.Caching)
+	}
+
 	if lc.APIServer != nil {
 		return openAPIServer(ctx, lc.APIServer, lc.ClientOptions, lc.Caching, password, options)
 	}
@@ -134,7 +156,17 @@ func Open(ctx context.Context, configFile, password string, options *Options) (r
 func getContentCacheOrNil(ctx context.Context, si *APIServerInfo, opt *content.CachingOptions, password string, mr *metrics.Registry, timeNow func() time.Time) (*cache.PersistentCache, error) {
 	opt = opt.CloneOrDefault()
 
-	cs, err := cache.NewStorageOrNil(ctx, opt.CacheDirectory, opt.ContentCacheSizeBytes, "server-contents")
+	var (
+		cs  cache.Storage
+		err error
+	)
+
+	if opt.MemoryFallbackReason != "" && opt.ContentCacheSizeBytes > 0 {
+		cs = cache.NewMemoryStorage(content.MemoryCacheSizeBytes(opt.ContentCacheSizeBytes), timeNow)
+	} else {
+		cs, err = cache.NewStorageOrNil(ctx, opt.CacheDirectory, opt.ContentCacheSizeBytes, "server-contents")
+	}
+
 	if cs == nil {
 		// this may be (nil, nil) or (nil, err)
 		return nil, errors.Wrap(err, "error opening storage")
@@ -224,9 +256,22 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 		st = readonly.NewWrapper(st)
 	}
 
+	if len(lc.ReadMirrors) > 0 {
+		st, err = addReadMirrors(ctx, st, lc.ReadMirrors)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	cliOpts := lc.ApplyDefaults(ctx, "Repository in "+st.DisplayName())
 
-	r, err := openWithConfig(ctx, st, cliOpts, password, options, lc.Caching, configFile)
+	domainKeys, err := loadEncryptionDomainKeys(ctx, options.PasswordPersist, configFile)
+	if err != nil {
+		st.Close(ctx) //nolint:errcheck
+		return nil, err
+	}
+
+	r, err := openWithConfig(ctx, st, cliOpts, password, options, lc.Caching, domainKeys, configFile)
 	if err != nil {
 		st.Close(ctx) //nolint:errcheck
 		return nil, err
@@ -235,15 +280,39 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 	return r, nil
 }
 
+// addReadMirrors wraps the storage so that reads fail over to the provided mirrors, which are never written to.
+func addReadMirrors(ctx context.Context, st blob.Storage, mirrors []blob.ConnectionInfo) (blob.Storage, error) {
+	var mirrorStorage []blob.Storage
+
+	for _, ci := range mirrors {
+		ms, err := blob.NewStorage(ctx, ci, false)
+		if err != nil {
+			for _, s := range mirrorStorage {
+				s.Close(ctx) //nolint:errcheck
+			}
+
+			st.Close(ctx) //nolint:errcheck
+
+			return nil, errors.Wrapf(err, "cannot open read mirror %v", ci.Type)
+		}
+
+		mirrorStorage = append(mirrorStorage, readonly.NewWrapper(ms))
+	}
+
+	return failover.NewWrapper(st, mirrorStorage, failover.Options{}), nil
+}
+
 // openWithConfig opens the repository with a given configuration, avoiding the need for a config file.
 //
 //nolint:funlen,gocyclo
-func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions, password string, options *Options, cacheOpts *content.CachingOptions, configFile string) (DirectRepository, error) {
+func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions, password string, options *Options, cacheOpts *content.CachingOptions, domainKeys []EncryptionDomainKey, configFile string) (DirectRepository, error) {
 	cacheOpts = cacheOpts.CloneOrDefault()
+	storageEndpoints, _ := st.(failover.StatusProvider)
 	cmOpts := &content.ManagerOptions{
-		TimeNow:                defaultTime(options.TimeNowFunc),
-		DisableInternalLog:     options.DisableInternalLog,
-		PermissiveCacheLoading: cliOpts.PermissiveCacheLoading,
+		TimeNow:                 defaultTime(options.TimeNowFunc),
+		DisableInternalLog:      options.DisableInternalLog,
+		PermissiveCacheLoading:  cliOpts.PermissiveCacheLoading,
+		VerifyContentHashOnRead: cliOpts.VerifyContentHashOnRead,
 	}
 
 	mr := metrics.NewRegistry()
@@ -259,6 +328,10 @@ func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions,
 		return nil, err
 	}
 
+	if l := fmgr.BlobLayout(); !l.IsDefault() {
+		st = layout.NewWrapper(st, *l)
+	}
+
 	if fmgr.SupportsPasswordChange() {
 		cacheOpts.HMACSecret = crypto.DeriveKeyFromMasterKey(fmgr.GetHmacSecret(), fmgr.UniqueID(), localCacheIntegrityPurpose, localCacheIntegrityHMACSecretLength)
 	} else {
@@ -266,6 +339,12 @@ func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions,
 		cacheOpts.HMACSecret = crypto.DeriveKeyFromMasterKey(fmgr.FormatEncryptionKey(), fmgr.UniqueID(), localCacheIntegrityPurpose, localCacheIntegrityHMACSecretLength)
 	}
 
+	if cmOpts.EncryptionDomains, ferr = encryptionDomainCrypters(fmgr, domainKeys); ferr != nil {
+		return nil, ferr
+	}
+
+	cmOpts.WriteEncryptionDomain = cliOpts.EncryptionDomain
+
 	limits := throttlingLimitsFromConnectionInfo(ctx, st.ConnectionInfo())
 	if cliOpts.Throttling != nil {
 		limits = *cliOpts.Throttling
@@ -346,6 +425,10 @@ func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions,
 		return nil, errors.Wrap(ferr, "unable to open manifests")
 	}
 
+	if !cliOpts.ReadOnly && !cliOpts.PermissiveCacheLoading {
+		recoverCrashedSessions(ctx, cm)
+	}
+
 	closer := newRefCountedCloser(
 		scm.CloseShared,
 		dw.Wait,
@@ -360,6 +443,7 @@ func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions,
 		mmgr:  manifests,
 		sm:    scm,
 		immutableDirectRepositoryParameters: immutableDirectRepositoryParameters{
+			connectionID:     uuid.NewString(),
 			cachingOptions:   *cacheOpts,
 			fmgr:             fmgr,
 			timeNow:          cmOpts.TimeNow,
@@ -370,12 +454,34 @@ func openWithConfig(ctx context.Context, st blob.Storage, cliOpts ClientOptions,
 			metricsRegistry:  mr,
 			refCountedCloser: closer,
 			beforeFlush:      options.BeforeFlush,
+			storageEndpoints: storageEndpoints,
 		},
 	}
 
 	return dr, nil
 }
 
+// recoverCrashedSessions cleans up after write sessions of this connection which were never committed
+// because the writer crashed, and reports what was lost.
+func recoverCrashedSessions(ctx context.Context, cm *content.WriteManager) {
+	crashed, err := cm.RecoverCrashedSessions(ctx)
+	if err != nil {
+		log(ctx).Warnf("unable to recover crashed write sessions: %v", err)
+	}
+
+	for _, cs := range crashed {
+		if cs.IndexWritten {
+			log(ctx).Infof("Recovered write session %v started by %v@%v at %v, which was interrupted after writing its index.",
+				cs.ID, cs.User, cs.Host, cs.StartTime.Local().Format(time.RFC3339))
+
+			continue
+		}
+
+		log(ctx).Warnf("Write session %v started by %v@%v at %v was interrupted before flushing, %v unflushed pack blobs (%v) were lost and have been deleted.",
+			cs.ID, cs.User, cs.Host, cs.StartTime.Local().Format(time.RFC3339), len(cs.DeletedPackBlobs), units.BytesString(cs.LostBytes()))
+	}
+}
+
 func handleMissingRequiredFeatures(ctx context.Context, fmgr *format.Manager, ignoreErrors bool) error {
 	required, err := fmgr.RequiredFeatures(ctx)
 	if err != nil {
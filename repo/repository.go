@@ -13,6 +13,7 @@
 	"github.com/kopia/kopia/internal/crypto"
 	"github.com/kopia/kopia/internal/metrics"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/failover"
 	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content"
@@ -87,6 +88,7 @@ type DirectRepository interface {
 	Token(password string) (string, error)
 	Throttler() throttling.SettableThrottler
 	DisableIndexRefresh()
+	Diagnostics(ctx context.Context) (*ConnectionDiagnostics, error)
 }
 
 // DirectRepositoryWriter provides low-level write access to the repository.
@@ -95,6 +97,7 @@ type DirectRepositoryWriter interface {
 	DirectRepository
 	BlobStorage() blob.Storage
 	ContentManager() *content.WriteManager
+	ManifestManager() *manifest.Manager
 	// SetParameters(ctx context.Context, m format.MutableParameters, blobcfg format.BlobStorageConfiguration, requiredFeatures []feature.Required) error
 	// ChangePassword(ctx context.Context, newPassword string) error
 	// GetUpgradeLockIntent(ctx context.Context) (*format.UpgradeLockIntent, error)
@@ -104,6 +107,7 @@ type DirectRepositoryWriter interface {
 }
 
 type immutableDirectRepositoryParameters struct {
+	connectionID    string
 	configFile      string
 	cachingOptions  content.CachingOptions
 	cliOpts         ClientOptions
@@ -114,6 +118,9 @@ type immutableDirectRepositoryParameters struct {
 	metricsRegistry *metrics.Registry
 	beforeFlush     []RepositoryWriterCallback
 
+	// storageEndpoints reports health of storage endpoints when read mirrors are configured, nil otherwise.
+	storageEndpoints failover.StatusProvider
+
 	*refCountedCloser
 }
 
@@ -165,6 +172,23 @@ func (r *directRepository) BlobStorage() blob.Storage {
 	return r.blobs
 }
 
+// StorageEndpointStatus checks and returns the health of the primary storage and its read mirrors,
+// nil if no read mirrors are configured.
+func (r *directRepository) StorageEndpointStatus(ctx context.Context) []failover.EndpointStatus {
+	if r.storageEndpoints == nil {
+		return nil
+	}
+
+	r.storageEndpoints.CheckHealth(ctx)
+
+	return r.storageEndpoints.EndpointStatus()
+}
+
+// ManifestManager returns the manifest manager.
+func (r *directRepository) ManifestManager() *manifest.Manager {
+	return r.mmgr
+}
+
 // Throttler returns the blob storage throttler.
 func (r *directRepository) Throttler() throttling.SettableThrottler {
 	return r.throttler
@@ -207,6 +231,12 @@ func (r *directRepository) OpenObject(ctx context.Context, id object.ID) (object
 	return object.Open(ctx, r.cmgr, id)
 }
 
+// ReadObjects reads the entire contents of multiple objects using batched content retrieval.
+func (r *directRepository) ReadObjects(ctx context.Context, ids []object.ID) ([][]byte, error) {
+	//nolint:wrapcheck
+	return object.ReadObjects(ctx, r.cmgr, ids)
+}
+
 // VerifyObject verifies that the given object is stored properly in a repository and returns backing content IDs.
 func (r *directRepository) VerifyObject(ctx context.Context, id object.ID) ([]content.ID, error) {
 	//nolint:wrapcheck
@@ -352,6 +382,13 @@ func (r *directRepository) UniqueID() []byte {
 	return r.fmgr.UniqueID()
 }
 
+// ConnectionID returns the random identifier of this connection, which is shared by all its write sessions.
+// Unlike UniqueID() it differs between connections to the same repository, which may be able to read
+// different contents.
+func (r *directRepository) ConnectionID() string {
+	return r.connectionID
+}
+
 // BlobReader returns the blob reader.
 func (r *directRepository) BlobReader() blob.Reader {
 	return r.blobs
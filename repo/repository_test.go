@@ -5,6 +5,7 @@
 	"context"
 	"io"
 	"math/rand"
+	"path/filepath"
 	"runtime/debug"
 	"strings"
 	"sync/atomic"
@@ -14,9 +15,11 @@
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/internal/blobtesting"
 	"github.com/kopia/kopia/internal/cache"
 	"github.com/kopia/kopia/internal/crypto"
 	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/internal/feature"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/metricid"
 	"github.com/kopia/kopia/internal/repotesting"
@@ -26,8 +29,10 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/beforeop"
+	"github.com/kopia/kopia/repo/blob/layout"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/content/indexblob"
+	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -125,7 +130,7 @@ func (s *formatSpecificTestSuite) TestPackingSimple(t *testing.T) {
 		t.Errorf("oid3a(%q) != oid3b(%q)", got, want)
 	}
 
-	env.VerifyBlobCount(t, 4)
+	env.VerifyBlobCount(t, 4+2*format.FormatBlobReplicaCount)
 
 	env.MustReopen(t)
 
@@ -492,8 +497,8 @@ func(ctx context.Context, id blob.ID) error {
 				env.RootStorage(),
 				// GetBlob callback
 				func(ctx context.Context, id blob.ID) error {
-					// simulate not-found for format-blob and blobcfg blob
-					if id == format.KopiaBlobCfgBlobID || id == format.KopiaRepositoryBlobID {
+					// simulate not-found for format-blob and blobcfg blob and their replicas
+					if strings.HasPrefix(string(id), format.KopiaBlobCfgBlobID) || strings.HasPrefix(string(id), format.KopiaRepositoryBlobID) {
 						return blob.ErrBlobNotFound
 					}
 					return nil
@@ -537,6 +542,97 @@ func(ctx context.Context, id blob.ID) error {
 		"unexpected error when checking for format blob: unexpected error")
 }
 
+func TestInitializeWithBlobLayout(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{
+		NewRepositoryOptions: func(n *repo.NewRepositoryOptions) {
+			n.BlobLayout = &layout.Parameters{Prefix: "kopia-data/", Shards: []int{1, 3}}
+		},
+	})
+
+	data := []byte("some data")
+	oid := writeObject(ctx, t, env.RepositoryWriter, data, "o1")
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	required, err := env.RepositoryWriter.FormatManager().RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Contains(t, required, feature.Required{
+		Feature:         format.BlobLayoutFeature,
+		IfNotUnderstood: feature.IfNotUnderstood{Message: "The repository stores blobs using a custom layout."},
+	})
+
+	// only central blobs are stored outside of the prefix.
+	names, err := blob.ListAllBlobs(ctx, env.RootStorage(), "")
+	require.NoError(t, err)
+
+	var sawPack bool
+
+	for _, bm := range names {
+		if strings.HasPrefix(string(bm.BlobID), "kopia.") {
+			continue
+		}
+
+		require.True(t, strings.HasPrefix(string(bm.BlobID), "kopia-data/"), bm.BlobID)
+
+		if strings.HasPrefix(string(bm.BlobID), "kopia-data/p/") {
+			sawPack = true
+		}
+	}
+
+	require.True(t, sawPack)
+
+	// blob IDs seen by the repository are not affected.
+	packs, err := blob.ListAllBlobs(ctx, env.RepositoryWriter.BlobReader(), "p")
+	require.NoError(t, err)
+	require.NotEmpty(t, packs)
+
+	for _, bm := range packs {
+		require.NotContains(t, string(bm.BlobID), "/")
+	}
+
+	env.MustReopen(t)
+	verify(ctx, t, env.RepositoryWriter, oid, data, "o1-reopened")
+
+	require.Error(t, repo.Initialize(ctx, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), &repo.NewRepositoryOptions{
+		BlobLayout: &layout.Parameters{Prefix: "kopia.data/"},
+	}, env.Password))
+}
+
+func TestInitializePasswordless(t *testing.T) {
+	const key = "Zm9vYmFyYmF6Zm9vYmFyYmF6Zm9vYmFyYmF6Zm9vYmFy"
+
+	ctx := testlogging.Context(t)
+
+	// regular passwords are too short to be used as a key.
+	require.Error(t, repo.Initialize(ctx, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), &repo.NewRepositoryOptions{
+		Passwordless: true,
+	}, repotesting.DefaultPasswordForTesting))
+
+	st := repotesting.NewReconnectableStorage(t, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil))
+	require.NoError(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{Passwordless: true}, key))
+
+	configFile := filepath.Join(testutil.TempDirectory(t), "repository.config")
+	require.ErrorIs(t, repo.Connect(ctx, configFile, st, "wrong-"+key, nil), format.ErrInvalidPassword)
+	require.NoError(t, repo.Connect(ctx, configFile, st, key, nil))
+
+	r, err := repo.Open(ctx, configFile, key, nil)
+	require.NoError(t, err)
+
+	defer r.Close(ctx) //nolint:errcheck
+
+	dr, ok := r.(repo.DirectRepository)
+	require.True(t, ok)
+	require.True(t, dr.FormatManager().IsPasswordless())
+	require.Equal(t, crypto.KeyFileAlgorithm, dr.FormatManager().KeyDerivationAlgorithm())
+	require.Error(t, dr.FormatManager().ChangePassword(ctx, "new-password"))
+	require.Error(t, dr.FormatManager().ChangeKeyDerivationAlgorithm(ctx, format.DefaultKeyDerivationAlgorithm))
+
+	// password-less repositories require format version 3.
+	require.Error(t, repo.Initialize(ctx, blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), &repo.NewRepositoryOptions{
+		BlockFormat:  format.ContentFormat{MutableParameters: format.MutableParameters{Version: format.FormatVersion2}},
+		Passwordless: true,
+	}, key))
+}
+
 func TestInitializeWithNoRetention(t *testing.T) {
 	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant, repotesting.Options{})
 
@@ -946,3 +1042,25 @@ func mustParseObjectID(t *testing.T, s string) object.ID {
 
 	return id
 }
+
+func TestInitializeWithECCShards(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3, repotesting.Options{
+		NewRepositoryOptions: func(n *repo.NewRepositoryOptions) {
+			n.BlockFormat.ECC = ecc.AlgorithmReedSolomonWithCrc32
+			n.BlockFormat.ECCDataShards = 4
+			n.BlockFormat.ECCParityShards = 2
+		},
+	})
+
+	data := []byte("some data")
+	oid := writeObject(ctx, t, env.RepositoryWriter, data, "o1")
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+	verify(ctx, t, env.RepositoryWriter, oid, data, "o1")
+
+	required, err := env.RepositoryWriter.FormatManager().RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Contains(t, required, feature.Required{
+		Feature:         format.ECCShardsFeature,
+		IfNotUnderstood: feature.IfNotUnderstood{Message: "The repository uses explicit numbers of error correction shards."},
+	})
+}
@@ -0,0 +1,52 @@
+package repo
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
+)
+
+func TestOpenWithoutKeySlotsFeature(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil)
+	configFile := filepath.Join(testutil.TempDirectory(t), "repository.config")
+
+	require.NoError(t, Initialize(ctx, st, &NewRepositoryOptions{}, "password"))
+
+	open := func() (DirectRepository, error) {
+		return openWithConfig(ctx, st, ClientOptions{}, "password", &Options{}, &content.CachingOptions{}, nil, configFile)
+	}
+
+	rep, err := open()
+	require.NoError(t, err)
+
+	_, err = rep.FormatManager().AddKeySlot(ctx, "backup", "slot-password", format.DefaultKeyDerivationAlgorithm)
+	require.NoError(t, err)
+	require.NoError(t, rep.Close(ctx))
+
+	// simulate a client which does not understand key slots and would drop them when rewriting the format blob.
+	saved := supportedFeatures
+	supportedFeatures = slices.DeleteFunc(slices.Clone(saved), func(f feature.Feature) bool {
+		return f == format.KeySlotsFeature
+	})
+
+	defer func() { supportedFeatures = saved }()
+
+	_, err = open()
+	require.ErrorContains(t, err, format.KeySlotsRequirement.UnsupportedMessage())
+
+	supportedFeatures = saved
+
+	rep, err = open()
+	require.NoError(t, err)
+	require.NoError(t, rep.Close(ctx))
+}
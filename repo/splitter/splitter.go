@@ -3,6 +3,9 @@
 
 import (
 	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const (
@@ -44,6 +47,74 @@ func SupportedAlgorithms() []string {
 // Factory creates instances of Splitter.
 type Factory func() Splitter
 
+// Parameters overrides chunk sizes of a dynamic splitter, zero values use defaults determined by the splitter name.
+type Parameters struct {
+	MinSize int `json:"minSize,omitempty"`
+	AvgSize int `json:"avgSize,omitempty"`
+	MaxSize int `json:"maxSize,omitempty"`
+}
+
+// IsEmpty returns true if the parameters don't override any chunk sizes.
+func (p Parameters) IsEmpty() bool {
+	return p == Parameters{}
+}
+
+// defaultParameters returns default chunk sizes for a dynamic splitter with the provided average chunk size.
+func defaultParameters(avgSize int) Parameters {
+	return Parameters{
+		MinSize: avgSize / 2, //nolint:mnd
+		AvgSize: avgSize,
+		MaxSize: avgSize * 2, //nolint:mnd
+	}
+}
+
+// MaxChunkSize is the maximum supported size of a chunk produced by a dynamic splitter.
+const MaxChunkSize = 16 << 20
+
+// Validate checks that the chunk sizes can be used by a dynamic splitter.
+func (p Parameters) Validate() error {
+	if p.AvgSize&(p.AvgSize-1) != 0 {
+		return errors.Errorf("average chunk size must be a power of two, got %v", p.AvgSize)
+	}
+
+	if p.MinSize <= splitterSlidingWindowSize {
+		return errors.Errorf("minimum chunk size must be greater than %v, got %v", splitterSlidingWindowSize, p.MinSize)
+	}
+
+	if p.MinSize >= p.AvgSize || p.AvgSize >= p.MaxSize {
+		return errors.Errorf("chunk sizes must satisfy min < avg < max, got %v, %v, %v", p.MinSize, p.AvgSize, p.MaxSize)
+	}
+
+	if p.MaxSize > MaxChunkSize {
+		return errors.Errorf("maximum chunk size must not exceed %v, got %v", MaxChunkSize, p.MaxSize)
+	}
+
+	return nil
+}
+
+// dynamicSplitterAlgorithms maps suffixes of names of dynamic splitters to functions creating them.
+//
+//nolint:gochecknoglobals
+var dynamicSplitterAlgorithms = map[string]func(p Parameters) Factory{
+	"BUZHASH":   newBuzHash32SplitterFactory,
+	"RABINKARP": newRabinKarp64SplitterFactory,
+	"FASTCDC":   newFastCDCSplitterFactory,
+	"DUMP":      newDumpSplitterFactory,
+}
+
+// dynamicSplitterSizes maps average chunk sizes in names of dynamic splitters to their values.
+//
+//nolint:gochecknoglobals
+var dynamicSplitterSizes = map[string]int{
+	"128K": splitterSize128KB,
+	"256K": splitterSize256KB,
+	"512K": splitterSize512KB,
+	"1M":   splitterSize1MB,
+	"2M":   splitterSize2MB,
+	"4M":   splitterSize4MB,
+	"8M":   splitterSize8MB,
+}
+
 // splitterFactories is a map of registered splitter factories.
 //
 //nolint:gochecknoglobals
@@ -56,28 +127,41 @@ func SupportedAlgorithms() []string {
 	"FIXED-4M":   pooled(Fixed(splitterSize4MB)),
 	"FIXED-8M":   pooled(Fixed(splitterSize8MB)),
 
-	"DYNAMIC-128K-BUZHASH": pooled(newBuzHash32SplitterFactory(splitterSize128KB)),
-	"DYNAMIC-256K-BUZHASH": pooled(newBuzHash32SplitterFactory(splitterSize256KB)),
-	"DYNAMIC-512K-BUZHASH": pooled(newBuzHash32SplitterFactory(splitterSize512KB)),
-	"DYNAMIC-1M-BUZHASH":   pooled(newBuzHash32SplitterFactory(splitterSize1MB)),
-	"DYNAMIC-2M-BUZHASH":   pooled(newBuzHash32SplitterFactory(splitterSize2MB)),
-	"DYNAMIC-4M-BUZHASH":   pooled(newBuzHash32SplitterFactory(splitterSize4MB)),
-	"DYNAMIC-8M-BUZHASH":   pooled(newBuzHash32SplitterFactory(splitterSize8MB)),
-
-	"DYNAMIC-128K-RABINKARP": pooled(newRabinKarp64SplitterFactory(splitterSize128KB)),
-	"DYNAMIC-256K-RABINKARP": pooled(newRabinKarp64SplitterFactory(splitterSize256KB)),
-	"DYNAMIC-512K-RABINKARP": pooled(newRabinKarp64SplitterFactory(splitterSize512KB)),
-	"DYNAMIC-1M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(splitterSize1MB)),
-	"DYNAMIC-2M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(splitterSize2MB)),
-	"DYNAMIC-4M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(splitterSize4MB)),
-	"DYNAMIC-8M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(splitterSize8MB)),
+	"DYNAMIC-128K-BUZHASH": pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize128KB))),
+	"DYNAMIC-256K-BUZHASH": pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize256KB))),
+	"DYNAMIC-512K-BUZHASH": pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize512KB))),
+	"DYNAMIC-1M-BUZHASH":   pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize1MB))),
+	"DYNAMIC-2M-BUZHASH":   pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize2MB))),
+	"DYNAMIC-4M-BUZHASH":   pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize4MB))),
+	"DYNAMIC-8M-BUZHASH":   pooled(newBuzHash32SplitterFactory(defaultParameters(splitterSize8MB))),
+
+	"DYNAMIC-128K-RABINKARP": pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize128KB))),
+	"DYNAMIC-256K-RABINKARP": pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize256KB))),
+	"DYNAMIC-512K-RABINKARP": pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize512KB))),
+	"DYNAMIC-1M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize1MB))),
+	"DYNAMIC-2M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize2MB))),
+	"DYNAMIC-4M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize4MB))),
+	"DYNAMIC-8M-RABINKARP":   pooled(newRabinKarp64SplitterFactory(defaultParameters(splitterSize8MB))),
+
+	"DYNAMIC-128K-FASTCDC": pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize128KB))),
+	"DYNAMIC-256K-FASTCDC": pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize256KB))),
+	"DYNAMIC-512K-FASTCDC": pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize512KB))),
+	"DYNAMIC-1M-FASTCDC":   pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize1MB))),
+	"DYNAMIC-2M-FASTCDC":   pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize2MB))),
+	"DYNAMIC-4M-FASTCDC":   pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize4MB))),
+	"DYNAMIC-8M-FASTCDC":   pooled(newFastCDCSplitterFactory(defaultParameters(splitterSize8MB))),
+
+	// splitters tuned for database dumps and virtual machine images, which benefit from larger chunks.
+	"DYNAMIC-2M-DUMP": pooled(newDumpSplitterFactory(defaultParameters(splitterSize2MB))),
+	"DYNAMIC-4M-DUMP": pooled(newDumpSplitterFactory(defaultParameters(splitterSize4MB))),
+	"DYNAMIC-8M-DUMP": pooled(newDumpSplitterFactory(defaultParameters(splitterSize8MB))),
 
 	// handle deprecated legacy names to splitters of arbitrary size
 	"FIXED": Fixed(splitterSize4MB),
 
 	// we don't want to use old DYNAMIC splitter because of its license, so
 	// map this one to arbitrary buzhash32 (different)
-	"DYNAMIC": newBuzHash32SplitterFactory(splitterSize4MB),
+	"DYNAMIC": newBuzHash32SplitterFactory(defaultParameters(splitterSize4MB)),
 }
 
 // GetFactory gets splitter factory with a specified name or nil if not found.
@@ -85,5 +169,70 @@ func GetFactory(name string) Factory {
 	return splitterFactories[name]
 }
 
+// GetFactoryWithParameters gets factory of a splitter with a specified name and chunk sizes overridden
+// by the provided parameters, which are only supported by dynamic splitters.
+func GetFactoryWithParameters(name string, p Parameters) (Factory, error) {
+	if p.IsEmpty() {
+		if f := GetFactory(name); f != nil {
+			return f, nil
+		}
+
+		return nil, errors.Errorf("unsupported splitter %q", name)
+	}
+
+	actual, err := ResolveParameters(name, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return pooled(dynamicSplitterAlgorithms[dynamicSplitterAlgorithm(name)](actual)), nil
+}
+
+// dynamicSplitterAlgorithm returns the algorithm of the dynamic splitter with the provided name
+// of the form DYNAMIC-<size>-<algorithm> or an empty string if the name is not in this form.
+func dynamicSplitterAlgorithm(name string) string {
+	parts := strings.Split(name, "-")
+	if len(parts) != 3 || parts[0] != "DYNAMIC" || dynamicSplitterSizes[parts[1]] == 0 { //nolint:mnd
+		return ""
+	}
+
+	return parts[2]
+}
+
+// ResolveParameters returns the actual chunk sizes of a dynamic splitter with a specified name, whose
+// default chunk sizes are overridden by non-zero provided parameters.
+func ResolveParameters(name string, p Parameters) (Parameters, error) {
+	algorithm := dynamicSplitterAlgorithm(name)
+	if algorithm == "" {
+		return Parameters{}, errors.Errorf("splitter %q does not support custom chunk sizes", name)
+	}
+
+	if dynamicSplitterAlgorithms[algorithm] == nil {
+		return Parameters{}, errors.Errorf("unsupported splitter %q", name)
+	}
+
+	avgSize := dynamicSplitterSizes[strings.Split(name, "-")[1]]
+
+	if p.AvgSize != 0 {
+		avgSize = p.AvgSize
+	}
+
+	actual := defaultParameters(avgSize)
+
+	if p.MinSize != 0 {
+		actual.MinSize = p.MinSize
+	}
+
+	if p.MaxSize != 0 {
+		actual.MaxSize = p.MaxSize
+	}
+
+	if err := actual.Validate(); err != nil {
+		return Parameters{}, errors.Wrapf(err, "invalid parameters of splitter %q", name)
+	}
+
+	return actual, nil
+}
+
 // DefaultAlgorithm is the name of the splitter used by default for new repositories.
 const DefaultAlgorithm = "DYNAMIC-4M-BUZHASH"
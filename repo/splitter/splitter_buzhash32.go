@@ -82,12 +82,11 @@ func (rs *buzhash32Splitter) MaxSegmentSize() int {
 	return rs.maxSize
 }
 
-func newBuzHash32SplitterFactory(avgSize int) Factory {
+func newBuzHash32SplitterFactory(p Parameters) Factory {
 	// avgSize must be a power of two, so 0b000001000...0000
 	// it just so happens that mask is avgSize-1 :)
-	mask := uint32(avgSize - 1) //nolint:gosec
-	maxSize := avgSize * 2      //nolint:mnd
-	minSize := avgSize / 2      //nolint:mnd
+	mask := uint32(p.AvgSize - 1) //nolint:gosec
+	minSize, maxSize := p.MinSize, p.MaxSize
 
 	return func() Splitter {
 		s := buzhash32.New()
@@ -0,0 +1,247 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+)
+
+// dumpSniffSize is the number of initial bytes of an object used to detect its format.
+const dumpSniffSize = 512
+
+// dumpFormat describes the format of an object, which determines where split points are placed.
+type dumpFormat int
+
+const (
+	// dumpFormatBinary splits at content-defined split points.
+	dumpFormatBinary dumpFormat = iota
+
+	// dumpFormatText delays content-defined split points until the end of the current line.
+	dumpFormatText
+
+	// dumpFormatSQL delays content-defined split points until the end of the current SQL statement.
+	dumpFormatSQL
+
+	// dumpFormatImage delays content-defined split points until the next block boundary of a disk image.
+	dumpFormatImage
+)
+
+// dumpSplitter implements content-defined chunking tuned for database dumps and virtual machine images.
+//
+// Candidate split points are found using FastCDC, but instead of splitting immediately the splitter waits
+// for the next anchor determined by the format of the object: the end of a line in text files, the end
+// of a statement in SQL dumps or a cluster boundary in disk images. Since anchors don't depend on
+// the position of previous split points, chunks of records that did not change between daily dumps
+// remain identical even when records before them have been inserted, deleted or resized.
+type dumpSplitter struct {
+	fp      uint64
+	maskS   uint64
+	maskL   uint64
+	count   int
+	minSize int
+	avgSize int
+	maxSize int
+
+	// size after which chunks are split at the next anchor even without a content-defined split point.
+	anchorOnlySize int
+
+	// state describing the entire object.
+	offset    int64
+	header    []byte
+	format    dumpFormat
+	blockSize int64
+	prev      byte
+	pending   bool
+}
+
+func (s *dumpSplitter) Close() {
+}
+
+func (s *dumpSplitter) Reset() {
+	s.resetChunk()
+
+	s.offset = 0
+	s.header = s.header[:0]
+	s.format = dumpFormatBinary
+	s.blockSize = 0
+	s.prev = 0
+}
+
+func (s *dumpSplitter) resetChunk() {
+	s.fp = 0
+	s.count = 0
+	s.pending = false
+}
+
+func (s *dumpSplitter) NextSplitPoint(b []byte) int {
+	var fastPathBytes int
+
+	// until minSize, only hash the last splitterSlidingWindowSize bytes, older bytes are shifted out of the hash.
+	if left := s.minSize - s.count - 1; left > 0 {
+		fastPathBytes = min(left, len(b))
+
+		s.sniff(b[:fastPathBytes])
+
+		for _, c := range b[max(fastPathBytes-splitterSlidingWindowSize, 0):fastPathBytes] {
+			s.fp = (s.fp << 1) + gearTable[c]
+		}
+
+		if fastPathBytes > 0 {
+			s.prev = b[fastPathBytes-1]
+		}
+
+		s.count += fastPathBytes
+		s.offset += int64(fastPathBytes)
+		b = b[fastPathBytes:]
+	}
+
+	for i, c := range b {
+		if s.offset < dumpSniffSize {
+			s.sniff(b[i : i+1])
+		}
+
+		s.fp = (s.fp << 1) + gearTable[c]
+		s.count++
+		s.offset++
+
+		if !s.pending {
+			mask := s.maskL
+			if s.count < s.avgSize {
+				mask = s.maskS
+			}
+
+			// close to the maximum size, split at any anchor rather than at an arbitrary position.
+			s.pending = s.fp&mask == 0 || s.format != dumpFormatBinary && s.count >= s.anchorOnlySize
+		}
+
+		isSplit := s.count >= s.maxSize || s.pending && s.isAnchor(c)
+		s.prev = c
+
+		if isSplit {
+			s.resetChunk()
+			return fastPathBytes + i + 1
+		}
+	}
+
+	return -1
+}
+
+// isAnchor determines whether a pending split point can be placed after the provided byte.
+func (s *dumpSplitter) isAnchor(c byte) bool {
+	switch s.format {
+	case dumpFormatText:
+		return c == '\n'
+
+	case dumpFormatSQL:
+		return c == '\n' && s.prev == ';'
+
+	case dumpFormatImage:
+		return s.offset%s.blockSize == 0
+
+	default:
+		return true
+	}
+}
+
+// sniff accumulates initial bytes of the object and detects its format once enough bytes have been seen.
+func (s *dumpSplitter) sniff(b []byte) {
+	left := dumpSniffSize - len(s.header)
+	if left <= 0 {
+		return
+	}
+
+	s.header = append(s.header, b[:min(left, len(b))]...)
+	if len(s.header) == dumpSniffSize {
+		s.format, s.blockSize = detectDumpFormat(s.header)
+
+		// block boundaries must be frequent enough to be found before reaching the maximum chunk size.
+		for s.format == dumpFormatImage && s.blockSize > int64(s.minSize) {
+			s.blockSize >>= 1
+		}
+	}
+}
+
+const (
+	qcow2ClusterBitsOffset = 20
+	qcow2MinClusterBits    = 9
+	qcow2MaxClusterBits    = 21
+	vmdkGrainSizeOffset    = 20
+	sectorSize             = 512
+	vhdxAlignment          = 1 << 20
+	defaultImageBlockSize  = 4096
+	mbrSignatureOffset     = 510
+)
+
+//nolint:gochecknoglobals
+var sqlDumpPrefixes = [][]byte{
+	[]byte("--"),
+	[]byte("/*"),
+	[]byte("SET "),
+	[]byte("CREATE "),
+	[]byte("INSERT "),
+	[]byte("DROP "),
+	[]byte("BEGIN"),
+	[]byte("PRAGMA "),
+	[]byte("USE "),
+	[]byte("LOCK "),
+}
+
+// detectDumpFormat determines the format of an object based on its initial bytes and for disk images, the size of their blocks.
+func detectDumpFormat(h []byte) (dumpFormat, int64) {
+	switch {
+	case bytes.HasPrefix(h, []byte("QFI\xfb")):
+		if clusterBits := binary.BigEndian.Uint32(h[qcow2ClusterBitsOffset:]); clusterBits >= qcow2MinClusterBits && clusterBits <= qcow2MaxClusterBits {
+			return dumpFormatImage, 1 << clusterBits
+		}
+
+		return dumpFormatImage, defaultImageBlockSize
+
+	case bytes.HasPrefix(h, []byte("KDMV")):
+		if grain := binary.LittleEndian.Uint64(h[vmdkGrainSizeOffset:]) * sectorSize; grain > 0 && grain <= MaxChunkSize && bits.OnesCount64(grain) == 1 {
+			return dumpFormatImage, int64(grain) //nolint:gosec
+		}
+
+		return dumpFormatImage, defaultImageBlockSize
+
+	case bytes.HasPrefix(h, []byte("vhdxfile")):
+		return dumpFormatImage, vhdxAlignment
+
+	case h[mbrSignatureOffset] == 0x55 && h[mbrSignatureOffset+1] == 0xAA:
+		return dumpFormatImage, defaultImageBlockSize
+
+	case bytes.IndexByte(h, 0) >= 0:
+		return dumpFormatBinary, 0
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(h, []byte("\xef\xbb\xbf")), " \t\r\n")
+	for _, p := range sqlDumpPrefixes {
+		if bytes.HasPrefix(trimmed, p) {
+			return dumpFormatSQL, 0
+		}
+	}
+
+	return dumpFormatText, 0
+}
+
+func (s *dumpSplitter) MaxSegmentSize() int {
+	return s.maxSize
+}
+
+func newDumpSplitterFactory(p Parameters) Factory {
+	avgBits := bits.Len(uint(p.AvgSize)) - 1 //nolint:gosec
+	maskS := topBitsMask(avgBits + fastCDCNormalizationLevel)
+	maskL := topBitsMask(avgBits - fastCDCNormalizationLevel)
+
+	return func() Splitter {
+		return &dumpSplitter{
+			maskS:   maskS,
+			maskL:   maskL,
+			minSize: p.MinSize,
+			avgSize: p.AvgSize,
+			maxSize: p.MaxSize,
+			header:  make([]byte, 0, dumpSniffSize),
+
+			anchorOnlySize: p.MaxSize - (p.MaxSize-p.AvgSize)/2, //nolint:mnd
+		}
+	}
+}
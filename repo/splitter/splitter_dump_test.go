@@ -0,0 +1,138 @@
+package splitter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func splitAll(f Factory, data []byte) [][]byte {
+	s := f()
+	defer s.Close()
+
+	var chunks [][]byte
+
+	for len(data) > 0 {
+		n := s.NextSplitPoint(data)
+		if n < 0 {
+			break
+		}
+
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+
+	if len(data) > 0 {
+		chunks = append(chunks, data)
+	}
+
+	return chunks
+}
+
+func sqlDump(r *rand.Rand, firstRow, numRows int) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("-- PostgreSQL database dump\n\nCREATE TABLE items (id integer, name text);\n\n")
+
+	for i := firstRow; i < firstRow+numRows; i++ {
+		fmt.Fprintf(&buf, "INSERT INTO items VALUES (%d, '%x');\n", i, r.Int63())
+	}
+
+	return buf.Bytes()
+}
+
+func TestDumpSplitterSQL(t *testing.T) {
+	f := newDumpSplitterFactory(defaultParameters(4096))
+
+	data := sqlDump(rand.New(rand.NewSource(1)), 0, 20000)
+	chunks := splitAll(f, data)
+	require.Greater(t, len(chunks), 50)
+
+	for _, c := range chunks[:len(chunks)-1] {
+		require.True(t, bytes.HasSuffix(c, []byte(");\n")), "chunk does not end at statement boundary: %q", c[len(c)-20:])
+	}
+
+	// rows inserted at the beginning of the dump only affect chunks containing them.
+	modified := append(sqlDump(rand.New(rand.NewSource(2)), -50, 50), data...)
+
+	original := map[string]bool{}
+	for _, c := range chunks {
+		original[string(c)] = true
+	}
+
+	var reused int
+
+	modifiedChunks := splitAll(f, modified)
+	for _, c := range modifiedChunks {
+		if original[string(c)] {
+			reused++
+		}
+	}
+
+	require.Greater(t, reused, len(modifiedChunks)*9/10)
+}
+
+func TestDumpSplitterImage(t *testing.T) {
+	const clusterSize = 1 << 12
+
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	copy(data, "QFI\xfb")
+	binary.BigEndian.PutUint32(data[qcow2ClusterBitsOffset:], 12)
+
+	var offset int
+
+	chunks := splitAll(newDumpSplitterFactory(defaultParameters(32768)), data)
+	for _, c := range chunks[:len(chunks)-1] {
+		offset += len(c)
+		require.Zero(t, offset%clusterSize, "split point %v is not aligned to cluster", offset)
+	}
+}
+
+func TestDumpFormatDetection(t *testing.T) {
+	header := func(prefix string) []byte {
+		h := make([]byte, dumpSniffSize)
+		for i := range h {
+			h[i] = ' '
+		}
+
+		copy(h, prefix)
+
+		return h
+	}
+
+	mbr := make([]byte, dumpSniffSize)
+	mbr[510], mbr[511] = 0x55, 0xAA
+
+	qcow2 := header("QFI\xfb")
+	binary.BigEndian.PutUint32(qcow2[qcow2ClusterBitsOffset:], 16)
+
+	vmdk := header("KDMV")
+	binary.LittleEndian.PutUint64(vmdk[vmdkGrainSizeOffset:], 128)
+
+	cases := []struct {
+		header    []byte
+		format    dumpFormat
+		blockSize int64
+	}{
+		{header("-- MySQL dump 10.13"), dumpFormatSQL, 0},
+		{header("\xef\xbb\xbf\nPRAGMA foreign_keys=OFF;"), dumpFormatSQL, 0},
+		{header("id,name\n1,foo\n"), dumpFormatText, 0},
+		{header("PGDMP\x00\x01"), dumpFormatBinary, 0},
+		{qcow2, dumpFormatImage, 1 << 16},
+		{vmdk, dumpFormatImage, 1 << 16},
+		{header("vhdxfile"), dumpFormatImage, vhdxAlignment},
+		{mbr, dumpFormatImage, defaultImageBlockSize},
+	}
+
+	for _, tc := range cases {
+		format, blockSize := detectDumpFormat(tc.header)
+		require.Equal(t, tc.format, format, "%q", tc.header[:16])
+		require.Equal(t, tc.blockSize, blockSize, "%q", tc.header[:16])
+	}
+}
@@ -0,0 +1,109 @@
+package splitter
+
+import "math/bits"
+
+// fastCDCNormalizationLevel determines how many bits are added to (removed from) the mask
+// before (after) the average chunk size, which narrows the distribution of chunk sizes.
+const fastCDCNormalizationLevel = 2
+
+// gearTable contains random values used by the gear rolling hash, they must never change
+// since they determine split points of existing repositories.
+//
+//nolint:gochecknoglobals
+var gearTable = newGearTable()
+
+// fastCDCSplitter implements FastCDC content-defined chunking with normalized chunk sizes.
+// Since each byte shifts the gear hash by one bit, the hash only depends on the last 64 bytes.
+type fastCDCSplitter struct {
+	fp      uint64
+	maskS   uint64 // mask used before reaching average size, harder to match
+	maskL   uint64 // mask used after reaching average size, easier to match
+	count   int
+	minSize int
+	avgSize int
+	maxSize int
+}
+
+func (s *fastCDCSplitter) Close() {
+}
+
+func (s *fastCDCSplitter) Reset() {
+	s.fp = 0
+	s.count = 0
+}
+
+func (s *fastCDCSplitter) NextSplitPoint(b []byte) int {
+	var fastPathBytes int
+
+	// until minSize, only hash the last splitterSlidingWindowSize bytes, older bytes are shifted out of the hash.
+	if left := s.minSize - s.count - 1; left > 0 {
+		fastPathBytes = min(left, len(b))
+
+		for _, c := range b[max(fastPathBytes-splitterSlidingWindowSize, 0):fastPathBytes] {
+			s.fp = (s.fp << 1) + gearTable[c]
+		}
+
+		s.count += fastPathBytes
+		b = b[fastPathBytes:]
+	}
+
+	for i, c := range b {
+		s.fp = (s.fp << 1) + gearTable[c]
+		s.count++
+
+		mask := s.maskL
+		if s.count < s.avgSize {
+			mask = s.maskS
+		}
+
+		if s.fp&mask == 0 || s.count >= s.maxSize {
+			s.Reset()
+			return fastPathBytes + i + 1
+		}
+	}
+
+	return -1
+}
+
+func (s *fastCDCSplitter) MaxSegmentSize() int {
+	return s.maxSize
+}
+
+func newFastCDCSplitterFactory(p Parameters) Factory {
+	avgBits := bits.Len(uint(p.AvgSize)) - 1 //nolint:gosec
+	maskS := topBitsMask(avgBits + fastCDCNormalizationLevel)
+	maskL := topBitsMask(avgBits - fastCDCNormalizationLevel)
+
+	return func() Splitter {
+		return &fastCDCSplitter{
+			maskS:   maskS,
+			maskL:   maskL,
+			minSize: p.MinSize,
+			avgSize: p.AvgSize,
+			maxSize: p.MaxSize,
+		}
+	}
+}
+
+// topBitsMask returns a mask of n most significant bits, which depend on the most recent bytes hashed.
+func topBitsMask(n int) uint64 {
+	return ^uint64(0) << (64 - n) //nolint:mnd
+}
+
+// newGearTable returns a table of pseudo-random values generated using deterministic splitmix64 sequence.
+func newGearTable() [256]uint64 {
+	var (
+		result [256]uint64
+		state  uint64 = 0x6b6f706961 //nolint:mnd
+	)
+
+	for i := range result {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9 //nolint:mnd
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb //nolint:mnd
+		result[i] = z ^ (z >> 31)                //nolint:mnd
+	}
+
+	return result
+}
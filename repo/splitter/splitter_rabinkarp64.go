@@ -82,9 +82,9 @@ func (rs *rabinKarp64Splitter) MaxSegmentSize() int {
 	return rs.maxSize
 }
 
-func newRabinKarp64SplitterFactory(avgSize int) Factory {
-	mask := uint64(avgSize - 1)              //nolint:gosec
-	minSize, maxSize := avgSize/2, avgSize*2 //nolint:mnd
+func newRabinKarp64SplitterFactory(p Parameters) Factory {
+	mask := uint64(p.AvgSize - 1) //nolint:gosec
+	minSize, maxSize := p.MinSize, p.MaxSize
 
 	return func() Splitter {
 		s := rabinkarp64.New()
@@ -27,29 +27,37 @@ func TestSplitterStability(t *testing.T) {
 		{Fixed(1000), 5000, 1000, 1000, 1000},
 		{Fixed(10000), 500, 10000, 10000, 10000},
 
-		{newBuzHash32SplitterFactory(32), 124235, 40, 16, 64},
-		{newBuzHash32SplitterFactory(1024), 3835, 1303, 512, 2048},
-		{newBuzHash32SplitterFactory(2048), 1924, 2598, 1024, 4096},
-		{newBuzHash32SplitterFactory(32768), 112, 44642, 16413, 65536},
-		{newBuzHash32SplitterFactory(65536), 57, 87719, 32932, 131072},
-		{newRabinKarp64SplitterFactory(32), 124108, 40, 16, 64},
-		{newRabinKarp64SplitterFactory(1024), 3771, 1325, 512, 2048},
-		{newRabinKarp64SplitterFactory(2048), 1887, 2649, 1028, 4096},
-		{newRabinKarp64SplitterFactory(32768), 121, 41322, 16896, 65536},
-		{newRabinKarp64SplitterFactory(65536), 53, 94339, 35875, 131072},
+		{newBuzHash32SplitterFactory(defaultParameters(32)), 124235, 40, 16, 64},
+		{newBuzHash32SplitterFactory(defaultParameters(1024)), 3835, 1303, 512, 2048},
+		{newBuzHash32SplitterFactory(defaultParameters(2048)), 1924, 2598, 1024, 4096},
+		{newBuzHash32SplitterFactory(defaultParameters(32768)), 112, 44642, 16413, 65536},
+		{newBuzHash32SplitterFactory(defaultParameters(65536)), 57, 87719, 32932, 131072},
+		{newRabinKarp64SplitterFactory(defaultParameters(32)), 124108, 40, 16, 64},
+		{newRabinKarp64SplitterFactory(defaultParameters(1024)), 3771, 1325, 512, 2048},
+		{newRabinKarp64SplitterFactory(defaultParameters(2048)), 1887, 2649, 1028, 4096},
+		{newRabinKarp64SplitterFactory(defaultParameters(32768)), 121, 41322, 16896, 65536},
+		{newRabinKarp64SplitterFactory(defaultParameters(65536)), 53, 94339, 35875, 131072},
+		{newFastCDCSplitterFactory(defaultParameters(1024)), 4103, 1218, 512, 2048},
+		{newFastCDCSplitterFactory(defaultParameters(2048)), 2054, 2434, 1026, 4096},
+		{newFastCDCSplitterFactory(defaultParameters(32768)), 133, 37593, 17061, 65536},
+		{newFastCDCSplitterFactory(Parameters{MinSize: 1000, AvgSize: 4096, MaxSize: 6000}), 1106, 4520, 1012, 6000},
+
+		// binary data is split at the same points as FastCDC.
+		{newDumpSplitterFactory(defaultParameters(1024)), 4103, 1218, 512, 2048},
+		{newDumpSplitterFactory(defaultParameters(32768)), 133, 37593, 17061, 65536},
 
 		{pooled(Fixed(1000)), 5000, 1000, 1000, 1000},
 
-		{pooled(newBuzHash32SplitterFactory(32)), 124235, 40, 16, 64},
-		{pooled(newBuzHash32SplitterFactory(1024)), 3835, 1303, 512, 2048},
-		{pooled(newBuzHash32SplitterFactory(2048)), 1924, 2598, 1024, 4096},
-		{pooled(newBuzHash32SplitterFactory(32768)), 112, 44642, 16413, 65536},
-		{pooled(newBuzHash32SplitterFactory(65536)), 57, 87719, 32932, 131072},
-		{pooled(newRabinKarp64SplitterFactory(32)), 124108, 40, 16, 64},
-		{pooled(newRabinKarp64SplitterFactory(1024)), 3771, 1325, 512, 2048},
-		{pooled(newRabinKarp64SplitterFactory(2048)), 1887, 2649, 1028, 4096},
-		{pooled(newRabinKarp64SplitterFactory(32768)), 121, 41322, 16896, 65536},
-		{pooled(newRabinKarp64SplitterFactory(65536)), 53, 94339, 35875, 131072},
+		{pooled(newBuzHash32SplitterFactory(defaultParameters(32))), 124235, 40, 16, 64},
+		{pooled(newBuzHash32SplitterFactory(defaultParameters(1024))), 3835, 1303, 512, 2048},
+		{pooled(newBuzHash32SplitterFactory(defaultParameters(2048))), 1924, 2598, 1024, 4096},
+		{pooled(newBuzHash32SplitterFactory(defaultParameters(32768))), 112, 44642, 16413, 65536},
+		{pooled(newBuzHash32SplitterFactory(defaultParameters(65536))), 57, 87719, 32932, 131072},
+		{pooled(newRabinKarp64SplitterFactory(defaultParameters(32))), 124108, 40, 16, 64},
+		{pooled(newRabinKarp64SplitterFactory(defaultParameters(1024))), 3771, 1325, 512, 2048},
+		{pooled(newRabinKarp64SplitterFactory(defaultParameters(2048))), 1887, 2649, 1028, 4096},
+		{pooled(newRabinKarp64SplitterFactory(defaultParameters(32768))), 121, 41322, 16896, 65536},
+		{pooled(newRabinKarp64SplitterFactory(defaultParameters(65536))), 53, 94339, 35875, 131072},
 	}
 
 	// run each test twice to rule out the possibility of some state leaking through splitter reuse
@@ -209,3 +217,50 @@ func getSplitPointsRandomSlices(data []byte, s Splitter) (minSplit, maxSplit, co
 
 	return minSplit, maxSplit, count
 }
+
+func TestGetFactoryWithParameters(t *testing.T) {
+	cases := []struct {
+		name    string
+		p       Parameters
+		wantErr bool
+		wantMax int
+	}{
+		{"DYNAMIC-4M-BUZHASH", Parameters{}, false, 8 << 20},
+		{"FIXED-1M", Parameters{}, false, 1 << 20},
+		{"NO-SUCH-SPLITTER", Parameters{}, true, 0},
+		{"FIXED-1M", Parameters{AvgSize: 1 << 20}, true, 0},
+		{"DYNAMIC-4M-FASTCDC", Parameters{MaxSize: 5 << 20}, false, 5 << 20},
+		{"DYNAMIC-4M-RABINKARP", Parameters{AvgSize: 1 << 20}, false, 2 << 20},
+		{"DYNAMIC-1M-BUZHASH", Parameters{MinSize: 1000, AvgSize: 65536, MaxSize: 100000}, false, 100000},
+		{"DYNAMIC-1M-FASTCDC", Parameters{AvgSize: 1000000}, true, 0},
+		{"DYNAMIC-1M-FASTCDC", Parameters{MinSize: 10}, true, 0},
+		{"DYNAMIC-1M-FASTCDC", Parameters{MinSize: 2 << 20}, true, 0},
+		{"DYNAMIC-8M-FASTCDC", Parameters{MaxSize: 32 << 20}, true, 0},
+		{"DYNAMIC-1M-NOSUCHALGO", Parameters{MaxSize: 3 << 20}, true, 0},
+		{"DYNAMIC-8M-DUMP", Parameters{}, false, 16 << 20},
+		{"DYNAMIC-4M-DUMP", Parameters{AvgSize: 1 << 20}, false, 2 << 20},
+	}
+
+	for _, tc := range cases {
+		f, err := GetFactoryWithParameters(tc.name, tc.p)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("expected error for %v %+v", tc.name, tc.p)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error for %v %+v: %v", tc.name, tc.p, err)
+		}
+
+		s := f()
+
+		if got := s.MaxSegmentSize(); got != tc.wantMax {
+			t.Errorf("unexpected max segment size of %v %+v: %v, want %v", tc.name, tc.p, got, tc.wantMax)
+		}
+
+		s.Close()
+	}
+}
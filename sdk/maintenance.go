@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot/snapshotmaintenance"
+)
+
+// MaintenanceOptions specifies options for RunMaintenance.
+type MaintenanceOptions struct {
+	// Full runs full maintenance instead of quick maintenance.
+	Full bool
+
+	// Force runs maintenance even if this client is not the maintenance owner.
+	Force bool
+}
+
+// RunMaintenance runs repository maintenance, which removes unreferenced data and compacts indexes.
+func (r *Repository) RunMaintenance(ctx context.Context, opts MaintenanceOptions) error {
+	mode := maintenance.ModeQuick
+	if opts.Full {
+		mode = maintenance.ModeFull
+	}
+
+	dr, ok := r.rep.(repo.DirectRepository)
+	if !ok {
+		return errors.New("maintenance is only supported for direct repository connections")
+	}
+
+	return errors.Wrap(repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
+		Purpose: "sdk:maintenance",
+	}, func(ctx context.Context, dw repo.DirectRepositoryWriter) error {
+		//nolint:wrapcheck
+		return snapshotmaintenance.Run(ctx, dw, mode, opts.Force, maintenance.SafetyFull)
+	}), "maintenance failed")
+}
@@ -0,0 +1,43 @@
+package sdk
+
+import (
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// SnapshotProgress receives notifications about the progress of a snapshot.
+// Methods may be invoked concurrently from multiple goroutines.
+type SnapshotProgress interface {
+	// FileProcessed is invoked after a file has been processed. Cached files were unchanged since
+	// the previous snapshot and their contents were not read.
+	FileProcessed(path string, size int64, cached bool)
+
+	// Error is invoked when a file or directory could not be processed. Ignored errors
+	// don't cause the snapshot to be incomplete.
+	Error(path string, err error, ignored bool)
+}
+
+// RestoreProgress receives periodic notifications about the progress of a restore.
+type RestoreProgress interface {
+	RestoreProgress(stats RestoreStats)
+}
+
+// snapshotProgressAdapter adapts SnapshotProgress to snapshotfs.UploadProgress.
+type snapshotProgressAdapter struct {
+	snapshotfs.NullUploadProgress
+
+	p SnapshotProgress
+}
+
+func (a *snapshotProgressAdapter) CachedFile(path string, size int64) {
+	a.p.FileProcessed(path, size, true)
+}
+
+func (a *snapshotProgressAdapter) FinishedHashingFile(path string, numBytes int64) {
+	a.p.FileProcessed(path, numBytes, false)
+}
+
+func (a *snapshotProgressAdapter) Error(path string, err error, ignored bool) {
+	a.p.Error(path, err, ignored)
+}
+
+var _ snapshotfs.UploadProgress = (*snapshotProgressAdapter)(nil)
@@ -0,0 +1,100 @@
+package sdk
+
+import (
+	"context"
+	"math"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// RestoreOptions specifies options for Restore.
+type RestoreOptions struct {
+	// Overwrite allows restoring into existing directories and replacing existing files and symlinks.
+	Overwrite bool
+
+	// Incremental skips files which already exist in the target with the same size and modification time.
+	Incremental bool
+
+	// IgnoreErrors continues the restore when individual files fail to restore.
+	IgnoreErrors bool
+
+	// Parallelism is the number of files restored in parallel.
+	Parallelism int
+
+	SkipOwners      bool
+	SkipPermissions bool
+
+	// Progress, when set, receives periodic progress notifications.
+	Progress RestoreProgress
+}
+
+// RestoreStats describes the results of a restore.
+type RestoreStats struct {
+	RestoredFileCount    int   `json:"restoredFiles"`
+	RestoredDirCount     int   `json:"restoredDirs"`
+	RestoredSymlinkCount int   `json:"restoredSymlinks"`
+	RestoredBytes        int64 `json:"restoredBytes"`
+	SkippedCount         int   `json:"skipped"`
+	IgnoredErrorCount    int   `json:"ignoredErrors"`
+}
+
+func restoreStatsFrom(s restore.Stats) RestoreStats {
+	return RestoreStats{
+		RestoredFileCount:    int(s.RestoredFileCount),
+		RestoredDirCount:     int(s.RestoredDirCount),
+		RestoredSymlinkCount: int(s.RestoredSymlinkCount),
+		RestoredBytes:        s.RestoredTotalFileSize,
+		SkippedCount:         int(s.SkippedCount),
+		IgnoredErrorCount:    int(s.IgnoredErrorCount),
+	}
+}
+
+// Restore restores the snapshot with the provided ID to the target path on the local filesystem.
+func (r *Repository) Restore(ctx context.Context, snapshotID, targetPath string, opts RestoreOptions) (RestoreStats, error) {
+	m, err := r.loadSnapshot(ctx, snapshotID)
+	if err != nil {
+		return RestoreStats{}, err
+	}
+
+	root, err := snapshotfs.SnapshotRoot(r.rep, m)
+	if err != nil {
+		return RestoreStats{}, errors.Wrap(err, "unable to get snapshot root")
+	}
+
+	output := &restore.FilesystemOutput{
+		TargetPath:             targetPath,
+		OverwriteDirectories:   opts.Overwrite,
+		OverwriteFiles:         opts.Overwrite,
+		OverwriteSymlinks:      opts.Overwrite,
+		SkipOwners:             opts.SkipOwners,
+		SkipPermissions:        opts.SkipPermissions,
+		IgnorePermissionErrors: true,
+	}
+
+	if err := output.Init(ctx); err != nil {
+		return RestoreStats{}, errors.Wrap(err, "unable to initialize output")
+	}
+
+	ropts := restore.Options{
+		Parallel:               opts.Parallelism,
+		Incremental:            opts.Incremental,
+		IgnoreErrors:           opts.IgnoreErrors,
+		RestoreDirEntryAtDepth: math.MaxInt32,
+	}
+
+	if opts.Progress != nil {
+		ropts.ProgressCallback = func(_ context.Context, s restore.Stats) {
+			opts.Progress.RestoreProgress(restoreStatsFrom(s))
+		}
+	}
+
+	st, err := restore.Entry(ctx, r.rep, output, root, ropts)
+	if err != nil {
+		return restoreStatsFrom(st), errors.Wrap(err, "restore failed")
+	}
+
+	return restoreStatsFrom(st), nil
+}
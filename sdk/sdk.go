@@ -0,0 +1,148 @@
+// Package sdk provides a stable facade for products embedding kopia.
+//
+// The package exposes high-level operations - creating and connecting to repositories, snapshotting
+// and restoring local paths, listing snapshots, verification and maintenance - using plain option
+// structs and small progress interfaces, so that callers don't need to depend on the lower-level
+// repository and snapshot packages, whose APIs change more frequently.
+package sdk
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// ErrAlreadyInitialized is returned by Create when the storage already contains a repository.
+var ErrAlreadyInitialized = repo.ErrAlreadyInitialized
+
+// ErrNotInitialized is returned by Connect when the storage does not contain a repository.
+var ErrNotInitialized = repo.ErrRepositoryNotInitialized
+
+// ConnectOptions specifies options used when creating and connecting to a repository.
+type ConnectOptions struct {
+	// ConfigFile is the path of the configuration file which is written when connecting
+	// and used to open the repository afterwards.
+	ConfigFile string
+
+	Password string
+
+	// Hostname and Username identify the client in snapshots it creates. When not provided,
+	// the local host name and user name are used.
+	Hostname string
+	Username string
+
+	// CacheDirectory is the location of local caches, defaults to a directory next to the config file.
+	CacheDirectory string
+
+	ReadOnly bool
+}
+
+func (o ConnectOptions) connectOptions() *repo.ConnectOptions {
+	return &repo.ConnectOptions{
+		ClientOptions: repo.ClientOptions{
+			Hostname: o.Hostname,
+			Username: o.Username,
+			ReadOnly: o.ReadOnly,
+		},
+		CachingOptions: content.CachingOptions{
+			CacheDirectory: o.CacheDirectory,
+		},
+	}
+}
+
+// FilesystemStorage returns the storage located in the provided local directory, creating it if needed.
+// Storage for other providers can be obtained from their packages under repo/blob.
+func FilesystemStorage(ctx context.Context, path string, create bool) (blob.Storage, error) {
+	st, err := filesystem.New(ctx, &filesystem.Options{Path: path}, create)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open filesystem storage")
+	}
+
+	return st, nil
+}
+
+// Create initializes a new repository with default settings in the provided storage and connects to it.
+func Create(ctx context.Context, st blob.Storage, opts ConnectOptions) error {
+	if err := repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, opts.Password); err != nil {
+		//nolint:wrapcheck
+		return err
+	}
+
+	if err := Connect(ctx, st, opts); err != nil {
+		return err
+	}
+
+	r, err := Open(ctx, opts.ConfigFile, opts.Password)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close(ctx) //nolint:errcheck
+
+	return errors.Wrap(repo.WriteSession(ctx, r.rep, repo.WriteSessionOptions{
+		Purpose: "sdk:populate repository",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		if err := policy.SetPolicy(ctx, w, policy.GlobalPolicySourceInfo, policy.DefaultPolicy); err != nil {
+			return errors.Wrap(err, "unable to set global policy")
+		}
+
+		p := maintenance.DefaultParams()
+		p.Owner = w.ClientOptions().UsernameAtHost()
+
+		return errors.Wrap(maintenance.SetParams(ctx, w, &p), "unable to set maintenance params")
+	}), "unable to populate repository")
+}
+
+// Connect connects to an existing repository in the provided storage and writes the configuration file.
+func Connect(ctx context.Context, st blob.Storage, opts ConnectOptions) error {
+	if opts.ConfigFile == "" {
+		return errors.New("config file must be provided")
+	}
+
+	//nolint:wrapcheck
+	return repo.Connect(ctx, opts.ConfigFile, st, opts.Password, opts.connectOptions())
+}
+
+// Disconnect removes the configuration file and local caches of a connected repository.
+func Disconnect(ctx context.Context, configFile string) error {
+	//nolint:wrapcheck
+	return repo.Disconnect(ctx, configFile)
+}
+
+// Repository is an open connection to a repository. It is safe for concurrent use.
+type Repository struct {
+	rep repo.Repository
+}
+
+// Open opens a repository previously connected using Create or Connect.
+func Open(ctx context.Context, configFile, password string) (*Repository, error) {
+	rep, err := repo.Open(ctx, configFile, password, &repo.Options{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open repository")
+	}
+
+	return &Repository{rep: rep}, nil
+}
+
+// Close closes the repository and releases associated resources.
+func (r *Repository) Close(ctx context.Context) error {
+	//nolint:wrapcheck
+	return r.rep.Close(ctx)
+}
+
+// Hostname returns the host name identifying this client.
+func (r *Repository) Hostname() string {
+	return r.rep.ClientOptions().Hostname
+}
+
+// Username returns the user name identifying this client.
+func (r *Repository) Username() string {
+	return r.rep.ClientOptions().Username
+}
@@ -0,0 +1,124 @@
+package sdk_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/sdk"
+)
+
+type fileCounter struct {
+	mu     sync.Mutex
+	files  map[string]bool
+	errors int
+}
+
+func (c *fileCounter) FileProcessed(path string, _ int64, cached bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.files[path] = cached
+}
+
+func (c *fileCounter) Error(string, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errors++
+}
+
+func TestSDK(t *testing.T) {
+	ctx := testlogging.Context(t)
+	baseDir := testutil.TempDirectory(t)
+
+	st, err := sdk.FilesystemStorage(ctx, filepath.Join(baseDir, "repo"), true)
+	require.NoError(t, err)
+
+	opts := sdk.ConnectOptions{
+		ConfigFile: filepath.Join(baseDir, "repository.config"),
+		Password:   "sdk-password",
+		Hostname:   "host",
+		Username:   "user",
+	}
+
+	require.NoError(t, sdk.Create(ctx, st, opts))
+	require.ErrorIs(t, sdk.Create(ctx, st, opts), sdk.ErrAlreadyInitialized)
+
+	r, err := sdk.Open(ctx, opts.ConfigFile, opts.Password)
+	require.NoError(t, err)
+
+	defer r.Close(ctx) //nolint:errcheck
+
+	src := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "sub"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a"), []byte("aaa"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "b"), []byte("bbbbbb"), 0o600))
+
+	progress := &fileCounter{files: map[string]bool{}}
+
+	s1, err := r.SnapshotPath(ctx, src, sdk.SnapshotOptions{
+		Description: "first",
+		Tags:        map[string]string{"env": "test"},
+		Progress:    progress,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "first", s1.Description)
+	require.Equal(t, map[string]string{"env": "test"}, s1.Tags)
+	require.EqualValues(t, 2, s1.TotalFileCount)
+	require.EqualValues(t, 9, s1.TotalFileSize)
+	require.Equal(t, map[string]bool{"a": false, "sub/b": false}, progress.files)
+	require.Zero(t, progress.errors)
+
+	// second snapshot reuses unchanged files.
+	progress.files = map[string]bool{}
+
+	s2, err := r.SnapshotPath(ctx, src, sdk.SnapshotOptions{Progress: progress})
+	require.NoError(t, err)
+	require.Equal(t, s1.RootObjectID, s2.RootObjectID)
+	require.Equal(t, map[string]bool{"a": true, "sub/b": true}, progress.files)
+
+	snapshots, err := r.ListSnapshots(ctx, src)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	require.Equal(t, s1.ID, snapshots[0].ID)
+	require.Equal(t, s2.ID, snapshots[1].ID)
+
+	all, err := r.ListSnapshots(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	target := filepath.Join(testutil.TempDirectory(t), "restored")
+
+	stats, err := r.Restore(ctx, s1.ID, target, sdk.RestoreOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.RestoredFileCount)
+	require.EqualValues(t, 9, stats.RestoredBytes)
+
+	b, err := os.ReadFile(filepath.Join(target, "sub", "b"))
+	require.NoError(t, err)
+	require.Equal(t, "bbbbbb", string(b))
+
+	require.NoError(t, r.Verify(ctx, sdk.VerifyOptions{VerifyFilesPercent: 100}))
+	require.NoError(t, r.RunMaintenance(ctx, sdk.MaintenanceOptions{}))
+
+	require.NoError(t, r.DeleteSnapshot(ctx, s1.ID))
+	require.Error(t, r.DeleteSnapshot(ctx, s1.ID))
+
+	snapshots, err = r.ListSnapshots(ctx, src)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	require.NoError(t, r.Close(ctx))
+	require.NoError(t, sdk.Disconnect(ctx, opts.ConfigFile))
+
+	_, err = sdk.Open(ctx, opts.ConfigFile, opts.Password)
+	require.Error(t, err)
+
+	require.NoError(t, sdk.Connect(ctx, st, opts))
+}
@@ -0,0 +1,261 @@
+package sdk
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const tagKeyPrefix = "tag:"
+
+// Snapshot describes a single snapshot of a local path.
+type Snapshot struct {
+	ID          string    `json:"id"`
+	Hostname    string    `json:"hostname"`
+	Username    string    `json:"username"`
+	Path        string    `json:"path"`
+	Description string    `json:"description,omitempty"`
+	StartTime   time.Time `json:"startTime"`
+	EndTime     time.Time `json:"endTime"`
+
+	// IncompleteReason is non-empty for snapshots that were interrupted before completing.
+	IncompleteReason string `json:"incompleteReason,omitempty"`
+
+	RootObjectID    string            `json:"rootObjectID"`
+	TotalFileSize   int64             `json:"totalFileSize"`
+	TotalFileCount  int64             `json:"totalFileCount"`
+	TotalDirCount   int64             `json:"totalDirCount"`
+	ErrorCount      int               `json:"errorCount"`
+	RetentionReason []string          `json:"retentionReason,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty"`
+}
+
+func snapshotFromManifest(m *snapshot.Manifest) Snapshot {
+	s := Snapshot{
+		ID:               string(m.ID),
+		Hostname:         m.Source.Host,
+		Username:         m.Source.UserName,
+		Path:             m.Source.Path,
+		Description:      m.Description,
+		StartTime:        m.StartTime.ToTime(),
+		EndTime:          m.EndTime.ToTime(),
+		IncompleteReason: m.IncompleteReason,
+		RetentionReason:  m.RetentionReasons,
+	}
+
+	if m.RootEntry != nil {
+		s.RootObjectID = m.RootEntry.ObjectID.String()
+
+		if ds := m.RootEntry.DirSummary; ds != nil {
+			s.TotalFileSize = ds.TotalFileSize
+			s.TotalFileCount = ds.TotalFileCount
+			s.TotalDirCount = ds.TotalDirCount
+			s.ErrorCount = ds.FatalErrorCount
+		}
+	}
+
+	for k, v := range m.Tags {
+		if tag, ok := strings.CutPrefix(k, tagKeyPrefix); ok {
+			if s.Tags == nil {
+				s.Tags = map[string]string{}
+			}
+
+			s.Tags[tag] = v
+		}
+	}
+
+	return s
+}
+
+// SnapshotOptions specifies options for SnapshotPath.
+type SnapshotOptions struct {
+	Description string
+
+	// Tags are arbitrary key-value pairs attached to the snapshot.
+	Tags map[string]string
+
+	// Progress, when set, receives notifications about processed files.
+	Progress SnapshotProgress
+
+	// Parallelism is the number of files uploaded in parallel, defaults to the number of CPUs.
+	Parallelism int
+
+	// ApplyRetention expires old snapshots of the path according to the retention policy
+	// after the snapshot completes.
+	ApplyRetention bool
+}
+
+// SnapshotPath creates a snapshot of the provided local file or directory using the policies
+// defined for it and returns the resulting snapshot.
+func (r *Repository) SnapshotPath(ctx context.Context, path string, opts SnapshotOptions) (Snapshot, error) {
+	si, err := r.sourceInfo(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	entry, err := localfs.NewEntry(si.Path)
+	if err != nil {
+		return Snapshot{}, errors.Wrap(err, "unable to get local filesystem entry")
+	}
+
+	var result Snapshot
+
+	err = repo.WriteSession(ctx, r.rep, repo.WriteSessionOptions{
+		Purpose: "sdk:snapshot " + si.Path,
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		policyTree, err := policy.TreeForSource(ctx, w, si)
+		if err != nil {
+			return errors.Wrap(err, "unable to get policy tree")
+		}
+
+		previous, err := previousSnapshots(ctx, w, si)
+		if err != nil {
+			return err
+		}
+
+		u := snapshotfs.NewUploader(w)
+		u.ParallelUploads = opts.Parallelism
+
+		if opts.Progress != nil {
+			u.Progress = &snapshotProgressAdapter{p: opts.Progress}
+		}
+
+		man, err := u.Upload(ctx, entry, policyTree, si, previous...)
+		if err != nil {
+			return errors.Wrap(err, "upload error")
+		}
+
+		man.Description = opts.Description
+
+		for k, v := range opts.Tags {
+			if man.Tags == nil {
+				man.Tags = map[string]string{}
+			}
+
+			man.Tags[tagKeyPrefix+k] = v
+		}
+
+		if _, err := snapshot.SaveSnapshot(ctx, w, man); err != nil {
+			return errors.Wrap(err, "unable to save snapshot")
+		}
+
+		if opts.ApplyRetention {
+			if _, err := policy.ApplyRetentionPolicy(ctx, w, si, true); err != nil {
+				return errors.Wrap(err, "unable to apply retention policy")
+			}
+		}
+
+		result = snapshotFromManifest(man)
+
+		return nil
+	})
+
+	return result, errors.Wrap(err, "snapshot failed")
+}
+
+// ListSnapshots returns snapshots of the provided local path made by this client, or snapshots
+// of all sources when the path is empty, ordered by start time.
+func (r *Repository) ListSnapshots(ctx context.Context, path string) ([]Snapshot, error) {
+	var manifests []*snapshot.Manifest
+
+	if path == "" {
+		ids, err := snapshot.ListSnapshotManifests(ctx, r.rep, nil, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list snapshots")
+		}
+
+		if manifests, err = snapshot.LoadSnapshots(ctx, r.rep, ids); err != nil {
+			return nil, errors.Wrap(err, "unable to load snapshots")
+		}
+	} else {
+		si, err := r.sourceInfo(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if manifests, err = snapshot.ListSnapshots(ctx, r.rep, si); err != nil {
+			return nil, errors.Wrap(err, "unable to list snapshots")
+		}
+	}
+
+	result := make([]Snapshot, 0, len(manifests))
+
+	for _, m := range snapshot.SortByTime(manifests, false) {
+		result = append(result, snapshotFromManifest(m))
+	}
+
+	return result, nil
+}
+
+// DeleteSnapshot deletes the snapshot with the provided ID.
+func (r *Repository) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if _, err := r.loadSnapshot(ctx, snapshotID); err != nil {
+		return err
+	}
+
+	return errors.Wrap(repo.WriteSession(ctx, r.rep, repo.WriteSessionOptions{
+		Purpose: "sdk:delete snapshot",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		//nolint:wrapcheck
+		return w.DeleteManifest(ctx, manifest.ID(snapshotID))
+	}), "unable to delete snapshot")
+}
+
+func (r *Repository) loadSnapshot(ctx context.Context, snapshotID string) (*snapshot.Manifest, error) {
+	m, err := snapshot.LoadSnapshot(ctx, r.rep, manifest.ID(snapshotID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to load snapshot %v", snapshotID)
+	}
+
+	return m, nil
+}
+
+func (r *Repository) sourceInfo(path string) (snapshot.SourceInfo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return snapshot.SourceInfo{}, errors.Wrap(err, "unable to determine absolute path")
+	}
+
+	return snapshot.SourceInfo{
+		Host:     r.Hostname(),
+		UserName: r.Username(),
+		Path:     filepath.Clean(abs),
+	}, nil
+}
+
+// previousSnapshots returns the latest complete snapshot of the source followed by
+// incomplete snapshots made after it.
+func previousSnapshots(ctx context.Context, rep repo.Repository, si snapshot.SourceInfo) ([]*snapshot.Manifest, error) {
+	all, err := snapshot.ListSnapshots(ctx, rep, si)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing previous snapshots")
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].StartTime.After(all[j].StartTime)
+	})
+
+	var result []*snapshot.Manifest
+
+	for _, m := range all {
+		if m.IncompleteReason == "" {
+			// previous complete snapshot goes first.
+			return append([]*snapshot.Manifest{m}, result...), nil
+		}
+
+		result = append(result, m)
+	}
+
+	return result, nil
+}
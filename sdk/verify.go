@@ -0,0 +1,81 @@
+package sdk
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// VerifyOptions specifies options for Verify.
+type VerifyOptions struct {
+	// Snapshots is the list of IDs of snapshots to verify, all snapshots are verified when empty.
+	Snapshots []string
+
+	// VerifyFilesPercent is the percentage of files whose contents are fully read, 0 only verifies
+	// that contents of all files exist.
+	VerifyFilesPercent float64
+
+	// Parallelism is the number of files verified in parallel, defaults to the number of CPUs.
+	Parallelism int
+
+	// MaxErrors stops the verification after the provided number of errors, 0 means unlimited.
+	MaxErrors int
+}
+
+// Verify verifies that all objects referenced by the snapshots exist in the repository
+// and optionally reads contents of a percentage of files.
+func (r *Repository) Verify(ctx context.Context, opts VerifyOptions) error {
+	ids := opts.Snapshots
+
+	if len(ids) == 0 {
+		all, err := r.ListSnapshots(ctx, "")
+		if err != nil {
+			return err
+		}
+
+		for _, s := range all {
+			ids = append(ids, s.ID)
+		}
+	}
+
+	v := snapshotfs.NewVerifier(ctx, r.rep, snapshotfs.VerifierOptions{
+		VerifyFilesPercent: opts.VerifyFilesPercent,
+		Parallelism:        opts.Parallelism,
+		MaxErrors:          opts.MaxErrors,
+	})
+
+	var enqueueErr error
+
+	err := v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
+		for _, id := range ids {
+			m, err := r.loadSnapshot(ctx, id)
+			if err != nil {
+				enqueueErr = err
+				return err
+			}
+
+			if m.RootEntry == nil {
+				continue
+			}
+
+			root, err := snapshotfs.SnapshotRoot(r.rep, m)
+			if err != nil {
+				enqueueErr = errors.Wrapf(err, "unable to get root of snapshot %v", id)
+				return enqueueErr
+			}
+
+			// errors are reported by the tree walker.
+			tw.Process(ctx, root, m.Source.Path) //nolint:errcheck
+		}
+
+		return nil
+	})
+
+	if err != nil && enqueueErr == nil {
+		return errors.Wrap(err, "verification failed")
+	}
+
+	return err
+}
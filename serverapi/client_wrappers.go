@@ -0,0 +1,1109 @@
+package serverapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/enrollment"
+	"github.com/kopia/kopia/internal/remoterestore"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// CreateSnapshotSource creates snapshot source with a given path.
+func CreateSnapshotSource(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateSnapshotSourceRequest) (*CreateSnapshotSourceResponse, error) {
+	resp := &CreateSnapshotSourceResponse{}
+	if err := c.Post(ctx, "sources", req, resp); err != nil {
+		return nil, errors.Wrap(err, "CreateSnapshotSource")
+	}
+
+	return resp, nil
+}
+
+// Estimate starts snapshot estimation task for a given directory.
+func Estimate(ctx context.Context, c *apiclient.KopiaAPIClient, req *EstimateRequest) (*uitask.Info, error) {
+	resp := &uitask.Info{}
+	if err := c.Post(ctx, "estimate", req, resp); err != nil {
+		return nil, errors.Wrap(err, "Estimate")
+	}
+
+	return resp, nil
+}
+
+// Restore starts snapshot restore task for a given directory.
+func Restore(ctx context.Context, c *apiclient.KopiaAPIClient, req *RestoreRequest) (*uitask.Info, error) {
+	resp := &uitask.Info{}
+	if err := c.Post(ctx, "restore", req, resp); err != nil {
+		return nil, errors.Wrap(err, "Restore")
+	}
+
+	return resp, nil
+}
+
+// GetTask starts snapshot estimation task for a given directory.
+func GetTask(ctx context.Context, c *apiclient.KopiaAPIClient, taskID string) (*uitask.Info, error) {
+	resp := &uitask.Info{}
+	if err := c.Get(ctx, "tasks/"+taskID, nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetTask")
+	}
+
+	return resp, nil
+}
+
+// UploadSnapshots triggers snapshot upload on matching snapshots.
+func UploadSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*MultipleSourceActionResponse, error) {
+	resp := &MultipleSourceActionResponse{}
+	if err := c.Post(ctx, "sources/upload"+matchSourceParameters(match), &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "UploadSnapshots")
+	}
+
+	return resp, nil
+}
+
+// SnapshotSource triggers immediate snapshot of a single source and returns the ID of the snapshot task.
+func SnapshotSource(ctx context.Context, c *apiclient.KopiaAPIClient, req *SnapshotSourceRequest) (*SnapshotSourceResponse, error) {
+	resp := &SnapshotSourceResponse{}
+	if err := c.Post(ctx, "sources/snapshot", req, resp); err != nil {
+		return nil, errors.Wrap(err, "SnapshotSource")
+	}
+
+	return resp, nil
+}
+
+// CancelUpload cancels snapshot upload on matching snapshots.
+func CancelUpload(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*MultipleSourceActionResponse, error) {
+	resp := &MultipleSourceActionResponse{}
+	if err := c.Post(ctx, "sources/cancel"+matchSourceParameters(match), &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "CancelUpload")
+	}
+
+	return resp, nil
+}
+
+// CreateRepository invokes the 'repo/create' API.
+func CreateRepository(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateRepositoryRequest) error {
+	//nolint:wrapcheck
+	return c.Post(ctx, "repo/create", req, &StatusResponse{})
+}
+
+// ConnectToRepository invokes the 'repo/connect' API.
+func ConnectToRepository(ctx context.Context, c *apiclient.KopiaAPIClient, req *ConnectRepositoryRequest) error {
+	//nolint:wrapcheck
+	return c.Post(ctx, "repo/connect", req, &StatusResponse{})
+}
+
+// DisconnectFromRepository invokes the 'repo/disconnect' API.
+func DisconnectFromRepository(ctx context.Context, c *apiclient.KopiaAPIClient) error {
+	//nolint:wrapcheck
+	return c.Post(ctx, "repo/disconnect", &Empty{}, &Empty{})
+}
+
+// Shutdown invokes the 'control/shutdown' API.
+func Shutdown(ctx context.Context, c *apiclient.KopiaAPIClient) error {
+	//nolint:wrapcheck
+	return c.Post(ctx, "control/shutdown", &Empty{}, &Empty{})
+}
+
+// RepoStatus invokes the 'repo/status' API.
+func RepoStatus(ctx context.Context, c *apiclient.KopiaAPIClient) (*StatusResponse, error) {
+	resp := &StatusResponse{}
+	if err := c.Get(ctx, "repo/status", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "Status")
+	}
+
+	return resp, nil
+}
+
+// Status invokes the 'control/status' API.
+func Status(ctx context.Context, c *apiclient.KopiaAPIClient) (*StatusResponse, error) {
+	resp := &StatusResponse{}
+	if err := c.Get(ctx, "control/status", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "Status")
+	}
+
+	return resp, nil
+}
+
+// GetThrottlingLimits gets the throttling limits.
+func GetThrottlingLimits(ctx context.Context, c *apiclient.KopiaAPIClient) (throttling.Limits, error) {
+	resp := throttling.Limits{}
+	if err := c.Get(ctx, "repo/throttle", nil, &resp); err != nil {
+		return throttling.Limits{}, errors.Wrap(err, "throttling")
+	}
+
+	return resp, nil
+}
+
+// SetThrottlingLimits sets the throttling limits.
+func SetThrottlingLimits(ctx context.Context, c *apiclient.KopiaAPIClient, l throttling.Limits) error {
+	if err := c.Put(ctx, "repo/throttle", &l, &Empty{}); err != nil {
+		return errors.Wrap(err, "throttling")
+	}
+
+	return nil
+}
+
+// ListSources lists the snapshot sources managed by the server.
+func ListSources(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*SourcesResponse, error) {
+	resp := &SourcesResponse{}
+	if err := c.Get(ctx, "sources"+matchSourceParameters(match), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListSources")
+	}
+
+	return resp, nil
+}
+
+// ListSnapshots lists the snapshots managed by the server for a given source source.
+func ListSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, src snapshot.SourceInfo, all bool) (*SnapshotsResponse, error) {
+	resp := &SnapshotsResponse{}
+
+	u := "snapshots" + matchSourceParameters(&src)
+	if all {
+		u += "&all=1"
+	}
+
+	if err := c.Get(ctx, u, nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListSnapshots")
+	}
+
+	return resp, nil
+}
+
+// ListSourcesPage lists a page of snapshot sources managed by the server matching the provided source and list options.
+func ListSourcesPage(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo, opt ListOptions) (*SourcesResponse, error) {
+	q := sourceQuery(match)
+	opt.addTo(q)
+
+	resp := &SourcesResponse{}
+	if err := c.Get(ctx, "sources?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListSourcesPage")
+	}
+
+	return resp, nil
+}
+
+// ListSnapshotsPage lists a page of snapshots matching the provided source and list options.
+// Empty fields of the source match all hosts, users or paths.
+func ListSnapshotsPage(ctx context.Context, c *apiclient.KopiaAPIClient, src snapshot.SourceInfo, all bool, opt ListOptions) (*SnapshotsResponse, error) {
+	q := sourceQuery(&src)
+	opt.addTo(q)
+
+	if all {
+		q.Set("all", "1")
+	}
+
+	resp := &SnapshotsResponse{}
+	if err := c.Get(ctx, "snapshots?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListSnapshotsPage")
+	}
+
+	return resp, nil
+}
+
+// ListFleet returns the inventory of all sources known to the server and their health.
+func ListFleet(ctx context.Context, c *apiclient.KopiaAPIClient) (*FleetResponse, error) {
+	resp := &FleetResponse{}
+	if err := c.Get(ctx, "control/fleet", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListFleet")
+	}
+
+	return resp, nil
+}
+
+// GetFleetHealth returns the summary of health of all sources known to the server.
+func GetFleetHealth(ctx context.Context, c *apiclient.KopiaAPIClient) (*FleetHealthSummary, error) {
+	resp := &FleetHealthSummary{}
+	if err := c.Get(ctx, "control/fleet/health", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetFleetHealth")
+	}
+
+	return resp, nil
+}
+
+// ListQuotaUsage returns quotas and resource usage of all users seen by the server.
+func ListQuotaUsage(ctx context.Context, c *apiclient.KopiaAPIClient) (*QuotaUsageResponse, error) {
+	resp := &QuotaUsageResponse{}
+	if err := c.Get(ctx, "control/quotas", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListQuotaUsage")
+	}
+
+	return resp, nil
+}
+
+// ResetQuotaUsage resets usage counters of a given user.
+func ResetQuotaUsage(ctx context.Context, c *apiclient.KopiaAPIClient, usernameAtHostname string) error {
+	if err := c.Post(ctx, "control/quotas/reset", &ResetQuotaUsageRequest{Username: usernameAtHostname}, &Empty{}); err != nil {
+		return errors.Wrap(err, "ResetQuotaUsage")
+	}
+
+	return nil
+}
+
+// GetLeaderStatus returns the state of leader election among servers connected to the repository.
+func GetLeaderStatus(ctx context.Context, c *apiclient.KopiaAPIClient) (*LeaderStatusResponse, error) {
+	resp := &LeaderStatusResponse{}
+	if err := c.Get(ctx, "control/leader", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetLeaderStatus")
+	}
+
+	return resp, nil
+}
+
+// AuditLogFilter specifies audit log entries to return.
+type AuditLogFilter struct {
+	Actor  string
+	Action string
+	From   time.Time
+	To     time.Time
+	Limit  int // only return the most recent entries
+}
+
+// ListAuditLog returns audit log entries matching the filter.
+func ListAuditLog(ctx context.Context, c *apiclient.KopiaAPIClient, f AuditLogFilter) (*AuditLogResponse, error) {
+	q := url.Values{}
+
+	if f.Actor != "" {
+		q.Set("actor", f.Actor)
+	}
+
+	if f.Action != "" {
+		q.Set("action", f.Action)
+	}
+
+	ListOptions{Limit: f.Limit, From: f.From, To: f.To}.addTo(q)
+
+	resp := &AuditLogResponse{}
+	if err := c.Get(ctx, "control/audit?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListAuditLog")
+	}
+
+	return resp, nil
+}
+
+// VerifyAuditLog verifies integrity of the audit log.
+func VerifyAuditLog(ctx context.Context, c *apiclient.KopiaAPIClient) (*auditlog.VerificationResult, error) {
+	resp := &auditlog.VerificationResult{}
+	if err := c.Get(ctx, "control/audit/verify", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "VerifyAuditLog")
+	}
+
+	return resp, nil
+}
+
+// GetMaintenanceInfo returns maintenance parameters, schedule and status of the repository the server is connected to.
+func GetMaintenanceInfo(ctx context.Context, c *apiclient.KopiaAPIClient) (*MaintenanceInfoResponse, error) {
+	resp := &MaintenanceInfoResponse{}
+	if err := c.Get(ctx, "control/maintenance", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetMaintenanceInfo")
+	}
+
+	return resp, nil
+}
+
+// SetMaintenance changes maintenance parameters and schedule.
+func SetMaintenance(ctx context.Context, c *apiclient.KopiaAPIClient, req *SetMaintenanceRequest) (*MaintenanceInfoResponse, error) {
+	resp := &MaintenanceInfoResponse{}
+	if err := c.Put(ctx, "control/maintenance", req, resp); err != nil {
+		return nil, errors.Wrap(err, "SetMaintenance")
+	}
+
+	return resp, nil
+}
+
+// SetMaintenanceOwner transfers maintenance ownership to a given user@hostname.
+func SetMaintenanceOwner(ctx context.Context, c *apiclient.KopiaAPIClient, owner string) (*MaintenanceInfoResponse, error) {
+	resp := &MaintenanceInfoResponse{}
+	if err := c.Post(ctx, "control/maintenance/owner", &SetMaintenanceOwnerRequest{Owner: owner}, resp); err != nil {
+		return nil, errors.Wrap(err, "SetMaintenanceOwner")
+	}
+
+	return resp, nil
+}
+
+// RunMaintenance starts maintenance on the server and returns the task running it.
+func RunMaintenance(ctx context.Context, c *apiclient.KopiaAPIClient, req *RunMaintenanceRequest) (*uitask.Info, error) {
+	resp := &uitask.Info{}
+	if err := c.Post(ctx, "control/maintenance/run", req, resp); err != nil {
+		return nil, errors.Wrap(err, "RunMaintenance")
+	}
+
+	return resp, nil
+}
+
+// CancelMaintenance cancels maintenance running on the server.
+func CancelMaintenance(ctx context.Context, c *apiclient.KopiaAPIClient) error {
+	if err := c.Post(ctx, "control/maintenance/cancel", &Empty{}, &Empty{}); err != nil {
+		return errors.Wrap(err, "CancelMaintenance")
+	}
+
+	return nil
+}
+
+// CreateRestoreRequest schedules restore of a snapshot to be executed by a client.
+func CreateRestoreRequest(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateRestoreRequestRequest) (*remoterestore.Request, error) {
+	resp := &remoterestore.Request{}
+	if err := c.Post(ctx, "control/restore-requests", req, resp); err != nil {
+		return nil, errors.Wrap(err, "CreateRestoreRequest")
+	}
+
+	return resp, nil
+}
+
+// ListRestoreRequests lists restore requests for a given client, empty username or hostname matches all.
+func ListRestoreRequests(ctx context.Context, c *apiclient.KopiaAPIClient, username, hostname string) (*RestoreRequestsResponse, error) {
+	uv := make(url.Values)
+
+	if username != "" {
+		uv.Set("username", username)
+	}
+
+	if hostname != "" {
+		uv.Set("hostname", hostname)
+	}
+
+	u := "control/restore-requests"
+	if len(uv) > 0 {
+		u += "?" + uv.Encode()
+	}
+
+	resp := &RestoreRequestsResponse{}
+	if err := c.Get(ctx, u, nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListRestoreRequests")
+	}
+
+	return resp, nil
+}
+
+// GetRestoreRequest returns the restore request with a given ID.
+func GetRestoreRequest(ctx context.Context, c *apiclient.KopiaAPIClient, id string) (*remoterestore.Request, error) {
+	resp := &remoterestore.Request{}
+	if err := c.Get(ctx, "control/restore-requests/"+url.PathEscape(id), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetRestoreRequest")
+	}
+
+	return resp, nil
+}
+
+// CancelRestoreRequest cancels the restore request with a given ID.
+func CancelRestoreRequest(ctx context.Context, c *apiclient.KopiaAPIClient, id string) (*remoterestore.Request, error) {
+	resp := &remoterestore.Request{}
+	if err := c.Post(ctx, "control/restore-requests/"+url.PathEscape(id)+"/cancel", &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "CancelRestoreRequest")
+	}
+
+	return resp, nil
+}
+
+// DeleteRestoreRequest deletes the restore request with a given ID.
+func DeleteRestoreRequest(ctx context.Context, c *apiclient.KopiaAPIClient, id string) error {
+	if err := c.Delete(ctx, "control/restore-requests/"+url.PathEscape(id), nil, nil, &Empty{}); err != nil {
+		return errors.Wrap(err, "DeleteRestoreRequest")
+	}
+
+	return nil
+}
+
+// GetIndexEpochStatus returns the state of the index epoch manager.
+func GetIndexEpochStatus(ctx context.Context, c *apiclient.KopiaAPIClient) (*IndexEpochStatusResponse, error) {
+	resp := &IndexEpochStatusResponse{}
+	if err := c.Get(ctx, "index/epoch", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetIndexEpochStatus")
+	}
+
+	return resp, nil
+}
+
+// AdvanceIndexEpoch forces the write epoch of the index to be advanced.
+func AdvanceIndexEpoch(ctx context.Context, c *apiclient.KopiaAPIClient, req *AdvanceIndexEpochRequest) (*AdvanceIndexEpochResponse, error) {
+	resp := &AdvanceIndexEpochResponse{}
+	if err := c.Post(ctx, "index/epoch/advance", req, resp); err != nil {
+		return nil, errors.Wrap(err, "AdvanceIndexEpoch")
+	}
+
+	return resp, nil
+}
+
+// GetCacheStats returns usage and statistics of local caches of the server.
+func GetCacheStats(ctx context.Context, c *apiclient.KopiaAPIClient) (*CacheStatsResponse, error) {
+	resp := &CacheStatsResponse{}
+	if err := c.Get(ctx, "repo/cache", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetCacheStats")
+	}
+
+	return resp, nil
+}
+
+// GetRepositoryStats returns repository statistics computed during the most recent full maintenance.
+func GetRepositoryStats(ctx context.Context, c *apiclient.KopiaAPIClient) (*RepositoryStatsResponse, error) {
+	resp := &RepositoryStatsResponse{}
+	if err := c.Get(ctx, "repo/stats", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetRepositoryStats")
+	}
+
+	return resp, nil
+}
+
+// GetRepositoryUsage returns storage attributed to user@host pairs during the most recent full maintenance.
+func GetRepositoryUsage(ctx context.Context, c *apiclient.KopiaAPIClient) (*RepositoryUsageResponse, error) {
+	resp := &RepositoryUsageResponse{}
+	if err := c.Get(ctx, "repo/usage", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetRepositoryUsage")
+	}
+
+	return resp, nil
+}
+
+// GetRepositoryDiagnostics returns diagnostic information about the repository connection of the server.
+func GetRepositoryDiagnostics(ctx context.Context, c *apiclient.KopiaAPIClient) (*RepositoryDiagnosticsResponse, error) {
+	resp := &RepositoryDiagnosticsResponse{}
+	if err := c.Get(ctx, "repo/diagnostics", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetRepositoryDiagnostics")
+	}
+
+	return resp, nil
+}
+
+// GetDedupReport returns the report of contents unique to and shared between user@host pairs.
+func GetDedupReport(ctx context.Context, c *apiclient.KopiaAPIClient) (*DedupReportResponse, error) {
+	resp := &DedupReportResponse{}
+	if err := c.Get(ctx, "repo/dedup-report", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetDedupReport")
+	}
+
+	return resp, nil
+}
+
+// GetVerificationStatus returns the status of background verification of repository contents.
+func GetVerificationStatus(ctx context.Context, c *apiclient.KopiaAPIClient) (*VerificationStatusResponse, error) {
+	resp := &VerificationStatusResponse{}
+	if err := c.Get(ctx, "verification", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetVerificationStatus")
+	}
+
+	return resp, nil
+}
+
+// GetReplicationStatus returns the status of replication of repository blobs to secondary storage.
+func GetReplicationStatus(ctx context.Context, c *apiclient.KopiaAPIClient) (*ReplicationStatusResponse, error) {
+	resp := &ReplicationStatusResponse{}
+	if err := c.Get(ctx, "replication", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetReplicationStatus")
+	}
+
+	return resp, nil
+}
+
+// GetRepositoryHistory returns events from the repository event log matching the provided query
+// parameters ('since' and 'until' as RFC3339 timestamps, 'type' and 'max').
+func GetRepositoryHistory(ctx context.Context, c *apiclient.KopiaAPIClient, query url.Values) (*RepositoryHistoryResponse, error) {
+	resp := &RepositoryHistoryResponse{}
+	if err := c.Get(ctx, "repo/history?"+query.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetRepositoryHistory")
+	}
+
+	return resp, nil
+}
+
+// ListACLEntries lists access control list entries.
+func ListACLEntries(ctx context.Context, c *apiclient.KopiaAPIClient) (*ACLListResponse, error) {
+	resp := &ACLListResponse{}
+	if err := c.Get(ctx, "control/acl", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListACLEntries")
+	}
+
+	return resp, nil
+}
+
+// AddACLEntry adds an access control list entry.
+func AddACLEntry(ctx context.Context, c *apiclient.KopiaAPIClient, req *AddACLEntryRequest) (*ACLEntry, error) {
+	resp := &ACLEntry{}
+	if err := c.Post(ctx, "control/acl", req, resp); err != nil {
+		return nil, errors.Wrap(err, "AddACLEntry")
+	}
+
+	return resp, nil
+}
+
+// DeleteACLEntry deletes the access control list entry with a given ID.
+func DeleteACLEntry(ctx context.Context, c *apiclient.KopiaAPIClient, id manifest.ID) error {
+	if err := c.Delete(ctx, "control/acl/"+string(id), nil, nil, &Empty{}); err != nil {
+		return errors.Wrap(err, "DeleteACLEntry")
+	}
+
+	return nil
+}
+
+// ListWebhooks lists the webhooks invoked by the server.
+func ListWebhooks(ctx context.Context, c *apiclient.KopiaAPIClient) (*WebhooksResponse, error) {
+	resp := &WebhooksResponse{}
+	if err := c.Get(ctx, "control/webhooks", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListWebhooks")
+	}
+
+	return resp, nil
+}
+
+// SetWebhook creates or replaces the webhook with a given name.
+func SetWebhook(ctx context.Context, c *apiclient.KopiaAPIClient, wh *webhook.Webhook) error {
+	if err := c.Put(ctx, "control/webhooks/"+url.PathEscape(wh.Name), wh, &Empty{}); err != nil {
+		return errors.Wrap(err, "SetWebhook")
+	}
+
+	return nil
+}
+
+// DeleteWebhook deletes the webhook with a given name.
+func DeleteWebhook(ctx context.Context, c *apiclient.KopiaAPIClient, name string) error {
+	if err := c.Delete(ctx, "control/webhooks/"+url.PathEscape(name), nil, nil, &Empty{}); err != nil {
+		return errors.Wrap(err, "DeleteWebhook")
+	}
+
+	return nil
+}
+
+// TestWebhook sends a test event to the webhook with a given name.
+func TestWebhook(ctx context.Context, c *apiclient.KopiaAPIClient, name string) error {
+	if err := c.Post(ctx, "control/webhooks/"+url.PathEscape(name)+"/test", &Empty{}, &Empty{}); err != nil {
+		return errors.Wrap(err, "TestWebhook")
+	}
+
+	return nil
+}
+
+// ListPolicies lists the policies managed by the server for a given target filter.
+func ListPolicies(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*PoliciesResponse, error) {
+	resp := &PoliciesResponse{}
+	if err := c.Get(ctx, "policies"+matchSourceParameters(match), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListPolicies")
+	}
+
+	return resp, nil
+}
+
+func policyTargetURLParamters(si snapshot.SourceInfo) string {
+	return fmt.Sprintf("userName=%v&host=%v&path=%v", si.UserName, si.Host, si.Path)
+}
+
+// SetPolicy sets the policy.
+func SetPolicy(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo, pol *policy.Policy) error {
+	resp := &Empty{}
+	if err := c.Put(ctx, "policy?"+policyTargetURLParamters(si), pol, resp); err != nil {
+		return errors.Wrap(err, "SetPolicy")
+	}
+
+	return nil
+}
+
+// ResolvePolicy resolves the policy.
+func ResolvePolicy(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo, req *ResolvePolicyRequest) (*ResolvePolicyResponse, error) {
+	resp := &ResolvePolicyResponse{}
+
+	if err := c.Post(ctx, "policy/resolve?"+policyTargetURLParamters(si), req, resp); err != nil {
+		return nil, errors.Wrap(err, "ResolvePolicy")
+	}
+
+	return resp, nil
+}
+
+// ListTasks lists the tasks.
+func ListTasks(ctx context.Context, c *apiclient.KopiaAPIClient) (*TaskListResponse, error) {
+	resp := &TaskListResponse{}
+	if err := c.Get(ctx, "tasks", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListTasks")
+	}
+
+	return resp, nil
+}
+
+// QueryTasks lists the page of tasks matching the provided query.
+func QueryTasks(ctx context.Context, c *apiclient.KopiaAPIClient, q uitask.Query) (*TaskListResponse, error) {
+	v := url.Values{}
+
+	if q.Kind != "" {
+		v.Set("kind", q.Kind)
+	}
+
+	if q.Status != "" {
+		v.Set("status", string(q.Status))
+	}
+
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+
+	resp := &TaskListResponse{}
+	if err := c.Get(ctx, "tasks?"+v.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "QueryTasks")
+	}
+
+	return resp, nil
+}
+
+// WatchEvents subscribes to the server event stream and invokes the provided callback for each event
+// until the context is canceled, the stream ends or the callback returns an error.
+func WatchEvents(ctx context.Context, c *apiclient.KopiaAPIClient, onEvent func(ev *Event) error) error {
+	body, err := c.Stream(ctx, "events")
+	if err != nil {
+		return errors.Wrap(err, "WatchEvents")
+	}
+
+	defer body.Close() //nolint:errcheck
+
+	s := bufio.NewScanner(body)
+
+	for s.Scan() {
+		data, ok := strings.CutPrefix(s.Text(), "data: ")
+		if !ok {
+			// event name, keep-alive comment or separator
+			continue
+		}
+
+		ev := &Event{}
+		if err := json.Unmarshal([]byte(data), ev); err != nil {
+			return errors.Wrap(err, "invalid event")
+		}
+
+		if err := onEvent(ev); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return errors.Wrap(s.Err(), "error reading event stream")
+}
+
+// StreamSnapshot returns a reader of the archive of the snapshot or its subdirectory in a given format ("tar", "tgz" or "zip").
+// The caller must close the returned reader.
+func StreamSnapshot(ctx context.Context, c *apiclient.KopiaAPIClient, snapshotID manifest.ID, relPath, format string) (io.ReadCloser, error) {
+	r, err := c.Stream(ctx, "snapshots/"+url.PathEscape(string(snapshotID))+"/stream?path="+url.QueryEscape(relPath)+"&format="+url.QueryEscape(format))
+	if err != nil {
+		return nil, errors.Wrap(err, "StreamSnapshot")
+	}
+
+	return r, nil
+}
+
+// StreamSnapshotEntries returns a reader of the archive of selected entries in the snapshot in a given format.
+// The caller must close the returned reader.
+func StreamSnapshotEntries(ctx context.Context, c *apiclient.KopiaAPIClient, snapshotID manifest.ID, relPaths []string, format string) (io.ReadCloser, error) {
+	q := url.Values{"path": relPaths, "format": {format}}
+
+	r, err := c.Stream(ctx, "snapshots/"+url.PathEscape(string(snapshotID))+"/stream?"+q.Encode())
+	if err != nil {
+		return nil, errors.Wrap(err, "StreamSnapshotEntries")
+	}
+
+	return r, nil
+}
+
+// BrowseSnapshot returns entries of a directory in the snapshot.
+func BrowseSnapshot(ctx context.Context, c *apiclient.KopiaAPIClient, snapshotID manifest.ID, relPath string) (*SnapshotBrowseResponse, error) {
+	resp := &SnapshotBrowseResponse{}
+	if err := c.Get(ctx, "snapshots/"+url.PathEscape(string(snapshotID))+"/browse?path="+url.QueryEscape(relPath), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "BrowseSnapshot")
+	}
+
+	return resp, nil
+}
+
+// PreviewSnapshotFile returns a reader of the contents of a small text or image file in the snapshot.
+// The caller must close the returned reader.
+func PreviewSnapshotFile(ctx context.Context, c *apiclient.KopiaAPIClient, snapshotID manifest.ID, relPath string) (io.ReadCloser, error) {
+	r, err := c.Stream(ctx, "snapshots/"+url.PathEscape(string(snapshotID))+"/preview?path="+url.QueryEscape(relPath))
+	if err != nil {
+		return nil, errors.Wrap(err, "PreviewSnapshotFile")
+	}
+
+	return r, nil
+}
+
+// GetObject returns the object payload.
+func GetObject(ctx context.Context, c *apiclient.KopiaAPIClient, objectID string) ([]byte, error) {
+	var b []byte
+
+	if err := c.Get(ctx, "objects/"+objectID, object.ErrObjectNotFound, &b); err != nil {
+		return nil, errors.Wrap(err, "GetObject")
+	}
+
+	return b, nil
+}
+
+// PauseSources pauses scheduled snapshots of sources matching the provided filter (nil == all).
+func PauseSources(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*MultipleSourceActionResponse, error) {
+	resp := &MultipleSourceActionResponse{}
+	if err := c.Post(ctx, "control/pause-source"+matchSourceParameters(match), &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "PauseSources")
+	}
+
+	return resp, nil
+}
+
+// ResumeSources resumes scheduled snapshots of sources matching the provided filter (nil == all).
+func ResumeSources(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*MultipleSourceActionResponse, error) {
+	resp := &MultipleSourceActionResponse{}
+	if err := c.Post(ctx, "control/resume-source"+matchSourceParameters(match), &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "ResumeSources")
+	}
+
+	return resp, nil
+}
+
+// Flush flushes pending writes of the server repository.
+func Flush(ctx context.Context, c *apiclient.KopiaAPIClient) error {
+	if err := c.Post(ctx, "control/flush", &Empty{}, &Empty{}); err != nil {
+		return errors.Wrap(err, "Flush")
+	}
+
+	return nil
+}
+
+// Refresh refreshes the server repository, picking up changes made by other clients.
+func Refresh(ctx context.Context, c *apiclient.KopiaAPIClient) error {
+	if err := c.Post(ctx, "control/refresh", &Empty{}, &Empty{}); err != nil {
+		return errors.Wrap(err, "Refresh")
+	}
+
+	return nil
+}
+
+// SyncRepository synchronizes the UI server with the repository.
+func SyncRepository(ctx context.Context, c *apiclient.KopiaAPIClient) error {
+	if err := c.Post(ctx, "repo/sync", &Empty{}, &Empty{}); err != nil {
+		return errors.Wrap(err, "SyncRepository")
+	}
+
+	return nil
+}
+
+// CheckRepositoryExists returns nil if the repository exists in the provided storage.
+func CheckRepositoryExists(ctx context.Context, c *apiclient.KopiaAPIClient, req *CheckRepositoryExistsRequest) error {
+	if err := c.Post(ctx, "repo/exists", req, &Empty{}); err != nil {
+		return errors.Wrap(err, "CheckRepositoryExists")
+	}
+
+	return nil
+}
+
+// SupportedAlgorithms returns algorithms supported by the server when creating repositories.
+func SupportedAlgorithms(ctx context.Context, c *apiclient.KopiaAPIClient) (*SupportedAlgorithmsResponse, error) {
+	resp := &SupportedAlgorithmsResponse{}
+	if err := c.Get(ctx, "repo/algorithms", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "SupportedAlgorithms")
+	}
+
+	return resp, nil
+}
+
+// DeleteSnapshots deletes snapshots of a given source.
+func DeleteSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, req *DeleteSnapshotsRequest) error {
+	if err := c.Post(ctx, "snapshots/delete", req, &Empty{}); err != nil {
+		return errors.Wrap(err, "DeleteSnapshots")
+	}
+
+	return nil
+}
+
+// EditSnapshots changes descriptions and pins of snapshots.
+func EditSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, req *EditSnapshotsRequest) ([]*Snapshot, error) {
+	var resp []*Snapshot
+	if err := c.Post(ctx, "snapshots/edit", req, &resp); err != nil {
+		return nil, errors.Wrap(err, "EditSnapshots")
+	}
+
+	return resp, nil
+}
+
+// GetPolicy returns the policy defined for a given target.
+func GetPolicy(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo) (*policy.Policy, error) {
+	resp := &policy.Policy{}
+	if err := c.Get(ctx, "policy?"+policyTargetURLParamters(si), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetPolicy")
+	}
+
+	return resp, nil
+}
+
+// DeletePolicy deletes the policy defined for a given target.
+func DeletePolicy(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo) error {
+	if err := c.Delete(ctx, "policy?"+policyTargetURLParamters(si), nil, nil, &Empty{}); err != nil {
+		return errors.Wrap(err, "DeletePolicy")
+	}
+
+	return nil
+}
+
+// ResolvePath resolves the provided path on the server to a snapshot source.
+func ResolvePath(ctx context.Context, c *apiclient.KopiaAPIClient, path string) (*ResolvePathResponse, error) {
+	resp := &ResolvePathResponse{}
+	if err := c.Post(ctx, "paths/resolve", &ResolvePathRequest{Path: path}, resp); err != nil {
+		return nil, errors.Wrap(err, "ResolvePath")
+	}
+
+	return resp, nil
+}
+
+// ListMounts lists snapshots mounted by the server.
+func ListMounts(ctx context.Context, c *apiclient.KopiaAPIClient) (*MountedSnapshots, error) {
+	resp := &MountedSnapshots{}
+	if err := c.Get(ctx, "mounts", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListMounts")
+	}
+
+	return resp, nil
+}
+
+// MountSnapshot mounts the directory with a given root object ID.
+func MountSnapshot(ctx context.Context, c *apiclient.KopiaAPIClient, root string) (*MountedSnapshot, error) {
+	resp := &MountedSnapshot{}
+	if err := c.Post(ctx, "mounts", &MountSnapshotRequest{Root: root}, resp); err != nil {
+		return nil, errors.Wrap(err, "MountSnapshot")
+	}
+
+	return resp, nil
+}
+
+// GetMount returns the mount of the directory with a given root object ID.
+func GetMount(ctx context.Context, c *apiclient.KopiaAPIClient, root string) (*MountedSnapshot, error) {
+	resp := &MountedSnapshot{}
+	if err := c.Get(ctx, "mounts/"+root, nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetMount")
+	}
+
+	return resp, nil
+}
+
+// UnmountSnapshot unmounts the directory with a given root object ID.
+func UnmountSnapshot(ctx context.Context, c *apiclient.KopiaAPIClient, root string) error {
+	if err := c.Delete(ctx, "mounts/"+root, nil, nil, &Empty{}); err != nil {
+		return errors.Wrap(err, "UnmountSnapshot")
+	}
+
+	return nil
+}
+
+// CurrentUser returns the user the UI server runs as.
+func CurrentUser(ctx context.Context, c *apiclient.KopiaAPIClient) (*CurrentUserResponse, error) {
+	resp := &CurrentUserResponse{}
+	if err := c.Get(ctx, "current-user", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "CurrentUser")
+	}
+
+	return resp, nil
+}
+
+// GetUIPreferences returns the UI preferences.
+func GetUIPreferences(ctx context.Context, c *apiclient.KopiaAPIClient) (*UIPreferences, error) {
+	resp := &UIPreferences{}
+	if err := c.Get(ctx, "ui-preferences", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetUIPreferences")
+	}
+
+	return resp, nil
+}
+
+// SetUIPreferences sets the UI preferences.
+func SetUIPreferences(ctx context.Context, c *apiclient.KopiaAPIClient, p *UIPreferences) error {
+	if err := c.Put(ctx, "ui-preferences", p, &Empty{}); err != nil {
+		return errors.Wrap(err, "SetUIPreferences")
+	}
+
+	return nil
+}
+
+// GetCLIInfo returns information about the command-line executable used by the server.
+func GetCLIInfo(ctx context.Context, c *apiclient.KopiaAPIClient) (*CLIInfo, error) {
+	resp := &CLIInfo{}
+	if err := c.Get(ctx, "cli", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetCLIInfo")
+	}
+
+	return resp, nil
+}
+
+// GetTaskLogs returns the log of a given task.
+func GetTaskLogs(ctx context.Context, c *apiclient.KopiaAPIClient, taskID string) (*TaskLogResponse, error) {
+	resp := &TaskLogResponse{}
+	if err := c.Get(ctx, "tasks/"+taskID+"/logs", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetTaskLogs")
+	}
+
+	return resp, nil
+}
+
+// CancelTask cancels a given task.
+func CancelTask(ctx context.Context, c *apiclient.KopiaAPIClient, taskID string) error {
+	if err := c.Post(ctx, "tasks/"+taskID+"/cancel", &Empty{}, &Empty{}); err != nil {
+		return errors.Wrap(err, "CancelTask")
+	}
+
+	return nil
+}
+
+// GetLogLevels returns the log levels of the server.
+func GetLogLevels(ctx context.Context, c *apiclient.KopiaAPIClient) (*LogLevels, error) {
+	resp := &LogLevels{}
+	if err := c.Get(ctx, "control/log-levels", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetLogLevels")
+	}
+
+	return resp, nil
+}
+
+// SetLogLevels changes the log levels of the server.
+func SetLogLevels(ctx context.Context, c *apiclient.KopiaAPIClient, req *LogLevels) (*LogLevels, error) {
+	resp := &LogLevels{}
+	if err := c.Put(ctx, "control/log-levels", req, resp); err != nil {
+		return nil, errors.Wrap(err, "SetLogLevels")
+	}
+
+	return resp, nil
+}
+
+func matchSourceParameters(match *snapshot.SourceInfo) string {
+	q := sourceQuery(match)
+	if len(q) == 0 {
+		return ""
+	}
+
+	return "?" + q.Encode()
+}
+
+func sourceQuery(match *snapshot.SourceInfo) url.Values {
+	q := url.Values{}
+
+	if match == nil {
+		return q
+	}
+
+	if v := match.Host; v != "" {
+		q.Set("host", v)
+	}
+
+	if v := match.UserName; v != "" {
+		q.Set("userName", v)
+	}
+
+	if v := match.Path; v != "" {
+		q.Set("path", v)
+	}
+
+	return q
+}
+
+func (o ListOptions) addTo(q url.Values) {
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+
+	if o.Descending {
+		q.Set("order", "desc")
+	}
+
+	if len(o.Fields) > 0 {
+		q.Set("fields", strings.Join(o.Fields, ","))
+	}
+
+	if o.PathGlob != "" {
+		q.Set("pathGlob", o.PathGlob)
+	}
+
+	if !o.From.IsZero() {
+		q.Set("from", o.From.Format(time.RFC3339Nano))
+	}
+
+	if !o.To.IsZero() {
+		q.Set("to", o.To.Format(time.RFC3339Nano))
+	}
+}
+
+// CreateEnrollmentToken creates a one-time token which allows a client to request enrollment.
+func CreateEnrollmentToken(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateEnrollmentTokenRequest) (*CreateEnrollmentTokenResponse, error) {
+	resp := &CreateEnrollmentTokenResponse{}
+	if err := c.Post(ctx, "control/enrollment-tokens", req, resp); err != nil {
+		return nil, errors.Wrap(err, "CreateEnrollmentToken")
+	}
+
+	return resp, nil
+}
+
+// ListEnrollments lists enrollment requests in a given state, empty state matches all.
+func ListEnrollments(ctx context.Context, c *apiclient.KopiaAPIClient, state string) (*EnrollmentRequestsResponse, error) {
+	u := "control/enrollments"
+	if state != "" {
+		u += "?state=" + url.QueryEscape(state)
+	}
+
+	resp := &EnrollmentRequestsResponse{}
+	if err := c.Get(ctx, u, nil, resp); err != nil {
+		return nil, errors.Wrap(err, "ListEnrollments")
+	}
+
+	return resp, nil
+}
+
+// ApproveEnrollment approves the pending enrollment request with a given ID.
+func ApproveEnrollment(ctx context.Context, c *apiclient.KopiaAPIClient, id string, req *ApproveEnrollmentRequest) (*enrollment.Request, error) {
+	resp := &enrollment.Request{}
+	if err := c.Post(ctx, "control/enrollments/"+url.PathEscape(id)+"/approve", req, resp); err != nil {
+		return nil, errors.Wrap(err, "ApproveEnrollment")
+	}
+
+	return resp, nil
+}
+
+// RejectEnrollment rejects the pending enrollment request with a given ID.
+func RejectEnrollment(ctx context.Context, c *apiclient.KopiaAPIClient, id string) (*enrollment.Request, error) {
+	resp := &enrollment.Request{}
+	if err := c.Post(ctx, "control/enrollments/"+url.PathEscape(id)+"/reject", &Empty{}, resp); err != nil {
+		return nil, errors.Wrap(err, "RejectEnrollment")
+	}
+
+	return resp, nil
+}
+
+// Enroll requests enrollment of a client using a one-time token, does not require authentication.
+func Enroll(ctx context.Context, c *apiclient.KopiaAPIClient, req *EnrollRequest) (*EnrollmentStatus, error) {
+	resp := &EnrollmentStatus{}
+	if err := c.Post(ctx, "enroll", req, resp); err != nil {
+		return nil, errors.Wrap(err, "Enroll")
+	}
+
+	return resp, nil
+}
+
+// GetEnrollmentStatus returns the state of the enrollment request with a given ID, does not require authentication.
+func GetEnrollmentStatus(ctx context.Context, c *apiclient.KopiaAPIClient, id string) (*EnrollmentStatus, error) {
+	resp := &EnrollmentStatus{}
+	if err := c.Get(ctx, "enroll/"+url.PathEscape(id), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetEnrollmentStatus")
+	}
+
+	return resp, nil
+}
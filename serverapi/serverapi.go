@@ -0,0 +1,655 @@
+// Package serverapi contains GO types corresponding to Kopia server API.
+package serverapi
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/acl"
+	"github.com/kopia/kopia/internal/auditlog"
+	"github.com/kopia/kopia/internal/bgverify"
+	"github.com/kopia/kopia/internal/enrollment"
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/internal/leaderelection"
+	"github.com/kopia/kopia/internal/remoterestore"
+	"github.com/kopia/kopia/internal/replication"
+	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// StatusResponse is the response of 'status' HTTP API command.
+type StatusResponse struct {
+	Connected                  bool           `json:"connected"`
+	ConfigFile                 string         `json:"configFile,omitempty"`
+	FormatVersion              format.Version `json:"formatVersion,omitempty"`
+	Hash                       string         `json:"hash,omitempty"`
+	Encryption                 string         `json:"encryption,omitempty"`
+	ECC                        string         `json:"ecc,omitempty"`
+	ECCOverheadPercent         int            `json:"eccOverheadPercent,omitempty"`
+	Splitter                   string         `json:"splitter,omitempty"`
+	MaxPackSize                int            `json:"maxPackSize,omitempty"`
+	Storage                    string         `json:"storage,omitempty"`
+	APIServerURL               string         `json:"apiServerURL,omitempty"`
+	SupportsContentCompression bool           `json:"supportsContentCompression"`
+
+	// non-empty when the repository uses in-memory caches because the cache directory is not usable.
+	CacheMemoryFallbackReason string `json:"cacheMemoryFallbackReason,omitempty"`
+
+	repo.ClientOptions
+
+	// non-empty while the repository is being initialized (opened, created or connected).
+	InitRepoTaskID string `json:"initTaskID,omitempty"`
+}
+
+// SourcesResponse is the response of 'sources' HTTP API command.
+type SourcesResponse struct {
+	LocalUsername string `json:"localUsername"`
+	LocalHost     string `json:"localHost"`
+
+	// if set to true, current repository supports accessing data for other users.
+	MultiUser bool `json:"multiUser"`
+
+	Sources []*SourceStatus `json:"sources"`
+
+	// cursor of the next page of results, empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// SourceStatus describes the status of a single source.
+type SourceStatus struct {
+	Source           snapshot.SourceInfo        `json:"source"`
+	Status           string                     `json:"status"`
+	SchedulingPolicy policy.SchedulingPolicy    `json:"schedule"`
+	LastSnapshot     *snapshot.Manifest         `json:"lastSnapshot,omitempty"`
+	NextSnapshotTime *time.Time                 `json:"nextSnapshotTime,omitempty"`
+	UploadCounters   *snapshotfs.UploadCounters `json:"upload,omitempty"`
+	CurrentTask      string                     `json:"currentTask,omitempty"`
+}
+
+// PolicyListEntry describes single policy.
+type PolicyListEntry struct {
+	ID     string              `json:"id"`
+	Target snapshot.SourceInfo `json:"target"`
+	Policy *policy.Policy      `json:"policy"`
+}
+
+// PoliciesResponse is the response of 'policies' HTTP API command.
+type PoliciesResponse struct {
+	Policies []*PolicyListEntry `json:"policies"`
+}
+
+// Empty represents empty request/response.
+type Empty struct{}
+
+// APIErrorCode indicates machine-readable error code returned in API responses.
+type APIErrorCode string
+
+// Supported error codes.
+const (
+	ErrorInternal           APIErrorCode = "INTERNAL"
+	ErrorAlreadyConnected   APIErrorCode = "ALREADY_CONNECTED"
+	ErrorAlreadyInitialized APIErrorCode = "ALREADY_INITIALIZED"
+	ErrorInvalidPassword    APIErrorCode = "INVALID_PASSWORD"
+	ErrorInvalidToken       APIErrorCode = "INVALID_TOKEN"
+	ErrorMalformedRequest   APIErrorCode = "MALFORMED_REQUEST"
+	ErrorNotConnected       APIErrorCode = "NOT_CONNECTED"
+	ErrorNotFound           APIErrorCode = "NOT_FOUND"
+	ErrorNotInitialized     APIErrorCode = "NOT_INITIALIZED"
+	ErrorPathNotFound       APIErrorCode = "PATH_NOT_FOUND"
+	ErrorStorageConnection  APIErrorCode = "STORAGE_CONNECTION"
+	ErrorAccessDenied       APIErrorCode = "ACCESS_DENIED"
+	ErrorSnapshotInProgress APIErrorCode = "SNAPSHOT_IN_PROGRESS"
+)
+
+// ErrorResponse represents error response.
+type ErrorResponse struct {
+	Code  APIErrorCode `json:"code"`
+	Error string       `json:"error"`
+}
+
+// SourceActionResponse is a per-source response.
+type SourceActionResponse struct {
+	Success bool `json:"success"`
+}
+
+// MultipleSourceActionResponse contains per-source responses for all sources targeted by API command.
+type MultipleSourceActionResponse struct {
+	Sources map[string]SourceActionResponse `json:"sources"`
+}
+
+// CreateRepositoryRequest contains request to create a repository in a given storage.
+type CreateRepositoryRequest struct {
+	ConnectRepositoryRequest
+	NewRepositoryOptions repo.NewRepositoryOptions `json:"options"`
+}
+
+// CheckRepositoryExistsRequest returns success if a repository exists in a given storage, ErrorNotInitialized if not.
+type CheckRepositoryExistsRequest struct {
+	Storage blob.ConnectionInfo `json:"storage"`
+}
+
+// ConnectRepositoryRequest contains request to connect to a repository.
+type ConnectRepositoryRequest struct {
+	Storage             blob.ConnectionInfo `json:"storage"`
+	Password            string              `json:"password"`
+	Token               string              `json:"token"` // when set, overrides Storage and Password
+	APIServer           *repo.APIServerInfo `json:"apiServer"`
+	ClientOptions       repo.ClientOptions  `json:"clientOptions"`
+	SyncWaitTimeSeconds int                 `json:"syncWaitTime"` // if non-zero, force particular wait time, negative == forever
+}
+
+// AlgorithmInfo is an information about a single algorithm.
+type AlgorithmInfo struct {
+	ID         string `json:"id"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+// SupportedAlgorithmsResponse returns the list of supported algorithms for repository creation.
+type SupportedAlgorithmsResponse struct {
+	DefaultHashAlgorithm       string `json:"defaultHash"`
+	DefaultEncryptionAlgorithm string `json:"defaultEncryption"`
+	DefaultECCAlgorithm        string `json:"defaultEcc"`
+	DefaultSplitterAlgorithm   string `json:"defaultSplitter"`
+
+	SupportedHashAlgorithms        []AlgorithmInfo `json:"hash"`
+	SupportedEncryptionAlgorithms  []AlgorithmInfo `json:"encryption"`
+	SupportedECCAlgorithms         []AlgorithmInfo `json:"ecc"`
+	SupportedSplitterAlgorithms    []AlgorithmInfo `json:"splitter"`
+	SupportedCompressionAlgorithms []AlgorithmInfo `json:"compression"`
+}
+
+// CreateSnapshotSourceRequest contains request to create snapshot source and optionally create first snapshot.
+type CreateSnapshotSourceRequest struct {
+	Path           string         `json:"path"`
+	CreateSnapshot bool           `json:"createSnapshot"`
+	Policy         *policy.Policy `json:"policy"` // policy to set on the path
+}
+
+// CreateSnapshotSourceResponse contains response of creating snapshot source.
+type CreateSnapshotSourceResponse struct {
+	SnapshotStarted bool `json:"snapshotted"` // whether snapshotting has been started
+}
+
+// SnapshotSourceRequest contains request to immediately snapshot a single source with optional overrides.
+type SnapshotSourceRequest struct {
+	Source      snapshot.SourceInfo `json:"source"`
+	Description string              `json:"description,omitempty"`
+	Tags        map[string]string   `json:"tags,omitempty"` // user-defined tags in <key>:<value> form, without 'tag:' prefix
+
+	// UploadBytesPerSecond temporarily overrides repository upload speed limit while the snapshot is running.
+	UploadBytesPerSecond float64 `json:"uploadBytesPerSecond,omitempty"`
+}
+
+// SnapshotSourceResponse contains the ID of the snapshot task, which can be polled for progress.
+type SnapshotSourceResponse struct {
+	TaskID string `json:"taskID"`
+}
+
+// ACLEntry describes a single access control list entry.
+type ACLEntry struct {
+	ID manifest.ID `json:"id"`
+	*acl.Entry
+}
+
+// ACLListResponse contains a list of access control list entries.
+type ACLListResponse struct {
+	Entries []*ACLEntry `json:"entries"`
+}
+
+// AddACLEntryRequest contains request to add an access control list entry.
+type AddACLEntryRequest struct {
+	Entry     *acl.Entry `json:"entry"`
+	Overwrite bool       `json:"overwrite,omitempty"` // overwrite existing entry with the same user and target
+}
+
+// WebhookInfo describes a webhook, the secret is never returned.
+type WebhookInfo struct {
+	webhook.Webhook
+
+	HasSecret bool `json:"hasSecret"`
+}
+
+// WebhooksResponse contains a list of webhooks.
+type WebhooksResponse struct {
+	Webhooks []*WebhookInfo `json:"webhooks"`
+}
+
+// Snapshot describes single snapshot entry.
+type Snapshot struct {
+	ID               manifest.ID          `json:"id"`
+	Description      string               `json:"description"`
+	StartTime        fs.UTCTimestamp      `json:"startTime"`
+	EndTime          fs.UTCTimestamp      `json:"endTime"`
+	IncompleteReason string               `json:"incomplete,omitempty"`
+	Summary          *fs.DirectorySummary `json:"summary"`
+	RootEntry        string               `json:"rootID"`
+	RetentionReasons []string             `json:"retention"`
+	Pins             []string             `json:"pins"`
+}
+
+// SnapshotsResponse contains a list of snapshots.
+type SnapshotsResponse struct {
+	Snapshots       []*Snapshot `json:"snapshots"`
+	UnfilteredCount int         `json:"unfilteredCount"`
+	UniqueCount     int         `json:"uniqueCount"`
+
+	// cursor of the next page of results, empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// SnapshotBrowseResponse contains entries of a directory in a snapshot.
+type SnapshotBrowseResponse struct {
+	SnapshotID manifest.ID          `json:"snapshotID"`
+	Path       string               `json:"path"`
+	Entries    []*snapshot.DirEntry `json:"entries"`
+}
+
+// MaintenanceInfoResponse contains repository maintenance parameters, schedule and status.
+type MaintenanceInfoResponse struct {
+	Params   maintenance.Params   `json:"params"`
+	Schedule maintenance.Schedule `json:"schedule"`
+
+	// user@hostname the server is connected to the repository as.
+	ServerUser string `json:"serverUser"`
+
+	// true if the server is the maintenance owner and runs maintenance periodically.
+	OwnedByServer bool `json:"ownedByServer"`
+
+	NextRunTime *time.Time   `json:"nextRunTime,omitempty"`
+	RunningTask *uitask.Info `json:"runningTask,omitempty"`
+}
+
+// SetMaintenanceRequest contains changes to maintenance parameters and schedule, nil fields are left unchanged.
+type SetMaintenanceRequest struct {
+	QuickCycle        *maintenance.CycleParams         `json:"quick,omitempty"`
+	FullCycle         *maintenance.CycleParams         `json:"full,omitempty"`
+	LogRetention      *maintenance.LogRetentionOptions `json:"logRetention,omitempty"`
+	ExtendObjectLocks *bool                            `json:"extendObjectLocks,omitempty"`
+	ListParallelism   *int                             `json:"listParallelism,omitempty"`
+
+	// setting next maintenance times in the future pauses maintenance until then.
+	NextQuickMaintenanceTime *time.Time `json:"nextQuickMaintenance,omitempty"`
+	NextFullMaintenanceTime  *time.Time `json:"nextFullMaintenance,omitempty"`
+}
+
+// SetMaintenanceOwnerRequest transfers maintenance ownership to a given user@hostname,
+// empty or "me" transfers it to the user of the server.
+type SetMaintenanceOwnerRequest struct {
+	Owner string `json:"owner"`
+}
+
+// RunMaintenanceRequest requests maintenance to be run by the server.
+type RunMaintenanceRequest struct {
+	Mode  string `json:"mode"`  // "auto" (default), "quick" or "full"
+	Force bool   `json:"force"` // run even if the server is not the maintenance owner
+}
+
+// IndexEpochStatusResponse contains the state of the index epoch manager.
+type IndexEpochStatusResponse struct {
+	epoch.Status
+}
+
+// AdvanceIndexEpochRequest requests the write epoch of the index to be advanced.
+type AdvanceIndexEpochRequest struct {
+	Compact bool `json:"compact"` // also compact all settled epochs
+}
+
+// AdvanceIndexEpochResponse is the result of advancing the write epoch of the index.
+type AdvanceIndexEpochResponse struct {
+	WriteEpoch      int `json:"writeEpoch"`
+	CompactedEpochs int `json:"compactedEpochs"`
+}
+
+// CacheStatsResponse contains usage and statistics of local caches of the repository connection.
+type CacheStatsResponse struct {
+	content.CacheStats
+}
+
+// RepositoryStatsResponse contains repository statistics computed during the most recent full maintenance.
+type RepositoryStatsResponse struct {
+	maintenance.RepositoryStats
+}
+
+// RepositoryUsageResponse attributes storage used by snapshots to user@host pairs, as computed during
+// the most recent full maintenance.
+type RepositoryUsageResponse struct {
+	maintenance.UsageStats
+}
+
+// RepositoryDiagnosticsResponse describes the repository connection of the server, to be attached to support requests.
+type RepositoryDiagnosticsResponse struct {
+	repo.ConnectionDiagnostics
+}
+
+// DedupReportResponse attributes contents referenced by snapshots to user@host pairs.
+type DedupReportResponse struct {
+	snapshotfs.DedupReport
+}
+
+// VerificationStatusResponse contains the status of continuous background verification of repository contents.
+type VerificationStatusResponse struct {
+	bgverify.Status
+}
+
+// ReplicationStatusResponse contains the status of continuous replication of repository blobs to secondary storage.
+type ReplicationStatusResponse struct {
+	replication.Status
+}
+
+// RepositoryHistoryResponse contains events from the repository event log, ordered by time.
+type RepositoryHistoryResponse struct {
+	Events []*eventlog.Event `json:"events"`
+}
+
+// ListOptions contains pagination, filtering and field selection options of sources and snapshots listings.
+type ListOptions struct {
+	Limit      int       // maximum number of items to return, 0 == all
+	Cursor     string    // NextCursor returned with the previous page
+	Descending bool      // return items in descending order
+	Fields     []string  // JSON fields of items to return, empty == all
+	PathGlob   string    // only return items whose path matches the glob
+	From       time.Time // only return items with time at or after this time
+	To         time.Time // only return items with time before this time
+}
+
+// DeleteSnapshotsRequest contains request to delete a number of snapshots and optionally the
+// entire snapshot source.
+type DeleteSnapshotsRequest struct {
+	SourceInfo            snapshot.SourceInfo `json:"source"`
+	SnapshotManifestIDs   []manifest.ID       `json:"snapshotManifestIds"`
+	DeleteSourceAndPolicy bool                `json:"deleteSourceAndPolicy"`
+}
+
+// EditSnapshotsRequest contains request to edit one or more snapshots.
+type EditSnapshotsRequest struct {
+	Snapshots      []manifest.ID `json:"snapshots"`
+	NewDescription *string       `json:"description"`
+	AddPins        []string      `json:"addPins"`
+	RemovePins     []string      `json:"removePins"`
+}
+
+// MountSnapshotRequest contains request to mount a snapshot.
+type MountSnapshotRequest struct {
+	Root string `json:"root"`
+}
+
+// UnmountSnapshotRequest contains request to unmount a snapshot.
+type UnmountSnapshotRequest struct {
+	Root string `json:"root"`
+}
+
+// MountedSnapshot describes single mounted snapshot.
+type MountedSnapshot struct {
+	Path string    `json:"path"`
+	Root object.ID `json:"root"`
+}
+
+// MountedSnapshots describes single mounted snapshot.
+type MountedSnapshots struct {
+	Items []*MountedSnapshot `json:"items"`
+}
+
+// CurrentUserResponse is the response of 'current-user' HTTP API command.
+type CurrentUserResponse struct {
+	Username string `json:"username"`
+	Hostname string `json:"hostname"`
+}
+
+// TaskListResponse contains a list of tasks.
+type TaskListResponse struct {
+	Tasks      []uitask.Info `json:"tasks"`
+	TotalCount int           `json:"totalCount"` // number of tasks matching the filter, before pagination
+}
+
+// Supported types of server events.
+const (
+	EventTaskStarted      = "task-started"
+	EventTaskProgress     = "task-progress"
+	EventTaskFinished     = "task-finished"
+	EventSnapshotStarted  = "snapshot-started"
+	EventSnapshotFinished = "snapshot-finished"
+	EventMaintenancePhase = "maintenance-phase"
+	EventError            = "error"
+)
+
+// Event is a single server event delivered over the event stream.
+type Event struct {
+	Type   string               `json:"type"`
+	Time   time.Time            `json:"time"`
+	Task   *uitask.Info         `json:"task,omitempty"`
+	Source *snapshot.SourceInfo `json:"source,omitempty"`
+
+	// SnapshotID is the ID of the snapshot manifest written by the finished snapshot.
+	SnapshotID manifest.ID `json:"snapshotID,omitempty"`
+
+	// Phase is the maintenance task that has started or finished.
+	Phase         string `json:"phase,omitempty"`
+	PhaseFinished bool   `json:"phaseFinished,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// TaskLogResponse contains a task log.
+type TaskLogResponse struct {
+	Logs []json.RawMessage `json:"logs"` // formatted as uitask.LogEntry
+}
+
+// RestoreRequest contains request to restore an object (file or directory) to a given destination.
+type RestoreRequest struct {
+	Root string `json:"root"`
+
+	Filesystem *restore.FilesystemOutput `json:"fsOutput"`
+
+	ZipFile         string `json:"zipFile"`
+	UncompressedZip bool   `json:"uncompressedZip"`
+
+	TarFile string          `json:"tarFile"`
+	Options restore.Options `json:"options"`
+}
+
+// EstimateRequest contains request to estimate the size of the snapshot in a given root.
+type EstimateRequest struct {
+	Root                 string         `json:"root"`
+	MaxExamplesPerBucket int            `json:"maxExamplesPerBucket"`
+	PolicyOverride       *policy.Policy `json:"policyOverride"`
+}
+
+// ResolvePolicyRequest contains request structure to ResolvePolicy.
+type ResolvePolicyRequest struct {
+	Updates                  *policy.Policy `json:"updates"`
+	NumUpcomingSnapshotTimes int            `json:"numUpcomingSnapshotTimes"` // if > 0, return N UpcomingSnapshotTimes
+}
+
+// ResolvePolicyResponse returns the resolved details about a single policy.
+type ResolvePolicyResponse struct {
+	Effective             *policy.Policy     `json:"effective"`
+	Definition            *policy.Definition `json:"definition"`
+	Defined               *policy.Policy     `json:"defined"`
+	UpcomingSnapshotTimes []time.Time        `json:"upcomingSnapshotTimes"`
+	SchedulingError       string             `json:"schedulingError,omitempty"`
+}
+
+// ResolvePathRequest contains request to resolve a particular path to ResolvePathResponse.
+type ResolvePathRequest struct {
+	Path string `json:"path"`
+}
+
+// ResolvePathResponse contains response to resolve a particular path.
+type ResolvePathResponse struct {
+	SourceInfo snapshot.SourceInfo `json:"source"`
+}
+
+// CLIInfo contains CLI information.
+type CLIInfo struct {
+	Executable string `json:"executable"`
+}
+
+// UIPreferences represents JSON object storing UI preferences.
+type UIPreferences struct {
+	BytesStringBase2       bool   `json:"bytesStringBase2"`       // If `true`, display storage values in base-2 (default is base-10)
+	DefaultSnapshotViewAll bool   `json:"defaultSnapshotViewAll"` // If `true` default to showing all snapshots (default is local snapshots)
+	Theme                  string `json:"theme"`                  // Specifies the theme used by the UI
+	FontSize               string `json:"fontSize"`               // Specifies the font size used by the UI
+	PageSize               int    `json:"pageSize"`               // A page size; the actual possible values will only be provided by the frontend
+	Language               string `json:"language"`               // Specifies the language used by the UI
+}
+
+// LogLevels contains per-subsystem log level overrides.
+type LogLevels struct {
+	// Subsystems that can have their log levels adjusted.
+	Subsystems []string `json:"subsystems,omitempty"`
+
+	// Overrides maps subsystem name to log level name (debug, info, warning, error).
+	// When setting levels, an empty level removes the override.
+	Overrides map[string]string `json:"overrides"`
+}
+
+// Health states of sources reported by the fleet inventory.
+const (
+	FleetHealthOK       = "ok"       // last snapshot succeeded and the source is on schedule
+	FleetHealthWarning  = "warning"  // last snapshot was incomplete or had errors or the source is overdue
+	FleetHealthCritical = "critical" // the source has no successful snapshots
+)
+
+// FleetSource describes a snapshot source known to the server along with the state of its client.
+type FleetSource struct {
+	Source snapshot.SourceInfo `json:"source"`
+
+	// information about the client, only available for clients that connected since the server started
+	// and for sources snapshotted by the server itself.
+	LastSeen       *time.Time `json:"lastSeen,omitempty"`
+	ClientVersion  string     `json:"clientVersion,omitempty"`
+	RemoteAddress  string     `json:"remoteAddress,omitempty"`
+	ActiveSessions int        `json:"activeSessions"`
+	ServerManaged  bool       `json:"serverManaged"` // true if the server itself snapshots the source
+
+	LastSnapshotTime           *time.Time `json:"lastSnapshotTime,omitempty"`
+	LastSnapshotIncomplete     string     `json:"lastSnapshotIncomplete,omitempty"`
+	LastSnapshotErrorCount     int        `json:"lastSnapshotErrorCount"`
+	LastSuccessfulSnapshotTime *time.Time `json:"lastSuccessfulSnapshotTime,omitempty"`
+
+	// schedule adherence based on the effective scheduling policy
+	ExpectedSnapshotTime *time.Time `json:"expectedSnapshotTime,omitempty"`
+	Overdue              bool       `json:"overdue"`
+
+	Health string `json:"health"`
+}
+
+// FleetHealthSummary contains the rollup of health of all sources.
+type FleetHealthSummary struct {
+	TotalSources   int            `json:"totalSources"`
+	Healthy        int            `json:"healthy"`
+	Warning        int            `json:"warning"`
+	Critical       int            `json:"critical"`
+	Overdue        int            `json:"overdue"`
+	ActiveClients  int            `json:"activeClients"`
+	KnownClients   int            `json:"knownClients"`
+	ClientVersions map[string]int `json:"clientVersions"` // number of clients by reported version
+}
+
+// FleetResponse contains the inventory of all sources known to the server.
+type FleetResponse struct {
+	Sources []*FleetSource     `json:"sources"`
+	Summary FleetHealthSummary `json:"summary"`
+}
+
+// UserQuotaUsage describes quotas and resource usage of a repository user.
+type UserQuotaUsage struct {
+	Username             string  `json:"username"` // user@host
+	MaxRequestsPerSecond float64 `json:"maxRequestsPerSecond,omitempty"`
+	MaxStorageBytes      int64   `json:"maxStorageBytes,omitempty"`
+	Requests             int64   `json:"requests"`
+	ThrottledRequests    int64   `json:"throttledRequests"`
+	RejectedWrites       int64   `json:"rejectedWrites"`
+	StorageBytes         int64   `json:"storageBytes"`
+}
+
+// QuotaUsageResponse contains quotas and resource usage of all users seen by the server.
+type QuotaUsageResponse struct {
+	Users []*UserQuotaUsage `json:"users"`
+}
+
+// ResetQuotaUsageRequest contains request to reset usage counters of a user.
+type ResetQuotaUsageRequest struct {
+	Username string `json:"username"` // user@host
+}
+
+// AuditLogResponse contains audit log entries ordered by sequence.
+type AuditLogResponse struct {
+	Enabled bool              `json:"enabled"`
+	Entries []*auditlog.Entry `json:"entries"`
+}
+
+// LeaderStatusResponse describes the state of leader election among servers connected to the repository.
+type LeaderStatusResponse struct {
+	HighAvailability bool                    `json:"highAvailability"`
+	InstanceID       string                  `json:"instanceID,omitempty"`
+	IsLeader         bool                    `json:"isLeader"`
+	Leader           *leaderelection.Lease   `json:"leader,omitempty"`
+	Leases           []*leaderelection.Lease `json:"leases,omitempty"`
+}
+
+// CreateRestoreRequestRequest contains request to schedule restore of a snapshot on a client.
+type CreateRestoreRequestRequest struct {
+	Username   string                   `json:"username"`
+	Hostname   string                   `json:"hostname"`
+	SnapshotID manifest.ID              `json:"snapshotID"`
+	Path       string                   `json:"path,omitempty"`
+	Output     restore.FilesystemOutput `json:"output"`
+	Options    restore.Options          `json:"options"`
+}
+
+// RestoreRequestsResponse contains a list of restore requests.
+type RestoreRequestsResponse struct {
+	Requests []*remoterestore.Request `json:"requests"`
+}
+
+// CreateEnrollmentTokenRequest contains request to create a one-time enrollment token.
+type CreateEnrollmentTokenRequest struct {
+	Description string        `json:"description,omitempty"`
+	Validity    time.Duration `json:"validity"`
+}
+
+// CreateEnrollmentTokenResponse contains the newly created enrollment token, which is only returned once.
+type CreateEnrollmentTokenResponse struct {
+	Token      string    `json:"token"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+// EnrollRequest contains request of a client to enroll as a user of the repository.
+type EnrollRequest struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// EnrollmentStatus describes the state of an enrollment request, as seen by the enrolling client.
+type EnrollmentStatus struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	State    string `json:"state"`
+}
+
+// EnrollmentRequestsResponse contains a list of enrollment requests.
+type EnrollmentRequestsResponse struct {
+	Requests []*enrollment.Request `json:"requests"`
+}
+
+// ApproveEnrollmentRequest contains request to approve a pending enrollment, optionally granting
+// additional access to the new user. ACL entries without a user apply to the enrolled user.
+type ApproveEnrollmentRequest struct {
+	ACL []*acl.Entry `json:"acl,omitempty"`
+}
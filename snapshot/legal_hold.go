@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// ErrLegalHold is returned when attempting to delete a snapshot which is under legal hold.
+var ErrLegalHold = errors.New("snapshot is under legal hold")
+
+// LegalHoldPeriod returns the duration after their start time during which snapshots are under legal hold.
+//
+// When legal hold is enabled in maintenance parameters of a repository with object locks, snapshots are held
+// for the object lock retention period, which guarantees that their data remains immutable for as long as
+// they are kept. Returns 0 when legal hold is not enabled or can't be determined by this client.
+func LegalHoldPeriod(ctx context.Context, rep repo.Repository) (time.Duration, error) {
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		// legal hold is enforced by the repository server when deleting snapshot manifests.
+		return 0, nil
+	}
+
+	p, err := maintenance.GetParams(ctx, rep)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to get maintenance parameters")
+	}
+
+	if !p.SnapshotLegalHold {
+		return 0, nil
+	}
+
+	blobCfg, err := dr.FormatManager().BlobCfgBlob(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "blob configuration")
+	}
+
+	if !blobCfg.IsRetentionEnabled() {
+		return 0, nil
+	}
+
+	return blobCfg.RetentionPeriod, nil
+}
+
+// LegalHoldUntil returns the time until which the snapshot is held given the legal hold period.
+func (m *Manifest) LegalHoldUntil(period time.Duration) time.Time {
+	if period <= 0 {
+		return time.Time{}
+	}
+
+	return m.StartTime.ToTime().Add(period)
+}
+
+// CheckLegalHold returns ErrLegalHold if the snapshot with a given ID can't be deleted due to legal hold.
+func CheckLegalHold(ctx context.Context, rep repo.Repository, id manifest.ID) error {
+	period, err := LegalHoldPeriod(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if period == 0 {
+		return nil
+	}
+
+	m, err := LoadSnapshot(ctx, rep, id)
+	if err != nil {
+		return errors.Wrapf(err, "error loading snapshot %v", id)
+	}
+
+	if until := m.LegalHoldUntil(period); rep.Time().Before(until) {
+		return errors.Wrapf(ErrLegalHold, "snapshot %v is held until %v", id, until.Format(time.RFC3339))
+	}
+
+	return nil
+}
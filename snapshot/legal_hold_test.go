@@ -0,0 +1,97 @@
+package snapshot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestLegalHold(t *testing.T) {
+	const period = 48 * time.Hour
+
+	ta := faketime.NewClockTimeWithOffset(0)
+
+	ctx, env := repotesting.NewEnvironment(t, format.FormatVersion3, repotesting.Options{
+		OpenOptions: func(o *repo.Options) {
+			o.TimeNowFunc = ta.NowFunc()
+		},
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.RetentionMode = blob.Governance
+			nro.RetentionPeriod = period
+		},
+	})
+
+	rw := env.RepositoryWriter
+	src := snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/some/path"}
+
+	saveSnapshot := func() *snapshot.Manifest {
+		m := &snapshot.Manifest{Source: src, StartTime: fs.UTCTimestampFromTime(ta.NowFunc()())}
+		mustSaveSnapshot(t, rw, m)
+
+		return m
+	}
+
+	// without legal hold snapshots can be deleted at any time.
+	m1 := saveSnapshot()
+
+	hold, err := snapshot.LegalHoldPeriod(ctx, rw)
+	require.NoError(t, err)
+	require.Zero(t, hold)
+	require.NoError(t, snapshot.DeleteSnapshot(ctx, rw, m1.ID, src, "test"))
+
+	p, err := maintenance.GetParams(ctx, rw)
+	require.NoError(t, err)
+
+	p.SnapshotLegalHold = true
+	require.NoError(t, maintenance.SetParams(ctx, rw, p))
+
+	hold, err = snapshot.LegalHoldPeriod(ctx, rw)
+	require.NoError(t, err)
+	require.Equal(t, period, hold)
+
+	m2 := saveSnapshot()
+	require.ErrorIs(t, snapshot.DeleteSnapshot(ctx, rw, m2.ID, src, "test"), snapshot.ErrLegalHold)
+
+	// retention policy does not expire held snapshots.
+	one, zero := policy.OptionalInt(1), policy.OptionalInt(0)
+
+	require.NoError(t, policy.SetPolicy(ctx, rw, src, &policy.Policy{
+		RetentionPolicy: policy.RetentionPolicy{
+			KeepLatest:  &one,
+			KeepHourly:  &zero,
+			KeepDaily:   &zero,
+			KeepWeekly:  &zero,
+			KeepMonthly: &zero,
+			KeepAnnual:  &zero,
+		},
+	}))
+
+	ta.Advance(time.Hour)
+
+	m3 := saveSnapshot()
+
+	expired, err := policy.ApplyRetentionPolicy(ctx, rw, src, true)
+	require.NoError(t, err)
+	require.Empty(t, expired)
+
+	// once the hold expires, the snapshot can be deleted.
+	ta.Advance(period)
+
+	expired, err = policy.ApplyRetentionPolicy(ctx, rw, src, true)
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	require.Equal(t, m2.ID, expired[0])
+
+	require.NoError(t, snapshot.DeleteSnapshot(ctx, rw, m3.ID, src, "test"))
+}
@@ -3,10 +3,12 @@
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
@@ -109,7 +111,45 @@ func LoadSnapshot(ctx context.Context, rep repo.Repository, manifestID manifest.
 }
 
 // SaveSnapshot persists given snapshot manifest and returns manifest ID.
+// Saving a complete snapshot is recorded in the repository event log.
 func SaveSnapshot(ctx context.Context, rep repo.RepositoryWriter, man *Manifest) (manifest.ID, error) {
+	id, err := saveSnapshot(ctx, rep, man)
+	if err != nil {
+		return "", err
+	}
+
+	if man.IncompleteReason == "" {
+		eventlog.RecordOrWarn(ctx, rep, eventlog.SnapshotCreated, fmt.Sprintf("created snapshot %v of %v", id, man.Source), map[string]string{
+			"snapshotID": string(id),
+			"source":     man.Source.String(),
+			"rootID":     man.RootObjectID().String(),
+		})
+	}
+
+	return id, nil
+}
+
+// DeleteSnapshot deletes the snapshot manifest with a given ID and records the deletion
+// along with the provided reason in the repository event log.
+func DeleteSnapshot(ctx context.Context, rep repo.RepositoryWriter, id manifest.ID, src SourceInfo, reason string) error {
+	if err := CheckLegalHold(ctx, rep, id); err != nil {
+		return err
+	}
+
+	if err := rep.DeleteManifest(ctx, id); err != nil {
+		return errors.Wrapf(err, "error deleting snapshot %v", id)
+	}
+
+	eventlog.RecordOrWarn(ctx, rep, eventlog.SnapshotDeleted, fmt.Sprintf("deleted snapshot %v of %v (%v)", id, src, reason), map[string]string{
+		"snapshotID": string(id),
+		"source":     src.String(),
+		"reason":     reason,
+	})
+
+	return nil
+}
+
+func saveSnapshot(ctx context.Context, rep repo.RepositoryWriter, man *Manifest) (manifest.ID, error) {
 	if man.Source.Host == "" {
 		return "", errors.New("missing host")
 	}
@@ -233,7 +273,7 @@ func FindSnapshotsByRootObjectID(ctx context.Context, rep repo.Repository, rootI
 func UpdateSnapshot(ctx context.Context, rep repo.RepositoryWriter, m *Manifest) error {
 	oldID := m.ID
 
-	newID, err := SaveSnapshot(ctx, rep, m)
+	newID, err := saveSnapshot(ctx, rep, m)
 	if err != nil {
 		return errors.Wrap(err, "error saving snapshot")
 	}
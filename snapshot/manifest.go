@@ -3,6 +3,7 @@
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"sort"
 	"strconv"
 
@@ -36,6 +37,46 @@ type Manifest struct {
 
 	// list of manually-defined pins which prevent the snapshot from being deleted.
 	Pins []string `json:"pins,omitempty"`
+
+	// findings about snapshot contents reported by content scanners.
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// Annotation describes a finding about a single file in a snapshot reported by a content scanner
+// (such as antivirus or PII detector).
+type Annotation struct {
+	Scanner string          `json:"scanner"`
+	Path    string          `json:"path"`
+	Finding string          `json:"finding,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    fs.UTCTimestamp `json:"time"`
+}
+
+// ReplaceAnnotations replaces all annotations made by the provided scanner with the new ones
+// and returns true if the manifest has changed.
+func (m *Manifest) ReplaceAnnotations(scanner string, annotations []Annotation) bool {
+	var result []Annotation
+
+	removed := 0
+
+	for _, a := range m.Annotations {
+		if a.Scanner == scanner {
+			removed++
+			continue
+		}
+
+		result = append(result, a)
+	}
+
+	result = append(result, annotations...)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	m.Annotations = result
+
+	return removed > 0 || len(annotations) > 0
 }
 
 // UpdatePins updates pins in the provided manifest.
@@ -81,8 +122,47 @@ func (m *Manifest) UpdatePins(add, remove []string) bool {
 	EntryTypeFile      EntryType = "f" // file
 	EntryTypeDirectory EntryType = "d" // directory
 	EntryTypeSymlink   EntryType = "s" // symbolic link
+
+	// special files, of which only metadata is captured.
+	EntryTypeNamedPipe   EntryType = "p" // named pipe (FIFO)
+	EntryTypeSocket      EntryType = "k" // socket, "s" is used by symbolic links
+	EntryTypeCharDevice  EntryType = "c" // character device node
+	EntryTypeBlockDevice EntryType = "b" // block device node
 )
 
+// SpecialFileEntryType returns the type of a special file with the provided mode or EntryTypeUnknown
+// if the mode does not describe a named pipe, socket or device node.
+func SpecialFileEntryType(mode os.FileMode) EntryType {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return EntryTypeNamedPipe
+	case mode&os.ModeSocket != 0:
+		return EntryTypeSocket
+	case mode&os.ModeCharDevice != 0:
+		return EntryTypeCharDevice
+	case mode&os.ModeDevice != 0:
+		return EntryTypeBlockDevice
+	default:
+		return EntryTypeUnknown
+	}
+}
+
+// SpecialFileMode returns the mode bits identifying the special file type, zero for other entry types.
+func (t EntryType) SpecialFileMode() os.FileMode {
+	switch t {
+	case EntryTypeNamedPipe:
+		return os.ModeNamedPipe
+	case EntryTypeSocket:
+		return os.ModeSocket
+	case EntryTypeCharDevice:
+		return os.ModeDevice | os.ModeCharDevice
+	case EntryTypeBlockDevice:
+		return os.ModeDevice
+	default:
+		return 0
+	}
+}
+
 // Permissions encapsulates UNIX permissions for a filesystem entry.
 //
 //nolint:recvcheck
@@ -129,6 +209,16 @@ type DirEntry struct {
 	GroupID     uint32               `json:"gid,omitempty"`
 	ObjectID    object.ID            `json:"obj,omitempty"`
 	DirSummary  *fs.DirectorySummary `json:"summ,omitempty"`
+
+	// Device is the device number of a device node.
+	Device uint64 `json:"rdev,omitempty"`
+
+	// SymlinkType distinguishes file and directory symbolic links and directory junctions on Windows.
+	SymlinkType fs.SymlinkType `json:"ltype,omitempty"`
+
+	// ExtendedAttributes contains extended attributes, ACLs and platform-specific metadata
+	// captured according to the extended attributes policy.
+	ExtendedAttributes fs.ExtendedAttributes `json:"xattrs,omitempty"`
 }
 
 // Clone returns a clone of the entry.
@@ -141,6 +231,14 @@ func (e *DirEntry) Clone() *DirEntry {
 		e2.DirSummary = &s2
 	}
 
+	if e.ExtendedAttributes != nil {
+		e2.ExtendedAttributes = fs.ExtendedAttributes{}
+
+		for n, v := range e.ExtendedAttributes {
+			e2.ExtendedAttributes[n] = v
+		}
+	}
+
 	return &e2
 }
 
@@ -37,7 +37,7 @@ func ApplyRetentionPolicy(ctx context.Context, rep repo.RepositoryWriter, source
 
 	if reallyDelete {
 		for _, manifestID := range toDelete {
-			if err := rep.DeleteManifest(ctx, manifestID); err != nil {
+			if err := snapshot.DeleteSnapshot(ctx, rep, manifestID, sourceInfo, "expired by retention policy"); err != nil {
 				return toDelete, errors.Wrapf(err, "error deleting manifest %v", manifestID)
 			}
 		}
@@ -71,9 +71,19 @@ func getExpiredSnapshotsForSource(ctx context.Context, rep repo.Repository, snap
 
 	pol.RetentionPolicy.ComputeRetentionReasons(snapshots)
 
+	holdPeriod, err := snapshot.LegalHoldPeriod(ctx, rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to determine legal hold period")
+	}
+
 	var toDelete []manifest.ID
 
 	for _, s := range snapshots {
+		if until := s.LegalHoldUntil(holdPeriod); rep.Time().Before(until) {
+			log(ctx).Debugf("  keeping %v legal hold until %v", s.StartTime.ToTime(), until)
+			continue
+		}
+
 		if len(s.RetentionReasons) == 0 && len(s.Pins) == 0 {
 			log(ctx).Debugf("  deleting %v", s.StartTime)
 			toDelete = append(toDelete, s.ID)
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// ExtendedAttributesPolicy describes which extended metadata of files and directories is captured in snapshots.
+type ExtendedAttributesPolicy struct {
+	Xattrs           *OptionalBool `json:"xattrs,omitempty"`
+	ACLs             *OptionalBool `json:"acls,omitempty"`
+	PlatformMetadata *OptionalBool `json:"platformMetadata,omitempty"`
+}
+
+// ExtendedAttributesPolicyDefinition specifies which policy definition provided the value of a particular field.
+type ExtendedAttributesPolicyDefinition struct {
+	Xattrs           snapshot.SourceInfo `json:"xattrs,omitempty"`
+	ACLs             snapshot.SourceInfo `json:"acls,omitempty"`
+	PlatformMetadata snapshot.SourceInfo `json:"platformMetadata,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *ExtendedAttributesPolicy) Merge(src ExtendedAttributesPolicy, def *ExtendedAttributesPolicyDefinition, si snapshot.SourceInfo) {
+	mergeOptionalBool(&p.Xattrs, src.Xattrs, &def.Xattrs, si)
+	mergeOptionalBool(&p.ACLs, src.ACLs, &def.ACLs, si)
+	mergeOptionalBool(&p.PlatformMetadata, src.PlatformMetadata, &def.PlatformMetadata, si)
+}
+
+// Kinds returns the kinds of extended attributes to be captured.
+func (p *ExtendedAttributesPolicy) Kinds() fs.ExtendedAttributeKind {
+	kinds := fs.ExtendedAttributesNone
+
+	if p.Xattrs.OrDefault(false) {
+		kinds |= fs.ExtendedAttributesGeneric
+	}
+
+	if p.ACLs.OrDefault(false) {
+		kinds |= fs.ExtendedAttributesACL
+	}
+
+	if p.PlatformMetadata.OrDefault(false) {
+		kinds |= fs.ExtendedAttributesPlatform
+	}
+
+	return kinds
+}
@@ -5,32 +5,47 @@
 // FilesPolicy describes files to be ignored when taking snapshots.
 type FilesPolicy struct {
 	IgnoreRules            []string      `json:"ignore,omitempty"`
+	IgnoreRuleFiles        []string      `json:"ignoreRuleFiles,omitempty"`
 	NoParentIgnoreRules    bool          `json:"noParentIgnore,omitempty"`
 	DotIgnoreFiles         []string      `json:"ignoreDotFiles,omitempty"`
 	NoParentDotIgnoreFiles bool          `json:"noParentDotFiles,omitempty"`
 	IgnoreCacheDirectories *OptionalBool `json:"ignoreCacheDirs,omitempty"`
 	MaxFileSize            int64         `json:"maxFileSize,omitempty"`
 	OneFileSystem          *OptionalBool `json:"oneFileSystem,omitempty"`
+
+	// FollowReparsePoints causes Windows symbolic links and directory junctions to be snapshotted
+	// as the files and directories they point to, instead of being preserved as links.
+	FollowReparsePoints *OptionalBool `json:"followReparsePoints,omitempty"`
+
+	// SnapshotSpecialFiles causes metadata of named pipes, sockets and device nodes to be recorded
+	// in snapshots instead of ignoring them as entries of unknown type.
+	SnapshotSpecialFiles *OptionalBool `json:"specialFiles,omitempty"`
 }
 
 // FilesPolicyDefinition specifies which policy definition provided the value of a particular field.
 type FilesPolicyDefinition struct {
 	IgnoreRules            snapshot.SourceInfo `json:"ignore,omitempty"`
+	IgnoreRuleFiles        snapshot.SourceInfo `json:"ignoreRuleFiles,omitempty"`
 	NoParentIgnoreRules    snapshot.SourceInfo `json:"noParentIgnore,omitempty"`
 	DotIgnoreFiles         snapshot.SourceInfo `json:"ignoreDotFiles,omitempty"`
 	NoParentDotIgnoreFiles snapshot.SourceInfo `json:"noParentDotFiles,omitempty"`
 	IgnoreCacheDirectories snapshot.SourceInfo `json:"ignoreCacheDirs,omitempty"`
 	MaxFileSize            snapshot.SourceInfo `json:"maxFileSize,omitempty"`
 	OneFileSystem          snapshot.SourceInfo `json:"oneFileSystem,omitempty"`
+	FollowReparsePoints    snapshot.SourceInfo `json:"followReparsePoints,omitempty"`
+	SnapshotSpecialFiles   snapshot.SourceInfo `json:"specialFiles,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
 func (p *FilesPolicy) Merge(src FilesPolicy, def *FilesPolicyDefinition, si snapshot.SourceInfo) {
 	mergeStringList(&p.IgnoreRules, src.IgnoreRules, &def.IgnoreRules, si)
+	mergeStringList(&p.IgnoreRuleFiles, src.IgnoreRuleFiles, &def.IgnoreRuleFiles, si)
 	mergeBool(&p.NoParentIgnoreRules, src.NoParentIgnoreRules, &def.NoParentIgnoreRules, si)
 	mergeStringsReplace(&p.DotIgnoreFiles, src.DotIgnoreFiles, &def.DotIgnoreFiles, si)
 	mergeBool(&p.NoParentDotIgnoreFiles, src.NoParentDotIgnoreFiles, &def.NoParentDotIgnoreFiles, si)
 	mergeOptionalBool(&p.IgnoreCacheDirectories, src.IgnoreCacheDirectories, &def.IgnoreCacheDirectories, si)
 	mergeInt64(&p.MaxFileSize, src.MaxFileSize, &def.MaxFileSize, si)
 	mergeOptionalBool(&p.OneFileSystem, src.OneFileSystem, &def.OneFileSystem, si)
+	mergeOptionalBool(&p.FollowReparsePoints, src.FollowReparsePoints, &def.FollowReparsePoints, si)
+	mergeOptionalBool(&p.SnapshotSpecialFiles, src.SnapshotSpecialFiles, &def.SnapshotSpecialFiles, si)
 }
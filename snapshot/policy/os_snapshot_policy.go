@@ -4,17 +4,20 @@
 
 // OSSnapshotPolicy describes settings for OS-level snapshots.
 type OSSnapshotPolicy struct {
-	VolumeShadowCopy VolumeShadowCopyPolicy `json:"volumeShadowCopy,omitempty"`
+	VolumeShadowCopy   VolumeShadowCopyPolicy   `json:"volumeShadowCopy,omitempty"`
+	FilesystemSnapshot FilesystemSnapshotPolicy `json:"filesystemSnapshot,omitempty"`
 }
 
 // OSSnapshotPolicyDefinition specifies which policy definition provided the value of a particular field.
 type OSSnapshotPolicyDefinition struct {
-	VolumeShadowCopy VolumeShadowCopyPolicyDefinition `json:"volumeShadowCopy,omitempty"`
+	VolumeShadowCopy   VolumeShadowCopyPolicyDefinition   `json:"volumeShadowCopy,omitempty"`
+	FilesystemSnapshot FilesystemSnapshotPolicyDefinition `json:"filesystemSnapshot,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
 func (p *OSSnapshotPolicy) Merge(src OSSnapshotPolicy, def *OSSnapshotPolicyDefinition, si snapshot.SourceInfo) {
 	p.VolumeShadowCopy.Merge(src.VolumeShadowCopy, &def.VolumeShadowCopy, si)
+	p.FilesystemSnapshot.Merge(src.FilesystemSnapshot, &def.FilesystemSnapshot, si)
 }
 
 // VolumeShadowCopyPolicy describes settings for Windows Volume Shadow Copy
@@ -34,6 +37,41 @@ func (p *VolumeShadowCopyPolicy) Merge(src VolumeShadowCopyPolicy, def *VolumeSh
 	mergeOSSnapshotMode(&p.Enable, src.Enable, &def.Enable, si)
 }
 
+// FilesystemSnapshotPolicy describes settings for snapshots of Linux volumes and filesystems
+// (LVM, btrfs, ZFS).
+type FilesystemSnapshotPolicy struct {
+	Enable *OSSnapshotMode `json:"enable,omitempty"`
+
+	// Provider is the name of the snapshot provider, empty to detect it based on the filesystem type.
+	Provider string `json:"provider,omitempty"`
+
+	// LVMSnapshotSize is the size of copy-on-write storage allocated for LVM snapshots.
+	LVMSnapshotSize *OptionalInt64 `json:"lvmSnapshotSize,omitempty"`
+}
+
+// FilesystemSnapshotPolicyDefinition specifies which policy definition provided
+// the value of a particular field.
+type FilesystemSnapshotPolicyDefinition struct {
+	Enable          snapshot.SourceInfo `json:"enable,omitempty"`
+	Provider        snapshot.SourceInfo `json:"provider,omitempty"`
+	LVMSnapshotSize snapshot.SourceInfo `json:"lvmSnapshotSize,omitempty"`
+}
+
+// Merge applies default values from the provided policy.
+func (p *FilesystemSnapshotPolicy) Merge(src FilesystemSnapshotPolicy, def *FilesystemSnapshotPolicyDefinition, si snapshot.SourceInfo) {
+	mergeOSSnapshotMode(&p.Enable, src.Enable, &def.Enable, si)
+	mergeString(&p.Provider, src.Provider, &def.Provider, si)
+	mergeOptionalInt64(&p.LVMSnapshotSize, src.LVMSnapshotSize, &def.LVMSnapshotSize, si)
+}
+
+// Filesystem snapshot providers.
+const (
+	FilesystemSnapshotProviderAuto  = "auto"
+	FilesystemSnapshotProviderBtrfs = "btrfs"
+	FilesystemSnapshotProviderZFS   = "zfs"
+	FilesystemSnapshotProviderLVM   = "lvm"
+)
+
 // OSSnapshotMode specifies whether OS-level snapshots are used for file systems
 // that support them.
 //
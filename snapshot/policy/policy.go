@@ -33,6 +33,7 @@ type Policy struct {
 	OSSnapshotPolicy          OSSnapshotPolicy          `json:"osSnapshots,omitempty"`
 	LoggingPolicy             LoggingPolicy             `json:"logging,omitempty"`
 	UploadPolicy              UploadPolicy              `json:"upload,omitempty"`
+	ExtendedAttributesPolicy  ExtendedAttributesPolicy  `json:"extendedAttributes,omitempty"`
 	NoParent                  bool                      `json:"noParent,omitempty"`
 }
 
@@ -50,6 +51,7 @@ type Definition struct {
 	OSSnapshotPolicy          OSSnapshotPolicyDefinition          `json:"osSnapshots,omitempty"`
 	LoggingPolicy             LoggingPolicyDefinition             `json:"logging,omitempty"`
 	UploadPolicy              UploadPolicyDefinition              `json:"upload,omitempty"`
+	ExtendedAttributesPolicy  ExtendedAttributesPolicyDefinition  `json:"extendedAttributes,omitempty"`
 }
 
 func (p *Policy) String() string {
@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// PolicyTypeEnforced is the type of the repository-wide policy which is enforced on all sources
+// regardless of policies defined by clients.
+const PolicyTypeEnforced = "enforced"
+
+func enforcedPolicyLabels() map[string]string {
+	return map[string]string{
+		typeKey:         ManifestType,
+		PolicyTypeLabel: PolicyTypeEnforced,
+	}
+}
+
+// GetEnforcedPolicy returns the enforced policy or ErrPolicyNotFound if not present.
+func GetEnforcedPolicy(ctx context.Context, rep repo.Repository) (*Policy, error) {
+	md, err := rep.FindManifests(ctx, enforcedPolicyLabels())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find enforced policy")
+	}
+
+	if len(md) == 0 {
+		return nil, ErrPolicyNotFound
+	}
+
+	p := &Policy{}
+
+	if err := loadPolicyFromManifest(ctx, rep, manifest.PickLatestID(md), p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// SetEnforcedPolicy sets the enforced policy.
+//
+// Values defined in the enforced policy take precedence over values defined in all other
+// policies, except for retention counts which act as minimums and can be increased by other policies.
+func SetEnforcedPolicy(ctx context.Context, rep repo.RepositoryWriter, pol *Policy) error {
+	if err := ValidatePolicy(GlobalPolicySourceInfo, pol); err != nil {
+		return errors.Wrap(err, "failed to validate policy")
+	}
+
+	if _, err := rep.ReplaceManifests(ctx, enforcedPolicyLabels(), pol); err != nil {
+		return errors.Wrap(err, "error writing enforced policy manifest")
+	}
+
+	return nil
+}
+
+// RemoveEnforcedPolicy removes the enforced policy.
+func RemoveEnforcedPolicy(ctx context.Context, rep repo.RepositoryWriter) error {
+	md, err := rep.FindManifests(ctx, enforcedPolicyLabels())
+	if err != nil {
+		return errors.Wrap(err, "unable to find enforced policy")
+	}
+
+	for _, em := range md {
+		if err := rep.DeleteManifest(ctx, em.ID); err != nil {
+			return errors.Wrap(err, "unable to delete enforced policy manifest")
+		}
+	}
+
+	return nil
+}
+
+// enforcedPolicyOrNil returns the enforced policy or nil if not present.
+func enforcedPolicyOrNil(ctx context.Context, rep repo.Repository) (*Policy, error) {
+	p, err := GetEnforcedPolicy(ctx, rep)
+	if errors.Is(err, ErrPolicyNotFound) {
+		return nil, nil //nolint:nilnil
+	}
+
+	return p, err
+}
+
+func isEnforcedPolicy(p *Policy) bool {
+	return p.Labels[PolicyTypeLabel] == PolicyTypeEnforced
+}
+
+// MergePoliciesWithEnforced computes the policy like MergePolicies and then applies the enforced policy on top of it.
+// Values defined in the enforced policy are reported in the definition as coming from the global policy.
+func MergePoliciesWithEnforced(enforced *Policy, policies []*Policy, si snapshot.SourceInfo) (*Policy, *Definition) {
+	merged, def := MergePolicies(policies, si)
+	if enforced == nil {
+		return merged, def
+	}
+
+	// enforced policy must always be merged with its parents and can't define non-inheritable actions.
+	e := *enforced
+	e.NoParent = false
+	e.Actions.BeforeFolder = nil
+	e.Actions.AfterFolder = nil
+
+	result, resultDef := MergePolicies(append([]*Policy{&e}, policies...), si)
+	result.Labels = merged.Labels
+
+	if len(policies) > 0 {
+		result.Actions.MergeNonInheritable(policies[0].Actions)
+	}
+
+	// retention counts in the enforced policy are minimums, keep larger values defined elsewhere.
+	r, rd := &result.RetentionPolicy, &resultDef.RetentionPolicy
+	m, md := merged.RetentionPolicy, def.RetentionPolicy
+
+	enforceMinimum(&r.KeepLatest, &rd.KeepLatest, m.KeepLatest, md.KeepLatest)
+	enforceMinimum(&r.KeepHourly, &rd.KeepHourly, m.KeepHourly, md.KeepHourly)
+	enforceMinimum(&r.KeepDaily, &rd.KeepDaily, m.KeepDaily, md.KeepDaily)
+	enforceMinimum(&r.KeepWeekly, &rd.KeepWeekly, m.KeepWeekly, md.KeepWeekly)
+	enforceMinimum(&r.KeepMonthly, &rd.KeepMonthly, m.KeepMonthly, md.KeepMonthly)
+	enforceMinimum(&r.KeepAnnual, &rd.KeepAnnual, m.KeepAnnual, md.KeepAnnual)
+
+	return result, resultDef
+}
+
+func enforceMinimum(target **OptionalInt, targetDef *snapshot.SourceInfo, v *OptionalInt, vDef snapshot.SourceInfo) {
+	if *target != nil && v != nil && *v > **target {
+		*target = v
+		*targetDef = vDef
+	}
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestEnforcedPolicy(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	src := snapshot.SourceInfo{Host: "host-a", UserName: "myuser", Path: "/some/path"}
+	userSrc := snapshot.SourceInfo{Host: "host-a", UserName: "myuser"}
+
+	require.NoError(t, SetPolicy(ctx, env.RepositoryWriter, src, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily:   newOptionalInt(3),
+			KeepMonthly: newOptionalInt(48),
+		},
+		CompressionPolicy: CompressionPolicy{
+			CompressorName: "none",
+		},
+		NoParent: true,
+	}))
+
+	_, err := GetEnforcedPolicy(ctx, env.RepositoryWriter)
+	require.ErrorIs(t, err, ErrPolicyNotFound)
+
+	require.NoError(t, SetEnforcedPolicy(ctx, env.RepositoryWriter, &Policy{
+		RetentionPolicy: RetentionPolicy{
+			KeepDaily:   newOptionalInt(30),
+			KeepMonthly: newOptionalInt(12),
+		},
+		CompressionPolicy: CompressionPolicy{
+			CompressorName: "zstd",
+		},
+	}))
+
+	effective, def, _, err := GetEffectivePolicy(ctx, env.RepositoryWriter, src)
+	require.NoError(t, err)
+
+	// enforced minimum wins over weaker value
+	require.Equal(t, OptionalInt(30), *effective.RetentionPolicy.KeepDaily)
+	require.Equal(t, GlobalPolicySourceInfo, def.RetentionPolicy.KeepDaily)
+
+	// stronger value defined by the client is kept
+	require.Equal(t, OptionalInt(48), *effective.RetentionPolicy.KeepMonthly)
+	require.Equal(t, src, def.RetentionPolicy.KeepMonthly)
+
+	// enforced values override client values, even when not inheriting from parents.
+	require.Equal(t, "zstd", string(effective.CompressionPolicy.CompressorName))
+	require.Equal(t, LabelsForSource(src), effective.Labels)
+
+	// enforced policy is excluded from the list of policies.
+	pols, err := ListPolicies(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, pols, 1)
+	require.Equal(t, src, pols[0].Target())
+
+	// source without own policy gets enforced values merged with defaults.
+	effective, _, _, err = GetEffectivePolicy(ctx, env.RepositoryWriter, userSrc)
+	require.NoError(t, err)
+	require.Equal(t, OptionalInt(30), *effective.RetentionPolicy.KeepDaily)
+	require.Equal(t, *DefaultPolicy.RetentionPolicy.KeepLatest, *effective.RetentionPolicy.KeepLatest)
+
+	require.NoError(t, RemoveEnforcedPolicy(ctx, env.RepositoryWriter))
+
+	effective, _, _, err = GetEffectivePolicy(ctx, env.RepositoryWriter, src)
+	require.NoError(t, err)
+	require.Equal(t, OptionalInt(3), *effective.RetentionPolicy.KeepDaily)
+	require.Equal(t, "none", string(effective.CompressionPolicy.CompressorName))
+}
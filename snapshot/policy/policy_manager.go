@@ -55,7 +55,12 @@ func GetEffectivePolicyWithOverride(ctx context.Context, rep repo.Repository, si
 		return nil, nil, nil, errors.Wrap(err, "unable to get parent policies")
 	}
 
-	merged, def := MergePolicies(policies, si)
+	enforced, err := enforcedPolicyOrNil(ctx, rep)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	merged, def := MergePoliciesWithEnforced(enforced, policies, si)
 
 	return merged, def, policies, nil
 }
@@ -203,7 +208,7 @@ func GetPolicyByID(ctx context.Context, rep repo.Repository, id manifest.ID) (*P
 	return p, nil
 }
 
-// ListPolicies returns a list of all policies.
+// ListPolicies returns a list of all policies, excluding the enforced policy.
 func ListPolicies(ctx context.Context, rep repo.Repository) ([]*Policy, error) {
 	ids, err := rep.FindManifests(ctx, map[string]string{
 		typeKey: ManifestType,
@@ -221,6 +226,10 @@ func ListPolicies(ctx context.Context, rep repo.Repository) ([]*Policy, error) {
 			return nil, err
 		}
 
+		if isEnforcedPolicy(pol) {
+			continue
+		}
+
 		policies = append(policies, pol)
 	}
 
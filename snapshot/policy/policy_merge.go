@@ -29,6 +29,7 @@ func MergePolicies(policies []*Policy, si snapshot.SourceInfo) (*Policy, *Defini
 		merged.Actions.Merge(p.Actions, &def.Actions, p.Target())
 		merged.OSSnapshotPolicy.Merge(p.OSSnapshotPolicy, &def.OSSnapshotPolicy, p.Target())
 		merged.LoggingPolicy.Merge(p.LoggingPolicy, &def.LoggingPolicy, p.Target())
+		merged.ExtendedAttributesPolicy.Merge(p.ExtendedAttributesPolicy, &def.ExtendedAttributesPolicy, p.Target())
 
 		if p.NoParent {
 			return &merged, &def
@@ -47,6 +48,7 @@ func MergePolicies(policies []*Policy, si snapshot.SourceInfo) (*Policy, *Defini
 	merged.Actions.Merge(defaultActionsPolicy, &def.Actions, GlobalPolicySourceInfo)
 	merged.OSSnapshotPolicy.Merge(defaultOSSnapshotPolicy, &def.OSSnapshotPolicy, GlobalPolicySourceInfo)
 	merged.LoggingPolicy.Merge(defaultLoggingPolicy, &def.LoggingPolicy, GlobalPolicySourceInfo)
+	merged.ExtendedAttributesPolicy.Merge(defaultExtendedAttributesPolicy, &def.ExtendedAttributesPolicy, GlobalPolicySourceInfo)
 
 	if len(policies) > 0 {
 		merged.Actions.MergeNonInheritable(policies[0].Actions)
@@ -156,6 +158,13 @@ func mergeStringList(target *[]string, src []string, def *snapshot.SourceInfo, s
 	}
 }
 
+func mergeTagRetentionRules(target *[]TagRetentionRule, src []TagRetentionRule, def *snapshot.SourceInfo, si snapshot.SourceInfo) {
+	if len(*target) == 0 && len(src) != 0 {
+		*target = src
+		*def = si
+	}
+}
+
 func mergeLogLevel(target **LogDetail, src *LogDetail, def *snapshot.SourceInfo, si snapshot.SourceInfo) {
 	if *target == nil && src != nil {
 		b := *src
@@ -148,6 +148,10 @@ func testPolicyMergeSingleField(t *testing.T, fieldName string, typ reflect.Type
 		v0 = reflect.ValueOf([]policy.TimeOfDay{})
 		v1 = reflect.ValueOf([]policy.TimeOfDay{{Hour: 10}})
 		v2 = reflect.ValueOf([]policy.TimeOfDay{{Hour: 11}})
+	case "[]policy.TagRetentionRule":
+		v0 = reflect.ValueOf([]policy.TagRetentionRule{})
+		v1 = reflect.ValueOf([]policy.TagRetentionRule{{Tag: "release"}})
+		v2 = reflect.ValueOf([]policy.TagRetentionRule{{Tag: "pre-upgrade"}})
 	case "compression.Name":
 		v0 = reflect.ValueOf(compression.Name(""))
 		v1 = reflect.ValueOf(compression.Name("foo"))
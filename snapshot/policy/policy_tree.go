@@ -62,6 +62,20 @@
 		VolumeShadowCopy: VolumeShadowCopyPolicy{
 			Enable: NewOSSnapshotMode(OSSnapshotNever),
 		},
+		FilesystemSnapshot: FilesystemSnapshotPolicy{
+			Enable:   NewOSSnapshotMode(OSSnapshotNever),
+			Provider: FilesystemSnapshotProviderAuto,
+
+			// allocate 1 GiB for changes made to the volume while LVM snapshot exists.
+			LVMSnapshotSize: newOptionalInt64(1 << 30), //nolint:mnd
+		},
+	}
+
+	// extended attributes are not captured unless enabled.
+	defaultExtendedAttributesPolicy = ExtendedAttributesPolicy{
+		Xattrs:           NewOptionalBool(false),
+		ACLs:             NewOptionalBool(false),
+		PlatformMetadata: NewOptionalBool(false),
 	}
 
 	defaultUploadPolicy = UploadPolicy{
@@ -70,6 +84,15 @@
 
 		// upload large files in chunks of 2 GiB
 		ParallelUploadAboveSize: newOptionalInt64(2 << 30), //nolint:mnd
+
+		// small files are uploaded as separate objects unless enabled.
+		PackFilesBelowSize: nil,
+
+		// contents of very small files are stored as separate objects unless enabled.
+		InlineFilesBelowSize: nil,
+
+		// memory usage of uploads is not limited unless enabled.
+		MemoryBudget: nil,
 	}
 
 	// DefaultPolicy is a default policy returned by policy tree in absence of other policies.
@@ -84,6 +107,7 @@
 		Actions:                   defaultActionsPolicy,
 		OSSnapshotPolicy:          defaultOSSnapshotPolicy,
 		UploadPolicy:              defaultUploadPolicy,
+		ExtendedAttributesPolicy:  defaultExtendedAttributesPolicy,
 	}
 
 	// DefaultDefinition provides the Definition for the default policy.
@@ -17,17 +17,68 @@
 
 	// minimal number of incomplete snapshots to keep.
 	retainIncompleteSnapshotMinimumCount = 3
+
+	// prefix of keys of user-defined snapshot tags.
+	snapshotTagKeyPrefix = "tag:"
 )
 
 // RetentionPolicy describes snapshot retention policy.
 type RetentionPolicy struct {
-	KeepLatest               *OptionalInt  `json:"keepLatest,omitempty"`
-	KeepHourly               *OptionalInt  `json:"keepHourly,omitempty"`
-	KeepDaily                *OptionalInt  `json:"keepDaily,omitempty"`
-	KeepWeekly               *OptionalInt  `json:"keepWeekly,omitempty"`
-	KeepMonthly              *OptionalInt  `json:"keepMonthly,omitempty"`
-	KeepAnnual               *OptionalInt  `json:"keepAnnual,omitempty"`
-	IgnoreIdenticalSnapshots *OptionalBool `json:"ignoreIdenticalSnapshots,omitempty"`
+	KeepLatest               *OptionalInt       `json:"keepLatest,omitempty"`
+	KeepHourly               *OptionalInt       `json:"keepHourly,omitempty"`
+	KeepDaily                *OptionalInt       `json:"keepDaily,omitempty"`
+	KeepWeekly               *OptionalInt       `json:"keepWeekly,omitempty"`
+	KeepMonthly              *OptionalInt       `json:"keepMonthly,omitempty"`
+	KeepAnnual               *OptionalInt       `json:"keepAnnual,omitempty"`
+	IgnoreIdenticalSnapshots *OptionalBool      `json:"ignoreIdenticalSnapshots,omitempty"`
+	TagRules                 []TagRetentionRule `json:"tagRules,omitempty"`
+}
+
+// TagRetentionRule describes retention of snapshots with a particular tag. Snapshots matching a tag rule
+// are retained according to the rule instead of the regular time-based retention settings.
+type TagRetentionRule struct {
+	// Tag is the tag key, optionally followed by ':' and the tag value, as specified when creating the snapshot.
+	Tag string `json:"tag"`
+
+	// KeepLatest is the number of most recent matching snapshots to keep, when not set matching snapshots never expire.
+	KeepLatest *OptionalInt `json:"keepLatest,omitempty"`
+}
+
+// Matches returns true if the provided snapshot has the tag specified in the rule.
+func (r TagRetentionRule) Matches(m *snapshot.Manifest) bool {
+	key, value, hasValue := strings.Cut(r.Tag, ":")
+
+	v, ok := m.Tags[snapshotTagKeyPrefix+key]
+	if !ok {
+		return false
+	}
+
+	return !hasValue || v == value
+}
+
+func (r TagRetentionRule) String() string {
+	if r.KeepLatest == nil {
+		return r.Tag + " (never expire)"
+	}
+
+	return fmt.Sprintf("%v (keep latest %v)", r.Tag, *r.KeepLatest)
+}
+
+// computeRetentionReasons sets retention reasons of the provided snapshots matching the rule,
+// which must be sorted most recent first.
+func (r TagRetentionRule) computeRetentionReasons(sorted []*snapshot.Manifest) {
+	reason := "tag:" + r.Tag
+
+	for i, s := range sorted {
+		switch {
+		case r.KeepLatest == nil:
+			s.RetentionReasons = []string{reason}
+		case i < int(*r.KeepLatest):
+			s.RetentionReasons = []string{fmt.Sprintf("%v-%v", reason, i+1)}
+		default:
+			s.RetentionReasons = []string{}
+		}
+	}
 }
 
 // RetentionPolicyDefinition specifies which policy definition provided the value of a particular field.
@@ -39,6 +90,7 @@ type RetentionPolicyDefinition struct {
 	KeepMonthly              snapshot.SourceInfo `json:"keepMonthly,omitempty"`
 	KeepAnnual               snapshot.SourceInfo `json:"keepAnnual,omitempty"`
 	IgnoreIdenticalSnapshots snapshot.SourceInfo `json:"ignoreIdenticalSnapshots,omitempty"`
+	TagRules                 snapshot.SourceInfo `json:"tagRules,omitempty"`
 }
 
 // ComputeRetentionReasons computes the reasons why each snapshot is retained, based on
@@ -88,13 +140,32 @@ func (r *RetentionPolicy) ComputeRetentionReasons(manifests []*snapshot.Manifest
 	// sort manifests in descending time order (most recent first)
 	sorted := snapshot.SortByTime(manifests, true)
 
-	// apply retention reasons to complete snapshots
-	for i, s := range sorted {
-		if s.IncompleteReason == "" {
-			s.RetentionReasons = r.getRetentionReasons(i, s, cutoff, ids, idCounters)
-		} else {
+	var regular []*snapshot.Manifest
+
+	tagged := make([][]*snapshot.Manifest, len(r.TagRules))
+
+	// split complete snapshots into those governed by tag rules and regular ones.
+	for _, s := range sorted {
+		if s.IncompleteReason != "" {
 			s.RetentionReasons = []string{}
+			continue
 		}
+
+		if ri := r.matchingTagRule(s); ri >= 0 {
+			tagged[ri] = append(tagged[ri], s)
+			continue
+		}
+
+		regular = append(regular, s)
+	}
+
+	// apply retention reasons to complete snapshots
+	for i, s := range regular {
+		s.RetentionReasons = r.getRetentionReasons(i, s, cutoff, ids, idCounters)
+	}
+
+	for ri, rule := range r.TagRules {
+		rule.computeRetentionReasons(tagged[ri])
 	}
 
 	// attach 'retention reason' tag to incomplete snapshots until we run into first complete one
@@ -114,6 +185,17 @@ func (r *RetentionPolicy) ComputeRetentionReasons(manifests []*snapshot.Manifest
 	}
 }
 
+// matchingTagRule returns the index of the first tag rule matching the provided snapshot or -1.
+func (r *RetentionPolicy) matchingTagRule(s *snapshot.Manifest) int {
+	for i, rule := range r.TagRules {
+		if rule.Matches(s) {
+			return i
+		}
+	}
+
+	return -1
+}
+
 // EffectiveKeepLatest returns the number of "latest" snapshots to keep. If all
 // retention values are set to 0 then returns MaxInt.
 func (r *RetentionPolicy) EffectiveKeepLatest() *OptionalInt {
@@ -223,6 +305,7 @@ func (r *RetentionPolicy) Merge(src RetentionPolicy, def *RetentionPolicyDefinit
 	mergeOptionalInt(&r.KeepMonthly, src.KeepMonthly, &def.KeepMonthly, si)
 	mergeOptionalInt(&r.KeepAnnual, src.KeepAnnual, &def.KeepAnnual, si)
 	mergeOptionalBool(&r.IgnoreIdenticalSnapshots, src.IgnoreIdenticalSnapshots, &def.IgnoreIdenticalSnapshots, si)
+	mergeTagRetentionRules(&r.TagRules, src.TagRules, &def.TagRules, si)
 }
 
 // CompactRetentionReasons returns compressed retention reasons given a list of retention reasons.
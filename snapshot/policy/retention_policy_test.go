@@ -241,3 +241,52 @@ func TestCompactRetentionrRasons(t *testing.T) {
 		require.Equal(t, tc.want, CompactRetentionReasons(tc.input))
 	}
 }
+
+func TestTagRetentionRules(t *testing.T) {
+	rp := &RetentionPolicy{
+		KeepLatest: newOptionalInt(2),
+		TagRules: []TagRetentionRule{
+			{Tag: "release"},
+			{Tag: "type:pre-upgrade", KeepLatest: newOptionalInt(1)},
+		},
+	}
+
+	base := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var manifests []*snapshot.Manifest
+
+	for i, tags := range []map[string]string{
+		{"tag:release": "1.0"},
+		{"tag:type": "pre-upgrade"},
+		nil,
+		{"tag:type": "pre-upgrade"},
+		{"tag:type": "other"},
+		nil,
+		{"tag:release": "2.0"},
+		nil,
+	} {
+		manifests = append(manifests, &snapshot.Manifest{
+			StartTime: fs.UTCTimestampFromTime(base.Add(time.Duration(i) * time.Hour)),
+			Tags:      tags,
+		})
+	}
+
+	rp.ComputeRetentionReasons(manifests)
+
+	var got [][]string
+
+	for _, m := range manifests {
+		got = append(got, m.RetentionReasons)
+	}
+
+	require.Equal(t, [][]string{
+		{"tag:release"},
+		{},
+		{},
+		{"tag:type:pre-upgrade-1"},
+		{},
+		{"latest-2"},
+		{"tag:release"},
+		{"latest-1"},
+	}, got)
+}
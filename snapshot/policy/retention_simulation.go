@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	// maximum number of snapshots created during retention simulation.
+	maxSimulatedSnapshots = 10000
+
+	// maximum number of snapshots retained at any point during retention simulation.
+	maxSimulatedRetainedSnapshots = 1000
+)
+
+// SimulatedSnapshot describes a snapshot that would exist at the end of the simulation.
+type SimulatedSnapshot struct {
+	StartTime        time.Time `json:"startTime"`
+	RetentionReasons []string  `json:"retentionReasons"`
+}
+
+// RetentionSimulation is the result of simulating snapshot creation and retention over a time horizon.
+type RetentionSimulation struct {
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	CreatedCount     int       `json:"createdCount"`
+	ExpiredCount     int       `json:"expiredCount"`
+	MaxRetainedCount int       `json:"maxRetainedCount"`
+
+	// Retained contains snapshots that would exist at the end of the simulation, most recent first.
+	Retained []*SimulatedSnapshot `json:"retained"`
+}
+
+// SimulateRetention generates the set of snapshots that would exist after taking snapshots according to the provided
+// schedule for the duration of the horizon starting at the provided time. Retention is applied after each snapshot,
+// as it happens when snapshots are created. The first snapshot is taken at the start time.
+func SimulateRetention(schedule *SchedulingPolicy, retention *RetentionPolicy, horizon time.Duration, start time.Time) (*RetentionSimulation, error) {
+	if horizon <= 0 {
+		return nil, errors.New("simulation horizon must be positive")
+	}
+
+	if schedule.Manual {
+		return nil, errors.New("manual schedule does not create any snapshots")
+	}
+
+	if schedule.IntervalSeconds == 0 && len(schedule.TimesOfDay) == 0 && len(schedule.Cron) == 0 {
+		return nil, errors.New("schedule does not define snapshot interval, times of day or cron expressions")
+	}
+
+	// missed snapshots are never simulated since the next snapshot time is always computed right after the previous one.
+	sched := *schedule
+	sched.RunMissed = NewOptionalBool(false)
+
+	res := &RetentionSimulation{
+		Start: start,
+		End:   start.Add(horizon),
+	}
+
+	var times []time.Time
+
+	for t, ok := start, true; ok && !t.After(res.End); t, ok = sched.NextSnapshotTime(t, t.Add(time.Second)) {
+		if len(times) >= maxSimulatedSnapshots {
+			return nil, errors.Errorf("schedule creates more than %v snapshots over %v, use shorter horizon", maxSimulatedSnapshots, horizon)
+		}
+
+		times = append(times, t)
+	}
+
+	var retained []*snapshot.Manifest
+
+	for _, t := range times {
+		res.CreatedCount++
+
+		retained = append(retained, &snapshot.Manifest{StartTime: fs.UTCTimestampFromTime(t)})
+		retention.ComputeRetentionReasons(retained)
+
+		var kept []*snapshot.Manifest
+
+		for _, m := range retained {
+			if len(m.RetentionReasons) == 0 {
+				res.ExpiredCount++
+				continue
+			}
+
+			kept = append(kept, m)
+		}
+
+		if len(kept) > maxSimulatedRetainedSnapshots {
+			return nil, errors.Errorf("retention policy keeps more than %v snapshots, use shorter horizon", maxSimulatedRetainedSnapshots)
+		}
+
+		retained = kept
+		res.MaxRetainedCount = max(res.MaxRetainedCount, len(retained))
+	}
+
+	for _, m := range snapshot.SortByTime(retained, true) {
+		res.Retained = append(res.Retained, &SimulatedSnapshot{
+			StartTime:        m.StartTime.ToTime(),
+			RetentionReasons: m.RetentionReasons,
+		})
+	}
+
+	return res, nil
+}
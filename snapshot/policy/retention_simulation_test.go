@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateRetention(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+
+	sp := &SchedulingPolicy{}
+	sp.SetInterval(time.Hour)
+
+	rp := &RetentionPolicy{
+		KeepLatest: newOptionalInt(3),
+		KeepDaily:  newOptionalInt(7),
+	}
+
+	res, err := SimulateRetention(sp, rp, 30*24*time.Hour, start)
+	require.NoError(t, err)
+
+	require.Equal(t, 30*24+1, res.CreatedCount)
+	require.Equal(t, res.CreatedCount-len(res.Retained), res.ExpiredCount)
+	require.Len(t, res.Retained, 8)
+	// the oldest daily snapshot expires only after the snapshot replacing it is taken.
+	require.Equal(t, 9, res.MaxRetainedCount)
+
+	require.Equal(t, start.Add(30*24*time.Hour), res.Retained[0].StartTime.Local())
+	require.Equal(t, []string{"latest-1", "daily-1"}, res.Retained[0].RetentionReasons)
+	require.Equal(t, time.Date(2020, 1, 25, 23, 0, 0, 0, time.Local), res.Retained[7].StartTime.Local())
+	require.Equal(t, []string{"daily-7"}, res.Retained[7].RetentionReasons)
+}
+
+func TestSimulateRetention_TimesOfDay(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+
+	sp := &SchedulingPolicy{
+		TimesOfDay: []TimeOfDay{{Hour: 10}, {Hour: 22, Minute: 30}},
+	}
+
+	res, err := SimulateRetention(sp, &RetentionPolicy{}, 10*24*time.Hour, start)
+	require.NoError(t, err)
+
+	// initial snapshot followed by two snapshots a day.
+	require.Equal(t, 21, res.CreatedCount)
+	require.Zero(t, res.ExpiredCount)
+	require.Len(t, res.Retained, 21)
+	require.Equal(t, time.Date(2020, 1, 10, 22, 30, 0, 0, time.Local), res.Retained[0].StartTime.Local())
+}
+
+func TestSimulateRetention_Errors(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+
+	everySecond := &SchedulingPolicy{}
+	everySecond.SetInterval(time.Second)
+
+	cases := []struct {
+		desc      string
+		schedule  *SchedulingPolicy
+		retention *RetentionPolicy
+		horizon   time.Duration
+	}{
+		{"manual", &SchedulingPolicy{Manual: true, IntervalSeconds: 3600}, &RetentionPolicy{}, time.Hour},
+		{"no schedule", &SchedulingPolicy{}, &RetentionPolicy{}, time.Hour},
+		{"no horizon", everySecond, &RetentionPolicy{}, 0},
+		{"too many snapshots", everySecond, &RetentionPolicy{}, 365 * 24 * time.Hour},
+		{"too many retained", everySecond, &RetentionPolicy{}, 2 * time.Hour},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := SimulateRetention(tc.schedule, tc.retention, tc.horizon, start)
+			require.Error(t, err)
+		})
+	}
+}
@@ -11,6 +11,9 @@ type UploadPolicy struct {
 	MaxParallelSnapshots    *OptionalInt   `json:"maxParallelSnapshots,omitempty"`
 	MaxParallelFileReads    *OptionalInt   `json:"maxParallelFileReads,omitempty"`
 	ParallelUploadAboveSize *OptionalInt64 `json:"parallelUploadAboveSize,omitempty"`
+	PackFilesBelowSize      *OptionalInt64 `json:"packFilesBelowSize,omitempty"`
+	InlineFilesBelowSize    *OptionalInt64 `json:"inlineFilesBelowSize,omitempty"`
+	MemoryBudget            *OptionalInt64 `json:"memoryBudget,omitempty"`
 }
 
 // UploadPolicyDefinition specifies which policy definition provided the value of a particular field.
@@ -18,6 +21,9 @@ type UploadPolicyDefinition struct {
 	MaxParallelSnapshots    snapshot.SourceInfo `json:"maxParallelSnapshots,omitempty"`
 	MaxParallelFileReads    snapshot.SourceInfo `json:"maxParallelFileReads,omitempty"`
 	ParallelUploadAboveSize snapshot.SourceInfo `json:"parallelUploadAboveSize,omitempty"`
+	PackFilesBelowSize      snapshot.SourceInfo `json:"packFilesBelowSize,omitempty"`
+	InlineFilesBelowSize    snapshot.SourceInfo `json:"inlineFilesBelowSize,omitempty"`
+	MemoryBudget            snapshot.SourceInfo `json:"memoryBudget,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
@@ -25,6 +31,9 @@ func (p *UploadPolicy) Merge(src UploadPolicy, def *UploadPolicyDefinition, si s
 	mergeOptionalInt(&p.MaxParallelSnapshots, src.MaxParallelSnapshots, &def.MaxParallelSnapshots, si)
 	mergeOptionalInt(&p.MaxParallelFileReads, src.MaxParallelFileReads, &def.MaxParallelFileReads, si)
 	mergeOptionalInt64(&p.ParallelUploadAboveSize, src.ParallelUploadAboveSize, &def.ParallelUploadAboveSize, si)
+	mergeOptionalInt64(&p.PackFilesBelowSize, src.PackFilesBelowSize, &def.PackFilesBelowSize, si)
+	mergeOptionalInt64(&p.InlineFilesBelowSize, src.InlineFilesBelowSize, &def.InlineFilesBelowSize, si)
+	mergeOptionalInt64(&p.MemoryBudget, src.MemoryBudget, &def.MemoryBudget, si)
 }
 
 // ValidateUploadPolicy returns an error if manual field is set along with Upload fields.
@@ -0,0 +1,191 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/units"
+)
+
+const (
+	// autoTuneInterval is the interval between adjustments of restore parameters.
+	autoTuneInterval = 2 * time.Second
+
+	// minimum relative change in throughput considered to be an improvement or degradation.
+	autoTuneImprovementThreshold = 0.05
+	autoTuneDegradationThreshold = 0.1
+
+	// the prefetcher stays ahead of the restore by the number of bytes written during this many batch latencies.
+	prefetchLatencyMultiplier = 4
+
+	minAutoTunePrefetchBytes = 8 << 20
+)
+
+// TuningDecision describes an adjustment of restore parameters made by auto-tuning.
+type TuningDecision struct {
+	Parallel       int           `json:"parallel"`
+	PrefetchBytes  int64         `json:"prefetchBytes"`
+	Throughput     float64       `json:"throughput"`
+	BackendLatency time.Duration `json:"backendLatency"`
+	Reason         string        `json:"reason"`
+}
+
+func (d TuningDecision) String() string {
+	return fmt.Sprintf("parallel=%v prefetch=%v (throughput %v, backend latency %v): %v",
+		d.Parallel,
+		units.BytesString(d.PrefetchBytes),
+		units.BytesPerSecondsString(d.Throughput),
+		d.BackendLatency.Truncate(time.Millisecond),
+		d.Reason)
+}
+
+// tuner adjusts restore parallelism and prefetch depth based on observed throughput of writes to the output
+// and latency of the backend, using hill-climbing for parallelism and bandwidth-delay product for prefetch.
+type tuner struct {
+	maxParallel      int
+	maxPrefetchBytes int64
+
+	parallel      int
+	prefetchBytes int64
+
+	// +1 when increasing parallelism, -1 when decreasing.
+	direction int
+
+	lastTime       time.Time
+	lastBytes      int64
+	lastThroughput float64
+}
+
+func newTuner(maxParallel int, maxPrefetchBytes int64) *tuner {
+	// start in the middle of the range, so that both directions can be explored.
+	initialParallel := max(1, maxParallel/2)                                                    //nolint:mnd
+	initialPrefetch := max(min(maxPrefetchBytes, minAutoTunePrefetchBytes), maxPrefetchBytes/4) //nolint:mnd
+
+	return &tuner{
+		maxParallel:      maxParallel,
+		maxPrefetchBytes: maxPrefetchBytes,
+		parallel:         initialParallel,
+		prefetchBytes:    initialPrefetch,
+		direction:        1,
+	}
+}
+
+// observe records the total number of bytes restored at the provided time and the current backend latency,
+// and returns a new decision if parameters should change.
+func (t *tuner) observe(now time.Time, restoredBytes int64, backendLatency time.Duration) (TuningDecision, bool) {
+	if t.lastTime.IsZero() {
+		t.lastTime = now
+		t.lastBytes = restoredBytes
+
+		return TuningDecision{}, false
+	}
+
+	dt := now.Sub(t.lastTime).Seconds()
+	if dt <= 0 {
+		return TuningDecision{}, false
+	}
+
+	throughput := float64(restoredBytes-t.lastBytes) / dt
+
+	t.lastTime = now
+	t.lastBytes = restoredBytes
+
+	if throughput <= 0 {
+		// nothing was written, probably processing directories or waiting for the backend.
+		return TuningDecision{}, false
+	}
+
+	previous := t.lastThroughput
+	t.lastThroughput = throughput
+
+	oldParallel, oldPrefetch := t.parallel, t.prefetchBytes
+
+	var reason string
+
+	switch {
+	case previous == 0:
+		reason = "initial measurement"
+		t.step()
+
+	case throughput >= previous*(1+autoTuneImprovementThreshold):
+		reason = "throughput improved"
+		t.step()
+
+	case throughput <= previous*(1-autoTuneDegradationThreshold):
+		reason = "throughput degraded"
+		t.direction = -t.direction
+		t.step()
+
+	default:
+		reason = "throughput stable"
+	}
+
+	if backendLatency > 0 && t.maxPrefetchBytes > 0 {
+		target := int64(throughput * backendLatency.Seconds() * prefetchLatencyMultiplier)
+		t.prefetchBytes = max(min(target, t.maxPrefetchBytes), min(t.maxPrefetchBytes, minAutoTunePrefetchBytes))
+	}
+
+	if t.parallel == oldParallel && t.prefetchBytes == oldPrefetch {
+		return TuningDecision{}, false
+	}
+
+	return TuningDecision{
+		Parallel:       t.parallel,
+		PrefetchBytes:  t.prefetchBytes,
+		Throughput:     throughput,
+		BackendLatency: backendLatency,
+		Reason:         reason,
+	}, true
+}
+
+// step moves parallelism in the current direction, reversing it at the limits.
+func (t *tuner) step() {
+	delta := max(1, t.parallel/4) //nolint:mnd
+
+	next := t.parallel + t.direction*delta
+	if next < 1 || next > t.maxParallel {
+		t.direction = -t.direction
+		next = t.parallel + t.direction*delta
+	}
+
+	t.parallel = max(1, min(t.maxParallel, next))
+}
+
+// autoTune periodically adjusts parallelism of the restore and depth of prefetching until the context is canceled.
+func (c *copier) autoTune(ctx context.Context, t *tuner, onDecision func(ctx context.Context, d TuningDecision)) {
+	ticker := time.NewTicker(autoTuneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			d, ok := t.observe(clock.Now(), c.stats.RestoredTotalFileSize.Load(), c.prefetch.averageLatency())
+			if !ok {
+				continue
+			}
+
+			c.applyTuning(d)
+
+			log(ctx).Debugf("restore auto-tuning: %v", d)
+
+			if onDecision != nil {
+				onDecision(ctx, d)
+			}
+		}
+	}
+}
+
+func (c *copier) applyTuning(d TuningDecision) {
+	c.q.SetMaxActiveWorkers(d.Parallel)
+	c.stats.Parallel.Store(int32(d.Parallel)) //nolint:gosec
+
+	if c.prefetch != nil {
+		c.prefetch.setMaxAheadBytes(d.PrefetchBytes)
+		c.stats.PrefetchBytes.Store(d.PrefetchBytes)
+	}
+}
@@ -0,0 +1,87 @@
+package restore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTuner(t *testing.T) {
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tu := newTuner(8, 128<<20)
+	require.Equal(t, 4, tu.parallel)
+	require.Equal(t, int64(32<<20), tu.prefetchBytes)
+
+	var restored int64
+
+	now := t0
+
+	observe := func(throughput int64, latency time.Duration) (TuningDecision, bool) {
+		t.Helper()
+
+		now = now.Add(time.Second)
+		restored += throughput
+
+		return tu.observe(now, restored, latency)
+	}
+
+	_, ok := observe(0, 0)
+	require.False(t, ok, "first observation only records baseline")
+
+	d, ok := observe(10<<20, 0)
+	require.True(t, ok)
+	require.Equal(t, 5, d.Parallel)
+	require.Equal(t, "initial measurement", d.Reason)
+	require.InDelta(t, float64(10<<20), d.Throughput, 1)
+
+	// improvement keeps increasing parallelism.
+	d, ok = observe(20<<20, 0)
+	require.True(t, ok)
+	require.Equal(t, 6, d.Parallel)
+	require.Equal(t, "throughput improved", d.Reason)
+
+	// stable throughput keeps parameters.
+	_, ok = observe(20<<20, 0)
+	require.False(t, ok)
+
+	// degradation reverses direction.
+	d, ok = observe(10<<20, 0)
+	require.True(t, ok)
+	require.Equal(t, 5, d.Parallel)
+	require.Equal(t, "throughput degraded", d.Reason)
+
+	// no writes - no decision.
+	_, ok = observe(0, 0)
+	require.False(t, ok)
+
+	// prefetch follows bandwidth-delay product, bounded by the minimum and maximum.
+	d, ok = observe(10<<20, time.Second)
+	require.True(t, ok)
+	require.Equal(t, int64(40<<20), d.PrefetchBytes)
+
+	d, ok = observe(100<<20, time.Second)
+	require.True(t, ok)
+	require.Equal(t, int64(128<<20), d.PrefetchBytes)
+
+	d, ok = observe(1<<20, 10*time.Millisecond)
+	require.True(t, ok)
+	require.Equal(t, int64(minAutoTunePrefetchBytes), d.PrefetchBytes)
+
+	// parallelism stays within limits.
+	for range 100 {
+		restored += 1 << 30
+		now = now.Add(time.Second)
+		tu.observe(now, restored, 0)
+
+		require.GreaterOrEqual(t, tu.parallel, 1)
+		require.LessOrEqual(t, tu.parallel, 8)
+	}
+
+	single := newTuner(1, 0)
+	single.observe(t0, 0, 0)
+
+	_, ok = single.observe(t0.Add(time.Second), 100, time.Second)
+	require.False(t, ok, "nothing to tune")
+}
@@ -3,6 +3,7 @@
 
 import (
 	"context"
+	stderrors "errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -23,35 +24,49 @@
 const (
 	outputDirMode                     = 0o700 // default mode to create directories in before setting their ACLs
 	maxTimeDeltaToConsiderFileTheSame = 2 * time.Second
+
+	// autoSparseMinHoleSize is the minimum length of a run of zeros written as a hole
+	// when sparse files are detected automatically.
+	autoSparseMinHoleSize = 1 << 20
+
+	windowsSparseBlockSize = 64 << 10
 )
 
 // streamCopier is a generic function type to perform the actual copying of data bits
 // from a source stream to a destination stream.
 type streamCopier func(io.WriteSeeker, io.Reader) (int64, error)
 
+// getSparseStreamCopier returns a function that copies data from a source stream to a destination stream,
+// writing runs of zero blocks that are at least minHoleSize bytes long as holes.
+func getSparseStreamCopier(blockSize, minHoleSize uint64) streamCopier {
+	return func(w io.WriteSeeker, r io.Reader) (int64, error) {
+		return sparsefile.CopyWithMinHoleSize(w, r, blockSize, minHoleSize)
+	}
+}
+
 // getStreamCopier returns a function that can copy data from a source stream to a destination stream.
-func getStreamCopier(ctx context.Context, targetpath string, sparse bool) (streamCopier, error) {
-	if sparse {
-		if !isWindows() {
-			dirpath := filepath.Dir(targetpath)
+func getStreamCopier() streamCopier {
+	// Wrap iocopy.Copy to conform to StreamCopier type.
+	return func(w io.WriteSeeker, r io.Reader) (int64, error) {
+		return iocopy.Copy(w, r)
+	}
+}
 
-			s, err := stat.GetBlockSize(dirpath)
-			if err != nil {
-				return nil, errors.Wrapf(err, "error getting disk block size for target %v", dirpath)
-			}
+// sparseBlockSize returns the granularity of holes in files in the target location.
+func sparseBlockSize(targetpath string) (uint64, error) {
+	if isWindows() {
+		// NTFS allocates sparse files in units of 16 clusters.
+		return windowsSparseBlockSize, nil
+	}
 
-			return func(w io.WriteSeeker, r io.Reader) (int64, error) {
-				return sparsefile.Copy(w, r, s)
-			}, nil
-		}
+	dirpath := filepath.Dir(targetpath)
 
-		log(ctx).Debug("sparse copying is not supported on Windows, falling back to regular copying")
+	s, err := stat.GetBlockSize(dirpath)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error getting disk block size for target %v", dirpath)
 	}
 
-	// Wrap iocopy.Copy to conform to StreamCopier type.
-	return func(w io.WriteSeeker, r io.Reader) (int64, error) {
-		return iocopy.Copy(w, r)
-	}, nil
+	return s, nil
 }
 
 // progressReportingReader wraps fs.Reader Read function to capture the and pass
@@ -104,23 +119,53 @@ type FilesystemOutput struct {
 	// SkipTimes when set to true causes restore to skip restoring modification times.
 	SkipTimes bool `json:"skipTimes"`
 
+	// SkipExtendedAttributes when set to true causes restore to skip restoring extended attributes, ACLs and platform-specific metadata.
+	SkipExtendedAttributes bool `json:"skipExtendedAttributes"`
+
 	// WriteSparseFiles when set to true, write contents as sparse files, minimizing allocated disk space.
 	WriteSparseFiles bool `json:"writeSparseFiles"`
 
+	// AutoSparseFiles when set to true, write long runs of zeros (such as unused areas of VM images)
+	// as holes if the target file system supports them. Ignored when WriteSparseFiles is set.
+	AutoSparseFiles bool `json:"autoSparseFiles,omitempty"`
+
 	// copier is the StreamCopier to use for copying the actual bit stream to output.
 	// It is assigned at runtime based on the target filesystem and restore options.
 	copier streamCopier `json:"-"`
+
+	// sparse is true when the copier writes sparse files.
+	sparse bool `json:"-"`
 }
 
 // Init initializes the internal members of the filesystem writer output.
 // This method must be called before FilesystemOutput can be used.
 func (o *FilesystemOutput) Init(ctx context.Context) error {
-	c, err := getStreamCopier(ctx, o.TargetPath, o.WriteSparseFiles)
-	if err != nil {
-		return errors.Wrap(err, "unable to get stream copier")
-	}
+	switch {
+	case o.WriteSparseFiles:
+		s, err := sparseBlockSize(o.TargetPath)
+		if err != nil {
+			return errors.Wrap(err, "unable to get stream copier")
+		}
+
+		o.copier = getSparseStreamCopier(s, s)
+		o.sparse = true
 
-	o.copier = c
+	case o.AutoSparseFiles:
+		s, err := sparseBlockSize(o.TargetPath)
+		if err != nil {
+			log(ctx).Debugf("sparse files are not supported by the target, falling back to regular copying: %v", err)
+
+			o.copier = getStreamCopier()
+
+			break
+		}
+
+		o.copier = getSparseStreamCopier(s, autoSparseMinHoleSize)
+		o.sparse = true
+
+	default:
+		o.copier = getStreamCopier()
+	}
 
 	return nil
 }
@@ -179,6 +224,18 @@ func (o *FilesystemOutput) WriteFile(ctx context.Context, relativePath string, f
 	return SafeRemoveAll(path)
 }
 
+// OpenRestoredFile implements restore.VerifiableOutput interface.
+func (o *FilesystemOutput) OpenRestoredFile(ctx context.Context, relativePath string) (io.ReadCloser, error) {
+	path := atomicfile.MaybePrefixLongFilenameOnWindows(filepath.Join(o.TargetPath, filepath.FromSlash(relativePath)))
+
+	f, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open restored file")
+	}
+
+	return f, nil
+}
+
 // FileExists implements restore.Output interface.
 func (o *FilesystemOutput) FileExists(ctx context.Context, relativePath string, e fs.File) bool {
 	st, err := os.Lstat(filepath.Join(o.TargetPath, relativePath))
@@ -219,7 +276,7 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 	case os.IsNotExist(err): // Proceed to symlink creation
 	case err != nil:
 		return errors.Wrap(err, "lstat error at symlink path")
-	case fileIsSymlink(st):
+	case fileIsSymlink(path, st):
 		// Throw error if we are not overwriting symlinks
 		if !o.OverwriteSymlinks {
 			return errors.New("will not overwrite existing symlink")
@@ -233,7 +290,12 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 		return errors.Errorf("unable to create symlink, %q already exists and is not a symlink", path)
 	}
 
-	if err := os.Symlink(targetPath, path); err != nil {
+	var linkType fs.SymlinkType
+	if ts, ok := e.(fs.TypedSymlink); ok {
+		linkType = ts.SymlinkType()
+	}
+
+	if err := localfs.CreateSymlink(targetPath, path, linkType); err != nil {
 		return errors.Wrap(err, "error creating symlink")
 	}
 
@@ -244,20 +306,78 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 	return nil
 }
 
-func fileIsSymlink(st os.FileInfo) bool {
-	return st.Mode()&os.ModeSymlink != 0
+// CreateSpecialFile implements restore.SpecialFileOutput interface.
+func (o *FilesystemOutput) CreateSpecialFile(ctx context.Context, relativePath string, e fs.SpecialFile) error {
+	log(ctx).Debugf("CreateSpecialFile %v, mode %v, device %v", filepath.Join(o.TargetPath, relativePath), e.Mode(), e.RawDevice())
+
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+
+	switch st, err := os.Lstat(path); {
+	case os.IsNotExist(err): // Proceed to special file creation
+	case err != nil:
+		return errors.Wrap(err, "lstat error at special file path")
+	case st.Mode().Type() == e.Mode().Type():
+		if !o.OverwriteFiles {
+			return errors.Errorf("unable to create special file, %q already exists", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return errors.Wrap(err, "removing existing special file")
+		}
+	default:
+		return errors.Errorf("unable to create special file, %q already exists and is of a different type", path)
+	}
+
+	if err := o.maybeIgnorePermissionError(createSpecialFile(path, e.Mode(), e.RawDevice())); err != nil {
+		return errors.Wrap(err, "error creating special file")
+	}
+
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		// creation was not permitted and the error was ignored.
+		return nil
+	}
+
+	if err := o.setAttributes(path, e, os.FileMode(0)); err != nil {
+		return errors.Wrap(err, "error setting attributes")
+	}
+
+	return nil
+}
+
+// fileIsSymlink returns true if the entry at the provided path is a symbolic link or a Windows directory junction.
+func fileIsSymlink(path string, st os.FileInfo) bool {
+	if st.Mode()&os.ModeSymlink != 0 {
+		return true
+	}
+
+	if st.Mode()&os.ModeIrregular == 0 {
+		return false
+	}
+
+	e, err := localfs.NewEntry(path)
+	if err != nil {
+		return false
+	}
+
+	defer e.Close()
+
+	_, ok := e.(fs.Symlink)
+
+	return ok
 }
 
 // SymlinkExists implements restore.Output interface.
 //
 //nolint:revive
 func (o *FilesystemOutput) SymlinkExists(ctx context.Context, relativePath string, e fs.Symlink) bool {
-	st, err := os.Lstat(filepath.Join(o.TargetPath, relativePath))
+	path := filepath.Join(o.TargetPath, relativePath)
+
+	st, err := os.Lstat(path)
 	if err != nil {
 		return false
 	}
 
-	return (st.Mode() & os.ModeType) == os.ModeSymlink
+	return fileIsSymlink(path, st)
 }
 
 // setAttributes sets permission, modification time and user/group ids
@@ -291,6 +411,11 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear
 		}
 	}
 
+	// Set extended attributes before permissions, which may prevent modifications.
+	if err = o.setExtendedAttributes(targetPath, e); err != nil {
+		return errors.Wrap(err, "could not set extended attributes on "+targetPath)
+	}
+
 	// Set file permissions from e
 	if o.shouldUpdatePermissions(le, e, modclear) {
 		if err = o.maybeIgnorePermissionError(osChmod(targetPath, (e.Mode()&fs.ModBits)&^modclear)); err != nil {
@@ -307,6 +432,26 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear
 	return nil
 }
 
+// setExtendedAttributes restores extended attributes captured in the snapshot. When permission errors are
+// ignored, errors caused by target filesystems that don't support extended attributes are ignored as well.
+func (o *FilesystemOutput) setExtendedAttributes(targetPath string, e fs.Entry) error {
+	if o.SkipExtendedAttributes {
+		return nil
+	}
+
+	hde, ok := e.(snapshot.HasDirEntry)
+	if !ok || len(hde.DirEntry().ExtendedAttributes) == 0 {
+		return nil
+	}
+
+	err := localfs.WriteExtendedAttributes(targetPath, hde.DirEntry().ExtendedAttributes, fs.ExtendedAttributesAll)
+	if o.IgnorePermissionErrors && stderrors.Is(err, stderrors.ErrUnsupported) {
+		return nil
+	}
+
+	return o.maybeIgnorePermissionError(err)
+}
+
 func isSymlink(e fs.Entry) bool {
 	_, ok := e.(fs.Symlink)
 	return ok
@@ -374,12 +519,19 @@ func (o *FilesystemOutput) createDirectory(ctx context.Context, path string) err
 	}
 }
 
-func write(targetPath string, r fs.Reader, size int64, c streamCopier) error {
+func write(targetPath string, r fs.Reader, size int64, c streamCopier, sparse bool) error {
 	f, err := os.OpenFile(targetPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600) //nolint:gosec,mnd
 	if err != nil {
 		return err //nolint:wrapcheck
 	}
 
+	if sparse {
+		if err := sparsefile.Prepare(f); err != nil {
+			f.Close() //nolint:errcheck
+			return errors.Wrapf(err, "unable to prepare sparse file %q", targetPath)
+		}
+	}
+
 	if err := f.Truncate(size); err != nil {
 		return err //nolint:wrapcheck
 	}
@@ -431,7 +583,7 @@ func (o *FilesystemOutput) copyFileContent(ctx context.Context, targetPath strin
 		return atomicfile.Write(targetPath, rr)
 	}
 
-	return write(targetPath, rr, f.Size(), o.copier)
+	return write(targetPath, rr, f.Size(), o.copier, o.sparse)
 }
 
 func isEmptyDirectory(name string) (bool, error) {
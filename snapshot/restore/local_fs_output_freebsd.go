@@ -0,0 +1,8 @@
+package restore
+
+import "golang.org/x/sys/unix"
+
+func mknod(path string, mode uint32, dev uint64) error {
+	//nolint:wrapcheck
+	return unix.Mknod(path, mode, dev)
+}
@@ -0,0 +1,11 @@
+//go:build linux || openbsd || darwin
+// +build linux openbsd darwin
+
+package restore
+
+import "golang.org/x/sys/unix"
+
+func mknod(path string, mode uint32, dev uint64) error {
+	//nolint:wrapcheck,gosec
+	return unix.Mknod(path, mode, int(dev))
+}
@@ -0,0 +1,31 @@
+//go:build linux || freebsd || openbsd || darwin
+// +build linux freebsd openbsd darwin
+
+package restore
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// createSpecialFile creates a named pipe, socket or device node with the provided mode and device number.
+func createSpecialFile(path string, mode os.FileMode, dev uint64) error {
+	var fileType uint32
+
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		fileType = unix.S_IFIFO
+	case mode&os.ModeSocket != 0:
+		fileType = unix.S_IFSOCK
+	case mode&os.ModeCharDevice != 0:
+		fileType = unix.S_IFCHR
+	case mode&os.ModeDevice != 0:
+		fileType = unix.S_IFBLK
+	default:
+		return errors.Errorf("not a special file: %v", mode)
+	}
+
+	return mknod(path, fileType|uint32(mode.Perm()), dev)
+}
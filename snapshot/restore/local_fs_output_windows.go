@@ -35,7 +35,7 @@ func symlinkChtimes(linkPath string, atime, mtime time.Time) error {
 		fn, windows.GENERIC_READ|windows.GENERIC_WRITE,
 		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
 		nil, windows.OPEN_EXISTING,
-		windows.FILE_FLAG_OPEN_REPARSE_POINT, 0)
+		windows.FILE_FLAG_OPEN_REPARSE_POINT|windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
 	if err != nil {
 		return errors.Wrapf(err, "CreateFile error on %v", linkPath)
 	}
@@ -45,3 +45,8 @@ func symlinkChtimes(linkPath string, atime, mtime time.Time) error {
 	//nolint:wrapcheck
 	return windows.SetFileTime(h, &ftw, &fta, &ftw)
 }
+
+//nolint:revive
+func createSpecialFile(path string, mode os.FileMode, dev uint64) error {
+	return errors.Errorf("special files are not supported on Windows: %v", mode)
+}
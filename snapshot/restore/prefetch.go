@@ -0,0 +1,183 @@
+package restore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// maxPrefetchBatchFiles is the maximum number of files whose contents are prefetched in a single batch.
+const maxPrefetchBatchFiles = 100
+
+// latencySmoothing is the weight of the most recent batch in the average prefetch latency.
+const latencySmoothing = 0.25
+
+// prefetcher fetches contents of files in the order in which they are going to be restored,
+// so that reading them later does not incur backend latency. It stays at most maxAheadBytes
+// ahead of the restore.
+type prefetcher struct {
+	rep           repo.Repository
+	restoredBytes func() int64
+
+	done chan struct{}
+
+	// average time it takes to prefetch a batch of files, in nanoseconds.
+	avgLatencyNanos atomic.Int64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// +checklocks:mu
+	maxAheadBytes int64
+	// +checklocks:mu
+	queue []fs.File
+	// +checklocks:mu
+	scheduledBytes int64
+	// +checklocks:mu
+	closed bool
+}
+
+func newPrefetcher(ctx context.Context, rep repo.Repository, maxAheadBytes int64, restoredBytes func() int64) *prefetcher {
+	p := &prefetcher{
+		rep:           rep,
+		maxAheadBytes: maxAheadBytes,
+		restoredBytes: restoredBytes,
+		done:          make(chan struct{}),
+	}
+
+	p.cond = sync.NewCond(&p.mu)
+
+	go p.run(ctx)
+
+	return p
+}
+
+// enqueue schedules prefetching of the provided file, which will be restored after all previously enqueued files.
+func (p *prefetcher) enqueue(f fs.File) {
+	if p == nil {
+		return
+	}
+
+	if _, ok := f.(object.HasObjectID); !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queue = append(p.queue, f)
+	p.cond.Broadcast()
+}
+
+// setMaxAheadBytes changes the maximum number of bytes the prefetcher stays ahead of the restore.
+func (p *prefetcher) setMaxAheadBytes(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.maxAheadBytes = n
+	p.cond.Broadcast()
+}
+
+// averageLatency returns the average time it takes to prefetch a batch of files, 0 if unknown.
+func (p *prefetcher) averageLatency() time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	return time.Duration(p.avgLatencyNanos.Load())
+}
+
+func (p *prefetcher) recordLatency(d time.Duration) {
+	avg := p.avgLatencyNanos.Load()
+	if avg == 0 {
+		p.avgLatencyNanos.Store(int64(d))
+		return
+	}
+
+	p.avgLatencyNanos.Store(int64(latencySmoothing*float64(d) + (1-latencySmoothing)*float64(avg)))
+}
+
+// progress notifies the prefetcher that the restore has made progress.
+func (p *prefetcher) progress() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cond.Broadcast()
+}
+
+// close stops the prefetcher and waits for the pending batch to complete.
+func (p *prefetcher) close() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	<-p.done
+}
+
+func (p *prefetcher) run(ctx context.Context) {
+	defer close(p.done)
+
+	for {
+		batch := p.nextBatch()
+		if batch == nil {
+			return
+		}
+
+		oids := make([]object.ID, 0, len(batch))
+		for _, f := range batch {
+			oids = append(oids, f.(object.HasObjectID).ObjectID()) //nolint:forcetypeassert
+		}
+
+		t0 := clock.Now()
+
+		if _, err := p.rep.PrefetchObjects(ctx, oids, content.PrefetchHintRanges); err != nil {
+			log(ctx).Debugf("error prefetching %v files: %v", len(oids), err)
+			continue
+		}
+
+		p.recordLatency(clock.Now().Sub(t0))
+	}
+}
+
+// nextBatch waits until the restore is close enough and returns the next batch of files to prefetch
+// or nil when the prefetcher has been closed.
+func (p *prefetcher) nextBatch() []fs.File {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed && (len(p.queue) == 0 || p.scheduledBytes-p.restoredBytes() >= p.maxAheadBytes) {
+		p.cond.Wait()
+	}
+
+	if p.closed {
+		return nil
+	}
+
+	var batch []fs.File
+
+	for len(p.queue) > 0 && len(batch) < maxPrefetchBatchFiles && (len(batch) == 0 || p.scheduledBytes-p.restoredBytes() < p.maxAheadBytes) {
+		f := p.queue[0]
+		p.queue = p.queue[1:]
+
+		batch = append(batch, f)
+		p.scheduledBytes += f.Size()
+	}
+
+	return batch
+}
@@ -0,0 +1,104 @@
+package restore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+type prefetchTestRepo struct {
+	repo.Repository
+
+	mu      sync.Mutex
+	batches [][]object.ID
+}
+
+func (r *prefetchTestRepo) PrefetchObjects(ctx context.Context, objectIDs []object.ID, hint string) ([]content.ID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.batches = append(r.batches, objectIDs)
+
+	return nil, nil
+}
+
+func (r *prefetchTestRepo) fileCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+
+	for _, b := range r.batches {
+		n += len(b)
+	}
+
+	return n
+}
+
+type prefetchTestFile struct {
+	fs.File
+
+	oid  object.ID
+	size int64
+}
+
+func (f prefetchTestFile) Size() int64 {
+	return f.size
+}
+
+func (f prefetchTestFile) ObjectID() object.ID {
+	return f.oid
+}
+
+func TestPrefetcher(t *testing.T) {
+	ctx := testlogging.Context(t)
+	rep := &prefetchTestRepo{}
+
+	var restored atomic.Int64
+
+	p := newPrefetcher(ctx, rep, 25, restored.Load)
+
+	var oids []object.ID
+
+	for i := range 5 {
+		cid, err := content.IDFromHash("", []byte{byte(i), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+		require.NoError(t, err)
+
+		oids = append(oids, object.DirectObjectID(cid))
+		p.enqueue(prefetchTestFile{oid: oids[i], size: 10})
+	}
+
+	// files are prefetched until the prefetcher is at least 25 bytes ahead of the restore.
+	require.Eventually(t, func() bool { return rep.fileCount() == 3 }, 5*time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 3, rep.fileCount())
+
+	// restoring the first file allows one more file to be prefetched.
+	restored.Store(10)
+	p.progress()
+	require.Eventually(t, func() bool { return rep.fileCount() == 4 }, 5*time.Second, time.Millisecond)
+
+	restored.Store(50)
+	p.progress()
+	require.Eventually(t, func() bool { return rep.fileCount() == 5 }, 5*time.Second, time.Millisecond)
+
+	p.close()
+
+	var all []object.ID
+
+	for _, b := range rep.batches {
+		all = append(all, b...)
+	}
+
+	require.Equal(t, oids, all)
+}
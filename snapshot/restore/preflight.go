@@ -0,0 +1,359 @@
+package restore
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/parallelwork"
+	"github.com/kopia/kopia/internal/stat"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// defaultPreflightMaxProblems is the default maximum number of problems recorded by Preflight.
+const defaultPreflightMaxProblems = 100
+
+// PreflightOptions provides options for Preflight.
+type PreflightOptions struct {
+	Parallel int
+
+	// TargetPath is the local path the restore will write to, if empty destination checks are skipped.
+	TargetPath string
+
+	// MaxProblems is the maximum number of problems to record, the walk stops after that.
+	MaxProblems int
+}
+
+// PreflightProblem describes a single problem that would prevent restore from succeeding.
+type PreflightProblem struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// PreflightResult contains results of restore preflight check.
+type PreflightResult struct {
+	FileCount    int32 `json:"fileCount"`
+	DirCount     int32 `json:"dirCount"`
+	SymlinkCount int32 `json:"symlinkCount"`
+
+	// TotalFileSize is the estimated amount of local disk space needed to restore all files.
+	TotalFileSize int64 `json:"totalFileSize"`
+
+	// ContentCount and DownloadSize describe unique contents that need to be fetched from the repository.
+	ContentCount int   `json:"contentCount"`
+	DownloadSize int64 `json:"downloadSize"`
+
+	TargetPath     string `json:"targetPath,omitempty"`
+	TargetExists   bool   `json:"targetExists"`
+	TargetWritable bool   `json:"targetWritable"`
+
+	// AvailableSpace is the free space on the destination filesystem, -1 if unknown.
+	AvailableSpace int64 `json:"availableSpace"`
+
+	Problems      []PreflightProblem `json:"problems,omitempty"`
+	ProblemsCount int                `json:"problemsCount"`
+}
+
+// OK returns true if the restore is expected to succeed.
+func (r *PreflightResult) OK() bool {
+	return r.ProblemsCount == 0
+}
+
+type preflighter struct {
+	rep         repo.Repository
+	blobReader  blob.Reader // nil when blobs can't be checked directly
+	q           *parallelwork.Queue
+	maxProblems int
+
+	mu sync.Mutex
+	// +checklocks:mu
+	seenObjects map[object.ID]bool
+	// +checklocks:mu
+	seenContents map[content.ID]bool
+	// +checklocks:mu
+	blobLengths map[blob.ID]int64 // -1 == missing
+	// +checklocks:mu
+	result PreflightResult
+}
+
+// Preflight walks the provided snapshot tree without writing anything and verifies that all
+// referenced contents exist and are readable, estimates required disk space and download size
+// and checks that the target path can be written to.
+func Preflight(ctx context.Context, rep repo.Repository, rootEntry fs.Entry, opt PreflightOptions) (*PreflightResult, error) {
+	if opt.MaxProblems <= 0 {
+		opt.MaxProblems = defaultPreflightMaxProblems
+	}
+
+	numWorkers := opt.Parallel
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+
+	p := &preflighter{
+		rep:          rep,
+		q:            parallelwork.NewQueue(),
+		maxProblems:  opt.MaxProblems,
+		seenObjects:  map[object.ID]bool{},
+		seenContents: map[content.ID]bool{},
+		blobLengths:  map[blob.ID]int64{},
+	}
+
+	if dr, ok := rep.(repo.DirectRepository); ok {
+		p.blobReader = dr.BlobReader()
+	}
+
+	p.result.AvailableSpace = -1
+
+	p.q.EnqueueFront(ctx, func() error {
+		p.checkEntry(ctx, rootEntry, ".")
+		return nil
+	})
+
+	if err := p.q.Process(ctx, numWorkers); err != nil {
+		return nil, errors.Wrap(err, "preflight error")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if opt.TargetPath != "" {
+		p.checkTargetLocked(opt.TargetPath)
+	}
+
+	r := p.result
+
+	return &r, nil
+}
+
+func (p *preflighter) reportProblem(entryPath string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reportProblemLocked(entryPath, err)
+}
+
+// +checklocks:p.mu
+func (p *preflighter) reportProblemLocked(entryPath string, err error) {
+	p.result.ProblemsCount++
+
+	if len(p.result.Problems) < p.maxProblems {
+		p.result.Problems = append(p.result.Problems, PreflightProblem{entryPath, err.Error()})
+	}
+}
+
+func (p *preflighter) tooManyProblems() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.result.ProblemsCount >= p.maxProblems
+}
+
+func (p *preflighter) checkEntry(ctx context.Context, e fs.Entry, entryPath string) {
+	if p.tooManyProblems() {
+		return
+	}
+
+	if h, ok := e.(object.HasObjectID); ok {
+		if err := p.checkObject(ctx, h.ObjectID()); err != nil {
+			p.reportProblem(entryPath, err)
+			return
+		}
+	}
+
+	switch e := e.(type) {
+	case fs.Directory:
+		p.mu.Lock()
+		p.result.DirCount++
+		p.mu.Unlock()
+
+		if err := fs.IterateEntries(ctx, e, func(ctx context.Context, child fs.Entry) error {
+			childPath := path.Join(entryPath, child.Name())
+
+			p.q.EnqueueBack(ctx, func() error {
+				p.checkEntry(ctx, child, childPath)
+				return nil
+			})
+
+			return nil
+		}); err != nil {
+			p.reportProblem(entryPath, errors.Wrap(err, "error reading directory"))
+		}
+
+	case fs.Symlink:
+		p.mu.Lock()
+		p.result.SymlinkCount++
+		p.mu.Unlock()
+
+	case fs.File:
+		p.mu.Lock()
+		p.result.FileCount++
+		p.result.TotalFileSize += e.Size()
+		p.mu.Unlock()
+	}
+}
+
+// checkObject verifies that all contents backing the provided object exist and their pack blobs are readable.
+func (p *preflighter) checkObject(ctx context.Context, oid object.ID) error {
+	p.mu.Lock()
+	seen := p.seenObjects[oid]
+	p.seenObjects[oid] = true
+	p.mu.Unlock()
+
+	if seen {
+		return nil
+	}
+
+	contentIDs, err := p.rep.VerifyObject(ctx, oid)
+	if err != nil {
+		return errors.Wrapf(err, "unable to verify object %v", oid)
+	}
+
+	for _, cid := range contentIDs {
+		if err := p.checkContent(ctx, cid); err != nil {
+			return errors.Wrapf(err, "object %v", oid)
+		}
+	}
+
+	return nil
+}
+
+func (p *preflighter) checkContent(ctx context.Context, cid content.ID) error {
+	p.mu.Lock()
+	seen := p.seenContents[cid]
+	p.seenContents[cid] = true
+	p.mu.Unlock()
+
+	if seen {
+		return nil
+	}
+
+	ci, err := p.rep.ContentInfo(ctx, cid)
+	if err != nil {
+		return errors.Wrapf(err, "content %v", cid)
+	}
+
+	if ci.Deleted {
+		return errors.Errorf("content %v is deleted", cid)
+	}
+
+	p.mu.Lock()
+	p.result.ContentCount++
+	p.result.DownloadSize += int64(ci.PackedLength)
+	p.mu.Unlock()
+
+	if p.blobReader == nil {
+		return nil
+	}
+
+	length, err := p.packBlobLength(ctx, ci.PackBlobID)
+	if err != nil {
+		return err
+	}
+
+	if length < 0 {
+		return errors.Errorf("content %v is stored in missing blob %v", cid, ci.PackBlobID)
+	}
+
+	if end := int64(ci.PackOffset) + int64(ci.PackedLength); end > length {
+		return errors.Errorf("content %v extends past the end of blob %v (%v > %v)", cid, ci.PackBlobID, end, length)
+	}
+
+	return nil
+}
+
+// packBlobLength returns the length of the provided pack blob or -1 if it does not exist.
+func (p *preflighter) packBlobLength(ctx context.Context, blobID blob.ID) (int64, error) {
+	p.mu.Lock()
+	length, ok := p.blobLengths[blobID]
+	p.mu.Unlock()
+
+	if ok {
+		return length, nil
+	}
+
+	bm, err := p.blobReader.GetMetadata(ctx, blobID)
+
+	switch {
+	case errors.Is(err, blob.ErrBlobNotFound):
+		length = -1
+	case err != nil:
+		return 0, errors.Wrapf(err, "unable to get metadata of blob %v", blobID)
+	default:
+		length = bm.Length
+	}
+
+	p.mu.Lock()
+	p.blobLengths[blobID] = length
+	p.mu.Unlock()
+
+	return length, nil
+}
+
+// checkTargetLocked verifies that the target path or its closest existing parent is a writable directory
+// with enough free space.
+//
+// +checklocks:p.mu
+func (p *preflighter) checkTargetLocked(targetPath string) {
+	p.result.TargetPath = targetPath
+
+	dir := targetPath
+
+	for {
+		st, err := os.Stat(dir)
+		if err == nil {
+			if !st.IsDir() {
+				p.reportProblemLocked(targetPath, errors.Errorf("%v is not a directory", dir))
+				return
+			}
+
+			break
+		}
+
+		if !os.IsNotExist(err) {
+			p.reportProblemLocked(targetPath, errors.Wrapf(err, "unable to stat %v", dir))
+			return
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			p.reportProblemLocked(targetPath, errors.New("no existing parent directory"))
+			return
+		}
+
+		dir = parent
+	}
+
+	p.result.TargetExists = dir == targetPath
+
+	f, err := os.CreateTemp(dir, ".kopia-preflight-*")
+	if err != nil {
+		p.reportProblemLocked(targetPath, errors.Wrapf(err, "directory %v is not writable", dir))
+		return
+	}
+
+	f.Close()           //nolint:errcheck
+	os.Remove(f.Name()) //nolint:errcheck
+
+	p.result.TargetWritable = true
+
+	avail, err := stat.GetAvailableSpace(dir)
+	if err != nil {
+		// not supported on all platforms.
+		return
+	}
+
+	p.result.AvailableSpace = int64(avail) //nolint:gosec
+
+	if p.result.AvailableSpace < p.result.TotalFileSize {
+		p.reportProblemLocked(targetPath, errors.Errorf("insufficient disk space: need %v bytes, available %v bytes", p.result.TotalFileSize, p.result.AvailableSpace))
+	}
+}
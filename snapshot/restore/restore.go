@@ -33,6 +33,11 @@ type Output interface {
 	Close(ctx context.Context) error
 }
 
+// SpecialFileOutput is implemented by outputs which can recreate named pipes, sockets and device nodes.
+type SpecialFileOutput interface {
+	CreateSpecialFile(ctx context.Context, relativePath string, e fs.SpecialFile) error
+}
+
 // Stats represents restore statistics.
 type Stats struct {
 	RestoredTotalFileSize int64
@@ -47,6 +52,15 @@ type Stats struct {
 	EnqueuedSymlinkCount int32
 	SkippedCount         int32
 	IgnoredErrorCount    int32
+
+	RestoredSpecialFileCount int32
+
+	VerifiedFileCount         int32
+	VerificationMismatchCount int32
+
+	// current restore parallelism and prefetch depth, which change over time when auto-tuning.
+	Parallel      int32
+	PrefetchBytes int64
 }
 
 // stats represents restore statistics.
@@ -63,6 +77,14 @@ type statsInternal struct {
 	EnqueuedSymlinkCount atomic.Int32
 	SkippedCount         atomic.Int32
 	IgnoredErrorCount    atomic.Int32
+
+	RestoredSpecialFileCount atomic.Int32
+
+	VerifiedFileCount         atomic.Int32
+	VerificationMismatchCount atomic.Int32
+
+	Parallel      atomic.Int32
+	PrefetchBytes atomic.Int64
 }
 
 func (s *statsInternal) clone() Stats {
@@ -78,6 +100,13 @@ func (s *statsInternal) clone() Stats {
 		EnqueuedSymlinkCount:  s.EnqueuedSymlinkCount.Load(),
 		SkippedCount:          s.SkippedCount.Load(),
 		IgnoredErrorCount:     s.IgnoredErrorCount.Load(),
+		Parallel:              s.Parallel.Load(),
+		PrefetchBytes:         s.PrefetchBytes.Load(),
+
+		RestoredSpecialFileCount: s.RestoredSpecialFileCount.Load(),
+
+		VerifiedFileCount:         s.VerifiedFileCount.Load(),
+		VerificationMismatchCount: s.VerificationMismatchCount.Load(),
 	}
 }
 
@@ -94,13 +123,30 @@ type Options struct {
 	RestoreDirEntryAtDepth int32 `json:"restoreDirEntryAtDepth"`
 	MinSizeForPlaceholder  int32 `json:"minSizeForPlaceholder"`
 
-	ProgressCallback ProgressCallback `json:"-"`
-	Cancel           chan struct{}    `json:"-"` // channel that can be externally closed to signal cancellation
+	// PrefetchBytes is the maximum number of bytes of upcoming files whose contents are
+	// prefetched ahead of the restore, 0 disables prefetching.
+	PrefetchBytes int64 `json:"prefetchBytes"`
+
+	// AutoTune enables adjusting parallelism and prefetch depth during the restore based on observed
+	// backend latency and output throughput, in which case Parallel and PrefetchBytes are the maximums.
+	AutoTune bool `json:"autoTune"`
+
+	// RestoreSpecialFiles causes named pipes, sockets and device nodes to be recreated if the output
+	// supports it, otherwise they are skipped.
+	RestoreSpecialFiles bool `json:"restoreSpecialFiles,omitempty"`
+
+	// VerifyAfter causes restored files to be read back from the output after the restore and compared
+	// against the snapshot, the output must implement VerifiableOutput.
+	VerifyAfter bool `json:"verifyAfter,omitempty"`
+
+	ProgressCallback ProgressCallback                            `json:"-"`
+	OnTuningDecision func(ctx context.Context, d TuningDecision) `json:"-"`
+	Cancel           chan struct{}                               `json:"-"` // channel that can be externally closed to signal cancellation
 }
 
 // Entry walks a snapshot root with given root entry and restores it to the provided output.
-//
-//nolint:revive
+// When verification finds restored files that don't match the snapshot, restore statistics are
+// returned along with *VerificationError.
 func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs.Entry, options Options) (Stats, error) {
 	c := copier{
 		output:           output,
@@ -108,21 +154,23 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 		q:                parallelwork.NewQueue(),
 		incremental:      options.Incremental,
 		ignoreErrors:     options.IgnoreErrors,
+		specialFiles:     options.RestoreSpecialFiles,
 		cancel:           options.Cancel,
 		progressCallback: options.ProgressCallback,
 	}
 
+	if options.VerifyAfter {
+		if _, ok := output.(VerifiableOutput); !ok {
+			return Stats{}, errors.New("restore output does not support verification")
+		}
+
+		c.verifier = &verifier{}
+	}
+
 	c.q.ProgressCallback = func(ctx context.Context, enqueued, active, completed int64) {
 		c.reportProgress(ctx)
 	}
 
-	// Control the depth of a restore. Default (options.MaxDepth = 0) is to restore to full depth.
-	currentdepth := int32(0)
-
-	c.q.EnqueueFront(ctx, func() error {
-		return errors.Wrap(c.copyEntry(ctx, rootEntry, "", currentdepth, options.RestoreDirEntryAtDepth, func() error { return nil }), "error copying")
-	})
-
 	numWorkers := options.Parallel
 	if numWorkers == 0 {
 		numWorkers = runtime.NumCPU()
@@ -132,6 +180,42 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 		numWorkers = 1
 	}
 
+	var t *tuner
+
+	prefetchBytes := options.PrefetchBytes
+
+	if options.AutoTune {
+		t = newTuner(numWorkers, options.PrefetchBytes)
+		prefetchBytes = t.prefetchBytes
+	}
+
+	if prefetchBytes > 0 {
+		c.prefetch = newPrefetcher(ctx, rep, prefetchBytes, func() int64 {
+			return c.stats.RestoredTotalFileSize.Load() + c.stats.SkippedTotalFileSize.Load()
+		})
+
+		defer c.prefetch.close()
+	}
+
+	c.stats.Parallel.Store(int32(numWorkers)) //nolint:gosec
+	c.stats.PrefetchBytes.Store(prefetchBytes)
+
+	if t != nil {
+		c.applyTuning(TuningDecision{Parallel: t.parallel, PrefetchBytes: t.prefetchBytes})
+
+		tuneCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		go c.autoTune(tuneCtx, t, options.OnTuningDecision)
+	}
+
+	// Control the depth of a restore. Default (options.MaxDepth = 0) is to restore to full depth.
+	currentdepth := int32(0)
+
+	c.q.EnqueueFront(ctx, func() error {
+		return errors.Wrap(c.copyEntry(ctx, rootEntry, "", currentdepth, options.RestoreDirEntryAtDepth, func() error { return nil }), "error copying")
+	})
+
 	if err := c.q.Process(ctx, numWorkers); err != nil {
 		return Stats{}, errors.Wrap(err, "restore error")
 	}
@@ -140,6 +224,12 @@ func Entry(ctx context.Context, rep repo.Repository, output Output, rootEntry fs
 		return Stats{}, errors.Wrap(err, "error closing output")
 	}
 
+	if c.verifier != nil && !c.isCanceled() {
+		if err := c.verify(ctx, numWorkers); err != nil {
+			return c.stats.clone(), err
+		}
+	}
+
 	return c.stats.clone(), nil
 }
 
@@ -150,25 +240,39 @@ type copier struct {
 	q             *parallelwork.Queue
 	incremental   bool
 	ignoreErrors  bool
+	specialFiles  bool
 	cancel        chan struct{}
+	prefetch      *prefetcher // nil if prefetching is disabled
+	verifier      *verifier   // nil if verification is disabled
 
 	progressCallback ProgressCallback
 }
 
 func (c *copier) reportProgress(ctx context.Context) {
+	c.prefetch.progress()
+
 	if c.progressCallback != nil {
 		c.progressCallback(ctx, c.stats.clone())
 	}
 }
 
-func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, currentdepth, maxdepth int32, onCompletion func() error) error {
-	if c.cancel != nil {
-		select {
-		case <-c.cancel:
-			return onCompletion()
+func (c *copier) isCanceled() bool {
+	if c.cancel == nil {
+		return false
+	}
 
-		default:
-		}
+	select {
+	case <-c.cancel:
+		return true
+
+	default:
+		return false
+	}
+}
+
+func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, currentdepth, maxdepth int32, onCompletion func() error) error {
+	if c.isCanceled() {
+		return onCompletion()
 	}
 
 	if c.incremental {
@@ -232,6 +336,10 @@ func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath s
 			if err := c.output.WriteFile(ctx, targetPath, e, progressCallback); err != nil {
 				return errors.Wrap(err, "copy file")
 			}
+
+			if c.verifier != nil {
+				c.verifier.add(targetPath, e)
+			}
 		}
 
 		c.stats.RestoredFileCount.Add(1)
@@ -249,11 +357,39 @@ func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath s
 
 		return onCompletion()
 
+	case fs.SpecialFile:
+		sfo, ok := c.output.(SpecialFileOutput)
+		if !c.specialFiles || !ok {
+			log(ctx).Debugf("skipping special file %v", targetPath)
+			c.stats.SkippedCount.Add(1)
+
+			return onCompletion()
+		}
+
+		log(ctx).Debugf("special file: '%v'", targetPath)
+
+		if err := sfo.CreateSpecialFile(ctx, targetPath, e); err != nil {
+			return errors.Wrap(err, "create special file")
+		}
+
+		c.stats.RestoredSpecialFileCount.Add(1)
+
+		return onCompletion()
+
 	default:
 		return errors.Errorf("invalid FS entry type for %q: %#v", targetPath, e)
 	}
 }
 
+// shouldPrefetch determines whether the contents of the provided file will be read during the restore.
+func (c *copier) shouldPrefetch(ctx context.Context, f fs.File, targetPath string, currentdepth, maxdepth int32) bool {
+	if c.prefetch == nil || currentdepth > maxdepth {
+		return false
+	}
+
+	return !c.incremental || !c.output.FileExists(ctx, targetPath, f)
+}
+
 func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath string, currentdepth, maxdepth int32, onCompletion parallelwork.CallbackFunc) error {
 	c.stats.RestoredDirCount.Add(1)
 
@@ -311,6 +447,10 @@ func (c *copier) copyDirectoryContent(ctx context.Context, d fs.Directory, targe
 
 			c.stats.EnqueuedTotalFileSize.Add(e.Size())
 
+			if f, ok := e.(fs.File); ok && c.shouldPrefetch(ctx, f, path.Join(targetPath, e.Name()), currentdepth, maxdepth) {
+				c.prefetch.enqueue(f)
+			}
+
 			c.q.EnqueueBack(ctx, func() error {
 				return c.copyEntry(ctx, e, path.Join(targetPath, e.Name()), currentdepth, maxdepth, onItemCompletion)
 			})
@@ -0,0 +1,166 @@
+package restore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/parallelwork"
+)
+
+// VerifiableOutput is implemented by outputs which can read back restored files, which allows
+// verifying them after the restore.
+type VerifiableOutput interface {
+	OpenRestoredFile(ctx context.Context, relativePath string) (io.ReadCloser, error)
+}
+
+// VerificationMismatch describes a restored file whose contents do not match the snapshot.
+type VerificationMismatch struct {
+	Path         string `json:"path"`
+	ExpectedHash string `json:"expectedHash,omitempty"`
+	ActualHash   string `json:"actualHash,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func (m VerificationMismatch) String() string {
+	if m.Error != "" {
+		return fmt.Sprintf("%v: %v", m.Path, m.Error)
+	}
+
+	return fmt.Sprintf("%v: expected sha256 %v, got %v", m.Path, m.ExpectedHash, m.ActualHash)
+}
+
+// VerificationError is returned when restored files could not be verified or their contents
+// do not match the snapshot.
+type VerificationError struct {
+	Mismatches []VerificationMismatch
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("verification failed for %v restored files", len(e.Mismatches))
+}
+
+// restoredFile is a file written by the restore, which will be verified after the restore completes.
+type restoredFile struct {
+	relativePath string
+	f            fs.File
+}
+
+// verifier collects restored files and verifies them after the restore.
+type verifier struct {
+	mu         sync.Mutex
+	files      []restoredFile
+	mismatches []VerificationMismatch
+}
+
+func (v *verifier) add(relativePath string, f fs.File) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.files = append(v.files, restoredFile{relativePath, f})
+}
+
+func (v *verifier) addMismatch(m VerificationMismatch) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.mismatches = append(v.mismatches, m)
+}
+
+// verify reads back all restored files from the output and compares their hashes with
+// the hashes of the snapshot contents.
+func (c *copier) verify(ctx context.Context, numWorkers int) error {
+	vo, ok := c.output.(VerifiableOutput)
+	if !ok {
+		return errors.New("restore output does not support verification")
+	}
+
+	q := parallelwork.NewQueue()
+
+	for _, rf := range c.verifier.files {
+		q.EnqueueBack(ctx, func() error {
+			if m := verifyRestoredFile(ctx, vo, rf); m != nil {
+				log(ctx).Errorf("verification failed: %v", m)
+				c.verifier.addMismatch(*m)
+				c.stats.VerificationMismatchCount.Add(1)
+			}
+
+			c.stats.VerifiedFileCount.Add(1)
+			c.reportProgress(ctx)
+
+			return nil
+		})
+	}
+
+	if err := q.Process(ctx, numWorkers); err != nil {
+		return errors.Wrap(err, "verification error")
+	}
+
+	if len(c.verifier.mismatches) == 0 {
+		return nil
+	}
+
+	sort.Slice(c.verifier.mismatches, func(i, j int) bool {
+		return c.verifier.mismatches[i].Path < c.verifier.mismatches[j].Path
+	})
+
+	return &VerificationError{c.verifier.mismatches}
+}
+
+// verifyRestoredFile returns a mismatch if the restored file cannot be read or differs from the snapshot.
+func verifyRestoredFile(ctx context.Context, vo VerifiableOutput, rf restoredFile) *VerificationMismatch {
+	expected, err := hashSnapshotFile(ctx, rf.f)
+	if err != nil {
+		return &VerificationMismatch{Path: rf.relativePath, Error: "unable to read snapshot file: " + err.Error()}
+	}
+
+	actual, err := hashRestoredFile(ctx, vo, rf.relativePath)
+	if err != nil {
+		return &VerificationMismatch{Path: rf.relativePath, ExpectedHash: expected, Error: "unable to read restored file: " + err.Error()}
+	}
+
+	if actual != expected {
+		return &VerificationMismatch{Path: rf.relativePath, ExpectedHash: expected, ActualHash: actual}
+	}
+
+	return nil
+}
+
+func hashSnapshotFile(ctx context.Context, f fs.File) (string, error) {
+	r, err := f.Open(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "open")
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	return hashReader(r)
+}
+
+func hashRestoredFile(ctx context.Context, vo VerifiableOutput, relativePath string) (string, error) {
+	r, err := vo.OpenRestoredFile(ctx, relativePath)
+	if err != nil {
+		return "", errors.Wrap(err, "open")
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	return hashReader(r)
+}
+
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+
+	if _, err := io.Copy(h, r); err != nil {
+		return "", errors.Wrap(err, "read")
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
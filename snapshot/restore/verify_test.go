@@ -0,0 +1,106 @@
+package restore
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+// corruptingOutput appends garbage to selected files after they have been written.
+type corruptingOutput struct {
+	*FilesystemOutput
+
+	corrupt map[string]bool
+}
+
+func (o *corruptingOutput) WriteFile(ctx context.Context, relativePath string, f fs.File, progressCb FileWriteProgress) error {
+	if err := o.FilesystemOutput.WriteFile(ctx, relativePath, f, progressCb); err != nil {
+		return err
+	}
+
+	if !o.corrupt[relativePath] {
+		return nil
+	}
+
+	fh, err := os.OpenFile(filepath.Join(o.TargetPath, filepath.FromSlash(relativePath)), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	defer fh.Close()
+
+	_, err = fh.WriteString("garbage")
+
+	return err
+}
+
+func newVerifyTestOutput(t *testing.T, corrupt ...string) *corruptingOutput {
+	t.Helper()
+
+	fso := &FilesystemOutput{
+		TargetPath:     testutil.TempDirectory(t),
+		OverwriteFiles: true,
+	}
+
+	require.NoError(t, fso.Init(testlogging.Context(t)))
+
+	o := &corruptingOutput{FilesystemOutput: fso, corrupt: map[string]bool{}}
+
+	for _, p := range corrupt {
+		o.corrupt[p] = true
+	}
+
+	return o
+}
+
+func TestRestoreVerifyAfter(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := mockfs.NewDirectory()
+	root.AddFile("a", []byte("aaa"), 0o644)
+	root.AddDir("sub", 0o755).AddFile("b", []byte("bbb"), 0o644)
+	root.AddFile("c", []byte("ccc"), 0o644)
+
+	st, err := Entry(ctx, nil, newVerifyTestOutput(t), root, Options{VerifyAfter: true, RestoreDirEntryAtDepth: math.MaxInt32})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, st.VerifiedFileCount)
+	require.EqualValues(t, 0, st.VerificationMismatchCount)
+
+	st, err = Entry(ctx, nil, newVerifyTestOutput(t, "sub/b", "a"), root, Options{VerifyAfter: true, RestoreDirEntryAtDepth: math.MaxInt32})
+
+	var verr *VerificationError
+
+	require.True(t, errors.As(err, &verr), "unexpected error: %v", err)
+	require.EqualValues(t, 3, st.VerifiedFileCount)
+	require.EqualValues(t, 2, st.VerificationMismatchCount)
+	require.Len(t, verr.Mismatches, 2)
+	require.Equal(t, "a", verr.Mismatches[0].Path)
+	require.Equal(t, "sub/b", verr.Mismatches[1].Path)
+	require.NotEqual(t, verr.Mismatches[0].ExpectedHash, verr.Mismatches[0].ActualHash)
+	require.Empty(t, verr.Mismatches[0].Error)
+
+	// without verification corruption is not detected.
+	st, err = Entry(ctx, nil, newVerifyTestOutput(t, "a"), root, Options{RestoreDirEntryAtDepth: math.MaxInt32})
+	require.NoError(t, err)
+	require.EqualValues(t, 0, st.VerifiedFileCount)
+}
+
+func TestRestoreVerifyAfter_UnsupportedOutput(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	root := mockfs.NewDirectory()
+	root.AddFile("a", []byte("aaa"), 0o644)
+
+	_, err := Entry(ctx, nil, NewTarOutput(nil), root, Options{VerifyAfter: true, RestoreDirEntryAtDepth: math.MaxInt32})
+	require.ErrorContains(t, err, "does not support verification")
+}
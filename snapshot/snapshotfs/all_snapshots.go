@@ -0,0 +1,234 @@
+package snapshotfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/virtualfs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	allSnapshotsDateFormat = "2006-01-02"
+	allSnapshotsTimeFormat = "150405"
+)
+
+// repositoryAllSnapshots is a virtual directory that organizes all snapshots
+// of all sources as <source>/<date>/<time>.
+type repositoryAllSnapshots struct {
+	rep repo.Repository
+}
+
+func (s *repositoryAllSnapshots) IsDir() bool {
+	return true
+}
+
+func (s *repositoryAllSnapshots) Name() string {
+	return "/"
+}
+
+func (s *repositoryAllSnapshots) ModTime() time.Time {
+	return s.rep.Time()
+}
+
+func (s *repositoryAllSnapshots) Mode() os.FileMode {
+	return 0o555 | os.ModeDir //nolint:mnd
+}
+
+func (s *repositoryAllSnapshots) Size() int64 {
+	return 0
+}
+
+func (s *repositoryAllSnapshots) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func (s *repositoryAllSnapshots) Device() fs.DeviceInfo {
+	return fs.DeviceInfo{}
+}
+
+func (s *repositoryAllSnapshots) Sys() interface{} {
+	return nil
+}
+
+func (s *repositoryAllSnapshots) LocalFilesystemPath() string {
+	return ""
+}
+
+func (s *repositoryAllSnapshots) SupportsMultipleIterations() bool {
+	return true
+}
+
+func (s *repositoryAllSnapshots) Close() {
+}
+
+func (s *repositoryAllSnapshots) Child(ctx context.Context, name string) (fs.Entry, error) {
+	//nolint:wrapcheck
+	return fs.IterateEntriesAndFindChild(ctx, s, name)
+}
+
+func (s *repositoryAllSnapshots) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	srcs, err := snapshot.ListSources(ctx, s.rep)
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing sources")
+	}
+
+	name2safe := map[string]string{}
+
+	for _, src := range srcs {
+		name2safe[src.String()] = safeNameForMount(src.String())
+	}
+
+	name2safe = disambiguateSafeNames(name2safe)
+
+	var entries []fs.Entry
+
+	for _, src := range srcs {
+		entries = append(entries, &sourceSnapshotsByDate{
+			rep:  s.rep,
+			src:  src,
+			name: name2safe[src.String()],
+		})
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+// sourceSnapshotsByDate is a virtual directory containing snapshots of a single source grouped by date.
+type sourceSnapshotsByDate struct {
+	rep  repo.Repository
+	src  snapshot.SourceInfo
+	name string
+}
+
+func (s *sourceSnapshotsByDate) IsDir() bool {
+	return true
+}
+
+func (s *sourceSnapshotsByDate) Name() string {
+	return s.name
+}
+
+func (s *sourceSnapshotsByDate) Mode() os.FileMode {
+	return 0o555 | os.ModeDir //nolint:mnd
+}
+
+func (s *sourceSnapshotsByDate) Size() int64 {
+	return 0
+}
+
+func (s *sourceSnapshotsByDate) Sys() interface{} {
+	return nil
+}
+
+func (s *sourceSnapshotsByDate) ModTime() time.Time {
+	return s.rep.Time()
+}
+
+func (s *sourceSnapshotsByDate) Owner() fs.OwnerInfo {
+	return fs.OwnerInfo{}
+}
+
+func (s *sourceSnapshotsByDate) Device() fs.DeviceInfo {
+	return fs.DeviceInfo{}
+}
+
+func (s *sourceSnapshotsByDate) LocalFilesystemPath() string {
+	return ""
+}
+
+func (s *sourceSnapshotsByDate) SupportsMultipleIterations() bool {
+	return true
+}
+
+func (s *sourceSnapshotsByDate) Close() {
+}
+
+func (s *sourceSnapshotsByDate) Child(ctx context.Context, name string) (fs.Entry, error) {
+	//nolint:wrapcheck
+	return fs.IterateEntriesAndFindChild(ctx, s, name)
+}
+
+func (s *sourceSnapshotsByDate) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
+	manifests, err := snapshot.ListSnapshots(ctx, s.rep, s.src)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list snapshots")
+	}
+
+	byDate := map[string][]*snapshot.Manifest{}
+
+	for _, m := range manifests {
+		d := m.StartTime.Format(allSnapshotsDateFormat)
+		byDate[d] = append(byDate[d], m)
+	}
+
+	var dates []string
+
+	for d := range byDate {
+		dates = append(dates, d)
+	}
+
+	sort.Strings(dates)
+
+	var entries []fs.Entry
+
+	for _, d := range dates {
+		entries = append(entries, virtualfs.NewStaticDirectory(d, s.snapshotEntries(byDate[d])))
+	}
+
+	return fs.StaticIterator(entries, nil), nil
+}
+
+func (s *sourceSnapshotsByDate) snapshotEntries(manifests []*snapshot.Manifest) []fs.Entry {
+	// multiple snapshots may start within the same second, disambiguate their names.
+	name2safe := map[string]string{}
+
+	for _, m := range manifests {
+		name := m.StartTime.Format(allSnapshotsTimeFormat)
+		if m.IncompleteReason != "" {
+			name += fmt.Sprintf(" (%v)", m.IncompleteReason)
+		}
+
+		name2safe[string(m.ID)] = name
+	}
+
+	name2safe = disambiguateSafeNames(name2safe)
+
+	var entries []fs.Entry
+
+	for _, m := range manifests {
+		de := &snapshot.DirEntry{
+			Name:        name2safe[string(m.ID)],
+			Permissions: 0o555, //nolint:mnd
+			Type:        snapshot.EntryTypeDirectory,
+			ModTime:     m.StartTime,
+			ObjectID:    m.RootObjectID(),
+		}
+
+		if m.RootEntry != nil {
+			de.DirSummary = m.RootEntry.DirSummary
+		}
+
+		entries = append(entries, EntryFromDirEntry(s.rep, de))
+	}
+
+	return entries
+}
+
+// AllSnapshotsEntry returns fs.Directory that contains all snapshots of all sources found in the repository
+// organized as <source>/<date>/<time>. The tree is generated on the fly from snapshot manifests.
+func AllSnapshotsEntry(rep repo.Repository) fs.Directory {
+	return &repositoryAllSnapshots{rep: rep}
+}
+
+var (
+	_ fs.Directory = (*repositoryAllSnapshots)(nil)
+	_ fs.Directory = (*sourceSnapshotsByDate)(nil)
+)
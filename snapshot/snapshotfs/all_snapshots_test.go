@@ -0,0 +1,55 @@
+package snapshotfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestAllSnapshots(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	u := NewUploader(env.RepositoryWriter)
+	man, err := u.Upload(ctx, mockfs.NewDirectory(), nil, snapshot.SourceInfo{Host: "dummy", UserName: "dummy", Path: "dummy"})
+	require.NoError(t, err)
+
+	manifests := []struct {
+		user, host, path, timestamp string
+	}{
+		{"some-user", "some-host", "/some/path", "2020-01-01T12:01:03Z"},
+		{"some-user", "some-host", "/some/path", "2020-01-01T12:01:04Z"},
+		{"some-user", "some-host", "/some/path", "2020-01-02T08:00:00Z"},
+		// two snapshots starting within the same second
+		{"some-user", "some-host", "/some/path", "2020-01-02T08:00:00.5Z"},
+		{"another-user", "some-host", "/", "2020-01-01T12:01:03Z"},
+	}
+
+	for _, m := range manifests {
+		ts, err := time.Parse(time.RFC3339, m.timestamp)
+		require.NoError(t, err)
+
+		mustWriteSnapshotManifest(ctx, t, env.RepositoryWriter, snapshot.SourceInfo{UserName: m.user, Host: m.host, Path: m.path}, fs.UTCTimestampFromTime(ts), man)
+	}
+
+	gotNames := iterateAllNames(ctx, t, AllSnapshotsEntry(env.RepositoryWriter), "")
+	wantNames := map[string]struct{}{
+		"another-user@some-host/":                              {},
+		"another-user@some-host/2020-01-01/":                   {},
+		"another-user@some-host/2020-01-01/120103/":            {},
+		"some-user@some-host_some_path/":                       {},
+		"some-user@some-host_some_path/2020-01-01/":            {},
+		"some-user@some-host_some_path/2020-01-01/120103/":     {},
+		"some-user@some-host_some_path/2020-01-01/120104/":     {},
+		"some-user@some-host_some_path/2020-01-02/":            {},
+		"some-user@some-host_some_path/2020-01-02/080000/":     {},
+		"some-user@some-host_some_path/2020-01-02/080000 (2)/": {},
+	}
+
+	require.Equal(t, wantNames, gotNames)
+}
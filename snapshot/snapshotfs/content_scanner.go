@@ -0,0 +1,241 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+var scanLog = logging.Module("scanner")
+
+const (
+	// DefaultScanTimeout is the default maximum amount of time an external scanner may spend on a single file.
+	DefaultScanTimeout = 5 * time.Minute
+
+	// exit code of an external scanner indicating that the file has been flagged.
+	externalScannerFindingExitCode = 1
+
+	// maximum number of bytes of scanner output recorded in an annotation.
+	maxScanFindingLength = 1024
+)
+
+// ContentScanner inspects contents of files (for example for malware or personally identifiable information).
+type ContentScanner interface {
+	// Name returns the name of the scanner, which is recorded in annotations.
+	Name() string
+
+	// Scan reads the provided file contents and returns a non-empty finding if the file has been flagged.
+	Scan(ctx context.Context, relativePath string, r io.Reader) (finding string, err error)
+}
+
+// ExternalScanner is a ContentScanner that streams file contents to the standard input of an external process.
+//
+// The process is invoked once per file with KOPIA_SCAN_PATH environment variable set to the path
+// of the file relative to the snapshot root. Exit code 0 means the file is clean, exit code 1 means
+// the file has been flagged and the standard output describes the finding, any other exit code
+// is treated as a scanner error.
+type ExternalScanner struct {
+	ScannerName string
+	Command     string
+	Arguments   []string
+	Timeout     time.Duration
+}
+
+// Name implements ContentScanner.
+func (s *ExternalScanner) Name() string {
+	if s.ScannerName != "" {
+		return s.ScannerName
+	}
+
+	return s.Command
+}
+
+// Scan implements ContentScanner.
+func (s *ExternalScanner) Scan(ctx context.Context, relativePath string, r io.Reader) (string, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultScanTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	c := exec.CommandContext(ctx, s.Command, s.Arguments...) //nolint:gosec
+	c.Env = append(os.Environ(),
+		"KOPIA_SCAN_PATH="+relativePath,
+		fmt.Sprintf("KOPIA_VERSION=%v", repo.BuildVersion),
+	)
+	c.Stdin = r
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	err := c.Run()
+	if err == nil {
+		return "", nil
+	}
+
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && ee.ExitCode() == externalScannerFindingExitCode {
+		finding := truncateFinding(strings.TrimSpace(stdout.String()))
+		if finding == "" {
+			finding = "flagged"
+		}
+
+		return finding, nil
+	}
+
+	return "", errors.Wrapf(err, "scanner %v failed: %v", s.Name(), truncateFinding(strings.TrimSpace(stderr.String())))
+}
+
+func truncateFinding(s string) string {
+	if len(s) > maxScanFindingLength {
+		return s[0:maxScanFindingLength] + "..."
+	}
+
+	return s
+}
+
+// scanResult is the outcome of scanning a single object.
+type scanResult struct {
+	finding string
+	err     string
+}
+
+// annotationCollector runs the content scanner on stored objects and accumulates annotations
+// for files that have been flagged or could not be scanned. Successful results are cached by object ID,
+// so identical contents are only scanned once.
+type annotationCollector struct {
+	rep     repo.Repository
+	scanner ContentScanner
+
+	mu sync.Mutex
+	// +checklocks:mu
+	results map[object.ID]scanResult
+	// +checklocks:mu
+	annotations []snapshot.Annotation
+}
+
+func newAnnotationCollector(rep repo.Repository, scanner ContentScanner) *annotationCollector {
+	return &annotationCollector{
+		rep:     rep,
+		scanner: scanner,
+		results: map[object.ID]scanResult{},
+	}
+}
+
+func (c *annotationCollector) cachedResult(oid object.ID) (scanResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.results[oid]
+
+	return r, ok
+}
+
+// scanObject scans the contents of the provided object and records an annotation for relativePath if needed.
+// Scanner failures are recorded as annotations, errors are only returned when the object can't be read.
+func (c *annotationCollector) scanObject(ctx context.Context, oid object.ID, relativePath string) error {
+	if r, ok := c.cachedResult(oid); ok {
+		c.record(relativePath, r)
+		return nil
+	}
+
+	or, err := c.rep.OpenObject(ctx, oid)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open object %v", oid)
+	}
+	defer or.Close() //nolint:errcheck
+
+	tr := &readErrorTrackingReader{r: or}
+
+	finding, err := c.scanner.Scan(ctx, relativePath, tr)
+	if tr.err != nil {
+		return errors.Wrapf(tr.err, "error reading object %v", oid)
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "scan canceled")
+	}
+
+	var res scanResult
+
+	if err != nil {
+		scanLog(ctx).Warnf("unable to scan %v: %v", relativePath, err)
+
+		res.err = err.Error()
+	} else {
+		c.mu.Lock()
+		c.results[oid] = scanResult{finding: finding}
+		c.mu.Unlock()
+	}
+
+	res.finding = finding
+
+	if finding != "" {
+		scanLog(ctx).Warnf("%v flagged %v: %v", c.scanner.Name(), relativePath, finding)
+	}
+
+	c.record(relativePath, res)
+
+	return nil
+}
+
+func (c *annotationCollector) record(relativePath string, r scanResult) {
+	if r.finding == "" && r.err == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.annotations = append(c.annotations, snapshot.Annotation{
+		Scanner: c.scanner.Name(),
+		Path:    relativePath,
+		Finding: r.finding,
+		Error:   r.err,
+		Time:    fs.UTCTimestampFromTime(c.rep.Time()),
+	})
+}
+
+// readErrorTrackingReader remembers the first error other than io.EOF returned by the underlying reader,
+// so that data errors can be distinguished from scanner failures.
+type readErrorTrackingReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrorTrackingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if err != nil && !errors.Is(err, io.EOF) && r.err == nil {
+		r.err = err
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// take returns annotations collected so far and resets the list, cached scan results are retained.
+func (c *annotationCollector) take() []snapshot.Annotation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := c.annotations
+	c.annotations = nil
+
+	return result
+}
@@ -0,0 +1,117 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// testScanner flags files whose contents contain a marker and fails on files containing another one.
+type testScanner struct {
+	mu      sync.Mutex
+	scanned []string
+}
+
+func (s *testScanner) Name() string { return "test" }
+
+func (s *testScanner) Scan(ctx context.Context, relativePath string, r io.Reader) (string, error) {
+	s.mu.Lock()
+	s.scanned = append(s.scanned, relativePath)
+	s.mu.Unlock()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", errors.Wrap(err, "read error")
+	}
+
+	switch {
+	case bytes.Contains(data, []byte("EICAR")):
+		return "test signature", nil
+	case bytes.Contains(data, []byte("BROKEN")):
+		return "", errors.New("scanner crashed")
+	default:
+		return "", nil
+	}
+}
+
+func TestUploadWithContentScanner(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddFile("infected", []byte("xxEICARxx"), defaultPermissions)
+	th.sourceDir.AddFile("d1/infected-copy", []byte("xxEICARxx"), defaultPermissions)
+	th.sourceDir.AddFile("d2/unscannable", []byte("BROKEN"), defaultPermissions)
+
+	sc := &testScanner{}
+
+	u := NewUploader(th.repo)
+	u.Scanner = sc
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	require.Len(t, man.Annotations, 3)
+
+	require.Equal(t, "d1/infected-copy", man.Annotations[0].Path)
+	require.Equal(t, "test signature", man.Annotations[0].Finding)
+	require.Equal(t, "test", man.Annotations[0].Scanner)
+
+	require.Equal(t, "d2/unscannable", man.Annotations[1].Path)
+	require.Empty(t, man.Annotations[1].Finding)
+	require.Contains(t, man.Annotations[1].Error, "scanner crashed")
+
+	require.Equal(t, "infected", man.Annotations[2].Path)
+
+	// identical contents are only scanned once.
+	require.Len(t, sc.scanned, 5)
+
+	// unchanged files are scanned again in subsequent snapshots.
+	sc2 := &testScanner{}
+	u.Scanner = sc2
+
+	man2, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{}, man)
+	require.NoError(t, err)
+	require.Len(t, man2.Annotations, 3)
+	require.NotEmpty(t, sc2.scanned)
+}
+
+func TestExternalScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires POSIX shell")
+	}
+
+	ctx := testlogging.Context(t)
+
+	s := &ExternalScanner{
+		Command:   "sh",
+		Arguments: []string{"-c", `if grep -q EICAR; then echo "found in $KOPIA_SCAN_PATH"; exit 1; fi; if [ "$KOPIA_SCAN_PATH" = "fail" ]; then echo oops >&2; exit 2; fi`},
+	}
+
+	require.Equal(t, "sh", s.Name())
+
+	finding, err := s.Scan(ctx, "clean", strings.NewReader("hello"))
+	require.NoError(t, err)
+	require.Empty(t, finding)
+
+	finding, err = s.Scan(ctx, "some/file", strings.NewReader("xxEICARxx"))
+	require.NoError(t, err)
+	require.Equal(t, "found in some/file", finding)
+
+	_, err = s.Scan(ctx, "fail", strings.NewReader("hello"))
+	require.ErrorContains(t, err, "oops")
+}
@@ -0,0 +1,236 @@
+package snapshotfs
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/bigmap"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// OwnerDedupStats describes contents referenced by snapshots of a single user@host pair
+// and how many of them are shared with other pairs. All byte counts refer to contents
+// as stored in the repository.
+type OwnerDedupStats struct {
+	UserName      string `json:"userName"`
+	Host          string `json:"host"`
+	SnapshotCount int    `json:"snapshotCount"`
+
+	// +checkatomic
+	ContentCount int64 `json:"contentCount"`
+	// +checkatomic
+	Bytes int64 `json:"bytes"`
+
+	// contents referenced only by this user@host pair.
+	// +checkatomic
+	UniqueContentCount int64 `json:"uniqueContentCount"`
+	// +checkatomic
+	UniqueBytes int64 `json:"uniqueBytes"`
+
+	// contents also referenced by other user@host pairs, which are stored only once.
+	// +checkatomic
+	SharedContentCount int64 `json:"sharedContentCount"`
+	// +checkatomic
+	SharedBytes int64 `json:"sharedBytes"`
+}
+
+// DedupReport attributes contents referenced by snapshots in the repository to user@host pairs.
+type DedupReport struct {
+	// distinct contents referenced by all snapshots.
+	ContentCount int64 `json:"contentCount"`
+	Bytes        int64 `json:"bytes"`
+
+	// distinct contents referenced by snapshots of more than one user@host pair.
+	SharedContentCount int64 `json:"sharedContentCount"`
+	SharedBytes        int64 `json:"sharedBytes"`
+
+	// per user@host statistics, ordered by the number of shared bytes, descending.
+	Owners []*OwnerDedupStats `json:"owners"`
+}
+
+type dedupOwner struct {
+	stats     *OwnerDedupStats
+	manifests []*snapshot.Manifest
+}
+
+// CalculateDedupReport walks all snapshots in the repository and determines, for each user@host pair,
+// how many of the contents referenced by its snapshots are unique to it and how many are shared with
+// other pairs, which indicates how much each client benefits from cross-client deduplication.
+func CalculateDedupReport(ctx context.Context, rep repo.Repository) (*DedupReport, error) {
+	owners, err := listDedupOwners(ctx, rep)
+	if err != nil {
+		return nil, err
+	}
+
+	// contents referenced by at least one and at least two owners, respectively.
+	seen, err := bigmap.NewSet(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewSet")
+	}
+
+	defer seen.Close(ctx)
+
+	shared, err := bigmap.NewSet(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewSet")
+	}
+
+	defer shared.Close(ctx)
+
+	report := &DedupReport{}
+
+	// first pass finds contents shared between owners.
+	for _, o := range owners {
+		if err := walkOwnerContents(ctx, rep, o.manifests, func(cid []byte, packedLength int64) {
+			o.stats.addContent(packedLength)
+
+			if seen.Put(ctx, cid) {
+				atomic.AddInt64(&report.ContentCount, 1)
+				atomic.AddInt64(&report.Bytes, packedLength)
+			} else if shared.Put(ctx, cid) {
+				atomic.AddInt64(&report.SharedContentCount, 1)
+				atomic.AddInt64(&report.SharedBytes, packedLength)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// second pass classifies contents of each owner, which could not be done before knowing all owners.
+	for _, o := range owners {
+		if err := walkOwnerContents(ctx, rep, o.manifests, func(cid []byte, packedLength int64) {
+			o.stats.classifyContent(shared.Contains(cid), packedLength)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, o := range owners {
+		report.Owners = append(report.Owners, o.stats)
+	}
+
+	sort.SliceStable(report.Owners, func(i, j int) bool {
+		return report.Owners[i].SharedBytes > report.Owners[j].SharedBytes
+	})
+
+	return report, nil
+}
+
+func (s *OwnerDedupStats) addContent(packedLength int64) {
+	atomic.AddInt64(&s.ContentCount, 1)
+	atomic.AddInt64(&s.Bytes, packedLength)
+}
+
+func (s *OwnerDedupStats) classifyContent(isShared bool, packedLength int64) {
+	if isShared {
+		atomic.AddInt64(&s.SharedContentCount, 1)
+		atomic.AddInt64(&s.SharedBytes, packedLength)
+	} else {
+		atomic.AddInt64(&s.UniqueContentCount, 1)
+		atomic.AddInt64(&s.UniqueBytes, packedLength)
+	}
+}
+
+// listDedupOwners returns snapshot manifests grouped by user@host, ordered by user@host.
+func listDedupOwners(ctx context.Context, rep repo.Repository) ([]*dedupOwner, error) {
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list snapshot manifests")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshot manifests")
+	}
+
+	byOwner := map[string]*dedupOwner{}
+
+	var result []*dedupOwner
+
+	for _, m := range manifests {
+		key := m.Source.UserName + "@" + m.Source.Host
+
+		o := byOwner[key]
+		if o == nil {
+			o = &dedupOwner{stats: &OwnerDedupStats{UserName: m.Source.UserName, Host: m.Source.Host}}
+			byOwner[key] = o
+			result = append(result, o)
+		}
+
+		o.stats.SnapshotCount++
+		o.manifests = append(o.manifests, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].stats.UserName != result[j].stats.UserName {
+			return result[i].stats.UserName < result[j].stats.UserName
+		}
+
+		return result[i].stats.Host < result[j].stats.Host
+	})
+
+	return result, nil
+}
+
+// walkOwnerContents invokes the provided callback exactly once for each distinct content referenced
+// by the provided snapshots. The callback may be invoked concurrently.
+func walkOwnerContents(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, callback func(cid []byte, packedLength int64)) error {
+	visited, err := bigmap.NewSet(ctx)
+	if err != nil {
+		return errors.Wrap(err, "NewSet")
+	}
+
+	defer visited.Close(ctx)
+
+	tw, twerr := NewTreeWalker(ctx, TreeWalkerOptions{
+		EntryCallback: func(ctx context.Context, _ fs.Entry, oid object.ID, _ string) error {
+			contentIDs, err := rep.VerifyObject(ctx, oid)
+			if err != nil {
+				return errors.Wrapf(err, "error verifying object %v", oid)
+			}
+
+			var cidbuf [128]byte
+
+			for _, cid := range contentIDs {
+				key := cid.Append(cidbuf[:0])
+
+				if !visited.Put(ctx, key) {
+					continue
+				}
+
+				info, err := rep.ContentInfo(ctx, cid)
+				if err != nil {
+					return errors.Wrapf(err, "error getting content info for %v", cid)
+				}
+
+				callback(key, int64(info.PackedLength))
+			}
+
+			return nil
+		},
+	})
+	if twerr != nil {
+		return errors.Wrap(twerr, "tree walker")
+	}
+
+	defer tw.Close(ctx)
+
+	for _, m := range manifests {
+		root, err := SnapshotRoot(rep, m)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get snapshot root for %v", m.Source)
+		}
+
+		if err := tw.Process(ctx, root, m.Source.String()); err != nil {
+			return errors.Wrapf(err, "error processing %v", m.Source)
+		}
+	}
+
+	return nil
+}
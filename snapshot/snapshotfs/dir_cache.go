@@ -0,0 +1,153 @@
+package snapshotfs
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// DefaultDirectoryCacheEntries is the default total number of directory entries held by the directory cache.
+const DefaultDirectoryCacheEntries = 100000
+
+// directoryCache is an in-memory LRU cache of decoded directory objects, which avoids fetching and
+// decoding the same directories repeatedly when browsing snapshots, for example using mount or diff.
+type directoryCache struct {
+	mu sync.Mutex
+
+	maxEntries   int
+	totalEntries int
+	lru          *list.List // of *dirCacheItem, most recently used first
+	items        map[dirCacheKey]*list.Element
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// dirCacheKey identifies a directory object read using a particular repository connection,
+// since connections may differ in which contents they can read, for example because of
+// encryption domains or contents deleted after the directory was cached.
+type dirCacheKey struct {
+	connectionID string
+	oid          object.ID
+}
+
+type dirCacheItem struct {
+	key     dirCacheKey
+	entries []*snapshot.DirEntry
+	summary *fs.DirectorySummary
+}
+
+//nolint:gochecknoglobals
+var globalDirectoryCache atomic.Pointer[directoryCache]
+
+// EnableDirectoryCache enables the process-wide cache of decoded directory objects holding up to
+// the provided total number of directory entries. Passing zero disables the cache.
+func EnableDirectoryCache(maxEntries int) {
+	if maxEntries <= 0 {
+		globalDirectoryCache.Store(nil)
+		return
+	}
+
+	globalDirectoryCache.Store(newDirectoryCache(maxEntries))
+}
+
+// DirectoryCacheStats returns the number of hits and misses of the directory cache since it was enabled.
+func DirectoryCacheStats() (hits, misses int64) {
+	c := globalDirectoryCache.Load()
+	if c == nil {
+		return 0, 0
+	}
+
+	return c.hits.Load(), c.misses.Load()
+}
+
+func newDirectoryCache(maxEntries int) *directoryCache {
+	return &directoryCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		items:      map[dirCacheKey]*list.Element{},
+	}
+}
+
+// directoryCacheKey returns the cache key of the provided directory object, or false if
+// directories of the repository can't be cached.
+func directoryCacheKey(rep repo.Repository, oid object.ID) (dirCacheKey, bool) {
+	c, ok := rep.(interface{ ConnectionID() string })
+	if !ok {
+		return dirCacheKey{}, false
+	}
+
+	return dirCacheKey{c.ConnectionID(), oid}, true
+}
+
+func (c *directoryCache) contains(key dirCacheKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.items[key]
+
+	return ok
+}
+
+// get returns copies of cached entries of the directory, so that callers are free to modify them.
+func (c *directoryCache) get(key dirCacheKey) ([]*snapshot.DirEntry, *fs.DirectorySummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, nil, false
+	}
+
+	c.hits.Add(1)
+	c.lru.MoveToFront(e)
+
+	it := e.Value.(*dirCacheItem) //nolint:forcetypeassert
+
+	return cloneDirEntries(it.entries), it.summary, true
+}
+
+func (c *directoryCache) put(key dirCacheKey, entries []*snapshot.DirEntry, summary *fs.DirectorySummary) {
+	if itemWeight(entries) > c.maxEntries {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&dirCacheItem{key, cloneDirEntries(entries), summary})
+	c.totalEntries += itemWeight(entries)
+
+	for c.totalEntries > c.maxEntries {
+		it := c.lru.Remove(c.lru.Back()).(*dirCacheItem) //nolint:forcetypeassert
+
+		delete(c.items, it.key)
+		c.totalEntries -= itemWeight(it.entries)
+	}
+}
+
+// itemWeight returns the weight of a cached directory, which also accounts for empty directories.
+func itemWeight(entries []*snapshot.DirEntry) int {
+	return len(entries) + 1
+}
+
+func cloneDirEntries(entries []*snapshot.DirEntry) []*snapshot.DirEntry {
+	result := make([]*snapshot.DirEntry, len(entries))
+
+	for i, e := range entries {
+		c := *e
+		result[i] = &c
+	}
+
+	return result
+}
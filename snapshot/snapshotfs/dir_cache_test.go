@@ -0,0 +1,118 @@
+package snapshotfs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestDirectoryCacheEviction(t *testing.T) {
+	c := newDirectoryCache(5)
+
+	entries := func(n int) []*snapshot.DirEntry {
+		var result []*snapshot.DirEntry
+
+		for range n {
+			result = append(result, &snapshot.DirEntry{Name: "x"})
+		}
+
+		return result
+	}
+
+	key := func(n int) dirCacheKey {
+		oid, err := object.ParseID(fmt.Sprintf("%032x", n))
+		require.NoError(t, err)
+
+		return dirCacheKey{"connection", oid}
+	}
+
+	c.put(key(1), entries(1), nil)
+	c.put(key(2), entries(1), nil)
+
+	// too large to be cached.
+	c.put(key(3), entries(5), nil)
+	require.False(t, c.contains(key(3)))
+
+	ent, _, ok := c.get(key(1))
+	require.True(t, ok)
+	require.Len(t, ent, 1)
+
+	// returned entries are copies.
+	ent[0].Name = "y"
+
+	ent, _, ok = c.get(key(1))
+	require.True(t, ok)
+	require.Equal(t, "x", ent[0].Name)
+
+	// k2 is least recently used and gets evicted.
+	c.put(key(4), entries(1), nil)
+	require.True(t, c.contains(key(1)))
+	require.False(t, c.contains(key(2)))
+	require.True(t, c.contains(key(4)))
+
+	// same object ID read using a different connection is not shared.
+	require.False(t, c.contains(dirCacheKey{"other-connection", key(1).oid}))
+}
+
+func TestDirectoryCacheBrowse(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	EnableDirectoryCache(DefaultDirectoryCacheEntries)
+	defer EnableDirectoryCache(0)
+
+	u := NewUploader(th.repo)
+	u.disableEstimation = true
+
+	man, err := u.Upload(ctx, th.sourceDir, policy.BuildTree(nil, policy.DefaultPolicy), snapshot.SourceInfo{UserName: "user", Host: "host", Path: "path"})
+	require.NoError(t, err)
+	require.NoError(t, th.repo.Flush(ctx))
+
+	listNames := func(rep repo.Repository) []string {
+		root := DirectoryEntry(rep, man.RootObjectID(), nil)
+
+		d1, err := root.Child(ctx, "d1")
+		require.NoError(t, err)
+
+		var names []string
+
+		require.NoError(t, fs.IterateEntries(ctx, d1.(fs.Directory), func(_ context.Context, e fs.Entry) error {
+			names = append(names, e.Name())
+			return nil
+		}))
+
+		return names
+	}
+
+	first := listNames(th.repo)
+	hits, _ := DirectoryCacheStats()
+
+	// browsing again uses decoded directories from the cache.
+	require.ElementsMatch(t, first, listNames(th.repo))
+
+	hits2, _ := DirectoryCacheStats()
+	require.GreaterOrEqual(t, hits2-hits, int64(2))
+
+	// other connections to the same repository don't use directories cached by this one.
+	rep2, err := repo.Open(ctx, filepath.Join(th.repoDir, ".kopia.config"), masterPassword, &repo.Options{})
+	require.NoError(t, err)
+
+	defer rep2.Close(ctx)
+
+	require.ElementsMatch(t, first, listNames(rep2))
+
+	hits3, _ := DirectoryCacheStats()
+	require.Equal(t, hits2, hits3)
+}
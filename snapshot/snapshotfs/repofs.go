@@ -2,6 +2,7 @@
 package snapshotfs
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -21,6 +22,14 @@
 	objectIDPrefixDirectory = "k"
 )
 
+// maxPreloadedSubdirectories is the maximum number of subdirectory manifests of a directory read in a single batch.
+const maxPreloadedSubdirectories = 1000
+
+// batchObjectReader is implemented by repositories that can read many small objects using batched content retrieval.
+type batchObjectReader interface {
+	ReadObjects(ctx context.Context, ids []object.ID) ([][]byte, error)
+}
+
 type repositoryEntry struct {
 	metadata *snapshot.DirEntry
 	repo     repo.Repository
@@ -38,6 +47,8 @@ func (e *repositoryEntry) Mode() os.FileMode {
 		return os.ModeSymlink | os.FileMode(e.metadata.Permissions) //nolint:gosec
 	case snapshot.EntryTypeFile:
 		return os.FileMode(e.metadata.Permissions) //nolint:gosec
+	case snapshot.EntryTypeNamedPipe, snapshot.EntryTypeSocket, snapshot.EntryTypeCharDevice, snapshot.EntryTypeBlockDevice:
+		return e.metadata.Type.SpecialFileMode() | os.FileMode(e.metadata.Permissions) //nolint:gosec
 	case snapshot.EntryTypeUnknown:
 		return 0
 	default:
@@ -93,6 +104,12 @@ type repositoryDirectory struct {
 	mu         sync.Mutex
 	summary    *fs.DirectorySummary
 	dirEntries map[string]*snapshot.DirEntry
+
+	// contents of the directory object, if read ahead of time by the parent directory.
+	data []byte
+
+	// contents of subdirectory objects read ahead of time, keyed by name.
+	preloaded map[string][]byte
 }
 
 type repositoryFile struct {
@@ -103,6 +120,10 @@ type repositorySymlink struct {
 	repositoryEntry
 }
 
+type repositorySpecialFile struct {
+	repositoryEntry
+}
+
 type repositoryEntryError struct {
 	repositoryEntry
 	err error
@@ -130,7 +151,7 @@ func (rd *repositoryDirectory) Child(ctx context.Context, name string) (fs.Entry
 		return nil, fs.ErrEntryNotFound
 	}
 
-	return EntryFromDirEntry(rd.repo, de), nil
+	return rd.childEntry(de), nil
 }
 
 func (rd *repositoryDirectory) Iterate(ctx context.Context) (fs.DirectoryIterator, error) {
@@ -141,12 +162,30 @@ func (rd *repositoryDirectory) Iterate(ctx context.Context) (fs.DirectoryIterato
 	var entries []fs.Entry
 
 	for _, de := range rd.dirEntries {
-		entries = append(entries, EntryFromDirEntry(rd.repo, de))
+		entries = append(entries, rd.childEntry(de))
 	}
 
 	return fs.StaticIterator(entries, nil), nil
 }
 
+// childEntry returns the entry for the provided child, handing over its preloaded contents if available.
+func (rd *repositoryDirectory) childEntry(de *snapshot.DirEntry) fs.Entry {
+	e := EntryFromDirEntry(rd.repo, de)
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	if data, ok := rd.preloaded[de.Name]; ok {
+		if sd, ok := e.(*repositoryDirectory); ok {
+			sd.data = data
+		}
+
+		delete(rd.preloaded, de.Name)
+	}
+
+	return e
+}
+
 func (rd *repositoryDirectory) ensureDirEntriesLoaded(ctx context.Context) error {
 	rd.mu.Lock()
 	defer rd.mu.Unlock()
@@ -170,11 +209,32 @@ func (rd *repositoryDirectory) ensureSummary(ctx context.Context) error {
 }
 
 func (rd *repositoryDirectory) loadLocked(ctx context.Context) error {
-	r, err := rd.repo.OpenObject(ctx, rd.metadata.ObjectID)
-	if err != nil {
-		return errors.Wrapf(err, "unable to open object: %v", rd.metadata.ObjectID)
+	cache := globalDirectoryCache.Load()
+	cacheKey, cacheable := directoryCacheKey(rd.repo, rd.metadata.ObjectID)
+
+	if cache != nil && cacheable {
+		if ent, summ, ok := cache.get(cacheKey); ok {
+			rd.data = nil
+			rd.setEntriesLocked(ent, summ)
+
+			return nil
+		}
+	}
+
+	var r io.Reader
+
+	if rd.data != nil {
+		r = bytes.NewReader(rd.data)
+		rd.data = nil
+	} else {
+		or, err := rd.repo.OpenObject(ctx, rd.metadata.ObjectID)
+		if err != nil {
+			return errors.Wrapf(err, "unable to open object: %v", rd.metadata.ObjectID)
+		}
+		defer or.Close() //nolint:errcheck
+
+		r = or
 	}
-	defer r.Close() //nolint:errcheck
 
 	ent, summ, err := readDirEntries(r)
 	if err != nil {
@@ -188,14 +248,77 @@ func (rd *repositoryDirectory) loadLocked(ctx context.Context) error {
 		}
 	}
 
+	if cache != nil && cacheable {
+		cache.put(cacheKey, ent, summ)
+	}
+
+	rd.setEntriesLocked(ent, summ)
+	rd.preloadSubdirectoriesLocked(ctx, ent)
+
+	return nil
+}
+
+func (rd *repositoryDirectory) setEntriesLocked(ent []*snapshot.DirEntry, summ *fs.DirectorySummary) {
 	rd.summary = summ
 	rd.dirEntries = map[string]*snapshot.DirEntry{}
 
 	for _, e := range ent {
 		rd.dirEntries[e.Name] = e
 	}
+}
+
+// preloadSubdirectoriesLocked reads manifests of subdirectories in a single batch, so that walking
+// the directory tree does not issue a separate storage request for each directory.
+func (rd *repositoryDirectory) preloadSubdirectoriesLocked(ctx context.Context, ent []*snapshot.DirEntry) {
+	br, ok := rd.repo.(batchObjectReader)
+	if !ok {
+		return
+	}
 
-	return nil
+	var (
+		names []string
+		oids  []object.ID
+	)
+
+	cache := globalDirectoryCache.Load()
+
+	for _, e := range ent {
+		if e.Type != snapshot.EntryTypeDirectory {
+			continue
+		}
+
+		if cache != nil {
+			if key, ok := directoryCacheKey(rd.repo, e.ObjectID); ok && cache.contains(key) {
+				// no need to read cached subdirectories.
+				continue
+			}
+		}
+
+		if len(oids) >= maxPreloadedSubdirectories {
+			break
+		}
+
+		names = append(names, e.Name)
+		oids = append(oids, e.ObjectID)
+	}
+
+	if len(oids) < 2 { //nolint:mnd
+		// nothing to gain from batching.
+		return
+	}
+
+	data, err := br.ReadObjects(ctx, oids)
+	if err != nil {
+		// not fatal, subdirectories will be read individually and report errors when accessed.
+		repoFSLog(ctx).Debugf("unable to preload subdirectories of %v: %v", rd.metadata.ObjectID, err)
+		return
+	}
+
+	rd.preloaded = make(map[string][]byte, len(names))
+
+	for i, n := range names {
+		rd.preloaded[n] = data[i]
+	}
 }
 
 func (rd *repositoryDirectory) Close() {
@@ -203,6 +326,7 @@ func (rd *repositoryDirectory) Close() {
 	defer rd.mu.Unlock()
 
 	rd.dirEntries = nil
+	rd.preloaded = nil
 }
 
 func (rf *repositoryFile) Open(ctx context.Context) (fs.Reader, error) {
@@ -234,6 +358,14 @@ func (rsl *repositorySymlink) Resolve(ctx context.Context) (fs.Entry, error) {
 	return nil, errors.New("Symlink.Resolve not implemented in Repofs")
 }
 
+func (rsl *repositorySymlink) SymlinkType() fs.SymlinkType {
+	return rsl.metadata.SymlinkType
+}
+
+func (rsf *repositorySpecialFile) RawDevice() uint64 {
+	return rsf.metadata.Device
+}
+
 func (ee *repositoryEntryError) ErrorInfo() error {
 	return ee.err
 }
@@ -255,6 +387,9 @@ func EntryFromDirEntry(r repo.Repository, md *snapshot.DirEntry) fs.Entry {
 	case snapshot.EntryTypeFile:
 		return fs.File(&repositoryFile{re})
 
+	case snapshot.EntryTypeNamedPipe, snapshot.EntryTypeSocket, snapshot.EntryTypeCharDevice, snapshot.EntryTypeBlockDevice:
+		return fs.SpecialFile(&repositorySpecialFile{re})
+
 	default:
 		return fs.ErrorEntry(&repositoryEntryError{re, fs.ErrUnknown})
 	}
@@ -352,13 +487,16 @@ func IsDirectoryID(oid object.ID) bool {
 }
 
 var (
-	_ fs.Directory = (*repositoryDirectory)(nil)
-	_ fs.File      = (*repositoryFile)(nil)
-	_ fs.Symlink   = (*repositorySymlink)(nil)
+	_ fs.Directory    = (*repositoryDirectory)(nil)
+	_ fs.File         = (*repositoryFile)(nil)
+	_ fs.Symlink      = (*repositorySymlink)(nil)
+	_ fs.TypedSymlink = (*repositorySymlink)(nil)
+	_ fs.SpecialFile  = (*repositorySpecialFile)(nil)
 )
 
 var (
 	_ snapshot.HasDirEntry = (*repositoryDirectory)(nil)
 	_ snapshot.HasDirEntry = (*repositoryFile)(nil)
 	_ snapshot.HasDirEntry = (*repositorySymlink)(nil)
+	_ snapshot.HasDirEntry = (*repositorySpecialFile)(nil)
 )
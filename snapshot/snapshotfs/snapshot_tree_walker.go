@@ -11,6 +11,7 @@
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/bigmap"
 	"github.com/kopia/kopia/internal/workshare"
+	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
 
@@ -111,6 +112,10 @@ func (w *TreeWalker) processDirEntry(ctx context.Context, dir fs.Directory, entr
 	defer ag.Close()
 
 	iter, err := dir.Iterate(ctx)
+	if w.isLockedDirectory(ctx, err, entryPath) {
+		return
+	}
+
 	if err != nil {
 		w.ReportError(ctx, entryPath, errors.Wrap(err, "error reading directory"))
 
@@ -142,11 +147,23 @@ func (w *TreeWalker) processDirEntry(ctx context.Context, dir fs.Directory, entr
 		ent, err = iter.Next(ctx)
 	}
 
-	if err != nil {
+	if err != nil && !w.isLockedDirectory(ctx, err, entryPath) {
 		w.ReportError(ctx, entryPath, errors.Wrap(err, "error reading directory"))
 	}
 }
 
+// isLockedDirectory returns true and invokes LockedDirectoryCallback if the error is caused by the directory
+// being in an encryption domain which is not unlocked by this connection.
+func (w *TreeWalker) isLockedDirectory(ctx context.Context, err error, entryPath string) bool {
+	if w.options.LockedDirectoryCallback == nil || !errors.Is(err, content.ErrEncryptionDomainLocked) {
+		return false
+	}
+
+	w.options.LockedDirectoryCallback(ctx, entryPath)
+
+	return true
+}
+
 // Process processes the snapshot tree entry.
 func (w *TreeWalker) Process(ctx context.Context, e fs.Entry, entryPath string) error {
 	if oidOf(e) == object.EmptyID {
@@ -172,6 +189,10 @@ func (w *TreeWalker) Close(ctx context.Context) {
 type TreeWalkerOptions struct {
 	EntryCallback EntryCallback
 
+	// LockedDirectoryCallback is invoked instead of reporting an error when a directory can't be read
+	// because it is in an encryption domain which is not unlocked by this connection.
+	LockedDirectoryCallback func(ctx context.Context, entryPath string)
+
 	Parallelism int
 	MaxErrors   int
 }
@@ -12,12 +12,15 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/formatcheck"
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/timetrack"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
 )
 
 var verifierLog = logging.Module("verifier")
@@ -31,8 +34,11 @@ type verifyFileWorkItem struct {
 type Verifier struct {
 	throttle timetrack.Throttle
 
-	queued    atomic.Int32
-	processed atomic.Int32
+	queued        atomic.Int32
+	processed     atomic.Int32
+	formatChecked atomic.Int32
+	locked        atomic.Int32
+	lockedDirs    atomic.Int32
 
 	fileWorkQueue chan verifyFileWorkItem
 	rep           repo.Repository
@@ -40,6 +46,8 @@ type Verifier struct {
 	workersWG     sync.WaitGroup
 
 	blobMap map[blob.ID]blob.Metadata // when != nil, will check that each backing blob exists
+
+	annotations *annotationCollector // when != nil, contents of each file are scanned
 }
 
 // ShowStats logs verification statistics.
@@ -54,6 +62,31 @@ func (v *Verifier) ShowFinalStats(ctx context.Context) {
 	processed := v.processed.Load()
 
 	verifierLog(ctx).Infof("Finished processing %v objects.", processed)
+
+	if n := v.formatChecked.Load(); n > 0 {
+		verifierLog(ctx).Infof("Validated file format of %v files.", n)
+	}
+
+	if n := v.locked.Load(); n > 0 {
+		verifierLog(ctx).Infof("Skipped %v files in locked encryption domains.", n)
+	}
+
+	if n := v.lockedDirs.Load(); n > 0 {
+		verifierLog(ctx).Infof("Skipped %v directories in locked encryption domains.", n)
+	}
+}
+
+// skipLocked returns nil and counts the file as skipped if the error is caused by the file being
+// in an encryption domain which is not unlocked by this connection.
+func (v *Verifier) skipLocked(ctx context.Context, err error, entryPath string) error {
+	if !errors.Is(err, content.ErrEncryptionDomainLocked) {
+		return err
+	}
+
+	verifierLog(ctx).Debugf("skipping %v in locked encryption domain", entryPath)
+	v.locked.Add(1)
+
+	return nil
 }
 
 // VerifyFile verifies a single file object (using content check, blob map check or full read).
@@ -66,7 +99,7 @@ func (v *Verifier) VerifyFile(ctx context.Context, oid object.ID, entryPath stri
 
 	contentIDs, err := v.rep.VerifyObject(ctx, oid)
 	if err != nil {
-		return errors.Wrap(err, "verify object")
+		return v.skipLocked(ctx, errors.Wrap(err, "verify object"), entryPath)
 	}
 
 	if v.blobMap != nil {
@@ -82,10 +115,19 @@ func (v *Verifier) VerifyFile(ctx context.Context, oid object.ID, entryPath stri
 		}
 	}
 
+	if v.annotations != nil {
+		// scanning reads the entire object, which also verifies it.
+		if err := v.annotations.scanObject(ctx, oid, entryPath); err != nil || len(v.opts.FormatCheckers) == 0 {
+			return v.skipLocked(ctx, err, entryPath)
+		}
+
+		return v.skipLocked(ctx, v.readEntireObject(ctx, oid, entryPath), entryPath)
+	}
+
 	//nolint:gosec
 	if 100*rand.Float64() < v.opts.VerifyFilesPercent {
 		if err := v.readEntireObject(ctx, oid, entryPath); err != nil {
-			return errors.Wrapf(err, "error reading object %v", oid)
+			return v.skipLocked(ctx, errors.Wrapf(err, "error reading object %v", oid), entryPath)
 		}
 	}
 
@@ -119,9 +161,41 @@ func (v *Verifier) readEntireObject(ctx context.Context, oid object.ID, path str
 	}
 	defer r.Close() //nolint:errcheck
 
+	if len(v.opts.FormatCheckers) > 0 {
+		if err := v.checkFormat(r); err != nil {
+			return err
+		}
+	}
+
+	// read whatever remains.
 	return errors.Wrap(iocopy.JustCopy(io.Discard, r), "unable to read data")
 }
 
+// checkFormat validates the structure of the object if it is in one of the well-known formats.
+func (v *Verifier) checkFormat(r object.Reader) error {
+	hdr, err := formatcheck.ReadHeader(r)
+	if err != nil {
+		return errors.Wrap(err, "unable to read data")
+	}
+
+	c := formatcheck.Detect(v.opts.FormatCheckers, hdr)
+	if c == nil {
+		return nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "unable to seek")
+	}
+
+	if err := c.Check(r, r.Length()); err != nil {
+		return errors.Wrapf(err, "%v format check failed", c.Name())
+	}
+
+	v.formatChecked.Add(1)
+
+	return nil
+}
+
 // VerifierOptions provides options for the verifier.
 type VerifierOptions struct {
 	VerifyFilesPercent float64
@@ -129,6 +203,22 @@ type VerifierOptions struct {
 	Parallelism        int
 	MaxErrors          int
 	BlobMap            map[blob.ID]blob.Metadata
+
+	// When set, contents of all files are passed to the scanner, see TakeAnnotations().
+	Scanner ContentScanner
+
+	// When set, files which are read in full are also validated by the checker matching their format.
+	FormatCheckers []formatcheck.Checker
+}
+
+// TakeAnnotations returns findings of the content scanner reported since the previous call
+// with paths as passed to the tree walker.
+func (v *Verifier) TakeAnnotations() []snapshot.Annotation {
+	if v.annotations == nil {
+		return nil
+	}
+
+	return v.annotations.take()
 }
 
 // InParallel starts parallel verification and invokes the provided function which can
@@ -138,6 +228,10 @@ func (v *Verifier) InParallel(ctx context.Context, enqueue func(tw *TreeWalker)
 		Parallelism:   v.opts.Parallelism,
 		EntryCallback: v.verifyObject,
 		MaxErrors:     v.opts.MaxErrors,
+		LockedDirectoryCallback: func(ctx context.Context, entryPath string) {
+			verifierLog(ctx).Debugf("skipping directory %v in locked encryption domain", entryPath)
+			v.lockedDirs.Add(1)
+		},
 	})
 	if twerr != nil {
 		return errors.Wrap(twerr, "tree walker")
@@ -187,9 +281,15 @@ func NewVerifier(ctx context.Context, rep repo.Repository, opts VerifierOptions)
 		opts.FileQueueLength = 20000
 	}
 
-	return &Verifier{
+	v := &Verifier{
 		opts:    opts,
 		rep:     rep,
 		blobMap: opts.BlobMap,
 	}
+
+	if opts.Scanner != nil {
+		v.annotations = newAnnotationCollector(rep, opts.Scanner)
+	}
+
+	return v
 }
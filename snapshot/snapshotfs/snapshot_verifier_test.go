@@ -1,13 +1,17 @@
 package snapshotfs_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"io"
 	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/internal/formatcheck"
 	"github.com/kopia/kopia/internal/mockfs"
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/repo"
@@ -141,6 +145,25 @@ func TestSnapshotVerifier(t *testing.T) {
 		}), "is backed by missing blob")
 	})
 
+	t.Run("ContentScanner", func(t *testing.T) {
+		opts := snapshotfs.VerifierOptions{
+			MaxErrors: 30,
+			Scanner:   flagContentsScanner{[]byte{1, 2, 4}},
+		}
+		v := snapshotfs.NewVerifier(ctx, te2, opts)
+
+		require.NoError(t, v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
+			tw.Process(ctx, snapshotfs.DirectoryEntry(te.Repository, obj1, nil), "root")
+			return nil
+		}))
+
+		annotations := v.TakeAnnotations()
+		require.Len(t, annotations, 1)
+		require.Equal(t, "root/file2", annotations[0].Path)
+		require.Equal(t, "flagged", annotations[0].Finding)
+		require.Empty(t, v.TakeAnnotations())
+	})
+
 	t.Run("FullFileReadsNoBlobMap", func(t *testing.T) {
 		opts := snapshotfs.VerifierOptions{
 			VerifyFilesPercent: 100,
@@ -166,3 +189,72 @@ func TestSnapshotVerifier(t *testing.T) {
 		}), "encountered 3 errors")
 	})
 }
+
+type flagContentsScanner struct {
+	contents []byte
+}
+
+func (s flagContentsScanner) Name() string { return "flag-contents" }
+
+func (s flagContentsScanner) Scan(ctx context.Context, relativePath string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", errors.Wrap(err, "read error")
+	}
+
+	if bytes.Equal(data, s.contents) {
+		return "flagged", nil
+	}
+
+	return "", nil
+}
+
+func TestSnapshotVerifier_FormatChecks(t *testing.T) {
+	ctx, te := repotesting.NewEnvironment(t, repotesting.FormatNotImportant)
+
+	var gz bytes.Buffer
+
+	gw := gzip.NewWriter(&gz)
+	gw.Write(bytes.Repeat([]byte("hello "), 100))
+	require.NoError(t, gw.Close())
+
+	corrupted := bytes.Clone(gz.Bytes())
+	corrupted[len(corrupted)-6] ^= 1
+
+	dir := mockfs.NewDirectory()
+	dir.AddFile("good.gz", gz.Bytes(), 0o644)
+	dir.AddFile("bad.gz", corrupted, 0o644)
+	dir.AddFile("plain.txt", []byte("not compressed"), 0o644)
+
+	var root object.ID
+
+	require.NoError(t, repo.WriteSession(ctx, te.Repository, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		man, err := snapshotfs.NewUploader(w).Upload(ctx, dir, nil, te.LocalPathSourceInfo("/dummy/path"))
+		require.NoError(t, err)
+
+		root = man.RootObjectID()
+
+		return nil
+	}))
+
+	verify := func(checkers []formatcheck.Checker) error {
+		v := snapshotfs.NewVerifier(ctx, te.Repository, snapshotfs.VerifierOptions{
+			VerifyFilesPercent: 100,
+			MaxErrors:          30,
+			FormatCheckers:     checkers,
+		})
+
+		return v.InParallel(ctx, func(tw *snapshotfs.TreeWalker) error {
+			tw.Process(ctx, snapshotfs.DirectoryEntry(te.Repository, root, nil), "root")
+			return nil
+		})
+	}
+
+	// byte-level verification does not detect the problem.
+	require.NoError(t, verify(nil))
+
+	checkers, err := formatcheck.ByName(formatcheck.Names())
+	require.NoError(t, err)
+
+	require.ErrorContains(t, verify(checkers), "gzip format check failed: invalid gzip data")
+}
@@ -10,6 +10,8 @@
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -21,8 +23,11 @@
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/fs/ignorefs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/feature"
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/internal/timetrack"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/internal/workshare"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/compression"
@@ -35,6 +40,9 @@
 // DefaultCheckpointInterval is the default frequency of mid-upload checkpointing.
 const DefaultCheckpointInterval = 45 * time.Minute
 
+// maxExtendedAttributesSize is the maximum total size of extended attributes captured for a single entry.
+const maxExtendedAttributesSize = 1 << 20
+
 var (
 	uploadLog = logging.Module("uploader")
 	repoFSLog = logging.Module("repofs")
@@ -60,10 +68,18 @@
 	IncompleteReasonLimitReached = "limit reached"
 )
 
+// ResumedDirectoriesTag is the tag set on snapshots which reused subdirectories completed by an interrupted
+// snapshot without walking them, its value is the number of reused directories. Changes made inside such
+// directories after the interrupted snapshot started are not detected unless the directory itself was modified.
+const ResumedDirectoriesTag = "resumed-directories"
+
 // Uploader supports efficient uploading files and directories to repository.
 type Uploader struct {
 	totalWrittenBytes atomic.Int64
 
+	// number of subdirectories reused from an interrupted snapshot without being walked.
+	resumedDirectories atomic.Int32
+
 	Progress UploadProgress
 
 	// automatically cancel the Upload after certain number of bytes
@@ -77,6 +93,9 @@ type Uploader struct {
 	// Number of files to hash and upload in parallel.
 	ParallelUploads int
 
+	// Target memory usage of the upload in bytes, overrides the policy setting when positive.
+	MemoryBudget int64
+
 	// Enable snapshot actions
 	EnableActions bool
 
@@ -90,12 +109,36 @@ type Uploader struct {
 	// How frequently to create checkpoint snapshot entries.
 	CheckpointInterval time.Duration
 
+	// When positive, a checkpoint is also created after a subdirectory completes if no checkpoint was
+	// created within this interval, so that an interrupted upload loses less work.
+	DirectoryCheckpointInterval time.Duration
+
+	// When set to true, subdirectories completed in an incomplete previous snapshot which have not been
+	// modified since that snapshot started are reused without being walked again. Changes to existing
+	// files deeper in such subdirectories are not detected.
+	ResumeCompletedDirectories bool
+
 	// When set to true, do not ignore any files, regardless of policy settings.
 	DisableIgnoreRules bool
 
 	// Labels to apply to every checkpoint made for this snapshot.
 	CheckpointLabels map[string]string
 
+	// Optional cache of object IDs of previously uploaded local files, shared across sources.
+	HintCache *UploadHintCache
+
+	// When positive, collect per-directory timing breakdowns and retain this many slowest directories,
+	// see SlowestDirectories().
+	DirectoryTimingReportSize int
+
+	// When set, contents of all files in the snapshot are passed to the scanner after they have been
+	// stored and its findings are recorded as annotations on the snapshot manifest.
+	Scanner ContentScanner
+
+	// When set to true, the source is walked and compared against previous snapshots, but nothing is
+	// stored in the repository. Files that would be uploaded are available using ChangeReport().
+	DryRun bool
+
 	repo repo.RepositoryWriter
 
 	// stats must be allocated on heap to enforce 64-bit alignment due to atomic access on ARM.
@@ -108,12 +151,39 @@ type Uploader struct {
 	// for testing only, when set will write to a given channel whenever checkpoint completes
 	checkpointFinished chan struct{}
 
+	// receives requests for checkpoints made after directories complete.
+	checkpointRequested chan struct{}
+
+	// time of the last checkpoint in unix nanoseconds.
+	lastCheckpoint atomic.Int64
+
 	// disable snapshot size estimation
 	disableEstimation bool
 
 	workerPool *workshare.Pool[*uploadWorkItem]
 
+	// limits the number of files read concurrently to stay within memory budget, nil if not limited.
+	memoryGate *memoryGate
+
+	packersMutex sync.Mutex
+	// +checklocks:packersMutex
+	packers map[packerKey]*object.Packer
+
+	requiredFeaturesMutex sync.Mutex
+	// +checklocks:requiredFeaturesMutex
+	requiredFeatures map[feature.Feature]bool
+
 	traceEnabled bool
+
+	timingMutex sync.Mutex
+	// +checklocks:timingMutex
+	slowestDirs []DirectoryTiming
+
+	annotations *annotationCollector
+
+	changesMutex sync.Mutex
+	// +checklocks:changesMutex
+	changes ChangeReport
 }
 
 // IsCanceled returns true if the upload is canceled.
@@ -135,6 +205,13 @@ func (u *Uploader) incompleteReason() string {
 }
 
 func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, relativePath string, f fs.File, pol *policy.Policy) (dirEntry *snapshot.DirEntry, ret error) {
+	if u.traceEnabled {
+		var span trace.Span
+
+		ctx, span = uploadTracer.Start(ctx, "UploadFile", trace.WithAttributes(attribute.String("file", relativePath), attribute.Int64("size", f.Size())))
+		defer span.End()
+	}
+
 	u.Progress.HashingFile(relativePath)
 
 	defer func() {
@@ -233,6 +310,9 @@ func concatenateParts(ctx context.Context, rep repo.RepositoryWriter, name strin
 }
 
 func (u *Uploader) uploadFileData(ctx context.Context, parentCheckpointRegistry *checkpointRegistry, f fs.File, fname string, offset, length int64, compressor, metadataComp compression.Name, splitterName string) (*snapshot.DirEntry, error) {
+	u.memoryGate.acquire()
+	defer u.memoryGate.release()
+
 	file, err := f.Open(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to open file")
@@ -274,16 +354,23 @@ func (u *Uploader) uploadFileData(ctx context.Context, parentCheckpointRegistry
 		s = io.LimitReader(s, length)
 	}
 
-	written, err := u.copyWithProgress(writer, s)
+	dt := directoryTimerFromContext(ctx)
+
+	written, err := u.copyWithProgress(dt, writer, s)
 	if err != nil {
 		return nil, err
 	}
 
+	flushTimer := timetrack.StartTimer()
+
 	r, err := writer.Result()
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get result")
 	}
 
+	dt.addFlush(flushTimer)
+	dt.addFile(written)
+
 	de, err := newDirEntry(f, fname, r)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
@@ -316,7 +403,7 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath strin
 	})
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, bytes.NewBufferString(target))
+	written, err := u.copyWithProgress(nil, writer, bytes.NewBufferString(target))
 	if err != nil {
 		return nil, err
 	}
@@ -364,7 +451,7 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 
 	defer writer.Close() //nolint:errcheck
 
-	written, err := u.copyWithProgress(writer, reader)
+	written, err := u.copyWithProgress(directoryTimerFromContext(ctx), writer, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -388,7 +475,7 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 	return de, nil
 }
 
-func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader) (int64, error) {
+func (u *Uploader) copyWithProgress(dt *directoryTimer, dst io.Writer, src io.Reader) (int64, error) {
 	uploadBuf := iocopy.GetBuffer()
 	defer iocopy.ReleaseBuffer(uploadBuf)
 
@@ -399,10 +486,14 @@ func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader) (int64, error)
 			return 0, errors.Wrap(errCanceled, "canceled when copying data")
 		}
 
+		readTimer := timetrack.StartTimer()
 		readBytes, readErr := src.Read(uploadBuf)
+		dt.addRead(readTimer)
 
 		if readBytes > 0 {
+			writeTimer := timetrack.StartTimer()
 			wroteBytes, writeErr := dst.Write(uploadBuf[0:readBytes])
+			dt.addWrite(writeTimer)
 			if wroteBytes > 0 {
 				written += int64(wroteBytes)
 				u.totalWrittenBytes.Add(int64(wroteBytes))
@@ -455,11 +546,13 @@ func newDirEntry(md fs.Entry, fname string, oid object.ID) (*snapshot.DirEntry,
 		entryType = snapshot.EntryTypeSymlink
 	case fs.File, fs.StreamingFile:
 		entryType = snapshot.EntryTypeFile
+	case fs.SpecialFile:
+		entryType = snapshot.SpecialFileEntryType(md.Mode())
 	default:
 		return nil, errors.Errorf("invalid entry type %T", md)
 	}
 
-	return &snapshot.DirEntry{
+	de := &snapshot.DirEntry{
 		Name:        fname,
 		Type:        entryType,
 		Permissions: snapshot.Permissions(md.Mode() & fs.ModBits),
@@ -468,7 +561,17 @@ func newDirEntry(md fs.Entry, fname string, oid object.ID) (*snapshot.DirEntry,
 		UserID:      md.Owner().UserID,
 		GroupID:     md.Owner().GroupID,
 		ObjectID:    oid,
-	}, nil
+	}
+
+	if ts, ok := md.(fs.TypedSymlink); ok {
+		de.SymlinkType = ts.SymlinkType()
+	}
+
+	if sf, ok := md.(fs.SpecialFile); ok {
+		de.Device = sf.RawDevice()
+	}
+
+	return de, nil
 }
 
 // newCachedDirEntry makes DirEntry objects for entries that are also in
@@ -489,6 +592,10 @@ func newCachedDirEntry(md, cached fs.Entry, fname string) (*snapshot.DirEntry, e
 
 // uploadFileWithCheckpointing uploads the specified File to the repository.
 func (u *Uploader) uploadFileWithCheckpointing(ctx context.Context, relativePath string, file fs.File, pol *policy.Policy, sourceInfo snapshot.SourceInfo) (*snapshot.DirEntry, error) {
+	if u.DryRun {
+		return u.dryRunFile(ctx, file, relativePath, nil)
+	}
+
 	var cp checkpointRegistry
 
 	cancelCheckpointer := u.periodicallyCheckpoint(ctx, &cp, &snapshot.Manifest{Source: sourceInfo})
@@ -499,6 +606,10 @@ func (u *Uploader) uploadFileWithCheckpointing(ctx context.Context, relativePath
 		return nil, err
 	}
 
+	if err := u.maybeScanFile(ctx, res, relativePath); err != nil {
+		return nil, err
+	}
+
 	return newDirEntryWithSummary(file, res.ObjectID, &fs.DirectorySummary{
 		TotalFileCount: 1,
 		TotalFileSize:  res.FileSize,
@@ -509,6 +620,11 @@ func (u *Uploader) uploadFileWithCheckpointing(ctx context.Context, relativePath
 // checkpointRoot invokes checkpoints on the provided registry and if a checkpoint entry was generated,
 // saves it in an incomplete snapshot manifest.
 func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, prototypeManifest *snapshot.Manifest) error {
+	// packed files pending in packers are only included in the checkpoint after they have been written.
+	if err := u.flushPackers(ctx); err != nil {
+		return err
+	}
+
 	var dmbCheckpoint DirManifestBuilder
 	if err := cp.runCheckpoints(&dmbCheckpoint); err != nil {
 		return errors.Wrap(err, "running checkpointers")
@@ -550,9 +666,18 @@ func (u *Uploader) checkpointRoot(ctx context.Context, cp *checkpointRegistry, p
 	return nil
 }
 
-// periodicallyCheckpoint periodically (every CheckpointInterval) invokes checkpointRoot until the
-// returned cancellation function has been called.
+func (u *Uploader) lastCheckpointTime() time.Time {
+	return time.Unix(0, u.lastCheckpoint.Load())
+}
+
+// periodicallyCheckpoint periodically (every CheckpointInterval) or when requested after a directory
+// completes invokes checkpointRoot until the returned cancellation function has been called.
 func (u *Uploader) periodicallyCheckpoint(ctx context.Context, cp *checkpointRegistry, prototypeManifest *snapshot.Manifest) (cancelFunc func()) {
+	if u.DryRun {
+		// nothing to checkpoint.
+		return func() {}
+	}
+
 	shutdown := make(chan struct{})
 	ch := u.getTicker(u.CheckpointInterval)
 
@@ -563,17 +688,21 @@ func (u *Uploader) periodicallyCheckpoint(ctx context.Context, cp *checkpointReg
 				return
 
 			case <-ch:
-				if err := u.checkpointRoot(ctx, cp, prototypeManifest); err != nil {
-					uploadLog(ctx).Errorf("error checkpointing: %v", err)
-					u.Cancel()
+			case <-u.checkpointRequested:
+			}
 
-					return
-				}
+			u.lastCheckpoint.Store(u.repo.Time().UnixNano())
 
-				// test action
-				if u.checkpointFinished != nil {
-					u.checkpointFinished <- struct{}{}
-				}
+			if err := u.checkpointRoot(ctx, cp, prototypeManifest); err != nil {
+				uploadLog(ctx).Errorf("error checkpointing: %v", err)
+				u.Cancel()
+
+				return
+			}
+
+			// test action
+			if u.checkpointFinished != nil {
+				u.checkpointFinished <- struct{}{}
 			}
 		}
 	}()
@@ -767,6 +896,65 @@ func (u *Uploader) maybeIgnoreCachedEntry(ctx context.Context, ent fs.Entry) fs.
 	return nil
 }
 
+// maybeUseUploadHint returns directory entry for a file whose contents have been previously uploaded
+// according to the upload hint cache, possibly under a different path or as part of a different source.
+func (u *Uploader) maybeUseUploadHint(ctx context.Context, entry fs.Entry) *snapshot.DirEntry {
+	if _, ok := entry.(fs.File); !ok {
+		return nil
+	}
+
+	oid, ok := u.HintCache.Get(entry)
+	if !ok {
+		return nil
+	}
+
+	if 100*rand.Float64() < u.ForceHashPercentage { //nolint:gosec
+		uploadLog(ctx).Debugw("re-hashing hinted object", "oid", oid)
+		return nil
+	}
+
+	if err := verifyHintedObject(ctx, u.repo, oid); err != nil {
+		uploadLog(ctx).Debugw("ignoring upload hint", "oid", oid, "error", err)
+		u.HintCache.Remove(entry)
+
+		return nil
+	}
+
+	de, err := newDirEntry(entry, entry.Name(), oid)
+	if err != nil {
+		return nil
+	}
+
+	return de
+}
+
+// captureExtendedAttributes stores extended attributes of a local filesystem entry enabled by the policy
+// in its directory entry. Failure to read them is not fatal and only results in a warning.
+func (u *Uploader) captureExtendedAttributes(ctx context.Context, entry fs.Entry, de *snapshot.DirEntry, pol *policy.Policy) {
+	kinds := pol.ExtendedAttributesPolicy.Kinds()
+	if kinds == fs.ExtendedAttributesNone || de == nil {
+		return
+	}
+
+	localPath := entry.LocalFilesystemPath()
+	if localPath == "" {
+		return
+	}
+
+	attrs, err := localfs.ReadExtendedAttributes(localPath, kinds)
+	if err != nil {
+		uploadLog(ctx).Warnf("unable to read extended attributes of %v: %v", localPath, err)
+		return
+	}
+
+	if sz := attrs.TotalSize(); sz > maxExtendedAttributesSize {
+		uploadLog(ctx).Warnf("extended attributes of %v are too large to be captured (%v)", localPath, units.BytesString(int64(sz)))
+		return
+	}
+
+	de.ExtendedAttributes = attrs
+}
+
 func (u *Uploader) effectiveParallelFileReads(pol *policy.Policy) int {
 	p := u.ParallelUploads
 	if p > 0 {
@@ -794,6 +982,9 @@ func (u *Uploader) processDirectoryEntries(
 	prevDirs []fs.Directory,
 	wg *workshare.AsyncGroup[*uploadWorkItem],
 ) error {
+	dt := directoryTimerFromContext(ctx)
+	listTimer := timetrack.StartTimer()
+
 	iter, err := dir.Iterate(ctx)
 	if err != nil {
 		return dirReadError{err}
@@ -802,6 +993,7 @@ func (u *Uploader) processDirectoryEntries(
 	defer iter.Close()
 
 	entry, err := iter.Next(ctx)
+	dt.addList(listTimer)
 
 	for entry != nil {
 		entry2 := entry
@@ -822,7 +1014,9 @@ func (u *Uploader) processDirectoryEntries(
 			}
 		}
 
+		listTimer = timetrack.StartTimer()
 		entry, err = iter.Next(ctx)
+		dt.addList(listTimer)
 	}
 
 	if err != nil {
@@ -848,6 +1042,17 @@ func (u *Uploader) processSingle(
 	// note this function runs in parallel and updates 'u.stats', which must be done using atomic operations.
 	t0 := timetrack.StartTimer()
 
+	if followed, err := maybeFollowSymlink(entry, policyTree.Child(entry.Name()).EffectivePolicy()); err != nil {
+		return u.processEntryUploadResult(ctx, nil, err, entryRelativePath, parentDirBuilder,
+			policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false),
+			u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone)),
+			"followed reparse point", t0)
+	} else if followed != nil {
+		defer followed.Close()
+
+		entry = followed
+	}
+
 	if _, ok := entry.(fs.Directory); !ok {
 		// See if we had this name during either of previous passes.
 		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entryRelativePath, entry, prevDirs, policyTree)); cachedEntry != nil {
@@ -863,11 +1068,31 @@ func (u *Uploader) processSingle(
 				return errors.Wrap(err, "unable to create dir entry")
 			}
 
+			if _, ok := entry.(fs.File); ok {
+				u.HintCache.Put(entry, cachedDirEntry.ObjectID)
+			}
+
+			u.captureExtendedAttributes(ctx, entry, cachedDirEntry, policyTree.Child(entry.Name()).EffectivePolicy())
+
 			return u.processEntryUploadResult(ctx, cachedDirEntry, nil, entryRelativePath, parentDirBuilder,
 				false,
 				u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.CacheHit.OrDefault(policy.LogDetailNone)),
 				"cached", t0)
 		}
+
+		// See if the same file has been uploaded before under a different path.
+		if hintedDirEntry := u.maybeUseUploadHint(ctx, entry); hintedDirEntry != nil {
+			u.captureExtendedAttributes(ctx, entry, hintedDirEntry, policyTree.Child(entry.Name()).EffectivePolicy())
+			atomic.AddInt32(&u.stats.CachedFiles, 1)
+			atomic.AddInt64(&u.stats.TotalFileSize, hintedDirEntry.FileSize)
+			u.Progress.CachedFile(entryRelativePath, hintedDirEntry.FileSize)
+			u.Progress.FinishedFile(entryRelativePath, nil)
+
+			return u.processEntryUploadResult(ctx, hintedDirEntry, nil, entryRelativePath, parentDirBuilder,
+				false,
+				u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.CacheHit.OrDefault(policy.LogDetailNone)),
+				"cached (upload hint)", t0)
+		}
 	}
 
 	switch entry := entry.(type) {
@@ -882,6 +1107,18 @@ func (u *Uploader) processSingle(
 		childTree := policyTree.Child(entry.Name())
 		childPrevDirs := uniqueChildDirectories(ctx, prevDirs, entry.Name())
 
+		if resumed := u.maybeResumeCompletedDirectory(entry, entryRelativePath, childPrevDirs); resumed != nil {
+			maybeLogEntryProcessed(
+				uploadLog(ctx),
+				u.OverrideDirLogDetail.OrDefault(childTree.EffectivePolicy().LoggingPolicy.Directories.Snapshotted.OrDefault(policy.LogDetailNone)),
+				"resumed completed directory", entryRelativePath, resumed, nil, t0)
+
+			u.captureExtendedAttributes(ctx, entry, resumed, childTree.EffectivePolicy())
+			parentDirBuilder.AddEntry(resumed)
+
+			return nil
+		}
+
 		de, err := uploadDirInternal(ctx, u, entry, childTree, childPrevDirs, childLocalDirPathOrEmpty, entryRelativePath, childDirBuilder, parentCheckpointRegistry)
 		if errors.Is(err, errCanceled) {
 			return err
@@ -899,14 +1136,30 @@ func (u *Uploader) processSingle(
 				return errors.Wrapf(err, "unable to process directory %q", entry.Name())
 			}
 		} else {
+			u.captureExtendedAttributes(ctx, entry, de, childTree.EffectivePolicy())
 			parentDirBuilder.AddEntry(de)
+			u.directoryCompleted()
 		}
 
 		return nil
 
 	case fs.Symlink:
 		childTree := policyTree.Child(entry.Name())
-		de, err := u.uploadSymlinkInternal(ctx, entryRelativePath, entry, childTree.EffectivePolicy().MetadataCompressionPolicy.MetadataCompressor())
+
+		var (
+			de  *snapshot.DirEntry
+			err error
+		)
+
+		if u.DryRun {
+			de, err = newDirEntry(entry, entry.Name(), object.EmptyID)
+		} else {
+			de, err = u.uploadSymlinkInternal(ctx, entryRelativePath, entry, childTree.EffectivePolicy().MetadataCompressionPolicy.MetadataCompressor())
+		}
+
+		if err == nil {
+			u.captureExtendedAttributes(ctx, entry, de, childTree.EffectivePolicy())
+		}
 
 		return u.processEntryUploadResult(ctx, de, err, entryRelativePath, parentDirBuilder,
 			policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false),
@@ -916,12 +1169,69 @@ func (u *Uploader) processSingle(
 	case fs.File:
 		atomic.AddInt32(&u.stats.NonCachedFiles, 1)
 
-		de, err := u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy())
+		isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false)
+		logDetail := u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone))
+		filePolicy := policyTree.Child(entry.Name()).EffectivePolicy()
+
+		if u.DryRun {
+			de, err := u.dryRunFile(ctx, entry, entryRelativePath, prevDirs)
+
+			return u.processEntryUploadResult(ctx, de, err, entryRelativePath, parentDirBuilder, isIgnoredError, logDetail, "changed file", t0)
+		}
+
+		inlined, err := u.maybeUploadInlineFile(ctx, entryRelativePath, entry, filePolicy)
+		if err != nil || inlined != nil {
+			if err == nil {
+				u.captureExtendedAttributes(ctx, entry, inlined, filePolicy)
+			}
+
+			return u.processEntryUploadResult(ctx, inlined, err, entryRelativePath, parentDirBuilder, isIgnoredError, logDetail, "snapshotted inline file", t0)
+		}
+
+		packed, err := u.maybeUploadPackedFile(ctx, entryRelativePath, entry, filePolicy, func(de *snapshot.DirEntry) {
+			u.captureExtendedAttributes(ctx, entry, de, filePolicy)
+			u.processEntryUploadResult(ctx, de, nil, entryRelativePath, parentDirBuilder, isIgnoredError, logDetail, "snapshotted packed file", t0) //nolint:errcheck
+		})
+		if packed {
+			if err != nil {
+				return u.processEntryUploadResult(ctx, nil, err, entryRelativePath, parentDirBuilder, isIgnoredError, logDetail, "snapshotted packed file", t0)
+			}
+
+			// directory entry will be added when the packed object is written.
+			return nil
+		}
+
+		de, err := u.uploadFileInternal(ctx, parentCheckpointRegistry, entryRelativePath, entry, filePolicy)
+		if err == nil && de != nil && de.FileSize == entry.Size() && de.ModTime.Equal(fs.UTCTimestampFromTime(entry.ModTime())) {
+			// only record hints for files that have not changed while being uploaded.
+			u.HintCache.Put(entry, de.ObjectID)
+		}
+
+		if err == nil {
+			u.captureExtendedAttributes(ctx, entry, de, filePolicy)
+		}
+
+		return u.processEntryUploadResult(ctx, de, err, entryRelativePath, parentDirBuilder, isIgnoredError, logDetail, "snapshotted file", t0)
+
+	case fs.SpecialFile:
+		childPolicy := policyTree.Child(entry.Name()).EffectivePolicy()
+
+		if !childPolicy.FilesPolicy.SnapshotSpecialFiles.OrDefault(false) {
+			return u.processEntryUploadResult(ctx, nil, errors.Wrapf(fs.ErrUnknown, "special file %v", entry.Mode()), entryRelativePath, parentDirBuilder,
+				policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreUnknownTypes.OrDefault(true),
+				u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone)),
+				"unknown entry", t0)
+		}
+
+		de, err := newDirEntry(entry, entry.Name(), object.EmptyID)
+		if err == nil {
+			u.captureExtendedAttributes(ctx, entry, de, childPolicy)
+		}
 
 		return u.processEntryUploadResult(ctx, de, err, entryRelativePath, parentDirBuilder,
 			policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false),
 			u.OverrideEntryLogDetail.OrDefault(policyTree.EffectivePolicy().LoggingPolicy.Entries.Snapshotted.OrDefault(policy.LogDetailNone)),
-			"snapshotted file", t0)
+			"snapshotted special file", t0)
 
 	case fs.ErrorEntry:
 		var (
@@ -945,7 +1255,16 @@ func (u *Uploader) processSingle(
 	case fs.StreamingFile:
 		atomic.AddInt32(&u.stats.NonCachedFiles, 1)
 
-		de, err := u.uploadStreamingFileInternal(ctx, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy())
+		var (
+			de  *snapshot.DirEntry
+			err error
+		)
+
+		if u.DryRun {
+			de, err = u.dryRunFile(ctx, entry, entryRelativePath, prevDirs)
+		} else {
+			de, err = u.uploadStreamingFileInternal(ctx, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy())
+		}
 
 		return u.processEntryUploadResult(ctx, de, err, entryRelativePath, parentDirBuilder,
 			policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrors.OrDefault(false),
@@ -957,12 +1276,36 @@ func (u *Uploader) processSingle(
 	}
 }
 
-//nolint:unparam
+// maybeFollowSymlink returns the entry a Windows symbolic link or directory junction points to when the policy
+// requires following them or nil if the entry should be snapshotted as is.
+func maybeFollowSymlink(entry fs.Entry, pol *policy.Policy) (fs.Entry, error) {
+	sl, ok := entry.(fs.TypedSymlink)
+	if !ok || sl.SymlinkType() == fs.SymlinkTypeUnknown || sl.LocalFilesystemPath() == "" || !pol.FilesPolicy.FollowReparsePoints.OrDefault(false) {
+		return nil, nil
+	}
+
+	//nolint:wrapcheck
+	return localfs.FollowSymlink(sl)
+}
+
+// maybeScanFile passes contents of the stored file to the content scanner, if one is configured.
+func (u *Uploader) maybeScanFile(ctx context.Context, de *snapshot.DirEntry, relativePath string) error {
+	if u.annotations == nil || u.DryRun || de == nil || de.Type != snapshot.EntryTypeFile {
+		return nil
+	}
+
+	return u.annotations.scanObject(ctx, de.ObjectID, relativePath)
+}
+
 func (u *Uploader) processEntryUploadResult(ctx context.Context, de *snapshot.DirEntry, err error, entryRelativePath string, parentDirBuilder *DirManifestBuilder, isIgnored bool, logDetail policy.LogDetail, logMessage string, t0 timetrack.Timer) error {
 	if err != nil {
 		u.reportErrorAndMaybeCancel(err, isIgnored, parentDirBuilder, entryRelativePath)
 	} else {
 		parentDirBuilder.AddEntry(de)
+
+		if err := u.maybeScanFile(ctx, de, entryRelativePath); err != nil {
+			return err
+		}
 	}
 
 	maybeLogEntryProcessed(
@@ -1103,15 +1446,30 @@ func uploadDirInternal(
 ) (resultDE *snapshot.DirEntry, resultErr error) {
 	atomic.AddInt32(&u.stats.TotalDirectoryCount, 1)
 
-	if u.traceEnabled {
-		var span trace.Span
+	var span trace.Span
 
+	if u.traceEnabled {
 		ctx, span = uploadTracer.Start(ctx, "UploadDir", trace.WithAttributes(attribute.String("dir", dirRelativePath)))
 		defer span.End()
 	}
 
 	t0 := timetrack.StartTimer()
 
+	if u.traceEnabled || u.DirectoryTimingReportSize > 0 {
+		dt := &directoryTimer{}
+		ctx = withDirectoryTimer(ctx, dt)
+
+		defer func() {
+			t := dt.timing(dirRelativePath, t0.Elapsed())
+
+			if span != nil {
+				span.SetAttributes(t.spanAttributes()...)
+			}
+
+			u.recordDirectoryTiming(t)
+		}()
+	}
+
 	defer func() {
 		maybeLogEntryProcessed(
 			uploadLog(ctx),
@@ -1175,8 +1533,19 @@ func uploadDirInternal(
 		return nil, err
 	}
 
+	defer directoryTimerFromContext(ctx).addFlush(timetrack.StartTimer())
+
+	// make sure entries of all packed files in this directory have been added.
+	if err := u.flushPackers(ctx); err != nil {
+		return nil, err
+	}
+
 	dirManifest := thisDirBuilder.Build(fs.UTCTimestampFromTime(directory.ModTime()), u.incompleteReason())
 
+	if u.DryRun {
+		return newDirEntryWithSummary(directory, object.EmptyID, dirManifest.Summary)
+	}
+
 	oid, err := writeDirManifest(ctx, u.repo, dirRelativePath, dirManifest, metadataComp)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error writing dir manifest: %v", directory.Name())
@@ -1261,6 +1630,13 @@ func (u *Uploader) Upload(
 
 	parallel := u.effectiveParallelFileReads(policyTree.EffectivePolicy())
 
+	if budget := u.effectiveMemoryBudget(policyTree.EffectivePolicy()); budget > 0 {
+		plan := planMemoryBudget(budget, parallel, maxSegmentSizeForPolicy(policyTree.EffectivePolicy()))
+		parallel = plan.parallelFileReads
+
+		defer u.startMemoryBudget(ctx, plan)()
+	}
+
 	uploadLog(ctx).Debugw("uploading", "source", sourceInfo, "previousManifests", len(previousManifests), "parallel", parallel)
 
 	s := &snapshot.Manifest{
@@ -1272,11 +1648,32 @@ func (u *Uploader) Upload(
 
 	u.stats = &snapshot.Stats{}
 	u.totalWrittenBytes.Store(0)
+	u.resumedDirectories.Store(0)
+
+	u.timingMutex.Lock()
+	u.slowestDirs = nil
+	u.timingMutex.Unlock()
+
+	u.packersMutex.Lock()
+	u.packers = nil
+	u.packersMutex.Unlock()
+
+	u.changesMutex.Lock()
+	u.changes = ChangeReport{}
+	u.changesMutex.Unlock()
+
+	u.annotations = nil
+	if u.Scanner != nil {
+		u.annotations = newAnnotationCollector(u.repo, u.Scanner)
+	}
 
 	var err error
 
 	s.StartTime = fs.UTCTimestampFromTime(u.repo.Time())
 
+	u.checkpointRequested = make(chan struct{}, 1)
+	u.lastCheckpoint.Store(s.StartTime.ToTime().UnixNano())
+
 	switch entry := source.(type) {
 	case fs.Directory:
 		s.RootEntry, err = u.uploadDir(ctx, previousManifests, entry, policyTree, sourceInfo)
@@ -1293,10 +1690,21 @@ func (u *Uploader) Upload(
 		return nil, rootCauseError(err)
 	}
 
+	u.captureExtendedAttributes(ctx, source, s.RootEntry, policyTree.EffectivePolicy())
+
 	s.IncompleteReason = u.incompleteReason()
+
+	if n := u.resumedDirectories.Load(); n > 0 {
+		s.Tags = map[string]string{ResumedDirectoriesTag: strconv.Itoa(int(n))}
+	}
+
 	s.EndTime = fs.UTCTimestampFromTime(u.repo.Time())
 	s.Stats = *u.stats
 
+	if u.annotations != nil {
+		s.ReplaceAnnotations(u.Scanner.Name(), u.annotations.take())
+	}
+
 	return s, nil
 }
 
@@ -1310,9 +1718,16 @@ func (u *Uploader) uploadDir(
 	var previousDirs []fs.Directory
 
 	for _, m := range previousManifests {
-		if d := u.maybeOpenDirectoryFromManifest(ctx, m); d != nil {
-			previousDirs = append(previousDirs, d)
+		d := u.maybeOpenDirectoryFromManifest(ctx, m)
+		if d == nil {
+			continue
 		}
+
+		if u.ResumeCompletedDirectories && m.IncompleteReason != "" {
+			d = checkpointDirectory{d, m.StartTime.ToTime()}
+		}
+
+		previousDirs = append(previousDirs, d)
 	}
 
 	estimationCtl := u.startDataSizeEstimation(ctx, entry, policyTree)
@@ -0,0 +1,104 @@
+package snapshotfs
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// FileChangeKind describes why a file would be uploaded.
+type FileChangeKind string
+
+// Kinds of file changes.
+const (
+	FileChangeNew      FileChangeKind = "new"
+	FileChangeModified FileChangeKind = "modified"
+)
+
+// ChangedFile describes a file that would be uploaded.
+type ChangedFile struct {
+	Path string         `json:"path"`
+	Kind FileChangeKind `json:"kind"`
+	Size int64          `json:"size"`
+}
+
+// ChangeReport describes files that would be uploaded by a dry-run upload.
+type ChangeReport struct {
+	NewFiles       int           `json:"newFiles"`
+	NewBytes       int64         `json:"newBytes"`
+	ModifiedFiles  int           `json:"modifiedFiles"`
+	ModifiedBytes  int64         `json:"modifiedBytes"`
+	UnchangedFiles int           `json:"unchangedFiles"`
+	Files          []ChangedFile `json:"files"`
+}
+
+// EstimatedUploadBytes returns the number of bytes that would be hashed and uploaded. Contents deduplicated
+// against data already in the repository are not uploaded, so the actual number may be lower.
+func (r *ChangeReport) EstimatedUploadBytes() int64 {
+	return r.NewBytes + r.ModifiedBytes
+}
+
+func (r *ChangeReport) add(cf ChangedFile) {
+	switch cf.Kind {
+	case FileChangeNew:
+		r.NewFiles++
+		r.NewBytes += cf.Size
+
+	case FileChangeModified:
+		r.ModifiedFiles++
+		r.ModifiedBytes += cf.Size
+	}
+
+	r.Files = append(r.Files, cf)
+}
+
+// ChangeReport returns files that would have been uploaded during the most recent upload made with DryRun,
+// ordered by path.
+func (u *Uploader) ChangeReport() ChangeReport {
+	u.changesMutex.Lock()
+	defer u.changesMutex.Unlock()
+
+	r := u.changes
+	r.Files = append([]ChangedFile(nil), r.Files...)
+
+	if u.stats != nil {
+		r.UnchangedFiles = int(atomic.LoadInt32(&u.stats.CachedFiles))
+	}
+
+	sort.Slice(r.Files, func(i, j int) bool {
+		return r.Files[i].Path < r.Files[j].Path
+	})
+
+	return r
+}
+
+func (u *Uploader) recordChangedFile(cf ChangedFile) {
+	u.changesMutex.Lock()
+	defer u.changesMutex.Unlock()
+
+	u.changes.add(cf)
+}
+
+// dryRunFile records the file that would be uploaded and returns its directory entry without storing
+// its contents. The file is new unless any of the previous directories had an entry with the same name.
+func (u *Uploader) dryRunFile(ctx context.Context, entry fs.Entry, relativePath string, prevDirs []fs.Directory) (*snapshot.DirEntry, error) {
+	kind := FileChangeNew
+
+	for _, d := range prevDirs {
+		if _, err := d.Child(ctx, entry.Name()); err == nil {
+			kind = FileChangeModified
+			break
+		}
+	}
+
+	u.recordChangedFile(ChangedFile{Path: relativePath, Kind: kind, Size: entry.Size()})
+
+	atomic.AddInt64(&u.stats.TotalFileSize, entry.Size())
+	u.Progress.FinishedFile(relativePath, nil)
+
+	return newDirEntry(entry, entry.Name(), object.EmptyID)
+}
@@ -0,0 +1,62 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestUploadDryRun(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	si := snapshot.SourceInfo{UserName: "user", Host: "host", Path: "path"}
+
+	u := NewUploader(th.repo)
+	u.disableEstimation = true
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, si)
+	require.NoError(t, err)
+	require.NoError(t, th.repo.Flush(ctx))
+
+	th.sourceDir.AddFile("f4", []byte{1, 2, 3, 4, 5, 6}, defaultPermissions)
+	th.sourceDir.Subdir("d1").Remove("f2")
+	th.sourceDir.Subdir("d1").AddFile("f2", []byte{9, 9, 9, 9, 9}, defaultPermissions)
+	th.sourceDir.AddDir("d3", defaultPermissions)
+	th.sourceDir.AddFile("d3/f1", []byte{1}, defaultPermissions)
+
+	putBlobCalls := th.faulty.NumCalls(blobtesting.MethodPutBlob)
+
+	u2 := NewUploader(th.repo)
+	u2.disableEstimation = true
+	u2.DryRun = true
+
+	man2, err := u2.Upload(ctx, th.sourceDir, policyTree, si, man1)
+	require.NoError(t, err)
+	require.NoError(t, th.repo.Flush(ctx))
+
+	// nothing was written.
+	require.Equal(t, putBlobCalls, th.faulty.NumCalls(blobtesting.MethodPutBlob))
+
+	r := u2.ChangeReport()
+	require.Equal(t, []ChangedFile{
+		{Path: "d1/f2", Kind: FileChangeModified, Size: 5},
+		{Path: "d3/f1", Kind: FileChangeNew, Size: 1},
+		{Path: "f4", Kind: FileChangeNew, Size: 6},
+	}, r.Files)
+	require.Equal(t, 2, r.NewFiles)
+	require.Equal(t, int64(7), r.NewBytes)
+	require.Equal(t, 1, r.ModifiedFiles)
+	require.Equal(t, int64(5), r.ModifiedBytes)
+	require.Equal(t, int64(12), r.EstimatedUploadBytes())
+	require.Equal(t, int(man1.RootEntry.DirSummary.TotalFileCount)-1, r.UnchangedFiles)
+	require.Equal(t, man1.RootEntry.DirSummary.TotalFileSize+8, man2.RootEntry.DirSummary.TotalFileSize)
+}
@@ -0,0 +1,68 @@
+package snapshotfs
+
+import (
+	"time"
+)
+
+// minETAElapsed is the minimum amount of time an upload must run before its ETA is estimated.
+const minETAElapsed = time.Second
+
+// UploadETA describes predicted completion of an upload based on the ratios achieved so far.
+//
+// Unlike a linear estimate based on the number of bytes processed, the model takes into account
+// that files reused from previous snapshots are processed almost instantly, and that hashed bytes
+// are reduced by deduplication and compression before being uploaded.
+type UploadETA struct {
+	PercentComplete  float64       `json:"percentComplete"`
+	Remaining        time.Duration `json:"remaining"`
+	EstimatedEndTime time.Time     `json:"estimatedEndTime"`
+
+	// HashedRatio is the fraction of processed bytes that had to be hashed (the rest was cached).
+	HashedRatio float64 `json:"hashedRatio"`
+
+	// UploadRatio is the ratio of uploaded to hashed bytes, reflecting achieved deduplication and compression.
+	UploadRatio float64 `json:"uploadRatio"`
+
+	// PredictedUploadBytes is the predicted total number of bytes uploaded by the end of the snapshot.
+	PredictedUploadBytes int64 `json:"predictedUploadBytes"`
+}
+
+// EstimateUploadETA estimates completion of an upload given its counters and the time elapsed since it started.
+// Returns false if not enough data is available yet.
+func EstimateUploadETA(c UploadCounters, now time.Time, elapsed time.Duration) (UploadETA, bool) {
+	processed := c.TotalHashedBytes + c.TotalCachedBytes
+	if elapsed < minETAElapsed || c.EstimatedBytes <= 0 || processed <= 0 {
+		return UploadETA{}, false
+	}
+
+	remaining := max(0, c.EstimatedBytes-processed)
+
+	e := UploadETA{
+		PercentComplete: min(100, 100*float64(processed)/float64(c.EstimatedBytes)),
+		HashedRatio:     float64(c.TotalHashedBytes) / float64(processed),
+	}
+
+	if c.TotalHashedBytes > 0 {
+		e.UploadRatio = float64(c.TotalUploadedBytes) / float64(c.TotalHashedBytes)
+	}
+
+	// assume the remaining data will be cached, deduplicated and compressed at the same rate
+	// as the data processed so far.
+	remainingHashed := float64(remaining) * e.HashedRatio
+
+	var remainingSeconds float64
+
+	if c.TotalHashedBytes > 0 {
+		// time spent on cached files is negligible compared to hashing and uploading, so the
+		// observed time is attributed to hashed bytes.
+		remainingSeconds = remainingHashed * elapsed.Seconds() / float64(c.TotalHashedBytes)
+	} else {
+		remainingSeconds = float64(remaining) * elapsed.Seconds() / float64(processed)
+	}
+
+	e.Remaining = time.Duration(remainingSeconds * float64(time.Second)).Truncate(time.Second)
+	e.EstimatedEndTime = now.Add(e.Remaining)
+	e.PredictedUploadBytes = c.TotalUploadedBytes + int64(remainingHashed*e.UploadRatio)
+
+	return e, true
+}
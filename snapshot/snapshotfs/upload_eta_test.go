@@ -0,0 +1,57 @@
+package snapshotfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateUploadETA(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	_, ok := EstimateUploadETA(UploadCounters{TotalHashedBytes: 100, EstimatedBytes: 1000}, now, 500*time.Millisecond)
+	require.False(t, ok, "too early")
+
+	_, ok = EstimateUploadETA(UploadCounters{TotalHashedBytes: 100}, now, 10*time.Second)
+	require.False(t, ok, "no estimated size")
+
+	_, ok = EstimateUploadETA(UploadCounters{EstimatedBytes: 1000}, now, 10*time.Second)
+	require.False(t, ok, "nothing processed")
+
+	// 100 bytes hashed in 10s, 300 bytes cached, 25 bytes uploaded.
+	// Of the remaining 600 bytes, 150 are expected to be hashed taking 15s and uploading 37 bytes.
+	eta, ok := EstimateUploadETA(UploadCounters{
+		TotalHashedBytes:   100,
+		TotalCachedBytes:   300,
+		TotalUploadedBytes: 25,
+		EstimatedBytes:     1000,
+	}, now, 10*time.Second)
+	require.True(t, ok)
+	require.InDelta(t, 40.0, eta.PercentComplete, 0.001)
+	require.InDelta(t, 0.25, eta.HashedRatio, 0.001)
+	require.InDelta(t, 0.25, eta.UploadRatio, 0.001)
+	require.Equal(t, 15*time.Second, eta.Remaining)
+	require.Equal(t, now.Add(15*time.Second), eta.EstimatedEndTime)
+	require.Equal(t, int64(62), eta.PredictedUploadBytes)
+
+	// only cached files so far - falls back to linear estimate.
+	eta, ok = EstimateUploadETA(UploadCounters{
+		TotalCachedBytes: 500,
+		EstimatedBytes:   1000,
+	}, now, 10*time.Second)
+	require.True(t, ok)
+	require.Equal(t, 10*time.Second, eta.Remaining)
+	require.Equal(t, int64(0), eta.PredictedUploadBytes)
+
+	// processed more than estimated.
+	eta, ok = EstimateUploadETA(UploadCounters{
+		TotalHashedBytes:   2000,
+		TotalUploadedBytes: 100,
+		EstimatedBytes:     1000,
+	}, now, 10*time.Second)
+	require.True(t, ok)
+	require.InDelta(t, 100.0, eta.PercentComplete, 0.001)
+	require.Equal(t, time.Duration(0), eta.Remaining)
+	require.Equal(t, int64(100), eta.PredictedUploadBytes)
+}
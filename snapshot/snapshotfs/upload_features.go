@@ -0,0 +1,44 @@
+package snapshotfs
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/internal/feature"
+	"github.com/kopia/kopia/repo"
+)
+
+// requireFeature ensures the repository format requires the provided feature before the uploader writes
+// object IDs that depend on it, so that older clients refuse to open the repository instead of failing
+// to parse directories. Returns false if the feature can't be registered, in which case it must not be used.
+func (u *Uploader) requireFeature(ctx context.Context, r feature.Required) bool {
+	if u.DryRun {
+		return true
+	}
+
+	u.requiredFeaturesMutex.Lock()
+	defer u.requiredFeaturesMutex.Unlock()
+
+	if ok, checked := u.requiredFeatures[r.Feature]; checked {
+		return ok
+	}
+
+	if u.requiredFeatures == nil {
+		u.requiredFeatures = map[feature.Feature]bool{}
+	}
+
+	ok := true
+
+	if dr, isDirect := u.repo.(repo.DirectRepository); !isDirect {
+		uploadLog(ctx).Warnf("repository format can't be updated through this connection, not using %v", r.Feature)
+
+		ok = false
+	} else if err := dr.FormatManager().AddRequiredFeature(ctx, r); err != nil {
+		uploadLog(ctx).Warnf("unable to mark repository as requiring %v, not using it: %v", r.Feature, err)
+
+		ok = false
+	}
+
+	u.requiredFeatures[r.Feature] = ok
+
+	return ok
+}
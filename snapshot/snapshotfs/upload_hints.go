@@ -0,0 +1,250 @@
+package snapshotfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/atomicfile"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// DefaultUploadHintCacheMaxEntries is the default maximum number of entries in the upload hint cache.
+const DefaultUploadHintCacheMaxEntries = 250000
+
+// UploadHintCacheDirName is the name of the subdirectory of the cache directory where upload hints are stored.
+const UploadHintCacheDirName = "upload-hints"
+
+const uploadHintCacheFileName = "hints.json"
+
+// uploadHintKey identifies the contents of a local file on a machine.
+type uploadHintKey struct {
+	Device  uint64 `json:"dev"`
+	Inode   uint64 `json:"ino"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+}
+
+type uploadHintEntry struct {
+	uploadHintKey
+
+	ObjectID object.ID `json:"oid"`
+	LastUsed int64     `json:"used"`
+}
+
+type uploadHintCacheFile struct {
+	Entries []*uploadHintEntry `json:"entries"`
+}
+
+// UploadHintCache is a persistent local cache that maps the identity of local files (device, inode, size and
+// modification time) to object IDs of their contents. It is shared across all sources snapshotted on a machine,
+// which allows the uploader to skip hashing of unchanged files that have been moved, renamed or are
+// part of a different source.
+type UploadHintCache struct {
+	filename   string
+	maxEntries int
+
+	mu sync.Mutex
+	// +checklocks:mu
+	entries map[uploadHintKey]*uploadHintEntry
+	// +checklocks:mu
+	dirty bool
+}
+
+// OpenUploadHintCache opens the upload hint cache stored in the provided cache directory.
+// A missing or unreadable cache is treated as empty.
+func OpenUploadHintCache(ctx context.Context, cacheDir string, maxEntries int) *UploadHintCache {
+	c := &UploadHintCache{
+		filename:   filepath.Join(cacheDir, UploadHintCacheDirName, uploadHintCacheFileName),
+		maxEntries: maxEntries,
+		entries:    map[uploadHintKey]*uploadHintEntry{},
+	}
+
+	if c.maxEntries <= 0 {
+		c.maxEntries = DefaultUploadHintCacheMaxEntries
+	}
+
+	b, err := os.ReadFile(c.filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			uploadLog(ctx).Debugf("unable to read upload hints: %v", err)
+		}
+
+		return c
+	}
+
+	var f uploadHintCacheFile
+
+	if err := json.Unmarshal(b, &f); err != nil {
+		uploadLog(ctx).Debugf("invalid upload hints, ignoring: %v", err)
+		return c
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range f.Entries {
+		c.entries[e.uploadHintKey] = e
+	}
+
+	return c
+}
+
+func uploadHintKeyForEntry(e fs.Entry) (uploadHintKey, bool) {
+	hi, ok := e.(fs.HasInode)
+	if !ok || hi.Inode() == 0 {
+		return uploadHintKey{}, false
+	}
+
+	return uploadHintKey{
+		Device:  e.Device().Dev,
+		Inode:   hi.Inode(),
+		Size:    e.Size(),
+		ModTime: e.ModTime().UnixNano(),
+	}, true
+}
+
+// Get returns the object ID of the contents of the provided file if it has been uploaded before.
+func (c *UploadHintCache) Get(e fs.Entry) (object.ID, bool) {
+	if c == nil {
+		return object.EmptyID, false
+	}
+
+	key, ok := uploadHintKeyForEntry(e)
+	if !ok {
+		return object.EmptyID, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	he := c.entries[key]
+	if he == nil {
+		return object.EmptyID, false
+	}
+
+	he.LastUsed = clock.Now().Unix()
+	c.dirty = true
+
+	return he.ObjectID, true
+}
+
+// Put records the object ID of the contents of the provided file.
+func (c *UploadHintCache) Put(e fs.Entry, oid object.ID) {
+	if c == nil {
+		return
+	}
+
+	key, ok := uploadHintKeyForEntry(e)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &uploadHintEntry{key, oid, clock.Now().Unix()}
+	c.dirty = true
+}
+
+// Remove removes the hint for the provided file.
+func (c *UploadHintCache) Remove(e fs.Entry) {
+	if c == nil {
+		return
+	}
+
+	key, ok := uploadHintKeyForEntry(e)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.dirty = true
+	}
+}
+
+// Save persists the cache, retaining up to maxEntries most recently used entries.
+func (c *UploadHintCache) Save(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	var f uploadHintCacheFile
+
+	for _, e := range c.entries {
+		f.Entries = append(f.Entries, e)
+	}
+
+	sort.Slice(f.Entries, func(i, j int) bool {
+		return f.Entries[i].LastUsed > f.Entries[j].LastUsed
+	})
+
+	if len(f.Entries) > c.maxEntries {
+		for _, e := range f.Entries[c.maxEntries:] {
+			delete(c.entries, e.uploadHintKey)
+		}
+
+		f.Entries = f.Entries[:c.maxEntries]
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal upload hints")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0o700); err != nil { //nolint:mnd
+		return errors.Wrap(err, "unable to create upload hints directory")
+	}
+
+	if err := atomicfile.Write(c.filename, bytes.NewReader(b)); err != nil {
+		return errors.Wrap(err, "unable to write upload hints")
+	}
+
+	uploadLog(ctx).Debugf("saved %v upload hints", len(f.Entries))
+
+	c.dirty = false
+
+	return nil
+}
+
+// verifyHintedObject ensures that all contents of the provided object are present in the repository and
+// not deleted, because the snapshot which referenced them when the hint was recorded may have been deleted since.
+func verifyHintedObject(ctx context.Context, rep repo.Repository, oid object.ID) error {
+	cids, err := rep.VerifyObject(ctx, oid)
+	if err != nil {
+		return errors.Wrap(err, "unable to verify object")
+	}
+
+	for _, cid := range cids {
+		info, err := rep.ContentInfo(ctx, cid)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get content info for %v", cid)
+		}
+
+		if info.Deleted {
+			return errors.Errorf("content %v is deleted", cid)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package snapshotfs
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// MaxInlineFileSize is the maximum inline size limit, only files smaller than it can be stored inline in
+// directory entries regardless of the policy, to keep directory objects small.
+const MaxInlineFileSize = 16 << 10
+
+// maybeUploadInlineFile embeds contents of a very small file in its directory entry if enabled by the policy.
+// Only files strictly below the size limit are inlined.
+// Returns nil directory entry if the file was not inlined and must be uploaded as an object.
+func (u *Uploader) maybeUploadInlineFile(ctx context.Context, relativePath string, f fs.File, pol *policy.Policy) (de *snapshot.DirEntry, ret error) {
+	limit := min(pol.UploadPolicy.InlineFilesBelowSize.OrDefault(0), MaxInlineFileSize)
+	if limit <= 0 || f.Size() <= 0 || f.Size() >= limit {
+		return nil, nil
+	}
+
+	if _, ok := f.(snapshot.HasDirEntryOrNil); ok {
+		// placeholder files are never inlined.
+		return nil, nil
+	}
+
+	if !u.requireFeature(ctx, format.InlineObjectIDsRequirement) {
+		return nil, nil
+	}
+
+	data, err := readSmallFile(ctx, f, limit-1)
+	if err == nil && len(data) == 0 {
+		// file has grown or was truncated since it was listed, upload it as an object.
+		return nil, nil
+	}
+
+	u.Progress.HashingFile(relativePath)
+
+	defer func() {
+		u.Progress.FinishedFile(relativePath, ret)
+	}()
+	defer u.Progress.FinishedHashingFile(relativePath, int64(len(data)))
+
+	if err != nil {
+		return nil, err
+	}
+
+	u.Progress.HashedBytes(int64(len(data)))
+
+	de, err = newDirEntry(f, f.Name(), object.InlineObjectID(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.FileSize = int64(len(data))
+
+	atomic.AddInt32(&u.stats.TotalFileCount, 1)
+	atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
+
+	return de, nil
+}
@@ -0,0 +1,228 @@
+package snapshotfs
+
+import (
+	"context"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/splitter"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+const (
+	// memory reserved for the runtime, caches and other fixed overhead of the upload.
+	memoryBudgetBaseBytes = 64 << 20
+
+	// each file being read holds the buffer of the current chunk, the chunk being written asynchronously
+	// and the buffer of compressed and encrypted data.
+	fileReadBuffersPerSegment = 3
+
+	// share of the memory budget above the base used for buffers of files being read, the rest is used
+	// for buffered index entries.
+	fileReadMemoryShare = 0.75
+
+	// approximate memory used by each pending index entry.
+	bytesPerPendingIndexEntry = 256
+	minPendingIndexEntries    = 10000
+
+	// heap usage, as a fraction of the budget, above which parallelism is reduced and below which it is increased.
+	memoryPressureHigh = 0.9
+	memoryPressureLow  = 0.6
+
+	memoryMonitorInterval = time.Second
+
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+)
+
+// memoryPlan describes parameters of the upload derived from the memory budget.
+type memoryPlan struct {
+	budget                 int64
+	parallelFileReads      int
+	maxPendingIndexEntries int
+}
+
+// planMemoryBudget computes upload parameters that keep the upload within the provided budget, given the
+// requested parallelism and maximum size of a segment produced by the splitter.
+func planMemoryBudget(budget int64, parallel, maxSegmentSize int) memoryPlan {
+	available := max(0, budget-memoryBudgetBaseBytes)
+	perFileRead := int64(fileReadBuffersPerSegment * maxSegmentSize)
+
+	return memoryPlan{
+		budget:                 budget,
+		parallelFileReads:      max(1, min(parallel, int(float64(available)*fileReadMemoryShare)/int(max(1, perFileRead)))),
+		maxPendingIndexEntries: max(minPendingIndexEntries, int(float64(available)*(1-fileReadMemoryShare))/bytesPerPendingIndexEntry),
+	}
+}
+
+// effectiveMemoryBudget returns the memory budget of the upload, 0 if not limited.
+func (u *Uploader) effectiveMemoryBudget(pol *policy.Policy) int64 {
+	if u.MemoryBudget > 0 {
+		// command-line override takes precedence.
+		return u.MemoryBudget
+	}
+
+	return pol.UploadPolicy.MemoryBudget.OrDefault(0)
+}
+
+func maxSegmentSizeForPolicy(pol *policy.Policy) int {
+	name := pol.SplitterPolicy.SplitterForFile(nil)
+	if name == "" {
+		name = splitter.DefaultAlgorithm
+	}
+
+	f := splitter.GetFactory(name)
+	if f == nil {
+		f = splitter.GetFactory(splitter.DefaultAlgorithm)
+	}
+
+	s := f()
+	defer s.Close()
+
+	return s.MaxSegmentSize()
+}
+
+// memoryGate limits the number of files read concurrently, the limit is adjusted based on heap usage.
+type memoryGate struct {
+	maxLimit int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// +checklocks:mu
+	limit int
+	// +checklocks:mu
+	active int
+}
+
+func newMemoryGate(limit int) *memoryGate {
+	g := &memoryGate{
+		maxLimit: limit,
+		limit:    limit,
+	}
+
+	g.cond = sync.NewCond(&g.mu)
+
+	return g
+}
+
+// acquire waits until another file can be read, nil gate never blocks.
+func (g *memoryGate) acquire() {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+
+	g.active++
+}
+
+func (g *memoryGate) release() {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.active--
+	g.cond.Broadcast()
+}
+
+func (g *memoryGate) currentLimit() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.limit
+}
+
+// adjust reduces the limit when heap usage is high relative to the budget and restores it when usage is low.
+// Returns the new limit.
+func (g *memoryGate) adjust(heapBytes, budget int64) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case float64(heapBytes) > memoryPressureHigh*float64(budget) && g.limit > 1:
+		g.limit--
+
+	case float64(heapBytes) < memoryPressureLow*float64(budget) && g.limit < g.maxLimit:
+		g.limit++
+		g.cond.Broadcast()
+	}
+
+	return g.limit
+}
+
+func heapObjectsBytes() int64 {
+	s := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(s)
+
+	if s[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return int64(s[0].Value.Uint64()) //nolint:gosec
+}
+
+// startMemoryBudget applies the memory plan to the upload and starts monitoring of heap usage,
+// the returned function must be called at the end of the upload.
+func (u *Uploader) startMemoryBudget(ctx context.Context, plan memoryPlan) func() {
+	u.memoryGate = newMemoryGate(plan.parallelFileReads)
+
+	previousLimit := debug.SetMemoryLimit(plan.budget)
+
+	dw, _ := u.repo.(repo.DirectRepositoryWriter)
+	if dw != nil {
+		dw.ContentManager().SetMaxPendingIndexEntries(plan.maxPendingIndexEntries)
+	}
+
+	uploadLog(ctx).Debugw("memory budget",
+		"budget", plan.budget,
+		"parallelFileReads", plan.parallelFileReads,
+		"maxPendingIndexEntries", plan.maxPendingIndexEntries)
+
+	monitorCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(memoryMonitorInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-monitorCtx.Done():
+				return
+
+			case <-ticker.C:
+				before := u.memoryGate.currentLimit()
+
+				if after := u.memoryGate.adjust(heapObjectsBytes(), plan.budget); after != before {
+					uploadLog(ctx).Debugw("adjusted parallel file reads to memory usage", "parallelFileReads", after)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		if dw != nil {
+			dw.ContentManager().SetMaxPendingIndexEntries(0)
+		}
+
+		debug.SetMemoryLimit(previousLimit)
+
+		u.memoryGate = nil
+	}
+}
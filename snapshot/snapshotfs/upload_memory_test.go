@@ -0,0 +1,87 @@
+package snapshotfs
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestPlanMemoryBudget(t *testing.T) {
+	const segment = 8 << 20
+
+	// 512 MiB NAS box - 448 MiB available, 336 MiB for 24 MiB of buffers per file read.
+	p := planMemoryBudget(512<<20, 16, segment)
+	require.Equal(t, 14, p.parallelFileReads)
+	require.Equal(t, 112<<20/bytesPerPendingIndexEntry, p.maxPendingIndexEntries)
+
+	// large budget is limited by requested parallelism.
+	p = planMemoryBudget(16<<30, 16, segment)
+	require.Equal(t, 16, p.parallelFileReads)
+
+	// tiny budget still allows progress.
+	p = planMemoryBudget(32<<20, 16, segment)
+	require.Equal(t, 1, p.parallelFileReads)
+	require.Equal(t, minPendingIndexEntries, p.maxPendingIndexEntries)
+}
+
+func TestMemoryGate(t *testing.T) {
+	g := newMemoryGate(3)
+
+	const budget = 1000
+
+	require.Equal(t, 2, g.adjust(950, budget))
+	require.Equal(t, 1, g.adjust(950, budget))
+	require.Equal(t, 1, g.adjust(950, budget))
+	require.Equal(t, 1, g.adjust(700, budget))
+	require.Equal(t, 2, g.adjust(100, budget))
+	require.Equal(t, 3, g.adjust(100, budget))
+	require.Equal(t, 3, g.adjust(100, budget))
+
+	var nilGate *memoryGate
+
+	nilGate.acquire()
+	nilGate.release()
+}
+
+func TestUpload_MemoryBudget(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	previousLimit := debug.SetMemoryLimit(-1)
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 8
+
+	policyTree := policy.BuildTree(map[string]*policy.Policy{
+		".": {
+			UploadPolicy: policy.UploadPolicy{
+				MemoryBudget: newOptionalInt64ForTest(128 << 20),
+			},
+		},
+	}, policy.DefaultPolicy)
+
+	require.Equal(t, int64(128<<20), u.effectiveMemoryBudget(policyTree.EffectivePolicy()))
+
+	u.MemoryBudget = 256 << 20
+	require.Equal(t, int64(256<<20), u.effectiveMemoryBudget(policyTree.EffectivePolicy()))
+
+	s, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), s.RootEntry.DirSummary.TotalFileCount)
+
+	// memory limit and gate are restored after the upload.
+	require.Equal(t, previousLimit, debug.SetMemoryLimit(-1))
+	require.Nil(t, u.memoryGate)
+}
+
+func newOptionalInt64ForTest(v int64) *policy.OptionalInt64 {
+	o := policy.OptionalInt64(v)
+	return &o
+}
@@ -0,0 +1,377 @@
+package snapshotfs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// btrfsSubvolumeRootInode is the inode number of the root directory of every btrfs subvolume.
+const btrfsSubvolumeRootInode = 256
+
+//nolint:gochecknoglobals
+var (
+	// mountInfoFile is the file describing mounted filesystems, overridden in tests.
+	mountInfoFile = "/proc/self/mountinfo"
+
+	// runOSSnapshotCommand runs the provided command and returns its output, overridden in tests.
+	runOSSnapshotCommand = func(ctx context.Context, name string, args ...string) (string, error) {
+		out, err := exec.CommandContext(ctx, name, args...).CombinedOutput() //nolint:gosec
+		if err != nil {
+			return "", errors.Wrapf(err, "%v %v failed: %v", name, strings.Join(args, " "), strings.TrimSpace(string(out)))
+		}
+
+		return string(out), nil
+	}
+)
+
+// osSnapshotProvider creates a point-in-time snapshot of the filesystem mounted at the provided mount point
+// and returns the path corresponding to dir inside the snapshot along with a function that removes the snapshot.
+type osSnapshotProvider func(ctx context.Context, m *mountInfo, dir string, p *policy.FilesystemSnapshotPolicy) (snapshotDir string, cleanup func(), err error)
+
+//nolint:gochecknoglobals
+var osSnapshotProviders = map[string]osSnapshotProvider{
+	policy.FilesystemSnapshotProviderBtrfs: createBtrfsSnapshot,
+	policy.FilesystemSnapshotProviderZFS:   createZFSSnapshot,
+	policy.FilesystemSnapshotProviderLVM:   createLVMSnapshot,
+}
+
+// mountInfo describes a mounted filesystem.
+type mountInfo struct {
+	MountPoint string
+	FSType     string
+	Source     string
+}
+
+func osSnapshotMode(p *policy.OSSnapshotPolicy) policy.OSSnapshotMode {
+	return p.FilesystemSnapshot.Enable.OrDefault(policy.OSSnapshotNever)
+}
+
+func createOSSnapshot(ctx context.Context, root fs.Directory, p *policy.OSSnapshotPolicy) (newRoot fs.Directory, cleanup func(), err error) {
+	local := root.LocalFilesystemPath()
+	if local == "" {
+		return nil, nil, errors.New("not a local filesystem")
+	}
+
+	dir, err := filepath.EvalSymlinks(local)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to resolve path")
+	}
+
+	mounts, err := readMountInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := findMount(mounts, dir)
+	if m == nil {
+		return nil, nil, errors.Errorf("unable to find filesystem containing %v", dir)
+	}
+
+	providerName, err := osSnapshotProviderName(m, p.FilesystemSnapshot.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uploadLog(ctx).Infof("creating %v snapshot of %v mounted at %v", providerName, m.Source, m.MountPoint)
+
+	snapshotDir, cleanup, err := osSnapshotProviders[providerName](ctx, m, dir, &p.FilesystemSnapshot)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to create %v snapshot", providerName)
+	}
+
+	newRoot, err = localfs.Directory(snapshotDir)
+	if err != nil {
+		cleanup()
+
+		return nil, nil, errors.Wrap(err, "unable to open snapshot root")
+	}
+
+	uploadLog(ctx).Debugf("filesystem snapshot root is %s", snapshotDir)
+
+	return newRoot, cleanup, nil
+}
+
+// osSnapshotProviderName returns the name of the provider to use for the provided mount,
+// detecting it based on the filesystem type unless explicitly configured.
+func osSnapshotProviderName(m *mountInfo, configured string) (string, error) {
+	if configured != "" && configured != policy.FilesystemSnapshotProviderAuto {
+		if osSnapshotProviders[configured] == nil {
+			return "", errors.Errorf("unsupported filesystem snapshot provider: %v", configured)
+		}
+
+		return configured, nil
+	}
+
+	switch {
+	case m.FSType == "btrfs":
+		return policy.FilesystemSnapshotProviderBtrfs, nil
+	case m.FSType == "zfs":
+		return policy.FilesystemSnapshotProviderZFS, nil
+	case strings.HasPrefix(m.Source, "/dev/mapper/"):
+		return policy.FilesystemSnapshotProviderLVM, nil
+	default:
+		return "", errors.Errorf("filesystem snapshots are not supported for %v filesystem on %v", m.FSType, m.Source)
+	}
+}
+
+func readMountInfo() ([]mountInfo, error) {
+	f, err := os.Open(mountInfoFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read mount info")
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	return parseMountInfo(f)
+}
+
+// parseMountInfo parses the contents of /proc/self/mountinfo, where each line is in the form:
+// "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue".
+func parseMountInfo(r io.Reader) ([]mountInfo, error) {
+	var result []mountInfo
+
+	s := bufio.NewScanner(r)
+
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+
+		sep := -1
+
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+
+		//nolint:mnd
+		if sep < 5 || len(fields) < sep+3 {
+			return nil, errors.Errorf("invalid mount info line: %q", s.Text())
+		}
+
+		result = append(result, mountInfo{
+			MountPoint: unescapeMountInfo(fields[4]),
+			FSType:     fields[sep+1],
+			Source:     unescapeMountInfo(fields[sep+2]),
+		})
+	}
+
+	return result, errors.Wrap(s.Err(), "error reading mount info")
+}
+
+// unescapeMountInfo decodes octal escapes (such as \040 for space) used in mount info fields.
+func unescapeMountInfo(s string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(v))
+
+				i += 3
+
+				continue
+			}
+		}
+
+		sb.WriteByte(s[i])
+	}
+
+	return sb.String()
+}
+
+// findMount returns the most recently mounted filesystem with the longest mount point containing the provided path.
+func findMount(mounts []mountInfo, path string) *mountInfo {
+	var best *mountInfo
+
+	for i := range mounts {
+		m := &mounts[i]
+
+		if !isPathWithin(path, m.MountPoint) {
+			continue
+		}
+
+		if best == nil || len(m.MountPoint) >= len(best.MountPoint) {
+			best = m
+		}
+	}
+
+	return best
+}
+
+func isPathWithin(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+
+	return strings.HasPrefix(path, dir+"/")
+}
+
+func relativeToMountPoint(m *mountInfo, dir string) string {
+	rel, err := filepath.Rel(m.MountPoint, dir)
+	if err != nil {
+		return "."
+	}
+
+	return rel
+}
+
+func newOSSnapshotName() string {
+	return fmt.Sprintf("kopia-%v-%x", clock.Now().Unix(), rand.Int63()) //nolint:gosec
+}
+
+// createBtrfsSnapshot creates a read-only snapshot of the btrfs subvolume containing the provided directory.
+// The snapshot is stored in the root of the subvolume.
+func createBtrfsSnapshot(ctx context.Context, m *mountInfo, dir string, _ *policy.FilesystemSnapshotPolicy) (string, func(), error) {
+	subvolume := btrfsSubvolumeRoot(m, dir)
+
+	rel, err := filepath.Rel(subvolume, dir)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "unable to determine path within subvolume")
+	}
+
+	snapshotPath := filepath.Join(subvolume, "."+newOSSnapshotName())
+
+	if _, err := runOSSnapshotCommand(ctx, "btrfs", "subvolume", "snapshot", "-r", subvolume, snapshotPath); err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		uploadLog(ctx).Infof("removing btrfs snapshot %v", snapshotPath)
+
+		if _, err := runOSSnapshotCommand(context.WithoutCancel(ctx), "btrfs", "subvolume", "delete", snapshotPath); err != nil {
+			uploadLog(ctx).Errorf("failed to remove btrfs snapshot: %v", err)
+		}
+	}
+
+	return filepath.Join(snapshotPath, rel), cleanup, nil
+}
+
+// btrfsSubvolumeRoot returns the root of the subvolume containing the provided directory,
+// which is its closest ancestor with the subvolume root inode number.
+func btrfsSubvolumeRoot(m *mountInfo, dir string) string {
+	for d := dir; isPathWithin(d, m.MountPoint); d = filepath.Dir(d) {
+		if st, err := os.Stat(d); err == nil {
+			if sys, ok := st.Sys().(*syscall.Stat_t); ok && sys.Ino == btrfsSubvolumeRootInode {
+				return d
+			}
+		}
+
+		if d == m.MountPoint || d == filepath.Dir(d) {
+			break
+		}
+	}
+
+	return m.MountPoint
+}
+
+// createZFSSnapshot creates a snapshot of the ZFS dataset mounted at the mount point, which is accessible
+// through the hidden .zfs directory of the dataset.
+func createZFSSnapshot(ctx context.Context, m *mountInfo, dir string, _ *policy.FilesystemSnapshotPolicy) (string, func(), error) {
+	name := newOSSnapshotName()
+	zfsSnapshot := m.Source + "@" + name
+
+	if _, err := runOSSnapshotCommand(ctx, "zfs", "snapshot", zfsSnapshot); err != nil {
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		uploadLog(ctx).Infof("removing ZFS snapshot %v", zfsSnapshot)
+
+		if _, err := runOSSnapshotCommand(context.WithoutCancel(ctx), "zfs", "destroy", zfsSnapshot); err != nil {
+			uploadLog(ctx).Errorf("failed to remove ZFS snapshot: %v", err)
+		}
+	}
+
+	return filepath.Join(m.MountPoint, ".zfs", "snapshot", name, relativeToMountPoint(m, dir)), cleanup, nil
+}
+
+// createLVMSnapshot creates a snapshot of the LVM logical volume mounted at the mount point
+// and mounts it read-only in a temporary directory.
+func createLVMSnapshot(ctx context.Context, m *mountInfo, dir string, p *policy.FilesystemSnapshotPolicy) (snapshotDir string, cleanup func(), err error) {
+	out, err := runOSSnapshotCommand(ctx, "lvs", "--noheadings", "--separator", "/", "-o", "vg_name,lv_name", m.Source)
+	if err != nil {
+		return "", nil, err
+	}
+
+	volume := strings.TrimSpace(out)
+
+	vg, _, ok := strings.Cut(volume, "/")
+	if !ok {
+		return "", nil, errors.Errorf("unable to determine logical volume of %v: %q", m.Source, volume)
+	}
+
+	name := newOSSnapshotName()
+	size := p.LVMSnapshotSize.OrDefault(1 << 30) //nolint:mnd
+
+	if _, err = runOSSnapshotCommand(ctx, "lvcreate", "--snapshot", "--size", fmt.Sprintf("%vb", size), "--name", name, volume); err != nil {
+		return "", nil, err
+	}
+
+	var cleanups []func()
+
+	cleanup = func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	cleanups = append(cleanups, func() {
+		uploadLog(ctx).Infof("removing LVM snapshot %v/%v", vg, name)
+
+		if _, err := runOSSnapshotCommand(context.WithoutCancel(ctx), "lvremove", "--force", vg+"/"+name); err != nil {
+			uploadLog(ctx).Errorf("failed to remove LVM snapshot: %v", err)
+		}
+	})
+
+	mountPoint, err := os.MkdirTemp("", "kopia-lvm-snapshot-")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "unable to create mount point")
+	}
+
+	cleanups = append(cleanups, func() {
+		if err := os.Remove(mountPoint); err != nil {
+			uploadLog(ctx).Errorf("failed to remove LVM snapshot mount point: %v", err)
+		}
+	})
+
+	mountOptions := "ro"
+	if m.FSType == "xfs" {
+		// XFS refuses to mount a filesystem with the same UUID as already mounted one.
+		mountOptions += ",nouuid"
+	}
+
+	if _, err = runOSSnapshotCommand(ctx, "mount", "-o", mountOptions, filepath.Join("/dev", vg, name), mountPoint); err != nil {
+		return "", nil, err
+	}
+
+	cleanups = append(cleanups, func() {
+		if _, err := runOSSnapshotCommand(context.WithoutCancel(ctx), "umount", mountPoint); err != nil {
+			uploadLog(ctx).Errorf("failed to unmount LVM snapshot: %v", err)
+		}
+	})
+
+	return filepath.Join(mountPoint, relativeToMountPoint(m, dir)), cleanup, nil
+}
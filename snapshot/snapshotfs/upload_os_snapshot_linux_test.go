@@ -0,0 +1,153 @@
+package snapshotfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+const testMountInfo = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /proc rw,nosuid shared:2 - proc proc rw
+24 22 0:30 / /data rw,relatime shared:3 - btrfs /dev/sdb1 rw,subvol=/
+25 22 0:31 / /tank rw,relatime shared:4 - zfs tank/home rw,xattr
+26 22 253:0 / /srv/my\040data rw,relatime shared:5 - xfs /dev/mapper/vg0-data rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := parseMountInfo(strings.NewReader(testMountInfo))
+	require.NoError(t, err)
+	require.Len(t, mounts, 5)
+	require.Equal(t, mountInfo{MountPoint: "/srv/my data", FSType: "xfs", Source: "/dev/mapper/vg0-data"}, mounts[4])
+
+	cases := []struct {
+		path         string
+		wantMount    string
+		wantProvider string
+	}{
+		{"/home/user", "/", ""},
+		{"/data", "/data", policy.FilesystemSnapshotProviderBtrfs},
+		{"/data/x/y", "/data", policy.FilesystemSnapshotProviderBtrfs},
+		{"/database", "/", ""},
+		{"/tank/some/dir", "/tank", policy.FilesystemSnapshotProviderZFS},
+		{"/srv/my data/files", "/srv/my data", policy.FilesystemSnapshotProviderLVM},
+	}
+
+	for _, tc := range cases {
+		m := findMount(mounts, tc.path)
+		require.NotNil(t, m, tc.path)
+		require.Equal(t, tc.wantMount, m.MountPoint, tc.path)
+
+		provider, err := osSnapshotProviderName(m, policy.FilesystemSnapshotProviderAuto)
+		if tc.wantProvider == "" {
+			require.Error(t, err, tc.path)
+			continue
+		}
+
+		require.NoError(t, err, tc.path)
+		require.Equal(t, tc.wantProvider, provider, tc.path)
+	}
+
+	_, err = parseMountInfo(strings.NewReader("invalid line\n"))
+	require.Error(t, err)
+
+	// explicitly configured provider overrides detection.
+	provider, err := osSnapshotProviderName(&mounts[0], policy.FilesystemSnapshotProviderLVM)
+	require.NoError(t, err)
+	require.Equal(t, policy.FilesystemSnapshotProviderLVM, provider)
+
+	_, err = osSnapshotProviderName(&mounts[0], "no-such-provider")
+	require.Error(t, err)
+}
+
+// fakeOSSnapshotCommands replaces execution of snapshot commands with recording them.
+func fakeOSSnapshotCommands(t *testing.T, outputs map[string]string) *[]string {
+	t.Helper()
+
+	var commands []string
+
+	old := runOSSnapshotCommand
+
+	t.Cleanup(func() { runOSSnapshotCommand = old })
+
+	runOSSnapshotCommand = func(_ context.Context, name string, args ...string) (string, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+
+		return outputs[name], nil
+	}
+
+	return &commands
+}
+
+func TestCreateZFSSnapshot(t *testing.T) {
+	ctx := testlogging.Context(t)
+	commands := fakeOSSnapshotCommands(t, nil)
+
+	dir, cleanup, err := createZFSSnapshot(ctx, &mountInfo{MountPoint: "/tank", FSType: "zfs", Source: "tank/home"}, "/tank/some/dir", &policy.FilesystemSnapshotPolicy{})
+	require.NoError(t, err)
+
+	require.Len(t, *commands, 1)
+	require.True(t, strings.HasPrefix((*commands)[0], "zfs snapshot tank/home@kopia-"), (*commands)[0])
+
+	name := strings.TrimPrefix((*commands)[0], "zfs snapshot tank/home@")
+	require.Equal(t, filepath.Join("/tank/.zfs/snapshot", name, "some/dir"), dir)
+
+	cleanup()
+	require.Equal(t, "zfs destroy tank/home@"+name, (*commands)[1])
+}
+
+func TestCreateLVMSnapshot(t *testing.T) {
+	ctx := testlogging.Context(t)
+	commands := fakeOSSnapshotCommands(t, map[string]string{"lvs": "  vg0/data\n"})
+
+	size := policy.OptionalInt64(1 << 20)
+	p := &policy.FilesystemSnapshotPolicy{LVMSnapshotSize: &size}
+
+	dir, cleanup, err := createLVMSnapshot(ctx, &mountInfo{MountPoint: "/srv", FSType: "xfs", Source: "/dev/mapper/vg0-data"}, "/srv/files", p)
+	require.NoError(t, err)
+
+	require.Len(t, *commands, 3)
+	require.Equal(t, "lvs --noheadings --separator / -o vg_name,lv_name /dev/mapper/vg0-data", (*commands)[0])
+	require.True(t, strings.HasPrefix((*commands)[1], "lvcreate --snapshot --size 1048576b --name kopia-"), (*commands)[1])
+	require.True(t, strings.HasSuffix((*commands)[1], " vg0/data"), (*commands)[1])
+	require.True(t, strings.HasPrefix((*commands)[2], "mount -o ro,nouuid /dev/vg0/kopia-"), (*commands)[2])
+
+	mountPoint := filepath.Dir(dir)
+	require.Equal(t, "files", filepath.Base(dir))
+	require.DirExists(t, mountPoint)
+
+	cleanup()
+	require.Len(t, *commands, 5)
+	require.Equal(t, "umount "+mountPoint, (*commands)[3])
+	require.True(t, strings.HasPrefix((*commands)[4], "lvremove --force vg0/kopia-"), (*commands)[4])
+	require.NoDirExists(t, mountPoint)
+}
+
+func TestCreateBtrfsSnapshot(t *testing.T) {
+	ctx := testlogging.Context(t)
+	commands := fakeOSSnapshotCommands(t, nil)
+
+	// in a non-btrfs directory the mount point is assumed to be the subvolume root.
+	mnt := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(mnt, "a", "b"), 0o700))
+
+	dir, cleanup, err := createBtrfsSnapshot(ctx, &mountInfo{MountPoint: mnt, FSType: "btrfs", Source: "/dev/sdb1"}, filepath.Join(mnt, "a", "b"), &policy.FilesystemSnapshotPolicy{})
+	require.NoError(t, err)
+
+	require.Len(t, *commands, 1)
+
+	snapshotPath := filepath.Dir(filepath.Dir(dir))
+	require.Equal(t, mnt, filepath.Dir(snapshotPath))
+	require.Equal(t, "btrfs subvolume snapshot -r "+mnt+" "+snapshotPath, (*commands)[0])
+	require.Equal(t, filepath.Join(snapshotPath, "a", "b"), dir)
+
+	cleanup()
+	require.Equal(t, "btrfs subvolume delete "+snapshotPath, (*commands)[1])
+}
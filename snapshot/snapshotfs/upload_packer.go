@@ -0,0 +1,154 @@
+package snapshotfs
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/format"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+// packerKey identifies a packer used for small files sharing the same object options.
+type packerKey struct {
+	compressor         compression.Name
+	metadataCompressor compression.Name
+	splitter           string
+}
+
+// packerFor returns the packer for small files with the provided object options.
+func (u *Uploader) packerFor(key packerKey) *object.Packer {
+	u.packersMutex.Lock()
+	defer u.packersMutex.Unlock()
+
+	if u.packers == nil {
+		u.packers = map[packerKey]*object.Packer{}
+	}
+
+	p := u.packers[key]
+	if p == nil {
+		p = object.NewPacker(u.repo.NewObjectWriter, object.WriterOptions{
+			Description:        "PACKED-FILES",
+			Compressor:         key.compressor,
+			MetadataCompressor: key.metadataCompressor,
+			Splitter:           key.splitter,
+		}, object.DefaultMaxPackedObjectSize)
+
+		u.packers[key] = p
+	}
+
+	return p
+}
+
+// flushPackers writes all pending packed objects, after which directory entries of all
+// packed files have been delivered to their callbacks.
+func (u *Uploader) flushPackers(ctx context.Context) error {
+	u.packersMutex.Lock()
+
+	var packers []*object.Packer
+
+	for _, p := range u.packers {
+		packers = append(packers, p)
+	}
+
+	u.packersMutex.Unlock()
+
+	for _, p := range packers {
+		if err := p.Flush(ctx); err != nil {
+			return errors.Wrap(err, "error flushing packed files")
+		}
+	}
+
+	return nil
+}
+
+// maybeUploadPackedFile uploads contents of a small file as part of a shared packed object if enabled by the policy.
+// Returns false if the file was not packed and must be uploaded as a separate object.
+// The directory entry of a packed file is passed to the provided callback when the packed object is written,
+// which happens no later than the next call to flushPackers().
+func (u *Uploader) maybeUploadPackedFile(ctx context.Context, relativePath string, f fs.File, pol *policy.Policy, done func(de *snapshot.DirEntry)) (packed bool, ret error) {
+	maxSize := pol.UploadPolicy.PackFilesBelowSize.OrDefault(0)
+	if maxSize <= 0 || f.Size() <= 0 || f.Size() > maxSize {
+		return false, nil
+	}
+
+	if _, ok := f.(snapshot.HasDirEntryOrNil); ok {
+		// placeholder files are never packed.
+		return false, nil
+	}
+
+	if u.IsCanceled() || !u.requireFeature(ctx, format.PackedObjectIDsRequirement) {
+		return false, nil
+	}
+
+	data, err := readSmallFile(ctx, f, maxSize)
+	if err == nil && data == nil {
+		// file has grown since it was listed, upload it as a separate object.
+		return false, nil
+	}
+
+	u.Progress.HashingFile(relativePath)
+
+	defer func() {
+		u.Progress.FinishedFile(relativePath, ret)
+	}()
+	defer u.Progress.FinishedHashingFile(relativePath, int64(len(data)))
+
+	if err != nil {
+		return true, err
+	}
+
+	u.totalWrittenBytes.Add(int64(len(data)))
+	u.Progress.HashedBytes(int64(len(data)))
+
+	de, err := newDirEntry(f, f.Name(), object.EmptyID)
+	if err != nil {
+		return true, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.FileSize = int64(len(data))
+
+	p := u.packerFor(packerKey{
+		compressor:         pol.CompressionPolicy.CompressorForFile(f),
+		metadataCompressor: pol.MetadataCompressionPolicy.MetadataCompressor(),
+		splitter:           pol.SplitterPolicy.SplitterForFile(f),
+	})
+
+	if err := p.Add(ctx, data, func(oid object.ID) {
+		de.ObjectID = oid
+		done(de)
+	}); err != nil {
+		return true, errors.Wrap(err, "unable to pack file")
+	}
+
+	atomic.AddInt32(&u.stats.TotalFileCount, 1)
+	atomic.AddInt64(&u.stats.TotalFileSize, de.FileSize)
+
+	return true, nil
+}
+
+// readSmallFile reads the entire contents of the file, returns nil if the file is larger than maxSize.
+func readSmallFile(ctx context.Context, f fs.File, maxSize int64) ([]byte, error) {
+	file, err := f.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open file")
+	}
+	defer file.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read file")
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, nil
+	}
+
+	return data, nil
+}
@@ -3,7 +3,9 @@
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/uitask"
 )
 
@@ -198,6 +200,9 @@ type UploadCounters struct {
 
 	LastErrorPath string `json:"lastErrorPath"`
 	LastError     string `json:"lastError"`
+
+	// ETA is the predicted completion of the upload, nil if not known yet.
+	ETA *UploadETA `json:"eta,omitempty"`
 }
 
 // CountingUploadProgress is an implementation of UploadProgress that accumulates counters.
@@ -207,12 +212,19 @@ type CountingUploadProgress struct {
 	mu sync.Mutex
 
 	counters UploadCounters
+
+	// +checklocks:mu
+	startTime time.Time
 }
 
 // UploadStarted implements UploadProgress.
 func (p *CountingUploadProgress) UploadStarted() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// reset counters to all-zero values.
 	p.counters = UploadCounters{}
+	p.startTime = clock.Now()
 }
 
 // UploadedBytes implements UploadProgress.
@@ -293,19 +305,47 @@ func (p *CountingUploadProgress) Snapshot() UploadCounters {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	return UploadCounters{
-		TotalCachedFiles:  atomic.LoadInt32(&p.counters.TotalCachedFiles),
-		TotalHashedFiles:  atomic.LoadInt32(&p.counters.TotalHashedFiles),
-		TotalCachedBytes:  atomic.LoadInt64(&p.counters.TotalCachedBytes),
-		TotalHashedBytes:  atomic.LoadInt64(&p.counters.TotalHashedBytes),
-		EstimatedBytes:    atomic.LoadInt64(&p.counters.EstimatedBytes),
-		EstimatedFiles:    atomic.LoadInt64(&p.counters.EstimatedFiles),
-		IgnoredErrorCount: atomic.LoadInt32(&p.counters.IgnoredErrorCount),
-		FatalErrorCount:   atomic.LoadInt32(&p.counters.FatalErrorCount),
-		CurrentDirectory:  p.counters.CurrentDirectory,
-		LastErrorPath:     p.counters.LastErrorPath,
-		LastError:         p.counters.LastError,
+	c := UploadCounters{
+		TotalCachedFiles:   atomic.LoadInt32(&p.counters.TotalCachedFiles),
+		TotalHashedFiles:   atomic.LoadInt32(&p.counters.TotalHashedFiles),
+		TotalCachedBytes:   atomic.LoadInt64(&p.counters.TotalCachedBytes),
+		TotalHashedBytes:   atomic.LoadInt64(&p.counters.TotalHashedBytes),
+		TotalUploadedBytes: atomic.LoadInt64(&p.counters.TotalUploadedBytes),
+		EstimatedBytes:     atomic.LoadInt64(&p.counters.EstimatedBytes),
+		EstimatedFiles:     atomic.LoadInt64(&p.counters.EstimatedFiles),
+		IgnoredErrorCount:  atomic.LoadInt32(&p.counters.IgnoredErrorCount),
+		FatalErrorCount:    atomic.LoadInt32(&p.counters.FatalErrorCount),
+		CurrentDirectory:   p.counters.CurrentDirectory,
+		LastErrorPath:      p.counters.LastErrorPath,
+		LastError:          p.counters.LastError,
+	}
+
+	if eta, ok := p.etaLocked(c); ok {
+		c.ETA = &eta
+	}
+
+	return c
+}
+
+// ETA returns the predicted completion of the upload, false if not known yet.
+func (p *CountingUploadProgress) ETA() (UploadETA, bool) {
+	c := p.Snapshot()
+	if c.ETA == nil {
+		return UploadETA{}, false
 	}
+
+	return *c.ETA, true
+}
+
+// +checklocks:p.mu
+func (p *CountingUploadProgress) etaLocked(c UploadCounters) (UploadETA, bool) {
+	if p.startTime.IsZero() {
+		return UploadETA{}, false
+	}
+
+	now := clock.Now()
+
+	return EstimateUploadETA(c, now, now.Sub(p.startTime))
 }
 
 // UITaskCounters returns UI task counters.
@@ -337,6 +377,11 @@ func (p *CountingUploadProgress) UITaskCounters(final bool) map[string]uitask.Co
 	if !final {
 		m["Estimated Files"] = uitask.SimpleCounter(atomic.LoadInt64(&p.counters.EstimatedFiles))
 		m["Estimated Bytes"] = uitask.BytesCounter(atomic.LoadInt64(&p.counters.EstimatedBytes))
+
+		if eta, ok := p.ETA(); ok {
+			m["Predicted Upload Bytes"] = uitask.BytesCounter(eta.PredictedUploadBytes)
+			m["Estimated Seconds Remaining"] = uitask.SimpleCounter(int64(eta.Remaining.Seconds()))
+		}
 	}
 
 	return m
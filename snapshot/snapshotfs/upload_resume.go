@@ -0,0 +1,129 @@
+package snapshotfs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// checkpointDirectory wraps a directory of an incomplete snapshot being resumed. Subdirectories which
+// were completed before the snapshot was interrupted can be reused without being walked again.
+type checkpointDirectory struct {
+	fs.Directory
+
+	// start time of the incomplete snapshot.
+	startTime time.Time
+}
+
+func (d checkpointDirectory) Child(ctx context.Context, name string) (fs.Entry, error) {
+	e, err := d.Directory.Child(ctx, name)
+	if err != nil {
+		//nolint:wrapcheck
+		return nil, err
+	}
+
+	if sd, ok := e.(fs.Directory); ok {
+		return checkpointDirectory{sd, d.startTime}, nil
+	}
+
+	return e, nil
+}
+
+func (d checkpointDirectory) ObjectID() object.ID {
+	if h, ok := d.Directory.(object.HasObjectID); ok {
+		return h.ObjectID()
+	}
+
+	return object.EmptyID
+}
+
+func (d checkpointDirectory) DirEntry() *snapshot.DirEntry {
+	if h, ok := d.Directory.(snapshot.HasDirEntry); ok {
+		return h.DirEntry()
+	}
+
+	return nil
+}
+
+// completedCheckpointDirEntry returns the entry of a previous directory that was fully completed before
+// an incomplete snapshot was interrupted, provided that the local directory has not been modified since
+// that snapshot started.
+func completedCheckpointDirEntry(dir fs.Directory, prevDirs []fs.Directory) *snapshot.DirEntry {
+	for _, pd := range prevDirs {
+		cd, ok := pd.(checkpointDirectory)
+		if !ok {
+			continue
+		}
+
+		de := cd.DirEntry()
+		if de == nil || de.DirSummary == nil {
+			continue
+		}
+
+		if s := de.DirSummary; s.IncompleteReason != "" || s.FatalErrorCount > 0 {
+			continue
+		}
+
+		// modification time of directories read from the repository is the latest modification time
+		// in the subtree, so compare against the start of the snapshot instead.
+		if !dir.ModTime().Before(cd.startTime) || dir.Mode() != cd.Mode() || dir.Owner() != cd.Owner() {
+			continue
+		}
+
+		return de
+	}
+
+	return nil
+}
+
+// maybeResumeCompletedDirectory returns the entry for a directory that can be reused from the incomplete
+// snapshot being resumed, or nil if the directory must be uploaded. Only the top-level directory is checked
+// for modifications, so the resulting snapshot is tagged with ResumedDirectoriesTag.
+func (u *Uploader) maybeResumeCompletedDirectory(dir fs.Directory, entryRelativePath string, prevDirs []fs.Directory) *snapshot.DirEntry {
+	if !u.ResumeCompletedDirectories {
+		return nil
+	}
+
+	prev := completedCheckpointDirEntry(dir, prevDirs)
+	if prev == nil {
+		return nil
+	}
+
+	de, err := newDirEntryWithSummary(dir, prev.ObjectID, prev.DirSummary)
+	if err != nil {
+		return nil
+	}
+
+	s := de.DirSummary
+
+	atomic.AddInt32(&u.stats.TotalDirectoryCount, int32(s.TotalDirCount)) //nolint:gosec
+	atomic.AddInt32(&u.stats.CachedFiles, int32(s.TotalFileCount))        //nolint:gosec
+	atomic.AddInt64(&u.stats.TotalFileSize, s.TotalFileSize)
+
+	u.resumedDirectories.Add(1)
+	u.Progress.CachedFile(entryRelativePath, s.TotalFileSize)
+
+	return de
+}
+
+// directoryCompleted is invoked after a subdirectory has been added to its parent and requests
+// a checkpoint if none was made within DirectoryCheckpointInterval.
+func (u *Uploader) directoryCompleted() {
+	if u.DirectoryCheckpointInterval <= 0 {
+		return
+	}
+
+	if u.repo.Time().Sub(u.lastCheckpointTime()) < u.DirectoryCheckpointInterval {
+		return
+	}
+
+	select {
+	case u.checkpointRequested <- struct{}{}:
+	default:
+		// checkpoint already requested.
+	}
+}
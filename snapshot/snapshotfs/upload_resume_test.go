@@ -0,0 +1,123 @@
+package snapshotfs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestUploadResumeFromDirectoryCheckpoint(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	th.sourceDir.AddDir("d2/d9", defaultPermissions)
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	si := snapshot.SourceInfo{UserName: "user", Host: "host", Path: "path"}
+
+	// directories must not be modified after the snapshot starts.
+	th.ft.Advance(mockfs.DefaultModTime.Sub(th.repo.Time()) + time.Hour)
+
+	u := NewUploader(th.repo)
+	u.ParallelUploads = 1
+	u.DirectoryCheckpointInterval = time.Minute
+	u.checkpointFinished = make(chan struct{}, 10)
+	u.disableEstimation = true
+
+	// completion of the first subdirectory of d2 triggers a checkpoint which includes completed d1
+	// and incomplete d2.
+	th.sourceDir.Subdir("d2").OnReaddir(func() {
+		th.ft.Advance(2 * time.Minute)
+	})
+
+	th.sourceDir.Subdir("d2").Subdir("d9").OnReaddir(func() {
+		<-u.checkpointFinished
+	})
+
+	man1, err := u.Upload(ctx, th.sourceDir, policyTree, si)
+	require.NoError(t, err)
+
+	snapshots, err := snapshot.ListSnapshots(ctx, th.repo, si)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	checkpoint := snapshots[0]
+	require.Equal(t, IncompleteReasonCheckpoint, checkpoint.IncompleteReason)
+
+	checkpointD1 := childDirEntry(ctx, t, th.repo, checkpoint, "d1")
+	require.Empty(t, checkpointD1.DirSummary.IncompleteReason)
+	require.Equal(t, childDirEntry(ctx, t, th.repo, man1, "d1").ObjectID, checkpointD1.ObjectID)
+	require.Equal(t, IncompleteReasonCheckpoint, childDirEntry(ctx, t, th.repo, checkpoint, "d2").DirSummary.IncompleteReason)
+
+	// resume from the checkpoint, completed d1 is not walked again.
+	th.sourceDir.Subdir("d2").Subdir("d9").OnReaddir(nil)
+
+	readDirs := map[string]int{}
+
+	for _, name := range []string{"d1", "d2"} {
+		th.sourceDir.Subdir(name).OnReaddir(func() {
+			readDirs[name]++
+		})
+	}
+
+	u2 := NewUploader(th.repo)
+	u2.ParallelUploads = 1
+	u2.ResumeCompletedDirectories = true
+	u2.disableEstimation = true
+
+	man2, err := u2.Upload(ctx, th.sourceDir, policyTree, si, checkpoint)
+	require.NoError(t, err)
+	require.Empty(t, man2.IncompleteReason)
+
+	require.Equal(t, map[string]int{"d2": 1}, readDirs)
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+	require.Equal(t, man1.Stats.TotalFileSize, man2.Stats.TotalFileSize)
+	require.Equal(t, map[string]string{ResumedDirectoriesTag: "2"}, man2.Tags)
+
+	// without resume, all directories are walked.
+	readDirs = map[string]int{}
+
+	u3 := NewUploader(th.repo)
+	u3.disableEstimation = true
+
+	man3, err := u3.Upload(ctx, th.sourceDir, policyTree, si, checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"d1": 1, "d2": 1}, readDirs)
+	require.Empty(t, man3.Tags)
+
+	// directories modified after the incomplete snapshot started are walked again.
+	readDirs = map[string]int{}
+
+	checkpoint.StartTime = fs.UTCTimestampFromTime(mockfs.DefaultModTime)
+
+	man4, err := u2.Upload(ctx, th.sourceDir, policyTree, si, checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"d1": 1, "d2": 1}, readDirs)
+	require.Empty(t, man4.Tags)
+}
+
+func childDirEntry(ctx context.Context, t *testing.T, rep repo.Repository, man *snapshot.Manifest, name string) *snapshot.DirEntry {
+	t.Helper()
+
+	root, ok := EntryFromDirEntry(rep, man.RootEntry).(fs.Directory)
+	require.True(t, ok)
+
+	child, err := root.Child(ctx, name)
+	require.NoError(t, err)
+
+	hde, ok := child.(snapshot.HasDirEntry)
+	require.True(t, ok)
+
+	return hde.DirEntry()
+}
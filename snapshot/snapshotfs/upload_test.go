@@ -10,6 +10,7 @@
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"runtime/debug"
 	"sort"
 	"strings"
@@ -40,6 +41,7 @@
 	bloblogging "github.com/kopia/kopia/repo/blob/logging"
 	"github.com/kopia/kopia/repo/compression"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/format"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
@@ -1271,6 +1273,152 @@ func TestParallelUploadOfLargeFiles(t *testing.T) {
 	require.Positive(t, successCount)
 }
 
+func TestUploadPackedFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	want := map[string][]byte{
+		"a":       []byte("aaaa"),
+		"b":       bytes.Repeat([]byte{2}, 999),
+		"c":       []byte("aaaa"),
+		"big":     bytes.Repeat([]byte{3}, 1001),
+		"sub/a":   []byte("sub-aaaa"),
+		"sub/b":   []byte("sub-bbbb"),
+		"sub/s/a": []byte("sub-s-aaaa"),
+	}
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddDir("sub", defaultPermissions)
+	sourceDir.AddDir("sub/s", defaultPermissions)
+
+	for name, data := range want {
+		sourceDir.AddFile(name, data, defaultPermissions)
+	}
+
+	pol := *policy.DefaultPolicy
+	threshold := policy.OptionalInt64(1000)
+	pol.UploadPolicy.PackFilesBelowSize = &threshold
+
+	policyTree := policy.BuildTree(nil, &pol)
+
+	fm := th.repo.(repo.DirectRepository).FormatManager()
+
+	required, err := fm.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.NotContains(t, required, format.PackedObjectIDsRequirement)
+
+	man, err := NewUploader(th.repo).Upload(ctx, sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.EqualValues(t, len(want), man.Stats.TotalFileCount)
+
+	// packed object IDs can't be parsed by older clients.
+	required, err = fm.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Contains(t, required, format.PackedObjectIDsRequirement)
+
+	verifyPackedFiles := func(man *snapshot.Manifest) {
+		t.Helper()
+
+		for name, data := range want {
+			e, err := GetNestedEntry(ctx, EntryFromDirEntry(th.repo, man.RootEntry), strings.Split(name, "/"))
+			require.NoError(t, err)
+
+			oid := e.(object.HasObjectID).ObjectID()
+
+			_, _, _, packed := oid.PackedObject()
+			require.Equal(t, len(data) <= 1000, packed, name)
+
+			r, err := e.(fs.File).Open(ctx)
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, data, got, name)
+
+			_, err = th.repo.VerifyObject(ctx, oid)
+			require.NoError(t, err)
+		}
+	}
+
+	verifyPackedFiles(man)
+
+	// second snapshot reuses packed objects of cached files.
+	man2, err := NewUploader(th.repo).Upload(ctx, sourceDir, policyTree, snapshot.SourceInfo{}, man)
+	require.NoError(t, err)
+	require.Equal(t, man.RootObjectID(), man2.RootObjectID())
+
+	verifyPackedFiles(man2)
+}
+
+func TestUploadInlineFiles(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	want := map[string][]byte{
+		"a":     []byte("aaaa"),
+		"b":     bytes.Repeat([]byte{2}, 99),
+		"limit": bytes.Repeat([]byte{3}, 100),
+		"big":   bytes.Repeat([]byte{4}, 101),
+		"empty": {},
+		"sub/a": []byte("sub-aaaa"),
+	}
+
+	sourceDir := mockfs.NewDirectory()
+	sourceDir.AddDir("sub", defaultPermissions)
+
+	for name, data := range want {
+		sourceDir.AddFile(name, data, defaultPermissions)
+	}
+
+	pol := *policy.DefaultPolicy
+	threshold := policy.OptionalInt64(100)
+	pol.UploadPolicy.InlineFilesBelowSize = &threshold
+
+	policyTree := policy.BuildTree(nil, &pol)
+
+	fm := th.repo.(repo.DirectRepository).FormatManager()
+
+	required, err := fm.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.NotContains(t, required, format.InlineObjectIDsRequirement)
+
+	man, err := NewUploader(th.repo).Upload(ctx, sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.EqualValues(t, len(want), man.Stats.TotalFileCount)
+
+	// inline object IDs can't be parsed by older clients.
+	required, err = fm.RequiredFeatures(ctx)
+	require.NoError(t, err)
+	require.Contains(t, required, format.InlineObjectIDsRequirement)
+
+	for name, data := range want {
+		e, err := GetNestedEntry(ctx, EntryFromDirEntry(th.repo, man.RootEntry), strings.Split(name, "/"))
+		require.NoError(t, err)
+
+		oid := e.(object.HasObjectID).ObjectID()
+
+		_, inline := oid.InlineData()
+		require.Equal(t, len(data) > 0 && len(data) < 100, inline, name)
+
+		r, err := e.(fs.File).Open(ctx)
+		require.NoError(t, err)
+
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, data, got, name)
+		require.EqualValues(t, len(data), e.Size(), name)
+
+		_, err = th.repo.VerifyObject(ctx, oid)
+		require.NoError(t, err)
+	}
+}
+
 func verifyFileContent(t *testing.T, f1Entry fs.File, f2Name string) {
 	t.Helper()
 
@@ -1685,3 +1833,61 @@ func verifyLogDetails(t *testing.T, desc string, wantDetailKeys []string, keysAn
 	sort.Strings(wantDetailKeys)
 	require.Equal(t, wantDetailKeys, gotDetailKeys, "invalid details for "+desc)
 }
+
+func TestUploadWithHints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("inode numbers are not available on Windows")
+	}
+
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+	cacheDir := testutil.TempDirectory(t)
+	td := testutil.TempDirectory(t)
+
+	src1 := filepath.Join(td, "src1")
+	require.NoError(t, os.MkdirAll(filepath.Join(src1, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src1, "a"), randomBytes(3000), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(src1, "sub", "b"), randomBytes(5000), 0o600))
+
+	upload := func(dir string) *snapshot.Manifest {
+		t.Helper()
+
+		srcdir, err := localfs.Directory(dir)
+		require.NoError(t, err)
+
+		hints := OpenUploadHintCache(ctx, cacheDir, 0)
+
+		u := NewUploader(th.repo)
+		u.HintCache = hints
+
+		man, err := u.Upload(ctx, srcdir, policyTree, snapshot.SourceInfo{Host: "host", UserName: "user", Path: dir})
+		require.NoError(t, err)
+		require.NoError(t, hints.Save(ctx))
+
+		return man
+	}
+
+	man1 := upload(src1)
+	require.EqualValues(t, 0, man1.Stats.CachedFiles)
+	require.EqualValues(t, 2, man1.Stats.NonCachedFiles)
+
+	// moved directory is a different source without previous snapshots, but files are not re-hashed.
+	src2 := filepath.Join(td, "src2")
+	require.NoError(t, os.Rename(src1, src2))
+
+	man2 := upload(src2)
+	require.EqualValues(t, 2, man2.Stats.CachedFiles)
+	require.EqualValues(t, 0, man2.Stats.NonCachedFiles)
+	require.Equal(t, man1.RootObjectID(), man2.RootObjectID())
+
+	// modified files are hashed again.
+	require.NoError(t, os.WriteFile(filepath.Join(src2, "a"), randomBytes(3001), 0o600))
+
+	man3 := upload(src2)
+	require.EqualValues(t, 1, man3.Stats.CachedFiles)
+	require.EqualValues(t, 1, man3.Stats.NonCachedFiles)
+}
@@ -0,0 +1,152 @@
+package snapshotfs
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kopia/kopia/internal/timetrack"
+)
+
+// DirectoryTiming is a breakdown of time spent processing the direct children of a single directory.
+//
+// Durations are summed across parallel workers, so they can exceed the wall-clock time.
+type DirectoryTiming struct {
+	Path string `json:"path"`
+
+	// Total wall-clock time spent uploading the directory, including subdirectories.
+	Total time.Duration `json:"total"`
+
+	// List is the time spent enumerating directory entries and their metadata (stat).
+	List time.Duration `json:"list"`
+
+	// Read is the time spent reading file contents from the source.
+	Read time.Duration `json:"read"`
+
+	// Write is the time spent splitting, hashing, compressing and encrypting file contents.
+	Write time.Duration `json:"write"`
+
+	// Flush is the time spent waiting for pending uploads and writing the directory manifest.
+	Flush time.Duration `json:"flush"`
+
+	Files int64 `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Exclusive returns the time spent on the directory itself, excluding subdirectories.
+func (t DirectoryTiming) Exclusive() time.Duration {
+	return t.List + t.Read + t.Write + t.Flush
+}
+
+// directoryTimer accumulates timings of a single directory, safe for concurrent use.
+type directoryTimer struct {
+	list  atomic.Int64
+	read  atomic.Int64
+	write atomic.Int64
+	flush atomic.Int64
+	files atomic.Int64
+	bytes atomic.Int64
+}
+
+type directoryTimerKey struct{}
+
+func withDirectoryTimer(ctx context.Context, dt *directoryTimer) context.Context {
+	return context.WithValue(ctx, directoryTimerKey{}, dt)
+}
+
+// directoryTimerFromContext returns the timer of the directory being uploaded or nil if timings are not collected.
+func directoryTimerFromContext(ctx context.Context) *directoryTimer {
+	dt, _ := ctx.Value(directoryTimerKey{}).(*directoryTimer)
+	return dt
+}
+
+func addDuration(v *atomic.Int64, timer timetrack.Timer) {
+	v.Add(int64(timer.Elapsed()))
+}
+
+func (dt *directoryTimer) addList(timer timetrack.Timer) {
+	if dt != nil {
+		addDuration(&dt.list, timer)
+	}
+}
+
+func (dt *directoryTimer) addRead(timer timetrack.Timer) {
+	if dt != nil {
+		addDuration(&dt.read, timer)
+	}
+}
+
+func (dt *directoryTimer) addWrite(timer timetrack.Timer) {
+	if dt != nil {
+		addDuration(&dt.write, timer)
+	}
+}
+
+func (dt *directoryTimer) addFlush(timer timetrack.Timer) {
+	if dt != nil {
+		addDuration(&dt.flush, timer)
+	}
+}
+
+func (dt *directoryTimer) addFile(size int64) {
+	if dt != nil {
+		dt.files.Add(1)
+		dt.bytes.Add(size)
+	}
+}
+
+func (dt *directoryTimer) timing(relativePath string, total time.Duration) DirectoryTiming {
+	return DirectoryTiming{
+		Path:  relativePath,
+		Total: total,
+		List:  time.Duration(dt.list.Load()),
+		Read:  time.Duration(dt.read.Load()),
+		Write: time.Duration(dt.write.Load()),
+		Flush: time.Duration(dt.flush.Load()),
+		Files: dt.files.Load(),
+		Bytes: dt.bytes.Load(),
+	}
+}
+
+func (t DirectoryTiming) spanAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("list_ms", t.List.Milliseconds()),
+		attribute.Int64("read_ms", t.Read.Milliseconds()),
+		attribute.Int64("write_ms", t.Write.Milliseconds()),
+		attribute.Int64("flush_ms", t.Flush.Milliseconds()),
+		attribute.Int64("files", t.Files),
+		attribute.Int64("bytes", t.Bytes),
+	}
+}
+
+// recordDirectoryTiming retains the provided timing if it is among the slowest directories seen so far.
+func (u *Uploader) recordDirectoryTiming(t DirectoryTiming) {
+	if u.DirectoryTimingReportSize <= 0 {
+		return
+	}
+
+	u.timingMutex.Lock()
+	defer u.timingMutex.Unlock()
+
+	u.slowestDirs = append(u.slowestDirs, t)
+
+	sort.Slice(u.slowestDirs, func(i, j int) bool {
+		return u.slowestDirs[i].Exclusive() > u.slowestDirs[j].Exclusive()
+	})
+
+	if len(u.slowestDirs) > u.DirectoryTimingReportSize {
+		u.slowestDirs = u.slowestDirs[:u.DirectoryTimingReportSize]
+	}
+}
+
+// SlowestDirectories returns timing breakdowns of directories with the longest exclusive processing time
+// during the most recent upload, ordered from slowest. Requires DirectoryTimingReportSize to be set.
+func (u *Uploader) SlowestDirectories() []DirectoryTiming {
+	u.timingMutex.Lock()
+	defer u.timingMutex.Unlock()
+
+	return append([]DirectoryTiming(nil), u.slowestDirs...)
+}
@@ -0,0 +1,51 @@
+package snapshotfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestUploadDirectoryTimings(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	u := NewUploader(th.repo)
+	u.DirectoryTimingReportSize = 3
+
+	policyTree := policy.BuildTree(nil, policy.DefaultPolicy)
+
+	_, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	timings := u.SlowestDirectories()
+	require.Len(t, timings, 3)
+
+	var files int64
+
+	for i, tm := range timings {
+		require.NotEmpty(t, tm.Path)
+		require.GreaterOrEqual(t, tm.Total, tm.List)
+
+		if i > 0 {
+			require.GreaterOrEqual(t, timings[i-1].Exclusive(), tm.Exclusive())
+		}
+
+		files += tm.Files
+	}
+
+	require.Positive(t, files)
+
+	// timings are not collected by default.
+	u2 := NewUploader(th.repo)
+
+	_, err = u2.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+	require.NoError(t, err)
+	require.Empty(t, u2.SlowestDirectories())
+}
@@ -3,6 +3,8 @@
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -23,20 +25,44 @@
 
 var log = logging.Module("snapshotgc")
 
-func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *bigmap.Set) error {
+// findInUseContentIDs adds IDs of all contents referenced by snapshots to the provided set and returns
+// statistics of contents attributed to each snapshot source. Snapshots are processed from the oldest,
+// so each content is attributed to the source that first referenced it.
+//
+// Objects and directories whose backing contents can't be determined because they are in encryption domains
+// which are not unlocked are skipped, the caller must keep all contents of such domains.
+func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *bigmap.Set) ([]*maintenance.SourceStats, error) {
 	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
 	if err != nil {
-		return errors.Wrap(err, "unable to list snapshot manifest IDs")
+		return nil, errors.Wrap(err, "unable to list snapshot manifest IDs")
 	}
 
 	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
 	if err != nil {
-		return errors.Wrap(err, "unable to load manifest IDs")
+		return nil, errors.Wrap(err, "unable to load manifest IDs")
 	}
 
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].StartTime < manifests[j].StartTime
+	})
+
+	var (
+		mu            sync.Mutex
+		sources       = map[snapshot.SourceInfo]*maintenance.SourceStats{}
+		currentSource *maintenance.SourceStats
+	)
+
 	w, twerr := snapshotfs.NewTreeWalker(ctx, snapshotfs.TreeWalkerOptions{
+		LockedDirectoryCallback: func(ctx context.Context, entryPath string) {
+			log(ctx).Debugf("skipping directory %v in locked encryption domain", entryPath)
+		},
 		EntryCallback: func(ctx context.Context, _ fs.Entry, oid object.ID, _ string) error {
 			contentIDs, verr := rep.VerifyObject(ctx, oid)
+			if errors.Is(verr, content.ErrEncryptionDomainLocked) {
+				log(ctx).Debugf("skipping %v in locked encryption domain", oid)
+				return nil
+			}
+
 			if verr != nil {
 				return errors.Wrapf(verr, "error verifying %v", oid)
 			}
@@ -44,14 +70,25 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *bigmap.
 			var cidbuf [128]byte
 
 			for _, cid := range contentIDs {
-				used.Put(ctx, cid.Append(cidbuf[:0]))
+				if !used.Put(ctx, cid.Append(cidbuf[:0])) {
+					continue
+				}
+
+				ci, err := rep.ContentInfo(ctx, cid)
+				if err != nil {
+					return errors.Wrapf(err, "error getting content info for %v", cid)
+				}
+
+				mu.Lock()
+				currentSource.Add(ci)
+				mu.Unlock()
 			}
 
 			return nil
 		},
 	})
 	if twerr != nil {
-		return errors.Wrap(err, "unable to create tree walker")
+		return nil, errors.Wrap(twerr, "unable to create tree walker")
 	}
 
 	defer w.Close(ctx)
@@ -61,15 +98,34 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *bigmap.
 	for _, m := range manifests {
 		root, err := snapshotfs.SnapshotRoot(rep, m)
 		if err != nil {
-			return errors.Wrap(err, "unable to get snapshot root")
+			return nil, errors.Wrap(err, "unable to get snapshot root")
+		}
+
+		ss := sources[m.Source]
+		if ss == nil {
+			ss = &maintenance.SourceStats{Source: m.Source.String()}
+			sources[m.Source] = ss
 		}
 
+		ss.SnapshotCount++
+		ss.LatestSnapshotTime = m.StartTime.ToTime()
+
+		mu.Lock()
+		currentSource = ss
+		mu.Unlock()
+
 		if err := w.Process(ctx, root, ""); err != nil {
-			return errors.Wrap(err, "error processing snapshot root")
+			return nil, errors.Wrap(err, "error processing snapshot root")
 		}
 	}
 
-	return nil
+	var result []*maintenance.SourceStats
+
+	for _, ss := range sources {
+		result = append(result, ss)
+	}
+
+	return result, nil
 }
 
 // Run performs garbage collection on all the snapshots in the repository.
@@ -88,6 +144,10 @@ func Run(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, sa
 		l.Infof("GC found %v in-use contents (%v)", st.InUseCount, units.BytesString(st.InUseBytes))
 		l.Infof("GC found %v in-use system-contents (%v)", st.SystemCount, units.BytesString(st.SystemBytes))
 
+		if st.LockedCount > 0 {
+			l.Infof("GC kept %v contents in locked encryption domains (%v)", st.LockedCount, units.BytesString(st.LockedBytes))
+		}
+
 		if st.UnusedCount > 0 && !gcDelete {
 			return errors.New("Not deleting because 'gcDelete' was not set")
 		}
@@ -99,7 +159,7 @@ func Run(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, sa
 }
 
 func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters, maintenanceStartTime time.Time, st *Stats) error {
-	var unused, inUse, system, tooRecent, undeleted stats.CountSum
+	var unused, inUse, system, tooRecent, undeleted, locked stats.CountSum
 
 	used, serr := bigmap.NewSet(ctx)
 	if serr != nil {
@@ -107,15 +167,18 @@ func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete
 	}
 	defer used.Close(ctx)
 
-	if err := findInUseContentIDs(ctx, rep, used); err != nil {
+	sources, err := findInUseContentIDs(ctx, rep, used)
+	if err != nil {
 		return errors.Wrap(err, "unable to find in-use content ID")
 	}
 
+	st.Sources = sources
+
 	log(ctx).Info("Looking for unreferenced contents...")
 
 	// Ensure that the iteration includes deleted contents, so those can be
 	// undeleted (recovered).
-	err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+	err = rep.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
 		if manifest.ContentPrefix == ci.ContentID.Prefix() {
 			system.Add(int64(ci.PackedLength))
 			return nil
@@ -137,6 +200,12 @@ func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete
 			return nil
 		}
 
+		if rep.ContentManager().IsEncryptionKeyLocked(ci.EncryptionKeyID) {
+			// references from objects in locked encryption domains are unknown, keep all their contents.
+			locked.Add(int64(ci.PackedLength))
+			return nil
+		}
+
 		if maintenanceStartTime.Sub(ci.Timestamp()) < safety.MinContentAgeSubjectToGC {
 			log(ctx).Debugf("recent unreferenced content %v (%v bytes, modified %v)", ci.ContentID, ci.PackedLength, ci.Timestamp())
 			tooRecent.Add(int64(ci.PackedLength))
@@ -171,6 +240,7 @@ func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete
 	st.SystemCount, st.SystemBytes = system.Approximate()
 	st.TooRecentCount, st.TooRecentBytes = tooRecent.Approximate()
 	st.UndeletedCount, st.UndeletedBytes = undeleted.Approximate()
+	st.LockedCount, st.LockedBytes = locked.Approximate()
 
 	if err != nil {
 		return errors.Wrap(err, "error iterating contents")
@@ -7,10 +7,15 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/eventlog"
+	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 	"github.com/kopia/kopia/snapshot/snapshotgc"
 )
 
+var log = logging.Module("snapshotmaintenance")
+
 // Run runs the complete snapshot and repository maintenance.
 func Run(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.Mode, force bool, safety maintenance.SafetyParameters) error {
 	//nolint:wrapcheck
@@ -18,12 +23,64 @@ func Run(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.M
 		func(ctx context.Context, runParams maintenance.RunParameters) error {
 			// run snapshot GC before full maintenance
 			if runParams.Mode == maintenance.ModeFull {
-				if _, err := snapshotgc.Run(ctx, dr, true, safety, runParams.MaintenanceStartTime); err != nil {
+				st, err := snapshotgc.Run(ctx, dr, true, safety, runParams.MaintenanceStartTime)
+				if err != nil {
 					return errors.Wrap(err, "snapshot GC failure")
 				}
+
+				// per-source statistics are persisted by full maintenance.
+				runParams.SourceStats = st.Sources
+				runParams.UsageStats = computeUsage(ctx, dr)
+
+				pruneEventLog(ctx, dr)
 			}
 
 			//nolint:wrapcheck
 			return maintenance.Run(ctx, runParams, safety)
 		})
 }
+
+// computeUsage attributes storage used by snapshots to user@host pairs. Failures are not fatal,
+// in which case usage statistics from the previous maintenance are preserved.
+func computeUsage(ctx context.Context, rep repo.Repository) *maintenance.UsageStats {
+	r, err := snapshotfs.CalculateDedupReport(ctx, rep)
+	if err != nil {
+		log(ctx).Warnf("unable to compute usage statistics: %v", err)
+		return nil
+	}
+
+	u := &maintenance.UsageStats{
+		ContentCount:       r.ContentCount,
+		Bytes:              r.Bytes,
+		SharedContentCount: r.SharedContentCount,
+		SharedBytes:        r.SharedBytes,
+		Users:              []*maintenance.UserUsageStats{},
+	}
+
+	for _, o := range r.Owners {
+		u.Users = append(u.Users, &maintenance.UserUsageStats{
+			UserName:           o.UserName,
+			Host:               o.Host,
+			SnapshotCount:      o.SnapshotCount,
+			UniqueContentCount: o.UniqueContentCount,
+			UniqueBytes:        o.UniqueBytes,
+			SharedContentCount: o.SharedContentCount,
+			SharedBytes:        o.SharedBytes,
+		})
+	}
+
+	return u
+}
+
+// pruneEventLog removes events older than the default retention period from the repository event log.
+func pruneEventLog(ctx context.Context, rep repo.RepositoryWriter) {
+	n, err := eventlog.Prune(ctx, rep, rep.Time().Add(-eventlog.DefaultRetention))
+	if err != nil {
+		log(ctx).Warnf("unable to prune repository event log: %v", err)
+		return
+	}
+
+	if n > 0 {
+		log(ctx).Infof("Removed %v old events from the repository event log.", n)
+	}
+}
@@ -6,8 +6,12 @@
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/acl"
 	"github.com/kopia/kopia/internal/auth"
+	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/tests/clitestutil"
 	"github.com/kopia/kopia/tests/testenv"
 )
@@ -192,3 +196,108 @@ func TestACL(t *testing.T) {
 		"--password", "new-password",
 	)
 }
+
+func TestACLGrants(t *testing.T) {
+	t.Parallel()
+
+	serverRunner := testenv.NewInProcRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "acl", "enable")
+
+	// alice@wonderland can only snapshot paths under sharedTestDataDir1, foo@bar can read her snapshots.
+	serverEnvironment.RunAndExpectSuccess(t, "server", "acl", "grant-path", "--user", "alice@wonderland", "--path", sharedTestDataDir1)
+	serverEnvironment.RunAndExpectSuccess(t, "server", "acl", "grant-read", "--user", "foo@bar", "--source", "alice@wonderland")
+	serverEnvironment.RunAndExpectFailure(t, "server", "acl", "grant-read", "--user", "foo@bar", "--source", "alice")
+
+	require.Len(t, serverEnvironment.RunAndExpectSuccess(t, "server", "acl", "list"), len(auth.DefaultACLs)+4)
+
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "foo@bar", "--user-password", "baz")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "alice@wonderland", "--user-password", "baz")
+
+	var sp testutil.ServerParameters
+
+	wait, kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+	)
+
+	defer wait()
+	defer kill()
+
+	ctx := testlogging.Context(t)
+
+	controlClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.BaseURL,
+		Username:                            "admin-user",
+		Password:                            "admin-pwd",
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+	})
+	require.NoError(t, err)
+
+	// remove default rules granting all users full access to all their snapshots and policies.
+	entries, err := serverapi.ListACLEntries(ctx, controlClient)
+	require.NoError(t, err)
+	require.Len(t, entries.Entries, len(auth.DefaultACLs)+4)
+
+	for _, e := range entries.Entries {
+		if e.User == "*@*" && (e.Target["type"] == "snapshot" || e.Target["type"] == "policy") && len(e.Target) == 3 && e.Target["username"] == acl.OwnUser && e.Access == acl.AccessLevelFull {
+			require.NoError(t, serverapi.DeleteACLEntry(ctx, controlClient, e.ID))
+		}
+	}
+
+	require.Error(t, serverapi.DeleteACLEntry(ctx, controlClient, "no-such-entry"))
+
+	_, err = serverapi.AddACLEntry(ctx, controlClient, &serverapi.AddACLEntryRequest{
+		Entry: &acl.Entry{User: "alice", Target: acl.TargetRule{"type": "snapshot"}, Access: acl.AccessLevelRead},
+	})
+	require.Error(t, err)
+
+	added, err := serverapi.AddACLEntry(ctx, controlClient, &serverapi.AddACLEntryRequest{
+		Entry: &acl.Entry{User: "alice@wonderland", Target: acl.TargetRule{"type": "policy", "policyType": "user", "username": acl.OwnUser, "hostname": acl.OwnHost}, Access: acl.AccessLevelRead},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, added.ID)
+
+	entries, err = serverapi.ListACLEntries(ctx, controlClient)
+	require.NoError(t, err)
+	require.Len(t, entries.Entries, len(auth.DefaultACLs)+3)
+
+	connect := func(username, hostname string) *testenv.CLITest {
+		e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+		delete(e.Environment, "KOPIA_PASSWORD")
+
+		e.RunAndExpectSuccess(t, "repo", "connect", "server",
+			"--url", sp.BaseURL+"/",
+			"--server-cert-fingerprint", sp.SHA256Fingerprint,
+			"--override-username", username,
+			"--override-hostname", hostname,
+			"--password", "baz",
+		)
+
+		t.Cleanup(func() { e.RunAndExpectSuccess(t, "repo", "disconnect") })
+
+		return e
+	}
+
+	alice := connect("alice", "wonderland")
+	foobar := connect("foo", "bar")
+
+	alice.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	alice.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir2)
+
+	// foo@bar can see alice's snapshots but not delete them.
+	snaps := clitestutil.ListSnapshotsAndExpectSuccess(t, foobar, "-a")
+	require.Len(t, snaps, 1)
+	require.Len(t, snaps[0].Snapshots, 1)
+
+	foobar.RunAndExpectFailure(t, "snapshot", "delete", snaps[0].Snapshots[0].SnapshotID, "--delete")
+}
@@ -12,8 +12,7 @@
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
-	"github.com/kopia/kopia/internal/apiclient"
-	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/apiclient"
 	"github.com/kopia/kopia/internal/servertesting"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
@@ -21,6 +20,7 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/serverapi"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/tests/clitestutil"
 	"github.com/kopia/kopia/tests/testdirtree"
@@ -379,3 +379,128 @@ func TestFindManifestsPaginationOverGRPC(t *testing.T) {
 	// make sure we got them all
 	require.Empty(t, uniqueIDs)
 }
+
+func TestAPIServerRepository_HostedRepositories(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--override-username", "foo", "--override-hostname", "bar")
+	e.RunAndExpectSuccess(t, "server", "users", "add", "foo@bar", "--user-password", "baz")
+
+	// second repository with its own storage, configuration and users.
+	e2 := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	defer e2.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e2.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e2.RepoDir, "--override-username", "foo", "--override-hostname", "bar")
+	e2.RunAndExpectSuccess(t, "server", "users", "add", "foo@bar", "--user-password", "qux")
+
+	hostedConfigFile := filepath.Join(e2.ConfigDir, ".kopia.config")
+
+	tlsCert := filepath.Join(e.ConfigDir, "tls.cert")
+	tlsKey := filepath.Join(e.ConfigDir, "tls.key")
+
+	var sp testutil.ServerParameters
+
+	wait, _ := e.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--grpc",
+		"--tls-key-file", tlsKey,
+		"--tls-cert-file", tlsCert,
+		"--tls-generate-cert",
+		"--server-username", uiUsername,
+		"--server-password", uiPassword,
+		"--server-control-username", controlUsername,
+		"--server-control-password", controlPassword,
+		"--hosted-repository", "hosted="+hostedConfigFile)
+
+	defer wait()
+
+	controlClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.BaseURL,
+		Username:                            controlUsername,
+		Password:                            controlPassword,
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+		LogRequests:                         true,
+	})
+	require.NoError(t, err)
+
+	waitUntilServerStarted(ctx, t, controlClient)
+
+	defer serverapi.Shutdown(ctx, controlClient)
+
+	// hosted repository status is available under /repos/<name>/.
+	hostedControlClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.BaseURL + "/repos/hosted",
+		Username:                            controlUsername,
+		Password:                            controlPassword,
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+		LogRequests:                         true,
+	})
+	require.NoError(t, err)
+
+	waitUntilServerStarted(ctx, t, hostedControlClient)
+
+	clientOpts := repo.ClientOptions{
+		Username: "foo",
+		Hostname: "bar",
+	}
+
+	hostedRep, err := servertesting.ConnectAndOpenAPIServer(t, ctx, &repo.APIServerInfo{
+		BaseURL:                             sp.BaseURL,
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+		RepositoryName:                      "hosted",
+	}, clientOpts, content.CachingOptions{}, "qux", &repo.Options{})
+	require.NoError(t, err)
+
+	defer hostedRep.Close(ctx)
+
+	labels := map[string]string{
+		"type":     "snapshot",
+		"username": "foo",
+		"hostname": "bar",
+	}
+
+	require.NoError(t, repo.WriteSession(ctx, hostedRep, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		_, err := w.PutManifest(ctx, labels, &snapshot.Manifest{})
+		return err
+	}))
+
+	defaultRep, err := servertesting.ConnectAndOpenAPIServer(t, ctx, &repo.APIServerInfo{
+		BaseURL:                             sp.BaseURL,
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+	}, clientOpts, content.CachingOptions{}, "baz", &repo.Options{})
+	require.NoError(t, err)
+
+	defer defaultRep.Close(ctx)
+
+	// manifest written to the hosted repository is not visible in the default one.
+	manifests, err := defaultRep.FindManifests(ctx, labels)
+	require.NoError(t, err)
+	require.Empty(t, manifests)
+
+	manifests, err = hostedRep.FindManifests(ctx, labels)
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+
+	// users of one repository can't access the other one.
+	_, err = servertesting.ConnectAndOpenAPIServer(t, ctx, &repo.APIServerInfo{
+		BaseURL:                             sp.BaseURL,
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+		RepositoryName:                      "hosted",
+	}, clientOpts, content.CachingOptions{}, "baz", &repo.Options{})
+	require.Error(t, err)
+
+	// unknown repository.
+	_, err = servertesting.ConnectAndOpenAPIServer(t, ctx, &repo.APIServerInfo{
+		BaseURL:                             sp.BaseURL,
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+		RepositoryName:                      "no-such-repo",
+	}, clientOpts, content.CachingOptions{}, "baz", &repo.Options{})
+	require.Error(t, err)
+}
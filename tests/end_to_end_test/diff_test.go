@@ -72,3 +72,54 @@ func TestDiff(t *testing.T) {
 		}
 	}
 }
+
+func TestDiffAgainstLocal(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	dataDir := testutil.TempDirectory(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "sub"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "unchanged"), []byte("unchanged"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "removed"), []byte("removed"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "sub", "modified"), []byte("original"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "ignored"), []byte("ignored"), 0o600))
+
+	e.RunAndExpectSuccess(t, "policy", "set", dataDir, "--add-ignore", "ignored")
+	e.RunAndExpectSuccess(t, "snapshot", "create", dataDir)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, dataDir)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	oid := si[0].Snapshots[0].ObjectID
+
+	// no drift.
+	out := e.RunAndExpectSuccess(t, "diff", oid, "--against-local", dataDir, "--compare-contents")
+	require.Contains(t, out, "Summary: 0 added, 0 removed, 0 changed, 3 unchanged files; 0 added, 0 removed directories.")
+
+	// same size and modification time, only detected by comparing contents.
+	modifiedFile := filepath.Join(dataDir, "sub", "modified")
+	st, err := os.Stat(modifiedFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(modifiedFile, []byte("modified"), 0o600))
+	require.NoError(t, os.Chtimes(modifiedFile, st.ModTime(), st.ModTime()))
+
+	require.NoError(t, os.Remove(filepath.Join(dataDir, "removed")))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "added"), []byte("added"), 0o600))
+
+	out = e.RunAndExpectSuccess(t, "diff", oid, "--against-local", dataDir)
+	require.Contains(t, out, "Summary: 1 added, 1 removed, 0 changed, 2 unchanged files; 0 added, 0 removed directories.")
+
+	out = e.RunAndExpectSuccess(t, "diff", oid, "--against-local", dataDir, "--compare-contents")
+	require.Contains(t, out, "Summary: 1 added, 1 removed, 1 changed, 1 unchanged files; 0 added, 0 removed directories.")
+
+	e.RunAndExpectFailure(t, "diff", oid)
+	e.RunAndExpectFailure(t, "diff", oid, oid, "--against-local", dataDir)
+}
@@ -1,6 +1,8 @@
 package endtoend_test
 
 import (
+	"bytes"
+	"fmt"
 	"math"
 	"math/rand"
 	"os"
@@ -95,6 +97,49 @@ func (s *formatSpecificTestSuite) TestECC(t *testing.T) {
 	require.Equal(t, data[:], restoreData)
 }
 
+func (s *formatSpecificTestSuite) TestECCRepair(t *testing.T) {
+	t.Parallel()
+
+	// ECC is not supported in version 1
+	if s.formatVersion == 1 {
+		return
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, s.formatFlags, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--flat", "--path", e.RepoDir, "--ecc-data-shards=4", "--ecc-parity-shards=2")
+	require.Contains(t, e.RunAndExpectSuccess(t, "repo", "status"), "Error correction:    REED-SOLOMON-CRC32 (50% overhead)")
+
+	dataDir := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "some-file1"), bytes.Repeat([]byte{1, 2, 3, 4, 5}, 10000), 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", dataDir)
+	require.Contains(t, e.RunAndExpectSuccess(t, "repair")[0], "found 0 corrupted, repaired 0.")
+
+	// corrupt the first content of each pack blob right after the preamble.
+	packs, err := filepath.Glob(filepath.Join(e.RepoDir, "p*.f"))
+	require.NoError(t, err)
+	require.NotEmpty(t, packs)
+
+	for _, p := range packs {
+		b, err := os.ReadFile(p)
+		require.NoError(t, err)
+
+		b[100] ^= 0xff
+
+		require.NoError(t, os.WriteFile(p, b, 0o600))
+	}
+
+	require.Contains(t, e.RunAndExpectSuccess(t, "repair", "--dry-run")[0], fmt.Sprintf("found %v corrupted, repaired 0.", len(packs)))
+	require.Contains(t, e.RunAndExpectSuccess(t, "repair")[0], fmt.Sprintf("found %v corrupted, repaired %v.", len(packs), len(packs)))
+	require.Contains(t, e.RunAndExpectSuccess(t, "repair")[0], "found 0 corrupted, repaired 0.")
+
+	e.RunAndExpectSuccess(t, "snapshot", "verify", "--verify-files-percent=100")
+}
+
 func (s *formatSpecificTestSuite) flipOneByteFromEachFile(e *testenv.CLITest) error {
 	return filepath.Walk(e.RepoDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -0,0 +1,65 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/cli"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestMigrateFromRsync(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	backupRoot := testutil.TempDirectory(t)
+
+	b1 := filepath.Join(backupRoot, "2023-05-01_10-00-00")
+	b2 := filepath.Join(backupRoot, "2023-05-02_10-00-00")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(b1, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(b1, "docs", "a.txt"), []byte("hello"), 0o644))
+
+	// second backup hard-links unchanged file and adds a new one, like rsync --link-dest
+	require.NoError(t, os.MkdirAll(filepath.Join(b2, "docs"), 0o755))
+	require.NoError(t, os.Link(filepath.Join(b1, "docs", "a.txt"), filepath.Join(b2, "docs", "a.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(b2, "docs", "b.txt"), []byte("world"), 0o644))
+
+	// ignored entries
+	require.NoError(t, os.Symlink(b2, filepath.Join(backupRoot, "latest")))
+	require.NoError(t, os.MkdirAll(filepath.Join(backupRoot, "incomplete"), 0o755))
+
+	e.RunAndExpectSuccess(t, "migrate", "from-rsync", backupRoot, "--source=user@host:/home/user", "--backup-time=name", "--time-zone=UTC")
+
+	var manifests []cli.SnapshotManifest
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", "-a", "--json"), &manifests)
+	require.Len(t, manifests, 2)
+
+	for _, m := range manifests {
+		require.Equal(t, snapshot.SourceInfo{Host: "host", UserName: "user", Path: "/home/user"}, m.Source)
+	}
+
+	require.True(t, manifests[0].StartTime.ToTime().Equal(time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)))
+	require.True(t, manifests[1].StartTime.ToTime().Equal(time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC)))
+	require.Equal(t, int64(10), manifests[1].Stats.TotalFileSize)
+
+	lines := e.RunAndExpectSuccess(t, "show", manifests[1].RootObjectID().String()+"/docs/b.txt")
+	require.Equal(t, []string{"world"}, lines)
+
+	// importing again is a no-op
+	e.RunAndExpectSuccess(t, "migrate", "from-rsync", backupRoot, "--source=user@host:/home/user", "--backup-time=name", "--time-zone=UTC")
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "list", "-a", "--json"), &manifests)
+	require.Len(t, manifests, 2)
+}
@@ -26,7 +26,11 @@ func (s *formatSpecificTestSuite) TestRepositoryRepair(t *testing.T) {
 	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
 	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
 
-	// remove kopia.repository
+	// remove kopia.repository and its replicas
+	for i := range format.FormatBlobReplicaCount {
+		e.RunAndExpectSuccess(t, "blob", "rm", string(format.ReplicaBlobID(format.KopiaRepositoryBlobID, i)))
+	}
+
 	e.RunAndExpectSuccess(t, "blob", "rm", "kopia.repository")
 	e.RunAndExpectSuccess(t, "repo", "disconnect")
 
@@ -41,3 +45,24 @@ func (s *formatSpecificTestSuite) TestRepositoryRepair(t *testing.T) {
 		e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
 	}
 }
+
+func TestRepositoryFormatBlobSelfHealing(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	// remove kopia.repository and one of its replicas
+	e.RunAndExpectSuccess(t, "blob", "rm", string(format.ReplicaBlobID(format.KopiaRepositoryBlobID, 0)))
+	e.RunAndExpectSuccess(t, "blob", "rm", "kopia.repository")
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// connect restores the format blob and the missing replica from the remaining replica.
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "blob", "show", "kopia.repository")
+	e.RunAndExpectSuccess(t, "blob", "show", string(format.ReplicaBlobID(format.KopiaRepositoryBlobID, 0)))
+	e.RunAndExpectSuccess(t, "snapshot", "list", sharedTestDataDir1)
+}
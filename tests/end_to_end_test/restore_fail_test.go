@@ -2,11 +2,14 @@
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/tests/testdirtree"
@@ -73,6 +76,51 @@ func TestRestoreFail(t *testing.T) {
 	e.RunAndExpectSuccess(t, "snapshot", "restore", "--ignore-errors", parsed.manifestID, targetDir)
 }
 
+func TestRestorePreflight(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	scratchDir := testutil.TempDirectory(t)
+	sourceDir := filepath.Join(scratchDir, "source")
+	targetDir := filepath.Join(scratchDir, "target")
+
+	testdirtree.MustCreateDirectoryTree(t, sourceDir, testdirtree.MaybeSimplifyFilesystem(testdirtree.DirectoryTreeOptions{
+		Depth:                  2,
+		MaxSubdirsPerDirectory: 5,
+		MaxFilesPerDirectory:   5,
+	}))
+
+	beforeBlobList := e.RunAndExpectSuccess(t, "blob", "list")
+
+	out, errOut := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "create", sourceDir)
+	parsed := parseSnapshotResultFromLog(t, out, errOut)
+
+	afterBlobList := e.RunAndExpectSuccess(t, "blob", "list")
+
+	lines := e.RunAndExpectSuccess(t, "snapshot", "restore", "--preflight", parsed.manifestID, targetDir)
+	require.Contains(t, lines, "Preflight check passed.")
+	require.Contains(t, lines, "Target writable:   true")
+
+	// preflight must not create the target.
+	_, err := os.Stat(targetDir)
+	require.True(t, os.IsNotExist(err))
+
+	blobIDToDelete := findPackBlob(getNewBlobIDs(beforeBlobList, afterBlobList))
+	require.NotEmpty(t, blobIDToDelete)
+
+	e.RunAndExpectSuccess(t, "blob", "delete", blobIDToDelete)
+
+	lines, _ = e.RunAndExpectFailure(t, "snapshot", "restore", "--preflight", parsed.manifestID, targetDir)
+	require.NotContains(t, lines, "Preflight check passed.")
+	require.Contains(t, strings.Join(lines, "\n"), "missing blob "+blobIDToDelete)
+}
+
 func findPackBlob(blobIDs []string) string {
 	// Pattern to match "p" followed by hexadecimal digits
 	// Ex) "pd4c69d72b75a9d3d7d9da21096c6b60a"
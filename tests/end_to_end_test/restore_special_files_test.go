@@ -0,0 +1,60 @@
+//go:build linux
+
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotRestoreSpecialFiles(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, os.WriteFile(filepath.Join(source, "file"), []byte{1, 2, 3}, 0o600))
+	require.NoError(t, unix.Mkfifo(filepath.Join(source, "pipe"), 0o640))
+
+	// without the policy flag the named pipe is ignored.
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--snapshot-special-files=true")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 2)
+
+	require.NotContains(t, strings.Join(e.RunAndExpectSuccess(t, "ls", si[0].Snapshots[0].ObjectID), "\n"), "pipe")
+	require.Contains(t, strings.Join(e.RunAndExpectSuccess(t, "ls", si[0].Snapshots[1].ObjectID), "\n"), "pipe")
+
+	snapID := si[0].Snapshots[1].SnapshotID
+
+	// by default special files are skipped during restore.
+	restoredDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, restoredDir)
+	require.FileExists(t, filepath.Join(restoredDir, "file"))
+	require.NoFileExists(t, filepath.Join(restoredDir, "pipe"))
+
+	restoredDir = testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--special-files", snapID, restoredDir)
+
+	st, err := os.Lstat(filepath.Join(restoredDir, "pipe"))
+	require.NoError(t, err)
+	require.Equal(t, os.ModeNamedPipe, st.Mode().Type())
+	require.Equal(t, os.FileMode(0o640), st.Mode().Perm())
+}
@@ -765,6 +765,58 @@ type chunk struct {
 	}
 }
 
+func TestSnapshotAutoSparseRestore(t *testing.T) {
+	t.Parallel()
+
+	testutil.TestSkipUnlessLinux(t)
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	sourceDir := testutil.TempDirectory(t)
+	restoreDir := testutil.TempDirectory(t)
+
+	blkSize, err := stat.GetBlockSize(restoreDir)
+	require.NoError(t, err)
+
+	const longZeroRun = 4 << 20
+
+	// data, single zero block, data, long run of zeros, data
+	sourceFile := filepath.Join(sourceDir, "image")
+	size := 3*blkSize + blkSize + longZeroRun
+
+	fd, err := os.Create(sourceFile)
+	require.NoError(t, err)
+	require.NoError(t, fd.Truncate(int64(size)))
+
+	for _, off := range []uint64{0, 2 * blkSize, 3*blkSize + longZeroRun} {
+		_, err = fd.WriteAt(bytes.Repeat([]byte("1"), int(blkSize)), int64(off))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, fd.Close())
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sourceFile)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, sourceFile)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	snapID := si[0].Snapshots[0].SnapshotID
+
+	// by default only the long run of zeros becomes a hole.
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, filepath.Join(restoreDir, "auto"))
+	verifyFileSize(t, filepath.Join(restoreDir, "auto"), size, 4*blkSize)
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, "--write-sparse-files", filepath.Join(restoreDir, "sparse"))
+	verifyFileSize(t, filepath.Join(restoreDir, "sparse"), size, 3*blkSize)
+
+	e.RunAndExpectSuccess(t, "snapshot", "restore", snapID, "--no-write-sparse-files", filepath.Join(restoreDir, "full"))
+	verifyFileSize(t, filepath.Join(restoreDir, "full"), size, size)
+}
+
 func verifyFileSize(t *testing.T, fname string, logical, physical uint64) {
 	t.Helper()
 
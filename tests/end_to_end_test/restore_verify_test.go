@@ -0,0 +1,45 @@
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotRestoreVerifyAfter(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "sub"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "file1"), []byte{1, 2, 3}, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "sub", "file2"), []byte{4, 5, 6}, 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	snapID := si[0].Snapshots[0].SnapshotID
+
+	restoredDir := testutil.TempDirectory(t)
+	_, stderr := e.RunAndExpectSuccessWithErrOut(t, "snapshot", "restore", "--verify-after", snapID, restoredDir)
+	require.Contains(t, strings.Join(stderr, "\n"), "verified 2 files (0 mismatches)")
+
+	// verification requires reading back restored files, which is not possible for archives.
+	e.RunAndExpectFailure(t, "snapshot", "restore", "--verify-after", snapID, filepath.Join(testutil.TempDirectory(t), "out.zip"))
+}
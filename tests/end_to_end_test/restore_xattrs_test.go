@@ -0,0 +1,83 @@
+//go:build linux
+
+package endtoend_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotRestoreExtendedAttributes(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	source := testutil.TempDirectory(t)
+	require.NoError(t, os.Mkdir(filepath.Join(source, "subdir"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "subdir", "file"), []byte("contents"), 0o600))
+
+	if err := unix.Setxattr(filepath.Join(source, "subdir", "file"), "user.kopia.file", []byte("file-value"), 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skip("extended attributes are not supported")
+		}
+
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, unix.Setxattr(filepath.Join(source, "subdir"), "user.kopia.dir", []byte("dir-value"), 0))
+
+	// extended attributes are not captured by default.
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	e.RunAndExpectSuccess(t, "policy", "set", source, "--capture-xattrs=true")
+	e.RunAndExpectSuccess(t, "snapshot", "create", source)
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e, source)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 2)
+
+	withoutXattrs := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[0].SnapshotID, withoutXattrs)
+	requireNoXattr(t, filepath.Join(withoutXattrs, "subdir", "file"), "user.kopia.file")
+
+	restored := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--no-ignore-permission-errors", si[0].Snapshots[1].SnapshotID, restored)
+	requireXattr(t, filepath.Join(restored, "subdir", "file"), "user.kopia.file", "file-value")
+	requireXattr(t, filepath.Join(restored, "subdir"), "user.kopia.dir", "dir-value")
+
+	skipped := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", "--skip-xattrs", si[0].Snapshots[1].SnapshotID, skipped)
+	requireNoXattr(t, filepath.Join(skipped, "subdir", "file"), "user.kopia.file")
+	requireNoXattr(t, filepath.Join(skipped, "subdir"), "user.kopia.dir")
+}
+
+func requireXattr(t *testing.T, path, name, want string) {
+	t.Helper()
+
+	buf := make([]byte, 1024)
+
+	n, err := unix.Getxattr(path, name, buf)
+	require.NoError(t, err)
+	require.Equal(t, want, string(buf[:n]))
+}
+
+func requireNoXattr(t *testing.T, path, name string) {
+	t.Helper()
+
+	_, err := unix.Getxattr(path, name, nil)
+	require.ErrorIs(t, err, unix.ENODATA)
+}
@@ -0,0 +1,93 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerEnrollment(t *testing.T) {
+	t.Parallel()
+
+	serverRunner := testenv.NewInProcRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+
+	var sp testutil.ServerParameters
+
+	wait, kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+		"--enable-enrollment",
+	)
+
+	defer wait()
+	defer kill()
+
+	controlFlags := []string{
+		"--address", sp.BaseURL,
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+	}
+
+	tokenLines := serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "enrollment", "create-token", "--description", "laptops"}, controlFlags...)...)
+	require.Len(t, tokenLines, 1)
+
+	token := tokenLines[0]
+
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	delete(e.Environment, "KOPIA_PASSWORD")
+
+	enroll := []string{
+		"server", "enroll",
+		"--address", sp.BaseURL,
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+		"--username", "alice@laptop",
+		"--user-password", "alice-pass",
+	}
+
+	e.RunAndExpectFailure(t, append(enroll, "--token", "bad-token")...)
+	e.RunAndExpectSuccess(t, append(enroll, "--token", token)...)
+
+	// the token can be used only once.
+	e.RunAndExpectFailure(t, append(enroll, "--token", token)...)
+
+	connect := []string{
+		"repo", "connect", "server",
+		"--url", sp.BaseURL + "/",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+		"--override-username", "alice",
+		"--override-hostname", "laptop",
+		"--password", "alice-pass",
+	}
+
+	pending := serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "enrollment", "list"}, controlFlags...)...)
+	require.Len(t, pending, 1)
+	require.Contains(t, pending[0], "alice@laptop")
+	require.Contains(t, pending[0], "laptops")
+
+	id := strings.Fields(pending[0])[0]
+
+	serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "enrollment", "approve", id}, controlFlags...)...)
+	serverEnvironment.RunAndExpectFailure(t, append([]string{"server", "enrollment", "reject", id}, controlFlags...)...)
+
+	require.Empty(t, serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "enrollment", "list"}, controlFlags...)...))
+	require.Len(t, serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "enrollment", "list", "--all"}, controlFlags...)...), 1)
+
+	e.RunAndExpectSuccess(t, connect...)
+	e.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+}
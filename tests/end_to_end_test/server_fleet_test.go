@@ -0,0 +1,103 @@
+package endtoend_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerFleet(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+
+	serverRunner := testenv.NewInProcRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "alice@wonderland", "--user-password", "baz")
+
+	// source of the server itself
+	serverDir := testutil.TempDirectory(t)
+	serverEnvironment.RunAndExpectSuccess(t, "snapshot", "create", serverDir)
+
+	var sp testutil.ServerParameters
+
+	wait, kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+	)
+
+	defer wait()
+	defer kill()
+
+	clientEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	defer clientEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	delete(clientEnvironment.Environment, "KOPIA_PASSWORD")
+
+	clientEnvironment.RunAndExpectSuccess(t, "repo", "connect", "server",
+		"--url", sp.BaseURL+"/",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+		"--override-username", "alice",
+		"--override-hostname", "wonderland",
+		"--password", "baz",
+	)
+
+	clientDir := testutil.TempDirectory(t)
+	clientEnvironment.RunAndExpectSuccess(t, "snapshot", "create", clientDir)
+
+	controlClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.BaseURL,
+		Username:                            "admin-user",
+		Password:                            "admin-pwd",
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+	})
+	require.NoError(t, err)
+
+	fleet, err := serverapi.ListFleet(ctx, controlClient)
+	require.NoError(t, err)
+	require.Len(t, fleet.Sources, 2)
+
+	sources := map[string]*serverapi.FleetSource{}
+	for _, s := range fleet.Sources {
+		sources[s.Source.UserName+"@"+s.Source.Host] = s
+	}
+
+	alice := sources["alice@wonderland"]
+	require.NotNil(t, alice)
+	require.Equal(t, clientDir, alice.Source.Path)
+	require.Equal(t, repo.BuildVersion, alice.ClientVersion)
+	require.NotNil(t, alice.LastSeen)
+	require.NotNil(t, alice.LastSnapshotTime)
+	require.NotNil(t, alice.LastSuccessfulSnapshotTime)
+	require.False(t, alice.ServerManaged)
+	require.False(t, alice.Overdue)
+	require.Equal(t, serverapi.FleetHealthOK, alice.Health)
+
+	foo := sources["foo@foo"]
+	require.NotNil(t, foo)
+	require.True(t, foo.ServerManaged)
+	require.Equal(t, serverapi.FleetHealthOK, foo.Health)
+
+	health, err := serverapi.GetFleetHealth(ctx, controlClient)
+	require.NoError(t, err)
+	require.Equal(t, 2, health.TotalSources)
+	require.Equal(t, 2, health.Healthy)
+	require.Equal(t, 1, health.KnownClients)
+	require.Equal(t, 1, health.ClientVersions[repo.BuildVersion])
+}
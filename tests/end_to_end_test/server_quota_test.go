@@ -0,0 +1,160 @@
+package endtoend_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerUserQuotas(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+
+	serverRunner := testenv.NewInProcRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "alice@wonderland", "--user-password", "baz", "--storage-quota-mb=-1")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "bob@builder", "--user-password", "baz")
+
+	var sp testutil.ServerParameters
+
+	wait, kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+		"--user-storage-quota-mb=1",
+		"--user-max-requests-per-second=10000",
+	)
+
+	defer wait()
+	defer kill()
+
+	const dataSize = 2 << 20
+
+	// directory with incompressible data, which exceeds the default quota.
+	makeSourceDir := func() string {
+		dir := testutil.TempDirectory(t)
+		data := make([]byte, dataSize)
+
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "file1"), data, 0o600))
+
+		return dir
+	}
+
+	connect := func(username, hostname string) *testenv.CLITest {
+		e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+		delete(e.Environment, "KOPIA_PASSWORD")
+
+		e.RunAndExpectSuccess(t, "repo", "connect", "server",
+			"--url", sp.BaseURL+"/",
+			"--server-cert-fingerprint", sp.SHA256Fingerprint,
+			"--override-username", username,
+			"--override-hostname", hostname,
+			"--password", "baz",
+		)
+
+		t.Cleanup(func() { e.RunAndExpectSuccess(t, "repo", "disconnect") })
+
+		return e
+	}
+
+	// data is different for each user, so it won't be deduplicated.
+	aliceDir := makeSourceDir()
+
+	// small files are written without checking whether the server already has them.
+	for i := range 64 {
+		data := make([]byte, 32<<10)
+
+		_, err := rand.Read(data)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(aliceDir, fmt.Sprintf("small%v", i)), data, 0o600))
+	}
+
+	bobEnv := connect("bob", "builder")
+
+	connect("alice", "wonderland").RunAndExpectSuccess(t, "snapshot", "create", aliceDir)
+	bobEnv.RunAndExpectFailure(t, "snapshot", "create", makeSourceDir())
+
+	controlClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.BaseURL,
+		Username:                            "admin-user",
+		Password:                            "admin-pwd",
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+	})
+	require.NoError(t, err)
+
+	usage, err := serverapi.ListQuotaUsage(ctx, controlClient)
+	require.NoError(t, err)
+
+	users := map[string]*serverapi.UserQuotaUsage{}
+	for _, u := range usage.Users {
+		users[u.Username] = u
+	}
+
+	alice := users["alice@wonderland"]
+	require.NotNil(t, alice)
+	require.Zero(t, alice.MaxStorageBytes)
+	require.EqualValues(t, 10000, alice.MaxRequestsPerSecond)
+	require.GreaterOrEqual(t, alice.StorageBytes, int64(dataSize))
+	require.Zero(t, alice.RejectedWrites)
+	require.Positive(t, alice.Requests)
+
+	bob := users["bob@builder"]
+	require.NotNil(t, bob)
+	require.EqualValues(t, 1<<20, bob.MaxStorageBytes)
+	require.LessOrEqual(t, bob.StorageBytes, bob.MaxStorageBytes)
+	require.Positive(t, bob.RejectedWrites)
+
+	require.NoError(t, serverapi.ResetQuotaUsage(ctx, controlClient, "bob@builder"))
+	require.Error(t, serverapi.ResetQuotaUsage(ctx, controlClient, "no-such@user"))
+
+	usage, err = serverapi.ListQuotaUsage(ctx, controlClient)
+	require.NoError(t, err)
+
+	for _, u := range usage.Users {
+		if u.Username == "bob@builder" {
+			require.Zero(t, u.RejectedWrites)
+			require.Zero(t, u.StorageBytes)
+		}
+	}
+
+	// contents already in the repository don't count towards the quota.
+	bobEnv.RunAndExpectSuccess(t, "snapshot", "create", aliceDir)
+
+	usage, err = serverapi.ListQuotaUsage(ctx, controlClient)
+	require.NoError(t, err)
+
+	for _, u := range usage.Users {
+		if u.Username == "bob@builder" {
+			require.Zero(t, u.RejectedWrites)
+			require.Less(t, u.StorageBytes, int64(dataSize))
+		}
+	}
+
+	require.Len(t, serverEnvironment.RunAndExpectSuccess(t, "server", "quota", "list",
+		"--address", sp.BaseURL,
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+	), 2)
+}
@@ -0,0 +1,63 @@
+package endtoend_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerReadOnlyClient(t *testing.T) {
+	t.Parallel()
+
+	serverRunner := testenv.NewInProcRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "alice@wonderland", "--user-password", "baz")
+
+	var sp testutil.ServerParameters
+
+	wait, kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+	)
+
+	defer wait()
+	defer kill()
+
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	delete(e.Environment, "KOPIA_PASSWORD")
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "server",
+		"--url", sp.BaseURL+"/",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+		"--override-username", "alice",
+		"--override-hostname", "wonderland",
+		"--password", "baz",
+		"--readonly",
+	)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	_, stderr := e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1)
+	require.Contains(t, strings.Join(stderr, "\n"), "read-only")
+
+	// reads are still allowed.
+	require.Empty(t, clitestutil.ListSnapshotsAndExpectSuccess(t, e))
+
+	e.RunAndExpectSuccess(t, "repo", "set-client", "--read-write")
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+	require.Len(t, clitestutil.ListSnapshotsAndExpectSuccess(t, e), 1)
+}
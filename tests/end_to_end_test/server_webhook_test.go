@@ -0,0 +1,162 @@
+package endtoend_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/apiclient"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/internal/webhook"
+	"github.com/kopia/kopia/serverapi"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerWebhooks(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+
+	const secret = "s3cret"
+
+	events := make(chan *webhook.Event, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ts, err := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+		if err != nil || r.Header.Get(webhook.SignatureHeader) != webhook.Signature(secret, ts, body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		ev := &webhook.Event{}
+		if err := json.Unmarshal(body, ev); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		events <- ev
+	}))
+	defer receiver.Close()
+
+	nextEvent := func(eventType string) *webhook.Event {
+		t.Helper()
+
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == eventType {
+					return ev
+				}
+
+			case <-time.After(30 * time.Second):
+				t.Fatalf("timed out waiting for %v event", eventType)
+			}
+		}
+	}
+
+	serverRunner := testenv.NewInProcRunner(t)
+	serverEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, serverRunner)
+
+	defer serverEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	serverEnvironment.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", serverEnvironment.RepoDir, "--override-hostname=foo", "--override-username=foo")
+	serverEnvironment.RunAndExpectSuccess(t, "server", "users", "add", "alice@wonderland", "--user-password", "baz")
+
+	var sp testutil.ServerParameters
+
+	wait, kill := serverEnvironment.RunAndProcessStderr(t, sp.ProcessOutput,
+		"server", "start",
+		"--address=localhost:0",
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--tls-generate-cert",
+		"--tls-generate-rsa-key-size=2048", // use shorter key size to speed up generation
+	)
+
+	defer wait()
+	defer kill()
+
+	controlFlags := []string{
+		"--address", sp.BaseURL,
+		"--server-control-username=admin-user",
+		"--server-control-password=admin-pwd",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+	}
+
+	serverEnvironment.RunAndExpectFailure(t, append([]string{"server", "webhook", "set", "bad", "--url", "not-a-url", "--event", "snapshot-finished"}, controlFlags...)...)
+
+	// the storage is practically never this empty, so low storage is reported as soon as the webhook is set.
+	serverEnvironment.RunAndExpectSuccess(t, append([]string{
+		"server", "webhook", "set", "hook1",
+		"--url", receiver.URL,
+		"--event", "snapshot-finished",
+		"--event", "low-storage",
+		"--low-storage-free-percent", "99.999",
+		"--secret", secret,
+	}, controlFlags...)...)
+
+	ev := nextEvent(webhook.EventLowStorage)
+	require.NotNil(t, ev.Storage)
+	require.Less(t, ev.Storage.FreePercent, 99.999)
+
+	serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "webhook", "test", "hook1"}, controlFlags...)...)
+	nextEvent(webhook.EventTest)
+
+	controlClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             sp.BaseURL,
+		Username:                            "admin-user",
+		Password:                            "admin-pwd",
+		TrustedServerCertificateFingerprint: sp.SHA256Fingerprint,
+	})
+	require.NoError(t, err)
+
+	hooks, err := serverapi.ListWebhooks(ctx, controlClient)
+	require.NoError(t, err)
+	require.Len(t, hooks.Webhooks, 1)
+	require.Equal(t, "hook1", hooks.Webhooks[0].Name)
+	require.True(t, hooks.Webhooks[0].HasSecret)
+	require.Empty(t, hooks.Webhooks[0].Secret)
+
+	// snapshots written by repository clients are reported.
+	clientEnvironment := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, testenv.NewInProcRunner(t))
+
+	defer clientEnvironment.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	delete(clientEnvironment.Environment, "KOPIA_PASSWORD")
+
+	clientEnvironment.RunAndExpectSuccess(t, "repo", "connect", "server",
+		"--url", sp.BaseURL+"/",
+		"--server-cert-fingerprint", sp.SHA256Fingerprint,
+		"--override-username", "alice",
+		"--override-hostname", "wonderland",
+		"--password", "baz",
+	)
+
+	clientEnvironment.RunAndExpectSuccess(t, "snapshot", "create", testutil.TempDirectory(t))
+
+	ev = nextEvent(webhook.EventSnapshotFinished)
+	require.NotNil(t, ev.Source)
+	require.Equal(t, "alice", ev.Source.UserName)
+	require.Equal(t, "wonderland", ev.Source.Host)
+	require.NotEmpty(t, ev.SnapshotID)
+
+	serverEnvironment.RunAndExpectSuccess(t, append([]string{"server", "webhook", "delete", "hook1"}, controlFlags...)...)
+	serverEnvironment.RunAndExpectFailure(t, append([]string{"server", "webhook", "delete", "hook1"}, controlFlags...)...)
+
+	hooks, err = serverapi.ListWebhooks(ctx, controlClient)
+	require.NoError(t, err)
+	require.Empty(t, hooks.Webhooks)
+}
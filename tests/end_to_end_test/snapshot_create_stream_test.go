@@ -0,0 +1,115 @@
+package endtoend_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/clitestutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotCreateStreamFromTar(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	modTime := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	for _, h := range []*tar.Header{
+		{Name: "db/", Typeflag: tar.TypeDir, Mode: 0o750},
+		{Name: "db/dump.sql", Typeflag: tar.TypeReg, Mode: 0o600, Size: 6},
+		{Name: "db/schema/tables.sql", Typeflag: tar.TypeReg, Mode: 0o640, Size: 6},
+		{Name: "README", Typeflag: tar.TypeReg, Mode: 0o644, Size: 6},
+	} {
+		h.ModTime = modTime
+		require.NoError(t, tw.WriteHeader(h))
+
+		_, err := tw.Write([]byte(strings.Repeat("x", int(h.Size))))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+
+	tarData := buf.Bytes()
+
+	runner.SetNextStdin(bytes.NewReader(tarData))
+	e.RunAndExpectSuccess(t, "snapshot", "create-stream", "postgres")
+
+	// second snapshot of the same stream reuses contents of the previous one.
+	runner.SetNextStdin(bytes.NewReader(tarData))
+	e.RunAndExpectSuccess(t, "snapshot", "create-stream", "postgres", "--description", "second")
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 2)
+
+	restoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[1].SnapshotID, restoreDir)
+
+	st, err := os.Stat(filepath.Join(restoreDir, "db", "dump.sql"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), st.Mode().Perm())
+	require.True(t, modTime.Equal(st.ModTime()))
+
+	data, err := os.ReadFile(filepath.Join(restoreDir, "db", "schema", "tables.sql"))
+	require.NoError(t, err)
+	require.Equal(t, "xxxxxx", string(data))
+
+	require.FileExists(t, filepath.Join(restoreDir, "README"))
+
+	// stream entries that are not grouped by directory cannot be snapshotted in a single pass.
+	buf.Reset()
+
+	tw = tar.NewWriter(&buf)
+	for _, name := range []string{"a/1", "b/2", "a/3"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644}))
+	}
+
+	require.NoError(t, tw.Close())
+
+	runner.SetNextStdin(&buf)
+	_, stderr := e.RunAndExpectFailure(t, "snapshot", "create-stream", "out-of-order", "--fail-fast")
+	require.Contains(t, strings.Join(stderr, "\n"), `entry "a/3" appears in stream after its directory has been processed`)
+}
+
+func TestSnapshotCreateStreamRaw(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	e.RunAndExpectFailure(t, "snapshot", "create-stream", "dump", "--format=raw")
+
+	runner.SetNextStdin(strings.NewReader("pg_dump output"))
+	e.RunAndExpectSuccess(t, "snapshot", "create-stream", "dump", "--format=raw", "--file-name=db.sql")
+
+	si := clitestutil.ListSnapshotsAndExpectSuccess(t, e)
+	require.Len(t, si, 1)
+	require.Len(t, si[0].Snapshots, 1)
+
+	restoreDir := testutil.TempDirectory(t)
+	e.RunAndExpectSuccess(t, "snapshot", "restore", si[0].Snapshots[0].ObjectID+"/db.sql", filepath.Join(restoreDir, "db.sql"))
+
+	data, err := os.ReadFile(filepath.Join(restoreDir, "db.sql"))
+	require.NoError(t, err)
+	require.Equal(t, "pg_dump output", string(data))
+}
@@ -20,6 +20,7 @@
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 	"github.com/kopia/kopia/tests/clitestutil"
 	"github.com/kopia/kopia/tests/testenv"
 )
@@ -797,3 +798,42 @@ func TestSnapshotCreateWithAllAndPath(t *testing.T) {
 	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir2)
 	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1, "--all")
 }
+
+func TestSnapshotCreateChangedOnly(t *testing.T) {
+	t.Parallel()
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, testenv.RepoFormatNotImportant, runner)
+
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	td := testutil.TempDirectory(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(td, "a"), []byte("aaa"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "b"), []byte("bbb"), 0o600))
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", td)
+
+	require.NoError(t, os.WriteFile(filepath.Join(td, "b"), []byte("bbbbb"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(td, "c"), []byte("cccccccc"), 0o600))
+
+	var report snapshotfs.ChangeReport
+
+	testutil.MustParseJSONLines(t, e.RunAndExpectSuccess(t, "snapshot", "create", td, "--changed-only", "--json"), &report)
+
+	require.Equal(t, []snapshotfs.ChangedFile{
+		{Path: "b", Kind: snapshotfs.FileChangeModified, Size: 5},
+		{Path: "c", Kind: snapshotfs.FileChangeNew, Size: 8},
+	}, report.Files)
+	require.Equal(t, 1, report.UnchangedFiles)
+
+	lines := e.RunAndExpectSuccess(t, "snapshot", "create", td, "--changed-only")
+	require.Contains(t, lines, "New files: 1 (8 B), modified files: 1 (5 B), unchanged files: 1.")
+
+	// no snapshots were created.
+	sources := clitestutil.ListSnapshotsAndExpectSuccess(t, e, td)
+	require.Len(t, sources, 1)
+	require.Len(t, sources[0].Snapshots, 1)
+}